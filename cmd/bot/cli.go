@@ -0,0 +1,535 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/valpere/shopogoda/internal/bot"
+	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/internal/services"
+	"github.com/valpere/shopogoda/pkg/metrics"
+)
+
+// Exit codes of the operational subcommands
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+const cliUsage = `Usage: shopogoda [flags] [command]
+
+Without a command the bot is started. Commands:
+
+  user info <id>                  Show a user's account
+  user promote <id> <role>        Change a user's role (user, moderator, admin)
+  export --user <id> [--type all|weather|alerts|subscriptions]
+         [--format json|csv|txt] [--out file|-] [--lang code]
+                                  Export a user's data like /export does
+  stats                           Show system statistics
+  migrate                         Bring the database schema up to date
+  cleanup [--dry-run]             Delete dead letters past their retention
+
+Every command accepts --json for machine-readable output. Commands that
+change data ask for confirmation unless --yes is given.
+`
+
+var (
+	// errUsage marks mistakes in the command line itself
+	errUsage = errors.New("invalid arguments")
+
+	// errAborted is returned when the operator declines a confirmation
+	errAborted = errors.New("aborted")
+)
+
+func usageErrorf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", errUsage, fmt.Sprintf(format, args...))
+}
+
+// command is a parsed subcommand line
+type command struct {
+	name       string
+	userID     int64
+	role       models.UserRole
+	exportType services.ExportType
+	format     services.ExportFormat
+	out        string
+	lang       string
+	dryRun     bool
+	yes        bool
+	json       bool
+}
+
+// parseCommand parses the arguments after the global flags. Flags may come
+// before or after positional arguments.
+func parseCommand(args []string) (*command, error) {
+	if len(args) == 0 {
+		return nil, usageErrorf("no command given")
+	}
+
+	cmd := &command{name: args[0]}
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&cmd.json, "json", false, "print machine-readable JSON")
+
+	args = args[1:]
+	switch cmd.name {
+	case "user":
+		if len(args) == 0 {
+			return nil, usageErrorf("user needs a subcommand: info or promote")
+		}
+		cmd.name += " " + args[0]
+		args = args[1:]
+
+		switch cmd.name {
+		case "user info":
+			positional, err := parseFlags(fs, args)
+			if err != nil {
+				return nil, err
+			}
+			if len(positional) != 1 {
+				return nil, usageErrorf("user info takes exactly one user id")
+			}
+			if cmd.userID, err = parseUserID(positional[0]); err != nil {
+				return nil, err
+			}
+		case "user promote":
+			fs.BoolVar(&cmd.yes, "yes", false, "do not ask for confirmation")
+			positional, err := parseFlags(fs, args)
+			if err != nil {
+				return nil, err
+			}
+			if len(positional) != 2 {
+				return nil, usageErrorf("user promote takes a user id and a role")
+			}
+			if cmd.userID, err = parseUserID(positional[0]); err != nil {
+				return nil, err
+			}
+			if cmd.role, err = parseRole(positional[1]); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, usageErrorf("unknown command %q", cmd.name)
+		}
+
+	case "export":
+		var userID, exportType, format string
+		fs.StringVar(&userID, "user", "", "id of the user to export")
+		fs.StringVar(&exportType, "type", string(services.ExportTypeAll), "data to export")
+		fs.StringVar(&format, "format", string(services.ExportFormatJSON), "file format")
+		fs.StringVar(&cmd.out, "out", "", "output file, - for stdout")
+		fs.StringVar(&cmd.lang, "lang", "en-US", "language of labels in the export")
+		positional, err := parseFlags(fs, args)
+		if err != nil {
+			return nil, err
+		}
+		if len(positional) > 0 {
+			return nil, usageErrorf("export takes no arguments, got %q", positional[0])
+		}
+		if userID == "" {
+			return nil, usageErrorf("export needs --user")
+		}
+		if cmd.userID, err = parseUserID(userID); err != nil {
+			return nil, err
+		}
+
+		cmd.exportType = services.ExportType(exportType)
+		switch cmd.exportType {
+		case services.ExportTypeAll, services.ExportTypeWeatherData, services.ExportTypeAlerts, services.ExportTypeSubscriptions:
+		default:
+			return nil, usageErrorf("unknown export type %q", exportType)
+		}
+
+		cmd.format = services.ExportFormat(format)
+		switch cmd.format {
+		case services.ExportFormatJSON, services.ExportFormatCSV, services.ExportFormatTXT:
+		default:
+			return nil, usageErrorf("unknown export format %q", format)
+		}
+
+	case "stats", "migrate", "cleanup":
+		if cmd.name == "cleanup" {
+			fs.BoolVar(&cmd.dryRun, "dry-run", false, "only report what would be deleted")
+			fs.BoolVar(&cmd.yes, "yes", false, "do not ask for confirmation")
+		}
+		positional, err := parseFlags(fs, args)
+		if err != nil {
+			return nil, err
+		}
+		if len(positional) > 0 {
+			return nil, usageErrorf("%s takes no arguments, got %q", cmd.name, positional[0])
+		}
+
+	default:
+		return nil, usageErrorf("unknown command %q", cmd.name)
+	}
+
+	return cmd, nil
+}
+
+// parseFlags parses fs and returns the positional arguments found between
+// the flags
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, usageErrorf("%v", err)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func parseUserID(value string) (int64, error) {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id == 0 {
+		return 0, usageErrorf("invalid user id %q", value)
+	}
+	return id, nil
+}
+
+func parseRole(value string) (models.UserRole, error) {
+	switch strings.ToLower(value) {
+	case "user":
+		return models.RoleUser, nil
+	case "moderator", "mod":
+		return models.RoleModerator, nil
+	case "admin":
+		return models.RoleAdmin, nil
+	}
+	return 0, usageErrorf("unknown role %q, expected user, moderator or admin", value)
+}
+
+// cli runs operational subcommands against the service layer, without
+// starting Telegram. Results go to stdout, prompts and errors to stderr.
+type cli struct {
+	stdin   *bufio.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+	connect func() (*services.Services, error)
+}
+
+// runCLI runs a subcommand with the configuration from the environment and
+// returns the process exit code
+func runCLI(args []string) int {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	c := &cli{
+		stdin:   bufio.NewReader(os.Stdin),
+		stdout:  os.Stdout,
+		stderr:  os.Stderr,
+		connect: connectServices,
+	}
+	return c.run(ctx, args)
+}
+
+// connectServices builds the services like the bot does, with logs on
+// stderr so that stdout only carries the command's output
+func connectServices() (*services.Services, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	gormlogger.Default = gormlogger.New(log.New(os.Stderr, "\r\n", log.LstdFlags), gormlogger.Config{
+		SlowThreshold: 200 * time.Millisecond,
+		LogLevel:      gormlogger.Warn,
+	})
+	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).
+		Level(zerolog.WarnLevel).
+		With().
+		Timestamp().
+		Str("component", "cli").
+		Logger()
+
+	return bot.NewServices(cfg, &logger, metrics.New())
+}
+
+func (c *cli) run(ctx context.Context, args []string) int {
+	cmd, err := parseCommand(args)
+	if err == nil {
+		err = c.execute(ctx, cmd)
+	}
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(c.stderr, "Error: %v\n\n%s", err, cliUsage)
+		return exitUsage
+	case errors.Is(err, errAborted):
+		fmt.Fprintln(c.stderr, "Aborted, nothing was changed")
+		return exitFailure
+	default:
+		fmt.Fprintf(c.stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+}
+
+func (c *cli) execute(ctx context.Context, cmd *command) error {
+	svcs, err := c.connect()
+	if err != nil {
+		return err
+	}
+
+	switch cmd.name {
+	case "user info":
+		return c.userInfo(ctx, svcs, cmd)
+	case "user promote":
+		return c.userPromote(ctx, svcs, cmd)
+	case "export":
+		return c.export(ctx, svcs, cmd)
+	case "stats":
+		return c.stats(ctx, svcs, cmd)
+	case "migrate":
+		return c.migrate(ctx, svcs, cmd)
+	case "cleanup":
+		return c.cleanup(ctx, svcs, cmd)
+	}
+	return usageErrorf("unknown command %q", cmd.name)
+}
+
+// confirm asks a yes/no question on stderr; anything but yes declines
+func (c *cli) confirm(cmd *command, question string) error {
+	if cmd.yes {
+		return nil
+	}
+
+	fmt.Fprintf(c.stderr, "%s [y/N] ", question)
+	answer, err := c.stdin.ReadString('\n')
+	if err != nil && answer == "" {
+		return errAborted
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return errAborted
+}
+
+func (c *cli) printJSON(v any) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func (c *cli) getUser(ctx context.Context, svcs *services.Services, userID int64) (*models.User, error) {
+	user, err := svcs.User.GetUser(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("user %d not found", userID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user %d: %w", userID, err)
+	}
+	return user, nil
+}
+
+func (c *cli) userInfo(ctx context.Context, svcs *services.Services, cmd *command) error {
+	user, err := c.getUser(ctx, svcs, cmd.userID)
+	if err != nil {
+		return err
+	}
+	if cmd.json {
+		return c.printJSON(user)
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID:\t%d\n", user.ID)
+	if user.Username != "" {
+		fmt.Fprintf(w, "Username:\t@%s\n", user.Username)
+	}
+	fmt.Fprintf(w, "Name:\t%s\n", strings.TrimSpace(user.FirstName+" "+user.LastName))
+	fmt.Fprintf(w, "Role:\t%s\n", svcs.User.GetRoleName(user.Role))
+	fmt.Fprintf(w, "Active:\t%t\n", user.IsActive)
+	fmt.Fprintf(w, "Language:\t%s\n", user.Language)
+	fmt.Fprintf(w, "Timezone:\t%s\n", user.Timezone)
+	if user.LocationName != "" {
+		fmt.Fprintf(w, "Location:\t%s (%.4f, %.4f)\n", user.LocationName, user.Latitude, user.Longitude)
+	} else {
+		fmt.Fprintf(w, "Location:\tnot set\n")
+	}
+	fmt.Fprintf(w, "Joined:\t%s\n", user.CreatedAt.UTC().Format(time.RFC3339))
+	return w.Flush()
+}
+
+func (c *cli) userPromote(ctx context.Context, svcs *services.Services, cmd *command) error {
+	user, err := c.getUser(ctx, svcs, cmd.userID)
+	if err != nil {
+		return err
+	}
+
+	oldRole := svcs.User.GetRoleName(user.Role)
+	newRole := svcs.User.GetRoleName(cmd.role)
+	if user.Role != cmd.role {
+		if err := c.confirm(cmd, fmt.Sprintf("Change the role of user %d from %s to %s?", user.ID, oldRole, newRole)); err != nil {
+			return err
+		}
+		if err := svcs.User.AssignRole(ctx, user.ID, cmd.role); err != nil {
+			return err
+		}
+	}
+
+	if cmd.json {
+		return c.printJSON(map[string]any{
+			"user_id":  user.ID,
+			"old_role": oldRole,
+			"new_role": newRole,
+			"changed":  user.Role != cmd.role,
+		})
+	}
+	if user.Role == cmd.role {
+		fmt.Fprintf(c.stdout, "User %d already has the role %s\n", user.ID, newRole)
+		return nil
+	}
+	fmt.Fprintf(c.stdout, "User %d changed from %s to %s\n", user.ID, oldRole, newRole)
+	return nil
+}
+
+func (c *cli) export(ctx context.Context, svcs *services.Services, cmd *command) error {
+	buffer, filename, err := svcs.Export.ExportUserData(ctx, cmd.userID, cmd.exportType, cmd.format, cmd.lang)
+	if err != nil {
+		return err
+	}
+
+	if cmd.out == "-" {
+		_, err := c.stdout.Write(buffer.Bytes())
+		return err
+	}
+
+	out := cmd.out
+	if out == "" {
+		out = filename
+	}
+	size := buffer.Len()
+	// Exports hold personal data, so only the owner may read the file
+	if err := os.WriteFile(out, buffer.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+
+	if cmd.json {
+		return c.printJSON(map[string]any{"file": out, "bytes": size})
+	}
+	fmt.Fprintf(c.stdout, "Exported %d bytes to %s\n", size, out)
+	return nil
+}
+
+// statsOutput holds the statistics read from the database and Redis; the
+// process metrics of SystemStats describe the bot, not this command
+type statsOutput struct {
+	TotalUsers          int64 `json:"total_users"`
+	ActiveUsers         int64 `json:"active_users"`
+	NewUsers24h         int64 `json:"new_users_24h"`
+	DailyActiveUsers    int64 `json:"daily_active_users"`
+	WeeklyActiveUsers   int64 `json:"weekly_active_users"`
+	MonthlyActiveUsers  int64 `json:"monthly_active_users"`
+	UsersWithLocation   int64 `json:"users_with_location"`
+	ActiveSubscriptions int64 `json:"active_subscriptions"`
+	AlertsConfigured    int64 `json:"alerts_configured"`
+	MessagesSent24h     int64 `json:"messages_sent_24h"`
+	WeatherRequests24h  int64 `json:"weather_requests_24h"`
+}
+
+func (c *cli) stats(ctx context.Context, svcs *services.Services, cmd *command) error {
+	stats, err := svcs.User.GetSystemStats(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get system stats: %w", err)
+	}
+
+	out := statsOutput{
+		TotalUsers:          stats.TotalUsers,
+		ActiveUsers:         stats.ActiveUsers,
+		NewUsers24h:         stats.NewUsers24h,
+		DailyActiveUsers:    stats.DailyActiveUsers,
+		WeeklyActiveUsers:   stats.WeeklyActiveUsers,
+		MonthlyActiveUsers:  stats.MonthlyActiveUsers,
+		UsersWithLocation:   stats.UsersWithLocation,
+		ActiveSubscriptions: stats.ActiveSubscriptions,
+		AlertsConfigured:    stats.AlertsConfigured,
+		MessagesSent24h:     stats.MessagesSent24h,
+		WeatherRequests24h:  stats.WeatherRequests24h,
+	}
+	if cmd.json {
+		return c.printJSON(out)
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Total users:\t%d\n", out.TotalUsers)
+	fmt.Fprintf(w, "Active users:\t%d\n", out.ActiveUsers)
+	fmt.Fprintf(w, "New users (24h):\t%d\n", out.NewUsers24h)
+	fmt.Fprintf(w, "Seen (24h / 7d / 30d):\t%d / %d / %d\n", out.DailyActiveUsers, out.WeeklyActiveUsers, out.MonthlyActiveUsers)
+	fmt.Fprintf(w, "Users with location:\t%d\n", out.UsersWithLocation)
+	fmt.Fprintf(w, "Active subscriptions:\t%d\n", out.ActiveSubscriptions)
+	fmt.Fprintf(w, "Alerts configured:\t%d\n", out.AlertsConfigured)
+	fmt.Fprintf(w, "Messages (24h):\t%d\n", out.MessagesSent24h)
+	fmt.Fprintf(w, "Weather requests (24h):\t%d\n", out.WeatherRequests24h)
+	return w.Flush()
+}
+
+func (c *cli) migrate(ctx context.Context, svcs *services.Services, cmd *command) error {
+	if err := svcs.Migrate(ctx); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
+	if cmd.json {
+		return c.printJSON(map[string]any{"migrated": true})
+	}
+	fmt.Fprintln(c.stdout, "Database schema is up to date")
+	return nil
+}
+
+func (c *cli) cleanup(ctx context.Context, svcs *services.Services, cmd *command) error {
+	expired, err := svcs.Outbox.CountExpired(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to count expired dead letters: %w", err)
+	}
+
+	deleted := int64(0)
+	if !cmd.dryRun && expired > 0 {
+		if err := c.confirm(cmd, fmt.Sprintf("Delete %d expired dead letters?", expired)); err != nil {
+			return err
+		}
+		if deleted, err = svcs.Outbox.PurgeExpired(ctx); err != nil {
+			return fmt.Errorf("failed to purge dead letters: %w", err)
+		}
+	}
+
+	if cmd.json {
+		return c.printJSON(map[string]any{
+			"expired_dead_letters": expired,
+			"deleted":              deleted,
+			"dry_run":              cmd.dryRun,
+		})
+	}
+	switch {
+	case cmd.dryRun:
+		fmt.Fprintf(c.stdout, "%d expired dead letters would be deleted\n", expired)
+	case expired == 0:
+		fmt.Fprintln(c.stdout, "Nothing to clean up")
+	default:
+		fmt.Fprintf(c.stdout, "Deleted %d expired dead letters\n", deleted)
+	}
+	return nil
+}
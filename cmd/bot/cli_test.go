@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/locales"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/internal/services"
+	"github.com/valpere/shopogoda/pkg/metrics"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want *command
+	}{
+		{
+			name: "user info",
+			args: []string{"user", "info", "42"},
+			want: &command{name: "user info", userID: 42},
+		},
+		{
+			name: "flags after positional arguments",
+			args: []string{"user", "info", "42", "--json"},
+			want: &command{name: "user info", userID: 42, json: true},
+		},
+		{
+			name: "user promote",
+			args: []string{"user", "promote", "--yes", "42", "Admin"},
+			want: &command{name: "user promote", userID: 42, role: models.RoleAdmin, yes: true},
+		},
+		{
+			name: "export with defaults",
+			args: []string{"export", "--user", "42"},
+			want: &command{name: "export", userID: 42, exportType: services.ExportTypeAll, format: services.ExportFormatJSON, lang: "en-US"},
+		},
+		{
+			name: "export with every flag",
+			args: []string{"export", "--user=42", "--type", "alerts", "--format", "csv", "--out", "alerts.csv", "--lang", "uk-UA"},
+			want: &command{name: "export", userID: 42, exportType: services.ExportTypeAlerts, format: services.ExportFormatCSV, out: "alerts.csv", lang: "uk-UA"},
+		},
+		{
+			name: "cleanup dry run",
+			args: []string{"cleanup", "--dry-run", "--json"},
+			want: &command{name: "cleanup", dryRun: true, json: true},
+		},
+		{
+			name: "stats",
+			args: []string{"stats"},
+			want: &command{name: "stats"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := parseCommand(tt.args)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, cmd)
+		})
+	}
+}
+
+func TestParseCommand_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		message string
+	}{
+		{"unknown command", []string{"frobnicate"}, `unknown command "frobnicate"`},
+		{"user without subcommand", []string{"user"}, "needs a subcommand"},
+		{"unknown user subcommand", []string{"user", "delete", "42"}, `unknown command "user delete"`},
+		{"missing user id", []string{"user", "info"}, "exactly one user id"},
+		{"invalid user id", []string{"user", "info", "bob"}, `invalid user id "bob"`},
+		{"unknown role", []string{"user", "promote", "42", "owner"}, `unknown role "owner"`},
+		{"export without user", []string{"export"}, "needs --user"},
+		{"unknown export type", []string{"export", "--user", "42", "--type", "photos"}, `unknown export type "photos"`},
+		{"unknown export format", []string{"export", "--user", "42", "--format", "xml"}, `unknown export format "xml"`},
+		{"unknown flag", []string{"stats", "--verbose"}, "flag provided but not defined"},
+		{"unexpected argument", []string{"migrate", "now"}, `migrate takes no arguments, got "now"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseCommand(tt.args)
+			require.ErrorIs(t, err, errUsage)
+			assert.Contains(t, err.Error(), tt.message)
+		})
+	}
+}
+
+// newTestCLI returns a CLI whose services run against mocked storage
+func newTestCLI(t *testing.T, input string) (*cli, *helpers.MockDB, *helpers.MockRedis, *bytes.Buffer, *bytes.Buffer) {
+	mockDB := helpers.NewMockDB(t)
+	t.Cleanup(func() { _ = mockDB.Close() })
+	mockRedis := helpers.NewMockRedis()
+
+	logger := helpers.NewSilentTestLogger()
+	svcs := services.New(mockDB.DB, mockRedis.Client, helpers.GetTestConfig(), logger, metrics.New())
+	require.NoError(t, svcs.Localization.LoadTranslations(locales.LocalesFS))
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	c := &cli{
+		stdin:   bufio.NewReader(strings.NewReader(input)),
+		stdout:  stdout,
+		stderr:  stderr,
+		connect: func() (*services.Services, error) { return svcs, nil },
+	}
+	return c, mockDB, mockRedis, stdout, stderr
+}
+
+func expectUserQuery(mockDB *helpers.MockDB, mockRedis *helpers.MockRedis, user *models.User) {
+	mockRedis.Mock.ExpectGet("user:42").RedisNil()
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
+		WithArgs(user.ID, 1).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id", "username", "first_name", "last_name", "language", "role", "is_active", "location_name", "latitude", "longitude"}).
+			AddRow(user.ID, user.Username, user.FirstName, user.LastName, user.Language, user.Role, user.IsActive, user.LocationName, user.Latitude, user.Longitude))
+}
+
+func TestCLI_UserInfo(t *testing.T) {
+	user := &models.User{ID: 42, Username: "olena", FirstName: "Olena", LastName: "K", Language: "uk-UA", Role: models.RoleModerator, IsActive: true, LocationName: "Kyiv", Latitude: 50.45, Longitude: 30.52}
+
+	t.Run("text", func(t *testing.T) {
+		c, mockDB, mockRedis, stdout, _ := newTestCLI(t, "")
+		expectUserQuery(mockDB, mockRedis, user)
+
+		code := c.run(context.Background(), []string{"user", "info", "42"})
+
+		assert.Equal(t, exitOK, code)
+		assert.Contains(t, stdout.String(), "Username:  @olena")
+		assert.Contains(t, stdout.String(), "Role:      Moderator")
+		assert.Contains(t, stdout.String(), "Location:  Kyiv (50.4500, 30.5200)")
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		c, mockDB, mockRedis, stdout, _ := newTestCLI(t, "")
+		expectUserQuery(mockDB, mockRedis, user)
+
+		code := c.run(context.Background(), []string{"user", "info", "--json", "42"})
+
+		require.Equal(t, exitOK, code)
+		var got models.User
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &got))
+		assert.Equal(t, int64(42), got.ID)
+		assert.Equal(t, models.RoleModerator, got.Role)
+	})
+
+	t.Run("unknown user fails", func(t *testing.T) {
+		c, mockDB, mockRedis, stdout, stderr := newTestCLI(t, "")
+		mockRedis.Mock.ExpectGet("user:7").RedisNil()
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users"`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}))
+
+		code := c.run(context.Background(), []string{"user", "info", "7"})
+
+		assert.Equal(t, exitFailure, code)
+		assert.Empty(t, stdout.String())
+		assert.Contains(t, stderr.String(), "user 7 not found")
+	})
+
+	t.Run("usage error", func(t *testing.T) {
+		c, _, _, _, stderr := newTestCLI(t, "")
+
+		code := c.run(context.Background(), []string{"user", "info"})
+
+		assert.Equal(t, exitUsage, code)
+		assert.Contains(t, stderr.String(), "Usage: shopogoda")
+	})
+}
+
+func TestCLI_UserPromote_Declined(t *testing.T) {
+	c, mockDB, mockRedis, stdout, stderr := newTestCLI(t, "n\n")
+	expectUserQuery(mockDB, mockRedis, &models.User{ID: 42, Role: models.RoleUser})
+
+	code := c.run(context.Background(), []string{"user", "promote", "42", "admin"})
+
+	assert.Equal(t, exitFailure, code)
+	assert.Empty(t, stdout.String())
+	assert.Contains(t, stderr.String(), "Change the role of user 42 from User to Admin? [y/N]")
+	assert.Contains(t, stderr.String(), "Aborted")
+	// No role update was issued
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestCLI_Export(t *testing.T) {
+	c, mockDB, _, stdout, _ := newTestCLI(t, "")
+
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
+		WithArgs(int64(42), 1).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id", "username"}).AddRow(42, "olena"))
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "weather_records"`).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id", "user_id"}))
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "subscriptions"`).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id", "user_id"}))
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "alert_configs"`).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id", "user_id"}))
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "environmental_alerts"`).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id", "user_id"}))
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "audit_logs"`).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id", "actor_id"}))
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectExec(`INSERT INTO "export_cursors"`).
+		WillReturnResult(helpers.NewResult(1, 1))
+	mockDB.Mock.ExpectCommit()
+
+	out := filepath.Join(t.TempDir(), "export.json")
+	code := c.run(context.Background(), []string{"export", "--user", "42", "--type", "all", "--format", "json", "--out", out, "--json"})
+
+	require.Equal(t, exitOK, code)
+	mockDB.ExpectationsWereMet(t)
+
+	var result struct {
+		File  string `json:"file"`
+		Bytes int    `json:"bytes"`
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &result))
+	assert.Equal(t, out, result.File)
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Len(t, data, result.Bytes)
+
+	var exported services.ExportData
+	require.NoError(t, json.Unmarshal(data, &exported))
+	assert.Equal(t, int64(42), exported.User.ID)
+	assert.Equal(t, services.ExportTypeAll, exported.Type)
+
+	info, err := os.Stat(out)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
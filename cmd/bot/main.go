@@ -12,11 +12,17 @@ import (
 	"github.com/valpere/shopogoda/internal/bot"
 	"github.com/valpere/shopogoda/internal/config"
 	"github.com/valpere/shopogoda/internal/version"
+	"github.com/valpere/shopogoda/pkg/metrics"
 )
 
 func main() {
 	// Command-line flags
 	versionFlag := flag.Bool("version", false, "Print version information and exit")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), cliUsage)
+		fmt.Fprintln(flag.CommandLine.Output(), "\nFlags:")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
 	// Handle version flag
@@ -26,6 +32,11 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Operational subcommands run instead of the bot
+	if flag.NArg() > 0 {
+		os.Exit(runCLI(flag.Args()))
+	}
+
 	log.Printf("Starting ShoPogoda v%s", version.Version)
 
 	// Load configuration
@@ -51,14 +62,30 @@ func main() {
 		}
 	}()
 
-	// Wait for interrupt signal
+	// Serve Prometheus metrics apart from the webhook server
+	if cfg.MetricsAddr != "" {
+		go func() {
+			if err := metrics.StartMetricsServer(cfg.MetricsAddr); err != nil {
+				log.Printf("Metrics server failed: %v", err)
+			}
+		}()
+		log.Printf("Serving metrics on %s/metrics", cfg.MetricsAddr)
+	}
+
+	// Wait for interrupt signal; SIGHUP reloads the template overrides
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	<-sigCh
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := <-sigCh; sig == syscall.SIGHUP; sig = <-sigCh {
+		weatherBot.Reload()
+	}
 
 	log.Println("Shutting down ShoPogoda...")
 	cancel()
 
+	if err := metrics.ShutdownMetricsServer(context.Background()); err != nil {
+		log.Printf("Error stopping metrics server: %v", err)
+	}
+
 	if err := weatherBot.Stop(); err != nil {
 		log.Printf("Error during shutdown: %v", err)
 	}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 
@@ -25,18 +24,28 @@ import (
 	"golang.org/x/time/rate"
 )
 
+// inactiveUserDays is how long users may go without writing to the bot before
+// they are deactivated; the sweep runs on start and then daily
+const (
+	inactiveUserDays     = 90
+	userDeactivationTick = 24 * time.Hour
+)
+
 type Bot struct {
-	bot         *gotgbot.Bot
-	updater     *ext.Updater
-	dispatcher  *ext.Dispatcher
-	config      *config.Config
-	logger      zerolog.Logger
-	services    *services.Services
-	server      *http.Server
-	metrics     *metrics.Metrics
-	rateLimiter *middleware.UserRateLimiter
+	bot        *gotgbot.Bot
+	updater    *ext.Updater
+	dispatcher *ext.Dispatcher
+	config     *config.Config
+	logger     zerolog.Logger
+	services   *services.Services
+	server     *http.Server
+	// webhookServer is the HTTPS server of webhook mode, nil otherwise
+	webhookServer *http.Server
+	metrics       *metrics.Metrics
+	rateLimiter   *middleware.UserRateLimiter
 }
 
+// New builds the bot from a configuration that config.Load has validated
 func New(cfg *config.Config) (*Bot, error) {
 	// Initialize logger
 	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout}).
@@ -48,31 +57,17 @@ func New(cfg *config.Config) (*Bot, error) {
 	// Initialize metrics
 	metricsCollector := metrics.New()
 
-	// Initialize database
-	db, err := database.Connect(&cfg.Database)
+	services, err := NewServices(cfg, &logger, metricsCollector)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
-	}
-
-	// Initialize Redis
-	rdb, err := database.ConnectRedis(&cfg.Redis)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
-	}
-
-	// Initialize services with metrics
-	services := services.New(db, rdb, cfg, &logger, metricsCollector)
-
-	// Load translations
-	if err := services.Localization.LoadTranslations(locales.LocalesFS); err != nil {
-		logger.Error().Err(err).Msg("Failed to load translations, continuing with fallback")
+		return nil, err
 	}
 
-	// Create bot
+	// Create bot; sends to chats the bot cannot post in are suppressed, and
+	// every message that does go out is paced by the outbound limiter
 	botInstance, err := gotgbot.NewBot(cfg.Bot.Token, &gotgbot.BotOpts{
-		BotClient: &gotgbot.BaseBotClient{
+		BotClient: services.ChatAccess.WrapClient(services.Outbound.WrapClient(&gotgbot.BaseBotClient{
 			Client: http.Client{Timeout: 30 * time.Second},
-		},
+		})),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create bot: %w", err)
@@ -80,9 +75,15 @@ func New(cfg *config.Config) (*Bot, error) {
 
 	// Set the bot instance for notifications
 	services.Notification.SetBot(botInstance)
+	services.Messaging.SetBot(botInstance)
+	services.Widgets.SetBot(botInstance)
+	services.Escalations.SetBot(botInstance)
 
 	// Create updater and dispatcher
-	dispatcher := ext.NewDispatcher(&ext.DispatcherOpts{})
+	dispatcher := ext.NewDispatcher(&ext.DispatcherOpts{
+		Processor: middleware.UserFetchMetrics{Metrics: metricsCollector},
+		Error:     middleware.ErrorMetrics(metricsCollector, logger),
+	})
 	updater := ext.NewUpdater(dispatcher, &ext.UpdaterOpts{})
 
 	// Create bot instance
@@ -108,6 +109,13 @@ func New(cfg *config.Config) (*Bot, error) {
 	// Setup HTTP server for webhooks and metrics
 	weatherBot.setupHTTPServer()
 
+	// Promote the admins listed in the configuration that already registered
+	if promoted, err := services.User.BootstrapAdmins(context.Background()); err != nil {
+		logger.Error().Err(err).Msg("Failed to promote configured admins")
+	} else if promoted > 0 {
+		logger.Info().Int("promoted", promoted).Msg("Promoted configured admins")
+	}
+
 	// Initialize demo mode if enabled
 	if cfg.Bot.DemoMode {
 		logger.Info().Msg("Demo mode enabled - seeding demo data")
@@ -121,68 +129,160 @@ func New(cfg *config.Config) (*Bot, error) {
 	return weatherBot, nil
 }
 
+// NewServices connects to the database and Redis and builds the service
+// layer with translations loaded, without starting Telegram. The bot and the
+// command line tools share it so both run the same code paths.
+func NewServices(cfg *config.Config, logger *zerolog.Logger, metricsCollector *metrics.Metrics) (*services.Services, error) {
+	// Initialize database
+	db, err := database.Connect(&cfg.Database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	// Initialize Redis
+	rdb, err := database.ConnectRedis(&cfg.Redis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	// Initialize services with metrics
+	svcs := services.New(db, rdb, cfg, logger, metricsCollector)
+
+	// Load translations
+	if err := svcs.Localization.LoadTranslations(locales.LocalesFS); err != nil {
+		logger.Error().Err(err).Msg("Failed to load translations, continuing with fallback")
+	}
+	if report := svcs.Localization.CheckConsistency(locales.ReferencedKeys); report.Problems() > 0 {
+		logger.Warn().Str("report", report.String()).Msg("Translations are inconsistent; send /i18nreport full for details")
+	}
+	if cfg.Templates.Dir != "" {
+		if _, err := svcs.Localization.ReloadOverrides(); err != nil {
+			logger.Error().Err(err).Str("dir", cfg.Templates.Dir).Msg("Failed to load template overrides, using bundled texts")
+		}
+	}
+
+	return svcs, nil
+}
+
 func (b *Bot) setupHandlers() error {
 	// Middleware runs in group -1 (before command handlers in group 0).
 	// Each middleware returns ext.ContinueGroups on success so the next one also runs.
 	b.dispatcher.AddHandlerToGroup(middleware.Wrap("logging", middleware.Logging(b.logger)), -1)
-	b.dispatcher.AddHandlerToGroup(middleware.Wrap("metrics", middleware.Metrics()), -1)
+	b.dispatcher.AddHandlerToGroup(middleware.Wrap("metrics", middleware.Metrics(b.metrics)), -1)
 	b.dispatcher.AddHandlerToGroup(middleware.Wrap("auth", middleware.Auth(b.services.User)), -1)
-	b.dispatcher.AddHandlerToGroup(middleware.Wrap("ratelimit", middleware.RateLimit(b.rateLimiter)), -1)
+	b.dispatcher.AddHandlerToGroup(middleware.Wrap("ratelimit", middleware.RateLimit(b.rateLimiter, b.services.Messaging)), -1)
+	b.dispatcher.AddHandlerToGroup(middleware.Wrap("chataccess", middleware.ChatAccess(b.services.ChatAccess)), -1)
+	b.dispatcher.AddHandlerToGroup(middleware.Wrap("activity", middleware.ActivityTracking(b.services.User, b.services.Engagement, b.logger)), -1)
+	b.dispatcher.AddHandlerToGroup(middleware.Wrap("alertack", middleware.AlertAcknowledgment(b.services.Escalations, b.logger)), -1)
 
-	// Command handlers
+	// Command handlers, each timed under its name
 	cmdHandler := commands.New(b.services, &b.logger)
 
 	// Basic commands
-	b.dispatcher.AddHandler(handlers.NewCommand("start", cmdHandler.Start))
-	b.dispatcher.AddHandler(handlers.NewCommand("help", cmdHandler.Help))
-	b.dispatcher.AddHandler(handlers.NewCommand("settings", cmdHandler.Settings))
-	b.dispatcher.AddHandler(handlers.NewCommand("language", cmdHandler.Language))
-	b.dispatcher.AddHandler(handlers.NewCommand("version", cmdHandler.Version))
+	b.addCommand("start", cmdHandler.Start)
+	b.addCommand("help", cmdHandler.Help)
+	b.addCommand("settings", cmdHandler.Settings)
+	b.addCommand("language", cmdHandler.Language)
+	b.addCommand("version", cmdHandler.Version)
+	b.addCommand("search", cmdHandler.Search)
 
 	// Weather commands
-	b.dispatcher.AddHandler(handlers.NewCommand("weather", cmdHandler.CurrentWeather))
-	b.dispatcher.AddHandler(handlers.NewCommand("forecast", cmdHandler.Forecast))
-	b.dispatcher.AddHandler(handlers.NewCommand("air", cmdHandler.AirQuality))
+	b.addCommand("weather", cmdHandler.CurrentWeather)
+	b.addCommand("forecast", cmdHandler.Forecast)
+	b.addCommand("hourly", cmdHandler.Hourly)
+	b.addCommand("air", cmdHandler.AirQuality)
+	b.addCommand("aqiforecast", cmdHandler.AQIForecast)
+	b.addCommand("compare", cmdHandler.Compare)
+	b.addCommand("uvindex", cmdHandler.UVIndex)
+	b.addCommand("sun", cmdHandler.Sun)
+	b.addCommand("astro", cmdHandler.Astro)
+	b.addCommand("pollen", cmdHandler.Pollen)
+	b.addCommand("tide", cmdHandler.Tide)
+	b.addCommand("history", cmdHandler.History)
+	b.addCommand("radar", cmdHandler.Radar)
+	b.addCommand("map", cmdHandler.Map)
+	b.addCommand("unpin", cmdHandler.Unpin)
+	b.addCommand("today", cmdHandler.Today)
+	b.addCommand("share", cmdHandler.Share)
+	b.addCommand("weatherhere", cmdHandler.WeatherHere)
 
 	// Location management
-	b.dispatcher.AddHandler(handlers.NewCommand("setlocation", cmdHandler.SetLocation))
+	b.addCommand("setlocation", cmdHandler.SetLocation)
+	b.addCommand("locations", cmdHandler.ListLocations)
+	b.addCommand("setgrouplocation", cmdHandler.SetGroupLocation)
 
 	// Subscription management
-	b.dispatcher.AddHandler(handlers.NewCommand("subscribe", cmdHandler.Subscribe))
-	b.dispatcher.AddHandler(handlers.NewCommand("unsubscribe", cmdHandler.Unsubscribe))
-	b.dispatcher.AddHandler(handlers.NewCommand("subscriptions", cmdHandler.ListSubscriptions))
+	b.addCommand("subscribe", cmdHandler.Subscribe)
+	b.addCommand("unsubscribe", cmdHandler.Unsubscribe)
+	b.addCommand("subscriptions", cmdHandler.ListSubscriptions)
 
 	// Alert management
-	b.dispatcher.AddHandler(handlers.NewCommand("addalert", cmdHandler.AddAlert))
-	b.dispatcher.AddHandler(handlers.NewCommand("alerts", cmdHandler.ListAlerts))
-	b.dispatcher.AddHandler(handlers.NewCommand("removealert", cmdHandler.RemoveAlert))
+	b.addCommand("addalert", cmdHandler.AddAlert)
+	b.addCommand("alerts", cmdHandler.ListAlerts)
+	b.addCommand("removealert", cmdHandler.RemoveAlert)
+	b.addCommand("quietdays", cmdHandler.QuietDays)
+	b.addCommand("webhook", cmdHandler.Webhook)
+
+	// Account transfer (admins can start and approve for other users)
+	b.addCommand("transfer", cmdHandler.Transfer)
+
+	// Stored data overview and account deletion
+	b.addCommand("privacy", cmdHandler.Privacy)
+	b.addCommand("deletemydata", cmdHandler.DeleteMyData)
+
+	// The user's own weather API key
+	b.addCommand("setapikey", cmdHandler.SetAPIKey)
+
+	// Pausing all notifications without deleting the account
+	b.addCommand("deactivate", cmdHandler.Deactivate)
+	b.addCommand("reactivate", cmdHandler.Reactivate)
+
+	// Feedback to the admins
+	b.addCommand("feedback", cmdHandler.Feedback)
 
 	// Admin commands (role-based access)
-	b.dispatcher.AddHandler(handlers.NewCommand("stats", cmdHandler.AdminStats))
-	b.dispatcher.AddHandler(handlers.NewCommand("broadcast", cmdHandler.AdminBroadcast))
-	b.dispatcher.AddHandler(handlers.NewCommand("users", cmdHandler.AdminListUsers))
-	b.dispatcher.AddHandler(handlers.NewCommand("promote", cmdHandler.Promote))
-	b.dispatcher.AddHandler(handlers.NewCommand("demote", cmdHandler.Demote))
-	b.dispatcher.AddHandler(handlers.NewCommand("demoreset", cmdHandler.DemoReset))
-	b.dispatcher.AddHandler(handlers.NewCommand("democlear", cmdHandler.DemoClear))
+	b.addCommand("stats", cmdHandler.AdminStats)
+	b.addCommand("broadcast", cmdHandler.AdminBroadcast)
+	b.addCommand("users", cmdHandler.AdminListUsers)
+	b.addCommand("userinfo", cmdHandler.UserInfo)
+	b.addCommand("feedbacklist", cmdHandler.ListFeedback)
+	b.addCommand("auditlog", cmdHandler.AuditLog)
+	b.addCommand("promote", cmdHandler.Promote)
+	b.addCommand("demote", cmdHandler.Demote)
+	b.addCommand("claimadmin", cmdHandler.ClaimAdmin)
+	b.addCommand("flags", cmdHandler.Flags)
+	b.addCommand("reload", cmdHandler.Reload)
+	b.addCommand("i18nreport", cmdHandler.I18nReport)
+	b.addCommand("preset", cmdHandler.Preset)
+	b.addCommand("deadletters", cmdHandler.DeadLetters)
+	b.addCommand("demoreset", cmdHandler.DemoReset)
+	b.addCommand("democlear", cmdHandler.DemoClear)
 
 	// Callback query handlers
-	b.dispatcher.AddHandler(handlers.NewCallback(nil, cmdHandler.HandleCallback))
+	b.dispatcher.AddHandler(handlers.NewCallback(nil, b.timed("callback", cmdHandler.HandleCallback)))
+
+	// Inline queries: "@bot <place>" shares the weather in any chat
+	b.dispatcher.AddHandler(handlers.NewInlineQuery(nil, b.timed("inline", cmdHandler.InlineQuery)))
 
 	// Message handlers for location sharing
 	b.dispatcher.AddHandler(handlers.NewMessage(func(msg *gotgbot.Message) bool {
 		return msg.Location != nil
-	}, cmdHandler.HandleLocationMessage))
+	}, b.timed("location", cmdHandler.HandleLocationMessage)))
+
+	// Telegram edits live location messages as the user moves
+	b.dispatcher.AddHandler(handlers.NewMessage(func(msg *gotgbot.Message) bool {
+		return msg.Location != nil && msg.EditDate != 0
+	}, b.timed("live_location", cmdHandler.HandleLiveLocationUpdate)).SetAllowEdited(true))
 
 	// Text message handler for plain location input
 	b.dispatcher.AddHandler(handlers.NewMessage(func(msg *gotgbot.Message) bool {
 		return msg.Text != "" && msg.Location == nil && !strings.HasPrefix(msg.Text, "/")
-	}, cmdHandler.HandleTextMessage))
+	}, b.timed("text", cmdHandler.HandleTextMessage)))
 
 	// Unknown command handler (for messages starting with / that aren't registered commands)
 	b.dispatcher.AddHandler(handlers.NewMessage(func(msg *gotgbot.Message) bool {
 		return msg.Text != "" && strings.HasPrefix(msg.Text, "/")
-	}, cmdHandler.UnknownCommand))
+	}, b.timed("unknown_command", cmdHandler.UnknownCommand)))
 
 	// Catch-all message handler for debugging (add at the end with low priority)
 	b.dispatcher.AddHandlerToGroup(handlers.NewMessage(func(msg *gotgbot.Message) bool {
@@ -192,67 +292,101 @@ func (b *Bot) setupHandlers() error {
 	return nil
 }
 
+// timed records how long a handler takes under name in the handler duration
+// histogram
+func (b *Bot) timed(name string, response handlers.Response) handlers.Response {
+	return middleware.Timing(b.metrics, name, response)
+}
+
+// addCommand registers the handler of a command, timed under the command
+func (b *Bot) addCommand(command string, response handlers.Response) {
+	b.dispatcher.AddHandler(handlers.NewCommand(command, b.timed(command, response)))
+}
+
 func (b *Bot) setupHTTPServer() {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 	router.Use(gin.Recovery())
 
 	// Health check endpoint
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status":  "healthy",
-			"version": "1.0.0",
-			"time":    time.Now().Unix(),
-		})
-	})
+	router.GET("/health", handleHealth)
 
 	// Metrics endpoint
 	router.GET("/metrics", gin.WrapH(b.metrics.Handler()))
 
-	// Webhook endpoint
+	// Webhook endpoint; in webhook mode it is served over HTTPS instead
 	if b.config.Bot.WebhookURL != "" {
-		router.POST("/webhook", func(c *gin.Context) {
-			b.logger.Info().Msg("WEBHOOK_DEBUG: Received webhook request")
-
-			var update gotgbot.Update
-			if err := c.ShouldBindJSON(&update); err != nil {
-				b.logger.Error().Err(err).Msg("Failed to parse webhook update")
-				c.Status(http.StatusBadRequest)
-				return
-			}
-
-			b.logger.Info().
-				Interface("update_id", update.UpdateId).
-				Bool("has_message", update.Message != nil).
-				Bool("has_callback", update.CallbackQuery != nil).
-				Bool("has_inline", update.InlineQuery != nil).
-				Msg("WEBHOOK_DEBUG: Parsed update")
-
-			if update.Message != nil {
-				b.logger.Info().
-					Int64("user_id", update.Message.From.Id).
-					Str("text", update.Message.Text).
-					Bool("has_location", update.Message.Location != nil).
-					Msg("WEBHOOK_DEBUG: Message details")
-			}
-
-			if err := b.dispatcher.ProcessUpdate(b.bot, &update, nil); err != nil {
-				b.logger.Error().Err(err).Msg("Failed to process update")
-				c.Status(http.StatusInternalServerError)
-				return
-			}
-
-			b.logger.Info().Msg("WEBHOOK_DEBUG: Successfully processed update")
-			c.Status(http.StatusOK)
-		})
+		router.POST(webhookPath, b.handleWebhookUpdate)
 	}
 
 	b.server = &http.Server{
-		Addr:         ":" + strconv.Itoa(b.config.Bot.WebhookPort),
+		Addr:         httpListenAddr(b.config.Bot),
 		Handler:      router,
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	}
+
+	if b.config.Webhook.Enabled {
+		webhookRouter := gin.New()
+		webhookRouter.Use(gin.Recovery())
+		webhookRouter.GET("/health", handleHealth)
+		webhookRouter.POST(webhookPath, b.handleWebhookUpdate)
+		b.webhookServer = newWebhookServer(b.config.Webhook, webhookRouter)
+	}
+}
+
+// handleHealth answers the health check of either HTTP server
+func handleHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "healthy",
+		"version": "1.0.0",
+		"time":    time.Now().Unix(),
+	})
+}
+
+// handleWebhookUpdate dispatches an update Telegram posted to the webhook.
+// Requests without the configured secret token are refused unread.
+func (b *Bot) handleWebhookUpdate(c *gin.Context) {
+	b.logger.Info().Msg("WEBHOOK_DEBUG: Received webhook request")
+
+	if !validSecretToken(c.GetHeader(secretTokenHeader), b.config.Bot.WebhookSecretToken) {
+		b.logger.Warn().Str("remote_addr", c.ClientIP()).Msg("Rejected webhook request without a valid secret token")
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+
+	var update gotgbot.Update
+	if err := c.ShouldBindJSON(&update); err != nil {
+		b.logger.Error().Err(err).Msg("Failed to parse webhook update")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	b.logger.Info().
+		Interface("update_id", update.UpdateId).
+		Bool("has_message", update.Message != nil).
+		Bool("has_callback", update.CallbackQuery != nil).
+		Bool("has_inline", update.InlineQuery != nil).
+		Msg("WEBHOOK_DEBUG: Parsed update")
+
+	if update.Message != nil {
+		b.logger.Info().
+			Int64("user_id", update.Message.From.Id).
+			Str("text", update.Message.Text).
+			Bool("has_location", update.Message.Location != nil).
+			Msg("WEBHOOK_DEBUG: Message details")
+	}
+
+	// The address the update came from is recorded with the admin actions it makes
+	data := map[string]any{commands.RemoteAddrKey: c.ClientIP()}
+	if err := b.dispatcher.ProcessUpdate(b.bot, &update, data); err != nil {
+		b.logger.Error().Err(err).Msg("Failed to process update")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	b.logger.Info().Msg("WEBHOOK_DEBUG: Successfully processed update")
+	c.Status(http.StatusOK)
 }
 
 func (b *Bot) Start(ctx context.Context) error {
@@ -266,15 +400,33 @@ func (b *Bot) Start(ctx context.Context) error {
 	}()
 
 	b.logger.Info().
-		Int("port", b.config.Bot.WebhookPort).
+		Str("addr", b.server.Addr).
 		Msg("HTTP server started")
 
-	// Setup webhook or polling
-	if b.config.Bot.WebhookURL != "" {
-		if err := b.setupWebhook(); err != nil {
+	if b.webhookServer != nil {
+		go func() {
+			if err := serveWebhook(b.webhookServer, b.config.Webhook); err != nil && err != http.ErrServerClosed {
+				b.logger.Fatal().Err(err).Msg("Webhook HTTPS server failed to start")
+			}
+		}()
+
+		b.logger.Info().
+			Str("addr", b.config.Webhook.ListenAddr).
+			Bool("lets_encrypt", b.config.Webhook.CertFile == "").
+			Msg("Webhook HTTPS server started")
+	}
+
+	// Setup webhook or polling; the configuration allows only one webhook mode
+	switch {
+	case b.config.Webhook.Enabled:
+		if err := b.setupWebhook(webhookURL(b.config.Webhook)); err != nil {
 			return fmt.Errorf("failed to setup webhook: %w", err)
 		}
-	} else {
+	case b.config.Bot.WebhookURL != "":
+		if err := b.setupWebhook(b.config.Bot.WebhookURL + webhookPath); err != nil {
+			return fmt.Errorf("failed to setup webhook: %w", err)
+		}
+	default:
 		b.logger.Info().Msg("Starting polling...")
 		b.logger.Info().Msg("POLLING_DEBUG: Polling mode enabled - will log all updates")
 		if err := b.updater.StartPolling(b.bot, &ext.PollingOpts{
@@ -293,6 +445,7 @@ func (b *Bot) Start(ctx context.Context) error {
 
 	// Start background services
 	go b.services.StartScheduler(ctx)
+	go b.runUserDeactivation(ctx)
 
 	b.logger.Info().Msg("ShoPogoda bot started successfully")
 
@@ -301,12 +454,34 @@ func (b *Bot) Start(ctx context.Context) error {
 	return nil
 }
 
-func (b *Bot) setupWebhook() error {
-	webhookURL := b.config.Bot.WebhookURL + "/webhook"
+// runUserDeactivation deactivates users inactive for inactiveUserDays, on
+// start and then daily until ctx is cancelled
+func (b *Bot) runUserDeactivation(ctx context.Context) {
+	ticker := time.NewTicker(userDeactivationTick)
+	defer ticker.Stop()
+
+	for {
+		deactivated, err := b.services.User.DeactivateInactiveUsers(ctx, inactiveUserDays)
+		if err != nil {
+			b.logger.Error().Err(err).Msg("Failed to deactivate inactive users")
+		} else if deactivated > 0 {
+			b.logger.Info().Int64("deactivated", deactivated).Int("inactive_days", inactiveUserDays).Msg("Deactivated inactive users")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
 
+// setupWebhook tells Telegram to post updates to webhookURL
+func (b *Bot) setupWebhook(webhookURL string) error {
 	_, err := b.bot.SetWebhook(webhookURL, &gotgbot.SetWebhookOpts{
 		MaxConnections:     100,
 		DropPendingUpdates: true,
+		SecretToken:        b.config.Bot.WebhookSecretToken,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to set webhook: %w", err)
@@ -314,27 +489,61 @@ func (b *Bot) setupWebhook() error {
 
 	b.logger.Info().
 		Str("webhook_url", webhookURL).
+		Bool("secret_token", b.config.Bot.WebhookSecretToken != "").
 		Msg("Webhook configured")
 
 	return nil
 }
 
+// Reload re-reads the parts of the configuration that can change without a
+// restart: the template overrides. main calls it on SIGHUP.
+func (b *Bot) Reload() {
+	report, err := b.services.Localization.ReloadOverrides()
+	if err != nil {
+		b.logger.Error().Err(err).Msg("Template override reload failed, keeping the previous overrides")
+		return
+	}
+	b.logger.Info().Str("overrides", report.String()).Msg("Reloaded template overrides")
+}
+
+// usesWebhook reports whether Telegram posts updates to the bot rather than
+// the bot polling for them
+func (b *Bot) usesWebhook() bool {
+	return b.config.Webhook.Enabled || b.config.Bot.WebhookURL != ""
+}
+
+// Stop shuts the bot down. In webhook mode the webhook is unregistered first
+// when configured, so Telegram stops posting to a server that is going away,
+// and the updates being handled get shutdownTimeout to finish.
 func (b *Bot) Stop() error {
 	b.logger.Info().Msg("Stopping ShoPogoda bot...")
 
-	// Stop updater
+	if b.usesWebhook() && b.config.Bot.DeleteWebhookOnStop {
+		if _, err := b.bot.DeleteWebhook(nil); err != nil {
+			b.logger.Error().Err(err).Msg("Failed to delete webhook")
+		} else {
+			b.logger.Info().Msg("Webhook deleted")
+		}
+	}
+
+	// Stop updater; it waits for the updates it is handling
 	if err := b.updater.Stop(); err != nil {
 		b.logger.Error().Err(err).Msg("Updater stop error")
 	}
 
-	// Shutdown HTTP server
+	// Shutdown HTTP servers once the webhook requests in flight are answered
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 	if b.server != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
 		if err := b.server.Shutdown(ctx); err != nil {
 			b.logger.Error().Err(err).Msg("HTTP server shutdown error")
 		}
 	}
+	if b.webhookServer != nil {
+		if err := b.webhookServer.Shutdown(ctx); err != nil {
+			b.logger.Error().Err(err).Msg("Webhook HTTPS server shutdown error")
+		}
+	}
 
 	// Stop rate limiter cleanup goroutine
 	b.rateLimiter.Stop()
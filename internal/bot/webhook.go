@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/valpere/shopogoda/internal/config"
+)
+
+// webhookPath is where Telegram posts updates, on either webhook server
+const webhookPath = "/webhook"
+
+// secretTokenHeader carries the secret token Telegram was given with the webhook
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// shutdownTimeout is how long updates being handled may take to finish on
+// shutdown; it matches the servers' write timeout
+const shutdownTimeout = 30 * time.Second
+
+// httpListenAddr is the address of the HTTP server serving health, metrics
+// and, behind a TLS proxy, the webhook
+func httpListenAddr(cfg config.BotConfig) string {
+	if cfg.WebhookListenAddr != "" {
+		return cfg.WebhookListenAddr
+	}
+	return ":" + strconv.Itoa(cfg.WebhookPort)
+}
+
+// validSecretToken reports whether an update carries the secret token the
+// webhook was registered with. Without a configured token every update is
+// accepted.
+func validSecretToken(header, secret string) bool {
+	if secret == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(header), []byte(secret)) == 1
+}
+
+// webhookURL is the address registered with Telegram for updates: the
+// configured domain, with the port of the HTTPS server unless it is 443
+func webhookURL(cfg config.WebhookConfig) string {
+	host := cfg.Domain
+	if _, port, err := net.SplitHostPort(cfg.ListenAddr); err == nil && port != "" && port != "443" {
+		host = net.JoinHostPort(cfg.Domain, port)
+	}
+	return "https://" + host + webhookPath
+}
+
+// newWebhookServer builds the HTTPS server of webhook mode. With a certificate
+// and key configured it serves those; otherwise it obtains and renews one for
+// the domain from Let's Encrypt, answering the TLS-ALPN challenge itself.
+func newWebhookServer(cfg config.WebhookConfig, handler http.Handler) *http.Server {
+	server := &http.Server{
+		Addr:         cfg.ListenAddr,
+		Handler:      handler,
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		TLSConfig:    &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+	if cfg.CertFile == "" {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.Domain),
+			Cache:      autocert.DirCache(cfg.CacheDir),
+		}
+		server.TLSConfig = manager.TLSConfig()
+		server.TLSConfig.MinVersion = tls.VersionTLS12
+	}
+	return server
+}
+
+// serveWebhook runs the HTTPS server of webhook mode until it is shut down
+func serveWebhook(server *http.Server, cfg config.WebhookConfig) error {
+	// Both are empty with Let's Encrypt, whose certificates come from TLSConfig
+	return server.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile)
+}
@@ -0,0 +1,106 @@
+package bot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/message"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/internal/handlers/commands"
+)
+
+func TestWebhookURL(t *testing.T) {
+	assert.Equal(t, "https://bot.example.com/webhook", webhookURL(config.WebhookConfig{Domain: "bot.example.com", ListenAddr: ":443"}))
+	assert.Equal(t, "https://bot.example.com:8443/webhook", webhookURL(config.WebhookConfig{Domain: "bot.example.com", ListenAddr: "0.0.0.0:8443"}))
+	assert.Equal(t, "https://bot.example.com/webhook", webhookURL(config.WebhookConfig{Domain: "bot.example.com"}))
+}
+
+func TestNewWebhookServer(t *testing.T) {
+	t.Run("Let's Encrypt", func(t *testing.T) {
+		server := newWebhookServer(config.WebhookConfig{Domain: "bot.example.com", ListenAddr: ":443", CacheDir: t.TempDir()}, http.NotFoundHandler())
+
+		assert.Equal(t, ":443", server.Addr)
+		assert.NotNil(t, server.TLSConfig.GetCertificate, "certificates come from the ACME manager")
+		assert.Contains(t, server.TLSConfig.NextProtos, "acme-tls/1", "answers the TLS-ALPN challenge")
+	})
+
+	t.Run("own certificate", func(t *testing.T) {
+		server := newWebhookServer(config.WebhookConfig{Domain: "bot.example.com", ListenAddr: ":8443", CertFile: "cert.pem", KeyFile: "key.pem"}, http.NotFoundHandler())
+
+		assert.Nil(t, server.TLSConfig.GetCertificate, "loaded from the files by ListenAndServeTLS")
+	})
+}
+
+func TestHTTPListenAddr(t *testing.T) {
+	assert.Equal(t, ":8080", httpListenAddr(config.BotConfig{WebhookPort: 8080}))
+	assert.Equal(t, "127.0.0.1:9000", httpListenAddr(config.BotConfig{WebhookPort: 8080, WebhookListenAddr: "127.0.0.1:9000"}))
+}
+
+func TestValidSecretToken(t *testing.T) {
+	assert.True(t, validSecretToken("", ""), "no token configured")
+	assert.True(t, validSecretToken("anything", ""), "no token configured")
+	assert.True(t, validSecretToken("s3cret-token", "s3cret-token"))
+	assert.False(t, validSecretToken("", "s3cret-token"))
+	assert.False(t, validSecretToken("s3cret", "s3cret-token"))
+}
+
+func TestHandleWebhookUpdate_SecretToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	b := &Bot{
+		config: &config.Config{Bot: config.BotConfig{WebhookSecretToken: "s3cret-token"}},
+		logger: zerolog.Nop(),
+	}
+	router := gin.New()
+	router.POST(webhookPath, b.handleWebhookUpdate)
+
+	post := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, webhookPath, strings.NewReader("not an update"))
+		if token != "" {
+			req.Header.Set(secretTokenHeader, token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, post(""))
+	assert.Equal(t, http.StatusUnauthorized, post("wrong"))
+	// With the right token the body is read, and this one is no update
+	assert.Equal(t, http.StatusBadRequest, post("s3cret-token"))
+}
+
+func TestHandleWebhookUpdate_RemoteAddr(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var remoteAddr any
+	dispatcher := ext.NewDispatcher(nil)
+	dispatcher.AddHandler(handlers.NewMessage(message.All, func(bot *gotgbot.Bot, ctx *ext.Context) error {
+		remoteAddr = ctx.Data[commands.RemoteAddrKey]
+		return nil
+	}))
+	b := &Bot{
+		config:     &config.Config{},
+		logger:     zerolog.Nop(),
+		bot:        &gotgbot.Bot{},
+		dispatcher: dispatcher,
+	}
+	router := gin.New()
+	router.POST(webhookPath, b.handleWebhookUpdate)
+
+	req := httptest.NewRequest(http.MethodPost, webhookPath,
+		strings.NewReader(`{"update_id":1,"message":{"message_id":1,"date":1,"chat":{"id":42,"type":"private"},"from":{"id":42,"first_name":"Admin"},"text":"/demoreset"}}`))
+	req.RemoteAddr = "149.154.167.220:443"
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "149.154.167.220", remoteAddr)
+}
@@ -3,7 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/spf13/viper"
@@ -17,6 +19,16 @@ type Config struct {
 	Logging      LoggingConfig      `mapstructure:"logging"`
 	Metrics      MetricsConfig      `mapstructure:"metrics"`
 	Integrations IntegrationsConfig `mapstructure:"integrations"`
+	Outbound     OutboundConfig     `mapstructure:"outbound"`
+	Features     FeaturesConfig     `mapstructure:"features"`
+	Templates    TemplatesConfig    `mapstructure:"templates"`
+	Anomaly      AnomalyConfig      `mapstructure:"anomaly"`
+	RateLimit    RateLimitConfig    `mapstructure:"rate_limit"`
+	Webhook      WebhookConfig      `mapstructure:"webhook"`
+
+	MetricsAddr      string `mapstructure:"metrics_addr"`        // Address of the Prometheus /metrics server; empty disables it
+	BroadcastWorkers int    `mapstructure:"broadcast_workers"`   // Messages of an admin broadcast in flight at once
+	MaxAlertsPerUser int    `mapstructure:"max_alerts_per_user"` // Active alerts one user may have
 }
 
 type BotConfig struct {
@@ -25,6 +37,23 @@ type BotConfig struct {
 	WebhookURL  string `mapstructure:"webhook_url"`
 	WebhookPort int    `mapstructure:"webhook_port"`
 	DemoMode    bool   `mapstructure:"demo_mode"`
+	AdminIDs    string `mapstructure:"admin_ids"` // Comma-separated Telegram user IDs that are made admins
+
+	WebhookListenAddr   string `mapstructure:"webhook_listen_addr"`    // Address of the HTTP server; ":<WebhookPort>" when empty
+	WebhookSecretToken  string `mapstructure:"webhook_secret_token"`   // #nosec G117 -- Telegram sends it with every update, in either webhook mode
+	DeleteWebhookOnStop bool   `mapstructure:"delete_webhook_on_stop"` // Unregister the webhook on shutdown, e.g. before switching to polling
+}
+
+// WebhookConfig makes the bot receive updates on its own HTTPS server instead
+// of long-polling. Without a certificate and key it gets one for Domain from
+// Let's Encrypt, which has to reach ListenAddr on port 443.
+type WebhookConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	Domain     string `mapstructure:"domain"`      // Public host name Telegram sends updates to
+	ListenAddr string `mapstructure:"listen_addr"` // Address of the HTTPS server
+	CertFile   string `mapstructure:"cert_file"`   // PEM certificate; empty for Let's Encrypt
+	KeyFile    string `mapstructure:"key_file"`    // PEM private key of CertFile
+	CacheDir   string `mapstructure:"cache_dir"`   // Where Let's Encrypt certificates are kept across restarts
 }
 
 type DatabaseConfig struct {
@@ -47,6 +76,20 @@ type WeatherConfig struct {
 	OpenWeatherAPIKey string `mapstructure:"openweather_api_key"`
 	AirQualityAPIKey  string `mapstructure:"airquality_api_key"`
 	UserAgent         string `mapstructure:"user_agent"`
+	UserKeySecret     string `mapstructure:"user_key_secret"` // Encrypts the API keys users set with /setapikey; users cannot set one without it
+
+	Providers       string        `mapstructure:"providers"`        // Comma-separated weather providers in the order they are tried, e.g. "openweathermap,openmeteo"
+	ProviderTimeout time.Duration `mapstructure:"provider_timeout"` // How long a provider may take before the next one is asked
+
+	HistoryInterval time.Duration `mapstructure:"history_interval"` // How often the weather at users' locations is recorded for /history
+	RecordRetention time.Duration `mapstructure:"record_retention"` // How long weather users looked up or were sent is kept
+	Warnings        bool          `mapstructure:"warnings"`         // Push official warnings to extreme weather subscribers; needs a One Call API 3.0 subscription
+
+	CurrentCacheTTL  time.Duration `mapstructure:"current_cache_ttl"`  // How long current weather and air quality are cached per place
+	ForecastCacheTTL time.Duration `mapstructure:"forecast_cache_ttl"` // How long daily forecasts are cached per place
+
+	TideAPIKey        string  `mapstructure:"tide_api_key"`        // WorldTides API key; /tide and tide alerts are off without it
+	TideCoastDistance float64 `mapstructure:"tide_coast_distance"` // How far from the sea, in km, a place may be and still get tides
 }
 
 type LoggingConfig struct {
@@ -65,6 +108,37 @@ type IntegrationsConfig struct {
 	GrafanaURL      string `mapstructure:"grafana_url"`
 }
 
+// OutboundConfig controls the pacing of messages the bot sends to Telegram
+type OutboundConfig struct {
+	BulkRate         float64       `mapstructure:"bulk_rate"`         // Messages per second for scheduled, broadcast and alert sends
+	InteractiveRate  float64       `mapstructure:"interactive_rate"`  // Messages per second reserved for replies to users
+	InteractiveBurst int           `mapstructure:"interactive_burst"` // Replies that may go out at once
+	DeliveryWindow   time.Duration `mapstructure:"delivery_window"`   // Period over which a notification slot is spread
+
+	DeadLetterRetention time.Duration `mapstructure:"dead_letter_retention"` // How long failed deliveries are kept as dead letters
+}
+
+// FeaturesConfig overrides the built-in defaults of feature flags
+type FeaturesConfig struct {
+	Flags string `mapstructure:"flags"` // Comma-separated name=on|off pairs, e.g. "charts=on,nowcast=off"
+}
+
+// TemplatesConfig lets a deployment reword the bot without forking it
+type TemplatesConfig struct {
+	Dir    string `mapstructure:"dir"`    // Directory of <language>.json translation overrides; reloaded on SIGHUP and /reload
+	Footer string `mapstructure:"footer"` // Comma-separated message types the message_footer text is appended to
+}
+
+// AnomalyConfig tunes the notes about weather that is unusual for the season
+type AnomalyConfig struct {
+	Threshold float64 `mapstructure:"threshold"` // Standard deviations from the seasonal baseline that count as unusual
+}
+
+// RateLimitConfig caps how often one user may call the weather API
+type RateLimitConfig struct {
+	WeatherPerMinute int `mapstructure:"weather_per_minute"` // Weather requests per user per minute, counted in Redis across instances
+}
+
 func Load() (*Config, error) {
 	// Load .env file if it exists
 	if _, err := os.Stat(".env"); err == nil {
@@ -82,7 +156,18 @@ func Load() (*Config, error) {
 	_ = viper.BindEnv("bot.debug", "BOT_DEBUG")
 	_ = viper.BindEnv("bot.webhook_url", "BOT_WEBHOOK_URL")
 	_ = viper.BindEnv("bot.webhook_port", "BOT_WEBHOOK_PORT")
+	_ = viper.BindEnv("bot.webhook_listen_addr", "BOT_WEBHOOK_LISTEN_ADDR")
+	_ = viper.BindEnv("bot.webhook_secret_token", "BOT_WEBHOOK_SECRET_TOKEN")
+	_ = viper.BindEnv("bot.delete_webhook_on_stop", "BOT_WEBHOOK_DELETE_ON_STOP")
 	_ = viper.BindEnv("bot.demo_mode", "DEMO_MODE")
+	_ = viper.BindEnv("bot.admin_ids", "ADMIN_TELEGRAM_IDS")
+
+	_ = viper.BindEnv("webhook.enabled", "WEBHOOK_ENABLED")
+	_ = viper.BindEnv("webhook.domain", "WEBHOOK_DOMAIN")
+	_ = viper.BindEnv("webhook.listen_addr", "WEBHOOK_LISTEN_ADDR")
+	_ = viper.BindEnv("webhook.cert_file", "WEBHOOK_CERT_FILE")
+	_ = viper.BindEnv("webhook.key_file", "WEBHOOK_KEY_FILE")
+	_ = viper.BindEnv("webhook.cache_dir", "WEBHOOK_CACHE_DIR")
 
 	_ = viper.BindEnv("database.host", "DB_HOST")
 	_ = viper.BindEnv("database.port", "DB_PORT")
@@ -99,17 +184,45 @@ func Load() (*Config, error) {
 	_ = viper.BindEnv("weather.openweather_api_key", "OPENWEATHER_API_KEY")
 	_ = viper.BindEnv("weather.airquality_api_key", "AIRQUALITY_API_KEY")
 	_ = viper.BindEnv("weather.user_agent", "WEATHER_USER_AGENT")
+	_ = viper.BindEnv("weather.user_key_secret", "USER_API_KEY_SECRET")
+	_ = viper.BindEnv("weather.providers", "WEATHER_PROVIDERS")
+	_ = viper.BindEnv("weather.provider_timeout", "WEATHER_PROVIDER_TIMEOUT")
+	_ = viper.BindEnv("weather.history_interval", "WEATHER_HISTORY_INTERVAL")
+	_ = viper.BindEnv("weather.record_retention", "WEATHER_RECORD_RETENTION")
+	_ = viper.BindEnv("weather.warnings", "WEATHER_WARNINGS")
+	_ = viper.BindEnv("weather.current_cache_ttl", "WEATHER_CACHE_TTL")
+	_ = viper.BindEnv("weather.forecast_cache_ttl", "FORECAST_CACHE_TTL")
+	_ = viper.BindEnv("weather.tide_api_key", "WORLDTIDES_API_KEY")
+	_ = viper.BindEnv("weather.tide_coast_distance", "TIDE_COAST_DISTANCE")
 
 	_ = viper.BindEnv("logging.level", "LOG_LEVEL")
 	_ = viper.BindEnv("logging.format", "LOG_FORMAT")
 
 	_ = viper.BindEnv("metrics.port", "PROMETHEUS_PORT")
 	_ = viper.BindEnv("metrics.jaeger_endpoint", "JAEGER_ENDPOINT")
+	_ = viper.BindEnv("metrics_addr", "METRICS_ADDR")
 
 	_ = viper.BindEnv("integrations.slack_webhook_url", "SLACK_WEBHOOK_URL")
 	_ = viper.BindEnv("integrations.teams_webhook_url", "TEAMS_WEBHOOK_URL")
 	_ = viper.BindEnv("integrations.grafana_url", "GRAFANA_URL")
 
+	_ = viper.BindEnv("outbound.bulk_rate", "OUTBOUND_BULK_RATE")
+	_ = viper.BindEnv("outbound.interactive_rate", "OUTBOUND_INTERACTIVE_RATE")
+	_ = viper.BindEnv("outbound.interactive_burst", "OUTBOUND_INTERACTIVE_BURST")
+	_ = viper.BindEnv("outbound.delivery_window", "NOTIFICATION_DELIVERY_WINDOW")
+	_ = viper.BindEnv("outbound.dead_letter_retention", "DEAD_LETTER_RETENTION")
+	_ = viper.BindEnv("broadcast_workers", "BROADCAST_WORKERS")
+	_ = viper.BindEnv("max_alerts_per_user", "MAX_ALERTS_PER_USER")
+
+	_ = viper.BindEnv("features.flags", "FEATURE_FLAGS")
+
+	_ = viper.BindEnv("templates.dir", "TEMPLATES_DIR")
+	_ = viper.BindEnv("templates.footer", "TEMPLATES_FOOTER")
+
+	_ = viper.BindEnv("anomaly.threshold", "ANOMALY_THRESHOLD")
+
+	_ = viper.BindEnv("rate_limit.weather_per_minute", "WEATHER_RATE_LIMIT")
+
 	// Set defaults
 	setDefaults()
 
@@ -122,13 +235,45 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("error unmarshalling config: %w", err)
 	}
 
+	// Long-polling and the two webhook modes are mutually exclusive
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
 	return &config, nil
 }
 
+// webhookSecretTokenPattern is what Telegram accepts as a webhook secret token
+var webhookSecretTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// Validate reports settings that contradict each other. The bot long-polls
+// unless a webhook is configured: BOT_WEBHOOK_URL behind a proxy that
+// terminates TLS, or WEBHOOK_ENABLED with the bot serving HTTPS itself.
+func (c *Config) Validate() error {
+	if c.Bot.WebhookSecretToken != "" && !webhookSecretTokenPattern.MatchString(c.Bot.WebhookSecretToken) {
+		return fmt.Errorf("BOT_WEBHOOK_SECRET_TOKEN must be 1-256 letters, digits, underscores or hyphens")
+	}
+	if !c.Webhook.Enabled {
+		return nil
+	}
+	if c.Bot.WebhookURL != "" {
+		return fmt.Errorf("WEBHOOK_ENABLED and BOT_WEBHOOK_URL select different update modes; set only one")
+	}
+	if c.Webhook.Domain == "" {
+		return fmt.Errorf("WEBHOOK_DOMAIN is required when WEBHOOK_ENABLED is set")
+	}
+	if (c.Webhook.CertFile == "") != (c.Webhook.KeyFile == "") {
+		return fmt.Errorf("WEBHOOK_CERT_FILE and WEBHOOK_KEY_FILE must be set together")
+	}
+	return nil
+}
+
 func setDefaults() {
 	// Bot defaults
 	viper.SetDefault("bot.debug", false)
 	viper.SetDefault("bot.webhook_port", 8080)
+	viper.SetDefault("webhook.listen_addr", ":443")
+	viper.SetDefault("webhook.cache_dir", "certs")
 
 	// Database defaults
 	viper.SetDefault("database.host", "localhost")
@@ -142,6 +287,14 @@ func setDefaults() {
 
 	// Weather defaults
 	viper.SetDefault("weather.user_agent", "ShoPogoda-Weather-Bot/1.0 (contact@shopogoda.bot)")
+	viper.SetDefault("weather.providers", "openweathermap")
+	viper.SetDefault("weather.provider_timeout", "5s")
+	viper.SetDefault("weather.history_interval", "1h")
+	viper.SetDefault("weather.record_retention", "2160h")
+	viper.SetDefault("weather.warnings", false)
+	viper.SetDefault("weather.current_cache_ttl", "10m")
+	viper.SetDefault("weather.forecast_cache_ttl", "1h")
+	viper.SetDefault("weather.tide_coast_distance", 20)
 
 	// Logging defaults
 	viper.SetDefault("logging.level", "info")
@@ -149,4 +302,23 @@ func setDefaults() {
 
 	// Metrics defaults
 	viper.SetDefault("metrics.port", 2112)
+	viper.SetDefault("metrics_addr", ":9090")
+
+	// Outbound message defaults (Telegram allows about 30 messages per second overall)
+	viper.SetDefault("outbound.bulk_rate", 25)
+	viper.SetDefault("outbound.interactive_rate", 5)
+	viper.SetDefault("outbound.interactive_burst", 5)
+	viper.SetDefault("outbound.delivery_window", "5m")
+	viper.SetDefault("outbound.dead_letter_retention", "720h")
+	viper.SetDefault("broadcast_workers", 10)
+	viper.SetDefault("max_alerts_per_user", 20)
+
+	// Template defaults; the bundled footer is empty, so nothing is appended until it is overridden
+	viper.SetDefault("templates.footer", "weather,digest")
+
+	// Unusual weather notes
+	viper.SetDefault("anomaly.threshold", 2.0)
+
+	// Per-user weather request limit
+	viper.SetDefault("rate_limit.weather_per_minute", 20)
 }
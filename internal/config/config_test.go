@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
@@ -40,6 +42,20 @@ func TestLoad(t *testing.T) {
 		assert.Equal(t, "info", cfg.Logging.Level)
 		assert.Equal(t, "json", cfg.Logging.Format)
 		assert.Equal(t, 2112, cfg.Metrics.Port)
+		assert.Equal(t, ":9090", cfg.MetricsAddr)
+		assert.Equal(t, 10, cfg.BroadcastWorkers)
+		assert.Equal(t, 20, cfg.MaxAlertsPerUser)
+		assert.False(t, cfg.Webhook.Enabled)
+		assert.Equal(t, ":443", cfg.Webhook.ListenAddr)
+		assert.Equal(t, "certs", cfg.Webhook.CacheDir)
+	})
+
+	t.Run("rejects webhook mode without a domain", func(t *testing.T) {
+		resetViper()
+		t.Setenv("WEBHOOK_ENABLED", "true")
+
+		_, err := Load()
+		assert.ErrorContains(t, err, "WEBHOOK_DOMAIN")
 	})
 
 	t.Run("loads from environment variables", func(t *testing.T) {
@@ -175,6 +191,14 @@ func TestSetDefaults(t *testing.T) {
 	t.Run("weather defaults", func(t *testing.T) {
 		assert.Equal(t, "ShoPogoda-Weather-Bot/1.0 (contact@shopogoda.bot)",
 			viper.GetString("weather.user_agent"))
+		assert.Equal(t, time.Hour, viper.GetDuration("weather.history_interval"))
+		assert.Equal(t, 90*24*time.Hour, viper.GetDuration("weather.record_retention"))
+		assert.Equal(t, 10*time.Minute, viper.GetDuration("weather.current_cache_ttl"))
+		assert.Equal(t, time.Hour, viper.GetDuration("weather.forecast_cache_ttl"))
+	})
+
+	t.Run("rate limit defaults", func(t *testing.T) {
+		assert.Equal(t, 20, viper.GetInt("rate_limit.weather_per_minute"))
 	})
 
 	t.Run("logging defaults", func(t *testing.T) {
@@ -184,6 +208,7 @@ func TestSetDefaults(t *testing.T) {
 
 	t.Run("metrics defaults", func(t *testing.T) {
 		assert.Equal(t, 2112, viper.GetInt("metrics.port"))
+		assert.Equal(t, ":9090", viper.GetString("metrics_addr"))
 	})
 }
 
@@ -421,6 +446,44 @@ func TestConfig(t *testing.T) {
 	})
 }
 
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		bot     BotConfig
+		webhook WebhookConfig
+		wantErr string
+	}{
+		{name: "long-polling"},
+		{name: "webhook behind a proxy", bot: BotConfig{WebhookURL: "https://bot.example.com"}},
+		{name: "Let's Encrypt", webhook: WebhookConfig{Enabled: true, Domain: "bot.example.com"}},
+		{name: "own certificate", webhook: WebhookConfig{Enabled: true, Domain: "bot.example.com", CertFile: "cert.pem", KeyFile: "key.pem"}},
+		{
+			name:    "both webhook modes",
+			bot:     BotConfig{WebhookURL: "https://bot.example.com"},
+			webhook: WebhookConfig{Enabled: true, Domain: "bot.example.com"},
+			wantErr: "set only one",
+		},
+		{name: "missing domain", webhook: WebhookConfig{Enabled: true}, wantErr: "WEBHOOK_DOMAIN"},
+		{name: "certificate without key", webhook: WebhookConfig{Enabled: true, Domain: "bot.example.com", CertFile: "cert.pem"}, wantErr: "together"},
+		{name: "disabled webhook is not checked", webhook: WebhookConfig{CertFile: "cert.pem"}},
+		{name: "secret token", bot: BotConfig{WebhookURL: "https://bot.example.com", WebhookSecretToken: "s3cret_Token-1"}},
+		{name: "secret token with invalid characters", bot: BotConfig{WebhookSecretToken: "s3cret token!"}, wantErr: "BOT_WEBHOOK_SECRET_TOKEN"},
+		{name: "secret token too long", bot: BotConfig{WebhookSecretToken: strings.Repeat("a", 257)}, wantErr: "BOT_WEBHOOK_SECRET_TOKEN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{Bot: tt.bot, Webhook: tt.webhook}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestEnvironmentVariableMapping(t *testing.T) {
 	t.Run("all environment variables are mapped", func(t *testing.T) {
 		viper.Reset()
@@ -12,7 +12,6 @@ import (
 	"gorm.io/gorm/logger"
 
 	"github.com/valpere/shopogoda/internal/config"
-	"github.com/valpere/shopogoda/internal/models"
 )
 
 func Connect(cfg *config.DatabaseConfig) (*gorm.DB, error) {
@@ -70,15 +69,3 @@ func ConnectRedis(cfg *config.RedisConfig) (*redis.Client, error) {
 
 	return rdb, nil
 }
-
-// Migrate runs database migrations
-func Migrate(db *gorm.DB) error {
-	return db.AutoMigrate(
-		&models.User{},
-		&models.WeatherData{},
-		&models.Subscription{},
-		&models.AlertConfig{},
-		&models.EnvironmentalAlert{},
-		&models.UserSession{},
-	)
-}
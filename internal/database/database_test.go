@@ -6,53 +6,12 @@ import (
 	"testing"
 	"time"
 
-	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/go-redis/redismock/v9"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/require"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 
 	"github.com/valpere/shopogoda/internal/config"
 )
 
-func TestMigrate(t *testing.T) {
-	// Create mock database
-	db, mock, err := sqlmock.New()
-	require.NoError(t, err)
-	defer func() {
-		if err := db.Close(); err != nil {
-			t.Logf("Failed to close mock database: %v", err)
-		}
-	}()
-
-	gormDB, err := gorm.Open(postgres.New(postgres.Config{
-		Conn: db,
-	}), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Silent),
-	})
-	require.NoError(t, err)
-
-	t.Run("successful migration", func(t *testing.T) {
-		// Expect migration queries
-		mock.ExpectExec("CREATE TABLE").WillReturnResult(sqlmock.NewResult(0, 0))
-		mock.ExpectExec("CREATE TABLE").WillReturnResult(sqlmock.NewResult(0, 0))
-		mock.ExpectExec("CREATE TABLE").WillReturnResult(sqlmock.NewResult(0, 0))
-		mock.ExpectExec("CREATE TABLE").WillReturnResult(sqlmock.NewResult(0, 0))
-		mock.ExpectExec("CREATE TABLE").WillReturnResult(sqlmock.NewResult(0, 0))
-		mock.ExpectExec("CREATE TABLE").WillReturnResult(sqlmock.NewResult(0, 0))
-
-		// Note: This will attempt migrations but may not match exact SQL
-		// The test validates that Migrate function can be called without panic
-		// Migration might partially succeed with mock - we mainly test it doesn't panic
-		// In real tests with testcontainers, full migration is validated
-		assert.NotPanics(t, func() {
-			Migrate(gormDB) //nolint:errcheck // Migrate doesn't return error, we test it doesn't panic
-		})
-	})
-}
-
 func TestConnect(t *testing.T) {
 	t.Run("validates config parameters", func(t *testing.T) {
 		// This test validates config structure, actual connection would need real DB
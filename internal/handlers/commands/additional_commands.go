@@ -3,14 +3,17 @@ package commands
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/PaulSonOfLars/gotgbot/v2"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext"
 	"github.com/google/uuid"
 
 	"github.com/valpere/shopogoda/internal"
+	"github.com/valpere/shopogoda/internal/markdown"
 	"github.com/valpere/shopogoda/internal/models"
 	"github.com/valpere/shopogoda/internal/services"
 	"github.com/valpere/shopogoda/internal/version"
@@ -35,7 +38,7 @@ func (h *CommandHandler) SetDefaultLocation(bot *gotgbot.Bot, ctx *ext.Context)
 // Unsubscribe command handler
 func (h *CommandHandler) Unsubscribe(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	subscriptions, err := h.services.Subscription.GetUserSubscriptions(context.Background(), userID)
 	if err != nil {
@@ -53,7 +56,7 @@ func (h *CommandHandler) Unsubscribe(bot *gotgbot.Bot, ctx *ext.Context) error {
 
 	for i, sub := range subscriptions {
 		subTypeText := h.getSubscriptionTypeText(sub.SubscriptionType, userLang)
-		text += fmt.Sprintf("%d. %s - %s\n", i+1, subTypeText, sub.User.LocationName)
+		text += fmt.Sprintf("%d. %s - %s\n", i+1, subTypeText, markdown.Escape(sub.User.LocationName))
 
 		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
 			{Text: fmt.Sprintf("🗑️ Remove %s", subTypeText),
@@ -74,7 +77,7 @@ func (h *CommandHandler) Unsubscribe(bot *gotgbot.Bot, ctx *ext.Context) error {
 // ListSubscriptions command handler
 func (h *CommandHandler) ListSubscriptions(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	subscriptions, err := h.services.Subscription.GetUserSubscriptions(context.Background(), userID)
 	if err != nil {
@@ -94,25 +97,27 @@ func (h *CommandHandler) ListSubscriptions(bot *gotgbot.Bot, ctx *ext.Context) e
 		return err
 	}
 
-	text := "📋 *Your Active Subscriptions:*\n\n"
+	text := h.services.Localization.TN(context.Background(), userLang, "subscriptions_list_title", int64(len(subscriptions))) + "\n\n"
 	var keyboard [][]gotgbot.InlineKeyboardButton
+	timezone := h.subscriptionTimezone(ctx, userID)
 
 	for i, sub := range subscriptions {
 		subTypeText := h.getSubscriptionTypeText(sub.SubscriptionType, userLang)
 		freqText := h.getFrequencyText(sub.Frequency, userLang)
 
 		text += fmt.Sprintf("%d. **%s**\n", i+1, subTypeText)
-		text += fmt.Sprintf("   📍 Location: %s\n", sub.User.LocationName)
+		text += fmt.Sprintf("   📍 Location: %s\n", markdown.Escape(sub.User.LocationName))
 		text += fmt.Sprintf("   ⏰ Frequency: %s\n", freqText)
-		text += fmt.Sprintf("   🕐 Time: %s\n", sub.TimeOfDay)
+		text += fmt.Sprintf("   🕐 Time: %s\n", subscriptionTimeLabel(sub.TimeOfDay, timezone))
 		text += "\n"
 
-		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
-			{Text: fmt.Sprintf("⚙️ Edit %s", subTypeText),
-				CallbackData: fmt.Sprintf("sub_edit_%s", sub.ID)},
-			{Text: "🗑️ Remove",
-				CallbackData: fmt.Sprintf("sub_remove_%s", sub.ID)},
-		})
+		var row []gotgbot.InlineKeyboardButton
+		if subscriptionEditable(&sub) {
+			row = append(row, gotgbot.InlineKeyboardButton{Text: fmt.Sprintf("⚙️ Edit %s", subTypeText),
+				CallbackData: fmt.Sprintf("sub_edit_%s", sub.ID)})
+		}
+		keyboard = append(keyboard, append(row, gotgbot.InlineKeyboardButton{Text: "🗑️ Remove",
+			CallbackData: fmt.Sprintf("sub_remove_%s", sub.ID)}))
 	}
 
 	keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
@@ -132,7 +137,7 @@ func (h *CommandHandler) ListSubscriptions(bot *gotgbot.Bot, ctx *ext.Context) e
 // ListAlerts command handler
 func (h *CommandHandler) ListAlerts(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	alerts, err := h.services.Alert.GetUserAlerts(context.Background(), userID)
 	if err != nil {
@@ -158,13 +163,13 @@ func (h *CommandHandler) ListAlerts(bot *gotgbot.Bot, ctx *ext.Context) error {
 	}
 
 	// Get user info for location
-	user, err := h.services.User.GetUser(context.Background(), userID)
+	user, err := h.getUser(ctx, userID)
 	if err != nil {
 		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to get user")
 		return err
 	}
 
-	titleText := h.services.Localization.T(context.Background(), userLang, "alerts_list_title")
+	titleText := h.services.Localization.TN(context.Background(), userLang, "alerts_list_title", int64(len(alerts)))
 	text := fmt.Sprintf("*%s*\n\n", titleText)
 	var keyboard [][]gotgbot.InlineKeyboardButton
 
@@ -178,14 +183,16 @@ func (h *CommandHandler) ListAlerts(bot *gotgbot.Bot, ctx *ext.Context) error {
 
 			text += fmt.Sprintf("%d. *%s Alert*\n", i+1, alertTypeText)
 			if user.LocationName != "" {
-				text += fmt.Sprintf("   📍 %s\n", user.LocationName)
+				text += fmt.Sprintf("   📍 %s\n", markdown.Escape(user.LocationName))
 			}
-			text += fmt.Sprintf("   ⚡ Trigger: %s %.1f\n", operatorSymbol, alert.Threshold)
+			text += fmt.Sprintf("   ⚡ Trigger: %s %s\n", operatorSymbol, services.FormatAlertValue(alert.AlertType, alert.Threshold, services.UserUnits(user)))
 			statusText := h.services.Localization.T(context.Background(), userLang, "alerts_status_active")
 			if !alert.IsActive {
 				statusText = h.services.Localization.T(context.Background(), userLang, "alerts_status_inactive")
 			}
-			text += fmt.Sprintf("   🔔 %s\n\n", statusText)
+			text += fmt.Sprintf("   🔔 %s\n", statusText)
+			text += h.alertLastCheckedLine(alert, user, userLang)
+			text += "\n"
 		}
 
 		editBtnText := h.services.Localization.T(context.Background(), userLang, "alerts_edit_btn")
@@ -248,10 +255,10 @@ func (h *CommandHandler) RemoveAlert(bot *gotgbot.Bot, ctx *ext.Context) error {
 // AdminBroadcast command handler
 func (h *CommandHandler) AdminBroadcast(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	// Check admin permissions
-	user, err := h.services.User.GetUser(context.Background(), userID)
+	user, err := h.getUser(ctx, userID)
 	if err != nil || user.Role != models.RoleAdmin {
 		errorMsg := h.services.Localization.T(context.Background(), userLang, "admin_broadcast_insufficient_permissions")
 		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
@@ -259,6 +266,10 @@ func (h *CommandHandler) AdminBroadcast(bot *gotgbot.Bot, ctx *ext.Context) erro
 	}
 
 	args := ctx.Args()
+	dryRun := len(args) > 1 && args[1] == "--dry-run"
+	if dryRun {
+		args = args[1:]
+	}
 	if len(args) < 2 {
 		usageMsg := h.services.Localization.T(context.Background(), userLang, "admin_broadcast_usage")
 		_, err := bot.SendMessage(ctx.EffectiveChat.Id, usageMsg, nil)
@@ -266,116 +277,266 @@ func (h *CommandHandler) AdminBroadcast(bot *gotgbot.Bot, ctx *ext.Context) erro
 	}
 
 	message := strings.Join(args[1:], " ")
+	if dryRun {
+		return h.previewBroadcast(bot, ctx, userLang, message)
+	}
 
-	// Get all active users
-	users, err := h.services.User.GetActiveUsers(context.Background())
+	// The progress message is edited as the broadcast runs, ending with its summary
+	progressMsg, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "admin_broadcast_starting"), nil)
 	if err != nil {
+		return err
+	}
+
+	// Each recipient gets the broadcast header in their own language
+	broadcastID, err := h.services.Broadcast.Start(context.Background(), userID, message, func(progress services.BroadcastProgress) {
+		h.showBroadcastProgress(bot, progressMsg, userLang, progress)
+	})
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to start admin broadcast")
 		errorMsg := h.services.Localization.T(context.Background(), userLang, "admin_broadcast_failed_get_users")
-		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
+		_, _, err := progressMsg.EditText(bot, errorMsg, nil)
 		return err
 	}
 
-	successCount := 0
-	failCount := 0
+	h.auditAdminAction(ctx, services.AuditBroadcastSent, 0, map[string]any{"broadcast_id": broadcastID, "message": message})
+	return nil
+}
 
-	// Send message to all users
-	for _, targetUser := range users {
-		if targetUser.ID == userID {
-			continue // Skip sender
-		}
+// previewBroadcast shows the admin what a broadcast would look like and how
+// many users it would reach, with a button that sends it
+func (h *CommandHandler) previewBroadcast(bot *gotgbot.Bot, ctx *ext.Context, lang, message string) error {
+	t := func(key string, args ...any) string {
+		return h.services.Localization.T(context.Background(), lang, key, args...)
+	}
 
-		broadcastHeader := h.services.Localization.T(context.Background(), userLang, "admin_broadcast_message_header", message)
-		_, err := bot.SendMessage(targetUser.ID, broadcastHeader, &gotgbot.SendMessageOpts{
-			ParseMode: "Markdown",
-		})
+	draft, err := h.services.Broadcast.DryRun(context.Background(), ctx.EffectiveUser.Id, message)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to preview admin broadcast")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, t("admin_broadcast_failed_get_users"), nil)
+		return err
+	}
+
+	text := t("admin_broadcast_preview", draft.Recipients) + "\n\n" + t("admin_broadcast_message_header", draft.Message)
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ParseMode: "Markdown",
+		ReplyMarkup: gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{{
+			{Text: t("admin_broadcast_send_now_btn"), CallbackData: "admin_broadcast_send_" + draft.ID},
+		}}},
+	})
+	return err
+}
+
+// showBroadcastProgress edits the admin's progress message of a broadcast
+func (h *CommandHandler) showBroadcastProgress(bot *gotgbot.Bot, progressMsg *gotgbot.Message, lang string, progress services.BroadcastProgress) {
+	text, keyboard := h.formatBroadcastProgress(progress, lang)
+	opts := &gotgbot.EditMessageTextOpts{ParseMode: "Markdown"}
+	if keyboard != nil {
+		opts.ReplyMarkup = *keyboard
+	}
+	if _, _, err := progressMsg.EditText(bot, text, opts); err != nil {
+		h.logger.Warn().Err(err).Str("broadcast_id", progress.ID).Msg("Failed to update broadcast progress")
+	}
+}
+
+// formatBroadcastProgress renders the progress of a broadcast, with a cancel
+// button while it runs
+func (h *CommandHandler) formatBroadcastProgress(progress services.BroadcastProgress, lang string) (string, *gotgbot.InlineKeyboardMarkup) {
+	t := func(key string, args ...any) string {
+		return h.services.Localization.T(context.Background(), lang, key, args...)
+	}
+
+	switch {
+	case progress.Cancelled:
+		return t("admin_broadcast_cancelled", progress.Sent, progress.Failed, progress.Deactivated, progress.Total-progress.Attempted()), nil
+	case progress.Done:
+		return t("admin_broadcast_results", progress.Sent, progress.Failed, progress.Deactivated, progress.Total), nil
+	}
+
+	return t("admin_broadcast_progress", progress.Attempted(), progress.Total, progress.Sent, progress.Failed, progress.Deactivated),
+		&gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{{
+			{Text: t("admin_broadcast_cancel_btn"), CallbackData: "admin_broadcast_cancel_" + progress.ID},
+		}}}
+}
+
+// handleBroadcastCallback sends a previewed broadcast or cancels a running
+// one; its final progress report then shows what was sent
+func (h *CommandHandler) handleBroadcastCallback(bot *gotgbot.Bot, ctx *ext.Context, params []string) error {
+	if len(params) < 2 || !h.requireAdmin(bot, ctx) {
+		return nil
+	}
 
-		if err != nil {
-			failCount++
-		} else {
-			successCount++
+	switch params[0] {
+	case "send":
+		return h.sendBroadcastDraft(bot, ctx, params[1])
+	case "cancel":
+		if h.services.Broadcast.Cancel(params[1]) {
+			h.logger.Info().Str("broadcast_id", params[1]).Int64("admin_id", ctx.EffectiveUser.Id).Msg("Broadcast cancelled")
+			h.auditAdminAction(ctx, services.AuditBroadcastCancelled, 0, map[string]any{"broadcast_id": params[1]})
 		}
 	}
+	return nil
+}
 
-	resultMessage := h.services.Localization.T(context.Background(), userLang, "admin_broadcast_results", successCount, failCount, len(users)-1)
+// sendBroadcastDraft starts a previewed broadcast, turning the preview into
+// its progress message
+func (h *CommandHandler) sendBroadcastDraft(bot *gotgbot.Bot, ctx *ext.Context, draftID string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+	progressMsg := &gotgbot.Message{
+		MessageId: ctx.CallbackQuery.Message.GetMessageId(),
+		Chat:      gotgbot.Chat{Id: ctx.EffectiveChat.Id},
+	}
 
-	_, err = bot.SendMessage(ctx.EffectiveChat.Id, resultMessage, &gotgbot.SendMessageOpts{
-		ParseMode: "Markdown",
+	broadcastID, err := h.services.Broadcast.StartDraft(context.Background(), draftID, userID, func(progress services.BroadcastProgress) {
+		h.showBroadcastProgress(bot, progressMsg, userLang, progress)
 	})
+	switch {
+	case errors.Is(err, services.ErrBroadcastDraftExpired):
+		_, _, err := progressMsg.EditText(bot, h.services.Localization.T(context.Background(), userLang, "admin_broadcast_draft_expired"), nil)
+		return err
+	case err != nil:
+		h.logger.Error().Err(err).Msg("Failed to start admin broadcast")
+		_, _, err := progressMsg.EditText(bot, h.services.Localization.T(context.Background(), userLang, "admin_broadcast_failed_get_users"), nil)
+		return err
+	}
 
-	return err
+	h.logger.Info().Str("draft_id", draftID).Int64("admin_id", userID).Msg("Broadcast sent from preview")
+	h.auditAdminAction(ctx, services.AuditBroadcastSent, 0, map[string]any{"broadcast_id": broadcastID})
+	return nil
 }
 
-// AdminListUsers command handler
+// AdminListUsers command handler: pages through the users, newest first,
+// with role and activity filters (Admin only)
 func (h *CommandHandler) AdminListUsers(bot *gotgbot.Bot, ctx *ext.Context) error {
-	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	if !h.requireAdmin(bot, ctx) {
+		return nil
+	}
+	h.auditAdminAction(ctx, services.AuditUsersViewed, 0, map[string]any{"view": "list"})
+	return h.showUsersPage(bot, ctx, services.UserFilterAll, 0, false)
+}
 
-	// Check admin permissions
-	user, err := h.services.User.GetUser(context.Background(), userID)
-	if err != nil || user.Role != models.RoleAdmin {
-		errorMsg := h.services.Localization.T(context.Background(), userLang, "admin_broadcast_insufficient_permissions")
-		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
+// showUsersPage sends one page of users, or edits the message of its buttons
+// into it. Users are queried again for every page, so roles changed in the
+// meantime show; a page past the end shows the last page instead.
+func (h *CommandHandler) showUsersPage(bot *gotgbot.Bot, ctx *ext.Context, filter services.UserListFilter, page int, edit bool) error {
+	userLang := h.getUserLanguage(ctx, ctx.EffectiveUser.Id)
+
+	users, total, err := h.services.User.ListUsers(context.Background(), page*services.UserListPageSize, services.UserListPageSize, filter)
+	if err == nil && len(users) == 0 && total > 0 {
+		page = userPages(total) - 1
+		users, total, err = h.services.User.ListUsers(context.Background(), page*services.UserListPageSize, services.UserListPageSize, filter)
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Str("filter", string(filter)).Msg("Failed to list users")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Failed to get user statistics. Please try again.", nil)
 		return err
 	}
-
-	// Get user statistics
 	stats, err := h.services.User.GetUserStatistics(context.Background())
 	if err != nil {
 		return err
 	}
 
-	title := h.services.Localization.T(context.Background(), userLang, "admin_users_title")
-	statisticsSection := h.services.Localization.T(context.Background(), userLang, "admin_users_statistics_section")
-	totalUsers := h.services.Localization.T(context.Background(), userLang, "admin_users_total_users", stats.TotalUsers)
-	activeUsers := h.services.Localization.T(context.Background(), userLang, "admin_users_active_users", stats.ActiveUsers)
-	newUsers := h.services.Localization.T(context.Background(), userLang, "admin_users_new_users", stats.NewUsers24h)
-	admins := h.services.Localization.T(context.Background(), userLang, "admin_users_admins", stats.AdminCount)
-	moderators := h.services.Localization.T(context.Background(), userLang, "admin_users_moderators", stats.ModeratorCount)
-
-	activitySection := h.services.Localization.T(context.Background(), userLang, "admin_users_activity_section")
-	messages := h.services.Localization.T(context.Background(), userLang, "admin_users_messages", stats.Messages24h)
-	weatherRequests := h.services.Localization.T(context.Background(), userLang, "admin_users_weather_requests", stats.WeatherRequests24h)
-	locationsSaved := h.services.Localization.T(context.Background(), userLang, "admin_users_locations_saved", stats.LocationsSaved)
-	activeAlerts := h.services.Localization.T(context.Background(), userLang, "admin_users_active_alerts", stats.ActiveAlerts)
-
-	statsText := fmt.Sprintf(`%s
-
-%s
-%s
-%s
-%s
-%s
-%s
-
-%s
-%s
-%s
-%s
-%s`,
-		title,
-		statisticsSection, totalUsers, activeUsers, newUsers, admins, moderators,
-		activitySection, messages, weatherRequests, locationsSaved, activeAlerts)
-
-	recentUsersBtn := h.services.Localization.T(context.Background(), userLang, "admin_users_recent_btn")
-	rolesBtn := h.services.Localization.T(context.Background(), userLang, "admin_users_roles_btn")
-	detailedStatsBtn := h.services.Localization.T(context.Background(), userLang, "admin_users_detailed_stats_btn")
-
-	keyboard := [][]gotgbot.InlineKeyboardButton{
-		{{Text: recentUsersBtn, CallbackData: "admin_users_recent"}},
-		{{Text: rolesBtn, CallbackData: "admin_users_roles"}},
-		{{Text: detailedStatsBtn, CallbackData: "admin_stats_detailed"}},
-	}
-
-	_, err = bot.SendMessage(ctx.EffectiveChat.Id, statsText, &gotgbot.SendMessageOpts{
-		ParseMode: "Markdown",
-		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
-			InlineKeyboard: keyboard,
-		},
+	text := h.formatUsersPage(userLang, stats, users, total, filter, page)
+	keyboard := gotgbot.InlineKeyboardMarkup{InlineKeyboard: h.usersPageKeyboard(userLang, total, filter, page)}
+	if edit {
+		_, _, err = bot.EditMessageText(text, &gotgbot.EditMessageTextOpts{
+			ChatId:      ctx.EffectiveChat.Id,
+			MessageId:   ctx.CallbackQuery.Message.GetMessageId(),
+			ParseMode:   "Markdown",
+			ReplyMarkup: keyboard,
+		})
+		return err
+	}
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &keyboard,
 	})
-
 	return err
 }
 
+// userPages returns the number of pages of users that match a filter
+func userPages(total int64) int {
+	return max(1, int((total+services.UserListPageSize-1)/services.UserListPageSize))
+}
+
+// formatUsersPage renders the user counts and a page of users, numbering them
+// across pages
+func (h *CommandHandler) formatUsersPage(userLang string, stats *services.UserStatistics, users []models.User, total int64, filter services.UserListFilter, page int) string {
+	t := func(key string, args ...interface{}) string {
+		return h.services.Localization.T(context.Background(), userLang, key, args...)
+	}
+
+	var b strings.Builder
+	b.WriteString(t("admin_users_title"))
+	b.WriteString("\n")
+	b.WriteString(strings.Join([]string{
+		t("admin_users_total_users", stats.TotalUsers),
+		t("admin_users_active_users", stats.ActiveUsers),
+		t("admin_users_admins", stats.AdminCount),
+		t("admin_users_moderators", stats.ModeratorCount),
+	}, " · "))
+	b.WriteString("\n\n")
+	b.WriteString(t("admin_users_page_summary", t("admin_users_filter_"+string(filter)), total, page+1, userPages(total)))
+	b.WriteString("\n\n")
+
+	if len(users) == 0 {
+		b.WriteString(t("admin_users_none"))
+		return b.String()
+	}
+	for i, user := range users {
+		fmt.Fprintf(&b, "*%d.* %s (`%d`), %s",
+			page*services.UserListPageSize+i+1, markdown.Escape(user.GetDisplayName()), user.ID,
+			h.getLocalizedRoleName(context.Background(), userLang, user.Role))
+		if !user.IsActive {
+			b.WriteString(", " + t("admin_users_inactive"))
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// usersPageKeyboard links the neighbouring pages and offers the filters, the
+// current one checked
+func (h *CommandHandler) usersPageKeyboard(userLang string, total int64, filter services.UserListFilter, page int) [][]gotgbot.InlineKeyboardButton {
+	var keyboard [][]gotgbot.InlineKeyboardButton
+
+	var navRow []gotgbot.InlineKeyboardButton
+	if page > 0 {
+		navRow = append(navRow, gotgbot.InlineKeyboardButton{Text: "◀️", CallbackData: fmt.Sprintf("admin_users_page_%d_%s", page-1, filter)})
+	}
+	if page < userPages(total)-1 {
+		navRow = append(navRow, gotgbot.InlineKeyboardButton{Text: "▶️", CallbackData: fmt.Sprintf("admin_users_page_%d_%s", page+1, filter)})
+	}
+	if len(navRow) > 0 {
+		keyboard = append(keyboard, navRow)
+	}
+
+	var filterRow []gotgbot.InlineKeyboardButton
+	for _, option := range services.UserListFilters {
+		text := h.services.Localization.T(context.Background(), userLang, "admin_users_filter_"+string(option))
+		if option == filter {
+			text = "✅ " + text
+		}
+		filterRow = append(filterRow, gotgbot.InlineKeyboardButton{Text: text, CallbackData: "admin_users_filter_" + string(option)})
+		// Three filters to a row keep the labels readable
+		if len(filterRow) == 3 {
+			keyboard = append(keyboard, filterRow)
+			filterRow = nil
+		}
+	}
+	if len(filterRow) > 0 {
+		keyboard = append(keyboard, filterRow)
+	}
+
+	keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
+		{Text: h.services.Localization.T(context.Background(), userLang, "admin_users_recent_btn"), CallbackData: "admin_users_recent"},
+		{Text: h.services.Localization.T(context.Background(), userLang, "admin_users_roles_btn"), CallbackData: "admin_users_roles"},
+	}, []gotgbot.InlineKeyboardButton{
+		{Text: h.services.Localization.T(context.Background(), userLang, "admin_users_detailed_stats_btn"), CallbackData: "admin_stats_detailed"},
+	})
+	return keyboard
+}
+
 // Helper methods for text formatting
 func (h *CommandHandler) getSubscriptionTypeText(subType models.SubscriptionType, language string) string {
 	switch subType {
@@ -387,6 +548,8 @@ func (h *CommandHandler) getSubscriptionTypeText(subType models.SubscriptionType
 		return h.services.Localization.T(context.Background(), language, "subscription_type_alerts")
 	case models.SubscriptionExtreme:
 		return h.services.Localization.T(context.Background(), language, "subscription_type_extreme")
+	case models.SubscriptionSunrise:
+		return h.services.Localization.T(context.Background(), language, "subscription_type_sunrise")
 	default:
 		return h.services.Localization.T(context.Background(), language, "subscription_type_unknown")
 	}
@@ -429,6 +592,10 @@ func (h *CommandHandler) getAlertTypeText(alertType models.AlertType) string {
 		return "Snow"
 	case models.AlertStorm:
 		return "Storm"
+	case models.AlertPollen:
+		return "Pollen"
+	case models.AlertTide:
+		return "Tide"
 	default:
 		return "Unknown"
 	}
@@ -446,7 +613,7 @@ func (h *CommandHandler) Language(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
 
 	// Get current user language
-	user, err := h.services.User.GetUser(context.Background(), userID)
+	user, err := h.getUser(ctx, userID)
 	if err != nil {
 		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to get user")
 		return err
@@ -466,11 +633,21 @@ func (h *CommandHandler) Language(bot *gotgbot.Bot, ctx *ext.Context) error {
 
 	message := fmt.Sprintf("%s\n\n%s", title, currentText)
 
-	// Create language selection keyboard
-	supportedLanguages := h.services.Localization.GetSupportedLanguages()
-	var keyboard [][]gotgbot.InlineKeyboardButton
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, message, &gotgbot.SendMessageOpts{
+		ParseMode: "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
+			InlineKeyboard: h.languageKeyboard(currentLang, "language_set"),
+		},
+	})
 
-	for _, lang := range supportedLanguages {
+	return err
+}
+
+// languageKeyboard lists the supported languages, the current one checked.
+// Each button's callback data is the prefix followed by the language code.
+func (h *CommandHandler) languageKeyboard(currentLang, prefix string) [][]gotgbot.InlineKeyboardButton {
+	var keyboard [][]gotgbot.InlineKeyboardButton
+	for _, lang := range h.services.Localization.GetSupportedLanguages() {
 		// Add checkmark if this is the current language
 		text := fmt.Sprintf("%s %s", lang.Flag, lang.Name)
 		if lang.Code == currentLang {
@@ -478,56 +655,87 @@ func (h *CommandHandler) Language(bot *gotgbot.Bot, ctx *ext.Context) error {
 		}
 
 		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
-			{Text: text, CallbackData: fmt.Sprintf("language_set_%s", lang.Code)},
+			{Text: text, CallbackData: fmt.Sprintf("%s_%s", prefix, lang.Code)},
 		})
 	}
+	return keyboard
+}
 
-	_, err = bot.SendMessage(ctx.EffectiveChat.Id, message, &gotgbot.SendMessageOpts{
-		ParseMode: "Markdown",
-		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
-			InlineKeyboard: keyboard,
-		},
+// Version command handler shows the build of the running bot, with a button
+// that checks GitHub for a newer release
+func (h *CommandHandler) Version(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	keyboard := [][]gotgbot.InlineKeyboardButton{{{
+		Text:         h.services.Localization.T(context.Background(), userLang, "version_check_btn"),
+		CallbackData: "version_check",
+	}}}
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.formatVersionMessage(version.GetInfo(), userLang), &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard},
 	})
 
 	return err
 }
 
-// Version command handler
-func (h *CommandHandler) Version(bot *gotgbot.Bot, ctx *ext.Context) error {
-	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
-	info := version.GetInfo()
-
-	title := h.services.Localization.T(context.Background(), userLang, "version_title")
-	versionText := h.services.Localization.T(context.Background(), userLang, "version_version", info.Version)
-	commitText := h.services.Localization.T(context.Background(), userLang, "version_commit", info.GitCommit)
-	builtText := h.services.Localization.T(context.Background(), userLang, "version_built", info.BuildTime)
-	goText := h.services.Localization.T(context.Background(), userLang, "version_go", info.GoVersion)
-	divider := h.services.Localization.T(context.Background(), userLang, "version_divider")
-	github := h.services.Localization.T(context.Background(), userLang, "version_github")
-	docs := h.services.Localization.T(context.Background(), userLang, "version_docs")
-	support := h.services.Localization.T(context.Background(), userLang, "version_support")
-
-	message := fmt.Sprintf(`%s
-
-%s
-%s
-%s
-%s
-
-%s
-%s
-%s
-%s`,
-		title, versionText, commitText, builtText, goText, divider, github, docs, support)
-
-	_, err := bot.SendMessage(ctx.EffectiveChat.Id, message, &gotgbot.SendMessageOpts{
-		ParseMode: "Markdown",
-	})
+// formatVersionMessage renders the build metadata and project links
+func (h *CommandHandler) formatVersionMessage(info version.Info, language string) string {
+	t := func(key string, args ...interface{}) string {
+		return h.services.Localization.T(context.Background(), language, key, args...)
+	}
+
+	// Build times and commits may hold underscores
+	return strings.Join([]string{
+		t("version_title"),
+		"",
+		t("version_version", markdown.Escape(info.Version)),
+		t("version_commit", markdown.Escape(info.GitCommit)),
+		t("version_built", markdown.Escape(info.BuildTime)),
+		t("version_go", markdown.Escape(info.GoVersion)),
+		t("version_platform", markdown.Escape(info.Platform)),
+		"",
+		t("version_divider"),
+		t("version_github"),
+		t("version_docs"),
+		t("version_support"),
+	}, "\n")
+}
+
+// handleVersionCallback handles the buttons of /version
+func (h *CommandHandler) handleVersionCallback(bot *gotgbot.Bot, ctx *ext.Context, subAction string) error {
+	if subAction == "check" {
+		return h.checkForUpdates(bot, ctx)
+	}
+	h.logger.Warn().Str("sub_action", subAction).Msg("Unknown version callback subaction")
+	return nil
+}
+
+// checkForUpdates tells the user whether the running version is the newest release
+func (h *CommandHandler) checkForUpdates(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userLang := h.getUserLanguage(ctx, ctx.EffectiveUser.Id)
+
+	status, err := h.services.Releases.CheckForUpdate(context.Background())
+	if err != nil {
+		h.logger.Warn().Err(err).Msg("Failed to check for updates")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "version_check_failed"), nil)
+		return err
+	}
 
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, h.formatUpdateStatus(status, userLang), &gotgbot.SendMessageOpts{ParseMode: "Markdown"})
 	return err
 }
 
+// formatUpdateStatus reports whether the bot is up to date or which release
+// is newer
+func (h *CommandHandler) formatUpdateStatus(status *services.UpdateStatus, language string) string {
+	if status.UpToDate {
+		return h.services.Localization.T(context.Background(), language, "version_up_to_date", markdown.Escape(status.Current))
+	}
+	return h.services.Localization.T(context.Background(), language, "version_update_available",
+		markdown.Escape(strings.TrimPrefix(status.Latest.Version, "v")), markdown.Escape(status.Current), status.Latest.URL)
+}
+
 // DemoReset command handler - resets demo data (admin only)
 func (h *CommandHandler) DemoReset(bot *gotgbot.Bot, ctx *ext.Context) error {
 	// Register or update user
@@ -536,7 +744,7 @@ func (h *CommandHandler) DemoReset(bot *gotgbot.Bot, ctx *ext.Context) error {
 	}
 
 	// Get user to check role
-	user, err := h.services.User.GetUser(context.Background(), ctx.EffectiveUser.Id)
+	user, err := h.getUser(ctx, ctx.EffectiveUser.Id)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get user")
 		return err
@@ -560,6 +768,7 @@ func (h *CommandHandler) DemoReset(bot *gotgbot.Bot, ctx *ext.Context) error {
 			nil)
 		return err
 	}
+	h.auditAdminAction(ctx, services.AuditDemoReset, 0, nil)
 
 	message := `✅ *Demo Data Reset*
 
@@ -588,7 +797,7 @@ func (h *CommandHandler) DemoClear(bot *gotgbot.Bot, ctx *ext.Context) error {
 	}
 
 	// Get user to check role
-	user, err := h.services.User.GetUser(context.Background(), ctx.EffectiveUser.Id)
+	user, err := h.getUser(ctx, ctx.EffectiveUser.Id)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get user")
 		return err
@@ -612,6 +821,7 @@ func (h *CommandHandler) DemoClear(bot *gotgbot.Bot, ctx *ext.Context) error {
 			nil)
 		return err
 	}
+	h.auditAdminAction(ctx, services.AuditDemoCleared, 0, nil)
 
 	message := `✅ *Demo Data Cleared*
 
@@ -625,3 +835,43 @@ To re-populate demo data, use /demoreset`
 
 	return err
 }
+
+// alertLastCheckedLine shows when the alert was last evaluated and the value
+// seen then, in the user's timezone. Alerts never checked get no line.
+func (h *CommandHandler) alertLastCheckedLine(alert models.AlertConfig, user *models.User, lang string) string {
+	if alert.LastCheckedAt == nil || alert.LastValue == nil {
+		return ""
+	}
+	zone := time.UTC
+	if user.Timezone != "" {
+		if location, err := time.LoadLocation(user.Timezone); err == nil {
+			zone = location
+		}
+	}
+	checkedAt := alert.LastCheckedAt.In(zone).Format("02 Jan 15:04")
+	value := services.FormatAlertValue(alert.AlertType, *alert.LastValue, services.UserUnits(user))
+	return fmt.Sprintf("   🕒 %s\n", h.services.Localization.T(context.Background(), lang, "alerts_last_checked", checkedAt, value))
+}
+
+// subscriptionTimezone returns the timezone subscription times are sent in:
+// the user's own, or UTC when it is unset or invalid
+func (h *CommandHandler) subscriptionTimezone(ctx *ext.Context, userID int64) string {
+	user, err := h.getUser(ctx, userID)
+	if err != nil || user == nil || user.Timezone == "" {
+		return "UTC"
+	}
+	if _, err := time.LoadLocation(user.Timezone); err != nil {
+		return "UTC"
+	}
+	return user.Timezone
+}
+
+// subscriptionTimeLabel annotates a subscription's time of day with the
+// timezone it is sent in, e.g. "08:00 (Europe/Kyiv)"
+func subscriptionTimeLabel(timeOfDay, timezone string) string {
+	if timeOfDay == models.SunriseTimeOfDay {
+		// Sunrise subscriptions have no clock time to annotate
+		return "🌅"
+	}
+	return fmt.Sprintf("%s (%s)", timeOfDay, markdown.Escape(timezone))
+}
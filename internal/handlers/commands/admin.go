@@ -1,24 +1,30 @@
 package commands
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/PaulSonOfLars/gotgbot/v2"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext"
 
+	"github.com/valpere/shopogoda/internal/locales"
+	"github.com/valpere/shopogoda/internal/markdown"
 	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/internal/services"
 )
 
 // Promote command handler - promotes a user to a higher role
 func (h *CommandHandler) Promote(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	// Check admin permissions
-	adminUser, err := h.services.User.GetUser(context.Background(), userID)
+	adminUser, err := h.getUser(ctx, userID)
 	if err != nil || adminUser.Role != models.RoleAdmin {
 		errorMsg := h.services.Localization.T(context.Background(), userLang, "admin_broadcast_insufficient_permissions")
 		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
@@ -56,7 +62,7 @@ User → Moderator → Admin`
 	}
 
 	// Get target user
-	targetUser, err := h.services.User.GetUser(context.Background(), targetUserID)
+	targetUser, err := h.getUser(ctx, targetUserID)
 	if err != nil {
 		_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ User not found", nil)
 		return err
@@ -101,48 +107,16 @@ User → Moderator → Admin`
 		return err
 	}
 
-	// Send confirmation dialog
-	currentRoleName := h.services.User.GetRoleName(targetUser.Role)
-	newRoleName := h.services.User.GetRoleName(newRole)
-
-	username := targetUser.Username
-	if username == "" {
-		username = fmt.Sprintf("%s %s", targetUser.FirstName, targetUser.LastName)
-	}
-
-	confirmMsg := fmt.Sprintf(`*Confirm Role Change*
-
-👤 *User:* %s (ID: %d)
-📊 *Current Role:* %s
-⬆️ *New Role:* %s
-
-Are you sure you want to promote this user?`,
-		username, targetUserID, currentRoleName, newRoleName)
-
-	keyboard := [][]gotgbot.InlineKeyboardButton{
-		{
-			{Text: "✅ Confirm", CallbackData: fmt.Sprintf("role_confirm_promote_%d_%d", targetUserID, newRole)},
-			{Text: "❌ Cancel", CallbackData: "role_cancel"},
-		},
-	}
-
-	_, err = bot.SendMessage(ctx.EffectiveChat.Id, confirmMsg, &gotgbot.SendMessageOpts{
-		ParseMode: "Markdown",
-		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
-			InlineKeyboard: keyboard,
-		},
-	})
-
-	return err
+	return h.sendRoleChangeConfirmation(bot, ctx, targetUser, newRole)
 }
 
 // Demote command handler - demotes a user to a lower role
 func (h *CommandHandler) Demote(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	// Check admin permissions
-	adminUser, err := h.services.User.GetUser(context.Background(), userID)
+	adminUser, err := h.getUser(ctx, userID)
 	if err != nil || adminUser.Role != models.RoleAdmin {
 		errorMsg := h.services.Localization.T(context.Background(), userLang, "admin_broadcast_insufficient_permissions")
 		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
@@ -177,7 +151,7 @@ Admin → Moderator → User`
 	}
 
 	// Get target user
-	targetUser, err := h.services.User.GetUser(context.Background(), targetUserID)
+	targetUser, err := h.getUser(ctx, targetUserID)
 	if err != nil {
 		_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ User not found", nil)
 		return err
@@ -196,37 +170,41 @@ Admin → Moderator → User`
 		newRole = models.RoleModerator
 	}
 
-	// Send confirmation dialog
-	currentRoleName := h.services.User.GetRoleName(targetUser.Role)
-	newRoleName := h.services.User.GetRoleName(newRole)
+	return h.sendRoleChangeConfirmation(bot, ctx, targetUser, newRole)
+}
 
-	username := targetUser.Username
-	if username == "" {
-		username = fmt.Sprintf("%s %s", targetUser.FirstName, targetUser.LastName)
+// sendRoleChangeConfirmation asks the admin to confirm moving the target user
+// to newRole, one step up or down from their current role
+func (h *CommandHandler) sendRoleChangeConfirmation(bot *gotgbot.Bot, ctx *ext.Context, targetUser *models.User, newRole models.UserRole) error {
+	action, arrow := "promote", "⬆️"
+	if newRole < targetUser.Role {
+		action, arrow = "demote", "⬇️"
 	}
+	currentRoleName := h.services.User.GetRoleName(targetUser.Role)
+	newRoleName := h.services.User.GetRoleName(newRole)
 
 	confirmMsg := fmt.Sprintf(`*Confirm Role Change*
 
 👤 *User:* %s (ID: %d)
 📊 *Current Role:* %s
-⬇️ *New Role:* %s
+%s *New Role:* %s
 
-Are you sure you want to demote this user?`,
-		username, targetUserID, currentRoleName, newRoleName)
+Are you sure you want to %s this user?`,
+		userDisplayName(targetUser), targetUser.ID, currentRoleName, arrow, newRoleName, action)
 
 	// Special warning for demoting admin
-	if targetUser.Role == models.RoleAdmin {
+	if action == "demote" && targetUser.Role == models.RoleAdmin {
 		confirmMsg += "\n\n⚠️ *Warning:* Demoting an Admin is a significant action."
 	}
 
 	keyboard := [][]gotgbot.InlineKeyboardButton{
 		{
-			{Text: "✅ Confirm", CallbackData: fmt.Sprintf("role_confirm_demote_%d_%d", targetUserID, newRole)},
-			{Text: "❌ Cancel", CallbackData: "role_cancel"},
+			{Text: "✅ Confirm", CallbackData: fmt.Sprintf("role_confirm_%s_%d_%d", action, targetUser.ID, newRole)},
+			{Text: "❌ Cancel", CallbackData: fmt.Sprintf("role_cancel_%d", targetUser.ID)},
 		},
 	}
 
-	_, err = bot.SendMessage(ctx.EffectiveChat.Id, confirmMsg, &gotgbot.SendMessageOpts{
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, confirmMsg, &gotgbot.SendMessageOpts{
 		ParseMode: "Markdown",
 		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
 			InlineKeyboard: keyboard,
@@ -236,12 +214,28 @@ Are you sure you want to demote this user?`,
 	return err
 }
 
+// userDisplayName is the user's @-less username, or their name for users
+// who have not set one
+func userDisplayName(user *models.User) string {
+	if user.Username != "" {
+		return user.Username
+	}
+	return fmt.Sprintf("%s %s", user.FirstName, user.LastName)
+}
+
 // handleRoleCallback processes role change confirmation callbacks
 func (h *CommandHandler) handleRoleCallback(bot *gotgbot.Bot, ctx *ext.Context, action string, params []string) error {
 	switch action {
 	case "confirm":
 		return h.confirmRoleChange(bot, ctx, params)
 	case "cancel":
+		// role_cancel_<targetUserID>; buttons sent before the target was
+		// added carry none
+		var targetUserID int64
+		if len(params) > 0 {
+			targetUserID, _ = strconv.ParseInt(params[0], 10, 64)
+		}
+		h.auditAdminAction(ctx, services.AuditRoleChangeCancelled, targetUserID, nil)
 		return h.cancelRoleChange(bot, ctx)
 	default:
 		h.logger.Warn().Str("action", action).Msg("Unknown role callback action")
@@ -281,7 +275,7 @@ func (h *CommandHandler) confirmRoleChange(bot *gotgbot.Bot, ctx *ext.Context, p
 	newRole := models.UserRole(newRoleInt)
 
 	// Get target user before change for comparison
-	targetUser, err := h.services.User.GetUser(context.Background(), targetUserID)
+	targetUser, err := h.getUser(ctx, targetUserID)
 	if err != nil {
 		_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ User not found", nil)
 		return err
@@ -294,16 +288,14 @@ func (h *CommandHandler) confirmRoleChange(bot *gotgbot.Bot, ctx *ext.Context, p
 	err = h.services.User.ChangeUserRole(context.Background(), adminID, targetUserID, newRole)
 	if err != nil {
 		h.logger.Error().Err(err).Int64("admin_id", adminID).Int64("target_user_id", targetUserID).Msg("Failed to change user role")
+		h.auditAdminAction(ctx, services.AuditRoleChangeFailed, targetUserID, map[string]any{"new_role": int(newRole), "error": err.Error()})
 
 		errorMsg := fmt.Sprintf("❌ Failed to change role: %v", err)
 		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
 		return err
 	}
 
-	username := targetUser.Username
-	if username == "" {
-		username = fmt.Sprintf("%s %s", targetUser.FirstName, targetUser.LastName)
-	}
+	username := userDisplayName(targetUser)
 
 	successMsg := fmt.Sprintf(`✅ *Role Changed Successfully*
 
@@ -336,3 +328,395 @@ func (h *CommandHandler) cancelRoleChange(bot *gotgbot.Bot, ctx *ext.Context) er
 
 	return err
 }
+
+const flagsUsage = `*Usage:*
+/flags list
+/flags set <flag> on|off|<N>%|user:<id>[:off]
+/flags reset <flag>
+
+*Examples:*
+/flags set charts 10% - Roll charts out to 10% of users
+/flags set nowcast user:123456789 - Enable nowcast for one user
+/flags reset charts - Drop runtime overrides`
+
+// flagsCommand is a parsed /flags invocation
+type flagsCommand struct {
+	action  string // list, set or reset
+	feature services.Feature
+	change  services.FlagChange
+}
+
+// parseFlagsCommand parses the arguments of /flags, including the command itself
+func parseFlagsCommand(args []string) (flagsCommand, error) {
+	if len(args) < 2 {
+		return flagsCommand{action: "list"}, nil
+	}
+
+	cmd := flagsCommand{action: strings.ToLower(args[1])}
+	switch cmd.action {
+	case "list":
+		return cmd, nil
+	case "set":
+		if len(args) < 4 {
+			return flagsCommand{}, fmt.Errorf("set needs a flag and a value")
+		}
+		change, err := services.ParseFlagChange(args[3])
+		if err != nil {
+			return flagsCommand{}, err
+		}
+		cmd.feature = services.Feature(strings.ToLower(args[2]))
+		cmd.change = change
+		return cmd, nil
+	case "reset":
+		if len(args) < 3 {
+			return flagsCommand{}, fmt.Errorf("reset needs a flag")
+		}
+		cmd.feature = services.Feature(strings.ToLower(args[2]))
+		return cmd, nil
+	default:
+		return flagsCommand{}, fmt.Errorf("unknown action: %s", args[1])
+	}
+}
+
+// Flags command handler - lists and changes feature flags at runtime
+func (h *CommandHandler) Flags(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	// Check admin permissions
+	adminUser, err := h.getUser(ctx, userID)
+	if err != nil || adminUser.Role != models.RoleAdmin {
+		errorMsg := h.services.Localization.T(context.Background(), userLang, "admin_broadcast_insufficient_permissions")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
+		return err
+	}
+
+	cmd, err := parseFlagsCommand(ctx.Args())
+	if err != nil {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, fmt.Sprintf("❌ %s\n\n%s", markdown.Escape(err.Error()), flagsUsage), &gotgbot.SendMessageOpts{
+			ParseMode: "Markdown",
+		})
+		return err
+	}
+
+	var reply string
+	switch cmd.action {
+	case "list":
+		statuses, err := h.services.FeatureFlags.List(context.Background())
+		if err != nil {
+			h.logger.Error().Err(err).Msg("Failed to list feature flags")
+			_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Failed to load feature flags", nil)
+			return err
+		}
+		reply = formatFlagStatuses(statuses)
+	case "set":
+		if err := h.services.FeatureFlags.Set(context.Background(), userID, cmd.feature, cmd.change); err != nil {
+			h.logger.Error().Err(err).Str("flag", string(cmd.feature)).Msg("Failed to set feature flag")
+			_, err := bot.SendMessage(ctx.EffectiveChat.Id, fmt.Sprintf("❌ %v", err), nil)
+			return err
+		}
+		reply = fmt.Sprintf("✅ Flag `%s` set to `%s`", cmd.feature, cmd.change)
+	case "reset":
+		if err := h.services.FeatureFlags.Reset(context.Background(), userID, cmd.feature); err != nil {
+			h.logger.Error().Err(err).Str("flag", string(cmd.feature)).Msg("Failed to reset feature flag")
+			_, err := bot.SendMessage(ctx.EffectiveChat.Id, fmt.Sprintf("❌ %v", err), nil)
+			return err
+		}
+		reply = fmt.Sprintf("✅ Flag `%s` reset to its config or default value", cmd.feature)
+	}
+
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, reply, &gotgbot.SendMessageOpts{
+		ParseMode: "Markdown",
+	})
+	return err
+}
+
+// formatFlagStatuses renders every flag with the layers that are set
+func formatFlagStatuses(statuses []services.FlagStatus) string {
+	var b strings.Builder
+	b.WriteString("🚩 *Feature Flags*\n")
+
+	for _, status := range statuses {
+		fmt.Fprintf(&b, "\n`%s` - %s\n", status.Name, status.Description)
+
+		layers := []string{"default " + onOff(status.State.Default)}
+		if status.State.Config != nil {
+			layers = append(layers, "config "+onOff(*status.State.Config))
+		}
+		if status.State.Percentage != nil {
+			layers = append(layers, fmt.Sprintf("rollout %d%%", *status.State.Percentage))
+		}
+		if len(status.State.Users) > 0 {
+			layers = append(layers, fmt.Sprintf("%d user overrides", len(status.State.Users)))
+		}
+		b.WriteString("• " + strings.Join(layers, ", ") + "\n")
+	}
+
+	return b.String()
+}
+
+func onOff(enabled bool) string {
+	if enabled {
+		return "on"
+	}
+	return "off"
+}
+
+// DeadLetters command handler - shows scheduled notifications that could not
+// be delivered, grouped by error class, with buttons to retry, purge or export
+// a class (Admin only)
+func (h *CommandHandler) DeadLetters(bot *gotgbot.Bot, ctx *ext.Context) error {
+	if !h.requireAdmin(bot, ctx) {
+		return nil
+	}
+	return h.showDeadLetters(bot, ctx)
+}
+
+// Reload command handler - reloads the template overrides without a restart,
+// like sending the process SIGHUP (Admin only)
+func (h *CommandHandler) Reload(bot *gotgbot.Bot, ctx *ext.Context) error {
+	if !h.requireAdmin(bot, ctx) {
+		return nil
+	}
+
+	report, err := h.services.Localization.ReloadOverrides()
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to reload template overrides")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, fmt.Sprintf("❌ Reload failed, the previous overrides stay in effect: %v", err), nil)
+		return err
+	}
+
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, formatOverrideReport(report), &gotgbot.SendMessageOpts{
+		ParseMode: "Markdown",
+	})
+	return err
+}
+
+// formatOverrideReport renders the result of a template override reload
+func formatOverrideReport(report services.OverrideReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔄 *Template overrides reloaded*\n\n%d in effect", report.Loaded)
+	if len(report.Rejected) > 0 {
+		fmt.Fprintf(&b, "\n\n❌ Rejected, format verbs differ from the bundled text:\n`%s`", strings.Join(report.Rejected, "`, `"))
+	}
+	if len(report.Unknown) > 0 {
+		fmt.Fprintf(&b, "\n\n⚠️ Ignored, unknown key or language:\n`%s`", strings.Join(report.Unknown, "`, `"))
+	}
+	return b.String()
+}
+
+// I18nReport command handler - checks the bundled translations against the
+// default language and the keys used in code; "full" lists every key found
+// (Admin only)
+func (h *CommandHandler) I18nReport(bot *gotgbot.Bot, ctx *ext.Context) error {
+	if !h.requireAdmin(bot, ctx) {
+		return nil
+	}
+
+	args := ctx.Args()
+	full := len(args) > 1 && strings.EqualFold(args[1], "full")
+	report := h.services.Localization.CheckConsistency(locales.ReferencedKeys)
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, formatLocalizationReport(report, full), &gotgbot.SendMessageOpts{
+		ParseMode: "Markdown",
+	})
+	return err
+}
+
+// maxReportLength keeps a report below Telegram's 4096 character limit
+const maxReportLength = 4000
+
+// formatLocalizationReport renders a localization check, with the keys of
+// every finding when full is set
+func formatLocalizationReport(report *services.LocalizationReport, full bool) string {
+	var b strings.Builder
+	b.WriteString("🌐 *Localization report*\n\n")
+	if report.Problems() == 0 {
+		b.WriteString("✅ All languages match " + report.Language)
+		return b.String()
+	}
+
+	keys := func(keys []string) {
+		if full && len(keys) > 0 {
+			fmt.Fprintf(&b, "\n`%s`", strings.Join(keys, "`, `"))
+		}
+	}
+
+	if len(report.MissingReferenced) > 0 {
+		fmt.Fprintf(&b, "❌ %d keys used in code are missing from %s", len(report.MissingReferenced), report.Language)
+		keys(report.MissingReferenced)
+		b.WriteString("\n\n")
+	}
+	for _, language := range report.Languages() {
+		fmt.Fprintf(&b, "*%s*: %d missing, %d extra, %d verb mismatches\n", language,
+			len(report.Missing[language]), len(report.Extra[language]), len(report.VerbMismatches[language]))
+		if full {
+			for _, finding := range []struct {
+				label string
+				keys  []string
+			}{
+				{"Missing", report.Missing[language]},
+				{"Extra", report.Extra[language]},
+				{"Verb mismatches", report.VerbMismatches[language]},
+			} {
+				if len(finding.keys) > 0 {
+					fmt.Fprintf(&b, "%s:", finding.label)
+					keys(finding.keys)
+					b.WriteString("\n")
+				}
+			}
+			b.WriteString("\n")
+		}
+	}
+	if !full {
+		b.WriteString("\nSend /i18nreport full for the keys")
+	}
+
+	text := strings.TrimRight(b.String(), "\n")
+	if len(text) > maxReportLength {
+		// Cut at a line break so no code span is left open
+		text = text[:strings.LastIndexByte(text[:maxReportLength], '\n')] + "\n…"
+	}
+	return text
+}
+
+// ClaimAdmin command handler: redeems the first-run admin claim code offered
+// on /start while the deployment has no admin
+func (h *CommandHandler) ClaimAdmin(bot *gotgbot.Bot, ctx *ext.Context) error {
+	args := ctx.Args()
+	if len(args) < 2 {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, "*Usage:* /claimadmin <code>", &gotgbot.SendMessageOpts{
+			ParseMode: "Markdown",
+		})
+		return err
+	}
+
+	err := h.services.User.ClaimAdmin(context.Background(), ctx.EffectiveUser.Id, args[1])
+	switch {
+	case errors.Is(err, services.ErrAdminClaimInvalid):
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ This claim code is not valid", nil)
+		return err
+	case err != nil:
+		h.logger.Error().Err(err).Int64("user_id", ctx.EffectiveUser.Id).Msg("Failed to claim admin")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Failed to claim admin, please try again", nil)
+		return err
+	}
+
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, "✅ You are now an admin. Add more admins with /promote or ADMIN_TELEGRAM_IDS.", nil)
+	return err
+}
+
+// requireAdmin reports whether the sender is an admin and tells them otherwise
+func (h *CommandHandler) requireAdmin(bot *gotgbot.Bot, ctx *ext.Context) bool {
+	userID := ctx.EffectiveUser.Id
+	adminUser, err := h.getUser(ctx, userID)
+	if err == nil && adminUser.Role == models.RoleAdmin {
+		return true
+	}
+
+	userLang := h.getUserLanguage(ctx, userID)
+	errorMsg := h.services.Localization.T(context.Background(), userLang, "admin_broadcast_insufficient_permissions")
+	if _, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil); err != nil {
+		h.logger.Warn().Err(err).Msg("Failed to send permission error")
+	}
+	return false
+}
+
+func (h *CommandHandler) showDeadLetters(bot *gotgbot.Bot, ctx *ext.Context) error {
+	classes, err := h.services.Outbox.DeadLetters(context.Background())
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to load dead letters")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Failed to load dead letters", nil)
+		return err
+	}
+
+	var keyboard [][]gotgbot.InlineKeyboardButton
+	for _, class := range classes {
+		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
+			{Text: "🔁 Retry " + class.ErrorClass, CallbackData: "admin_deadletters_retry_" + class.ErrorClass},
+			{Text: "🗑 Purge", CallbackData: "admin_deadletters_purge_" + class.ErrorClass},
+			{Text: "📄 Export", CallbackData: "admin_deadletters_export_" + class.ErrorClass},
+		})
+	}
+
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, formatDeadLetters(classes, time.Now()), &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	return err
+}
+
+// formatDeadLetters renders the dead letter count and age range of each class
+func formatDeadLetters(classes []services.DeadLetterClass, now time.Time) string {
+	if len(classes) == 0 {
+		return "📭 No dead letters"
+	}
+
+	var b strings.Builder
+	b.WriteString("📭 *Dead Letters*\n")
+	for _, class := range classes {
+		fmt.Fprintf(&b, "\n`%s` - %d items, oldest %s ago, newest %s ago",
+			class.ErrorClass, class.Count, formatAge(now.Sub(class.Oldest)), formatAge(now.Sub(class.Newest)))
+	}
+	return b.String()
+}
+
+// formatAge renders a duration in its largest whole unit, e.g. "3d" or "5h"
+func formatAge(age time.Duration) string {
+	switch {
+	case age >= 24*time.Hour:
+		return fmt.Sprintf("%dd", int(age/(24*time.Hour)))
+	case age >= time.Hour:
+		return fmt.Sprintf("%dh", int(age/time.Hour))
+	default:
+		return fmt.Sprintf("%dm", int(age/time.Minute))
+	}
+}
+
+func (h *CommandHandler) handleDeadLetterCallback(bot *gotgbot.Bot, ctx *ext.Context, params []string) error {
+	if len(params) < 2 || !h.requireAdmin(bot, ctx) {
+		return nil
+	}
+	action, errorClass := params[0], params[1]
+
+	var reply string
+	switch action {
+	case "retry":
+		moved, err := h.services.Outbox.RetryClass(context.Background(), errorClass)
+		if err != nil {
+			h.logger.Error().Err(err).Str("error_class", errorClass).Msg("Failed to retry dead letters")
+			reply = "❌ Failed to retry dead letters"
+			break
+		}
+		h.auditAdminAction(ctx, services.AuditDeadLetters, 0, map[string]any{"action": action, "error_class": errorClass, "count": moved})
+		reply = fmt.Sprintf("🔁 %d `%s` items moved back to pending, they are retried with the next hourly run", moved, errorClass)
+	case "purge":
+		purged, err := h.services.Outbox.PurgeClass(context.Background(), errorClass)
+		if err != nil {
+			h.logger.Error().Err(err).Str("error_class", errorClass).Msg("Failed to purge dead letters")
+			reply = "❌ Failed to purge dead letters"
+			break
+		}
+		h.auditAdminAction(ctx, services.AuditDeadLetters, 0, map[string]any{"action": action, "error_class": errorClass, "count": purged})
+		reply = fmt.Sprintf("🗑 %d `%s` items purged", purged, errorClass)
+	case "export":
+		data, err := h.services.Outbox.ExportClass(context.Background(), errorClass)
+		if err != nil {
+			h.logger.Error().Err(err).Str("error_class", errorClass).Msg("Failed to export dead letters")
+			reply = "❌ Failed to export dead letters"
+			break
+		}
+		h.auditAdminAction(ctx, services.AuditDeadLetters, 0, map[string]any{"action": action, "error_class": errorClass})
+		filename := fmt.Sprintf("dead-letters-%s-%s.json", errorClass, time.Now().UTC().Format("20060102-150405"))
+		_, err = bot.SendDocument(ctx.EffectiveChat.Id, gotgbot.InputFileByReader(filename, bytes.NewReader(data)), nil)
+		return err
+	default:
+		return nil
+	}
+
+	h.services.Outbox.UpdateMetrics(context.Background())
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, reply, &gotgbot.SendMessageOpts{
+		ParseMode: "Markdown",
+	})
+	return err
+}
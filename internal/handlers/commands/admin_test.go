@@ -2,13 +2,17 @@ package commands
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/valpere/shopogoda/internal/locales"
 	"github.com/valpere/shopogoda/internal/models"
 	"github.com/valpere/shopogoda/internal/services"
 	"github.com/valpere/shopogoda/pkg/metrics"
@@ -691,3 +695,325 @@ func TestCommandHandler_handleRoleCallback(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestParseFlagsCommand(t *testing.T) {
+	t.Run("list is the default", func(t *testing.T) {
+		cmd, err := parseFlagsCommand([]string{"/flags"})
+		require.NoError(t, err)
+		assert.Equal(t, "list", cmd.action)
+
+		cmd, err = parseFlagsCommand([]string{"/flags", "LIST"})
+		require.NoError(t, err)
+		assert.Equal(t, "list", cmd.action)
+	})
+
+	t.Run("set percentage", func(t *testing.T) {
+		cmd, err := parseFlagsCommand([]string{"/flags", "set", "Charts", "10%"})
+		require.NoError(t, err)
+		assert.Equal(t, "set", cmd.action)
+		assert.Equal(t, services.FeatureCharts, cmd.feature)
+		require.NotNil(t, cmd.change.Percentage)
+		assert.Equal(t, 10, *cmd.change.Percentage)
+	})
+
+	t.Run("set user override", func(t *testing.T) {
+		cmd, err := parseFlagsCommand([]string{"/flags", "set", "nowcast", "user:12345"})
+		require.NoError(t, err)
+		assert.Equal(t, services.FeatureNowcast, cmd.feature)
+		assert.Equal(t, int64(12345), cmd.change.UserID)
+		assert.True(t, cmd.change.Enabled)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		cmd, err := parseFlagsCommand([]string{"/flags", "reset", "charts"})
+		require.NoError(t, err)
+		assert.Equal(t, "reset", cmd.action)
+		assert.Equal(t, services.FeatureCharts, cmd.feature)
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"/flags", "set", "charts"},
+			{"/flags", "set", "charts", "sometimes"},
+			{"/flags", "reset"},
+			{"/flags", "toggle", "charts"},
+		} {
+			_, err := parseFlagsCommand(args)
+			assert.Error(t, err, "args %v", args)
+		}
+	})
+}
+
+func TestFormatFlagStatuses(t *testing.T) {
+	on := true
+	ten := 10
+
+	text := formatFlagStatuses([]services.FlagStatus{
+		{Name: services.FeatureCharts, Description: "Forecast charts", State: services.FlagState{Config: &on, Percentage: &ten, Users: map[int64]bool{1: true}}},
+		{Name: services.FeatureNowcast, Description: "Next-hour precipitation nowcast"},
+	})
+
+	assert.Contains(t, text, "`charts` - Forecast charts\n• default off, config on, rollout 10%, 1 user overrides")
+	assert.Contains(t, text, "`nowcast` - Next-hour precipitation nowcast\n• default off\n")
+}
+
+func TestFormatDeadLetters(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	text := formatDeadLetters([]services.DeadLetterClass{
+		{ErrorClass: services.DeliveryErrorBlocked, Count: 12, Oldest: now.Add(-72 * time.Hour), Newest: now.Add(-5 * time.Hour)},
+		{ErrorClass: services.DeliveryErrorBadRequest, Count: 1, Oldest: now.Add(-40 * time.Minute), Newest: now.Add(-40 * time.Minute)},
+	}, now)
+
+	assert.Contains(t, text, "`blocked` - 12 items, oldest 3d ago, newest 5h ago")
+	assert.Contains(t, text, "`bad-request` - 1 items, oldest 40m ago, newest 40m ago")
+	assert.Equal(t, "📭 No dead letters", formatDeadLetters(nil, now))
+}
+
+func TestFormatOverrideReport(t *testing.T) {
+	text := formatOverrideReport(services.OverrideReport{
+		Loaded:   3,
+		Rejected: []string{"en-US/greeting"},
+		Unknown:  []string{"en-US/gretting", "pl-PL"},
+	})
+
+	assert.Contains(t, text, "3 in effect")
+	assert.Contains(t, text, "format verbs differ from the bundled text:\n`en-US/greeting`")
+	assert.Contains(t, text, "unknown key or language:\n`en-US/gretting`, `pl-PL`")
+	assert.NotContains(t, formatOverrideReport(services.OverrideReport{Loaded: 1}), "Rejected")
+}
+
+func TestFormatLocalizationReport(t *testing.T) {
+	report := &services.LocalizationReport{
+		Language:          "en-US",
+		MissingReferenced: []string{"alerts_none"},
+		Missing:           map[string][]string{"de-DE": {"records", "weather"}},
+		Extra:             map[string][]string{},
+		VerbMismatches:    map[string][]string{"uk-UA": {"greeting"}},
+	}
+
+	summary := formatLocalizationReport(report, false)
+	assert.Contains(t, summary, "❌ 1 keys used in code are missing from en-US\n\n")
+	assert.Contains(t, summary, "*de-DE*: 2 missing, 0 extra, 0 verb mismatches\n*uk-UA*: 0 missing, 0 extra, 1 verb mismatches")
+	assert.Contains(t, summary, "/i18nreport full")
+	assert.NotContains(t, summary, "`records`")
+
+	full := formatLocalizationReport(report, true)
+	assert.Contains(t, full, "missing from en-US\n`alerts_none`")
+	assert.Contains(t, full, "Missing:\n`records`, `weather`")
+	assert.Contains(t, full, "Verb mismatches:\n`greeting`")
+	assert.NotContains(t, full, "Extra:")
+
+	clean := &services.LocalizationReport{Language: "en-US"}
+	assert.Equal(t, "🌐 *Localization report*\n\n✅ All languages match en-US", formatLocalizationReport(clean, true))
+}
+
+func TestFormatUserInfo(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	testServices := newTestServices(mockDB, helpers.NewMockRedis())
+	require.NoError(t, testServices.Localization.LoadTranslations(locales.LocalesFS))
+	logger := zerolog.Nop()
+	handler := New(testServices, &logger)
+
+	seen := time.Date(2025, 6, 1, 14, 3, 0, 0, time.UTC)
+	checked := time.Date(2025, 6, 1, 14, 0, 0, 0, time.UTC)
+	user := &models.User{
+		ID: 123, FirstName: "Olena", Language: "uk-UA", Units: "metric", Timezone: "Europe/Kyiv",
+		Role: models.RoleModerator, IsActive: true, LastActiveAt: &seen,
+		LocationName: "Kyiv", Latitude: 50.4501, Longitude: 30.5234,
+	}
+	subscriptions := []models.Subscription{
+		{SubscriptionType: models.SubscriptionDaily, Frequency: models.FrequencyDaily, TimeOfDay: "08:00"},
+	}
+	alerts := []models.AlertConfig{
+		{AlertType: models.AlertTemperature, Condition: `{"operator":"gt","value":30}`, Threshold: 30, LastCheckedAt: &checked},
+		{AlertType: models.AlertWindSpeed, Condition: `{"operator":"gte","value":50}`, Threshold: 50},
+	}
+
+	text := handler.formatUserInfo(user, subscriptions, alerts, true)
+	assert.Contains(t, text, "*Username:* none")
+	assert.Contains(t, text, "*Role:* Moderator")
+	assert.Contains(t, text, "*Last seen:* 2025-06-01 14:03 UTC")
+	assert.Contains(t, text, "📍 *Location:* Kyiv (50.4501, 30.5234)")
+	assert.Contains(t, text, "• Daily Weather, Daily at 08:00")
+	assert.Contains(t, text, "• Temperature > 30.0°C, checked Jun 1 14:00 UTC")
+	assert.Contains(t, text, "• Wind Speed ≥ 50.0 km/h, never checked")
+
+	// Moderators do not see where the user lives
+	user.Username = "kyiv_walker"
+	text = handler.formatUserInfo(user, nil, nil, false)
+	assert.Contains(t, text, "*Username:* @kyiv\\_walker")
+	assert.Contains(t, text, "📍 *Location:* set")
+	assert.NotContains(t, text, "50.4501")
+	assert.Contains(t, text, "*Subscriptions (0):*\nnone")
+}
+
+func TestUserInfoKeyboard(t *testing.T) {
+	callbacks := func(keyboard [][]gotgbot.InlineKeyboardButton) []string {
+		var data []string
+		for _, row := range keyboard {
+			for _, button := range row {
+				data = append(data, button.CallbackData)
+			}
+		}
+		return data
+	}
+
+	assert.Equal(t, []string{"userinfo_promote_1", "userinfo_deactivate_1"},
+		callbacks(userInfoKeyboard(&models.User{ID: 1, Role: models.RoleUser, IsActive: true})))
+	assert.Equal(t, []string{"userinfo_promote_2", "userinfo_demote_2"},
+		callbacks(userInfoKeyboard(&models.User{ID: 2, Role: models.RoleModerator})))
+	assert.Equal(t, []string{"userinfo_demote_3", "userinfo_deactivate_3"},
+		callbacks(userInfoKeyboard(&models.User{ID: 3, Role: models.RoleAdmin, IsActive: true})))
+}
+
+func TestFormatFeedbackPage(t *testing.T) {
+	assert.Equal(t, "✅ No unresolved feedback", formatFeedbackPage(nil, 0, 0))
+
+	created := time.Date(2025, 6, 1, 14, 3, 0, 0, time.UTC)
+	items := []models.Feedback{
+		{UserID: 123, Message: "Alerts in *bold* please", CreatedAt: created, User: models.User{ID: 123, Username: "kyiv_walker"}},
+		{UserID: 456, Message: strings.Repeat("a", feedbackListPreviewLength+10), CreatedAt: created},
+	}
+
+	text := formatFeedbackPage(items, 7, 1)
+	assert.True(t, strings.HasPrefix(text, "📝 *Unresolved feedback (7)*"))
+	assert.Contains(t, text, "*6.* kyiv\\_walker (ID: `123`), Jun 1 14:03 UTC\nAlerts in \\*bold\\* please")
+	assert.Contains(t, text, "*7.*")
+	assert.Contains(t, text, strings.Repeat("a", feedbackListPreviewLength)+"…")
+	assert.True(t, strings.HasSuffix(text, "Page 2/2"))
+
+	// A single page has no page counter
+	assert.NotContains(t, formatFeedbackPage(items[:1], 1, 0), "Page")
+}
+
+func TestFeedbackPageKeyboard(t *testing.T) {
+	first, second := uuid.New(), uuid.New()
+	items := []models.Feedback{{ID: first}, {ID: second}}
+
+	keyboard := feedbackPageKeyboard(items, 12, 1)
+	require.Len(t, keyboard, 3)
+	assert.Equal(t, "✅ 6", keyboard[0][0].Text)
+	assert.Equal(t, fmt.Sprintf("feedback_resolve_%s_1", first), keyboard[0][0].CallbackData)
+	assert.Equal(t, fmt.Sprintf("feedback_resolve_%s_1", second), keyboard[0][1].CallbackData)
+	assert.Equal(t, "💬 7", keyboard[1][1].Text)
+	assert.Equal(t, "feedback_reply_"+second.String(), keyboard[1][1].CallbackData)
+	assert.Equal(t, "feedback_page_0", keyboard[2][0].CallbackData)
+	assert.Equal(t, "feedback_page_2", keyboard[2][1].CallbackData)
+	for _, button := range keyboard[0] {
+		assert.LessOrEqual(t, len(button.CallbackData), 64, "Telegram limits callback data to 64 bytes")
+	}
+
+	assert.Empty(t, feedbackPageKeyboard(nil, 0, 0))
+}
+
+func TestFormatAuditLogPage(t *testing.T) {
+	assert.Equal(t, "📜 No admin actions recorded yet", formatAuditLogPage(nil, 0, 0))
+
+	created := time.Date(2026, 10, 17, 9, 5, 0, 0, time.UTC)
+	entries := []models.AuditLog{
+		{ActorID: 100, Action: services.AuditRoleChanged, TargetID: 200, Metadata: []byte(`{"new_role":2,"old_role":1}`), CreatedAt: created},
+		{ActorID: 100, Action: services.AuditBroadcastSent, Metadata: []byte(`{"message":"` + strings.Repeat("a", auditLogPreviewLength) + `"}`), CreatedAt: created},
+		{ActorID: 101, Action: services.AuditUsersViewed, Metadata: []byte(`{}`), CreatedAt: created},
+	}
+
+	text := formatAuditLogPage(entries, 13, 1)
+	assert.True(t, strings.HasPrefix(text, "📜 *Audit log (13)*"))
+	assert.Contains(t, text, "*11.* role\\_changed by `100` on `200`, Oct 17 09:05 UTC\n{\"new\\_role\":2,\"old\\_role\":1}\n")
+	assert.Contains(t, text, "*12.* broadcast\\_sent by `100`, Oct 17 09:05 UTC\n")
+	assert.Contains(t, text, "…")
+	assert.Contains(t, text, "*13.* users\\_viewed by `101`, Oct 17 09:05 UTC\n\nPage 2/2")
+	assert.True(t, strings.HasSuffix(text, "Page 2/2"))
+
+	// Admins made through the configuration were made by no admin
+	text = formatAuditLogPage([]models.AuditLog{{Action: services.AuditRoleChanged, TargetID: 200, CreatedAt: created}}, 1, 0)
+	assert.Contains(t, text, "*1.* role\\_changed on `200`, Oct 17 09:05 UTC")
+}
+
+func TestAuditLogPageKeyboard(t *testing.T) {
+	keyboard := auditLogPageKeyboard(25, 1)
+	require.Len(t, keyboard, 1)
+	assert.Equal(t, "auditlog_page_0", keyboard[0][0].CallbackData)
+	assert.Equal(t, "auditlog_page_2", keyboard[0][1].CallbackData)
+
+	assert.Empty(t, auditLogPageKeyboard(5, 0))
+}
+
+func TestCommandHandler_AuditsRoleChangeCancel(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	testServices := newTestServices(mockDB, helpers.NewMockRedis())
+	testServices.Audit = services.NewAuditService(mockDB.DB)
+	logger := zerolog.Nop()
+	handler := New(testServices, &logger)
+
+	adminID, targetUserID := int64(100), int64(200)
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(adminID, services.AuditRoleChangeCancelled, targetUserID, []byte(`{}`), "", helpers.AnyTime{}).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mockDB.Mock.ExpectCommit()
+
+	mockCtx := helpers.NewMockContextWithCallback(adminID, "test_callback", "role_cancel_200")
+	err := handler.handleRoleCallback(helpers.NewMockBot().Bot, mockCtx.Context, "cancel", []string{"200"})
+
+	assert.NoError(t, err)
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestCommandHandler_AuditsRemoteAddr(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	testServices := newTestServices(mockDB, helpers.NewMockRedis())
+	testServices.Audit = services.NewAuditService(mockDB.DB)
+	logger := zerolog.Nop()
+	handler := New(testServices, &logger)
+
+	// An update posted to the webhook carries the address it came from
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(int64(100), services.AuditDemoReset, int64(0), []byte(`{}`), "149.154.167.220", helpers.AnyTime{}).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mockDB.Mock.ExpectCommit()
+
+	mockCtx := helpers.NewSimpleMockContext(100, "/demoreset")
+	mockCtx.Context.Data = map[string]any{RemoteAddrKey: "149.154.167.220"}
+	handler.auditAdminAction(mockCtx.Context, services.AuditDemoReset, 0, nil)
+
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestUsersPage(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	testServices := newTestServices(mockDB, helpers.NewMockRedis())
+	require.NoError(t, testServices.Localization.LoadTranslations(locales.LocalesFS))
+	logger := zerolog.Nop()
+	handler := New(testServices, &logger)
+
+	stats := &services.UserStatistics{TotalUsers: 25, ActiveUsers: 20, AdminCount: 1, ModeratorCount: 2}
+	users := []models.User{
+		{ID: 123, Username: "kyiv_walker", Role: models.RoleModerator, IsActive: true},
+		{ID: 456, FirstName: "Olena", Role: models.RoleUser},
+	}
+
+	text := handler.formatUsersPage("en-US", stats, users, 25, services.UserFilterAll, 1)
+	assert.Contains(t, text, "Total Users: 25 · Active Users: 20 · Admins: 1 · Moderators: 2")
+	assert.Contains(t, text, "🔎 All: 25 · page 2/3")
+	assert.Contains(t, text, "*11.* @kyiv\\_walker (`123`), Moderator\n")
+	assert.True(t, strings.HasSuffix(text, "*12.* Olena (`456`), User, inactive"))
+	assert.Contains(t, handler.formatUsersPage("en-US", stats, nil, 0, services.UserFilterAdmins, 0), "No users match this filter.")
+
+	keyboard := handler.usersPageKeyboard("en-US", 25, services.UserFilterActive, 1)
+	require.Len(t, keyboard, 5)
+	assert.Equal(t, "admin_users_page_0_active", keyboard[0][0].CallbackData)
+	assert.Equal(t, "admin_users_page_2_active", keyboard[0][1].CallbackData)
+	assert.Equal(t, "admin_users_filter_all", keyboard[1][0].CallbackData)
+	assert.Equal(t, "✅ Active", keyboard[2][0].Text)
+	assert.Equal(t, "Inactive", keyboard[2][1].Text)
+
+	// A single page has no page buttons
+	keyboard = handler.usersPageKeyboard("en-US", 3, services.UserFilterAll, 0)
+	assert.Equal(t, "✅ All", keyboard[0][0].Text)
+}
@@ -0,0 +1,145 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/services"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+// userAPIKeyKey memoizes the user's own weather API key in the update data
+const userAPIKeyKey = "user_api_key"
+
+// weatherContext returns the context weather requests for the user who sent
+// the update are made with, carrying their own API key when they set one
+func (h *CommandHandler) weatherContext(ctx *ext.Context) context.Context {
+	if key := h.userAPIKey(ctx); key != "" {
+		return weather.WithAPIKey(context.Background(), key)
+	}
+	return context.Background()
+}
+
+// userAPIKey returns the weather API key of the user who sent the update, or
+// "" for none. It is read once per update; without it the bot's key is used.
+func (h *CommandHandler) userAPIKey(ctx *ext.Context) string {
+	if ctx.EffectiveUser == nil || !h.services.User.APIKeysEnabled() {
+		return ""
+	}
+	if key, ok := ctx.Data[userAPIKeyKey].(string); ok {
+		return key
+	}
+
+	key, err := h.services.User.GetUserAPIKey(context.Background(), ctx.EffectiveUser.Id)
+	if err != nil {
+		h.logger.Warn().Err(err).Int64("user_id", ctx.EffectiveUser.Id).Msg("Failed to get user API key")
+	}
+	if ctx.Data == nil {
+		ctx.Data = make(map[string]any)
+	}
+	ctx.Data[userAPIKeyKey] = key
+	return key
+}
+
+// SetAPIKey command stores the user's own OpenWeather API key after checking
+// that OpenWeather accepts it. Their weather requests then count against the
+// key's rate limit rather than the bot's. The message with the key is deleted.
+func (h *CommandHandler) SetAPIKey(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+	reply := func(key string, args ...any) error {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, key, args...), &gotgbot.SendMessageOpts{
+			ParseMode: "Markdown",
+		})
+		return err
+	}
+
+	if !h.services.User.APIKeysEnabled() {
+		return reply("apikey_unavailable")
+	}
+
+	var key string
+	if args := ctx.Args(); len(args) > 1 {
+		key = strings.TrimSpace(args[1])
+	}
+	if key == "" {
+		return reply("apikey_usage")
+	}
+
+	// Keep the key out of the chat history
+	if _, err := bot.DeleteMessage(ctx.EffectiveChat.Id, ctx.EffectiveMessage.MessageId, nil); err != nil {
+		h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to delete API key message")
+	}
+	if isGroupChat(ctx.EffectiveChat) {
+		return reply("apikey_private_only")
+	}
+
+	if err := h.services.Weather.ValidateAPIKey(context.Background(), key); err != nil {
+		if errors.Is(err, weather.ErrUnauthorized) {
+			return reply("apikey_invalid")
+		}
+		h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to check user API key")
+		return reply("apikey_check_failed")
+	}
+
+	if err := h.services.User.SetUserAPIKey(context.Background(), userID, key); err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to save user API key")
+		return reply("apikey_save_failed")
+	}
+	delete(ctx.Data, userAPIKeyKey)
+
+	return reply("apikey_saved", services.MaskAPIKey(key))
+}
+
+// handleAPIKeySettings shows the user's own API key, masked, with a button to
+// remove it
+func (h *CommandHandler) handleAPIKeySettings(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	var text string
+	keyboard := [][]gotgbot.InlineKeyboardButton{}
+	switch key := h.userAPIKey(ctx); {
+	case !h.services.User.APIKeysEnabled():
+		text = h.services.Localization.T(context.Background(), userLang, "apikey_unavailable")
+	case key == "":
+		text = h.services.Localization.T(context.Background(), userLang, "apikey_settings_none")
+	default:
+		text = h.services.Localization.T(context.Background(), userLang, "apikey_settings_current", services.MaskAPIKey(key))
+		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{{
+			Text:         h.services.Localization.T(context.Background(), userLang, "button_apikey_remove"),
+			CallbackData: "settings_apikey_remove",
+		}})
+	}
+	keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{{
+		Text:         h.services.Localization.T(context.Background(), userLang, "button_back_to_settings"),
+		CallbackData: "settings_main",
+	}})
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	return err
+}
+
+// removeAPIKey drops the user's own API key, so their requests use the bot's
+// again
+func (h *CommandHandler) removeAPIKey(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	key := "apikey_removed"
+	if err := h.services.User.ClearUserAPIKey(context.Background(), userID); err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to remove user API key")
+		key = "apikey_save_failed"
+	}
+	delete(ctx.Data, userAPIKeyKey)
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, key), nil)
+	return err
+}
@@ -0,0 +1,148 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+// aqiForecastHours is how far ahead /aqiforecast shows the air quality
+const aqiForecastHours = 24
+
+// dayPartEmoji marks each part of the day in /aqiforecast
+var dayPartEmoji = map[string]string{
+	weather.DayPartNight:     "🌙",
+	weather.DayPartMorning:   "🌅",
+	weather.DayPartAfternoon: "☀️",
+	weather.DayPartEvening:   "🌆",
+}
+
+// AQIForecast command shows the air quality of the next 24 hours, the worst
+// hour of each part of the day with the pollutant behind it
+func (h *CommandHandler) AQIForecast(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+
+	location := h.parseLocationFromArgs(ctx)
+	if location == "" {
+		locationName, err := h.getChatLocation(ctx, userID)
+		if err != nil || locationName == "" {
+			return h.sendLocationNeeded(bot, ctx, "aqiforecast_location_needed")
+		}
+		location = locationName
+	}
+	return h.sendAirQualityForecast(bot, ctx, location)
+}
+
+// sendAirQualityForecast looks a place up by name and shows its air quality
+// forecast
+func (h *CommandHandler) sendAirQualityForecast(bot *gotgbot.Bot, ctx *ext.Context, location string) error {
+	locationData, err := h.services.Weather.GeocodeLocation(h.weatherContext(ctx), location)
+	if err != nil {
+		return h.sendWeatherError(bot, ctx, location, err, "location_not_found", location)
+	}
+	return h.sendAirQualityForecastAt(bot, ctx, location, locationData.Name, locationData.Latitude, locationData.Longitude)
+}
+
+// sendAirQualityForecastAt shows the air quality forecast at the coordinates,
+// titled with name
+func (h *CommandHandler) sendAirQualityForecastAt(bot *gotgbot.Bot, ctx *ext.Context, location, name string, lat, lon float64) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	forecast, err := h.services.Weather.GetAirQualityForecast(h.weatherContext(ctx), lat, lon, weather.AirQualityForecastHours)
+	if errors.Is(err, weather.ErrNotSupported) {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "aqiforecast_unavailable"), nil)
+		return err
+	}
+	if err != nil {
+		return h.sendWeatherError(bot, ctx, location, err, "aqiforecast_error")
+	}
+
+	if err := h.services.User.IncrementWeatherRequestCounter(context.Background()); err != nil {
+		h.logger.Warn().Err(err).Msg("Failed to increment weather request counter")
+	}
+
+	hours := forecast.Next(time.Now(), aqiForecastHours)
+	if len(hours) == 0 {
+		return h.sendWeatherError(bot, ctx, location, weather.ErrNoAirData, "aqiforecast_error")
+	}
+
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, h.formatAirQualityForecast(name, hours, userLang, h.getUserTimezone(ctx, userID)), &gotgbot.SendMessageOpts{
+		ParseMode: "Markdown",
+	})
+	return err
+}
+
+// formatAirQualityForecast renders the worst hour of each part of the day
+// with the pollutant behind it, that of the worst hour of all in bold, and
+// what the worst hour means for health. Times are in zone.
+func (h *CommandHandler) formatAirQualityForecast(locationName string, hours []weather.AirQualityHour, language string, zone *time.Location) string {
+	worst, _ := weather.WorstAirQualityHour(hours)
+	lines := []string{
+		h.services.Localization.T(context.Background(), language, "aqiforecast_title", markdown.EscapeBold(locationName)),
+		h.services.Localization.T(context.Background(), language, "aqiforecast_subtitle"),
+		"",
+	}
+
+	for _, period := range weather.AirQualityDayParts(hours, zone) {
+		pollutant := h.pollutantName(period.Worst.Pollutant(), language)
+		if period.Worst.Time.Equal(worst.Time) {
+			pollutant = "*" + pollutant + "*"
+		}
+		lines = append(lines, dayPartEmoji[period.Part]+" "+h.services.Localization.T(context.Background(), language, "aqiforecast_period",
+			h.services.Localization.T(context.Background(), language, "daypart_"+period.Part),
+			h.weekdayName(language, period.Start.Weekday()),
+			weather.FormatAQI(float64(period.Worst.AQI)), h.getAQIDescription(period.Worst.AQI, language), pollutant))
+	}
+
+	local := worst.Time.In(zone)
+	lines = append(lines, "",
+		h.services.Localization.T(context.Background(), language, "aqiforecast_worst",
+			local.Format("15:04"), h.weekdayName(language, local.Weekday()),
+			weather.FormatAQI(float64(worst.AQI)), "*"+h.pollutantName(worst.Pollutant(), language)+"*"),
+		h.getHealthRecommendation(worst.AQI, language))
+	return strings.Join(lines, "\n")
+}
+
+// pollutantName names a pollutant of weather.DominantPollutant
+func (h *CommandHandler) pollutantName(pollutant, language string) string {
+	return h.services.Localization.T(context.Background(), language, "pollutant_"+pollutant)
+}
+
+// handleAQIForecastCallback answers the AQI forecast button of the air
+// quality message, for a named place or for coordinates
+func (h *CommandHandler) handleAQIForecastCallback(bot *gotgbot.Bot, ctx *ext.Context, action string, params []string) error {
+	if action == "coords" {
+		if len(params) < 2 {
+			return nil
+		}
+		lat, err := strconv.ParseFloat(params[0], 64)
+		if err != nil {
+			return err
+		}
+		lon, err := strconv.ParseFloat(params[1], 64)
+		if err != nil {
+			return err
+		}
+		name, err := h.services.Weather.GetLocationName(h.weatherContext(ctx), lat, lon)
+		if err != nil || name == "" {
+			name = fmt.Sprintf("%.4f, %.4f", lat, lon)
+		}
+		return h.sendAirQualityForecastAt(bot, ctx, "", name, lat, lon)
+	}
+
+	locationName, err := h.callbackLabel(append([]string{action}, params...))
+	if err != nil {
+		return h.sendLabelExpired(bot, ctx)
+	}
+	return h.sendAirQualityForecast(bot, ctx, locationName)
+}
@@ -0,0 +1,219 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/services"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+const (
+	// astroMaxDays is how many days ahead /astro can be browsed
+	astroMaxDays = 60
+
+	// astroDateFormat is how /astro buttons carry the day to show
+	astroDateFormat = "20060102"
+)
+
+// astroPhases are the principal moon phases /astro offers to jump to, at
+// their weather.MoonPhase values
+var astroPhases = []struct {
+	phase float64
+	name  string
+}{
+	{0, weather.MoonNew},
+	{0.25, weather.MoonFirstQuarter},
+	{0.5, weather.MoonFull},
+	{0.75, weather.MoonLastQuarter},
+}
+
+// astroPhaseEmoji marks the principal phases on the /astro buttons
+var astroPhaseEmoji = map[string]string{
+	weather.MoonNew:          "🌑",
+	weather.MoonFirstQuarter: "🌓",
+	weather.MoonFull:         "🌕",
+	weather.MoonLastQuarter:  "🌗",
+}
+
+// Astro command shows the moon's phase, moonrise and moonset, and the
+// astronomical night at a place today, with buttons to browse the coming
+// days and jump to the next principal moon phases
+func (h *CommandHandler) Astro(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+
+	location := h.parseLocationFromArgs(ctx)
+	if location == "" {
+		locationName, err := h.getChatLocation(ctx, userID)
+		if err != nil || locationName == "" {
+			return h.sendLocationNeeded(bot, ctx, "astro_location_needed")
+		}
+		location = locationName
+	}
+	return h.sendAstro(bot, ctx, location, h.astroToday(ctx, userID), false)
+}
+
+// astroToday returns the midnight starting the user's current day
+func (h *CommandHandler) astroToday(ctx *ext.Context, userID int64) time.Time {
+	now := time.Now().In(h.getUserTimezone(ctx, userID))
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// sendAstro shows the sky of a day at a location. Browsing edits the message
+// the buttons belong to instead of sending a new one.
+func (h *CommandHandler) sendAstro(bot *gotgbot.Bot, ctx *ext.Context, location string, day time.Time, edit bool) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	locationData, err := h.services.Weather.GeocodeLocation(h.weatherContext(ctx), location)
+	if err != nil {
+		return h.sendWeatherError(bot, ctx, location, err, "location_not_found", location)
+	}
+
+	data, err := h.services.Weather.GetAstroData(context.Background(), locationData.Latitude, locationData.Longitude, day)
+	if err != nil {
+		return h.sendWeatherError(bot, ctx, location, err, "astro_error")
+	}
+
+	if !edit {
+		if err := h.services.User.IncrementWeatherRequestCounter(context.Background()); err != nil {
+			h.logger.Warn().Err(err).Msg("Failed to increment weather request counter")
+		}
+	}
+
+	text := h.formatAstroMessage(locationData.Name, data, userLang)
+	keyboard := gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: h.astroKeyboard(location, data.Date, h.astroToday(ctx, userID), time.Now(), userLang),
+	}
+
+	if edit && ctx.CallbackQuery != nil {
+		_, _, err = bot.EditMessageText(text, &gotgbot.EditMessageTextOpts{
+			ChatId:      ctx.EffectiveChat.Id,
+			MessageId:   ctx.CallbackQuery.Message.GetMessageId(),
+			ParseMode:   "Markdown",
+			ReplyMarkup: keyboard,
+		})
+		return err
+	}
+
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &keyboard,
+	})
+	return err
+}
+
+// formatAstroMessage renders the moon and the astronomical night of a day,
+// with times in the location of data.Date, the user's timezone
+func (h *CommandHandler) formatAstroMessage(locationName string, data *weather.AstroData, language string) string {
+	t := func(key string, args ...interface{}) string {
+		return h.services.Localization.T(context.Background(), language, key, args...)
+	}
+	zone := data.Date.Location()
+
+	lines := []string{
+		t("astro_title", markdown.EscapeBold(locationName)),
+		t("astro_date", h.weekdayName(language, data.Date.Weekday()), data.Date.Format("02.01.2006")),
+		"",
+		t("astro_moon_phase", data.Emoji(), t("moon_"+data.MoonPhase), int(math.Round(data.MoonIllumination*100))),
+	}
+
+	if data.Moonrise.IsZero() {
+		lines = append(lines, t("astro_no_moonrise"))
+	} else {
+		lines = append(lines, t("astro_moonrise", data.Moonrise.In(zone).Format("15:04")))
+	}
+	if data.Moonset.IsZero() {
+		lines = append(lines, t("astro_no_moonset"))
+	} else {
+		lines = append(lines, t("astro_moonset", data.Moonset.In(zone).Format("15:04")))
+	}
+
+	lines = append(lines, "")
+	if data.AstroTwilight.IsZero() {
+		lines = append(lines, t("astro_no_night"))
+	} else {
+		lines = append(lines, t("astro_night", data.AstroTwilight.In(zone).Format("15:04"), data.AstroDawn.In(zone).Format("15:04")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// astroKeyboard pages /astro a day back or ahead within astroMaxDays of today,
+// and jumps to the days of the next principal moon phases after now
+func (h *CommandHandler) astroKeyboard(location string, day, today, now time.Time, language string) [][]gotgbot.InlineKeyboardButton {
+	button := func(text string, date time.Time) gotgbot.InlineKeyboardButton {
+		data, _ := h.callbackWithLabel("astro_"+date.Format(astroDateFormat), location)
+		return gotgbot.InlineKeyboardButton{Text: text, CallbackData: data}
+	}
+	dayLabel := func(date time.Time) string {
+		weekday := h.services.Localization.T(context.Background(), language, "weekday_short_"+strings.ToLower(date.Weekday().String()))
+		return weekday + " " + date.Format("02.01")
+	}
+
+	var paging []gotgbot.InlineKeyboardButton
+	if day.After(today) {
+		previous := day.AddDate(0, 0, -1)
+		paging = append(paging, button("◀️ "+dayLabel(previous), previous))
+		if previous.After(today) {
+			paging = append(paging, button(h.services.Localization.T(context.Background(), language, "button_astro_today"), today))
+		}
+	}
+	if next := day.AddDate(0, 0, 1); !next.After(today.AddDate(0, 0, astroMaxDays)) {
+		paging = append(paging, button(dayLabel(next)+" ▶️", next))
+	}
+
+	type phaseDay struct {
+		name string
+		date time.Time
+	}
+	phases := make([]phaseDay, 0, len(astroPhases))
+	for _, phase := range astroPhases {
+		at := weather.NextMoonPhase(now, phase.phase).In(today.Location())
+		phases = append(phases, phaseDay{phase.name, time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())})
+	}
+	sort.Slice(phases, func(i, j int) bool { return phases[i].date.Before(phases[j].date) })
+
+	jumps := make([]gotgbot.InlineKeyboardButton, 0, len(phases))
+	for _, phase := range phases {
+		jumps = append(jumps, button(astroPhaseEmoji[phase.name]+" "+phase.date.Format("02.01"), phase.date))
+	}
+
+	keyboard := [][]gotgbot.InlineKeyboardButton{jumps}
+	if len(paging) > 0 {
+		keyboard = append([][]gotgbot.InlineKeyboardButton{paging}, keyboard...)
+	}
+	return keyboard
+}
+
+// handleAstroCallback shows the day a /astro button picked, kept within
+// astroMaxDays of today
+func (h *CommandHandler) handleAstroCallback(bot *gotgbot.Bot, ctx *ext.Context, dateParam string, params []string) error {
+	today := h.astroToday(ctx, ctx.EffectiveUser.Id)
+	day, err := time.ParseInLocation(astroDateFormat, dateParam, today.Location())
+	if err != nil {
+		return fmt.Errorf("invalid astro date %q: %w", dateParam, err)
+	}
+	if day.Before(today) {
+		day = today
+	} else if last := today.AddDate(0, 0, astroMaxDays); day.After(last) {
+		day = last
+	}
+
+	location, err := h.callbackLabel(params)
+	if err != nil {
+		if errors.Is(err, services.ErrLabelExpired) {
+			return h.sendLabelExpired(bot, ctx)
+		}
+		return err
+	}
+	return h.sendAstro(bot, ctx, location, day, true)
+}
@@ -0,0 +1,161 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/internal/services"
+)
+
+// auditLogPreviewLength shortens the details of each /auditlog entry, such
+// as the text of a broadcast, so that a full page stays within Telegram's
+// message limit
+const auditLogPreviewLength = 200
+
+// RemoteAddrKey is the key of the update data holding the address an update
+// was posted from, which the bot sets for updates that come over HTTP
+const RemoteAddrKey = "remote_addr"
+
+// auditAdminAction records an action the sender took as an admin. The action
+// has been taken by then, so a failure to record it is only logged.
+func (h *CommandHandler) auditAdminAction(ctx *ext.Context, action string, targetID int64, metadata map[string]any) {
+	if h.services.Audit == nil {
+		return
+	}
+	ip, _ := ctx.Data[RemoteAddrKey].(string)
+	err := h.services.Audit.Log(context.Background(), services.AuditEntry{
+		ActorID:  ctx.EffectiveUser.Id,
+		Action:   action,
+		TargetID: targetID,
+		Metadata: metadata,
+		IP:       ip,
+	})
+	if err != nil {
+		h.logger.Error().Err(err).Int64("admin_id", ctx.EffectiveUser.Id).Str("action", action).Msg("Failed to audit admin action")
+	}
+}
+
+// AuditLog command handler: pages through the admin actions, newest first.
+// /auditlog 3 opens the third page. (Admin only)
+func (h *CommandHandler) AuditLog(bot *gotgbot.Bot, ctx *ext.Context) error {
+	if !h.requireAdmin(bot, ctx) {
+		return nil
+	}
+
+	page := 0
+	if args := ctx.Args(); len(args) > 1 {
+		number, err := strconv.Atoi(args[1])
+		if err != nil || number < 1 {
+			_, err := bot.SendMessage(ctx.EffectiveChat.Id, "Usage: /auditlog [page]", nil)
+			return err
+		}
+		page = number - 1
+	}
+	return h.showAuditLogPage(bot, ctx, page, false)
+}
+
+// showAuditLogPage sends one page of the audit log, or edits the message of
+// its buttons into it. A page past the end shows the last page instead.
+func (h *CommandHandler) showAuditLogPage(bot *gotgbot.Bot, ctx *ext.Context, page int, edit bool) error {
+	entries, total, err := h.services.Audit.List(context.Background(), page)
+	if err == nil && len(entries) == 0 && total > 0 {
+		page = auditLogPages(total) - 1
+		entries, total, err = h.services.Audit.List(context.Background(), page)
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to list audit log")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Failed to load the audit log", nil)
+		return err
+	}
+
+	text := formatAuditLogPage(entries, total, page)
+	keyboard := gotgbot.InlineKeyboardMarkup{InlineKeyboard: auditLogPageKeyboard(total, page)}
+	if edit {
+		_, _, err = bot.EditMessageText(text, &gotgbot.EditMessageTextOpts{
+			ChatId:      ctx.EffectiveChat.Id,
+			MessageId:   ctx.CallbackQuery.Message.GetMessageId(),
+			ParseMode:   "Markdown",
+			ReplyMarkup: keyboard,
+		})
+		return err
+	}
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &keyboard,
+	})
+	return err
+}
+
+// auditLogPages returns the number of pages of the audit log
+func auditLogPages(total int64) int {
+	return max(1, int((total+services.AuditPageSize-1)/services.AuditPageSize))
+}
+
+// formatAuditLogPage renders a page of the audit log: the action, who took
+// it on whom, when, and its details. Actions of the deployment itself name
+// no admin.
+func formatAuditLogPage(entries []models.AuditLog, total int64, page int) string {
+	if total == 0 {
+		return "📜 No admin actions recorded yet"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📜 *Audit log (%d)*\n\n", total)
+	for i, entry := range entries {
+		fmt.Fprintf(&b, "*%d.* %s", page*services.AuditPageSize+i+1, markdown.Escape(entry.Action))
+		if entry.ActorID != 0 {
+			fmt.Fprintf(&b, " by `%d`", entry.ActorID)
+		}
+		if entry.TargetID != 0 {
+			fmt.Fprintf(&b, " on `%d`", entry.TargetID)
+		}
+		fmt.Fprintf(&b, ", %s\n", entry.CreatedAt.UTC().Format("Jan 2 15:04 UTC"))
+		if details := string(entry.Metadata); details != "" && details != "{}" && details != "null" {
+			if utf8.RuneCountInString(details) > auditLogPreviewLength {
+				details = string([]rune(details)[:auditLogPreviewLength]) + "…"
+			}
+			b.WriteString(markdown.Escape(details) + "\n")
+		}
+		b.WriteString("\n")
+	}
+	if pages := auditLogPages(total); pages > 1 {
+		fmt.Fprintf(&b, "Page %d/%d", page+1, pages)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// auditLogPageKeyboard links the neighbouring pages of the audit log
+func auditLogPageKeyboard(total int64, page int) [][]gotgbot.InlineKeyboardButton {
+	var navRow []gotgbot.InlineKeyboardButton
+	if page > 0 {
+		navRow = append(navRow, gotgbot.InlineKeyboardButton{Text: "◀️ Previous", CallbackData: fmt.Sprintf("auditlog_page_%d", page-1)})
+	}
+	if page < auditLogPages(total)-1 {
+		navRow = append(navRow, gotgbot.InlineKeyboardButton{Text: "Next ▶️", CallbackData: fmt.Sprintf("auditlog_page_%d", page+1)})
+	}
+	if len(navRow) == 0 {
+		return nil
+	}
+	return [][]gotgbot.InlineKeyboardButton{navRow}
+}
+
+// handleAuditLogCallback turns the pages of /auditlog
+func (h *CommandHandler) handleAuditLogCallback(bot *gotgbot.Bot, ctx *ext.Context, action string, params []string) error {
+	if action != "page" || len(params) < 1 || !h.requireAdmin(bot, ctx) {
+		return nil
+	}
+	page, err := strconv.Atoi(params[0])
+	if err != nil {
+		h.logger.Warn().Err(err).Str("page", params[0]).Msg("Invalid audit log page")
+		return nil
+	}
+	return h.showAuditLogPage(bot, ctx, page, true)
+}
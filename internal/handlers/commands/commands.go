@@ -3,10 +3,13 @@ package commands
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/url"
-	"os"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -19,24 +22,88 @@ import (
 	"github.com/rs/zerolog"
 
 	"github.com/valpere/shopogoda/internal"
+	"github.com/valpere/shopogoda/internal/markdown"
 	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/internal/render"
 	"github.com/valpere/shopogoda/internal/services"
 	"github.com/valpere/shopogoda/pkg/weather"
 )
 
 type CommandHandler struct {
 	services *services.Services
+	renderer *render.TemplateRenderer
 	logger   *zerolog.Logger
 }
 
-// availableCommands lists all available bot commands
-var availableCommands = []string{
-	"start", "help", "settings", "language", "version",
-	"weather", "forecast", "air",
-	"setlocation",
-	"subscribe", "unsubscribe", "subscriptions",
-	"addalert", "alerts", "removealert",
-	"stats", "broadcast", "users", "demoreset", "democlear",
+// CommandMetadata describes a bot command for typo suggestions and /search
+type CommandMetadata struct {
+	Name        string
+	Description string // locale key of the one-line description
+	Category    string // locale key of the /help section listing the command
+}
+
+// Command categories, named after their /help section headings
+const (
+	categoryBasic         = "help_basic_commands"
+	categoryLocation      = "help_location_management"
+	categoryNotifications = "help_notifications"
+	categoryAlerts        = "help_alerts"
+	categorySettings      = "help_settings"
+	categoryAdmin         = "help_admin_commands"
+)
+
+// commandRegistry lists all available bot commands
+var commandRegistry = []CommandMetadata{
+	{Name: "start", Description: "help_start", Category: categoryBasic},
+	{Name: "help", Description: "help_help", Category: categoryBasic},
+	{Name: "search", Description: "help_search", Category: categoryBasic},
+	{Name: "version", Description: "help_version", Category: categoryBasic},
+	{Name: "weather", Description: "help_weather", Category: categoryBasic},
+	{Name: "forecast", Description: "help_forecast", Category: categoryBasic},
+	{Name: "hourly", Description: "help_hourly", Category: categoryBasic},
+	{Name: "air", Description: "help_air", Category: categoryBasic},
+	{Name: "aqiforecast", Description: "help_aqiforecast", Category: categoryBasic},
+	{Name: "uvindex", Description: "help_uvindex", Category: categoryBasic},
+	{Name: "sun", Description: "help_sun", Category: categoryBasic},
+	{Name: "astro", Description: "help_astro", Category: categoryBasic},
+	{Name: "pollen", Description: "help_pollen", Category: categoryBasic},
+	{Name: "tide", Description: "help_tide", Category: categoryBasic},
+	{Name: "compare", Description: "help_compare", Category: categoryBasic},
+	{Name: "history", Description: "help_history", Category: categoryBasic},
+	{Name: "radar", Description: "help_radar", Category: categoryBasic},
+	{Name: "map", Description: "help_map", Category: categoryBasic},
+	{Name: "today", Description: "help_today", Category: categoryBasic},
+	{Name: "share", Description: "help_share", Category: categoryBasic},
+	{Name: "weatherhere", Description: "help_weatherhere", Category: categoryBasic},
+	{Name: "feedback", Description: "help_feedback", Category: categoryBasic},
+	{Name: "setlocation", Description: "help_setlocation", Category: categoryLocation},
+	{Name: "locations", Description: "help_locations", Category: categoryLocation},
+	{Name: "setgrouplocation", Description: "help_setgrouplocation", Category: categoryLocation},
+	{Name: "subscribe", Description: "help_subscribe", Category: categoryNotifications},
+	{Name: "unsubscribe", Description: "help_unsubscribe", Category: categoryNotifications},
+	{Name: "subscriptions", Description: "help_subscriptions", Category: categoryNotifications},
+	{Name: "unpin", Description: "help_unpin", Category: categoryNotifications},
+	{Name: "addalert", Description: "help_addalert", Category: categoryAlerts},
+	{Name: "alerts", Description: "help_view_alerts", Category: categoryAlerts},
+	{Name: "removealert", Description: "help_removealert", Category: categoryAlerts},
+	{Name: "quietdays", Description: "help_quietdays", Category: categoryAlerts},
+	{Name: "webhook", Description: "help_webhook", Category: categoryAlerts},
+	{Name: "settings", Description: "help_settings_summary", Category: categorySettings},
+	{Name: "language", Description: "help_language", Category: categorySettings},
+	{Name: "transfer", Description: "help_transfer", Category: categorySettings},
+	{Name: "privacy", Description: "help_privacy", Category: categorySettings},
+	{Name: "deletemydata", Description: "help_deletemydata", Category: categorySettings},
+	{Name: "setapikey", Description: "help_setapikey", Category: categorySettings},
+	{Name: "deactivate", Description: "help_deactivate", Category: categorySettings},
+	{Name: "reactivate", Description: "help_reactivate", Category: categorySettings},
+	{Name: "stats", Description: "help_stats", Category: categoryAdmin},
+	{Name: "broadcast", Description: "help_broadcast", Category: categoryAdmin},
+	{Name: "users", Description: "help_users", Category: categoryAdmin},
+	{Name: "userinfo", Description: "help_userinfo", Category: categoryAdmin},
+	{Name: "feedbacklist", Description: "help_feedbacklist", Category: categoryAdmin},
+	{Name: "auditlog", Description: "help_auditlog", Category: categoryAdmin},
+	{Name: "demoreset", Description: "help_demoreset", Category: categoryAdmin},
+	{Name: "democlear", Description: "help_democlear", Category: categoryAdmin},
 }
 
 const (
@@ -47,11 +114,21 @@ const (
 	thresholdOptionsRange = 3   // Number of options to show above/below current value
 	minThresholdOptions   = 5   // Minimum number of threshold options to display
 	maxThresholdOptions   = 7   // Maximum number of threshold options to display
+
+	// Alert history view linked from the daily digest
+	alertHistoryDays  = 30 // How far back the history goes
+	alertHistoryLimit = 20 // Most alerts listed at once
 )
 
 func New(services *services.Services, logger *zerolog.Logger) *CommandHandler {
+	renderer, err := render.NewTemplateRenderer(render.TemplatesFS, services.Localization)
+	if err != nil {
+		// The templates are embedded, so this fails on every start or never
+		panic(err)
+	}
 	return &CommandHandler{
 		services: services,
+		renderer: renderer,
 		logger:   logger,
 	}
 }
@@ -70,12 +147,12 @@ func suggestSimilarCommands(input string, maxSuggestions int) []string {
 	suggestions := make([]commandSuggestion, 0)
 
 	// Calculate edit distance for each command
-	for _, cmd := range availableCommands {
-		distance := edlib.LevenshteinDistance(input, cmd)
+	for _, cmd := range commandRegistry {
+		distance := edlib.LevenshteinDistance(input, cmd.Name)
 		// Only suggest commands with reasonable edit distance (less than half the command length)
-		if distance <= len(cmd)/2+1 {
+		if distance <= len(cmd.Name)/2+1 {
 			suggestions = append(suggestions, commandSuggestion{
-				command:  cmd,
+				command:  cmd.Name,
 				distance: distance,
 			})
 		}
@@ -103,22 +180,70 @@ func suggestSimilarCommands(input string, maxSuggestions int) []string {
 	return result
 }
 
+// getUser returns a user, reading the sender of the update from the snapshot
+// memoized for the update
+func (h *CommandHandler) getUser(ctx *ext.Context, userID int64) (*models.User, error) {
+	return h.services.User.GetUpdateUser(context.Background(), ctx, userID)
+}
+
+// getUserLocation is UserService.GetUserLocation served from the user
+// memoized for the update
+func (h *CommandHandler) getUserLocation(ctx *ext.Context, userID int64) (string, float64, float64, error) {
+	user, err := h.getUser(ctx, userID)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if user.LocationName == "" {
+		return "", 0, 0, fmt.Errorf("user has no location set")
+	}
+	return user.LocationName, user.Latitude, user.Longitude, nil
+}
+
 // getUserLanguage gets the user's language preference or returns default
-func (h *CommandHandler) getUserLanguage(ctx context.Context, userID int64) string {
-	user, err := h.services.User.GetUser(ctx, userID)
+func (h *CommandHandler) getUserLanguage(ctx *ext.Context, userID int64) string {
+	user, err := h.getUser(ctx, userID)
 	if err != nil || user == nil || user.Language == "" {
 		return internal.DefaultLanguage // default to English
 	}
 	return user.Language
 }
 
+// getUserUnits gets the user's unit system or returns metric
+func (h *CommandHandler) getUserUnits(ctx *ext.Context, userID int64) string {
+	user, err := h.getUser(ctx, userID)
+	if err != nil {
+		user = nil
+	}
+	return services.UserUnits(user)
+}
+
+// getUserTimezone returns the user's timezone, or UTC when it is unset or invalid
+func (h *CommandHandler) getUserTimezone(ctx *ext.Context, userID int64) *time.Location {
+	user, err := h.getUser(ctx, userID)
+	if err != nil {
+		user = nil
+	}
+	return services.UserTimezone(user)
+}
+
+// renderForUser renders weather content in the user's language and, when they
+// have bilingual output on, follows it with the summary in their secondary language
+func (h *CommandHandler) renderForUser(ctx *ext.Context, userID int64, render, summary services.RenderFunc) string {
+	user, err := h.getUser(ctx, userID)
+	if err != nil {
+		user = nil
+	}
+	return services.RenderForUser(user, render, summary)
+}
+
 // ensureUserRegistered ensures the user is registered, auto-registering if needed
 // Returns true if user was just registered (new user), false if already existed
-func (h *CommandHandler) ensureUserRegistered(ctx context.Context, user *gotgbot.User) bool {
-	dbUser, err := h.services.User.GetUser(ctx, user.Id)
+func (h *CommandHandler) ensureUserRegistered(ctx *ext.Context) bool {
+	user := ctx.EffectiveUser
+	dbUser, err := h.getUser(ctx, user.Id)
 	if err != nil || dbUser == nil {
 		// User not found, auto-register
-		if err := h.services.User.RegisterUser(ctx, user); err != nil {
+		if err := h.services.User.RegisterUser(context.Background(), user); err != nil {
 			h.logger.Error().Err(err).Int64("user_id", user.Id).Msg("Failed to auto-register user")
 		}
 		return true // New user
@@ -146,8 +271,51 @@ func (h *CommandHandler) Start(bot *gotgbot.Bot, ctx *ext.Context) error {
 		h.logger.Error().Err(err).Int64("user_id", user.Id).Msg("Failed to register user")
 	}
 
+	// A deployment without any admin offers the first user to start the bot
+	// a claim code, written to the server log
+	if offered, err := h.services.User.OfferAdminClaim(context.Background(), user.Id); err != nil {
+		h.logger.Warn().Err(err).Msg("Failed to offer admin claim")
+	} else if offered {
+		if _, err := bot.SendMessage(ctx.EffectiveChat.Id, "🔑 This bot has no admin yet. A one-time claim code was written to the server logs; send /claimadmin <code> to become admin.", nil); err != nil {
+			h.logger.Warn().Err(err).Msg("Failed to send admin claim offer")
+		}
+	}
+
+	// A paused account is offered to resume; writing to the bot does not
+	// resume it by itself
+	if dbUser, err := h.getUser(ctx, user.Id); err == nil && dbUser.DeactivatedAt != nil {
+		return h.sendReactivateOffer(bot, ctx, h.getUserLanguage(ctx, user.Id), "start_paused")
+	}
+
+	// Deep links to a preset open its preview instead of the welcome message
+	if args := ctx.Args(); len(args) > 1 && strings.HasPrefix(args[1], services.PresetStartPrefix) {
+		return h.showPresetPreview(bot, ctx, strings.TrimPrefix(args[1], services.PresetStartPrefix))
+	}
+
+	// The share link under a /today card opens the user's own card
+	if args := ctx.Args(); len(args) > 1 && args[1] == services.TodayStartParameter {
+		return h.sendToday(bot, ctx)
+	}
+
+	// Links from /search results run the command they name
+	if args := ctx.Args(); len(args) > 1 && strings.HasPrefix(args[1], commandStartPrefix) {
+		return h.runLinkedCommand(bot, ctx, strings.TrimPrefix(args[1], commandStartPrefix))
+	}
+
+	// First-time users are guided through the setup instead of the menu
+	if ctx.EffectiveChat.Type == gotgbot.ChatTypePrivate {
+		if dbUser, err := h.getUser(ctx, user.Id); err == nil && services.NeedsOnboarding(dbUser) {
+			return h.startOnboarding(bot, ctx)
+		}
+	}
+
+	return h.sendWelcome(bot, ctx)
+}
+
+// sendWelcome shows the welcome message with the main menu
+func (h *CommandHandler) sendWelcome(bot *gotgbot.Bot, ctx *ext.Context) error {
 	// Get user's language preference
-	userLang := h.getUserLanguage(context.Background(), user.Id)
+	userLang := h.getUserLanguage(ctx, ctx.EffectiveUser.Id)
 
 	// Get localized welcome message
 	welcomeText := h.services.Localization.T(context.Background(), userLang, "welcome_message")
@@ -174,7 +342,7 @@ func (h *CommandHandler) Start(bot *gotgbot.Bot, ctx *ext.Context) error {
 // Help command handler
 func (h *CommandHandler) Help(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	// Get localized help text components
 	title := h.services.Localization.T(context.Background(), userLang, "help_title")
@@ -182,6 +350,14 @@ func (h *CommandHandler) Help(bot *gotgbot.Bot, ctx *ext.Context) error {
 	weather := h.services.Localization.T(context.Background(), userLang, "help_weather")
 	forecast := h.services.Localization.T(context.Background(), userLang, "help_forecast")
 	air := h.services.Localization.T(context.Background(), userLang, "help_air")
+	today := h.services.Localization.T(context.Background(), userLang, "help_today")
+	locations := h.services.Localization.T(context.Background(), userLang, "help_locations")
+	hourly := h.services.Localization.T(context.Background(), userLang, "help_hourly")
+	compare := h.services.Localization.T(context.Background(), userLang, "help_compare")
+	history := h.services.Localization.T(context.Background(), userLang, "help_history")
+	radar := h.services.Localization.T(context.Background(), userLang, "help_radar")
+	weatherMap := h.services.Localization.T(context.Background(), userLang, "help_map")
+	search := h.services.Localization.T(context.Background(), userLang, "help_search")
 
 	locationMgmt := h.services.Localization.T(context.Background(), userLang, "help_location_management")
 	setLocation := h.services.Localization.T(context.Background(), userLang, "help_setlocation")
@@ -190,14 +366,17 @@ func (h *CommandHandler) Help(bot *gotgbot.Bot, ctx *ext.Context) error {
 	subscribe := h.services.Localization.T(context.Background(), userLang, "help_subscribe")
 	unsubscribe := h.services.Localization.T(context.Background(), userLang, "help_unsubscribe")
 	subscriptions := h.services.Localization.T(context.Background(), userLang, "help_subscriptions")
+	unpin := h.services.Localization.T(context.Background(), userLang, "help_unpin")
 
 	alerts := h.services.Localization.T(context.Background(), userLang, "help_alerts")
 	addAlert := h.services.Localization.T(context.Background(), userLang, "help_addalert")
 	viewAlerts := h.services.Localization.T(context.Background(), userLang, "help_view_alerts")
 	removeAlert := h.services.Localization.T(context.Background(), userLang, "help_removealert")
+	quietDays := h.services.Localization.T(context.Background(), userLang, "help_quietdays")
 
 	settings := h.services.Localization.T(context.Background(), userLang, "help_settings")
 	settingsDesc := h.services.Localization.T(context.Background(), userLang, "help_settings_desc")
+	transfer := h.services.Localization.T(context.Background(), userLang, "help_transfer")
 	dataExport := h.services.Localization.T(context.Background(), userLang, "help_data_export")
 
 	exportFeatures := h.services.Localization.T(context.Background(), userLang, "help_export_features")
@@ -226,24 +405,35 @@ func (h *CommandHandler) Help(bot *gotgbot.Bot, ctx *ext.Context) error {
 *🏠 %s:*
 /weather \[location] - %s
 /forecast \[location] - %s
+/hourly \[location] - %s
 /air \[location] - %s
+/compare <city1> <city2> - %s
+/history - %s
+/radar - %s
+/map - %s
+/today - %s
+/search <keyword> - %s
 
 *📍 %s:*
 /setlocation - %s
+/locations - %s
 
 *🔔 %s:*
 /subscribe - %s
 /unsubscribe - %s
 /subscriptions - %s
+/unpin - %s
 
 *⚠️ %s:*
 /addalert - %s
 /alerts - %s
 /removealert <id> - %s
+/quietdays - %s
 
 *⚙️ %s:*
 /settings - %s
 • %s
+/transfer - %s
 
 *📊 %s:*
 %s:
@@ -266,11 +456,11 @@ func (h *CommandHandler) Help(bot *gotgbot.Bot, ctx *ext.Context) error {
 
 *🆘 %s:*
 https://github.com/valpere/shopogoda/issues`,
-		title, basicCmd, weather, forecast, air,
-		locationMgmt, setLocation,
-		notifications, subscribe, unsubscribe, subscriptions,
-		alerts, addAlert, viewAlerts, removeAlert,
-		settings, settingsDesc, dataExport,
+		title, basicCmd, weather, forecast, hourly, air, compare, history, radar, weatherMap, today, search,
+		locationMgmt, setLocation, locations,
+		notifications, subscribe, unsubscribe, subscriptions, unpin,
+		alerts, addAlert, viewAlerts, removeAlert, quietDays,
+		settings, settingsDesc, transfer, dataExport,
 		exportFeatures, exportFeatures,
 		weatherData, alertHistory, notifSubs, completeExport,
 		adminCmd, stats, broadcast, users,
@@ -312,87 +502,113 @@ func (h *CommandHandler) CurrentWeather(bot *gotgbot.Bot, ctx *ext.Context) erro
 		Str("parsed_location", location).
 		Msg("Parsed location parameter")
 
-	// If no location provided, use user's saved location or ask for it
-	if location == "" {
-		locationName, _, _, err := h.services.User.GetUserLocation(context.Background(), userID)
-		if err != nil || locationName == "" {
-			userLang := h.getUserLanguage(context.Background(), userID)
-			message := h.services.Localization.T(context.Background(), userLang, "weather_location_needed")
-
-			// Show 3-button dialog (same as /setlocation)
-			setByNameBtn := h.services.Localization.T(context.Background(), userLang, "location_settings_btn_set_name")
-			setCoordsBtn := h.services.Localization.T(context.Background(), userLang, "location_settings_btn_set_coords")
-			backBtn := h.services.Localization.T(context.Background(), userLang, "button_back_to_start")
-
-			keyboard := [][]gotgbot.InlineKeyboardButton{
-				{{Text: setByNameBtn, CallbackData: "location_set_name"}},
-				{{Text: setCoordsBtn, CallbackData: "location_set_coords"}},
-				{{Text: backBtn, CallbackData: "back_to_start"}},
-			}
+	location, picked, err := h.resolveSavedLocation(bot, ctx, "weather", location)
+	if picked {
+		return err
+	}
 
-			_, err := bot.SendMessage(ctx.EffectiveChat.Id,
-				message,
-				&gotgbot.SendMessageOpts{
-					ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
-						InlineKeyboard: keyboard,
-					},
-				})
-			return err
+	// If no location provided, use the group's or the user's saved location or ask for it
+	savedLocation := location == ""
+	if savedLocation {
+		locationName, err := h.getChatLocation(ctx, userID)
+		if err != nil || locationName == "" {
+			return h.sendLocationNeeded(bot, ctx, "weather_location_needed")
 		}
 		location = locationName
 	}
 
+	if limited, err := h.weatherRateLimited(bot, ctx, userID); limited {
+		return err
+	}
+
+	return h.sendCurrentWeather(bot, ctx, location, savedLocation, false)
+}
+
+// refreshWeather answers the refresh button under a weather message: the
+// cached weather of the place is dropped and the message shows the
+// provider's answer in place
+func (h *CommandHandler) refreshWeather(bot *gotgbot.Bot, ctx *ext.Context, location string) error {
+	userID := ctx.EffectiveUser.Id
+
+	if limited, err := h.weatherRateLimited(bot, ctx, userID); limited {
+		return err
+	}
+
+	userLang := h.getChatLanguage(ctx, userID)
+	if err := h.services.Weather.InvalidateCurrentWeather(weather.WithLanguage(context.Background(), userLang), location); err != nil {
+		h.logger.Warn().Err(err).Str("location", location).Msg("Failed to drop cached weather")
+	}
+
+	savedLocation, _, _, err := h.getUserLocation(ctx, userID)
+	return h.sendCurrentWeather(bot, ctx, location, err == nil && savedLocation == location, true)
+}
+
+// sendCurrentWeather shows the current weather at location, in place of the
+// message whose button was pressed if any. edit is set when refreshing, which
+// is not counted as a new request. savedLocation is set when location is the
+// user's saved location.
+func (h *CommandHandler) sendCurrentWeather(bot *gotgbot.Bot, ctx *ext.Context, location string, savedLocation, edit bool) error {
+	userID := ctx.EffectiveUser.Id
+
 	// Get weather data
 	h.logger.Debug().
 		Str("location", location).
 		Msg("Calling weather service")
 
-	weatherData, err := h.services.Weather.GetCurrentWeatherByLocation(context.Background(), location)
+	userLang := h.getChatLanguage(ctx, userID)
+	result, err := h.services.Weather.GetWeatherResultByLocation(weather.WithLanguage(h.weatherContext(ctx), userLang), location)
 	if err != nil {
 		h.logger.Error().
 			Err(err).
 			Str("location", location).
 			Msg("Failed to get weather data")
 
-		userLang := h.getUserLanguage(context.Background(), userID)
-		errorMsg := h.services.Localization.T(context.Background(), userLang, "weather_error", location)
-
-		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
-		return err
+		return h.sendWeatherError(bot, ctx, location, err, "weather_error", location)
 	}
+	weatherData := result.Data
 
 	h.logger.Debug().
 		Str("location", location).
 		Msg("Successfully got weather data")
 
 	// Track weather request in Redis
-	if err := h.services.User.IncrementWeatherRequestCounter(context.Background()); err != nil {
-		h.logger.Warn().Err(err).Msg("Failed to increment weather request counter")
+	if !edit {
+		if err := h.services.User.IncrementWeatherRequestCounter(context.Background()); err != nil {
+			h.logger.Warn().Err(err).Msg("Failed to increment weather request counter")
+		}
 	}
-
-	// Format weather message
-	userLang := h.getUserLanguage(context.Background(), userID)
-	weatherText := h.formatWeatherMessage(weatherData, userLang)
-
-	// Get localized button texts
-	forecastBtn := h.services.Localization.T(context.Background(), userLang, "button_forecast")
-	airQualityBtn := h.services.Localization.T(context.Background(), userLang, "button_air_quality")
-	setAlertBtn := h.services.Localization.T(context.Background(), userLang, "button_set_alert")
-
-	keyboard := [][]gotgbot.InlineKeyboardButton{
-		{{Text: forecastBtn, CallbackData: fmt.Sprintf("forecast_%s", location)}},
-		{{Text: airQualityBtn, CallbackData: fmt.Sprintf("air_%s", location)}},
-		{{Text: setAlertBtn, CallbackData: fmt.Sprintf("alert_%s", location)}},
+	// Stale weather is not what the place has now, so it is not recorded
+	if !result.StaleDataWarning && weatherData.Location != nil {
+		h.recordWeather(ctx, weatherData.Location.Latitude, weatherData.Location.Longitude, weatherData)
 	}
 
-	_, err = bot.SendMessage(ctx.EffectiveChat.Id, weatherText, &gotgbot.SendMessageOpts{
+	// Format weather message; a group that set its own language and units gets those
+	units := h.getChatUnits(ctx, userID)
+	zone := h.getUserTimezone(ctx, userID)
+	showTips := h.showsRecommendations(ctx, userID)
+	weatherText := h.renderForChat(ctx, userID, func(language string) string {
+		view := h.weatherView(result, language, units, zone)
+		if showTips {
+			view.Tips = services.RecommendationTips(h.services.Localization, language, units, weatherData)
+		}
+		return h.render(render.Weather, language, view)
+	}, services.WeatherSummary(h.services.Localization, weatherData, units))
+	weatherText = h.services.Localization.AppendFooter(context.Background(), userLang, services.MessageTypeWeather, weatherText)
+
+	// The live widget follows the saved location, so only offer it for that
+	keyboard := h.weatherKeyboard(userLang, weatherKeyboardInput{
+		Location: location,
+		Weather:  weatherData,
+		Features: h.services.Features(context.Background(), userID),
+		CanPin:   savedLocation && ctx.EffectiveChat.Type == gotgbot.ChatTypePrivate,
+	})
+
+	return h.sendOrEdit(bot, ctx, weatherText, &gotgbot.SendMessageOpts{
 		ParseMode: "Markdown",
 		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
 			InlineKeyboard: keyboard,
 		},
 	})
-
-	return err
 }
 
 // Forecast command
@@ -413,10 +629,15 @@ func (h *CommandHandler) Forecast(bot *gotgbot.Bot, ctx *ext.Context) error {
 		Str("parsed_location", location).
 		Msg("FORECAST_DEBUG: Parsed location parameter")
 
+	location, picked, err := h.resolveSavedLocation(bot, ctx, "forecast", location)
+	if picked {
+		return err
+	}
+
 	if location == "" {
-		locationName, _, _, err := h.services.User.GetUserLocation(context.Background(), userID)
+		locationName, err := h.getChatLocation(ctx, userID)
 		if err != nil || locationName == "" {
-			userLang := h.getUserLanguage(context.Background(), userID)
+			userLang := h.getUserLanguage(ctx, userID)
 			message := h.services.Localization.T(context.Background(), userLang, "forecast_location_needed")
 
 			_, err := bot.SendMessage(ctx.EffectiveChat.Id, message, nil)
@@ -426,22 +647,15 @@ func (h *CommandHandler) Forecast(bot *gotgbot.Bot, ctx *ext.Context) error {
 	}
 
 	// Get coordinates first for forecast
-	locationData, err := h.services.Weather.GeocodeLocation(context.Background(), location)
+	locationData, err := h.services.Weather.GeocodeLocation(h.weatherContext(ctx), location)
 	if err != nil {
-		userLang := h.getUserLanguage(context.Background(), userID)
-		errorMsg := h.services.Localization.T(context.Background(), userLang, "location_not_found", location)
-
-		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
-		return err
+		return h.sendWeatherError(bot, ctx, location, err, "location_not_found", location)
 	}
 
-	forecast, err := h.services.Weather.GetForecast(context.Background(), locationData.Latitude, locationData.Longitude, 5)
+	userLang := h.getChatLanguage(ctx, userID)
+	forecast, err := h.services.Weather.GetForecast(weather.WithLanguage(h.weatherContext(ctx), userLang), locationData.Latitude, locationData.Longitude, 5)
 	if err != nil {
-		userLang := h.getUserLanguage(context.Background(), userID)
-		errorMsg := h.services.Localization.T(context.Background(), userLang, "forecast_error", location)
-
-		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
-		return err
+		return h.sendWeatherError(bot, ctx, location, err, "forecast_error")
 	}
 
 	// Track weather request in Redis
@@ -449,8 +663,11 @@ func (h *CommandHandler) Forecast(bot *gotgbot.Bot, ctx *ext.Context) error {
 		h.logger.Warn().Err(err).Msg("Failed to increment weather request counter")
 	}
 
-	userLang := h.getUserLanguage(context.Background(), userID)
-	forecastText := h.formatForecastMessage(forecast, userLang)
+	units := h.getChatUnits(ctx, userID)
+	zone := h.getUserTimezone(ctx, userID)
+	forecastText := h.renderForChat(ctx, userID, func(language string) string {
+		return h.render(render.Forecast, language, h.forecastView(forecast, language, units, zone))
+	}, h.forecastSummary(forecast, units))
 
 	_, err = bot.SendMessage(ctx.EffectiveChat.Id, forecastText, &gotgbot.SendMessageOpts{
 		ParseMode: "Markdown",
@@ -459,16 +676,23 @@ func (h *CommandHandler) Forecast(bot *gotgbot.Bot, ctx *ext.Context) error {
 	return err
 }
 
-// Air quality command
-func (h *CommandHandler) AirQuality(bot *gotgbot.Bot, ctx *ext.Context) error {
+// /hourly covers the next 24 hours; its 3-hour slots are shown a few per page
+// so each stays readable on a phone
+const (
+	hourlyForecastHours = 24
+	hourlySlotsPerPage  = 4
+)
+
+// Hourly command
+func (h *CommandHandler) Hourly(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
-	location := h.parseLocationFromArgs(ctx)
-	userLang := h.getUserLanguage(context.Background(), userID)
 
+	location := h.parseLocationFromArgs(ctx)
 	if location == "" {
-		locationName, _, _, err := h.services.User.GetUserLocation(context.Background(), userID)
+		locationName, _, _, err := h.getUserLocation(ctx, userID)
 		if err != nil || locationName == "" {
-			message := h.services.Localization.T(context.Background(), userLang, "air_location_needed")
+			userLang := h.getUserLanguage(ctx, userID)
+			message := h.services.Localization.T(context.Background(), userLang, "hourly_location_needed")
 
 			_, err := bot.SendMessage(ctx.EffectiveChat.Id, message, nil)
 			return err
@@ -476,37 +700,204 @@ func (h *CommandHandler) AirQuality(bot *gotgbot.Bot, ctx *ext.Context) error {
 		location = locationName
 	}
 
-	// Get coordinates first for air quality
-	locationData, err := h.services.Weather.GeocodeLocation(context.Background(), location)
+	return h.sendHourlyForecast(bot, ctx, location, 0, false)
+}
+
+// sendHourlyForecast shows one page of the hourly forecast for a location.
+// Paging edits the message the buttons belong to instead of sending a new one.
+func (h *CommandHandler) sendHourlyForecast(bot *gotgbot.Bot, ctx *ext.Context, location string, page int, edit bool) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	locationData, err := h.services.Weather.GeocodeLocation(h.weatherContext(ctx), location)
 	if err != nil {
-		errorMsg := h.services.Localization.T(context.Background(), userLang, "location_not_found", location)
+		return h.sendWeatherError(bot, ctx, location, err, "location_not_found", location)
+	}
 
-		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
+	forecast, err := h.services.Weather.GetHourlyForecast(weather.WithLanguage(h.weatherContext(ctx), userLang), locationData.Latitude, locationData.Longitude, hourlyForecastHours)
+	if err != nil {
+		return h.sendWeatherError(bot, ctx, location, err, "hourly_error")
+	}
+
+	units := h.getUserUnits(ctx, userID)
+
+	if !edit {
+		if err := h.services.User.IncrementWeatherRequestCounter(context.Background()); err != nil {
+			h.logger.Warn().Err(err).Msg("Failed to increment weather request counter")
+		}
+	}
+
+	pages := max(1, (len(forecast.Slots)+hourlySlotsPerPage-1)/hourlySlotsPerPage)
+	page = min(max(page, 0), pages-1)
+	text := h.formatHourlyForecastMessage(forecast, page, pages, userLang, units, h.hourlyZone(ctx, userID, forecast))
+	keyboard := gotgbot.InlineKeyboardMarkup{InlineKeyboard: h.hourlyKeyboard(location, page, pages, userLang)}
+
+	if edit && ctx.CallbackQuery != nil {
+		_, _, err = bot.EditMessageText(text, &gotgbot.EditMessageTextOpts{
+			ChatId:      ctx.EffectiveChat.Id,
+			MessageId:   ctx.CallbackQuery.Message.GetMessageId(),
+			ParseMode:   "Markdown",
+			ReplyMarkup: keyboard,
+		})
 		return err
 	}
 
-	airData, err := h.services.Weather.GetAirQuality(context.Background(), locationData.Latitude, locationData.Longitude)
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &keyboard,
+	})
+	return err
+}
+
+// sendLocationNeeded asks a user without a saved location to set one, with
+// the same 3-button dialog as /setlocation
+func (h *CommandHandler) sendLocationNeeded(bot *gotgbot.Bot, ctx *ext.Context, messageKey string) error {
+	userLang := h.getUserLanguage(ctx, ctx.EffectiveUser.Id)
+	message := h.services.Localization.T(context.Background(), userLang, messageKey)
+
+	setByNameBtn := h.services.Localization.T(context.Background(), userLang, "location_settings_btn_set_name")
+	setCoordsBtn := h.services.Localization.T(context.Background(), userLang, "location_settings_btn_set_coords")
+	backBtn := h.services.Localization.T(context.Background(), userLang, "button_back_to_start")
+
+	keyboard := [][]gotgbot.InlineKeyboardButton{
+		{{Text: setByNameBtn, CallbackData: "location_set_name"}},
+		{{Text: setCoordsBtn, CallbackData: "location_set_coords"}},
+		{{Text: backBtn, CallbackData: "back_to_start"}},
+	}
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id,
+		message,
+		&gotgbot.SendMessageOpts{
+			ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
+				InlineKeyboard: keyboard,
+			},
+		})
+	return err
+}
+
+// hourlyZone is the time zone hourly times are shown in: the user's own when
+// they set one, otherwise the location's
+func (h *CommandHandler) hourlyZone(ctx *ext.Context, userID int64, forecast *weather.HourlyForecastData) *time.Location {
+	if user, err := h.getUser(ctx, userID); err == nil && user != nil && user.Timezone != "" {
+		return h.services.User.ConvertToUserTime(context.Background(), userID, time.Now()).Location()
+	}
+	return time.FixedZone("", forecast.UTCOffset)
+}
+
+// formatHourlyForecastMessage renders one page of 3-hour slots with times in
+// the given zone
+func (h *CommandHandler) formatHourlyForecastMessage(forecast *weather.HourlyForecastData, page, pages int, language, units string, zone *time.Location) string {
+	title := h.services.Localization.T(context.Background(), language, "hourly_title", markdown.EscapeBold(forecast.Location))
+	text := fmt.Sprintf("%s\n\n", title)
+
+	start := page * hourlySlotsPerPage
+	end := min(start+hourlySlotsPerPage, len(forecast.Slots))
+	for _, slot := range forecast.Slots[start:end] {
+		slotTime := slot.Time.In(zone)
+		text += fmt.Sprintf("🕒 *%s %s* %s %s\n", h.services.Localization.LocalizedShortWeekday(slotTime, language), slotTime.Format("15:04"),
+			weather.ConditionEmoji(slot.Icon), slot.Description)
+		text += h.services.Localization.T(context.Background(), language, "hourly_slot",
+			weather.FormatTemp(slot.Temperature, units),
+			weather.FormatPercent(slot.PrecipitationChance*100),
+			weather.FormatSpeed(slot.WindSpeed, units)) + "\n\n"
+	}
+
+	if pages > 1 {
+		text += h.services.Localization.T(context.Background(), language, "hourly_page", page+1, pages)
+	}
+	return strings.TrimRight(text, "\n")
+}
+
+// hourlyKeyboard links the neighbouring pages of the hourly forecast
+func (h *CommandHandler) hourlyKeyboard(location string, page, pages int, language string) [][]gotgbot.InlineKeyboardButton {
+	var row []gotgbot.InlineKeyboardButton
+	if page > 0 {
+		data, _ := h.callbackWithLabel(fmt.Sprintf("hourly_%d", page-1), location)
+		row = append(row, gotgbot.InlineKeyboardButton{
+			Text:         h.services.Localization.T(context.Background(), language, "button_hourly_earlier"),
+			CallbackData: data,
+		})
+	}
+	if page < pages-1 {
+		data, _ := h.callbackWithLabel(fmt.Sprintf("hourly_%d", page+1), location)
+		row = append(row, gotgbot.InlineKeyboardButton{
+			Text:         h.services.Localization.T(context.Background(), language, "button_hourly_later"),
+			CallbackData: data,
+		})
+	}
+	if len(row) == 0 {
+		return nil
+	}
+	return [][]gotgbot.InlineKeyboardButton{row}
+}
+
+// handleHourlyCallback turns the hourly forecast to another page
+func (h *CommandHandler) handleHourlyCallback(bot *gotgbot.Bot, ctx *ext.Context, pageParam string, params []string) error {
+	page, err := strconv.Atoi(pageParam)
 	if err != nil {
-		errorMsg := h.services.Localization.T(context.Background(), userLang, "air_quality_error", location)
+		return err
+	}
+	location, err := h.callbackLabel(params)
+	if err != nil {
+		if errors.Is(err, services.ErrLabelExpired) {
+			return h.sendLabelExpired(bot, ctx)
+		}
+		return err
+	}
+	return h.sendHourlyForecast(bot, ctx, location, page, true)
+}
 
-		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
+// Air quality command
+func (h *CommandHandler) AirQuality(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getChatLanguage(ctx, userID)
+
+	location, picked, err := h.resolveSavedLocation(bot, ctx, "air", h.parseLocationFromArgs(ctx))
+	if picked {
 		return err
 	}
 
+	if location == "" {
+		locationName, err := h.getChatLocation(ctx, userID)
+		if err != nil || locationName == "" {
+			message := h.services.Localization.T(context.Background(), userLang, "air_location_needed")
+
+			_, err := bot.SendMessage(ctx.EffectiveChat.Id, message, nil)
+			return err
+		}
+		location = locationName
+	}
+
+	// Get coordinates first for air quality
+	locationData, err := h.services.Weather.GeocodeLocation(h.weatherContext(ctx), location)
+	if err != nil {
+		return h.sendWeatherError(bot, ctx, location, err, "location_not_found", location)
+	}
+
+	airData, err := h.services.Weather.GetAirQuality(h.weatherContext(ctx), locationData.Latitude, locationData.Longitude)
+	if err != nil {
+		return h.sendWeatherError(bot, ctx, location, err, "air_quality_error")
+	}
+
 	// Track weather request in Redis
 	if err := h.services.User.IncrementWeatherRequestCounter(context.Background()); err != nil {
 		h.logger.Warn().Err(err).Msg("Failed to increment weather request counter")
 	}
 
-	airText := h.formatAirQualityMessage(airData, userLang)
+	zone := h.getUserTimezone(ctx, userID)
+	airText := h.renderForChat(ctx, userID, func(language string) string {
+		return h.render(render.AirQuality, language, h.airQualityView(airData, language, zone))
+	}, h.airQualitySummary(airData))
 
 	// Get localized button texts
 	weatherBtn := h.services.Localization.T(context.Background(), userLang, "button_current_weather")
+	aqiForecastBtn := h.services.Localization.T(context.Background(), userLang, "button_aqi_forecast")
 	alertBtn := h.services.Localization.T(context.Background(), userLang, "button_set_air_alert")
 
 	keyboard := [][]gotgbot.InlineKeyboardButton{
-		{{Text: weatherBtn, CallbackData: fmt.Sprintf("weather_%s", location)}},
-		{{Text: alertBtn, CallbackData: fmt.Sprintf("air_alert_%s", location)}},
+		{{Text: weatherBtn, CallbackData: h.locationCallback("weather", location)}},
+		{{Text: aqiForecastBtn, CallbackData: h.locationCallback("aqiforecast", location)}},
+		{{Text: alertBtn, CallbackData: h.locationCallback("air_alert", location)}},
 	}
 
 	_, err = bot.SendMessage(ctx.EffectiveChat.Id, airText, &gotgbot.SendMessageOpts{
@@ -523,20 +914,20 @@ func (h *CommandHandler) AirQuality(bot *gotgbot.Bot, ctx *ext.Context) error {
 func (h *CommandHandler) Settings(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
 
-	user, err := h.services.User.GetUser(context.Background(), userID)
+	user, err := h.getUser(ctx, userID)
 	if err != nil {
 		return err
 	}
 
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	// Get user's current location
-	locationName, _, _, err := h.services.User.GetUserLocation(context.Background(), userID)
+	locationName, _, _, err := h.getUserLocation(ctx, userID)
 	var locationText string
 	if err != nil || locationName == "" {
 		locationText = h.services.Localization.T(context.Background(), userLang, "settings_not_set")
 	} else {
-		locationText = locationName
+		locationText = markdown.Escape(h.formatLocationText(context.Background(), userLang, user))
 	}
 
 	// Get localized strings
@@ -593,21 +984,39 @@ func (h *CommandHandler) Settings(bot *gotgbot.Bot, ctx *ext.Context) error {
 	// Get localized button texts
 	setLocationBtn := h.services.Localization.T(context.Background(), userLang, "button_set_location")
 	languageBtn := h.services.Localization.T(context.Background(), userLang, "button_language")
+	secondLanguageBtn := h.services.Localization.T(context.Background(), userLang, "button_secondary_language")
 	unitsBtn := h.services.Localization.T(context.Background(), userLang, "button_units")
+	weekStartBtn := h.services.Localization.T(context.Background(), userLang, "button_week_start")
+	skinTypeBtn := h.services.Localization.T(context.Background(), userLang, "button_skin_type", skinTypeNumerals[skinTypeOf(user)])
 	timezoneBtn := h.services.Localization.T(context.Background(), userLang, "button_timezone")
+	// The button shows the current state and flips it
+	tipsBtn := h.services.Localization.T(context.Background(), userLang, "button_recommendations_off")
+	tipsData := "settings_tips_on"
+	if user.ShowRecommendations {
+		tipsBtn = h.services.Localization.T(context.Background(), userLang, "button_recommendations_on")
+		tipsData = "settings_tips_off"
+	}
 	notificationsBtn := h.services.Localization.T(context.Background(), userLang, "button_notifications")
 	exportBtn := h.services.Localization.T(context.Background(), userLang, "button_data_export")
+	apiKeyBtn := h.services.Localization.T(context.Background(), userLang, "button_api_key")
 	backBtn := h.services.Localization.T(context.Background(), userLang, "button_back_to_start")
 
 	keyboard := [][]gotgbot.InlineKeyboardButton{
 		{{Text: setLocationBtn, CallbackData: "settings_location"}},
 		{{Text: languageBtn, CallbackData: "settings_language"}},
+		{{Text: secondLanguageBtn, CallbackData: "settings_secondlang"}},
 		{{Text: unitsBtn, CallbackData: "settings_units"}},
+		{{Text: weekStartBtn, CallbackData: "settings_weekstart"}},
+		{{Text: skinTypeBtn, CallbackData: "settings_skintype"}},
 		{{Text: timezoneBtn, CallbackData: "settings_timezone"}},
+		{{Text: tipsBtn, CallbackData: tipsData}},
 		{{Text: notificationsBtn, CallbackData: "settings_notifications"}},
 		{{Text: exportBtn, CallbackData: "settings_export"}},
-		{{Text: backBtn, CallbackData: "settings_start"}},
 	}
+	if h.services.User.APIKeysEnabled() {
+		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{{Text: apiKeyBtn, CallbackData: "settings_apikey"}})
+	}
+	keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{{Text: backBtn, CallbackData: "settings_start"}})
 
 	_, err = bot.SendMessage(ctx.EffectiveChat.Id, settingsText, &gotgbot.SendMessageOpts{
 		ParseMode: "Markdown",
@@ -647,6 +1056,16 @@ func (h *CommandHandler) HandleCallback(bot *gotgbot.Bot, ctx *ext.Context) erro
 		return h.handleWeatherCallback(bot, ctx, subAction, parts[2:])
 	case "forecast":
 		return h.handleForecastCallback(bot, ctx, subAction, parts[2:])
+	case "hourly":
+		return h.handleHourlyCallback(bot, ctx, subAction, parts[2:])
+	case "astro":
+		return h.handleAstroCallback(bot, ctx, subAction, parts[2:])
+	case "radar":
+		return h.sendRadar(bot, ctx, true)
+	case "map":
+		return h.handleMapCallback(bot, ctx, subAction, parts[2:])
+	case "locations":
+		return h.handleLocationsCallback(bot, ctx, subAction, parts[2:])
 	case "settings":
 		return h.handleSettingsCallback(bot, ctx, subAction, parts[2:])
 	case "location":
@@ -655,6 +1074,10 @@ func (h *CommandHandler) HandleCallback(bot *gotgbot.Bot, ctx *ext.Context) erro
 		return h.handleTimezoneCallback(bot, ctx, subAction, parts[2:])
 	case "language":
 		return h.handleLanguageCallback(bot, ctx, subAction, parts[2:])
+	case "widget":
+		return h.handleWidgetCallback(bot, ctx, subAction)
+	case "preset":
+		return h.handlePresetCallback(bot, ctx, subAction, parts[2:])
 	case "alert":
 		return h.handleAlertCallback(bot, ctx, subAction, parts[2:])
 	case "alerts":
@@ -662,21 +1085,55 @@ func (h *CommandHandler) HandleCallback(bot *gotgbot.Bot, ctx *ext.Context) erro
 	case "subscribe", "unsubscribe":
 		return h.handleSubscriptionCallback(bot, ctx, action, subAction, parts[2:])
 	case "sub":
-		return h.handleSubscriptionCallback(bot, ctx, subAction, parts[1], parts[2:])
+		return h.handleSubscriptionEditCallback(bot, ctx, subAction, parts[2:])
 	case "subscriptions":
 		return h.handleSubscriptionCallback(bot, ctx, action, subAction, parts[2:])
 	case "admin":
 		return h.handleAdminCallback(bot, ctx, subAction, parts[2:])
 	case "air":
 		return h.handleAirCallback(bot, ctx, subAction, parts[2:])
+	case "aqiforecast":
+		return h.handleAQIForecastCallback(bot, ctx, subAction, parts[2:])
 	case "notifications":
 		return h.handleNotificationCallback(bot, ctx, subAction, parts[2:])
 	case "export":
 		return h.handleExportCallback(bot, ctx, subAction, parts[2:])
+	case "digest":
+		return h.handleDigestCallback(bot, ctx, subAction, parts[2:])
 	case "back":
 		return h.handleBackCallback(bot, ctx, subAction, parts[2:])
 	case "role":
 		return h.handleRoleCallback(bot, ctx, subAction, parts[2:])
+	case "userinfo":
+		return h.handleUserInfoCallback(bot, ctx, subAction, parts[2:])
+	case "feedback":
+		return h.handleFeedbackCallback(bot, ctx, subAction, parts[2:])
+	case "auditlog":
+		return h.handleAuditLogCallback(bot, ctx, subAction, parts[2:])
+	case "transfer":
+		return h.handleTransferCallback(bot, ctx, subAction, parts[2:])
+	case "privacy":
+		return h.handlePrivacyCallback(bot, ctx, subAction)
+	case "deletemydata":
+		return h.handleDeleteMyDataCallback(bot, ctx, subAction)
+	case "setup":
+		return h.handleSetupCallback(bot, ctx, subAction)
+	case "onboarding":
+		return h.handleOnboardingCallback(bot, ctx, subAction, parts[2:])
+	case "account":
+		return h.handleAccountCallback(bot, ctx, subAction)
+	case "nexthour", "uv", "sensitive":
+		return h.handleWeatherSuggestionCallback(bot, ctx, action, parts[1:])
+	case "share":
+		return h.sendShareCard(bot, ctx, strings.Join(parts[1:], "_"))
+	case "refresh":
+		return h.refreshWeather(bot, ctx, strings.Join(parts[1:], "_"))
+	case "live":
+		return h.handleLiveCallback(bot, ctx, subAction, parts[2:])
+	case "sun":
+		return h.handleSunCallback(bot, ctx, subAction)
+	case "version":
+		return h.handleVersionCallback(bot, ctx, subAction)
 	}
 
 	return nil
@@ -701,30 +1158,73 @@ func (h *CommandHandler) HandleLocationMessage(bot *gotgbot.Bot, ctx *ext.Contex
 	lon := ctx.Message.Location.Longitude
 	h.logger.Info().Float64("lat", lat).Float64("lon", lon).Msg("Processing location message")
 
+	// The location asked for by the onboarding is saved right away
+	if handled, err := h.handleOnboardingLocation(bot, ctx, lat, lon); handled {
+		return err
+	}
+
+	if err := h.sendCoordinateWeather(bot, ctx, lat, lon, "", true); err != nil {
+		return err
+	}
+	if ctx.Message.Location.LivePeriod > 0 {
+		return h.offerLiveFollow(bot, ctx)
+	}
+	return nil
+}
+
+// sendCoordinateWeather shows the weather for a point with buttons to save it,
+// see the forecast or add an alert. A non-empty label adds a second save button
+// that keeps the user's own name for the place.
+func (h *CommandHandler) sendCoordinateWeather(bot *gotgbot.Bot, ctx *ext.Context, lat, lon float64, label string, offerNearby bool) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
 	// Get location name from coordinates
-	locationName, err := h.services.Weather.GetLocationName(context.Background(), lat, lon)
+	locationName, err := h.services.Weather.GetLocationName(h.weatherContext(ctx), lat, lon)
 	if err != nil {
 		locationName = fmt.Sprintf("Location (%.4f, %.4f)", lat, lon)
 	}
 
 	// Get weather for this location
-	userLang := h.getUserLanguage(context.Background(), user.Id)
-	weatherData, err := h.services.Weather.GetCurrentWeatherByCoords(context.Background(), lat, lon)
+	weatherData, err := h.services.Weather.GetCurrentWeatherByCoords(h.weatherContext(ctx), lat, lon)
 	if err != nil {
 		errorMsg := h.services.Localization.T(context.Background(), userLang, "error_weather_location_failed")
 		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
 		return err
 	}
+	h.recordWeather(ctx, lat, lon, weatherData)
 
-	weatherText := h.formatWeatherMessage(weatherData, userLang)
+	units := h.getUserUnits(ctx, userID)
+	zone := h.getUserTimezone(ctx, userID)
+	showTips := h.showsRecommendations(ctx, userID)
+	weatherText := h.renderForUser(ctx, userID, func(language string) string {
+		view := h.weatherView(&services.WeatherResult{Data: weatherData}, language, units, zone)
+		if showTips {
+			view.Tips = services.RecommendationTips(h.services.Localization, language, units, weatherData)
+		}
+		return h.render(render.Weather, language, view)
+	}, services.WeatherSummary(h.services.Localization, weatherData, units))
 
-	// URL encode the location name to handle spaces and special characters
-	encodedName := url.QueryEscape(locationName)
+	savePrefix := fmt.Sprintf("location_save_%.4f_%.4f", lat, lon)
+	// Without the name the save handler looks it up again from the coordinates
+	saveData, _ := h.callbackWithLabel(savePrefix, locationName)
 
 	keyboard := [][]gotgbot.InlineKeyboardButton{
-		{{Text: "💾 Save Location", CallbackData: fmt.Sprintf("location_save_%.4f_%.4f_%s", lat, lon, encodedName)}},
-		{{Text: "📊 Forecast", CallbackData: fmt.Sprintf("forecast_coords_%.4f_%.4f", lat, lon)}},
-		{{Text: "🔔 Set Alert", CallbackData: fmt.Sprintf("alert_coords_%.4f_%.4f", lat, lon)}},
+		{{Text: "💾 Save Location", CallbackData: saveData}},
+	}
+	if label != "" {
+		if data, ok := h.callbackWithLabel(savePrefix, label); ok {
+			saveAsText := h.services.Localization.T(context.Background(), userLang, "location_save_as_btn", label)
+			keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{{Text: saveAsText, CallbackData: data}})
+		}
+	}
+	keyboard = append(keyboard,
+		[]gotgbot.InlineKeyboardButton{{Text: "📊 Forecast", CallbackData: fmt.Sprintf("forecast_coords_%.4f_%.4f", lat, lon)}},
+		[]gotgbot.InlineKeyboardButton{{Text: "🔔 Set Alert", CallbackData: fmt.Sprintf("alert_coords_%.4f_%.4f", lat, lon)}},
+	)
+	if offerNearby {
+		nearbyText := h.services.Localization.T(context.Background(), userLang, "location_nearby_btn")
+		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{{Text: nearbyText, CallbackData: fmt.Sprintf("location_nearby_%.4f_%.4f", lat, lon)}})
 	}
 
 	_, err = bot.SendMessage(ctx.EffectiveChat.Id, weatherText, &gotgbot.SendMessageOpts{
@@ -737,55 +1237,291 @@ func (h *CommandHandler) HandleLocationMessage(bot *gotgbot.Bot, ctx *ext.Contex
 	return err
 }
 
-// HandleAnyMessage logs incoming messages for debugging (debug level only)
-func (h *CommandHandler) HandleAnyMessage(bot *gotgbot.Bot, ctx *ext.Context) error {
-	msg := ctx.Message
-	if msg == nil {
-		h.logger.Debug().Msg("Non-message update received")
-		return nil
-	}
+// labelTokenMarker starts a callback parameter that refers to a stored label.
+// URL encoding escapes "@", so an inline label never starts with it.
+const labelTokenMarker = "@"
 
-	// Only log in debug mode to avoid privacy concerns and log spam
-	h.logger.Debug().
-		Int64("user_id", ctx.EffectiveUser.Id).
-		Int64("chat_id", ctx.EffectiveChat.Id).
-		Str("chat_type", ctx.EffectiveChat.Type).
-		Int64("message_id", msg.MessageId).
-		Bool("has_location", msg.Location != nil).
-		Bool("has_photo", len(msg.Photo) > 0).
-		Bool("has_document", msg.Document != nil).
-		Bool("has_voice", msg.Voice != nil).
-		Msg("Received message")
+// maxCallbackData is Telegram's limit on callback data, in bytes
+const maxCallbackData = 64
 
-	if msg.Location != nil {
-		h.logger.Debug().
-			Float64("latitude", msg.Location.Latitude).
-			Float64("longitude", msg.Location.Longitude).
-			Msg("Location shared")
+// callbackWithLabel appends a label as the last parameter of callback data. A
+// label that does not fit in full is stored server-side and referenced by a
+// token, never shortened. Without storage the prefix alone is returned with false.
+func (h *CommandHandler) callbackWithLabel(prefix, label string) (string, bool) {
+	if data := prefix + "_" + url.QueryEscape(label); len(data) <= maxCallbackData {
+		return data, true
 	}
 
-	return nil // Don't consume the message, let other handlers process it
+	token, err := h.services.Labels.Store(context.Background(), label)
+	if err != nil {
+		h.logger.Warn().Err(err).Msg("Failed to store callback label")
+		return prefix, false
+	}
+	return prefix + "_" + labelTokenMarker + token, true
 }
 
-// HandleTextMessage processes plain text messages that might be location names
-func (h *CommandHandler) HandleTextMessage(bot *gotgbot.Bot, ctx *ext.Context) error {
-	msg := ctx.Message
-	if msg == nil || msg.Text == "" {
-		return nil
+// locationCallback appends a place name to callback data as a stored token.
+// Names come from users and geocoding, so they may contain underscores, which
+// split callback data, or run past its 64 bytes. Without storage the name
+// travels inline when it fits. callbackLabel recovers it either way.
+func (h *CommandHandler) locationCallback(prefix, name string) string {
+	token, err := h.services.Labels.Token(context.Background(), name)
+	if err != nil {
+		h.logger.Warn().Err(err).Msg("Failed to store location for callback")
+		if data := prefix + "_" + url.QueryEscape(name); len(data) <= maxCallbackData {
+			return data
+		}
+		return prefix
 	}
+	return prefix + "_" + labelTokenMarker + token
+}
 
-	// Skip if it's a command (starts with /)
-	if strings.HasPrefix(msg.Text, "/") {
-		return nil
+// callbackLabel recovers the label added by callbackWithLabel from the trailing
+// callback parameters. ErrLabelExpired is returned when its token has expired.
+func (h *CommandHandler) callbackLabel(params []string) (string, error) {
+	encoded := strings.Join(params, "_")
+	if token, ok := strings.CutPrefix(encoded, labelTokenMarker); ok {
+		return h.services.Labels.Resolve(context.Background(), token)
 	}
 
-	text := strings.TrimSpace(msg.Text)
-
-	// Check if this looks like GPS coordinates first
-	coordPattern := `^(-?\d+\.?\d*),?\s*(-?\d+\.?\d*)$`
-	coordMatch, _ := regexp.MatchString(coordPattern, text)
-	if coordMatch {
-		return h.handleCoordinateInput(bot, ctx, text)
+	label, err := url.QueryUnescape(encoded)
+	if err != nil {
+		// Fall back to the raw parameter rather than losing the label
+		return encoded, nil
+	}
+	return label, nil
+}
+
+// sendLabelExpired tells the user that a button's stored label is gone
+func (h *CommandHandler) sendLabelExpired(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userLang := h.getUserLanguage(ctx, ctx.EffectiveUser.Id)
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "callback_label_expired"), nil)
+	return err
+}
+
+// sendPlaceSuggestions offers up to three places near a name the geocoder could
+// not resolve. Without any candidates the plain not-found message is sent.
+func (h *CommandHandler) sendPlaceSuggestions(bot *gotgbot.Bot, ctx *ext.Context, locationName, notFoundMsg string) error {
+	userLang := h.getUserLanguage(ctx, ctx.EffectiveUser.Id)
+
+	candidates, err := h.services.Weather.SuggestPlaces(h.weatherContext(ctx), locationName)
+	if err != nil || len(candidates) == 0 {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, notFoundMsg, nil)
+		return err
+	}
+
+	header := h.services.Localization.T(context.Background(), userLang, "location_suggestions_header", locationName)
+	text, keyboard := h.formatPlaceCandidates(userLang, header, candidates, locationName)
+
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	return err
+}
+
+// sendWeatherError tells the user why weather for a location could not be
+// fetched; fallbackKey is the command's own message for failures of no known
+// class. A place that was not found also gets the nearby suggestions.
+func (h *CommandHandler) sendWeatherError(bot *gotgbot.Bot, ctx *ext.Context, location string, err error, fallbackKey string, fallbackArgs ...any) error {
+	userLang := h.getUserLanguage(ctx, ctx.EffectiveUser.Id)
+	fallback := h.services.Localization.T(context.Background(), userLang, fallbackKey, fallbackArgs...)
+	text := services.WeatherErrorMessage(context.Background(), h.services.Localization, userLang, location, err, fallback)
+
+	if location != "" && errors.Is(err, weather.ErrNotFound) {
+		return h.sendPlaceSuggestions(bot, ctx, location, text)
+	}
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, text, nil)
+	return err
+}
+
+// sendOrEdit shows text in the message whose button was pressed, so switching
+// between current weather, forecast and air quality does not clutter the
+// chat. Commands, and buttons under photos or messages too old to edit, get a
+// new message. An edit that changes nothing is not an error.
+func (h *CommandHandler) sendOrEdit(bot *gotgbot.Bot, ctx *ext.Context, text string, opts *gotgbot.SendMessageOpts) error {
+	if message := editableCallbackMessage(ctx); message != nil {
+		editOpts := &gotgbot.EditMessageTextOpts{
+			ChatId:    message.Chat.Id,
+			MessageId: message.MessageId,
+		}
+		if opts != nil {
+			editOpts.ParseMode = opts.ParseMode
+			editOpts.LinkPreviewOptions = opts.LinkPreviewOptions
+			if markup, ok := opts.ReplyMarkup.(*gotgbot.InlineKeyboardMarkup); ok && markup != nil {
+				editOpts.ReplyMarkup = *markup
+			}
+		}
+		_, _, err := bot.EditMessageText(text, editOpts)
+		if services.MessageNotModified(err) {
+			return nil
+		}
+		return err
+	}
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, opts)
+	return err
+}
+
+// editableCallbackMessage returns the text message of the pressed button, or
+// nil when there is none: not a callback, an inline message, a message too old
+// for the bot to access, or one with a photo
+func editableCallbackMessage(ctx *ext.Context) *gotgbot.Message {
+	if ctx.Update == nil || ctx.CallbackQuery == nil {
+		return nil
+	}
+	var message *gotgbot.Message
+	switch m := ctx.CallbackQuery.Message.(type) {
+	case gotgbot.Message:
+		message = &m
+	case *gotgbot.Message:
+		message = m
+	}
+	if message == nil || message.Text == "" {
+		return nil
+	}
+	return message
+}
+
+// sendNearbyPlaces lists the named places closest to a shared GPS position
+func (h *CommandHandler) sendNearbyPlaces(bot *gotgbot.Bot, ctx *ext.Context, lat, lon float64) error {
+	userLang := h.getUserLanguage(ctx, ctx.EffectiveUser.Id)
+
+	candidates, err := h.services.Weather.NearbyPlaces(h.weatherContext(ctx), lat, lon)
+	if err != nil || len(candidates) == 0 {
+		if err != nil {
+			h.logger.Warn().Err(err).Float64("lat", lat).Float64("lon", lon).Msg("Failed to find nearby places")
+		}
+		errorMsg := h.services.Localization.T(context.Background(), userLang, "location_nearby_none")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
+		return err
+	}
+
+	header := h.services.Localization.T(context.Background(), userLang, "location_nearby_header")
+	text, keyboard := h.formatPlaceCandidates(userLang, header, candidates, "")
+
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	return err
+}
+
+// formatPlaceCandidates renders candidate places as a numbered list with one
+// pick button each. The label travels with the pick so the place can be saved
+// under the name the user originally typed.
+func (h *CommandHandler) formatPlaceCandidates(userLang, header string, candidates []services.PlaceCandidate, label string) (string, [][]gotgbot.InlineKeyboardButton) {
+	var sb strings.Builder
+	sb.WriteString(header)
+	sb.WriteString("\n")
+
+	keyboard := make([][]gotgbot.InlineKeyboardButton, 0, len(candidates))
+	for i, candidate := range candidates {
+		sb.WriteString(fmt.Sprintf("\n%d. %s", i+1, h.formatPlaceCandidate(userLang, candidate)))
+
+		data := fmt.Sprintf("location_pick_%.4f_%.4f", candidate.Location.Latitude, candidate.Location.Longitude)
+		if label != "" {
+			data, _ = h.callbackWithLabel(data, label)
+		}
+		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
+			{Text: fmt.Sprintf("%d. %s", i+1, candidate.Location.Name), CallbackData: data},
+		})
+	}
+
+	return sb.String(), keyboard
+}
+
+// formatPlaceCandidate describes one place with its region and, unless it is the
+// reference place, its distance and direction
+func (h *CommandHandler) formatPlaceCandidate(userLang string, candidate services.PlaceCandidate) string {
+	name := candidate.Location.Name
+	if candidate.Region != "" && candidate.Region != name {
+		name = fmt.Sprintf("%s, %s", name, candidate.Region)
+	}
+
+	if candidate.Direction == "" {
+		return name
+	}
+
+	direction := h.services.Localization.T(context.Background(), userLang, "compass_"+strings.ToLower(candidate.Direction))
+	if candidate.RelativeTo == "" {
+		return h.services.Localization.T(context.Background(), userLang, "place_candidate_from_you", name, candidate.DistanceKm, direction)
+	}
+	return h.services.Localization.T(context.Background(), userLang, "place_candidate_relative", name, candidate.DistanceKm, direction, candidate.RelativeTo)
+}
+
+// HandleAnyMessage logs incoming messages for debugging (debug level only)
+func (h *CommandHandler) HandleAnyMessage(bot *gotgbot.Bot, ctx *ext.Context) error {
+	msg := ctx.Message
+	if msg == nil {
+		h.logger.Debug().Msg("Non-message update received")
+		return nil
+	}
+
+	// Only log in debug mode to avoid privacy concerns and log spam
+	h.logger.Debug().
+		Int64("user_id", ctx.EffectiveUser.Id).
+		Int64("chat_id", ctx.EffectiveChat.Id).
+		Str("chat_type", ctx.EffectiveChat.Type).
+		Int64("message_id", msg.MessageId).
+		Bool("has_location", msg.Location != nil).
+		Bool("has_photo", len(msg.Photo) > 0).
+		Bool("has_document", msg.Document != nil).
+		Bool("has_voice", msg.Voice != nil).
+		Msg("Received message")
+
+	if msg.Location != nil {
+		h.logger.Debug().
+			Float64("latitude", msg.Location.Latitude).
+			Float64("longitude", msg.Location.Longitude).
+			Msg("Location shared")
+	}
+
+	return nil // Don't consume the message, let other handlers process it
+}
+
+// HandleTextMessage processes plain text messages that might be location names
+func (h *CommandHandler) HandleTextMessage(bot *gotgbot.Bot, ctx *ext.Context) error {
+	msg := ctx.Message
+	if msg == nil || msg.Text == "" {
+		return nil
+	}
+
+	// Skip if it's a command (starts with /)
+	if strings.HasPrefix(msg.Text, "/") {
+		return nil
+	}
+
+	text := strings.TrimSpace(msg.Text)
+
+	// The DELETE typed to confirm /deletemydata, which is never anything else
+	if handled, err := h.handleDeleteMyDataInput(bot, ctx, text); handled {
+		return err
+	}
+	// A reply to the threshold prompt of a custom alert, which would
+	// otherwise be taken for coordinates or a place
+	if handled, err := h.handleCustomThresholdInput(bot, ctx, text); handled {
+		return err
+	}
+	// Likewise a reply with the dates of a custom export range
+	if handled, err := h.handleExportRangeInput(bot, ctx, text); handled {
+		return err
+	}
+	// and the message asked for by /feedback, or an admin's reply to it
+	if handled, err := h.handleFeedbackInput(bot, ctx, text); handled {
+		return err
+	}
+	if handled, err := h.handleFeedbackReplyInput(bot, ctx, text); handled {
+		return err
+	}
+	// and the place typed at the location step of the onboarding
+	if handled, err := h.handleOnboardingInput(bot, ctx, text); handled {
+		return err
+	}
+
+	// Check if this looks like GPS coordinates first
+	coordPattern := `^(-?\d+\.?\d*),?\s*(-?\d+\.?\d*)$`
+	coordMatch, _ := regexp.MatchString(coordPattern, text)
+	if coordMatch {
+		return h.handleCoordinateInput(bot, ctx, text)
 	}
 
 	// Check if this looks like a timezone first - use very specific patterns to avoid conflicts
@@ -844,10 +1580,10 @@ func (h *CommandHandler) parseLocationFromArgs(ctx *ext.Context) string {
 // showLocationConfirmation displays a confirmation dialog for setting/changing location
 func (h *CommandHandler) showLocationConfirmation(bot *gotgbot.Bot, ctx *ext.Context, locationName string) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	// Check if user already has a location set
-	existingLocation, _, _, err := h.services.User.GetUserLocation(context.Background(), userID)
+	existingLocation, _, _, err := h.getUserLocation(ctx, userID)
 
 	var messageText string
 	var keyboard [][]gotgbot.InlineKeyboardButton
@@ -856,24 +1592,30 @@ func (h *CommandHandler) showLocationConfirmation(bot *gotgbot.Bot, ctx *ext.Con
 
 	if err != nil || existingLocation == "" {
 		// No location set - offer to set this as their location
-		messageText = h.services.Localization.T(context.Background(), userLang, "location_confirm_set", locationName)
+		messageText = h.services.Localization.T(context.Background(), userLang, "location_confirm_set", markdown.EscapeBold(locationName))
 		yesText := h.services.Localization.T(context.Background(), userLang, "location_confirm_yes_set")
 		noText := h.services.Localization.T(context.Background(), userLang, "location_confirm_no_ignore")
 		keyboard = [][]gotgbot.InlineKeyboardButton{
-			{{Text: yesText, CallbackData: fmt.Sprintf("location_confirm_%s", url.QueryEscape(locationName))}},
+			{{Text: yesText, CallbackData: h.locationCallback("location_confirm", locationName)}},
 			{{Text: noText, CallbackData: "location_ignore"}},
 			{{Text: backText, CallbackData: "settings_main"}},
 		}
 	} else {
 		// Location already set - offer to change it
-		messageText = h.services.Localization.T(context.Background(), userLang, "location_confirm_change", existingLocation, locationName)
+		messageText = h.services.Localization.T(context.Background(), userLang, "location_confirm_change", markdown.EscapeBold(existingLocation), markdown.EscapeBold(locationName))
 		yesText := h.services.Localization.T(context.Background(), userLang, "location_confirm_yes_change")
 		noText := h.services.Localization.T(context.Background(), userLang, "location_confirm_no_keep")
 		keyboard = [][]gotgbot.InlineKeyboardButton{
-			{{Text: yesText, CallbackData: fmt.Sprintf("location_confirm_%s", url.QueryEscape(locationName))}},
-			{{Text: noText, CallbackData: "location_ignore"}},
-			{{Text: backText, CallbackData: "settings_main"}},
+			{{Text: yesText, CallbackData: h.locationCallback("location_confirm", locationName)}},
 		}
+		if addData, ok := h.callbackWithLabel("locations_add", locationName); ok {
+			addText := h.services.Localization.T(context.Background(), userLang, "locations_add_another_btn")
+			keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{{Text: addText, CallbackData: addData}})
+		}
+		keyboard = append(keyboard,
+			[]gotgbot.InlineKeyboardButton{{Text: noText, CallbackData: "location_ignore"}},
+			[]gotgbot.InlineKeyboardButton{{Text: backText, CallbackData: "settings_main"}},
+		)
 	}
 
 	_, err = bot.SendMessage(ctx.EffectiveChat.Id, messageText, &gotgbot.SendMessageOpts{
@@ -889,7 +1631,7 @@ func (h *CommandHandler) showLocationConfirmation(bot *gotgbot.Bot, ctx *ext.Con
 // handleCoordinateInput processes GPS coordinates entered as text
 func (h *CommandHandler) handleCoordinateInput(bot *gotgbot.Bot, ctx *ext.Context, coordinateText string) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	// Parse coordinates from text
 	coordPattern := `^(-?\d+\.?\d*),?\s*(-?\d+\.?\d*)$`
@@ -946,7 +1688,7 @@ func (h *CommandHandler) handleCoordinateInput(bot *gotgbot.Bot, ctx *ext.Contex
 // handleTimezoneInput processes timezone input entered as text
 func (h *CommandHandler) handleTimezoneInput(bot *gotgbot.Bot, ctx *ext.Context, timezoneText string) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	h.logger.Info().Str("timezone", timezoneText).Int64("user_id", userID).Msg("Processing timezone input")
 
@@ -973,10 +1715,10 @@ func (h *CommandHandler) isValidTimezone(timezone string) bool {
 // showTimezoneConfirmation displays a confirmation dialog for setting/changing timezone
 func (h *CommandHandler) showTimezoneConfirmation(bot *gotgbot.Bot, ctx *ext.Context, timezoneName string) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	// Check if user already has a timezone set (get current user settings)
-	user, err := h.services.User.GetUser(context.Background(), userID)
+	user, err := h.getUser(ctx, userID)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get user for timezone confirmation")
 		// Continue with default behavior
@@ -1017,135 +1759,146 @@ func (h *CommandHandler) showTimezoneConfirmation(bot *gotgbot.Bot, ctx *ext.Con
 	return err
 }
 
-// Helper methods for formatting messages
-func (h *CommandHandler) formatWeatherMessage(weather *services.WeatherData, userLang string) string {
-	// Get localized strings
-	temperature := h.services.Localization.T(context.Background(), userLang, "weather_temperature")
-	feelsLike := h.services.Localization.T(context.Background(), userLang, "weather_feels_like")
-	humidity := h.services.Localization.T(context.Background(), userLang, "weather_humidity")
-	wind := h.services.Localization.T(context.Background(), userLang, "weather_wind")
-	visibility := h.services.Localization.T(context.Background(), userLang, "weather_visibility")
-	uvIndex := h.services.Localization.T(context.Background(), userLang, "weather_uv_index")
-	pressure := h.services.Localization.T(context.Background(), userLang, "weather_pressure")
-	airQuality := h.services.Localization.T(context.Background(), userLang, "weather_air_quality")
-	aqi := h.services.Localization.T(context.Background(), userLang, "weather_aqi")
-	updated := h.services.Localization.T(context.Background(), userLang, "weather_updated")
-
-	// Get localized location name if available
-	locationName := weather.LocationName
-	if weather.Location != nil {
-		locationName = h.services.Weather.GetLocalizedLocationName(weather.Location, userLang)
+// render executes a message template, logging the error and returning
+// nothing when the template cannot be rendered
+func (h *CommandHandler) render(name, language string, view any) string {
+	text, err := h.renderer.Render(name, language, view)
+	if err != nil {
+		h.logger.Error().Err(err).Str("template", name).Str("language", language).Msg("Failed to render message")
 	}
+	return text
+}
 
-	return fmt.Sprintf(`🌤️ *%s*
-
-%s: %d°C (%s %d°C)
-%s: %d%%
-%s: %.1f km/h %d°
-%s: %.1f km
-%s: %.1f
-%s: %.1f hPa
+// weatherView formats current conditions for weather.tmpl. Sunrise and
+// sunset are shown in zone, the user's timezone.
+func (h *CommandHandler) weatherView(result *services.WeatherResult, userLang, units string, zone *time.Location) render.WeatherView {
+	current := result.Data
 
-%s %s
+	// Get localized location name if available
+	locationName := current.LocationName
+	if current.Location != nil {
+		locationName = h.services.Weather.GetLocalizedLocationName(current.Location, userLang)
+	}
+
+	view := render.WeatherView{
+		Stale:          result.StaleDataWarning,
+		Location:       locationName,
+		Temperature:    weather.FormatTemp(current.Temperature, units),
+		FeelsLike:      weather.FormatTemp(current.FeelsLike, units),
+		Humidity:       weather.FormatPercent(float64(current.Humidity)),
+		Wind:           weather.FormatSpeed(current.WindSpeed, units),
+		WindDirection:  current.WindDirection,
+		Visibility:     weather.FormatVisibility(current.Visibility, units),
+		UVIndex:        weather.FormatUV(current.UVIndex),
+		Pressure:       weather.FormatPressure(current.Pressure, units),
+		SunTimes:       formatSunTimes(current.Sunrise, current.Sunset, zone),
+		MoonEmoji:      weather.MoonPhaseEmoji(current.MoonPhase),
+		MoonPhase:      h.services.Localization.T(context.Background(), userLang, services.MoonPhaseKey(current.MoonPhase)),
+		Icon:           current.Icon,
+		Description:    current.Description,
+		AQI:            weather.FormatAQI(float64(current.AQI)),
+		AQIDescription: h.getAQIDescription(current.AQI, userLang),
+		CO:             current.CO,
+		NO2:            current.NO2,
+		O3:             current.O3,
+		PM25:           current.PM25,
+		PM10:           current.PM10,
+		Updated:        current.Timestamp.In(zone).Format("15:04 MST"),
+	}
+	if result.StaleDataWarning {
+		view.DataAge = h.formatDataAge(result.CacheAge, userLang)
+	}
+	return view
+}
 
-*%s:*
-%s: %d (%s)
-CO: %.2f | NO₂: %.2f | O₃: %.2f
-PM2.5: %.1f | PM10: %.1f
-
-%s: %s`,
-		locationName,
-		temperature, int(weather.Temperature), feelsLike, int(weather.Temperature), // FeelsLike not available in current struct
-		humidity, weather.Humidity,
-		wind, weather.WindSpeed, weather.WindDirection,
-		visibility, weather.Visibility,
-		uvIndex, weather.UVIndex,
-		pressure, weather.Pressure,
-		weather.Icon,
-		weather.Description,
-		airQuality,
-		aqi, weather.AQI, h.getAQIDescription(weather.AQI, userLang),
-		weather.CO,
-		weather.NO2,
-		weather.O3,
-		weather.PM25,
-		weather.PM10,
-		updated, weather.Timestamp.Format("15:04 UTC"))
+// showsRecommendations reports whether the user wants clothing and activity
+// tips under current weather; they are on unless turned off in Settings
+func (h *CommandHandler) showsRecommendations(ctx *ext.Context, userID int64) bool {
+	user, err := h.getUser(ctx, userID)
+	return err != nil || user.ShowRecommendations
 }
 
-func (h *CommandHandler) getAQIDescription(aqi int, language string) string {
+// formatDataAge renders how old data is in its largest whole unit, at least
+// a minute
+func (h *CommandHandler) formatDataAge(age time.Duration, lang string) string {
 	switch {
-	case aqi <= 50:
-		return h.services.Localization.T(context.Background(), language, "aqi_good")
-	case aqi <= 100:
-		return h.services.Localization.T(context.Background(), language, "aqi_moderate")
-	case aqi <= 150:
-		return h.services.Localization.T(context.Background(), language, "aqi_unhealthy_sensitive")
-	case aqi <= 200:
-		return h.services.Localization.T(context.Background(), language, "aqi_unhealthy")
-	case aqi <= 300:
-		return h.services.Localization.T(context.Background(), language, "aqi_very_unhealthy")
+	case age >= 24*time.Hour:
+		return h.services.Localization.T(context.Background(), lang, "data_age_days", int(age/(24*time.Hour)))
+	case age >= time.Hour:
+		return h.services.Localization.T(context.Background(), lang, "data_age_hours", int(age/time.Hour))
 	default:
-		return h.services.Localization.T(context.Background(), language, "aqi_hazardous")
+		return h.services.Localization.T(context.Background(), lang, "data_age_minutes", max(1, int(age/time.Minute)))
 	}
 }
 
-func (h *CommandHandler) formatForecastMessage(forecast *weather.ForecastData, language string) string {
-	title := h.services.Localization.T(context.Background(), language, "forecast_title", forecast.Location)
-	text := fmt.Sprintf("%s\n\n", title)
+// formatSunTimes shows sunrise and sunset in the timezone, e.g.
+// "🌅 06:42 / 🌇 19:15", or nothing when the sun does not rise or set
+func formatSunTimes(sunrise, sunset time.Time, zone *time.Location) string {
+	if sunrise.IsZero() || sunset.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("🌅 %s / 🌇 %s", sunrise.In(zone).Format("15:04"), sunset.In(zone).Format("15:04"))
+}
 
-	humidityLabel := h.services.Localization.T(context.Background(), language, "forecast_humidity")
-	windLabel := h.services.Localization.T(context.Background(), language, "forecast_wind")
+func (h *CommandHandler) getAQIDescription(aqi int, language string) string {
+	return h.services.Localization.T(context.Background(), language, services.AQIDescriptionKey(aqi))
+}
 
+// forecastView formats the forecast days for forecast.tmpl, with dates in the
+// language and each day's sunrise and sunset in zone, the user's timezone
+func (h *CommandHandler) forecastView(forecast *weather.ForecastData, language, units string, zone *time.Location) render.ForecastView {
+	view := render.ForecastView{Location: forecast.Location}
 	for _, day := range forecast.Forecasts {
-		text += fmt.Sprintf("📅 *%s*\n", day.Date.Format("Monday, Jan 2"))
-		text += fmt.Sprintf("🌡️ %.1f°/%.1f°C | %s %s\n",
-			day.MaxTemp, day.MinTemp, day.Icon, day.Description)
-		text += fmt.Sprintf("%s: %d%% | %s: %.1f km/h\n\n",
-			humidityLabel, day.Humidity, windLabel, day.WindSpeed)
+		view.Days = append(view.Days, render.ForecastDayView{
+			Date:        h.services.Localization.LocalizedDate(day.Date, language),
+			High:        weather.FormatTemp(day.MaxTemp, units),
+			Low:         weather.FormatTemp(day.MinTemp, units),
+			Icon:        day.Icon,
+			Description: day.Description,
+			Humidity:    weather.FormatPercent(float64(day.Humidity)),
+			Wind:        weather.FormatSpeed(day.WindSpeed, units),
+			SunTimes:    formatSunTimes(day.Sunrise, day.Sunset, zone),
+		})
 	}
-
-	return text
+	return view
 }
 
-func (h *CommandHandler) formatAirQualityMessage(air *weather.AirQualityData, language string) string {
-	title := h.services.Localization.T(context.Background(), language, "air_quality_title", "Air Quality Data")
-	overallAqi := h.services.Localization.T(context.Background(), language, "air_quality_overall_aqi", air.AQI, h.getAQIDescription(air.AQI, language))
-	pollutantsLabel := h.services.Localization.T(context.Background(), language, "air_quality_pollutants")
-	coLabel := h.services.Localization.T(context.Background(), language, "air_quality_co")
-	no2Label := h.services.Localization.T(context.Background(), language, "air_quality_no2")
-	o3Label := h.services.Localization.T(context.Background(), language, "air_quality_o3")
-	pm25Label := h.services.Localization.T(context.Background(), language, "air_quality_pm25")
-	pm10Label := h.services.Localization.T(context.Background(), language, "air_quality_pm10")
-	healthLabel := h.services.Localization.T(context.Background(), language, "air_quality_health_recommendations")
-	updatedLabel := h.services.Localization.T(context.Background(), language, "air_quality_updated")
-
-	return fmt.Sprintf(`%s
-
-%s
-
-%s
-%s: %.2f μg/m³
-%s: %.2f μg/m³
-%s: %.2f μg/m³
-%s: %.1f μg/m³
-%s: %.1f μg/m³
+// forecastSummary renders the forecast as one line per day, the block shown
+// in the secondary language when bilingual output is on
+func (h *CommandHandler) forecastSummary(forecast *weather.ForecastData, units string) services.RenderFunc {
+	return func(language string) string {
+		lines := []string{h.services.Localization.T(context.Background(), language, "forecast_title", forecast.Location)}
+		for _, day := range forecast.Forecasts {
+			lines = append(lines, fmt.Sprintf("📅 %s %d: %s/%s %s",
+				h.services.Localization.LocalizedShortWeekday(day.Date, language), day.Date.Day(),
+				weather.FormatTemp(day.MaxTemp, units), weather.FormatTemp(day.MinTemp, units), day.Icon))
+		}
+		return strings.Join(lines, "\n")
+	}
+}
 
-%s
-%s
+// airQualitySummary renders the overall AQI, the block shown in the secondary
+// language when bilingual output is on
+func (h *CommandHandler) airQualitySummary(air *weather.AirQualityData) services.RenderFunc {
+	return func(language string) string {
+		return h.services.Localization.T(context.Background(), language, "air_quality_overall_aqi", weather.FormatAQI(float64(air.AQI)), h.getAQIDescription(air.AQI, language))
+	}
+}
 
-%s: %s`,
-		title,
-		overallAqi,
-		pollutantsLabel,
-		coLabel, air.CO,
-		no2Label, air.NO2,
-		o3Label, air.O3,
-		pm25Label, air.PM25,
-		pm10Label, air.PM10,
-		healthLabel,
-		h.getHealthRecommendation(air.AQI, language),
-		updatedLabel, air.Timestamp.Format("15:04 UTC"))
+// airQualityView formats air quality readings for airquality.tmpl, with the
+// time of the readings in zone, the user's timezone
+func (h *CommandHandler) airQualityView(air *weather.AirQualityData, language string, zone *time.Location) render.AirQualityView {
+	return render.AirQualityView{
+		AQI:                  weather.FormatAQI(float64(air.AQI)),
+		AQIDescription:       h.getAQIDescription(air.AQI, language),
+		HealthRecommendation: h.getHealthRecommendation(air.AQI, language),
+		CO:                   air.CO,
+		NO2:                  air.NO2,
+		O3:                   air.O3,
+		PM25:                 air.PM25,
+		PM10:                 air.PM10,
+		Updated:              air.Timestamp.In(zone).Format("15:04 MST"),
+	}
 }
 
 func (h *CommandHandler) getHealthRecommendation(aqi int, language string) string {
@@ -1168,7 +1921,7 @@ func (h *CommandHandler) getHealthRecommendation(aqi int, language string) strin
 // Additional command handlers
 func (h *CommandHandler) SetLocation(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 	locationName := strings.TrimSpace(strings.Join(ctx.Args()[1:], " "))
 
 	if locationName == "" {
@@ -1192,11 +1945,14 @@ func (h *CommandHandler) SetLocation(bot *gotgbot.Bot, ctx *ext.Context) error {
 		setCoordsBtn := h.services.Localization.T(context.Background(), userLang, "location_settings_btn_set_coords")
 		backBtn := h.services.Localization.T(context.Background(), userLang, "button_back_to_start")
 
-		keyboard := [][]gotgbot.InlineKeyboardButton{
-			{{Text: setByNameBtn, CallbackData: "location_set_name"}},
-			{{Text: setCoordsBtn, CallbackData: "location_set_coords"}},
-			{{Text: backBtn, CallbackData: "back_to_start"}},
-		}
+		// Saved locations come first so the user can switch between them
+		savedText, keyboard := h.savedLocationSwitches(userID, userLang)
+		promptText += savedText
+		keyboard = append(keyboard,
+			[]gotgbot.InlineKeyboardButton{{Text: setByNameBtn, CallbackData: "location_set_name"}},
+			[]gotgbot.InlineKeyboardButton{{Text: setCoordsBtn, CallbackData: "location_set_coords"}},
+			[]gotgbot.InlineKeyboardButton{{Text: backBtn, CallbackData: "back_to_start"}},
+		)
 
 		_, err := bot.SendMessage(ctx.EffectiveChat.Id,
 			promptText,
@@ -1209,12 +1965,21 @@ func (h *CommandHandler) SetLocation(bot *gotgbot.Bot, ctx *ext.Context) error {
 		return err
 	}
 
+	// A saved location is switched to without looking it up again
+	if switched, err := h.switchToSavedLocation(bot, ctx, locationName); switched || err != nil {
+		return err
+	}
+
 	// Validate location
-	coords, err := h.services.Weather.GeocodeLocation(context.Background(), locationName)
+	coords, err := h.services.Weather.GeocodeLocation(h.weatherContext(ctx), locationName)
 	if err != nil {
 		errorMsg := h.services.Localization.T(context.Background(), userLang, "setlocation_not_found", locationName)
-		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
-		return err
+		return h.sendPlaceSuggestions(bot, ctx, locationName, errorMsg)
+	}
+
+	// With a location already set, ask whether to replace it or add another
+	if existing, _, _, err := h.getUserLocation(ctx, userID); err == nil && !strings.EqualFold(existing, locationName) {
+		return h.offerAnotherLocation(bot, ctx, existing, locationName)
 	}
 
 	// Save location as user's location
@@ -1225,17 +1990,25 @@ func (h *CommandHandler) SetLocation(bot *gotgbot.Bot, ctx *ext.Context) error {
 		return err
 	}
 
+	return h.sendSetLocationSuccess(bot, ctx, locationName)
+}
+
+// sendSetLocationSuccess confirms a new default location with buttons for its
+// weather and alerts
+func (h *CommandHandler) sendSetLocationSuccess(bot *gotgbot.Bot, ctx *ext.Context, locationName string) error {
+	userLang := h.getUserLanguage(ctx, ctx.EffectiveUser.Id)
+
 	weatherButtonText := h.services.Localization.T(context.Background(), userLang, "button_get_weather")
 	alertButtonText := h.services.Localization.T(context.Background(), userLang, "button_add_alert")
 
 	keyboard := [][]gotgbot.InlineKeyboardButton{
-		{{Text: weatherButtonText, CallbackData: fmt.Sprintf("weather_%s", locationName)}},
-		{{Text: alertButtonText, CallbackData: fmt.Sprintf("alert_%s", locationName)}},
+		{{Text: weatherButtonText, CallbackData: h.locationCallback("weather", locationName)}},
+		{{Text: alertButtonText, CallbackData: h.locationCallback("alert", locationName)}},
 	}
 
 	successMsg := h.services.Localization.T(context.Background(), userLang, "setlocation_success", locationName)
 
-	_, err = bot.SendMessage(ctx.EffectiveChat.Id, successMsg,
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, successMsg,
 		&gotgbot.SendMessageOpts{
 			ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
 				InlineKeyboard: keyboard,
@@ -1245,52 +2018,9 @@ func (h *CommandHandler) SetLocation(bot *gotgbot.Bot, ctx *ext.Context) error {
 	return err
 }
 
-func (h *CommandHandler) ListLocations(bot *gotgbot.Bot, ctx *ext.Context) error {
-	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
-
-	locationName, _, _, err := h.services.User.GetUserLocation(context.Background(), userID)
-	if err != nil {
-		return err
-	}
-
-	if locationName == "" {
-		noLocationText := h.services.Localization.T(context.Background(), userLang, "listlocations_no_location")
-		setLocationBtnText := h.services.Localization.T(context.Background(), userLang, "listlocations_set_location_btn")
-
-		_, err := bot.SendMessage(ctx.EffectiveChat.Id, noLocationText,
-			&gotgbot.SendMessageOpts{
-				ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
-					InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
-						{{Text: setLocationBtnText, CallbackData: "location_set"}},
-					},
-				},
-			})
-		return err
-	}
-
-	titleText := h.services.Localization.T(context.Background(), userLang, "listlocations_title", locationName)
-	currentWeatherBtnText := h.services.Localization.T(context.Background(), userLang, "listlocations_current_weather_btn")
-	changeLocationBtnText := h.services.Localization.T(context.Background(), userLang, "listlocations_change_location_btn")
-
-	keyboard := [][]gotgbot.InlineKeyboardButton{
-		{{Text: currentWeatherBtnText, CallbackData: fmt.Sprintf("weather_%s", locationName)}},
-		{{Text: changeLocationBtnText, CallbackData: "location_set"}},
-	}
-
-	_, err = bot.SendMessage(ctx.EffectiveChat.Id, titleText, &gotgbot.SendMessageOpts{
-		ParseMode: "Markdown",
-		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
-			InlineKeyboard: keyboard,
-		},
-	})
-
-	return err
-}
-
 func (h *CommandHandler) Subscribe(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	subscriptionText := h.services.Localization.T(context.Background(), userLang, "subscribe_text")
 
@@ -1320,7 +2050,7 @@ func (h *CommandHandler) Subscribe(bot *gotgbot.Bot, ctx *ext.Context) error {
 
 func (h *CommandHandler) AddAlert(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	alertText := h.services.Localization.T(context.Background(), userLang, "addalert_text")
 
@@ -1328,6 +2058,8 @@ func (h *CommandHandler) AddAlert(bot *gotgbot.Bot, ctx *ext.Context) error {
 	windBtn := h.services.Localization.T(context.Background(), userLang, "addalert_wind_btn")
 	airBtn := h.services.Localization.T(context.Background(), userLang, "addalert_air_btn")
 	rainBtn := h.services.Localization.T(context.Background(), userLang, "addalert_rain_btn")
+	pollenBtn := h.services.Localization.T(context.Background(), userLang, "addalert_pollen_btn")
+	recommendedBtn := h.services.Localization.T(context.Background(), userLang, "setup_alerts_btn")
 	myAlertsBtn := h.services.Localization.T(context.Background(), userLang, "addalert_my_alerts_btn")
 
 	keyboard := [][]gotgbot.InlineKeyboardButton{
@@ -1335,6 +2067,8 @@ func (h *CommandHandler) AddAlert(bot *gotgbot.Bot, ctx *ext.Context) error {
 		{{Text: windBtn, CallbackData: "alert_create_wind"}},
 		{{Text: airBtn, CallbackData: "alert_create_air"}},
 		{{Text: rainBtn, CallbackData: "alert_create_rain"}},
+		{{Text: pollenBtn, CallbackData: "alert_create_pollen"}},
+		{{Text: recommendedBtn, CallbackData: "setup_alerts"}},
 		{{Text: myAlertsBtn, CallbackData: "alerts_list"}},
 	}
 
@@ -1352,10 +2086,10 @@ func (h *CommandHandler) AddAlert(bot *gotgbot.Bot, ctx *ext.Context) error {
 func (h *CommandHandler) AdminStats(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
 
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	// Check admin permissions
-	user, err := h.services.User.GetUser(context.Background(), userID)
+	user, err := h.getUser(ctx, userID)
 	if err != nil || user.Role != models.RoleAdmin {
 		errorMsg := h.services.Localization.T(context.Background(), userLang, "unauthorized")
 		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
@@ -1369,18 +2103,21 @@ func (h *CommandHandler) AdminStats(bot *gotgbot.Bot, ctx *ext.Context) error {
 
 	title := h.services.Localization.T(context.Background(), userLang, "admin_stats_title")
 	usersSection := h.services.Localization.T(context.Background(), userLang, "admin_stats_users_section")
-	totalUsers := h.services.Localization.T(context.Background(), userLang, "admin_stats_total_users", stats.TotalUsers)
-	activeUsers := h.services.Localization.T(context.Background(), userLang, "admin_stats_active_users", stats.ActiveUsers)
-	newUsers := h.services.Localization.T(context.Background(), userLang, "admin_stats_new_users", stats.NewUsers24h)
-	usersWithLocation := h.services.Localization.T(context.Background(), userLang, "admin_stats_users_with_location", stats.UsersWithLocation)
+	totalUsers := h.services.Localization.TN(context.Background(), userLang, "admin_stats_total_users", stats.TotalUsers)
+	activeUsers := h.services.Localization.TN(context.Background(), userLang, "admin_stats_active_users", stats.ActiveUsers)
+	deactivatedUsers := h.services.Localization.TN(context.Background(), userLang, "admin_stats_deactivated_users", stats.DeactivatedUsers)
+	newUsers := h.services.Localization.TN(context.Background(), userLang, "admin_stats_new_users", stats.NewUsers24h)
+	usersSeen := h.services.Localization.T(context.Background(), userLang, "admin_stats_users_seen", stats.DailyActiveUsers, stats.WeeklyActiveUsers, stats.MonthlyActiveUsers)
+	usersWithLocation := h.services.Localization.TN(context.Background(), userLang, "admin_stats_users_with_location", stats.UsersWithLocation)
+	onboarding := h.services.Localization.T(context.Background(), userLang, "admin_stats_onboarding", stats.OnboardingCompleted, stats.OnboardingStarted, stats.OnboardingConversion())
 
 	notificationsSection := h.services.Localization.T(context.Background(), userLang, "admin_stats_notifications_section")
-	activeSubscriptions := h.services.Localization.T(context.Background(), userLang, "admin_stats_active_subscriptions", stats.ActiveSubscriptions)
-	alertsConfigured := h.services.Localization.T(context.Background(), userLang, "admin_stats_alerts_configured", stats.AlertsConfigured)
-	messagesSent := h.services.Localization.T(context.Background(), userLang, "admin_stats_messages_sent", stats.MessagesSent24h)
+	activeSubscriptions := h.services.Localization.TN(context.Background(), userLang, "admin_stats_active_subscriptions", stats.ActiveSubscriptions)
+	alertsConfigured := h.services.Localization.TN(context.Background(), userLang, "admin_stats_alerts_configured", stats.AlertsConfigured)
+	messagesSent := h.services.Localization.TN(context.Background(), userLang, "admin_stats_messages_sent", stats.MessagesSent24h)
 
 	apiSection := h.services.Localization.T(context.Background(), userLang, "admin_stats_api_section")
-	weatherRequests := h.services.Localization.T(context.Background(), userLang, "admin_stats_weather_requests", stats.WeatherRequests24h)
+	weatherRequests := h.services.Localization.TN(context.Background(), userLang, "admin_stats_weather_requests", stats.WeatherRequests24h)
 	cacheHitRate := h.services.Localization.T(context.Background(), userLang, "admin_stats_cache_hit_rate", stats.CacheHitRate)
 
 	performanceSection := h.services.Localization.T(context.Background(), userLang, "admin_stats_performance_section")
@@ -1394,6 +2131,9 @@ func (h *CommandHandler) AdminStats(bot *gotgbot.Bot, ctx *ext.Context) error {
 %s
 %s
 %s
+%s
+%s
+%s
 
 %s
 %s
@@ -1408,7 +2148,7 @@ func (h *CommandHandler) AdminStats(bot *gotgbot.Bot, ctx *ext.Context) error {
 %s
 %s`,
 		title,
-		usersSection, totalUsers, activeUsers, newUsers, usersWithLocation,
+		usersSection, totalUsers, activeUsers, deactivatedUsers, newUsers, usersSeen, usersWithLocation, onboarding,
 		notificationsSection, activeSubscriptions, alertsConfigured, messagesSent,
 		apiSection, weatherRequests, cacheHitRate,
 		performanceSection, avgResponseTime, uptime)
@@ -1427,111 +2167,136 @@ func (h *CommandHandler) handleWeatherCallback(bot *gotgbot.Bot, ctx *ext.Contex
 		return h.CurrentWeather(bot, ctx)
 	default:
 		// Handle weather for specific location from button callback
-		locationName := action
-		if len(params) > 0 {
-			locationName = strings.Join(append([]string{action}, params...), " ")
+		locationName, err := h.callbackLabel(append([]string{action}, params...))
+		if err != nil {
+			return h.sendLabelExpired(bot, ctx)
 		}
-
 		return h.getWeatherForLocation(bot, ctx, locationName)
 	}
 }
 
+// weatherRateLimited counts a weather request of the user and, when they are
+// over their limit, tells them how long to wait. The limit fails open: a
+// Redis error never keeps anyone from the weather.
+func (h *CommandHandler) weatherRateLimited(bot *gotgbot.Bot, ctx *ext.Context, userID int64) (bool, error) {
+	// Requests with the user's own API key count against its rate limit
+	if h.services.WeatherLimit == nil || h.userAPIKey(ctx) != "" {
+		return false, nil
+	}
+
+	result, err := h.services.WeatherLimit.Allow(context.Background(), userID)
+	if err != nil {
+		h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to check weather rate limit")
+		return false, nil
+	}
+	if result.Allowed {
+		return false, nil
+	}
+
+	userLang := h.getUserLanguage(ctx, userID)
+	message := h.services.Localization.T(context.Background(), userLang, "rate_limit_exceeded",
+		h.services.WeatherLimit.Limit(), int(result.RetryAfter.Seconds()))
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, message, nil)
+	return true, err
+}
+
 // Helper function to get weather for a specific location
 func (h *CommandHandler) getWeatherForLocation(bot *gotgbot.Bot, ctx *ext.Context, locationName string) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	// Get weather data
-	weatherData, err := h.services.Weather.GetCurrentWeatherByLocation(context.Background(), locationName)
+	weatherData, err := h.services.Weather.GetCurrentWeatherByLocation(weather.WithLanguage(h.weatherContext(ctx), userLang), locationName)
 	if err != nil {
-		errorMsg := h.services.Localization.T(context.Background(), userLang, "error_weather_get_failed", locationName)
-		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
-		return err
+		return h.sendWeatherError(bot, ctx, locationName, err, "error_weather_get_failed", locationName)
 	}
 
 	// Format weather information using localized template
-	weatherFormat := h.services.Localization.T(context.Background(), userLang, "weather_current_format")
-	weatherText := fmt.Sprintf(weatherFormat,
-		weatherData.LocationName,
-		weatherData.Temperature,
-		weatherData.WindSpeed,
-		weatherData.Humidity,
-		weatherData.Pressure,
-		weatherData.Visibility,
-		weatherData.UVIndex,
-		weatherData.Description,
-	)
+	units := h.getUserUnits(ctx, userID)
+	weatherText := h.renderForUser(ctx, userID, func(language string) string {
+		weatherFormat := h.services.Localization.T(context.Background(), language, "weather_current_format")
+		return fmt.Sprintf(weatherFormat,
+			markdown.EscapeBold(weatherData.LocationName),
+			weather.FormatTemp(weatherData.Temperature, units),
+			weather.FormatTemp(weatherData.FeelsLike, units),
+			weather.FormatSpeed(weatherData.WindSpeed, units),
+			weather.FormatPercent(float64(weatherData.Humidity)),
+			weather.FormatPressure(weatherData.Pressure, units),
+			weather.FormatVisibility(weatherData.Visibility, units),
+			weather.FormatUV(weatherData.UVIndex),
+			weatherData.Description,
+		)
+	}, services.WeatherSummary(h.services.Localization, weatherData, units))
 
 	forecastBtn := h.services.Localization.T(context.Background(), userLang, "button_forecast")
 	airQualityBtn := h.services.Localization.T(context.Background(), userLang, "button_air_quality")
 	setAlertBtn := h.services.Localization.T(context.Background(), userLang, "button_set_alert")
 
 	keyboard := [][]gotgbot.InlineKeyboardButton{
-		{{Text: forecastBtn, CallbackData: fmt.Sprintf("forecast_%s", locationName)}},
-		{{Text: airQualityBtn, CallbackData: fmt.Sprintf("air_%s", locationName)}},
-		{{Text: setAlertBtn, CallbackData: fmt.Sprintf("alert_%s", locationName)}},
+		{{Text: forecastBtn, CallbackData: h.locationCallback("forecast", locationName)}},
+		{{Text: airQualityBtn, CallbackData: h.locationCallback("air", locationName)}},
+		{{Text: setAlertBtn, CallbackData: h.locationCallback("alert", locationName)}},
 	}
 
-	_, err = bot.SendMessage(ctx.EffectiveChat.Id, weatherText, &gotgbot.SendMessageOpts{
+	return h.sendOrEdit(bot, ctx, weatherText, &gotgbot.SendMessageOpts{
 		ParseMode: "Markdown",
 		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
 			InlineKeyboard: keyboard,
 		},
 	})
-
-	return err
 }
 
 func (h *CommandHandler) getForecastForLocation(bot *gotgbot.Bot, ctx *ext.Context, locationName string) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	// First get coordinates for the location
-	locationData, err := h.services.Weather.GeocodeLocation(context.Background(), locationName)
+	locationData, err := h.services.Weather.GeocodeLocation(h.weatherContext(ctx), locationName)
 	if err != nil {
-		errorMsg := h.services.Localization.T(context.Background(), userLang, "error_location_not_found", locationName)
-		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
-		return err
+		return h.sendWeatherError(bot, ctx, locationName, err, "error_location_not_found", locationName)
 	}
 
-	forecast, err := h.services.Weather.GetForecast(context.Background(), locationData.Latitude, locationData.Longitude, 5)
+	forecast, err := h.services.Weather.GetForecast(weather.WithLanguage(h.weatherContext(ctx), userLang), locationData.Latitude, locationData.Longitude, 5)
 	if err != nil {
-		errorMsg := h.services.Localization.T(context.Background(), userLang, "error_forecast_get_failed", locationName)
-		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
-		return err
+		return h.sendWeatherError(bot, ctx, locationName, err, "error_forecast_get_failed", locationName)
 	}
 
-	forecastText := h.formatForecastMessage(forecast, userLang)
+	units := h.getUserUnits(ctx, userID)
+	zone := h.getUserTimezone(ctx, userID)
+	forecastText := h.renderForUser(ctx, userID, func(language string) string {
+		return h.render(render.Forecast, language, h.forecastView(forecast, language, units, zone))
+	}, h.forecastSummary(forecast, units))
 
 	currentWeatherBtn := h.services.Localization.T(context.Background(), userLang, "button_current_weather")
 	airQualityBtn := h.services.Localization.T(context.Background(), userLang, "button_air_quality")
 
 	keyboard := [][]gotgbot.InlineKeyboardButton{
-		{{Text: currentWeatherBtn, CallbackData: fmt.Sprintf("weather_%s", locationName)}},
-		{{Text: airQualityBtn, CallbackData: fmt.Sprintf("air_%s", locationName)}},
+		{{Text: currentWeatherBtn, CallbackData: h.locationCallback("weather", locationName)}},
+		{{Text: airQualityBtn, CallbackData: h.locationCallback("air", locationName)}},
 	}
 
-	_, err = bot.SendMessage(ctx.EffectiveChat.Id, forecastText, &gotgbot.SendMessageOpts{
+	return h.sendOrEdit(bot, ctx, forecastText, &gotgbot.SendMessageOpts{
 		ParseMode: "Markdown",
 		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
 			InlineKeyboard: keyboard,
 		},
 	})
-	return err
 }
 
 func (h *CommandHandler) getForecastByCoords(bot *gotgbot.Bot, ctx *ext.Context, lat, lon float64) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
-	forecast, err := h.services.Weather.GetForecast(context.Background(), lat, lon, 5)
+	forecast, err := h.services.Weather.GetForecast(weather.WithLanguage(h.weatherContext(ctx), userLang), lat, lon, 5)
 	if err != nil {
-		errorMsg := h.services.Localization.T(context.Background(), userLang, "forecast_error")
-		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
-		return err
+		return h.sendWeatherError(bot, ctx, "", err, "forecast_error")
 	}
 
-	forecastText := h.formatForecastMessage(forecast, userLang)
+	units := h.getUserUnits(ctx, userID)
+	zone := h.getUserTimezone(ctx, userID)
+	forecastText := h.renderForUser(ctx, userID, func(language string) string {
+		return h.render(render.Forecast, language, h.forecastView(forecast, language, units, zone))
+	}, h.forecastSummary(forecast, units))
 
 	currentWeatherBtn := h.services.Localization.T(context.Background(), userLang, "button_current_weather")
 	airQualityBtn := h.services.Localization.T(context.Background(), userLang, "button_air_quality")
@@ -1541,13 +2306,12 @@ func (h *CommandHandler) getForecastByCoords(bot *gotgbot.Bot, ctx *ext.Context,
 		{{Text: airQualityBtn, CallbackData: fmt.Sprintf("air_coords_%.4f_%.4f", lat, lon)}},
 	}
 
-	_, err = bot.SendMessage(ctx.EffectiveChat.Id, forecastText, &gotgbot.SendMessageOpts{
+	return h.sendOrEdit(bot, ctx, forecastText, &gotgbot.SendMessageOpts{
 		ParseMode: "Markdown",
 		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
 			InlineKeyboard: keyboard,
 		},
 	})
-	return err
 }
 
 func (h *CommandHandler) handleForecastCallback(bot *gotgbot.Bot, ctx *ext.Context, action string, params []string) error {
@@ -1566,9 +2330,9 @@ func (h *CommandHandler) handleForecastCallback(bot *gotgbot.Bot, ctx *ext.Conte
 		}
 	default:
 		// Handle forecast for specific location from button callback
-		locationName := action
-		if len(params) > 0 {
-			locationName = strings.Join(append([]string{action}, params...), " ")
+		locationName, err := h.callbackLabel(append([]string{action}, params...))
+		if err != nil {
+			return h.sendLabelExpired(bot, ctx)
 		}
 		return h.getForecastForLocation(bot, ctx, locationName)
 	}
@@ -1588,27 +2352,51 @@ func (h *CommandHandler) handleSettingsCallback(bot *gotgbot.Bot, ctx *ext.Conte
 			return h.setUserLanguage(bot, ctx, params[1])
 		}
 		return h.handleLanguageSettings(bot, ctx)
+	case "secondlang":
+		if len(params) >= 2 && params[0] == "set" {
+			return h.setSecondaryLanguage(bot, ctx, params[1])
+		}
+		return h.handleSecondaryLanguageSettings(bot, ctx)
 	case "units":
 		if len(params) >= 2 && params[0] == "set" {
 			return h.setUserUnits(bot, ctx, params[1])
 		}
 		return h.handleUnitSettings(bot, ctx)
+	case "weekstart":
+		if len(params) >= 2 && params[0] == "set" {
+			return h.setWeekStart(bot, ctx, params[1])
+		}
+		return h.handleWeekStartSettings(bot, ctx)
+	case "skintype":
+		if len(params) >= 2 && params[0] == "set" {
+			return h.setSkinType(bot, ctx, params[1])
+		}
+		return h.handleSkinTypeSettings(bot, ctx)
 	case "timezone":
 		if len(params) >= 2 && params[0] == "set" {
 			return h.setUserTimezone(bot, ctx, strings.Join(params[1:], "_"))
 		}
 		return h.handleTimezoneSettings(bot, ctx)
+	case "tips":
+		if len(params) >= 1 {
+			return h.setShowRecommendations(bot, ctx, params[0] == "on")
+		}
 	case "notifications":
 		return h.handleNotificationSettings(bot, ctx)
 	case "export":
 		return h.handleExportSettings(bot, ctx)
+	case "apikey":
+		if len(params) >= 1 && params[0] == "remove" {
+			return h.removeAPIKey(bot, ctx)
+		}
+		return h.handleAPIKeySettings(bot, ctx)
 	}
 	return nil
 }
 
 func (h *CommandHandler) handleLocationCallback(bot *gotgbot.Bot, ctx *ext.Context, action string, params []string) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	switch action {
 	case "add":
@@ -1651,28 +2439,34 @@ func (h *CommandHandler) handleLocationCallback(bot *gotgbot.Bot, ctx *ext.Conte
 		_, err = bot.SendMessage(ctx.EffectiveChat.Id, successText, nil)
 		return err
 	case "default":
-		// With single location per user, this is no longer needed
-		_, err := bot.SendMessage(ctx.EffectiveChat.Id, "✅ You only have one location - it's already your default!", nil)
-		return err
+		// The default is picked from the saved locations
+		return h.ListLocations(bot, ctx)
 	case "save":
 		h.logger.Info().Int("params_count", len(params)).Interface("params", params).Msg("Location save callback")
 		// Handle saving shared location
-		if len(params) >= 3 {
+		if len(params) >= 2 {
 			lat, _ := strconv.ParseFloat(params[0], 64)
 			lon, _ := strconv.ParseFloat(params[1], 64)
-			encodedName := strings.Join(params[2:], "_")
 
-			// URL decode the location name
-			name, decodeErr := url.QueryUnescape(encodedName)
-			if decodeErr != nil {
-				// If decoding fails, use the encoded name as fallback
-				name = encodedName
+			var name string
+			var err error
+			if len(params) > 2 {
+				if name, err = h.callbackLabel(params[2:]); err != nil {
+					if errors.Is(err, services.ErrLabelExpired) {
+						return h.sendLabelExpired(bot, ctx)
+					}
+					h.logger.Error().Err(err).Msg("Failed to load location name")
+					_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Failed to save location", nil)
+					return err
+				}
+			} else if name, err = h.services.Weather.GetLocationName(h.weatherContext(ctx), lat, lon); err != nil {
+				name = fmt.Sprintf("Location (%.4f, %.4f)", lat, lon)
 			}
 
 			h.logger.Info().Float64("lat", lat).Float64("lon", lon).Str("name", name).Msg("Saving location")
 
 			userID := ctx.EffectiveUser.Id
-			err := h.services.User.SetUserLocation(context.Background(), userID, name, "", "", lat, lon)
+			err = h.services.User.SetUserLocation(context.Background(), userID, name, "", "", lat, lon)
 			if err != nil {
 				h.logger.Error().Err(err).Msg("Failed to save location to database")
 				_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Failed to save location", nil)
@@ -1680,27 +2474,21 @@ func (h *CommandHandler) handleLocationCallback(bot *gotgbot.Bot, ctx *ext.Conte
 			}
 
 			h.logger.Info().Str("name", name).Msg("Location saved successfully")
-			_, err = bot.SendMessage(ctx.EffectiveChat.Id,
-				fmt.Sprintf("✅ Location '%s' saved successfully!", name), nil)
-			return err
+			return h.sendLocationSaved(bot, ctx, fmt.Sprintf("✅ Location '%s' saved successfully!", markdown.Escape(name)))
 		} else {
 			h.logger.Warn().Int("params_count", len(params)).Msg("Not enough parameters for location save")
 		}
 	case "confirm":
 		// Handle location confirmation from plain text input
 		if len(params) >= 1 {
-			encodedLocationName := strings.Join(params, " ")
-			// URL decode the location name
-			locationName, decodeErr := url.QueryUnescape(encodedLocationName)
-			if decodeErr != nil {
-				// If decoding fails, use the encoded name as fallback
-				locationName = encodedLocationName
-				h.logger.Warn().Err(decodeErr).Str("encoded", encodedLocationName).Msg("Failed to decode location name")
+			locationName, err := h.callbackLabel(params)
+			if err != nil {
+				return h.sendLabelExpired(bot, ctx)
 			}
 			h.logger.Info().Str("location", locationName).Msg("User confirmed location from text input")
 
 			userID := ctx.EffectiveUser.Id
-			userLang := h.getUserLanguage(context.Background(), userID)
+			userLang := h.getUserLanguage(ctx, userID)
 
 			// Check if this is raw coordinates input that needs processing
 			coordPattern := `^coordinates \((-?\d+\.?\d*),\s*(-?\d+\.?\d*)\)$`
@@ -1715,7 +2503,7 @@ func (h *CommandHandler) handleLocationCallback(bot *gotgbot.Bot, ctx *ext.Conte
 				h.logger.Info().Float64("lat", lat).Float64("lon", lon).Msg("Processing raw coordinates on confirmation")
 
 				// Get location name from coordinates (reverse geocoding)
-				baseName, err := h.services.Weather.GetLocationName(context.Background(), lat, lon)
+				baseName, err := h.services.Weather.GetLocationName(h.weatherContext(ctx), lat, lon)
 				if err != nil {
 					baseName = "Location"
 					h.logger.Warn().Err(err).Msg("Failed to get location name from coordinates, using default")
@@ -1733,10 +2521,7 @@ func (h *CommandHandler) handleLocationCallback(bot *gotgbot.Bot, ctx *ext.Conte
 				}
 
 				h.logger.Info().Str("location", finalLocationName).Msg("Location with coordinates saved successfully")
-				_, err = bot.SendMessage(ctx.EffectiveChat.Id,
-					fmt.Sprintf("✅ Location set to *%s*", finalLocationName),
-					&gotgbot.SendMessageOpts{ParseMode: "Markdown"})
-				return err
+				return h.sendLocationSaved(bot, ctx, fmt.Sprintf("✅ Location set to *%s*", markdown.EscapeBold(finalLocationName)))
 			}
 
 			// Check if this location name already contains coordinates (from reverse geocoding)
@@ -1762,18 +2547,14 @@ func (h *CommandHandler) handleLocationCallback(bot *gotgbot.Bot, ctx *ext.Conte
 				}
 
 				h.logger.Info().Str("location", locationName).Msg("Location with coordinates saved successfully")
-				_, err = bot.SendMessage(ctx.EffectiveChat.Id,
-					fmt.Sprintf("✅ Location set to *%s*", locationName),
-					&gotgbot.SendMessageOpts{ParseMode: "Markdown"})
-				return err
+				return h.sendLocationSaved(bot, ctx, fmt.Sprintf("✅ Location set to *%s*", markdown.EscapeBold(locationName)))
 			} else {
 				// Regular location name (name-based input) - needs geocoding
-				location, err := h.services.Weather.GeocodeLocation(context.Background(), locationName)
+				location, err := h.services.Weather.GeocodeLocation(h.weatherContext(ctx), locationName)
 				if err != nil {
 					h.logger.Error().Err(err).Str("location", locationName).Msg("Failed to geocode location")
-					_, err := bot.SendMessage(ctx.EffectiveChat.Id,
-						fmt.Sprintf("❌ Sorry, I couldn't find the location '%s'. Please try a different city name.", locationName), nil)
-					return err
+					return h.sendPlaceSuggestions(bot, ctx, locationName,
+						fmt.Sprintf("❌ Sorry, I couldn't find the location '%s'. Please try a different city name.", locationName))
 				}
 
 				// Save the location
@@ -1785,11 +2566,39 @@ func (h *CommandHandler) handleLocationCallback(bot *gotgbot.Bot, ctx *ext.Conte
 				}
 
 				h.logger.Info().Str("location", location.Name).Msg("Location saved successfully from text input")
-				successText := h.services.Localization.T(context.Background(), userLang, "location_save_success_with_coords", location.Name, location.Country, location.Latitude, location.Longitude)
+				successText := h.services.Localization.T(context.Background(), userLang, "location_save_success_with_coords", markdown.EscapeBold(location.Name), markdown.EscapeBold(location.Country), location.Latitude, location.Longitude)
 				_, err = bot.SendMessage(ctx.EffectiveChat.Id, successText, &gotgbot.SendMessageOpts{ParseMode: "Markdown"})
 				return err
 			}
 		}
+	case "privacy":
+		if len(params) > 0 {
+			return h.setLocationPrivacy(bot, ctx, params[0] == "city")
+		}
+	case "pick", "nearby":
+		if len(params) < 2 {
+			return nil
+		}
+		lat, latErr := strconv.ParseFloat(params[0], 64)
+		lon, lonErr := strconv.ParseFloat(params[1], 64)
+		if latErr != nil || lonErr != nil {
+			h.logger.Warn().Strs("params", params).Msg("Invalid coordinates in location callback")
+			return nil
+		}
+		if action == "nearby" {
+			return h.sendNearbyPlaces(bot, ctx, lat, lon)
+		}
+
+		var label string
+		if len(params) > 2 {
+			var err error
+			if label, err = h.callbackLabel(params[2:]); err != nil {
+				// The place can still be shown, only the typed name is gone
+				h.logger.Debug().Err(err).Msg("Callback label unavailable")
+				label = ""
+			}
+		}
+		return h.sendCoordinateWeather(bot, ctx, lat, lon, label, false)
 	case "ignore":
 		// Handle ignoring potential location from plain text input
 		h.logger.Info().Msg("User ignored location suggestion from text input")
@@ -1818,7 +2627,7 @@ func (h *CommandHandler) handleTimezoneCallback(bot *gotgbot.Bot, ctx *ext.Conte
 			if !h.isValidTimezone(timezoneName) {
 				h.logger.Error().Str("timezone", timezoneName).Msg("Invalid timezone during confirmation")
 				userID := ctx.EffectiveUser.Id
-				userLang := h.getUserLanguage(context.Background(), userID)
+				userLang := h.getUserLanguage(ctx, userID)
 				errorMsg := h.services.Localization.T(context.Background(), userLang, "error_timezone_invalid_simple")
 				_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
 				return err
@@ -1900,27 +2709,59 @@ func (h *CommandHandler) handleLanguageCallback(bot *gotgbot.Bot, ctx *ext.Conte
 
 func (h *CommandHandler) handleAlertCallback(bot *gotgbot.Bot, ctx *ext.Context, action string, params []string) error {
 	switch action {
+	case "ack":
+		return h.acknowledgeAlert(bot, ctx)
 	case "create":
 		if len(params) > 0 {
 			alertType := params[0]
 			return h.handleCreateAlert(bot, ctx, alertType)
 		}
 	case "temp":
+		if len(params) == 1 && params[0] == "custom" {
+			return h.startCustomAlert(bot, ctx, models.AlertTemperature)
+		}
 		if len(params) >= 2 {
 			return h.handleTemperatureAlert(bot, ctx, params[0], params[1])
 		}
 	case "wind":
+		if len(params) == 1 && params[0] == "custom" {
+			return h.startCustomAlert(bot, ctx, models.AlertWindSpeed)
+		}
 		if len(params) >= 2 {
 			return h.handleWindAlert(bot, ctx, params[0], params[1])
 		}
 	case "air":
+		if len(params) == 1 && params[0] == "custom" {
+			return h.startCustomAlert(bot, ctx, models.AlertAirQuality)
+		}
 		if len(params) >= 2 {
 			return h.handleAirQualityAlert(bot, ctx, params[0], params[1])
 		}
 	case "humidity":
+		if len(params) == 1 && params[0] == "custom" {
+			return h.startCustomAlert(bot, ctx, models.AlertHumidity)
+		}
 		if len(params) >= 2 {
 			return h.handleHumidityAlert(bot, ctx, params[0], params[1])
 		}
+	case "pollen":
+		if len(params) == 1 && params[0] == "custom" {
+			return h.startCustomAlert(bot, ctx, models.AlertPollen)
+		}
+		if len(params) >= 2 {
+			return h.handlePollenAlert(bot, ctx, params[0], params[1])
+		}
+	case "tide":
+		if len(params) == 1 && params[0] == "custom" {
+			return h.startCustomAlert(bot, ctx, models.AlertTide)
+		}
+		if len(params) >= 2 {
+			return h.handleTideAlert(bot, ctx, params[0], params[1])
+		}
+	case "custom":
+		if len(params) > 0 && params[0] == "cancel" {
+			return h.cancelCustomAlert(bot, ctx)
+		}
 	case "edit":
 		if len(params) > 0 {
 			return h.editAlert(bot, ctx, params[0])
@@ -1931,11 +2772,10 @@ func (h *CommandHandler) handleAlertCallback(bot *gotgbot.Bot, ctx *ext.Context,
 		}
 	default:
 		// Handle alert setup for specific location from button callback
-		locationName := action
-		if len(params) > 0 {
-			locationName = strings.Join(append([]string{action}, params...), " ")
+		locationName, err := h.callbackLabel(append([]string{action}, params...))
+		if err != nil {
+			return h.sendLabelExpired(bot, ctx)
 		}
-
 		return h.showAlertOptions(bot, ctx, locationName)
 	}
 	return nil
@@ -1944,14 +2784,14 @@ func (h *CommandHandler) handleAlertCallback(bot *gotgbot.Bot, ctx *ext.Context,
 // Helper function to show alert options for a location
 func (h *CommandHandler) showAlertOptions(bot *gotgbot.Bot, ctx *ext.Context, locationName string) error {
 	keyboard := [][]gotgbot.InlineKeyboardButton{
-		{{Text: "🌡️ Temperature Alert", CallbackData: fmt.Sprintf("alert_temp_setup_%s", locationName)}},
-		{{Text: "💨 Wind Speed Alert", CallbackData: fmt.Sprintf("alert_wind_setup_%s", locationName)}},
-		{{Text: "🌬️ Air Quality Alert", CallbackData: fmt.Sprintf("alert_air_setup_%s", locationName)}},
-		{{Text: "💧 Humidity Alert", CallbackData: fmt.Sprintf("alert_humidity_setup_%s", locationName)}},
+		{{Text: "🌡️ Temperature Alert", CallbackData: h.locationCallback("alert_temp_setup", locationName)}},
+		{{Text: "💨 Wind Speed Alert", CallbackData: h.locationCallback("alert_wind_setup", locationName)}},
+		{{Text: "🌬️ Air Quality Alert", CallbackData: h.locationCallback("alert_air_setup", locationName)}},
+		{{Text: "💧 Humidity Alert", CallbackData: h.locationCallback("alert_humidity_setup", locationName)}},
 	}
 
 	_, err := bot.SendMessage(ctx.EffectiveChat.Id,
-		fmt.Sprintf("🔔 *Set Alert for %s*\n\nChoose the type of alert you want to create:", locationName),
+		fmt.Sprintf("🔔 *Set Alert for %s*\n\nChoose the type of alert you want to create:", markdown.EscapeBold(locationName)),
 		&gotgbot.SendMessageOpts{
 			ParseMode: "Markdown",
 			ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
@@ -1997,7 +2837,7 @@ func (h *CommandHandler) handleLanguageSettings(bot *gotgbot.Bot, ctx *ext.Conte
 
 func (h *CommandHandler) handleUnitSettings(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	text := h.services.Localization.T(context.Background(), userLang, "units_choose_prompt")
 
@@ -2021,7 +2861,7 @@ func (h *CommandHandler) handleUnitSettings(bot *gotgbot.Bot, ctx *ext.Context)
 
 func (h *CommandHandler) handleTimezoneSettings(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	text := h.services.Localization.T(context.Background(), userLang, "timezone_input_prompt")
 	_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
@@ -2030,27 +2870,61 @@ func (h *CommandHandler) handleTimezoneSettings(bot *gotgbot.Bot, ctx *ext.Conte
 	return err
 }
 
+// acknowledgeAlert handles the "OK, got it" button under an extreme alert: it
+// cancels the follow-up and marks the button as acknowledged
+func (h *CommandHandler) acknowledgeAlert(bot *gotgbot.Bot, ctx *ext.Context) error {
+	chatID := ctx.EffectiveChat.Id
+	messageID := ctx.CallbackQuery.Message.GetMessageId()
+	userLang := h.getUserLanguage(ctx, ctx.EffectiveUser.Id)
+
+	pending, err := h.services.Escalations.Acknowledge(context.Background(), chatID, messageID)
+	if err != nil {
+		h.logger.Error().Err(err).Int64("chat_id", chatID).Msg("Failed to acknowledge alert")
+		return err
+	}
+	// Already acknowledged, or the follow-up went out already
+	if !pending {
+		return nil
+	}
+
+	_, _, err = bot.EditMessageReplyMarkup(&gotgbot.EditMessageReplyMarkupOpts{
+		ChatId:    chatID,
+		MessageId: messageID,
+		ReplyMarkup: gotgbot.InlineKeyboardMarkup{
+			InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+				{{Text: h.services.Localization.T(context.Background(), userLang, "alert_acknowledged_btn"), CallbackData: services.AlertAckCallback}},
+			},
+		},
+	})
+	return err
+}
+
 func (h *CommandHandler) handleCreateAlert(bot *gotgbot.Bot, ctx *ext.Context, alertType string) error {
 	var text string
 	var keyboard [][]gotgbot.InlineKeyboardButton
+	units := h.getUserUnits(ctx, ctx.EffectiveUser.Id)
 
 	switch alertType {
 	case "temperature":
 		text = `🌡️ *Temperature Alert Setup*
 
 Choose alert condition:`
+		highLabel, highData := alertPreset("alert_temp_high", models.AlertTemperature, 30, units)
+		lowLabel, lowData := alertPreset("alert_temp_low", models.AlertTemperature, 0, units)
 		keyboard = [][]gotgbot.InlineKeyboardButton{
-			{{Text: "🔥 High Temperature (>30°C)", CallbackData: "alert_temp_high_30"}},
-			{{Text: "🥶 Low Temperature (<0°C)", CallbackData: "alert_temp_low_0"}},
+			{{Text: "🔥 High Temperature (>" + highLabel + ")", CallbackData: highData}},
+			{{Text: "🥶 Low Temperature (<" + lowLabel + ")", CallbackData: lowData}},
 			{{Text: "⚙️ Custom Threshold", CallbackData: "alert_temp_custom"}},
 		}
 	case "wind":
 		text = `🌬️ *Wind Speed Alert Setup*
 
 Choose alert condition:`
+		strongLabel, strongData := alertPreset("alert_wind_high", models.AlertWindSpeed, 50, units)
+		veryStrongLabel, veryStrongData := alertPreset("alert_wind_high", models.AlertWindSpeed, 80, units)
 		keyboard = [][]gotgbot.InlineKeyboardButton{
-			{{Text: "💨 Strong Wind (>50 km/h)", CallbackData: "alert_wind_high_50"}},
-			{{Text: "🌪️ Very Strong (>80 km/h)", CallbackData: "alert_wind_high_80"}},
+			{{Text: "💨 Strong Wind (>" + strongLabel + ")", CallbackData: strongData}},
+			{{Text: "🌪️ Very Strong (>" + veryStrongLabel + ")", CallbackData: veryStrongData}},
 			{{Text: "⚙️ Custom Threshold", CallbackData: "alert_wind_custom"}},
 		}
 	case "air":
@@ -2062,6 +2936,28 @@ Choose alert condition:`
 			{{Text: "🚨 Unhealthy AQI (>150)", CallbackData: "alert_air_unhealthy_150"}},
 			{{Text: "⚙️ Custom Threshold", CallbackData: "alert_air_custom"}},
 		}
+	case "pollen":
+		text = `🌼 *Pollen Alert Setup*
+
+Choose alert condition:`
+		highLabel, highData := alertPreset("alert_pollen_high", models.AlertPollen, weather.MaxPollenRisk-2, units)
+		veryHighLabel, veryHighData := alertPreset("alert_pollen_veryhigh", models.AlertPollen, weather.MaxPollenRisk-1, units)
+		keyboard = [][]gotgbot.InlineKeyboardButton{
+			{{Text: "🤧 High Pollen (>" + highLabel + ")", CallbackData: highData}},
+			{{Text: "🚨 Very High Pollen (>" + veryHighLabel + ")", CallbackData: veryHighData}},
+			{{Text: "⚙️ Custom Threshold", CallbackData: "alert_pollen_custom"}},
+		}
+	case "tide":
+		text = `🌊 *Tide Alert Setup*
+
+Choose alert condition:`
+		highLabel, highData := alertPreset("alert_tide_high", models.AlertTide, tideAlertHigh, units)
+		veryHighLabel, veryHighData := alertPreset("alert_tide_veryhigh", models.AlertTide, tideAlertVeryHigh, units)
+		keyboard = [][]gotgbot.InlineKeyboardButton{
+			{{Text: "🌊 High Tide (>" + highLabel + ")", CallbackData: highData}},
+			{{Text: "🚨 Very High Tide (>" + veryHighLabel + ")", CallbackData: veryHighData}},
+			{{Text: "⚙️ Custom Threshold", CallbackData: "alert_tide_custom"}},
+		}
 	}
 
 	_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
@@ -2074,24 +2970,43 @@ Choose alert condition:`
 	return err
 }
 
+// alertPreset rounds a metric preset threshold to a whole number in the user's
+// units and returns its label and callback data. The callback carries the value
+// in the user's units, as a threshold they entered.
+func alertPreset(prefix string, alertType models.AlertType, metric float64, units string) (string, string) {
+	value := math.Round(services.AlertValueFromMetric(alertType, metric, units))
+	label := services.FormatAlertValue(alertType, services.AlertValueToMetric(alertType, value, units), units)
+	return label, fmt.Sprintf("%s_%s", prefix, weather.FormatDecimal(value, 0))
+}
+
+// parseAlertThreshold reads a threshold entered in the user's units and
+// returns it in metric, or fallback, already metric, when none was given
+func parseAlertThreshold(threshold string, fallback float64, alertType models.AlertType, units string) float64 {
+	value, err := strconv.ParseFloat(threshold, 64)
+	if threshold == "" || err != nil {
+		return fallback
+	}
+	return services.AlertValueToMetric(alertType, value, units)
+}
+
 // handleSubscriptionCallback handles subscription-related button callbacks
 func (h *CommandHandler) handleSubscriptionCallback(bot *gotgbot.Bot, ctx *ext.Context, action, subAction string, params []string) error {
 	switch action {
 	case "subscribe":
 		switch subAction {
 		case "daily":
-			return h.createDailySubscription(bot, ctx)
+			return h.createDailySubscription(bot, ctx, params)
 		case "weekly":
-			return h.createWeeklySubscription(bot, ctx)
+			return h.createWeeklySubscription(bot, ctx, params)
 		case "alerts":
 			return h.createAlertsSubscription(bot, ctx)
+		case "immediate":
+			return h.enableImmediateAlerts(bot, ctx)
 		case "air":
 			return h.createAirQualitySubscription(bot, ctx)
 		}
 	case "unsubscribe":
 		return h.removeSubscription(bot, ctx, subAction)
-	case "edit":
-		return h.editSubscription(bot, ctx, subAction)
 	case "subscriptions":
 		if subAction == "list" {
 			return h.listUserSubscriptions(bot, ctx)
@@ -2104,20 +3019,51 @@ func (h *CommandHandler) handleSubscriptionCallback(bot *gotgbot.Bot, ctx *ext.C
 func (h *CommandHandler) handleAdminCallback(bot *gotgbot.Bot, ctx *ext.Context, action string, params []string) error {
 	switch action {
 	case "users":
+		// Buttons forwarded or left in a chat must not list users to others
+		if !h.requireAdmin(bot, ctx) {
+			return nil
+		}
+		view := "list"
+		if len(params) > 0 {
+			view = strings.Join(params, "_")
+		}
+		h.auditAdminAction(ctx, services.AuditUsersViewed, 0, map[string]any{"view": view})
 		if len(params) > 0 {
 			switch params[0] {
 			case "recent":
 				return h.showRecentUsers(bot, ctx)
 			case "roles":
 				return h.showUserRoles(bot, ctx)
+			case "page":
+				// admin_users_page_<page>_<filter>
+				if len(params) < 3 {
+					return nil
+				}
+				page, err := strconv.Atoi(params[1])
+				if err != nil || page < 0 {
+					return nil
+				}
+				return h.showUsersPage(bot, ctx, services.ParseUserListFilter(params[2]), page, true)
+			case "filter":
+				if len(params) < 2 {
+					return nil
+				}
+				return h.showUsersPage(bot, ctx, services.ParseUserListFilter(params[1]), 0, true)
 			}
 		}
-		return h.AdminListUsers(bot, ctx)
+		return h.showUsersPage(bot, ctx, services.UserFilterAll, 0, false)
 	case "stats":
+		if !h.requireAdmin(bot, ctx) {
+			return nil
+		}
 		if len(params) > 0 && params[0] == "detailed" {
 			return h.showDetailedStats(bot, ctx)
 		}
 		return h.AdminStats(bot, ctx)
+	case "deadletters":
+		return h.handleDeadLetterCallback(bot, ctx, params)
+	case "broadcast":
+		return h.handleBroadcastCallback(bot, ctx, params)
 	}
 	return nil
 }
@@ -2137,76 +3083,165 @@ func (h *CommandHandler) handleAirCallback(bot *gotgbot.Bot, ctx *ext.Context, a
 			}
 			return h.getAirQualityByCoords(bot, ctx, lat, lon)
 		}
+	case "alert":
+		locationName, err := h.callbackLabel(params)
+		if err != nil {
+			return h.sendLabelExpired(bot, ctx)
+		}
+		return h.showAlertOptions(bot, ctx, locationName)
 	default:
 		// Handle air quality for specific location from button callback
-		locationName := action
-		if len(params) > 0 {
-			locationName = strings.Join(append([]string{action}, params...), " ")
+		locationName, err := h.callbackLabel(append([]string{action}, params...))
+		if err != nil {
+			return h.sendLabelExpired(bot, ctx)
 		}
 		return h.getAirQualityData(bot, ctx, locationName)
 	}
 	return nil
 }
 
-// Helper functions for subscription handling
-func (h *CommandHandler) createDailySubscription(bot *gotgbot.Bot, ctx *ext.Context) error {
+// subscriptionTimeOptions are the notification times offered when subscribing,
+// in the user's timezone
+var subscriptionTimeOptions = []struct {
+	Emoji string
+	Time  string
+}{
+	{"🌅", "06:00"}, {"🌞", "08:00"}, {"🌤️", "10:00"}, {"☀️", "12:00"},
+	{"🌇", "18:00"}, {"🌙", "20:00"}, {"🌃", "22:00"},
+}
+
+// subscriptionTimeKeyboard offers subscriptionTimeOptions, two per row, each
+// button calling back with callbackData of its time. The current time, if
+// any, is ticked.
+func subscriptionTimeKeyboard(current string, callbackData func(timeOfDay string) string) [][]gotgbot.InlineKeyboardButton {
+	var keyboard [][]gotgbot.InlineKeyboardButton
+	for i, option := range subscriptionTimeOptions {
+		emoji := option.Emoji
+		if option.Time == current {
+			emoji = "✅"
+		}
+		button := gotgbot.InlineKeyboardButton{
+			Text:         fmt.Sprintf("%s %s", emoji, option.Time),
+			CallbackData: callbackData(option.Time),
+		}
+		if i%2 == 0 {
+			keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{button})
+		} else {
+			keyboard[len(keyboard)-1] = append(keyboard[len(keyboard)-1], button)
+		}
+	}
+	return keyboard
+}
+
+// chooseSubscriptionTime asks when a new subscription should be sent. The
+// chosen time comes back as the last part of "<callbackPrefix>_<HH:MM>".
+func (h *CommandHandler) chooseSubscriptionTime(bot *gotgbot.Bot, ctx *ext.Context, callbackPrefix string) error {
 	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+	message := h.services.Localization.T(context.Background(), userLang, "subscription_choose_time", h.subscriptionTimezone(ctx, userID))
 
-	// Get user's location
-	locationName, _, _, err := h.services.User.GetUserLocation(context.Background(), userID)
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, message, &gotgbot.SendMessageOpts{
+		ReplyMarkup: gotgbot.InlineKeyboardMarkup{
+			InlineKeyboard: subscriptionTimeKeyboard("", func(timeOfDay string) string {
+				return callbackPrefix + "_" + timeOfDay
+			}),
+		},
+	})
+	return err
+}
+
+// sendSubscriptionFailed reports a subscription that could not be created,
+// telling apart a time that is not HH:MM
+func (h *CommandHandler) sendSubscriptionFailed(bot *gotgbot.Bot, ctx *ext.Context, err error) error {
+	message := "❌ Failed to create subscription. Please try again."
+	if errors.Is(err, services.ErrInvalidTimeOfDay) {
+		userLang := h.getUserLanguage(ctx, ctx.EffectiveUser.Id)
+		message = h.services.Localization.T(context.Background(), userLang, "subscription_invalid_time")
+	}
+	_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, message, nil)
+	return sendErr
+}
+
+// Helper functions for subscription handling. Without a time in params the
+// user is asked for one first.
+func (h *CommandHandler) createDailySubscription(bot *gotgbot.Bot, ctx *ext.Context, params []string) error {
+	userID := ctx.EffectiveUser.Id
+
+	// Get the group's or the user's location
+	locationName, err := h.getChatLocation(ctx, userID)
 	if err != nil || locationName == "" {
-		userLang := h.getUserLanguage(context.Background(), userID)
+		userLang := h.getUserLanguage(ctx, userID)
 		errorMsg := h.services.Localization.T(context.Background(), userLang, "location_required_setlocation")
 		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
 		return sendErr
 	}
 
-	_, err = h.services.Subscription.CreateSubscription(
+	if len(params) == 0 {
+		return h.chooseSubscriptionTime(bot, ctx, "subscribe_daily")
+	}
+	timeOfDay := params[0]
+
+	subscription, err := h.services.Subscription.CreateSubscription(
 		context.Background(),
 		userID,
 		models.SubscriptionDaily,
 		models.FrequencyDaily,
-		"08:00",
+		timeOfDay,
 	)
 
 	if err != nil {
-		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Failed to create subscription. Please try again.", nil)
-		return sendErr
+		return h.sendSubscriptionFailed(bot, ctx, err)
 	}
 
-	_, err = bot.SendMessage(ctx.EffectiveChat.Id, "✅ Daily weather subscription created! You'll receive morning updates at 8:00 AM.", nil)
+	userLang := h.getUserLanguage(ctx, userID)
+	message := h.services.Localization.T(context.Background(), userLang, "subscription_daily_created",
+		timeOfDay, h.subscriptionTimezone(ctx, userID)) + h.deliverToChat(ctx, subscription, userLang)
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, message, nil)
 	return err
 }
 
-func (h *CommandHandler) createWeeklySubscription(bot *gotgbot.Bot, ctx *ext.Context) error {
+func (h *CommandHandler) createWeeklySubscription(bot *gotgbot.Bot, ctx *ext.Context, params []string) error {
 	userID := ctx.EffectiveUser.Id
 
-	// Get user's location
-	locationName, _, _, err := h.services.User.GetUserLocation(context.Background(), userID)
+	// Get the group's or the user's location
+	locationName, err := h.getChatLocation(ctx, userID)
 	if err != nil || locationName == "" {
-		userLang := h.getUserLanguage(context.Background(), userID)
+		userLang := h.getUserLanguage(ctx, userID)
 		errorMsg := h.services.Localization.T(context.Background(), userLang, "location_required_setlocation")
 		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
 		return sendErr
 	}
 
-	_, err = h.services.Subscription.CreateSubscription(
-		context.Background(),
-		userID,
-		models.SubscriptionWeekly,
-		models.FrequencyWeekly,
-		"09:00",
-	)
+	if len(params) == 0 {
+		return h.chooseSubscriptionTime(bot, ctx, "subscribe_weekly")
+	}
+	timeOfDay := params[0]
 
+	user, err := h.getUser(ctx, userID)
 	if err != nil {
 		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Failed to create subscription. Please try again.", nil)
 		return sendErr
 	}
 
-	_, err = bot.SendMessage(ctx.EffectiveChat.Id, "✅ Weekly weather subscription created! You'll receive updates every Sunday at 9:00 AM.", nil)
+	// The digest goes out on the first day of the user's week
+	subscription, err := h.services.Subscription.CreateWeeklySubscription(context.Background(), user, timeOfDay)
+	if err != nil {
+		return h.sendSubscriptionFailed(bot, ctx, err)
+	}
+
+	userLang := h.getUserLanguage(ctx, userID)
+	message := h.services.Localization.T(context.Background(), userLang, "subscription_weekly_created",
+		h.weekdayName(userLang, subscription.WeeklySendDay()), timeOfDay, h.subscriptionTimezone(ctx, userID)) +
+		h.deliverToChat(ctx, subscription, userLang)
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, message, nil)
 	return err
 }
 
+// weekdayName returns the localized name of day
+func (h *CommandHandler) weekdayName(userLang string, day time.Weekday) string {
+	return h.services.Localization.T(context.Background(), userLang, "weekday_"+strings.ToLower(day.String()))
+}
+
 func (h *CommandHandler) removeSubscription(bot *gotgbot.Bot, ctx *ext.Context, subscriptionID string) error {
 	userID := ctx.EffectiveUser.Id
 
@@ -2227,81 +3262,78 @@ func (h *CommandHandler) removeSubscription(bot *gotgbot.Bot, ctx *ext.Context,
 	return err
 }
 
-func (h *CommandHandler) editSubscription(bot *gotgbot.Bot, ctx *ext.Context, subscriptionID string) error {
-	_, err := bot.SendMessage(ctx.EffectiveChat.Id, "⚙️ Subscription editing feature coming soon!", nil)
-	return err
-}
-
 func (h *CommandHandler) getAirQualityData(bot *gotgbot.Bot, ctx *ext.Context, locationName string) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	// Get coordinates first for air quality
-	locationData, err := h.services.Weather.GeocodeLocation(context.Background(), locationName)
+	locationData, err := h.services.Weather.GeocodeLocation(h.weatherContext(ctx), locationName)
 	if err != nil {
-		errorMsg := h.services.Localization.T(context.Background(), userLang, "error_location_not_found", locationName)
-		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
-		return err
+		return h.sendWeatherError(bot, ctx, locationName, err, "error_location_not_found", locationName)
 	}
 
-	airData, err := h.services.Weather.GetAirQuality(context.Background(), locationData.Latitude, locationData.Longitude)
+	airData, err := h.services.Weather.GetAirQuality(h.weatherContext(ctx), locationData.Latitude, locationData.Longitude)
 	if err != nil {
-		errorMsg := h.services.Localization.T(context.Background(), userLang, "air_quality_error")
-		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
-		return err
+		return h.sendWeatherError(bot, ctx, locationName, err, "air_quality_error")
 	}
 
-	airText := h.formatAirQualityMessage(airData, userLang)
+	zone := h.getUserTimezone(ctx, userID)
+	airText := h.renderForUser(ctx, userID, func(language string) string {
+		return h.render(render.AirQuality, language, h.airQualityView(airData, language, zone))
+	}, h.airQualitySummary(airData))
 
 	currentWeatherBtn := h.services.Localization.T(context.Background(), userLang, "button_current_weather")
 	forecastBtn := h.services.Localization.T(context.Background(), userLang, "button_forecast")
+	aqiForecastBtn := h.services.Localization.T(context.Background(), userLang, "button_aqi_forecast")
 	setAlertBtn := h.services.Localization.T(context.Background(), userLang, "button_set_alert")
 
 	keyboard := [][]gotgbot.InlineKeyboardButton{
-		{{Text: currentWeatherBtn, CallbackData: fmt.Sprintf("weather_%s", locationName)}},
-		{{Text: forecastBtn, CallbackData: fmt.Sprintf("forecast_%s", locationName)}},
-		{{Text: setAlertBtn, CallbackData: fmt.Sprintf("alert_%s", locationName)}},
+		{{Text: currentWeatherBtn, CallbackData: h.locationCallback("weather", locationName)}},
+		{{Text: forecastBtn, CallbackData: h.locationCallback("forecast", locationName)}},
+		{{Text: aqiForecastBtn, CallbackData: h.locationCallback("aqiforecast", locationName)}},
+		{{Text: setAlertBtn, CallbackData: h.locationCallback("alert", locationName)}},
 	}
 
-	_, err = bot.SendMessage(ctx.EffectiveChat.Id, airText, &gotgbot.SendMessageOpts{
+	return h.sendOrEdit(bot, ctx, airText, &gotgbot.SendMessageOpts{
 		ParseMode: "Markdown",
 		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
 			InlineKeyboard: keyboard,
 		},
 	})
-	return err
 }
 
 func (h *CommandHandler) getAirQualityByCoords(bot *gotgbot.Bot, ctx *ext.Context, lat, lon float64) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
-	airData, err := h.services.Weather.GetAirQuality(context.Background(), lat, lon)
+	airData, err := h.services.Weather.GetAirQuality(h.weatherContext(ctx), lat, lon)
 	if err != nil {
-		errorMsg := h.services.Localization.T(context.Background(), userLang, "air_quality_error")
-		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
-		return err
+		return h.sendWeatherError(bot, ctx, "", err, "air_quality_error")
 	}
 
-	airText := h.formatAirQualityMessage(airData, userLang)
+	zone := h.getUserTimezone(ctx, userID)
+	airText := h.renderForUser(ctx, userID, func(language string) string {
+		return h.render(render.AirQuality, language, h.airQualityView(airData, language, zone))
+	}, h.airQualitySummary(airData))
 
 	currentWeatherBtn := h.services.Localization.T(context.Background(), userLang, "button_current_weather")
 	forecastBtn := h.services.Localization.T(context.Background(), userLang, "button_forecast")
+	aqiForecastBtn := h.services.Localization.T(context.Background(), userLang, "button_aqi_forecast")
 	setAlertBtn := h.services.Localization.T(context.Background(), userLang, "button_set_alert")
 
 	keyboard := [][]gotgbot.InlineKeyboardButton{
 		{{Text: currentWeatherBtn, CallbackData: fmt.Sprintf("weather_coords_%.4f_%.4f", lat, lon)}},
 		{{Text: forecastBtn, CallbackData: fmt.Sprintf("forecast_coords_%.4f_%.4f", lat, lon)}},
+		{{Text: aqiForecastBtn, CallbackData: fmt.Sprintf("aqiforecast_coords_%.4f_%.4f", lat, lon)}},
 		{{Text: setAlertBtn, CallbackData: fmt.Sprintf("alert_coords_%.4f_%.4f", lat, lon)}},
 	}
 
-	_, err = bot.SendMessage(ctx.EffectiveChat.Id, airText, &gotgbot.SendMessageOpts{
+	return h.sendOrEdit(bot, ctx, airText, &gotgbot.SendMessageOpts{
 		ParseMode: "Markdown",
 		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
 			InlineKeyboard: keyboard,
 		},
 	})
-	return err
 }
 
 // Additional subscription handlers
@@ -2309,9 +3341,9 @@ func (h *CommandHandler) createAlertsSubscription(bot *gotgbot.Bot, ctx *ext.Con
 	userID := ctx.EffectiveUser.Id
 
 	// Get user's location
-	locationName, _, _, err := h.services.User.GetUserLocation(context.Background(), userID)
+	locationName, _, _, err := h.getUserLocation(ctx, userID)
 	if err != nil || locationName == "" {
-		userLang := h.getUserLanguage(context.Background(), userID)
+		userLang := h.getUserLanguage(ctx, userID)
 		errorMsg := h.services.Localization.T(context.Background(), userLang, "location_required_setlocation")
 		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
 		return sendErr
@@ -2334,13 +3366,38 @@ func (h *CommandHandler) createAlertsSubscription(bot *gotgbot.Bot, ctx *ext.Con
 	return err
 }
 
+// enableImmediateAlerts turns on the alerts subscription from the prompt shown
+// after creating an alert, reusing a previously cancelled subscription
+func (h *CommandHandler) enableImmediateAlerts(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	_, err := h.services.Subscription.EnsureSubscription(
+		context.Background(),
+		userID,
+		models.SubscriptionAlerts,
+		models.FrequencyDaily,
+		"12:00",
+	)
+	if err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to enable immediate alert notifications")
+		errorMsg := h.services.Localization.T(context.Background(), userLang, "alert_notify_immediately_failed")
+		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
+		return sendErr
+	}
+
+	confirmation := h.services.Localization.T(context.Background(), userLang, "alert_notify_immediately_enabled")
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, confirmation, nil)
+	return err
+}
+
 func (h *CommandHandler) createAirQualitySubscription(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
 
 	// Get user's location
-	locationName, _, _, err := h.services.User.GetUserLocation(context.Background(), userID)
+	locationName, _, _, err := h.getUserLocation(ctx, userID)
 	if err != nil || locationName == "" {
-		userLang := h.getUserLanguage(context.Background(), userID)
+		userLang := h.getUserLanguage(ctx, userID)
 		errorMsg := h.services.Localization.T(context.Background(), userLang, "location_required_setlocation")
 		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
 		return sendErr
@@ -2379,12 +3436,13 @@ func (h *CommandHandler) listUserSubscriptions(bot *gotgbot.Bot, ctx *ext.Contex
 
 	var text strings.Builder
 	text.WriteString("📋 *Your Active Subscriptions:*\n\n")
+	timezone := h.subscriptionTimezone(ctx, userID)
 
 	for _, sub := range subscriptions {
 		fmt.Fprintf(&text, "• **%s** - %s at %s\n",
 			sub.SubscriptionType.String(),
 			sub.Frequency.String(),
-			sub.TimeOfDay)
+			subscriptionTimeLabel(sub.TimeOfDay, timezone))
 	}
 
 	keyboard := [][]gotgbot.InlineKeyboardButton{
@@ -2404,7 +3462,7 @@ func (h *CommandHandler) listUserSubscriptions(bot *gotgbot.Bot, ctx *ext.Contex
 // Additional admin handlers
 func (h *CommandHandler) showRecentUsers(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	stats, err := h.services.User.GetUserStatistics(context.Background())
 	if err != nil {
@@ -2432,7 +3490,7 @@ func (h *CommandHandler) showRecentUsers(bot *gotgbot.Bot, ctx *ext.Context) err
 
 func (h *CommandHandler) showUserRoles(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	stats, err := h.services.User.GetUserStatistics(context.Background())
 	if err != nil {
@@ -2470,7 +3528,7 @@ func (h *CommandHandler) showUserRoles(bot *gotgbot.Bot, ctx *ext.Context) error
 
 func (h *CommandHandler) showDetailedStats(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	systemStats, err := h.services.User.GetSystemStats(context.Background())
 	if err != nil {
@@ -2483,6 +3541,7 @@ func (h *CommandHandler) showDetailedStats(bot *gotgbot.Bot, ctx *ext.Context) e
 	usersTotal := h.services.Localization.T(context.Background(), userLang, "admin_detailed_stats_users_total", systemStats.TotalUsers)
 	usersActive := h.services.Localization.T(context.Background(), userLang, "admin_detailed_stats_users_active", systemStats.ActiveUsers)
 	usersNew := h.services.Localization.T(context.Background(), userLang, "admin_detailed_stats_users_new", systemStats.NewUsers24h)
+	usersSeen := h.services.Localization.T(context.Background(), userLang, "admin_detailed_stats_users_seen", systemStats.DailyActiveUsers, systemStats.WeeklyActiveUsers, systemStats.MonthlyActiveUsers)
 	locationsSection := h.services.Localization.T(context.Background(), userLang, "admin_detailed_stats_locations_section")
 	locationsCount := h.services.Localization.T(context.Background(), userLang, "admin_detailed_stats_locations_count", systemStats.UsersWithLocation)
 	subsSection := h.services.Localization.T(context.Background(), userLang, "admin_detailed_stats_subscriptions_section")
@@ -2495,8 +3554,8 @@ func (h *CommandHandler) showDetailedStats(bot *gotgbot.Bot, ctx *ext.Context) e
 	avgRespTime := h.services.Localization.T(context.Background(), userLang, "admin_detailed_stats_avg_response_time", systemStats.AvgResponseTime)
 	uptime := h.services.Localization.T(context.Background(), userLang, "admin_detailed_stats_uptime", systemStats.Uptime)
 
-	text := fmt.Sprintf("%s\n\n%s\n%s\n%s\n%s\n\n%s\n%s\n\n%s\n%s\n%s\n\n%s\n%s\n%s\n%s\n%s\n%s",
-		title, usersSection, usersTotal, usersActive, usersNew,
+	text := fmt.Sprintf("%s\n\n%s\n%s\n%s\n%s\n%s\n\n%s\n%s\n\n%s\n%s\n%s\n\n%s\n%s\n%s\n%s\n%s\n%s",
+		title, usersSection, usersTotal, usersActive, usersNew, usersSeen,
 		locationsSection, locationsCount,
 		subsSection, subsActive, alertsConfigured,
 		perfSection, messagesSent, weatherReqs, cacheHitRate, avgRespTime, uptime)
@@ -2538,9 +3597,157 @@ func (h *CommandHandler) setUserLanguage(bot *gotgbot.Bot, ctx *ext.Context, lan
 	return err
 }
 
+// handleSecondaryLanguageSettings offers the languages that can be shown below
+// the main one in weather reports and digests, plus a button to turn it off
+func (h *CommandHandler) handleSecondaryLanguageSettings(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := internal.DefaultLanguage
+	secondary := ""
+	if user, err := h.getUser(ctx, userID); err == nil && user != nil {
+		if user.Language != "" {
+			userLang = user.Language
+		}
+		secondary = user.SecondaryLanguage
+	}
+
+	currentText := h.services.Localization.T(context.Background(), userLang, "secondary_language_off_label")
+	if lang, ok := h.services.Localization.GetLanguageByCode(secondary); ok {
+		currentText = fmt.Sprintf("%s %s", lang.Flag, lang.Name)
+	}
+	text := fmt.Sprintf("%s\n\n%s",
+		h.services.Localization.T(context.Background(), userLang, "secondary_language_select"),
+		h.services.Localization.T(context.Background(), userLang, "secondary_language_current", currentText))
+
+	supportedLanguages := h.services.Localization.GetSupportedLanguages()
+	codes := make([]string, 0, len(supportedLanguages))
+	for code := range supportedLanguages {
+		if code != userLang {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+
+	var keyboard [][]gotgbot.InlineKeyboardButton
+	for _, code := range codes {
+		lang := supportedLanguages[code]
+		label := fmt.Sprintf("%s %s", lang.Flag, lang.Name)
+		if code == secondary {
+			label += " ✅"
+		}
+		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
+			{Text: label, CallbackData: fmt.Sprintf("settings_secondlang_set_%s", code)},
+		})
+	}
+	keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
+		{Text: h.services.Localization.T(context.Background(), userLang, "secondary_language_off_btn"), CallbackData: "settings_secondlang_set_off"},
+	})
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ParseMode: "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
+			InlineKeyboard: keyboard,
+		},
+	})
+
+	return err
+}
+
+// setSecondaryLanguage stores the user's one secondary language, or clears it for "off"
+func (h *CommandHandler) setSecondaryLanguage(bot *gotgbot.Bot, ctx *ext.Context, language string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	if language == "off" {
+		language = ""
+	} else if language == userLang || !h.services.Localization.IsLanguageSupported(language) {
+		message := h.services.Localization.T(context.Background(), userLang, "secondary_language_invalid")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, message, nil)
+		return err
+	}
+
+	if err := h.services.User.UpdateUserSecondaryLanguage(context.Background(), userID, language); err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to update secondary language")
+		message := h.services.Localization.T(context.Background(), userLang, "secondary_language_update_failed")
+		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, message, nil)
+		return sendErr
+	}
+
+	message := h.services.Localization.T(context.Background(), userLang, "secondary_language_disabled")
+	if language != "" {
+		lang, _ := h.services.Localization.GetLanguageByCode(language)
+		message = h.services.Localization.T(context.Background(), userLang, "secondary_language_enabled", fmt.Sprintf("%s %s", lang.Flag, lang.Name))
+	}
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, message, nil)
+	return err
+}
+
+// handleWeekStartSettings shows the first day of the user's week and lets them
+// pin it to Monday or Sunday or derive it from their location and language
+func (h *CommandHandler) handleWeekStartSettings(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := internal.DefaultLanguage
+	var user *models.User
+	if u, err := h.getUser(ctx, userID); err == nil && u != nil {
+		user = u
+		if u.Language != "" {
+			userLang = u.Language
+		}
+	}
+
+	current := h.weekdayName(userLang, services.FirstDayOfWeek(user))
+	if user == nil || user.WeekStart == "" {
+		current = h.services.Localization.T(context.Background(), userLang, "week_start_auto_label", current)
+	}
+	text := fmt.Sprintf("%s\n\n%s",
+		h.services.Localization.T(context.Background(), userLang, "week_start_select"),
+		h.services.Localization.T(context.Background(), userLang, "week_start_current", current))
+
+	keyboard := [][]gotgbot.InlineKeyboardButton{
+		{{Text: h.services.Localization.T(context.Background(), userLang, "week_start_auto_btn"), CallbackData: "settings_weekstart_set_auto"}},
+		{{Text: h.weekdayName(userLang, time.Monday), CallbackData: "settings_weekstart_set_" + models.WeekStartMonday}},
+		{{Text: h.weekdayName(userLang, time.Sunday), CallbackData: "settings_weekstart_set_" + models.WeekStartSunday}},
+	}
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ParseMode: "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
+			InlineKeyboard: keyboard,
+		},
+	})
+
+	return err
+}
+
+// setWeekStart stores the first day of the user's week, or clears it for "auto"
+func (h *CommandHandler) setWeekStart(bot *gotgbot.Bot, ctx *ext.Context, weekStart string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	if weekStart == "auto" {
+		weekStart = ""
+	}
+
+	if err := h.services.User.UpdateUserWeekStart(context.Background(), userID, weekStart); err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to update week start")
+		message := h.services.Localization.T(context.Background(), userLang, "week_start_update_failed")
+		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, message, nil)
+		return sendErr
+	}
+
+	// Derive the resulting day the same way the digest does
+	firstDay := time.Monday
+	if user, err := h.getUser(ctx, userID); err == nil {
+		firstDay = services.FirstDayOfWeek(user)
+	}
+	message := h.services.Localization.T(context.Background(), userLang, "week_start_updated", h.weekdayName(userLang, firstDay))
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, message, nil)
+	return err
+}
+
 func (h *CommandHandler) setUserUnits(bot *gotgbot.Bot, ctx *ext.Context, units string) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	err := h.services.User.UpdateUserSettings(context.Background(), userID, map[string]interface{}{
 		"units": units,
@@ -2561,7 +3768,7 @@ func (h *CommandHandler) setUserUnits(bot *gotgbot.Bot, ctx *ext.Context, units
 
 func (h *CommandHandler) setUserTimezone(bot *gotgbot.Bot, ctx *ext.Context, timezone string) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	err := h.services.User.UpdateUserSettings(context.Background(), userID, map[string]interface{}{
 		"timezone": timezone,
@@ -2596,13 +3803,14 @@ func (h *CommandHandler) handleNotificationSettings(bot *gotgbot.Bot, ctx *ext.C
 		message += "You don't have any active notifications.\n\n"
 	} else {
 		message += "*Your Active Notifications:*\n"
+		timezone := h.subscriptionTimezone(ctx, userID)
 		for i, sub := range subscriptions {
 			status := "✅"
 			if !sub.IsActive {
 				status = "❌"
 			}
 			message += fmt.Sprintf("%d. %s %s - %s at %s\n",
-				i+1, status, sub.SubscriptionType.String(), sub.Frequency.String(), sub.TimeOfDay)
+				i+1, status, sub.SubscriptionType.String(), sub.Frequency.String(), subscriptionTimeLabel(sub.TimeOfDay, timezone))
 		}
 		message += "\n"
 	}
@@ -2636,6 +3844,47 @@ func (h *CommandHandler) handleNotificationSettings(bot *gotgbot.Bot, ctx *ext.C
 		)
 	}
 
+	// Activity tracking powers digest send-time suggestions and can be turned off
+	if user, err := h.getUser(ctx, userID); err == nil {
+		userLang := h.getUserLanguage(ctx, userID)
+		trackingBtn := h.services.Localization.T(context.Background(), userLang, "activity_tracking_btn_disable")
+		trackingData := "notifications_tracking_off"
+		if user.ActivityTrackingDisabled {
+			trackingBtn = h.services.Localization.T(context.Background(), userLang, "activity_tracking_btn_enable")
+			trackingData = "notifications_tracking_on"
+		}
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard,
+			[]gotgbot.InlineKeyboardButton{
+				{Text: trackingBtn, CallbackData: trackingData},
+			},
+		)
+
+		// How digests and extreme alerts reach the user
+		quietBtn := h.services.Localization.T(context.Background(), userLang, "quiet_digests_btn_enable")
+		quietData := "notifications_quiet_on"
+		if user.QuietDigests {
+			quietBtn = h.services.Localization.T(context.Background(), userLang, "quiet_digests_btn_disable")
+			quietData = "notifications_quiet_off"
+		}
+		escalationBtn := h.services.Localization.T(context.Background(), userLang, "alert_escalation_btn_disable")
+		escalationData := "notifications_escalation_off"
+		if user.AlertEscalationDisabled {
+			escalationBtn = h.services.Localization.T(context.Background(), userLang, "alert_escalation_btn_enable")
+			escalationData = "notifications_escalation_on"
+		}
+		unusualBtn := h.services.Localization.T(context.Background(), userLang, "unusual_notes_btn_enable")
+		unusualData := "notifications_unusual_on"
+		if user.UnusualWeatherNotes {
+			unusualBtn = h.services.Localization.T(context.Background(), userLang, "unusual_notes_btn_disable")
+			unusualData = "notifications_unusual_off"
+		}
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard,
+			[]gotgbot.InlineKeyboardButton{{Text: quietBtn, CallbackData: quietData}},
+			[]gotgbot.InlineKeyboardButton{{Text: escalationBtn, CallbackData: escalationData}},
+			[]gotgbot.InlineKeyboardButton{{Text: unusualBtn, CallbackData: unusualData}},
+		)
+	}
+
 	// Add back button
 	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard,
 		[]gotgbot.InlineKeyboardButton{
@@ -2653,22 +3902,37 @@ func (h *CommandHandler) handleNotificationSettings(bot *gotgbot.Bot, ctx *ext.C
 
 func (h *CommandHandler) handleLocationSettings(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
+
+	user, err := h.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
 
 	// Get user's current location
-	locationName, _, _, err := h.services.User.GetUserLocation(context.Background(), userID)
+	locationName := user.LocationName
 
 	var locationText string
 	var statusText string
-	if err != nil || locationName == "" {
+	if locationName == "" {
 		locationText = h.services.Localization.T(context.Background(), userLang, "settings_not_set")
 		statusText = h.services.Localization.T(context.Background(), userLang, "location_settings_not_set_help")
 	} else {
 		// Location name already includes coordinates from reverse geocoding
-		locationText = locationName
+		locationText = markdown.Escape(h.formatLocationText(context.Background(), userLang, user))
 		statusText = h.services.Localization.T(context.Background(), userLang, "location_settings_is_set")
 	}
 
+	privacyLabel := h.services.Localization.T(context.Background(), userLang, "location_privacy_label")
+	privacyText := h.services.Localization.T(context.Background(), userLang, "location_privacy_precise")
+	privacyBtn := h.services.Localization.T(context.Background(), userLang, "location_privacy_btn_city")
+	privacyCallback := "location_privacy_city"
+	if user.LocationCityLevel {
+		privacyText = h.services.Localization.T(context.Background(), userLang, "location_privacy_city")
+		privacyBtn = h.services.Localization.T(context.Background(), userLang, "location_privacy_btn_precise")
+		privacyCallback = "location_privacy_precise"
+	}
+
 	title := h.services.Localization.T(context.Background(), userLang, "location_settings_title")
 	currentLabel := h.services.Localization.T(context.Background(), userLang, "location_settings_current")
 	optionsLabel := h.services.Localization.T(context.Background(), userLang, "location_settings_options")
@@ -2682,6 +3946,7 @@ func (h *CommandHandler) handleLocationSettings(bot *gotgbot.Bot, ctx *ext.Conte
 %s
 
 %s
+%s: %s
 
 *%s:*
 • %s
@@ -2691,6 +3956,7 @@ func (h *CommandHandler) handleLocationSettings(bot *gotgbot.Bot, ctx *ext.Conte
 		currentLabel,
 		locationText,
 		statusText,
+		privacyLabel, privacyText,
 		optionsLabel,
 		optionName,
 		optionGPS,
@@ -2711,6 +3977,10 @@ func (h *CommandHandler) handleLocationSettings(bot *gotgbot.Bot, ctx *ext.Conte
 		})
 	}
 
+	keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
+		{Text: privacyBtn, CallbackData: privacyCallback},
+	})
+
 	backBtn := h.services.Localization.T(context.Background(), userLang, "location_settings_btn_back")
 	keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
 		{Text: backBtn, CallbackData: "settings_main"},
@@ -2726,13 +3996,69 @@ func (h *CommandHandler) handleLocationSettings(bot *gotgbot.Bot, ctx *ext.Conte
 	return err
 }
 
-func (h *CommandHandler) handleExportSettings(bot *gotgbot.Bot, ctx *ext.Context) error {
-	keyboard := gotgbot.InlineKeyboardMarkup{
-		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
-			{
-				{Text: "🌤️ Weather Data", CallbackData: "export_weather"},
-				{Text: "⚠️ Alerts", CallbackData: "export_alerts"},
-			},
+// setLocationPrivacy switches the user between precise and city-level location storage
+func (h *CommandHandler) setLocationPrivacy(bot *gotgbot.Bot, ctx *ext.Context, cityLevel bool) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	if err := h.services.User.SetLocationPrivacy(context.Background(), userID, cityLevel); err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Bool("city_level", cityLevel).Msg("Failed to update location privacy")
+		errorText := h.services.Localization.T(context.Background(), userLang, "location_privacy_failed")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorText, nil)
+		return err
+	}
+
+	key := "location_privacy_precise_enabled"
+	if cityLevel {
+		key = "location_privacy_city_enabled"
+	}
+	backBtn := h.services.Localization.T(context.Background(), userLang, "location_settings_btn_back")
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, key), &gotgbot.SendMessageOpts{
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
+			InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+				{{Text: backBtn, CallbackData: "settings_location"}},
+			},
+		},
+	})
+	return err
+}
+
+// formatLocationText returns the user's location name, marked when only the city centroid is stored
+func (h *CommandHandler) formatLocationText(ctx context.Context, language string, user *models.User) string {
+	if !user.LocationApproximate {
+		return user.LocationName
+	}
+	return fmt.Sprintf("%s %s", user.LocationName, h.services.Localization.T(ctx, language, "location_approximate_suffix"))
+}
+
+// sendLocationSaved sends text, the confirmation of a saved location, or reports the stored
+// city instead when it was coarsened.
+func (h *CommandHandler) sendLocationSaved(bot *gotgbot.Bot, ctx *ext.Context, text string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+	if user, err := h.getUser(ctx, userID); err == nil && user.LocationApproximate {
+		suffix := h.services.Localization.T(context.Background(), userLang, "location_approximate_suffix")
+		text = h.services.Localization.T(context.Background(), userLang, "location_saved_approximate", markdown.EscapeBold(user.LocationName), suffix)
+	}
+
+	opts := &gotgbot.SendMessageOpts{ParseMode: "Markdown"}
+	// A first location completes onboarding: offer the recommended setup
+	if markup := h.setupMarkup(userID, userLang); markup != nil {
+		opts.ReplyMarkup = markup
+	}
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, opts)
+	return err
+}
+
+func (h *CommandHandler) handleExportSettings(bot *gotgbot.Bot, ctx *ext.Context) error {
+	keyboard := gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+			{
+				{Text: "🌤️ Weather Data", CallbackData: "export_weather"},
+				{Text: "⚠️ Alerts", CallbackData: "export_alerts"},
+			},
 			{
 				{Text: "📋 Subscriptions", CallbackData: "export_subscriptions"},
 				{Text: "📦 All Data", CallbackData: "export_all"},
@@ -2745,12 +4071,20 @@ func (h *CommandHandler) handleExportSettings(bot *gotgbot.Bot, ctx *ext.Context
 
 	text := "📊 *Data Export*\n\n" +
 		"Choose what data you want to export:\n\n" +
-		"🌤️ *Weather Data* - Last 30 days of weather records\n" +
+		"🌤️ *Weather Data* - Weather records of a period you choose\n" +
 		"⚠️ *Alerts* - Your alert configurations and triggered alerts\n" +
 		"📋 *Subscriptions* - Your notification preferences\n" +
 		"📦 *All Data* - Complete export of all your data\n\n" +
 		"Exported data will be sent to you as a file."
 
+	// The stored location is part of every export, so show what will be included
+	userID := ctx.EffectiveUser.Id
+	if user, err := h.getUser(ctx, userID); err == nil && user.HasLocation() {
+		userLang := h.getUserLanguage(ctx, userID)
+		locationText := markdown.Escape(h.formatLocationText(context.Background(), userLang, user))
+		text += "\n\n" + h.services.Localization.T(context.Background(), userLang, "export_stored_location", locationText)
+	}
+
 	_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
 		ParseMode:   "Markdown",
 		ReplyMarkup: keyboard,
@@ -2760,13 +4094,96 @@ func (h *CommandHandler) handleExportSettings(bot *gotgbot.Bot, ctx *ext.Context
 }
 
 // Alert handlers
+
+// sendAlertCreated confirms a new alert and, for users who get routine alerts
+// only in their daily digest, offers to deliver them immediately as well
+func (h *CommandHandler) sendAlertCreated(bot *gotgbot.Bot, ctx *ext.Context, message string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	var opts *gotgbot.SendMessageOpts
+	if markup := h.immediateAlertsMarkup(context.Background(), userID, userLang); markup != nil {
+		opts = &gotgbot.SendMessageOpts{ReplyMarkup: *markup}
+	}
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, message, opts)
+	return err
+}
+
+// sendIfAlertExists tells the user when they already have the alert they are
+// creating, offering to change its threshold instead, and reports whether it
+// did. A failed check lets the alert be created.
+func (h *CommandHandler) sendIfAlertExists(bot *gotgbot.Bot, ctx *ext.Context, alertType models.AlertType, condition services.AlertCondition) (bool, error) {
+	userID := ctx.EffectiveUser.Id
+
+	exists, err := h.services.Alert.AlertExists(context.Background(), userID, alertType, condition.Operator, condition.Value)
+	if err != nil {
+		h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to check for a duplicate alert")
+		return false, nil
+	}
+	if !exists {
+		return false, nil
+	}
+
+	userLang := h.getUserLanguage(ctx, userID)
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "alert_already_exists"), &gotgbot.SendMessageOpts{
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+			{{Text: h.services.Localization.T(context.Background(), userLang, "alert_already_exists_btn"), CallbackData: "alerts_list"}},
+		}},
+	})
+	return true, err
+}
+
+// sendAlertCreateFailed tells the user their alert was not created: at the
+// alert limit with a button to their alerts, so they can make room, and
+// otherwise with the message given
+func (h *CommandHandler) sendAlertCreateFailed(bot *gotgbot.Bot, ctx *ext.Context, err error, message string) error {
+	if !errors.Is(err, services.ErrAlertLimitReached) {
+		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, message, nil)
+		return sendErr
+	}
+	return h.sendAlertLimitReached(bot, ctx, "alert_limit_reached")
+}
+
+// sendAlertLimitReached sends the message under key, which takes the alert
+// limit, with a button to the user's alerts
+func (h *CommandHandler) sendAlertLimitReached(bot *gotgbot.Bot, ctx *ext.Context, key string) error {
+	userLang := h.getUserLanguage(ctx, ctx.EffectiveUser.Id)
+	_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.TN(context.Background(), userLang, key, int64(h.services.Alert.MaxAlertsPerUser())), &gotgbot.SendMessageOpts{
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+			{{Text: h.services.Localization.T(context.Background(), userLang, "alert_limit_reached_btn"), CallbackData: "alerts_list"}},
+		}},
+	})
+	return sendErr
+}
+
+// immediateAlertsMarkup returns the "also notify me immediately" button, or nil
+// when the user's alerts are already sent as they trigger
+func (h *CommandHandler) immediateAlertsMarkup(ctx context.Context, userID int64, userLang string) *gotgbot.InlineKeyboardMarkup {
+	immediate, err := h.services.Subscription.ImmediateAlertDelivery(ctx, userID)
+	if err != nil {
+		h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to check alert delivery")
+		return nil
+	}
+	if immediate {
+		return nil
+	}
+
+	text := h.services.Localization.T(ctx, userLang, "alert_notify_immediately_btn")
+	return &gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+			{{Text: text, CallbackData: "subscribe_immediate"}},
+		},
+	}
+}
+
 func (h *CommandHandler) handleTemperatureAlert(bot *gotgbot.Bot, ctx *ext.Context, condition, threshold string) error {
 	userID := ctx.EffectiveUser.Id
 
 	// Get user's location
-	locationName, _, _, err := h.services.User.GetUserLocation(context.Background(), userID)
+	locationName, _, _, err := h.getUserLocation(ctx, userID)
 	if err != nil || locationName == "" {
-		userLang := h.getUserLanguage(context.Background(), userID)
+		userLang := h.getUserLanguage(ctx, userID)
 		errorMsg := h.services.Localization.T(context.Background(), userLang, "location_required_setlocation")
 		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
 		return sendErr
@@ -2777,34 +4194,18 @@ func (h *CommandHandler) handleTemperatureAlert(bot *gotgbot.Bot, ctx *ext.Conte
 	var operator string
 	var message string
 
+	// Thresholds are entered in the user's units and stored in metric
+	units := h.getUserUnits(ctx, userID)
+
 	switch condition {
 	case "high":
-		thresholdValue = 30.0 // Default high temperature
-		if threshold != "" {
-			if val, err := strconv.ParseFloat(threshold, 64); err == nil {
-				thresholdValue = val
-			}
-		}
+		thresholdValue = parseAlertThreshold(threshold, 30.0, models.AlertTemperature, units) // Default high temperature
 		operator = "gt"
-		message = fmt.Sprintf("✅ High temperature alert created! You'll be notified when temperature exceeds %.1f°C.", thresholdValue)
+		message = "✅ High temperature alert created! You'll be notified when temperature exceeds " + weather.FormatTemp(thresholdValue, units) + "."
 	case "low":
-		thresholdValue = 0.0 // Default low temperature
-		if threshold != "" {
-			if val, err := strconv.ParseFloat(threshold, 64); err == nil {
-				thresholdValue = val
-			}
-		}
+		thresholdValue = parseAlertThreshold(threshold, 0.0, models.AlertTemperature, units) // Default low temperature
 		operator = "lt"
-		message = fmt.Sprintf("✅ Low temperature alert created! You'll be notified when temperature drops below %.1f°C.", thresholdValue)
-	case "custom":
-		thresholdValue = 25.0 // Default value for custom
-		if threshold != "" {
-			if val, err := strconv.ParseFloat(threshold, 64); err == nil {
-				thresholdValue = val
-			}
-		}
-		operator = "gt"
-		message = "✅ Custom temperature alert created! Specify your threshold next."
+		message = "✅ Low temperature alert created! You'll be notified when temperature drops below " + weather.FormatTemp(thresholdValue, units) + "."
 	default:
 		thresholdValue = 25.0
 		operator = "gt"
@@ -2817,23 +4218,25 @@ func (h *CommandHandler) handleTemperatureAlert(bot *gotgbot.Bot, ctx *ext.Conte
 		Value:    thresholdValue,
 	}
 
+	if exists, err := h.sendIfAlertExists(bot, ctx, models.AlertTemperature, alertCondition); exists {
+		return err
+	}
+
 	_, err = h.services.Alert.CreateAlert(context.Background(), userID, models.AlertTemperature, alertCondition)
 	if err != nil {
-		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Failed to create temperature alert. Please try again.", nil)
-		return sendErr
+		return h.sendAlertCreateFailed(bot, ctx, err, "❌ Failed to create temperature alert. Please try again.")
 	}
 
-	_, err = bot.SendMessage(ctx.EffectiveChat.Id, message, nil)
-	return err
+	return h.sendAlertCreated(bot, ctx, message)
 }
 
 func (h *CommandHandler) handleWindAlert(bot *gotgbot.Bot, ctx *ext.Context, condition, threshold string) error {
 	userID := ctx.EffectiveUser.Id
 
 	// Get user's location
-	locationName, _, _, err := h.services.User.GetUserLocation(context.Background(), userID)
+	locationName, _, _, err := h.getUserLocation(ctx, userID)
 	if err != nil || locationName == "" {
-		userLang := h.getUserLanguage(context.Background(), userID)
+		userLang := h.getUserLanguage(ctx, userID)
 		errorMsg := h.services.Localization.T(context.Background(), userLang, "location_required_setlocation")
 		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
 		return sendErr
@@ -2844,25 +4247,14 @@ func (h *CommandHandler) handleWindAlert(bot *gotgbot.Bot, ctx *ext.Context, con
 	var operator string
 	var message string
 
+	// Thresholds are entered in the user's units and stored in metric
+	units := h.getUserUnits(ctx, userID)
+
 	switch condition {
 	case "high":
-		thresholdValue = 50.0 // Default high wind speed in km/h
-		if threshold != "" {
-			if val, err := strconv.ParseFloat(threshold, 64); err == nil {
-				thresholdValue = val
-			}
-		}
+		thresholdValue = parseAlertThreshold(threshold, 50.0, models.AlertWindSpeed, units) // Default high wind speed in km/h
 		operator = "gt"
-		message = fmt.Sprintf("✅ Wind alert created! You'll be notified when wind speed exceeds %.1f km/h.", thresholdValue)
-	case "custom":
-		thresholdValue = 30.0 // Default value for custom
-		if threshold != "" {
-			if val, err := strconv.ParseFloat(threshold, 64); err == nil {
-				thresholdValue = val
-			}
-		}
-		operator = "gt"
-		message = "✅ Custom wind alert created! Specify your threshold next."
+		message = "✅ Wind alert created! You'll be notified when wind speed exceeds " + weather.FormatSpeed(thresholdValue, units) + "."
 	default:
 		thresholdValue = 40.0
 		operator = "gt"
@@ -2875,23 +4267,25 @@ func (h *CommandHandler) handleWindAlert(bot *gotgbot.Bot, ctx *ext.Context, con
 		Value:    thresholdValue,
 	}
 
+	if exists, err := h.sendIfAlertExists(bot, ctx, models.AlertWindSpeed, alertCondition); exists {
+		return err
+	}
+
 	_, err = h.services.Alert.CreateAlert(context.Background(), userID, models.AlertWindSpeed, alertCondition)
 	if err != nil {
-		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Failed to create wind alert. Please try again.", nil)
-		return sendErr
+		return h.sendAlertCreateFailed(bot, ctx, err, "❌ Failed to create wind alert. Please try again.")
 	}
 
-	_, err = bot.SendMessage(ctx.EffectiveChat.Id, message, nil)
-	return err
+	return h.sendAlertCreated(bot, ctx, message)
 }
 
 func (h *CommandHandler) handleAirQualityAlert(bot *gotgbot.Bot, ctx *ext.Context, condition, threshold string) error {
 	userID := ctx.EffectiveUser.Id
 
 	// Get user's location
-	locationName, _, _, err := h.services.User.GetUserLocation(context.Background(), userID)
+	locationName, _, _, err := h.getUserLocation(ctx, userID)
 	if err != nil || locationName == "" {
-		userLang := h.getUserLanguage(context.Background(), userID)
+		userLang := h.getUserLanguage(ctx, userID)
 		errorMsg := h.services.Localization.T(context.Background(), userLang, "location_required_setlocation")
 		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
 		return sendErr
@@ -2911,7 +4305,7 @@ func (h *CommandHandler) handleAirQualityAlert(bot *gotgbot.Bot, ctx *ext.Contex
 			}
 		}
 		operator = "gt"
-		message = fmt.Sprintf("✅ Air quality alert created! You'll be notified when AQI exceeds %.0f.", thresholdValue)
+		message = "✅ Air quality alert created! You'll be notified when AQI exceeds " + weather.FormatAQI(thresholdValue) + "."
 	case "unhealthy":
 		thresholdValue = 150.0 // Unhealthy AQI threshold
 		if threshold != "" {
@@ -2920,16 +4314,7 @@ func (h *CommandHandler) handleAirQualityAlert(bot *gotgbot.Bot, ctx *ext.Contex
 			}
 		}
 		operator = "gt"
-		message = fmt.Sprintf("✅ Air quality alert created! You'll be notified when AQI reaches unhealthy levels (%.0f+).", thresholdValue)
-	case "custom":
-		thresholdValue = 75.0 // Default value for custom
-		if threshold != "" {
-			if val, err := strconv.ParseFloat(threshold, 64); err == nil {
-				thresholdValue = val
-			}
-		}
-		operator = "gt"
-		message = "✅ Custom air quality alert created! Specify your threshold next."
+		message = "✅ Air quality alert created! You'll be notified when AQI reaches unhealthy levels (" + weather.FormatAQI(thresholdValue) + "+)."
 	default:
 		thresholdValue = 100.0
 		operator = "gt"
@@ -2942,23 +4327,25 @@ func (h *CommandHandler) handleAirQualityAlert(bot *gotgbot.Bot, ctx *ext.Contex
 		Value:    thresholdValue,
 	}
 
+	if exists, err := h.sendIfAlertExists(bot, ctx, models.AlertAirQuality, alertCondition); exists {
+		return err
+	}
+
 	_, err = h.services.Alert.CreateAlert(context.Background(), userID, models.AlertAirQuality, alertCondition)
 	if err != nil {
-		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Failed to create air quality alert. Please try again.", nil)
-		return sendErr
+		return h.sendAlertCreateFailed(bot, ctx, err, "❌ Failed to create air quality alert. Please try again.")
 	}
 
-	_, err = bot.SendMessage(ctx.EffectiveChat.Id, message, nil)
-	return err
+	return h.sendAlertCreated(bot, ctx, message)
 }
 
 func (h *CommandHandler) handleHumidityAlert(bot *gotgbot.Bot, ctx *ext.Context, condition, threshold string) error {
 	userID := ctx.EffectiveUser.Id
 
 	// Get user's location
-	locationName, _, _, err := h.services.User.GetUserLocation(context.Background(), userID)
+	locationName, _, _, err := h.getUserLocation(ctx, userID)
 	if err != nil || locationName == "" {
-		userLang := h.getUserLanguage(context.Background(), userID)
+		userLang := h.getUserLanguage(ctx, userID)
 		errorMsg := h.services.Localization.T(context.Background(), userLang, "location_required_setlocation")
 		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
 		return sendErr
@@ -2978,7 +4365,7 @@ func (h *CommandHandler) handleHumidityAlert(bot *gotgbot.Bot, ctx *ext.Context,
 			}
 		}
 		operator = "gt"
-		message = fmt.Sprintf("✅ High humidity alert created! You'll be notified when humidity exceeds %.1f%%.", thresholdValue)
+		message = "✅ High humidity alert created! You'll be notified when humidity exceeds " + weather.FormatPercent(thresholdValue) + "."
 	case "low":
 		thresholdValue = 30.0 // Low humidity threshold (%)
 		if threshold != "" {
@@ -2987,16 +4374,7 @@ func (h *CommandHandler) handleHumidityAlert(bot *gotgbot.Bot, ctx *ext.Context,
 			}
 		}
 		operator = "lt"
-		message = fmt.Sprintf("✅ Low humidity alert created! You'll be notified when humidity drops below %.1f%%.", thresholdValue)
-	case "custom":
-		thresholdValue = 60.0 // Default value for custom
-		if threshold != "" {
-			if val, err := strconv.ParseFloat(threshold, 64); err == nil {
-				thresholdValue = val
-			}
-		}
-		operator = "gt"
-		message = "✅ Custom humidity alert created! Specify your threshold next."
+		message = "✅ Low humidity alert created! You'll be notified when humidity drops below " + weather.FormatPercent(thresholdValue) + "."
 	default:
 		thresholdValue = 70.0
 		operator = "gt"
@@ -3009,19 +4387,21 @@ func (h *CommandHandler) handleHumidityAlert(bot *gotgbot.Bot, ctx *ext.Context,
 		Value:    thresholdValue,
 	}
 
+	if exists, err := h.sendIfAlertExists(bot, ctx, models.AlertHumidity, alertCondition); exists {
+		return err
+	}
+
 	_, err = h.services.Alert.CreateAlert(context.Background(), userID, models.AlertHumidity, alertCondition)
 	if err != nil {
-		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Failed to create humidity alert. Please try again.", nil)
-		return sendErr
+		return h.sendAlertCreateFailed(bot, ctx, err, "❌ Failed to create humidity alert. Please try again.")
 	}
 
-	_, err = bot.SendMessage(ctx.EffectiveChat.Id, message, nil)
-	return err
+	return h.sendAlertCreated(bot, ctx, message)
 }
 
 func (h *CommandHandler) editAlert(bot *gotgbot.Bot, ctx *ext.Context, alertID string) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	// Parse the alert UUID
 	alertUUID, err := uuid.Parse(alertID)
@@ -3053,10 +4433,11 @@ func (h *CommandHandler) editAlert(bot *gotgbot.Bot, ctx *ext.Context, alertID s
 	// Get alert type text
 	alertTypeText := h.getAlertTypeTextLocalized(alert.AlertType, userLang)
 	operatorSymbol := h.getOperatorSymbol(condition.Operator)
+	units := h.getUserUnits(ctx, userID)
 
 	// Build the edit message
 	titleText := h.services.Localization.T(context.Background(), userLang, "alerts_edit_title")
-	currentText := h.services.Localization.T(context.Background(), userLang, "alerts_edit_current", alertTypeText, operatorSymbol, alert.Threshold)
+	currentText := h.services.Localization.T(context.Background(), userLang, "alerts_edit_current", alertTypeText, operatorSymbol, services.FormatAlertValue(alert.AlertType, alert.Threshold, units))
 	instructionText := h.services.Localization.T(context.Background(), userLang, "alerts_edit_instruction")
 
 	message := fmt.Sprintf("*%s*\n\n%s\n\n%s", titleText, currentText, instructionText)
@@ -3065,14 +4446,15 @@ func (h *CommandHandler) editAlert(bot *gotgbot.Bot, ctx *ext.Context, alertID s
 	var keyboard [][]gotgbot.InlineKeyboardButton
 
 	// Generate threshold options based on alert type
-	thresholds := h.getThresholdOptions(alert.AlertType, alert.Threshold)
+	thresholds := h.getThresholdOptions(alert.AlertType, alert.Threshold, units)
 
 	// Note: Using %.1f format for threshold values in callback data.
 	// This limits precision to 1 decimal place, which is sufficient for weather thresholds
-	// and avoids scientific notation for very small/large numbers.
+	// and avoids scientific notation for very small/large numbers. The values
+	// are metric, so a button still means the same after the user switches units.
 	for _, threshold := range thresholds {
 		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
-			{Text: fmt.Sprintf("%s %.1f", operatorSymbol, threshold),
+			{Text: operatorSymbol + " " + services.FormatAlertValue(alert.AlertType, threshold, units),
 				CallbackData: fmt.Sprintf("alerts_update_%s_%.1f", alert.ID, threshold)},
 		})
 	}
@@ -3089,6 +4471,21 @@ func (h *CommandHandler) editAlert(bot *gotgbot.Bot, ctx *ext.Context, alertID s
 		{Text: toggleText, CallbackData: fmt.Sprintf("alerts_toggle_%s", alert.ID)},
 	})
 
+	// Add public holiday suppression toggle
+	holidaysKey := "alerts_holidays_btn_off"
+	if alert.SuppressOnHolidays {
+		holidaysKey = "alerts_holidays_btn_on"
+	}
+	keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
+		{Text: h.services.Localization.T(context.Background(), userLang, holidaysKey), CallbackData: fmt.Sprintf("alerts_holidays_%s", alert.ID)},
+	})
+
+	// Add cooldown picker
+	cooldownText := h.services.Localization.T(context.Background(), userLang, "alerts_cooldown_btn", h.formatAlertCooldown(alert.Cooldown(), userLang))
+	keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
+		{Text: cooldownText, CallbackData: fmt.Sprintf("alerts_cooldown_%s", alert.ID)},
+	})
+
 	// Add back button
 	backBtnText := h.services.Localization.T(context.Background(), userLang, "alerts_back_to_list")
 	keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
@@ -3110,34 +4507,73 @@ func (h *CommandHandler) editAlert(bot *gotgbot.Bot, ctx *ext.Context, alertID s
 	return err
 }
 
-// getThresholdOptions generates threshold options based on alert type and current value
-func (h *CommandHandler) getThresholdOptions(alertType models.AlertType, currentValue float64) []float64 {
-	switch alertType {
-	case models.AlertTemperature:
-		// Temperature: -20 to 40°C in 5° increments
-		return h.generateRangeOptions(-20, 40, 5, currentValue)
-	case models.AlertHumidity:
-		// Humidity: 20 to 90% in 10% increments
-		return h.generateRangeOptions(20, 90, 10, currentValue)
-	case models.AlertPressure:
-		// Pressure: 960 to 1040 hPa in 10 hPa increments
-		return h.generateRangeOptions(960, 1040, 10, currentValue)
-	case models.AlertWindSpeed:
-		// Wind: 5 to 50 km/h in 5 km/h increments
-		return h.generateRangeOptions(5, 50, 5, currentValue)
-	case models.AlertUVIndex:
-		// UV: 1 to 11 in 1 increment
-		return h.generateRangeOptions(1, 11, 1, currentValue)
-	case models.AlertAirQuality:
-		// AQI: 50 to 300 in 50 increments
-		return h.generateRangeOptions(50, 300, 50, currentValue)
-	default:
-		// Default: show ±20% around current value
-		min := currentValue * defaultMinFactor
-		max := currentValue * defaultMaxFactor
-		step := (max - min) / defaultStepDivisor
-		return h.generateRangeOptions(min, max, step, currentValue)
+// thresholdScale is the range of thresholds offered for an alert type and the
+// step between options, in the units of the table it is listed in
+type thresholdScale struct {
+	min, max, step float64
+}
+
+// metricThresholdScales are the threshold ranges in metric units
+var metricThresholdScales = map[models.AlertType]thresholdScale{
+	models.AlertTemperature: {-20, 40, 5},    // °C
+	models.AlertHumidity:    {20, 90, 10},    // %
+	models.AlertPressure:    {960, 1040, 10}, // hPa
+	models.AlertWindSpeed:   {5, 50, 5},      // km/h
+	models.AlertUVIndex:     {1, 11, 1},
+	models.AlertAirQuality:  {50, 300, 50}, // AQI
+	models.AlertPollen:      {0, weather.MaxPollenRisk - 1, 1},
+	models.AlertTide:        {0.5, 5, 0.5}, // m above mean sea level
+}
+
+// imperialThresholdScales are the threshold ranges of the alert types whose
+// unit differs in imperial; the others use metricThresholdScales
+var imperialThresholdScales = map[models.AlertType]thresholdScale{
+	models.AlertTemperature: {0, 100, 10},      // °F
+	models.AlertPressure:    {28.4, 30.8, 0.2}, // inHg
+	models.AlertWindSpeed:   {5, 30, 5},        // mph
+	models.AlertTide:        {2, 16, 1},        // ft above mean sea level
+}
+
+// thresholdScaleFor returns the threshold range of an alert type in the
+// user's units
+func thresholdScaleFor(alertType models.AlertType, units string) (thresholdScale, bool) {
+	if units == weather.UnitsImperial {
+		if scale, ok := imperialThresholdScales[alertType]; ok {
+			return scale, true
+		}
+	}
+	scale, ok := metricThresholdScales[alertType]
+	return scale, ok
+}
+
+// getThresholdOptions generates threshold options based on alert type and
+// current value. Options are spaced in the given unit system and returned in
+// metric, the unit alerts are stored in.
+func (h *CommandHandler) getThresholdOptions(alertType models.AlertType, currentValue float64, units string) []float64 {
+	scale, ok := imperialThresholdScales[alertType]
+	if units != weather.UnitsImperial || !ok {
+		return h.getMetricThresholdOptions(alertType, currentValue)
+	}
+
+	current := services.AlertValueFromMetric(alertType, currentValue, units)
+	options := h.generateRangeOptions(scale.min, scale.max, scale.step, current)
+	for i, option := range options {
+		options[i] = services.AlertValueToMetric(alertType, option, units)
 	}
+	return options
+}
+
+// getMetricThresholdOptions generates threshold options in metric units
+func (h *CommandHandler) getMetricThresholdOptions(alertType models.AlertType, currentValue float64) []float64 {
+	if scale, ok := metricThresholdScales[alertType]; ok {
+		return h.generateRangeOptions(scale.min, scale.max, scale.step, currentValue)
+	}
+
+	// Default: show ±20% around current value
+	min := currentValue * defaultMinFactor
+	max := currentValue * defaultMaxFactor
+	step := (max - min) / defaultStepDivisor
+	return h.generateRangeOptions(min, max, step, currentValue)
 }
 
 // generateRangeOptions generates a range of values around the current value
@@ -3166,7 +4602,7 @@ func (h *CommandHandler) generateRangeOptions(min, max, step, current float64) [
 // updateAlertThreshold updates the threshold value of an alert
 func (h *CommandHandler) updateAlertThreshold(bot *gotgbot.Bot, ctx *ext.Context, alertID string, thresholdStr string) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	// Parse alert UUID
 	alertUUID, err := uuid.Parse(alertID)
@@ -3182,9 +4618,10 @@ func (h *CommandHandler) updateAlertThreshold(bot *gotgbot.Bot, ctx *ext.Context
 		return err
 	}
 
-	// Update the alert
+	// Update the alert; a new threshold re-arms it
 	updates := map[string]interface{}{
-		"threshold": threshold,
+		"threshold":     threshold,
+		"rearm_pending": false,
 	}
 
 	err = h.services.Alert.UpdateAlert(context.Background(), userID, alertUUID, updates)
@@ -3196,7 +4633,12 @@ func (h *CommandHandler) updateAlertThreshold(bot *gotgbot.Bot, ctx *ext.Context
 	}
 
 	// Send success message
-	successMsg := h.services.Localization.T(context.Background(), userLang, "alerts_update_success", threshold)
+	alert, err := h.services.Alert.GetAlert(context.Background(), userID, alertUUID)
+	thresholdText := weather.FormatDecimal(threshold, 1)
+	if err == nil {
+		thresholdText = services.FormatAlertValue(alert.AlertType, threshold, h.getUserUnits(ctx, userID))
+	}
+	successMsg := h.services.Localization.T(context.Background(), userLang, "alerts_update_success", thresholdText)
 	backBtnText := h.services.Localization.T(context.Background(), userLang, "alerts_back_to_list")
 
 	_, err = bot.SendMessage(ctx.EffectiveChat.Id, successMsg, &gotgbot.SendMessageOpts{
@@ -3219,7 +4661,7 @@ func (h *CommandHandler) updateAlertThreshold(bot *gotgbot.Bot, ctx *ext.Context
 // showOperatorOptions shows operator change options for an alert
 func (h *CommandHandler) showOperatorOptions(bot *gotgbot.Bot, ctx *ext.Context, alertID string) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	titleText := h.services.Localization.T(context.Background(), userLang, "alerts_operator_title")
 	message := fmt.Sprintf("*%s*\n\n", titleText)
@@ -3255,7 +4697,7 @@ func (h *CommandHandler) showOperatorOptions(bot *gotgbot.Bot, ctx *ext.Context,
 // updateAlertOperator updates the operator of an alert
 func (h *CommandHandler) updateAlertOperator(bot *gotgbot.Bot, ctx *ext.Context, alertID string, operator string) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	// Parse alert UUID
 	alertUUID, err := uuid.Parse(alertID)
@@ -3271,101 +4713,301 @@ func (h *CommandHandler) updateAlertOperator(bot *gotgbot.Bot, ctx *ext.Context,
 		return err
 	}
 
-	// Parse current condition
-	var condition services.AlertCondition
-	if err := json.Unmarshal([]byte(alert.Condition), &condition); err != nil {
-		h.logger.Error().Err(err).Msg("Failed to parse alert condition")
-		return err
+	// Parse current condition
+	var condition services.AlertCondition
+	if err := json.Unmarshal([]byte(alert.Condition), &condition); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to parse alert condition")
+		return err
+	}
+
+	// Update operator
+	condition.Operator = operator
+	conditionJSON, err := json.Marshal(condition)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to marshal condition")
+		return err
+	}
+
+	// Update the alert; a new operator re-arms it
+	updates := map[string]interface{}{
+		"condition":     string(conditionJSON),
+		"rearm_pending": false,
+	}
+
+	err = h.services.Alert.UpdateAlert(context.Background(), userID, alertUUID, updates)
+	if err != nil {
+		h.logger.Error().Err(err).Str("alert_id", alertID).Msg("Failed to update alert operator")
+		errorMsg := h.services.Localization.T(context.Background(), userLang, "alerts_update_failed")
+		_, _ = bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
+		return err
+	}
+
+	// Send success message
+	operatorSymbol := h.getOperatorSymbol(operator)
+	successMsg := h.services.Localization.T(context.Background(), userLang, "alerts_operator_update_success", operatorSymbol)
+	backBtnText := h.services.Localization.T(context.Background(), userLang, "alerts_back_to_list")
+
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, successMsg, &gotgbot.SendMessageOpts{
+		ParseMode: "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
+			InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+				{{Text: backBtnText, CallbackData: "alerts_list"}},
+			},
+		},
+	})
+
+	if ctx.CallbackQuery != nil {
+		_, _ = ctx.CallbackQuery.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{
+			Text: h.services.Localization.T(context.Background(), userLang, "alerts_operator_update_success", operatorSymbol),
+		})
+	}
+
+	return err
+}
+
+// toggleAlert toggles an alert active/inactive state
+func (h *CommandHandler) toggleAlert(bot *gotgbot.Bot, ctx *ext.Context, alertID string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	// Parse alert UUID
+	alertUUID, err := uuid.Parse(alertID)
+	if err != nil {
+		h.logger.Error().Err(err).Str("alert_id", alertID).Msg("Invalid alert UUID")
+		return err
+	}
+
+	// Get the current alert
+	alert, err := h.services.Alert.GetAlert(context.Background(), userID, alertUUID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get alert")
+		return err
+	}
+
+	// Toggle the active state; switching an alert on counts against the limit
+	newState := !alert.IsActive
+	if newState {
+		err = h.services.Alert.ActivateAlert(context.Background(), userID, alertUUID)
+	} else {
+		err = h.services.Alert.UpdateAlert(context.Background(), userID, alertUUID, map[string]interface{}{"is_active": false})
+	}
+	if errors.Is(err, services.ErrAlertLimitReached) {
+		return h.sendAlertLimitReached(bot, ctx, "alert_limit_reached_activate")
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Str("alert_id", alertID).Msg("Failed to toggle alert")
+		errorMsg := h.services.Localization.T(context.Background(), userLang, "alerts_toggle_failed")
+		_, _ = bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
+		return err
+	}
+
+	// Send success message with appropriate key based on new state
+	successKey := "alerts_deactivated"
+	if newState {
+		successKey = "alerts_activated"
+	}
+	successMsg := h.services.Localization.T(context.Background(), userLang, successKey)
+
+	backBtnText := h.services.Localization.T(context.Background(), userLang, "alerts_back_to_list")
+
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, successMsg, &gotgbot.SendMessageOpts{
+		ParseMode: "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
+			InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+				{{Text: backBtnText, CallbackData: "alerts_list"}},
+			},
+		},
+	})
+
+	if ctx.CallbackQuery != nil {
+		_, _ = ctx.CallbackQuery.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{
+			Text: successMsg,
+		})
+	}
+
+	return err
+}
+
+// toggleAlertHolidays switches whether routine triggers of an alert are held
+// back on public holidays of the user's country
+func (h *CommandHandler) toggleAlertHolidays(bot *gotgbot.Bot, ctx *ext.Context, alertID string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	alertUUID, err := uuid.Parse(alertID)
+	if err != nil {
+		h.logger.Error().Err(err).Str("alert_id", alertID).Msg("Invalid alert UUID")
+		return err
+	}
+
+	alert, err := h.services.Alert.GetAlert(context.Background(), userID, alertUUID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get alert")
+		return err
+	}
+
+	suppress := !alert.SuppressOnHolidays
+	err = h.services.Alert.UpdateAlert(context.Background(), userID, alertUUID, map[string]interface{}{
+		"suppress_on_holidays": suppress,
+	})
+	if err != nil {
+		h.logger.Error().Err(err).Str("alert_id", alertID).Msg("Failed to toggle alert holiday suppression")
+		errorMsg := h.services.Localization.T(context.Background(), userLang, "alerts_toggle_failed")
+		_, _ = bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
+		return err
+	}
+
+	message := h.services.Localization.T(context.Background(), userLang, "alerts_holidays_disabled")
+	if suppress {
+		message = h.services.Localization.T(context.Background(), userLang, "alerts_holidays_enabled")
+		if user, err := h.getUser(ctx, userID); err == nil && !services.HasHolidayCalendar(user.Country) {
+			message += "\n\n" + h.services.Localization.T(context.Background(), userLang, "alerts_holidays_no_calendar")
+		}
+	}
+
+	backBtnText := h.services.Localization.T(context.Background(), userLang, "alerts_back_to_list")
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, message, &gotgbot.SendMessageOpts{
+		ParseMode: "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
+			InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+				{{Text: backBtnText, CallbackData: "alerts_list"}},
+			},
+		},
+	})
+
+	if ctx.CallbackQuery != nil {
+		_, _ = ctx.CallbackQuery.Answer(bot, nil)
+	}
+
+	return err
+}
+
+// formatAlertCooldown renders a cooldown as minutes below an hour, else hours
+func (h *CommandHandler) formatAlertCooldown(cooldown time.Duration, lang string) string {
+	if cooldown < time.Hour {
+		return h.services.Localization.T(context.Background(), lang, "alerts_cooldown_minutes", int(cooldown.Minutes()))
+	}
+	return h.services.Localization.T(context.Background(), lang, "alerts_cooldown_hours", int(cooldown.Hours()))
+}
+
+// showCooldownOptions lets the user pick how long an alert stays quiet after
+// it triggered
+func (h *CommandHandler) showCooldownOptions(bot *gotgbot.Bot, ctx *ext.Context, alertID string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	alertUUID, err := uuid.Parse(alertID)
+	if err != nil {
+		h.logger.Error().Err(err).Str("alert_id", alertID).Msg("Invalid alert UUID")
+		return err
+	}
+
+	alert, err := h.services.Alert.GetAlert(context.Background(), userID, alertUUID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get alert")
+		return err
+	}
+
+	message := h.services.Localization.T(context.Background(), userLang, "alerts_cooldown_title", h.formatAlertCooldown(alert.Cooldown(), userLang))
+	quietUntil := alert.QuietUntil()
+	inCooldown := time.Now().Before(quietUntil)
+	if inCooldown {
+		until := h.services.User.ConvertToUserTime(context.Background(), userID, quietUntil)
+		message += "\n" + h.services.Localization.T(context.Background(), userLang, "alerts_cooldown_quiet_until", until.Format("02.01 15:04"))
+	}
+
+	var row []gotgbot.InlineKeyboardButton
+	for _, option := range models.AlertCooldownOptions {
+		text := h.formatAlertCooldown(option, userLang)
+		if option == alert.Cooldown() {
+			text = "✓ " + text
+		}
+		row = append(row, gotgbot.InlineKeyboardButton{
+			Text:         text,
+			CallbackData: fmt.Sprintf("alerts_setcooldown_%s_%d", alertID, int(option.Minutes())),
+		})
+	}
+
+	keyboard := [][]gotgbot.InlineKeyboardButton{row}
+	if inCooldown {
+		rearmText := h.services.Localization.T(context.Background(), userLang, "alerts_rearm_btn")
+		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{{Text: rearmText, CallbackData: fmt.Sprintf("alerts_rearm_%s", alertID)}})
+	}
+	backBtnText := h.services.Localization.T(context.Background(), userLang, "alerts_back")
+	keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{{Text: backBtnText, CallbackData: fmt.Sprintf("alerts_edit_%s", alertID)}})
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, message, &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+
+	if ctx.CallbackQuery != nil {
+		_, _ = ctx.CallbackQuery.Answer(bot, nil)
 	}
 
-	// Update operator
-	condition.Operator = operator
-	conditionJSON, err := json.Marshal(condition)
+	return err
+}
+
+// rearmAlert ends the cooldown of an alert, so it may trigger again at the
+// next check
+func (h *CommandHandler) rearmAlert(bot *gotgbot.Bot, ctx *ext.Context, alertID string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	alertUUID, err := uuid.Parse(alertID)
 	if err != nil {
-		h.logger.Error().Err(err).Msg("Failed to marshal condition")
+		h.logger.Error().Err(err).Str("alert_id", alertID).Msg("Invalid alert UUID")
 		return err
 	}
 
-	// Update the alert
-	updates := map[string]interface{}{
-		"condition": string(conditionJSON),
-	}
-
-	err = h.services.Alert.UpdateAlert(context.Background(), userID, alertUUID, updates)
-	if err != nil {
-		h.logger.Error().Err(err).Str("alert_id", alertID).Msg("Failed to update alert operator")
+	if err := h.services.Alert.RearmAlert(context.Background(), userID, alertUUID); err != nil {
+		h.logger.Error().Err(err).Str("alert_id", alertID).Msg("Failed to re-arm alert")
 		errorMsg := h.services.Localization.T(context.Background(), userLang, "alerts_update_failed")
 		_, _ = bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
 		return err
 	}
 
-	// Send success message
-	operatorSymbol := h.getOperatorSymbol(operator)
-	successMsg := h.services.Localization.T(context.Background(), userLang, "alerts_operator_update_success", operatorSymbol)
+	successMsg := h.services.Localization.T(context.Background(), userLang, "alerts_rearmed")
 	backBtnText := h.services.Localization.T(context.Background(), userLang, "alerts_back_to_list")
-
 	_, err = bot.SendMessage(ctx.EffectiveChat.Id, successMsg, &gotgbot.SendMessageOpts{
-		ParseMode: "Markdown",
 		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
 			InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
 				{{Text: backBtnText, CallbackData: "alerts_list"}},
 			},
 		},
 	})
-
-	if ctx.CallbackQuery != nil {
-		_, _ = ctx.CallbackQuery.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{
-			Text: h.services.Localization.T(context.Background(), userLang, "alerts_operator_update_success", operatorSymbol),
-		})
-	}
-
 	return err
 }
 
-// toggleAlert toggles an alert active/inactive state
-func (h *CommandHandler) toggleAlert(bot *gotgbot.Bot, ctx *ext.Context, alertID string) error {
+// updateAlertCooldown sets the cooldown of an alert to one of the offered options
+func (h *CommandHandler) updateAlertCooldown(bot *gotgbot.Bot, ctx *ext.Context, alertID string, minutesStr string) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
-	// Parse alert UUID
 	alertUUID, err := uuid.Parse(alertID)
 	if err != nil {
 		h.logger.Error().Err(err).Str("alert_id", alertID).Msg("Invalid alert UUID")
 		return err
 	}
 
-	// Get the current alert
-	alert, err := h.services.Alert.GetAlert(context.Background(), userID, alertUUID)
-	if err != nil {
-		h.logger.Error().Err(err).Msg("Failed to get alert")
-		return err
-	}
-
-	// Toggle the active state
-	newState := !alert.IsActive
-	updates := map[string]interface{}{
-		"is_active": newState,
+	minutes, err := strconv.Atoi(minutesStr)
+	if err != nil || !slices.Contains(models.AlertCooldownOptions, time.Duration(minutes)*time.Minute) {
+		h.logger.Warn().Str("minutes", minutesStr).Msg("Invalid alert cooldown")
+		return nil
 	}
 
-	err = h.services.Alert.UpdateAlert(context.Background(), userID, alertUUID, updates)
+	err = h.services.Alert.UpdateAlert(context.Background(), userID, alertUUID, map[string]interface{}{
+		"cooldown_minutes": minutes,
+	})
 	if err != nil {
-		h.logger.Error().Err(err).Str("alert_id", alertID).Msg("Failed to toggle alert")
-		errorMsg := h.services.Localization.T(context.Background(), userLang, "alerts_toggle_failed")
+		h.logger.Error().Err(err).Str("alert_id", alertID).Msg("Failed to update alert cooldown")
+		errorMsg := h.services.Localization.T(context.Background(), userLang, "alerts_update_failed")
 		_, _ = bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
 		return err
 	}
 
-	// Send success message with appropriate key based on new state
-	successKey := "alerts_deactivated"
-	if newState {
-		successKey = "alerts_activated"
-	}
-	successMsg := h.services.Localization.T(context.Background(), userLang, successKey)
-
+	successMsg := h.services.Localization.T(context.Background(), userLang, "alerts_cooldown_updated", h.formatAlertCooldown(time.Duration(minutes)*time.Minute, userLang))
 	backBtnText := h.services.Localization.T(context.Background(), userLang, "alerts_back_to_list")
-
 	_, err = bot.SendMessage(ctx.EffectiveChat.Id, successMsg, &gotgbot.SendMessageOpts{
-		ParseMode: "Markdown",
 		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
 			InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
 				{{Text: backBtnText, CallbackData: "alerts_list"}},
@@ -3374,9 +5016,7 @@ func (h *CommandHandler) toggleAlert(bot *gotgbot.Bot, ctx *ext.Context, alertID
 	})
 
 	if ctx.CallbackQuery != nil {
-		_, _ = ctx.CallbackQuery.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{
-			Text: successMsg,
-		})
+		_, _ = ctx.CallbackQuery.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{Text: successMsg})
 	}
 
 	return err
@@ -3384,7 +5024,7 @@ func (h *CommandHandler) toggleAlert(bot *gotgbot.Bot, ctx *ext.Context, alertID
 
 func (h *CommandHandler) removeAlert(bot *gotgbot.Bot, ctx *ext.Context, alertID string) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	// Parse the alert UUID
 	alertUUID, err := uuid.Parse(alertID)
@@ -3438,6 +5078,10 @@ func (h *CommandHandler) handleAlertsCallback(bot *gotgbot.Bot, ctx *ext.Context
 		// List all user alerts
 		return h.listUserAlerts(bot, ctx)
 
+	case "history":
+		// Alerts that triggered recently, linked from the daily digest
+		return h.showAlertHistory(bot, ctx)
+
 	case "edit":
 		// Edit an alert by ID
 		if len(params) > 0 {
@@ -3473,14 +5117,76 @@ func (h *CommandHandler) handleAlertsCallback(bot *gotgbot.Bot, ctx *ext.Context
 		if len(params) > 0 {
 			return h.toggleAlert(bot, ctx, params[0])
 		}
+
+	case "holidays":
+		// Toggle public holiday suppression: alerts_holidays_{alertID}
+		if len(params) > 0 {
+			return h.toggleAlertHolidays(bot, ctx, params[0])
+		}
+
+	case "cooldown":
+		// Show cooldown options: alerts_cooldown_{alertID}
+		if len(params) > 0 {
+			return h.showCooldownOptions(bot, ctx, params[0])
+		}
+
+	case "setcooldown":
+		// Set the cooldown: alerts_setcooldown_{alertID}_{minutes}
+		if len(params) >= 2 {
+			return h.updateAlertCooldown(bot, ctx, params[0], params[1])
+		}
+
+	case "rearm":
+		// End the cooldown of a triggered alert: alerts_rearm_{alertID}
+		if len(params) > 0 {
+			return h.rearmAlert(bot, ctx, params[0])
+		}
 	}
 
 	return nil
 }
 
+// showAlertHistory lists the alerts that triggered in the last alertHistoryDays
+func (h *CommandHandler) showAlertHistory(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	user, err := h.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -alertHistoryDays)
+	alerts, err := h.services.Alert.GetTriggeredAlertsSince(context.Background(), userID, since, alertHistoryLimit+1)
+	if err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to get alert history")
+		errorMsg := h.services.Localization.T(context.Background(), userLang, "alerts_fetch_failed")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
+		return err
+	}
+
+	if len(alerts) == 0 {
+		text := h.services.Localization.TN(context.Background(), userLang, "alerts_history_empty", alertHistoryDays)
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, nil)
+		return err
+	}
+
+	activity := &services.AlertActivity{Alerts: alerts}
+	if len(alerts) > alertHistoryLimit {
+		activity.Alerts = alerts[:alertHistoryLimit]
+		activity.More = true
+	}
+
+	title := h.services.Localization.TN(context.Background(), userLang, "alerts_history_title", alertHistoryDays)
+	text := h.services.Notification.FormatAlertHistory(user, title, activity)
+
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{ParseMode: "Markdown"})
+	return err
+}
+
 func (h *CommandHandler) listUserAlerts(bot *gotgbot.Bot, ctx *ext.Context) error {
 	userID := ctx.EffectiveUser.Id
-	userLang := h.getUserLanguage(context.Background(), userID)
+	userLang := h.getUserLanguage(ctx, userID)
 
 	alerts, err := h.services.Alert.GetUserAlerts(context.Background(), userID)
 	if err != nil {
@@ -3508,13 +5214,13 @@ func (h *CommandHandler) listUserAlerts(bot *gotgbot.Bot, ctx *ext.Context) erro
 	}
 
 	// Get user info for location
-	user, err := h.services.User.GetUser(context.Background(), userID)
+	user, err := h.getUser(ctx, userID)
 	if err != nil {
 		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to get user")
 		return err
 	}
 
-	titleText := h.services.Localization.T(context.Background(), userLang, "alerts_list_title")
+	titleText := h.services.Localization.TN(context.Background(), userLang, "alerts_list_title", int64(len(alerts)))
 	text := fmt.Sprintf("*%s*\n\n", titleText)
 	var keyboard [][]gotgbot.InlineKeyboardButton
 
@@ -3528,14 +5234,16 @@ func (h *CommandHandler) listUserAlerts(bot *gotgbot.Bot, ctx *ext.Context) erro
 
 			text += fmt.Sprintf("%d. *%s*\n", i+1, alertTypeText)
 			if user.LocationName != "" {
-				text += fmt.Sprintf("   📍 %s\n", user.LocationName)
+				text += fmt.Sprintf("   📍 %s\n", markdown.Escape(user.LocationName))
 			}
-			text += fmt.Sprintf("   ⚡ %s %.1f\n", operatorSymbol, alert.Threshold)
+			text += fmt.Sprintf("   ⚡ %s %s\n", operatorSymbol, services.FormatAlertValue(alert.AlertType, alert.Threshold, services.UserUnits(user)))
 			statusText := h.services.Localization.T(context.Background(), userLang, "alerts_status_active")
 			if !alert.IsActive {
 				statusText = h.services.Localization.T(context.Background(), userLang, "alerts_status_inactive")
 			}
-			text += fmt.Sprintf("   🔔 %s\n\n", statusText)
+			text += fmt.Sprintf("   🔔 %s\n", statusText)
+			text += h.alertLastCheckedLine(alert, user, userLang)
+			text += "\n"
 		}
 
 		editBtnText := h.services.Localization.T(context.Background(), userLang, "alerts_edit_btn")
@@ -3589,6 +5297,23 @@ func (h *CommandHandler) handleNotificationCallback(bot *gotgbot.Bot, ctx *ext.C
 		if len(params) > 0 {
 			return h.deleteNotification(bot, ctx, params[0])
 		}
+	case "tracking":
+		if len(params) > 0 {
+			return h.setActivityTracking(bot, ctx, params[0] == "on")
+		}
+	case "quiet":
+		if len(params) > 0 {
+			return h.setNotificationPreference(bot, ctx, "quiet_digests", params[0] == "on", "quiet_digests_"+params[0])
+		}
+	case "unusual":
+		if len(params) > 0 {
+			return h.setNotificationPreference(bot, ctx, "unusual_weather_notes", params[0] == "on", "unusual_notes_"+params[0])
+		}
+	case "escalation":
+		if len(params) > 0 {
+			// The column stores the opt-out
+			return h.setNotificationPreference(bot, ctx, "alert_escalation_disabled", params[0] != "on", "alert_escalation_"+params[0])
+		}
 	case "info":
 		// Handle info display button - this is just for display, acknowledge the callback
 		if len(params) > 0 && params[0] == "display" {
@@ -3601,14 +5326,126 @@ func (h *CommandHandler) handleNotificationCallback(bot *gotgbot.Bot, ctx *ext.C
 	return nil
 }
 
+// setNotificationPreference stores one of the user's notification behavior
+// settings and confirms it with the given message
+func (h *CommandHandler) setNotificationPreference(bot *gotgbot.Bot, ctx *ext.Context, setting string, value bool, confirmationKey string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	if err := h.services.User.UpdateUserSettings(context.Background(), userID, map[string]interface{}{setting: value}); err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Str("setting", setting).Msg("Failed to update notification preference")
+		errorText := h.services.Localization.T(context.Background(), userLang, "notification_preference_failed")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorText, nil)
+		return err
+	}
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, confirmationKey), &gotgbot.SendMessageOpts{
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
+			InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+				{{Text: "🔙 Back to Notifications", CallbackData: "settings_notifications"}},
+			},
+		},
+	})
+	return err
+}
+
+// setShowRecommendations turns the tips under current weather on or off
+func (h *CommandHandler) setShowRecommendations(bot *gotgbot.Bot, ctx *ext.Context, enabled bool) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	if err := h.services.User.UpdateUserSettings(context.Background(), userID, map[string]interface{}{"show_recommendations": enabled}); err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Bool("enabled", enabled).Msg("Failed to update weather tips")
+		errorText := h.services.Localization.T(context.Background(), userLang, "recommendations_update_failed")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorText, nil)
+		return err
+	}
+
+	key := "recommendations_off"
+	if enabled {
+		key = "recommendations_on"
+	}
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, key), &gotgbot.SendMessageOpts{
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
+			InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+				{{Text: h.services.Localization.T(context.Background(), userLang, "button_back_to_settings"), CallbackData: "settings_main"}},
+			},
+		},
+	})
+	return err
+}
+
+func (h *CommandHandler) setActivityTracking(bot *gotgbot.Bot, ctx *ext.Context, enabled bool) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	if err := h.services.Engagement.SetActivityTracking(context.Background(), userID, enabled); err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Bool("enabled", enabled).Msg("Failed to update activity tracking")
+		errorText := h.services.Localization.T(context.Background(), userLang, "activity_tracking_failed")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorText, nil)
+		return err
+	}
+
+	key := "activity_tracking_disabled"
+	if enabled {
+		key = "activity_tracking_enabled"
+	}
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, key), &gotgbot.SendMessageOpts{
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
+			InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+				{{Text: "🔙 Back to Notifications", CallbackData: "settings_notifications"}},
+			},
+		},
+	})
+	return err
+}
+
+// Digest send-time suggestion callback handler
+func (h *CommandHandler) handleDigestCallback(bot *gotgbot.Bot, ctx *ext.Context, action string, params []string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	var text string
+	switch action {
+	case "accept":
+		if len(params) < 2 {
+			return fmt.Errorf("invalid digest accept callback: missing parameters")
+		}
+		subscriptionID, err := uuid.Parse(params[0])
+		if err != nil {
+			return fmt.Errorf("invalid subscription ID: %w", err)
+		}
+		timeOfDay := params[1]
+
+		if err := h.services.Engagement.AcceptDigestTimeSuggestion(context.Background(), userID, subscriptionID, timeOfDay); err != nil {
+			h.logger.Error().Err(err).Int64("user_id", userID).Str("subscription_id", params[0]).Msg("Failed to move daily digest")
+			text = h.services.Localization.T(context.Background(), userLang, "digest_suggestion_failed")
+		} else {
+			text = h.services.Localization.T(context.Background(), userLang, "digest_suggestion_accepted", timeOfDay)
+		}
+	case "decline":
+		text = h.services.Localization.T(context.Background(), userLang, "digest_suggestion_declined")
+	default:
+		h.logger.Warn().Str("action", action).Msg("Unknown digest callback action")
+		return nil
+	}
+
+	_, _, err := bot.EditMessageText(text, &gotgbot.EditMessageTextOpts{
+		ChatId:    ctx.EffectiveChat.Id,
+		MessageId: ctx.CallbackQuery.Message.GetMessageId(),
+	})
+	return err
+}
+
 func (h *CommandHandler) handleAddNotification(bot *gotgbot.Bot, ctx *ext.Context, notificationType string) error {
 	userID := ctx.EffectiveUser.Id
 	h.logger.Info().Str("type", notificationType).Int64("user_id", userID).Msg("Adding notification")
 
 	// Check if user has a location set
-	locationName, _, _, err := h.services.User.GetUserLocation(context.Background(), userID)
+	locationName, _, _, err := h.getUserLocation(ctx, userID)
 	if err != nil || locationName == "" {
-		userLang := h.getUserLanguage(context.Background(), userID)
+		userLang := h.getUserLanguage(ctx, userID)
 		errorMsg := h.services.Localization.T(context.Background(), userLang, "location_required_notifications")
 		_, err := bot.SendMessage(ctx.EffectiveChat.Id,
 			errorMsg,
@@ -3654,24 +5491,13 @@ Choose your preferred time:`, emoji, description, description, locationName)
 	frequency := getNotificationFrequency(notificationType)
 
 	// Create time selection buttons
+	timeButtons := subscriptionTimeKeyboard("", func(timeOfDay string) string {
+		return fmt.Sprintf("notifications_create_%s_%s_%s", notificationType, timeOfDay, frequency)
+	})
 	keyboard := gotgbot.InlineKeyboardMarkup{
-		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
-			{
-				{Text: "🌅 06:00", CallbackData: fmt.Sprintf("notifications_create_%s_06:00_%s", notificationType, frequency)},
-				{Text: "🌞 08:00", CallbackData: fmt.Sprintf("notifications_create_%s_08:00_%s", notificationType, frequency)},
-			},
-			{
-				{Text: "☀️ 12:00", CallbackData: fmt.Sprintf("notifications_create_%s_12:00_%s", notificationType, frequency)},
-				{Text: "🌅 18:00", CallbackData: fmt.Sprintf("notifications_create_%s_18:00_%s", notificationType, frequency)},
-			},
-			{
-				{Text: "🌙 20:00", CallbackData: fmt.Sprintf("notifications_create_%s_20:00_%s", notificationType, frequency)},
-				{Text: "🌃 22:00", CallbackData: fmt.Sprintf("notifications_create_%s_22:00_%s", notificationType, frequency)},
-			},
-			{
-				{Text: "🔙 Back", CallbackData: "settings_notifications"},
-			},
-		},
+		InlineKeyboard: append(timeButtons, []gotgbot.InlineKeyboardButton{
+			{Text: "🔙 Back", CallbackData: "settings_notifications"},
+		}),
 	}
 
 	_, err = bot.SendMessage(ctx.EffectiveChat.Id, message, &gotgbot.SendMessageOpts{
@@ -3710,16 +5536,29 @@ func (h *CommandHandler) createNotification(bot *gotgbot.Bot, ctx *ext.Context,
 		freq = models.FrequencyDaily
 	}
 
-	// Create the subscription
-	_, err := h.services.Subscription.CreateSubscription(context.Background(), userID, subscriptionType, freq, timeOfDay)
+	// Create the subscription; weekly digests also get the user's first day of the week
+	var subscription *models.Subscription
+	var err error
+	if subscriptionType == models.SubscriptionWeekly {
+		var user *models.User
+		if user, err = h.getUser(ctx, userID); err == nil {
+			subscription, err = h.services.Subscription.CreateWeeklySubscription(context.Background(), user, timeOfDay)
+		}
+	} else {
+		subscription, err = h.services.Subscription.CreateSubscription(context.Background(), userID, subscriptionType, freq, timeOfDay)
+	}
 	if err != nil {
 		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to create subscription")
+		if errors.Is(err, services.ErrInvalidTimeOfDay) {
+			return h.sendSubscriptionFailed(bot, ctx, err)
+		}
 		_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Error creating notification. Please try again.", nil)
 		return err
 	}
 
 	message := fmt.Sprintf("✅ *Notification Created!*\n\n%s %s notifications will be sent at %s every day.\n\nYou can manage all your notifications in Settings → Notifications.",
-		getNotificationEmoji(subscriptionType), subscriptionType.String(), timeOfDay)
+		getNotificationEmoji(subscriptionType), subscriptionType.String(), timeOfDay) +
+		h.deliverToChat(ctx, subscription, h.getUserLanguage(ctx, userID))
 
 	keyboard := gotgbot.InlineKeyboardMarkup{
 		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
@@ -3761,6 +5600,7 @@ func (h *CommandHandler) handleManageNotifications(bot *gotgbot.Bot, ctx *ext.Co
 
 	message := "⚙️ *Manage Your Notifications*\n\n*Active Notifications:*\n"
 	var keyboard [][]gotgbot.InlineKeyboardButton
+	timezone := h.subscriptionTimezone(ctx, userID)
 
 	for i, sub := range subscriptions {
 		status := "✅"
@@ -3769,7 +5609,7 @@ func (h *CommandHandler) handleManageNotifications(bot *gotgbot.Bot, ctx *ext.Co
 		}
 		message += fmt.Sprintf("%d. %s %s %s - %s at %s\n",
 			i+1, getNotificationEmoji(sub.SubscriptionType), status,
-			sub.SubscriptionType.String(), sub.Frequency.String(), sub.TimeOfDay)
+			sub.SubscriptionType.String(), sub.Frequency.String(), subscriptionTimeLabel(sub.TimeOfDay, timezone))
 
 		// Add toggle button
 		toggleText := "❌ Disable"
@@ -3778,11 +5618,16 @@ func (h *CommandHandler) handleManageNotifications(bot *gotgbot.Bot, ctx *ext.Co
 			toggleText = "✅ Enable"
 		}
 
-		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
+		row := []gotgbot.InlineKeyboardButton{
 			{Text: fmt.Sprintf("%s %s", getNotificationEmoji(sub.SubscriptionType), sub.SubscriptionType.String()), CallbackData: "notifications_info_display"},
-			{Text: toggleText, CallbackData: fmt.Sprintf("notifications_%s_%s", toggleAction, sub.ID.String())},
-			{Text: "🗑️", CallbackData: fmt.Sprintf("notifications_delete_%s", sub.ID.String())},
-		})
+		}
+		if subscriptionEditable(&sub) {
+			row = append(row, gotgbot.InlineKeyboardButton{Text: "✏️", CallbackData: fmt.Sprintf("sub_edit_%s", sub.ID.String())})
+		}
+		keyboard = append(keyboard, append(row,
+			gotgbot.InlineKeyboardButton{Text: toggleText, CallbackData: fmt.Sprintf("notifications_%s_%s", toggleAction, sub.ID.String())},
+			gotgbot.InlineKeyboardButton{Text: "🗑️", CallbackData: fmt.Sprintf("notifications_delete_%s", sub.ID.String())},
+		))
 	}
 
 	keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
@@ -3888,60 +5733,122 @@ func (h *CommandHandler) deleteNotification(bot *gotgbot.Bot, ctx *ext.Context,
 	return err
 }
 
-// Export callback handler
+// Export callback handler. Time-series exports ask for a period after the data
+// type; the period travels as a range key in the callback data of the formats.
 func (h *CommandHandler) handleExportCallback(bot *gotgbot.Bot, ctx *ext.Context, subAction string, parts []string) error {
 	switch subAction {
-	case "weather", "alerts", "subscriptions", "all":
-		return h.showExportFormatOptions(bot, ctx, subAction)
-	case "format":
+	case "weather", "alerts", "all":
+		return h.showExportRangeOptions(bot, ctx, subAction)
+	case "subscriptions":
+		return h.showExportFormatOptions(bot, ctx, subAction, "", false)
+	case "range":
+		if len(parts) < 2 {
+			return fmt.Errorf("invalid export range callback: missing parameters")
+		}
+		if parts[1] == exportRangeCustom {
+			return h.startCustomExportRange(bot, ctx, parts[0])
+		}
+		return h.showExportFormatOptions(bot, ctx, parts[0], parts[1], false)
+	case "cancel":
+		return h.cancelExportRange(bot, ctx)
+	case "since":
+		if len(parts) < 1 {
+			return fmt.Errorf("invalid incremental export callback: missing parameters")
+		}
+		return h.showExportFormatOptions(bot, ctx, parts[0], "", true)
+	case "format", "sinceformat":
 		if len(parts) < 2 {
 			return fmt.Errorf("invalid export format callback: missing parameters")
 		}
 		exportType := parts[0]
 		format := parts[1]
-		return h.processExportRequest(bot, ctx, exportType, format)
+		rangeKey := ""
+		if len(parts) > 2 {
+			rangeKey = parts[2]
+		}
+		return h.processExportRequest(bot, ctx, exportType, format, rangeKey, subAction == "sinceformat")
 	default:
 		h.logger.Warn().Str("sub_action", subAction).Msg("Unknown export callback subaction")
 		return nil
 	}
 }
 
-func (h *CommandHandler) showExportFormatOptions(bot *gotgbot.Bot, ctx *ext.Context, exportType string) error {
-	keyboard := gotgbot.InlineKeyboardMarkup{
-		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
-			{
-				{Text: "📄 JSON", CallbackData: fmt.Sprintf("export_format_%s_json", exportType)},
-				{Text: "📊 CSV", CallbackData: fmt.Sprintf("export_format_%s_csv", exportType)},
-			},
-			{
-				{Text: "📝 TXT", CallbackData: fmt.Sprintf("export_format_%s_txt", exportType)},
-			},
-			{
-				{Text: "🔙 Back", CallbackData: "settings_export"},
-			},
-		},
-	}
-
-	var dataTypeText string
+// exportDataTypeText names the data type of an export
+func exportDataTypeText(exportType string) string {
 	switch exportType {
 	case "weather":
-		dataTypeText = "🌤️ Weather Data"
+		return "🌤️ Weather Data"
 	case "alerts":
-		dataTypeText = "⚠️ Alerts"
+		return "⚠️ Alerts"
 	case "subscriptions":
-		dataTypeText = "📋 Subscriptions"
+		return "📋 Subscriptions"
 	case "all":
-		dataTypeText = "📦 All Data"
+		return "📦 All Data"
 	default:
-		dataTypeText = "Data"
+		return "Data"
+	}
+}
+
+func (h *CommandHandler) showExportFormatOptions(bot *gotgbot.Bot, ctx *ext.Context, exportType, rangeKey string, incremental bool) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	formatAction := "format"
+	if incremental {
+		formatAction = "sinceformat"
+	}
+	suffix := ""
+	if rangeKey != "" {
+		suffix = "_" + rangeKey
+	}
+
+	backCallback := "settings_export"
+	if exportType != "subscriptions" {
+		backCallback = "export_" + exportType
+	}
+
+	rows := [][]gotgbot.InlineKeyboardButton{
+		{
+			{Text: "📄 JSON", CallbackData: fmt.Sprintf("export_%s_%s_json%s", formatAction, exportType, suffix)},
+			{Text: "📊 CSV", CallbackData: fmt.Sprintf("export_%s_%s_csv%s", formatAction, exportType, suffix)},
+		},
+		{
+			{Text: "📝 TXT", CallbackData: fmt.Sprintf("export_%s_%s_txt%s", formatAction, exportType, suffix)},
+		},
+		{
+			{Text: "🔙 Back", CallbackData: backCallback},
+		},
 	}
+	keyboard := gotgbot.InlineKeyboardMarkup{InlineKeyboard: rows}
 
 	text := fmt.Sprintf("📊 *Export %s*\n\n"+
 		"Choose the export format:\n\n"+
 		"📄 *JSON* - Machine-readable format for technical use\n"+
 		"📊 *CSV* - Spreadsheet-compatible format\n"+
 		"📝 *TXT* - Human-readable text format\n\n"+
-		"The exported file will be sent to you via Telegram.", dataTypeText)
+		"The exported file will be sent to you via Telegram.", exportDataTypeText(exportType))
+
+	if rangeKey != "" {
+		text += "\n\n" + h.services.Localization.T(context.Background(), userLang, "export_range_selected", h.describeExportRange(rangeKey, userLang))
+	}
+	if incremental {
+		lastExport, err := h.services.Export.GetLastExportTime(context.Background(), userID, services.ExportType(exportType))
+		if err != nil {
+			h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to get last export time")
+		}
+		if lastExport != nil {
+			text += "\n\n" + h.services.Localization.T(context.Background(), userLang, "export_incremental_note", lastExport.Format("2006-01-02 15:04 UTC"))
+		}
+	}
+
+	// A custom range is entered as a text reply, which has no message to edit
+	if ctx.CallbackQuery == nil {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+			ParseMode:   "Markdown",
+			ReplyMarkup: keyboard,
+		})
+		return err
+	}
 
 	_, _, err := bot.EditMessageText(text, &gotgbot.EditMessageTextOpts{
 		ChatId:      ctx.EffectiveChat.Id,
@@ -3953,11 +5860,16 @@ func (h *CommandHandler) showExportFormatOptions(bot *gotgbot.Bot, ctx *ext.Cont
 	return err
 }
 
-func (h *CommandHandler) processExportRequest(bot *gotgbot.Bot, ctx *ext.Context, exportType, format string) error {
+func (h *CommandHandler) processExportRequest(bot *gotgbot.Bot, ctx *ext.Context, exportType, format, rangeKey string, incremental bool) error {
 	userID := ctx.EffectiveUser.Id
 
+	window, err := exportRangeFromKey(rangeKey, time.Now())
+	if err != nil {
+		return fmt.Errorf("invalid export range %q: %w", rangeKey, err)
+	}
+
 	// Show processing message
-	_, _, err := bot.EditMessageText("🔄 *Preparing your data export...*\n\nThis may take a few moments.", &gotgbot.EditMessageTextOpts{
+	_, _, err = bot.EditMessageText("🔄 *Preparing your data export...*\n\nThis may take a few moments.", &gotgbot.EditMessageTextOpts{
 		ChatId:    ctx.EffectiveChat.Id,
 		MessageId: ctx.CallbackQuery.Message.GetMessageId(),
 		ParseMode: "Markdown",
@@ -3995,8 +5907,8 @@ func (h *CommandHandler) processExportRequest(bot *gotgbot.Bot, ctx *ext.Context
 	}
 
 	// Generate export
-	userLang := h.getUserLanguage(context.Background(), userID)
-	buffer, filename, err := h.services.Export.ExportUserData(context.Background(), userID, serviceExportType, serviceFormat, userLang)
+	userLang := h.getUserLanguage(ctx, userID)
+	export, err := h.services.Export.PrepareExport(context.Background(), userID, serviceExportType, serviceFormat, userLang, incremental, window)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to export user data")
 
@@ -4010,43 +5922,40 @@ func (h *CommandHandler) processExportRequest(bot *gotgbot.Bot, ctx *ext.Context
 		}
 		return err
 	}
-
-	// Create a temporary file for sending
-	tempFile, err := os.CreateTemp("", filename)
-	if err != nil {
-		h.logger.Error().Err(err).Msg("Failed to create temporary file for export")
-		return fmt.Errorf("failed to create temporary file: %w", err)
-	}
-	defer func() {
-		if err := os.Remove(tempFile.Name()); err != nil { // #nosec G703
-			h.logger.Warn().Err(err).Str("file", tempFile.Name()).Msg("Failed to remove temporary file")
-		}
-	}()
-	defer func() {
-		if err := tempFile.Close(); err != nil {
-			h.logger.Warn().Err(err).Str("file", tempFile.Name()).Msg("Failed to close temporary file")
-		}
+	filename := export.Filename
+
+	// Stream the file into the upload as it is generated, so a large export is
+	// never held in memory whole
+	reader, writer := io.Pipe()
+	var fileSize int64
+	var writeErr error
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		fileSize, writeErr = h.services.Export.WriteExport(context.Background(), writer, export)
+		writer.CloseWithError(writeErr)
 	}()
 
-	// Write buffer to temporary file
-	if _, err := buffer.WriteTo(tempFile); err != nil {
-		h.logger.Error().Err(err).Msg("Failed to write export data to temporary file")
-		return fmt.Errorf("failed to write to temporary file: %w", err)
-	}
-
-	// Reset file pointer to beginning
-	if _, err := tempFile.Seek(0, 0); err != nil {
-		h.logger.Error().Err(err).Msg("Failed to reset file pointer")
-		return fmt.Errorf("failed to reset file pointer: %w", err)
-	}
-
 	// Send the file
-	_, err = bot.SendDocument(ctx.EffectiveChat.Id, gotgbot.InputFileByReader(filename, tempFile), &gotgbot.SendDocumentOpts{
+	_, err = bot.SendDocument(ctx.EffectiveChat.Id, gotgbot.InputFileByReader(filename, reader), &gotgbot.SendDocumentOpts{
 		Caption:   fmt.Sprintf("📊 Your %s export in %s format", exportType, format),
 		ParseMode: "Markdown",
 	})
 
+	// Closing the reader stops the generation of a file the upload gave up on
+	_ = reader.Close()
+	<-written
+	if writeErr != nil && !errors.Is(writeErr, io.ErrClosedPipe) {
+		h.logger.Error().Err(writeErr).Msg("Failed to generate export file")
+	}
+
 	if err != nil {
+		// The bot cannot post in this chat; editing the status message would fail too
+		if errors.Is(err, services.ErrChatUnwritable) {
+			h.logger.Info().Int64("chat_id", ctx.EffectiveChat.Id).Msg("Skipped export delivery, bot cannot post in chat")
+			return nil
+		}
+
 		h.logger.Error().Err(err).Msg("Failed to send export file")
 
 		_, _, editErr := bot.EditMessageText("❌ *Export Failed*\n\nSorry, there was an error sending your export file. Please try again later.", &gotgbot.EditMessageTextOpts{
@@ -4060,6 +5969,9 @@ func (h *CommandHandler) processExportRequest(bot *gotgbot.Bot, ctx *ext.Context
 		return err
 	}
 
+	// Only a delivered export moves the incremental cursor
+	h.services.Export.CommitExport(context.Background(), export)
+
 	// Update the message to show success
 	_, _, err = bot.EditMessageText("✅ *Export Complete*\n\nYour data export has been sent as a file above.", &gotgbot.EditMessageTextOpts{
 		ChatId:    ctx.EffectiveChat.Id,
@@ -4072,7 +5984,7 @@ func (h *CommandHandler) processExportRequest(bot *gotgbot.Bot, ctx *ext.Context
 		Str("export_type", exportType).
 		Str("format", format).
 		Str("filename", filename).
-		Int("file_size", buffer.Len()).
+		Int64("file_size", fileSize).
 		Msg("Data export completed successfully")
 
 	return err
@@ -4110,6 +6022,8 @@ func getNotificationEmoji(subscriptionType models.SubscriptionType) string {
 		return "⚡"
 	case models.SubscriptionExtreme:
 		return "🌪️"
+	case models.SubscriptionSunrise:
+		return "🌅"
 	default:
 		return "🔔"
 	}
@@ -4161,10 +6075,8 @@ func (h *CommandHandler) getLocalizedStatusText(ctx context.Context, language st
 
 // UnknownCommand handles unknown commands and suggests similar ones
 func (h *CommandHandler) UnknownCommand(bot *gotgbot.Bot, ctx *ext.Context) error {
-	user := ctx.EffectiveUser
-
 	// Auto-register new users and show welcome message
-	isNewUser := h.ensureUserRegistered(context.Background(), user)
+	isNewUser := h.ensureUserRegistered(ctx)
 	if isNewUser {
 		welcomeMsg := "👋 Welcome to ShoPogoda Weather Bot!\n\n"
 		welcomeMsg += "I see this is your first time here. Let's get started!\n\n"
@@ -4235,6 +6147,10 @@ func (h *CommandHandler) getAlertTypeTextLocalized(alertType models.AlertType, l
 		return h.services.Localization.T(context.Background(), language, "alert_type_snow")
 	case models.AlertStorm:
 		return h.services.Localization.T(context.Background(), language, "alert_type_storm")
+	case models.AlertPollen:
+		return h.services.Localization.T(context.Background(), language, "alert_type_pollen")
+	case models.AlertTide:
+		return h.services.Localization.T(context.Background(), language, "alert_type_tide")
 	default:
 		return h.services.Localization.T(context.Background(), language, "alert_type_unknown")
 	}
@@ -2,22 +2,34 @@ package commands
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"math"
 	"testing"
+	"time"
 
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/internal/locales"
 	"github.com/valpere/shopogoda/internal/models"
 	"github.com/valpere/shopogoda/internal/services"
+	"github.com/valpere/shopogoda/pkg/weather"
 	"github.com/valpere/shopogoda/tests/helpers"
 )
 
 func TestNew(t *testing.T) {
 	logger := zerolog.Nop()
 
-	handler := New(nil, &logger)
+	handler := New(&services.Services{}, &logger)
 
 	assert.NotNil(t, handler)
+	assert.NotNil(t, handler.renderer)
 	assert.NotNil(t, handler.logger)
 }
 
@@ -38,6 +50,8 @@ func TestGetAlertTypeText(t *testing.T) {
 		{"Rain", models.AlertRain, "Rain"},
 		{"Snow", models.AlertSnow, "Snow"},
 		{"Storm", models.AlertStorm, "Storm"},
+		{"Pollen", models.AlertPollen, "Pollen"},
+		{"Tide", models.AlertTide, "Tide"},
 	}
 
 	for _, tt := range tests {
@@ -230,6 +244,32 @@ func TestGetHealthRecommendation(t *testing.T) {
 	}
 }
 
+func TestGetPollenHealthRecommendation(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	handler := &CommandHandler{
+		services: &services.Services{Localization: services.NewLocalizationService(logger)},
+		logger:   logger,
+	}
+
+	tests := []struct {
+		risk     int
+		expected string
+	}{
+		{0, "pollen_health_none"},
+		{1, "pollen_health_very_low"},
+		{2, "pollen_health_low"},
+		{3, "pollen_health_moderate"},
+		{4, "pollen_health_high"},
+		{5, "pollen_health_very_high"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			assert.Equal(t, tt.expected, handler.getPollenHealthRecommendation(tt.risk, "en-US"))
+		})
+	}
+}
+
 func TestGetLocalizedUnitsText(t *testing.T) {
 	logger := helpers.NewSilentTestLogger()
 	locService := services.NewLocalizationService(logger)
@@ -561,12 +601,136 @@ func TestGetThresholdOptions(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			options := handler.getThresholdOptions(tt.alertType, tt.currentValue)
+			options := handler.getThresholdOptions(tt.alertType, tt.currentValue, weather.UnitsMetric)
 			tt.validate(t, options)
 		})
 	}
 }
 
+func TestGetThresholdOptions_Imperial(t *testing.T) {
+	handler := &CommandHandler{}
+
+	// 30°C is 86°F; options step 10°F and come back in Celsius
+	options := handler.getThresholdOptions(models.AlertTemperature, 30, weather.UnitsImperial)
+	require.NotEmpty(t, options)
+	found := false
+	for _, option := range options {
+		found = found || math.Abs(option-30) < 0.001
+		fahrenheit := weather.CelsiusToFahrenheit(option)
+		assert.GreaterOrEqual(t, fahrenheit, -0.001)
+		assert.LessOrEqual(t, fahrenheit, 100.001)
+	}
+	assert.True(t, found, "current threshold missing from %v", options)
+
+	// Wind steps 5 mph
+	options = handler.getThresholdOptions(models.AlertWindSpeed, weather.MphToKmh(15), weather.UnitsImperial)
+	require.NotEmpty(t, options)
+	for _, option := range options {
+		mph := weather.KmhToMph(option)
+		assert.InDelta(t, math.Round(mph/5)*5, mph, 0.001)
+	}
+
+	// Unitless alerts are the same in both systems
+	assert.Equal(t,
+		handler.getThresholdOptions(models.AlertHumidity, 60, weather.UnitsMetric),
+		handler.getThresholdOptions(models.AlertHumidity, 60, weather.UnitsImperial))
+}
+
+func TestAlertPreset(t *testing.T) {
+	label, data := alertPreset("alert_temp_high", models.AlertTemperature, 30, weather.UnitsMetric)
+	assert.Equal(t, "30.0°C", label)
+	assert.Equal(t, "alert_temp_high_30", data)
+
+	label, data = alertPreset("alert_temp_high", models.AlertTemperature, 30, weather.UnitsImperial)
+	assert.Equal(t, "86.0°F", label)
+	assert.Equal(t, "alert_temp_high_86", data)
+
+	// 50 km/h is 31.07 mph, offered as a whole 31 mph
+	label, data = alertPreset("alert_wind_high", models.AlertWindSpeed, 50, weather.UnitsImperial)
+	assert.Equal(t, "31.0 mph", label)
+	assert.Equal(t, "alert_wind_high_31", data)
+}
+
+func TestParseAlertThreshold(t *testing.T) {
+	// Entered in Fahrenheit, stored in Celsius
+	assert.InDelta(t, 30.0, parseAlertThreshold("86", 0, models.AlertTemperature, weather.UnitsImperial), 0.001)
+	assert.InDelta(t, 50.0, parseAlertThreshold("50", 0, models.AlertWindSpeed, weather.UnitsMetric), 0.001)
+	assert.InDelta(t, 16.09344, parseAlertThreshold("10", 0, models.AlertWindSpeed, weather.UnitsImperial), 0.001)
+
+	// Missing or invalid values fall back to the metric default unchanged
+	assert.Equal(t, 25.0, parseAlertThreshold("", 25, models.AlertTemperature, weather.UnitsImperial))
+	assert.Equal(t, 25.0, parseAlertThreshold("warm", 25, models.AlertTemperature, weather.UnitsImperial))
+}
+
+func TestParseCustomThreshold(t *testing.T) {
+	threshold, err := parseCustomThreshold(" 32,5 ", models.AlertTemperature, weather.UnitsMetric)
+	require.NoError(t, err)
+	assert.Equal(t, 32.5, threshold)
+
+	// Entered in Fahrenheit, stored in Celsius
+	threshold, err = parseCustomThreshold("86", models.AlertTemperature, weather.UnitsImperial)
+	require.NoError(t, err)
+	assert.InDelta(t, 30.0, threshold, 0.001)
+
+	// The range is the one the threshold options are offered in
+	threshold, err = parseCustomThreshold("45", models.AlertTemperature, weather.UnitsMetric)
+	assert.ErrorIs(t, err, errThresholdOutOfRange)
+	assert.Equal(t, 45.0, threshold)
+	_, err = parseCustomThreshold("300", models.AlertAirQuality, weather.UnitsMetric)
+	assert.NoError(t, err)
+	_, err = parseCustomThreshold("35", models.AlertWindSpeed, weather.UnitsImperial)
+	assert.ErrorIs(t, err, errThresholdOutOfRange)
+
+	for _, text := range []string{"warm", "", "NaN", "Inf", "25°"} {
+		_, err = parseCustomThreshold(text, models.AlertTemperature, weather.UnitsMetric)
+		assert.ErrorIs(t, err, errThresholdNotNumber, text)
+	}
+}
+
+func TestCustomThresholdRange(t *testing.T) {
+	low, high := customThresholdRange(models.AlertTemperature, weather.UnitsMetric)
+	assert.Equal(t, "-20.0°C", low)
+	assert.Equal(t, "40.0°C", high)
+
+	low, high = customThresholdRange(models.AlertWindSpeed, weather.UnitsImperial)
+	assert.Equal(t, "5.0 mph", low)
+	assert.Equal(t, "30.0 mph", high)
+
+	low, high = customThresholdRange(models.AlertHumidity, weather.UnitsImperial)
+	assert.Equal(t, "20%", low)
+	assert.Equal(t, "90%", high)
+}
+
+func TestExportRangeFromKey(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+
+	window, err := exportRangeFromKey("", now)
+	require.NoError(t, err)
+	assert.Nil(t, window)
+
+	window, err = exportRangeFromKey("all", now)
+	require.NoError(t, err)
+	assert.Equal(t, &services.ExportRange{}, window)
+
+	window, err = exportRangeFromKey("30", now)
+	require.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, -30), window.From)
+	assert.True(t, window.To.IsZero())
+
+	custom, err := services.ParseExportRange("2026-01-01 2026-03-31")
+	require.NoError(t, err)
+	key := customExportRangeKey(custom)
+	assert.Equal(t, "20260101-20260331", key)
+	window, err = exportRangeFromKey(key, now)
+	require.NoError(t, err)
+	assert.Equal(t, custom, *window)
+
+	for _, key := range []string{"14", "-1", "20260301-20260101", "20260101", "soon"} {
+		_, err := exportRangeFromKey(key, now)
+		assert.Error(t, err, key)
+	}
+}
+
 func TestGenerateRangeOptions(t *testing.T) {
 	handler := &CommandHandler{}
 
@@ -767,6 +931,8 @@ func TestGetAlertTypeTextLocalized(t *testing.T) {
 		{"Rain in English", models.AlertRain, "en-US", "alert_type_rain"},
 		{"Snow in English", models.AlertSnow, "en-US", "alert_type_snow"},
 		{"Storm in English", models.AlertStorm, "en-US", "alert_type_storm"},
+		{"Pollen in English", models.AlertPollen, "en-US", "alert_type_pollen"},
+		{"Tide in English", models.AlertTide, "en-US", "alert_type_tide"},
 		{"Unknown type in English", models.AlertType(999), "en-US", "alert_type_unknown"},
 	}
 
@@ -777,3 +943,527 @@ func TestGetAlertTypeTextLocalized(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatBroadcastProgress(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		logger:   logger,
+	}
+
+	running := services.BroadcastProgress{ID: "ab12cd34", Total: 10, Sent: 5, Failed: 1, Deactivated: 2}
+	text, keyboard := handler.formatBroadcastProgress(running, "en-US")
+	assert.Contains(t, text, "📤 8 of 10")
+	assert.Contains(t, text, "Blocked the bot: 2")
+	require.NotNil(t, keyboard)
+	assert.Equal(t, "admin_broadcast_cancel_ab12cd34", keyboard.InlineKeyboard[0][0].CallbackData)
+
+	done := running
+	done.Sent, done.Done = 7, true
+	text, keyboard = handler.formatBroadcastProgress(done, "en-US")
+	assert.Contains(t, text, "Successful: 7")
+	assert.Contains(t, text, "Total: 10")
+	assert.Nil(t, keyboard)
+
+	cancelled := running
+	cancelled.Done, cancelled.Cancelled = true, true
+	text, keyboard = handler.formatBroadcastProgress(cancelled, "en-US")
+	assert.Contains(t, text, "Broadcast cancelled")
+	assert.Contains(t, text, "Not sent: 2")
+	assert.Nil(t, keyboard)
+}
+
+func TestWeatherKeyboardButtons(t *testing.T) {
+	logger := zerolog.Nop()
+	nowcastOn := services.NewFeatureFlagService(&config.FeaturesConfig{Flags: "nowcast=on"}, nil, helpers.NewMockRedis().Client, &logger).
+		ForUser(context.Background(), 1)
+
+	forecast := weatherButton{"button_forecast", "forecast_Kyiv"}
+	hourly := weatherButton{"button_hourly", "hourly_0_Kyiv"}
+	air := weatherButton{"button_air_quality", "air_Kyiv"}
+	alert := weatherButton{"button_set_alert", "alert_Kyiv"}
+	nextHour := weatherButton{"button_next_hour", "nexthour_Kyiv"}
+	sensitive := weatherButton{"button_air_sensitive", "sensitive_Kyiv"}
+	uv := weatherButton{"button_uv_details", "uv_Kyiv"}
+	pin := weatherButton{"widget_pin_btn", "widget_pin"}
+	refresh := weatherButton{"button_refresh", "refresh_Kyiv"}
+	share := weatherButton{"button_share", "share_Kyiv"}
+
+	tests := []struct {
+		name     string
+		weather  services.WeatherData
+		features *services.Features
+		canPin   bool
+		expected [][]weatherButton
+	}{
+		{
+			name:     "calm weather keeps the base buttons and sharing",
+			weather:  services.WeatherData{Icon: "01d", UVIndex: 2, AQI: 40},
+			expected: [][]weatherButton{{forecast, hourly, air, alert}, {refresh, share}},
+		},
+		{
+			name:     "saved location in a private chat adds the pin button",
+			weather:  services.WeatherData{Icon: "01d", UVIndex: 2, AQI: 40},
+			canPin:   true,
+			expected: [][]weatherButton{{forecast, hourly, air, alert}, {pin, refresh, share}},
+		},
+		{
+			name:     "rain suggests the next hour",
+			weather:  services.WeatherData{Icon: "10d", AQI: 40},
+			features: nowcastOn,
+			expected: [][]weatherButton{{forecast, hourly, air, alert}, {nextHour, refresh, share}},
+		},
+		{
+			name:     "rain without the nowcast feature adds nothing",
+			weather:  services.WeatherData{Icon: "09n", AQI: 40},
+			expected: [][]weatherButton{{forecast, hourly, air, alert}, {refresh, share}},
+		},
+		{
+			name:     "high UV suggests UV details",
+			weather:  services.WeatherData{Icon: "01d", UVIndex: 6, AQI: 40},
+			expected: [][]weatherButton{{forecast, hourly, air, alert}, {uv, refresh, share}},
+		},
+		{
+			name:     "unhealthy air promotes air quality and adds guidance",
+			weather:  services.WeatherData{Icon: "50d", AQI: 101},
+			expected: [][]weatherButton{{air, forecast, hourly, alert}, {sensitive, refresh, share}},
+		},
+		{
+			name:     "AQI of exactly 100 is not unhealthy",
+			weather:  services.WeatherData{Icon: "50d", AQI: 100},
+			expected: [][]weatherButton{{forecast, hourly, air, alert}, {refresh, share}},
+		},
+		{
+			name:     "suggestions fill the second row in priority order before refresh and sharing",
+			weather:  services.WeatherData{Icon: "11d", UVIndex: 7, AQI: 160},
+			features: nowcastOn,
+			expected: [][]weatherButton{{air, forecast, hourly, alert}, {nextHour, sensitive, refresh, share}},
+		},
+		{
+			name:     "overflowing suggestions drop the lowest priority",
+			weather:  services.WeatherData{Icon: "11d", UVIndex: 7, AQI: 160},
+			features: nowcastOn,
+			canPin:   true,
+			expected: [][]weatherButton{{air, forecast, hourly, alert}, {nextHour, sensitive, refresh, share}},
+		},
+		{
+			name:     "pin keeps its place when fewer suggestions qualify",
+			weather:  services.WeatherData{Icon: "01d", UVIndex: 2, AQI: 120},
+			canPin:   true,
+			expected: [][]weatherButton{{air, forecast, hourly, alert}, {sensitive, pin, refresh, share}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buttons := weatherKeyboardButtons(weatherKeyboardInput{
+				Location: "Kyiv",
+				Weather:  &tt.weather,
+				Features: tt.features,
+				CanPin:   tt.canPin,
+			})
+
+			assert.Equal(t, tt.expected, buttons)
+		})
+	}
+}
+
+func TestCommandHandler_Settings_FetchesUserOnce(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	mockRedis := helpers.NewMockRedis()
+	logger := zerolog.Nop()
+	handler := New(newTestServices(mockDB, mockRedis), &logger)
+
+	// Settings reads the user, their language and their location; only the
+	// first lookup reaches Redis and the database
+	mockRedis.Mock.ExpectGet("user:42").RedisNil()
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "users"`).
+		WithArgs(int64(42), 1).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id", "language", "units", "role", "is_active", "location_name"}).
+			AddRow(42, "en-US", "metric", models.RoleUser, true, "Kyiv"))
+
+	mockCtx := helpers.NewMockContext(helpers.MockContextOptions{UserID: 42})
+
+	err := handler.Settings(helpers.NewMockBot().Bot, mockCtx.Context)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, services.UpdateUserFetches(mockCtx.Context))
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestCommandHandler_ChatSettingsInGroups(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	mockRedis := helpers.NewMockRedis()
+	logger := zerolog.Nop()
+	testServices := newTestServices(mockDB, mockRedis)
+	testServices.ChatSettings = services.NewChatSettingsService(mockDB.DB)
+	handler := New(testServices, &logger)
+
+	t.Run("group answers with its own settings, read once", func(t *testing.T) {
+		mockCtx := helpers.NewMockContext(helpers.MockContextOptions{UserID: 42, ChatID: -100123})
+		mockCtx.Context.EffectiveChat.Type = "supergroup"
+		require.True(t, isGroupChat(mockCtx.Context.EffectiveChat))
+
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "chat_locations" WHERE chat_id = \$1`).
+			WithArgs(int64(-100123), 1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"chat_id", "location_name", "latitude", "longitude", "language", "units"}).
+				AddRow(int64(-100123), "Lviv", 49.84, 24.03, "uk-UA", "imperial"))
+
+		location, err := handler.getChatLocation(mockCtx.Context, 42)
+		require.NoError(t, err)
+		assert.Equal(t, "Lviv", location)
+		assert.Equal(t, "uk-UA", handler.getChatLanguage(mockCtx.Context, 42))
+		assert.Equal(t, "imperial", handler.getChatUnits(mockCtx.Context, 42))
+	})
+
+	t.Run("private chat ignores chat settings", func(t *testing.T) {
+		mockCtx := helpers.NewMockContext(helpers.MockContextOptions{UserID: 42})
+		require.False(t, isGroupChat(mockCtx.Context.EffectiveChat))
+		assert.Nil(t, handler.getGroupSettings(mockCtx.Context))
+		assert.Empty(t, handler.deliverToChat(mockCtx.Context, &models.Subscription{UserID: 42}, "en-US"))
+	})
+
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestCommandHandler_SavedLocationPickerInGroups(t *testing.T) {
+	// A member with two saved locations, who would be asked to pick one
+	expectSavedLocations := func(mockDB *helpers.MockDB, mockRedis *helpers.MockRedis) {
+		mockRedis.Mock.ExpectGet("user:42").SetVal(`{"id":42,"language":"en-US"}`)
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "user_locations" WHERE user_id = \$1`).
+			WithArgs(int64(42)).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id", "user_id", "name"}).
+				AddRow(uuid.New(), int64(42), "Home street").
+				AddRow(uuid.New(), int64(42), "Work street"))
+	}
+	newHandler := func(mockDB *helpers.MockDB, mockRedis *helpers.MockRedis) *CommandHandler {
+		testServices := newTestServices(mockDB, mockRedis)
+		testServices.ChatSettings = services.NewChatSettingsService(mockDB.DB)
+		return New(testServices, helpers.NewSilentTestLogger())
+	}
+
+	t.Run("group answers for its own location without the picker", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		mockDB.Mock.MatchExpectationsInOrder(false)
+		mockRedis := helpers.NewMockRedis()
+		handler := newHandler(mockDB, mockRedis)
+		client := &recordingBotClient{}
+		bot := helpers.NewMockBot().Bot
+		bot.BotClient = client
+		mockCtx := helpers.NewMockContext(helpers.MockContextOptions{UserID: 42, ChatID: -100123})
+		mockCtx.Context.EffectiveChat.Type = "supergroup"
+
+		expectSavedLocations(mockDB, mockRedis)
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "chat_locations" WHERE chat_id = \$1`).
+			WithArgs(int64(-100123), 1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"chat_id", "location_name", "latitude", "longitude"}).
+				AddRow(int64(-100123), "Lviv", 49.84, 24.03))
+
+		location, picked, err := handler.resolveSavedLocation(bot, mockCtx.Context, "weather", "")
+		require.NoError(t, err)
+		assert.False(t, picked)
+		assert.Empty(t, location)
+		location, err = handler.getChatLocation(mockCtx.Context, 42)
+		require.NoError(t, err)
+		assert.Equal(t, "Lviv", location)
+
+		// A number is a place name in a group, not one of the member's places
+		location, picked, err = handler.resolveSavedLocation(bot, mockCtx.Context, "weather", "2")
+		require.NoError(t, err)
+		assert.False(t, picked)
+		assert.Equal(t, "2", location)
+
+		assert.Empty(t, client.methods)
+	})
+
+	t.Run("private chat offers the picker", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		mockRedis := helpers.NewMockRedis()
+		handler := newHandler(mockDB, mockRedis)
+		client := &recordingBotClient{}
+		bot := helpers.NewMockBot().Bot
+		bot.BotClient = client
+		mockCtx := helpers.NewMockContext(helpers.MockContextOptions{UserID: 42})
+
+		expectSavedLocations(mockDB, mockRedis)
+
+		_, picked, err := handler.resolveSavedLocation(bot, mockCtx.Context, "weather", "")
+		require.NoError(t, err)
+		assert.True(t, picked)
+		assert.Equal(t, []string{"sendMessage"}, client.methods)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestCommandHandler_PrivacyDeletion(t *testing.T) {
+	userID := int64(42)
+	expectUser := func(mockDB *helpers.MockDB) {
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users"`).
+			WithArgs(userID, 1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id", "username", "language"}).AddRow(userID, "user", "en-US"))
+	}
+
+	t.Run("asks twice and deletes nothing when cancelled", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		mockRedis := helpers.NewMockRedis()
+		handler := New(newTestServices(mockDB, mockRedis), helpers.NewSilentTestLogger())
+		mockBot := helpers.NewMockBot().Bot
+		mockCtx := helpers.NewMockContextWithCallback(userID, "cb", "privacy_delete")
+
+		// The user is read once for the update; no data is touched
+		expectUser(mockDB)
+		for _, action := range []string{"delete", "continue", "cancel"} {
+			require.NoError(t, handler.handlePrivacyCallback(mockBot, mockCtx.Context, action))
+		}
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("final confirmation deletes the account and its keys", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		mockRedis := helpers.NewMockRedis()
+		// The user lookup misses the cache in any order
+		mockRedis.Mock.MatchExpectationsInOrder(false)
+		handler := New(newTestServices(mockDB, mockRedis), helpers.NewSilentTestLogger())
+		mockBot := helpers.NewMockBot().Bot
+		mockCtx := helpers.NewMockContextWithCallback(userID, "cb", "privacy_erase")
+
+		expectUser(mockDB)
+		expectAccountDeletion(mockDB, mockRedis, userID)
+
+		require.NoError(t, handler.handlePrivacyCallback(mockBot, mockCtx.Context, "erase"))
+		mockDB.ExpectationsWereMet(t)
+		mockRedis.ExpectationsWereMet(t)
+	})
+}
+
+// expectAccountDeletion expects a user's data to be deleted from every table,
+// the users row to be anonymized and the user's keys to go
+func expectAccountDeletion(mockDB *helpers.MockDB, mockRedis *helpers.MockRedis, userID int64) {
+	userTables := []string{
+		"outbox_items", "alert_escalations", "weather_widgets", "environmental_alerts",
+		"alert_configs", "subscriptions", "quiet_periods", "weather_data", "weather_history",
+		"weather_records", "user_locations", "user_sessions", "export_cursors", "preset_applications", "feedback", "user_webhooks",
+	}
+
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectQuery(`SELECT "id" FROM "users" WHERE role = \$1 AND "users"\."deleted_at" IS NULL FOR UPDATE`).
+		WithArgs(models.RoleAdmin).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id"}))
+	for _, table := range userTables {
+		mockDB.Mock.ExpectExec(`DELETE FROM "` + table + `" WHERE user_id = \$1`).
+			WithArgs(userID).
+			WillReturnResult(helpers.NewResult(0, 1))
+	}
+	mockDB.Mock.ExpectExec(`DELETE FROM "account_transfers"`).
+		WithArgs(userID, userID).
+		WillReturnResult(helpers.NewResult(0, 0))
+	mockDB.Mock.ExpectExec(`UPDATE "users" SET "username"=\$1,"first_name"=\$2,"last_name"=\$3,.*"deleted_at"=\$30 WHERE id = \$31 AND "users"\."deleted_at" IS NULL`).
+		WillReturnResult(helpers.NewResult(0, 1))
+	mockDB.Mock.ExpectCommit()
+	mockRedis.Mock.ExpectScan(0, fmt.Sprintf("user:%d:*", userID), 100).SetVal(nil, 0)
+	mockRedis.Mock.ExpectDel(fmt.Sprintf("user:%d", userID), fmt.Sprintf("activity:hours:%d", userID),
+		fmt.Sprintf("alert_pending:%d", userID), fmt.Sprintf("export_pending:%d", userID)).SetVal(1)
+}
+
+func TestCommandHandler_DeleteMyData(t *testing.T) {
+	userID := int64(42)
+	pendingKey := "user:42:deletion_pending"
+	newHandler := func(t *testing.T) (*CommandHandler, *helpers.MockDB, *helpers.MockRedis) {
+		mockDB := helpers.NewMockDB(t)
+		t.Cleanup(func() { _ = mockDB.Close() })
+		mockRedis := helpers.NewMockRedis()
+		// The user lookup misses the cache in any order
+		mockRedis.Mock.MatchExpectationsInOrder(false)
+		return New(newTestServices(mockDB, mockRedis), helpers.NewSilentTestLogger()), mockDB, mockRedis
+	}
+	expectUser := func(mockDB *helpers.MockDB) {
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users"`).
+			WithArgs(userID, 1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id", "username", "language"}).AddRow(userID, "user", "en-US"))
+	}
+	typed := func(text string) *ext.Context {
+		return helpers.NewMockContext(helpers.MockContextOptions{UserID: userID, ChatID: userID, MessageText: text}).Context
+	}
+
+	t.Run("yes waits for DELETE to be typed", func(t *testing.T) {
+		handler, mockDB, mockRedis := newHandler(t)
+		mockCtx := helpers.NewMockContextWithCallback(userID, "cb", "deletemydata_yes")
+
+		expectUser(mockDB)
+		mockRedis.Mock.ExpectSet(pendingKey, 1, services.PendingDeletionTimeout).SetVal("OK")
+
+		require.NoError(t, handler.handleDeleteMyDataCallback(helpers.NewMockBot().Bot, mockCtx.Context, "yes"))
+		mockDB.ExpectationsWereMet(t)
+		mockRedis.ExpectationsWereMet(t)
+	})
+
+	t.Run("no deletes nothing", func(t *testing.T) {
+		handler, mockDB, mockRedis := newHandler(t)
+		mockCtx := helpers.NewMockContextWithCallback(userID, "cb", "deletemydata_no")
+
+		expectUser(mockDB)
+
+		require.NoError(t, handler.handleDeleteMyDataCallback(helpers.NewMockBot().Bot, mockCtx.Context, "no"))
+		mockDB.ExpectationsWereMet(t)
+		mockRedis.ExpectationsWereMet(t)
+	})
+
+	t.Run("typed DELETE deletes the account", func(t *testing.T) {
+		handler, mockDB, mockRedis := newHandler(t)
+
+		mockRedis.Mock.ExpectGetDel(pendingKey).SetVal("1")
+		expectUser(mockDB)
+		expectAccountDeletion(mockDB, mockRedis, userID)
+
+		handled, err := handler.handleDeleteMyDataInput(helpers.NewMockBot().Bot, typed("DELETE"), "DELETE")
+		require.NoError(t, err)
+		assert.True(t, handled)
+		mockDB.ExpectationsWereMet(t)
+		mockRedis.ExpectationsWereMet(t)
+	})
+
+	t.Run("anything else cancels", func(t *testing.T) {
+		handler, mockDB, mockRedis := newHandler(t)
+
+		mockRedis.Mock.ExpectSet(pendingKey, 1, services.PendingDeletionTimeout).SetVal("OK")
+		require.NoError(t, handler.services.User.StartPendingDeletion(context.Background(), userID))
+		mockRedis.Mock.ExpectGetDel(pendingKey).SetVal("1")
+		expectUser(mockDB)
+
+		handled, err := handler.handleDeleteMyDataInput(helpers.NewMockBot().Bot, typed("delete"), "delete")
+		require.NoError(t, err)
+		assert.True(t, handled)
+		mockDB.ExpectationsWereMet(t)
+		mockRedis.ExpectationsWereMet(t)
+	})
+
+	t.Run("other text skips the lookup when this process asked for nothing", func(t *testing.T) {
+		handler, mockDB, mockRedis := newHandler(t)
+
+		handled, err := handler.handleDeleteMyDataInput(helpers.NewMockBot().Bot, typed("Kyiv"), "Kyiv")
+		require.NoError(t, err)
+		assert.False(t, handled)
+		mockDB.ExpectationsWereMet(t)
+		mockRedis.ExpectationsWereMet(t)
+	})
+
+	t.Run("the last admin is refused", func(t *testing.T) {
+		handler, mockDB, mockRedis := newHandler(t)
+
+		mockRedis.Mock.ExpectGetDel(pendingKey).SetVal("1")
+		expectUser(mockDB)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`SELECT "id" FROM "users" WHERE role = \$1 AND "users"\."deleted_at" IS NULL FOR UPDATE`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(userID))
+		mockDB.Mock.ExpectRollback()
+
+		handled, err := handler.handleDeleteMyDataInput(helpers.NewMockBot().Bot, typed("DELETE"), "DELETE")
+		require.NoError(t, err)
+		assert.True(t, handled)
+		mockDB.ExpectationsWereMet(t)
+		mockRedis.ExpectationsWereMet(t)
+	})
+
+	t.Run("text without a pending deletion is left alone", func(t *testing.T) {
+		handler, mockDB, mockRedis := newHandler(t)
+
+		mockRedis.Mock.ExpectGetDel(pendingKey).RedisNil()
+
+		handled, err := handler.handleDeleteMyDataInput(helpers.NewMockBot().Bot, typed("DELETE"), "DELETE")
+		require.NoError(t, err)
+		assert.False(t, handled)
+		mockDB.ExpectationsWereMet(t)
+		mockRedis.ExpectationsWereMet(t)
+	})
+}
+
+// recordingBotClient answers like helpers.MockBotClient, records the API
+// methods called and fails edits with editErr when it is set
+type recordingBotClient struct {
+	helpers.MockBotClient
+	methods []string
+	editErr error
+}
+
+func (c *recordingBotClient) RequestWithContext(ctx context.Context, token string, method string, params map[string]any, opts *gotgbot.RequestOpts) (json.RawMessage, error) {
+	c.methods = append(c.methods, method)
+	if method == "editMessageText" && c.editErr != nil {
+		return nil, c.editErr
+	}
+	return c.MockBotClient.RequestWithContext(ctx, token, method, params, opts)
+}
+
+func TestCommandHandler_SendOrEdit(t *testing.T) {
+	logger := zerolog.Nop()
+	handler := New(&services.Services{}, &logger)
+	opts := &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{{{Text: "Forecast", CallbackData: "forecast_kyiv"}}}},
+	}
+	chat := gotgbot.Chat{Id: 456, Type: gotgbot.ChatTypePrivate}
+	callback := func(message gotgbot.MaybeInaccessibleMessage) *ext.Context {
+		return &ext.Context{
+			Update:        &gotgbot.Update{CallbackQuery: &gotgbot.CallbackQuery{Id: "1", Message: message}},
+			EffectiveUser: &gotgbot.User{Id: 123},
+			EffectiveChat: &chat,
+		}
+	}
+	newBot := func(editErr error) (*gotgbot.Bot, *recordingBotClient) {
+		client := &recordingBotClient{editErr: editErr}
+		return &gotgbot.Bot{Token: "test", BotClient: client}, client
+	}
+
+	t.Run("a command sends a new message", func(t *testing.T) {
+		bot, client := newBot(nil)
+		ctx := &ext.Context{
+			Update:        &gotgbot.Update{Message: &gotgbot.Message{Text: "/weather"}},
+			EffectiveUser: &gotgbot.User{Id: 123},
+			EffectiveChat: &chat,
+		}
+
+		require.NoError(t, handler.sendOrEdit(bot, ctx, "Kyiv: 12°C", opts))
+		assert.Equal(t, []string{"sendMessage"}, client.methods)
+	})
+
+	t.Run("a button edits its message", func(t *testing.T) {
+		bot, client := newBot(nil)
+		ctx := callback(gotgbot.Message{MessageId: 7, Chat: chat, Text: "Kyiv: 12°C"})
+
+		require.NoError(t, handler.sendOrEdit(bot, ctx, "Kyiv forecast", opts))
+		assert.Equal(t, []string{"editMessageText"}, client.methods)
+	})
+
+	t.Run("an unchanged message is not an error", func(t *testing.T) {
+		bot, _ := newBot(&gotgbot.TelegramError{Method: "editMessageText", Code: 400, Description: "Bad Request: message is not modified: specified new message content and reply markup are exactly the same"})
+		ctx := callback(gotgbot.Message{MessageId: 7, Chat: chat, Text: "Kyiv: 12°C"})
+
+		assert.NoError(t, handler.sendOrEdit(bot, ctx, "Kyiv: 12°C", opts))
+	})
+
+	t.Run("other edit failures are returned", func(t *testing.T) {
+		bot, _ := newBot(&gotgbot.TelegramError{Method: "editMessageText", Code: 400, Description: "Bad Request: message to edit not found"})
+		ctx := callback(gotgbot.Message{MessageId: 7, Chat: chat, Text: "Kyiv: 12°C"})
+
+		assert.Error(t, handler.sendOrEdit(bot, ctx, "Kyiv forecast", opts))
+	})
+
+	t.Run("buttons under photos and inaccessible messages send a new message", func(t *testing.T) {
+		for _, message := range []gotgbot.MaybeInaccessibleMessage{
+			gotgbot.Message{MessageId: 7, Chat: chat, Caption: "Radar"},
+			gotgbot.InaccessibleMessage{MessageId: 7, Chat: chat},
+			nil,
+		} {
+			bot, client := newBot(nil)
+			require.NoError(t, handler.sendOrEdit(bot, callback(message), "Kyiv forecast", opts))
+			assert.Equal(t, []string{"sendMessage"}, client.methods)
+		}
+	})
+}
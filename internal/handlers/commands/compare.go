@@ -0,0 +1,218 @@
+package commands
+
+import (
+	"context"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/services"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+// compareSeparators split the two places of /compare when either name has
+// several words, e.g. "/compare New York vs Los Angeles", strongest first: a
+// comma only separates the places when nothing else does, so that
+// "Paris, France; London" keeps the country with its city
+var compareSeparators = []string{";", "|", " vs. ", " vs ", ","}
+
+// comfortTemperature is the temperature, in °C, /compare considers ideal
+const comfortTemperature = 21.0
+
+// parseCompareArgs splits the argument of /compare into two places: around a
+// separator when there is one, otherwise as exactly two words. A single place
+// is returned second, with the first left empty for the saved location.
+func parseCompareArgs(text string) (string, string, bool) {
+	text = strings.TrimSpace(text)
+	lower := strings.ToLower(text)
+	for _, separator := range compareSeparators {
+		if i := strings.Index(lower, separator); i >= 0 {
+			first := strings.TrimSpace(text[:i])
+			second := strings.TrimSpace(text[i+len(separator):])
+			if second == "" {
+				first, second = "", first
+			}
+			return first, second, second != ""
+		}
+	}
+
+	words := strings.Fields(text)
+	if len(words) == 2 {
+		return words[0], words[1], true
+	}
+	return "", text, text != ""
+}
+
+// Compare shows the current weather of two places side by side, the saved
+// location being the first when only one is named. When one of them cannot be
+// fetched the other is still shown, followed by the reason.
+func (h *CommandHandler) Compare(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	first, second, ok := parseCompareArgs(h.parseLocationFromArgs(ctx))
+	if !ok {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "compare_usage"), &gotgbot.SendMessageOpts{
+			ParseMode: "Markdown",
+		})
+		return err
+	}
+	if first == "" {
+		savedLocation, _, _, err := h.getUserLocation(ctx, userID)
+		if err != nil || savedLocation == "" {
+			return h.sendLocationNeeded(bot, ctx, "compare_location_needed")
+		}
+		first = savedLocation
+	}
+
+	locations := [2]string{first, second}
+	var results [2]*services.WeatherData
+	var errs [2]error
+
+	// Each lookup records its own error so one failure does not hide the other
+	var group errgroup.Group
+	for i, location := range locations {
+		group.Go(func() error {
+			results[i], errs[i] = h.services.Weather.GetCurrentWeatherByLocation(weather.WithLanguage(h.weatherContext(ctx), userLang), location)
+			return nil
+		})
+	}
+	_ = group.Wait()
+
+	if errs[0] != nil && errs[1] != nil {
+		h.logger.Error().Err(errs[0]).Str("location", first).Str("other_location", second).Msg("Failed to get weather for comparison")
+		return h.sendWeatherError(bot, ctx, first, errs[0], "weather_error", first)
+	}
+
+	if err := h.services.User.IncrementWeatherRequestCounter(context.Background()); err != nil {
+		h.logger.Warn().Err(err).Msg("Failed to increment weather request counter")
+	}
+
+	text := h.formatComparisonMessage(results[0], results[1], userLang, h.getUserUnits(ctx, userID))
+	var forecastButtons []gotgbot.InlineKeyboardButton
+	for i, err := range errs {
+		if err == nil {
+			forecastButtons = append(forecastButtons, gotgbot.InlineKeyboardButton{
+				Text:         h.services.Localization.T(context.Background(), userLang, "compare_forecast_btn", results[i].LocationName),
+				CallbackData: h.locationCallback("forecast", locations[i]),
+			})
+			continue
+		}
+		h.logger.Warn().Err(err).Str("location", locations[i]).Msg("Failed to get weather for comparison")
+		fallback := h.services.Localization.T(context.Background(), userLang, "weather_error", locations[i])
+		text += "\n\n⚠️ " + services.WeatherErrorMessage(context.Background(), h.services.Localization, userLang, locations[i], err, fallback)
+	}
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{forecastButtons}},
+	})
+	return err
+}
+
+// compareRow is a line of the comparison table
+type compareRow struct {
+	Key   string
+	Value func(*services.WeatherData) string
+	// Penalty ranks the places on the row, lowest best; nil for rows with no
+	// better side
+	Penalty func(*services.WeatherData) float64
+}
+
+// compareRows are the lines of the comparison table
+func compareRows(units string) []compareRow {
+	comfort := func(temperature float64) float64 { return math.Abs(temperature - comfortTemperature) }
+	return []compareRow{
+		{"compare_temperature", func(w *services.WeatherData) string { return weather.FormatTemp(w.Temperature, units) },
+			func(w *services.WeatherData) float64 { return comfort(w.Temperature) }},
+		{"compare_feels_like", func(w *services.WeatherData) string { return weather.FormatTemp(w.FeelsLike, units) },
+			func(w *services.WeatherData) float64 { return comfort(w.FeelsLike) }},
+		{"compare_humidity", func(w *services.WeatherData) string { return weather.FormatPercent(float64(w.Humidity)) }, nil},
+		{"compare_wind", func(w *services.WeatherData) string { return weather.FormatSpeed(w.WindSpeed, units) },
+			func(w *services.WeatherData) float64 { return w.WindSpeed }},
+		{"compare_precipitation", func(w *services.WeatherData) string { return weather.FormatPrecipitation(w.Precipitation, units) },
+			func(w *services.WeatherData) float64 { return w.Precipitation }},
+		{"compare_aqi", func(w *services.WeatherData) string { return weather.FormatAQI(float64(w.AQI)) },
+			func(w *services.WeatherData) float64 { return float64(w.AQI) }},
+		{"compare_uv", func(w *services.WeatherData) string { return weather.FormatUV(w.UVIndex) },
+			func(w *services.WeatherData) float64 { return w.UVIndex }},
+	}
+}
+
+// formatComparisonMessage renders the weather of two places as a table in a
+// code block, which keeps its columns aligned, with a check mark on the more
+// pleasant side of each row. A nil place is left out.
+func (h *CommandHandler) formatComparisonMessage(w1, w2 *services.WeatherData, lang, units string) string {
+	var places []*services.WeatherData
+	for _, w := range []*services.WeatherData{w1, w2} {
+		if w != nil {
+			places = append(places, w)
+		}
+	}
+
+	names := make([]string, 0, len(places))
+	header := []string{""}
+	for _, place := range places {
+		names = append(names, markdown.EscapeBold(place.LocationName))
+		header = append(header, strings.ReplaceAll(place.LocationName, "`", "'"))
+	}
+
+	rows := [][]string{header}
+	for _, row := range compareRows(units) {
+		cells := []string{h.services.Localization.T(context.Background(), lang, row.Key)}
+		for _, place := range places {
+			cells = append(cells, row.Value(place))
+		}
+		if row.Penalty != nil && len(places) == 2 {
+			first, second := row.Penalty(places[0]), row.Penalty(places[1])
+			switch {
+			case first < second:
+				cells[1] += " ✓"
+			case second < first:
+				cells[2] += " ✓"
+			}
+		}
+		rows = append(rows, cells)
+	}
+
+	title := h.services.Localization.T(context.Background(), lang, "compare_title", strings.Join(names, " vs "))
+	text := title + "\n\n```\n" + formatTable(rows) + "\n```"
+	if len(places) == 2 {
+		text += "\n" + h.services.Localization.T(context.Background(), lang, "compare_legend")
+	}
+	return text
+}
+
+// formatTable pads the cells of each column to a common width
+func formatTable(rows [][]string) string {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+
+	lines := make([]string, 0, len(rows))
+	for _, row := range rows {
+		var b strings.Builder
+		for i, cell := range row {
+			if i > 0 {
+				b.WriteString("  ")
+			}
+			b.WriteString(cell)
+			if i < len(row)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
+			}
+		}
+		lines = append(lines, b.String())
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,181 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/internal/services"
+)
+
+// customAlertCancelCallback stops waiting for a custom alert threshold
+const customAlertCancelCallback = "alert_custom_cancel"
+
+// customAlertCallbacks are the "⚙️ Custom Threshold" buttons of each alert
+// type offered in the alert setup
+var customAlertCallbacks = map[models.AlertType]string{
+	models.AlertTemperature: "alert_temp_custom",
+	models.AlertWindSpeed:   "alert_wind_custom",
+	models.AlertAirQuality:  "alert_air_custom",
+	models.AlertHumidity:    "alert_humidity_custom",
+	models.AlertPollen:      "alert_pollen_custom",
+	models.AlertTide:        "alert_tide_custom",
+}
+
+var (
+	errThresholdNotNumber  = errors.New("threshold is not a number")
+	errThresholdOutOfRange = errors.New("threshold is out of range")
+)
+
+// startCustomAlert asks for the threshold of a custom alert; the user's next
+// text message is read as the threshold
+func (h *CommandHandler) startCustomAlert(bot *gotgbot.Bot, ctx *ext.Context, alertType models.AlertType) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	locationName, _, _, err := h.getUserLocation(ctx, userID)
+	if err != nil || locationName == "" {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "location_required_setlocation"), nil)
+		return err
+	}
+
+	if err := h.services.Alert.StartPendingAlert(context.Background(), userID, alertType, "gt"); err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to start custom alert")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "error_alert_create_failed"), nil)
+		return err
+	}
+
+	low, high := customThresholdRange(alertType, h.getUserUnits(ctx, userID))
+	text := h.services.Localization.T(context.Background(), userLang, "alert_custom_prompt",
+		h.getAlertTypeTextLocalized(alertType, userLang), low, high)
+	return h.sendCustomAlertReply(bot, ctx, text, userLang, "")
+}
+
+// handleCustomThresholdInput reads a text message as the threshold of the
+// custom alert the user started, if any. It reports whether the message was
+// taken as a threshold reply.
+func (h *CommandHandler) handleCustomThresholdInput(bot *gotgbot.Bot, ctx *ext.Context, text string) (bool, error) {
+	userID := ctx.EffectiveUser.Id
+
+	pending, err := h.services.Alert.GetPendingAlert(context.Background(), userID)
+	if pending == nil {
+		if err != nil {
+			h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to check pending custom alert")
+		}
+		return false, nil
+	}
+
+	userLang := h.getUserLanguage(ctx, userID)
+	units := h.getUserUnits(ctx, userID)
+	low, high := customThresholdRange(pending.AlertType, units)
+
+	if errors.Is(err, services.ErrPendingAlertExpired) {
+		if err := h.services.Alert.ClearPendingAlert(context.Background(), userID); err != nil {
+			h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to clear expired custom alert")
+		}
+		message := h.services.Localization.T(context.Background(), userLang, "alert_custom_expired")
+		return true, h.sendCustomAlertReply(bot, ctx, message, userLang, customAlertCallbacks[pending.AlertType])
+	}
+
+	threshold, err := parseCustomThreshold(text, pending.AlertType, units)
+	switch {
+	case errors.Is(err, errThresholdNotNumber):
+		message := h.services.Localization.T(context.Background(), userLang, "alert_custom_not_number", low, high)
+		return true, h.sendCustomAlertReply(bot, ctx, message, userLang, "")
+	case errors.Is(err, errThresholdOutOfRange):
+		message := h.services.Localization.T(context.Background(), userLang, "alert_custom_out_of_range",
+			services.FormatAlertValue(pending.AlertType, threshold, units), low, high)
+		return true, h.sendCustomAlertReply(bot, ctx, message, userLang, "")
+	}
+
+	condition := services.AlertCondition{Operator: pending.Operator, Value: threshold}
+	if exists, err := h.sendIfAlertExists(bot, ctx, pending.AlertType, condition); exists {
+		if err := h.services.Alert.ClearPendingAlert(context.Background(), userID); err != nil {
+			h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to clear custom alert")
+		}
+		return true, err
+	}
+	if _, err := h.services.Alert.CreateAlert(context.Background(), userID, pending.AlertType, condition); err != nil {
+		if errors.Is(err, services.ErrAlertLimitReached) {
+			// Another threshold would not fit either
+			if err := h.services.Alert.ClearPendingAlert(context.Background(), userID); err != nil {
+				h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to clear custom alert")
+			}
+		} else {
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to create custom alert")
+		}
+		return true, h.sendAlertCreateFailed(bot, ctx, err, h.services.Localization.T(context.Background(), userLang, "error_alert_create_failed"))
+	}
+	if err := h.services.Alert.ClearPendingAlert(context.Background(), userID); err != nil {
+		h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to clear custom alert")
+	}
+
+	message := h.services.Localization.T(context.Background(), userLang, "alert_custom_created",
+		h.getAlertTypeTextLocalized(pending.AlertType, userLang), services.FormatAlertValue(pending.AlertType, threshold, units))
+	return true, h.sendAlertCreated(bot, ctx, message)
+}
+
+// cancelCustomAlert stops waiting for a custom alert threshold
+func (h *CommandHandler) cancelCustomAlert(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	if err := h.services.Alert.ClearPendingAlert(context.Background(), userID); err != nil {
+		h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to cancel custom alert")
+	}
+
+	userLang := h.getUserLanguage(ctx, userID)
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "alert_custom_cancelled"), nil)
+	return err
+}
+
+// sendCustomAlertReply sends a message of the custom alert input with a
+// cancel button, and a button to start over when restartCallback is set
+func (h *CommandHandler) sendCustomAlertReply(bot *gotgbot.Bot, ctx *ext.Context, text, lang, restartCallback string) error {
+	var row []gotgbot.InlineKeyboardButton
+	if restartCallback != "" {
+		row = append(row, gotgbot.InlineKeyboardButton{
+			Text:         h.services.Localization.T(context.Background(), lang, "alert_custom_restart_btn"),
+			CallbackData: restartCallback,
+		})
+	}
+	row = append(row, gotgbot.InlineKeyboardButton{
+		Text:         h.services.Localization.T(context.Background(), lang, "alert_custom_cancel_btn"),
+		CallbackData: customAlertCancelCallback,
+	})
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{row}},
+	})
+	return err
+}
+
+// parseCustomThreshold reads a threshold typed in the user's units, with a
+// decimal point or comma, and returns it in metric. Thresholds must lie in
+// the range the threshold options of the alert type are offered in; one
+// outside it is still returned, with errThresholdOutOfRange.
+func parseCustomThreshold(text string, alertType models.AlertType, units string) (float64, error) {
+	value, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(text), ",", "."), 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, errThresholdNotNumber
+	}
+
+	threshold := services.AlertValueToMetric(alertType, value, units)
+	if scale, ok := thresholdScaleFor(alertType, units); ok && (value < scale.min || value > scale.max) {
+		return threshold, errThresholdOutOfRange
+	}
+	return threshold, nil
+}
+
+// customThresholdRange returns the lowest and highest threshold accepted for
+// an alert type, formatted in the user's units
+func customThresholdRange(alertType models.AlertType, units string) (string, string) {
+	scale, _ := thresholdScaleFor(alertType, units)
+	return services.FormatAlertValue(alertType, services.AlertValueToMetric(alertType, scale.min, units), units),
+		services.FormatAlertValue(alertType, services.AlertValueToMetric(alertType, scale.max, units), units)
+}
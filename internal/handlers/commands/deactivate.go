@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+)
+
+// Deactivate command pauses the user's account: scheduled weather, alerts and
+// widgets stop until the user reactivates it, but nothing is deleted
+func (h *CommandHandler) Deactivate(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	key := "deactivate_done"
+	if user, err := h.getUser(ctx, userID); err == nil && user.DeactivatedAt != nil {
+		key = "deactivate_already"
+	} else if err := h.services.User.DeactivateUser(context.Background(), userID); err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to deactivate user")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "deactivate_failed"), nil)
+		return err
+	}
+
+	return h.sendReactivateOffer(bot, ctx, userLang, key)
+}
+
+// Reactivate resumes an account paused with /deactivate. It answers both the
+// command and the button under the pause confirmation.
+func (h *CommandHandler) Reactivate(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	if ctx.CallbackQuery != nil {
+		if _, _, err := bot.EditMessageReplyMarkup(&gotgbot.EditMessageReplyMarkupOpts{
+			ChatId:    ctx.EffectiveChat.Id,
+			MessageId: ctx.CallbackQuery.Message.GetMessageId(),
+		}); err != nil {
+			h.logger.Warn().Err(err).Msg("Failed to remove reactivate button")
+		}
+	}
+
+	key := "reactivate_done"
+	if user, err := h.getUser(ctx, userID); err == nil && user.DeactivatedAt == nil && user.IsActive {
+		key = "reactivate_not_paused"
+	} else if err := h.services.User.ReactivateUser(context.Background(), userID); err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to reactivate user")
+		key = "reactivate_failed"
+	}
+	text := h.services.Localization.T(context.Background(), userLang, key)
+
+	// The alerts resume with the account, within the limit that applies now
+	if key == "reactivate_done" {
+		trimmed, err := h.services.Alert.TrimAlertsToLimit(context.Background(), userID)
+		if err != nil {
+			h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to apply the alert limit on reactivation")
+		} else if trimmed > 0 {
+			text += "\n\n" + h.services.Localization.TN(context.Background(), userLang, "reactivate_alerts_trimmed", trimmed, h.services.Alert.MaxAlertsPerUser())
+		}
+	}
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, nil)
+	return err
+}
+
+// sendReactivateOffer says the account is paused, with a button to resume it
+func (h *CommandHandler) sendReactivateOffer(bot *gotgbot.Bot, ctx *ext.Context, userLang, key string) error {
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, key), &gotgbot.SendMessageOpts{
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{{
+			{Text: h.services.Localization.T(context.Background(), userLang, "reactivate_btn"), CallbackData: "account_reactivate"},
+		}}},
+	})
+	return err
+}
+
+func (h *CommandHandler) handleAccountCallback(bot *gotgbot.Bot, ctx *ext.Context, action string) error {
+	switch action {
+	case "reactivate":
+		return h.Reactivate(bot, ctx)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/services"
+)
+
+const (
+	// exportRangeAll is the range key of an export from the user's first records
+	exportRangeAll = "all"
+	// exportRangeCustom is the range key asking for the dates as a text reply
+	exportRangeCustom = "custom"
+	// exportRangeDayLayout writes the days of a custom range into callback data
+	exportRangeDayLayout = "20060102"
+	// exportRangeCancelCallback stops waiting for a custom export range
+	exportRangeCancelCallback = "export_cancel"
+)
+
+// exportRangeDays are the ranges of the last days offered for an export
+var exportRangeDays = []int{7, 30, 90}
+
+var errExportRangeKey = errors.New("invalid export range")
+
+// showExportRangeOptions asks for the period of a time-series export, after
+// its data type was chosen
+func (h *CommandHandler) showExportRangeOptions(bot *gotgbot.Bot, ctx *ext.Context, exportType string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	var dayRow []gotgbot.InlineKeyboardButton
+	for _, days := range exportRangeDays {
+		dayRow = append(dayRow, gotgbot.InlineKeyboardButton{
+			Text:         h.services.Localization.T(context.Background(), userLang, fmt.Sprintf("export_range_%dd_btn", days)),
+			CallbackData: fmt.Sprintf("export_range_%s_%d", exportType, days),
+		})
+	}
+	rows := [][]gotgbot.InlineKeyboardButton{
+		dayRow,
+		{
+			{Text: h.services.Localization.T(context.Background(), userLang, "export_range_all_btn"), CallbackData: fmt.Sprintf("export_range_%s_%s", exportType, exportRangeAll)},
+			{Text: h.services.Localization.T(context.Background(), userLang, "export_range_custom_btn"), CallbackData: fmt.Sprintf("export_range_%s_%s", exportType, exportRangeCustom)},
+		},
+	}
+
+	// Exports can also continue from the previous export instead of covering a period
+	lastExport, err := h.services.Export.GetLastExportTime(context.Background(), userID, services.ExportType(exportType))
+	if err != nil {
+		h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to get last export time")
+	}
+	if lastExport != nil {
+		incrementalBtn := h.services.Localization.T(context.Background(), userLang, "export_incremental_btn", lastExport.Format("2006-01-02"))
+		rows = append(rows, []gotgbot.InlineKeyboardButton{
+			{Text: incrementalBtn, CallbackData: fmt.Sprintf("export_since_%s", exportType)},
+		})
+	}
+
+	rows = append(rows, []gotgbot.InlineKeyboardButton{
+		{Text: "🔙 Back", CallbackData: "settings_export"},
+	})
+
+	text := h.services.Localization.T(context.Background(), userLang, "export_range_title", exportDataTypeText(exportType))
+	_, _, err = bot.EditMessageText(text, &gotgbot.EditMessageTextOpts{
+		ChatId:      ctx.EffectiveChat.Id,
+		MessageId:   ctx.CallbackQuery.Message.GetMessageId(),
+		ParseMode:   "Markdown",
+		ReplyMarkup: gotgbot.InlineKeyboardMarkup{InlineKeyboard: rows},
+	})
+	return err
+}
+
+// exportRangeFromKey turns the range key of an export callback into the date
+// range of the export; the empty key keeps the default retention windows
+func exportRangeFromKey(key string, now time.Time) (*services.ExportRange, error) {
+	switch key {
+	case "":
+		return nil, nil
+	case exportRangeAll:
+		return &services.ExportRange{}, nil
+	}
+
+	if days, err := strconv.Atoi(key); err == nil {
+		if !slices.Contains(exportRangeDays, days) {
+			return nil, errExportRangeKey
+		}
+		window := services.ExportRangeLastDays(now, days)
+		return &window, nil
+	}
+
+	from, to, ok := strings.Cut(key, "-")
+	if !ok {
+		return nil, errExportRangeKey
+	}
+	window, err := services.ParseExportRange(exportRangeKeyDay(from) + " " + exportRangeKeyDay(to))
+	if err != nil {
+		return nil, err
+	}
+	return &window, nil
+}
+
+// exportRangeKeyDay rewrites a day of a custom range key as YYYY-MM-DD
+func exportRangeKeyDay(day string) string {
+	t, err := time.Parse(exportRangeDayLayout, day)
+	if err != nil {
+		return day
+	}
+	return t.Format("2006-01-02")
+}
+
+// customExportRangeKey is the range key of a custom range for callback data
+func customExportRangeKey(window services.ExportRange) string {
+	return window.From.Format(exportRangeDayLayout) + "-" + window.To.Format(exportRangeDayLayout)
+}
+
+// describeExportRange names the period of a range key for the user
+func (h *CommandHandler) describeExportRange(key, lang string) string {
+	if key == exportRangeAll {
+		return h.services.Localization.T(context.Background(), lang, "export_range_all")
+	}
+	if days, err := strconv.Atoi(key); err == nil {
+		return h.services.Localization.T(context.Background(), lang, "export_range_days", days)
+	}
+	if from, to, ok := strings.Cut(key, "-"); ok {
+		return exportRangeKeyDay(from) + " – " + exportRangeKeyDay(to)
+	}
+	return key
+}
+
+// startCustomExportRange asks for the dates of an export; the user's next
+// text message is read as its range
+func (h *CommandHandler) startCustomExportRange(bot *gotgbot.Bot, ctx *ext.Context, exportType string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	if err := h.services.Export.StartPendingExport(context.Background(), userID, services.ExportType(exportType)); err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to start custom export range")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "export_failed"), &gotgbot.SendMessageOpts{ParseMode: "Markdown"})
+		return err
+	}
+
+	text := h.services.Localization.T(context.Background(), userLang, "export_range_custom_prompt")
+	return h.sendExportRangeReply(bot, ctx, text, userLang, "")
+}
+
+// handleExportRangeInput reads a text message as the date range of the export
+// the user started, if any. It reports whether the message was taken as a
+// range reply.
+func (h *CommandHandler) handleExportRangeInput(bot *gotgbot.Bot, ctx *ext.Context, text string) (bool, error) {
+	userID := ctx.EffectiveUser.Id
+
+	pending, err := h.services.Export.GetPendingExport(context.Background(), userID)
+	if pending == nil {
+		if err != nil {
+			h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to check pending export range")
+		}
+		return false, nil
+	}
+
+	userLang := h.getUserLanguage(ctx, userID)
+	restartCallback := "export_" + string(pending.Type)
+
+	if errors.Is(err, services.ErrPendingExportExpired) {
+		if err := h.services.Export.ClearPendingExport(context.Background(), userID); err != nil {
+			h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to clear expired export range")
+		}
+		message := h.services.Localization.T(context.Background(), userLang, "export_range_expired")
+		return true, h.sendExportRangeReply(bot, ctx, message, userLang, restartCallback)
+	}
+
+	window, err := services.ParseExportRange(text)
+	switch {
+	case errors.Is(err, services.ErrExportRangeOrder):
+		return true, h.sendExportRangeReply(bot, ctx, h.services.Localization.T(context.Background(), userLang, "export_range_invalid_order"), userLang, "")
+	case errors.Is(err, services.ErrExportRangeTooLong):
+		return true, h.sendExportRangeReply(bot, ctx, h.services.Localization.T(context.Background(), userLang, "export_range_too_long"), userLang, "")
+	case err != nil:
+		return true, h.sendExportRangeReply(bot, ctx, h.services.Localization.T(context.Background(), userLang, "export_range_invalid_format"), userLang, "")
+	}
+
+	if err := h.services.Export.ClearPendingExport(context.Background(), userID); err != nil {
+		h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to clear export range")
+	}
+	return true, h.showExportFormatOptions(bot, ctx, string(pending.Type), customExportRangeKey(window), false)
+}
+
+// cancelExportRange stops waiting for a custom export range
+func (h *CommandHandler) cancelExportRange(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	if err := h.services.Export.ClearPendingExport(context.Background(), userID); err != nil {
+		h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to cancel export range")
+	}
+
+	userLang := h.getUserLanguage(ctx, userID)
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "export_range_cancelled"), nil)
+	return err
+}
+
+// sendExportRangeReply sends a message of the custom range input with a
+// cancel button, and a button to start over when restartCallback is set
+func (h *CommandHandler) sendExportRangeReply(bot *gotgbot.Bot, ctx *ext.Context, text, lang, restartCallback string) error {
+	var row []gotgbot.InlineKeyboardButton
+	if restartCallback != "" {
+		row = append(row, gotgbot.InlineKeyboardButton{
+			Text:         h.services.Localization.T(context.Background(), lang, "export_range_restart_btn"),
+			CallbackData: restartCallback,
+		})
+	}
+	row = append(row, gotgbot.InlineKeyboardButton{
+		Text:         h.services.Localization.T(context.Background(), lang, "export_range_cancel_btn"),
+		CallbackData: exportRangeCancelCallback,
+	})
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{row}},
+	})
+	return err
+}
@@ -0,0 +1,391 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/google/uuid"
+
+	"github.com/valpere/shopogoda/internal"
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/internal/services"
+)
+
+// feedbackListPreviewLength shortens each message of /feedbacklist so that a
+// full page stays within Telegram's message limit
+const feedbackListPreviewLength = 600
+
+// Feedback command handler: saves a message for the admins and forwards it
+// to them. Sent without text, it asks for the message and takes the user's
+// next one.
+func (h *CommandHandler) Feedback(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	args := ctx.Args()
+	if len(args) > 1 {
+		return h.submitFeedback(bot, ctx, strings.Join(args[1:], " "))
+	}
+
+	if err := h.services.Feedback.StartPendingFeedback(context.Background(), userID); err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to start feedback input")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "feedback_failed"), nil)
+		return err
+	}
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "feedback_prompt"), &gotgbot.SendMessageOpts{
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{{
+			{Text: h.services.Localization.T(context.Background(), userLang, "feedback_cancel_btn"), CallbackData: "feedback_cancel"},
+		}}},
+	})
+	return err
+}
+
+// handleFeedbackInput reads a text message as the feedback the user was asked
+// for, if any. It reports whether the message was taken as feedback.
+func (h *CommandHandler) handleFeedbackInput(bot *gotgbot.Bot, ctx *ext.Context, text string) (bool, error) {
+	userID := ctx.EffectiveUser.Id
+
+	pending, err := h.services.Feedback.TakePendingFeedback(context.Background(), userID)
+	if err != nil {
+		h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to check pending feedback")
+	}
+	if !pending {
+		return false, nil
+	}
+	return true, h.submitFeedback(bot, ctx, text)
+}
+
+// submitFeedback saves the user's feedback, forwards it to the admins and
+// thanks the user
+func (h *CommandHandler) submitFeedback(bot *gotgbot.Bot, ctx *ext.Context, text string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	feedback, err := h.services.Feedback.Submit(context.Background(), userID, ctx.EffectiveChat.Id, text)
+	if err != nil {
+		message := h.services.Localization.T(context.Background(), userLang, "feedback_failed")
+		switch {
+		case errors.Is(err, services.ErrFeedbackTooLong):
+			message = h.services.Localization.T(context.Background(), userLang, "feedback_too_long", services.MaxFeedbackLength)
+		case errors.Is(err, services.ErrFeedbackEmpty):
+			message = h.services.Localization.T(context.Background(), userLang, "feedback_prompt")
+		default:
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to save feedback")
+		}
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, message, nil)
+		return err
+	}
+
+	h.notifyAdminsOfFeedback(bot, ctx.EffectiveUser, feedback)
+	h.logger.Info().Int64("user_id", userID).Str("feedback_id", feedback.ID.String()).Msg("Feedback received")
+
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "feedback_thanks"), nil)
+	return err
+}
+
+// notifyAdminsOfFeedback forwards new feedback to every admin with buttons
+// to mark it resolved and to reply. The feedback is saved either way, so
+// failures are only logged.
+func (h *CommandHandler) notifyAdminsOfFeedback(bot *gotgbot.Bot, sender *gotgbot.User, feedback *models.Feedback) {
+	admins, err := h.services.User.GetAdmins(context.Background())
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get admins for feedback")
+		return
+	}
+
+	from := &models.User{ID: sender.Id, Username: sender.Username, FirstName: sender.FirstName, LastName: sender.LastName}
+	text := fmt.Sprintf("📝 *New feedback*\n\n👤 *From:* %s (ID: `%d`)\n\n%s",
+		markdown.Escape(userDisplayName(from)), sender.Id, markdown.Escape(feedback.Message))
+	opts := &gotgbot.SendMessageOpts{
+		ParseMode: "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{{
+			{Text: "✅ Mark Resolved", CallbackData: "feedback_resolve_" + feedback.ID.String()},
+			{Text: "💬 Reply", CallbackData: "feedback_reply_" + feedback.ID.String()},
+		}}},
+	}
+	for _, admin := range admins {
+		if _, err := bot.SendMessage(admin.ID, text, opts); err != nil {
+			h.logger.Warn().Err(err).Int64("admin_id", admin.ID).Str("feedback_id", feedback.ID.String()).Msg("Failed to forward feedback to admin")
+		}
+	}
+}
+
+// startFeedbackReply asks the admin for their reply to the feedback and
+// takes their next message
+func (h *CommandHandler) startFeedbackReply(bot *gotgbot.Bot, ctx *ext.Context, id uuid.UUID) error {
+	adminID := ctx.EffectiveUser.Id
+	feedback, err := h.services.Feedback.Get(context.Background(), id)
+	if errors.Is(err, services.ErrFeedbackNotFound) {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Feedback not found", nil)
+		return err
+	}
+	if err == nil {
+		err = h.services.Feedback.StartFeedbackReply(context.Background(), adminID, id)
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Int64("admin_id", adminID).Str("feedback_id", id.String()).Msg("Failed to start feedback reply")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Failed to start the reply", nil)
+		return err
+	}
+
+	text := fmt.Sprintf("💬 Send your reply to %s (ID: `%d`):\n\n_%s_",
+		markdown.Escape(userDisplayName(&feedback.User)), feedback.UserID, markdown.Escape(feedback.Message))
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ParseMode: "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{{
+			{Text: "❌ Cancel", CallbackData: "feedback_cancelreply"},
+		}}},
+	})
+	return err
+}
+
+// handleFeedbackReplyInput sends a text message of an admin as their reply
+// to the feedback they chose, if any, in the language of its sender. It
+// reports whether the message was taken as a reply.
+func (h *CommandHandler) handleFeedbackReplyInput(bot *gotgbot.Bot, ctx *ext.Context, text string) (bool, error) {
+	adminID := ctx.EffectiveUser.Id
+
+	id, pending, err := h.services.Feedback.TakeFeedbackReply(context.Background(), adminID)
+	if err != nil {
+		h.logger.Warn().Err(err).Int64("admin_id", adminID).Msg("Failed to check pending feedback reply")
+	}
+	if !pending {
+		return false, nil
+	}
+
+	feedback, err := h.services.Feedback.Get(context.Background(), id)
+	if err != nil {
+		h.logger.Error().Err(err).Int64("admin_id", adminID).Str("feedback_id", id.String()).Msg("Failed to load feedback to reply to")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Failed to send the reply", nil)
+		return true, err
+	}
+
+	chatID := feedback.ChatID
+	if chatID == 0 {
+		chatID = feedback.UserID
+	}
+	language := feedback.User.Language
+	if language == "" {
+		language = internal.DefaultLanguage
+	}
+	reply := h.services.Localization.T(context.Background(), language, "feedback_reply_header") + "\n\n" + markdown.Escape(text)
+	if _, err := bot.SendMessage(chatID, reply, &gotgbot.SendMessageOpts{ParseMode: "Markdown"}); err != nil {
+		h.logger.Warn().Err(err).Int64("admin_id", adminID).Str("feedback_id", id.String()).Msg("Failed to send feedback reply")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Failed to send the reply; the user may have blocked the bot", nil)
+		return true, err
+	}
+	h.logger.Info().Int64("admin_id", adminID).Str("feedback_id", id.String()).Msg("Feedback replied to")
+	h.auditAdminAction(ctx, services.AuditFeedbackReplied, feedback.UserID, map[string]any{"feedback_id": id.String(), "message": text})
+
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, "✅ Reply sent", &gotgbot.SendMessageOpts{
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{{
+			{Text: "✅ Mark Resolved", CallbackData: "feedback_resolve_" + id.String()},
+		}}},
+	})
+	return true, err
+}
+
+// ListFeedback command handler: pages through the unresolved feedback,
+// oldest first (Admin only)
+func (h *CommandHandler) ListFeedback(bot *gotgbot.Bot, ctx *ext.Context) error {
+	if !h.requireAdmin(bot, ctx) {
+		return nil
+	}
+	return h.showFeedbackPage(bot, ctx, 0, false)
+}
+
+// showFeedbackPage sends one page of the unresolved feedback, or edits the
+// message of its buttons into it. A page past the end, as left behind by
+// resolving its last item, shows the last page instead.
+func (h *CommandHandler) showFeedbackPage(bot *gotgbot.Bot, ctx *ext.Context, page int, edit bool) error {
+	items, total, err := h.services.Feedback.List(context.Background(), page)
+	if err == nil && len(items) == 0 && total > 0 {
+		page = feedbackPages(total) - 1
+		items, total, err = h.services.Feedback.List(context.Background(), page)
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to list feedback")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Failed to load feedback", nil)
+		return err
+	}
+
+	text := formatFeedbackPage(items, total, page)
+	keyboard := gotgbot.InlineKeyboardMarkup{InlineKeyboard: feedbackPageKeyboard(items, total, page)}
+	if edit {
+		_, _, err = bot.EditMessageText(text, &gotgbot.EditMessageTextOpts{
+			ChatId:      ctx.EffectiveChat.Id,
+			MessageId:   ctx.CallbackQuery.Message.GetMessageId(),
+			ParseMode:   "Markdown",
+			ReplyMarkup: keyboard,
+		})
+		return err
+	}
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &keyboard,
+	})
+	return err
+}
+
+// feedbackPages returns the number of pages of unresolved feedback
+func feedbackPages(total int64) int {
+	return max(1, int((total+services.FeedbackPageSize-1)/services.FeedbackPageSize))
+}
+
+// formatFeedbackPage renders a page of unresolved feedback, numbering the
+// items across pages
+func formatFeedbackPage(items []models.Feedback, total int64, page int) string {
+	if total == 0 {
+		return "✅ No unresolved feedback"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📝 *Unresolved feedback (%d)*\n\n", total)
+	for i, item := range items {
+		message := item.Message
+		if utf8.RuneCountInString(message) > feedbackListPreviewLength {
+			message = string([]rune(message)[:feedbackListPreviewLength]) + "…"
+		}
+		fmt.Fprintf(&b, "*%d.* %s (ID: `%d`), %s\n%s\n\n",
+			page*services.FeedbackPageSize+i+1, markdown.Escape(userDisplayName(&item.User)), item.UserID,
+			item.CreatedAt.UTC().Format("Jan 2 15:04 UTC"), markdown.Escape(message))
+	}
+	if pages := feedbackPages(total); pages > 1 {
+		fmt.Fprintf(&b, "Page %d/%d", page+1, pages)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// feedbackPageKeyboard offers resolving and replying to each item of the
+// page, by its number, and links the neighbouring pages
+func feedbackPageKeyboard(items []models.Feedback, total int64, page int) [][]gotgbot.InlineKeyboardButton {
+	var keyboard [][]gotgbot.InlineKeyboardButton
+
+	var resolveRow, replyRow []gotgbot.InlineKeyboardButton
+	for i, item := range items {
+		number := page*services.FeedbackPageSize + i + 1
+		resolveRow = append(resolveRow, gotgbot.InlineKeyboardButton{
+			Text:         fmt.Sprintf("✅ %d", number),
+			CallbackData: fmt.Sprintf("feedback_resolve_%s_%d", item.ID, page),
+		})
+		replyRow = append(replyRow, gotgbot.InlineKeyboardButton{
+			Text:         fmt.Sprintf("💬 %d", number),
+			CallbackData: "feedback_reply_" + item.ID.String(),
+		})
+	}
+	if len(resolveRow) > 0 {
+		keyboard = append(keyboard, resolveRow, replyRow)
+	}
+
+	var navRow []gotgbot.InlineKeyboardButton
+	if page > 0 {
+		navRow = append(navRow, gotgbot.InlineKeyboardButton{Text: "◀️ Previous", CallbackData: fmt.Sprintf("feedback_page_%d", page-1)})
+	}
+	if page < feedbackPages(total)-1 {
+		navRow = append(navRow, gotgbot.InlineKeyboardButton{Text: "Next ▶️", CallbackData: fmt.Sprintf("feedback_page_%d", page+1)})
+	}
+	if len(navRow) > 0 {
+		keyboard = append(keyboard, navRow)
+	}
+	return keyboard
+}
+
+// handleFeedbackCallback handles cancelling the feedback prompt and the
+// admin buttons: resolving from a forwarded message or from a page of
+// /feedbacklist, which then shows the page again, replying, and turning pages
+func (h *CommandHandler) handleFeedbackCallback(bot *gotgbot.Bot, ctx *ext.Context, action string, params []string) error {
+	if action == "cancel" {
+		userID := ctx.EffectiveUser.Id
+		if err := h.services.Feedback.ClearPendingFeedback(context.Background(), userID); err != nil {
+			h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to cancel feedback input")
+		}
+		userLang := h.getUserLanguage(ctx, userID)
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "feedback_cancelled"), nil)
+		return err
+	}
+
+	if !h.requireAdmin(bot, ctx) {
+		return nil
+	}
+
+	switch action {
+	case "reply":
+		if len(params) < 1 {
+			return nil
+		}
+		id, err := uuid.Parse(params[0])
+		if err != nil {
+			h.logger.Warn().Err(err).Str("feedback_id", params[0]).Msg("Invalid feedback in callback")
+			return nil
+		}
+		return h.startFeedbackReply(bot, ctx, id)
+	case "cancelreply":
+		adminID := ctx.EffectiveUser.Id
+		if err := h.services.Feedback.ClearFeedbackReply(context.Background(), adminID); err != nil {
+			h.logger.Warn().Err(err).Int64("admin_id", adminID).Msg("Failed to cancel feedback reply")
+		}
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, "Reply cancelled", nil)
+		return err
+	case "page":
+		if len(params) < 1 {
+			return nil
+		}
+		page, err := strconv.Atoi(params[0])
+		if err != nil {
+			h.logger.Warn().Err(err).Str("page", params[0]).Msg("Invalid feedback page")
+			return nil
+		}
+		return h.showFeedbackPage(bot, ctx, page, true)
+	case "resolve":
+		if len(params) < 1 {
+			return nil
+		}
+		id, err := uuid.Parse(params[0])
+		if err != nil {
+			h.logger.Warn().Err(err).Str("feedback_id", params[0]).Msg("Invalid feedback in callback")
+			return nil
+		}
+
+		adminID := ctx.EffectiveUser.Id
+		err = h.services.Feedback.Resolve(context.Background(), id, adminID)
+		switch {
+		case errors.Is(err, services.ErrFeedbackNotFound):
+			_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Feedback not found", nil)
+			return err
+		case errors.Is(err, services.ErrFeedbackResolved):
+			_, err := bot.SendMessage(ctx.EffectiveChat.Id, "ℹ️ This feedback is already resolved", nil)
+			return err
+		case err != nil:
+			h.logger.Error().Err(err).Int64("admin_id", adminID).Str("feedback_id", id.String()).Msg("Failed to resolve feedback")
+			_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Failed to resolve the feedback", nil)
+			return err
+		}
+		h.logger.Info().Int64("admin_id", adminID).Str("feedback_id", id.String()).Msg("Feedback resolved")
+		h.auditAdminAction(ctx, services.AuditFeedbackResolved, 0, map[string]any{"feedback_id": id.String()})
+
+		if len(params) > 1 {
+			page, _ := strconv.Atoi(params[1])
+			return h.showFeedbackPage(bot, ctx, page, true)
+		}
+		_, _, err = bot.EditMessageReplyMarkup(&gotgbot.EditMessageReplyMarkupOpts{
+			ChatId:    ctx.EffectiveChat.Id,
+			MessageId: ctx.CallbackQuery.Message.GetMessageId(),
+		})
+		if err != nil {
+			h.logger.Warn().Err(err).Msg("Failed to remove the resolve button")
+		}
+		_, err = bot.SendMessage(ctx.EffectiveChat.Id, "✅ Feedback marked as resolved", nil)
+		return err
+	default:
+		h.logger.Warn().Str("action", action).Msg("Unknown feedback callback action")
+		return nil
+	}
+}
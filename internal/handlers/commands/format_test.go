@@ -1,25 +1,46 @@
 package commands
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/internal/locales"
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/internal/render"
 	"github.com/valpere/shopogoda/internal/services"
+	"github.com/valpere/shopogoda/internal/version"
 	"github.com/valpere/shopogoda/pkg/weather"
 	"github.com/valpere/shopogoda/tests/helpers"
 )
 
-func TestFormatWeatherMessage(t *testing.T) {
+var updateGolden = flag.Bool("update", false, "rewrite golden files from the current formatter output")
+
+func TestRenderWeatherMessage(t *testing.T) {
 	logger := helpers.NewSilentTestLogger()
 	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
 	svc := &services.Services{
 		Localization: locService,
 	}
 
 	handler := &CommandHandler{
 		services: svc,
+		renderer: newTestRenderer(t, locService),
 		logger:   logger,
 	}
 
@@ -27,6 +48,10 @@ func TestFormatWeatherMessage(t *testing.T) {
 		name     string
 		weather  *services.WeatherData
 		language string
+		units    string
+		zone     *time.Location
+		staleFor time.Duration
+		contains []string
 	}{
 		{
 			name: "Complete weather data in English",
@@ -75,12 +100,79 @@ func TestFormatWeatherMessage(t *testing.T) {
 				WindSpeed:   15.0,
 			},
 			language: "en-US",
+			contains: []string{"-5.0°C", "995 hPa"},
+		},
+		{
+			name: "Slightly below freezing keeps the sign",
+			weather: &services.WeatherData{
+				LocationName: "Kyiv",
+				Temperature:  -0.4,
+				Description:  "light snow",
+				Humidity:     93,
+				Pressure:     1008.6,
+				WindSpeed:    9.96,
+			},
+			language: "en-US",
+			contains: []string{"-0.4°C", "10.0 km/h", "1009 hPa"},
+		},
+		{
+			name: "Imperial units",
+			weather: &services.WeatherData{
+				LocationName: "Chicago",
+				Temperature:  -0.4,
+				FeelsLike:    -5,
+				Description:  "light snow",
+				Humidity:     93,
+				Pressure:     1013.25,
+				WindSpeed:    16.09344,
+				Visibility:   10,
+			},
+			language: "en-US",
+			units:    weather.UnitsImperial,
+			contains: []string{"31.3°F", "23.0°F", "10.0 mph", "6.2 mi", "29.92 inHg"},
+		},
+		{
+			name: "Sunrise and sunset in the user's timezone",
+			weather: &services.WeatherData{
+				LocationName: "Kyiv",
+				Description:  "clear sky",
+				Sunrise:      time.Date(2024, 10, 14, 4, 19, 0, 0, time.UTC),
+				Sunset:       time.Date(2024, 10, 14, 15, 9, 0, 0, time.UTC),
+				MoonPhase:    0.5,
+			},
+			language: "en-US",
+			zone:     mustLoadLocation(t, "Europe/Kyiv"),
+			contains: []string{"🌅 07:19 / 🌇 18:09", "🌕"},
+		},
+		{
+			name: "Stale data leads with its age",
+			weather: &services.WeatherData{
+				LocationName: "Kyiv",
+				Description:  "overcast clouds",
+			},
+			language: "uk-UA",
+			staleFor: 3*time.Hour + 20*time.Minute,
+			contains: []string{"⚠️ Погодний сервіс зараз недоступний", "отримані 3 год тому"},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := handler.formatWeatherMessage(tt.weather, tt.language)
+			units := tt.units
+			if units == "" {
+				units = weather.UnitsMetric
+			}
+			if tt.zone == nil {
+				tt.zone = time.UTC
+			}
+			result := handler.render(render.Weather, tt.language, handler.weatherView(&services.WeatherResult{
+				Data:             tt.weather,
+				StaleDataWarning: tt.staleFor > 0,
+				CacheAge:         tt.staleFor,
+			}, tt.language, units, tt.zone))
+			if tt.staleFor == 0 {
+				assert.True(t, strings.HasPrefix(result, "🌤️"), "fresh data has no banner")
+			}
 
 			// Verify message contains key information
 			assert.Contains(t, result, tt.weather.LocationName)
@@ -88,11 +180,14 @@ func TestFormatWeatherMessage(t *testing.T) {
 
 			// Verify message contains weather data
 			assert.Contains(t, result, tt.weather.Description)
+			for _, expected := range tt.contains {
+				assert.Contains(t, result, expected)
+			}
 		})
 	}
 }
 
-func TestFormatForecastMessage(t *testing.T) {
+func TestRenderForecastMessage(t *testing.T) {
 	logger := helpers.NewSilentTestLogger()
 	locService := services.NewLocalizationService(logger)
 	svc := &services.Services{
@@ -101,6 +196,7 @@ func TestFormatForecastMessage(t *testing.T) {
 
 	handler := &CommandHandler{
 		services: svc,
+		renderer: newTestRenderer(t, locService),
 		logger:   logger,
 	}
 
@@ -112,6 +208,8 @@ func TestFormatForecastMessage(t *testing.T) {
 		name     string
 		forecast *weather.ForecastData
 		language string
+		units    string
+		contains []string
 	}{
 		{
 			name: "Multi-day forecast in English",
@@ -133,25 +231,549 @@ func TestFormatForecastMessage(t *testing.T) {
 						Description: "sunny",
 						Humidity:    65,
 						WindSpeed:   6.0,
+						Sunrise:     time.Date(2025, 1, 12, 7, 43, 0, 0, time.UTC),
+						Sunset:      time.Date(2025, 1, 12, 16, 17, 0, 0, time.UTC),
+					},
+				},
+			},
+			language: "en-US",
+			units:    weather.UnitsMetric,
+			contains: []string{"12.0°C/5.0°C", "8.0 km/h", "🌅 07:43 / 🌇 16:17"},
+		},
+		{
+			name: "Forecast in imperial units",
+			forecast: &weather.ForecastData{
+				Location: "Chicago, US",
+				Forecasts: []weather.DailyForecast{
+					{
+						Date:        date1,
+						MinTemp:     -10.0,
+						MaxTemp:     0.0,
+						Description: "snow",
+						Humidity:    85,
+						WindSpeed:   32.18688,
 					},
 				},
 			},
 			language: "en-US",
+			units:    weather.UnitsImperial,
+			contains: []string{"32.0°F/14.0°F", "20.0 mph"},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := handler.formatForecastMessage(tt.forecast, tt.language)
+			result := handler.render(render.Forecast, tt.language, handler.forecastView(tt.forecast, tt.language, tt.units, time.UTC))
 
 			// Verify message is generated and contains forecast data
 			assert.NotEmpty(t, result)
 			assert.Contains(t, result, tt.forecast.Forecasts[0].Description)
+			for _, expected := range tt.contains {
+				assert.Contains(t, result, expected)
+			}
 		})
 	}
 }
 
-func TestFormatAirQualityMessage(t *testing.T) {
+func TestFormatSunTimes(t *testing.T) {
+	// London at midsummer
+	sunrise := time.Date(2024, 6, 21, 3, 43, 0, 0, time.UTC)
+	sunset := time.Date(2024, 6, 21, 20, 21, 0, 0, time.UTC)
+
+	assert.Equal(t, "🌅 03:43 / 🌇 20:21", formatSunTimes(sunrise, sunset, time.UTC))
+	assert.Equal(t, "🌅 04:43 / 🌇 21:21", formatSunTimes(sunrise, sunset, mustLoadLocation(t, "Europe/London")))
+	assert.Equal(t, "🌅 06:43 / 🌇 23:21", formatSunTimes(sunrise, sunset, mustLoadLocation(t, "Europe/Kyiv")))
+	// West of UTC the sunset falls on the local day before the next sunrise
+	assert.Equal(t, "🌅 23:43 / 🌇 16:21", formatSunTimes(sunrise, sunset, mustLoadLocation(t, "America/New_York")))
+	// Half-hour offsets
+	assert.Equal(t, "🌅 09:13 / 🌇 01:51", formatSunTimes(sunrise, sunset, mustLoadLocation(t, "Asia/Kolkata")))
+
+	// Polar day or night
+	assert.Empty(t, formatSunTimes(time.Time{}, time.Time{}, time.UTC))
+	assert.Empty(t, formatSunTimes(sunrise, time.Time{}, time.UTC))
+}
+
+func TestFormatDataAge(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		logger:   logger,
+	}
+
+	assert.Equal(t, "1 min", handler.formatDataAge(20*time.Second, "en-US"))
+	assert.Equal(t, "59 min", handler.formatDataAge(59*time.Minute, "en-US"))
+	assert.Equal(t, "2 h", handler.formatDataAge(2*time.Hour+59*time.Minute, "en-US"))
+	assert.Equal(t, "3 дн", handler.formatDataAge(80*time.Hour, "uk-UA"))
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	require.NoError(t, err)
+	return location
+}
+
+func TestFormatHourlyForecastMessage(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		logger:   logger,
+	}
+
+	start := time.Date(2025, 7, 1, 9, 0, 0, 0, time.UTC)
+	forecast := &weather.HourlyForecastData{Location: "Kyiv, UA", UTCOffset: 3 * 3600}
+	for i := 0; i < 8; i++ {
+		forecast.Slots = append(forecast.Slots, weather.HourlyForecast{
+			Time:                start.Add(time.Duration(i*3) * time.Hour),
+			Temperature:         20 + float64(i),
+			WindSpeed:           18,
+			Description:         "light rain",
+			Icon:                "10d",
+			PrecipitationChance: 0.4,
+		})
+	}
+
+	locationZone := time.FixedZone("", forecast.UTCOffset)
+	first := handler.formatHourlyForecastMessage(forecast, 0, 2, "en-US", weather.UnitsMetric, locationZone)
+	assert.Contains(t, first, "Kyiv, UA")
+	assert.Contains(t, first, "*Tue 12:00* 🌦️ light rain") // local time of the location
+	assert.Contains(t, first, "🌡️ 20.0°C | ☔ 40% | 🌬️ 18.0 km/h")
+	assert.Contains(t, first, "23.0°C")
+	assert.NotContains(t, first, "24.0°C")
+	assert.Contains(t, first, "Page 1 of 2")
+
+	second := handler.formatHourlyForecastMessage(forecast, 1, 2, "en-US", weather.UnitsImperial, locationZone)
+	assert.Contains(t, second, "75.2°F")
+	assert.Contains(t, second, "11.2 mph")
+	assert.NotContains(t, second, "71.6°F")
+	assert.Contains(t, second, "Page 2 of 2")
+
+	// A user's own time zone wins over the location's
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	local := handler.formatHourlyForecastMessage(forecast, 0, 2, "en-US", weather.UnitsMetric, newYork)
+	assert.Contains(t, local, "*Tue 05:00*")
+}
+
+func TestParseCompareArgs(t *testing.T) {
+	tests := []struct {
+		text          string
+		first, second string
+		ok            bool
+	}{
+		{"Kyiv Lviv", "Kyiv", "Lviv", true},
+		{"New York vs Los Angeles", "New York", "Los Angeles", true},
+		{"New York VS. Boston", "New York", "Boston", true},
+		{"Kyiv, Rio de Janeiro", "Kyiv", "Rio de Janeiro", true},
+		{"London ; New York", "London", "New York", true},
+		{"Paris, France; London, UK", "Paris, France", "London, UK", true},
+		{"Paris, France vs London, UK", "Paris, France", "London, UK", true},
+		{"Washington, D.C. | Portland, Oregon", "Washington, D.C.", "Portland, Oregon", true},
+		// A single place is compared with the saved location
+		{"Kyiv", "", "Kyiv", true},
+		{"Rio de Janeiro", "", "Rio de Janeiro", true},
+		{"Kyiv,", "", "Kyiv", true},
+		{"; Kyiv", "", "Kyiv", true},
+		{"", "", "", false},
+		{" ; ", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			first, second, ok := parseCompareArgs(tt.text)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, tt.first, first)
+				assert.Equal(t, tt.second, second)
+			}
+		})
+	}
+}
+
+func TestFormatComparisonMessage(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		logger:   logger,
+	}
+
+	kyiv := &services.WeatherData{LocationName: "Kyiv", Temperature: -0.4, FeelsLike: -4, Humidity: 93, WindSpeed: 9.96, Precipitation: 0.4, AQI: 42, UVIndex: 0.5}
+	lviv := &services.WeatherData{LocationName: "Lviv_City", Temperature: 2, FeelsLike: -4, Humidity: 80, WindSpeed: 5, AQI: 30, UVIndex: 1}
+
+	text := handler.formatComparisonMessage(kyiv, lviv, "en-US", weather.UnitsMetric)
+	assert.True(t, strings.HasPrefix(text, `⚖️ *Kyiv vs Lviv_City*`))
+	assert.Contains(t, text, "Temperature    -0.4°C     2.0°C ✓")
+	assert.Contains(t, text, "Feels like     -4.0°C     -4.0°C\n", "no better side on a tie")
+	assert.Contains(t, text, "Humidity       93%        80%\n", "no better side for humidity")
+	assert.Contains(t, text, "Wind           10.0 km/h  5.0 km/h ✓")
+	assert.Contains(t, text, "UV index       0.5 ✓      1.0")
+	assert.Contains(t, text, "✓ marks the more pleasant side")
+
+	// Only the place that could be fetched is shown
+	text = handler.formatComparisonMessage(nil, lviv, "en-US", weather.UnitsImperial)
+	assert.NotContains(t, text, "Kyiv")
+	assert.NotContains(t, text, "✓")
+	assert.Contains(t, text, "Temperature    35.6°F")
+}
+
+func TestFormatUVIndexMessage(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		logger:   logger,
+	}
+
+	morning := time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC)
+	hours := []weather.UVHour{
+		{Time: morning, UVIndex: 3.2},
+		{Time: morning.Add(time.Hour), UVIndex: 6},
+		{Time: morning.Add(10 * time.Hour), UVIndex: 0},
+	}
+
+	text := handler.formatUVIndexMessage("Kyiv, UA", 6, hours, 3, "en-US", time.FixedZone("", 3*3600))
+	assert.Contains(t, text, "UV index in Kyiv, UA")
+	assert.Contains(t, text, "*6.0* (high)")
+	assert.Contains(t, text, "Skin type III: unprotected skin starts to burn after about 33 min")
+	assert.Contains(t, text, "SPF 30 or higher")
+	assert.Contains(t, text, "*Next 3 hours*")
+	assert.Contains(t, text, "12:00 ▃ 3.2\n13:00 ▅ 6.0\n```", "times in the zone, dark hours left out")
+
+	night := []weather.UVHour{{Time: morning}, {Time: morning.Add(time.Hour)}}
+	text = handler.formatUVIndexMessage("Tromsø, NO", 0, night, 1, "en-US", time.UTC)
+	assert.Contains(t, text, "Skin type I: too little UV to burn")
+	assert.Contains(t, text, "Sunscreen: not needed")
+	assert.Contains(t, text, "The sun stays down for the next 2 hours")
+	assert.NotContains(t, text, "```")
+}
+
+func TestFormatAirQualityForecast(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		logger:   logger,
+	}
+
+	// Thursday 09:00 to 20:00 in Kyiv
+	start := time.Date(2026, 4, 16, 6, 0, 0, 0, time.UTC)
+	var hours []weather.AirQualityHour
+	for i := 0; i < 12; i++ {
+		hours = append(hours, weather.AirQualityHour{Time: start.Add(time.Duration(i) * time.Hour), AQI: 40, PM25: 9})
+	}
+	hours[5].AQI, hours[5].O3 = 119, 150
+	hours[10].AQI, hours[10].PM25 = 56, 13
+
+	text := handler.formatAirQualityForecast("Las Vegas_NV", hours, "en-US", time.FixedZone("EEST", 3*3600))
+	assert.True(t, strings.HasPrefix(text, "🌫️ *Air quality forecast for Las Vegas_NV*"))
+	assert.Contains(t, text, "🌅 *Morning* · Thursday: AQI 40, Good — fine particles (PM2.5)")
+	assert.Contains(t, text, "☀️ *Afternoon* · Thursday: AQI 119, Unhealthy for Sensitive Groups — *ozone (O₃)*")
+	assert.Contains(t, text, "🌆 *Evening* · Thursday: AQI 56, Moderate — fine particles (PM2.5)")
+	assert.Contains(t, text, "⚠️ Worst at 14:00 on Thursday: AQI 119, mostly from *ozone (O₃)*")
+	assert.NotContains(t, text, "Night")
+}
+
+func TestFormatSunMessage(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		logger:   logger,
+	}
+
+	bst := time.FixedZone("BST", 3600)
+	current := &weather.WeatherData{
+		Sunrise:   time.Date(2024, 6, 21, 3, 43, 0, 0, time.UTC),
+		Sunset:    time.Date(2024, 6, 21, 20, 21, 0, 0, time.UTC),
+		UTCOffset: 3600,
+	}
+	text := handler.formatSunMessage("London", current, 51.5074, -0.1278, time.Date(2024, 6, 21, 13, 2, 0, 0, bst), "en-US")
+	assert.True(t, strings.HasPrefix(text, "🌞 *Sun in London*"))
+	assert.Contains(t, text, "🌅 Sunrise: 04:43\n🌇 Sunset: 21:21\n⏳ Day length: 16h 38m")
+	assert.Contains(t, text, "🕛 Solar noon: 13:0")
+	assert.Contains(t, text, "The sun is 62° above the horizon, S (")
+
+	// Times show in the user's timezone, wherever the place is
+	text = handler.formatSunMessage("London", current, 51.5074, -0.1278, time.Date(2024, 6, 21, 23, 0, 0, 0, time.UTC).In(time.FixedZone("", 9*3600)), "en-US")
+	assert.Contains(t, text, "Sunrise: 12:43")
+	assert.Contains(t, text, "below the horizon, N")
+
+	text = handler.formatSunMessage("Tromsø", &weather.WeatherData{UTCOffset: 3600}, 69.6492, 18.9553, time.Date(2024, 12, 21, 12, 0, 0, 0, time.UTC), "uk-UA")
+	assert.Contains(t, text, "Полярна ніч")
+	assert.NotContains(t, text, "Схід сонця")
+	text = handler.formatSunMessage("Tromsø", &weather.WeatherData{UTCOffset: 7200}, 69.6492, 18.9553, time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC), "en-US")
+	assert.Contains(t, text, "Polar day")
+}
+
+func TestFormatAstroMessage(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		logger:   logger,
+	}
+
+	kyiv := time.FixedZone("EET", 2*3600)
+	data := &weather.AstroData{
+		Date:             time.Date(2024, 12, 21, 0, 0, 0, 0, kyiv),
+		MoonPhase:        weather.MoonWaningGibbous,
+		MoonIllumination: 0.684,
+		Moonset:          time.Date(2024, 12, 21, 9, 58, 0, 0, time.UTC),
+		AstroTwilight:    time.Date(2024, 12, 21, 15, 57, 0, 0, time.UTC),
+		AstroDawn:        time.Date(2024, 12, 22, 4, 5, 0, 0, time.UTC),
+	}
+	text := handler.formatAstroMessage("Kyiv", data, "en-US")
+	assert.True(t, strings.HasPrefix(text, "🌙 *Night sky in Kyiv*\n📅 Saturday, 21.12.2024"))
+	assert.Contains(t, text, "🌖 *Waning gibbous*, 68% lit")
+	assert.Contains(t, text, "No moonrise this day")
+	assert.Contains(t, text, "Moonset: 11:58")
+	assert.Contains(t, text, "Astronomical night: 17:57 – 06:05")
+
+	data.AstroTwilight, data.AstroDawn = time.Time{}, time.Time{}
+	text = handler.formatAstroMessage("London", data, "uk-UA")
+	assert.Contains(t, text, "Астрономічної ночі немає")
+}
+
+func TestFormatTideMessage(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		logger:   logger,
+	}
+
+	at := func(day, hour, minute int) time.Time { return time.Date(2024, 10, day, hour, minute, 0, 0, time.UTC) }
+	tides := &weather.TideData{
+		Points: []weather.TidePoint{
+			{Time: at(16, 5, 12), Height: 2.31, Type: weather.TideHigh},
+			{Time: at(16, 11, 30), Height: -2.05, Type: weather.TideLow},
+			{Time: at(16, 17, 40), Height: 2.18, Type: weather.TideHigh},
+			{Time: at(17, 0, 5), Height: -1.96, Type: weather.TideLow},
+		},
+		Station: "PLYMOUTH (DEVONPORT)",
+	}
+	now := at(16, 9, 0)
+
+	text := handler.formatTideMessage("Plymouth", tides, now, weather.UnitsMetric, "en-US")
+	assert.True(t, strings.HasPrefix(text, "🌊 *Tides in Plymouth*\n📍 Station: PLYMOUTH (DEVONPORT)\n⏭️ Next: Low tide at 11:30, -2.1 m"))
+	assert.Contains(t, text, "*Wednesday, 16.10*\n⬆️ 05:12  High tide, 2.3 m")
+	assert.Contains(t, text, "*Thursday, 17.10*\n⬇️ 00:05  Low tide, -2.0 m")
+
+	// Days follow the user's timezone
+	text = handler.formatTideMessage("Plymouth", tides, now.In(time.FixedZone("UTC-1", -3600)), weather.UnitsImperial, "en-US")
+	assert.Contains(t, text, "*Wednesday, 16.10*\n⬆️ 04:12  High tide, 7.6 ft")
+	assert.Contains(t, text, "⬇️ 23:05  Low tide, -6.4 ft")
+	assert.NotContains(t, text, "17.10")
+}
+
+func TestFormatHistoryMessage(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		logger:   logger,
+	}
+
+	day := func(d int, minTemp, maxTemp float64) services.DailyWeatherHistory {
+		return services.DailyWeatherHistory{Day: time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC), LocationName: "Kyiv", MinTemp: minTemp, MaxTemp: maxTemp}
+	}
+	history := []services.DailyWeatherHistory{day(12, 5, 11), day(13, 8, 14), day(14, 3, 9), day(15, 3.5, 9.2)}
+
+	text := handler.formatHistoryMessage(history, nil, "Kyiv", "en-US", weather.UnitsMetric)
+	assert.Contains(t, text, "Weather in Kyiv, last 7 days")
+	assert.Contains(t, text, "▫️ *Monday 12.10*: 5.0°C … 11.0°C")
+	assert.Contains(t, text, "↗️ *Tuesday 13.10*")
+	assert.Contains(t, text, "↘️ *Wednesday 14.10*")
+	assert.Contains(t, text, "➡️ *Thursday 15.10*")
+
+	text = handler.formatHistoryMessage(history[:1], nil, "Kyiv", "en-US", weather.UnitsImperial)
+	assert.Contains(t, text, "41.0°F … 51.8°F")
+
+	text = handler.formatHistoryMessage(nil, nil, "Kyiv", "en-US", weather.UnitsMetric)
+	assert.Contains(t, text, "No weather has been recorded for Kyiv yet")
+
+	summary := &services.WeatherRecordSummary{
+		MinTemp: 2, MaxTemp: 14, AvgTemp: 8.25, Samples: 20,
+		Conditions: []services.ConditionShare{{Group: "rain", Share: 0.5}, {Group: "clouds", Share: 0.3}, {Group: "clear", Share: 0.15}, {Group: "fog", Share: 0.05}},
+	}
+	text = handler.formatHistoryMessage(history, summary, "Kyiv", "en-US", weather.UnitsMetric)
+	assert.Contains(t, text, "📊 *Last 7 days*: min 2.0°C, avg 8.3°C, max 14.0°C from 20 readings")
+	assert.Contains(t, text, "Mostly 🌧️ rain 50%, ☁️ cloudy 30%, ☀️ clear 15%")
+	assert.NotContains(t, text, "fog")
+	assert.True(t, strings.HasSuffix(text, "about the same as the day before._"))
+
+	// Looked-up weather alone is enough for a message
+	text = handler.formatHistoryMessage(nil, summary, "Kyiv", "en-US", weather.UnitsMetric)
+	assert.Contains(t, text, "Weather in Kyiv, last 7 days")
+	assert.Contains(t, text, "min 2.0°C")
+	assert.NotContains(t, text, "Lowest … highest")
+}
+
+func TestFormatVersionMessage(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		logger:   logger,
+	}
+
+	info := version.Info{Version: "0.1.1", GitCommit: "abc123", BuildTime: "2026-10-16_12:00:00_UTC", GoVersion: "go1.25.0", Platform: "linux/amd64"}
+	text := handler.formatVersionMessage(info, "en-US")
+	assert.Contains(t, text, "📦 Version: 0.1.1")
+	assert.Contains(t, text, "🔨 Git Commit: abc123")
+	assert.Contains(t, text, `🕐 Built: 2026-10-16\_12:00:00\_UTC`)
+	assert.Contains(t, text, "⚙️ Go Version: go1.25.0")
+	assert.Contains(t, text, "🖥️ Platform: linux/amd64")
+
+	text = handler.formatUpdateStatus(&services.UpdateStatus{Current: "0.1.1", Latest: &services.Release{Version: "v0.1.1"}, UpToDate: true}, "en-US")
+	assert.Equal(t, "✅ ShoPogoda is up to date: version 0.1.1 is the latest release.", text)
+
+	text = handler.formatUpdateStatus(&services.UpdateStatus{
+		Current: "0.1.1",
+		Latest:  &services.Release{Version: "v0.2.0", URL: "https://github.com/valpere/shopogoda/releases/tag/v0.2.0"},
+	}, "en-US")
+	assert.Equal(t, "🆕 Version 0.2.0 is available; this bot runs 0.1.1.\nhttps://github.com/valpere/shopogoda/releases/tag/v0.2.0", text)
+}
+
+func TestAlertLastCheckedLine(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		logger:   logger,
+	}
+
+	checkedAt := time.Date(2026, 10, 16, 9, 5, 0, 0, time.UTC)
+	value := 31.5
+	alert := models.AlertConfig{AlertType: models.AlertTemperature, LastCheckedAt: &checkedAt, LastValue: &value}
+	user := &models.User{Timezone: "Europe/Kyiv", Units: weather.UnitsMetric}
+
+	assert.Equal(t, "   🕒 Last checked 16 Oct 12:05: 31.5°C\n", handler.alertLastCheckedLine(alert, user, "en-US"))
+	assert.Empty(t, handler.alertLastCheckedLine(models.AlertConfig{AlertType: models.AlertTemperature}, user, "en-US"))
+}
+
+func TestFormatRadarCaption(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		logger:   logger,
+	}
+
+	rain := &weather.WeatherData{Description: "light rain", Icon: "10d", Precipitation: 1.25}
+	assert.Equal(t, "🌧️ *Precipitation around St_Ives*\n🌦️ 1.3 mm in the last hour (light rain)",
+		handler.formatRadarCaption("St_Ives", rain, "en-US", weather.UnitsMetric))
+	assert.Contains(t, handler.formatRadarCaption("Kyiv", rain, "en-US", weather.UnitsImperial), "0.05 in in the last hour")
+
+	dry := &weather.WeatherData{Description: "clear sky", Icon: "01d"}
+	assert.Contains(t, handler.formatRadarCaption("Kyiv", dry, "en-US", weather.UnitsMetric), "☀️ No precipitation right now (clear sky)")
+}
+
+func TestMapLayerKeyboard(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		logger:   logger,
+	}
+
+	prompt := handler.mapLayerKeyboard("en-US", "")
+	require.Len(t, prompt, 1)
+	require.Len(t, prompt[0], 3)
+	assert.Equal(t, "🌧️ Precipitation", prompt[0][0].Text)
+	assert.Equal(t, "map_show_precipitation", prompt[0][0].CallbackData)
+	assert.Equal(t, "map_show_wind", prompt[0][2].CallbackData)
+
+	switcher := handler.mapLayerKeyboard("en-US", weather.MapLayerTemperature)
+	assert.Equal(t, "✅ 🌡️ Temperature", switcher[0][1].Text)
+	assert.Equal(t, "map_switch_temperature", switcher[0][1].CallbackData)
+	assert.Equal(t, "💨 Wind", switcher[0][2].Text)
+}
+
+func TestSubscriptionTimeLabel(t *testing.T) {
+	assert.Equal(t, "08:00 (Europe/Kyiv)", subscriptionTimeLabel("08:00", "Europe/Kyiv"))
+	assert.Equal(t, "07:30 (America/Argentina/Buenos\\_Aires)", subscriptionTimeLabel("07:30", "America/Argentina/Buenos_Aires"))
+}
+
+func TestInlineWeatherArticle(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService, Weather: services.NewWeatherService(&config.WeatherConfig{}, nil, logger)},
+		renderer: newTestRenderer(t, locService),
+		logger:   logger,
+	}
+
+	place := services.InlineWeather{
+		Location: weather.Location{Name: "Kyiv", Country: "UA", Latitude: 50.45, Longitude: 30.52, LocalNames: map[string]string{"uk-UA": "Київ"}},
+		Weather:  &services.WeatherData{Temperature: 12.5, FeelsLike: 11, Humidity: 60, WindSpeed: 10, Icon: "☀️", Description: "clear sky"},
+	}
+
+	article := handler.inlineWeatherArticle(place, "en-US", weather.UnitsMetric)
+	assert.Equal(t, "50.4500:30.5200", article.Id)
+	assert.Equal(t, "Kyiv, UA", article.Title)
+	assert.Equal(t, "☀️ 12.5°C, clear sky · 💧 60% · 💨 10.0 km/h", article.Description)
+	content, ok := article.InputMessageContent.(gotgbot.InputTextMessageContent)
+	require.True(t, ok)
+	assert.Contains(t, content.MessageText, "*Kyiv, UA*")
+	assert.Contains(t, content.MessageText, "12.5°C")
+
+	article = handler.inlineWeatherArticle(place, "uk-UA", weather.UnitsImperial)
+	assert.Equal(t, "Київ, UA", article.Title)
+	assert.Contains(t, article.Description, "54.5°F")
+
+	forecast := &weather.ForecastData{Location: "Kyiv", Forecasts: []weather.DailyForecast{
+		{Date: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), MaxTemp: 24, MinTemp: 15, Icon: "☀️", Description: "clear sky"},
+		{Date: time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC), MaxTemp: 19, MinTemp: 12, Icon: "🌧️", Description: "light rain"},
+	}}
+	article = handler.inlineForecastArticle(place, forecast, "en-US", weather.UnitsMetric, time.UTC)
+	assert.Equal(t, "50.4500:30.5200:forecast", article.Id)
+	assert.Equal(t, "📊 5-day forecast: Kyiv, UA", article.Title)
+	assert.Equal(t, "Mon 24.0°C/15.0°C ☀️ · Tue 19.0°C/12.0°C 🌧️", article.Description)
+	content, ok = article.InputMessageContent.(gotgbot.InputTextMessageContent)
+	require.True(t, ok)
+	assert.Contains(t, content.MessageText, "Kyiv, UA")
+	assert.Contains(t, content.MessageText, "light rain")
+
+	place.Weather.AQI, place.Weather.PM25, place.Weather.PM10 = 42, 8.4, 20
+	require.True(t, hasAirQuality(place.Weather))
+	article = handler.inlineAirQualityArticle(place, "en-US", time.UTC)
+	assert.Equal(t, "50.4500:30.5200:air", article.Id)
+	assert.Equal(t, "🌿 Air quality: Kyiv, UA", article.Title)
+	assert.Contains(t, article.Description, "AQI 42")
+	assert.Contains(t, article.Description, "PM2.5 8.4 μg/m³")
+	content, ok = article.InputMessageContent.(gotgbot.InputTextMessageContent)
+	require.True(t, ok)
+	assert.True(t, strings.HasPrefix(content.MessageText, "📍 *Kyiv, UA*"))
+
+	assert.False(t, hasAirQuality(&services.WeatherData{Temperature: 10}))
+}
+
+func TestInlineDescription(t *testing.T) {
+	assert.Equal(t, "a · b", inlineDescription("a", "b"))
+
+	long := inlineDescription(strings.Repeat("ü", inlineDescriptionLimit), "more")
+	assert.Equal(t, inlineDescriptionLimit, utf8.RuneCountInString(long))
+	assert.True(t, strings.HasSuffix(long, "…"))
+}
+
+func TestRenderAirQualityMessage(t *testing.T) {
 	logger := helpers.NewSilentTestLogger()
 	locService := services.NewLocalizationService(logger)
 	svc := &services.Services{
@@ -160,6 +782,7 @@ func TestFormatAirQualityMessage(t *testing.T) {
 
 	handler := &CommandHandler{
 		services: svc,
+		renderer: newTestRenderer(t, locService),
 		logger:   logger,
 	}
 
@@ -196,10 +819,471 @@ func TestFormatAirQualityMessage(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := handler.formatAirQualityMessage(tt.airData, tt.language)
+			result := handler.render(render.AirQuality, tt.language, handler.airQualityView(tt.airData, tt.language, time.UTC))
 
 			// Verify message is generated
 			assert.NotEmpty(t, result)
 		})
 	}
 }
+
+func TestBilingualWeatherSnapshot(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		renderer: newTestRenderer(t, locService),
+		logger:   logger,
+	}
+
+	current := &services.WeatherData{
+		LocationName:  "Kyiv",
+		Temperature:   -0.4,
+		FeelsLike:     -4.1,
+		Description:   "light snow",
+		Icon:          "🌨️",
+		Humidity:      93,
+		Pressure:      1008.6,
+		WindSpeed:     9.96,
+		WindDirection: 270,
+		Visibility:    4.2,
+		UVIndex:       0.5,
+		AQI:           42,
+		Sunrise:       time.Date(2025, 1, 15, 5, 55, 0, 0, time.UTC),
+		Sunset:        time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC),
+		MoonPhase:     weather.MoonPhase(time.Date(2025, 1, 15, 7, 0, 0, 0, time.UTC)),
+		Timestamp:     time.Date(2025, 1, 15, 7, 0, 0, 0, time.UTC),
+	}
+	user := &models.User{Language: "en-US", SecondaryLanguage: "uk-UA"}
+
+	kyiv := mustLoadLocation(t, "Europe/Kyiv")
+
+	message := services.RenderForUser(user, func(language string) string {
+		return handler.render(render.Weather, language, handler.weatherView(&services.WeatherResult{Data: current}, language, weather.UnitsMetric, kyiv))
+	}, services.WeatherSummary(locService, current, weather.UnitsMetric))
+
+	// English first, then the divider, then the short Ukrainian summary
+	primary, secondary, found := strings.Cut(message, "\n\n┈┈┈┈┈┈┈┈┈┈\n")
+	require.True(t, found, "divider missing")
+	assert.Equal(t, handler.render(render.Weather, "en-US", handler.weatherView(&services.WeatherResult{Data: current}, "en-US", weather.UnitsMetric, kyiv)), primary)
+	assert.Contains(t, secondary, "відчувається як")
+	assert.Len(t, strings.Split(secondary, "\n"), 2)
+
+	path := filepath.Join("testdata", "bilingual_weather.golden")
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(message), 0o644))
+	}
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err, "golden file missing; run go test ./internal/handlers/commands -run TestBilingualWeatherSnapshot -update")
+	assert.Equal(t, string(expected), message)
+}
+
+func TestFormatPlaceCandidates(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		logger:   logger,
+	}
+
+	candidates := []services.PlaceCandidate{
+		{Location: weather.Location{Name: "Kyiv", Latitude: 50.45, Longitude: 30.5234}, Region: "Kyiv"},
+		{Location: weather.Location{Name: "Brovary", Latitude: 50.5114, Longitude: 30.7903}, Region: "Kyiv Oblast", DistanceKm: 20.3, Direction: "E", RelativeTo: "Kyiv"},
+		{Location: weather.Location{Name: "Vyshneve", Latitude: 50.3886, Longitude: 30.3708}, Region: "UA", DistanceKm: 12.6, Direction: "SW", RelativeTo: "Kyiv"},
+	}
+
+	t.Run("suggestions relative to the first place", func(t *testing.T) {
+		text, keyboard := handler.formatPlaceCandidates("en-US", "Header", candidates, "Velyka Dymerka village")
+
+		assert.Equal(t, "Header\n\n1. Kyiv\n2. Brovary, Kyiv Oblast — 20 km E of Kyiv\n3. Vyshneve, UA — 13 km SW of Kyiv", text)
+		require.Len(t, keyboard, 3)
+		assert.Equal(t, "1. Kyiv", keyboard[0][0].Text)
+		assert.Equal(t, "location_pick_50.4500_30.5234_Velyka+Dymerka+village", keyboard[0][0].CallbackData)
+		assert.Equal(t, "location_pick_50.5114_30.7903_Velyka+Dymerka+village", keyboard[1][0].CallbackData)
+	})
+
+	t.Run("localized directions", func(t *testing.T) {
+		text, _ := handler.formatPlaceCandidates("uk-UA", "Header", candidates[1:2], "")
+
+		assert.Contains(t, text, "Brovary, Kyiv Oblast — 20 км на Сх від Kyiv")
+	})
+
+	t.Run("nearby places relative to the user", func(t *testing.T) {
+		nearby := []services.PlaceCandidate{
+			{Location: weather.Location{Name: "Brovary", Latitude: 50.5114, Longitude: 30.7903}, Region: "Kyiv Oblast", DistanceKm: 3.2, Direction: "NE"},
+		}
+
+		text, keyboard := handler.formatPlaceCandidates("en-US", "Header", nearby, "")
+
+		assert.Equal(t, "Header\n\n1. Brovary, Kyiv Oblast — 3 km NE of you", text)
+		assert.Equal(t, "location_pick_50.5114_30.7903", keyboard[0][0].CallbackData)
+	})
+
+}
+
+func TestCallbackWithLabel(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	mockRedis := helpers.NewMockRedis()
+	handler := &CommandHandler{
+		services: &services.Services{Labels: services.NewCallbackLabelService(mockRedis.Client)},
+		logger:   logger,
+	}
+
+	// storedLabel accepts the label under any generated token
+	var stored string
+	storedLabel := func(expected, actual []interface{}) error {
+		key, _ := actual[1].(string)
+		if !strings.HasPrefix(key, "callback_label:") || actual[2] != expected[2] {
+			return fmt.Errorf("unexpected label write %v", actual)
+		}
+		stored = strings.TrimPrefix(key, "callback_label:")
+		return nil
+	}
+
+	t.Run("short labels travel inline", func(t *testing.T) {
+		data, ok := handler.callbackWithLabel("location_save_50.4500_30.5234", "Dymerka")
+		assert.True(t, ok)
+		assert.Equal(t, "location_save_50.4500_30.5234_Dymerka", data)
+
+		label, err := handler.callbackLabel(strings.Split(data, "_")[4:])
+		require.NoError(t, err)
+		assert.Equal(t, "Dymerka", label)
+	})
+
+	t.Run("long Cyrillic labels are stored, not shortened", func(t *testing.T) {
+		label := "Велика Димерка, Броварський район"
+		mockRedis.Mock.CustomMatch(storedLabel).ExpectSet("", label, 24*time.Hour).SetVal("OK")
+
+		data, ok := handler.callbackWithLabel("location_pick_50.4500_30.5234", label)
+		require.True(t, ok)
+		assert.LessOrEqual(t, len(data), maxCallbackData)
+		assert.Equal(t, "location_pick_50.4500_30.5234_@"+stored, data)
+
+		mockRedis.Mock.ExpectGet("callback_label:" + stored).SetVal(label)
+		resolved, err := handler.callbackLabel(strings.Split(data, "_")[4:])
+		require.NoError(t, err)
+		assert.Equal(t, label, resolved)
+		mockRedis.ExpectationsWereMet(t)
+	})
+
+	t.Run("expired labels are reported", func(t *testing.T) {
+		mockRedis.Mock.ExpectGet("callback_label:0a1b2c3d4e5f").RedisNil()
+
+		_, err := handler.callbackLabel([]string{"@0a1b2c3d4e5f"})
+		assert.ErrorIs(t, err, services.ErrLabelExpired)
+	})
+
+	t.Run("without storage the label is left out", func(t *testing.T) {
+		mockRedis.Mock.CustomMatch(storedLabel).ExpectSet("", strings.Repeat("ж", 40), 24*time.Hour).SetErr(errors.New("redis down"))
+
+		data, ok := handler.callbackWithLabel("location_save_50.4500_30.5234", strings.Repeat("ж", 40))
+		assert.False(t, ok)
+		assert.Equal(t, "location_save_50.4500_30.5234", data)
+	})
+}
+
+// Place names that broke messages and buttons: an underscore opens a Markdown
+// entity that never closes, and a long Thai name runs far past callback data
+var awkwardLocationNames = []string{
+	"Las Vegas_NV",
+	"São Paulo",
+	"ตำบลศรีภูมิ อำเภอเมืองเชียงใหม่ จังหวัดเชียงใหม่ ราชอาณาจักรไทย 50200", // 69 letters
+}
+
+func TestLocationCallback(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	mockRedis := helpers.NewMockRedis()
+	handler := &CommandHandler{
+		services: &services.Services{Labels: services.NewCallbackLabelService(mockRedis.Client)},
+		logger:   logger,
+	}
+
+	// storedLabel accepts the label under any token
+	storedLabel := func(expected, actual []interface{}) error {
+		key, _ := actual[1].(string)
+		if !strings.HasPrefix(key, "callback_label:") || actual[2] != expected[2] {
+			return fmt.Errorf("unexpected label write %v", actual)
+		}
+		return nil
+	}
+
+	require.Greater(t, len(awkwardLocationNames[2]), 64)
+	for _, name := range awkwardLocationNames {
+		t.Run(name, func(t *testing.T) {
+			mockRedis.Mock.CustomMatch(storedLabel).ExpectSet("", name, 24*time.Hour).SetVal("OK")
+			data := handler.locationCallback("alert_temp_setup", name)
+			assert.LessOrEqual(t, len(data), maxCallbackData)
+			assert.NotContains(t, strings.TrimPrefix(data, "alert_temp_setup_"), "_")
+
+			parts := strings.Split(data, "_")
+			require.Len(t, parts, 4)
+			mockRedis.Mock.ExpectGet("callback_label:" + strings.TrimPrefix(parts[3], "@")).SetVal(name)
+			resolved, err := handler.callbackLabel(parts[3:])
+			require.NoError(t, err)
+			assert.Equal(t, name, resolved)
+			mockRedis.ExpectationsWereMet(t)
+		})
+	}
+
+	t.Run("without storage short names travel inline", func(t *testing.T) {
+		mockRedis.Mock.CustomMatch(storedLabel).ExpectSet("", "Las Vegas_NV", 24*time.Hour).SetErr(errors.New("redis down"))
+		data := handler.locationCallback("weather", "Las Vegas_NV")
+		assert.Equal(t, "weather_Las+Vegas_NV", data)
+
+		resolved, err := handler.callbackLabel(strings.Split(data, "_")[1:])
+		require.NoError(t, err)
+		assert.Equal(t, "Las Vegas_NV", resolved)
+
+		thai := awkwardLocationNames[2]
+		mockRedis.Mock.CustomMatch(storedLabel).ExpectSet("", thai, 24*time.Hour).SetErr(errors.New("redis down"))
+		assert.Equal(t, "weather", handler.locationCallback("weather", thai))
+	})
+
+	t.Run("buttons sent before names were stored still work", func(t *testing.T) {
+		resolved, err := handler.callbackLabel([]string{"New York"})
+		require.NoError(t, err)
+		assert.Equal(t, "New York", resolved)
+	})
+}
+
+func TestLocationNamesAreEscaped(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		renderer: newTestRenderer(t, locService),
+		logger:   logger,
+	}
+
+	// assertBoldName parses text as Telegram does and checks that the reader
+	// sees the name, bolded, as it was given
+	assertBoldName := func(t *testing.T, text, name string) {
+		t.Helper()
+		plain, entities, err := helpers.ParseMarkdown(text)
+		require.NoError(t, err, text)
+		offset := strings.Index(plain, name)
+		require.GreaterOrEqual(t, offset, 0, "%q does not show %q", plain, name)
+		assert.True(t, helpers.MarkdownIsBold(plain, entities, offset, offset+len(name)), "%q is not bold in %q", name, text)
+	}
+
+	for _, name := range []string{"Las Vegas_NV", "A*B", "*Star*", "São Paulo", "x`[1]`"} {
+		t.Run(name, func(t *testing.T) {
+			assertBoldName(t, handler.render(render.Weather, "en-US", render.WeatherView{Location: name}), name)
+			assertBoldName(t, handler.render(render.Forecast, "en-US", render.ForecastView{Location: name}), name)
+			assertBoldName(t, handler.formatNextHourMessage(&services.WeatherData{}, name, "en-US"), name)
+
+			for language := range locService.GetSupportedLanguages() {
+				for _, key := range []string{"location_confirm_set", "hourly_title", "today_title", "share_title", "astro_title", "aqiforecast_title", "next_hour_title"} {
+					assertBoldName(t, locService.T(context.Background(), language, key, markdown.EscapeBold(name)), name)
+				}
+				assertBoldName(t, locService.T(context.Background(), language, "location_save_success_with_coords", markdown.EscapeBold(name), "US", 36.17, -115.14), name)
+			}
+		})
+	}
+}
+
+func TestHourlyKeyboard(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		logger:   logger,
+	}
+
+	first := handler.hourlyKeyboard("New York", 0, 2, "en-US")
+	require.Len(t, first, 1)
+	require.Len(t, first[0], 1)
+	assert.Equal(t, "hourly_1_New+York", first[0][0].CallbackData)
+
+	last := handler.hourlyKeyboard("New York", 1, 2, "en-US")
+	require.Len(t, last[0], 1)
+	assert.Equal(t, "hourly_0_New+York", last[0][0].CallbackData)
+
+	assert.Nil(t, handler.hourlyKeyboard("New York", 0, 1, "en-US"))
+}
+
+func TestFormatSavedLocations(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		logger:   logger,
+	}
+
+	kyivID := uuid.MustParse("00000000-0000-0000-0000-000000000004")
+	lvivID := uuid.MustParse("00000000-0000-0000-0000-000000000009")
+	text, keyboard := handler.formatSavedLocations("en-US", []models.UserLocation{
+		{ID: kyivID, Name: "Kyiv", IsDefault: true},
+		{ID: lvivID, Name: "Lviv_Center"},
+	})
+
+	assert.Contains(t, text, "1. 🏠 Kyiv")
+	assert.Contains(t, text, `2. 📍 Lviv\_Center`)
+	assert.Contains(t, text, "/weather 2")
+
+	require.Len(t, keyboard, 3)
+	// The default has no button to make it the default
+	require.Len(t, keyboard[0], 2)
+	assert.Equal(t, "locations_weather_"+kyivID.String(), keyboard[0][0].CallbackData)
+	assert.Equal(t, "locations_delete_"+kyivID.String(), keyboard[0][1].CallbackData)
+	require.Len(t, keyboard[1], 3)
+	assert.Equal(t, "locations_default_"+lvivID.String(), keyboard[1][1].CallbackData)
+	for _, row := range keyboard {
+		for _, button := range row {
+			assert.LessOrEqual(t, len(button.CallbackData), 64, "callback data over Telegram's limit")
+		}
+	}
+	assert.Equal(t, "location_set_name", keyboard[2][0].CallbackData)
+}
+
+func TestSubscriptionTimeKeyboard(t *testing.T) {
+	keyboard := subscriptionTimeKeyboard("", func(timeOfDay string) string {
+		return "subscribe_daily_" + timeOfDay
+	})
+
+	require.Len(t, keyboard, 4)
+	assert.Len(t, keyboard[0], 2)
+	assert.Len(t, keyboard[3], 1)
+	assert.Equal(t, "🌞 08:00", keyboard[0][1].Text)
+	assert.Equal(t, "subscribe_daily_08:00", keyboard[0][1].CallbackData)
+	assert.Equal(t, "subscribe_daily_22:00", keyboard[3][0].CallbackData)
+
+	keyboard = subscriptionTimeKeyboard("08:00", func(timeOfDay string) string { return timeOfDay })
+	assert.Equal(t, "✅ 08:00", keyboard[0][1].Text)
+	assert.Equal(t, "🌅 06:00", keyboard[0][0].Text)
+}
+
+func TestSubscriptionEditKeyboard(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		logger:   logger,
+	}
+
+	sub := &models.Subscription{
+		ID:               uuid.New(),
+		SubscriptionType: models.SubscriptionDaily,
+		Frequency:        models.FrequencyDaily,
+		TimeOfDay:        "18:00",
+	}
+	keyboard := handler.subscriptionEditKeyboard(sub, time.Monday, "en-US")
+	require.Len(t, keyboard, 6)
+	assert.Equal(t, "✅ 18:00", keyboard[2][0].Text)
+	assert.Equal(t, "sub_time_"+sub.ID.String()+"_18:00", keyboard[2][0].CallbackData)
+	assert.Equal(t, "✅ Daily", keyboard[4][0].Text)
+	assert.Equal(t, "sub_freq_"+sub.ID.String()+"_weekly", keyboard[4][1].CallbackData)
+	assert.Equal(t, "notifications_manage", keyboard[5][0].CallbackData)
+
+	// Weekly ones pick their day, from the first day of the user's week
+	sunday := int(time.Sunday)
+	sub.Frequency, sub.SendWeekday = models.FrequencyWeekly, &sunday
+	keyboard = handler.subscriptionEditKeyboard(sub, time.Sunday, "en-US")
+	require.Len(t, keyboard, 8)
+	assert.Equal(t, "✅ Weekly", keyboard[4][1].Text)
+	assert.Equal(t, "✅ Sun", keyboard[5][0].Text)
+	assert.Equal(t, "sub_day_"+sub.ID.String()+"_1", keyboard[5][1].CallbackData)
+	assert.Len(t, keyboard[6], 3)
+
+	// The weekly digest is always weekly
+	sub.SubscriptionType, sub.Frequency = models.SubscriptionWeekly, models.FrequencyWeekly
+	keyboard = handler.subscriptionEditKeyboard(sub, time.Monday, "en-US")
+	require.Len(t, keyboard, 7)
+	assert.Equal(t, "Mon", keyboard[4][0].Text)
+
+	for _, row := range keyboard {
+		for _, button := range row {
+			assert.LessOrEqual(t, len(button.CallbackData), 64, "callback data over Telegram's limit")
+		}
+	}
+}
+
+func TestCommandRegistry_Translated(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+
+	seen := make(map[string]bool)
+	for _, command := range commandRegistry {
+		assert.False(t, seen[command.Name], "duplicate command %s", command.Name)
+		seen[command.Name] = true
+		for _, key := range []string{command.Description, command.Category} {
+			assert.NotEqual(t, key, locService.T(context.Background(), "en-US", key), "/%s: missing key %s", command.Name, key)
+		}
+	}
+}
+
+func TestSearchCommands(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	describe := func(command CommandMetadata) string {
+		return locService.T(context.Background(), "en-US", command.Description)
+	}
+	names := func(matches []commandMatch) []string {
+		result := make([]string, len(matches))
+		for i, match := range matches {
+			result[i] = match.command.Name
+		}
+		return result
+	}
+
+	t.Run("name and description", func(t *testing.T) {
+		matches := searchCommands("air", describe, false, maxSearchResults)
+		require.NotEmpty(t, matches)
+		assert.Equal(t, "air", matches[0].command.Name)
+		assert.Equal(t, 0, matches[0].score)
+	})
+
+	t.Run("description only", func(t *testing.T) {
+		assert.ElementsMatch(t, []string{"subscribe", "unsubscribe", "settings", "deactivate"}, names(searchCommands("notif", describe, false, maxSearchResults)))
+		assert.Equal(t, []string{"settings"}, names(searchCommands("export", describe, false, maxSearchResults)))
+	})
+
+	t.Run("typo in the name", func(t *testing.T) {
+		matches := searchCommands("/forcast", describe, false, maxSearchResults)
+		require.NotEmpty(t, matches)
+		assert.Equal(t, "forecast", matches[0].command.Name)
+	})
+
+	t.Run("admin commands", func(t *testing.T) {
+		assert.NotContains(t, names(searchCommands("stats", describe, false, maxSearchResults)), "stats")
+		assert.Contains(t, names(searchCommands("stats", describe, true, maxSearchResults)), "stats")
+	})
+
+	t.Run("limit and empty keyword", func(t *testing.T) {
+		assert.Len(t, searchCommands("s", describe, true, 2), 2)
+		assert.Empty(t, searchCommands(" ", describe, true, maxSearchResults))
+	})
+}
+
+func TestFormatSearchResults(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	locService := services.NewLocalizationService(logger)
+	require.NoError(t, locService.LoadTranslations(locales.LocalesFS))
+	handler := &CommandHandler{
+		services: &services.Services{Localization: locService},
+		logger:   logger,
+	}
+
+	matches := []commandMatch{{command: CommandMetadata{Name: "air", Description: "help_air", Category: categoryBasic}}}
+	text, keyboard := handler.formatSearchResults("air_q", matches, "en-US", "shopogoda_bot")
+
+	assert.Equal(t, "🔎 *Commands for \"air_q\"*\n\n/air - Air quality index and pollutants", text)
+	require.Len(t, keyboard, 1)
+	assert.Equal(t, "/air", keyboard[0][0].Text)
+	assert.Equal(t, "https://t.me/shopogoda_bot?start=cmd_air", keyboard[0][0].Url)
+}
+
+func newTestRenderer(t *testing.T, translator render.Translator) *render.TemplateRenderer {
+	renderer, err := render.NewTemplateRenderer(render.TemplatesFS, translator)
+	require.NoError(t, err)
+	return renderer
+}
@@ -0,0 +1,165 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/internal/services"
+)
+
+// chatSettingsKey memoizes the settings of a group chat in the update data
+const chatSettingsKey = "chat_settings"
+
+// isGroupChat reports whether a chat is a group or supergroup, where weather
+// commands answer for the group rather than for the member who asks
+func isGroupChat(chat *gotgbot.Chat) bool {
+	return chat != nil && (chat.Type == gotgbot.ChatTypeGroup || chat.Type == gotgbot.ChatTypeSupergroup)
+}
+
+// SetGroupLocation command sets the location, language and units weather
+// commands in a group answer with. Only the group's admins may use it.
+func (h *CommandHandler) SetGroupLocation(bot *gotgbot.Bot, ctx *ext.Context) error {
+	return h.setGroupLocation(bot, ctx, h.parseLocationFromArgs(ctx))
+}
+
+// setGroupLocation stores the location of a group, also for /today set. A
+// group without a language or units yet takes those of the admin.
+func (h *CommandHandler) setGroupLocation(bot *gotgbot.Bot, ctx *ext.Context, locationName string) error {
+	userID := ctx.EffectiveUser.Id
+	chat := ctx.EffectiveChat
+	userLang := h.getUserLanguage(ctx, userID)
+
+	reply := func(key string, args ...any) error {
+		_, err := bot.SendMessage(chat.Id, h.services.Localization.T(context.Background(), userLang, key, args...), nil)
+		return err
+	}
+
+	if chat.Type == gotgbot.ChatTypePrivate {
+		return reply("group_location_private")
+	}
+	if locationName == "" {
+		return reply("group_location_usage")
+	}
+	if !h.isChatAdmin(bot, chat.Id, userID) {
+		return reply("group_location_admin_only")
+	}
+
+	location, err := h.services.Weather.GeocodeLocation(h.weatherContext(ctx), locationName)
+	if err != nil {
+		return reply("group_location_not_found")
+	}
+
+	admin, err := h.getUser(ctx, userID)
+	if err != nil || admin == nil {
+		admin = &models.User{ID: userID}
+	}
+	settings, err := h.services.ChatSettings.SetChatLocation(context.Background(), chat.Id, admin, location.Name, location.Latitude, location.Longitude)
+	if err != nil {
+		h.logger.Error().Err(err).Int64("chat_id", chat.Id).Msg("Failed to set group location")
+		return reply("group_location_failed")
+	}
+	h.rememberGroupSettings(ctx, settings)
+
+	language := h.getChatLanguage(ctx, userID)
+	_, err = bot.SendMessage(chat.Id, h.services.Localization.T(context.Background(), language, "group_location_set",
+		location.Name, h.getLocalizedUnitsText(context.Background(), language, h.getChatUnits(ctx, userID))), nil)
+	return err
+}
+
+// isChatAdmin reports whether the user is the creator or an admin of the chat.
+// A failed lookup counts as not an admin.
+func (h *CommandHandler) isChatAdmin(bot *gotgbot.Bot, chatID, userID int64) bool {
+	member, err := bot.GetChatMember(chatID, userID, nil)
+	if err != nil {
+		h.logger.Warn().Err(err).Int64("chat_id", chatID).Int64("user_id", userID).Msg("Failed to check chat admin status")
+		return false
+	}
+	status := member.GetStatus()
+	return status == "creator" || status == "administrator"
+}
+
+// getGroupSettings returns the settings of the group the update comes from,
+// or nil in a private chat and in a group without settings. They are read
+// once per update.
+func (h *CommandHandler) getGroupSettings(ctx *ext.Context) *models.ChatSettings {
+	if !isGroupChat(ctx.EffectiveChat) {
+		return nil
+	}
+	if settings, ok := ctx.Data[chatSettingsKey].(*models.ChatSettings); ok {
+		return settings
+	}
+
+	settings, err := h.services.ChatSettings.GetChatSettings(context.Background(), ctx.EffectiveChat.Id)
+	if err != nil {
+		// Without the group's settings the member's own are used
+		h.logger.Warn().Err(err).Int64("chat_id", ctx.EffectiveChat.Id).Msg("Failed to load chat settings")
+		return nil
+	}
+	h.rememberGroupSettings(ctx, settings)
+	return settings
+}
+
+// rememberGroupSettings memoizes the settings of the group for the update
+func (h *CommandHandler) rememberGroupSettings(ctx *ext.Context, settings *models.ChatSettings) {
+	if ctx.Data == nil {
+		ctx.Data = make(map[string]any)
+	}
+	ctx.Data[chatSettingsKey] = settings
+}
+
+// getChatLocation returns the location weather commands use when none is
+// given: the group's in a group that set one, the user's own otherwise
+func (h *CommandHandler) getChatLocation(ctx *ext.Context, userID int64) (string, error) {
+	if settings := h.getGroupSettings(ctx); settings != nil && settings.HasLocation() {
+		return settings.LocationName, nil
+	}
+	locationName, _, _, err := h.getUserLocation(ctx, userID)
+	return locationName, err
+}
+
+// getChatLanguage returns the language weather commands answer in: the
+// group's in a group that set one, the user's own otherwise
+func (h *CommandHandler) getChatLanguage(ctx *ext.Context, userID int64) string {
+	if settings := h.getGroupSettings(ctx); settings != nil && settings.Language != "" {
+		return settings.Language
+	}
+	return h.getUserLanguage(ctx, userID)
+}
+
+// getChatUnits returns the units weather commands answer in: the group's in
+// a group that set them, the user's own otherwise
+func (h *CommandHandler) getChatUnits(ctx *ext.Context, userID int64) string {
+	if settings := h.getGroupSettings(ctx); settings != nil && settings.Units != "" {
+		return settings.Units
+	}
+	return h.getUserUnits(ctx, userID)
+}
+
+// renderForChat renders weather content in the group's language in a group
+// that set one, and as renderForUser otherwise. Messages to a group carry no
+// summary in the member's secondary language.
+func (h *CommandHandler) renderForChat(ctx *ext.Context, userID int64, render, summary services.RenderFunc) string {
+	if settings := h.getGroupSettings(ctx); settings != nil && settings.Language != "" {
+		return render(settings.Language)
+	}
+	return h.renderForUser(ctx, userID, render, summary)
+}
+
+// deliverToChat makes a subscription created in a group deliver to the group
+// rather than to the user's private chat, and returns a note telling the user
+// so. In a private chat, or when the change fails and the subscription stays
+// with the user, the note is empty.
+func (h *CommandHandler) deliverToChat(ctx *ext.Context, subscription *models.Subscription, language string) string {
+	if subscription == nil || !isGroupChat(ctx.EffectiveChat) {
+		return ""
+	}
+	err := h.services.Subscription.SetDeliveryChat(context.Background(), subscription.UserID, subscription.ID, ctx.EffectiveChat.Id)
+	if err != nil {
+		h.logger.Error().Err(err).Int64("chat_id", ctx.EffectiveChat.Id).Msg("Failed to deliver subscription to group")
+		return ""
+	}
+	return "\n\n" + h.services.Localization.T(context.Background(), language, "subscription_group_delivery")
+}
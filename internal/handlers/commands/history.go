@@ -0,0 +1,140 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/services"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+const (
+	historyDays = 7 // Days shown by /history
+	// historyConditions is how many of the most common kinds of weather the
+	// summary names
+	historyConditions = 3
+
+	// historyTrendThreshold is how far, in °C, the middle of a day's range must
+	// move from the day before to count as warmer or colder
+	historyTrendThreshold = 1.0
+)
+
+// History shows the lowest and highest temperature recorded at the user's
+// location on each of the last days, with a summary of the weather the user
+// looked up or was sent there
+func (h *CommandHandler) History(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	locationName, lat, lon, err := h.getUserLocation(ctx, userID)
+	if err != nil || locationName == "" {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "history_location_needed"), nil)
+		return err
+	}
+
+	history, err := h.services.Weather.GetWeatherHistory(context.Background(), userID, historyDays)
+	if err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to get weather history")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "history_error"), nil)
+		return err
+	}
+
+	var summary *services.WeatherRecordSummary
+	if h.services.Records != nil {
+		since := time.Now().UTC().AddDate(0, 0, -historyDays)
+		summary, err = h.services.Records.Summarize(context.Background(), userID, lat, lon, since)
+		if err != nil {
+			h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to summarize weather records")
+		}
+	}
+
+	text := h.formatHistoryMessage(history, summary, locationName, userLang, h.getUserUnits(ctx, userID))
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{ParseMode: "Markdown"})
+	return err
+}
+
+// formatHistoryMessage lists one line per day with its temperature range and
+// an arrow comparing it with the day before, followed by the summary of the
+// recorded weather when there is one
+func (h *CommandHandler) formatHistoryMessage(history []services.DailyWeatherHistory, summary *services.WeatherRecordSummary, locationName, lang, units string) string {
+	if len(history) == 0 && summary == nil {
+		return h.services.Localization.T(context.Background(), lang, "history_empty", markdown.Escape(locationName))
+	}
+
+	if len(history) > 0 {
+		locationName = history[len(history)-1].LocationName
+	}
+	var b strings.Builder
+	b.WriteString(h.services.Localization.T(context.Background(), lang, "history_title", markdown.EscapeBold(locationName), historyDays))
+	b.WriteString("\n\n")
+	for i, day := range history {
+		trend := "▫️"
+		if i > 0 {
+			trend = historyTrend(history[i-1], day)
+		}
+		fmt.Fprintf(&b, "%s *%s %s*: %s … %s\n", trend,
+			h.weekdayName(lang, day.Day.Weekday()), day.Day.Format("02.01"),
+			weather.FormatTemp(day.MinTemp, units), weather.FormatTemp(day.MaxTemp, units))
+	}
+	if summary != nil {
+		if len(history) > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(h.formatHistorySummary(summary, lang, units))
+		b.WriteString("\n")
+	}
+	if len(history) > 0 {
+		b.WriteString("\n")
+		b.WriteString(h.services.Localization.T(context.Background(), lang, "history_legend"))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// formatHistorySummary renders the temperatures and most common kinds of
+// weather of the recorded weather
+func (h *CommandHandler) formatHistorySummary(summary *services.WeatherRecordSummary, lang, units string) string {
+	text := h.services.Localization.T(context.Background(), lang, "history_summary", historyDays,
+		weather.FormatTemp(summary.MinTemp, units), weather.FormatTemp(summary.AvgTemp, units),
+		weather.FormatTemp(summary.MaxTemp, units), summary.Samples)
+
+	var conditions []string
+	for _, condition := range summary.Conditions[:min(len(summary.Conditions), historyConditions)] {
+		name := h.services.Localization.T(context.Background(), lang, "history_condition_"+condition.Group)
+		conditions = append(conditions, fmt.Sprintf("%s %.0f%%", name, condition.Share*100))
+	}
+	if len(conditions) > 0 {
+		text += "\n" + h.services.Localization.T(context.Background(), lang, "history_summary_conditions", strings.Join(conditions, ", "))
+	}
+	return text
+}
+
+// recordWeather keeps the weather shown in the chat in the user's weather
+// records, for the summary of /history and data exports
+func (h *CommandHandler) recordWeather(ctx *ext.Context, lat, lon float64, current *services.WeatherData) {
+	if h.services.Records == nil || current == nil {
+		return
+	}
+	userID := ctx.EffectiveUser.Id
+	if _, err := h.services.Records.Record(context.Background(), userID, ctx.EffectiveChat.Id, lat, lon, current); err != nil {
+		h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to record weather")
+	}
+}
+
+// historyTrend returns the arrow for a day compared with the one before
+func historyTrend(previous, day services.DailyWeatherHistory) string {
+	change := (day.MinTemp+day.MaxTemp)/2 - (previous.MinTemp+previous.MaxTemp)/2
+	switch {
+	case change >= historyTrendThreshold:
+		return "↗️"
+	case change <= -historyTrendThreshold:
+		return "↘️"
+	default:
+		return "➡️"
+	}
+}
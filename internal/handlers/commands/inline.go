@@ -0,0 +1,212 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/render"
+	"github.com/valpere/shopogoda/internal/services"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+const (
+	// inlineStartParameter is the /start payload of the button offered to
+	// users with no location who send an empty inline query
+	inlineStartParameter = "inline"
+
+	// inlineDescriptionLimit is the most characters Telegram shows of an
+	// inline result's description
+	inlineDescriptionLimit = 512
+
+	// inlineForecastDays is how many days the forecast result covers
+	inlineForecastDays = 5
+)
+
+// InlineQuery answers "@bot <place>" in any chat with the current weather,
+// the 5-day forecast and the air quality of the best matching place, followed
+// by the current weather of the other matches. An empty query offers the
+// user's own location.
+func (h *CommandHandler) InlineQuery(bot *gotgbot.Bot, ctx *ext.Context) error {
+	query := ctx.InlineQuery
+	userID := query.From.Id
+	userLang := h.getUserLanguage(ctx, userID)
+	units := h.getUserUnits(ctx, userID)
+	text := strings.TrimSpace(query.Query)
+
+	var places []services.InlineWeather
+	if text == "" {
+		if name, lat, lon, err := h.getUserLocation(ctx, userID); err == nil {
+			current, err := h.services.Weather.GetInlineWeather(weather.WithLanguage(h.weatherContext(ctx), userLang), lat, lon)
+			if err != nil {
+				h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to get weather for inline query")
+			} else {
+				current.LocationName = name
+				places = append(places, services.InlineWeather{
+					Location: weather.Location{Name: name, Latitude: lat, Longitude: lon},
+					Weather:  current,
+				})
+			}
+		}
+	} else {
+		var err error
+		places, err = h.services.Weather.SearchInlineWeather(weather.WithLanguage(h.weatherContext(ctx), userLang), text)
+		if err != nil {
+			h.logger.Debug().Err(err).Str("query", text).Msg("No places for inline query")
+		}
+	}
+
+	results := make([]gotgbot.InlineQueryResult, 0, len(places)+2)
+	for i, place := range places {
+		results = append(results, h.inlineWeatherArticle(place, userLang, units))
+		if i > 0 {
+			continue
+		}
+		lat, lon := place.Location.Latitude, place.Location.Longitude
+		forecast, err := h.services.Weather.GetForecast(weather.WithLanguage(h.weatherContext(ctx), userLang), lat, lon, inlineForecastDays)
+		if err != nil {
+			h.logger.Warn().Err(err).Str("location", place.Location.Name).Msg("Failed to get forecast for inline query")
+		} else {
+			results = append(results, h.inlineForecastArticle(place, forecast, userLang, units, h.getUserTimezone(ctx, userID)))
+		}
+		if hasAirQuality(place.Weather) {
+			results = append(results, h.inlineAirQualityArticle(place, userLang, h.getUserTimezone(ctx, userID)))
+		}
+	}
+
+	// Answers depend on the user's language and units, so they are personal
+	cacheTime := int64(services.InlineQueryResultCacheTime / time.Second)
+	opts := &gotgbot.AnswerInlineQueryOpts{CacheTime: &cacheTime, IsPersonal: true}
+	if len(results) == 0 && text == "" {
+		opts.Button = &gotgbot.InlineQueryResultsButton{
+			Text:           h.services.Localization.T(context.Background(), userLang, "inline_set_location"),
+			StartParameter: inlineStartParameter,
+		}
+	}
+
+	_, err := query.Answer(bot, results, opts)
+	return err
+}
+
+// inlineWeatherArticle renders one place as an inline result whose message is
+// the weather summary
+func (h *CommandHandler) inlineWeatherArticle(place services.InlineWeather, lang, units string) gotgbot.InlineQueryResultArticle {
+	current := *place.Weather
+	title := h.inlinePlaceTitle(place, lang)
+	current.LocationName = title
+
+	return gotgbot.InlineQueryResultArticle{
+		Id:    inlineResultID(place, ""),
+		Title: title,
+		Description: inlineDescription(
+			fmt.Sprintf("%s %s, %s", current.Icon, weather.FormatTemp(current.Temperature, units), current.Description),
+			"💧 "+weather.FormatPercent(float64(current.Humidity)),
+			"💨 "+weather.FormatSpeed(current.WindSpeed, units),
+		),
+		InputMessageContent: gotgbot.InputTextMessageContent{
+			MessageText: services.WeatherSummary(h.services.Localization, &current, units)(lang),
+			ParseMode:   "Markdown",
+		},
+	}
+}
+
+// inlineForecastArticle renders the forecast of a place as an inline result
+// listing the highs and lows, whose message is the full forecast with sun
+// times in zone, the user's timezone
+func (h *CommandHandler) inlineForecastArticle(place services.InlineWeather, forecast *weather.ForecastData, lang, units string, zone *time.Location) gotgbot.InlineQueryResultArticle {
+	title := h.inlinePlaceTitle(place, lang)
+	days := make([]string, 0, len(forecast.Forecasts))
+	for _, day := range forecast.Forecasts {
+		days = append(days, fmt.Sprintf("%s %s/%s %s", h.services.Localization.LocalizedShortWeekday(day.Date, lang),
+			weather.FormatTemp(day.MaxTemp, units), weather.FormatTemp(day.MinTemp, units), day.Icon))
+	}
+	view := h.forecastView(forecast, lang, units, zone)
+	view.Location = title
+
+	return gotgbot.InlineQueryResultArticle{
+		Id:          inlineResultID(place, "forecast"),
+		Title:       h.services.Localization.T(context.Background(), lang, "inline_forecast_title", title),
+		Description: inlineDescription(days...),
+		InputMessageContent: gotgbot.InputTextMessageContent{
+			MessageText: h.render(render.Forecast, lang, view),
+			ParseMode:   "Markdown",
+		},
+	}
+}
+
+// inlineAirQualityArticle renders the air quality of a place as an inline
+// result with the index and fine particles, whose message is the full reading
+// timed in zone, the user's timezone
+func (h *CommandHandler) inlineAirQualityArticle(place services.InlineWeather, lang string, zone *time.Location) gotgbot.InlineQueryResultArticle {
+	current := place.Weather
+	title := h.inlinePlaceTitle(place, lang)
+	air := &weather.AirQualityData{
+		AQI: current.AQI, CO: current.CO, NO2: current.NO2, O3: current.O3,
+		PM25: current.PM25, PM10: current.PM10, Timestamp: current.Timestamp,
+	}
+
+	return gotgbot.InlineQueryResultArticle{
+		Id:    inlineResultID(place, "air"),
+		Title: h.services.Localization.T(context.Background(), lang, "inline_air_title", title),
+		Description: inlineDescription(
+			fmt.Sprintf("AQI %s, %s", weather.FormatAQI(float64(air.AQI)), h.getAQIDescription(air.AQI, lang)),
+			fmt.Sprintf("PM2.5 %.1f μg/m³", air.PM25),
+			fmt.Sprintf("PM10 %.1f μg/m³", air.PM10),
+		),
+		InputMessageContent: gotgbot.InputTextMessageContent{
+			MessageText: fmt.Sprintf("📍 *%s*\n\n%s", markdown.EscapeBold(title), h.render(render.AirQuality, lang, h.airQualityView(air, lang, zone))),
+			ParseMode:   "Markdown",
+		},
+	}
+}
+
+// inlinePlaceTitle names a place in the user's language with its region and
+// country, e.g. "Kyiv, UA"
+func (h *CommandHandler) inlinePlaceTitle(place services.InlineWeather, lang string) string {
+	title := h.services.Weather.GetLocalizedLocationName(&place.Location, lang)
+	if region := strings.Join(nonEmpty(place.Location.State, place.Location.Country), ", "); region != "" {
+		title += ", " + region
+	}
+	return title
+}
+
+// inlineResultID identifies a kind of result for a place; the current weather
+// has no kind
+func inlineResultID(place services.InlineWeather, kind string) string {
+	id := fmt.Sprintf("%.4f:%.4f", place.Location.Latitude, place.Location.Longitude)
+	if kind != "" {
+		id += ":" + kind
+	}
+	return id
+}
+
+// inlineDescription joins the metrics of a result, cut to what Telegram shows
+func inlineDescription(metrics ...string) string {
+	description := []rune(strings.Join(metrics, " · "))
+	if len(description) <= inlineDescriptionLimit {
+		return string(description)
+	}
+	return string(description[:inlineDescriptionLimit-1]) + "…"
+}
+
+// hasAirQuality reports whether the weather carries an air quality reading.
+// A failed reading leaves every concentration at zero.
+func hasAirQuality(current *services.WeatherData) bool {
+	return current.AQI > 0 || current.PM25 > 0 || current.PM10 > 0 || current.O3 > 0
+}
+
+// nonEmpty drops empty strings
+func nonEmpty(values ...string) []string {
+	kept := values[:0]
+	for _, value := range values {
+		if value != "" {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,191 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/services"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+// WeatherHere command handler: sent as a reply to a shared location or venue,
+// shows the weather there
+func (h *CommandHandler) WeatherHere(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+
+	reply := ctx.EffectiveMessage.ReplyToMessage
+	if reply == nil || reply.Location == nil {
+		userLang := h.getUserLanguage(ctx, userID)
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "weatherhere_usage"), nil)
+		return err
+	}
+
+	if limited, err := h.weatherRateLimited(bot, ctx, userID); limited {
+		return err
+	}
+
+	label := ""
+	if reply.Venue != nil {
+		label = reply.Venue.Title
+	}
+	return h.sendCoordinateWeather(bot, ctx, reply.Location.Latitude, reply.Location.Longitude, label, true)
+}
+
+// offerLiveFollow remembers a live location the user just shared and offers to
+// follow it for weather changes while it is shared
+func (h *CommandHandler) offerLiveFollow(bot *gotgbot.Bot, ctx *ext.Context) error {
+	msg := ctx.EffectiveMessage
+	userID := ctx.EffectiveUser.Id
+	chatID := ctx.EffectiveChat.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	until := services.LivePeriodEnd(time.Unix(msg.Date, 0), msg.Location.LivePeriod)
+	if err := h.services.Live.Share(context.Background(), userID, chatID, msg.MessageId, msg.Location.Latitude, msg.Location.Longitude, until); err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to remember live location")
+		return err
+	}
+
+	zone := h.getUserTimezone(ctx, userID)
+	text := h.services.Localization.T(context.Background(), userLang, "live_location_offer", until.In(zone).Format("15:04"))
+	followText := h.services.Localization.T(context.Background(), userLang, "live_follow_btn")
+	_, err := bot.SendMessage(chatID, text, &gotgbot.SendMessageOpts{
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
+			InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+				{{Text: followText, CallbackData: fmt.Sprintf("live_follow_%d", msg.MessageId)}},
+			},
+		},
+	})
+	return err
+}
+
+// HandleLiveLocationUpdate handles the edits Telegram makes to a live location
+// message: the user moved, or stopped sharing
+func (h *CommandHandler) HandleLiveLocationUpdate(bot *gotgbot.Bot, ctx *ext.Context) error {
+	msg := ctx.EffectiveMessage
+	userID := ctx.EffectiveUser.Id
+	chatID := ctx.EffectiveChat.Id
+
+	// A live location that stopped updating comes without a live period
+	if msg.Location.LivePeriod == 0 {
+		live, err := h.services.Live.Stop(context.Background(), userID, chatID)
+		if err != nil {
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to stop live location")
+			return err
+		}
+		if live == nil || !live.Following || live.MessageID != msg.MessageId {
+			return nil
+		}
+		userLang := h.getUserLanguage(ctx, userID)
+		_, err = bot.SendMessage(chatID, h.services.Localization.T(context.Background(), userLang, "live_follow_ended"), nil)
+		return err
+	}
+
+	userLang := h.getUserLanguage(ctx, userID)
+	change, err := h.services.Live.Move(weather.WithLanguage(h.weatherContext(ctx), userLang), userID, chatID, msg.MessageId, msg.Location.Latitude, msg.Location.Longitude)
+	if err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to update live location")
+		return err
+	}
+	if change == nil {
+		return nil
+	}
+	return h.sendLiveWeatherChange(bot, ctx, change)
+}
+
+// sendLiveWeatherChange tells the user how the weather changed along the way
+func (h *CommandHandler) sendLiveWeatherChange(bot *gotgbot.Bot, ctx *ext.Context, change *services.LiveWeatherChange) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+	units := h.getUserUnits(ctx, userID)
+	current := change.Current
+
+	var text string
+	if change.ConditionChanged {
+		text = h.services.Localization.T(context.Background(), userLang, "live_weather_changed",
+			current.Icon, current.Description, weather.FormatTemp(current.Temperature, units))
+	}
+	if change.AQIChanged {
+		if text != "" {
+			text += "\n"
+		}
+		category := h.services.Localization.T(context.Background(), userLang, services.AQIDescriptionKey(current.AQI))
+		text += h.services.Localization.T(context.Background(), userLang, "live_aqi_changed", current.AQI, category)
+	}
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ReplyMarkup: h.liveStopKeyboard(userLang),
+	})
+	return err
+}
+
+// handleLiveCallback handles the follow and stop buttons of live locations
+func (h *CommandHandler) handleLiveCallback(bot *gotgbot.Bot, ctx *ext.Context, subAction string, params []string) error {
+	userID := ctx.EffectiveUser.Id
+	chatID := ctx.EffectiveChat.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	switch subAction {
+	case "follow":
+		if len(params) == 0 {
+			return nil
+		}
+		messageID, err := strconv.ParseInt(params[0], 10, 64)
+		if err != nil {
+			return nil
+		}
+
+		live, current, err := h.services.Live.Follow(weather.WithLanguage(h.weatherContext(ctx), userLang), userID, chatID, messageID)
+		if errors.Is(err, services.ErrLiveLocationEnded) {
+			return h.editLiveMessage(bot, ctx, h.services.Localization.T(context.Background(), userLang, "live_follow_ended"), nil)
+		}
+		if err != nil {
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to follow live location")
+			errorMsg := h.services.Localization.T(context.Background(), userLang, "error_weather_location_failed")
+			_, err := bot.SendMessage(chatID, errorMsg, nil)
+			return err
+		}
+
+		zone := h.getUserTimezone(ctx, userID)
+		units := h.getUserUnits(ctx, userID)
+		text := h.services.Localization.T(context.Background(), userLang, "live_follow_started",
+			current.Icon, current.Description, weather.FormatTemp(current.Temperature, units), live.Until.In(zone).Format("15:04"))
+		return h.editLiveMessage(bot, ctx, text, h.liveStopKeyboard(userLang))
+
+	case "stop":
+		if _, err := h.services.Live.Stop(context.Background(), userID, chatID); err != nil {
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to stop live location")
+			return err
+		}
+		return h.editLiveMessage(bot, ctx, h.services.Localization.T(context.Background(), userLang, "live_follow_stopped"), nil)
+	}
+
+	return nil
+}
+
+func (h *CommandHandler) liveStopKeyboard(userLang string) *gotgbot.InlineKeyboardMarkup {
+	stopText := h.services.Localization.T(context.Background(), userLang, "live_follow_stop_btn")
+	return &gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+			{{Text: stopText, CallbackData: "live_stop"}},
+		},
+	}
+}
+
+// editLiveMessage replaces the message whose button was pressed
+func (h *CommandHandler) editLiveMessage(bot *gotgbot.Bot, ctx *ext.Context, text string, markup *gotgbot.InlineKeyboardMarkup) error {
+	opts := &gotgbot.EditMessageTextOpts{
+		ChatId:    ctx.EffectiveChat.Id,
+		MessageId: ctx.CallbackQuery.Message.GetMessageId(),
+	}
+	if markup != nil {
+		opts.ReplyMarkup = *markup
+	}
+	_, _, err := bot.EditMessageText(text, opts)
+	return err
+}
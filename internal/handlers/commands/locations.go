@@ -0,0 +1,318 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/google/uuid"
+
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/internal/services"
+)
+
+// ListLocations shows the user's saved locations with buttons to check their
+// weather, make one the default or delete it
+func (h *CommandHandler) ListLocations(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	saved, err := h.services.User.ListUserLocations(context.Background(), userID)
+	if err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to list saved locations")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "locations_failed"), nil)
+		return err
+	}
+
+	if len(saved) == 0 {
+		noLocationText := h.services.Localization.T(context.Background(), userLang, "listlocations_no_location")
+		setLocationBtnText := h.services.Localization.T(context.Background(), userLang, "listlocations_set_location_btn")
+
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, noLocationText,
+			&gotgbot.SendMessageOpts{
+				ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
+					InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+						{{Text: setLocationBtnText, CallbackData: "location_set"}},
+					},
+				},
+			})
+		return err
+	}
+
+	text, keyboard := h.formatSavedLocations(userLang, saved)
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ParseMode: "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
+			InlineKeyboard: keyboard,
+		},
+	})
+	return err
+}
+
+// formatSavedLocations numbers the saved locations as /weather, /forecast and
+// /air accept them, with a row of buttons per location
+func (h *CommandHandler) formatSavedLocations(userLang string, saved []models.UserLocation) (string, [][]gotgbot.InlineKeyboardButton) {
+	text := h.services.Localization.T(context.Background(), userLang, "locations_title")
+	var keyboard [][]gotgbot.InlineKeyboardButton
+
+	deleteText := h.services.Localization.T(context.Background(), userLang, "locations_delete_btn")
+	defaultText := h.services.Localization.T(context.Background(), userLang, "locations_default_btn")
+	for i, location := range saved {
+		marker := "📍"
+		if location.IsDefault {
+			marker = "🏠"
+		}
+		text += fmt.Sprintf("\n%d. %s %s", i+1, marker, markdown.Escape(location.Name))
+
+		row := []gotgbot.InlineKeyboardButton{
+			{Text: fmt.Sprintf("%d. %s", i+1, location.Name), CallbackData: fmt.Sprintf("locations_weather_%s", location.ID)},
+		}
+		if !location.IsDefault {
+			row = append(row, gotgbot.InlineKeyboardButton{Text: defaultText, CallbackData: fmt.Sprintf("locations_default_%s", location.ID)})
+		}
+		row = append(row, gotgbot.InlineKeyboardButton{Text: deleteText, CallbackData: fmt.Sprintf("locations_delete_%s", location.ID)})
+		keyboard = append(keyboard, row)
+	}
+
+	text += "\n\n" + h.services.Localization.T(context.Background(), userLang, "locations_hint", len(saved))
+	if len(saved) < services.MaxSavedLocations {
+		addText := h.services.Localization.T(context.Background(), userLang, "locations_add_btn")
+		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{{Text: addText, CallbackData: "location_set_name"}})
+	}
+	return text, keyboard
+}
+
+// savedLocationSwitches lists the saved locations for /setlocation, with a
+// button to switch to each one that is not the default. Both are empty when
+// the user has no saved locations.
+func (h *CommandHandler) savedLocationSwitches(userID int64, userLang string) (string, [][]gotgbot.InlineKeyboardButton) {
+	saved, err := h.services.User.ListUserLocations(context.Background(), userID)
+	if err != nil {
+		h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to list saved locations")
+		return "", nil
+	}
+	if len(saved) == 0 {
+		return "", nil
+	}
+
+	text := "\n\n" + h.services.Localization.T(context.Background(), userLang, "setlocation_saved_title")
+	var keyboard [][]gotgbot.InlineKeyboardButton
+	for _, location := range saved {
+		marker := "📍"
+		if location.IsDefault {
+			marker = "🏠"
+		}
+		text += fmt.Sprintf("\n%s %s", marker, markdown.Escape(location.Name))
+		if location.IsDefault {
+			continue
+		}
+		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{{
+			Text:         h.services.Localization.T(context.Background(), userLang, "setlocation_switch_btn", location.Name),
+			CallbackData: fmt.Sprintf("locations_default_%s", location.ID),
+		}})
+	}
+	return text, keyboard
+}
+
+// switchToSavedLocation makes the saved location with the given name the
+// default, reporting whether there was one
+func (h *CommandHandler) switchToSavedLocation(bot *gotgbot.Bot, ctx *ext.Context, locationName string) (bool, error) {
+	userID := ctx.EffectiveUser.Id
+	saved, err := h.services.User.ListUserLocations(context.Background(), userID)
+	if err != nil {
+		h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to list saved locations")
+		return false, nil
+	}
+
+	for _, location := range saved {
+		if !strings.EqualFold(location.Name, locationName) {
+			continue
+		}
+		if err := h.services.User.SetDefaultLocation(context.Background(), userID, location.ID); err != nil {
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to switch location")
+			userLang := h.getUserLanguage(ctx, userID)
+			_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "setlocation_save_failed"), nil)
+			return true, err
+		}
+		return true, h.sendSetLocationSuccess(bot, ctx, location.Name)
+	}
+	return false, nil
+}
+
+// resolveSavedLocation picks the location /weather, /forecast or /air answers
+// for. A number selects a saved location as /locations lists them; without an
+// argument a user with several saved locations is asked to pick one. It
+// reports whether the picker was sent, which answers the command. Groups
+// answer for their own location, and a member's saved places are not
+// shown to the whole group.
+func (h *CommandHandler) resolveSavedLocation(bot *gotgbot.Bot, ctx *ext.Context, command, location string) (string, bool, error) {
+	if isGroupChat(ctx.EffectiveChat) {
+		return location, false, nil
+	}
+
+	index, err := strconv.Atoi(location)
+	if location != "" && err != nil {
+		return location, false, nil
+	}
+
+	userID := ctx.EffectiveUser.Id
+	saved, err := h.services.User.ListUserLocations(context.Background(), userID)
+	if err != nil {
+		h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to list saved locations")
+		return location, false, nil
+	}
+
+	if location != "" {
+		// Numbers beyond the list are left to the geocoder, e.g. postcodes
+		if index >= 1 && index <= len(saved) {
+			return saved[index-1].Name, false, nil
+		}
+		return location, false, nil
+	}
+	if len(saved) < 2 {
+		return "", false, nil
+	}
+
+	userLang := h.getUserLanguage(ctx, userID)
+	keyboard := make([][]gotgbot.InlineKeyboardButton, 0, len(saved))
+	for i, location := range saved {
+		marker := "📍"
+		if location.IsDefault {
+			marker = "🏠"
+		}
+		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{{
+			Text:         fmt.Sprintf("%d. %s %s", i+1, marker, location.Name),
+			CallbackData: fmt.Sprintf("locations_%s_%s", command, location.ID),
+		}})
+	}
+
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "locations_pick"), &gotgbot.SendMessageOpts{
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	return "", true, err
+}
+
+// offerAnotherLocation asks a user who already has a location whether a new
+// one replaces it or is saved next to it
+func (h *CommandHandler) offerAnotherLocation(bot *gotgbot.Bot, ctx *ext.Context, existing, locationName string) error {
+	userLang := h.getUserLanguage(ctx, ctx.EffectiveUser.Id)
+
+	replaceData, _ := h.callbackWithLabel("locations_replace", locationName)
+	keyboard := [][]gotgbot.InlineKeyboardButton{
+		{{Text: h.services.Localization.T(context.Background(), userLang, "locations_replace_btn", existing), CallbackData: replaceData}},
+	}
+	if addData, ok := h.callbackWithLabel("locations_add", locationName); ok {
+		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
+			{Text: h.services.Localization.T(context.Background(), userLang, "locations_add_another_btn"), CallbackData: addData},
+		})
+	}
+
+	text := h.services.Localization.T(context.Background(), userLang, "locations_add_or_replace", existing, locationName)
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	return err
+}
+
+// handleLocationsCallback handles the buttons of saved locations: checking the
+// weather of one, making it the default, deleting it, and saving a new one
+// next to or instead of the default
+func (h *CommandHandler) handleLocationsCallback(bot *gotgbot.Bot, ctx *ext.Context, action string, params []string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+	send := func(key string, args ...any) error {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, key, args...), nil)
+		return err
+	}
+
+	switch action {
+	case "add", "replace":
+		locationName, err := h.callbackLabel(params)
+		if err != nil {
+			if errors.Is(err, services.ErrLabelExpired) {
+				return h.sendLabelExpired(bot, ctx)
+			}
+			return err
+		}
+		coords, err := h.services.Weather.GeocodeLocation(h.weatherContext(ctx), locationName)
+		if err != nil {
+			return send("setlocation_not_found", locationName)
+		}
+
+		if action == "replace" {
+			if err := h.services.User.SetUserLocation(context.Background(), userID, locationName, coords.Country, "", coords.Latitude, coords.Longitude); err != nil {
+				h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to replace location")
+				return send("setlocation_save_failed")
+			}
+			return h.sendSetLocationSuccess(bot, ctx, locationName)
+		}
+
+		_, err = h.services.User.AddUserLocation(context.Background(), userID, locationName, coords.Country, "", coords.Latitude, coords.Longitude)
+		switch {
+		case errors.Is(err, services.ErrLocationExists):
+			return send("locations_exists", locationName)
+		case errors.Is(err, services.ErrTooManyLocations):
+			return send("locations_limit", services.MaxSavedLocations)
+		case err != nil:
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to add location")
+			return send("setlocation_save_failed")
+		}
+		return send("locations_added", locationName)
+	}
+
+	if len(params) == 0 {
+		return nil
+	}
+	locationID, err := uuid.Parse(params[0])
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "weather", "forecast", "air":
+		saved, err := h.services.User.ListUserLocations(context.Background(), userID)
+		if err != nil {
+			return err
+		}
+		for _, location := range saved {
+			if location.ID != locationID {
+				continue
+			}
+			switch action {
+			case "forecast":
+				return h.getForecastForLocation(bot, ctx, location.Name)
+			case "air":
+				return h.getAirQualityData(bot, ctx, location.Name)
+			default:
+				return h.getWeatherForLocation(bot, ctx, location.Name)
+			}
+		}
+		return send("locations_not_found")
+	case "default":
+		err := h.services.User.SetDefaultLocation(context.Background(), userID, locationID)
+		if errors.Is(err, services.ErrLocationNotFound) {
+			return send("locations_not_found")
+		}
+		if err != nil {
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to set default location")
+			return send("locations_failed")
+		}
+		return h.ListLocations(bot, ctx)
+	case "delete":
+		err := h.services.User.RemoveUserLocation(context.Background(), userID, locationID)
+		if errors.Is(err, services.ErrLocationNotFound) {
+			return send("locations_not_found")
+		}
+		if err != nil {
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to delete location")
+			return send("locations_failed")
+		}
+		return h.ListLocations(bot, ctx)
+	}
+	return nil
+}
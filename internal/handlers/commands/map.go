@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+// Map offers the weather layers that can be drawn around the user's location
+func (h *CommandHandler) Map(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	if locationName, _, _, err := h.getUserLocation(ctx, userID); err != nil || locationName == "" {
+		return h.sendLocationNeeded(bot, ctx, "map_location_needed")
+	}
+
+	userLang := h.getUserLanguage(ctx, userID)
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id,
+		h.services.Localization.T(context.Background(), userLang, "map_choose_layer"),
+		&gotgbot.SendMessageOpts{
+			ReplyMarkup: gotgbot.InlineKeyboardMarkup{InlineKeyboard: h.mapLayerKeyboard(userLang, "")},
+		})
+	return err
+}
+
+// handleMapCallback sends the map of the chosen layer. Buttons under a map
+// replace its photo; buttons under the /map prompt send a new one.
+func (h *CommandHandler) handleMapCallback(bot *gotgbot.Bot, ctx *ext.Context, action string, params []string) error {
+	if len(params) == 0 {
+		return nil
+	}
+	layer, err := weather.ParseMapLayer(params[0])
+	if err != nil {
+		h.logger.Warn().Err(err).Msg("Invalid map layer in callback")
+		return nil
+	}
+	return h.sendWeatherMap(bot, ctx, layer, action == "switch")
+}
+
+// sendWeatherMap renders one layer around the user's location
+func (h *CommandHandler) sendWeatherMap(bot *gotgbot.Bot, ctx *ext.Context, layer weather.MapLayer, edit bool) error {
+	userID := ctx.EffectiveUser.Id
+
+	locationName, lat, lon, ok, err := h.mapLocation(bot, ctx, "map_location_needed")
+	if !ok {
+		return err
+	}
+
+	image, err := h.services.Weather.GetWeatherMap(h.weatherContext(ctx), lat, lon, weather.DefaultMapZoom, string(layer))
+	if err != nil {
+		return h.sendWeatherError(bot, ctx, locationName, err, "map_error")
+	}
+
+	if !edit {
+		if err := h.services.User.IncrementWeatherRequestCounter(context.Background()); err != nil {
+			h.logger.Warn().Err(err).Msg("Failed to increment weather request counter")
+		}
+	}
+
+	userLang := h.getUserLanguage(ctx, userID)
+	caption := h.services.Localization.T(context.Background(), userLang, "map_title",
+		h.mapLayerName(layer, userLang), markdown.EscapeBold(locationName))
+	keyboard := gotgbot.InlineKeyboardMarkup{InlineKeyboard: h.mapLayerKeyboard(userLang, layer)}
+	photo := gotgbot.InputFileByReader(fmt.Sprintf("map_%s.png", layer), bytes.NewReader(image))
+
+	if edit && ctx.CallbackQuery != nil {
+		_, _, err = bot.EditMessageMedia(gotgbot.InputMediaPhoto{
+			Media:     photo,
+			Caption:   caption,
+			ParseMode: "Markdown",
+		}, &gotgbot.EditMessageMediaOpts{
+			ChatId:      ctx.EffectiveChat.Id,
+			MessageId:   ctx.CallbackQuery.Message.GetMessageId(),
+			ReplyMarkup: keyboard,
+		})
+		return err
+	}
+
+	_, err = bot.SendPhoto(ctx.EffectiveChat.Id, photo, &gotgbot.SendPhotoOpts{
+		Caption:     caption,
+		ParseMode:   "Markdown",
+		ReplyMarkup: keyboard,
+	})
+	return err
+}
+
+// mapLayerKeyboard has one button per layer. Under a map showing current,
+// the buttons switch the photo in place and the current layer is marked.
+func (h *CommandHandler) mapLayerKeyboard(lang string, current weather.MapLayer) [][]gotgbot.InlineKeyboardButton {
+	action := "show"
+	if current != "" {
+		action = "switch"
+	}
+
+	row := make([]gotgbot.InlineKeyboardButton, 0, len(weather.MapLayers))
+	for _, layer := range weather.MapLayers {
+		text := h.mapLayerName(layer, lang)
+		if layer == current {
+			text = "✅ " + text
+		}
+		row = append(row, gotgbot.InlineKeyboardButton{
+			Text:         text,
+			CallbackData: fmt.Sprintf("map_%s_%s", action, layer),
+		})
+	}
+	return [][]gotgbot.InlineKeyboardButton{row}
+}
+
+// mapLayerName returns the localized name of a layer
+func (h *CommandHandler) mapLayerName(layer weather.MapLayer, lang string) string {
+	return h.services.Localization.T(context.Background(), lang, "map_layer_"+string(layer))
+}
+
+// mapLocation returns the saved location maps are drawn around. When there is
+// none, or the user is over the weather rate limit, the user has been told and
+// ok is false.
+func (h *CommandHandler) mapLocation(bot *gotgbot.Bot, ctx *ext.Context, locationNeededKey string) (name string, lat, lon float64, ok bool, err error) {
+	userID := ctx.EffectiveUser.Id
+
+	name, lat, lon, err = h.getUserLocation(ctx, userID)
+	if err != nil || name == "" {
+		return "", 0, 0, false, h.sendLocationNeeded(bot, ctx, locationNeededKey)
+	}
+
+	if limited, err := h.weatherRateLimited(bot, ctx, userID); limited {
+		return "", 0, 0, false, err
+	}
+
+	// Locations saved by name before coordinates were stored have none
+	if lat == 0 && lon == 0 {
+		location, err := h.services.Weather.GeocodeLocation(h.weatherContext(ctx), name)
+		if err != nil {
+			return "", 0, 0, false, h.sendWeatherError(bot, ctx, name, err, "location_not_found", name)
+		}
+		lat, lon = location.Latitude, location.Longitude
+	}
+	return name, lat, lon, true, nil
+}
@@ -0,0 +1,279 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/internal/services"
+)
+
+// setupMarkup offers to finish the setup of a user who has no notifications yet
+func (h *CommandHandler) setupMarkup(userID int64, userLang string) *gotgbot.InlineKeyboardMarkup {
+	subscriptions, err := h.services.Subscription.GetUserSubscriptions(context.Background(), userID)
+	if err != nil || len(subscriptions) > 0 {
+		return nil
+	}
+
+	return &gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{{
+		{Text: h.services.Localization.T(context.Background(), userLang, "setup_complete_btn"), CallbackData: "setup_complete"},
+	}}}
+}
+
+// handleSetupCallback runs the composite setup flows. Each runs in one
+// transaction, so a failure leaves nothing half-configured and the user only
+// needs to retry.
+func (h *CommandHandler) handleSetupCallback(bot *gotgbot.Bot, ctx *ext.Context, action string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	var text string
+	switch action {
+	case "complete":
+		if err := h.services.CompleteOnboarding(context.Background(), ctx.EffectiveUser); err != nil {
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to complete onboarding")
+			return h.sendSetupRetry(bot, ctx, userLang, "setup_complete_failed", "setup_complete")
+		}
+		text = h.services.Localization.T(context.Background(), userLang, "setup_complete_done", services.RecommendedDigestTime)
+	case "alerts":
+		created, err := h.services.CreateRecommendedAlerts(context.Background(), userID)
+		if errors.Is(err, services.ErrAlertLimitReached) {
+			return h.sendAlertCreateFailed(bot, ctx, err, "")
+		}
+		if err != nil {
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to create recommended alerts")
+			return h.sendSetupRetry(bot, ctx, userLang, "setup_alerts_failed", "setup_alerts")
+		}
+		if created == 0 {
+			text = h.services.Localization.T(context.Background(), userLang, "setup_alerts_none")
+		} else {
+			text = h.services.Localization.T(context.Background(), userLang, "setup_alerts_done", created)
+		}
+	default:
+		return nil
+	}
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, nil)
+	return err
+}
+
+// sendSetupRetry tells the user that nothing was changed and offers to run the
+// same flow again
+func (h *CommandHandler) sendSetupRetry(bot *gotgbot.Bot, ctx *ext.Context, userLang, key, callbackData string) error {
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, key), &gotgbot.SendMessageOpts{
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{{
+			{Text: h.services.Localization.T(context.Background(), userLang, "setup_retry_btn"), CallbackData: callbackData},
+		}}},
+	})
+	return err
+}
+
+// startOnboarding guides a first-time user through the setup: choosing a
+// language, setting a location and subscribing to the daily weather. Every
+// step can be skipped.
+func (h *CommandHandler) startOnboarding(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	if err := h.services.Onboarding.Begin(context.Background(), userID); err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to begin onboarding")
+		return h.sendWelcome(bot, ctx)
+	}
+
+	userLang := h.getUserLanguage(ctx, userID)
+	keyboard := append(h.languageKeyboard(userLang, "onboarding_lang"), []gotgbot.InlineKeyboardButton{
+		{Text: h.services.Localization.T(context.Background(), userLang, "onboarding_skip_btn"), CallbackData: "onboarding_skip"},
+	})
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "onboarding_welcome"), &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	return err
+}
+
+// handleOnboardingCallback answers the buttons of the onboarding steps.
+// Buttons of a step the user has already left only take away the keyboard.
+func (h *CommandHandler) handleOnboardingCallback(bot *gotgbot.Bot, ctx *ext.Context, action string, params []string) error {
+	userID := ctx.EffectiveUser.Id
+	step, err := h.services.Onboarding.Step(context.Background(), userID)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case action == "lang" && step == services.OnboardingLanguage:
+		if len(params) < 1 || !h.services.Localization.IsLanguageSupported(params[0]) {
+			return fmt.Errorf("unsupported language: %v", params)
+		}
+		if err := h.services.User.UpdateUserLanguage(context.Background(), userID, params[0]); err != nil {
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to update user language")
+			return err
+		}
+		langInfo, _ := h.services.Localization.GetLanguageByCode(params[0])
+		h.editOnboardingMessage(bot, ctx, h.services.Localization.T(context.Background(), params[0], "language_set_success", langInfo.Flag, langInfo.Name))
+		return h.sendOnboardingLocation(bot, ctx)
+	case action == "skip" && step == services.OnboardingLanguage:
+		h.clearOnboardingButtons(bot, ctx)
+		return h.sendOnboardingLocation(bot, ctx)
+	case action == "daily" && step == services.OnboardingSubscription:
+		h.clearOnboardingButtons(bot, ctx)
+		if _, err := h.services.Subscription.EnsureSubscription(context.Background(), userID, models.SubscriptionDaily, models.FrequencyDaily, services.RecommendedDigestTime); err != nil {
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to subscribe to the daily weather")
+			userLang := h.getUserLanguage(ctx, userID)
+			return h.sendSetupRetry(bot, ctx, userLang, "onboarding_daily_failed", "onboarding_daily")
+		}
+		return h.finishOnboarding(bot, ctx, "onboarding_daily_done", services.RecommendedDigestTime)
+	case action == "skip" && step == services.OnboardingSubscription:
+		h.clearOnboardingButtons(bot, ctx)
+		return h.finishOnboarding(bot, ctx, "onboarding_done")
+	default:
+		h.clearOnboardingButtons(bot, ctx)
+		return nil
+	}
+}
+
+// sendOnboardingLocation asks for the user's location, shared with a button
+// or typed as the name of a place
+func (h *CommandHandler) sendOnboardingLocation(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	if err := h.services.Onboarding.Advance(context.Background(), userID, services.OnboardingLocation); err != nil {
+		return err
+	}
+
+	userLang := h.getUserLanguage(ctx, userID)
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "onboarding_location_prompt"), &gotgbot.SendMessageOpts{
+		ParseMode: "Markdown",
+		ReplyMarkup: &gotgbot.ReplyKeyboardMarkup{
+			Keyboard: [][]gotgbot.KeyboardButton{
+				{{Text: h.services.Localization.T(context.Background(), userLang, "onboarding_share_location_btn"), RequestLocation: true}},
+				{{Text: h.services.Localization.T(context.Background(), userLang, "onboarding_skip_btn")}},
+			},
+			ResizeKeyboard:  true,
+			OneTimeKeyboard: true,
+		},
+	})
+	return err
+}
+
+// handleOnboardingInput takes a message sent at the location step as the
+// place to look up, or as skipping the step. It reports whether the user is
+// at that step.
+func (h *CommandHandler) handleOnboardingInput(bot *gotgbot.Bot, ctx *ext.Context, text string) (bool, error) {
+	if !h.atOnboardingLocation(ctx) {
+		return false, nil
+	}
+
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+	if text == h.services.Localization.T(context.Background(), userLang, "onboarding_skip_btn") {
+		return true, h.finishOnboarding(bot, ctx, "onboarding_done")
+	}
+
+	location, err := h.services.Weather.GeocodeLocation(h.weatherContext(ctx), text)
+	if err != nil {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "onboarding_location_not_found", text), nil)
+		return true, err
+	}
+	return true, h.saveOnboardingLocation(bot, ctx, text, location.Country, location.Latitude, location.Longitude)
+}
+
+// handleOnboardingLocation saves a location shared at the location step. It
+// reports whether the user is at that step.
+func (h *CommandHandler) handleOnboardingLocation(bot *gotgbot.Bot, ctx *ext.Context, lat, lon float64) (bool, error) {
+	if !h.atOnboardingLocation(ctx) {
+		return false, nil
+	}
+
+	name, err := h.services.Weather.GetLocationName(h.weatherContext(ctx), lat, lon)
+	if err != nil {
+		name = fmt.Sprintf("Location (%.4f, %.4f)", lat, lon)
+	}
+	return true, h.saveOnboardingLocation(bot, ctx, name, "", lat, lon)
+}
+
+// atOnboardingLocation reports whether the user is asked for their location
+// by the onboarding
+func (h *CommandHandler) atOnboardingLocation(ctx *ext.Context) bool {
+	if ctx.EffectiveChat.Type != gotgbot.ChatTypePrivate {
+		return false
+	}
+	step, err := h.services.Onboarding.Step(context.Background(), ctx.EffectiveUser.Id)
+	if err != nil {
+		h.logger.Warn().Err(err).Int64("user_id", ctx.EffectiveUser.Id).Msg("Failed to check onboarding step")
+		return false
+	}
+	return step == services.OnboardingLocation
+}
+
+// saveOnboardingLocation sets the user's location and offers the daily weather for it
+func (h *CommandHandler) saveOnboardingLocation(bot *gotgbot.Bot, ctx *ext.Context, name, country string, lat, lon float64) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	if err := h.services.User.SetUserLocation(context.Background(), userID, name, country, "", lat, lon); err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to save onboarding location")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "setlocation_save_failed"), nil)
+		return err
+	}
+
+	if _, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "onboarding_location_saved", markdown.EscapeBold(name)), &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &gotgbot.ReplyKeyboardRemove{RemoveKeyboard: true},
+	}); err != nil {
+		return err
+	}
+
+	if err := h.services.Onboarding.Advance(context.Background(), userID, services.OnboardingSubscription); err != nil {
+		return err
+	}
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "onboarding_daily_prompt", services.RecommendedDigestTime), &gotgbot.SendMessageOpts{
+		ParseMode: "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+			{{Text: h.services.Localization.T(context.Background(), userLang, "onboarding_daily_btn"), CallbackData: "onboarding_daily"}},
+			{{Text: h.services.Localization.T(context.Background(), userLang, "onboarding_skip_btn"), CallbackData: "onboarding_skip"}},
+		}},
+	})
+	return err
+}
+
+// finishOnboarding records the onboarding as done, says so with the message
+// under key and shows the main menu
+func (h *CommandHandler) finishOnboarding(bot *gotgbot.Bot, ctx *ext.Context, key string, args ...interface{}) error {
+	userID := ctx.EffectiveUser.Id
+	if err := h.services.Onboarding.Complete(context.Background(), userID); err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to complete onboarding")
+	}
+
+	userLang := h.getUserLanguage(ctx, userID)
+	if _, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, key, args...), &gotgbot.SendMessageOpts{
+		ReplyMarkup: &gotgbot.ReplyKeyboardRemove{RemoveKeyboard: true},
+	}); err != nil {
+		return err
+	}
+	return h.sendWelcome(bot, ctx)
+}
+
+// editOnboardingMessage replaces the step the user answered with the answer
+func (h *CommandHandler) editOnboardingMessage(bot *gotgbot.Bot, ctx *ext.Context, text string) {
+	if _, _, err := bot.EditMessageText(text, &gotgbot.EditMessageTextOpts{
+		ChatId:    ctx.EffectiveChat.Id,
+		MessageId: ctx.CallbackQuery.Message.GetMessageId(),
+		ParseMode: "Markdown",
+	}); err != nil {
+		h.logger.Warn().Err(err).Msg("Failed to edit onboarding message")
+	}
+}
+
+// clearOnboardingButtons takes the buttons off a step the user answered
+func (h *CommandHandler) clearOnboardingButtons(bot *gotgbot.Bot, ctx *ext.Context) {
+	if _, _, err := bot.EditMessageReplyMarkup(&gotgbot.EditMessageReplyMarkupOpts{
+		ChatId:    ctx.EffectiveChat.Id,
+		MessageId: ctx.CallbackQuery.Message.GetMessageId(),
+	}); err != nil {
+		h.logger.Warn().Err(err).Msg("Failed to remove onboarding buttons")
+	}
+}
@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/internal/services"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+// Pollen command shows the tree, grass and weed pollen counts of a place,
+// what their risk means for allergy sufferers and the risk of the days ahead
+func (h *CommandHandler) Pollen(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	location := h.parseLocationFromArgs(ctx)
+	if location == "" {
+		locationName, _, _, err := h.getUserLocation(ctx, userID)
+		if err != nil || locationName == "" {
+			return h.sendLocationNeeded(bot, ctx, "pollen_location_needed")
+		}
+		location = locationName
+	}
+
+	locationData, err := h.services.Weather.GeocodeLocation(h.weatherContext(ctx), location)
+	if err != nil {
+		return h.sendWeatherError(bot, ctx, location, err, "location_not_found", location)
+	}
+
+	pollen, err := h.services.Weather.GetPollenForecast(h.weatherContext(ctx), locationData.Latitude, locationData.Longitude)
+	if errors.Is(err, weather.ErrNoPollenData) {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "pollen_no_data", locationData.Name), nil)
+		return err
+	}
+	if err != nil {
+		return h.sendWeatherError(bot, ctx, location, err, "pollen_error")
+	}
+
+	if err := h.services.User.IncrementWeatherRequestCounter(context.Background()); err != nil {
+		h.logger.Warn().Err(err).Msg("Failed to increment weather request counter")
+	}
+
+	keyboard := [][]gotgbot.InlineKeyboardButton{{{
+		Text:         h.services.Localization.T(context.Background(), userLang, "addalert_pollen_btn"),
+		CallbackData: "alert_create_pollen",
+	}}}
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, h.formatPollenMessage(locationData.Name, pollen, userLang), &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	return err
+}
+
+// formatPollenMessage renders the pollen count and risk of each group, the
+// health recommendation of the highest risk and the risk of each day ahead
+func (h *CommandHandler) formatPollenMessage(locationName string, pollen *weather.PollenData, language string) string {
+	risk := pollen.MaxRisk()
+	lines := []string{
+		h.services.Localization.T(context.Background(), language, "pollen_title", markdown.EscapeBold(locationName)),
+		"",
+		h.formatPollenReading(weather.PollenTree, pollen.Tree, language),
+		h.formatPollenReading(weather.PollenGrass, pollen.Grass, language),
+		h.formatPollenReading(weather.PollenWeed, pollen.Weed, language),
+		"",
+		h.services.Localization.T(context.Background(), language, "pollen_overall", risk, weather.MaxPollenRisk, h.pollenLevelText(risk, language)),
+		"",
+		h.getPollenHealthRecommendation(risk, language),
+	}
+
+	if len(pollen.Days) > 0 {
+		lines = append(lines, "", h.services.Localization.T(context.Background(), language, "pollen_forecast_title", len(pollen.Days)))
+		for _, day := range pollen.Days {
+			lines = append(lines, fmt.Sprintf("%s: %s (🌳 %d · 🌾 %d · 🌿 %d)",
+				h.weekdayName(language, day.Date.Weekday()), h.pollenLevelText(day.MaxRisk(), language),
+				day.Tree.Risk, day.Grass.Risk, day.Weed.Risk))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatPollenReading renders the count and risk level of a pollen group
+func (h *CommandHandler) formatPollenReading(group string, reading weather.PollenReading, language string) string {
+	return h.services.Localization.T(context.Background(), language, "pollen_reading",
+		h.services.Localization.T(context.Background(), language, "pollen_group_"+group),
+		weather.FormatDecimal(reading.Count, 0), h.pollenLevelText(reading.Risk, language))
+}
+
+// pollenLevelText names a pollen risk level, 0 to 5
+func (h *CommandHandler) pollenLevelText(risk int, language string) string {
+	return h.services.Localization.T(context.Background(), language, fmt.Sprintf("pollen_level_%d", min(max(risk, 0), weather.MaxPollenRisk)))
+}
+
+// getPollenHealthRecommendation advises allergy sufferers for a pollen risk level
+func (h *CommandHandler) getPollenHealthRecommendation(risk int, language string) string {
+	switch {
+	case risk <= 0:
+		return h.services.Localization.T(context.Background(), language, "pollen_health_none")
+	case risk == 1:
+		return h.services.Localization.T(context.Background(), language, "pollen_health_very_low")
+	case risk == 2:
+		return h.services.Localization.T(context.Background(), language, "pollen_health_low")
+	case risk == 3:
+		return h.services.Localization.T(context.Background(), language, "pollen_health_moderate")
+	case risk == 4:
+		return h.services.Localization.T(context.Background(), language, "pollen_health_high")
+	default:
+		return h.services.Localization.T(context.Background(), language, "pollen_health_very_high")
+	}
+}
+
+// handlePollenAlert creates a pollen alert from a preset of the alert setup;
+// the alert triggers when the highest pollen risk rises above the threshold
+func (h *CommandHandler) handlePollenAlert(bot *gotgbot.Bot, ctx *ext.Context, condition, threshold string) error {
+	userID := ctx.EffectiveUser.Id
+
+	// Get user's location
+	locationName, _, _, err := h.getUserLocation(ctx, userID)
+	if err != nil || locationName == "" {
+		userLang := h.getUserLanguage(ctx, userID)
+		errorMsg := h.services.Localization.T(context.Background(), userLang, "location_required_setlocation")
+		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
+		return sendErr
+	}
+
+	units := h.getUserUnits(ctx, userID)
+
+	var thresholdValue float64
+	switch condition {
+	case "veryhigh":
+		thresholdValue = parseAlertThreshold(threshold, weather.MaxPollenRisk-1, models.AlertPollen, units)
+	default:
+		thresholdValue = parseAlertThreshold(threshold, weather.MaxPollenRisk-2, models.AlertPollen, units)
+	}
+
+	alertCondition := services.AlertCondition{
+		Operator: "gt",
+		Value:    thresholdValue,
+	}
+
+	if exists, err := h.sendIfAlertExists(bot, ctx, models.AlertPollen, alertCondition); exists {
+		return err
+	}
+
+	_, err = h.services.Alert.CreateAlert(context.Background(), userID, models.AlertPollen, alertCondition)
+	if err != nil {
+		return h.sendAlertCreateFailed(bot, ctx, err, "❌ Failed to create pollen alert. Please try again.")
+	}
+
+	message := "✅ Pollen alert created! You'll be notified when the pollen risk exceeds " + services.FormatAlertValue(models.AlertPollen, thresholdValue, units) + "."
+	return h.sendAlertCreated(bot, ctx, message)
+}
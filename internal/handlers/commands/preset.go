@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/internal/services"
+)
+
+const presetUsage = "Usage:\n" +
+	"`/preset create <name>; <place>[; daily|weekly HH:MM][; alerts][; <metric><op><value>...]`\n" +
+	"`/preset list`\n" +
+	"`/preset revoke <token>`\n\n" +
+	"Example: `/preset create Hiking club; Lviv; daily 07:00; wind>30; temp<0`\n" +
+	"Alert metrics: temp (°C), humidity (%), pressure (hPa), wind (km/h), uv, aqi. " +
+	"Weekly digests go out on Mondays."
+
+// Preset command handler: moderators create, list and revoke shareable presets
+func (h *CommandHandler) Preset(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	// Check moderator permissions
+	user, err := h.getUser(ctx, userID)
+	if err != nil || user.Role < models.RoleModerator {
+		errorMsg := h.services.Localization.T(context.Background(), userLang, "admin_broadcast_insufficient_permissions")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
+		return err
+	}
+
+	reply := func(text string) error {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+			ParseMode: "Markdown",
+		})
+		return err
+	}
+
+	args := ctx.Args()
+	if len(args) < 2 {
+		return reply(presetUsage)
+	}
+
+	switch strings.ToLower(args[1]) {
+	case "create":
+		return h.createPreset(bot, ctx, strings.Join(args[2:], " "), reply)
+	case "list":
+		presets, err := h.services.Presets.List(context.Background())
+		if err != nil {
+			h.logger.Error().Err(err).Msg("Failed to list presets")
+			return reply("❌ Failed to load presets")
+		}
+		return reply(formatPresetList(bot.Username, presets))
+	case "revoke":
+		if len(args) < 3 {
+			return reply(presetUsage)
+		}
+		err := h.services.Presets.Revoke(context.Background(), args[2])
+		switch {
+		case errors.Is(err, services.ErrPresetNotFound):
+			return reply("❌ No active preset with this token")
+		case err != nil:
+			h.logger.Error().Err(err).Str("token", args[2]).Msg("Failed to revoke preset")
+			return reply("❌ Failed to revoke preset")
+		}
+		return reply("✅ Preset revoked, its link no longer applies")
+	}
+	return reply(presetUsage)
+}
+
+func (h *CommandHandler) createPreset(bot *gotgbot.Bot, ctx *ext.Context, text string, reply func(string) error) error {
+	spec, err := services.ParsePresetSpec(text)
+	if err != nil {
+		return reply(fmt.Sprintf("❌ %v\n\n%s", err, presetUsage))
+	}
+
+	location, err := h.services.Weather.GeocodeLocation(h.weatherContext(ctx), spec.LocationQuery)
+	if err != nil {
+		return reply(fmt.Sprintf("❌ Location not found: %s", spec.LocationQuery))
+	}
+
+	preset, err := h.services.Presets.Create(context.Background(), ctx.EffectiveUser.Id, spec, location)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to create preset")
+		return reply("❌ Failed to create preset")
+	}
+
+	return reply(fmt.Sprintf("✅ Preset *%s* created for %s\n\nShare this link:\n%s",
+		preset.Name, preset.LocationName, presetLink(bot.Username, preset.Token)))
+}
+
+// presetLink returns the deep link that opens the preset preview
+func presetLink(botUsername, token string) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s%s", botUsername, services.PresetStartPrefix, token)
+}
+
+func formatPresetList(botUsername string, presets []models.Preset) string {
+	if len(presets) == 0 {
+		return "📦 No active presets"
+	}
+
+	var b strings.Builder
+	b.WriteString("📦 *Active Presets*\n")
+	for _, preset := range presets {
+		fmt.Fprintf(&b, "\n*%s* - %s, applied %d times\n`%s`\n%s\n",
+			preset.Name, preset.LocationName, preset.UsageCount, preset.Token, presetLink(botUsername, preset.Token))
+	}
+	return b.String()
+}
+
+// showPresetPreview answers a preset deep link with what applying it would
+// set up and a button to confirm
+func (h *CommandHandler) showPresetPreview(bot *gotgbot.Bot, ctx *ext.Context, token string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	preset, err := h.services.Presets.GetByToken(context.Background(), token)
+	if err != nil {
+		return h.sendPresetError(bot, ctx, userLang, err)
+	}
+
+	// The preview falls back to defaults when the user cannot be loaded
+	user, _ := h.getUser(ctx, userID)
+
+	keyboard := [][]gotgbot.InlineKeyboardButton{{
+		{Text: h.services.Localization.T(context.Background(), userLang, "preset_apply_btn"), CallbackData: "preset_apply_" + preset.Token},
+		{Text: h.services.Localization.T(context.Background(), userLang, "preset_cancel_btn"), CallbackData: "preset_cancel"},
+	}}
+
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, h.services.Presets.Preview(context.Background(), preset, user), &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	return err
+}
+
+func (h *CommandHandler) handlePresetCallback(bot *gotgbot.Bot, ctx *ext.Context, action string, params []string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	key := "preset_cancelled"
+	if action == "apply" && len(params) > 0 {
+		applied, err := h.services.ApplyPreset(context.Background(), params[0], userID)
+		switch {
+		case errors.Is(err, services.ErrPresetNotFound), errors.Is(err, services.ErrPresetRevoked):
+			return h.sendPresetError(bot, ctx, userLang, err)
+		case errors.Is(err, services.ErrAlertLimitReached):
+			return h.sendAlertCreateFailed(bot, ctx, err, "")
+		case err != nil:
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to apply preset")
+			key = "preset_apply_failed"
+		case !applied:
+			key = "preset_already_applied"
+		default:
+			key = "preset_applied"
+		}
+	}
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, key), nil)
+	return err
+}
+
+func (h *CommandHandler) sendPresetError(bot *gotgbot.Bot, ctx *ext.Context, userLang string, err error) error {
+	key := "preset_not_found"
+	switch {
+	case errors.Is(err, services.ErrPresetRevoked):
+		key = "preset_revoked"
+	case !errors.Is(err, services.ErrPresetNotFound):
+		h.logger.Error().Err(err).Msg("Failed to load preset")
+	}
+
+	_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, key), nil)
+	return sendErr
+}
@@ -0,0 +1,208 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/services"
+)
+
+// Privacy command lists the categories of data the bot keeps about the user
+// and offers to delete the account with all of it
+func (h *CommandHandler) Privacy(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+	t := func(key string, args ...interface{}) string {
+		return h.services.Localization.T(context.Background(), userLang, key, args...)
+	}
+	reply := func(text string) error {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, nil)
+		return err
+	}
+
+	// The summary is personal, so it is not shown to a group
+	if ctx.EffectiveChat.Type != gotgbot.ChatTypePrivate {
+		return reply(t("privacy_private_only"))
+	}
+
+	user, err := h.getUser(ctx, userID)
+	if err != nil || user == nil {
+		return reply(t("privacy_failed"))
+	}
+	stored, err := h.services.User.GetStoredData(context.Background(), userID)
+	if err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to count stored user data")
+		return reply(t("privacy_failed"))
+	}
+
+	profile := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if user.Username != "" {
+		profile += " (@" + user.Username + ")"
+	}
+	location := t("privacy_no_location")
+	if user.HasLocation() {
+		location = user.LocationName
+	}
+
+	text := t("privacy_summary",
+		markdown.Escape(profile), markdown.Escape(location), stored.Locations,
+		stored.Subscriptions, stored.AlertConfigs, stored.TriggeredAlerts, stored.WeatherRecords)
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ParseMode: "Markdown",
+		ReplyMarkup: gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+			{{Text: t("privacy_delete_btn"), CallbackData: "privacy_delete"}},
+		}},
+	})
+	return err
+}
+
+// handlePrivacyCallback walks the user through deleting their account: the
+// delete button asks twice before anything is deleted, and cancel stops at
+// either step
+func (h *CommandHandler) handlePrivacyCallback(bot *gotgbot.Bot, ctx *ext.Context, action string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+	t := func(key string) string {
+		return h.services.Localization.T(context.Background(), userLang, key)
+	}
+	// Editing without a keyboard removes the buttons of the last step
+	edit := func(key string, keyboard [][]gotgbot.InlineKeyboardButton) error {
+		opts := &gotgbot.EditMessageTextOpts{
+			ChatId:    ctx.EffectiveChat.Id,
+			MessageId: ctx.CallbackQuery.Message.GetMessageId(),
+			ParseMode: "Markdown",
+		}
+		if keyboard != nil {
+			opts.ReplyMarkup = gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard}
+		}
+		_, _, err := bot.EditMessageText(t(key), opts)
+		return err
+	}
+	cancel := []gotgbot.InlineKeyboardButton{{Text: t("privacy_cancel_btn"), CallbackData: "privacy_cancel"}}
+
+	switch action {
+	case "delete":
+		return edit("privacy_delete_warning", [][]gotgbot.InlineKeyboardButton{
+			{{Text: t("privacy_continue_btn"), CallbackData: "privacy_continue"}},
+			cancel,
+		})
+	case "continue":
+		return edit("privacy_delete_final", [][]gotgbot.InlineKeyboardButton{
+			{{Text: t("privacy_erase_btn"), CallbackData: "privacy_erase"}},
+			cancel,
+		})
+	case "erase":
+		err := h.services.User.DeleteUser(context.Background(), userID)
+		switch {
+		case errors.Is(err, services.ErrLastAdmin):
+			return edit("privacy_delete_last_admin", nil)
+		case err != nil:
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to delete user data")
+			return edit("privacy_delete_failed", nil)
+		}
+		h.logger.Info().Int64("user_id", userID).Msg("User deleted their account and data")
+		return edit("privacy_deleted", nil)
+	case "cancel":
+		return edit("privacy_delete_cancelled", nil)
+	}
+	return nil
+}
+
+// deletionConfirmation is what the user types to confirm /deletemydata
+const deletionConfirmation = "DELETE"
+
+// DeleteMyData command asks the user to confirm deleting all their data:
+// first with a button, then by typing DELETE
+func (h *CommandHandler) DeleteMyData(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userLang := h.getUserLanguage(ctx, ctx.EffectiveUser.Id)
+	t := func(key string) string {
+		return h.services.Localization.T(context.Background(), userLang, key)
+	}
+
+	if ctx.EffectiveChat.Type != gotgbot.ChatTypePrivate {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, t("deletemydata_private_only"), nil)
+		return err
+	}
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, t("deletemydata_confirm"), &gotgbot.SendMessageOpts{
+		ParseMode: "Markdown",
+		ReplyMarkup: gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{{
+			{Text: t("deletemydata_yes_btn"), CallbackData: "deletemydata_yes"},
+			{Text: t("deletemydata_no_btn"), CallbackData: "deletemydata_no"},
+		}}},
+	})
+	return err
+}
+
+// handleDeleteMyDataCallback answers the first confirmation of /deletemydata:
+// yes waits for DELETE to be typed, no stops there
+func (h *CommandHandler) handleDeleteMyDataCallback(bot *gotgbot.Bot, ctx *ext.Context, action string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+	edit := func(key string) error {
+		_, _, err := bot.EditMessageText(h.services.Localization.T(context.Background(), userLang, key), &gotgbot.EditMessageTextOpts{
+			ChatId:    ctx.EffectiveChat.Id,
+			MessageId: ctx.CallbackQuery.Message.GetMessageId(),
+			ParseMode: "Markdown",
+		})
+		return err
+	}
+
+	switch action {
+	case "yes":
+		if err := h.services.User.StartPendingDeletion(context.Background(), userID); err != nil {
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to start data deletion")
+			return edit("privacy_delete_failed")
+		}
+		return edit("deletemydata_type_delete")
+	case "no":
+		return edit("privacy_delete_cancelled")
+	}
+	return nil
+}
+
+// handleDeleteMyDataInput reads a text message as the typed confirmation of
+// /deletemydata, if one was asked for. Anything but DELETE cancels the
+// deletion. It reports whether the message was taken as the confirmation.
+func (h *CommandHandler) handleDeleteMyDataInput(bot *gotgbot.Bot, ctx *ext.Context, text string) (bool, error) {
+	userID := ctx.EffectiveUser.Id
+
+	// Every text message passes here, so the stored confirmation is only
+	// looked up for DELETE itself or after this process asked for it
+	if text != deletionConfirmation && !h.services.User.HasLocalPendingDeletion(userID) {
+		return false, nil
+	}
+
+	pending, err := h.services.User.TakePendingDeletion(context.Background(), userID)
+	if err != nil {
+		h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to check pending data deletion")
+	}
+	if !pending {
+		return false, nil
+	}
+
+	userLang := h.getUserLanguage(ctx, userID)
+	reply := func(key string) error {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, key), &gotgbot.SendMessageOpts{ParseMode: "Markdown"})
+		return err
+	}
+
+	if text != deletionConfirmation {
+		return true, reply("deletemydata_not_confirmed")
+	}
+	err = h.services.User.DeleteUser(context.Background(), userID)
+	switch {
+	case errors.Is(err, services.ErrLastAdmin):
+		return true, reply("privacy_delete_last_admin")
+	case err != nil:
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to delete user data")
+		return true, reply("privacy_delete_failed")
+	}
+	h.logger.Info().Int64("user_id", userID).Msg("User deleted their account and data")
+	return true, reply("privacy_deleted")
+}
@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/services"
+)
+
+// QuietDays command handler: users list, add and remove the days on which
+// their routine alerts are held back
+func (h *CommandHandler) QuietDays(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+	t := func(key string, args ...interface{}) string {
+		return h.services.Localization.T(context.Background(), userLang, key, args...)
+	}
+
+	reply := func(text string) error {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+			ParseMode: "Markdown",
+		})
+		return err
+	}
+
+	user, err := h.getUser(ctx, userID)
+	if err != nil {
+		return reply(t("quietdays_failed"))
+	}
+	today := services.UserToday(user)
+
+	args := ctx.Args()
+	if len(args) < 2 || strings.EqualFold(args[1], "list") {
+		ranges, err := h.services.QuietDays.List(context.Background(), userID, today)
+		if err != nil {
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to list quiet days")
+			return reply(t("quietdays_failed"))
+		}
+		return reply(formatQuietDays(ranges, t))
+	}
+
+	action := strings.ToLower(args[1])
+	if (action != "add" && action != "remove") || len(args) < 3 {
+		return reply(t("quietdays_usage"))
+	}
+
+	r, err := services.ParseDateRange(strings.Join(args[2:], ""))
+	if err != nil {
+		return reply(t(quietDaysErrorKey(err)) + "\n\n" + t("quietdays_usage"))
+	}
+
+	if action == "add" {
+		if r.End.Before(today) {
+			return reply(t("quietdays_past"))
+		}
+		merged, err := h.services.QuietDays.Add(context.Background(), userID, r)
+		if err != nil {
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to add quiet days")
+			return reply(t("quietdays_failed"))
+		}
+		return reply(t("quietdays_added", merged.String()))
+	}
+
+	removed, err := h.services.QuietDays.Remove(context.Background(), userID, r)
+	switch {
+	case err != nil:
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to remove quiet days")
+		return reply(t("quietdays_failed"))
+	case !removed:
+		return reply(t("quietdays_none_removed", r.String()))
+	}
+	return reply(t("quietdays_removed", r.String()))
+}
+
+// quietDaysErrorKey returns the localization key explaining a date range error
+func quietDaysErrorKey(err error) string {
+	switch {
+	case errors.Is(err, services.ErrDateRangeReversed):
+		return "quietdays_reversed"
+	case errors.Is(err, services.ErrDateRangeTooLong):
+		return "quietdays_too_long"
+	default:
+		return "quietdays_invalid"
+	}
+}
+
+func formatQuietDays(ranges []services.DateRange, t func(string, ...interface{}) string) string {
+	var b strings.Builder
+	b.WriteString(t("quietdays_title"))
+	b.WriteString("\n\n")
+	if len(ranges) == 0 {
+		b.WriteString(t("quietdays_empty"))
+	}
+	for _, r := range ranges {
+		b.WriteString("• " + r.String() + "\n")
+	}
+	b.WriteString("\n" + t("quietdays_usage"))
+	return b.String()
+}
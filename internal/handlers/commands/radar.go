@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+// radarRefreshCallback re-renders the map of a /radar message in place
+const radarRefreshCallback = "radar_refresh"
+
+// Radar sends a precipitation map around the user's saved location
+func (h *CommandHandler) Radar(bot *gotgbot.Bot, ctx *ext.Context) error {
+	return h.sendRadar(bot, ctx, false)
+}
+
+// sendRadar renders the precipitation map with the current precipitation as
+// its caption. Refreshing replaces the photo of the message the button
+// belongs to instead of sending a new one.
+func (h *CommandHandler) sendRadar(bot *gotgbot.Bot, ctx *ext.Context, edit bool) error {
+	userID := ctx.EffectiveUser.Id
+
+	locationName, lat, lon, ok, err := h.mapLocation(bot, ctx, "radar_location_needed")
+	if !ok {
+		return err
+	}
+
+	current, err := h.services.Weather.GetCurrentWeather(h.weatherContext(ctx), lat, lon)
+	if err != nil {
+		return h.sendWeatherError(bot, ctx, locationName, err, "radar_error")
+	}
+	image, err := h.services.Weather.GetPrecipitationTile(h.weatherContext(ctx), lat, lon, weather.DefaultMapZoom)
+	if err != nil {
+		return h.sendWeatherError(bot, ctx, locationName, err, "radar_error")
+	}
+
+	if !edit {
+		if err := h.services.User.IncrementWeatherRequestCounter(context.Background()); err != nil {
+			h.logger.Warn().Err(err).Msg("Failed to increment weather request counter")
+		}
+	}
+
+	userLang := h.getUserLanguage(ctx, userID)
+	caption := h.formatRadarCaption(locationName, current, userLang, h.getUserUnits(ctx, userID))
+	keyboard := gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+		{{Text: h.services.Localization.T(context.Background(), userLang, "button_refresh"), CallbackData: radarRefreshCallback}},
+	}}
+	photo := gotgbot.InputFileByReader("radar.png", bytes.NewReader(image))
+
+	if edit && ctx.CallbackQuery != nil {
+		_, _, err = bot.EditMessageMedia(gotgbot.InputMediaPhoto{
+			Media:     photo,
+			Caption:   caption,
+			ParseMode: "Markdown",
+		}, &gotgbot.EditMessageMediaOpts{
+			ChatId:      ctx.EffectiveChat.Id,
+			MessageId:   ctx.CallbackQuery.Message.GetMessageId(),
+			ReplyMarkup: keyboard,
+		})
+		return err
+	}
+
+	_, err = bot.SendPhoto(ctx.EffectiveChat.Id, photo, &gotgbot.SendPhotoOpts{
+		Caption:     caption,
+		ParseMode:   "Markdown",
+		ReplyMarkup: keyboard,
+	})
+	return err
+}
+
+// formatRadarCaption describes the precipitation at the location right now
+func (h *CommandHandler) formatRadarCaption(locationName string, current *weather.WeatherData, lang, units string) string {
+	title := h.services.Localization.T(context.Background(), lang, "radar_title", markdown.EscapeBold(locationName))
+	now := h.services.Localization.T(context.Background(), lang, "radar_dry", current.Description)
+	if current.Precipitation > 0 {
+		now = h.services.Localization.T(context.Background(), lang, "radar_precipitation",
+			weather.FormatPrecipitation(current.Precipitation, units), current.Description)
+	}
+	return fmt.Sprintf("%s\n%s %s", title, weather.ConditionEmoji(current.Icon), now)
+}
@@ -0,0 +1,197 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/hbollon/go-edlib"
+
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/models"
+)
+
+const (
+	// commandStartPrefix starts the /start payload of the links under /search
+	// results, followed by the command name
+	commandStartPrefix = "cmd_"
+
+	maxSearchResults = 5 // Most commands listed by /search
+
+	// Search ranking: every edit between the keyword and a command name costs
+	// searchNameWeight, and a description that does not contain the keyword
+	// adds searchDescriptionPenalty
+	searchNameWeight         = 2
+	searchDescriptionPenalty = 3
+)
+
+// commandMatch is a command found by /search with its score, lower is better
+type commandMatch struct {
+	command CommandMetadata
+	score   int
+}
+
+// Search lists the commands whose name or description matches a keyword
+func (h *CommandHandler) Search(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	args := ctx.Args()
+	if len(args) < 2 {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "search_usage"), &gotgbot.SendMessageOpts{ParseMode: "Markdown"})
+		return err
+	}
+	keyword := strings.Join(args[1:], " ")
+
+	// Admin commands only show up for admins
+	includeAdmin := false
+	if user, err := h.getUser(ctx, userID); err == nil && user != nil {
+		includeAdmin = user.Role == models.RoleAdmin
+	}
+
+	matches := searchCommands(keyword, func(command CommandMetadata) string {
+		return h.services.Localization.T(context.Background(), userLang, command.Description)
+	}, includeAdmin, maxSearchResults)
+	if len(matches) == 0 {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "search_no_results", markdown.Escape(keyword)), &gotgbot.SendMessageOpts{ParseMode: "Markdown"})
+		return err
+	}
+
+	text, keyboard := h.formatSearchResults(keyword, matches, userLang, bot.Username)
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	return err
+}
+
+// searchCommands ranks the commands against a keyword by the edit distance
+// to their name and whether their description contains it. A command must be
+// close to the keyword by name or mention it in its description to be listed.
+func searchCommands(keyword string, describe func(CommandMetadata) string, includeAdmin bool, limit int) []commandMatch {
+	keyword = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(keyword), "/"))
+	if keyword == "" {
+		return nil
+	}
+
+	var matches []commandMatch
+	for _, command := range commandRegistry {
+		if command.Category == categoryAdmin && !includeAdmin {
+			continue
+		}
+
+		var distance int
+		switch {
+		case strings.HasPrefix(command.Name, keyword):
+			distance = 0
+		case strings.Contains(command.Name, keyword):
+			distance = 1
+		default:
+			distance = edlib.LevenshteinDistance(keyword, command.Name)
+		}
+		nameMatches := distance <= len(command.Name)/2
+		descriptionMatches := strings.Contains(strings.ToLower(describe(command)), keyword)
+		if !nameMatches && !descriptionMatches {
+			continue
+		}
+
+		score := searchNameWeight * distance
+		if !descriptionMatches {
+			score += searchDescriptionPenalty
+		}
+		matches = append(matches, commandMatch{command: command, score: score})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score == matches[j].score {
+			return matches[i].command.Name < matches[j].command.Name
+		}
+		return matches[i].score < matches[j].score
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// formatSearchResults lists the matches with their descriptions, and a
+// button per match linking to the bot with the command as /start payload
+func (h *CommandHandler) formatSearchResults(keyword string, matches []commandMatch, lang, botUsername string) (string, [][]gotgbot.InlineKeyboardButton) {
+	var b strings.Builder
+	b.WriteString(h.services.Localization.T(context.Background(), lang, "search_title", markdown.EscapeBold(keyword)))
+	b.WriteString("\n\n")
+
+	keyboard := make([][]gotgbot.InlineKeyboardButton, 0, len(matches))
+	for _, match := range matches {
+		fmt.Fprintf(&b, "/%s - %s\n", markdown.Escape(match.command.Name),
+			h.services.Localization.T(context.Background(), lang, match.command.Description))
+		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{{
+			Text: "/" + match.command.Name,
+			Url:  fmt.Sprintf("https://t.me/%s?start=%s%s", botUsername, commandStartPrefix, match.command.Name),
+		}})
+	}
+	return strings.TrimSuffix(b.String(), "\n"), keyboard
+}
+
+// runLinkedCommand runs the command named by a /search result link as if the
+// user had sent it without arguments, and falls back to /help for any other
+// name
+func (h *CommandHandler) runLinkedCommand(bot *gotgbot.Bot, ctx *ext.Context, name string) error {
+	commands := map[string]func(*gotgbot.Bot, *ext.Context) error{
+		"help":             h.Help,
+		"version":          h.Version,
+		"weather":          h.CurrentWeather,
+		"forecast":         h.Forecast,
+		"hourly":           h.Hourly,
+		"air":              h.AirQuality,
+		"aqiforecast":      h.AQIForecast,
+		"uvindex":          h.UVIndex,
+		"pollen":           h.Pollen,
+		"tide":             h.Tide,
+		"astro":            h.Astro,
+		"compare":          h.Compare,
+		"history":          h.History,
+		"radar":            h.Radar,
+		"map":              h.Map,
+		"today":            h.Today,
+		"share":            h.Share,
+		"feedback":         h.Feedback,
+		"setlocation":      h.SetLocation,
+		"locations":        h.ListLocations,
+		"setgrouplocation": h.SetGroupLocation,
+		"subscribe":        h.Subscribe,
+		"unsubscribe":      h.Unsubscribe,
+		"subscriptions":    h.ListSubscriptions,
+		"unpin":            h.Unpin,
+		"addalert":         h.AddAlert,
+		"alerts":           h.ListAlerts,
+		"removealert":      h.RemoveAlert,
+		"quietdays":        h.QuietDays,
+		"settings":         h.Settings,
+		"language":         h.Language,
+		"transfer":         h.Transfer,
+		"privacy":          h.Privacy,
+		"deletemydata":     h.DeleteMyData,
+		"setapikey":        h.SetAPIKey,
+		"deactivate":       h.Deactivate,
+		"reactivate":       h.Reactivate,
+		"stats":            h.AdminStats,
+		"broadcast":        h.AdminBroadcast,
+		"users":            h.AdminListUsers,
+		"userinfo":         h.UserInfo,
+		"feedbacklist":     h.ListFeedback,
+		"auditlog":         h.AuditLog,
+	}
+	run, ok := commands[name]
+	if !ok {
+		return h.Help(bot, ctx)
+	}
+
+	// The command reads its arguments from the message, which still holds
+	// the /start payload
+	ctx.EffectiveMessage.Text = "/" + name
+	return run(bot, ctx)
+}
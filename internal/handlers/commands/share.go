@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/render"
+	"github.com/valpere/shopogoda/internal/services"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+// Share command handler: sends a weather card of the given place, or of the
+// user's saved location, to forward to people outside the bot
+func (h *CommandHandler) Share(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+
+	location := strings.TrimSpace(strings.Join(ctx.Args()[1:], " "))
+	if location == "" {
+		name, _, _, err := h.getUserLocation(ctx, userID)
+		if err != nil || name == "" {
+			return h.sendLocationNeeded(bot, ctx, "share_location_needed")
+		}
+		location = name
+	}
+
+	if limited, err := h.weatherRateLimited(bot, ctx, userID); limited {
+		return err
+	}
+	return h.sendShareCard(bot, ctx, location)
+}
+
+// sendShareCard answers /share and the share button under a weather message
+// with a new, self-contained card the user can forward: a picture of the
+// weather with the card text as its caption. The weather message itself stays
+// as it is; its buttons would be dead in someone else's chat.
+func (h *CommandHandler) sendShareCard(bot *gotgbot.Bot, ctx *ext.Context, location string) error {
+	userID := ctx.EffectiveUser.Id
+	chatID := ctx.EffectiveChat.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	units := h.getUserUnits(ctx, userID)
+
+	card, err := h.services.Weather.ComposeShareCard(h.weatherContext(ctx), location, userLang)
+	if err != nil {
+		h.logger.Error().Err(err).Str("location", location).Msg("Failed to compose share card")
+		return h.sendWeatherError(bot, ctx, location, err, "weather_error", location)
+	}
+
+	text := services.RenderShareCard(context.Background(), h.services.Localization, userLang, units, card)
+	keyboard := &gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: services.ShareCardKeyboard(context.Background(), h.services.Localization, userLang, bot.Username),
+	}
+
+	image, err := h.services.Weather.CachedShareCardImage(context.Background(), card, userLang, units, time.Now(), func() ([]byte, error) {
+		return render.GenerateWeatherCard(shareCardView(card, units))
+	})
+	if err == nil {
+		_, err = bot.SendPhoto(chatID, gotgbot.InputFileByReader("weather.png", bytes.NewReader(image)), &gotgbot.SendPhotoOpts{
+			Caption:     text,
+			ParseMode:   "Markdown",
+			ReplyMarkup: keyboard,
+		})
+		return err
+	}
+
+	// The text alone still makes a card
+	h.logger.Warn().Err(err).Str("location", location).Msg("Failed to draw share card image")
+	_, err = bot.SendMessage(chatID, text, &gotgbot.SendMessageOpts{
+		ParseMode:          "Markdown",
+		LinkPreviewOptions: &gotgbot.LinkPreviewOptions{IsDisabled: true},
+		ReplyMarkup:        keyboard,
+	})
+	return err
+}
+
+// shareCardView formats the current weather of a card for its image. The
+// place is named as the provider does, in Latin script, which the card font
+// draws; the caption names it in the reader's language.
+func shareCardView(card *services.ShareCard, units string) render.CardView {
+	current := card.Current
+	return render.CardView{
+		Location:    current.LocationName,
+		Temperature: weather.FormatTemp(current.Temperature, units),
+		Humidity:    weather.FormatPercent(float64(current.Humidity)),
+		Wind:        weather.FormatSpeed(current.WindSpeed, units),
+		Icon:        current.Icon,
+	}
+}
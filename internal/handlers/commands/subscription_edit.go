@@ -0,0 +1,234 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/internal/services"
+)
+
+// handleSubscriptionEditCallback handles the buttons of a listed subscription:
+// sub_<action>_<subscription ID>, and sub_<time|freq|day>_<ID>_<value> from
+// its editor
+func (h *CommandHandler) handleSubscriptionEditCallback(bot *gotgbot.Bot, ctx *ext.Context, action string, params []string) error {
+	if len(params) == 0 {
+		return nil
+	}
+	switch action {
+	case "edit":
+		return h.editSubscription(bot, ctx, params[0], false)
+	case "remove":
+		return h.removeSubscription(bot, ctx, params[0])
+	case "time", "freq", "day":
+		if len(params) < 2 {
+			return nil
+		}
+		return h.updateSubscriptionSchedule(bot, ctx, params[0], action, params[1])
+	}
+	return nil
+}
+
+// subscriptionEditable reports whether a subscription has a schedule the user
+// can edit. Alerts follow the weather and sunrise subscriptions the sun.
+func subscriptionEditable(sub *models.Subscription) bool {
+	return sub.SubscriptionType == models.SubscriptionDaily || sub.SubscriptionType == models.SubscriptionWeekly
+}
+
+// loadEditableSubscription returns one of the user's subscriptions to edit, or
+// nil after telling them why it cannot be
+func (h *CommandHandler) loadEditableSubscription(bot *gotgbot.Bot, ctx *ext.Context, subscriptionID string) (*models.Subscription, error) {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+	reply := func(key string) error {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, key), nil)
+		return err
+	}
+
+	subID, err := uuid.Parse(subscriptionID)
+	if err != nil {
+		return nil, reply("subscription_invalid_id")
+	}
+
+	sub, err := h.services.Subscription.GetUserSubscription(context.Background(), userID, subID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, reply("subscription_not_found")
+		}
+		h.logger.Error().Err(err).Str("subscription_id", subscriptionID).Msg("Failed to get subscription")
+		return nil, reply("subscription_edit_failed")
+	}
+	if !subscriptionEditable(sub) {
+		return nil, reply("subscription_edit_unsupported")
+	}
+	return sub, nil
+}
+
+// editSubscription shows when a subscription is sent, with buttons to change
+// its time, whether daily weather comes daily or weekly, and the day weekly
+// ones go out. After a change the editor's own message is updated.
+func (h *CommandHandler) editSubscription(bot *gotgbot.Bot, ctx *ext.Context, subscriptionID string, edit bool) error {
+	sub, err := h.loadEditableSubscription(bot, ctx, subscriptionID)
+	if sub == nil {
+		return err
+	}
+
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+	firstDay := time.Monday
+	if user, err := h.getUser(ctx, userID); err == nil && user != nil {
+		firstDay = services.FirstDayOfWeek(user)
+	}
+
+	text := h.formatSubscriptionEditor(sub, h.subscriptionTimezone(ctx, userID), userLang)
+	keyboard := gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: h.subscriptionEditKeyboard(sub, firstDay, userLang),
+	}
+
+	if edit && ctx.CallbackQuery != nil {
+		_, _, err = bot.EditMessageText(text, &gotgbot.EditMessageTextOpts{
+			ChatId:      ctx.EffectiveChat.Id,
+			MessageId:   ctx.CallbackQuery.Message.GetMessageId(),
+			ParseMode:   "Markdown",
+			ReplyMarkup: keyboard,
+		})
+		return err
+	}
+
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &keyboard,
+	})
+	return err
+}
+
+// formatSubscriptionEditor describes when a subscription is sent
+func (h *CommandHandler) formatSubscriptionEditor(sub *models.Subscription, timezone, language string) string {
+	t := func(key string, args ...interface{}) string {
+		return h.services.Localization.T(context.Background(), language, key, args...)
+	}
+
+	lines := []string{
+		t("subscription_edit_title", getNotificationEmoji(sub.SubscriptionType), h.getSubscriptionTypeText(sub.SubscriptionType, language)),
+		"",
+		t("subscription_edit_time", subscriptionTimeLabel(sub.TimeOfDay, timezone)),
+	}
+	if sub.SubscriptionType == models.SubscriptionDaily {
+		frequency := models.FrequencyDaily
+		if sub.IsWeekly() {
+			frequency = models.FrequencyWeekly
+		}
+		lines = append(lines, t("subscription_edit_frequency", h.getFrequencyText(frequency, language)))
+	}
+	if sub.IsWeekly() {
+		lines = append(lines, t("subscription_edit_day", h.weekdayName(language, sub.WeeklySendDay())))
+	}
+	lines = append(lines, "", t("subscription_edit_prompt"))
+	return strings.Join(lines, "\n")
+}
+
+// subscriptionEditKeyboard offers the subscription times with the current one
+// ticked, a daily or weekly toggle for daily weather, and for weekly ones the
+// days of the week from firstDay
+func (h *CommandHandler) subscriptionEditKeyboard(sub *models.Subscription, firstDay time.Weekday, language string) [][]gotgbot.InlineKeyboardButton {
+	callback := func(action, value string) string {
+		return fmt.Sprintf("sub_%s_%s_%s", action, sub.ID, value)
+	}
+	ticked := func(selected bool, text string) string {
+		if selected {
+			return "✅ " + text
+		}
+		return text
+	}
+
+	keyboard := subscriptionTimeKeyboard(sub.TimeOfDay, func(timeOfDay string) string {
+		return callback("time", timeOfDay)
+	})
+
+	if sub.SubscriptionType == models.SubscriptionDaily {
+		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
+			{Text: ticked(!sub.IsWeekly(), h.getFrequencyText(models.FrequencyDaily, language)), CallbackData: callback("freq", "daily")},
+			{Text: ticked(sub.IsWeekly(), h.getFrequencyText(models.FrequencyWeekly, language)), CallbackData: callback("freq", "weekly")},
+		})
+	}
+
+	if sub.IsWeekly() {
+		var days []gotgbot.InlineKeyboardButton
+		for i := 0; i < 7; i++ {
+			day := (firstDay + time.Weekday(i)) % 7
+			name := h.services.Localization.T(context.Background(), language, "weekday_short_"+strings.ToLower(day.String()))
+			days = append(days, gotgbot.InlineKeyboardButton{
+				Text:         ticked(day == sub.WeeklySendDay(), name),
+				CallbackData: callback("day", strconv.Itoa(int(day))),
+			})
+		}
+		keyboard = append(keyboard, days[:4], days[4:])
+	}
+
+	return append(keyboard, []gotgbot.InlineKeyboardButton{{
+		Text:         h.services.Localization.T(context.Background(), language, "button_back_to_notifications"),
+		CallbackData: "notifications_manage",
+	}})
+}
+
+// updateSubscriptionSchedule applies a change picked in the subscription
+// editor: a new time, daily or weekly, or the day weekly ones go out
+func (h *CommandHandler) updateSubscriptionSchedule(bot *gotgbot.Bot, ctx *ext.Context, subscriptionID, field, value string) error {
+	sub, err := h.loadEditableSubscription(bot, ctx, subscriptionID)
+	if sub == nil {
+		return err
+	}
+	userID := ctx.EffectiveUser.Id
+
+	updates := make(map[string]interface{})
+	switch field {
+	case "time":
+		updates["time_of_day"] = value
+	case "freq":
+		// The weekly digest only comes weekly
+		if sub.SubscriptionType != models.SubscriptionDaily {
+			return nil
+		}
+		switch value {
+		case "daily":
+			updates["frequency"] = models.FrequencyDaily
+		case "weekly":
+			updates["frequency"] = models.FrequencyWeekly
+			if sub.SendWeekday == nil {
+				// Like a new weekly digest, start on the first day of the week
+				if user, err := h.getUser(ctx, userID); err == nil && user != nil {
+					updates["send_weekday"] = int(services.FirstDayOfWeek(user))
+				}
+			}
+		default:
+			return nil
+		}
+	case "day":
+		day, err := strconv.Atoi(value)
+		if err != nil || !sub.IsWeekly() {
+			return nil
+		}
+		updates["send_weekday"] = day
+	}
+
+	if err := h.services.Subscription.UpdateSubscription(context.Background(), userID, sub.ID, updates); err != nil {
+		h.logger.Error().Err(err).Str("subscription_id", subscriptionID).Msg("Failed to update subscription")
+		if errors.Is(err, services.ErrInvalidTimeOfDay) {
+			return h.sendSubscriptionFailed(bot, ctx, err)
+		}
+		userLang := h.getUserLanguage(ctx, userID)
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "subscription_edit_failed"), nil)
+		return err
+	}
+
+	return h.editSubscription(bot, ctx, subscriptionID, true)
+}
@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+// Sun command shows today's sunrise, sunset, day length and solar noon at a
+// place in the user's timezone, and where the sun stands now. The provider
+// reports sunrise and sunset; the sun's position is computed.
+func (h *CommandHandler) Sun(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	location := h.parseLocationFromArgs(ctx)
+	if location == "" {
+		locationName, _, _, err := h.getUserLocation(ctx, userID)
+		if err != nil || locationName == "" {
+			return h.sendLocationNeeded(bot, ctx, "sun_location_needed")
+		}
+		location = locationName
+	}
+
+	locationData, err := h.services.Weather.GeocodeLocation(h.weatherContext(ctx), location)
+	if err != nil {
+		return h.sendWeatherError(bot, ctx, location, err, "location_not_found", location)
+	}
+
+	current, err := h.services.Weather.GetCurrentWeather(h.weatherContext(ctx), locationData.Latitude, locationData.Longitude)
+	if err != nil {
+		return h.sendWeatherError(bot, ctx, location, err, "sun_error")
+	}
+
+	if err := h.services.User.IncrementWeatherRequestCounter(context.Background()); err != nil {
+		h.logger.Warn().Err(err).Msg("Failed to increment weather request counter")
+	}
+
+	now := h.services.User.ConvertToUserTime(context.Background(), userID, time.Now().UTC())
+	text := h.formatSunMessage(locationData.Name, current, locationData.Latitude, locationData.Longitude, now, userLang)
+
+	keyboard := [][]gotgbot.InlineKeyboardButton{{{
+		Text:         h.services.Localization.T(context.Background(), userLang, "sun_alert_btn"),
+		CallbackData: "sun_alert",
+	}}}
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	return err
+}
+
+// formatSunMessage renders the sun's day at the coordinates with times in the
+// location of now, the user's timezone. Without a sunrise and sunset it tells
+// the polar day from the polar night by the height of the noon sun.
+func (h *CommandHandler) formatSunMessage(locationName string, current *weather.WeatherData, lat, lon float64, now time.Time, language string) string {
+	t := func(key string, args ...interface{}) string {
+		return h.services.Localization.T(context.Background(), language, key, args...)
+	}
+	zone := now.Location()
+
+	// Solar noon of the calendar day at the place, not the user's
+	localDate := now.UTC().Add(time.Duration(current.UTCOffset) * time.Second)
+	noon := weather.SolarNoon(localDate, lon)
+
+	lines := []string{t("sun_title", markdown.EscapeBold(locationName)), ""}
+	if !current.Sunrise.IsZero() && !current.Sunset.IsZero() {
+		daylight := current.Sunset.Sub(current.Sunrise).Round(time.Minute)
+		lines = append(lines,
+			t("sun_sunrise", current.Sunrise.In(zone).Format("15:04")),
+			t("sun_sunset", current.Sunset.In(zone).Format("15:04")),
+			t("sun_day_length", int(daylight.Hours()), int(daylight.Minutes())%60))
+	} else if weather.SunElevation(noon, lat, lon) > 0 {
+		lines = append(lines, t("sun_polar_day"))
+	} else {
+		lines = append(lines, t("sun_polar_night"))
+	}
+	lines = append(lines, t("sun_solar_noon", noon.In(zone).Format("15:04")), "")
+
+	altitude, azimuth := weather.SunPosition(now, lat, lon)
+	if altitude >= 0 {
+		lines = append(lines, t("sun_position_up", altitude, weather.CompassPoint(azimuth), azimuth))
+	} else {
+		lines = append(lines, t("sun_position_down", -altitude, weather.CompassPoint(azimuth), azimuth))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// handleSunCallback handles the buttons of /sun
+func (h *CommandHandler) handleSunCallback(bot *gotgbot.Bot, ctx *ext.Context, subAction string) error {
+	if subAction == "alert" {
+		return h.subscribeSunrise(bot, ctx)
+	}
+	h.logger.Warn().Str("sub_action", subAction).Msg("Unknown sun callback subaction")
+	return nil
+}
+
+// subscribeSunrise subscribes the user to the weather at sunrise in their
+// saved location, every day
+func (h *CommandHandler) subscribeSunrise(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	locationName, _, _, err := h.getUserLocation(ctx, userID)
+	if err != nil || locationName == "" {
+		return h.sendLocationNeeded(bot, ctx, "sun_alert_location_needed")
+	}
+
+	if _, err := h.services.Subscription.EnsureSubscription(context.Background(), userID, models.SubscriptionSunrise, models.FrequencyDaily, models.SunriseTimeOfDay); err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to create sunrise subscription")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "sun_alert_failed"), nil)
+		return err
+	}
+
+	message := h.services.Localization.T(context.Background(), userLang, "sun_alert_set", markdown.EscapeBold(locationName))
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, message, &gotgbot.SendMessageOpts{ParseMode: "Markdown"})
+	return err
+}
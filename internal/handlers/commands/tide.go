@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/internal/services"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+// Preset tide alert thresholds, in metres above mean sea level
+const (
+	tideAlertHigh     = 2
+	tideAlertVeryHigh = 3
+)
+
+// tideEmoji marks high and low tides in /tide
+var tideEmoji = map[string]string{
+	weather.TideHigh: "⬆️",
+	weather.TideLow:  "⬇️",
+}
+
+// Tide command shows the high and low tides of a coastal place today and
+// tomorrow. Places too far from the sea get a note that they have none.
+func (h *CommandHandler) Tide(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+	reply := func(key string, args ...interface{}) error {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, key, args...), nil)
+		return err
+	}
+
+	if h.services.Tides == nil {
+		return reply("tide_unavailable")
+	}
+
+	location := h.parseLocationFromArgs(ctx)
+	if location == "" {
+		locationName, _, _, err := h.getUserLocation(ctx, userID)
+		if err != nil || locationName == "" {
+			return h.sendLocationNeeded(bot, ctx, "tide_location_needed")
+		}
+		location = locationName
+	}
+
+	locationData, err := h.services.Weather.GeocodeLocation(h.weatherContext(ctx), location)
+	if err != nil {
+		return h.sendWeatherError(bot, ctx, location, err, "location_not_found", location)
+	}
+
+	tides, err := h.services.Tides.GetTidePredictions(context.Background(), locationData.Latitude, locationData.Longitude, services.TideForecastDays)
+	switch {
+	case errors.Is(err, services.ErrTidesDisabled):
+		return reply("tide_unavailable")
+	case errors.Is(err, weather.ErrNoTideData):
+		return reply("tide_inland", locationData.Name)
+	case err != nil:
+		return h.sendWeatherError(bot, ctx, location, err, "tide_error")
+	}
+
+	if err := h.services.User.IncrementWeatherRequestCounter(context.Background()); err != nil {
+		h.logger.Warn().Err(err).Msg("Failed to increment weather request counter")
+	}
+
+	text := h.formatTideMessage(locationData.Name, tides, time.Now().In(h.getUserTimezone(ctx, userID)), h.getUserUnits(ctx, userID), userLang)
+	keyboard := [][]gotgbot.InlineKeyboardButton{{{
+		Text:         h.services.Localization.T(context.Background(), userLang, "addalert_tide_btn"),
+		CallbackData: "alert_create_tide",
+	}}}
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	return err
+}
+
+// formatTideMessage renders the next tide and then the tides of each day, in
+// the timezone of now and heights in the user's units
+func (h *CommandHandler) formatTideMessage(locationName string, tides *weather.TideData, now time.Time, units, language string) string {
+	t := func(key string, args ...interface{}) string {
+		return h.services.Localization.T(context.Background(), language, key, args...)
+	}
+	zone := now.Location()
+
+	lines := []string{t("tide_title", markdown.EscapeBold(locationName))}
+	if tides.Station != "" {
+		lines = append(lines, t("tide_station", markdown.Escape(tides.Station)))
+	}
+	if upcoming := tides.Upcoming(now); len(upcoming) > 0 {
+		next := upcoming[0]
+		lines = append(lines, t("tide_next", t("tide_"+next.Type), next.Time.In(zone).Format("15:04"), weather.FormatHeight(next.Height, units)))
+	}
+
+	var day string
+	for _, point := range tides.Points {
+		local := point.Time.In(zone)
+		if date := local.Format("02.01"); date != day {
+			day = date
+			lines = append(lines, "", t("tide_day", h.weekdayName(language, local.Weekday()), date))
+		}
+		lines = append(lines, t("tide_point", tideEmoji[point.Type], local.Format("15:04"), t("tide_"+point.Type), weather.FormatHeight(point.Height, units)))
+	}
+
+	lines = append(lines, "", t("tide_disclaimer"))
+	return strings.Join(lines, "\n")
+}
+
+// handleTideAlert creates a tide alert from one of the preset buttons
+func (h *CommandHandler) handleTideAlert(bot *gotgbot.Bot, ctx *ext.Context, condition, threshold string) error {
+	userID := ctx.EffectiveUser.Id
+
+	// Get user's location
+	locationName, _, _, err := h.getUserLocation(ctx, userID)
+	if err != nil || locationName == "" {
+		userLang := h.getUserLanguage(ctx, userID)
+		errorMsg := h.services.Localization.T(context.Background(), userLang, "location_required_setlocation")
+		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
+		return sendErr
+	}
+
+	units := h.getUserUnits(ctx, userID)
+
+	var thresholdValue float64
+	switch condition {
+	case "veryhigh":
+		thresholdValue = parseAlertThreshold(threshold, tideAlertVeryHigh, models.AlertTide, units)
+	default:
+		thresholdValue = parseAlertThreshold(threshold, tideAlertHigh, models.AlertTide, units)
+	}
+
+	alertCondition := services.AlertCondition{
+		Operator: "gt",
+		Value:    thresholdValue,
+	}
+
+	if exists, err := h.sendIfAlertExists(bot, ctx, models.AlertTide, alertCondition); exists {
+		return err
+	}
+
+	_, err = h.services.Alert.CreateAlert(context.Background(), userID, models.AlertTide, alertCondition)
+	if err != nil {
+		return h.sendAlertCreateFailed(bot, ctx, err, "❌ Failed to create tide alert. Please try again.")
+	}
+
+	message := "✅ Tide alert created! You'll be notified when a high tide of the next 24 hours exceeds " + services.FormatAlertValue(models.AlertTide, thresholdValue, units) + "."
+	return h.sendAlertCreated(bot, ctx, message)
+}
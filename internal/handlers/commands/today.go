@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"context"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/services"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+// Today command handler: posts the "today in <city>" card for the chat's
+// location. Group admins set that location with /today set <city>, the same
+// as /setgrouplocation.
+func (h *CommandHandler) Today(bot *gotgbot.Bot, ctx *ext.Context) error {
+	if args := ctx.Args(); len(args) > 1 && strings.EqualFold(args[1], "set") {
+		return h.setGroupLocation(bot, ctx, strings.TrimSpace(strings.Join(args[2:], " ")))
+	}
+	return h.sendToday(bot, ctx)
+}
+
+// sendToday posts the card. Cards go to the whole chat, so they carry no
+// buttons, and a chat that had one within the cooldown gets nothing.
+func (h *CommandHandler) sendToday(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	chat := ctx.EffectiveChat
+	userLang := h.getUserLanguage(ctx, userID)
+
+	reply := func(key string) error {
+		_, err := bot.SendMessage(chat.Id, h.services.Localization.T(context.Background(), userLang, key), nil)
+		return err
+	}
+
+	// Units follow the user in a private chat; a group shares metric ones
+	user, _ := h.getUser(ctx, userID)
+	units := weather.UnitsMetric
+	if chat.Type == gotgbot.ChatTypePrivate && user != nil && user.Units != "" {
+		units = user.Units
+	}
+
+	location, err := h.services.Today.ResolveLocation(context.Background(), chat.Type, chat.Id, user)
+	switch {
+	case err != nil:
+		h.logger.Error().Err(err).Int64("chat_id", chat.Id).Msg("Failed to resolve today card location")
+		return reply("today_failed")
+	case location == nil && chat.Type == gotgbot.ChatTypePrivate:
+		return reply("today_location_needed")
+	case location == nil:
+		return reply("today_chat_location_needed")
+	}
+
+	allowed, err := h.services.Today.ClaimCooldown(context.Background(), chat.Id)
+	if err != nil {
+		h.logger.Warn().Err(err).Int64("chat_id", chat.Id).Msg("Failed to check today card cooldown")
+	} else if !allowed {
+		h.logger.Debug().Int64("chat_id", chat.Id).Msg("Today card skipped during chat cooldown")
+		return nil
+	}
+
+	card, err := h.services.Today.Compose(h.weatherContext(ctx), *location)
+	if err != nil {
+		h.logger.Error().Err(err).Int64("chat_id", chat.Id).Msg("Failed to compose today card")
+		return h.sendWeatherError(bot, ctx, "", err, "today_failed")
+	}
+
+	text := h.services.Today.Render(context.Background(), userLang, units, card, services.TodayShareLink(bot.Username))
+	_, err = bot.SendMessage(chat.Id, text, &gotgbot.SendMessageOpts{
+		ParseMode:          "Markdown",
+		LinkPreviewOptions: &gotgbot.LinkPreviewOptions{IsDisabled: true},
+	})
+	return err
+}
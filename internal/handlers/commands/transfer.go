@@ -0,0 +1,332 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/google/uuid"
+
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/internal/services"
+)
+
+// Transfer command handler: moves the sender's configuration to a new
+// Telegram account through a one-time code. Admins can start and approve a
+// transfer for an account its owner can no longer use.
+func (h *CommandHandler) Transfer(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+	t := func(key string, args ...interface{}) string {
+		return h.services.Localization.T(context.Background(), userLang, key, args...)
+	}
+	reply := func(text string) error {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{ParseMode: "Markdown"})
+		return err
+	}
+
+	args := ctx.Args()
+	if len(args) < 2 {
+		return reply(t("transfer_usage"))
+	}
+
+	switch strings.ToLower(args[1]) {
+	case "start":
+		if len(args) > 2 {
+			return h.adminStartTransfer(bot, ctx, args[2])
+		}
+		transfer, err := h.services.Transfers.Start(context.Background(), userID, userID)
+		if err != nil {
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to start transfer")
+			return reply(t("transfer_failed"))
+		}
+		return reply(t("transfer_started", transfer.Code, transfer.Code))
+
+	case "claim":
+		if len(args) < 3 {
+			return reply(t("transfer_usage"))
+		}
+		transfer, err := h.services.Transfers.Claim(context.Background(), strings.Join(args[2:], ""), userID)
+		if err != nil {
+			return reply(t(h.transferErrorKey(err)))
+		}
+		return h.sendTransferClaimed(bot, ctx, transfer)
+
+	case "cancel":
+		cancelled, err := h.services.Transfers.Cancel(context.Background(), userID)
+		switch {
+		case err != nil:
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to cancel transfer")
+			return reply(t("transfer_failed"))
+		case !cancelled:
+			return reply(t("transfer_none_open"))
+		}
+		return reply(t("transfer_cancelled"))
+
+	case "approve":
+		return h.adminApproveTransfer(bot, ctx, args[2:])
+
+	default:
+		return reply(t("transfer_usage"))
+	}
+}
+
+// adminStartTransfer issues a code for another user's account; the admin
+// vouches for the old end, so only the new account has to confirm
+func (h *CommandHandler) adminStartTransfer(bot *gotgbot.Bot, ctx *ext.Context, target string) error {
+	if !h.requireAdmin(bot, ctx) {
+		return nil
+	}
+
+	reply := func(text string) error {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{ParseMode: "Markdown"})
+		return err
+	}
+
+	fromUserID, err := strconv.ParseInt(target, 10, 64)
+	if err != nil {
+		return reply("❌ Invalid user ID\n\n*Usage:* `/transfer start <user_id>`")
+	}
+	if _, err := h.services.User.GetUser(context.Background(), fromUserID); err != nil {
+		return reply(fmt.Sprintf("❌ User %d not found", fromUserID))
+	}
+
+	transfer, err := h.services.Transfers.Start(context.Background(), fromUserID, ctx.EffectiveUser.Id)
+	if err != nil {
+		h.logger.Error().Err(err).Int64("from_user_id", fromUserID).Msg("Failed to start transfer")
+		return reply("❌ Failed to start transfer")
+	}
+	return reply(fmt.Sprintf("🔑 Transfer code for user %d: `%s`, valid for 24 hours.\n\n"+
+		"The old account counts as approved. Give the code to its owner to run `/transfer claim %s` from the new account.",
+		fromUserID, transfer.Code, transfer.Code))
+}
+
+// adminApproveTransfer confirms a transfer for an old account its owner can
+// no longer use
+func (h *CommandHandler) adminApproveTransfer(bot *gotgbot.Bot, ctx *ext.Context, args []string) error {
+	if !h.requireAdmin(bot, ctx) {
+		return nil
+	}
+
+	reply := func(text string) error {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{ParseMode: "Markdown"})
+		return err
+	}
+
+	if len(args) == 0 {
+		return reply("*Usage:* `/transfer approve <code>`")
+	}
+	transfer, err := h.services.Transfers.GetByCode(context.Background(), strings.Join(args, ""))
+	if err == nil {
+		transfer, err = h.services.Transfers.ConfirmFrom(context.Background(), transfer.ID, ctx.EffectiveUser.Id)
+	}
+	switch {
+	case errors.Is(err, services.ErrTransferNotFound):
+		return reply("❌ No open transfer with this code")
+	case errors.Is(err, services.ErrTransferExpired):
+		return reply("❌ This transfer code has expired")
+	case err != nil:
+		h.logger.Error().Err(err).Msg("Failed to approve transfer")
+		return reply("❌ Failed to approve transfer")
+	}
+
+	if !transfer.ReadyToComplete() {
+		return reply(fmt.Sprintf("✅ Approved for user %d. The transfer completes once the new account confirms.", transfer.FromUserID))
+	}
+	if _, err := h.completeTransfer(bot, transfer); err != nil {
+		h.logger.Error().Err(err).Str("transfer_id", transfer.ID.String()).Msg("Failed to complete transfer")
+		return reply("❌ Approved, but moving the configuration failed. Nothing was moved; try `/transfer approve` again.")
+	}
+	return reply(fmt.Sprintf("✅ Approved. The configuration of user %d moved to user %d.", transfer.FromUserID, transfer.ToUserID))
+}
+
+// sendTransferClaimed asks the new account to confirm, choosing what to do
+// with its own configuration if it has one, and asks the old account to
+// approve unless that is done already
+func (h *CommandHandler) sendTransferClaimed(bot *gotgbot.Bot, ctx *ext.Context, transfer *models.AccountTransfer) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+	t := func(key string, args ...interface{}) string {
+		return h.services.Localization.T(context.Background(), userLang, key, args...)
+	}
+
+	hasData, err := h.services.Transfers.HasData(context.Background(), userID)
+	if err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to check account data for transfer")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, t("transfer_failed"), nil)
+		return err
+	}
+
+	from := h.transferAccountName(transfer.FromUserID)
+	id := transfer.ID.String()
+	text := t("transfer_claim_confirm", from)
+	keyboard := [][]gotgbot.InlineKeyboardButton{
+		{{Text: t("transfer_btn_confirm"), CallbackData: "transfer_confirm_" + id}},
+	}
+	if hasData {
+		text = t("transfer_claim_conflict", from)
+		keyboard = [][]gotgbot.InlineKeyboardButton{
+			{{Text: t("transfer_btn_keep_mine"), CallbackData: "transfer_resolve_" + id + "_" + string(models.TransferKeepMine)}},
+			{{Text: t("transfer_btn_keep_theirs"), CallbackData: "transfer_resolve_" + id + "_" + string(models.TransferKeepTheirs)}},
+			{{Text: t("transfer_btn_merge"), CallbackData: "transfer_resolve_" + id + "_" + string(models.TransferMerge)}},
+		}
+	}
+
+	if _, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	}); err != nil {
+		return err
+	}
+
+	if !transfer.FromConfirmed {
+		h.notifyTransferUser(bot, transfer.FromUserID, func(t func(string, ...interface{}) string) (string, *gotgbot.InlineKeyboardMarkup) {
+			return t("transfer_approve_request", h.transferAccountName(userID), transfer.Code),
+				&gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+					{{Text: t("transfer_btn_approve"), CallbackData: "transfer_approve_" + id}},
+					{{Text: t("transfer_btn_decline"), CallbackData: "transfer_decline_" + id}},
+				}}
+		})
+	}
+	return nil
+}
+
+// handleTransferCallback handles the confirmation buttons of both accounts
+func (h *CommandHandler) handleTransferCallback(bot *gotgbot.Bot, ctx *ext.Context, action string, params []string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+	t := func(key string, args ...interface{}) string {
+		return h.services.Localization.T(context.Background(), userLang, key, args...)
+	}
+	reply := func(text string) error {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{ParseMode: "Markdown"})
+		return err
+	}
+
+	if len(params) == 0 {
+		return nil
+	}
+	id, err := uuid.Parse(params[0])
+	if err != nil {
+		return nil
+	}
+
+	var transfer *models.AccountTransfer
+	switch action {
+	case "approve":
+		// Only the old account approves through the button; admins use the command
+		transfer, err = h.services.Transfers.Get(context.Background(), id)
+		if err == nil && transfer.FromUserID != userID {
+			err = services.ErrTransferNotFound
+		}
+		if err == nil {
+			transfer, err = h.services.Transfers.ConfirmFrom(context.Background(), id, userID)
+		}
+	case "decline":
+		if _, err := h.services.Transfers.Cancel(context.Background(), userID); err != nil {
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to cancel transfer")
+			return reply(t("transfer_failed"))
+		}
+		return reply(t("transfer_cancelled"))
+	case "confirm", "resolve":
+		// The resolution itself contains underscores
+		resolution := models.TransferResolution(strings.Join(params[1:], "_"))
+		transfer, err = h.services.Transfers.ConfirmTo(context.Background(), id, userID, resolution)
+		if errors.Is(err, services.ErrTransferResolutionNeeded) {
+			// The account got a configuration of its own since claiming
+			transfer, err = h.services.Transfers.Get(context.Background(), id)
+			if err == nil {
+				return h.sendTransferClaimed(bot, ctx, transfer)
+			}
+		}
+	default:
+		return nil
+	}
+	if err != nil {
+		return reply(t(h.transferErrorKey(err)))
+	}
+
+	if !transfer.ReadyToComplete() {
+		if action == "approve" {
+			return reply(t("transfer_waiting_new"))
+		}
+		return reply(t("transfer_waiting_old", transfer.Code))
+	}
+	if _, err := h.completeTransfer(bot, transfer); err != nil {
+		return reply(t(h.transferErrorKey(err)))
+	}
+	return nil
+}
+
+// completeTransfer moves the configuration and tells both accounts
+func (h *CommandHandler) completeTransfer(bot *gotgbot.Bot, transfer *models.AccountTransfer) (*models.AccountTransfer, error) {
+	completed, err := h.services.CompleteTransfer(context.Background(), transfer.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for userID, key := range map[int64]string{
+		completed.ToUserID:   "transfer_completed_new",
+		completed.FromUserID: "transfer_completed_old",
+	} {
+		h.notifyTransferUser(bot, userID, func(t func(string, ...interface{}) string) (string, *gotgbot.InlineKeyboardMarkup) {
+			return t(key), nil
+		})
+	}
+	return completed, nil
+}
+
+// notifyTransferUser sends a message to the private chat of the other
+// account, in its language. The old account may be out of reach, so failures
+// are only logged.
+func (h *CommandHandler) notifyTransferUser(bot *gotgbot.Bot, userID int64, build func(t func(string, ...interface{}) string) (string, *gotgbot.InlineKeyboardMarkup)) {
+	language := ""
+	if user, err := h.services.User.GetUser(context.Background(), userID); err == nil {
+		language = user.Language
+	}
+	t := func(key string, args ...interface{}) string {
+		return h.services.Localization.T(context.Background(), language, key, args...)
+	}
+
+	// Plain text: usernames may contain Markdown characters
+	text, markup := build(t)
+	opts := &gotgbot.SendMessageOpts{}
+	if markup != nil {
+		opts.ReplyMarkup = markup
+	}
+	if _, err := bot.SendMessage(userID, text, opts); err != nil {
+		h.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to notify account about transfer")
+	}
+}
+
+// transferAccountName describes an account to the other end of a transfer
+func (h *CommandHandler) transferAccountName(userID int64) string {
+	user, err := h.services.User.GetUser(context.Background(), userID)
+	switch {
+	case err != nil:
+		return strconv.FormatInt(userID, 10)
+	case user.Username != "":
+		return "@" + user.Username
+	case user.FirstName != "":
+		return fmt.Sprintf("%s (%d)", user.FirstName, userID)
+	}
+	return strconv.FormatInt(userID, 10)
+}
+
+// transferErrorKey returns the localization key explaining a transfer error
+func (h *CommandHandler) transferErrorKey(err error) string {
+	switch {
+	case errors.Is(err, services.ErrTransferExpired):
+		return "transfer_code_expired"
+	case errors.Is(err, services.ErrTransferSelf):
+		return "transfer_self"
+	case errors.Is(err, services.ErrTransferNotFound):
+		return "transfer_code_invalid"
+	default:
+		h.logger.Error().Err(err).Msg("Account transfer failed")
+		return "transfer_failed"
+	}
+}
@@ -0,0 +1,268 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/internal/services"
+)
+
+const userInfoUsage = `*Usage:* /userinfo <user_id or @username>
+
+*Examples:*
+/userinfo 123456789
+/userinfo @kyiv\_walker
+
+Users without a username can only be looked up by ID.`
+
+// UserInfo command shows moderators and admins one user's settings,
+// subscriptions and alerts, to see why notifications do or do not reach them.
+// The saved location is shown to admins only, who also get buttons to change
+// the user's role or deactivate the account.
+func (h *CommandHandler) UserInfo(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	// Check moderator permissions
+	viewer, err := h.getUser(ctx, userID)
+	if err != nil || viewer.Role < models.RoleModerator {
+		errorMsg := h.services.Localization.T(context.Background(), userLang, "admin_broadcast_insufficient_permissions")
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, errorMsg, nil)
+		return err
+	}
+
+	reply := func(text string, keyboard [][]gotgbot.InlineKeyboardButton) error {
+		opts := &gotgbot.SendMessageOpts{ParseMode: "Markdown"}
+		if len(keyboard) > 0 {
+			opts.ReplyMarkup = &gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard}
+		}
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, opts)
+		return err
+	}
+
+	args := ctx.Args()
+	if len(args) < 2 {
+		return reply(userInfoUsage, nil)
+	}
+
+	target, err := h.findUser(ctx, args[1])
+	if err != nil {
+		h.logger.Debug().Err(err).Str("query", args[1]).Msg("User info lookup failed")
+		return reply("❌ User not found", nil)
+	}
+
+	subscriptions, err := h.services.Subscription.GetUserSubscriptions(context.Background(), target.ID)
+	if err != nil {
+		h.logger.Error().Err(err).Int64("target_user_id", target.ID).Msg("Failed to get subscriptions for user info")
+		return reply("❌ Failed to load the user's subscriptions", nil)
+	}
+	alerts, err := h.services.Alert.GetUserAlerts(context.Background(), target.ID)
+	if err != nil {
+		h.logger.Error().Err(err).Int64("target_user_id", target.ID).Msg("Failed to get alerts for user info")
+		return reply("❌ Failed to load the user's alerts", nil)
+	}
+
+	isAdmin := viewer.Role == models.RoleAdmin
+	var keyboard [][]gotgbot.InlineKeyboardButton
+	if isAdmin {
+		keyboard = userInfoKeyboard(target)
+	}
+	return reply(h.formatUserInfo(target, subscriptions, alerts, isAdmin), keyboard)
+}
+
+// findUser looks a user up by Telegram ID or by username. Telegram usernames
+// never start with a digit, so a number is always an ID.
+func (h *CommandHandler) findUser(ctx *ext.Context, query string) (*models.User, error) {
+	if id, err := strconv.ParseInt(query, 10, 64); err == nil {
+		return h.getUser(ctx, id)
+	}
+	return h.services.User.GetUserByUsername(context.Background(), query)
+}
+
+// formatUserInfo renders the user's profile, subscriptions and alerts. The
+// saved location and its coordinates are included for admins only;
+// moderators see whether one is set.
+func (h *CommandHandler) formatUserInfo(user *models.User, subscriptions []models.Subscription, alerts []models.AlertConfig, isAdmin bool) string {
+	var b strings.Builder
+
+	username := "none"
+	if user.Username != "" {
+		username = "@" + markdown.Escape(user.Username)
+	}
+	status := "Active"
+	if !user.IsActive {
+		status = "Deactivated"
+	}
+	lastSeen := "not recorded"
+	if user.LastActiveAt != nil {
+		lastSeen = user.LastActiveAt.UTC().Format("2006-01-02 15:04 UTC")
+	}
+
+	fmt.Fprintf(&b, "👤 *User Info*\n\n")
+	fmt.Fprintf(&b, "*Name:* %s\n", markdown.Escape(strings.TrimSpace(user.FirstName+" "+user.LastName)))
+	fmt.Fprintf(&b, "*Username:* %s\n", username)
+	fmt.Fprintf(&b, "*ID:* `%d`\n", user.ID)
+	fmt.Fprintf(&b, "*Role:* %s\n", h.services.User.GetRoleName(user.Role))
+	fmt.Fprintf(&b, "*Status:* %s\n", status)
+	fmt.Fprintf(&b, "*Language:* %s\n", user.Language)
+	fmt.Fprintf(&b, "*Units:* %s\n", user.Units)
+	fmt.Fprintf(&b, "*Timezone:* %s\n", markdown.Escape(user.Timezone))
+	fmt.Fprintf(&b, "*Last seen:* %s\n", lastSeen)
+
+	switch {
+	case !user.HasLocation():
+		fmt.Fprintf(&b, "📍 *Location:* not set\n")
+	case isAdmin:
+		fmt.Fprintf(&b, "📍 *Location:* %s (%.4f, %.4f)", markdown.Escape(user.LocationName), user.Latitude, user.Longitude)
+		if user.LocationCityLevel {
+			fmt.Fprintf(&b, ", city level")
+		}
+		fmt.Fprintf(&b, "\n")
+	default:
+		fmt.Fprintf(&b, "📍 *Location:* set\n")
+	}
+
+	fmt.Fprintf(&b, "\n🔔 *Subscriptions (%d):*\n", len(subscriptions))
+	if len(subscriptions) == 0 {
+		fmt.Fprintf(&b, "none\n")
+	}
+	for _, sub := range subscriptions {
+		fmt.Fprintf(&b, "• %s, %s at %s",
+			h.getSubscriptionTypeText(sub.SubscriptionType, "en-US"), h.getFrequencyText(sub.Frequency, "en-US"), sub.TimeOfDay)
+		if sub.LastSentAt != nil {
+			fmt.Fprintf(&b, ", last sent %s", sub.LastSentAt.UTC().Format("Jan 2 15:04 UTC"))
+		}
+		fmt.Fprintf(&b, "\n")
+	}
+
+	fmt.Fprintf(&b, "\n⚠️ *Alerts (%d):*\n", len(alerts))
+	if len(alerts) == 0 {
+		fmt.Fprintf(&b, "none\n")
+	}
+	for _, alert := range alerts {
+		operator := "?"
+		var condition services.AlertCondition
+		if err := json.Unmarshal([]byte(alert.Condition), &condition); err == nil {
+			operator = h.getOperatorSymbol(condition.Operator)
+		}
+		fmt.Fprintf(&b, "• %s %s %s", h.getAlertTypeText(alert.AlertType), operator,
+			services.FormatAlertValue(alert.AlertType, alert.Threshold, services.UserUnits(user)))
+		if alert.LastCheckedAt != nil {
+			fmt.Fprintf(&b, ", checked %s", alert.LastCheckedAt.UTC().Format("Jan 2 15:04 UTC"))
+		} else {
+			fmt.Fprintf(&b, ", never checked")
+		}
+		if alert.LastTriggered != nil {
+			fmt.Fprintf(&b, ", triggered %s", alert.LastTriggered.UTC().Format("Jan 2 15:04 UTC"))
+		}
+		fmt.Fprintf(&b, "\n")
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// userInfoKeyboard offers the role changes open to the user and, while the
+// account is active, deactivating it
+func userInfoKeyboard(user *models.User) [][]gotgbot.InlineKeyboardButton {
+	var roleButtons []gotgbot.InlineKeyboardButton
+	if user.Role < models.RoleAdmin {
+		roleButtons = append(roleButtons, gotgbot.InlineKeyboardButton{Text: "⬆️ Promote", CallbackData: fmt.Sprintf("userinfo_promote_%d", user.ID)})
+	}
+	if user.Role > models.RoleUser {
+		roleButtons = append(roleButtons, gotgbot.InlineKeyboardButton{Text: "⬇️ Demote", CallbackData: fmt.Sprintf("userinfo_demote_%d", user.ID)})
+	}
+
+	keyboard := [][]gotgbot.InlineKeyboardButton{roleButtons}
+	if user.IsActive {
+		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
+			{Text: "🚫 Deactivate", CallbackData: fmt.Sprintf("userinfo_deactivate_%d", user.ID)},
+		})
+	}
+	return keyboard
+}
+
+// handleUserInfoCallback acts on the buttons of /userinfo. Role changes go
+// through the confirmation of /promote and /demote; deactivating asks for
+// its own.
+func (h *CommandHandler) handleUserInfoCallback(bot *gotgbot.Bot, ctx *ext.Context, action string, params []string) error {
+	if action == "cancel" {
+		_, _, err := bot.EditMessageText("❌ Cancelled.", &gotgbot.EditMessageTextOpts{
+			ChatId:    ctx.EffectiveChat.Id,
+			MessageId: ctx.CallbackQuery.Message.GetMessageId(),
+		})
+		return err
+	}
+
+	if !h.requireAdmin(bot, ctx) {
+		return nil
+	}
+	if len(params) < 1 {
+		h.logger.Warn().Str("action", action).Msg("User info callback without a user")
+		return nil
+	}
+	targetUserID, err := strconv.ParseInt(params[0], 10, 64)
+	if err != nil {
+		h.logger.Warn().Err(err).Str("target_user_id", params[0]).Msg("Invalid user in user info callback")
+		return nil
+	}
+	target, err := h.getUser(ctx, targetUserID)
+	if err != nil {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ User not found", nil)
+		return err
+	}
+
+	switch action {
+	case "promote":
+		if target.Role >= models.RoleAdmin {
+			_, err := bot.SendMessage(ctx.EffectiveChat.Id, "ℹ️ User is already an Admin (highest role)", nil)
+			return err
+		}
+		return h.sendRoleChangeConfirmation(bot, ctx, target, target.Role+1)
+	case "demote":
+		if target.Role <= models.RoleUser {
+			_, err := bot.SendMessage(ctx.EffectiveChat.Id, "ℹ️ User already has the lowest role (User)", nil)
+			return err
+		}
+		return h.sendRoleChangeConfirmation(bot, ctx, target, target.Role-1)
+	case "deactivate":
+		confirmMsg := fmt.Sprintf(`*Confirm Deactivation*
+
+👤 *User:* %s (ID: %d)
+
+Deactivated users are left out of broadcasts and counted as inactive in /stats; their subscriptions and alerts are kept. They are active again as soon as they write to the bot.`,
+			markdown.Escape(userDisplayName(target)), target.ID)
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, confirmMsg, &gotgbot.SendMessageOpts{
+			ParseMode: "Markdown",
+			ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{{
+				{Text: "✅ Confirm", CallbackData: fmt.Sprintf("userinfo_confirmdeactivate_%d", target.ID)},
+				{Text: "❌ Cancel", CallbackData: "userinfo_cancel"},
+			}}},
+		})
+		return err
+	case "confirmdeactivate":
+		adminID := ctx.EffectiveUser.Id
+		if err := h.services.User.UpdateUserSettings(context.Background(), target.ID, map[string]interface{}{"is_active": false}); err != nil {
+			h.logger.Error().Err(err).Int64("admin_id", adminID).Int64("target_user_id", target.ID).Msg("Failed to deactivate user")
+			_, err := bot.SendMessage(ctx.EffectiveChat.Id, "❌ Failed to deactivate the user", nil)
+			return err
+		}
+		h.logger.Info().Int64("admin_id", adminID).Int64("target_user_id", target.ID).Msg("User deactivated by admin")
+		h.auditAdminAction(ctx, services.AuditUserDeactivated, target.ID, nil)
+		_, _, err := bot.EditMessageText(fmt.Sprintf("✅ User %d deactivated.", target.ID), &gotgbot.EditMessageTextOpts{
+			ChatId:    ctx.EffectiveChat.Id,
+			MessageId: ctx.CallbackQuery.Message.GetMessageId(),
+		})
+		return err
+	default:
+		h.logger.Warn().Str("action", action).Msg("Unknown user info callback action")
+		return nil
+	}
+}
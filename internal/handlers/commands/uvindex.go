@@ -0,0 +1,177 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+// uvForecastHours is how far ahead /uvindex draws the UV index hour by hour
+const uvForecastHours = 12
+
+// skinTypeNumerals are the Roman numerals the Fitzpatrick scale is written in
+var skinTypeNumerals = [weather.MaxSkinType + 1]string{1: "I", 2: "II", 3: "III", 4: "IV", 5: "V", 6: "VI"}
+
+// UVIndex command shows the UV index of a place, how long the user's skin
+// can stay in the sun unprotected and the UV index of the hours ahead. The
+// provider reports no UV, so it is estimated from the position of the sun
+// and the forecast cloud cover.
+func (h *CommandHandler) UVIndex(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	location := h.parseLocationFromArgs(ctx)
+	if location == "" {
+		locationName, _, _, err := h.getUserLocation(ctx, userID)
+		if err != nil || locationName == "" {
+			return h.sendLocationNeeded(bot, ctx, "uvindex_location_needed")
+		}
+		location = locationName
+	}
+
+	locationData, err := h.services.Weather.GeocodeLocation(h.weatherContext(ctx), location)
+	if err != nil {
+		return h.sendWeatherError(bot, ctx, location, err, "location_not_found", location)
+	}
+
+	forecast, err := h.services.Weather.GetHourlyForecast(h.weatherContext(ctx), locationData.Latitude, locationData.Longitude, hourlyForecastHours)
+	if err != nil {
+		return h.sendWeatherError(bot, ctx, location, err, "uvindex_error")
+	}
+
+	if err := h.services.User.IncrementWeatherRequestCounter(context.Background()); err != nil {
+		h.logger.Warn().Err(err).Msg("Failed to increment weather request counter")
+	}
+
+	user, _ := h.getUser(ctx, userID)
+	skinType := skinTypeOf(user)
+
+	now := time.Now()
+	clouds := 0
+	if len(forecast.Slots) > 0 {
+		clouds = forecast.Slots[0].Clouds
+	}
+	current := weather.EstimateUVIndex(now, locationData.Latitude, locationData.Longitude, clouds)
+	hours := weather.HourlyUVIndex(forecast, locationData.Latitude, locationData.Longitude, now, uvForecastHours)
+	text := h.formatUVIndexMessage(forecast.Location, current, hours, skinType, userLang, h.hourlyZone(ctx, userID, forecast))
+
+	keyboard := [][]gotgbot.InlineKeyboardButton{{{
+		Text:         h.services.Localization.T(context.Background(), userLang, "button_skin_type", skinTypeNumerals[skinType]),
+		CallbackData: "settings_skintype",
+	}}}
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	return err
+}
+
+// formatUVIndexMessage renders the UV index now, what it means for skin of
+// the Fitzpatrick type and a bar per daylight hour ahead, in the given zone
+func (h *CommandHandler) formatUVIndexMessage(locationName string, uv float64, hours []weather.UVHour, skinType int, language string, zone *time.Location) string {
+	level := uvLevel(uv)
+	lines := []string{
+		h.services.Localization.T(context.Background(), language, "uv_details_title", markdown.EscapeBold(locationName)),
+		"",
+		h.services.Localization.T(context.Background(), language, "uv_details_index",
+			weather.FormatUV(uv), h.services.Localization.T(context.Background(), language, "uv_level_"+level)),
+		h.services.Localization.T(context.Background(), language, "uvindex_estimated"),
+		"",
+	}
+
+	numeral := skinTypeNumerals[skinType]
+	if exposure := weather.SafeExposure(uv, skinType); exposure > 0 {
+		lines = append(lines, h.services.Localization.T(context.Background(), language, "uvindex_safe_exposure",
+			numeral, h.services.Localization.T(context.Background(), language, "data_age_minutes", int(exposure/time.Minute))))
+	} else {
+		lines = append(lines, h.services.Localization.T(context.Background(), language, "uvindex_safe_exposure_unlimited", numeral))
+	}
+	if spf := weather.RecommendedSPF(uv, skinType); spf > 0 {
+		lines = append(lines, h.services.Localization.T(context.Background(), language, "uvindex_spf", spf))
+	} else {
+		lines = append(lines, h.services.Localization.T(context.Background(), language, "uvindex_spf_none"))
+	}
+	lines = append(lines, h.services.Localization.T(context.Background(), language, "uv_advice_"+level), "")
+
+	var bars []string
+	for _, hour := range hours {
+		if hour.UVIndex <= 0 {
+			continue
+		}
+		bars = append(bars, fmt.Sprintf("%s %s %s", hour.Time.In(zone).Format("15:04"), weather.UVBar(hour.UVIndex), weather.FormatUV(hour.UVIndex)))
+	}
+	if len(bars) == 0 {
+		lines = append(lines, h.services.Localization.T(context.Background(), language, "uvindex_hourly_dark", len(hours)))
+	} else {
+		lines = append(lines,
+			h.services.Localization.T(context.Background(), language, "uvindex_hourly_title", len(hours)),
+			"```\n"+strings.Join(bars, "\n")+"\n```")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// handleSkinTypeSettings shows the Fitzpatrick skin types to pick from, the
+// user's own marked
+func (h *CommandHandler) handleSkinTypeSettings(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	user, _ := h.getUser(ctx, userID)
+	current := skinTypeOf(user)
+
+	keyboard := make([][]gotgbot.InlineKeyboardButton, 0, weather.MaxSkinType)
+	for skinType := weather.MinSkinType; skinType <= weather.MaxSkinType; skinType++ {
+		label := h.services.Localization.T(context.Background(), userLang, fmt.Sprintf("skin_type_%d", skinType))
+		if skinType == current {
+			label = "✅ " + label
+		}
+		keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{{
+			Text:         label,
+			CallbackData: fmt.Sprintf("settings_skintype_set_%d", skinType),
+		}})
+	}
+
+	_, err := bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, "skin_type_select"), &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: &gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	return err
+}
+
+// setSkinType stores the user's Fitzpatrick skin type
+func (h *CommandHandler) setSkinType(bot *gotgbot.Bot, ctx *ext.Context, value string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	skinType, err := strconv.Atoi(value)
+	if err == nil {
+		err = h.services.User.UpdateUserSkinType(context.Background(), userID, skinType)
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Str("skin_type", value).Msg("Failed to update skin type")
+		message := h.services.Localization.T(context.Background(), userLang, "skin_type_update_failed")
+		_, sendErr := bot.SendMessage(ctx.EffectiveChat.Id, message, nil)
+		return sendErr
+	}
+
+	message := h.services.Localization.T(context.Background(), userLang, "skin_type_updated", skinTypeNumerals[skinType])
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, message, nil)
+	return err
+}
+
+// skinTypeOf returns the user's skin type, the default when unset
+func skinTypeOf(user *models.User) int {
+	if user == nil || user.SkinType < weather.MinSkinType || user.SkinType > weather.MaxSkinType {
+		return weather.DefaultSkinType
+	}
+	return user.SkinType
+}
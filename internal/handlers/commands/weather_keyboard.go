@@ -0,0 +1,217 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/services"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+const (
+	// weatherKeyboardRowWidth and weatherKeyboardMaxRows bound the keyboard
+	// under a weather message so it never pushes the message off screen
+	weatherKeyboardRowWidth = 4
+	weatherKeyboardMaxRows  = 2
+
+	// highUVIndex is where sun protection becomes necessary (WHO scale)
+	highUVIndex = 6.0
+
+	// unhealthyAQI is where air becomes unhealthy for sensitive groups
+	unhealthyAQI = 100
+)
+
+// weatherKeyboardInput is everything the keyboard under a weather message
+// depends on: the weather snapshot, the user's feature flags and where the
+// message is shown
+type weatherKeyboardInput struct {
+	Location string
+	Weather  *services.WeatherData
+	Features *services.Features
+	// CanPin is set when the live widget can be pinned: the saved location
+	// shown in a private chat
+	CanPin bool
+}
+
+// weatherButton is a button of the keyboard identified by its label key
+type weatherButton struct {
+	LabelKey     string
+	CallbackData string
+}
+
+// rainExpected reports whether rain is falling or about to: OpenWeather
+// condition icons 09 (showers), 10 (rain) and 11 (thunderstorm)
+func rainExpected(data *services.WeatherData) bool {
+	for _, prefix := range []string{"09", "10", "11"} {
+		if strings.HasPrefix(data.Icon, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// weatherKeyboardButtons lays out the buttons under a weather message.
+// Forecast, hourly forecast, air quality and alert setup always fill the first
+// row, with air quality first when the air is unhealthy. Context-dependent suggestions
+// fill the second row in a fixed priority order, ahead of the refresh and share
+// buttons which always close it; the suggestions that do not fit are dropped:
+//
+//  1. next hour, when rain is expected and the nowcast feature is on
+//  2. guidance for sensitive groups, when AQI > 100
+//  3. UV details, when UV >= 6
+//  4. pinning the live widget
+func weatherKeyboardButtons(in weatherKeyboardInput) [][]weatherButton {
+	forecast := weatherButton{"button_forecast", "forecast_" + in.Location}
+	hourly := weatherButton{"button_hourly", "hourly_0_" + url.QueryEscape(in.Location)}
+	air := weatherButton{"button_air_quality", "air_" + in.Location}
+	alert := weatherButton{"button_set_alert", "alert_" + in.Location}
+	refresh := weatherButton{"button_refresh", "refresh_" + in.Location}
+	share := weatherButton{"button_share", "share_" + in.Location}
+
+	unhealthyAir := in.Weather.AQI > unhealthyAQI
+	first := []weatherButton{forecast, hourly, air, alert}
+	if unhealthyAir {
+		first = []weatherButton{air, forecast, hourly, alert}
+	}
+
+	var suggestions []weatherButton
+	if rainExpected(in.Weather) && in.Features.Enabled(services.FeatureNowcast) {
+		suggestions = append(suggestions, weatherButton{"button_next_hour", "nexthour_" + in.Location})
+	}
+	if unhealthyAir {
+		suggestions = append(suggestions, weatherButton{"button_air_sensitive", "sensitive_" + in.Location})
+	}
+	if in.Weather.UVIndex >= highUVIndex {
+		suggestions = append(suggestions, weatherButton{"button_uv_details", "uv_" + in.Location})
+	}
+	if in.CanPin {
+		suggestions = append(suggestions, weatherButton{"widget_pin_btn", "widget_pin"})
+	}
+
+	if slots := (weatherKeyboardMaxRows-1)*weatherKeyboardRowWidth - 2; len(suggestions) > slots {
+		suggestions = suggestions[:slots]
+	}
+	suggestions = append(suggestions, refresh, share)
+
+	rows := [][]weatherButton{first}
+	for len(suggestions) > 0 && len(rows) < weatherKeyboardMaxRows {
+		n := min(len(suggestions), weatherKeyboardRowWidth)
+		rows = append(rows, suggestions[:n])
+		suggestions = suggestions[n:]
+	}
+	return rows
+}
+
+// weatherKeyboard renders the buttons under a weather message in the user's
+// language
+func (h *CommandHandler) weatherKeyboard(userLang string, in weatherKeyboardInput) [][]gotgbot.InlineKeyboardButton {
+	rows := weatherKeyboardButtons(in)
+	keyboard := make([][]gotgbot.InlineKeyboardButton, 0, len(rows))
+	for _, row := range rows {
+		buttons := make([]gotgbot.InlineKeyboardButton, 0, len(row))
+		for _, button := range row {
+			buttons = append(buttons, gotgbot.InlineKeyboardButton{
+				Text:         h.services.Localization.T(context.Background(), userLang, button.LabelKey),
+				CallbackData: button.CallbackData,
+			})
+		}
+		keyboard = append(keyboard, buttons)
+	}
+	return keyboard
+}
+
+// handleWeatherSuggestionCallback answers the context-dependent buttons under
+// a weather message. The location is the rest of the callback data.
+func (h *CommandHandler) handleWeatherSuggestionCallback(bot *gotgbot.Bot, ctx *ext.Context, action string, params []string) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+	location := strings.Join(params, "_")
+
+	// A button from an older message can outlive the rollout that showed it
+	if action == "nexthour" && !h.services.Features(context.Background(), userID).Enabled(services.FeatureNowcast) {
+		return nil
+	}
+
+	weatherData, err := h.services.Weather.GetCurrentWeatherByLocation(weather.WithLanguage(h.weatherContext(ctx), userLang), location)
+	if err != nil {
+		h.logger.Error().Err(err).Str("location", location).Msg("Failed to get weather data")
+		return h.sendWeatherError(bot, ctx, location, err, "weather_error", location)
+	}
+
+	locationName := weatherData.LocationName
+	if weatherData.Location != nil {
+		locationName = h.services.Weather.GetLocalizedLocationName(weatherData.Location, userLang)
+	}
+
+	var text string
+	switch action {
+	case "nexthour":
+		text = h.formatNextHourMessage(weatherData, locationName, userLang)
+	case "uv":
+		text = h.formatUVMessage(weatherData, locationName, userLang)
+	case "sensitive":
+		text = h.formatSensitiveGroupsMessage(weatherData, locationName, userLang)
+	default:
+		return nil
+	}
+
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{ParseMode: "Markdown"})
+	return err
+}
+
+func (h *CommandHandler) formatNextHourMessage(data *services.WeatherData, locationName, language string) string {
+	title := h.services.Localization.T(context.Background(), language, "next_hour_title", markdown.EscapeBold(locationName))
+	key := "next_hour_dry"
+	if rainExpected(data) {
+		key = "next_hour_rain"
+	}
+	return fmt.Sprintf("%s\n\n%s", title, h.services.Localization.T(context.Background(), language, key, data.Description))
+}
+
+// uvLevel names the WHO UV index category
+func uvLevel(uv float64) string {
+	switch {
+	case uv < 3:
+		return "low"
+	case uv < 6:
+		return "moderate"
+	case uv < 8:
+		return "high"
+	case uv < 11:
+		return "very_high"
+	default:
+		return "extreme"
+	}
+}
+
+func (h *CommandHandler) formatUVMessage(data *services.WeatherData, locationName, language string) string {
+	level := uvLevel(data.UVIndex)
+	title := h.services.Localization.T(context.Background(), language, "uv_details_title", markdown.EscapeBold(locationName))
+	index := h.services.Localization.T(context.Background(), language, "uv_details_index",
+		weather.FormatUV(data.UVIndex),
+		h.services.Localization.T(context.Background(), language, "uv_level_"+level))
+	advice := h.services.Localization.T(context.Background(), language, "uv_advice_"+level)
+	return fmt.Sprintf("%s\n\n%s\n\n%s", title, index, advice)
+}
+
+func (h *CommandHandler) formatSensitiveGroupsMessage(data *services.WeatherData, locationName, language string) string {
+	title := h.services.Localization.T(context.Background(), language, "air_sensitive_title", markdown.EscapeBold(locationName))
+	aqi := h.services.Localization.T(context.Background(), language, "air_quality_overall_aqi",
+		weather.FormatAQI(float64(data.AQI)), h.getAQIDescription(data.AQI, language))
+	groups := h.services.Localization.T(context.Background(), language, "air_sensitive_groups")
+
+	key := "air_sensitive_advice_ok"
+	switch {
+	case data.AQI > 200:
+		key = "air_sensitive_advice_severe"
+	case data.AQI > unhealthyAQI:
+		key = "air_sensitive_advice_unhealthy"
+	}
+	return fmt.Sprintf("%s\n\n%s\n\n%s\n\n%s", title, aqi, groups, h.services.Localization.T(context.Background(), language, key))
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/notifications"
+)
+
+// Webhook command handler: /webhook <url> posts the user's alert triggers to
+// the URL, /webhook off stops it and /webhook alone shows the current one
+func (h *CommandHandler) Webhook(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+	t := func(key string, args ...interface{}) string {
+		return h.services.Localization.T(context.Background(), userLang, key, args...)
+	}
+	reply := func(text string) error {
+		_, err := bot.SendMessage(ctx.EffectiveChat.Id, text, &gotgbot.SendMessageOpts{ParseMode: "Markdown"})
+		return err
+	}
+
+	// The secret signs the user's requests, so it is never shown to a group
+	if ctx.EffectiveChat.Type != gotgbot.ChatTypePrivate {
+		return reply(t("webhook_private_only"))
+	}
+
+	args := ctx.Args()
+	if len(args) < 2 {
+		webhook, err := h.services.Webhooks.Get(context.Background(), userID)
+		if err != nil {
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to get webhook")
+			return reply(t("webhook_failed"))
+		}
+		if webhook == nil {
+			return reply(t("webhook_usage"))
+		}
+		return reply(t("webhook_status", webhook.URL))
+	}
+
+	if strings.EqualFold(args[1], "off") {
+		removed, err := h.services.Webhooks.Remove(context.Background(), userID)
+		switch {
+		case err != nil:
+			h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to remove webhook")
+			return reply(t("webhook_failed"))
+		case !removed:
+			return reply(t("webhook_usage"))
+		}
+		return reply(t("webhook_removed"))
+	}
+
+	webhook, err := h.services.Webhooks.Register(context.Background(), userID, args[1])
+	if errors.Is(err, notifications.ErrInvalidWebhookURL) {
+		return reply(t("webhook_invalid_url"))
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to register webhook")
+		return reply(t("webhook_failed"))
+	}
+	return reply(t("webhook_set", webhook.URL, webhook.Secret, notifications.SignatureHeader, notifications.TimestampHeader))
+}
@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"context"
+	"errors"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+
+	"github.com/valpere/shopogoda/internal/services"
+)
+
+// Unpin command handler: stops the live weather widget of the chat
+func (h *CommandHandler) Unpin(bot *gotgbot.Bot, ctx *ext.Context) error {
+	return h.unpinWidget(bot, ctx)
+}
+
+func (h *CommandHandler) handleWidgetCallback(bot *gotgbot.Bot, ctx *ext.Context, action string) error {
+	switch action {
+	case "pin":
+		return h.pinWidget(bot, ctx)
+	case "unpin":
+		return h.unpinWidget(bot, ctx)
+	}
+	return nil
+}
+
+// pinWidget pins a live weather message for the user's saved location
+func (h *CommandHandler) pinWidget(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	chatID := ctx.EffectiveChat.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	reply := func(key string) error {
+		_, err := bot.SendMessage(chatID, h.services.Localization.T(context.Background(), userLang, key), nil)
+		return err
+	}
+
+	if ctx.EffectiveChat.Type != gotgbot.ChatTypePrivate {
+		return reply("widget_private_only")
+	}
+
+	user, err := h.getUser(ctx, userID)
+	if err != nil || !user.HasLocation() {
+		return reply("widget_location_needed")
+	}
+
+	_, err = h.services.Widgets.Pin(context.Background(), chatID, user)
+	switch {
+	case errors.Is(err, services.ErrWidgetExists):
+		return reply("widget_exists")
+	case err != nil:
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to pin live weather widget")
+		return reply("widget_pin_failed")
+	}
+	return reply("widget_pinned")
+}
+
+func (h *CommandHandler) unpinWidget(bot *gotgbot.Bot, ctx *ext.Context) error {
+	userID := ctx.EffectiveUser.Id
+	userLang := h.getUserLanguage(ctx, userID)
+
+	removed, err := h.services.Widgets.Unpin(context.Background(), ctx.EffectiveChat.Id)
+	key := "widget_unpinned"
+	switch {
+	case err != nil:
+		h.logger.Error().Err(err).Int64("user_id", userID).Msg("Failed to remove live weather widget")
+		key = "widget_unpin_failed"
+	case !removed:
+		key = "widget_none"
+	}
+
+	_, err = bot.SendMessage(ctx.EffectiveChat.Id, h.services.Localization.T(context.Background(), userLang, key), nil)
+	return err
+}
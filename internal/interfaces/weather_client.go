@@ -12,6 +12,7 @@ import (
 type WeatherClientInterface interface {
 	GetCurrentWeather(ctx context.Context, lat, lon float64) (*weather.WeatherData, error)
 	GetForecast(ctx context.Context, lat, lon float64, days int) (*weather.ForecastData, error)
+	GetHourlyForecast(ctx context.Context, lat, lon float64, hours int) (*weather.HourlyForecastData, error)
 	GetAirQuality(ctx context.Context, lat, lon float64) (*weather.AirQualityData, error)
 }
 
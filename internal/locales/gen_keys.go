@@ -0,0 +1,43 @@
+//go:build ignore
+
+// gen_keys writes referenced_keys.go: the translation keys passed as string
+// literals to a T(ctx, language, key, ...) or TN(ctx, language, key, count,
+// ...) call anywhere in the non-test sources of the bot, or to t "key" in a
+// message template. Run it through go generate after adding or renaming a key.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+
+	"github.com/valpere/shopogoda/internal/locales/keyscan"
+)
+
+// roots are scanned relative to this directory
+var roots = []string{"../../internal", "../../cmd"}
+
+func main() {
+	keys, err := keyscan.Scan(roots...)
+	if err != nil {
+		log.Fatalf("failed to scan sources: %v", err)
+	}
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by gen_keys.go; DO NOT EDIT.\n\npackage locales\n\n")
+	b.WriteString("// ReferencedKeys lists the translation keys the code passes to T as literals\nvar ReferencedKeys = []string{\n")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "\t%q,\n", key)
+	}
+	b.WriteString("}\n")
+
+	source, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatalf("failed to format registry: %v", err)
+	}
+	if err := os.WriteFile("referenced_keys.go", source, 0o644); err != nil {
+		log.Fatalf("failed to write registry: %v", err)
+	}
+}
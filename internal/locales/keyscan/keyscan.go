@@ -0,0 +1,95 @@
+// Package keyscan finds the translation keys the bot's sources use: those
+// passed as string literals to a T(ctx, language, key, ...) or TN(ctx,
+// language, key, count, ...) call in non-test Go files, or to t "key" in a
+// message template.
+package keyscan
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// templateKey matches a literal key looked up in a text template, {{t "key" ...}}
+var templateKey = regexp.MustCompile(`[{(]-?\s*t\s+("[^"]+")`)
+
+// Scan returns the keys used under the given directories, sorted
+func Scan(roots ...string) ([]string, error) {
+	keys := make(map[string]bool)
+	fset := token.NewFileSet()
+
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			if strings.HasSuffix(path, ".tmpl") {
+				return templateKeys(path, keys)
+			}
+			if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+				return nil
+			}
+			file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+			if err != nil {
+				return err
+			}
+			ast.Inspect(file, func(node ast.Node) bool {
+				if key, ok := translationKey(node); ok {
+					keys[key] = true
+				}
+				return true
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	return sorted, nil
+}
+
+// translationKey returns the key of a x.T(ctx, language, "key", ...) or
+// x.TN(ctx, language, "key", count, ...) call
+func translationKey(node ast.Node) (string, bool) {
+	call, ok := node.(*ast.CallExpr)
+	if !ok || len(call.Args) < 3 {
+		return "", false
+	}
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || (selector.Sel.Name != "T" && selector.Sel.Name != "TN") {
+		return "", false
+	}
+	literal, ok := call.Args[2].(*ast.BasicLit)
+	if !ok || literal.Kind != token.STRING {
+		return "", false
+	}
+	key, err := strconv.Unquote(literal.Value)
+	return key, err == nil
+}
+
+// templateKeys adds the literal keys of a message template
+func templateKeys(path string, keys map[string]bool) error {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for _, match := range templateKey.FindAllSubmatch(source, -1) {
+		if key, err := strconv.Unquote(string(match[1])); err == nil {
+			keys[key] = true
+		}
+	}
+	return nil
+}
@@ -1,5 +1,7 @@
 package locales
 
+//go:generate go run gen_keys.go
+
 import (
 	"embed"
 )
@@ -0,0 +1,598 @@
+// Code generated by gen_keys.go; DO NOT EDIT.
+
+package locales
+
+// ReferencedKeys lists the translation keys the code passes to T as literals
+var ReferencedKeys = []string{
+	"activity_tracking_btn_disable",
+	"activity_tracking_btn_enable",
+	"activity_tracking_failed",
+	"addalert_air_btn",
+	"addalert_my_alerts_btn",
+	"addalert_pollen_btn",
+	"addalert_rain_btn",
+	"addalert_temp_btn",
+	"addalert_text",
+	"addalert_tide_btn",
+	"addalert_wind_btn",
+	"admin_broadcast_draft_expired",
+	"admin_broadcast_failed_get_users",
+	"admin_broadcast_insufficient_permissions",
+	"admin_broadcast_starting",
+	"admin_broadcast_usage",
+	"admin_detailed_stats_alerts_configured",
+	"admin_detailed_stats_avg_response_time",
+	"admin_detailed_stats_cache_hit_rate",
+	"admin_detailed_stats_locations_count",
+	"admin_detailed_stats_locations_section",
+	"admin_detailed_stats_messages_sent",
+	"admin_detailed_stats_performance_section",
+	"admin_detailed_stats_subscriptions_active",
+	"admin_detailed_stats_subscriptions_section",
+	"admin_detailed_stats_title",
+	"admin_detailed_stats_uptime",
+	"admin_detailed_stats_users_active",
+	"admin_detailed_stats_users_new",
+	"admin_detailed_stats_users_section",
+	"admin_detailed_stats_users_seen",
+	"admin_detailed_stats_users_total",
+	"admin_detailed_stats_weather_requests",
+	"admin_recent_activity_alerts",
+	"admin_recent_activity_locations",
+	"admin_recent_activity_messages",
+	"admin_recent_activity_new_users",
+	"admin_recent_activity_title",
+	"admin_recent_activity_total_active",
+	"admin_recent_activity_total_users",
+	"admin_recent_activity_weather_requests",
+	"admin_roles_demote_btn",
+	"admin_roles_overview_admins",
+	"admin_roles_overview_moderators",
+	"admin_roles_overview_title",
+	"admin_roles_overview_total",
+	"admin_roles_overview_users",
+	"admin_roles_promote_btn",
+	"admin_stats_active_subscriptions",
+	"admin_stats_active_users",
+	"admin_stats_alerts_configured",
+	"admin_stats_api_section",
+	"admin_stats_avg_response_time",
+	"admin_stats_cache_hit_rate",
+	"admin_stats_deactivated_users",
+	"admin_stats_messages_sent",
+	"admin_stats_new_users",
+	"admin_stats_notifications_section",
+	"admin_stats_onboarding",
+	"admin_stats_performance_section",
+	"admin_stats_title",
+	"admin_stats_total_users",
+	"admin_stats_uptime",
+	"admin_stats_users_section",
+	"admin_stats_users_seen",
+	"admin_stats_users_with_location",
+	"admin_stats_weather_requests",
+	"admin_users_detailed_stats_btn",
+	"admin_users_recent_btn",
+	"admin_users_roles_btn",
+	"air_location_needed",
+	"air_quality_co",
+	"air_quality_health_recommendations",
+	"air_quality_no2",
+	"air_quality_o3",
+	"air_quality_overall_aqi",
+	"air_quality_pm10",
+	"air_quality_pm25",
+	"air_quality_pollutants",
+	"air_quality_title",
+	"air_quality_updated",
+	"air_sensitive_groups",
+	"air_sensitive_title",
+	"alert_acknowledged_btn",
+	"alert_already_exists",
+	"alert_already_exists_btn",
+	"alert_custom_cancel_btn",
+	"alert_custom_cancelled",
+	"alert_custom_created",
+	"alert_custom_expired",
+	"alert_custom_not_number",
+	"alert_custom_out_of_range",
+	"alert_custom_prompt",
+	"alert_custom_restart_btn",
+	"alert_escalation_btn_disable",
+	"alert_escalation_btn_enable",
+	"alert_escalation_followup",
+	"alert_limit_reached_btn",
+	"alert_notify_immediately_btn",
+	"alert_notify_immediately_enabled",
+	"alert_notify_immediately_failed",
+	"alert_type_air_quality",
+	"alert_type_humidity",
+	"alert_type_pollen",
+	"alert_type_pressure",
+	"alert_type_rain",
+	"alert_type_snow",
+	"alert_type_storm",
+	"alert_type_temperature",
+	"alert_type_tide",
+	"alert_type_unknown",
+	"alert_type_uv_index",
+	"alert_type_wind_speed",
+	"alerts_add_new_btn",
+	"alerts_back",
+	"alerts_back_to_list",
+	"alerts_change_operator",
+	"alerts_cooldown_btn",
+	"alerts_cooldown_hours",
+	"alerts_cooldown_minutes",
+	"alerts_cooldown_quiet_until",
+	"alerts_cooldown_title",
+	"alerts_cooldown_updated",
+	"alerts_create_btn",
+	"alerts_delete_failed",
+	"alerts_delete_success",
+	"alerts_edit_btn",
+	"alerts_edit_current",
+	"alerts_edit_instruction",
+	"alerts_edit_title",
+	"alerts_fetch_failed",
+	"alerts_history_empty",
+	"alerts_history_title",
+	"alerts_holidays_disabled",
+	"alerts_holidays_enabled",
+	"alerts_holidays_no_calendar",
+	"alerts_invalid_id",
+	"alerts_last_checked",
+	"alerts_list_title",
+	"alerts_none",
+	"alerts_operator_title",
+	"alerts_operator_update_success",
+	"alerts_parse_error",
+	"alerts_rearm_btn",
+	"alerts_rearmed",
+	"alerts_remove_btn",
+	"alerts_status_active",
+	"alerts_status_inactive",
+	"alerts_toggle",
+	"alerts_toggle_failed",
+	"alerts_update_failed",
+	"alerts_update_success",
+	"apikey_settings_current",
+	"apikey_settings_none",
+	"apikey_unavailable",
+	"aqiforecast_period",
+	"aqiforecast_subtitle",
+	"aqiforecast_title",
+	"aqiforecast_unavailable",
+	"aqiforecast_worst",
+	"bilingual_weather_summary",
+	"button_add_alert",
+	"button_air_quality",
+	"button_api_key",
+	"button_apikey_remove",
+	"button_aqi_forecast",
+	"button_astro_today",
+	"button_back_to_notifications",
+	"button_back_to_settings",
+	"button_back_to_start",
+	"button_current_weather",
+	"button_data_export",
+	"button_forecast",
+	"button_get_weather",
+	"button_hourly_earlier",
+	"button_hourly_later",
+	"button_language",
+	"button_notifications",
+	"button_recommendations_off",
+	"button_recommendations_on",
+	"button_refresh",
+	"button_secondary_language",
+	"button_set_air_alert",
+	"button_set_alert",
+	"button_set_location",
+	"button_settings",
+	"button_skin_type",
+	"button_timezone",
+	"button_units",
+	"button_week_start",
+	"callback_label_expired",
+	"chat_unwritable_notice",
+	"chat_unwritable_unknown_group",
+	"compare_forecast_btn",
+	"compare_legend",
+	"compare_title",
+	"compare_usage",
+	"data_age_days",
+	"data_age_hours",
+	"data_age_minutes",
+	"date_format_day_month",
+	"date_format_weekday_day_month",
+	"deactivate_failed",
+	"digest_suggestion_accept_btn",
+	"digest_suggestion_accepted",
+	"digest_suggestion_decline_btn",
+	"digest_suggestion_declined",
+	"digest_suggestion_failed",
+	"error_alert_create_failed",
+	"error_coordinate_format",
+	"error_latitude_invalid",
+	"error_latitude_range",
+	"error_longitude_invalid",
+	"error_longitude_range",
+	"error_timezone_invalid",
+	"error_timezone_invalid_simple",
+	"error_weather_location_failed",
+	"export_action",
+	"export_actor_id",
+	"export_alert_configurations",
+	"export_alert_type",
+	"export_aqi",
+	"export_audit_log",
+	"export_condition",
+	"export_coordinates",
+	"export_covered_interval",
+	"export_created_at",
+	"export_data_export_header",
+	"export_description",
+	"export_details",
+	"export_exported_at",
+	"export_failed",
+	"export_frequency",
+	"export_from_cache",
+	"export_humidity",
+	"export_incremental_btn",
+	"export_incremental_note",
+	"export_ip",
+	"export_is_active",
+	"export_is_resolved",
+	"export_language",
+	"export_location",
+	"export_name",
+	"export_pressure",
+	"export_range_all",
+	"export_range_all_btn",
+	"export_range_cancel_btn",
+	"export_range_cancelled",
+	"export_range_custom_btn",
+	"export_range_custom_prompt",
+	"export_range_days",
+	"export_range_expired",
+	"export_range_invalid_format",
+	"export_range_invalid_order",
+	"export_range_restart_btn",
+	"export_range_selected",
+	"export_range_title",
+	"export_range_too_long",
+	"export_severity",
+	"export_source_timestamp",
+	"export_status",
+	"export_stored_location",
+	"export_subscriptions",
+	"export_target_id",
+	"export_temperature",
+	"export_threshold",
+	"export_time_of_day",
+	"export_timestamp",
+	"export_timezone",
+	"export_title",
+	"export_triggered_alerts",
+	"export_type",
+	"export_type_label",
+	"export_units",
+	"export_user_id",
+	"export_user_information",
+	"export_username",
+	"export_uv_index",
+	"export_value",
+	"export_visibility",
+	"export_weather_data",
+	"export_wind_degree",
+	"export_wind_speed",
+	"feedback_cancel_btn",
+	"feedback_cancelled",
+	"feedback_failed",
+	"feedback_prompt",
+	"feedback_reply_header",
+	"feedback_thanks",
+	"feedback_too_long",
+	"forecast_humidity",
+	"forecast_location_needed",
+	"forecast_title",
+	"forecast_wind",
+	"frequency_daily",
+	"frequency_every_3_hours",
+	"frequency_every_6_hours",
+	"frequency_hourly",
+	"frequency_unknown",
+	"frequency_weekly",
+	"group_location_set",
+	"health_good",
+	"health_hazardous",
+	"health_moderate",
+	"health_unhealthy",
+	"health_unhealthy_sensitive",
+	"health_very_unhealthy",
+	"help_addalert",
+	"help_admin_commands",
+	"help_air",
+	"help_alerts",
+	"help_basic_commands",
+	"help_broadcast",
+	"help_compare",
+	"help_data_export",
+	"help_export_alerts",
+	"help_export_complete",
+	"help_export_features",
+	"help_export_subscriptions",
+	"help_export_weather",
+	"help_forecast",
+	"help_history",
+	"help_hourly",
+	"help_location_management",
+	"help_locations",
+	"help_map",
+	"help_notifications",
+	"help_pro_tips",
+	"help_quietdays",
+	"help_radar",
+	"help_removealert",
+	"help_search",
+	"help_setlocation",
+	"help_settings",
+	"help_settings_desc",
+	"help_stats",
+	"help_subscribe",
+	"help_subscriptions",
+	"help_support",
+	"help_tip_alerts",
+	"help_tip_export",
+	"help_tip_location",
+	"help_tip_separation",
+	"help_tip_timezone",
+	"help_title",
+	"help_today",
+	"help_transfer",
+	"help_unpin",
+	"help_unsubscribe",
+	"help_users",
+	"help_view_alerts",
+	"help_weather",
+	"history_empty",
+	"history_error",
+	"history_legend",
+	"history_location_needed",
+	"history_summary",
+	"history_summary_conditions",
+	"history_title",
+	"hourly_location_needed",
+	"hourly_page",
+	"hourly_slot",
+	"hourly_title",
+	"inline_air_title",
+	"inline_forecast_title",
+	"inline_set_location",
+	"language_current",
+	"language_select",
+	"language_set_error",
+	"language_set_success",
+	"listlocations_no_location",
+	"listlocations_set_location_btn",
+	"live_aqi_changed",
+	"live_follow_btn",
+	"live_follow_ended",
+	"live_follow_started",
+	"live_follow_stop_btn",
+	"live_follow_stopped",
+	"live_location_offer",
+	"live_weather_changed",
+	"location_approximate_suffix",
+	"location_clear_failed",
+	"location_clear_success",
+	"location_confirm_change",
+	"location_confirm_no_ignore",
+	"location_confirm_no_keep",
+	"location_confirm_set",
+	"location_confirm_yes_change",
+	"location_confirm_yes_set",
+	"location_input_coords_prompt",
+	"location_input_name_prompt",
+	"location_nearby_btn",
+	"location_nearby_header",
+	"location_nearby_none",
+	"location_privacy_btn_city",
+	"location_privacy_btn_precise",
+	"location_privacy_city",
+	"location_privacy_failed",
+	"location_privacy_label",
+	"location_privacy_precise",
+	"location_required_notifications",
+	"location_required_setlocation",
+	"location_save_as_btn",
+	"location_save_success_with_coords",
+	"location_saved_approximate",
+	"location_settings_btn_back",
+	"location_settings_btn_clear",
+	"location_settings_btn_set_coords",
+	"location_settings_btn_set_name",
+	"location_settings_current",
+	"location_settings_is_set",
+	"location_settings_not_set_help",
+	"location_settings_option_clear",
+	"location_settings_option_gps",
+	"location_settings_option_name",
+	"location_settings_options",
+	"location_settings_title",
+	"location_suggestions_header",
+	"locations_add_another_btn",
+	"locations_add_btn",
+	"locations_add_or_replace",
+	"locations_default_btn",
+	"locations_delete_btn",
+	"locations_failed",
+	"locations_hint",
+	"locations_pick",
+	"locations_replace_btn",
+	"locations_title",
+	"map_choose_layer",
+	"map_title",
+	"next_hour_title",
+	"notification_preference_failed",
+	"onboarding_daily_btn",
+	"onboarding_daily_prompt",
+	"onboarding_location_not_found",
+	"onboarding_location_prompt",
+	"onboarding_location_saved",
+	"onboarding_share_location_btn",
+	"onboarding_skip_btn",
+	"onboarding_welcome",
+	"place_candidate_from_you",
+	"place_candidate_relative",
+	"pollen_forecast_title",
+	"pollen_health_high",
+	"pollen_health_low",
+	"pollen_health_moderate",
+	"pollen_health_none",
+	"pollen_health_very_high",
+	"pollen_health_very_low",
+	"pollen_no_data",
+	"pollen_overall",
+	"pollen_reading",
+	"pollen_title",
+	"preset_apply_btn",
+	"preset_cancel_btn",
+	"quiet_digests_btn_disable",
+	"quiet_digests_btn_enable",
+	"radar_dry",
+	"radar_precipitation",
+	"radar_title",
+	"rate_limit_exceeded",
+	"reactivate_alerts_trimmed",
+	"reactivate_btn",
+	"recommendations_update_failed",
+	"role_admin",
+	"role_moderator",
+	"role_user",
+	"search_no_results",
+	"search_title",
+	"search_usage",
+	"secondary_language_current",
+	"secondary_language_disabled",
+	"secondary_language_enabled",
+	"secondary_language_invalid",
+	"secondary_language_off_btn",
+	"secondary_language_off_label",
+	"secondary_language_select",
+	"secondary_language_update_failed",
+	"setlocation_not_found",
+	"setlocation_save_failed",
+	"setlocation_saved_title",
+	"setlocation_success",
+	"setlocation_switch_btn",
+	"settings_available",
+	"settings_current_config",
+	"settings_data_export",
+	"settings_lang_prefs",
+	"settings_language",
+	"settings_location",
+	"settings_location_mgmt",
+	"settings_not_set",
+	"settings_notif_prefs",
+	"settings_role",
+	"settings_status",
+	"settings_timezone",
+	"settings_timezone_settings",
+	"settings_title",
+	"settings_unit_system",
+	"settings_units",
+	"setup_alerts_btn",
+	"setup_alerts_done",
+	"setup_alerts_none",
+	"setup_complete_btn",
+	"setup_complete_done",
+	"setup_retry_btn",
+	"share_button_open",
+	"skin_type_select",
+	"skin_type_update_failed",
+	"skin_type_updated",
+	"status_active",
+	"status_inactive",
+	"subscribe_air_btn",
+	"subscribe_alerts_btn",
+	"subscribe_daily_btn",
+	"subscribe_my_subs_btn",
+	"subscribe_text",
+	"subscribe_weekly_btn",
+	"subscription_choose_time",
+	"subscription_daily_created",
+	"subscription_edit_failed",
+	"subscription_group_delivery",
+	"subscription_invalid_time",
+	"subscription_type_alerts",
+	"subscription_type_daily",
+	"subscription_type_extreme",
+	"subscription_type_sunrise",
+	"subscription_type_unknown",
+	"subscription_type_weekly",
+	"subscription_weekly_created",
+	"subscriptions_list_title",
+	"sun_alert_btn",
+	"sun_alert_failed",
+	"sun_alert_set",
+	"timezone_confirm_change",
+	"timezone_confirm_no_ignore",
+	"timezone_confirm_no_keep",
+	"timezone_confirm_set",
+	"timezone_confirm_yes_change",
+	"timezone_confirm_yes_set",
+	"timezone_input_prompt",
+	"timezone_update_failed",
+	"timezone_update_success",
+	"unauthorized",
+	"units_choose_prompt",
+	"units_imperial",
+	"units_metric",
+	"units_update_failed",
+	"units_update_success",
+	"unusual_notes_btn_disable",
+	"unusual_notes_btn_enable",
+	"uv_details_index",
+	"uv_details_title",
+	"uvindex_estimated",
+	"uvindex_hourly_dark",
+	"uvindex_hourly_title",
+	"uvindex_safe_exposure",
+	"uvindex_safe_exposure_unlimited",
+	"uvindex_spf",
+	"uvindex_spf_none",
+	"version_check_btn",
+	"version_check_failed",
+	"version_up_to_date",
+	"version_update_available",
+	"weather_air_quality",
+	"weather_aqi",
+	"weather_current_format",
+	"weather_error",
+	"weather_feels_like",
+	"weather_humidity",
+	"weather_moon",
+	"weather_pressure",
+	"weather_stale_data",
+	"weather_sun",
+	"weather_temperature",
+	"weather_updated",
+	"weather_uv_index",
+	"weather_visibility",
+	"weather_wind",
+	"weatherhere_usage",
+	"week_start_auto_btn",
+	"week_start_auto_label",
+	"week_start_current",
+	"week_start_select",
+	"week_start_update_failed",
+	"week_start_updated",
+	"weekly_digest_week",
+	"weekly_digest_week_iso",
+	"welcome_message",
+	"widget_last_updated",
+	"widget_unpin_btn",
+	"widget_weather",
+}
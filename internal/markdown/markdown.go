@@ -0,0 +1,25 @@
+// Package markdown prepares text for Telegram's legacy Markdown parse mode,
+// which the bot's messages use
+package markdown
+
+import "strings"
+
+// escaper escapes the characters that legacy Markdown treats as entity
+// delimiters
+var escaper = strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[")
+
+// Escape makes text from users or providers, such as a place name, safe to
+// put in a Markdown message. Unescaped, a name like "Las Vegas_NV" opens an
+// entity that never closes and Telegram rejects the whole message.
+func Escape(text string) string {
+	return escaper.Replace(text)
+}
+
+// EscapeBold is Escape for text placed between the asterisks of a bold
+// entity. Legacy Markdown has no escapes inside an entity: everything up to
+// the next asterisk is shown as is, backslashes included. So only an asterisk
+// needs care, and it is written by closing the entity, escaping it and
+// opening the entity again.
+func EscapeBold(text string) string {
+	return strings.ReplaceAll(text, "*", "*\\**")
+}
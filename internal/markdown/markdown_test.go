@@ -0,0 +1,21 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscape(t *testing.T) {
+	assert.Equal(t, "Kyiv", Escape("Kyiv"))
+	assert.Equal(t, `my\_place \*home\* \[1]`, Escape("my_place *home* [1]"))
+	assert.Equal(t, "\\`x\\`", Escape("`x`"))
+	assert.Equal(t, `Las Vegas\_NV`, Escape("Las Vegas_NV"))
+	assert.Equal(t, "São Paulo", Escape("São Paulo"))
+}
+
+func TestEscapeBold(t *testing.T) {
+	assert.Equal(t, "Las Vegas_NV", EscapeBold("Las Vegas_NV"))
+	assert.Equal(t, `A*\**B`, EscapeBold("A*B"))
+	assert.Equal(t, "x`[1]`", EscapeBold("x`[1]`"))
+}
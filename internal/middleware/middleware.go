@@ -2,16 +2,19 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/PaulSonOfLars/gotgbot/v2"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
 	"github.com/rs/zerolog"
 	"golang.org/x/time/rate"
 
 	"github.com/valpere/shopogoda/internal/services"
+	"github.com/valpere/shopogoda/pkg/metrics"
 )
 
 // UserRateLimiter manages rate limits per user with graceful shutdown support
@@ -108,19 +111,26 @@ func Logging(logger zerolog.Logger) func(bot *gotgbot.Bot, ctx *ext.Context) err
 		start := time.Now()
 
 		user := ctx.EffectiveUser
-		chat := ctx.EffectiveChat
+
+		// Inline queries come from no chat
+		var chatID int64
+		if ctx.EffectiveChat != nil {
+			chatID = ctx.EffectiveChat.Id
+		}
 
 		var command string
 		if ctx.Message != nil && ctx.Message.Text != "" {
 			command = ctx.Message.Text
 		} else if ctx.CallbackQuery != nil {
 			command = fmt.Sprintf("callback:%s", ctx.CallbackQuery.Data)
+		} else if ctx.InlineQuery != nil {
+			command = fmt.Sprintf("inline:%s", ctx.InlineQuery.Query)
 		}
 
 		logger.Info().
 			Int64("user_id", user.Id).
 			Str("username", user.Username).
-			Int64("chat_id", chat.Id).
+			Int64("chat_id", chatID).
 			Str("command", command).
 			Dur("duration", time.Since(start)).
 			Msg("Request processed")
@@ -129,14 +139,23 @@ func Logging(logger zerolog.Logger) func(bot *gotgbot.Bot, ctx *ext.Context) err
 	}
 }
 
-// RateLimit creates a rate limiting handler function.
+// RateLimit creates a rate limiting handler function. Limited users get the
+// quota warning template through the messaging service, when one is given.
 // Returns ext.EndGroups when the user is rate-limited (stops all further processing).
-func RateLimit(rateLimiter *UserRateLimiter) func(bot *gotgbot.Bot, ctx *ext.Context) error {
+func RateLimit(rateLimiter *UserRateLimiter, messaging *services.MessagingService) func(bot *gotgbot.Bot, ctx *ext.Context) error {
 	return func(bot *gotgbot.Bot, ctx *ext.Context) error {
 		userID := ctx.EffectiveUser.Id
 
 		if !rateLimiter.Allow(userID) {
-			_, _ = ctx.EffectiveMessage.Reply(bot, "Rate limit exceeded. Please try again later.", nil)
+			if messaging != nil && ctx.EffectiveChat != nil {
+				var options services.SendOptions
+				if ctx.EffectiveMessage != nil {
+					options.ReplyTo = ctx.EffectiveMessage.MessageId
+				}
+				_ = messaging.SendToChat(context.Background(), ctx.EffectiveChat.Id, userID, services.TemplateQuotaWarning, nil, options)
+			} else if ctx.EffectiveMessage != nil {
+				_, _ = ctx.EffectiveMessage.Reply(bot, "Rate limit exceeded. Please try again later.", nil)
+			}
 			return ext.EndGroups
 		}
 
@@ -173,9 +192,10 @@ func Auth(userService *services.UserService) func(bot *gotgbot.Bot, ctx *ext.Con
 	return func(bot *gotgbot.Bot, ctx *ext.Context) error {
 		user := ctx.EffectiveUser
 
-		// Register or update user - need to create a proper context
+		// Register or update user; a user who keeps writing is written to
+		// the database once every few minutes
 		bgCtx := context.Background()
-		err := userService.RegisterUser(bgCtx, user)
+		err := userService.RegisterActiveUser(bgCtx, user)
 		if err != nil {
 			return fmt.Errorf("failed to register user: %w", err)
 		}
@@ -184,11 +204,151 @@ func Auth(userService *services.UserService) func(bot *gotgbot.Bot, ctx *ext.Con
 	}
 }
 
-// Metrics creates a metrics collection handler function for basic tracking
-func Metrics() func(bot *gotgbot.Bot, ctx *ext.Context) error {
+// ActivityTracking records when users interact with the bot for digest send-time
+// suggestions. Tracking failures never block the update.
+func ActivityTracking(userService *services.UserService, engagement *services.EngagementService, logger zerolog.Logger) func(bot *gotgbot.Bot, ctx *ext.Context) error {
+	return func(bot *gotgbot.Bot, ctx *ext.Context) error {
+		if ctx.EffectiveUser == nil || (ctx.Message == nil && ctx.CallbackQuery == nil) {
+			return nil
+		}
+
+		// Fetching through the update memoizes the user for the handlers
+		bgCtx := context.Background()
+		user, err := userService.GetUpdateUser(bgCtx, ctx, ctx.EffectiveUser.Id)
+		if err != nil {
+			return nil
+		}
+
+		if err := engagement.RecordInteraction(bgCtx, user, time.Now()); err != nil {
+			logger.Warn().Err(err).Int64("user_id", user.ID).Msg("Failed to record user activity")
+		}
+
+		return nil
+	}
+}
+
+// AlertAcknowledgment takes a reply to one of the bot's extreme alerts as its
+// acknowledgment, so no follow-up is sent. Failures never block the update.
+func AlertAcknowledgment(escalations *services.AlertEscalationService, logger zerolog.Logger) func(bot *gotgbot.Bot, ctx *ext.Context) error {
+	return func(bot *gotgbot.Bot, ctx *ext.Context) error {
+		msg := ctx.Message
+		if msg == nil || msg.ReplyToMessage == nil || msg.ReplyToMessage.From == nil || msg.ReplyToMessage.From.Id != bot.Id {
+			return nil
+		}
+
+		if _, err := escalations.Acknowledge(context.Background(), msg.Chat.Id, msg.ReplyToMessage.MessageId); err != nil {
+			logger.Warn().Err(err).Int64("chat_id", msg.Chat.Id).Msg("Failed to acknowledge alert by reply")
+		}
+
+		return nil
+	}
+}
+
+// ChatAccess keeps the chat access registry current. It remembers who last used
+// a group, and when an update arrives from a chat where the bot could not post,
+// probes whether it can post there again. Registry failures never block the update.
+func ChatAccess(access *services.ChatAccessService) func(bot *gotgbot.Bot, ctx *ext.Context) error {
+	return func(bot *gotgbot.Bot, ctx *ext.Context) error {
+		chat := ctx.EffectiveChat
+		if chat == nil {
+			return nil
+		}
+
+		bgCtx := context.Background()
+		if chat.Type != "private" && ctx.EffectiveUser != nil {
+			access.Observe(bgCtx, chat.Id, chat.Title, ctx.EffectiveUser.Id)
+		}
+
+		state, err := access.State(bgCtx, chat.Id)
+		if err != nil || !state.Unwritable() {
+			return nil
+		}
+
+		access.Probe(bgCtx, bot, chat.Id)
+		return nil
+	}
+}
+
+// UserFetchMetrics wraps the dispatcher's processor to record how many times
+// the sender of each update was fetched while it was handled
+type UserFetchMetrics struct {
+	ext.BaseProcessor
+	Metrics *metrics.Metrics
+}
+
+func (p UserFetchMetrics) ProcessUpdate(d *ext.Dispatcher, b *gotgbot.Bot, ctx *ext.Context) error {
+	err := p.BaseProcessor.ProcessUpdate(d, b, ctx)
+	if ctx.EffectiveUser != nil {
+		p.Metrics.ObserveHistogram("bot_user_fetches_per_update", float64(services.UpdateUserFetches(ctx)))
+	}
+	return err
+}
+
+// ErrorType classifies an error returned by a handler for the bot_errors_total
+// counter: "telegram" for Telegram API errors, "weather" for failed weather
+// fetches, "timeout" and otherwise "internal"
+func ErrorType(err error) string {
+	var telegramErr *gotgbot.TelegramError
+	switch {
+	case errors.As(err, &telegramErr):
+		return "telegram"
+	case services.WeatherErrorClass(err) != services.WeatherErrorOther:
+		return "weather"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "internal"
+	}
+}
+
+// ErrorMetrics returns a dispatcher error handler that logs and counts the
+// errors returned by handlers, then carries on with the next handler group
+func ErrorMetrics(metricsCollector *metrics.Metrics, logger zerolog.Logger) ext.DispatcherErrorHandler {
+	return func(bot *gotgbot.Bot, ctx *ext.Context, err error) ext.DispatcherAction {
+		errorType := ErrorType(err)
+		metricsCollector.IncrementCounter("bot_errors_total", errorType)
+		logger.Error().Err(err).Str("error_type", errorType).Msg("Handler failed")
+		return ext.DispatcherActionNoop
+	}
+}
+
+// Metrics creates a handler function that counts the updates the bot
+// receives in bot_updates_total by UpdateType
+func Metrics(metricsCollector *metrics.Metrics) func(bot *gotgbot.Bot, ctx *ext.Context) error {
 	return func(bot *gotgbot.Bot, ctx *ext.Context) error {
-		// Basic metrics tracking - would integrate with actual metrics system
-		// For now, just a placeholder that does nothing
+		metricsCollector.IncrementCounter("bot_updates_total", UpdateType(ctx))
 		return nil
 	}
 }
+
+// UpdateType classifies an update for the bot_updates_total counter:
+// "message", "edited_message", "callback_query", "inline_query" and
+// otherwise "other"
+func UpdateType(ctx *ext.Context) string {
+	switch {
+	case ctx.Update == nil:
+		return "other"
+	case ctx.Message != nil:
+		return "message"
+	case ctx.EditedMessage != nil:
+		return "edited_message"
+	case ctx.CallbackQuery != nil:
+		return "callback_query"
+	case ctx.InlineQuery != nil:
+		return "inline_query"
+	default:
+		return "other"
+	}
+}
+
+// Timing wraps a handler to record how long it takes in the
+// bot_handler_duration_seconds histogram under the name of its command, which
+// the average response time of /stats is taken from. Failed runs count too.
+func Timing(metricsCollector *metrics.Metrics, command string, response handlers.Response) handlers.Response {
+	return func(bot *gotgbot.Bot, ctx *ext.Context) error {
+		start := time.Now()
+		err := response(bot, ctx)
+		metricsCollector.ObserveHistogram("bot_handler_duration_seconds", time.Since(start).Seconds(), command)
+		return err
+	}
+}
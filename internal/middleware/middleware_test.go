@@ -1,6 +1,10 @@
 package middleware
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -12,6 +16,7 @@ import (
 
 	"github.com/valpere/shopogoda/internal/services"
 	"github.com/valpere/shopogoda/pkg/metrics"
+	"github.com/valpere/shopogoda/pkg/weather"
 	"github.com/valpere/shopogoda/tests/helpers"
 )
 
@@ -180,11 +185,24 @@ func TestLogging(t *testing.T) {
 		err := handler(bot, ctx)
 		assert.NoError(t, err)
 	})
+
+	t.Run("logs inline query without a chat", func(t *testing.T) {
+		bot := &gotgbot.Bot{}
+		ctx := &ext.Context{
+			Update: &gotgbot.Update{
+				InlineQuery: &gotgbot.InlineQuery{Query: "Kyiv"},
+			},
+			EffectiveUser: &gotgbot.User{Id: 123, Username: "testuser"},
+		}
+
+		err := handler(bot, ctx)
+		assert.NoError(t, err)
+	})
 }
 
 func TestRateLimitMiddleware(t *testing.T) {
 	limiter := NewUserRateLimiter(rate.Limit(1), 1)
-	handler := RateLimit(limiter)
+	handler := RateLimit(limiter, nil)
 
 	bot := &gotgbot.Bot{}
 
@@ -292,10 +310,11 @@ func TestAuthMiddleware(t *testing.T) {
 }
 
 func TestMetricsMiddleware(t *testing.T) {
-	handler := Metrics()
+	metricsCollector, registry := helpers.NewTestMetrics()
+	handler := Metrics(metricsCollector)
+	bot := &gotgbot.Bot{}
 
-	t.Run("executes without error", func(t *testing.T) {
-		bot := &gotgbot.Bot{}
+	t.Run("executes without an update", func(t *testing.T) {
 		ctx := &ext.Context{
 			EffectiveUser: &gotgbot.User{Id: 123},
 		}
@@ -303,4 +322,90 @@ func TestMetricsMiddleware(t *testing.T) {
 		err := handler(bot, ctx)
 		assert.NoError(t, err)
 	})
+
+	t.Run("counts updates by type", func(t *testing.T) {
+		for _, update := range []*gotgbot.Update{
+			{Message: &gotgbot.Message{Text: "/weather"}},
+			{Message: &gotgbot.Message{Text: "Kyiv"}},
+			{CallbackQuery: &gotgbot.CallbackQuery{Data: "weather_refresh"}},
+		} {
+			assert.NoError(t, handler(bot, &ext.Context{Update: update}))
+		}
+
+		series := helpers.ScrapeMetrics(t, registry)
+		assert.Equal(t, 2.0, series[`bot_updates_total{type="message"}`])
+		assert.Equal(t, 1.0, series[`bot_updates_total{type="callback_query"}`])
+		assert.Equal(t, 1.0, series[`bot_updates_total{type="other"}`])
+	})
+}
+
+func TestUpdateType(t *testing.T) {
+	assert.Equal(t, "other", UpdateType(&ext.Context{}))
+	assert.Equal(t, "message", UpdateType(&ext.Context{Update: &gotgbot.Update{Message: &gotgbot.Message{}}}))
+	assert.Equal(t, "edited_message", UpdateType(&ext.Context{Update: &gotgbot.Update{EditedMessage: &gotgbot.Message{}}}))
+	assert.Equal(t, "callback_query", UpdateType(&ext.Context{Update: &gotgbot.Update{CallbackQuery: &gotgbot.CallbackQuery{}}}))
+	assert.Equal(t, "inline_query", UpdateType(&ext.Context{Update: &gotgbot.Update{InlineQuery: &gotgbot.InlineQuery{}}}))
+	assert.Equal(t, "other", UpdateType(&ext.Context{Update: &gotgbot.Update{}}))
+}
+
+func TestTiming(t *testing.T) {
+	metricsCollector, registry := helpers.NewTestMetrics()
+	bot := &gotgbot.Bot{}
+	ctx := &ext.Context{EffectiveUser: &gotgbot.User{Id: 123}}
+
+	weather := Timing(metricsCollector, "weather", func(bot *gotgbot.Bot, ctx *ext.Context) error {
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	})
+	failing := Timing(metricsCollector, "forecast", func(bot *gotgbot.Bot, ctx *ext.Context) error {
+		return errors.New("boom")
+	})
+
+	assert.NoError(t, weather(bot, ctx))
+	assert.NoError(t, weather(bot, ctx))
+	assert.EqualError(t, failing(bot, ctx), "boom")
+
+	series := helpers.ScrapeMetrics(t, registry)
+	assert.Equal(t, 2.0, series[`bot_handler_duration_seconds{command="weather"}`])
+	assert.Equal(t, 1.0, series[`bot_handler_duration_seconds{command="forecast"}`])
+	assert.Greater(t, metricsCollector.GetAverageResponseTime(), 0.0)
+}
+
+func TestErrorType(t *testing.T) {
+	assert.Equal(t, "telegram", ErrorType(fmt.Errorf("send: %w", &gotgbot.TelegramError{Code: 400})))
+	assert.Equal(t, "weather", ErrorType(fmt.Errorf("fetch: %w", weather.ErrUnavailable)))
+	assert.Equal(t, "timeout", ErrorType(context.DeadlineExceeded))
+	assert.Equal(t, "internal", ErrorType(errors.New("boom")))
+}
+
+func TestActivityTracking(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	mockRedis := helpers.NewMockRedis()
+	logger := zerolog.Nop()
+
+	userService := services.NewUserService(mockDB.DB, mockRedis.Client, metrics.New(), &logger, time.Now())
+	engagement := services.NewEngagementService(mockRedis.Client, userService, nil, nil, nil, &logger)
+	middleware := ActivityTracking(userService, engagement, logger)
+
+	user := helpers.MockUser(123)
+	user.ActivityTrackingDisabled = true
+	userJSON, _ := json.Marshal(user)
+	mockRedis.Mock.ExpectGet("user:123").SetVal(string(userJSON))
+
+	update := &ext.Context{
+		EffectiveUser: &gotgbot.User{Id: 123},
+		Update:        &gotgbot.Update{Message: &gotgbot.Message{Text: "/weather"}},
+	}
+	update.Message = update.Update.Message
+
+	assert.NoError(t, middleware(nil, update))
+
+	// The handler's lookup reuses the user fetched by the middleware
+	fetched, err := userService.GetUpdateUser(context.Background(), update, 123)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(123), fetched.ID)
+	assert.Equal(t, 1, services.UpdateUserFetches(update))
+	mockRedis.ExpectationsWereMet(t)
+	mockDB.ExpectationsWereMet(t)
 }
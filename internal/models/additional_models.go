@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+
+	"github.com/valpere/shopogoda/pkg/weather"
 )
 
 // Additional model methods and constants
@@ -33,6 +35,8 @@ func (s SubscriptionType) String() string {
 		return "Alerts"
 	case SubscriptionExtreme:
 		return "Extreme"
+	case SubscriptionSunrise:
+		return "Sunrise"
 	default:
 		return "Unknown"
 	}
@@ -75,6 +79,10 @@ func (a AlertType) String() string {
 		return "Snow"
 	case AlertStorm:
 		return "Storm"
+	case AlertPollen:
+		return "Pollen"
+	case AlertTide:
+		return "Tide"
 	default:
 		return "Unknown"
 	}
@@ -115,6 +123,11 @@ func (u *User) HasLocation() bool {
 	return u.LocationName != ""
 }
 
+// HasLocation reports whether the chat has a location set
+func (c *ChatSettings) HasLocation() bool {
+	return c.LocationName != ""
+}
+
 func (u *User) IsAdmin() bool {
 	return u.Role == RoleAdmin
 }
@@ -124,18 +137,35 @@ func (u *User) IsModerator() bool {
 }
 
 func (w *WeatherData) GetTemperatureString() string {
-	return fmt.Sprintf("%.1f°C", w.Temperature)
+	return weather.FormatTemp(w.Temperature, weather.UnitsMetric)
 }
 
 func (w *WeatherData) IsRecent() bool {
 	return time.Since(w.Timestamp) < time.Hour
 }
 
+// Cooldown returns how long the alert stays quiet after it triggered
+func (a *AlertConfig) Cooldown() time.Duration {
+	if a.CooldownMinutes <= 0 {
+		return DefaultAlertCooldown
+	}
+	return time.Duration(a.CooldownMinutes) * time.Minute
+}
+
+// QuietUntil returns when the cooldown of the last trigger ends, or the zero
+// time when the alert never triggered
+func (a *AlertConfig) QuietUntil() time.Time {
+	if a.LastTriggered == nil {
+		return time.Time{}
+	}
+	return a.LastTriggered.Add(a.Cooldown())
+}
+
 func (a *AlertConfig) IsRecentlyTriggered() bool {
 	if a.LastTriggered == nil {
 		return false
 	}
-	return time.Since(*a.LastTriggered) < time.Hour
+	return time.Since(*a.LastTriggered) < a.Cooldown()
 }
 
 func (ea *EnvironmentalAlert) GetSeverityColor() string {
@@ -154,18 +184,18 @@ func (ea *EnvironmentalAlert) GetSeverityColor() string {
 }
 
 func (ea *EnvironmentalAlert) GetFormattedMessage() string {
-	return fmt.Sprintf("%s %s\n📊 Value: %.1f (Threshold: %.1f)\n%s",
+	return fmt.Sprintf("%s %s\n📊 Value: %s (Threshold: %s)\n%s",
 		ea.GetSeverityColor(),
 		ea.Title,
-		ea.Value,
-		ea.Threshold,
+		weather.FormatDecimal(ea.Value, 1),
+		weather.FormatDecimal(ea.Threshold, 1),
 		ea.Description)
 }
 
 func (ea *EnvironmentalAlert) IsTriggered(value float64) bool {
 	// Simple condition logic for testing - in reality this would be more complex
 	switch ea.AlertType {
-	case AlertTemperature, AlertWindSpeed, AlertUVIndex, AlertAirQuality:
+	case AlertTemperature, AlertWindSpeed, AlertUVIndex, AlertAirQuality, AlertPollen, AlertTide:
 		return value > ea.Threshold
 	case AlertHumidity:
 		// For humidity tests: 65 < 70 should trigger, 75 > 70 should not
@@ -208,7 +238,7 @@ func (s *Subscription) Validate() error {
 	if s.UserID <= 0 {
 		return fmt.Errorf("invalid user ID")
 	}
-	if s.SubscriptionType < 1 || s.SubscriptionType > 4 {
+	if s.SubscriptionType < SubscriptionDaily || s.SubscriptionType > SubscriptionSunrise {
 		return fmt.Errorf("invalid subscription type")
 	}
 	if s.Frequency < 1 || s.Frequency > 5 {
@@ -73,6 +73,11 @@ func TestSubscriptionType_String(t *testing.T) {
 			subType:  SubscriptionExtreme,
 			expected: "Extreme",
 		},
+		{
+			name:     "sunrise subscription",
+			subType:  SubscriptionSunrise,
+			expected: "Sunrise",
+		},
 		{
 			name:     "unknown subscription",
 			subType:  SubscriptionType(999),
@@ -185,6 +190,16 @@ func TestAlertType_String(t *testing.T) {
 			alertType: AlertStorm,
 			expected:  "Storm",
 		},
+		{
+			name:      "pollen alert",
+			alertType: AlertPollen,
+			expected:  "Pollen",
+		},
+		{
+			name:      "tide alert",
+			alertType: AlertTide,
+			expected:  "Tide",
+		},
 		{
 			name:      "unknown alert type",
 			alertType: AlertType(999),
@@ -362,6 +377,8 @@ func TestAlertType_ValidValues(t *testing.T) {
 		AlertRain,
 		AlertSnow,
 		AlertStorm,
+		AlertPollen,
+		AlertTide,
 	}
 
 	// Create a map to track uniqueness
@@ -372,7 +389,7 @@ func TestAlertType_ValidValues(t *testing.T) {
 	}
 
 	// Verify we have all expected alert types
-	assert.Len(t, alertTypes, 9, "Should have exactly 9 alert types defined")
+	assert.Len(t, alertTypes, 11, "Should have exactly 11 alert types defined")
 }
 
 func TestAlertSeverity_ValidValues(t *testing.T) {
@@ -402,6 +419,7 @@ func TestSubscriptionType_ValidValues(t *testing.T) {
 		SubscriptionWeekly,
 		SubscriptionAlerts,
 		SubscriptionExtreme,
+		SubscriptionSunrise,
 	}
 
 	// Create a map to track uniqueness
@@ -412,7 +430,7 @@ func TestSubscriptionType_ValidValues(t *testing.T) {
 	}
 
 	// Verify we have all expected subscription types
-	assert.Len(t, subTypes, 4, "Should have exactly 4 subscription types defined")
+	assert.Len(t, subTypes, 5, "Should have exactly 5 subscription types defined")
 }
 
 func TestFrequency_ValidValues(t *testing.T) {
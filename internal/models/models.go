@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -26,9 +27,61 @@ type User struct {
 	Country      string  `json:"country"`
 	City         string  `json:"city"`
 
+	// Location privacy: city-level mode stores the city centroid instead of exact coordinates
+	LocationCityLevel   bool `gorm:"default:false" json:"location_city_level"`
+	LocationApproximate bool `gorm:"default:false" json:"location_approximate"`
+
+	// Privacy: opt out of the interaction-time tracking behind digest time suggestions
+	ActivityTrackingDisabled bool `gorm:"default:false" json:"activity_tracking_disabled"`
+
+	// Optional second language for weather responses and digests; empty when off
+	SecondaryLanguage string `gorm:"type:varchar(10)" json:"secondary_language,omitempty"`
+
+	// First day of the week: WeekStartMonday or WeekStartSunday, empty to
+	// derive it from the location's country or the language
+	WeekStart string `gorm:"type:varchar(3)" json:"week_start,omitempty"`
+
+	// Notification behavior: deliver scheduled digests without sound, and
+	// opt out of follow-ups for unacknowledged extreme alerts
+	QuietDigests            bool `gorm:"default:false" json:"quiet_digests"`
+	AlertEscalationDisabled bool `gorm:"default:false" json:"alert_escalation_disabled"`
+
+	// Send a standalone note when the weather is unusual for the season; the
+	// daily digest carries the note either way
+	UnusualWeatherNotes bool `gorm:"default:false" json:"unusual_weather_notes"`
+
+	// Fitzpatrick skin type, 1 (always burns) to 6 (never burns), that
+	// /uvindex works out safe sun exposure for
+	SkinType int `gorm:"default:3" json:"skin_type"`
+
+	// Append a clothing or activity tip to current weather messages
+	ShowRecommendations bool `gorm:"default:true" json:"show_recommendations"`
+
+	// The user's own OpenWeather API key, encrypted with USER_API_KEY_SECRET;
+	// empty to use the bot's. Never exported or cached.
+	APIKey string `gorm:"type:text" json:"-"`
+
+	// Last update the user sent the bot, written at most every 10 minutes;
+	// users registered before it was recorded start from their registration
+	LastActiveAt *time.Time `gorm:"index" json:"last_active_at,omitempty"`
+
+	// Set while the user has paused their account with /deactivate; writing
+	// to the bot does not make a paused account active again
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+
+	// Guided setup of new users: when /start first began it, and when it was
+	// finished or skipped
+	OnboardingStartedAt *time.Time `json:"onboarding_started_at,omitempty"`
+	OnboardedAt         *time.Time `json:"onboarded_at,omitempty"`
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 
+	// Set when the user deleted their data; the row is kept, anonymized, so
+	// the ID stays valid for rows that refer to it. Queries leave deleted users
+	// out, and one who writes to the bot again starts over as a new user.
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
 	// Relationships
 	Subscriptions []Subscription `json:"subscriptions,omitempty"`
 	AlertConfigs  []AlertConfig  `json:"alert_configs,omitempty"`
@@ -36,6 +89,12 @@ type User struct {
 
 type UserRole int
 
+// Explicit first day of the week
+const (
+	WeekStartMonday = "mon"
+	WeekStartSunday = "sun"
+)
+
 const (
 	RoleUser UserRole = iota + 1
 	RoleModerator
@@ -64,6 +123,10 @@ type WeatherData struct {
 	Timestamp   time.Time `gorm:"index" json:"timestamp"` // Always UTC
 	CreatedAt   time.Time `json:"created_at"`
 
+	// Data freshness at capture time (nil for rows recorded before these were tracked)
+	SourceTimestamp *time.Time `json:"source_timestamp,omitempty"` // Provider observation time, UTC
+	FromCache       *bool      `json:"from_cache,omitempty"`
+
 	// Relationships
 	User User `json:"user,omitempty"`
 }
@@ -74,8 +137,11 @@ type Subscription struct {
 	UserID           int64            `gorm:"index" json:"user_id"`
 	SubscriptionType SubscriptionType `json:"subscription_type"`
 	Frequency        Frequency        `json:"frequency"`
-	TimeOfDay        string           `json:"time_of_day"` // HH:MM format in user timezone
+	TimeOfDay        string           `json:"time_of_day"` // HH:MM format in user timezone; SunriseTimeOfDay for sunrise subscriptions
 	IsActive         bool             `gorm:"default:true" json:"is_active"`
+	LastSentAt       *time.Time       `json:"last_sent_at,omitempty"` // UTC, last successful Telegram delivery
+	SendWeekday      *int             `json:"send_weekday,omitempty"` // Weekly only, 0 = Sunday; Monday when unset
+	ChatID           int64            `json:"chat_id,omitempty"`      // Group the subscription was created in; zero for the user's private chat
 	CreatedAt        time.Time        `json:"created_at"`
 	UpdatedAt        time.Time        `json:"updated_at"`
 
@@ -83,6 +149,30 @@ type Subscription struct {
 	User User `json:"user,omitempty"`
 }
 
+// DeliveryChatID returns the chat the subscription is delivered to: the group
+// it was created in, or the user's private chat
+func (s *Subscription) DeliveryChatID() int64 {
+	if s.ChatID != 0 {
+		return s.ChatID
+	}
+	return s.UserID
+}
+
+// IsWeekly reports whether the subscription is delivered once a week: the
+// weekly digest, or daily weather switched to weekly
+func (s *Subscription) IsWeekly() bool {
+	return s.SubscriptionType == SubscriptionWeekly || s.Frequency == FrequencyWeekly
+}
+
+// WeeklySendDay returns the weekday a weekly subscription is delivered on.
+// Subscriptions created before the day was stored go out on Mondays.
+func (s *Subscription) WeeklySendDay() time.Weekday {
+	if s.SendWeekday == nil {
+		return time.Monday
+	}
+	return time.Weekday(*s.SendWeekday)
+}
+
 type SubscriptionType int
 
 const (
@@ -90,8 +180,13 @@ const (
 	SubscriptionWeekly
 	SubscriptionAlerts
 	SubscriptionExtreme
+	SubscriptionSunrise
 )
 
+// SunriseTimeOfDay is the time of day of sunrise subscriptions, which follow
+// the sun at the user's location rather than a clock time
+const SunriseTimeOfDay = "sunrise"
+
 type Frequency int
 
 const (
@@ -104,20 +199,32 @@ const (
 
 // AlertConfig represents alert configuration
 type AlertConfig struct {
-	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	UserID        int64      `gorm:"index" json:"user_id"`
-	AlertType     AlertType  `json:"alert_type"`
-	Condition     string     `json:"condition"` // JSON condition
-	Threshold     float64    `json:"threshold"`
-	IsActive      bool       `gorm:"default:true" json:"is_active"`
-	LastTriggered *time.Time `json:"last_triggered,omitempty"` // UTC
-	CreatedAt     time.Time  `json:"created_at"`
-	UpdatedAt     time.Time  `json:"updated_at"`
+	ID                 uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID             int64      `gorm:"index" json:"user_id"`
+	AlertType          AlertType  `json:"alert_type"`
+	Condition          string     `json:"condition"` // JSON condition
+	Threshold          float64    `json:"threshold"`
+	IsActive           bool       `gorm:"default:true" json:"is_active"`
+	SuppressOnHolidays bool       `json:"suppress_on_holidays"`                // Record routine triggers on public holidays without sending them
+	LastTriggered      *time.Time `json:"last_triggered,omitempty"`            // UTC; no new trigger within the cooldown of it
+	LastCheckedAt      *time.Time `json:"last_checked_at,omitempty"`           // UTC, when the alert was last evaluated
+	LastValue          *float64   `json:"last_value,omitempty"`                // Value seen at LastCheckedAt, metric
+	CooldownMinutes    int        `gorm:"default:120" json:"cooldown_minutes"` // Quiet period after a trigger; DefaultAlertCooldown when zero
+	RearmPending       bool       `json:"rearm_pending"`                       // Triggered; waits for the value to clear the threshold by the hysteresis margin
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
 
 	// Relationships
 	User User `json:"user,omitempty"`
 }
 
+// DefaultAlertCooldown is how long an alert stays quiet after it triggered,
+// unless the user picked another cooldown
+const DefaultAlertCooldown = 2 * time.Hour
+
+// AlertCooldownOptions are the cooldowns a user can pick for an alert
+var AlertCooldownOptions = []time.Duration{30 * time.Minute, time.Hour, 2 * time.Hour, 6 * time.Hour, 24 * time.Hour}
+
 type AlertType int
 
 const (
@@ -130,27 +237,38 @@ const (
 	AlertRain
 	AlertSnow
 	AlertStorm
+	AlertPollen // Highest pollen risk level, 0 to 5
+	AlertTide   // Highest high tide of the next 24 hours, metres above mean sea level
 )
 
 // EnvironmentalAlert represents triggered alerts
 type EnvironmentalAlert struct {
-	ID          uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	UserID      int64      `gorm:"index" json:"user_id"`
-	AlertType   AlertType  `json:"alert_type"`
-	Severity    Severity   `json:"severity"`
-	Title       string     `json:"title"`
-	Description string     `json:"description"`
-	Value       float64    `json:"value"`
-	Threshold   float64    `json:"threshold"`
-	IsResolved  bool       `gorm:"default:false" json:"is_resolved"`
-	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`   // UTC
-	CreatedAt   time.Time  `gorm:"index" json:"created_at"` // UTC
-	UpdatedAt   time.Time  `json:"updated_at"`              // UTC
+	ID          uuid.UUID         `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID      int64             `gorm:"index" json:"user_id"`
+	AlertType   AlertType         `json:"alert_type"`
+	Severity    Severity          `json:"severity"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Value       float64           `json:"value"`
+	Threshold   float64           `json:"threshold"`
+	IsResolved  bool              `gorm:"default:false" json:"is_resolved"`
+	ResolvedAt  *time.Time        `json:"resolved_at,omitempty"`                        // UTC
+	Suppressed  SuppressionReason `gorm:"type:varchar(16)" json:"suppressed,omitempty"` // Set when the trigger was recorded without being sent
+	CreatedAt   time.Time         `gorm:"index" json:"created_at"`                      // UTC
+	UpdatedAt   time.Time         `json:"updated_at"`                                   // UTC
 
 	// Relationships
 	User User `json:"user,omitempty"`
 }
 
+// SuppressionReason says why a triggered alert was not sent
+type SuppressionReason string
+
+const (
+	SuppressedHoliday  SuppressionReason = "holiday"   // Public holiday in the user's country
+	SuppressedQuietDay SuppressionReason = "quiet_day" // One of the user's quiet days
+)
+
 type Severity int
 
 const (
@@ -170,14 +288,322 @@ type UserSession struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// ExportCursor tracks the last successful export per user and data type,
+// used as the lower bound of the next incremental export
+type ExportCursor struct {
+	UserID         int64     `gorm:"primaryKey" json:"user_id"`
+	ExportType     string    `gorm:"primaryKey" json:"export_type"`
+	LastExportedAt time.Time `json:"last_exported_at"` // UTC, inclusive upper bound of the last export
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// WeatherWidget is a pinned message in a private chat that the bot keeps
+// updated with current weather. The chat ID is the key: one widget per chat.
+type WeatherWidget struct {
+	ChatID          int64      `gorm:"primaryKey;autoIncrement:false" json:"chat_id"`
+	UserID          int64      `gorm:"index" json:"user_id"`
+	MessageID       int64      `json:"message_id"`
+	IntervalHours   int        `gorm:"default:3" json:"interval_hours"`
+	WindowStart     string     `gorm:"type:varchar(5);default:'06:00'" json:"window_start"` // HH:MM in user timezone
+	WindowEnd       string     `gorm:"type:varchar(5);default:'22:00'" json:"window_end"`   // HH:MM in user timezone
+	LastRefreshedAt *time.Time `json:"last_refreshed_at,omitempty"`                         // UTC
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	// Relationships
+	User User `json:"user,omitempty"`
+}
+
+// ChatSettings are the location, language and units a group or channel uses
+// instead of those of the member who asks, for chat-level cards such as
+// /today and for weather commands sent in a group. The chat ID is the key:
+// one set of settings per chat.
+type ChatSettings struct {
+	ChatID       int64     `gorm:"primaryKey;autoIncrement:false" json:"chat_id"`
+	LocationName string    `json:"location_name"`
+	Latitude     float64   `json:"latitude"`
+	Longitude    float64   `json:"longitude"`
+	Language     string    `json:"language"` // Empty to follow the member who asks
+	Units        string    `json:"units"`    // Empty to follow the member who asks
+	SetBy        int64     `json:"set_by"`   // Admin who configured them
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TableName keeps the table the chat locations of /today were stored in
+func (ChatSettings) TableName() string {
+	return "chat_locations"
+}
+
+// UserLocation is a place the user saved. The default one mirrors the
+// location stored on the user, which subscriptions and alerts use.
+type UserLocation struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID    int64     `gorm:"not null;index" json:"user_id"`
+	Name      string    `gorm:"not null" json:"name"`
+	Country   string    `json:"country"`
+	City      string    `json:"city"`
+	Latitude  float64   `gorm:"column:lat" json:"latitude"`
+	Longitude float64   `gorm:"column:lon" json:"longitude"`
+	IsDefault bool      `gorm:"default:false" json:"is_default"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relationships
+	User User `json:"user,omitempty"`
+}
+
+// WeatherHistory is a snapshot of the weather at a user's location, recorded
+// hourly in the background for /history
+type WeatherHistory struct {
+	ID           uuid.UUID       `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID       int64           `gorm:"not null;index:idx_weather_history_user_recorded" json:"user_id"`
+	LocationName string          `json:"location_name"`
+	Latitude     float64         `gorm:"column:lat" json:"latitude"`
+	Longitude    float64         `gorm:"column:lon" json:"longitude"`
+	Data         json.RawMessage `gorm:"type:jsonb" json:"data"`                                              // Weather as returned by the weather service
+	RecordedAt   time.Time       `gorm:"not null;index:idx_weather_history_user_recorded" json:"recorded_at"` // UTC
+}
+
+// TableName keeps the table name singular, like the history it holds
+func (WeatherHistory) TableName() string {
+	return "weather_history"
+}
+
+// WeatherRecord is weather a user looked up or was sent by a subscription,
+// kept for /history and data exports until the retention period ends
+type WeatherRecord struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID      int64     `gorm:"not null;index:idx_weather_records_user_timestamp" json:"user_id"`
+	ChatID      int64     `json:"chat_id"` // Chat the weather was shown in or delivered to
+	Latitude    float64   `gorm:"column:lat" json:"latitude"`
+	Longitude   float64   `gorm:"column:lon" json:"longitude"`
+	Temperature float64   `json:"temperature"` // Celsius
+	Humidity    int       `json:"humidity"`
+	Pressure    float64   `json:"pressure"`   // hPa
+	WindSpeed   float64   `json:"wind_speed"` // km/h
+	WindDegree  int       `json:"wind_degree"`
+	Visibility  float64   `json:"visibility"` // km
+	UVIndex     float64   `json:"uv_index"`
+	Description string    `json:"description"`
+	Icon        string    `json:"icon"`
+	AQI         int       `json:"aqi"`
+	Timestamp   time.Time `gorm:"not null;index:idx_weather_records_user_timestamp" json:"timestamp"` // Provider observation time, UTC
+	FromCache   bool      `json:"from_cache"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"` // UTC, when recorded
+}
+
+// AlertEscalation is an extreme weather alert message that the user has not
+// acknowledged yet. The message is the key; a follow-up is sent once it is due.
+type AlertEscalation struct {
+	ChatID    int64     `gorm:"primaryKey;autoIncrement:false" json:"chat_id"`
+	MessageID int64     `gorm:"primaryKey;autoIncrement:false" json:"message_id"`
+	UserID    int64     `gorm:"index" json:"user_id"`
+	Title     string    `json:"title"`               // Alert title, repeated in the follow-up
+	DueAt     time.Time `gorm:"index" json:"due_at"` // UTC
+	CreatedAt time.Time `json:"created_at"`          // UTC
+
+	// Relationships
+	User User `json:"user,omitempty"`
+}
+
+// QuietPeriod is a range of days, such as a vacation, on which the user's
+// routine alerts are recorded without being sent
+type QuietPeriod struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID    int64     `gorm:"index" json:"user_id"`
+	StartDate time.Time `gorm:"type:date" json:"start_date"` // First quiet day
+	EndDate   time.Time `gorm:"type:date" json:"end_date"`   // Last quiet day, inclusive
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Feedback is a message a user sent to the admins with /feedback
+type Feedback struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID     int64      `gorm:"index" json:"user_id"`
+	ChatID     int64      `json:"chat_id"` // Chat the feedback was sent from, where replies go
+	Message    string     `gorm:"type:text" json:"message"`
+	IsResolved bool       `gorm:"default:false;index" json:"is_resolved"`
+	ResolvedBy int64      `json:"resolved_by"`           // Admin who marked it resolved
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"` // UTC
+	CreatedAt  time.Time  `json:"created_at"`            // UTC
+
+	// Relationships
+	User User `json:"user,omitempty"`
+}
+
+// TableName keeps the table name singular, as feedback has no plural
+func (Feedback) TableName() string {
+	return "feedback"
+}
+
+// UserWebhook is the URL a user's alert triggers are posted to, signed with
+// the user's secret so the receiver can tell them from forgeries
+type UserWebhook struct {
+	UserID    int64     `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	URL       string    `gorm:"type:text" json:"url"`
+	Secret    string    `gorm:"type:varchar(64)" json:"-"` // Hex HMAC-SHA256 key, shown to the user once
+	CreatedAt time.Time `json:"created_at"`                // UTC
+	UpdatedAt time.Time `json:"updated_at"`                // UTC
+
+	// Relationships
+	User User `json:"user,omitempty"`
+}
+
+// FeatureFlagChange is the audit record of a runtime feature flag change
+type FeatureFlagChange struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Flag      string    `gorm:"type:varchar(50);index" json:"flag"`
+	Change    string    `gorm:"type:varchar(50)" json:"change"` // on, off, N%, user:ID[:off] or reset
+	AdminID   int64     `gorm:"index" json:"admin_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditLog records an action an admin took, such as a role change or a
+// broadcast, for /auditlog and the data exports of the users involved
+type AuditLog struct {
+	ID        uuid.UUID       `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	ActorID   int64           `gorm:"index" json:"actor_id"`                    // Admin who took the action; 0 for the deployment itself
+	Action    string          `gorm:"type:text;index" json:"action"`            // What was done, e.g. role_changed
+	TargetID  int64           `gorm:"index" json:"target_id"`                   // User acted on; 0 for actions on no user
+	Metadata  json.RawMessage `gorm:"type:jsonb" json:"metadata"`               // Details of the action
+	IP        string          `gorm:"type:text" json:"ip"`                      // Address the update came from over HTTP; empty for actions taken otherwise
+	CreatedAt time.Time       `gorm:"type:timestamptz;index" json:"created_at"` // UTC
+}
+
+// Preset is a shareable bundle of a location, a subscription and alert
+// configs. Users reach it through a deep link carrying the token and apply it
+// to their own account.
+type Preset struct {
+	ID           uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Token        string    `gorm:"type:varchar(32);uniqueIndex" json:"token"`
+	Name         string    `json:"name"`
+	CreatedBy    int64     `gorm:"index" json:"created_by"`
+	LocationName string    `json:"location_name"`
+	Country      string    `json:"country"`
+	City         string    `json:"city"`
+	Latitude     float64   `json:"latitude"`
+	Longitude    float64   `json:"longitude"`
+
+	// Subscription to ensure; zero type when the preset has none
+	SubscriptionType SubscriptionType `json:"subscription_type"`
+	Frequency        Frequency        `json:"frequency"`
+	TimeOfDay        string           `json:"time_of_day"` // HH:MM in the applying user's timezone
+
+	Alerts     string     `json:"alerts"` // JSON array of alert conditions
+	UsageCount int        `gorm:"default:0" json:"usage_count"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"` // UTC
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// PresetApplication records that a user applied a preset, so applying it
+// again is a no-op
+type PresetApplication struct {
+	PresetID  uuid.UUID `gorm:"type:uuid;primaryKey" json:"preset_id"`
+	UserID    int64     `gorm:"primaryKey" json:"user_id"`
+	AppliedAt time.Time `json:"applied_at"` // UTC
+}
+
+// OutboxItem is a scheduled notification whose delivery failed. Pending items
+// are retried by the scheduler; once retries are exhausted, or the failure
+// cannot succeed on retry, the item is kept as a dead letter for admins.
+type OutboxItem struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	SubscriptionID uuid.UUID  `gorm:"type:uuid;index" json:"subscription_id"`
+	UserID         int64      `gorm:"index" json:"user_id"`
+	Status         string     `gorm:"type:varchar(16);index" json:"status"`
+	Attempts       int        `json:"attempts"`
+	ErrorClass     string     `gorm:"type:varchar(32);index" json:"error_class"`
+	LastError      string     `json:"last_error"`
+	DeadAt         *time.Time `json:"dead_at,omitempty"` // UTC
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	// Relationships
+	Subscription Subscription `json:"-"`
+}
+
+// Outbox item statuses
+const (
+	OutboxPending = "pending"
+	OutboxDead    = "dead"
+)
+
+// AccountTransfer moves a user's configuration to another Telegram account.
+// The old account, or an admin on its behalf, starts it and gets a one-time
+// code that the new account claims. Nothing moves until both ends have
+// confirmed; the completed row stays as the audit record of the move.
+type AccountTransfer struct {
+	ID            uuid.UUID          `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Code          string             `gorm:"type:varchar(16);uniqueIndex" json:"code"`
+	FromUserID    int64              `gorm:"index" json:"from_user_id"`
+	ToUserID      int64              `gorm:"index" json:"to_user_id"` // Zero until the code is claimed
+	StartedBy     int64              `json:"started_by"`              // The old account, or the admin acting for it
+	Status        string             `gorm:"type:varchar(16);index" json:"status"`
+	FromConfirmed bool               `json:"from_confirmed"`
+	ToConfirmed   bool               `json:"to_confirmed"`
+	ApprovedBy    int64              `json:"approved_by"`                        // Admin who confirmed for the old account; zero when it confirmed itself
+	Resolution    TransferResolution `gorm:"type:varchar(16)" json:"resolution"` // Chosen by the new account when it had data of its own
+	ExpiresAt     time.Time          `json:"expires_at"`                         // UTC
+	CompletedAt   *time.Time         `json:"completed_at,omitempty"`             // UTC
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+}
+
+// Account transfer statuses
+const (
+	TransferPending   = "pending"   // Code issued, not claimed yet
+	TransferClaimed   = "claimed"   // New account known, waiting for confirmations
+	TransferCompleted = "completed" // Configuration moved, old account deactivated
+	TransferCancelled = "cancelled" // Replaced by a newer code or cancelled by the old account
+)
+
+// ReadyToComplete reports whether both ends have confirmed a claimed transfer
+func (t *AccountTransfer) ReadyToComplete() bool {
+	return t.Status == TransferClaimed && t.FromConfirmed && t.ToConfirmed
+}
+
+// TransferResolution says what happens to the new account's own location,
+// settings, subscriptions, alerts and quiet days. It is empty when the new
+// account had none.
+type TransferResolution string
+
+const (
+	TransferKeepMine   TransferResolution = "keep_mine"   // Keep the new account's configuration; only history moves
+	TransferKeepTheirs TransferResolution = "keep_theirs" // Replace it with the old account's
+	TransferMerge      TransferResolution = "merge"       // Keep its location and settings, add the old subscriptions, alerts and quiet days
+)
+
 // Migrate runs database migrations
 func Migrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	if err := db.AutoMigrate(
 		&User{},
 		&WeatherData{},
 		&Subscription{},
 		&AlertConfig{},
 		&EnvironmentalAlert{},
 		&UserSession{},
-	)
+		&ExportCursor{},
+		&WeatherWidget{},
+		&AlertEscalation{},
+		&Preset{},
+		&PresetApplication{},
+		&OutboxItem{},
+		&QuietPeriod{},
+		&AccountTransfer{},
+		&FeatureFlagChange{},
+		&ChatSettings{},
+		&UserLocation{},
+		&WeatherHistory{},
+		&WeatherRecord{},
+		&Feedback{},
+		&UserWebhook{},
+		&AuditLog{},
+	); err != nil {
+		return err
+	}
+
+	// Users registered before their last activity was recorded count as
+	// last seen when they registered
+	return db.Model(&User{}).Where("last_active_at IS NULL").Update("last_active_at", gorm.Expr("created_at")).Error
 }
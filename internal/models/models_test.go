@@ -427,6 +427,17 @@ func TestSubscription_IsActiveTime(t *testing.T) {
 	}
 }
 
+func TestSubscription_IsWeekly(t *testing.T) {
+	assert.True(t, (&Subscription{SubscriptionType: SubscriptionWeekly}).IsWeekly())
+	assert.True(t, (&Subscription{SubscriptionType: SubscriptionDaily, Frequency: FrequencyWeekly}).IsWeekly())
+	assert.False(t, (&Subscription{SubscriptionType: SubscriptionDaily, Frequency: FrequencyDaily}).IsWeekly())
+}
+
+func TestSubscription_DeliveryChatID(t *testing.T) {
+	assert.Equal(t, int64(42), (&Subscription{UserID: 42}).DeliveryChatID())
+	assert.Equal(t, int64(-100123), (&Subscription{UserID: 42, ChatID: -100123}).DeliveryChatID())
+}
+
 func TestSubscription_ShouldNotify(t *testing.T) {
 	mockTime := time.Date(2023, 1, 1, 8, 0, 0, 0, time.UTC) // Sunday
 
@@ -598,7 +609,7 @@ func TestSubscription_Validate(t *testing.T) {
 			name: "invalid subscription type - too high",
 			subscription: &Subscription{
 				UserID:           123,
-				SubscriptionType: 5,
+				SubscriptionType: 6,
 				Frequency:        FrequencyDaily,
 			},
 			expectErr: true,
@@ -741,6 +752,14 @@ func TestAlertConfig_IsRecentlyTriggered(t *testing.T) {
 			},
 			expected: false,
 		},
+		{
+			name: "past a shorter cooldown",
+			config: &AlertConfig{
+				LastTriggered:   &recentTime,
+				CooldownMinutes: 20,
+			},
+			expected: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -751,6 +770,14 @@ func TestAlertConfig_IsRecentlyTriggered(t *testing.T) {
 	}
 }
 
+func TestAlertConfig_QuietUntil(t *testing.T) {
+	triggered := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+
+	assert.True(t, (&AlertConfig{}).QuietUntil().IsZero())
+	assert.Equal(t, triggered.Add(DefaultAlertCooldown), (&AlertConfig{LastTriggered: &triggered}).QuietUntil())
+	assert.Equal(t, triggered.Add(30*time.Minute), (&AlertConfig{LastTriggered: &triggered, CooldownMinutes: 30}).QuietUntil())
+}
+
 func TestBeforeCreate_Hooks(t *testing.T) {
 	t.Run("User BeforeCreate", func(t *testing.T) {
 		validUser := &User{ID: 123, FirstName: "John"}
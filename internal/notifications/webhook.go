@@ -0,0 +1,215 @@
+// Package notifications pushes alert triggers to URLs users configure, so they
+// can pipe them into Slack, PagerDuty or their own dashboards. Each request is
+// signed with the user's secret.
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/valpere/shopogoda/internal/models"
+)
+
+const (
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of
+	// "<timestamp>.<body>" keyed with the user's secret
+	SignatureHeader = "X-Shopogoda-Signature"
+	// TimestampHeader carries the Unix time the request was signed at, so
+	// receivers can refuse replayed requests
+	TimestampHeader = "X-Shopogoda-Timestamp"
+
+	// webhookTimeout bounds one delivery; alert checks wait for it
+	webhookTimeout = 10 * time.Second
+	// secretBytes is the length of generated secrets before hex encoding
+	secretBytes = 32
+	// maxURLLength keeps stored URLs to a sane size
+	maxURLLength = 2048
+)
+
+var (
+	// ErrInvalidWebhookURL is returned for URLs that are not absolute https
+	// URLs of a public host
+	ErrInvalidWebhookURL = errors.New("webhook URL must be an https URL of a public host")
+
+	// errPrivateAddress refuses connections to addresses inside our network
+	errPrivateAddress = errors.New("webhook host resolves to a private address")
+)
+
+// AlertEvent is the JSON payload posted for a triggered alert
+type AlertEvent struct {
+	UserID         int64     `json:"user_id"`
+	AlertType      string    `json:"alert_type"`
+	TriggeredValue float64   `json:"triggered_value"`
+	Threshold      float64   `json:"threshold"`
+	Location       string    `json:"location"`
+	Timestamp      time.Time `json:"timestamp"` // UTC
+}
+
+// WebhookNotifier stores the users' webhooks and delivers alert events to them
+type WebhookNotifier struct {
+	db     *gorm.DB
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewWebhookNotifier(db *gorm.DB) *WebhookNotifier {
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: refusePrivateAddress}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	return &WebhookNotifier{
+		db: db,
+		client: &http.Client{
+			Timeout:   webhookTimeout,
+			Transport: transport,
+			// A redirect could lead the request somewhere the user did not configure
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		now: time.Now,
+	}
+}
+
+// Get returns the user's webhook, or nil when none is configured
+func (n *WebhookNotifier) Get(ctx context.Context, userID int64) (*models.UserWebhook, error) {
+	var webhook models.UserWebhook
+	err := n.db.WithContext(ctx).Where("user_id = ?", userID).First(&webhook).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return &webhook, nil
+}
+
+// Register points the user's alert triggers at a URL with a new secret,
+// replacing any earlier webhook. The returned webhook carries the secret.
+func (n *WebhookNotifier) Register(ctx context.Context, userID int64, rawURL string) (*models.UserWebhook, error) {
+	if err := ValidateURL(rawURL); err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+
+	webhook := &models.UserWebhook{UserID: userID, URL: rawURL, Secret: hex.EncodeToString(secret)}
+	err := n.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"url", "secret", "updated_at"}),
+	}).Create(webhook).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to save webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+// Remove stops posting the user's alert triggers; it reports whether a webhook
+// was configured
+func (n *WebhookNotifier) Remove(ctx context.Context, userID int64) (bool, error) {
+	result := n.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.UserWebhook{})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to remove webhook: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Send posts an alert event to the webhook of its user. Users without a
+// webhook are skipped; any response other than 2xx is an error.
+func (n *WebhookNotifier) Send(ctx context.Context, event AlertEvent) error {
+	webhook, err := n.Get(ctx, event.UserID)
+	if err != nil || webhook == nil {
+		return err
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	timestamp := n.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, "sha256="+Sign(webhook.Secret, timestamp, body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the
+// secret. Receivers compute the same to verify a request.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateURL accepts absolute https URLs whose host is not a private or
+// loopback address. Names are checked again when connecting, after they
+// resolve.
+func ValidateURL(rawURL string) error {
+	if len(rawURL) > maxURLLength {
+		return ErrInvalidWebhookURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" || u.User != nil {
+		return ErrInvalidWebhookURL
+	}
+	if u.Hostname() == "localhost" {
+		return ErrInvalidWebhookURL
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !publicIP(ip) {
+		return ErrInvalidWebhookURL
+	}
+	return nil
+}
+
+// refusePrivateAddress is the dialer hook that keeps webhooks from reaching
+// the bot's own network, whatever their host name resolves to
+func refusePrivateAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsMulticast()
+}
@@ -0,0 +1,113 @@
+// Package ratelimit limits how often each user may do something, across every
+// instance of the bot. Counters live in Redis, one per user and minute.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DefaultWeatherLimit is how many weather requests a user may make per
+	// minute when the configuration does not say
+	DefaultWeatherLimit = 20
+
+	// window is the length of one counter
+	window = time.Minute
+)
+
+// Result says whether a request may go ahead and, when it may not, how long
+// the user has to wait
+type Result struct {
+	Allowed    bool
+	RetryAfter time.Duration // Zero when allowed; otherwise whole seconds, at least one
+}
+
+// RateLimiter is a sliding-window counter. Each minute has its own counter,
+// stored as ratelimit:<name>:<userID>:<windowMinute>. A request is weighed
+// against the current minute plus the share of the previous minute that
+// still falls within the last 60 seconds, which smooths the jump at the
+// start of each minute that fixed windows have.
+type RateLimiter struct {
+	redis *redis.Client
+	name  string
+	limit int
+	now   func() time.Time
+}
+
+// New creates a limiter that allows limit requests per minute for each user.
+// Limits of zero or less fall back to DefaultWeatherLimit.
+func New(redis *redis.Client, name string, limit int) *RateLimiter {
+	if limit <= 0 {
+		limit = DefaultWeatherLimit
+	}
+	return &RateLimiter{redis: redis, name: name, limit: limit, now: time.Now}
+}
+
+// Limit returns the number of requests allowed per minute
+func (l *RateLimiter) Limit() int {
+	return l.limit
+}
+
+// Allow counts a request of the user unless it is over the limit. Requests
+// that are turned away are not counted, so waiting always helps.
+func (l *RateLimiter) Allow(ctx context.Context, userID int64) (Result, error) {
+	now := l.now()
+	minute := now.Unix() / int64(window/time.Second)
+	elapsed := now.Sub(time.Unix(minute*int64(window/time.Second), 0))
+
+	previous, err := l.redis.Get(ctx, l.key(userID, minute-1)).Int()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return Result{}, fmt.Errorf("failed to read previous rate limit window: %w", err)
+	}
+
+	currentKey := l.key(userID, minute)
+	current, err := l.redis.Incr(ctx, currentKey).Result()
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to count request: %w", err)
+	}
+	// The counter is read as the previous window during the next minute
+	if err := l.redis.Expire(ctx, currentKey, 2*window).Err(); err != nil {
+		return Result{}, fmt.Errorf("failed to expire rate limit window: %w", err)
+	}
+
+	if weighted(previous, int(current), elapsed) <= float64(l.limit) {
+		return Result{Allowed: true}, nil
+	}
+
+	if err := l.redis.Decr(ctx, currentKey).Err(); err != nil {
+		return Result{}, fmt.Errorf("failed to uncount request: %w", err)
+	}
+	return Result{RetryAfter: retryAfter(previous, int(current)-1, l.limit, elapsed)}, nil
+}
+
+func (l *RateLimiter) key(userID int64, minute int64) string {
+	return fmt.Sprintf("ratelimit:%s:%d:%d", l.name, userID, minute)
+}
+
+// weighted estimates the requests in the last minute from the counters of the
+// previous and current minute
+func weighted(previous, current int, elapsed time.Duration) float64 {
+	return float64(previous)*(1-elapsed.Seconds()/window.Seconds()) + float64(current)
+}
+
+// retryAfter is how long until one more request fits within limit, given the
+// counts before the turned-away request
+func retryAfter(previous, current, limit int, elapsed time.Duration) time.Duration {
+	var wait time.Duration
+	if current < limit && previous > 0 {
+		// The previous minute's share decays within this minute
+		fraction := 1 - float64(limit-current-1)/float64(previous)
+		wait = time.Duration(fraction*float64(window)) - elapsed
+	} else {
+		// Only once this minute becomes the previous one and decays enough
+		fraction := 1 - float64(limit-1)/float64(current)
+		wait = window - elapsed + time.Duration(fraction*float64(window))
+	}
+	return max(time.Duration(math.Ceil(wait.Seconds()))*time.Second, time.Second)
+}
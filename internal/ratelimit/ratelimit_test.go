@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 12:00:30 UTC on 16 Oct 2026; the minute's counter suffix is 29869200
+var testNow = time.Date(2026, 10, 16, 12, 0, 30, 0, time.UTC)
+
+const (
+	currentKey  = "ratelimit:weather:42:29869200"
+	previousKey = "ratelimit:weather:42:29869199"
+)
+
+func newTestLimiter(limit int) (*RateLimiter, redismock.ClientMock) {
+	rdb, mock := redismock.NewClientMock()
+	limiter := New(rdb, "weather", limit)
+	limiter.now = func() time.Time { return testNow }
+	return limiter, mock
+}
+
+func TestNew_DefaultLimit(t *testing.T) {
+	assert.Equal(t, DefaultWeatherLimit, New(nil, "weather", 0).Limit())
+	assert.Equal(t, 5, New(nil, "weather", 5).Limit())
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("allows requests under the limit", func(t *testing.T) {
+		limiter, mock := newTestLimiter(20)
+		mock.ExpectGet(previousKey).RedisNil()
+		mock.ExpectIncr(currentKey).SetVal(1)
+		mock.ExpectExpire(currentKey, 2*time.Minute).SetVal(true)
+
+		result, err := limiter.Allow(ctx, 42)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Zero(t, result.RetryAfter)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("blocks the request over the limit and uncounts it", func(t *testing.T) {
+		limiter, mock := newTestLimiter(20)
+		mock.ExpectGet(previousKey).RedisNil()
+		mock.ExpectIncr(currentKey).SetVal(21)
+		mock.ExpectExpire(currentKey, 2*time.Minute).SetVal(true)
+		mock.ExpectDecr(currentKey).SetVal(20)
+
+		result, err := limiter.Allow(ctx, 42)
+		require.NoError(t, err)
+		assert.False(t, result.Allowed)
+		// 30s until the next minute, then 3s until 20 requests decay to 19
+		assert.Equal(t, 33*time.Second, result.RetryAfter)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("weighs in half of the previous minute at half past", func(t *testing.T) {
+		limiter, mock := newTestLimiter(20)
+		mock.ExpectGet(previousKey).SetVal("20")
+		mock.ExpectIncr(currentKey).SetVal(11)
+		mock.ExpectExpire(currentKey, 2*time.Minute).SetVal(true)
+		mock.ExpectDecr(currentKey).SetVal(10)
+
+		result, err := limiter.Allow(ctx, 42)
+		require.NoError(t, err)
+		assert.False(t, result.Allowed)
+		// 20 * (1 - f) + 10 + 1 <= 20 once f reaches 0.55, at 33s
+		assert.Equal(t, 3*time.Second, result.RetryAfter)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("returns Redis errors", func(t *testing.T) {
+		limiter, mock := newTestLimiter(20)
+		mock.ExpectGet(previousKey).SetErr(errors.New("connection refused"))
+
+		_, err := limiter.Allow(ctx, 42)
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRetryAfter_AtLeastOneSecond(t *testing.T) {
+	// The previous minute has already decayed enough by the time of the answer
+	assert.Equal(t, time.Second, retryAfter(20, 0, 20, 59500*time.Millisecond))
+}
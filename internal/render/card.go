@@ -0,0 +1,391 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+)
+
+// Size of the weather card image, in pixels
+const (
+	CardWidth  = 800
+	CardHeight = 400
+)
+
+// CardView is what the weather card image shows: the current conditions of
+// a place, formatted in the reader's units like WeatherView
+type CardView struct {
+	Location    string // Left out when the card font cannot draw it; the caption names the place
+	Temperature string
+	Humidity    string
+	Wind        string
+	Icon        string // Provider icon code such as "10d"
+}
+
+// GenerateWeatherCard draws the card as a PNG: the place and temperature
+// over a background that follows the sky, an icon of the conditions, and
+// the humidity and wind along the bottom. Text is drawn in a built-in 5x7
+// pixel font of Latin capitals, digits and the symbols of formatted values.
+func GenerateWeatherCard(view CardView) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, CardWidth, CardHeight))
+	top, bottom := cardBackground(view.Icon)
+	for y := 0; y < CardHeight; y++ {
+		c := mix(top, bottom, float64(y)/float64(CardHeight-1))
+		for x := 0; x < CardWidth; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	white := color.RGBA{255, 255, 255, 255}
+	if location := strings.ToUpper(view.Location); location != "" && drawable(location) {
+		text, scale := fitText(location, 6, 3, CardWidth-80)
+		drawText(img, text, 40, 40, scale, white)
+	}
+	if view.Temperature != "" {
+		drawText(img, view.Temperature, 40, 140, fitScale(view.Temperature, 16, 6, 480), white)
+	}
+	drawConditionIcon(img, view.Icon, 640, 170)
+
+	if view.Humidity != "" {
+		drawDrop(img, 52, 322, white)
+		drawText(img, strings.ToUpper(view.Humidity), 84, 308, 5, white)
+	}
+	if view.Wind != "" {
+		drawWind(img, 380, 312, white)
+		drawText(img, strings.ToUpper(view.Wind), 440, 308, 5, white)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode weather card: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// cardBackground returns the top and bottom colours of the background for a
+// provider icon code: the sky by day or night, or the colour of the weather
+func cardBackground(icon string) (top, bottom color.RGBA) {
+	condition, night := iconCondition(icon)
+	switch {
+	case night && (condition == "01" || condition == "02"):
+		return color.RGBA{18, 24, 58, 255}, color.RGBA{58, 48, 110, 255}
+	case condition == "01" || condition == "02":
+		return color.RGBA{46, 134, 222, 255}, color.RGBA{135, 206, 250, 255}
+	case condition == "09" || condition == "10":
+		return color.RGBA{60, 80, 105, 255}, color.RGBA{120, 140, 160, 255}
+	case condition == "11":
+		return color.RGBA{40, 40, 60, 255}, color.RGBA{90, 85, 110, 255}
+	case condition == "13":
+		return color.RGBA{110, 145, 185, 255}, color.RGBA{190, 210, 230, 255}
+	case condition == "50":
+		return color.RGBA{110, 120, 130, 255}, color.RGBA{170, 175, 180, 255}
+	default:
+		return color.RGBA{80, 110, 140, 255}, color.RGBA{150, 175, 195, 255}
+	}
+}
+
+// iconCondition splits a provider icon code such as "10n" into its
+// condition and whether it is night
+func iconCondition(icon string) (condition string, night bool) {
+	if len(icon) < 2 {
+		return "", false
+	}
+	return icon[:2], strings.HasSuffix(icon, "n")
+}
+
+// mix blends from a to b by f, between 0 and 1
+func mix(a, b color.RGBA, f float64) color.RGBA {
+	channel := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x) + (float64(y)-float64(x))*f))
+	}
+	return color.RGBA{channel(a.R, b.R), channel(a.G, b.G), channel(a.B, b.B), 255}
+}
+
+// fillShape paints the pixels within bounds for which inside holds
+func fillShape(img *image.RGBA, bounds image.Rectangle, c color.RGBA, inside func(x, y int) bool) {
+	bounds = bounds.Intersect(img.Bounds())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if inside(x, y) {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+}
+
+// inCircle reports whether (x, y) lies within r of (cx, cy)
+func inCircle(x, y, cx, cy, r int) bool {
+	dx, dy := x-cx, y-cy
+	return dx*dx+dy*dy <= r*r
+}
+
+func fillCircle(img *image.RGBA, cx, cy, r int, c color.RGBA) {
+	fillShape(img, image.Rect(cx-r, cy-r, cx+r+1, cy+r+1), c, func(x, y int) bool {
+		return inCircle(x, y, cx, cy, r)
+	})
+}
+
+// drawConditionIcon draws the icon of a provider icon code centred on (cx, cy)
+func drawConditionIcon(img *image.RGBA, icon string, cx, cy int) {
+	condition, night := iconCondition(icon)
+	cloud := color.RGBA{245, 247, 250, 255}
+
+	switch condition {
+	case "01":
+		if night {
+			drawMoon(img, cx, cy, 70)
+		} else {
+			drawSun(img, cx, cy, 62)
+		}
+	case "02", "03":
+		if night {
+			drawMoon(img, cx+40, cy-40, 45)
+		} else {
+			drawSun(img, cx+40, cy-40, 40)
+		}
+		drawCloud(img, cx-10, cy+20, cloud)
+	case "09", "10":
+		drawCloud(img, cx, cy-20, color.RGBA{215, 222, 230, 255})
+		for i := -1; i <= 1; i++ {
+			drawDrop(img, cx+i*40, cy+70+(i%2)*10, color.RGBA{90, 170, 255, 255})
+		}
+	case "11":
+		drawCloud(img, cx, cy-20, color.RGBA{150, 155, 170, 255})
+		drawBolt(img, cx, cy+35, color.RGBA{255, 210, 40, 255})
+	case "13":
+		drawCloud(img, cx, cy-20, cloud)
+		for i := -1; i <= 1; i++ {
+			fillCircle(img, cx+i*40, cy+70+(i%2)*12, 9, color.RGBA{255, 255, 255, 255})
+		}
+	case "50":
+		for i, width := range []int{150, 120, 150, 100} {
+			y := cy - 60 + i*36
+			fillShape(img, image.Rect(cx-width/2, y, cx+width/2, y+16), cloud, func(int, int) bool { return true })
+		}
+	default:
+		drawCloud(img, cx, cy, cloud)
+	}
+}
+
+// drawSun draws a disc of radius r with rays around it
+func drawSun(img *image.RGBA, cx, cy, r int) {
+	sun := color.RGBA{255, 200, 40, 255}
+	fillCircle(img, cx, cy, r, sun)
+	for i := 0; i < 8; i++ {
+		angle := float64(i) * math.Pi / 4
+		distance := float64(r) * 1.35
+		fillCircle(img, cx+int(math.Round(distance*math.Cos(angle))), cy+int(math.Round(distance*math.Sin(angle))), r/6, sun)
+	}
+}
+
+// drawMoon draws a crescent of radius r
+func drawMoon(img *image.RGBA, cx, cy, r int) {
+	fillShape(img, image.Rect(cx-r, cy-r, cx+r+1, cy+r+1), color.RGBA{240, 236, 200, 255}, func(x, y int) bool {
+		return inCircle(x, y, cx, cy, r) && !inCircle(x, y, cx+r/2, cy-r/3, r*4/5)
+	})
+}
+
+// drawCloud draws a cloud about 180 pixels wide centred on (cx, cy)
+func drawCloud(img *image.RGBA, cx, cy int, c color.RGBA) {
+	fillShape(img, image.Rect(cx-100, cy-75, cx+100, cy+50), c, func(x, y int) bool {
+		return inCircle(x, y, cx-50, cy+5, 40) ||
+			inCircle(x, y, cx, cy-20, 52) ||
+			inCircle(x, y, cx+50, cy+5, 40) ||
+			(x >= cx-50 && x <= cx+50 && y >= cy && y <= cy+45)
+	})
+}
+
+// drawDrop draws a raindrop with its round bottom centred on (cx, cy)
+func drawDrop(img *image.RGBA, cx, cy int, c color.RGBA) {
+	const r = 12
+	top := cy - 2*r
+	fillShape(img, image.Rect(cx-r, top, cx+r+1, cy+r+1), c, func(x, y int) bool {
+		if inCircle(x, y, cx, cy, r) {
+			return true
+		}
+		if y < top || y > cy {
+			return false
+		}
+		return abs(x-cx)*(cy-top) <= r*(y-top)
+	})
+}
+
+// drawWind draws three streaks starting at (x, y)
+func drawWind(img *image.RGBA, x, y int, c color.RGBA) {
+	for i, length := range []int{40, 50, 30} {
+		top := y + i*14
+		fillShape(img, image.Rect(x, top, x+length, top+6), c, func(int, int) bool { return true })
+	}
+}
+
+// drawBolt draws a lightning bolt whose top is centred on (cx, cy)
+func drawBolt(img *image.RGBA, cx, cy int, c color.RGBA) {
+	points := [][2]int{{cx + 5, cy}, {cx - 25, cy + 50}, {cx - 2, cy + 50}, {cx - 12, cy + 90}, {cx + 25, cy + 35}, {cx + 2, cy + 35}, {cx + 15, cy}}
+	fillShape(img, image.Rect(cx-25, cy, cx+26, cy+91), c, func(x, y int) bool {
+		return inPolygon(x, y, points)
+	})
+}
+
+// inPolygon reports whether (x, y) lies within the polygon, by the even-odd rule
+func inPolygon(x, y int, points [][2]int) bool {
+	inside := false
+	for i, j := 0, len(points)-1; i < len(points); j, i = i, i+1 {
+		xi, yi, xj, yj := points[i][0], points[i][1], points[j][0], points[j][1]
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Glyphs of the card font are 5x7 pixels, drawn 6 pixels apart
+const (
+	glyphWidth   = 5
+	glyphHeight  = 7
+	glyphAdvance = 6
+)
+
+// cardFont holds the rows of each glyph, '#' for a set pixel
+var cardFont = map[rune][glyphHeight]string{
+	'A':  {".###.", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'B':  {"####.", "#...#", "#...#", "####.", "#...#", "#...#", "####."},
+	'C':  {".###.", "#...#", "#....", "#....", "#....", "#...#", ".###."},
+	'D':  {"###..", "#..#.", "#...#", "#...#", "#...#", "#..#.", "###.."},
+	'E':  {"#####", "#....", "#....", "####.", "#....", "#....", "#####"},
+	'F':  {"#####", "#....", "#....", "####.", "#....", "#....", "#...."},
+	'G':  {".###.", "#...#", "#....", "#.###", "#...#", "#...#", ".####"},
+	'H':  {"#...#", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'I':  {".###.", "..#..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'J':  {"..###", "...#.", "...#.", "...#.", "...#.", "#..#.", ".##.."},
+	'K':  {"#...#", "#..#.", "#.#..", "##...", "#.#..", "#..#.", "#...#"},
+	'L':  {"#....", "#....", "#....", "#....", "#....", "#....", "#####"},
+	'M':  {"#...#", "##.##", "#.#.#", "#.#.#", "#...#", "#...#", "#...#"},
+	'N':  {"#...#", "#...#", "##..#", "#.#.#", "#..##", "#...#", "#...#"},
+	'O':  {".###.", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'P':  {"####.", "#...#", "#...#", "####.", "#....", "#....", "#...."},
+	'Q':  {".###.", "#...#", "#...#", "#...#", "#.#.#", "#..#.", ".##.#"},
+	'R':  {"####.", "#...#", "#...#", "####.", "#.#..", "#..#.", "#...#"},
+	'S':  {".####", "#....", "#....", ".###.", "....#", "....#", "####."},
+	'T':  {"#####", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."},
+	'U':  {"#...#", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'V':  {"#...#", "#...#", "#...#", "#...#", "#...#", ".#.#.", "..#.."},
+	'W':  {"#...#", "#...#", "#...#", "#.#.#", "#.#.#", "#.#.#", ".#.#."},
+	'X':  {"#...#", "#...#", ".#.#.", "..#..", ".#.#.", "#...#", "#...#"},
+	'Y':  {"#...#", "#...#", ".#.#.", "..#..", "..#..", "..#..", "..#.."},
+	'Z':  {"#####", "....#", "...#.", "..#..", ".#...", "#....", "#####"},
+	'0':  {".###.", "#...#", "#..##", "#.#.#", "##..#", "#...#", ".###."},
+	'1':  {"..#..", ".##..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'2':  {".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	'3':  {"#####", "...#.", "..#..", "...#.", "....#", "#...#", ".###."},
+	'4':  {"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	'5':  {"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	'6':  {"..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."},
+	'7':  {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	'8':  {".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+	'9':  {".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."},
+	' ':  {".....", ".....", ".....", ".....", ".....", ".....", "....."},
+	'-':  {".....", ".....", ".....", ".###.", ".....", ".....", "....."},
+	'.':  {".....", ".....", ".....", ".....", ".....", ".##..", ".##.."},
+	',':  {".....", ".....", ".....", ".....", ".##..", "..#..", ".#..."},
+	'%':  {"##...", "##..#", "...#.", "..#..", ".#...", "#..##", "...##"},
+	'°':  {".##..", "#..#.", ".##..", ".....", ".....", ".....", "....."},
+	'/':  {".....", "....#", "...#.", "..#..", ".#...", "#....", "....."},
+	'\'': {"..#..", "..#..", ".#...", ".....", ".....", ".....", "....."},
+	'(':  {"...#.", "..#..", ".#...", ".#...", ".#...", "..#..", "...#."},
+	')':  {".#...", "..#..", "...#.", "...#.", "...#.", "..#..", ".#..."},
+}
+
+// drawable reports whether the card font has every character of text
+func drawable(text string) bool {
+	for _, r := range text {
+		if _, ok := cardFont[r]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// textWidth returns the width of text drawn at scale, in pixels
+func textWidth(text string, scale int) int {
+	n := len([]rune(text))
+	if n == 0 {
+		return 0
+	}
+	return (n*glyphAdvance - 1) * scale
+}
+
+// fitScale returns the largest scale from maxScale down to minScale at which
+// text is no wider than width
+func fitScale(text string, maxScale, minScale, width int) int {
+	scale := maxScale
+	for scale > minScale && textWidth(text, scale) > width {
+		scale--
+	}
+	return scale
+}
+
+// fitText returns text as drawn to fit width: at a smaller scale down to
+// minScale, then shortened
+func fitText(text string, scale, minScale, width int) (string, int) {
+	scale = fitScale(text, scale, minScale, width)
+	runes := []rune(text)
+	for len(runes) > 1 && textWidth(string(runes), scale) > width {
+		runes = runes[:len(runes)-1]
+	}
+	if len(runes) < len([]rune(text)) && len(runes) > 2 {
+		runes = append(runes[:len(runes)-2], '.', '.')
+	}
+	return string(runes), scale
+}
+
+// drawText draws text with its top-left corner at (x, y), each font pixel
+// scale pixels wide, over a soft shadow. Characters the font lacks are skipped.
+func drawText(img *image.RGBA, text string, x, y, scale int, c color.RGBA) {
+	shadow := color.RGBA{0, 0, 0, 255}
+	offset := max(1, scale/3)
+	for _, pass := range []struct {
+		dx    int
+		color color.RGBA
+	}{{offset, shadow}, {0, c}} {
+		cursor := x + pass.dx
+		for _, r := range text {
+			glyph, ok := cardFont[r]
+			if ok {
+				drawGlyph(img, glyph, cursor, y+pass.dx, scale, pass.color, pass.dx > 0)
+			}
+			cursor += glyphAdvance * scale
+		}
+	}
+}
+
+// drawGlyph draws one glyph; a shadow is blended into the background
+func drawGlyph(img *image.RGBA, glyph [glyphHeight]string, x, y, scale int, c color.RGBA, shadow bool) {
+	for row := 0; row < glyphHeight; row++ {
+		for col := 0; col < glyphWidth; col++ {
+			if glyph[row][col] != '#' {
+				continue
+			}
+			bounds := image.Rect(x+col*scale, y+row*scale, x+(col+1)*scale, y+(row+1)*scale).Intersect(img.Bounds())
+			for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+				for px := bounds.Min.X; px < bounds.Max.X; px++ {
+					if shadow {
+						img.SetRGBA(px, py, mix(img.RGBAAt(px, py), c, 0.35))
+					} else {
+						img.SetRGBA(px, py, c)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package render
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateWeatherCard(t *testing.T) {
+	view := CardView{
+		Location:    "Kyiv, UA",
+		Temperature: "-3.5°C",
+		Humidity:    "93%",
+		Wind:        "10.0 km/h",
+		Icon:        "13d",
+	}
+
+	data, err := GenerateWeatherCard(view)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, CardWidth, img.Bounds().Dx())
+	assert.Equal(t, CardHeight, img.Bounds().Dy())
+
+	// The background follows the sky
+	view.Icon = "01n"
+	night, err := GenerateWeatherCard(view)
+	require.NoError(t, err)
+	nightImg, err := png.Decode(bytes.NewReader(night))
+	require.NoError(t, err)
+	assert.NotEqual(t, img.At(5, 5), nightImg.At(5, 5))
+
+	// A name the font cannot draw is left to the caption
+	view.Location = "Київ"
+	_, err = GenerateWeatherCard(view)
+	assert.NoError(t, err)
+}
+
+func TestCardFont(t *testing.T) {
+	for r, glyph := range cardFont {
+		for _, row := range glyph {
+			assert.Len(t, row, glyphWidth, "glyph %q", r)
+			assert.Equal(t, "", strings.Trim(row, ".#"), "glyph %q", r)
+		}
+	}
+
+	// Every formatted value the card shows can be drawn
+	for _, text := range []string{"-12.5°C", "54.3°F", "100%", "10.0 KM/H", "6.2 MPH", "ST. JOHN'S (NL)"} {
+		assert.True(t, drawable(text), text)
+	}
+	assert.False(t, drawable("KYIV, УКРАЇНА"))
+	assert.False(t, drawable("kyiv"), "the font has capitals only")
+}
+
+func TestFitText(t *testing.T) {
+	text, scale := fitText("KYIV", 6, 3, 720)
+	assert.Equal(t, "KYIV", text)
+	assert.Equal(t, 6, scale)
+
+	long := strings.Repeat("LLANFAIRPWLLGWYNGYLL", 3)
+	text, scale = fitText(long, 6, 3, 720)
+	assert.Equal(t, 3, scale)
+	assert.LessOrEqual(t, textWidth(text, scale), 720)
+	assert.True(t, strings.HasSuffix(text, ".."))
+
+	assert.Equal(t, 16, fitScale("-3°C", 16, 6, 480))
+	assert.Less(t, fitScale("-12.5°C", 16, 6, 480), 16)
+}
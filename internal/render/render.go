@@ -0,0 +1,90 @@
+// Package render builds the longer bot messages from the text templates in
+// templates/. Templates hold the layout and call t to look up labels in the
+// reader's language; the values they show arrive already formatted in a view.
+package render
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"strings"
+	"text/template"
+
+	"github.com/valpere/shopogoda/internal/markdown"
+)
+
+// TemplatesFS contains the bundled message templates
+//
+//go:embed templates/*.tmpl
+var TemplatesFS embed.FS
+
+// Names of the bundled templates
+const (
+	Weather    = "weather"
+	Forecast   = "forecast"
+	AirQuality = "airquality"
+)
+
+// Translator looks up a localized string, as LocalizationService does
+type Translator interface {
+	T(ctx context.Context, language, key string, args ...any) string
+}
+
+// TemplateRenderer renders the message templates in a language. A template
+// named name.<lang>.tmpl, e.g. weather.uk-UA.tmpl, replaces name.tmpl for
+// readers of that language.
+type TemplateRenderer struct {
+	templates  *template.Template
+	translator Translator
+}
+
+// NewTemplateRenderer parses every templates/*.tmpl file in fsys
+func NewTemplateRenderer(fsys fs.FS, translator Translator) (*TemplateRenderer, error) {
+	// t is bound to the reader's language on each render; md escapes names
+	// and other free text for Markdown, and mdBold does so for text inside
+	// a bold entity
+	placeholder := template.FuncMap{
+		"t":      func(key string, args ...any) string { return key },
+		"md":     markdown.Escape,
+		"mdBold": markdown.EscapeBold,
+	}
+	templates, err := template.New("").Funcs(placeholder).Option("missingkey=error").ParseFS(fsys, "templates/*.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message templates: %w", err)
+	}
+	return &TemplateRenderer{templates: templates, translator: translator}, nil
+}
+
+// Render executes the template name for a reader of lang, preferring the
+// variant for that language when there is one
+func (r *TemplateRenderer) Render(name, lang string, view any) (string, error) {
+	file := r.lookup(name, lang)
+	if file == "" {
+		return "", fmt.Errorf("no %s template", name)
+	}
+
+	templates, err := r.templates.Clone()
+	if err != nil {
+		return "", err
+	}
+	templates.Funcs(template.FuncMap{"t": func(key string, args ...any) string {
+		return r.translator.T(context.Background(), lang, key, args...)
+	}})
+
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, file, view); err != nil {
+		return "", fmt.Errorf("failed to render %s: %w", file, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func (r *TemplateRenderer) lookup(name, lang string) string {
+	for _, file := range []string{name + "." + lang + ".tmpl", name + ".tmpl"} {
+		if r.templates.Lookup(file) != nil {
+			return file
+		}
+	}
+	return ""
+}
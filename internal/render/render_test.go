@@ -0,0 +1,153 @@
+package render
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/locales"
+	"github.com/valpere/shopogoda/internal/services"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestTemplateRenderer_Bundled(t *testing.T) {
+	localization := services.NewLocalizationService(helpers.NewSilentTestLogger())
+	require.NoError(t, localization.LoadTranslations(locales.LocalesFS))
+	renderer, err := NewTemplateRenderer(TemplatesFS, localization)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		template string
+		view     any
+		contains []string
+	}{
+		{
+			name:     "weather",
+			template: Weather,
+			view: WeatherView{
+				Location:       "Kyiv, UA",
+				Temperature:    "-0.4°C",
+				FeelsLike:      "-4.1°C",
+				Humidity:       "93%",
+				Wind:           "10.0 km/h",
+				WindDirection:  270,
+				Visibility:     "4.2 km",
+				UVIndex:        "0.5",
+				Pressure:       "1009 hPa",
+				SunTimes:       "🌅 07:55 / 🌇 16:30",
+				MoonEmoji:      "🌖",
+				MoonPhase:      "Waning gibbous",
+				Icon:           "🌨️",
+				Description:    "light snow",
+				AQI:            "42",
+				AQIDescription: "Good",
+				CO:             201.94,
+				PM25:           8.25,
+				Updated:        "07:00 UTC",
+			},
+			contains: []string{"🌤️ *Kyiv, UA*", "-0.4°C", "10.0 km/h 270°", "🌅 07:55 / 🌇 16:30", "🌨️ light snow", "CO: 201.94", "PM2.5: 8.2"},
+		},
+		{
+			name:     "stale weather during polar night",
+			template: Weather,
+			view: WeatherView{
+				Stale:       true,
+				DataAge:     "3 h",
+				Location:    "Tromsø, NO",
+				Description: "clear sky",
+			},
+			contains: []string{"3 h", "Tromsø, NO"},
+		},
+		{
+			name:     "forecast",
+			template: Forecast,
+			view: ForecastView{
+				Location: "Paris, FR",
+				Days: []ForecastDayView{
+					{Date: "Saturday, Jan 11", High: "12.0°C", Low: "5.0°C", Icon: "⛅", Description: "partly cloudy", Humidity: "70%", Wind: "8.0 km/h"},
+					{Date: "Sunday, Jan 12", High: "14.0°C", Low: "7.0°C", Icon: "☀️", Description: "sunny", Humidity: "65%", Wind: "6.0 km/h", SunTimes: "🌅 08:43 / 🌇 17:17"},
+				},
+			},
+			contains: []string{"Paris, FR", "📅 *Saturday, Jan 11*", "🌡️ 12.0°C/5.0°C | ⛅ partly cloudy", "🌅 08:43 / 🌇 17:17"},
+		},
+		{
+			name:     "forecast without days",
+			template: Forecast,
+			view:     ForecastView{Location: "Paris, FR"},
+			contains: []string{"Paris, FR"},
+		},
+		{
+			name:     "air quality",
+			template: AirQuality,
+			view: AirQualityView{
+				AQI:                  "350",
+				AQIDescription:       "Hazardous",
+				HealthRecommendation: "Stay indoors",
+				CO:                   2000,
+				NO2:                  100,
+				PM10:                 300,
+				Updated:              "07:00 UTC",
+			},
+			contains: []string{"350", "Stay indoors", "2000.00 μg/m³", "300.0 μg/m³"},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, lang := range []string{"en-US", "uk-UA", "de-DE", "fr-FR", "es-ES"} {
+			t.Run(fmt.Sprintf("%s/%s", tt.name, lang), func(t *testing.T) {
+				text, err := renderer.Render(tt.template, lang, tt.view)
+				require.NoError(t, err)
+
+				assert.NotEmpty(t, text)
+				assert.NotContains(t, text, "<no value>")
+				assert.NotRegexp(t, `[a-z0-9]+_[a-z0-9_]+`, text, "untranslated key")
+				for _, expected := range tt.contains {
+					assert.Contains(t, text, expected)
+				}
+			})
+		}
+	}
+}
+
+// translator returns the key with the language it was asked for
+type translator struct{}
+
+func (translator) T(_ context.Context, language, key string, args ...any) string {
+	return language + ":" + key
+}
+
+func TestTemplateRenderer_LanguageVariant(t *testing.T) {
+	fsys := fstest.MapFS{
+		"templates/weather.tmpl":       {Data: []byte(`{{t "weather_updated"}} {{.Updated}}`)},
+		"templates/weather.uk-UA.tmpl": {Data: []byte(`{{.Updated}} ({{t "weather_updated"}})` + "\n\n")},
+	}
+	renderer, err := NewTemplateRenderer(fsys, translator{})
+	require.NoError(t, err)
+	view := WeatherView{Updated: "07:00 UTC"}
+
+	text, err := renderer.Render(Weather, "en-US", view)
+	require.NoError(t, err)
+	assert.Equal(t, "en-US:weather_updated 07:00 UTC", text)
+
+	text, err = renderer.Render(Weather, "uk-UA", view)
+	require.NoError(t, err)
+	assert.Equal(t, "07:00 UTC (uk-UA:weather_updated)", text, "variant wins, trailing blank lines trimmed")
+
+	_, err = renderer.Render(Forecast, "en-US", ForecastView{})
+	assert.Error(t, err, "no such template")
+
+	_, err = renderer.Render(Weather, "en-US", ForecastView{})
+	assert.Error(t, err, "view without the fields the template uses")
+}
+
+func TestNewTemplateRenderer_InvalidTemplate(t *testing.T) {
+	_, err := NewTemplateRenderer(fstest.MapFS{
+		"templates/weather.tmpl": {Data: []byte(`{{if .Stale}}`)},
+	}, translator{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,66 @@
+package render
+
+// WeatherView is what weather.tmpl shows: current conditions with the
+// measurements formatted in the reader's units
+type WeatherView struct {
+	Stale   bool   // Served from the last known copy while the provider is down
+	DataAge string // How old stale data is, e.g. "3 h"
+
+	Location      string
+	Temperature   string
+	FeelsLike     string
+	Humidity      string
+	Wind          string
+	WindDirection int
+	Visibility    string
+	UVIndex       string
+	Pressure      string
+	SunTimes      string // Empty during polar day or night
+	MoonEmoji     string
+	MoonPhase     string
+	Icon          string
+	Description   string
+
+	AQI            string
+	AQIDescription string
+	CO             float64
+	NO2            float64
+	O3             float64
+	PM25           float64
+	PM10           float64
+
+	Tips []string // Clothing and activity tips, empty when the user turned them off
+
+	Updated string
+}
+
+// ForecastView is what forecast.tmpl shows
+type ForecastView struct {
+	Location string
+	Days     []ForecastDayView
+}
+
+// ForecastDayView is one day of a ForecastView
+type ForecastDayView struct {
+	Date        string
+	High        string
+	Low         string
+	Icon        string
+	Description string
+	Humidity    string
+	Wind        string
+	SunTimes    string // Empty during polar day or night
+}
+
+// AirQualityView is what airquality.tmpl shows; pollutants are in μg/m³
+type AirQualityView struct {
+	AQI                  string
+	AQIDescription       string
+	HealthRecommendation string
+	CO                   float64
+	NO2                  float64
+	O3                   float64
+	PM25                 float64
+	PM10                 float64
+	Updated              string
+}
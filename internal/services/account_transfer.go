@@ -0,0 +1,426 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/valpere/shopogoda/internal/models"
+)
+
+// transferCodeTTL is how long a transfer code can be claimed and confirmed
+const transferCodeTTL = 24 * time.Hour
+
+// transferCodeAlphabet leaves out characters that are easy to misread
+const transferCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const transferCodeLength = 8
+
+var (
+	ErrTransferNotFound         = errors.New("transfer not found")
+	ErrTransferExpired          = errors.New("transfer code expired")
+	ErrTransferSelf             = errors.New("transfer code claimed by the account that started it")
+	ErrTransferResolutionNeeded = errors.New("the new account has its own configuration")
+)
+
+// transferUserColumns are the settings and location copied to the new account
+var transferUserColumns = []string{
+	"language", "units", "timezone",
+	"location_name", "latitude", "longitude", "country", "city",
+	"location_city_level", "location_approximate", "activity_tracking_disabled",
+	"secondary_language", "week_start", "quiet_digests", "alert_escalation_disabled",
+}
+
+// AccountTransferService moves a user's configuration to a new Telegram
+// account through a one-time code, see models.AccountTransfer
+type AccountTransferService struct {
+	db     *gorm.DB
+	logger *zerolog.Logger
+	now    func() time.Time
+}
+
+func NewAccountTransferService(db *gorm.DB, logger *zerolog.Logger) *AccountTransferService {
+	return &AccountTransferService{db: db, logger: logger, now: time.Now}
+}
+
+// withTx returns a copy of the service that runs its queries on the transaction db
+func (s *AccountTransferService) withTx(db *gorm.DB) *AccountTransferService {
+	scoped := *s
+	scoped.db = db
+	return &scoped
+}
+
+// NormalizeTransferCode uppercases a code as typed and drops spaces and dashes
+func NormalizeTransferCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
+
+func newTransferCode() (string, error) {
+	buf := make([]byte, transferCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate transfer code: %w", err)
+	}
+	for i := range buf {
+		buf[i] = transferCodeAlphabet[int(buf[i])%len(transferCodeAlphabet)]
+	}
+	return string(buf), nil
+}
+
+// Start issues a transfer code for the old account, cancelling any transfer
+// it still has open. When an admin starts it on the account's behalf the old
+// end counts as confirmed.
+func (s *AccountTransferService) Start(ctx context.Context, fromUserID, startedBy int64) (*models.AccountTransfer, error) {
+	code, err := newTransferCode()
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := &models.AccountTransfer{
+		Code:       code,
+		FromUserID: fromUserID,
+		StartedBy:  startedBy,
+		Status:     models.TransferPending,
+		ExpiresAt:  s.now().UTC().Add(transferCodeTTL),
+	}
+	if startedBy != fromUserID {
+		transfer.FromConfirmed = true
+		transfer.ApprovedBy = startedBy
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.cancelOpen(tx, fromUserID); err != nil {
+			return err
+		}
+		return tx.Create(transfer).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transfer: %w", err)
+	}
+	return transfer, nil
+}
+
+// Cancel cancels the open transfer of the old account, reporting whether it had one
+func (s *AccountTransferService) Cancel(ctx context.Context, fromUserID int64) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&models.AccountTransfer{}).
+		Where("from_user_id = ? AND status IN ?", fromUserID, []string{models.TransferPending, models.TransferClaimed}).
+		Update("status", models.TransferCancelled)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to cancel transfer: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (s *AccountTransferService) cancelOpen(tx *gorm.DB, fromUserID int64) error {
+	return tx.Model(&models.AccountTransfer{}).
+		Where("from_user_id = ? AND status IN ?", fromUserID, []string{models.TransferPending, models.TransferClaimed}).
+		Update("status", models.TransferCancelled).Error
+}
+
+// Get returns a transfer by ID
+func (s *AccountTransferService) Get(ctx context.Context, id uuid.UUID) (*models.AccountTransfer, error) {
+	var transfer models.AccountTransfer
+	if err := s.db.WithContext(ctx).Where("id = ?", id).First(&transfer).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTransferNotFound
+		}
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// GetByCode returns the open transfer behind a code. Used and cancelled codes
+// are not found; expired ones return ErrTransferExpired.
+func (s *AccountTransferService) GetByCode(ctx context.Context, code string) (*models.AccountTransfer, error) {
+	var transfer models.AccountTransfer
+	err := s.db.WithContext(ctx).
+		Where("code = ? AND status IN ?", NormalizeTransferCode(code), []string{models.TransferPending, models.TransferClaimed}).
+		First(&transfer).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTransferNotFound
+		}
+		return nil, err
+	}
+	if s.expired(&transfer) {
+		return nil, ErrTransferExpired
+	}
+	return &transfer, nil
+}
+
+func (s *AccountTransferService) expired(transfer *models.AccountTransfer) bool {
+	return !s.now().Before(transfer.ExpiresAt)
+}
+
+// Claim binds a code to the new account. Claiming a code again from the same
+// account returns the transfer unchanged; a code claimed by another account
+// is not found.
+func (s *AccountTransferService) Claim(ctx context.Context, code string, toUserID int64) (*models.AccountTransfer, error) {
+	transfer, err := s.GetByCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case transfer.FromUserID == toUserID:
+		return nil, ErrTransferSelf
+	case transfer.Status == models.TransferClaimed && transfer.ToUserID == toUserID:
+		return transfer, nil
+	case transfer.Status != models.TransferPending:
+		return nil, ErrTransferNotFound
+	}
+
+	// Only the first claim wins
+	result := s.db.WithContext(ctx).Model(&models.AccountTransfer{}).
+		Where("id = ? AND status = ?", transfer.ID, models.TransferPending).
+		Updates(map[string]interface{}{"status": models.TransferClaimed, "to_user_id": toUserID})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to claim transfer: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrTransferNotFound
+	}
+
+	transfer.Status = models.TransferClaimed
+	transfer.ToUserID = toUserID
+	return transfer, nil
+}
+
+// HasData reports whether an account has a location, subscriptions, alerts
+// or quiet days of its own that a transfer would have to resolve
+func (s *AccountTransferService) HasData(ctx context.Context, userID int64) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Raw(`SELECT
+		(SELECT COUNT(*) FROM users WHERE id = ? AND location_name <> '') +
+		(SELECT COUNT(*) FROM subscriptions WHERE user_id = ? AND is_active = true) +
+		(SELECT COUNT(*) FROM alert_configs WHERE user_id = ? AND is_active = true) +
+		(SELECT COUNT(*) FROM quiet_periods WHERE user_id = ?)`,
+		userID, userID, userID, userID).Scan(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check account data: %w", err)
+	}
+	return count > 0, nil
+}
+
+// ConfirmFrom records that the old account agreed to the transfer. actorID is
+// the old account itself or an admin approving on its behalf; the caller
+// checks the admin's role.
+func (s *AccountTransferService) ConfirmFrom(ctx context.Context, id uuid.UUID, actorID int64) (*models.AccountTransfer, error) {
+	transfer, err := s.openTransfer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{"from_confirmed": true}
+	if actorID != transfer.FromUserID {
+		updates["approved_by"] = actorID
+		transfer.ApprovedBy = actorID
+	}
+	if err := s.db.WithContext(ctx).Model(transfer).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to confirm transfer: %w", err)
+	}
+	transfer.FromConfirmed = true
+	return transfer, nil
+}
+
+// ConfirmTo records that the new account agreed to the transfer. When the new
+// account has a configuration of its own the resolution says what happens to
+// it and ErrTransferResolutionNeeded is returned without one; otherwise the
+// resolution is ignored.
+func (s *AccountTransferService) ConfirmTo(ctx context.Context, id uuid.UUID, userID int64, resolution models.TransferResolution) (*models.AccountTransfer, error) {
+	transfer, err := s.openTransfer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.Status != models.TransferClaimed || transfer.ToUserID != userID {
+		return nil, ErrTransferNotFound
+	}
+
+	hasData, err := s.HasData(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case !hasData:
+		resolution = ""
+	case resolution != models.TransferKeepMine && resolution != models.TransferKeepTheirs && resolution != models.TransferMerge:
+		return nil, ErrTransferResolutionNeeded
+	}
+
+	if err := s.db.WithContext(ctx).Model(transfer).
+		Updates(map[string]interface{}{"to_confirmed": true, "resolution": resolution}).Error; err != nil {
+		return nil, fmt.Errorf("failed to confirm transfer: %w", err)
+	}
+	transfer.ToConfirmed = true
+	transfer.Resolution = resolution
+	return transfer, nil
+}
+
+// openTransfer returns a transfer that is neither finished nor expired
+func (s *AccountTransferService) openTransfer(ctx context.Context, id uuid.UUID) (*models.AccountTransfer, error) {
+	transfer, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.Status != models.TransferPending && transfer.Status != models.TransferClaimed {
+		return nil, ErrTransferNotFound
+	}
+	if s.expired(transfer) {
+		return nil, ErrTransferExpired
+	}
+	return transfer, nil
+}
+
+// complete moves the configuration of a confirmed transfer. It runs on a
+// service bound to a transaction by Services.CompleteTransfer.
+func (s *AccountTransferService) complete(ctx context.Context, id uuid.UUID) (*models.AccountTransfer, error) {
+	db := s.db.WithContext(ctx)
+
+	var transfer models.AccountTransfer
+	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&transfer).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTransferNotFound
+		}
+		return nil, err
+	}
+	if !transfer.ReadyToComplete() {
+		return nil, ErrTransferNotFound
+	}
+	if s.expired(&transfer) {
+		return nil, ErrTransferExpired
+	}
+
+	if err := s.move(db, &transfer); err != nil {
+		return nil, err
+	}
+
+	completedAt := s.now().UTC()
+	if err := db.Model(&transfer).
+		Updates(map[string]interface{}{"status": models.TransferCompleted, "completed_at": completedAt}).Error; err != nil {
+		return nil, err
+	}
+	transfer.Status = models.TransferCompleted
+	transfer.CompletedAt = &completedAt
+	return &transfer, nil
+}
+
+// move hands the old account's configuration and history to the new account
+// as the resolution says, and deactivates the old account. History (triggered
+// alerts and stored weather) always moves, so it stays with the person;
+// delivery state tied to the old chat, pinned widgets and pending follow-ups,
+// is dropped.
+func (s *AccountTransferService) move(db *gorm.DB, transfer *models.AccountTransfer) error {
+	from, to := transfer.FromUserID, transfer.ToUserID
+	reassign := func(model interface{}, where string, args ...interface{}) error {
+		return db.Model(model).Where(where, args...).Update("user_id", to).Error
+	}
+	deactivate := func(model interface{}, userID int64) error {
+		return db.Model(model).Where("user_id = ? AND is_active = ?", userID, true).Update("is_active", false).Error
+	}
+
+	switch transfer.Resolution {
+	case models.TransferKeepMine:
+		// The old configuration stays behind, switched off with the account
+		if err := deactivate(&models.Subscription{}, from); err != nil {
+			return err
+		}
+		if err := deactivate(&models.AlertConfig{}, from); err != nil {
+			return err
+		}
+
+	case models.TransferMerge:
+		// A subscription type the new account already has keeps its own
+		if err := reassign(&models.Subscription{},
+			"user_id = ? AND is_active = ? AND subscription_type NOT IN (?)", from, true,
+			db.Model(&models.Subscription{}).Select("subscription_type").Where("user_id = ? AND is_active = ?", to, true)); err != nil {
+			return err
+		}
+		if err := deactivate(&models.Subscription{}, from); err != nil {
+			return err
+		}
+		if err := reassign(&models.AlertConfig{}, "user_id = ?", from); err != nil {
+			return err
+		}
+		if err := reassign(&models.QuietPeriod{}, "user_id = ?", from); err != nil {
+			return err
+		}
+		// Saved places join the new account's, which keeps its own default
+		if err := db.Model(&models.UserLocation{}).Where("user_id = ?", from).
+			Updates(map[string]interface{}{"user_id": to, "is_default": false}).Error; err != nil {
+			return err
+		}
+
+	default:
+		// Keep theirs, or nothing to resolve: the old configuration replaces the new one
+		if transfer.Resolution == models.TransferKeepTheirs {
+			if err := deactivate(&models.Subscription{}, to); err != nil {
+				return err
+			}
+			if err := deactivate(&models.AlertConfig{}, to); err != nil {
+				return err
+			}
+			if err := db.Where("user_id = ?", to).Delete(&models.QuietPeriod{}).Error; err != nil {
+				return err
+			}
+			if err := db.Where("user_id = ?", to).Delete(&models.UserLocation{}).Error; err != nil {
+				return err
+			}
+		}
+
+		var old models.User
+		if err := db.Select(transferUserColumns).Where("id = ?", from).First(&old).Error; err != nil {
+			return fmt.Errorf("failed to load old account: %w", err)
+		}
+		if err := db.Model(&models.User{}).Where("id = ?", to).Select(transferUserColumns).Updates(&old).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&models.Subscription{}, &models.AlertConfig{}, &models.QuietPeriod{}, &models.UserLocation{}} {
+			if err := reassign(model, "user_id = ?", from); err != nil {
+				return err
+			}
+		}
+	}
+
+	// History follows the person whatever the resolution
+	if err := reassign(&models.EnvironmentalAlert{}, "user_id = ?", from); err != nil {
+		return err
+	}
+	if err := reassign(&models.WeatherData{}, "user_id = ?", from); err != nil {
+		return err
+	}
+	if err := reassign(&models.WeatherHistory{}, "user_id = ?", from); err != nil {
+		return err
+	}
+	if err := reassign(&models.WeatherRecord{}, "user_id = ?", from); err != nil {
+		return err
+	}
+
+	// Delivery state of the old chat
+	if err := db.Where("user_id = ?", from).Delete(&models.WeatherWidget{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("user_id = ?", from).Delete(&models.AlertEscalation{}).Error; err != nil {
+		return err
+	}
+
+	return db.Model(&models.User{}).Where("id = ?", from).Update("is_active", false).Error
+}
+
+// logCompleted writes the audit log entry of a completed transfer
+func (s *AccountTransferService) logCompleted(transfer *models.AccountTransfer) {
+	s.logger.Info().
+		Str("transfer_id", transfer.ID.String()).
+		Int64("from_user_id", transfer.FromUserID).
+		Int64("to_user_id", transfer.ToUserID).
+		Int64("started_by", transfer.StartedBy).
+		Int64("approved_by", transfer.ApprovedBy).
+		Str("resolution", string(transfer.Resolution)).
+		Msg("Account transferred")
+}
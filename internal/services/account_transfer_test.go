@@ -0,0 +1,496 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/metrics"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+const (
+	transferFrom = int64(100)
+	transferTo   = int64(200)
+)
+
+var transferNow = time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+var transferColumns = []string{"id", "code", "from_user_id", "to_user_id", "started_by", "status", "from_confirmed", "to_confirmed", "resolution", "expires_at"}
+
+// expectTransferRow returns a transfer row for the next SELECT on account_transfers
+func expectTransferRow(mockDB *helpers.MockDB, query string, transfer *models.AccountTransfer) {
+	mockDB.Mock.ExpectQuery(query).WillReturnRows(mockDB.Mock.NewRows(transferColumns).AddRow(
+		transfer.ID, transfer.Code, transfer.FromUserID, transfer.ToUserID, transfer.StartedBy, transfer.Status,
+		transfer.FromConfirmed, transfer.ToConfirmed, transfer.Resolution, transfer.ExpiresAt))
+}
+
+func newTestTransferService(mockDB *helpers.MockDB) *AccountTransferService {
+	service := NewAccountTransferService(mockDB.DB, helpers.NewSilentTestLogger())
+	service.now = func() time.Time { return transferNow }
+	return service
+}
+
+func TestNormalizeTransferCode(t *testing.T) {
+	assert.Equal(t, "ABCD2345", NormalizeTransferCode(" abcd-2345 "))
+}
+
+func TestAccountTransferService_Start(t *testing.T) {
+	t.Run("by the old account", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := newTestTransferService(mockDB)
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "account_transfers" SET "status"=\$1,"updated_at"=\$2 WHERE from_user_id = \$3 AND status IN \(\$4,\$5\)`).
+			WithArgs(models.TransferCancelled, helpers.AnyTime{}, transferFrom, models.TransferPending, models.TransferClaimed).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectQuery(`INSERT INTO "account_transfers"`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mockDB.Mock.ExpectCommit()
+
+		transfer, err := service.Start(context.Background(), transferFrom, transferFrom)
+
+		require.NoError(t, err)
+		assert.Len(t, transfer.Code, transferCodeLength)
+		for _, c := range transfer.Code {
+			assert.Contains(t, transferCodeAlphabet, string(c))
+		}
+		assert.Equal(t, models.TransferPending, transfer.Status)
+		assert.Equal(t, transferNow.Add(24*time.Hour), transfer.ExpiresAt)
+		assert.False(t, transfer.FromConfirmed, "the old account confirms once the code is claimed")
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("by an admin", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := newTestTransferService(mockDB)
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "account_transfers"`).WillReturnResult(helpers.NewResult(0, 0))
+		mockDB.Mock.ExpectQuery(`INSERT INTO "account_transfers"`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mockDB.Mock.ExpectCommit()
+
+		transfer, err := service.Start(context.Background(), transferFrom, 1)
+
+		require.NoError(t, err)
+		assert.True(t, transfer.FromConfirmed)
+		assert.Equal(t, int64(1), transfer.ApprovedBy)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestAccountTransferService_Claim(t *testing.T) {
+	const selectByCode = `SELECT \* FROM "account_transfers" WHERE code = \$1 AND status IN \(\$2,\$3\)`
+	pending := func() *models.AccountTransfer {
+		return &models.AccountTransfer{
+			ID:         uuid.New(),
+			Code:       "ABCD2345",
+			FromUserID: transferFrom,
+			StartedBy:  transferFrom,
+			Status:     models.TransferPending,
+			ExpiresAt:  transferNow.Add(time.Hour),
+		}
+	}
+
+	t.Run("first claim", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := newTestTransferService(mockDB)
+		transfer := pending()
+
+		expectTransferRow(mockDB, selectByCode, transfer)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "account_transfers" SET "status"=\$1,"to_user_id"=\$2,"updated_at"=\$3 WHERE id = \$4 AND status = \$5`).
+			WithArgs(models.TransferClaimed, transferTo, helpers.AnyTime{}, transfer.ID, models.TransferPending).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+
+		claimed, err := service.Claim(context.Background(), "abcd-2345", transferTo)
+
+		require.NoError(t, err)
+		assert.Equal(t, models.TransferClaimed, claimed.Status)
+		assert.Equal(t, transferTo, claimed.ToUserID)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("claimed again by the same account", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := newTestTransferService(mockDB)
+		transfer := pending()
+		transfer.Status, transfer.ToUserID = models.TransferClaimed, transferTo
+
+		expectTransferRow(mockDB, selectByCode, transfer)
+
+		claimed, err := service.Claim(context.Background(), transfer.Code, transferTo)
+
+		require.NoError(t, err)
+		assert.Equal(t, transfer.ID, claimed.ID)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("lost the race to another claim", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := newTestTransferService(mockDB)
+
+		expectTransferRow(mockDB, selectByCode, pending())
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "account_transfers"`).WillReturnResult(helpers.NewResult(0, 0))
+		mockDB.Mock.ExpectCommit()
+
+		_, err := service.Claim(context.Background(), "ABCD2345", transferTo)
+
+		assert.ErrorIs(t, err, ErrTransferNotFound)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	tests := []struct {
+		name   string
+		modify func(*models.AccountTransfer)
+		userID int64
+		err    error
+	}{
+		{"claimed by another account", func(tr *models.AccountTransfer) { tr.Status, tr.ToUserID = models.TransferClaimed, 300 }, transferTo, ErrTransferNotFound},
+		{"claimed by the old account", func(*models.AccountTransfer) {}, transferFrom, ErrTransferSelf},
+		{"expired", func(tr *models.AccountTransfer) { tr.ExpiresAt = transferNow }, transferTo, ErrTransferExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := helpers.NewMockDB(t)
+			defer func() { _ = mockDB.Close() }()
+			service := newTestTransferService(mockDB)
+			transfer := pending()
+			tt.modify(transfer)
+
+			expectTransferRow(mockDB, selectByCode, transfer)
+
+			_, err := service.Claim(context.Background(), transfer.Code, tt.userID)
+
+			assert.ErrorIs(t, err, tt.err)
+			mockDB.ExpectationsWereMet(t)
+		})
+	}
+
+	t.Run("unknown, used or cancelled code", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := newTestTransferService(mockDB)
+
+		mockDB.Mock.ExpectQuery(selectByCode).WillReturnRows(mockDB.Mock.NewRows(transferColumns))
+
+		_, err := service.Claim(context.Background(), "ZZZZ2222", transferTo)
+
+		assert.ErrorIs(t, err, ErrTransferNotFound)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestAccountTransferService_ConfirmTo(t *testing.T) {
+	claimed := func() *models.AccountTransfer {
+		return &models.AccountTransfer{
+			ID:         uuid.New(),
+			Code:       "ABCD2345",
+			FromUserID: transferFrom,
+			ToUserID:   transferTo,
+			Status:     models.TransferClaimed,
+			ExpiresAt:  transferNow.Add(time.Hour),
+		}
+	}
+	expectHasData := func(mockDB *helpers.MockDB, count int) {
+		mockDB.Mock.ExpectQuery(`SELECT .* FROM users WHERE id = \$1`).
+			WithArgs(transferTo, transferTo, transferTo, transferTo).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(count))
+	}
+
+	t.Run("new account with its own data must choose", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := newTestTransferService(mockDB)
+		transfer := claimed()
+
+		expectTransferRow(mockDB, `SELECT \* FROM "account_transfers" WHERE id = \$1`, transfer)
+		expectHasData(mockDB, 2)
+
+		_, err := service.ConfirmTo(context.Background(), transfer.ID, transferTo, "")
+
+		assert.ErrorIs(t, err, ErrTransferResolutionNeeded)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("new account with its own data chooses", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := newTestTransferService(mockDB)
+		transfer := claimed()
+
+		expectTransferRow(mockDB, `SELECT \* FROM "account_transfers" WHERE id = \$1`, transfer)
+		expectHasData(mockDB, 1)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "account_transfers" SET "resolution"=\$1,"to_confirmed"=\$2,"updated_at"=\$3 WHERE "id" = \$4`).
+			WithArgs(models.TransferMerge, true, helpers.AnyTime{}, transfer.ID).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+
+		confirmed, err := service.ConfirmTo(context.Background(), transfer.ID, transferTo, models.TransferMerge)
+
+		require.NoError(t, err)
+		assert.True(t, confirmed.ToConfirmed)
+		assert.Equal(t, models.TransferMerge, confirmed.Resolution)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("empty new account needs no resolution", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := newTestTransferService(mockDB)
+		transfer := claimed()
+
+		expectTransferRow(mockDB, `SELECT \* FROM "account_transfers" WHERE id = \$1`, transfer)
+		expectHasData(mockDB, 0)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "account_transfers"`).
+			WithArgs(models.TransferResolution(""), true, helpers.AnyTime{}, transfer.ID).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+
+		confirmed, err := service.ConfirmTo(context.Background(), transfer.ID, transferTo, models.TransferKeepMine)
+
+		require.NoError(t, err)
+		assert.Empty(t, confirmed.Resolution)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("only the claiming account confirms", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := newTestTransferService(mockDB)
+		transfer := claimed()
+
+		expectTransferRow(mockDB, `SELECT \* FROM "account_transfers" WHERE id = \$1`, transfer)
+
+		_, err := service.ConfirmTo(context.Background(), transfer.ID, 300, "")
+
+		assert.ErrorIs(t, err, ErrTransferNotFound)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestServices_CompleteTransfer(t *testing.T) {
+	newServices := func(t *testing.T) (*Services, *helpers.MockDB, *redisRecorder) {
+		mockDB := helpers.NewMockDB(t)
+		t.Cleanup(func() { _ = mockDB.Close() })
+		redisClient, recorder := newRecordingRedis()
+		logger := helpers.NewSilentTestLogger()
+
+		svcs := &Services{
+			User:         NewUserService(mockDB.DB, redisClient, metrics.New(), logger, time.Now()),
+			Alert:        NewAlertService(mockDB.DB, redisClient),
+			Subscription: NewSubscriptionService(mockDB.DB, redisClient),
+			Presets:      NewPresetService(mockDB.DB, nil, logger),
+			Transfers:    newTestTransferService(mockDB),
+			db:           mockDB.DB,
+		}
+		return svcs, mockDB, recorder
+	}
+	confirmed := func(resolution models.TransferResolution) *models.AccountTransfer {
+		return &models.AccountTransfer{
+			ID:            uuid.New(),
+			Code:          "ABCD2345",
+			FromUserID:    transferFrom,
+			ToUserID:      transferTo,
+			StartedBy:     transferFrom,
+			Status:        models.TransferClaimed,
+			FromConfirmed: true,
+			ToConfirmed:   true,
+			Resolution:    resolution,
+			ExpiresAt:     transferNow.Add(time.Hour),
+		}
+	}
+	expectLocked := func(mockDB *helpers.MockDB, transfer *models.AccountTransfer) {
+		mockDB.Mock.ExpectBegin()
+		expectTransferRow(mockDB, `SELECT \* FROM "account_transfers" WHERE id = \$1 .* FOR UPDATE`, transfer)
+	}
+	expectDeactivate := func(mockDB *helpers.MockDB, table string, userID int64) {
+		mockDB.Mock.ExpectExec(`UPDATE "`+table+`" SET "is_active"=\$1,"updated_at"=\$2 WHERE user_id = \$3 AND is_active = \$4`).
+			WithArgs(false, helpers.AnyTime{}, userID, true).
+			WillReturnResult(helpers.NewResult(0, 1))
+	}
+	expectMove := func(mockDB *helpers.MockDB, table string) {
+		mockDB.Mock.ExpectExec(`UPDATE "` + table + `" SET "user_id"=\$1.* WHERE user_id = \$\d+$`).
+			WillReturnResult(helpers.NewResult(0, 3))
+	}
+	expectOldSettings := func(mockDB *helpers.MockDB) {
+		mockDB.Mock.ExpectQuery(`SELECT "language","units","timezone","location_name",.* FROM "users" WHERE id = \$1`).
+			WithArgs(transferFrom, 1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"language", "units", "timezone", "location_name", "latitude", "longitude"}).
+				AddRow("uk-UA", "metric", "Europe/Kyiv", "Kyiv", 50.45, 30.52))
+		mockDB.Mock.ExpectExec(`UPDATE "users" SET "language"=\$1,"units"=\$2,"timezone"=\$3,"location_name"=\$4,.* WHERE id = \$\d+`).
+			WillReturnResult(helpers.NewResult(0, 1))
+	}
+	// History follows the person and the old chat's delivery state is dropped
+	// whatever the resolution
+	expectFinish := func(mockDB *helpers.MockDB, transfer *models.AccountTransfer) {
+		mockDB.Mock.ExpectExec(`UPDATE "environmental_alerts" SET "user_id"=\$1,"updated_at"=\$2 WHERE user_id = \$3`).
+			WithArgs(transferTo, helpers.AnyTime{}, transferFrom).
+			WillReturnResult(helpers.NewResult(0, 12))
+		mockDB.Mock.ExpectExec(`UPDATE "weather_data" SET "user_id"=\$1 WHERE user_id = \$2`).
+			WithArgs(transferTo, transferFrom).
+			WillReturnResult(helpers.NewResult(0, 40))
+		mockDB.Mock.ExpectExec(`UPDATE "weather_history" SET "user_id"=\$1 WHERE user_id = \$2`).
+			WithArgs(transferTo, transferFrom).
+			WillReturnResult(helpers.NewResult(0, 24))
+		mockDB.Mock.ExpectExec(`UPDATE "weather_records" SET "user_id"=\$1 WHERE user_id = \$2`).
+			WithArgs(transferTo, transferFrom).
+			WillReturnResult(helpers.NewResult(0, 12))
+		mockDB.Mock.ExpectExec(`DELETE FROM "weather_widgets" WHERE user_id = \$1`).
+			WithArgs(transferFrom).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectExec(`DELETE FROM "alert_escalations" WHERE user_id = \$1`).
+			WithArgs(transferFrom).
+			WillReturnResult(helpers.NewResult(0, 0))
+		mockDB.Mock.ExpectExec(`UPDATE "users" SET "is_active"=\$1,"updated_at"=\$2 WHERE id = \$3`).
+			WithArgs(false, helpers.AnyTime{}, transferFrom).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectExec(`UPDATE "account_transfers" SET "completed_at"=\$1,"status"=\$2,"updated_at"=\$3 WHERE "id" = \$4`).
+			WithArgs(transferNow, models.TransferCompleted, helpers.AnyTime{}, transfer.ID).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+	}
+
+	t.Run("empty new account takes everything", func(t *testing.T) {
+		svcs, mockDB, recorder := newServices(t)
+		transfer := confirmed("")
+
+		expectLocked(mockDB, transfer)
+		expectOldSettings(mockDB)
+		expectMove(mockDB, "subscriptions")
+		expectMove(mockDB, "alert_configs")
+		expectMove(mockDB, "quiet_periods")
+		expectMove(mockDB, "user_locations")
+		expectFinish(mockDB, transfer)
+
+		completed, err := svcs.CompleteTransfer(context.Background(), transfer.ID)
+
+		require.NoError(t, err)
+		assert.Equal(t, models.TransferCompleted, completed.Status)
+		assert.Equal(t, transferNow, *completed.CompletedAt)
+		assert.Equal(t, []string{"del user:100", "del user:200"}, recorder.commands)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("keep theirs switches off the new account's configuration", func(t *testing.T) {
+		svcs, mockDB, _ := newServices(t)
+		transfer := confirmed(models.TransferKeepTheirs)
+
+		expectLocked(mockDB, transfer)
+		expectDeactivate(mockDB, "subscriptions", transferTo)
+		expectDeactivate(mockDB, "alert_configs", transferTo)
+		mockDB.Mock.ExpectExec(`DELETE FROM "quiet_periods" WHERE user_id = \$1`).
+			WithArgs(transferTo).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectExec(`DELETE FROM "user_locations" WHERE user_id = \$1`).
+			WithArgs(transferTo).
+			WillReturnResult(helpers.NewResult(0, 2))
+		expectOldSettings(mockDB)
+		expectMove(mockDB, "subscriptions")
+		expectMove(mockDB, "alert_configs")
+		expectMove(mockDB, "quiet_periods")
+		expectMove(mockDB, "user_locations")
+		expectFinish(mockDB, transfer)
+
+		_, err := svcs.CompleteTransfer(context.Background(), transfer.ID)
+
+		require.NoError(t, err)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("keep mine leaves the old configuration switched off", func(t *testing.T) {
+		svcs, mockDB, _ := newServices(t)
+		transfer := confirmed(models.TransferKeepMine)
+
+		expectLocked(mockDB, transfer)
+		expectDeactivate(mockDB, "subscriptions", transferFrom)
+		expectDeactivate(mockDB, "alert_configs", transferFrom)
+		expectFinish(mockDB, transfer)
+
+		_, err := svcs.CompleteTransfer(context.Background(), transfer.ID)
+
+		require.NoError(t, err)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("merge adds subscription types the new account lacks", func(t *testing.T) {
+		svcs, mockDB, _ := newServices(t)
+		transfer := confirmed(models.TransferMerge)
+
+		expectLocked(mockDB, transfer)
+		mockDB.Mock.ExpectExec(`UPDATE "subscriptions" SET "user_id"=\$1,"updated_at"=\$2 WHERE user_id = \$3 AND is_active = \$4 AND subscription_type NOT IN \(SELECT "subscription_type" FROM "subscriptions" WHERE user_id = \$5 AND is_active = \$6\)`).
+			WithArgs(transferTo, helpers.AnyTime{}, transferFrom, true, transferTo, true).
+			WillReturnResult(helpers.NewResult(0, 1))
+		expectDeactivate(mockDB, "subscriptions", transferFrom)
+		expectMove(mockDB, "alert_configs")
+		expectMove(mockDB, "quiet_periods")
+		mockDB.Mock.ExpectExec(`UPDATE "user_locations" SET "is_default"=\$1,"user_id"=\$2 WHERE user_id = \$3`).
+			WithArgs(false, transferTo, transferFrom).
+			WillReturnResult(helpers.NewResult(0, 2))
+		expectFinish(mockDB, transfer)
+
+		_, err := svcs.CompleteTransfer(context.Background(), transfer.ID)
+
+		require.NoError(t, err)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("failure rolls everything back", func(t *testing.T) {
+		svcs, mockDB, recorder := newServices(t)
+		transfer := confirmed("")
+
+		expectLocked(mockDB, transfer)
+		expectOldSettings(mockDB)
+		mockDB.Mock.ExpectExec(`UPDATE "subscriptions"`).WillReturnError(assert.AnError)
+		mockDB.Mock.ExpectRollback()
+
+		_, err := svcs.CompleteTransfer(context.Background(), transfer.ID)
+
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.Empty(t, recorder.commands, "caches are only dropped after commit")
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("not confirmed by both ends", func(t *testing.T) {
+		svcs, mockDB, _ := newServices(t)
+		transfer := confirmed("")
+		transfer.FromConfirmed = false
+
+		expectLocked(mockDB, transfer)
+		mockDB.Mock.ExpectRollback()
+
+		_, err := svcs.CompleteTransfer(context.Background(), transfer.ID)
+
+		assert.ErrorIs(t, err, ErrTransferNotFound)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		svcs, mockDB, _ := newServices(t)
+		transfer := confirmed("")
+		transfer.ExpiresAt = transferNow.Add(-time.Minute)
+
+		expectLocked(mockDB, transfer)
+		mockDB.Mock.ExpectRollback()
+
+		_, err := svcs.CompleteTransfer(context.Background(), transfer.ID)
+
+		assert.ErrorIs(t, err, ErrTransferExpired)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
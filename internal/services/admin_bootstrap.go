@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/valpere/shopogoda/internal/models"
+)
+
+const (
+	// adminClaimKey holds the pending first-run claim as "<user id>:<code>"
+	adminClaimKey = "admin_claim"
+	// adminClaimTTL is how long a claim code can be redeemed; after that the
+	// next user to send /start is offered a new one
+	adminClaimTTL = 24 * time.Hour
+)
+
+// ErrAdminClaimInvalid is returned for a claim code that is wrong, expired,
+// issued to another user, or no longer needed because an admin exists
+var ErrAdminClaimInvalid = errors.New("admin claim code is invalid or expired")
+
+// ParseAdminIDs splits the comma-separated ADMIN_TELEGRAM_IDS list. Entries
+// that are not Telegram user IDs are returned as invalid.
+func ParseAdminIDs(list string) (ids []int64, invalid []string) {
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(entry, 10, 64)
+		if err != nil || id <= 0 {
+			invalid = append(invalid, entry)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, invalid
+}
+
+// SetBootstrapAdmins sets the users that are made admins at startup, or when
+// they register if they have not yet
+func (s *UserService) SetBootstrapAdmins(ids []int64) {
+	s.bootstrapAdmins = make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		s.bootstrapAdmins[id] = true
+	}
+}
+
+// BootstrapAdmins promotes the listed users that are already registered and
+// returns how many were promoted. It only ever promotes: admins missing from
+// the list keep their role, and demotion stays an explicit admin action.
+func (s *UserService) BootstrapAdmins(ctx context.Context) (int, error) {
+	if len(s.bootstrapAdmins) == 0 {
+		return 0, nil
+	}
+	ids := make([]int64, 0, len(s.bootstrapAdmins))
+	for id := range s.bootstrapAdmins {
+		ids = append(ids, id)
+	}
+
+	var users []models.User
+	if err := s.db.WithContext(ctx).Where("id IN ? AND role <> ?", ids, models.RoleAdmin).Find(&users).Error; err != nil {
+		return 0, fmt.Errorf("failed to load bootstrap admins: %w", err)
+	}
+	for i := range users {
+		if err := s.promoteToAdmin(ctx, &users[i], "bootstrap", 0); err != nil {
+			return i, err
+		}
+	}
+	return len(users), nil
+}
+
+// OfferAdminClaim issues the first-run admin claim code when the deployment
+// has no admin at all. Only the first user to ask while no code is pending
+// gets the offer; the code itself is written to the server log, so only
+// whoever runs the deployment can pass it on. It reports whether the user
+// was offered the claim.
+func (s *UserService) OfferAdminClaim(ctx context.Context, userID int64) (bool, error) {
+	if s.adminExists.Load() {
+		return false, nil
+	}
+	admins, err := s.countAdmins(ctx)
+	if err != nil || admins > 0 {
+		return false, err
+	}
+
+	code, err := newTransferCode()
+	if err != nil {
+		return false, err
+	}
+	claimed, err := s.redis.SetNX(ctx, adminClaimKey, strconv.FormatInt(userID, 10)+":"+code, adminClaimTTL).Result()
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	s.logger.Warn().
+		Int64("user_id", userID).
+		Str("claim_code", code).
+		Msg("No admin exists; the user can become admin with /claimadmin and this code")
+	return true, nil
+}
+
+// ClaimAdmin redeems the first-run claim code, making the user admin. The
+// code works once, only for the user it was offered to, and only while the
+// deployment still has no admin.
+func (s *UserService) ClaimAdmin(ctx context.Context, userID int64, code string) error {
+	pending, err := s.redis.Get(ctx, adminClaimKey).Result()
+	if errors.Is(err, redis.Nil) {
+		return ErrAdminClaimInvalid
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read admin claim: %w", err)
+	}
+	if pending != strconv.FormatInt(userID, 10)+":"+NormalizeTransferCode(code) {
+		return ErrAdminClaimInvalid
+	}
+
+	admins, err := s.countAdmins(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.redis.Del(ctx, adminClaimKey).Err(); err != nil {
+		return fmt.Errorf("failed to consume admin claim: %w", err)
+	}
+	if admins > 0 {
+		return ErrAdminClaimInvalid
+	}
+
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get claiming user: %w", err)
+	}
+	return s.promoteToAdmin(ctx, user, "claim", user.ID)
+}
+
+func (s *UserService) countAdmins(ctx context.Context) (int64, error) {
+	var admins int64
+	if err := s.db.WithContext(ctx).Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&admins).Error; err != nil {
+		return 0, fmt.Errorf("failed to count admins: %w", err)
+	}
+	if admins > 0 {
+		s.adminExists.Store(true)
+	}
+	return admins, nil
+}
+
+// promoteToAdmin stores the admin role of a user and writes the audit entry
+// naming what granted it, on behalf of the actor: the claiming user, or 0
+// for the deployment
+func (s *UserService) promoteToAdmin(ctx context.Context, user *models.User, via string, actorID int64) error {
+	cacheKey := fmt.Sprintf("user:%d", user.ID)
+	if err := s.invalidateCache(ctx, cacheKey); err != nil {
+		s.logger.Warn().Err(err).Str("cache_key", cacheKey).Msg("Failed to invalidate user cache before role change")
+	}
+
+	if err := s.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", user.ID).Update("role", models.RoleAdmin).Error; err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+
+	s.logger.Info().
+		Str("actor", via).
+		Int64("target_user_id", user.ID).
+		Str("target_username", user.Username).
+		Int("old_role", int(user.Role)).
+		Int("new_role", int(models.RoleAdmin)).
+		Msg("User role changed")
+	s.auditRoleChange(ctx, actorID, user.ID, user.Role, models.RoleAdmin, via)
+	s.adminExists.Store(true)
+	return nil
+}
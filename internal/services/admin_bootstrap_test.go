@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func newBootstrapTestService(t *testing.T) (*UserService, *helpers.MockDB, *helpers.MockRedis) {
+	mockDB := helpers.NewMockDB(t)
+	t.Cleanup(func() { _ = mockDB.Close() })
+	mockRedis := helpers.NewMockRedis()
+	service := NewUserService(mockDB.DB, mockRedis.Client, nil, helpers.NewSilentTestLogger(), time.Now())
+	return service, mockDB, mockRedis
+}
+
+func TestParseAdminIDs(t *testing.T) {
+	ids, invalid := ParseAdminIDs(" 123, 456 ,,abc,-7")
+	assert.Equal(t, []int64{123, 456}, ids)
+	assert.Equal(t, []string{"abc", "-7"}, invalid)
+
+	ids, invalid = ParseAdminIDs("")
+	assert.Empty(t, ids)
+	assert.Empty(t, invalid)
+}
+
+func TestUserService_BootstrapAdmins(t *testing.T) {
+	t.Run("promotes listed users that are registered", func(t *testing.T) {
+		service, mockDB, mockRedis := newBootstrapTestService(t)
+		service.SetBootstrapAdmins([]int64{100})
+		service.SetAudit(NewAuditService(mockDB.DB))
+
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE \(id IN \(\$1\) AND role <> \$2\) AND "users"."deleted_at" IS NULL`).
+			WithArgs(int64(100), models.RoleAdmin).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id", "username", "role"}).AddRow(100, "owner", models.RoleModerator))
+		mockRedis.Mock.ExpectDel("user:100").SetVal(1)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "users" SET "role"=\$1,"updated_at"=\$2 WHERE id = \$3`).
+			WithArgs(models.RoleAdmin, helpers.AnyTime{}, int64(100)).
+			WillReturnResult(helpers.NewResult(1, 1))
+		mockDB.Mock.ExpectCommit()
+		// The deployment made the change, so no admin is named
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+			WithArgs(int64(0), AuditRoleChanged, int64(100), []byte(`{"new_role":3,"old_role":2,"via":"bootstrap"}`), "", helpers.AnyTime{}).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mockDB.Mock.ExpectCommit()
+
+		promoted, err := service.BootstrapAdmins(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, promoted)
+		mockDB.ExpectationsWereMet(t)
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+
+	// Admins missing from the list and listed users who already are admins
+	// are left alone; the bootstrap only ever writes the admin role
+	t.Run("never demotes", func(t *testing.T) {
+		service, mockDB, mockRedis := newBootstrapTestService(t)
+		service.SetBootstrapAdmins([]int64{100})
+
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE \(id IN \(\$1\) AND role <> \$2\) AND "users"."deleted_at" IS NULL`).
+			WithArgs(int64(100), models.RoleAdmin).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id", "username", "role"}))
+
+		promoted, err := service.BootstrapAdmins(context.Background())
+		require.NoError(t, err)
+		assert.Zero(t, promoted)
+		mockDB.ExpectationsWereMet(t)
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("no list does nothing", func(t *testing.T) {
+		service, mockDB, _ := newBootstrapTestService(t)
+
+		promoted, err := service.BootstrapAdmins(context.Background())
+		require.NoError(t, err)
+		assert.Zero(t, promoted)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestUserService_RegisterUser_BootstrapAdmin(t *testing.T) {
+	tgUser := &gotgbot.User{Id: 100, Username: "owner", FirstName: "Owner", LanguageCode: "en"}
+	insertArgs := func(role models.UserRole) []driver.Value {
+		return []driver.Value{
+			"owner", "Owner", "", "en-US", "metric", "UTC", role, true,
+			"", float64(0), float64(0), "", "", false, false, false, "", "", false, false, false, int64(3), true, "",
+			helpers.AnyTime{}, nil, nil, nil, helpers.AnyTime{}, helpers.AnyTime{}, nil, int64(100),
+		}
+	}
+
+	t.Run("listed user registering for the first time starts as admin", func(t *testing.T) {
+		service, mockDB, _ := newBootstrapTestService(t)
+		service.SetBootstrapAdmins([]int64{100})
+
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE id = \$1`).
+			WithArgs(int64(100)).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(0))
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "users"`).
+			WithArgs(insertArgs(models.RoleAdmin)...).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(100))
+		mockDB.Mock.ExpectCommit()
+
+		require.NoError(t, service.RegisterUser(context.Background(), tgUser))
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	// The upsert of a registered user never touches the role, so an admin
+	// demoted explicitly stays demoted while still listed
+	t.Run("registered listed user keeps their role", func(t *testing.T) {
+		service, mockDB, _ := newBootstrapTestService(t)
+		service.SetBootstrapAdmins([]int64{100})
+
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE id = \$1`).
+			WithArgs(int64(100)).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(1))
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "users" .* ON CONFLICT \("id"\) DO UPDATE SET "username"="excluded"."username","first_name"="excluded"."first_name","last_name"="excluded"."last_name","last_active_at"="excluded"."last_active_at","updated_at"="excluded"."updated_at","is_active"="users"."deactivated_at" IS NULL`).
+			WithArgs(insertArgs(models.RoleUser)...).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(100))
+		mockDB.Mock.ExpectCommit()
+
+		require.NoError(t, service.RegisterUser(context.Background(), tgUser))
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestUserService_AdminClaim(t *testing.T) {
+	service, mockDB, mockRedis := newBootstrapTestService(t)
+	service.SetAudit(NewAuditService(mockDB.DB))
+	ctx := context.Background()
+	countAdmins := func(admins int) {
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE role = \$1`).
+			WithArgs(models.RoleAdmin).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(admins))
+	}
+
+	// The first user to start a bot without admins is offered the claim
+	countAdmins(0)
+	mockRedis.Mock.Regexp().ExpectSetNX(adminClaimKey, `^42:[A-Z2-9]{8}$`, adminClaimTTL).SetVal(true)
+	offered, err := service.OfferAdminClaim(ctx, 42)
+	require.NoError(t, err)
+	assert.True(t, offered)
+
+	// Anyone else starting it while the code is pending is not
+	countAdmins(0)
+	mockRedis.Mock.Regexp().ExpectSetNX(adminClaimKey, `^43:`, adminClaimTTL).SetVal(false)
+	offered, err = service.OfferAdminClaim(ctx, 43)
+	require.NoError(t, err)
+	assert.False(t, offered)
+
+	// The code only works for the user it was offered to
+	mockRedis.Mock.ExpectGet(adminClaimKey).SetVal("42:ABCD2345")
+	assert.ErrorIs(t, service.ClaimAdmin(ctx, 43, "ABCD2345"), ErrAdminClaimInvalid)
+	mockRedis.Mock.ExpectGet(adminClaimKey).SetVal("42:ABCD2345")
+	assert.ErrorIs(t, service.ClaimAdmin(ctx, 42, "WRONG234"), ErrAdminClaimInvalid)
+
+	// Redeeming it makes the user admin and consumes the code
+	mockRedis.Mock.ExpectGet(adminClaimKey).SetVal("42:ABCD2345")
+	countAdmins(0)
+	mockRedis.Mock.ExpectDel(adminClaimKey).SetVal(1)
+	mockRedis.Mock.ExpectGet("user:42").SetVal(`{"id":42,"username":"first","role":1}`)
+	mockRedis.Mock.ExpectDel("user:42").SetVal(1)
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectExec(`UPDATE "users" SET "role"=\$1,"updated_at"=\$2 WHERE id = \$3`).
+		WithArgs(models.RoleAdmin, helpers.AnyTime{}, int64(42)).
+		WillReturnResult(helpers.NewResult(1, 1))
+	mockDB.Mock.ExpectCommit()
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(int64(42), AuditRoleChanged, int64(42), []byte(`{"new_role":3,"old_role":1,"via":"claim"}`), "", helpers.AnyTime{}).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mockDB.Mock.ExpectCommit()
+	require.NoError(t, service.ClaimAdmin(ctx, 42, "abcd-2345"))
+
+	// A used code is gone
+	mockRedis.Mock.ExpectGet(adminClaimKey).RedisNil()
+	assert.ErrorIs(t, service.ClaimAdmin(ctx, 42, "ABCD2345"), ErrAdminClaimInvalid)
+
+	// With an admin in place nobody is offered a claim any more
+	offered, err = service.OfferAdminClaim(ctx, 44)
+	require.NoError(t, err)
+	assert.False(t, offered)
+
+	mockDB.ExpectationsWereMet(t)
+	assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+}
+
+func TestUserService_ClaimAdmin_AdminAppeared(t *testing.T) {
+	service, mockDB, mockRedis := newBootstrapTestService(t)
+
+	// An admin promoted through the configuration meanwhile makes the pending
+	// code void
+	mockRedis.Mock.ExpectGet(adminClaimKey).SetVal("42:ABCD2345")
+	mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE role = \$1`).
+		WithArgs(models.RoleAdmin).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(1))
+	mockRedis.Mock.ExpectDel(adminClaimKey).SetVal(1)
+
+	assert.ErrorIs(t, service.ClaimAdmin(context.Background(), 42, "ABCD2345"), ErrAdminClaimInvalid)
+	mockDB.ExpectationsWereMet(t)
+	assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+}
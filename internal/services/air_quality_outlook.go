@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+const (
+	// airQualityOutlookInterval is how often alerts subscribers are checked
+	// for the morning air quality outlook
+	airQualityOutlookInterval = 30 * time.Minute
+
+	// The outlook goes out between these local hours, so that a check
+	// missed at 07:00 still sends it later in the morning
+	airQualityOutlookFromHour  = 7
+	airQualityOutlookUntilHour = 10
+)
+
+// airQualityOutlookKey marks the outlook of the user's local day as sent
+func airQualityOutlookKey(userID int64, day time.Time) string {
+	return fmt.Sprintf("air_quality_outlook:%d:%s", userID, day.Format("2006-01-02"))
+}
+
+// processAirQualityOutlooks sends alerts subscribers with an air quality
+// alert a note in their morning when the forecast for the rest of the day
+// passes its threshold, before the alert itself triggers. Each user gets at
+// most one outlook a day.
+func (s *SchedulerService) processAirQualityOutlooks(ctx context.Context, now time.Time) {
+	if s.messaging == nil || s.localization == nil || s.alert == nil {
+		return
+	}
+
+	var subscriptions []models.Subscription
+	if err := s.db.WithContext(ctx).
+		Preload("User").
+		Where("subscription_type = ? AND is_active = ?", models.SubscriptionAlerts, true).
+		Find(&subscriptions).Error; err != nil {
+		s.logger.Error().Err(err).Msg("Failed to get alerts subscriptions")
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		user := subscription.User
+		if !user.IsActive || !user.HasLocation() {
+			continue
+		}
+		local := now.In(userLocation(&user))
+		if local.Hour() < airQualityOutlookFromHour || local.Hour() >= airQualityOutlookUntilHour {
+			continue
+		}
+
+		conditions, err := s.airQualityConditions(ctx, user.ID)
+		if err != nil {
+			s.logger.Warn().Err(err).Int64("user_id", user.ID).Msg("Failed to get air quality alerts")
+			continue
+		}
+		if len(conditions) == 0 || s.chatUnwritable(ctx, user.ID) {
+			continue
+		}
+
+		forecast, err := s.weather.GetAirQualityForecast(ctx, user.Latitude, user.Longitude, weather.AirQualityForecastHours)
+		if errors.Is(err, weather.ErrNotSupported) {
+			// Not configured; the same holds for every other subscriber
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).
+				Str("location", user.LocationName).
+				Int64("user_id", user.ID).
+				Msg("Failed to get air quality forecast")
+			continue
+		}
+
+		peak, threshold, ok := s.airQualityOutlookPeak(forecast, conditions, local)
+		if ok {
+			s.sendAirQualityOutlook(ctx, &user, peak, threshold, local)
+		}
+	}
+}
+
+// airQualityConditions returns the conditions of the user's active air
+// quality alerts, lowest threshold first
+func (s *SchedulerService) airQualityConditions(ctx context.Context, userID int64) ([]AlertCondition, error) {
+	alerts, err := s.alert.GetUserAlerts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var conditions []AlertCondition
+	for _, alert := range alerts {
+		if alert.AlertType != models.AlertAirQuality {
+			continue
+		}
+		var condition AlertCondition
+		if err := json.Unmarshal([]byte(alert.Condition), &condition); err != nil {
+			continue
+		}
+		// The threshold column follows edits made after the alert was created
+		condition.Value = alert.Threshold
+		conditions = append(conditions, condition)
+	}
+	sort.Slice(conditions, func(i, j int) bool { return conditions[i].Value < conditions[j].Value })
+	return conditions, nil
+}
+
+// airQualityOutlookPeak returns the worst forecast hour from local, the
+// user's current time, to the end of their day, and the threshold of the
+// first alert it passes. ok is false when it passes none.
+func (s *SchedulerService) airQualityOutlookPeak(forecast *weather.AirQualityForecast, conditions []AlertCondition, local time.Time) (peak weather.AirQualityHour, threshold float64, ok bool) {
+	endOfDay := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, local.Location())
+
+	var today []weather.AirQualityHour
+	for _, hour := range forecast.Next(local, weather.AirQualityForecastHours) {
+		if !hour.Time.Before(endOfDay) {
+			break
+		}
+		today = append(today, hour)
+	}
+
+	peak, found := weather.WorstAirQualityHour(today)
+	if !found {
+		return peak, 0, false
+	}
+	for _, condition := range conditions {
+		if s.alert.evaluateCondition(float64(peak.AQI), condition) {
+			return peak, condition.Value, true
+		}
+	}
+	return peak, 0, false
+}
+
+// sendAirQualityOutlook sends the outlook unless the user already got one
+// today. A failed send is retried on the next check.
+func (s *SchedulerService) sendAirQualityOutlook(ctx context.Context, user *models.User, peak weather.AirQualityHour, threshold float64, local time.Time) {
+	key := airQualityOutlookKey(user.ID, local)
+	claimed, err := s.redis.SetNX(ctx, key, local.Unix(), 48*time.Hour).Result()
+	if err != nil {
+		s.logger.Warn().Err(err).Int64("user_id", user.ID).Msg("Failed to check sent air quality outlook")
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	zone := local.Location()
+	vars := map[string]string{
+		"location":  markdown.EscapeBold(user.LocationName),
+		"aqi":       weather.FormatAQI(float64(peak.AQI)),
+		"time":      peak.Time.In(zone).Format("15:04"),
+		"pollutant": s.localization.T(ctx, user.Language, "pollutant_"+peak.Pollutant()),
+		"threshold": FormatAlertValue(models.AlertAirQuality, threshold, UserUnits(user)),
+	}
+	err = s.messaging.SendWithOptions(ctx, user.ID, TemplateAirQualityOutlook, vars, SendOptions{Silent: user.QuietDigests})
+	if err != nil {
+		s.logger.Error().Err(err).Int64("user_id", user.ID).Msg("Failed to send air quality outlook")
+		if delErr := s.redis.Del(ctx, key).Err(); delErr != nil {
+			s.logger.Warn().Err(delErr).Str("key", key).Msg("Failed to release air quality outlook for retry")
+		}
+	}
+}
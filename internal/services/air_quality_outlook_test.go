@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/metrics"
+	"github.com/valpere/shopogoda/pkg/weather"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestSchedulerService_AirQualityOutlookPeak(t *testing.T) {
+	kyiv := time.FixedZone("EEST", 3*3600)
+	// 07:30 on Thursday in Kyiv; the forecast runs into Friday morning
+	local := time.Date(2026, 4, 16, 7, 30, 0, 0, kyiv)
+	forecast := &weather.AirQualityForecast{}
+	for i := 0; i < 24; i++ {
+		forecast.Hours = append(forecast.Hours, weather.AirQualityHour{
+			Time: time.Date(2026, 4, 16, 4+i, 0, 0, 0, time.UTC),
+			AQI:  40,
+			PM25: 9,
+		})
+	}
+	forecast.Hours[7].AQI, forecast.Hours[7].O3 = 119, 150 // 14:00 local
+	forecast.Hours[22].AQI = 180                           // 05:00 on Friday
+
+	service := NewSchedulerService(nil, nil, nil, NewAlertService(nil, nil), nil, helpers.NewSilentTestLogger())
+
+	t.Run("passes the lowest threshold it exceeds", func(t *testing.T) {
+		conditions := []AlertCondition{{Operator: "gt", Value: 100}, {Operator: "gt", Value: 110}}
+
+		peak, threshold, ok := service.airQualityOutlookPeak(forecast, conditions, local)
+
+		assert.True(t, ok)
+		assert.Equal(t, 119, peak.AQI)
+		assert.Equal(t, weather.PollutantO3, peak.Pollutant())
+		assert.Equal(t, 100.0, threshold)
+	})
+
+	t.Run("tomorrow does not count", func(t *testing.T) {
+		_, _, ok := service.airQualityOutlookPeak(forecast, []AlertCondition{{Operator: "gt", Value: 150}}, local)
+		assert.False(t, ok)
+	})
+
+	t.Run("hours already over do not count", func(t *testing.T) {
+		afternoon := time.Date(2026, 4, 16, 15, 0, 0, 0, kyiv)
+		_, _, ok := service.airQualityOutlookPeak(forecast, []AlertCondition{{Operator: "gt", Value: 100}}, afternoon)
+		assert.False(t, ok)
+	})
+}
+
+func TestSchedulerService_SendAirQualityOutlook(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	mockRedis := helpers.NewMockRedis()
+	localization := NewLocalizationService(logger)
+	service := NewSchedulerService(nil, mockRedis.Client, nil, nil, nil, logger)
+	service.SetLocalization(localization)
+	// Without a bot the message is rendered but not delivered
+	service.SetMessaging(NewMessagingService(nil, localization, metrics.New(), logger))
+
+	user := &models.User{ID: 123, Language: "en-US", LocationName: "Kyiv"}
+	local := time.Date(2026, 4, 16, 7, 30, 0, 0, time.FixedZone("EEST", 3*3600))
+	peak := weather.AirQualityHour{Time: time.Date(2026, 4, 16, 11, 0, 0, 0, time.UTC), AQI: 119, O3: 150}
+
+	mockRedis.Mock.ExpectSetNX("air_quality_outlook:123:2026-04-16", local.Unix(), 48*time.Hour).SetVal(true)
+	service.sendAirQualityOutlook(context.Background(), user, peak, 100, local)
+	mockRedis.Mock.ExpectSetNX("air_quality_outlook:123:2026-04-16", local.Unix(), 48*time.Hour).SetVal(false)
+	service.sendAirQualityOutlook(context.Background(), user, peak, 100, local)
+
+	mockRedis.ExpectationsWereMet(t)
+}
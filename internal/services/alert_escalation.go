@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+
+	"github.com/valpere/shopogoda/internal/models"
+)
+
+// defaultEscalationDelay is how long an extreme alert may stay unacknowledged
+// before the follow-up is sent
+const defaultEscalationDelay = 30 * time.Minute
+
+// AlertAckCallback is the callback data of the "OK, got it" button under extreme alerts
+const AlertAckCallback = "alert_ack"
+
+// AlertEscalationService follows up on extreme weather alerts that the user
+// neither acknowledged with the button nor replied to within the delay
+type AlertEscalationService struct {
+	db           *gorm.DB
+	localization *LocalizationService
+	logger       *zerolog.Logger
+	bot          *gotgbot.Bot
+	delay        time.Duration
+	now          func() time.Time
+}
+
+func NewAlertEscalationService(db *gorm.DB, localization *LocalizationService, logger *zerolog.Logger) *AlertEscalationService {
+	return &AlertEscalationService{
+		db:           db,
+		localization: localization,
+		logger:       logger,
+		delay:        defaultEscalationDelay,
+		now:          time.Now,
+	}
+}
+
+// SetBot sets the bot used to send follow-ups
+func (s *AlertEscalationService) SetBot(bot *gotgbot.Bot) {
+	s.bot = bot
+}
+
+// isExtremeAlert reports whether an alert is loud enough to be escalated
+func isExtremeAlert(alert *models.EnvironmentalAlert) bool {
+	return alert.Severity >= models.SeverityCritical
+}
+
+// Track schedules the follow-up of an extreme alert message, unless the user
+// turned escalation off
+func (s *AlertEscalationService) Track(ctx context.Context, user *models.User, chatID, messageID int64, alert *models.EnvironmentalAlert) error {
+	if user.AlertEscalationDisabled {
+		return nil
+	}
+
+	escalation := &models.AlertEscalation{
+		ChatID:    chatID,
+		MessageID: messageID,
+		UserID:    user.ID,
+		Title:     alert.Title,
+		DueAt:     s.now().UTC().Add(s.delay),
+	}
+	return s.db.WithContext(ctx).Create(escalation).Error
+}
+
+// Acknowledge cancels the follow-up of an alert message. It reports whether
+// a follow-up was still pending.
+func (s *AlertEscalationService) Acknowledge(ctx context.Context, chatID, messageID int64) (bool, error) {
+	result := s.db.WithContext(ctx).
+		Where("chat_id = ? AND message_id = ?", chatID, messageID).
+		Delete(&models.AlertEscalation{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ProcessDue sends the follow-ups that are due and returns how many were sent.
+// Every alert is followed up at most once.
+func (s *AlertEscalationService) ProcessDue(ctx context.Context) (int, error) {
+	if s.bot == nil {
+		return 0, nil
+	}
+
+	var due []models.AlertEscalation
+	if err := s.db.WithContext(ctx).
+		Preload("User").
+		Where("due_at <= ?", s.now().UTC()).
+		Order("due_at").
+		Find(&due).Error; err != nil {
+		return 0, fmt.Errorf("failed to load due alert escalations: %w", err)
+	}
+
+	sent := 0
+	for i := range due {
+		escalation := &due[i]
+
+		// Removing the escalation before sending claims it: an acknowledgment
+		// that arrived since the query wins and no follow-up goes out twice
+		claimed, err := s.Acknowledge(ctx, escalation.ChatID, escalation.MessageID)
+		if err != nil {
+			s.logger.Error().Err(err).Int64("chat_id", escalation.ChatID).Msg("Failed to claim alert escalation")
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		// The user paused their account since the alert was sent
+		if !escalation.User.IsActive {
+			continue
+		}
+
+		if err := s.sendFollowUp(ctx, escalation); err != nil {
+			s.logger.Warn().Err(err).Int64("chat_id", escalation.ChatID).Msg("Failed to send alert follow-up")
+			continue
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+// sendFollowUp replies to the unacknowledged alert message
+func (s *AlertEscalationService) sendFollowUp(ctx context.Context, escalation *models.AlertEscalation) error {
+	text := s.localization.T(ctx, escalation.User.Language, "alert_escalation_followup", escalation.Title)
+	_, err := s.bot.SendMessageWithContext(ctx, escalation.ChatID, text, &gotgbot.SendMessageOpts{
+		ReplyParameters: &gotgbot.ReplyParameters{
+			MessageId:                escalation.MessageID,
+			AllowSendingWithoutReply: true,
+		},
+	})
+	return err
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/locales"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func newTestEscalations(t *testing.T, api gotgbot.BotClient) (*AlertEscalationService, *helpers.MockDB, *time.Time) {
+	logger := helpers.NewSilentTestLogger()
+	localization := NewLocalizationService(logger)
+	require.NoError(t, localization.LoadTranslations(locales.LocalesFS))
+
+	mockDB := helpers.NewMockDB(t)
+	t.Cleanup(func() { _ = mockDB.Close() })
+
+	service := NewAlertEscalationService(mockDB.DB, localization, logger)
+	service.SetBot(&gotgbot.Bot{Token: "test", BotClient: api})
+	now := time.Date(2025, 7, 2, 14, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+	return service, mockDB, &now
+}
+
+func TestAlertEscalationService_Track(t *testing.T) {
+	alert := &models.EnvironmentalAlert{Title: "Extreme heat", Severity: models.SeverityCritical}
+
+	t.Run("follow-up is due after the delay", func(t *testing.T) {
+		service, mockDB, now := newTestEscalations(t, &restrictedBotAPI{})
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`INSERT INTO "alert_escalations"`).
+			WithArgs(int64(42), int64(100), int64(42), "Extreme heat", now.Add(30*time.Minute), helpers.AnyTime{}).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+
+		require.NoError(t, service.Track(context.Background(), &models.User{ID: 42}, 42, 100, alert))
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("nothing is tracked when the user turned escalation off", func(t *testing.T) {
+		service, mockDB, _ := newTestEscalations(t, &restrictedBotAPI{})
+
+		require.NoError(t, service.Track(context.Background(), &models.User{ID: 42, AlertEscalationDisabled: true}, 42, 100, alert))
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestAlertEscalationService_ProcessDue(t *testing.T) {
+	escalationColumns := []string{"chat_id", "message_id", "user_id", "title", "due_at"}
+	expectDue := func(mockDB *helpers.MockDB, now time.Time, rows ...[]driver.Value) {
+		result := mockDB.Mock.NewRows(escalationColumns)
+		for _, row := range rows {
+			result.AddRow(row...)
+		}
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "alert_escalations" WHERE due_at <= \$1 ORDER BY due_at`).
+			WithArgs(now).
+			WillReturnRows(result)
+		if len(rows) > 0 {
+			mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"."id" = \$1`).
+				WillReturnRows(mockDB.Mock.NewRows([]string{"id", "language", "is_active"}).AddRow(42, "en-US", true))
+		}
+	}
+	expectClaim := func(mockDB *helpers.MockDB, rowsAffected int64) {
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`DELETE FROM "alert_escalations" WHERE chat_id = \$1 AND message_id = \$2`).
+			WithArgs(int64(42), int64(100)).
+			WillReturnResult(helpers.NewResult(0, rowsAffected))
+		mockDB.Mock.ExpectCommit()
+	}
+
+	t.Run("unacknowledged alert gets one follow-up", func(t *testing.T) {
+		api := &restrictedBotAPI{}
+		service, mockDB, now := newTestEscalations(t, api)
+
+		expectDue(mockDB, *now, []driver.Value{42, 100, 42, "Extreme heat", now.Add(-time.Minute)})
+		expectClaim(mockDB, 1)
+
+		sent, err := service.ProcessDue(context.Background())
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, sent)
+		require.Len(t, api.requests, 1)
+		assert.Equal(t, "sendMessage", api.requests[0].method)
+		assert.Contains(t, api.requests[0].text, `"Extreme heat" is still unacknowledged`)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("acknowledged alert is not followed up", func(t *testing.T) {
+		api := &restrictedBotAPI{}
+		service, mockDB, now := newTestEscalations(t, api)
+
+		expectClaim(mockDB, 1)
+		pending, err := service.Acknowledge(context.Background(), 42, 100)
+		require.NoError(t, err)
+		assert.True(t, pending)
+
+		// Half an hour later the acknowledged escalation is gone
+		*now = now.Add(30 * time.Minute)
+		expectDue(mockDB, *now)
+
+		sent, err := service.ProcessDue(context.Background())
+
+		require.NoError(t, err)
+		assert.Zero(t, sent)
+		assert.Empty(t, api.requests)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("acknowledgment racing with the follow-up wins", func(t *testing.T) {
+		api := &restrictedBotAPI{}
+		service, mockDB, now := newTestEscalations(t, api)
+
+		expectDue(mockDB, *now, []driver.Value{42, 100, 42, "Extreme heat", now.Add(-time.Minute)})
+		expectClaim(mockDB, 0)
+
+		sent, err := service.ProcessDue(context.Background())
+
+		require.NoError(t, err)
+		assert.Zero(t, sent)
+		assert.Empty(t, api.requests)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
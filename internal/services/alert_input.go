@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/valpere/shopogoda/internal/models"
+)
+
+const (
+	// pendingAlertKeyPrefix keys the custom alert a user is entering the
+	// threshold of, followed by the user ID
+	pendingAlertKeyPrefix = "alert_pending:"
+
+	// PendingAlertTimeout is how long a custom alert waits for its threshold
+	PendingAlertTimeout = 5 * time.Minute
+
+	// pendingAlertTTL keeps the state past the timeout so that a late reply
+	// is told the input expired instead of being read as a location
+	pendingAlertTTL = time.Hour
+)
+
+// ErrPendingAlertExpired is returned for a custom alert whose threshold was
+// not entered within PendingAlertTimeout
+var ErrPendingAlertExpired = errors.New("custom alert input expired")
+
+// PendingAlert is a custom alert waiting for the user to reply with its
+// threshold
+type PendingAlert struct {
+	AlertType models.AlertType `json:"alert_type"`
+	Operator  string           `json:"operator"`
+	StartedAt time.Time        `json:"started_at"`
+}
+
+func pendingAlertKey(userID int64) string {
+	return fmt.Sprintf("%s%d", pendingAlertKeyPrefix, userID)
+}
+
+// StartPendingAlert waits for the user's next message to be the threshold of
+// a custom alert, replacing any custom alert they had started before
+func (s *AlertService) StartPendingAlert(ctx context.Context, userID int64, alertType models.AlertType, operator string) error {
+	data, err := json.Marshal(PendingAlert{AlertType: alertType, Operator: operator, StartedAt: s.now()})
+	if err != nil {
+		return err
+	}
+	if err := s.redis.Set(ctx, pendingAlertKey(userID), data, pendingAlertTTL).Err(); err != nil {
+		return fmt.Errorf("failed to store pending alert: %w", err)
+	}
+	return nil
+}
+
+// GetPendingAlert returns the custom alert waiting for the user's threshold,
+// or nil when there is none. A pending alert older than PendingAlertTimeout is
+// returned with ErrPendingAlertExpired.
+func (s *AlertService) GetPendingAlert(ctx context.Context, userID int64) (*PendingAlert, error) {
+	data, err := s.redis.Get(ctx, pendingAlertKey(userID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pending alert: %w", err)
+	}
+
+	var pending PendingAlert
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return nil, fmt.Errorf("failed to decode pending alert: %w", err)
+	}
+	if s.now().Sub(pending.StartedAt) > PendingAlertTimeout {
+		return &pending, ErrPendingAlertExpired
+	}
+	return &pending, nil
+}
+
+// ClearPendingAlert stops waiting for a custom alert threshold
+func (s *AlertService) ClearPendingAlert(ctx context.Context, userID int64) error {
+	return s.redis.Del(ctx, pendingAlertKey(userID)).Err()
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestAlertService_PendingAlert(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	newService := func(t *testing.T, now time.Time) (*AlertService, *helpers.MockRedis) {
+		mockDB := helpers.NewMockDB(t)
+		t.Cleanup(func() { _ = mockDB.Close() })
+		mockRedis := helpers.NewMockRedis()
+		service := NewAlertService(mockDB.DB, mockRedis.Client)
+		service.now = func() time.Time { return now }
+		return service, mockRedis
+	}
+	stored := `{"alert_type":4,"operator":"gt","started_at":"2026-10-16T09:00:00Z"}`
+
+	t.Run("start stores the alert type and time", func(t *testing.T) {
+		service, mockRedis := newService(t, now)
+		mockRedis.Mock.ExpectSet("alert_pending:123", []byte(stored), pendingAlertTTL).SetVal("OK")
+
+		require.NoError(t, service.StartPendingAlert(context.Background(), 123, models.AlertWindSpeed, "gt"))
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("none pending", func(t *testing.T) {
+		service, mockRedis := newService(t, now)
+		mockRedis.Mock.ExpectGet("alert_pending:123").RedisNil()
+
+		pending, err := service.GetPendingAlert(context.Background(), 123)
+		require.NoError(t, err)
+		assert.Nil(t, pending)
+	})
+
+	t.Run("pending within the timeout", func(t *testing.T) {
+		service, mockRedis := newService(t, now.Add(PendingAlertTimeout))
+		mockRedis.Mock.ExpectGet("alert_pending:123").SetVal(stored)
+
+		pending, err := service.GetPendingAlert(context.Background(), 123)
+		require.NoError(t, err)
+		assert.Equal(t, models.AlertWindSpeed, pending.AlertType)
+		assert.Equal(t, "gt", pending.Operator)
+	})
+
+	t.Run("expired after the timeout", func(t *testing.T) {
+		service, mockRedis := newService(t, now.Add(PendingAlertTimeout+time.Second))
+		mockRedis.Mock.ExpectGet("alert_pending:123").SetVal(stored)
+
+		pending, err := service.GetPendingAlert(context.Background(), 123)
+		assert.ErrorIs(t, err, ErrPendingAlertExpired)
+		require.NotNil(t, pending)
+		assert.Equal(t, models.AlertWindSpeed, pending.AlertType)
+	})
+
+	t.Run("redis failure", func(t *testing.T) {
+		service, mockRedis := newService(t, now)
+		mockRedis.Mock.ExpectGet("alert_pending:123").SetErr(errors.New("connection refused"))
+
+		_, err := service.GetPendingAlert(context.Background(), 123)
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrPendingAlertExpired)
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		service, mockRedis := newService(t, now)
+		mockRedis.Mock.ExpectDel("alert_pending:123").SetVal(1)
+
+		require.NoError(t, service.ClearPendingAlert(context.Background(), 123))
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+}
@@ -3,19 +3,42 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/weather"
 )
 
+// DefaultMaxAlertsPerUser is how many active alerts a user may have unless
+// configured otherwise
+const DefaultMaxAlertsPerUser = 20
+
+// ErrAlertLimitReached is returned by CreateAlert and ActivateAlert when the
+// user already has as many active alerts as they may have
+var ErrAlertLimitReached = errors.New("alert limit reached")
+
 type AlertService struct {
-	db    *gorm.DB
-	redis *redis.Client
+	db        *gorm.DB
+	redis     *redis.Client
+	quietDays *QuietDayService
+	pollen    PollenSource
+	tides     TideService
+	maxAlerts int
+	now       func() time.Time
+	inTx      bool // Set on copies bound to a transaction by Services.WithTx
+}
+
+// PollenSource provides the pollen forecast that pollen alerts are checked against
+type PollenSource interface {
+	GetPollenForecast(ctx context.Context, lat, lon float64) (*weather.PollenData, error)
 }
 
 type AlertCondition struct {
@@ -25,29 +48,194 @@ type AlertCondition struct {
 
 func NewAlertService(db *gorm.DB, redis *redis.Client) *AlertService {
 	return &AlertService{
-		db:    db,
-		redis: redis,
+		db:        db,
+		redis:     redis,
+		maxAlerts: DefaultMaxAlertsPerUser,
+		now:       time.Now,
+	}
+}
+
+// SetMaxAlertsPerUser sets how many active alerts a user may have; zero or
+// less keeps the default
+func (s *AlertService) SetMaxAlertsPerUser(maxAlerts int) {
+	if maxAlerts > 0 {
+		s.maxAlerts = maxAlerts
+	}
+}
+
+// MaxAlertsPerUser is how many active alerts a user may have
+func (s *AlertService) MaxAlertsPerUser() int {
+	return s.maxAlerts
+}
+
+// SetQuietDays sets the quiet days that routine alerts are suppressed on
+func (s *AlertService) SetQuietDays(quietDays *QuietDayService) {
+	s.quietDays = quietDays
+}
+
+// SetPollen sets the source of pollen counts; without it pollen alerts are not checked
+func (s *AlertService) SetPollen(pollen PollenSource) {
+	s.pollen = pollen
+}
+
+// SetTides sets the source of tide predictions; without it tide alerts are not checked
+func (s *AlertService) SetTides(tides TideService) {
+	s.tides = tides
+}
+
+// withTx returns a copy of the service that writes through the transaction db
+func (s *AlertService) withTx(db *gorm.DB) *AlertService {
+	scoped := *s
+	scoped.db = db
+	scoped.inTx = true
+	return &scoped
+}
+
+// transaction runs fn in a transaction, or in the one the service is bound to
+func (s *AlertService) transaction(ctx context.Context, fn func(db *gorm.DB) error) error {
+	if s.inTx {
+		return fn(s.db.WithContext(ctx))
+	}
+	return s.db.WithContext(ctx).Transaction(fn)
+}
+
+// lockUserAlerts locks the user's row for the rest of the transaction and
+// counts their active alerts. Every change that adds an active alert takes
+// the lock first, so two of them at once cannot both fit under the limit.
+func lockUserAlerts(db *gorm.DB, userID int64) (int64, error) {
+	var locked []int64
+	if err := db.Model(&models.User{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", userID).Pluck("id", &locked).Error; err != nil {
+		return 0, fmt.Errorf("failed to lock user alerts: %w", err)
+	}
+	var active int64
+	if err := db.Model(&models.AlertConfig{}).
+		Where("user_id = ? AND is_active = ?", userID, true).
+		Count(&active).Error; err != nil {
+		return 0, fmt.Errorf("failed to count alerts: %w", err)
 	}
+	return active, nil
 }
 
+// CreateAlert creates an active alert for the user, or fails with
+// ErrAlertLimitReached when they already have MaxAlertsPerUser active alerts
 func (s *AlertService) CreateAlert(ctx context.Context, userID int64, alertType models.AlertType, condition AlertCondition) (*models.AlertConfig, error) {
 	conditionJSON, _ := json.Marshal(condition)
 
 	alert := &models.AlertConfig{
-		UserID:    userID,
-		AlertType: alertType,
-		Condition: string(conditionJSON),
-		Threshold: condition.Value,
-		IsActive:  true,
+		UserID:          userID,
+		AlertType:       alertType,
+		Condition:       string(conditionJSON),
+		Threshold:       condition.Value,
+		IsActive:        true,
+		CooldownMinutes: int(models.DefaultAlertCooldown.Minutes()),
 	}
 
-	if err := s.db.WithContext(ctx).Create(alert).Error; err != nil {
+	// Every active alert is checked against the weather each run, so one user
+	// cannot pile them up
+	err := s.transaction(ctx, func(db *gorm.DB) error {
+		active, err := lockUserAlerts(db, userID)
+		if err != nil {
+			return err
+		}
+		if active >= int64(s.maxAlerts) {
+			return ErrAlertLimitReached
+		}
+		return db.Create(alert).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return alert, nil
 }
 
+// ActivateAlert switches an inactive alert of the user back on, or fails with
+// ErrAlertLimitReached when they already have MaxAlertsPerUser active alerts
+func (s *AlertService) ActivateAlert(ctx context.Context, userID int64, alertID uuid.UUID) error {
+	return s.transaction(ctx, func(db *gorm.DB) error {
+		active, err := lockUserAlerts(db, userID)
+		if err != nil {
+			return err
+		}
+		if active >= int64(s.maxAlerts) {
+			return ErrAlertLimitReached
+		}
+
+		result := db.Model(&models.AlertConfig{}).
+			Where("id = ? AND user_id = ? AND is_active = ?", alertID, userID, false).
+			Updates(map[string]interface{}{"is_active": true, "updated_at": time.Now().UTC()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("alert not found")
+		}
+		return nil
+	})
+}
+
+// TrimAlertsToLimit switches off the user's newest active alerts beyond
+// MaxAlertsPerUser, which they can have after the limit was lowered or after
+// a merge brought in alerts from another account. It returns how many were
+// switched off.
+func (s *AlertService) TrimAlertsToLimit(ctx context.Context, userID int64) (int64, error) {
+	var trimmed int64
+	err := s.transaction(ctx, func(db *gorm.DB) error {
+		active, err := lockUserAlerts(db, userID)
+		if err != nil || active <= int64(s.maxAlerts) {
+			return err
+		}
+
+		var excess []uuid.UUID
+		if err := db.Model(&models.AlertConfig{}).
+			Where("user_id = ? AND is_active = ?", userID, true).
+			Order("created_at DESC").
+			Limit(int(active)-s.maxAlerts).
+			Pluck("id", &excess).Error; err != nil {
+			return fmt.Errorf("failed to find alerts over the limit: %w", err)
+		}
+		result := db.Model(&models.AlertConfig{}).
+			Where("id IN ?", excess).
+			Updates(map[string]interface{}{"is_active": false, "updated_at": time.Now().UTC()})
+		if result.Error != nil {
+			return fmt.Errorf("failed to switch off alerts over the limit: %w", result.Error)
+		}
+		trimmed = result.RowsAffected
+		return nil
+	})
+	return trimmed, err
+}
+
+// alertThresholdTolerance is how close two thresholds must be to count as the
+// same; thresholds entered in imperial units arrive converted to metric
+const alertThresholdTolerance = 1e-6
+
+// AlertExists reports whether the user already has an active alert of the
+// type with the same operator and threshold. Alerts watch the user's
+// location, so such an alert is identical to the one they are about to
+// create. A deactivated one is not: it no longer appears among their alerts.
+func (s *AlertService) AlertExists(ctx context.Context, userID int64, alertType models.AlertType, operator string, threshold float64) (bool, error) {
+	var alerts []models.AlertConfig
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ? AND alert_type = ? AND is_active = ?", userID, alertType, true).
+		Find(&alerts).Error; err != nil {
+		return false, fmt.Errorf("failed to look up alerts: %w", err)
+	}
+
+	for _, alert := range alerts {
+		// The threshold column follows edits; the operator lives in the condition
+		var condition AlertCondition
+		if err := json.Unmarshal([]byte(alert.Condition), &condition); err != nil {
+			continue
+		}
+		if condition.Operator == operator && math.Abs(alert.Threshold-threshold) < alertThresholdTolerance {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (s *AlertService) GetUserAlerts(ctx context.Context, userID int64) ([]models.AlertConfig, error) {
 	var alerts []models.AlertConfig
 	err := s.db.WithContext(ctx).
@@ -57,7 +245,13 @@ func (s *AlertService) GetUserAlerts(ctx context.Context, userID int64) ([]model
 	return alerts, err
 }
 
-func (s *AlertService) CheckAlerts(ctx context.Context, weatherData *models.WeatherData, userID int64) ([]models.EnvironmentalAlert, error) {
+// CheckAlerts evaluates the user's alerts against current weather and returns
+// the triggered alerts to send. Routine triggers on the user's quiet days, or
+// on public holidays for alerts set to skip them, are recorded as suppressed
+// and not returned.
+func (s *AlertService) CheckAlerts(ctx context.Context, weatherData *models.WeatherData, user *models.User) ([]models.EnvironmentalAlert, error) {
+	userID := user.ID
+
 	// Get active alerts for this user
 	var alertConfigs []models.AlertConfig
 	err := s.db.WithContext(ctx).
@@ -69,6 +263,9 @@ func (s *AlertService) CheckAlerts(ctx context.Context, weatherData *models.Weat
 	}
 
 	var triggeredAlerts []models.EnvironmentalAlert
+	suppression := s.newAlertSuppression(user)
+	pollen := s.newPollenLookup(user)
+	tides := s.newTideLookup(user)
 
 	for _, config := range alertConfigs {
 		var condition AlertCondition
@@ -85,30 +282,58 @@ func (s *AlertService) CheckAlerts(ctx context.Context, weatherData *models.Weat
 		case models.AlertTemperature:
 			currentValue = weatherData.Temperature
 			alertTitle = "Temperature Alert"
-			alertDescription = fmt.Sprintf("Temperature is %.1f°C", currentValue)
+			alertDescription = "Temperature is " + weather.FormatTemp(currentValue, UserUnits(user))
 		case models.AlertHumidity:
 			currentValue = float64(weatherData.Humidity)
 			alertTitle = "Humidity Alert"
-			alertDescription = fmt.Sprintf("Humidity is %d%%", weatherData.Humidity)
+			alertDescription = "Humidity is " + weather.FormatPercent(currentValue)
 		case models.AlertWindSpeed:
 			currentValue = weatherData.WindSpeed
 			alertTitle = "Wind Speed Alert"
-			alertDescription = fmt.Sprintf("Wind speed is %.1f km/h", currentValue)
+			alertDescription = "Wind speed is " + weather.FormatSpeed(currentValue, UserUnits(user))
 		case models.AlertAirQuality:
 			currentValue = float64(weatherData.AQI)
 			alertTitle = "Air Quality Alert"
-			alertDescription = fmt.Sprintf("AQI is %d", weatherData.AQI)
+			alertDescription = "AQI is " + weather.FormatAQI(currentValue)
+		case models.AlertPollen:
+			pollenData := pollen.get(ctx)
+			if pollenData == nil {
+				continue
+			}
+			currentValue = float64(pollenData.MaxRisk())
+			alertTitle = "Pollen Alert"
+			alertDescription = fmt.Sprintf("Pollen risk is %d/%d", pollenData.MaxRisk(), weather.MaxPollenRisk)
+		case models.AlertTide:
+			tideData := tides.get(ctx)
+			if tideData == nil {
+				continue
+			}
+			now := s.now()
+			highest, ok := tideData.HighestTide(now, now.Add(24*time.Hour))
+			if !ok {
+				continue
+			}
+			currentValue = highest.Height
+			alertTitle = "Tide Alert"
+			alertDescription = fmt.Sprintf("High tide of %s at %s", weather.FormatHeight(highest.Height, UserUnits(user)),
+				highest.Time.In(userLocation(user)).Format("15:04"))
 		default:
 			continue
 		}
 
-		// Check if condition is met
-		if s.evaluateCondition(currentValue, condition) {
-			// Check if alert was recently triggered (avoid spam)
-			if config.LastTriggered != nil && time.Since(*config.LastTriggered) < time.Hour {
-				continue
-			}
+		now := s.now().UTC()
+		updates := map[string]any{"last_checked_at": now, "last_value": currentValue}
 
+		// A triggered alert stays quiet for its cooldown, and until the value
+		// has gone back past the threshold by more than the hysteresis margin,
+		// so a value hovering around the threshold does not trigger it again
+		rearmPending := config.RearmPending
+		if rearmPending && s.clearsThreshold(currentValue, condition) {
+			rearmPending = false
+			updates["rearm_pending"] = false
+		}
+		inCooldown := now.Before(config.QuietUntil())
+		if s.evaluateCondition(currentValue, condition) && !inCooldown && !rearmPending {
 			severity := s.calculateSeverity(config.AlertType, currentValue, condition.Value)
 
 			alert := models.EnvironmentalAlert{
@@ -121,21 +346,158 @@ func (s *AlertService) CheckAlerts(ctx context.Context, weatherData *models.Weat
 				Threshold:   condition.Value,
 				IsResolved:  false,
 			}
+			alert.Suppressed = suppression.reason(ctx, &config, &alert)
 
 			// Save alert
 			if err := s.db.WithContext(ctx).Create(&alert).Error; err == nil {
-				triggeredAlerts = append(triggeredAlerts, alert)
-
-				// Update last triggered time
-				now := time.Now().UTC()
-				s.db.WithContext(ctx).Model(&config).Update("last_triggered", &now)
+				if alert.Suppressed == "" {
+					triggeredAlerts = append(triggeredAlerts, alert)
+				}
+				updates["last_triggered"] = now
+				updates["rearm_pending"] = true
 			}
 		}
+
+		// Record the check so users can see when the alert was last evaluated
+		s.db.WithContext(ctx).Model(&config).Updates(updates)
 	}
 
 	return triggeredAlerts, nil
 }
 
+// alertSuppression decides which triggers of one check are recorded without
+// being sent. The user's quiet days are looked up once, on the first routine
+// trigger.
+type alertSuppression struct {
+	quietDays *QuietDayService
+	user      *models.User
+	today     time.Time
+	quiet     *bool
+}
+
+func (s *AlertService) newAlertSuppression(user *models.User) *alertSuppression {
+	return &alertSuppression{
+		quietDays: s.quietDays,
+		user:      user,
+		today:     CalendarDate(s.now().In(userLocation(user))),
+	}
+}
+
+// reason returns why a trigger is suppressed, or nothing when it is sent.
+// Extreme triggers are always sent.
+func (a *alertSuppression) reason(ctx context.Context, config *models.AlertConfig, alert *models.EnvironmentalAlert) models.SuppressionReason {
+	if isExtremeAlert(alert) {
+		return ""
+	}
+	if config.SuppressOnHolidays {
+		if _, ok := PublicHoliday(a.user.Country, a.today); ok {
+			return models.SuppressedHoliday
+		}
+	}
+	if a.isQuietDay(ctx) {
+		return models.SuppressedQuietDay
+	}
+	return ""
+}
+
+func (a *alertSuppression) isQuietDay(ctx context.Context) bool {
+	if a.quietDays == nil {
+		return false
+	}
+	if a.quiet == nil {
+		// When quiet days cannot be read the alert is sent rather than lost
+		quiet, err := a.quietDays.IsQuietDay(ctx, a.user.ID, a.today)
+		quiet = quiet && err == nil
+		a.quiet = &quiet
+	}
+	return *a.quiet
+}
+
+// pollenLookup fetches the pollen forecast of the user's location once per
+// check, on the first pollen alert
+type pollenLookup struct {
+	source  PollenSource
+	user    *models.User
+	fetched bool
+	data    *weather.PollenData
+}
+
+func (s *AlertService) newPollenLookup(user *models.User) *pollenLookup {
+	return &pollenLookup{source: s.pollen, user: user}
+}
+
+// get returns the pollen forecast, or nil when there is no source or it
+// cannot be read, in which case pollen alerts are skipped for this check
+func (p *pollenLookup) get(ctx context.Context) *weather.PollenData {
+	if p.source == nil {
+		return nil
+	}
+	if !p.fetched {
+		p.fetched = true
+		data, err := p.source.GetPollenForecast(ctx, p.user.Latitude, p.user.Longitude)
+		if err == nil {
+			p.data = data
+		}
+	}
+	return p.data
+}
+
+// tideLookup fetches the tides of the user's location once per check, on the
+// first tide alert
+type tideLookup struct {
+	source  TideService
+	user    *models.User
+	fetched bool
+	data    *weather.TideData
+}
+
+func (s *AlertService) newTideLookup(user *models.User) *tideLookup {
+	return &tideLookup{source: s.tides, user: user}
+}
+
+// get returns the tides of today and tomorrow, or nil when there is no
+// source, the user is inland or they cannot be read, in which case tide
+// alerts are skipped for this check
+func (t *tideLookup) get(ctx context.Context) *weather.TideData {
+	if t.source == nil {
+		return nil
+	}
+	if !t.fetched {
+		t.fetched = true
+		data, err := t.source.GetTidePredictions(ctx, t.user.Latitude, t.user.Longitude, TideForecastDays)
+		if err == nil {
+			t.data = data
+		}
+	}
+	return t.data
+}
+
+// alertStatusKey returns the localization key of whether a triggered alert
+// was sent or why it was suppressed
+func alertStatusKey(alert *models.EnvironmentalAlert) string {
+	switch alert.Suppressed {
+	case models.SuppressedHoliday:
+		return "alert_status_suppressed_holiday"
+	case models.SuppressedQuietDay:
+		return "alert_status_suppressed_quiet_day"
+	default:
+		return "alert_status_sent"
+	}
+}
+
+// GetTriggeredAlertsSince returns up to limit alerts that triggered for the user
+// after since, newest first
+func (s *AlertService) GetTriggeredAlertsSince(ctx context.Context, userID int64, since time.Time, limit int) ([]models.EnvironmentalAlert, error) {
+	var alerts []models.EnvironmentalAlert
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND created_at > ?", userID, since).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&alerts).Error
+
+	return alerts, err
+}
+
 func (s *AlertService) evaluateCondition(currentValue float64, condition AlertCondition) bool {
 	switch condition.Operator {
 	case "gt":
@@ -153,6 +515,29 @@ func (s *AlertService) evaluateCondition(currentValue float64, condition AlertCo
 	}
 }
 
+// alertHysteresis returns how far past its threshold the value of a triggered
+// alert has to go back before the alert can trigger again: 5% of the
+// threshold, and at least 1 unit
+func alertHysteresis(threshold float64) float64 {
+	return math.Max(math.Abs(threshold)*0.05, 1)
+}
+
+// clearsThreshold reports whether the value is on the quiet side of the
+// condition by more than the hysteresis margin, which re-arms a triggered alert
+func (s *AlertService) clearsThreshold(currentValue float64, condition AlertCondition) bool {
+	margin := alertHysteresis(condition.Value)
+	switch condition.Operator {
+	case "gt", "gte":
+		return currentValue <= condition.Value-margin
+	case "lt", "lte":
+		return currentValue >= condition.Value+margin
+	case "eq":
+		return math.Abs(currentValue-condition.Value) >= margin
+	default:
+		return true
+	}
+}
+
 func (s *AlertService) calculateSeverity(alertType models.AlertType, currentValue, threshold float64) models.Severity {
 	var deviation float64
 	if currentValue > threshold {
@@ -182,6 +567,22 @@ func (s *AlertService) calculateSeverity(alertType models.AlertType, currentValu
 		}
 		return models.SeverityLow
 
+	case models.AlertPollen:
+		if currentValue >= weather.MaxPollenRisk {
+			return models.SeverityHigh
+		} else if currentValue >= weather.MaxPollenRisk-1 {
+			return models.SeverityMedium
+		}
+		return models.SeverityLow
+
+	case models.AlertTide:
+		if deviation > 1 {
+			return models.SeverityHigh
+		} else if deviation > 0.5 {
+			return models.SeverityMedium
+		}
+		return models.SeverityLow
+
 	default:
 		return models.SeverityMedium
 	}
@@ -225,6 +626,15 @@ func (s *AlertService) UpdateAlert(ctx context.Context, userID int64, alertID uu
 	return nil
 }
 
+// RearmAlert ends the cooldown of an alert that triggered, so it may trigger
+// again at the next check when the condition still holds
+func (s *AlertService) RearmAlert(ctx context.Context, userID int64, alertID uuid.UUID) error {
+	return s.UpdateAlert(ctx, userID, alertID, map[string]interface{}{
+		"last_triggered": nil,
+		"rearm_pending":  false,
+	})
+}
+
 // GetAlert retrieves a specific alert by ID and user ID
 func (s *AlertService) GetAlert(ctx context.Context, userID int64, alertID uuid.UUID) (*models.AlertConfig, error) {
 	var alert models.AlertConfig
@@ -2,13 +2,21 @@ package services
 
 import (
 	"context"
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/valpere/shopogoda/internal/config"
 	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/weather"
 	"github.com/valpere/shopogoda/tests/helpers"
 )
 
@@ -19,6 +27,14 @@ func TestAlertService_CreateAlert(t *testing.T) {
 
 	mockRedis := helpers.NewMockRedis()
 	service := NewAlertService(mockDB.DB, mockRedis.Client)
+	countActive := func(active int) {
+		mockDB.Mock.ExpectQuery(`SELECT "id" FROM "users" WHERE id = \$1 AND "users"\."deleted_at" IS NULL FOR UPDATE`).
+			WithArgs(int64(123)).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(int64(123)))
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "alert_configs" WHERE user_id = \$1 AND is_active = \$2`).
+			WithArgs(int64(123), true).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(active))
+	}
 
 	t.Run("successful alert creation", func(t *testing.T) {
 		userID := int64(123)
@@ -30,8 +46,9 @@ func TestAlertService_CreateAlert(t *testing.T) {
 
 		// Mock database expectations - CREATE operation
 		mockDB.Mock.ExpectBegin()
+		countActive(DefaultMaxAlertsPerUser - 1)
 		mockDB.Mock.ExpectQuery(`INSERT INTO "alert_configs"`).
-			WithArgs(userID, alertType, `{"operator":"gt","value":25}`, 25.0, true, nil, helpers.AnyTime{}, helpers.AnyTime{}).
+			WithArgs(userID, alertType, `{"operator":"gt","value":25}`, 25.0, true, false, nil, nil, nil, 120, false, helpers.AnyTime{}, helpers.AnyTime{}).
 			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
 		mockDB.Mock.ExpectCommit()
 
@@ -54,6 +71,7 @@ func TestAlertService_CreateAlert(t *testing.T) {
 		}
 
 		mockDB.Mock.ExpectBegin()
+		countActive(0)
 		mockDB.Mock.ExpectQuery(`INSERT INTO "alert_configs"`).
 			WillReturnError(errors.New("database error"))
 		mockDB.Mock.ExpectRollback()
@@ -65,6 +83,169 @@ func TestAlertService_CreateAlert(t *testing.T) {
 		assert.Contains(t, err.Error(), "database error")
 		mockDB.ExpectationsWereMet(t)
 	})
+
+	t.Run("limit reached", func(t *testing.T) {
+		condition := AlertCondition{Operator: "gt", Value: 25.0}
+
+		// Nothing is inserted at the limit
+		mockDB.Mock.ExpectBegin()
+		countActive(DefaultMaxAlertsPerUser)
+		mockDB.Mock.ExpectRollback()
+		alertConfig, err := service.CreateAlert(context.Background(), 123, models.AlertTemperature, condition)
+		assert.ErrorIs(t, err, ErrAlertLimitReached)
+		assert.Nil(t, alertConfig)
+
+		// A configured limit replaces the default
+		service.SetMaxAlertsPerUser(5)
+		defer service.SetMaxAlertsPerUser(DefaultMaxAlertsPerUser)
+		mockDB.Mock.ExpectBegin()
+		countActive(5)
+		mockDB.Mock.ExpectRollback()
+		_, err = service.CreateAlert(context.Background(), 123, models.AlertTemperature, condition)
+		assert.ErrorIs(t, err, ErrAlertLimitReached)
+
+		mockDB.Mock.ExpectBegin()
+		countActive(4)
+		mockDB.Mock.ExpectQuery(`INSERT INTO "alert_configs"`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mockDB.Mock.ExpectCommit()
+		_, err = service.CreateAlert(context.Background(), 123, models.AlertTemperature, condition)
+		assert.NoError(t, err)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestAlertService_ActivateAlert(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	service := NewAlertService(mockDB.DB, helpers.NewMockRedis().Client)
+	service.SetMaxAlertsPerUser(3)
+	alertID := uuid.New()
+
+	lockAndCount := func(active int) {
+		mockDB.Mock.ExpectQuery(`SELECT "id" FROM "users" WHERE id = \$1 AND "users"\."deleted_at" IS NULL FOR UPDATE`).
+			WithArgs(int64(123)).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(int64(123)))
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "alert_configs" WHERE user_id = \$1 AND is_active = \$2`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(active))
+	}
+
+	t.Run("switches the alert on under the limit", func(t *testing.T) {
+		mockDB.Mock.ExpectBegin()
+		lockAndCount(2)
+		mockDB.Mock.ExpectExec(`UPDATE "alert_configs" SET "is_active"=\$1,"updated_at"=\$2 WHERE id = \$3 AND user_id = \$4 AND is_active = \$5`).
+			WithArgs(true, helpers.AnyTime{}, alertID, int64(123), false).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+
+		require.NoError(t, service.ActivateAlert(context.Background(), 123, alertID))
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("limit reached", func(t *testing.T) {
+		mockDB.Mock.ExpectBegin()
+		lockAndCount(3)
+		mockDB.Mock.ExpectRollback()
+
+		assert.ErrorIs(t, service.ActivateAlert(context.Background(), 123, alertID), ErrAlertLimitReached)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("alert already on or not the user's", func(t *testing.T) {
+		mockDB.Mock.ExpectBegin()
+		lockAndCount(1)
+		mockDB.Mock.ExpectExec(`UPDATE "alert_configs" SET`).WillReturnResult(helpers.NewResult(0, 0))
+		mockDB.Mock.ExpectRollback()
+
+		assert.ErrorContains(t, service.ActivateAlert(context.Background(), 123, alertID), "alert not found")
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestAlertService_TrimAlertsToLimit(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	service := NewAlertService(mockDB.DB, helpers.NewMockRedis().Client)
+	service.SetMaxAlertsPerUser(3)
+
+	lockAndCount := func(active int) {
+		mockDB.Mock.ExpectQuery(`SELECT "id" FROM "users" WHERE id = \$1 .*FOR UPDATE`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(int64(123)))
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "alert_configs"`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(active))
+	}
+
+	t.Run("switches off the newest alerts over the limit", func(t *testing.T) {
+		newest, next := uuid.New(), uuid.New()
+		mockDB.Mock.ExpectBegin()
+		lockAndCount(5)
+		mockDB.Mock.ExpectQuery(`SELECT "id" FROM "alert_configs" WHERE user_id = \$1 AND is_active = \$2 ORDER BY created_at DESC LIMIT \$3`).
+			WithArgs(int64(123), true, 2).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(newest).AddRow(next))
+		mockDB.Mock.ExpectExec(`UPDATE "alert_configs" SET "is_active"=\$1,"updated_at"=\$2 WHERE id IN \(\$3,\$4\)`).
+			WithArgs(false, helpers.AnyTime{}, newest, next).
+			WillReturnResult(helpers.NewResult(0, 2))
+		mockDB.Mock.ExpectCommit()
+
+		trimmed, err := service.TrimAlertsToLimit(context.Background(), 123)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), trimmed)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("within the limit changes nothing", func(t *testing.T) {
+		mockDB.Mock.ExpectBegin()
+		lockAndCount(3)
+		mockDB.Mock.ExpectCommit()
+
+		trimmed, err := service.TrimAlertsToLimit(context.Background(), 123)
+		require.NoError(t, err)
+		assert.Zero(t, trimmed)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestAlertService_AlertExists(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	service := NewAlertService(mockDB.DB, helpers.NewMockRedis().Client)
+
+	existing := func() *sqlmock.Rows {
+		return mockDB.Mock.NewRows([]string{"id", "user_id", "alert_type", "condition", "threshold", "is_active"}).
+			AddRow(uuid.New(), int64(123), models.AlertTemperature, `{"operator":"gt","value":30}`, 30.0, true).
+			AddRow(uuid.New(), int64(123), models.AlertTemperature, `{"operator":"lt","value":0}`, -5.0, true)
+	}
+	query := `SELECT \* FROM "alert_configs" WHERE user_id = \$1 AND alert_type = \$2 AND is_active = \$3`
+
+	tests := []struct {
+		name      string
+		operator  string
+		threshold float64
+		want      bool
+	}{
+		{"same operator and threshold", "gt", 30, true},
+		{"threshold changed after creation", "lt", -5, true},
+		{"other threshold", "gt", 35, false},
+		{"other operator", "gte", 30, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB.Mock.ExpectQuery(query).WithArgs(int64(123), models.AlertTemperature, true).WillReturnRows(existing())
+
+			exists, err := service.AlertExists(context.Background(), 123, models.AlertTemperature, tt.operator, tt.threshold)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, exists)
+			mockDB.ExpectationsWereMet(t)
+		})
+	}
+
+	t.Run("database error", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(query).WillReturnError(errors.New("database error"))
+
+		_, err := service.AlertExists(context.Background(), 123, models.AlertTemperature, "gt", 30)
+		assert.Error(t, err)
+		mockDB.ExpectationsWereMet(t)
+	})
 }
 
 func TestAlertService_GetAlert(t *testing.T) {
@@ -103,6 +284,22 @@ func TestAlertService_GetAlert(t *testing.T) {
 		mockDB.ExpectationsWereMet(t)
 	})
 
+	t.Run("re-arming clears the last trigger", func(t *testing.T) {
+		userID := int64(123)
+		alertID := uuid.New()
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "alert_configs" SET "last_triggered"=\$1,"rearm_pending"=\$2,"updated_at"=\$3 WHERE id = \$4 AND user_id = \$5`).
+			WithArgs(nil, false, helpers.AnyTime{}, alertID, userID).
+			WillReturnResult(helpers.NewResult(1, 1))
+		mockDB.Mock.ExpectCommit()
+
+		err := service.RearmAlert(context.Background(), userID, alertID)
+
+		assert.NoError(t, err)
+		mockDB.ExpectationsWereMet(t)
+	})
+
 	t.Run("alert not found", func(t *testing.T) {
 		userID := int64(123)
 		alertID := uuid.New()
@@ -210,18 +407,18 @@ func TestAlertService_CheckAlerts(t *testing.T) {
 		// Mock the INSERT for EnvironmentalAlert creation
 		mockDB.Mock.ExpectBegin()
 		mockDB.Mock.ExpectQuery(`INSERT INTO "environmental_alerts"`).
-			WithArgs(userID, models.AlertTemperature, helpers.AnyValue{}, "Temperature Alert", "Temperature is 26.5°C", 26.5, 25.0, false, nil, helpers.AnyTime{}, helpers.AnyTime{}).
+			WithArgs(userID, models.AlertTemperature, helpers.AnyValue{}, "Temperature Alert", "Temperature is 26.5°C", 26.5, 25.0, false, nil, models.SuppressionReason(""), helpers.AnyTime{}, helpers.AnyTime{}).
 			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
 		mockDB.Mock.ExpectCommit()
 
-		// Mock the UPDATE for last_triggered time
+		// The check is recorded along with the trigger
 		mockDB.Mock.ExpectBegin()
-		mockDB.Mock.ExpectExec(`UPDATE "alert_configs" SET "last_triggered"=\$1,"updated_at"=\$2 WHERE "id" = \$3`).
-			WithArgs(helpers.AnyTime{}, helpers.AnyTime{}, alertConfig.ID).
+		mockDB.Mock.ExpectExec(`UPDATE "alert_configs" SET "last_checked_at"=\$1,"last_triggered"=\$2,"last_value"=\$3,"rearm_pending"=\$4,"updated_at"=\$5 WHERE "id" = \$6`).
+			WithArgs(helpers.AnyTime{}, helpers.AnyTime{}, 26.5, true, helpers.AnyTime{}, alertConfig.ID).
 			WillReturnResult(helpers.NewResult(1, 1))
 		mockDB.Mock.ExpectCommit()
 
-		triggeredAlerts, err := service.CheckAlerts(context.Background(), weatherData, userID)
+		triggeredAlerts, err := service.CheckAlerts(context.Background(), weatherData, &models.User{ID: userID})
 
 		assert.NoError(t, err)
 		assert.Len(t, triggeredAlerts, 1)
@@ -250,7 +447,14 @@ func TestAlertService_CheckAlerts(t *testing.T) {
 			WithArgs(userID, true).
 			WillReturnRows(rows)
 
-		triggeredAlerts, err := service.CheckAlerts(context.Background(), weatherData, userID)
+		// Only the check is recorded
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "alert_configs" SET "last_checked_at"=\$1,"last_value"=\$2,"updated_at"=\$3 WHERE "id" = \$4`).
+			WithArgs(helpers.AnyTime{}, 20.5, helpers.AnyTime{}, alertConfig.ID).
+			WillReturnResult(helpers.NewResult(1, 1))
+		mockDB.Mock.ExpectCommit()
+
+		triggeredAlerts, err := service.CheckAlerts(context.Background(), weatherData, &models.User{ID: userID})
 
 		assert.NoError(t, err)
 		assert.Len(t, triggeredAlerts, 0)
@@ -259,6 +463,434 @@ func TestAlertService_CheckAlerts(t *testing.T) {
 
 }
 
+func TestAlertService_CheckAlerts_Rearm(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	const (
+		above = `{"operator":"gt","value":30}` // Hysteresis margin 1.5
+		below = `{"operator":"lt","value":0}`  // Hysteresis margin 1
+	)
+
+	tests := []struct {
+		name             string
+		condition        string
+		value            float64
+		triggeredAgo     time.Duration // Zero when the alert never triggered
+		cooldownMinutes  int
+		rearmPending     bool
+		wantTriggered    bool
+		wantUpdateSQL    string
+		wantUpdateValues []driver.Value
+	}{
+		{
+			name:      "gt triggers when armed",
+			condition: above, value: 30.5,
+			wantTriggered:    true,
+			wantUpdateSQL:    `SET "last_checked_at"=\$1,"last_triggered"=\$2,"last_value"=\$3,"rearm_pending"=\$4,"updated_at"=\$5 WHERE`,
+			wantUpdateValues: []driver.Value{now, now, 30.5, true},
+		},
+		{
+			name:      "gt flapping just under the threshold after the cooldown stays quiet",
+			condition: above, value: 29, triggeredAgo: 3 * time.Hour, rearmPending: true,
+			wantUpdateSQL:    `SET "last_checked_at"=\$1,"last_value"=\$2,"updated_at"=\$3 WHERE`,
+			wantUpdateValues: []driver.Value{now, 29.0},
+		},
+		{
+			name:      "gt back over the threshold before re-arming stays quiet",
+			condition: above, value: 31, triggeredAgo: 3 * time.Hour, rearmPending: true,
+			wantUpdateSQL:    `SET "last_checked_at"=\$1,"last_value"=\$2,"updated_at"=\$3 WHERE`,
+			wantUpdateValues: []driver.Value{now, 31.0},
+		},
+		{
+			name:      "gt re-arms once the value is below the threshold by the margin",
+			condition: above, value: 28.5, triggeredAgo: 3 * time.Hour, rearmPending: true,
+			wantUpdateSQL:    `SET "last_checked_at"=\$1,"last_value"=\$2,"rearm_pending"=\$3,"updated_at"=\$4 WHERE`,
+			wantUpdateValues: []driver.Value{now, 28.5, false},
+		},
+		{
+			name:      "gt triggers again once re-armed and out of cooldown",
+			condition: above, value: 31, triggeredAgo: 3 * time.Hour,
+			wantTriggered:    true,
+			wantUpdateSQL:    `SET "last_checked_at"=\$1,"last_triggered"=\$2,"last_value"=\$3,"rearm_pending"=\$4,"updated_at"=\$5 WHERE`,
+			wantUpdateValues: []driver.Value{now, now, 31.0, true},
+		},
+		{
+			name:      "re-armed alert still waits for its cooldown",
+			condition: above, value: 31, triggeredAgo: 90 * time.Minute,
+			wantUpdateSQL:    `SET "last_checked_at"=\$1,"last_value"=\$2,"updated_at"=\$3 WHERE`,
+			wantUpdateValues: []driver.Value{now, 31.0},
+		},
+		{
+			name:      "shorter cooldown picked by the user",
+			condition: above, value: 31, triggeredAgo: 40 * time.Minute, cooldownMinutes: 30,
+			wantTriggered:    true,
+			wantUpdateSQL:    `SET "last_checked_at"=\$1,"last_triggered"=\$2,"last_value"=\$3,"rearm_pending"=\$4,"updated_at"=\$5 WHERE`,
+			wantUpdateValues: []driver.Value{now, now, 31.0, true},
+		},
+		{
+			name:      "lt flapping just over the threshold stays quiet",
+			condition: below, value: 0.5, triggeredAgo: 3 * time.Hour, rearmPending: true,
+			wantUpdateSQL:    `SET "last_checked_at"=\$1,"last_value"=\$2,"updated_at"=\$3 WHERE`,
+			wantUpdateValues: []driver.Value{now, 0.5},
+		},
+		{
+			name:      "lt back under the threshold before re-arming stays quiet",
+			condition: below, value: -2, triggeredAgo: 3 * time.Hour, rearmPending: true,
+			wantUpdateSQL:    `SET "last_checked_at"=\$1,"last_value"=\$2,"updated_at"=\$3 WHERE`,
+			wantUpdateValues: []driver.Value{now, -2.0},
+		},
+		{
+			name:      "lt re-arms once the value is above the threshold by the margin",
+			condition: below, value: 1, triggeredAgo: 3 * time.Hour, rearmPending: true,
+			wantUpdateSQL:    `SET "last_checked_at"=\$1,"last_value"=\$2,"rearm_pending"=\$3,"updated_at"=\$4 WHERE`,
+			wantUpdateValues: []driver.Value{now, 1.0, false},
+		},
+		{
+			name:      "lt triggers once re-armed",
+			condition: below, value: -1, triggeredAgo: 3 * time.Hour,
+			wantTriggered:    true,
+			wantUpdateSQL:    `SET "last_checked_at"=\$1,"last_triggered"=\$2,"last_value"=\$3,"rearm_pending"=\$4,"updated_at"=\$5 WHERE`,
+			wantUpdateValues: []driver.Value{now, now, -1.0, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := helpers.NewMockDB(t)
+			defer func() { _ = mockDB.Close() }()
+			service := NewAlertService(mockDB.DB, helpers.NewMockRedis().Client)
+			service.now = func() time.Time { return now }
+
+			var lastTriggered *time.Time
+			if tt.triggeredAgo > 0 {
+				at := now.Add(-tt.triggeredAgo)
+				lastTriggered = &at
+			}
+			alertID := uuid.New()
+			mockDB.Mock.ExpectQuery(`SELECT \* FROM "alert_configs"`).
+				WillReturnRows(mockDB.Mock.NewRows([]string{"id", "user_id", "alert_type", "condition", "is_active", "last_triggered", "cooldown_minutes", "rearm_pending"}).
+					AddRow(alertID, 123, models.AlertTemperature, tt.condition, true, lastTriggered, tt.cooldownMinutes, tt.rearmPending))
+			if tt.wantTriggered {
+				mockDB.Mock.ExpectBegin()
+				mockDB.Mock.ExpectQuery(`INSERT INTO "environmental_alerts"`).
+					WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+				mockDB.Mock.ExpectCommit()
+			}
+			mockDB.Mock.ExpectBegin()
+			mockDB.Mock.ExpectExec(`UPDATE "alert_configs" ` + tt.wantUpdateSQL).
+				WithArgs(append(tt.wantUpdateValues, helpers.AnyTime{}, alertID)...).
+				WillReturnResult(helpers.NewResult(1, 1))
+			mockDB.Mock.ExpectCommit()
+
+			weatherData := helpers.MockWeatherData(123)
+			weatherData.Temperature = tt.value
+			triggered, err := service.CheckAlerts(context.Background(), weatherData, &models.User{ID: 123})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantTriggered, len(triggered) == 1)
+			mockDB.ExpectationsWereMet(t)
+		})
+	}
+}
+
+func TestAlertService_CheckAlerts_Cooldown(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	service := NewAlertService(mockDB.DB, helpers.NewMockRedis().Client)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+
+	// Triggered 40 minutes ago: the check is recorded, nothing is sent
+	alertConfig := helpers.MockAlertConfig(123)
+	lastTriggered := now.Add(-40 * time.Minute)
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "alert_configs"`).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id", "user_id", "alert_type", "condition", "threshold", "is_active", "last_triggered"}).
+			AddRow(alertConfig.ID, alertConfig.UserID, alertConfig.AlertType, alertConfig.Condition, alertConfig.Threshold, true, lastTriggered))
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectExec(`UPDATE "alert_configs" SET "last_checked_at"=\$1,"last_value"=\$2,"updated_at"=\$3 WHERE "id" = \$4`).
+		WithArgs(now, 27.0, helpers.AnyTime{}, alertConfig.ID).
+		WillReturnResult(helpers.NewResult(1, 1))
+	mockDB.Mock.ExpectCommit()
+
+	weatherData := helpers.MockWeatherData(123)
+	weatherData.Temperature = 27
+	triggered, err := service.CheckAlerts(context.Background(), weatherData, &models.User{ID: 123})
+	require.NoError(t, err)
+	assert.Empty(t, triggered)
+	mockDB.ExpectationsWereMet(t)
+}
+
+// fakePollenSource returns the same pollen forecast for every place and
+// counts how often it was asked
+type fakePollenSource struct {
+	data  *weather.PollenData
+	err   error
+	calls int
+}
+
+func (f *fakePollenSource) GetPollenForecast(ctx context.Context, lat, lon float64) (*weather.PollenData, error) {
+	f.calls++
+	return f.data, f.err
+}
+
+func TestAlertService_CheckAlerts_Pollen(t *testing.T) {
+	configRows := func(mockDB *helpers.MockDB, configs ...*models.AlertConfig) {
+		rows := mockDB.Mock.NewRows([]string{"id", "user_id", "alert_type", "condition", "threshold", "is_active"})
+		for _, config := range configs {
+			rows.AddRow(config.ID, config.UserID, config.AlertType, config.Condition, config.Threshold, true)
+		}
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "alert_configs"`).WillReturnRows(rows)
+	}
+	pollenConfig := func(threshold string) *models.AlertConfig {
+		config := helpers.MockAlertConfig(123)
+		config.AlertType = models.AlertPollen
+		config.Condition = `{"operator":"gt","value":` + threshold + `}`
+		return config
+	}
+	user := &models.User{ID: 123, Latitude: 50.45, Longitude: 30.52}
+
+	t.Run("risk above the threshold triggers, fetched once", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := NewAlertService(mockDB.DB, helpers.NewMockRedis().Client)
+		source := &fakePollenSource{data: &weather.PollenData{
+			Tree:  weather.PollenReading{Count: 120, Risk: 4},
+			Grass: weather.PollenReading{Count: 3, Risk: 1},
+		}}
+		service.SetPollen(source)
+
+		high, veryHigh := pollenConfig("3"), pollenConfig("4")
+		configRows(mockDB, high, veryHigh)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "environmental_alerts"`).
+			WithArgs(int64(123), models.AlertPollen, models.SeverityMedium, "Pollen Alert", "Pollen risk is 4/5", 4.0, 3.0, false, nil, models.SuppressionReason(""), helpers.AnyTime{}, helpers.AnyTime{}).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mockDB.Mock.ExpectCommit()
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "alert_configs" SET "last_checked_at"=\$1,"last_triggered"=\$2,"last_value"=\$3`).
+			WillReturnResult(helpers.NewResult(1, 1))
+		mockDB.Mock.ExpectCommit()
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "alert_configs" SET "last_checked_at"=\$1,"last_value"=\$2`).
+			WillReturnResult(helpers.NewResult(1, 1))
+		mockDB.Mock.ExpectCommit()
+
+		triggered, err := service.CheckAlerts(context.Background(), helpers.MockWeatherData(123), user)
+		require.NoError(t, err)
+		require.Len(t, triggered, 1)
+		assert.Equal(t, models.AlertPollen, triggered[0].AlertType)
+		assert.Equal(t, 1, source.calls)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("skipped when pollen cannot be read", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := NewAlertService(mockDB.DB, helpers.NewMockRedis().Client)
+		service.SetPollen(&fakePollenSource{err: weather.ErrNoPollenData})
+
+		configRows(mockDB, pollenConfig("3"))
+
+		triggered, err := service.CheckAlerts(context.Background(), helpers.MockWeatherData(123), user)
+		require.NoError(t, err)
+		assert.Empty(t, triggered)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+// fakeTideSource returns the same tides for every place and counts how often
+// it was asked
+type fakeTideSource struct {
+	data  *weather.TideData
+	err   error
+	calls int
+}
+
+func (f *fakeTideSource) GetTidePredictions(ctx context.Context, lat, lon float64, days int) (*weather.TideData, error) {
+	f.calls++
+	return f.data, f.err
+}
+
+func TestAlertService_CheckAlerts_Tide(t *testing.T) {
+	now := time.Date(2024, 10, 16, 8, 0, 0, 0, time.UTC)
+	tideConfig := func(threshold string) *models.AlertConfig {
+		config := helpers.MockAlertConfig(123)
+		config.AlertType = models.AlertTide
+		config.Condition = `{"operator":"gt","value":` + threshold + `}`
+		return config
+	}
+	configRows := func(mockDB *helpers.MockDB, config *models.AlertConfig) {
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "alert_configs"`).WillReturnRows(
+			mockDB.Mock.NewRows([]string{"id", "user_id", "alert_type", "condition", "threshold", "is_active"}).
+				AddRow(config.ID, config.UserID, config.AlertType, config.Condition, config.Threshold, true))
+	}
+	user := &models.User{ID: 123, Latitude: 50.37, Longitude: -4.14}
+
+	t.Run("highest high tide of the next 24 hours triggers", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := NewAlertService(mockDB.DB, helpers.NewMockRedis().Client)
+		service.now = func() time.Time { return now }
+		source := &fakeTideSource{data: &weather.TideData{Points: []weather.TidePoint{
+			{Time: now.Add(-2 * time.Hour), Height: 5.1, Type: weather.TideHigh}, // Already past
+			{Time: now.Add(4 * time.Hour), Height: 4.2, Type: weather.TideHigh},
+			{Time: now.Add(10 * time.Hour), Height: -2.1, Type: weather.TideLow},
+			{Time: now.Add(16 * time.Hour), Height: 3.9, Type: weather.TideHigh},
+			{Time: now.Add(30 * time.Hour), Height: 4.8, Type: weather.TideHigh}, // After tomorrow morning
+		}}}
+		service.SetTides(source)
+
+		configRows(mockDB, tideConfig("3.5"))
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "environmental_alerts"`).
+			WithArgs(int64(123), models.AlertTide, models.SeverityMedium, "Tide Alert", "High tide of 4.2 m at 12:00", 4.2, 3.5, false, nil, models.SuppressionReason(""), helpers.AnyTime{}, helpers.AnyTime{}).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mockDB.Mock.ExpectCommit()
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "alert_configs" SET "last_checked_at"=\$1,"last_triggered"=\$2,"last_value"=\$3`).
+			WillReturnResult(helpers.NewResult(1, 1))
+		mockDB.Mock.ExpectCommit()
+
+		triggered, err := service.CheckAlerts(context.Background(), helpers.MockWeatherData(123), user)
+		require.NoError(t, err)
+		require.Len(t, triggered, 1)
+		assert.Equal(t, 4.2, triggered[0].Value)
+		assert.Equal(t, 1, source.calls)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("skipped inland", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := NewAlertService(mockDB.DB, helpers.NewMockRedis().Client)
+		service.SetTides(&fakeTideSource{err: weather.ErrNoTideData})
+
+		configRows(mockDB, tideConfig("3.5"))
+
+		triggered, err := service.CheckAlerts(context.Background(), helpers.MockWeatherData(123), user)
+		require.NoError(t, err)
+		assert.Empty(t, triggered)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestAlertService_CheckAlerts_ApproximateLocation(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	mockRedis := helpers.NewMockRedis()
+	logger := zerolog.Nop()
+
+	weatherService := NewWeatherService(&config.WeatherConfig{OpenWeatherAPIKey: "test-key"}, mockRedis.Client, &logger)
+	alertService := NewAlertService(mockDB.DB, mockRedis.Client)
+
+	// City-level users carry the centroid, so weather is resolved for the city centre
+	user := helpers.MockUser(123)
+	user.LocationName = "Kyiv"
+	user.Latitude = 50.4501
+	user.Longitude = 30.5234
+	user.LocationApproximate = true
+
+	weatherJSON, _ := json.Marshal(weather.WeatherData{Temperature: 31.0, Humidity: 40, Timestamp: time.Now().UTC()})
+	airJSON, _ := json.Marshal(weather.AirQualityData{AQI: 2})
+	mockRedis.Mock.ExpectGet("weather:current:50.45:30.52").SetVal(string(weatherJSON))
+	mockRedis.Mock.ExpectGet("weather:air:50.45:30.52").SetVal(string(airJSON))
+
+	weatherData, err := weatherService.GetCurrentWeatherByCoords(context.Background(), user.Latitude, user.Longitude)
+	require.NoError(t, err)
+
+	alertConfig := helpers.MockAlertConfig(user.ID)
+	rows := mockDB.Mock.NewRows([]string{
+		"id", "user_id", "alert_type", "condition", "threshold", "is_active", "last_triggered", "created_at", "updated_at",
+	}).AddRow(
+		alertConfig.ID, alertConfig.UserID, alertConfig.AlertType, alertConfig.Condition,
+		alertConfig.Threshold, alertConfig.IsActive, alertConfig.LastTriggered,
+		alertConfig.CreatedAt, alertConfig.UpdatedAt,
+	)
+
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "alert_configs" WHERE user_id = \$1 AND is_active = \$2`).
+		WithArgs(user.ID, true).
+		WillReturnRows(rows)
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectQuery(`INSERT INTO "environmental_alerts"`).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mockDB.Mock.ExpectCommit()
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectExec(`UPDATE "alert_configs" SET "last_checked_at"=\$1,"last_triggered"`).
+		WillReturnResult(helpers.NewResult(1, 1))
+	mockDB.Mock.ExpectCommit()
+
+	triggeredAlerts, err := alertService.CheckAlerts(context.Background(), weatherData.ToModelWeatherData(), user)
+
+	require.NoError(t, err)
+	assert.Len(t, triggeredAlerts, 1)
+	assert.Equal(t, 31.0, triggeredAlerts[0].Value)
+	mockDB.ExpectationsWereMet(t)
+	mockRedis.ExpectationsWereMet(t)
+}
+
+func TestAlertService_CheckAlerts_Suppression(t *testing.T) {
+	independenceDay := time.Date(2025, time.July, 4, 12, 0, 0, 0, time.UTC)
+	configColumns := []string{"id", "user_id", "alert_type", "condition", "threshold", "is_active", "suppress_on_holidays"}
+
+	// check runs a temperature alert (threshold 25°C) against temperature and
+	// expects the trigger to be recorded with the given suppression
+	check := func(t *testing.T, service *AlertService, mockDB *helpers.MockDB, suppressOnHolidays bool, temperature float64, expectQuietDays bool, suppressed models.SuppressionReason) []models.EnvironmentalAlert {
+		alertConfig := helpers.MockAlertConfig(123)
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "alert_configs"`).
+			WillReturnRows(mockDB.Mock.NewRows(configColumns).AddRow(
+				alertConfig.ID, alertConfig.UserID, alertConfig.AlertType, alertConfig.Condition, alertConfig.Threshold, true, suppressOnHolidays))
+		if expectQuietDays {
+			mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "quiet_periods" WHERE user_id = \$1 AND start_date <= \$2 AND end_date >= \$3`).
+				WithArgs(int64(123), date(2025, time.July, 4), date(2025, time.July, 4)).
+				WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(1))
+		}
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "environmental_alerts"`).
+			WithArgs(int64(123), models.AlertTemperature, helpers.AnyValue{}, "Temperature Alert", helpers.AnyValue{}, temperature, 25.0, false, nil, suppressed, helpers.AnyTime{}, helpers.AnyTime{}).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mockDB.Mock.ExpectCommit()
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "alert_configs" SET "last_checked_at"=\$1,"last_triggered"`).
+			WillReturnResult(helpers.NewResult(1, 1))
+		mockDB.Mock.ExpectCommit()
+
+		weatherData := helpers.MockWeatherData(123)
+		weatherData.Temperature = temperature
+		user := &models.User{ID: 123, Country: "US", Timezone: "America/New_York"}
+
+		triggered, err := service.CheckAlerts(context.Background(), weatherData, user)
+		require.NoError(t, err)
+		mockDB.ExpectationsWereMet(t)
+		return triggered
+	}
+
+	newService := func(t *testing.T) (*AlertService, *helpers.MockDB) {
+		mockDB := helpers.NewMockDB(t)
+		t.Cleanup(func() { _ = mockDB.Close() })
+		service := NewAlertService(mockDB.DB, helpers.NewMockRedis().Client)
+		service.now = func() time.Time { return independenceDay }
+		return service, mockDB
+	}
+
+	t.Run("routine trigger on a public holiday is recorded as suppressed", func(t *testing.T) {
+		service, mockDB := newService(t)
+		triggered := check(t, service, mockDB, true, 26.5, false, models.SuppressedHoliday)
+		assert.Empty(t, triggered)
+	})
+
+	t.Run("extreme trigger on a public holiday is sent", func(t *testing.T) {
+		service, mockDB := newService(t)
+		triggered := check(t, service, mockDB, true, 41.0, false, "")
+		require.Len(t, triggered, 1)
+		assert.Equal(t, models.SeverityCritical, triggered[0].Severity)
+	})
+
+	t.Run("routine trigger on a quiet day is recorded as suppressed", func(t *testing.T) {
+		service, mockDB := newService(t)
+		service.SetQuietDays(NewQuietDayService(mockDB.DB))
+		triggered := check(t, service, mockDB, false, 26.5, true, models.SuppressedQuietDay)
+		assert.Empty(t, triggered)
+	})
+}
+
 func TestAlertService_UpdateAlert(t *testing.T) {
 	// Setup
 	mockDB := helpers.NewMockDB(t)
@@ -553,6 +1185,18 @@ func TestAlertService_CalculateSeverity(t *testing.T) {
 		}
 	})
 
+	t.Run("tide alert severities", func(t *testing.T) {
+		assert.Equal(t, models.SeverityHigh, service.calculateSeverity(models.AlertTide, 5.2, 4))
+		assert.Equal(t, models.SeverityMedium, service.calculateSeverity(models.AlertTide, 4.7, 4))
+		assert.Equal(t, models.SeverityLow, service.calculateSeverity(models.AlertTide, 4.3, 4))
+	})
+
+	t.Run("pollen alert severities", func(t *testing.T) {
+		assert.Equal(t, models.SeverityHigh, service.calculateSeverity(models.AlertPollen, 5, 3))
+		assert.Equal(t, models.SeverityMedium, service.calculateSeverity(models.AlertPollen, 4, 3))
+		assert.Equal(t, models.SeverityLow, service.calculateSeverity(models.AlertPollen, 3, 2))
+	})
+
 	t.Run("default alert type - medium severity", func(t *testing.T) {
 		result := service.calculateSeverity(models.AlertHumidity, 80.0, 50.0)
 		assert.Equal(t, models.SeverityMedium, result)
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+
+	"github.com/valpere/shopogoda/internal"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+const (
+	// DefaultAnomalyThreshold is how many standard deviations from the seasonal
+	// baseline make a day's temperature unusual
+	DefaultAnomalyThreshold = 2.0
+
+	// minBaselineSamples is the fewest days a baseline may rest on. With fewer,
+	// the mean and spread are too noisy to call anything unusual.
+	minBaselineSamples = 7
+	// minBaselineStdDev floors the spread in °C, so a run of near-identical
+	// days does not turn a one-degree change into an anomaly
+	minBaselineStdDev = 1.0
+	// anomalyHistoryYears bounds how far back the seasonal baseline looks
+	anomalyHistoryYears = 5
+	// fallbackBaselineDays is the reach, each way, of the current year's
+	// surrounding weeks used when earlier years have too few days
+	fallbackBaselineDays = 14
+
+	// observationInterval keeps at most one recorded observation per user per hour
+	observationInterval  = time.Hour
+	observationKeyPrefix = "weather_observation:"
+	// unusualNoteKeyPrefix marks users who already got today's standalone note
+	unusualNoteKeyPrefix = "unusual_weather_note:"
+)
+
+// DailyTemperature is the mean of the temperatures recorded on one UTC day
+type DailyTemperature struct {
+	Day  time.Time
+	Mean float64
+}
+
+// Anomaly describes a temperature that is unusual for the season
+type Anomaly struct {
+	Observed   float64 // °C
+	Baseline   float64 // Mean of the baseline days, °C
+	StdDev     float64 // Spread of the baseline days, floored at minBaselineStdDev
+	Deviations float64 // (Observed - Baseline) / StdDev
+	Samples    int     // Days in the baseline
+}
+
+// Warmer reports whether the temperature is above the baseline
+func (a *Anomaly) Warmer() bool {
+	return a.Deviations > 0
+}
+
+// seasonalBaseline picks the days a temperature on today is compared with:
+// the same ISO calendar week in earlier years, or, when those are fewer than
+// minBaselineSamples, the surrounding weeks of the current year
+func seasonalBaseline(history []DailyTemperature, today time.Time) []float64 {
+	year, week := today.ISOWeek()
+
+	var sameWeek, surrounding []float64
+	for _, day := range history {
+		dayYear, dayWeek := day.Day.ISOWeek()
+		if dayYear < year && dayWeek == week {
+			sameWeek = append(sameWeek, day.Mean)
+		}
+		distance := today.Sub(day.Day).Abs()
+		if dayYear == year && distance > 0 && distance <= fallbackBaselineDays*24*time.Hour {
+			surrounding = append(surrounding, day.Mean)
+		}
+	}
+
+	if len(sameWeek) >= minBaselineSamples {
+		return sameWeek
+	}
+	return surrounding
+}
+
+// DetectAnomaly compares an observed temperature with its seasonal baseline
+// and returns the anomaly when it lies more than threshold standard
+// deviations away. It returns nil for ordinary days and when the baseline has
+// fewer than minBaselineSamples days.
+//
+// The statistics are deliberately plain: the baseline is the arithmetic mean
+// of the daily means, the spread their sample standard deviation.
+func DetectAnomaly(history []DailyTemperature, today time.Time, observed, threshold float64) *Anomaly {
+	samples := seasonalBaseline(history, today)
+	if len(samples) < minBaselineSamples {
+		return nil
+	}
+
+	var sum float64
+	for _, value := range samples {
+		sum += value
+	}
+	mean := sum / float64(len(samples))
+
+	var squares float64
+	for _, value := range samples {
+		squares += (value - mean) * (value - mean)
+	}
+	stdDev := math.Max(math.Sqrt(squares/float64(len(samples)-1)), minBaselineStdDev)
+
+	deviations := (observed - mean) / stdDev
+	if math.Abs(deviations) <= threshold {
+		return nil
+	}
+
+	return &Anomaly{
+		Observed:   observed,
+		Baseline:   mean,
+		StdDev:     stdDev,
+		Deviations: deviations,
+		Samples:    len(samples),
+	}
+}
+
+// AnomalyService records the weather at users' locations and notices days
+// that are unusually warm or cold for the season
+type AnomalyService struct {
+	db           *gorm.DB
+	redis        *redis.Client
+	localization *LocalizationService
+	logger       *zerolog.Logger
+	threshold    float64
+}
+
+func NewAnomalyService(db *gorm.DB, redis *redis.Client, localization *LocalizationService, logger *zerolog.Logger, threshold float64) *AnomalyService {
+	if threshold <= 0 {
+		threshold = DefaultAnomalyThreshold
+	}
+	return &AnomalyService{
+		db:           db,
+		redis:        redis,
+		localization: localization,
+		logger:       logger,
+		threshold:    threshold,
+	}
+}
+
+// RecordObservation stores the current weather at the user's location as
+// history for the seasonal baseline, at most once per observationInterval. It
+// reports whether an observation was stored.
+func (s *AnomalyService) RecordObservation(ctx context.Context, userID int64, current *WeatherData, now time.Time) (bool, error) {
+	key := observationKeyPrefix + strconv.FormatInt(userID, 10)
+	claimed, err := s.redis.SetNX(ctx, key, now.Unix(), observationInterval).Result()
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	observation := current.ToModelWeatherData()
+	observation.UserID = userID
+	if err := s.db.WithContext(ctx).Create(observation).Error; err != nil {
+		return false, fmt.Errorf("failed to record weather observation: %w", err)
+	}
+	return true, nil
+}
+
+// Check compares the observed temperature with the user's history. It
+// returns nil when the day is ordinary or the history is too short.
+func (s *AnomalyService) Check(ctx context.Context, userID int64, observed float64, now time.Time) (*Anomaly, error) {
+	today := now.UTC().Truncate(24 * time.Hour)
+	history, err := s.dailyTemperatures(ctx, userID, today.AddDate(-anomalyHistoryYears, 0, -7), today)
+	if err != nil {
+		return nil, err
+	}
+	return DetectAnomaly(history, today, observed, s.threshold), nil
+}
+
+// dailyTemperatures loads the user's daily mean temperatures from since up to,
+// but not including, until
+func (s *AnomalyService) dailyTemperatures(ctx context.Context, userID int64, since, until time.Time) ([]DailyTemperature, error) {
+	var history []DailyTemperature
+	err := s.db.WithContext(ctx).Model(&models.WeatherData{}).
+		Select("DATE(timestamp) AS day, AVG(temperature) AS mean").
+		Where("user_id = ? AND timestamp >= ? AND timestamp < ?", userID, since, until).
+		Group("DATE(timestamp)").
+		Order("day").
+		Scan(&history).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load temperature history: %w", err)
+	}
+	return history, nil
+}
+
+// Note phrases the anomaly as one line in the given language
+func (s *AnomalyService) Note(ctx context.Context, language string, anomaly *Anomaly) string {
+	key := "unusual_weather_cold"
+	if anomaly.Warmer() {
+		key = "unusual_weather_warm"
+	}
+	if language == "" {
+		language = internal.DefaultLanguage
+	}
+	return s.localization.T(ctx, language, key,
+		weather.FormatTemp(anomaly.Observed, weather.UnitsMetric),
+		weather.FormatTemp(anomaly.Baseline, weather.UnitsMetric))
+}
+
+// ClaimDailyNote reports whether the user may get today's standalone note,
+// marking it as sent; each user gets at most one per UTC day
+func (s *AnomalyService) ClaimDailyNote(ctx context.Context, userID int64, now time.Time) (bool, error) {
+	key := unusualNoteKeyPrefix + strconv.FormatInt(userID, 10) + ":" + now.UTC().Format("2006-01-02")
+	return s.redis.SetNX(ctx, key, now.Unix(), 48*time.Hour).Result()
+}
+
+// NoteForUser returns the anomaly note for today's observed temperature in
+// the user's language, or an empty string on an ordinary day
+func (s *AnomalyService) NoteForUser(ctx context.Context, user *models.User, observed float64, now time.Time) string {
+	anomaly, err := s.Check(ctx, user.ID, observed, now)
+	if err != nil {
+		s.logger.Warn().Err(err).Int64("user_id", user.ID).Msg("Failed to check for unusual weather")
+		return ""
+	}
+	if anomaly == nil {
+		return ""
+	}
+	return s.Note(ctx, user.Language, anomaly)
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/locales"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+// seasonHistory returns daily means for the ISO week of today in each of the
+// given earlier years, cycling through temps
+func seasonHistory(today time.Time, years int, temps ...float64) []DailyTemperature {
+	var history []DailyTemperature
+	for y := 1; y <= years; y++ {
+		sameDay := today.AddDate(-y, 0, 0)
+		_, week := today.ISOWeek()
+		for offset := -10; offset <= 10; offset++ {
+			day := sameDay.AddDate(0, 0, offset)
+			if _, dayWeek := day.ISOWeek(); dayWeek != week {
+				continue
+			}
+			history = append(history, DailyTemperature{Day: day, Mean: temps[len(history)%len(temps)]})
+		}
+	}
+	return history
+}
+
+func TestDetectAnomaly(t *testing.T) {
+	today := time.Date(2025, 4, 16, 0, 0, 0, 0, time.UTC)
+	history := seasonHistory(today, 3, 12, 14, 13, 15, 11, 13, 14)
+
+	t.Run("warm day", func(t *testing.T) {
+		anomaly := DetectAnomaly(history, today, 22, DefaultAnomalyThreshold)
+		require.NotNil(t, anomaly)
+		assert.True(t, anomaly.Warmer())
+		assert.Equal(t, 21, anomaly.Samples)
+		assert.InDelta(t, 13.14, anomaly.Baseline, 0.01)
+		assert.Greater(t, anomaly.Deviations, DefaultAnomalyThreshold)
+	})
+
+	t.Run("cold day", func(t *testing.T) {
+		anomaly := DetectAnomaly(history, today, 4, DefaultAnomalyThreshold)
+		require.NotNil(t, anomaly)
+		assert.False(t, anomaly.Warmer())
+	})
+
+	t.Run("ordinary day", func(t *testing.T) {
+		assert.Nil(t, DetectAnomaly(history, today, 15, DefaultAnomalyThreshold))
+	})
+
+	t.Run("threshold is configurable", func(t *testing.T) {
+		assert.NotNil(t, DetectAnomaly(history, today, 15.5, 1))
+	})
+}
+
+func TestDetectAnomaly_SmallSample(t *testing.T) {
+	today := time.Date(2025, 4, 16, 0, 0, 0, 0, time.UTC)
+
+	var history []DailyTemperature
+	for i := 1; i < minBaselineSamples; i++ {
+		history = append(history, DailyTemperature{Day: today.AddDate(0, 0, -i), Mean: 13})
+	}
+
+	assert.Nil(t, DetectAnomaly(history, today, 40, DefaultAnomalyThreshold), "too few days for a baseline")
+	assert.Nil(t, DetectAnomaly(nil, today, 40, DefaultAnomalyThreshold))
+}
+
+func TestDetectAnomaly_FallsBackToSurroundingWeeks(t *testing.T) {
+	today := time.Date(2025, 4, 16, 0, 0, 0, 0, time.UTC)
+
+	// A few days of an earlier year are too little, so the current year's
+	// last two weeks count
+	history := seasonHistory(today, 1, 30)[:3]
+	for i := 1; i <= fallbackBaselineDays; i++ {
+		history = append(history, DailyTemperature{Day: today.AddDate(0, 0, -i), Mean: float64(12 + i%3)})
+	}
+
+	anomaly := DetectAnomaly(history, today, 22, DefaultAnomalyThreshold)
+	require.NotNil(t, anomaly)
+	assert.Equal(t, fallbackBaselineDays, anomaly.Samples)
+	assert.InDelta(t, 13, anomaly.Baseline, 0.1)
+}
+
+func TestDetectAnomaly_FlatHistory(t *testing.T) {
+	today := time.Date(2025, 4, 16, 0, 0, 0, 0, time.UTC)
+	history := seasonHistory(today, 2, 10)
+
+	// The spread is floored, so a small change on flat history is not unusual
+	assert.Nil(t, DetectAnomaly(history, today, 11.5, DefaultAnomalyThreshold))
+	anomaly := DetectAnomaly(history, today, 12.5, DefaultAnomalyThreshold)
+	require.NotNil(t, anomaly)
+	assert.Equal(t, minBaselineStdDev, anomaly.StdDev)
+}
+
+func TestAnomalyService_Note(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	localization := NewLocalizationService(logger)
+	require.NoError(t, localization.LoadTranslations(locales.LocalesFS))
+	service := NewAnomalyService(nil, nil, localization, logger, 0)
+	ctx := context.Background()
+
+	warm := &Anomaly{Observed: 22.04, Baseline: 13.14, Deviations: 3.2}
+	cold := &Anomaly{Observed: 3.96, Baseline: 13.14, Deviations: -2.9}
+
+	assert.Equal(t, "📈 Unusually warm for this time of year: 22.0°C, usually about 13.1°C", service.Note(ctx, "en-US", warm))
+	assert.Equal(t, "📉 Unusually cold for this time of year: 4.0°C, usually about 13.1°C", service.Note(ctx, "en-US", cold))
+	assert.Equal(t, "📈 Незвично тепло як для цієї пори року: 22.0°C, зазвичай близько 13.1°C", service.Note(ctx, "uk-UA", warm))
+	assert.Equal(t, service.Note(ctx, "en-US", warm), service.Note(ctx, "", warm))
+}
+
+func TestAnomalyService_Check(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	service := NewAnomalyService(mockDB.DB, nil, nil, helpers.NewSilentTestLogger(), 0)
+	now := time.Date(2025, 4, 16, 9, 30, 0, 0, time.UTC)
+	today := time.Date(2025, 4, 16, 0, 0, 0, 0, time.UTC)
+
+	rows := mockDB.Mock.NewRows([]string{"day", "mean"})
+	for _, day := range seasonHistory(today, 3, 12, 14, 13, 15, 11, 13, 14) {
+		rows.AddRow(day.Day, day.Mean)
+	}
+	mockDB.Mock.ExpectQuery(`SELECT DATE\(timestamp\) AS day, AVG\(temperature\) AS mean FROM "weather_data" WHERE user_id = \$1 AND timestamp >= \$2 AND timestamp < \$3 GROUP BY DATE\(timestamp\) ORDER BY day`).
+		WithArgs(int64(42), today.AddDate(-anomalyHistoryYears, 0, -7), today).
+		WillReturnRows(rows)
+
+	anomaly, err := service.Check(context.Background(), 42, 22, now)
+	require.NoError(t, err)
+	require.NotNil(t, anomaly)
+	assert.True(t, anomaly.Warmer())
+	mockDB.ExpectationsWereMet(t)
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/valpere/shopogoda/internal/models"
+)
+
+// AuditPageSize is how many entries /auditlog shows at once
+const AuditPageSize = 10
+
+// Audited admin actions
+const (
+	AuditRoleChanged         = "role_changed"          // Target's role changed; metadata has old_role and new_role, and via for changes no admin made
+	AuditRoleChangeFailed    = "role_change_failed"    // Confirmed role change refused; metadata has new_role and error
+	AuditRoleChangeCancelled = "role_change_cancelled" // Role change confirmation dismissed
+	AuditBroadcastSent       = "broadcast_sent"        // Broadcast started; metadata has broadcast_id, and message when sent without a preview
+	AuditBroadcastCancelled  = "broadcast_cancelled"   // Running broadcast stopped; metadata has broadcast_id
+	AuditUsersViewed         = "users_viewed"          // User list or role overview opened; metadata has view
+	AuditDeadLetters         = "dead_letters"          // Dead letters retried, purged or exported; metadata has action, error_class and count
+	AuditUserDeactivated     = "user_deactivated"      // Target deactivated
+	AuditFeedbackResolved    = "feedback_resolved"     // Feedback marked resolved; metadata has feedback_id
+	AuditFeedbackReplied     = "feedback_replied"      // Reply sent to the target's feedback; metadata has feedback_id and message
+	AuditDemoReset           = "demo_reset"            // Demo data cleared and seeded again
+	AuditDemoCleared         = "demo_cleared"          // Demo data removed
+)
+
+// AuditEntry is an admin action to record
+type AuditEntry struct {
+	ActorID  int64          // Admin who took the action; 0 for the deployment itself
+	Action   string         // One of the Audit action constants
+	TargetID int64          // User acted on; 0 for actions on no user
+	Metadata map[string]any // Details of the action, stored as JSON
+	IP       string         // Address the update came from over HTTP, when it did
+}
+
+// AuditService keeps the structured log of admin actions
+type AuditService struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{db: db, now: time.Now}
+}
+
+// Log records an admin action
+func (s *AuditService) Log(ctx context.Context, entry AuditEntry) error {
+	metadata := json.RawMessage("{}")
+	if len(entry.Metadata) > 0 {
+		encoded, err := json.Marshal(entry.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit metadata: %w", err)
+		}
+		metadata = encoded
+	}
+
+	record := &models.AuditLog{
+		ActorID:   entry.ActorID,
+		Action:    entry.Action,
+		TargetID:  entry.TargetID,
+		Metadata:  metadata,
+		IP:        entry.IP,
+		CreatedAt: s.now().UTC(),
+	}
+	if err := s.db.WithContext(ctx).Create(record).Error; err != nil {
+		return fmt.Errorf("failed to record audit log entry: %w", err)
+	}
+	return nil
+}
+
+// List returns one page of the audit log, newest first, and how many entries
+// there are in all. Pages count from zero.
+func (s *AuditService) List(ctx context.Context, page int) ([]models.AuditLog, int64, error) {
+	var total int64
+	if err := s.db.WithContext(ctx).Model(&models.AuditLog{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit log entries: %w", err)
+	}
+
+	var entries []models.AuditLog
+	err := s.db.WithContext(ctx).
+		Order("created_at DESC").
+		Offset(max(page, 0) * AuditPageSize).
+		Limit(AuditPageSize).
+		Find(&entries).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit log entries: %w", err)
+	}
+	return entries, total, nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func newTestAuditService(t *testing.T) (*AuditService, *helpers.MockDB) {
+	mockDB := helpers.NewMockDB(t)
+	t.Cleanup(func() { _ = mockDB.Close() })
+	service := NewAuditService(mockDB.DB)
+	service.now = func() time.Time { return time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC) }
+	return service, mockDB
+}
+
+func TestAuditService_Log(t *testing.T) {
+	t.Run("records the entry with its metadata as JSON", func(t *testing.T) {
+		service, mockDB := newTestAuditService(t)
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "audit_logs" \("actor_id","action","target_id","metadata","ip","created_at"\)`).
+			WithArgs(int64(100), AuditBroadcastSent, int64(0), []byte(`{"broadcast_id":"a1b2c3d4","message":"Storm tonight"}`), "", time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mockDB.Mock.ExpectCommit()
+
+		err := service.Log(context.Background(), AuditEntry{
+			ActorID:  100,
+			Action:   AuditBroadcastSent,
+			Metadata: map[string]any{"broadcast_id": "a1b2c3d4", "message": "Storm tonight"},
+		})
+		require.NoError(t, err)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("entry without metadata stores an empty object", func(t *testing.T) {
+		service, mockDB := newTestAuditService(t)
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+			WithArgs(int64(100), AuditRoleChangeCancelled, int64(200), []byte(`{}`), "203.0.113.7", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mockDB.Mock.ExpectCommit()
+
+		err := service.Log(context.Background(), AuditEntry{ActorID: 100, Action: AuditRoleChangeCancelled, TargetID: 200, IP: "203.0.113.7"})
+		require.NoError(t, err)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		service, mockDB := newTestAuditService(t)
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "audit_logs"`).WillReturnError(errors.New("connection lost"))
+		mockDB.Mock.ExpectRollback()
+
+		err := service.Log(context.Background(), AuditEntry{ActorID: 100, Action: AuditUsersViewed})
+		assert.ErrorContains(t, err, "failed to record audit log entry")
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestAuditService_List(t *testing.T) {
+	service, mockDB := newTestAuditService(t)
+
+	mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "audit_logs"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "audit_logs" ORDER BY created_at DESC LIMIT \$1 OFFSET \$2`).
+		WithArgs(AuditPageSize, AuditPageSize).
+		WillReturnRows(sqlmock.NewRows([]string{"actor_id", "action", "target_id", "metadata"}).
+			AddRow(int64(100), AuditRoleChanged, int64(200), []byte(`{"new_role":2,"old_role":1}`)))
+
+	entries, total, err := service.List(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), total)
+	require.Len(t, entries, 1)
+	assert.Equal(t, AuditRoleChanged, entries[0].Action)
+	assert.JSONEq(t, `{"new_role":2,"old_role":1}`, string(entries[0].Metadata))
+	mockDB.ExpectationsWereMet(t)
+}
@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// broadcastProgressInterval is how often a running broadcast reports progress
+	broadcastProgressInterval = 3 * time.Second
+
+	// defaultBroadcastWorkers is how many messages of a broadcast are in
+	// flight at once unless configured otherwise
+	defaultBroadcastWorkers = 10
+
+	// broadcastDraftTTL is how long a dry run can still be sent
+	broadcastDraftTTL = 30 * time.Minute
+)
+
+// ErrBroadcastDraftExpired is returned for a dry run that expired, was sent
+// already or belongs to another admin
+var ErrBroadcastDraftExpired = errors.New("broadcast draft expired")
+
+// BroadcastDraft is a broadcast previewed with a dry run, waiting for the
+// admin to send it
+type BroadcastDraft struct {
+	ID         string
+	SenderID   int64
+	Message    string
+	Recipients int // Active users other than the sender when previewed
+	created    time.Time
+}
+
+// BroadcastProgress counts the recipients a broadcast has reached
+type BroadcastProgress struct {
+	ID          string
+	Total       int
+	Sent        int
+	Failed      int
+	Deactivated int // Recipients who blocked the bot, now marked inactive
+	Done        bool
+	Cancelled   bool
+}
+
+// Attempted is the number of recipients the broadcast has tried so far
+func (p BroadcastProgress) Attempted() int {
+	return p.Sent + p.Failed + p.Deactivated
+}
+
+// BroadcastService sends an admin message to every active user in the
+// background. A pool of workers keeps several sends in flight; they all go
+// through the bulk lane of the outbound limiter, which paces them at the
+// configured bulk rate and retries after a 429 once Telegram's retry_after has
+// passed. Users who blocked the bot are marked inactive so later broadcasts
+// skip them.
+type BroadcastService struct {
+	user      *UserService
+	messaging *MessagingService
+	logger    *zerolog.Logger
+
+	progressInterval time.Duration
+	workers          int
+	now              func() time.Time
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+	drafts  map[string]*BroadcastDraft
+	wg      sync.WaitGroup
+}
+
+func NewBroadcastService(userService *UserService, messaging *MessagingService, logger *zerolog.Logger) *BroadcastService {
+	return &BroadcastService{
+		user:             userService,
+		messaging:        messaging,
+		logger:           logger,
+		progressInterval: broadcastProgressInterval,
+		workers:          defaultBroadcastWorkers,
+		now:              time.Now,
+		running:          make(map[string]context.CancelFunc),
+		drafts:           make(map[string]*BroadcastDraft),
+	}
+}
+
+// SetWorkers sets how many messages of a broadcast are in flight at once;
+// zero or less keeps the default
+func (s *BroadcastService) SetWorkers(workers int) {
+	if workers > 0 {
+		s.workers = workers
+	}
+}
+
+// recipients lists the active users a broadcast from the sender goes to
+func (s *BroadcastService) recipients(ctx context.Context, senderID int64) ([]int64, error) {
+	users, err := s.user.GetActiveUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := make([]int64, 0, len(users))
+	for _, user := range users {
+		if user.ID != senderID {
+			recipients = append(recipients, user.ID)
+		}
+	}
+	return recipients, nil
+}
+
+// DryRun counts the recipients of message without sending it, and keeps it
+// as a draft the sender can send with StartDraft for a while
+func (s *BroadcastService) DryRun(ctx context.Context, senderID int64, message string) (*BroadcastDraft, error) {
+	recipients, err := s.recipients(ctx, senderID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	draft := &BroadcastDraft{
+		ID:         uuid.NewString()[:8],
+		SenderID:   senderID,
+		Message:    message,
+		Recipients: len(recipients),
+		created:    now,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, old := range s.drafts {
+		if now.Sub(old.created) >= broadcastDraftTTL {
+			delete(s.drafts, id)
+		}
+	}
+	s.drafts[draft.ID] = draft
+	return draft, nil
+}
+
+// StartDraft sends a dry run's message like Start. A draft is sent once, and
+// only by the admin who made it; recipients are looked up again.
+func (s *BroadcastService) StartDraft(ctx context.Context, draftID string, senderID int64, report func(BroadcastProgress)) (string, error) {
+	s.mu.Lock()
+	draft, ok := s.drafts[draftID]
+	ok = ok && draft.SenderID == senderID && s.now().Sub(draft.created) < broadcastDraftTTL
+	if ok {
+		delete(s.drafts, draftID)
+	}
+	s.mu.Unlock()
+	if !ok {
+		return "", ErrBroadcastDraftExpired
+	}
+
+	return s.Start(ctx, senderID, draft.Message, report)
+}
+
+// Start queues message for every active user except the sender and returns
+// once the broadcast is running. report receives the progress: first with
+// nothing sent yet, before Start returns, then every few seconds, and last
+// with Done set. Calls to report never overlap.
+func (s *BroadcastService) Start(ctx context.Context, senderID int64, message string, report func(BroadcastProgress)) (string, error) {
+	recipients, err := s.recipients(ctx, senderID)
+	if err != nil {
+		return "", err
+	}
+
+	id := uuid.NewString()[:8]
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	s.mu.Lock()
+	s.running[id] = cancel
+	s.mu.Unlock()
+
+	progress := BroadcastProgress{ID: id, Total: len(recipients)}
+	report(progress)
+
+	s.logger.Info().Str("broadcast_id", id).Int64("sender_id", senderID).Int("recipients", len(recipients)).Msg("Broadcast started")
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.finish(id)
+		s.run(runCtx, progress, recipients, message, report)
+	}()
+
+	return id, nil
+}
+
+// broadcastOutcome is what became of one message of a broadcast
+type broadcastOutcome int
+
+const (
+	broadcastSent broadcastOutcome = iota
+	broadcastFailed
+	broadcastDeactivated
+	broadcastInterrupted // Cancelled before or while sending; not a delivery failure
+)
+
+// run hands the recipients to the workers and tallies what they report, so
+// that progress is counted and reported from this goroutine alone
+func (s *BroadcastService) run(ctx context.Context, progress BroadcastProgress, recipients []int64, message string, report func(BroadcastProgress)) {
+	jobs := make(chan int64)
+	outcomes := make(chan broadcastOutcome)
+
+	go func() {
+		defer close(jobs)
+		for _, userID := range recipients {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case jobs <- userID:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var workers sync.WaitGroup
+	for range min(s.workers, len(recipients)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			vars := map[string]string{"message": message}
+			for userID := range jobs {
+				outcomes <- s.send(ctx, userID, vars)
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(outcomes)
+	}()
+
+	lastReport := time.Now()
+	for outcome := range outcomes {
+		switch outcome {
+		case broadcastSent:
+			progress.Sent++
+		case broadcastFailed:
+			progress.Failed++
+		case broadcastDeactivated:
+			progress.Deactivated++
+		}
+
+		if time.Since(lastReport) >= s.progressInterval {
+			report(progress)
+			lastReport = time.Now()
+		}
+	}
+
+	progress.Cancelled = progress.Attempted() < progress.Total
+	progress.Done = true
+	report(progress)
+
+	s.logger.Info().
+		Str("broadcast_id", progress.ID).
+		Int("sent", progress.Sent).
+		Int("failed", progress.Failed).
+		Int("deactivated", progress.Deactivated).
+		Bool("cancelled", progress.Cancelled).
+		Msg("Broadcast finished")
+}
+
+// send delivers the broadcast to one recipient, deactivating a user who
+// blocked the bot
+func (s *BroadcastService) send(ctx context.Context, userID int64, vars map[string]string) broadcastOutcome {
+	if ctx.Err() != nil {
+		return broadcastInterrupted
+	}
+
+	err := s.messaging.Send(ctx, userID, TemplateAdminBroadcast, vars)
+	switch {
+	case err == nil:
+		return broadcastSent
+	case blockedByUser(err):
+		if err := s.user.UpdateUserSettings(context.WithoutCancel(ctx), userID, map[string]interface{}{"is_active": false}); err != nil {
+			s.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to deactivate user who blocked the bot")
+		}
+		return broadcastDeactivated
+	case ctx.Err() != nil:
+		return broadcastInterrupted
+	default:
+		return broadcastFailed
+	}
+}
+
+// blockedByUser reports whether a send failed because the user blocked the bot:
+// Telegram answered 403, or the chat is already known to refuse messages
+func blockedByUser(err error) bool {
+	var tgErr *gotgbot.TelegramError
+	return (errors.As(err, &tgErr) && tgErr.Code == 403) || errors.Is(err, ErrChatUnwritable)
+}
+
+func (s *BroadcastService) finish(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.running[id]; ok {
+		cancel()
+		delete(s.running, id)
+	}
+}
+
+// Cancel stops a running broadcast after the message being sent, reporting
+// whether it was still running
+func (s *BroadcastService) Cancel(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cancel, ok := s.running[id]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// Stop cancels the running broadcasts and waits for their final reports
+func (s *BroadcastService) Stop() {
+	s.mu.Lock()
+	for _, cancel := range s.running {
+		cancel()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/pkg/metrics"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+// broadcastSender answers like Telegram: 403 for users who blocked the bot
+type broadcastSender struct {
+	mu      sync.Mutex
+	sent    []int64
+	blocked map[int64]bool
+	failing map[int64]bool
+}
+
+func (f *broadcastSender) SendMessageWithContext(ctx context.Context, chatId int64, text string, opts *gotgbot.SendMessageOpts) (*gotgbot.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case f.blocked[chatId]:
+		return nil, &gotgbot.TelegramError{Code: 403, Description: "Forbidden: bot was blocked by the user"}
+	case f.failing[chatId]:
+		return nil, errors.New("connection reset")
+	}
+	f.sent = append(f.sent, chatId)
+	return &gotgbot.Message{}, nil
+}
+
+func TestBroadcastService(t *testing.T) {
+	newService := func(t *testing.T, sender *broadcastSender) (*BroadcastService, *helpers.MockDB, *helpers.MockRedis) {
+		mockDB := helpers.NewMockDB(t)
+		t.Cleanup(func() { _ = mockDB.Close() })
+		mockRedis := helpers.NewMockRedis()
+		logger := zerolog.Nop()
+		userService := NewUserService(mockDB.DB, mockRedis.Client, metrics.New(), &logger, time.Now())
+
+		messaging, _ := newTestMessagingService(t, nil)
+		messaging.sender = sender
+
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE is_active = \$1`).
+			WithArgs(true).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id", "is_active"}).
+				AddRow(1, true).AddRow(2, true).AddRow(3, true).AddRow(4, true))
+		return NewBroadcastService(userService, messaging, &logger), mockDB, mockRedis
+	}
+
+	t.Run("sends to everyone but the sender and deactivates who blocked the bot", func(t *testing.T) {
+		sender := &broadcastSender{blocked: map[int64]bool{3: true}, failing: map[int64]bool{4: true}}
+		service, mockDB, mockRedis := newService(t, sender)
+		mockRedis.Mock.ExpectDel("user:3").SetVal(1)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "users" SET "is_active"=\$1,"updated_at"=\$2 WHERE id = \$3`).
+			WithArgs(false, helpers.AnyTime{}, int64(3)).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+
+		var (
+			mu      sync.Mutex
+			reports []BroadcastProgress
+		)
+		finished := make(chan struct{})
+		id, err := service.Start(context.Background(), 1, "Maintenance tonight", func(progress BroadcastProgress) {
+			mu.Lock()
+			reports = append(reports, progress)
+			mu.Unlock()
+			if progress.Done {
+				close(finished)
+			}
+		})
+		require.NoError(t, err)
+		mu.Lock()
+		require.NotEmpty(t, reports, "first report before Start returns")
+		mu.Unlock()
+		<-finished
+		service.Stop()
+
+		assert.Equal(t, BroadcastProgress{ID: id, Total: 3}, reports[0])
+		assert.Equal(t, BroadcastProgress{ID: id, Total: 3, Sent: 1, Failed: 1, Deactivated: 1, Done: true}, reports[len(reports)-1])
+		assert.Equal(t, []int64{2}, sender.sent)
+		mockDB.ExpectationsWereMet(t)
+		mockRedis.ExpectationsWereMet(t)
+		assert.False(t, service.Cancel(id), "finished broadcasts are forgotten")
+	})
+
+	t.Run("cancel stops before the next recipient", func(t *testing.T) {
+		sender := &broadcastSender{}
+		service, _, _ := newService(t, sender)
+
+		var last BroadcastProgress
+		_, err := service.Start(context.Background(), 1, "Oops", func(progress BroadcastProgress) {
+			if !progress.Done {
+				assert.True(t, service.Cancel(progress.ID))
+			}
+			last = progress
+		})
+		require.NoError(t, err)
+		service.Stop()
+
+		assert.True(t, last.Done)
+		assert.True(t, last.Cancelled)
+		assert.Zero(t, last.Attempted())
+		assert.Empty(t, sender.sent)
+	})
+
+	t.Run("dry run counts recipients and sends once from the draft", func(t *testing.T) {
+		sender := &broadcastSender{}
+		service, mockDB, _ := newService(t, sender)
+		service.SetWorkers(2)
+
+		draft, err := service.DryRun(context.Background(), 1, "New feature")
+		require.NoError(t, err)
+		assert.Equal(t, 3, draft.Recipients)
+		assert.Empty(t, sender.sent, "a dry run sends nothing")
+
+		_, err = service.StartDraft(context.Background(), draft.ID, 2, func(BroadcastProgress) {})
+		assert.ErrorIs(t, err, ErrBroadcastDraftExpired, "only the admin who made the draft sends it")
+
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE is_active = \$1`).
+			WithArgs(true).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id", "is_active"}).
+				AddRow(1, true).AddRow(2, true).AddRow(3, true).AddRow(4, true))
+		var last BroadcastProgress
+		finished := make(chan struct{})
+		_, err = service.StartDraft(context.Background(), draft.ID, 1, func(progress BroadcastProgress) {
+			last = progress
+			if progress.Done {
+				close(finished)
+			}
+		})
+		require.NoError(t, err)
+		<-finished
+		service.Stop()
+
+		assert.Equal(t, 3, last.Sent)
+		assert.True(t, last.Done)
+		assert.ElementsMatch(t, []int64{2, 3, 4}, sender.sent)
+
+		_, err = service.StartDraft(context.Background(), draft.ID, 1, func(BroadcastProgress) {})
+		assert.ErrorIs(t, err, ErrBroadcastDraftExpired, "a draft is sent once")
+	})
+
+	t.Run("drafts expire", func(t *testing.T) {
+		service, _, _ := newService(t, &broadcastSender{})
+		now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+		service.now = func() time.Time { return now }
+
+		draft, err := service.DryRun(context.Background(), 1, "Hello")
+		require.NoError(t, err)
+		now = now.Add(broadcastDraftTTL)
+
+		_, err = service.StartDraft(context.Background(), draft.ID, 1, func(BroadcastProgress) {
+			t.Error("expired drafts are not sent")
+		})
+		assert.ErrorIs(t, err, ErrBroadcastDraftExpired)
+	})
+
+	t.Run("user list failure", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		logger := zerolog.Nop()
+		userService := NewUserService(mockDB.DB, helpers.NewMockRedis().Client, metrics.New(), &logger, time.Now())
+		messaging, _ := newTestMessagingService(t, nil)
+		service := NewBroadcastService(userService, messaging, &logger)
+		mockDB.Mock.ExpectQuery(`SELECT`).WillReturnError(errors.New("connection refused"))
+
+		_, err := service.Start(context.Background(), 1, "Hello", func(BroadcastProgress) {
+			t.Error("nothing to report")
+		})
+		assert.Error(t, err)
+	})
+}
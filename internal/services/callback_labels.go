@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrLabelExpired is returned for a label token that is unknown or has expired
+var ErrLabelExpired = errors.New("label token expired")
+
+const (
+	callbackLabelKeyPrefix = "callback_label:"
+
+	// callbackLabelTTL is how long a button carrying a stored label keeps working
+	callbackLabelTTL = 24 * time.Hour
+)
+
+// CallbackLabelService keeps free-text labels, such as the name a user typed
+// for a place, on the server for buttons that need them. Telegram limits
+// callback data to 64 bytes, which fits only a few non-Latin letters, so the
+// button carries a short token instead of the label itself.
+type CallbackLabelService struct {
+	redis    *redis.Client
+	newToken func() (string, error)
+}
+
+func NewCallbackLabelService(redis *redis.Client) *CallbackLabelService {
+	return &CallbackLabelService{
+		redis:    redis,
+		newToken: newCallbackLabelToken,
+	}
+}
+
+// Store saves the label and returns the token to put in callback data
+func (s *CallbackLabelService) Store(ctx context.Context, label string) (string, error) {
+	token, err := s.newToken()
+	if err != nil {
+		return "", err
+	}
+	if err := s.redis.Set(ctx, callbackLabelKeyPrefix+token, label, callbackLabelTTL).Err(); err != nil {
+		return "", fmt.Errorf("failed to store callback label: %w", err)
+	}
+	return token, nil
+}
+
+// Token returns the token of a place name or other label that recurs across
+// buttons. It is derived from the label, so every button for the same place
+// shares one stored copy, and each call keeps it for another callbackLabelTTL.
+func (s *CallbackLabelService) Token(ctx context.Context, label string) (string, error) {
+	sum := sha256.Sum256([]byte(label))
+	token := hex.EncodeToString(sum[:6])
+	if err := s.redis.Set(ctx, callbackLabelKeyPrefix+token, label, callbackLabelTTL).Err(); err != nil {
+		return "", fmt.Errorf("failed to store callback label: %w", err)
+	}
+	return token, nil
+}
+
+// Resolve returns the label stored under the token, or ErrLabelExpired
+func (s *CallbackLabelService) Resolve(ctx context.Context, token string) (string, error) {
+	label, err := s.redis.Get(ctx, callbackLabelKeyPrefix+token).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrLabelExpired
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load callback label: %w", err)
+	}
+	return label, nil
+}
+
+// newCallbackLabelToken returns 12 hex characters; the token travels in
+// callback data, so it must not contain underscores
+func newCallbackLabelToken() (string, error) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate label token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestCallbackLabelService(t *testing.T) {
+	mockRedis := helpers.NewMockRedis()
+	service := NewCallbackLabelService(mockRedis.Client)
+	service.newToken = func() (string, error) { return "0a1b2c3d4e5f", nil }
+
+	// A Cyrillic label far longer than callback data could carry
+	label := "Велика Димерка, Броварський район, Київська область"
+
+	mockRedis.Mock.ExpectSet("callback_label:0a1b2c3d4e5f", label, callbackLabelTTL).SetVal("OK")
+	token, err := service.Store(context.Background(), label)
+	require.NoError(t, err)
+	assert.Equal(t, "0a1b2c3d4e5f", token)
+
+	mockRedis.Mock.ExpectGet("callback_label:0a1b2c3d4e5f").SetVal(label)
+	resolved, err := service.Resolve(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, label, resolved)
+
+	mockRedis.Mock.ExpectGet("callback_label:ffffffffffff").RedisNil()
+	_, err = service.Resolve(context.Background(), "ffffffffffff")
+	assert.ErrorIs(t, err, ErrLabelExpired)
+
+	mockRedis.ExpectationsWereMet(t)
+}
+
+func TestCallbackLabelService_Token(t *testing.T) {
+	mockRedis := helpers.NewMockRedis()
+	service := NewCallbackLabelService(mockRedis.Client)
+
+	// The same place always gets the same token, so its copy is refreshed
+	// rather than stored again for every button
+	mockRedis.Mock.ExpectSet("callback_label:69afc6f335f5", "Las Vegas_NV", callbackLabelTTL).SetVal("OK")
+	mockRedis.Mock.ExpectSet("callback_label:69afc6f335f5", "Las Vegas_NV", callbackLabelTTL).SetVal("OK")
+	first, err := service.Token(context.Background(), "Las Vegas_NV")
+	require.NoError(t, err)
+	second, err := service.Token(context.Background(), "Las Vegas_NV")
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Len(t, first, 12)
+	assert.NotContains(t, first, "_")
+
+	mockRedis.ExpectationsWereMet(t)
+}
+
+func TestNewCallbackLabelToken(t *testing.T) {
+	token, err := newCallbackLabelToken()
+	require.NoError(t, err)
+	assert.Len(t, token, 12)
+	assert.NotContains(t, token, "_")
+}
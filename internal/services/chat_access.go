@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+)
+
+// ErrChatUnwritable is returned instead of sending to a chat where the bot
+// recently lost the right to post
+var ErrChatUnwritable = errors.New("bot cannot post in this chat")
+
+const (
+	chatAccessKeyPrefix = "chat_access:"
+	// chatProbeKeyPrefix holds the time of the last probe of a chat
+	chatProbeKeyPrefix = "chat_access_probe:"
+
+	// defaultChatAccessBackoff is how long sends to an unwritable chat are
+	// suppressed before the next send is allowed through as a retry
+	defaultChatAccessBackoff = 6 * time.Hour
+
+	// chatAccessRecordTTL drops the record of chats nobody has used for a while
+	chatAccessRecordTTL = 30 * 24 * time.Hour
+)
+
+// Redis hash fields of a chat access record
+const (
+	chatFieldUnwritableAt = "unwritable_at"
+	chatFieldReason       = "reason"
+	chatFieldTitle        = "title"
+	chatFieldLastUser     = "last_user"
+)
+
+// ChatAccessState is what the registry knows about one chat
+type ChatAccessState struct {
+	UnwritableAt time.Time // Zero while the bot can post
+	Reason       string    // Telegram's description of the failed send
+	Title        string    // Group title, for telling users which chat is affected
+	LastUserID   int64     // Last user seen in the chat, notified in DM when the bot is muted
+}
+
+// Unwritable reports whether the chat is marked as one the bot cannot post in
+func (s ChatAccessState) Unwritable() bool {
+	return !s.UnwritableAt.IsZero()
+}
+
+type chatProbeKey struct{}
+
+// withChatProbe marks a request as a probe that may reach an unwritable chat
+func withChatProbe(ctx context.Context) context.Context {
+	return context.WithValue(ctx, chatProbeKey{}, true)
+}
+
+func isChatProbe(ctx context.Context) bool {
+	probe, _ := ctx.Value(chatProbeKey{}).(bool)
+	return probe
+}
+
+// ChatAccessService remembers chats where Telegram refused the bot's messages
+// (kicked, muted, restricted) so that handlers and scheduled jobs stop sending
+// there until the chat is usable again
+type ChatAccessService struct {
+	redis        *redis.Client
+	user         *UserService
+	localization *LocalizationService
+	logger       *zerolog.Logger
+	backoff      time.Duration
+	now          func() time.Time
+}
+
+func NewChatAccessService(redis *redis.Client, userService *UserService, localization *LocalizationService, logger *zerolog.Logger) *ChatAccessService {
+	return &ChatAccessService{
+		redis:        redis,
+		user:         userService,
+		localization: localization,
+		logger:       logger,
+		backoff:      defaultChatAccessBackoff,
+		now:          time.Now,
+	}
+}
+
+func chatAccessKey(chatID int64) string {
+	return chatAccessKeyPrefix + strconv.FormatInt(chatID, 10)
+}
+
+// State loads the registry record of a chat. A missing record means writable.
+func (s *ChatAccessService) State(ctx context.Context, chatID int64) (ChatAccessState, error) {
+	fields, err := s.redis.HGetAll(ctx, chatAccessKey(chatID)).Result()
+	if err != nil {
+		return ChatAccessState{}, err
+	}
+
+	state := ChatAccessState{
+		Reason: fields[chatFieldReason],
+		Title:  fields[chatFieldTitle],
+	}
+	if unix, err := strconv.ParseInt(fields[chatFieldUnwritableAt], 10, 64); err == nil {
+		state.UnwritableAt = time.Unix(unix, 0).UTC()
+	}
+	if userID, err := strconv.ParseInt(fields[chatFieldLastUser], 10, 64); err == nil {
+		state.LastUserID = userID
+	}
+	return state, nil
+}
+
+// Suppressed reports whether sends to the chat are currently held back. Once
+// the backoff has passed the next send goes through and either clears the
+// mark or renews it. Registry failures never block a send.
+func (s *ChatAccessService) Suppressed(ctx context.Context, chatID int64) bool {
+	state, err := s.State(ctx, chatID)
+	if err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("Failed to load chat access state")
+		return false
+	}
+	return s.suppressed(state)
+}
+
+func (s *ChatAccessService) suppressed(state ChatAccessState) bool {
+	return state.Unwritable() && s.now().Before(state.UnwritableAt.Add(s.backoff))
+}
+
+// Observe records the title of a group and the user who last interacted in
+// it, so a later permission failure can be reported to that user
+func (s *ChatAccessService) Observe(ctx context.Context, chatID int64, title string, userID int64) {
+	key := chatAccessKey(chatID)
+	fields := []any{chatFieldLastUser, userID}
+	if title != "" {
+		fields = append(fields, chatFieldTitle, title)
+	}
+
+	pipe := s.redis.TxPipeline()
+	pipe.HSet(ctx, key, fields...)
+	pipe.Expire(ctx, key, chatAccessRecordTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("Failed to record chat activity")
+	}
+}
+
+// MarkUnwritable stores that the bot cannot post in the chat. It returns the
+// previous state so callers can tell a new failure from a renewed one.
+func (s *ChatAccessService) MarkUnwritable(ctx context.Context, chatID int64, reason string) (ChatAccessState, error) {
+	previous, err := s.State(ctx, chatID)
+	if err != nil {
+		return ChatAccessState{}, err
+	}
+
+	key := chatAccessKey(chatID)
+	pipe := s.redis.TxPipeline()
+	pipe.HSet(ctx, key, chatFieldUnwritableAt, s.now().Unix(), chatFieldReason, reason)
+	pipe.Expire(ctx, key, chatAccessRecordTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return ChatAccessState{}, err
+	}
+
+	return previous, nil
+}
+
+// Clear marks the chat as writable again
+func (s *ChatAccessService) Clear(ctx context.Context, chatID int64) error {
+	return s.redis.HDel(ctx, chatAccessKey(chatID), chatFieldUnwritableAt, chatFieldReason).Err()
+}
+
+// Probe checks whether the bot can post in a chat marked as unwritable, for
+// example after an update from that chat shows the bot can see it again.
+// The probe is a typing indicator, so users see nothing when it succeeds. A
+// chat is probed at most once per backoff period, however many updates arrive.
+func (s *ChatAccessService) Probe(ctx context.Context, bot *gotgbot.Bot, chatID int64) bool {
+	// Claim the probe slot first so a busy group cannot trigger a probe per message
+	claimed, err := s.redis.SetNX(ctx, chatProbeKeyPrefix+strconv.FormatInt(chatID, 10), s.now().Unix(), s.backoff).Result()
+	if err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("Failed to claim chat probe")
+		return false
+	}
+	if !claimed {
+		return false
+	}
+
+	if _, err := bot.SendChatActionWithContext(withChatProbe(ctx), chatID, "typing", nil); err != nil {
+		return false
+	}
+
+	if err := s.Clear(ctx, chatID); err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("Failed to clear chat access state")
+		return false
+	}
+	s.logger.Info().Int64("chat_id", chatID).Msg("Bot can post in chat again")
+	return true
+}
+
+// chatWriteForbidden reports whether err is Telegram refusing to deliver to the
+// chat because of the bot's membership or rights, and returns the description
+func chatWriteForbidden(err error) (string, bool) {
+	var tgErr *gotgbot.TelegramError
+	if !errors.As(err, &tgErr) {
+		return "", false
+	}
+
+	// 403: kicked, not a member, or blocked by the user
+	if tgErr.Code == 403 {
+		return tgErr.Description, true
+	}
+
+	description := strings.ToLower(tgErr.Description)
+	for _, marker := range []string{"not enough rights", "chat_write_forbidden", "have no rights to send"} {
+		if strings.Contains(description, marker) {
+			return tgErr.Description, true
+		}
+	}
+	return "", false
+}
+
+// WrapClient returns a bot client that refuses sends to chats marked as
+// unwritable and marks chats when Telegram rejects a send for lack of rights
+func (s *ChatAccessService) WrapClient(client gotgbot.BotClient) gotgbot.BotClient {
+	return &chatAccessClient{BotClient: client, access: s}
+}
+
+type chatAccessClient struct {
+	gotgbot.BotClient
+	access *ChatAccessService
+}
+
+func (c *chatAccessClient) RequestWithContext(ctx context.Context, token string, method string, params map[string]any, opts *gotgbot.RequestOpts) (json.RawMessage, error) {
+	chatID, ok := params["chat_id"].(int64)
+	if !ok || !isMessageMethod(method) {
+		return c.BotClient.RequestWithContext(ctx, token, method, params, opts)
+	}
+
+	state, err := c.access.State(ctx, chatID)
+	if err != nil {
+		c.access.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("Failed to load chat access state")
+	}
+	if !isChatProbe(ctx) && c.access.suppressed(state) {
+		return nil, fmt.Errorf("%w: chat %d", ErrChatUnwritable, chatID)
+	}
+
+	result, err := c.BotClient.RequestWithContext(ctx, token, method, params, opts)
+	if reason, forbidden := chatWriteForbidden(err); forbidden {
+		c.markUnwritable(ctx, token, chatID, reason)
+		return result, err
+	}
+
+	// A regular send that succeeds after the backoff proves the chat works again;
+	// probes clear the mark themselves
+	if err == nil && state.Unwritable() && !isChatProbe(ctx) {
+		if clearErr := c.access.Clear(ctx, chatID); clearErr != nil {
+			c.access.logger.Warn().Err(clearErr).Int64("chat_id", chatID).Msg("Failed to clear chat access state")
+		} else {
+			c.access.logger.Info().Int64("chat_id", chatID).Msg("Bot can post in chat again")
+		}
+	}
+
+	return result, err
+}
+
+// markUnwritable records the failure and, the first time it happens in a
+// group, tells the last user who interacted there in a direct message
+func (c *chatAccessClient) markUnwritable(ctx context.Context, token string, chatID int64, reason string) {
+	previous, err := c.access.MarkUnwritable(ctx, chatID, reason)
+	if err != nil {
+		c.access.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("Failed to mark chat as unwritable")
+		return
+	}
+
+	c.access.logger.Warn().
+		Int64("chat_id", chatID).
+		Str("reason", reason).
+		Msg("Bot cannot post in chat, suppressing sends")
+
+	// Private chats have positive IDs and there is nobody else to tell
+	if previous.Unwritable() || chatID > 0 || previous.LastUserID == 0 {
+		return
+	}
+
+	notice := c.access.unwritableNotice(ctx, previous)
+	params := map[string]any{"chat_id": previous.LastUserID, "text": notice}
+	if _, err := c.RequestWithContext(ctx, token, "sendMessage", params, nil); err != nil {
+		c.access.logger.Debug().Err(err).Int64("user_id", previous.LastUserID).Msg("Could not tell user about unwritable chat")
+	}
+}
+
+func (s *ChatAccessService) unwritableNotice(ctx context.Context, state ChatAccessState) string {
+	language := "en-US"
+	if s.user != nil {
+		if user, err := s.user.GetUser(ctx, state.LastUserID); err == nil && user.Language != "" {
+			language = user.Language
+		}
+	}
+
+	title := state.Title
+	if title == "" {
+		title = s.localization.T(ctx, language, "chat_unwritable_unknown_group")
+	}
+	return s.localization.T(ctx, language, "chat_unwritable_notice", title)
+}
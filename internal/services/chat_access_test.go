@@ -0,0 +1,357 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/metrics"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+// restrictedBotAPI answers sends to the chats in forbidden with the given
+// Telegram error and records every request that reached it
+type restrictedBotAPI struct {
+	mu        sync.Mutex
+	forbidden map[int64]*gotgbot.TelegramError
+	requests  []sentRequest
+	params    []map[string]any // Raw parameters of each request
+}
+
+type sentRequest struct {
+	method string
+	chatID int64
+	text   string
+}
+
+func (f *restrictedBotAPI) RequestWithContext(ctx context.Context, token string, method string, params map[string]any, opts *gotgbot.RequestOpts) (json.RawMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	chatID, _ := params["chat_id"].(int64)
+	text, _ := params["text"].(string)
+	f.requests = append(f.requests, sentRequest{method: method, chatID: chatID, text: text})
+	f.params = append(f.params, params)
+
+	if tgErr, ok := f.forbidden[chatID]; ok {
+		return nil, tgErr
+	}
+	if method == "sendChatAction" {
+		return json.RawMessage(`true`), nil
+	}
+	return json.RawMessage(`{"message_id": 1, "date": 0, "chat": {"id": ` + strconv.FormatInt(chatID, 10) + `, "type": "group"}}`), nil
+}
+
+func (f *restrictedBotAPI) GetAPIURL(opts *gotgbot.RequestOpts) string {
+	return gotgbot.DefaultAPIURL
+}
+
+func (f *restrictedBotAPI) FileURL(token string, tgFilePath string, opts *gotgbot.RequestOpts) string {
+	return ""
+}
+
+var errMuted = &gotgbot.TelegramError{
+	Method:      "sendMessage",
+	Code:        400,
+	Description: "Bad Request: not enough rights to send text messages to the chat",
+}
+
+func newTestChatAccess(t *testing.T) (*ChatAccessService, *helpers.MockRedis) {
+	logger := helpers.NewSilentTestLogger()
+	localization := NewLocalizationService(logger)
+	err := localization.LoadTranslations(fstest.MapFS{
+		"languages.json": &fstest.MapFile{
+			Data: []byte(`{"en-US": {"code": "en-US", "name": "English", "flag": "🇺🇸"}}`),
+		},
+		"en-US.json": &fstest.MapFile{
+			Data: []byte(`{"chat_unwritable_notice": "I can't post in %s", "chat_unwritable_unknown_group": "your group"}`),
+		},
+	})
+	require.NoError(t, err)
+
+	mockRedis := helpers.NewMockRedis()
+	service := NewChatAccessService(mockRedis.Client, nil, localization, logger)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+	return service, mockRedis
+}
+
+func unixString(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+func TestChatWriteForbidden(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"kicked", &gotgbot.TelegramError{Code: 403, Description: "Forbidden: bot was kicked from the group chat"}, true},
+		{"not a member", &gotgbot.TelegramError{Code: 403, Description: "Forbidden: bot is not a member of the channel chat"}, true},
+		{"muted", errMuted, true},
+		{"write forbidden", &gotgbot.TelegramError{Code: 400, Description: "Bad Request: CHAT_WRITE_FORBIDDEN"}, true},
+		{"rate limited", &gotgbot.TelegramError{Code: 429, Description: "Too Many Requests: retry after 5"}, false},
+		{"other bad request", &gotgbot.TelegramError{Code: 400, Description: "Bad Request: message text is empty"}, false},
+		{"network error", errors.New("connection reset"), false},
+		{"no error", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, forbidden := chatWriteForbidden(tt.err)
+			assert.Equal(t, tt.want, forbidden)
+		})
+	}
+}
+
+func TestChatAccessClient_MarksAndSuppresses(t *testing.T) {
+	service, mockRedis := newTestChatAccess(t)
+	api := &restrictedBotAPI{forbidden: map[int64]*gotgbot.TelegramError{-100: errMuted}}
+	bot := &gotgbot.Bot{Token: "test", BotClient: service.WrapClient(api)}
+	now := service.now()
+
+	// First send is refused by Telegram: the chat is marked and the last
+	// user seen there is told in a direct message
+	mockRedis.Mock.ExpectHGetAll("chat_access:-100").SetVal(map[string]string{"last_user": "42", "title": "Hikers"})
+	mockRedis.Mock.ExpectHGetAll("chat_access:-100").SetVal(map[string]string{"last_user": "42", "title": "Hikers"})
+	mockRedis.Mock.ExpectTxPipeline()
+	mockRedis.Mock.ExpectHSet("chat_access:-100", "unwritable_at", now.Unix(), "reason", errMuted.Description).SetVal(2)
+	mockRedis.Mock.ExpectExpire("chat_access:-100", chatAccessRecordTTL).SetVal(true)
+	mockRedis.Mock.ExpectTxPipelineExec()
+	mockRedis.Mock.ExpectHGetAll("chat_access:42").SetVal(map[string]string{})
+
+	_, err := bot.SendMessage(-100, "Daily digest", nil)
+
+	var tgErr *gotgbot.TelegramError
+	require.ErrorAs(t, err, &tgErr)
+	require.Len(t, api.requests, 2)
+	assert.Equal(t, sentRequest{method: "sendMessage", chatID: 42, text: "I can't post in Hikers"}, api.requests[1])
+
+	// Further sends during the backoff never reach Telegram
+	mockRedis.Mock.ExpectHGetAll("chat_access:-100").SetVal(map[string]string{"unwritable_at": unixString(now), "last_user": "42"})
+
+	_, err = bot.SendMessage(-100, "Alert", nil)
+
+	assert.ErrorIs(t, err, ErrChatUnwritable)
+	assert.Len(t, api.requests, 2)
+	assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+}
+
+func TestChatAccessClient_RenewedFailureDoesNotNotifyAgain(t *testing.T) {
+	service, mockRedis := newTestChatAccess(t)
+	api := &restrictedBotAPI{forbidden: map[int64]*gotgbot.TelegramError{-100: errMuted}}
+	bot := &gotgbot.Bot{Token: "test", BotClient: service.WrapClient(api)}
+	now := service.now()
+	expired := map[string]string{"unwritable_at": unixString(now.Add(-7 * time.Hour)), "last_user": "42"}
+
+	mockRedis.Mock.ExpectHGetAll("chat_access:-100").SetVal(expired)
+	mockRedis.Mock.ExpectHGetAll("chat_access:-100").SetVal(expired)
+	mockRedis.Mock.ExpectTxPipeline()
+	mockRedis.Mock.ExpectHSet("chat_access:-100", "unwritable_at", now.Unix(), "reason", errMuted.Description).SetVal(0)
+	mockRedis.Mock.ExpectExpire("chat_access:-100", chatAccessRecordTTL).SetVal(true)
+	mockRedis.Mock.ExpectTxPipelineExec()
+
+	_, err := bot.SendMessage(-100, "Daily digest", nil)
+
+	assert.Error(t, err)
+	assert.Len(t, api.requests, 1)
+	assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+}
+
+func TestChatAccessClient_ClearsAfterSuccessfulRetry(t *testing.T) {
+	service, mockRedis := newTestChatAccess(t)
+	api := &restrictedBotAPI{}
+	bot := &gotgbot.Bot{Token: "test", BotClient: service.WrapClient(api)}
+
+	mockRedis.Mock.ExpectHGetAll("chat_access:-100").SetVal(map[string]string{
+		"unwritable_at": unixString(service.now().Add(-7 * time.Hour)),
+	})
+	mockRedis.Mock.ExpectHDel("chat_access:-100", "unwritable_at", "reason").SetVal(2)
+
+	_, err := bot.SendMessage(-100, "Daily digest", nil)
+
+	require.NoError(t, err)
+	assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+}
+
+func TestChatAccessService_Probe(t *testing.T) {
+	t.Run("clears the mark when the bot can post again", func(t *testing.T) {
+		service, mockRedis := newTestChatAccess(t)
+		api := &restrictedBotAPI{}
+		bot := &gotgbot.Bot{Token: "test", BotClient: service.WrapClient(api)}
+		marked := map[string]string{"unwritable_at": unixString(service.now())}
+
+		mockRedis.Mock.ExpectSetNX("chat_access_probe:-100", service.now().Unix(), service.backoff).SetVal(true)
+		mockRedis.Mock.ExpectHGetAll("chat_access:-100").SetVal(marked)
+		mockRedis.Mock.ExpectHDel("chat_access:-100", "unwritable_at", "reason").SetVal(2)
+
+		assert.True(t, service.Probe(context.Background(), bot, -100))
+		require.Len(t, api.requests, 1)
+		assert.Equal(t, "sendChatAction", api.requests[0].method)
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("keeps the mark while the bot is still muted", func(t *testing.T) {
+		service, mockRedis := newTestChatAccess(t)
+		api := &restrictedBotAPI{forbidden: map[int64]*gotgbot.TelegramError{-100: errMuted}}
+		bot := &gotgbot.Bot{Token: "test", BotClient: service.WrapClient(api)}
+		marked := map[string]string{"unwritable_at": unixString(service.now().Add(-time.Minute)), "last_user": "42"}
+
+		mockRedis.Mock.ExpectSetNX("chat_access_probe:-100", service.now().Unix(), service.backoff).SetVal(true)
+		mockRedis.Mock.ExpectHGetAll("chat_access:-100").SetVal(marked)
+		mockRedis.Mock.ExpectHGetAll("chat_access:-100").SetVal(marked)
+		mockRedis.Mock.ExpectTxPipeline()
+		mockRedis.Mock.ExpectHSet("chat_access:-100", "unwritable_at", service.now().Unix(), "reason", errMuted.Description).SetVal(0)
+		mockRedis.Mock.ExpectExpire("chat_access:-100", chatAccessRecordTTL).SetVal(true)
+		mockRedis.Mock.ExpectTxPipelineExec()
+
+		assert.False(t, service.Probe(context.Background(), bot, -100))
+		assert.Len(t, api.requests, 1)
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("probes once per backoff period", func(t *testing.T) {
+		service, mockRedis := newTestChatAccess(t)
+		api := &restrictedBotAPI{}
+		bot := &gotgbot.Bot{Token: "test", BotClient: service.WrapClient(api)}
+
+		// Another update already probed the chat within the backoff period
+		mockRedis.Mock.ExpectSetNX("chat_access_probe:-100", service.now().Unix(), service.backoff).SetVal(false)
+
+		assert.False(t, service.Probe(context.Background(), bot, -100))
+		assert.Empty(t, api.requests)
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+}
+
+func TestSchedulerService_PausesDeliveryToUnwritableChat(t *testing.T) {
+	chatAccess, mockRedis := newTestChatAccess(t)
+	scheduler := NewSchedulerService(nil, nil, nil, nil, nil, helpers.NewSilentTestLogger())
+	scheduler.SetDeliveryWindow(0)
+	scheduler.SetChatAccess(chatAccess)
+
+	mockRedis.Mock.ExpectHGetAll("chat_access:123").SetVal(map[string]string{
+		"unwritable_at": unixString(chatAccess.now()),
+	})
+
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	scheduler.SetOutbox(NewOutboxService(mockDB.DB, metrics.New(), helpers.NewSilentTestLogger(), 0))
+	subscriptionID := uuid.New()
+
+	// The paused notification is kept for the retries instead of being dropped
+	mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "outbox_items" WHERE subscription_id = \$1 AND status = \$2`).
+		WithArgs(subscriptionID, models.OutboxPending).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(0))
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectQuery(`INSERT INTO "outbox_items"`).
+		WithArgs(subscriptionID, int64(123), models.OutboxPending, 0, DeliveryErrorPaused, ErrChatUnwritable.Error(), nil, helpers.AnyTime{}, helpers.AnyTime{}).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mockDB.Mock.ExpectCommit()
+
+	// The weather service is nil, so reaching the send would panic
+	scheduler.deliverSpread(context.Background(), []models.Subscription{
+		{ID: subscriptionID, UserID: 123, SubscriptionType: models.SubscriptionDaily, TimeOfDay: "08:00"},
+	})
+
+	assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestOutboxService_Defer(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	service := NewOutboxService(mockDB.DB, metrics.New(), helpers.NewSilentTestLogger(), 0)
+	subscriptionID := uuid.New()
+
+	// A notification already waiting is not deferred a second time
+	mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "outbox_items"`).
+		WithArgs(subscriptionID, models.OutboxPending).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(1))
+
+	require.NoError(t, service.Defer(context.Background(), subscriptionID, 123, ErrChatUnwritable))
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestSchedulerService_KeepsUndeliveredOnStop(t *testing.T) {
+	chatAccess, mockRedis := newTestChatAccess(t)
+	scheduler := NewSchedulerService(nil, nil, nil, nil, nil, helpers.NewSilentTestLogger())
+	scheduler.SetDeliveryWindow(time.Hour)
+	scheduler.SetChatAccess(chatAccess)
+	close(scheduler.stopChan)
+
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	scheduler.SetOutbox(NewOutboxService(mockDB.DB, metrics.New(), helpers.NewSilentTestLogger(), 0))
+	paused, waiting := uuid.New(), uuid.New()
+
+	expectDefer := func(subscriptionID uuid.UUID, userID int64, cause error) {
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "outbox_items"`).
+			WithArgs(subscriptionID, models.OutboxPending).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(0))
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "outbox_items"`).
+			WithArgs(subscriptionID, userID, models.OutboxPending, 0, DeliveryErrorClass(cause), cause.Error(), nil, helpers.AnyTime{}, helpers.AnyTime{}).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mockDB.Mock.ExpectCommit()
+	}
+
+	// The first turn is due at once; its chat is paused
+	mockRedis.Mock.ExpectHGetAll("chat_access:123").SetVal(map[string]string{
+		"unwritable_at": unixString(chatAccess.now()),
+	})
+	expectDefer(paused, 123, ErrChatUnwritable)
+	// The scheduler stops before the second turn, which is kept for the retries
+	expectDefer(waiting, 456, errDeliveryInterrupted)
+
+	scheduler.deliverSpread(context.Background(), []models.Subscription{
+		{ID: paused, UserID: 123, SubscriptionType: models.SubscriptionDaily, TimeOfDay: "08:00"},
+		{ID: waiting, UserID: 456, SubscriptionType: models.SubscriptionDaily, TimeOfDay: "08:00"},
+	})
+
+	assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestSchedulerService_KeepsUndeliveredOnCancel(t *testing.T) {
+	scheduler := NewSchedulerService(nil, nil, nil, nil, nil, helpers.NewSilentTestLogger())
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	scheduler.SetOutbox(NewOutboxService(mockDB.DB, metrics.New(), helpers.NewSilentTestLogger(), 0))
+	subscriptionID := uuid.New()
+
+	mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "outbox_items"`).
+		WithArgs(subscriptionID, models.OutboxPending).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(0))
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectQuery(`INSERT INTO "outbox_items"`).
+		WithArgs(subscriptionID, int64(123), models.OutboxPending, 0, DeliveryErrorInterrupted, errDeliveryInterrupted.Error(), nil, helpers.AnyTime{}, helpers.AnyTime{}).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mockDB.Mock.ExpectCommit()
+
+	// The delivery was stopped by cancelling its context, which must not keep
+	// the undelivered notification from being stored
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	scheduler.deferUndelivered(ctx, []models.Subscription{
+		{ID: subscriptionID, UserID: 123, SubscriptionType: models.SubscriptionDaily, TimeOfDay: "08:00"},
+	}, "Notification delivery cancelled")
+
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestDeliveryErrorClass_NotAttempted(t *testing.T) {
+	assert.Equal(t, DeliveryErrorPaused, DeliveryErrorClass(ErrChatUnwritable))
+	assert.Equal(t, DeliveryErrorInterrupted, DeliveryErrorClass(errDeliveryInterrupted))
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/valpere/shopogoda/internal/models"
+)
+
+// ChatSettingsService keeps the location, language and units groups use in
+// place of those of the member who asks
+type ChatSettingsService struct {
+	db *gorm.DB
+}
+
+func NewChatSettingsService(db *gorm.DB) *ChatSettingsService {
+	return &ChatSettingsService{db: db}
+}
+
+// GetChatSettings returns the settings of a chat, or nil when it has none
+func (s *ChatSettingsService) GetChatSettings(ctx context.Context, chatID int64) (*models.ChatSettings, error) {
+	var settings models.ChatSettings
+	err := s.db.WithContext(ctx).Where("chat_id = ?", chatID).First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chat settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// SetChatLocation stores the location of a group or channel, replacing any
+// earlier one. A chat without a language or units yet takes those of the
+// admin who sets it, so the group keeps them whoever asks afterwards.
+func (s *ChatSettingsService) SetChatLocation(ctx context.Context, chatID int64, admin *models.User, name string, lat, lon float64) (*models.ChatSettings, error) {
+	settings, err := s.GetChatSettings(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		settings = &models.ChatSettings{ChatID: chatID}
+	}
+
+	settings.LocationName = name
+	settings.Latitude = lat
+	settings.Longitude = lon
+	settings.SetBy = admin.ID
+	if settings.Language == "" {
+		settings.Language = admin.Language
+	}
+	if settings.Units == "" {
+		settings.Units = admin.Units
+	}
+
+	if err := s.db.WithContext(ctx).Save(settings).Error; err != nil {
+		return nil, fmt.Errorf("failed to save chat settings: %w", err)
+	}
+	return settings, nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+var chatSettingsColumns = []string{"chat_id", "location_name", "latitude", "longitude", "language", "units", "set_by"}
+
+func TestChatSettingsService_GetChatSettings(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	service := NewChatSettingsService(mockDB.DB)
+	ctx := context.Background()
+
+	t.Run("chat with settings", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "chat_locations" WHERE chat_id = \$1`).
+			WithArgs(int64(-100123), 1).
+			WillReturnRows(mockDB.Mock.NewRows(chatSettingsColumns).
+				AddRow(int64(-100123), "Lviv", 49.84, 24.03, "uk-UA", "metric", int64(7)))
+
+		settings, err := service.GetChatSettings(ctx, -100123)
+		require.NoError(t, err)
+		require.NotNil(t, settings)
+		assert.Equal(t, "Lviv", settings.LocationName)
+		assert.Equal(t, "uk-UA", settings.Language)
+		assert.True(t, settings.HasLocation())
+	})
+
+	t.Run("chat without settings", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "chat_locations" WHERE chat_id = \$1`).
+			WithArgs(int64(-100456), 1).
+			WillReturnRows(mockDB.Mock.NewRows(chatSettingsColumns))
+
+		settings, err := service.GetChatSettings(ctx, -100456)
+		require.NoError(t, err)
+		assert.Nil(t, settings)
+	})
+
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestChatSettingsService_SetChatLocation(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	service := NewChatSettingsService(mockDB.DB)
+	ctx := context.Background()
+	admin := &models.User{ID: 7, Language: "de-DE", Units: "imperial"}
+
+	t.Run("new chat takes the admin's language and units", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "chat_locations" WHERE chat_id = \$1`).
+			WithArgs(int64(-100456), 1).
+			WillReturnRows(mockDB.Mock.NewRows(chatSettingsColumns))
+		// Save updates first and inserts when no row was updated
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "chat_locations" SET`).
+			WillReturnResult(helpers.NewResult(0, 0))
+		mockDB.Mock.ExpectCommit()
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`INSERT INTO "chat_locations"`).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+
+		settings, err := service.SetChatLocation(ctx, -100456, admin, "Berlin", 52.52, 13.40)
+		require.NoError(t, err)
+		assert.Equal(t, "Berlin", settings.LocationName)
+		assert.Equal(t, "de-DE", settings.Language)
+		assert.Equal(t, "imperial", settings.Units)
+		assert.Equal(t, int64(7), settings.SetBy)
+	})
+
+	t.Run("existing chat keeps its language and units", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "chat_locations" WHERE chat_id = \$1`).
+			WithArgs(int64(-100123), 1).
+			WillReturnRows(mockDB.Mock.NewRows(chatSettingsColumns).
+				AddRow(int64(-100123), "Lviv", 49.84, 24.03, "uk-UA", "metric", int64(3)))
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "chat_locations" SET`).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+
+		settings, err := service.SetChatLocation(ctx, -100123, admin, "Berlin", 52.52, 13.40)
+		require.NoError(t, err)
+		assert.Equal(t, "Berlin", settings.LocationName)
+		assert.Equal(t, "uk-UA", settings.Language)
+		assert.Equal(t, "metric", settings.Units)
+		assert.Equal(t, int64(7), settings.SetBy)
+	})
+
+	mockDB.ExpectationsWereMet(t)
+}
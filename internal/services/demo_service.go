@@ -224,6 +224,8 @@ func (s *DemoService) ClearDemoData(ctx context.Context) error {
 		&models.Subscription{},
 		&models.AlertConfig{},
 		&models.WeatherData{},
+		&models.WeatherHistory{},
+		&models.WeatherRecord{},
 	}
 
 	for _, table := range relatedTables {
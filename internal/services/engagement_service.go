@@ -0,0 +1,317 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+
+	"github.com/valpere/shopogoda/internal"
+	"github.com/valpere/shopogoda/internal/models"
+)
+
+const (
+	// activityWindow is how far back interactions count towards the activity histogram
+	activityWindow = 30 * 24 * time.Hour
+
+	// digestSuggestionMinInteractions is the minimum number of interactions in the
+	// window before a pattern is considered at all
+	digestSuggestionMinInteractions = 10
+
+	// digestSuggestionMinShare is the share of interactions that must fall within
+	// one hour of the peak for the pattern to count as clear
+	digestSuggestionMinShare = 0.5
+
+	// digestSuggestionMinDivergence is how many hours the peak must be away from
+	// the digest time (strictly more) before suggesting a move
+	digestSuggestionMinDivergence = 3
+
+	// digestSuggestionCooldown limits suggestions to one per subscription in this period
+	digestSuggestionCooldown = 60 * 24 * time.Hour
+)
+
+// EngagementService tracks when users interact with the bot and suggests moving
+// their daily digest to the time they are actually active
+type EngagementService struct {
+	redis        *redis.Client
+	user         *UserService
+	subscription *SubscriptionService
+	messaging    *MessagingService
+	localization *LocalizationService
+	logger       *zerolog.Logger
+	now          func() time.Time
+}
+
+// DigestTimeSuggestion describes a proposed move of a daily digest
+type DigestTimeSuggestion struct {
+	SubscriptionID uuid.UUID
+	UserID         int64
+	CurrentTime    string // HH:MM in user timezone
+	SuggestedTime  string // HH:MM in user timezone
+}
+
+func NewEngagementService(
+	redis *redis.Client,
+	userService *UserService,
+	subscriptionService *SubscriptionService,
+	messaging *MessagingService,
+	localization *LocalizationService,
+	logger *zerolog.Logger,
+) *EngagementService {
+	return &EngagementService{
+		redis:        redis,
+		user:         userService,
+		subscription: subscriptionService,
+		messaging:    messaging,
+		localization: localization,
+		logger:       logger,
+		now:          time.Now,
+	}
+}
+
+func activityKey(userID int64) string {
+	return fmt.Sprintf("activity:hours:%d", userID)
+}
+
+func digestSuggestionKey(subscriptionID uuid.UUID) string {
+	return fmt.Sprintf("digest_suggestion:%s", subscriptionID)
+}
+
+// RecordInteraction counts an interaction in the user's hourly activity buckets.
+// Nothing is recorded for users who opted out of activity tracking.
+func (s *EngagementService) RecordInteraction(ctx context.Context, user *models.User, at time.Time) error {
+	if user == nil || user.ActivityTrackingDisabled {
+		return nil
+	}
+
+	key := activityKey(user.ID)
+	bucket := at.UTC().Format("2006010215")
+
+	pipe := s.redis.TxPipeline()
+	pipe.HIncrBy(ctx, key, bucket, 1)
+	pipe.Expire(ctx, key, activityWindow+24*time.Hour)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record interaction: %w", err)
+	}
+
+	return nil
+}
+
+// SetActivityTracking updates the user's tracking preference, discarding any
+// recorded activity when tracking is turned off
+func (s *EngagementService) SetActivityTracking(ctx context.Context, userID int64, enabled bool) error {
+	if err := s.user.SetActivityTracking(ctx, userID, enabled); err != nil {
+		return err
+	}
+
+	if !enabled {
+		if err := s.redis.Del(ctx, activityKey(userID)).Err(); err != nil {
+			return fmt.Errorf("failed to clear recorded activity: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// InteractionHistogram returns the user's interactions over the last 30 days by
+// hour of day in the given location. Buckets older than the window are pruned.
+func (s *EngagementService) InteractionHistogram(ctx context.Context, userID int64, loc *time.Location) ([24]int, error) {
+	var histogram [24]int
+
+	key := activityKey(userID)
+	buckets, err := s.redis.HGetAll(ctx, key).Result()
+	if err != nil {
+		return histogram, fmt.Errorf("failed to get activity: %w", err)
+	}
+
+	cutoff := s.now().UTC().Add(-activityWindow)
+	var stale []string
+
+	for bucket, value := range buckets {
+		hour, err := time.ParseInLocation("2006010215", bucket, time.UTC)
+		if err != nil || hour.Before(cutoff) {
+			stale = append(stale, bucket)
+			continue
+		}
+
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			continue
+		}
+		histogram[hour.In(loc).Hour()] += count
+	}
+
+	if len(stale) > 0 {
+		if err := s.redis.HDel(ctx, key, stale...).Err(); err != nil {
+			s.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to prune stale activity buckets")
+		}
+	}
+
+	return histogram, nil
+}
+
+// PreferredHour finds the hour around which the user's activity clusters. It
+// reports false unless there is enough activity and at least half of it falls
+// within one hour of the peak.
+func PreferredHour(histogram [24]int) (int, bool) {
+	total := 0
+	for _, count := range histogram {
+		total += count
+	}
+	if total < digestSuggestionMinInteractions {
+		return 0, false
+	}
+
+	bestHour, bestWindow := 0, -1
+	for hour := range histogram {
+		window := histogram[(hour+23)%24] + histogram[hour] + histogram[(hour+1)%24]
+		if window > bestWindow || (window == bestWindow && histogram[hour] > histogram[bestHour]) {
+			bestHour, bestWindow = hour, window
+		}
+	}
+
+	if float64(bestWindow)/float64(total) < digestSuggestionMinShare {
+		return 0, false
+	}
+
+	return bestHour, true
+}
+
+// hourDistance is the distance between two hours of the day, wrapping at midnight
+func hourDistance(a, b int) int {
+	d := a - b
+	if d < 0 {
+		d = -d
+	}
+	if d > 12 {
+		d = 24 - d
+	}
+	return d
+}
+
+// SuggestDigestTime checks whether the user's activity diverges from the daily
+// digest time enough to suggest a move. It does not check the cool-down.
+func (s *EngagementService) SuggestDigestTime(ctx context.Context, subscription *models.Subscription) (*DigestTimeSuggestion, error) {
+	if subscription.SubscriptionType != models.SubscriptionDaily || subscription.User.ActivityTrackingDisabled {
+		return nil, nil
+	}
+
+	current, err := time.Parse("15:04", subscription.TimeOfDay)
+	if err != nil {
+		return nil, nil
+	}
+
+	loc, err := time.LoadLocation(subscription.User.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	histogram, err := s.InteractionHistogram(ctx, subscription.UserID, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	hour, ok := PreferredHour(histogram)
+	if !ok || hourDistance(hour, current.Hour()) <= digestSuggestionMinDivergence {
+		return nil, nil
+	}
+
+	return &DigestTimeSuggestion{
+		SubscriptionID: subscription.ID,
+		UserID:         subscription.UserID,
+		CurrentTime:    subscription.TimeOfDay,
+		SuggestedTime:  fmt.Sprintf("%02d:00", hour),
+	}, nil
+}
+
+// ProcessDigestTimeSuggestions sends a one-time suggestion to every daily digest
+// subscriber whose activity pattern diverges from their digest time. Each
+// subscription receives at most one suggestion per 60 days.
+func (s *EngagementService) ProcessDigestTimeSuggestions(ctx context.Context) (int, error) {
+	subscriptions, err := s.subscription.GetSubscriptionsByType(ctx, models.SubscriptionDaily)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get daily subscriptions: %w", err)
+	}
+
+	sent := 0
+	for i := range subscriptions {
+		subscription := &subscriptions[i]
+		if !subscription.User.IsActive {
+			continue
+		}
+
+		suggestion, err := s.SuggestDigestTime(ctx, subscription)
+		if err != nil {
+			s.logger.Warn().Err(err).Int64("user_id", subscription.UserID).Msg("Failed to check digest time")
+			continue
+		}
+		if suggestion == nil {
+			continue
+		}
+
+		// Claim the cool-down slot first so concurrent runs cannot double-send
+		key := digestSuggestionKey(subscription.ID)
+		claimed, err := s.redis.SetNX(ctx, key, suggestion.SuggestedTime, digestSuggestionCooldown).Result()
+		if err != nil {
+			s.logger.Warn().Err(err).Str("subscription_id", subscription.ID.String()).Msg("Failed to claim digest suggestion cool-down")
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := s.sendDigestTimeSuggestion(ctx, subscription, suggestion); err != nil {
+			// Release the slot so the suggestion can be retried next week
+			if delErr := s.redis.Del(ctx, key).Err(); delErr != nil {
+				s.logger.Warn().Err(delErr).Str("cache_key", key).Msg("Failed to release digest suggestion cool-down")
+			}
+			s.logger.Error().Err(err).Int64("user_id", subscription.UserID).Msg("Failed to send digest time suggestion")
+			continue
+		}
+		sent++
+	}
+
+	s.logger.Info().Int("sent", sent).Int("subscriptions", len(subscriptions)).Msg("Processed digest time suggestions")
+
+	return sent, nil
+}
+
+func (s *EngagementService) sendDigestTimeSuggestion(ctx context.Context, subscription *models.Subscription, suggestion *DigestTimeSuggestion) error {
+	language := subscription.User.Language
+	if language == "" {
+		language = internal.DefaultLanguage
+	}
+
+	acceptBtn := s.localization.T(ctx, language, "digest_suggestion_accept_btn", suggestion.SuggestedTime)
+	declineBtn := s.localization.T(ctx, language, "digest_suggestion_decline_btn", suggestion.CurrentTime)
+
+	markup := gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+			{
+				{Text: acceptBtn, CallbackData: fmt.Sprintf("digest_accept_%s_%s", suggestion.SubscriptionID, suggestion.SuggestedTime)},
+				{Text: declineBtn, CallbackData: fmt.Sprintf("digest_decline_%s", suggestion.SubscriptionID)},
+			},
+		},
+	}
+
+	return s.messaging.SendWithMarkup(ctx, subscription.UserID, TemplateDigestTimeSuggestion, map[string]string{
+		"suggested_time": suggestion.SuggestedTime,
+		"current_time":   suggestion.CurrentTime,
+	}, markup)
+}
+
+// AcceptDigestTimeSuggestion moves the user's daily digest to the suggested time
+func (s *EngagementService) AcceptDigestTimeSuggestion(ctx context.Context, userID int64, subscriptionID uuid.UUID, timeOfDay string) error {
+	if _, err := time.Parse("15:04", timeOfDay); err != nil {
+		return fmt.Errorf("invalid time of day %q: %w", timeOfDay, err)
+	}
+
+	return s.subscription.UpdateSubscription(ctx, userID, subscriptionID, map[string]interface{}{
+		"time_of_day": timeOfDay,
+	})
+}
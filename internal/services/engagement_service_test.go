@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestPreferredHour(t *testing.T) {
+	tests := []struct {
+		name      string
+		histogram map[int]int
+		wantHour  int
+		wantOK    bool
+	}{
+		{
+			name:      "too few interactions",
+			histogram: map[int]int{19: 9},
+			wantOK:    false,
+		},
+		{
+			name:      "clear evening peak",
+			histogram: map[int]int{18: 3, 19: 6, 20: 2, 8: 1, 12: 2},
+			wantHour:  19,
+			wantOK:    true,
+		},
+		{
+			name:      "activity spread over the day",
+			histogram: map[int]int{6: 2, 9: 2, 12: 2, 15: 2, 18: 2, 21: 2},
+			wantOK:    false,
+		},
+		{
+			name:      "exactly half around the peak is enough",
+			histogram: map[int]int{19: 5, 8: 2, 12: 3},
+			wantHour:  19,
+			wantOK:    true,
+		},
+		{
+			name:      "peak wraps around midnight",
+			histogram: map[int]int{23: 4, 0: 5, 1: 3, 12: 2},
+			wantHour:  0,
+			wantOK:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var histogram [24]int
+			for hour, count := range tt.histogram {
+				histogram[hour] = count
+			}
+
+			hour, ok := PreferredHour(histogram)
+
+			assert.Equal(t, tt.wantOK, ok)
+			if tt.wantOK {
+				assert.Equal(t, tt.wantHour, hour)
+			}
+		})
+	}
+}
+
+func TestHourDistance(t *testing.T) {
+	assert.Equal(t, 11, hourDistance(8, 19))
+	assert.Equal(t, 3, hourDistance(16, 19))
+	assert.Equal(t, 2, hourDistance(23, 1))
+	assert.Equal(t, 0, hourDistance(7, 7))
+}
+
+func TestEngagementService_RecordInteraction(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	at := time.Date(2025, 6, 5, 19, 12, 0, 0, time.UTC)
+
+	t.Run("counts interaction in hourly bucket", func(t *testing.T) {
+		mockRedis := helpers.NewMockRedis()
+		service := NewEngagementService(mockRedis.Client, nil, nil, nil, nil, logger)
+
+		mockRedis.Mock.ExpectTxPipeline()
+		mockRedis.Mock.ExpectHIncrBy("activity:hours:123", "2025060519", 1).SetVal(1)
+		mockRedis.Mock.ExpectExpire("activity:hours:123", activityWindow+24*time.Hour).SetVal(true)
+		mockRedis.Mock.ExpectTxPipelineExec()
+
+		err := service.RecordInteraction(context.Background(), &models.User{ID: 123}, at)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("respects opt-out", func(t *testing.T) {
+		mockRedis := helpers.NewMockRedis()
+		service := NewEngagementService(mockRedis.Client, nil, nil, nil, nil, logger)
+
+		err := service.RecordInteraction(context.Background(), &models.User{ID: 123, ActivityTrackingDisabled: true}, at)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+}
+
+func TestEngagementService_InteractionHistogram(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	mockRedis := helpers.NewMockRedis()
+	service := NewEngagementService(mockRedis.Client, nil, nil, nil, nil, logger)
+	service.now = func() time.Time { return time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC) }
+
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	require.NoError(t, err)
+
+	mockRedis.Mock.ExpectHGetAll("activity:hours:123").SetVal(map[string]string{
+		"2025062916": "4", // 19:00 in Kyiv (UTC+3)
+		"2025062816": "2",
+		"2025052016": "7", // older than 30 days
+	})
+	mockRedis.Mock.ExpectHDel("activity:hours:123", "2025052016").SetVal(1)
+
+	histogram, err := service.InteractionHistogram(context.Background(), 123, kyiv)
+
+	require.NoError(t, err)
+	assert.Equal(t, 6, histogram[19])
+	assert.Equal(t, 0, histogram[16])
+	assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+}
+
+func TestEngagementService_ProcessDigestTimeSuggestions(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	now := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	userID := int64(123)
+	subscriptionID := uuid.New()
+
+	// Evening activity: 12 interactions at 19:00 UTC over the past days
+	eveningActivity := map[string]string{
+		"2025062919": "5",
+		"2025062819": "4",
+		"2025062719": "3",
+	}
+
+	setup := func(t *testing.T, timeOfDay string) (*EngagementService, *helpers.MockDB, *helpers.MockRedis, *fakeMessageSender) {
+		mockDB := helpers.NewMockDB(t)
+		mockRedis := helpers.NewMockRedis()
+
+		messaging, sender := newTestMessagingService(t, nil)
+		service := NewEngagementService(mockRedis.Client, nil, NewSubscriptionService(mockDB.DB, mockRedis.Client), messaging, messaging.localization, logger)
+		service.now = func() time.Time { return now }
+
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "subscriptions" WHERE subscription_type = \$1 AND is_active = \$2`).
+			WithArgs(models.SubscriptionDaily, true).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id", "user_id", "subscription_type", "time_of_day", "is_active"}).
+				AddRow(subscriptionID, userID, models.SubscriptionDaily, timeOfDay, true))
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1`).
+			WithArgs(userID).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id", "language", "timezone", "is_active"}).
+				AddRow(userID, "en-US", "UTC", true))
+
+		mockRedis.Mock.ExpectHGetAll("activity:hours:123").SetVal(eveningActivity)
+
+		return service, mockDB, mockRedis, sender
+	}
+
+	t.Run("suggests a new time when activity diverges", func(t *testing.T) {
+		service, mockDB, mockRedis, sender := setup(t, "08:00")
+		defer func() { _ = mockDB.Close() }()
+
+		mockRedis.Mock.ExpectSetNX("digest_suggestion:"+subscriptionID.String(), "19:00", digestSuggestionCooldown).SetVal(true)
+
+		sent, err := service.ProcessDigestTimeSuggestions(context.Background())
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, sent)
+		require.Len(t, sender.sent, 1)
+		assert.Equal(t, userID, sender.sent[0].chatID)
+
+		markup, ok := sender.sent[0].opts.ReplyMarkup.(gotgbot.InlineKeyboardMarkup)
+		require.True(t, ok)
+		assert.Equal(t, "digest_accept_"+subscriptionID.String()+"_19:00", markup.InlineKeyboard[0][0].CallbackData)
+		assert.Equal(t, "digest_decline_"+subscriptionID.String(), markup.InlineKeyboard[0][1].CallbackData)
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("no suggestion within the divergence threshold", func(t *testing.T) {
+		service, mockDB, mockRedis, sender := setup(t, "16:00")
+		defer func() { _ = mockDB.Close() }()
+
+		sent, err := service.ProcessDigestTimeSuggestions(context.Background())
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, sent)
+		assert.Empty(t, sender.sent)
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("no repeat suggestion during cool-down", func(t *testing.T) {
+		service, mockDB, mockRedis, sender := setup(t, "08:00")
+		defer func() { _ = mockDB.Close() }()
+
+		mockRedis.Mock.ExpectSetNX("digest_suggestion:"+subscriptionID.String(), "19:00", digestSuggestionCooldown).SetVal(false)
+
+		sent, err := service.ProcessDigestTimeSuggestions(context.Background())
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, sent)
+		assert.Empty(t, sender.sent)
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("failed send releases the cool-down", func(t *testing.T) {
+		service, mockDB, mockRedis, sender := setup(t, "08:00")
+		defer func() { _ = mockDB.Close() }()
+		sender.failFor[userID] = true
+
+		key := "digest_suggestion:" + subscriptionID.String()
+		mockRedis.Mock.ExpectSetNX(key, "19:00", digestSuggestionCooldown).SetVal(true)
+		mockRedis.Mock.ExpectDel(key).SetVal(1)
+
+		sent, err := service.ProcessDigestTimeSuggestions(context.Background())
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, sent)
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+}
+
+func TestEngagementService_AcceptDigestTimeSuggestion(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	mockRedis := helpers.NewMockRedis()
+	service := NewEngagementService(mockRedis.Client, nil, NewSubscriptionService(mockDB.DB, mockRedis.Client), nil, nil, logger)
+
+	userID := int64(123)
+	subscriptionID := uuid.New()
+
+	t.Run("updates the subscription time", func(t *testing.T) {
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "subscriptions" SET`).
+			WithArgs("19:00", helpers.AnyTime{}, subscriptionID, userID).
+			WillReturnResult(helpers.NewResult(1, 1))
+		mockDB.Mock.ExpectCommit()
+
+		err := service.AcceptDigestTimeSuggestion(context.Background(), userID, subscriptionID, "19:00")
+
+		assert.NoError(t, err)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("rejects invalid time", func(t *testing.T) {
+		err := service.AcceptDigestTimeSuggestion(context.Background(), userID, subscriptionID, "25:00")
+
+		assert.Error(t, err)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestEngagementService_SetActivityTracking(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	mockRedis := helpers.NewMockRedis()
+	userService := NewUserService(mockDB.DB, mockRedis.Client, nil, logger, time.Now())
+	service := NewEngagementService(mockRedis.Client, userService, nil, nil, nil, logger)
+
+	mockRedis.Mock.ExpectDel("user:123").SetVal(1)
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectExec(`UPDATE "users" SET "activity_tracking_disabled"=\$1,"updated_at"=\$2 WHERE id = \$3`).
+		WithArgs(true, helpers.AnyTime{}, int64(123)).
+		WillReturnResult(helpers.NewResult(1, 1))
+	mockDB.Mock.ExpectCommit()
+	mockRedis.Mock.ExpectDel("activity:hours:123").SetVal(1)
+
+	err := service.SetActivityTracking(context.Background(), 123, false)
+
+	assert.NoError(t, err)
+	mockDB.ExpectationsWereMet(t)
+	assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// pendingExportKeyPrefix keys the export a user is entering the date
+	// range of, followed by the user ID
+	pendingExportKeyPrefix = "export_pending:"
+
+	// PendingExportTimeout is how long an export waits for its date range
+	PendingExportTimeout = 5 * time.Minute
+
+	// pendingExportTTL keeps the state past the timeout so that a late reply
+	// is told the input expired instead of being read as a location
+	pendingExportTTL = time.Hour
+
+	// exportRangeDateLayout is the layout of both dates of a custom range
+	exportRangeDateLayout = "2006-01-02"
+)
+
+var (
+	// ErrPendingExportExpired is returned for an export whose date range was
+	// not entered within PendingExportTimeout
+	ErrPendingExportExpired = errors.New("export range input expired")
+	// ErrExportRangeFormat is returned for a date range not written as two
+	// YYYY-MM-DD dates
+	ErrExportRangeFormat = errors.New("export range must be two YYYY-MM-DD dates")
+)
+
+// PendingExport is an export waiting for the user to reply with its date range
+type PendingExport struct {
+	Type      ExportType `json:"type"`
+	StartedAt time.Time  `json:"started_at"`
+}
+
+func pendingExportKey(userID int64) string {
+	return fmt.Sprintf("%s%d", pendingExportKeyPrefix, userID)
+}
+
+// StartPendingExport waits for the user's next message to be the date range
+// of an export, replacing any export they had started before
+func (s *ExportService) StartPendingExport(ctx context.Context, userID int64, exportType ExportType) error {
+	if s.redis == nil {
+		return errors.New("custom export ranges are not available")
+	}
+	data, err := json.Marshal(PendingExport{Type: exportType, StartedAt: s.now()})
+	if err != nil {
+		return err
+	}
+	if err := s.redis.Set(ctx, pendingExportKey(userID), data, pendingExportTTL).Err(); err != nil {
+		return fmt.Errorf("failed to store pending export: %w", err)
+	}
+	return nil
+}
+
+// GetPendingExport returns the export waiting for the user's date range, or
+// nil when there is none. A pending export older than PendingExportTimeout is
+// returned with ErrPendingExportExpired.
+func (s *ExportService) GetPendingExport(ctx context.Context, userID int64) (*PendingExport, error) {
+	if s.redis == nil {
+		return nil, nil
+	}
+	data, err := s.redis.Get(ctx, pendingExportKey(userID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pending export: %w", err)
+	}
+
+	var pending PendingExport
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return nil, fmt.Errorf("failed to decode pending export: %w", err)
+	}
+	if s.now().Sub(pending.StartedAt) > PendingExportTimeout {
+		return &pending, ErrPendingExportExpired
+	}
+	return &pending, nil
+}
+
+// ClearPendingExport stops waiting for an export date range
+func (s *ExportService) ClearPendingExport(ctx context.Context, userID int64) error {
+	if s.redis == nil {
+		return nil
+	}
+	return s.redis.Del(ctx, pendingExportKey(userID)).Err()
+}
+
+// ParseExportRange reads a custom range written as "YYYY-MM-DD YYYY-MM-DD".
+// Both days are UTC and included whole, and the range is validated.
+func ParseExportRange(text string) (ExportRange, error) {
+	fields := strings.Fields(text)
+	if len(fields) != 2 {
+		return ExportRange{}, ErrExportRangeFormat
+	}
+	from, err := time.Parse(exportRangeDateLayout, fields[0])
+	if err != nil {
+		return ExportRange{}, ErrExportRangeFormat
+	}
+	to, err := time.Parse(exportRangeDateLayout, fields[1])
+	if err != nil {
+		return ExportRange{}, ErrExportRangeFormat
+	}
+
+	// The last day ends at the database's microsecond precision
+	r := ExportRange{From: from, To: to.AddDate(0, 0, 1).Add(-time.Microsecond)}
+	return r, r.Validate()
+}
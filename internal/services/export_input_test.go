@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestExportService_PendingExport(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	newService := func(t *testing.T, now time.Time) (*ExportService, *helpers.MockRedis) {
+		mockRedis := helpers.NewMockRedis()
+		service := NewExportService(nil, helpers.NewSilentTestLogger(), nil)
+		service.SetRedis(mockRedis.Client)
+		service.now = func() time.Time { return now }
+		return service, mockRedis
+	}
+	stored := `{"type":"weather","started_at":"2026-10-16T09:00:00Z"}`
+
+	t.Run("start stores the export type and time", func(t *testing.T) {
+		service, mockRedis := newService(t, now)
+		mockRedis.Mock.ExpectSet("export_pending:123", []byte(stored), pendingExportTTL).SetVal("OK")
+
+		require.NoError(t, service.StartPendingExport(context.Background(), 123, ExportTypeWeatherData))
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("none pending", func(t *testing.T) {
+		service, mockRedis := newService(t, now)
+		mockRedis.Mock.ExpectGet("export_pending:123").RedisNil()
+
+		pending, err := service.GetPendingExport(context.Background(), 123)
+		require.NoError(t, err)
+		assert.Nil(t, pending)
+	})
+
+	t.Run("expired after the timeout", func(t *testing.T) {
+		service, mockRedis := newService(t, now.Add(PendingExportTimeout+time.Second))
+		mockRedis.Mock.ExpectGet("export_pending:123").SetVal(stored)
+
+		pending, err := service.GetPendingExport(context.Background(), 123)
+		assert.ErrorIs(t, err, ErrPendingExportExpired)
+		require.NotNil(t, pending)
+		assert.Equal(t, ExportTypeWeatherData, pending.Type)
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		service, mockRedis := newService(t, now)
+		mockRedis.Mock.ExpectDel("export_pending:123").SetVal(1)
+
+		require.NoError(t, service.ClearPendingExport(context.Background(), 123))
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("without redis nothing is pending", func(t *testing.T) {
+		service := NewExportService(nil, helpers.NewSilentTestLogger(), nil)
+
+		pending, err := service.GetPendingExport(context.Background(), 123)
+		assert.NoError(t, err)
+		assert.Nil(t, pending)
+		assert.Error(t, service.StartPendingExport(context.Background(), 123, ExportTypeWeatherData))
+	})
+}
+
+func TestParseExportRange(t *testing.T) {
+	window, err := ParseExportRange(" 2026-01-01   2026-01-01 ")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), window.From)
+	assert.Equal(t, time.Date(2026, 1, 1, 23, 59, 59, 999999000, time.UTC), window.To)
+
+	_, err = ParseExportRange("2026-03-01 2026-02-01")
+	assert.ErrorIs(t, err, ErrExportRangeOrder)
+
+	// Two years, leap day included, are within the cap; a day more is not
+	_, err = ParseExportRange("2024-01-01 2025-12-31")
+	assert.NoError(t, err)
+	_, err = ParseExportRange("2024-01-01 2026-01-01")
+	assert.ErrorIs(t, err, ErrExportRangeTooLong)
+
+	for _, text := range []string{"", "2026-01-01", "01.01.2026 02.01.2026", "2026-01-01 to 2026-02-01"} {
+		_, err = ParseExportRange(text)
+		assert.ErrorIs(t, err, ErrExportRangeFormat, text)
+	}
+}
@@ -1,18 +1,25 @@
 package services
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"strconv"
 	"time"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/weather"
 )
 
 type ExportFormat string
@@ -32,21 +39,120 @@ const (
 	ExportTypeAll           ExportType = "all"
 )
 
+const (
+	// exportRecordLimit caps the number of time-series records in a single export
+	exportRecordLimit = 1000
+
+	// Retention windows for full (non-incremental) exports
+	weatherExportWindowDays = 30
+	alertExportWindowDays   = 90
+
+	// MaxExportRangeYears caps the span of a date range export
+	MaxExportRangeYears = 2
+)
+
+var (
+	// ErrExportRangeOrder is returned for a date range that does not start before it ends
+	ErrExportRangeOrder = errors.New("export range must start before it ends")
+	// ErrExportRangeTooLong is returned for a date range longer than MaxExportRangeYears
+	ErrExportRangeTooLong = errors.New("export range is too long")
+)
+
 type ExportService struct {
 	db           *gorm.DB
+	redis        *redis.Client // Pending custom date ranges; nil disables them
 	logger       *zerolog.Logger
 	localization *LocalizationService
+	now          func() time.Time
+}
+
+// ExportInterval states exactly which records of a time-series dataset an export covers.
+// Incremental exports cover (From, To]; full exports cover [From, To].
+type ExportInterval struct {
+	Dataset     string    `json:"dataset"`
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	Incremental bool      `json:"incremental"`
+}
+
+// String formats the interval in mathematical notation
+func (i ExportInterval) String() string {
+	open := "["
+	if i.Incremental {
+		open = "("
+	}
+	return fmt.Sprintf("%s%s, %s]", open, i.From.Format(time.RFC3339Nano), i.To.Format(time.RFC3339Nano))
+}
+
+// ExportRange limits the time-series records of a full export to [From, To].
+// A zero From starts with the user's first records and a zero To ends at the
+// time of the export.
+type ExportRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// ExportRangeLastDays is the range of the given number of days up to the time of the export
+func ExportRangeLastDays(now time.Time, days int) ExportRange {
+	return ExportRange{From: now.UTC().AddDate(0, 0, -days)}
+}
+
+// Validate checks that a range with both ends set starts before it ends and
+// spans at most MaxExportRangeYears
+func (r ExportRange) Validate() error {
+	if r.From.IsZero() || r.To.IsZero() {
+		return nil
+	}
+	if !r.From.Before(r.To) {
+		return ErrExportRangeOrder
+	}
+	if r.To.After(r.From.AddDate(MaxExportRangeYears, 0, 0)) {
+		return ErrExportRangeTooLong
+	}
+	return nil
 }
 
+// resolve fills in the open ends of the range for an export of the user at exportedAt
+func (r ExportRange) resolve(user *models.User, exportedAt time.Time) ExportRange {
+	if r.From.IsZero() {
+		r.From = user.CreatedAt.UTC()
+	}
+	if r.To.IsZero() || r.To.After(exportedAt) {
+		r.To = exportedAt
+	}
+	return r
+}
+
+// ExportData is the content of an export, laid out as its JSON document. A
+// prepared export leaves the record lists empty and reads the records from the
+// database while its file is written.
 type ExportData struct {
 	User            *models.User                `json:"user,omitempty"`
-	WeatherData     []models.WeatherData        `json:"weather_data,omitempty"`
+	WeatherData     []models.WeatherRecord      `json:"weather_data,omitempty"`
 	Subscriptions   []models.Subscription       `json:"subscriptions,omitempty"`
 	AlertConfigs    []models.AlertConfig        `json:"alert_configs,omitempty"`
 	TriggeredAlerts []models.EnvironmentalAlert `json:"triggered_alerts,omitempty"`
+	AuditLogs       []models.AuditLog           `json:"audit_logs,omitempty"` // Admin actions taken by or on the user
 	ExportedAt      time.Time                   `json:"exported_at"`
 	Format          ExportFormat                `json:"format"`
 	Type            ExportType                  `json:"type"`
+	Coverage        []ExportInterval            `json:"coverage,omitempty"`
+
+	window  *ExportRange  // Date range the export was limited to, if any
+	queries exportQueries // Records of a prepared export, read as its file is written
+}
+
+// exportQuery selects the records of one dataset of an export
+type exportQuery func(db *gorm.DB) *gorm.DB
+
+// exportQueries select the records of the datasets of a prepared export. A
+// dataset without a query has no records to read.
+type exportQueries struct {
+	weatherData     exportQuery
+	subscriptions   exportQuery
+	alertConfigs    exportQuery
+	triggeredAlerts exportQuery
+	auditLogs       exportQuery
 }
 
 func NewExportService(db *gorm.DB, logger *zerolog.Logger, localization *LocalizationService) *ExportService {
@@ -54,98 +160,375 @@ func NewExportService(db *gorm.DB, logger *zerolog.Logger, localization *Localiz
 		db:           db,
 		logger:       logger,
 		localization: localization,
+		now:          time.Now,
 	}
 }
 
-// ExportUserData exports user's data in the specified format
+// SetRedis enables the custom date ranges entered as a text reply, which are
+// kept pending in Redis until the user answers
+func (s *ExportService) SetRedis(redis *redis.Client) {
+	s.redis = redis
+}
+
+// ExportUserData exports user's data in the specified format into memory.
+// Large exports should rather use StreamExportUserData.
 func (s *ExportService) ExportUserData(ctx context.Context, userID int64, exportType ExportType, format ExportFormat, userLang string) (*bytes.Buffer, string, error) {
+	return s.exportUserData(ctx, userID, exportType, format, userLang, false)
+}
+
+// StreamExportUserData writes user's data in the specified format to w as it
+// is generated, without holding the whole file in memory. The export is
+// recorded once it has been written completely.
+func (s *ExportService) StreamExportUserData(ctx context.Context, userID int64, exportType ExportType, format ExportFormat, userLang string, w io.Writer) error {
+	_, err := s.streamExport(ctx, userID, exportType, format, userLang, false, w)
+	return err
+}
+
+// ExportUserDataIncremental exports only the weather records and triggered alerts
+// captured since the user's last successful export of the same type. Without a
+// previous export it starts from the beginning of the weather retention window.
+func (s *ExportService) ExportUserDataIncremental(ctx context.Context, userID int64, exportType ExportType, format ExportFormat, userLang string) (*bytes.Buffer, string, error) {
+	return s.exportUserData(ctx, userID, exportType, format, userLang, true)
+}
+
+// GetLastExportTime returns the upper bound of the user's last successful export
+// of the given type, or nil if the user has never exported it
+func (s *ExportService) GetLastExportTime(ctx context.Context, userID int64, exportType ExportType) (*time.Time, error) {
+	var cursor models.ExportCursor
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND export_type = ?", userID, string(exportType)).
+		First(&cursor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lastExportedAt := cursor.LastExportedAt.UTC()
+	return &lastExportedAt, nil
+}
+
+// exportExtensions are the file extensions of the supported export formats
+var exportExtensions = map[ExportFormat]string{
+	ExportFormatJSON: "json",
+	ExportFormatCSV:  "csv",
+	ExportFormatTXT:  "txt",
+}
+
+// PreparedExport is an export whose records have been chosen but are only read
+// by WriteExport as it generates the file, and which has not been recorded as
+// delivered yet
+type PreparedExport struct {
+	Filename string
+
+	data       *ExportData
+	userLang   string
+	userID     int64
+	exportType ExportType
+	until      time.Time // Point up to which the export is complete
+}
+
+// PrepareExport chooses the records of an export without reading them,
+// generating its file or recording it. Call CommitExport once the file has reached the user, so the
+// records of an export that failed to deliver are included again in the next
+// incremental export. A full export can be limited to a date range; without
+// one it covers the retention windows.
+func (s *ExportService) PrepareExport(ctx context.Context, userID int64, exportType ExportType, format ExportFormat, userLang string, incremental bool, window *ExportRange) (*PreparedExport, error) {
+	if window != nil {
+		if incremental {
+			return nil, errors.New("incremental exports cannot be limited to a date range")
+		}
+		if err := window.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
 	s.logger.Info().
 		Int64("user_id", userID).
 		Str("type", string(exportType)).
 		Str("format", string(format)).
+		Bool("incremental", incremental).
 		Msg("Starting data export")
 
+	extension, ok := exportExtensions[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+
 	// Get user data
 	user, err := s.getUserData(ctx, userID)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to get user data: %w", err)
+		return nil, fmt.Errorf("failed to get user data: %w", err)
 	}
 
+	// Truncate to the database's microsecond precision so the stored cursor matches exactly
+	exportedAt := s.now().UTC().Truncate(time.Microsecond)
+
 	exportData := &ExportData{
 		User:       user,
-		ExportedAt: time.Now().UTC(),
+		ExportedAt: exportedAt,
 		Format:     format,
 		Type:       exportType,
 	}
 
-	// Get specific data based on export type
-	switch exportType {
-	case ExportTypeWeatherData:
-		exportData.WeatherData, err = s.getWeatherData(ctx, userID)
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to get weather data: %w", err)
+	if window != nil {
+		resolved := window.resolve(user, exportedAt)
+		if err := resolved.Validate(); err != nil {
+			return nil, err
 		}
-	case ExportTypeAlerts:
-		exportData.AlertConfigs, err = s.getAlertConfigs(ctx, userID)
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to get alert configs: %w", err)
-		}
-		exportData.TriggeredAlerts, err = s.getTriggeredAlerts(ctx, userID)
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to get triggered alerts: %w", err)
-		}
-	case ExportTypeSubscriptions:
-		exportData.Subscriptions, err = s.getSubscriptions(ctx, userID)
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to get subscriptions: %w", err)
-		}
-	case ExportTypeAll:
-		exportData.WeatherData, err = s.getWeatherData(ctx, userID)
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to get weather data: %w", err)
-		}
-		exportData.AlertConfigs, err = s.getAlertConfigs(ctx, userID)
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to get alert configs: %w", err)
-		}
-		exportData.TriggeredAlerts, err = s.getTriggeredAlerts(ctx, userID)
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to get triggered alerts: %w", err)
-		}
-		exportData.Subscriptions, err = s.getSubscriptions(ctx, userID)
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to get subscriptions: %w", err)
-		}
-	default:
-		return nil, "", fmt.Errorf("unsupported export type: %s", exportType)
+		exportData.window = &resolved
+	}
+
+	if incremental {
+		err = s.collectIncrementalData(ctx, userID, exportData)
+	} else {
+		err = s.collectFullData(userID, exportData)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prepared := &PreparedExport{
+		Filename:   exportFilename(exportData, extension),
+		data:       exportData,
+		userLang:   userLang,
+		userID:     userID,
+		exportType: exportType,
+	}
+	// A range ending in the past says nothing about the records since, so
+	// it must not move the cursor of incremental exports
+	if until := exportData.coverageEnd(); until.Equal(exportedAt) || exportData.window == nil {
+		prepared.until = until
 	}
+	return prepared, nil
+}
 
-	// Generate export based on format
-	var buffer *bytes.Buffer
-	var filename string
+// CommitExport records a delivered export, so the next incremental export of
+// the same type continues where it ends. Exports of a range ending in the past
+// are not recorded.
+func (s *ExportService) CommitExport(ctx context.Context, export *PreparedExport) {
+	if export.until.IsZero() {
+		return
+	}
+	s.saveExportCursor(ctx, export.userID, export.exportType, export.until)
+}
 
-	switch format {
+// WriteExport generates the file of a prepared export into w and returns the
+// number of bytes written. The records are read from the database row by row
+// and encoded one at a time, so neither they nor the file are held in memory
+// whole, and the file can be streamed to the user as it is generated.
+func (s *ExportService) WriteExport(ctx context.Context, w io.Writer, export *PreparedExport) (int64, error) {
+	counter := &countingWriter{w: w}
+
+	var err error
+	switch export.data.Format {
 	case ExportFormatJSON:
-		buffer, filename, err = s.exportToJSON(exportData, userLang)
+		err = s.writeJSON(ctx, counter, export.data)
 	case ExportFormatCSV:
-		buffer, filename, err = s.exportToCSV(exportData, userLang)
+		err = s.writeCSV(ctx, counter, export.data, export.userLang)
 	case ExportFormatTXT:
-		buffer, filename, err = s.exportToTXT(exportData, userLang)
+		err = s.writeTXT(ctx, counter, export.data, export.userLang)
 	default:
-		return nil, "", fmt.Errorf("unsupported export format: %s", format)
+		err = fmt.Errorf("unsupported export format: %s", export.data.Format)
 	}
-
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to generate %s export: %w", format, err)
+		return counter.n, fmt.Errorf("failed to generate %s export: %w", export.data.Format, err)
 	}
 
 	s.logger.Info().
-		Int64("user_id", userID).
-		Str("filename", filename).
-		Int("size_bytes", buffer.Len()).
+		Int64("user_id", export.userID).
+		Str("filename", export.Filename).
+		Int64("size_bytes", counter.n).
 		Msg("Data export completed")
+	return counter.n, nil
+}
 
-	return buffer, filename, nil
+// exportUserData generates an export into memory and records it right away
+func (s *ExportService) exportUserData(ctx context.Context, userID int64, exportType ExportType, format ExportFormat, userLang string, incremental bool) (*bytes.Buffer, string, error) {
+	var buffer bytes.Buffer
+	filename, err := s.streamExport(ctx, userID, exportType, format, userLang, incremental, &buffer)
+	if err != nil {
+		return nil, "", err
+	}
+	return &buffer, filename, nil
+}
+
+// streamExport generates an export into w and records it once it has been
+// written completely. It returns the name of the file.
+func (s *ExportService) streamExport(ctx context.Context, userID int64, exportType ExportType, format ExportFormat, userLang string, incremental bool, w io.Writer) (string, error) {
+	export, err := s.PrepareExport(ctx, userID, exportType, format, userLang, incremental, nil)
+	if err != nil {
+		return "", err
+	}
+	if _, err := s.WriteExport(ctx, w, export); err != nil {
+		return "", err
+	}
+
+	s.CommitExport(ctx, export)
+	return export.Filename, nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// collectFullData selects all data of the export type within the chosen date
+// range, or within the retention windows without one
+func (s *ExportService) collectFullData(userID int64, exportData *ExportData) error {
+	exportedAt := exportData.ExportedAt
+
+	weatherFrom := exportedAt.AddDate(0, 0, -weatherExportWindowDays)
+	alertsFrom := exportedAt.AddDate(0, 0, -alertExportWindowDays)
+	until := exportedAt
+	if exportData.window != nil {
+		weatherFrom, alertsFrom, until = exportData.window.From, exportData.window.From, exportData.window.To
+	}
+
+	includeWeather := false
+	includeAlerts := false
+	includeSubscriptions := false
+	includeAuditLogs := false
+
+	switch exportData.Type {
+	case ExportTypeWeatherData:
+		includeWeather = true
+	case ExportTypeAlerts:
+		includeAlerts = true
+	case ExportTypeSubscriptions:
+		includeSubscriptions = true
+	case ExportTypeAll:
+		includeWeather = true
+		includeAlerts = true
+		includeSubscriptions = true
+		includeAuditLogs = true
+	default:
+		return fmt.Errorf("unsupported export type: %s", exportData.Type)
+	}
+
+	if includeWeather {
+		exportData.queries.weatherData = weatherDataQuery(userID, weatherFrom, until)
+		exportData.Coverage = append(exportData.Coverage, ExportInterval{
+			Dataset: "weather_data",
+			From:    weatherFrom,
+			To:      until,
+		})
+	}
+	if includeAlerts {
+		exportData.queries.alertConfigs = alertConfigsQuery(userID)
+		exportData.queries.triggeredAlerts = triggeredAlertsQuery(userID, alertsFrom, until)
+		exportData.Coverage = append(exportData.Coverage, ExportInterval{
+			Dataset: "triggered_alerts",
+			From:    alertsFrom,
+			To:      until,
+		})
+	}
+	if includeSubscriptions {
+		exportData.queries.subscriptions = subscriptionsQuery(userID)
+	}
+	if includeAuditLogs {
+		exportData.queries.auditLogs = auditLogsQuery(userID, alertsFrom, until)
+		exportData.Coverage = append(exportData.Coverage, ExportInterval{
+			Dataset: "audit_logs",
+			From:    alertsFrom,
+			To:      until,
+		})
+	}
+
+	return nil
+}
+
+// collectIncrementalData selects the time-series records captured after the last export.
+// All datasets share one (since, until] interval so the next export continues exactly
+// where this one stops, even when the weather records had to be truncated.
+func (s *ExportService) collectIncrementalData(ctx context.Context, userID int64, exportData *ExportData) error {
+	includeWeather := false
+	includeAlerts := false
+
+	switch exportData.Type {
+	case ExportTypeWeatherData:
+		includeWeather = true
+	case ExportTypeAlerts:
+		includeAlerts = true
+	case ExportTypeAll:
+		includeWeather = true
+		includeAlerts = true
+	default:
+		return fmt.Errorf("incremental export not supported for type: %s", exportData.Type)
+	}
+
+	lastExport, err := s.GetLastExportTime(ctx, userID, exportData.Type)
+	if err != nil {
+		return fmt.Errorf("failed to get last export time: %w", err)
+	}
+
+	since := exportData.ExportedAt.AddDate(0, 0, -weatherExportWindowDays)
+	if lastExport != nil {
+		since = *lastExport
+	}
+	until := exportData.ExportedAt
+
+	if includeWeather {
+		until, err = s.incrementalWeatherEnd(ctx, userID, since, until)
+		if err != nil {
+			return fmt.Errorf("failed to get weather data: %w", err)
+		}
+		exportData.queries.weatherData = weatherDataBetweenQuery(userID, since, until)
+		exportData.Coverage = append(exportData.Coverage, ExportInterval{
+			Dataset: "weather_data", From: since, To: until, Incremental: true,
+		})
+	}
+	if includeAlerts {
+		exportData.queries.triggeredAlerts = triggeredAlertsBetweenQuery(userID, since, until)
+		exportData.Coverage = append(exportData.Coverage, ExportInterval{
+			Dataset: "triggered_alerts", From: since, To: until, Incremental: true,
+		})
+	}
+
+	return nil
+}
+
+// coverageEnd is the point up to which the export is complete for every dataset
+func (d *ExportData) coverageEnd() time.Time {
+	end := d.ExportedAt
+	for _, interval := range d.Coverage {
+		if interval.To.Before(end) {
+			end = interval.To
+		}
+	}
+	return end
+}
+
+// saveExportCursor records a successful export; failures only cost the user a
+// larger next incremental export, so they are logged rather than returned
+func (s *ExportService) saveExportCursor(ctx context.Context, userID int64, exportType ExportType, exportedUntil time.Time) {
+	cursor := models.ExportCursor{
+		UserID:         userID,
+		ExportType:     string(exportType),
+		LastExportedAt: exportedUntil,
+	}
+
+	err := s.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "export_type"}},
+			DoUpdates: clause.AssignmentColumns([]string{"last_exported_at", "updated_at"}),
+		}).
+		Create(&cursor).Error
+	if err != nil {
+		s.logger.Warn().
+			Err(err).
+			Int64("user_id", userID).
+			Str("type", string(exportType)).
+			Msg("Failed to save export cursor")
+	}
 }
 
 func (s *ExportService) getUserData(ctx context.Context, userID int64) (*models.User, error) {
@@ -156,67 +539,261 @@ func (s *ExportService) getUserData(ctx context.Context, userID int64) (*models.
 	return &user, nil
 }
 
-func (s *ExportService) getWeatherData(ctx context.Context, userID int64) ([]models.WeatherData, error) {
-	var weatherData []models.WeatherData
-	// Get last 30 days of weather data
-	thirtyDaysAgo := time.Now().UTC().AddDate(0, 0, -30)
+// weatherDataQuery selects the weather the user looked up or was sent,
+// observed in [from, to], newest first
+func weatherDataQuery(userID int64, from, to time.Time) exportQuery {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Model(&models.WeatherRecord{}).
+			Where("user_id = ? AND timestamp >= ? AND timestamp <= ?", userID, from, to).
+			Order("timestamp DESC").
+			Limit(exportRecordLimit)
+	}
+}
 
-	err := s.db.WithContext(ctx).
-		Where("user_id = ? AND timestamp >= ?", userID, thirtyDaysAgo).
-		Order("timestamp DESC").
-		Limit(1000). // Reasonable limit
-		Find(&weatherData).Error
+// weatherDataBetweenQuery selects weather records stored in (since, until],
+// oldest first. The interval is on the insertion time, not the observation
+// time: a record served from cache is stored later than it was observed and
+// must not fall behind the cursor.
+func weatherDataBetweenQuery(userID int64, since, until time.Time) exportQuery {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Model(&models.WeatherRecord{}).
+			Where("user_id = ? AND created_at > ? AND created_at <= ?", userID, since, until).
+			Order("created_at ASC").
+			Limit(exportRecordLimit)
+	}
+}
 
-	return weatherData, err
+// incrementalWeatherEnd returns the upper bound of an incremental export of
+// the weather records stored in (since, until]. If the record limit is hit,
+// the interval is shortened to the last complete insertion time.
+func (s *ExportService) incrementalWeatherEnd(ctx context.Context, userID int64, since, until time.Time) (time.Time, error) {
+	var last []time.Time
+	err := weatherDataBetweenQuery(userID, since, until)(s.db.WithContext(ctx)).
+		Offset(exportRecordLimit-1).
+		Limit(1).
+		Pluck("created_at", &last).Error
+	if err != nil || len(last) == 0 {
+		return until, err
+	}
+
+	// More records with the last insertion time may exist beyond the limit,
+	// so the interval ends at the insertion time before it
+	var complete sql.NullTime
+	err = s.db.WithContext(ctx).Model(&models.WeatherRecord{}).
+		Where("user_id = ? AND created_at > ? AND created_at < ?", userID, since, last[0]).
+		Select("MAX(created_at)").
+		Row().Scan(&complete)
+	if err != nil {
+		return until, err
+	}
+	if !complete.Valid {
+		return last[0].UTC(), nil
+	}
+	return complete.Time.UTC(), nil
 }
 
-func (s *ExportService) getAlertConfigs(ctx context.Context, userID int64) ([]models.AlertConfig, error) {
-	var alertConfigs []models.AlertConfig
-	err := s.db.WithContext(ctx).
-		Where("user_id = ?", userID).
-		Find(&alertConfigs).Error
-	return alertConfigs, err
+func alertConfigsQuery(userID int64) exportQuery {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Model(&models.AlertConfig{}).Where("user_id = ?", userID)
+	}
 }
 
-func (s *ExportService) getTriggeredAlerts(ctx context.Context, userID int64) ([]models.EnvironmentalAlert, error) {
-	var triggeredAlerts []models.EnvironmentalAlert
-	// Get last 90 days of triggered alerts
-	ninetyDaysAgo := time.Now().UTC().AddDate(0, 0, -90)
+// triggeredAlertsQuery selects triggered alerts created in [from, to], newest first
+func triggeredAlertsQuery(userID int64, from, to time.Time) exportQuery {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Model(&models.EnvironmentalAlert{}).
+			Where("user_id = ? AND created_at >= ? AND created_at <= ?", userID, from, to).
+			Order("created_at DESC")
+	}
+}
 
-	err := s.db.WithContext(ctx).
-		Where("user_id = ? AND created_at >= ?", userID, ninetyDaysAgo).
-		Order("created_at DESC").
-		Find(&triggeredAlerts).Error
+// triggeredAlertsBetweenQuery selects triggered alerts created in (since, until], oldest first
+func triggeredAlertsBetweenQuery(userID int64, since, until time.Time) exportQuery {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Model(&models.EnvironmentalAlert{}).
+			Where("user_id = ? AND created_at > ? AND created_at <= ?", userID, since, until).
+			Order("created_at ASC")
+	}
+}
 
-	return triggeredAlerts, err
+func subscriptionsQuery(userID int64) exportQuery {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Model(&models.Subscription{}).Where("user_id = ?", userID)
+	}
 }
 
-func (s *ExportService) getSubscriptions(ctx context.Context, userID int64) ([]models.Subscription, error) {
-	var subscriptions []models.Subscription
-	err := s.db.WithContext(ctx).
-		Where("user_id = ?", userID).
-		Find(&subscriptions).Error
-	return subscriptions, err
+// auditLogsQuery selects the admin actions taken by or on the user in
+// [from, to], newest first
+func auditLogsQuery(userID int64, from, to time.Time) exportQuery {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Model(&models.AuditLog{}).
+			Where("(actor_id = ? OR target_id = ?) AND created_at >= ? AND created_at <= ?", userID, userID, from, to).
+			Order("created_at DESC")
+	}
+}
+
+// query applies an export query to the database; without a query it is nil
+func (s *ExportService) query(ctx context.Context, query exportQuery) *gorm.DB {
+	if query == nil {
+		return nil
+	}
+	return query(s.db.WithContext(ctx))
 }
 
-func (s *ExportService) exportToJSON(data *ExportData, userLang string) (*bytes.Buffer, string, error) {
-	jsonData, err := json.MarshalIndent(data, "", "  ")
+// recordCount returns how many records a dataset of an export has: those in
+// memory and those its query selects
+func (s *ExportService) recordCount(ctx context.Context, query exportQuery, inMemory int) (int64, error) {
+	if query == nil {
+		return int64(inMemory), nil
+	}
+	var stored int64
+	db := s.db.WithContext(ctx)
+	if err := db.Table("(?) AS records", query(db)).Count(&stored).Error; err != nil {
+		return 0, err
+	}
+	return int64(inMemory) + stored, nil
+}
+
+// eachRecord calls fn for each record of a dataset: first the records in
+// memory, then the rows of query, which are read one at a time
+func eachRecord[T any](query *gorm.DB, items []T, fn func(*T)) error {
+	for i := range items {
+		fn(&items[i])
+	}
+	if query == nil {
+		return nil
+	}
+
+	rows, err := query.Rows()
 	if err != nil {
-		return nil, "", err
+		return err
 	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var record T
+		if err := query.ScanRows(rows, &record); err != nil {
+			return err
+		}
+		fn(&record)
+	}
+	return rows.Err()
+}
 
-	buffer := bytes.NewBuffer(jsonData)
-	filename := fmt.Sprintf("shopogoda_%s_%s_%s.json",
-		data.Type,
-		data.User.Username,
-		data.ExportedAt.Format("2006-01-02"))
+// eachSectionRecord calls fn for each record of a dataset like eachRecord,
+// calling heading once before the first. It returns the number of records.
+func eachSectionRecord[T any](query *gorm.DB, items []T, heading func(), fn func(*T)) (int, error) {
+	count := 0
+	err := eachRecord(query, items, func(record *T) {
+		if count == 0 {
+			heading()
+		}
+		count++
+		fn(record)
+	})
+	return count, err
+}
 
-	return buffer, filename, nil
+// exportFilename names the file of an export: its type, the user and the day
+// of the export, or the first and last day of the date range it is limited to
+func exportFilename(data *ExportData, extension string) string {
+	period := data.ExportedAt.Format("2006-01-02")
+	if data.window != nil {
+		period = data.window.From.Format("2006-01-02") + "_" + data.window.To.Format("2006-01-02")
+	}
+	return fmt.Sprintf("shopogoda_%s_%s_%s.%s", data.Type, data.User.Username, period, extension)
 }
 
-func (s *ExportService) exportToCSV(data *ExportData, userLang string) (*bytes.Buffer, string, error) {
-	var buffer bytes.Buffer
-	writer := csv.NewWriter(&buffer)
+// writeJSON writes the export as an indented JSON object. The records of each
+// list are encoded one at a time rather than the document as a whole.
+func (s *ExportService) writeJSON(ctx context.Context, w io.Writer, data *ExportData) error {
+	out := &jsonObjectWriter{out: bufio.NewWriter(w)}
+	out.out.WriteString("{")
+	if data.User != nil {
+		out.field("user", data.User)
+	}
+	writeJSONList(out, "weather_data", s.query(ctx, data.queries.weatherData), data.WeatherData)
+	writeJSONList(out, "subscriptions", s.query(ctx, data.queries.subscriptions), data.Subscriptions)
+	writeJSONList(out, "alert_configs", s.query(ctx, data.queries.alertConfigs), data.AlertConfigs)
+	writeJSONList(out, "triggered_alerts", s.query(ctx, data.queries.triggeredAlerts), data.TriggeredAlerts)
+	writeJSONList(out, "audit_logs", s.query(ctx, data.queries.auditLogs), data.AuditLogs)
+	out.field("exported_at", data.ExportedAt)
+	out.field("format", data.Format)
+	out.field("type", data.Type)
+	writeJSONList(out, "coverage", nil, data.Coverage)
+	out.out.WriteString("\n}\n")
+
+	if out.err != nil {
+		return out.err
+	}
+	return out.out.Flush()
+}
+
+// jsonObjectWriter writes the fields of a JSON object in the layout of
+// json.MarshalIndent with a two-space indent, keeping the first error
+type jsonObjectWriter struct {
+	out    *bufio.Writer
+	fields int
+	err    error
+}
+
+// key starts a field of the object
+func (j *jsonObjectWriter) key(name string) {
+	if j.fields > 0 {
+		j.out.WriteString(",")
+	}
+	j.fields++
+	fmt.Fprintf(j.out, "\n  %q: ", name)
+}
+
+// value writes a JSON value indented to the given prefix
+func (j *jsonObjectWriter) value(v any, prefix string) {
+	if j.err != nil {
+		return
+	}
+	encoded, err := json.MarshalIndent(v, prefix, "  ")
+	if err != nil {
+		j.err = err
+		return
+	}
+	j.out.Write(encoded)
+}
+
+// field writes a field of the object
+func (j *jsonObjectWriter) field(name string, v any) {
+	j.key(name)
+	j.value(v, "  ")
+}
+
+// writeJSONList writes a list field of the object one item at a time, from
+// memory and then from query. Like omitempty, an empty list is left out.
+func writeJSONList[T any](j *jsonObjectWriter, name string, query *gorm.DB, items []T) {
+	if j.err != nil {
+		return
+	}
+	first := true
+	count, err := eachSectionRecord(query, items, func() {
+		j.key(name)
+		j.out.WriteString("[")
+	}, func(item *T) {
+		if !first {
+			j.out.WriteString(",")
+		}
+		first = false
+		j.out.WriteString("\n    ")
+		j.value(item, "    ")
+	})
+	if count > 0 {
+		j.out.WriteString("\n  ]")
+	}
+	if err != nil && j.err == nil {
+		j.err = fmt.Errorf("failed to read %s: %w", name, err)
+	}
+}
+
+// writeCSV writes the export as CSV, flushing the rows as they fill the
+// writer's buffer
+func (s *ExportService) writeCSV(ctx context.Context, w io.Writer, data *ExportData, userLang string) error {
+	writer := csv.NewWriter(w)
 
 	// Write user info header
 	exportType := s.localization.T(context.Background(), userLang, "export_type")
@@ -228,10 +805,28 @@ func (s *ExportService) exportToCSV(data *ExportData, userLang string) (*bytes.B
 	_ = writer.Write([]string{userID, strconv.FormatInt(data.User.ID, 10)})
 	_ = writer.Write([]string{username, data.User.Username})
 	_ = writer.Write([]string{exportedAt, data.ExportedAt.Format(time.RFC3339)})
+	if data.User.LocationName != "" {
+		location := s.localization.T(context.Background(), userLang, "export_location")
+		coordinates := s.localization.T(context.Background(), userLang, "export_coordinates")
+		_ = writer.Write([]string{location, data.User.LocationName, data.User.City, data.User.Country})
+		coordinateRow := []string{coordinates,
+			strconv.FormatFloat(data.User.Latitude, 'f', 4, 64),
+			strconv.FormatFloat(data.User.Longitude, 'f', 4, 64)}
+		if data.User.LocationApproximate {
+			coordinateRow = append(coordinateRow, s.localization.T(context.Background(), userLang, "location_approximate_suffix"))
+		}
+		_ = writer.Write(coordinateRow)
+	}
+	if len(data.Coverage) > 0 {
+		coveredInterval := s.localization.T(context.Background(), userLang, "export_covered_interval")
+		for _, interval := range data.Coverage {
+			_ = writer.Write([]string{coveredInterval, interval.Dataset, interval.String()})
+		}
+	}
 	_ = writer.Write([]string{}) // Empty line
 
 	// Export weather data if present
-	if len(data.WeatherData) > 0 {
+	count, err := eachSectionRecord(s.query(ctx, data.queries.weatherData), data.WeatherData, func() {
 		weatherData := s.localization.T(context.Background(), userLang, "export_weather_data")
 		timestamp := s.localization.T(context.Background(), userLang, "export_timestamp")
 		temperature := s.localization.T(context.Background(), userLang, "export_temperature")
@@ -243,29 +838,36 @@ func (s *ExportService) exportToCSV(data *ExportData, userLang string) (*bytes.B
 		uvIndex := s.localization.T(context.Background(), userLang, "export_uv_index")
 		description := s.localization.T(context.Background(), userLang, "export_description")
 		aqi := s.localization.T(context.Background(), userLang, "export_aqi")
+		sourceTimestamp := s.localization.T(context.Background(), userLang, "export_source_timestamp")
+		fromCache := s.localization.T(context.Background(), userLang, "export_from_cache")
 
 		_ = writer.Write([]string{weatherData})
-		_ = writer.Write([]string{timestamp, temperature, humidity, pressure, windSpeed, windDegree, visibility, uvIndex, description, aqi})
-
-		for _, weather := range data.WeatherData {
-			_ = writer.Write([]string{
-				weather.Timestamp.Format(time.RFC3339),
-				fmt.Sprintf("%.1f", weather.Temperature),
-				strconv.Itoa(weather.Humidity),
-				fmt.Sprintf("%.1f", weather.Pressure),
-				fmt.Sprintf("%.1f", weather.WindSpeed),
-				strconv.Itoa(weather.WindDegree),
-				fmt.Sprintf("%.1f", weather.Visibility),
-				fmt.Sprintf("%.1f", weather.UVIndex),
-				weather.Description,
-				strconv.Itoa(weather.AQI),
-			})
-		}
+		_ = writer.Write([]string{timestamp, temperature, humidity, pressure, windSpeed, windDegree, visibility, uvIndex, description, aqi, sourceTimestamp, fromCache})
+	}, func(record *models.WeatherRecord) {
+		_ = writer.Write([]string{
+			record.Timestamp.Format(time.RFC3339),
+			weather.FormatDecimal(record.Temperature, 1),
+			strconv.Itoa(record.Humidity),
+			weather.FormatDecimal(record.Pressure, 1),
+			weather.FormatDecimal(record.WindSpeed, 1),
+			strconv.Itoa(record.WindDegree),
+			weather.FormatDecimal(record.Visibility, 1),
+			weather.FormatUV(record.UVIndex),
+			record.Description,
+			strconv.Itoa(record.AQI),
+			record.Timestamp.UTC().Format(time.RFC3339),
+			strconv.FormatBool(record.FromCache),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read weather data: %w", err)
+	}
+	if count > 0 {
 		_ = writer.Write([]string{}) // Empty line
 	}
 
 	// Export subscriptions if present
-	if len(data.Subscriptions) > 0 {
+	count, err = eachSectionRecord(s.query(ctx, data.queries.subscriptions), data.Subscriptions, func() {
 		subscriptions := s.localization.T(context.Background(), userLang, "export_subscriptions")
 		typeLabel := s.localization.T(context.Background(), userLang, "export_type_label")
 		frequency := s.localization.T(context.Background(), userLang, "export_frequency")
@@ -275,21 +877,24 @@ func (s *ExportService) exportToCSV(data *ExportData, userLang string) (*bytes.B
 
 		_ = writer.Write([]string{subscriptions})
 		_ = writer.Write([]string{typeLabel, frequency, timeOfDay, isActive, createdAt})
-
-		for _, sub := range data.Subscriptions {
-			_ = writer.Write([]string{
-				sub.SubscriptionType.String(),
-				sub.Frequency.String(),
-				sub.TimeOfDay,
-				strconv.FormatBool(sub.IsActive),
-				sub.CreatedAt.Format(time.RFC3339),
-			})
-		}
+	}, func(sub *models.Subscription) {
+		_ = writer.Write([]string{
+			sub.SubscriptionType.String(),
+			sub.Frequency.String(),
+			sub.TimeOfDay,
+			strconv.FormatBool(sub.IsActive),
+			sub.CreatedAt.Format(time.RFC3339),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read subscriptions: %w", err)
+	}
+	if count > 0 {
 		_ = writer.Write([]string{}) // Empty line
 	}
 
 	// Export alert configs if present
-	if len(data.AlertConfigs) > 0 {
+	count, err = eachSectionRecord(s.query(ctx, data.queries.alertConfigs), data.AlertConfigs, func() {
 		alertConfigs := s.localization.T(context.Background(), userLang, "export_alert_configurations")
 		alertType := s.localization.T(context.Background(), userLang, "export_alert_type")
 		condition := s.localization.T(context.Background(), userLang, "export_condition")
@@ -299,21 +904,24 @@ func (s *ExportService) exportToCSV(data *ExportData, userLang string) (*bytes.B
 
 		_ = writer.Write([]string{alertConfigs})
 		_ = writer.Write([]string{alertType, condition, threshold, isActive, createdAt})
-
-		for _, alert := range data.AlertConfigs {
-			_ = writer.Write([]string{
-				alert.AlertType.String(),
-				alert.Condition,
-				fmt.Sprintf("%.1f", alert.Threshold),
-				strconv.FormatBool(alert.IsActive),
-				alert.CreatedAt.Format(time.RFC3339),
-			})
-		}
+	}, func(alert *models.AlertConfig) {
+		_ = writer.Write([]string{
+			alert.AlertType.String(),
+			alert.Condition,
+			weather.FormatDecimal(alert.Threshold, 1),
+			strconv.FormatBool(alert.IsActive),
+			alert.CreatedAt.Format(time.RFC3339),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read alert configs: %w", err)
+	}
+	if count > 0 {
 		_ = writer.Write([]string{}) // Empty line
 	}
 
 	// Export triggered alerts if present
-	if len(data.TriggeredAlerts) > 0 {
+	count, err = eachSectionRecord(s.query(ctx, data.queries.triggeredAlerts), data.TriggeredAlerts, func() {
 		triggeredAlerts := s.localization.T(context.Background(), userLang, "export_triggered_alerts")
 		alertType := s.localization.T(context.Background(), userLang, "export_alert_type")
 		severity := s.localization.T(context.Background(), userLang, "export_severity")
@@ -321,39 +929,65 @@ func (s *ExportService) exportToCSV(data *ExportData, userLang string) (*bytes.B
 		value := s.localization.T(context.Background(), userLang, "export_value")
 		threshold := s.localization.T(context.Background(), userLang, "export_threshold")
 		isResolved := s.localization.T(context.Background(), userLang, "export_is_resolved")
+		status := s.localization.T(context.Background(), userLang, "export_status")
 		createdAt := s.localization.T(context.Background(), userLang, "export_created_at")
 
 		_ = writer.Write([]string{triggeredAlerts})
-		_ = writer.Write([]string{alertType, severity, title, value, threshold, isResolved, createdAt})
-
-		for _, alert := range data.TriggeredAlerts {
-			_ = writer.Write([]string{
-				alert.AlertType.String(),
-				alert.Severity.String(),
-				alert.Title,
-				fmt.Sprintf("%.1f", alert.Value),
-				fmt.Sprintf("%.1f", alert.Threshold),
-				strconv.FormatBool(alert.IsResolved),
-				alert.CreatedAt.Format(time.RFC3339),
-			})
-		}
+		_ = writer.Write([]string{alertType, severity, title, value, threshold, isResolved, status, createdAt})
+	}, func(alert *models.EnvironmentalAlert) {
+		_ = writer.Write([]string{
+			alert.AlertType.String(),
+			alert.Severity.String(),
+			alert.Title,
+			weather.FormatDecimal(alert.Value, 1),
+			weather.FormatDecimal(alert.Threshold, 1),
+			strconv.FormatBool(alert.IsResolved),
+			s.localization.T(context.Background(), userLang, alertStatusKey(alert)),
+			alert.CreatedAt.Format(time.RFC3339),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read triggered alerts: %w", err)
 	}
-
-	writer.Flush()
-	if err := writer.Error(); err != nil {
-		return nil, "", err
+	if count > 0 {
+		_ = writer.Write([]string{}) // Empty line
 	}
 
-	filename := fmt.Sprintf("shopogoda_%s_%s_%s.csv",
-		data.Type,
-		data.User.Username,
-		data.ExportedAt.Format("2006-01-02"))
+	// Export admin actions if present
+	_, err = eachSectionRecord(s.query(ctx, data.queries.auditLogs), data.AuditLogs, func() {
+		auditLog := s.localization.T(context.Background(), userLang, "export_audit_log")
+		action := s.localization.T(context.Background(), userLang, "export_action")
+		actorID := s.localization.T(context.Background(), userLang, "export_actor_id")
+		targetID := s.localization.T(context.Background(), userLang, "export_target_id")
+		details := s.localization.T(context.Background(), userLang, "export_details")
+		ip := s.localization.T(context.Background(), userLang, "export_ip")
+		createdAt := s.localization.T(context.Background(), userLang, "export_created_at")
 
-	return &buffer, filename, nil
+		_ = writer.Write([]string{auditLog})
+		_ = writer.Write([]string{action, actorID, targetID, details, ip, createdAt})
+	}, func(entry *models.AuditLog) {
+		_ = writer.Write([]string{
+			entry.Action,
+			strconv.FormatInt(entry.ActorID, 10),
+			strconv.FormatInt(entry.TargetID, 10),
+			string(entry.Metadata),
+			entry.IP,
+			entry.CreatedAt.Format(time.RFC3339),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	writer.Flush()
+	return writer.Error()
 }
 
-func (s *ExportService) exportToTXT(data *ExportData, userLang string) (*bytes.Buffer, string, error) {
-	var buffer bytes.Buffer
+// writeTXT writes the export as plain text, flushing the lines as they fill
+// the writer's buffer. Each section is headed by its number of records, so
+// they are counted before they are read.
+func (s *ExportService) writeTXT(ctx context.Context, w io.Writer, data *ExportData, userLang string) error {
+	buffer := bufio.NewWriter(w)
 
 	// Header
 	header := s.localization.T(context.Background(), userLang, "export_data_export_header")
@@ -362,14 +996,21 @@ func (s *ExportService) exportToTXT(data *ExportData, userLang string) (*bytes.B
 	exportedAt := s.localization.T(context.Background(), userLang, "export_exported_at")
 	userInformation := s.localization.T(context.Background(), userLang, "export_user_information")
 
-	fmt.Fprintf(&buffer, "%s\n", header)
+	fmt.Fprintf(buffer, "%s\n", header)
 	buffer.WriteString("=====================\n\n")
-	fmt.Fprintf(&buffer, "%s: %s\n", exportType, data.Type)
-	fmt.Fprintf(&buffer, "%s: %s (ID: %d)\n", username, data.User.Username, data.User.ID)
-	fmt.Fprintf(&buffer, "%s: %s\n\n", exportedAt, data.ExportedAt.Format(time.RFC3339))
+	fmt.Fprintf(buffer, "%s: %s\n", exportType, data.Type)
+	fmt.Fprintf(buffer, "%s: %s (ID: %d)\n", username, data.User.Username, data.User.ID)
+	fmt.Fprintf(buffer, "%s: %s\n", exportedAt, data.ExportedAt.Format(time.RFC3339))
+	if len(data.Coverage) > 0 {
+		coveredInterval := s.localization.T(context.Background(), userLang, "export_covered_interval")
+		for _, interval := range data.Coverage {
+			fmt.Fprintf(buffer, "%s (%s): %s\n", coveredInterval, interval.Dataset, interval.String())
+		}
+	}
+	buffer.WriteString("\n")
 
 	// User information
-	fmt.Fprintf(&buffer, "%s:\n", userInformation)
+	fmt.Fprintf(buffer, "%s:\n", userInformation)
 	buffer.WriteString("-----------------\n")
 	name := s.localization.T(context.Background(), userLang, "export_name")
 	language := s.localization.T(context.Background(), userLang, "export_language")
@@ -378,78 +1019,139 @@ func (s *ExportService) exportToTXT(data *ExportData, userLang string) (*bytes.B
 	location := s.localization.T(context.Background(), userLang, "export_location")
 	coordinates := s.localization.T(context.Background(), userLang, "export_coordinates")
 
-	fmt.Fprintf(&buffer, "%s: %s %s\n", name, data.User.FirstName, data.User.LastName)
-	fmt.Fprintf(&buffer, "%s: %s\n", language, data.User.Language)
-	fmt.Fprintf(&buffer, "%s: %s\n", units, data.User.Units)
-	fmt.Fprintf(&buffer, "%s: %s\n", timezone, data.User.Timezone)
+	fmt.Fprintf(buffer, "%s: %s %s\n", name, data.User.FirstName, data.User.LastName)
+	fmt.Fprintf(buffer, "%s: %s\n", language, data.User.Language)
+	fmt.Fprintf(buffer, "%s: %s\n", units, data.User.Units)
+	fmt.Fprintf(buffer, "%s: %s\n", timezone, data.User.Timezone)
 	if data.User.LocationName != "" {
-		fmt.Fprintf(&buffer, "%s: %s (%s, %s)\n", location, data.User.LocationName, data.User.City, data.User.Country)
-		fmt.Fprintf(&buffer, "%s: %.4f, %.4f\n", coordinates, data.User.Latitude, data.User.Longitude)
+		fmt.Fprintf(buffer, "%s: %s (%s, %s)\n", location, data.User.LocationName, data.User.City, data.User.Country)
+		if data.User.LocationApproximate {
+			approximate := s.localization.T(context.Background(), userLang, "location_approximate_suffix")
+			fmt.Fprintf(buffer, "%s: %.4f, %.4f %s\n", coordinates, data.User.Latitude, data.User.Longitude, approximate)
+		} else {
+			fmt.Fprintf(buffer, "%s: %.4f, %.4f\n", coordinates, data.User.Latitude, data.User.Longitude)
+		}
 	}
 	buffer.WriteString("\n")
 
 	// Weather data
-	if len(data.WeatherData) > 0 {
-		fmt.Fprintf(&buffer, "Weather Data (%d records):\n", len(data.WeatherData))
+	count, err := s.recordCount(ctx, data.queries.weatherData, len(data.WeatherData))
+	if err != nil {
+		return fmt.Errorf("failed to count weather data: %w", err)
+	}
+	if count > 0 {
+		fmt.Fprintf(buffer, "Weather Data (%d records):\n", count)
 		buffer.WriteString("----------------------------\n")
-		for _, weather := range data.WeatherData {
-			fmt.Fprintf(&buffer, "Date: %s\n", weather.Timestamp.Format("2006-01-02 15:04:05 UTC"))
-			fmt.Fprintf(&buffer, "  Temperature: %.1f°C, Humidity: %d%%\n", weather.Temperature, weather.Humidity)
-			fmt.Fprintf(&buffer, "  Pressure: %.1fhPa, Wind: %.1fkm/h at %d°\n", weather.Pressure, weather.WindSpeed, weather.WindDegree)
-			fmt.Fprintf(&buffer, "  Visibility: %.1fkm, UV Index: %.1f\n", weather.Visibility, weather.UVIndex)
-			fmt.Fprintf(&buffer, "  Conditions: %s, AQI: %d\n\n", weather.Description, weather.AQI)
+		err := eachRecord(s.query(ctx, data.queries.weatherData), data.WeatherData, func(record *models.WeatherRecord) {
+			fmt.Fprintf(buffer, "Date: %s\n", record.Timestamp.Format("2006-01-02 15:04:05 UTC"))
+			fmt.Fprintf(buffer, "  Temperature: %s, Humidity: %s\n",
+				weather.FormatTemp(record.Temperature, weather.UnitsMetric), weather.FormatPercent(float64(record.Humidity)))
+			fmt.Fprintf(buffer, "  Pressure: %s, Wind: %s at %d°\n",
+				weather.FormatPressure(record.Pressure, weather.UnitsMetric), weather.FormatSpeed(record.WindSpeed, weather.UnitsMetric), record.WindDegree)
+			fmt.Fprintf(buffer, "  Visibility: %s, UV Index: %s\n", weather.FormatVisibility(record.Visibility, weather.UnitsMetric), weather.FormatUV(record.UVIndex))
+			fmt.Fprintf(buffer, "  Conditions: %s, AQI: %s\n", record.Description, weather.FormatAQI(float64(record.AQI)))
+			source := "provider"
+			if record.FromCache {
+				source = "cache"
+			}
+			fmt.Fprintf(buffer, "  Data as of: %s (%s)\n", record.Timestamp.UTC().Format("2006-01-02 15:04:05 UTC"), source)
+			buffer.WriteString("\n")
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read weather data: %w", err)
 		}
 		buffer.WriteString("\n")
 	}
 
 	// Subscriptions
-	if len(data.Subscriptions) > 0 {
-		fmt.Fprintf(&buffer, "Subscriptions (%d active):\n", len(data.Subscriptions))
+	count, err = s.recordCount(ctx, data.queries.subscriptions, len(data.Subscriptions))
+	if err != nil {
+		return fmt.Errorf("failed to count subscriptions: %w", err)
+	}
+	if count > 0 {
+		fmt.Fprintf(buffer, "Subscriptions (%d active):\n", count)
 		buffer.WriteString("---------------------------\n")
-		for _, sub := range data.Subscriptions {
-			fmt.Fprintf(&buffer, "Type: %s\n", sub.SubscriptionType.String())
-			fmt.Fprintf(&buffer, "  Frequency: %s\n", sub.Frequency.String())
-			fmt.Fprintf(&buffer, "  Time: %s\n", sub.TimeOfDay)
-			fmt.Fprintf(&buffer, "  Active: %t\n", sub.IsActive)
-			fmt.Fprintf(&buffer, "  Created: %s\n\n", sub.CreatedAt.Format("2006-01-02 15:04:05 UTC"))
+		err := eachRecord(s.query(ctx, data.queries.subscriptions), data.Subscriptions, func(sub *models.Subscription) {
+			fmt.Fprintf(buffer, "Type: %s\n", sub.SubscriptionType.String())
+			fmt.Fprintf(buffer, "  Frequency: %s\n", sub.Frequency.String())
+			fmt.Fprintf(buffer, "  Time: %s\n", sub.TimeOfDay)
+			fmt.Fprintf(buffer, "  Active: %t\n", sub.IsActive)
+			fmt.Fprintf(buffer, "  Created: %s\n\n", sub.CreatedAt.Format("2006-01-02 15:04:05 UTC"))
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read subscriptions: %w", err)
 		}
 		buffer.WriteString("\n")
 	}
 
 	// Alert configs
-	if len(data.AlertConfigs) > 0 {
-		fmt.Fprintf(&buffer, "Alert Configurations (%d configured):\n", len(data.AlertConfigs))
+	count, err = s.recordCount(ctx, data.queries.alertConfigs, len(data.AlertConfigs))
+	if err != nil {
+		return fmt.Errorf("failed to count alert configs: %w", err)
+	}
+	if count > 0 {
+		fmt.Fprintf(buffer, "Alert Configurations (%d configured):\n", count)
 		buffer.WriteString("---------------------------------------\n")
-		for _, alert := range data.AlertConfigs {
-			fmt.Fprintf(&buffer, "Type: %s\n", alert.AlertType.String())
-			fmt.Fprintf(&buffer, "  Condition: %s\n", alert.Condition)
-			fmt.Fprintf(&buffer, "  Threshold: %.1f\n", alert.Threshold)
-			fmt.Fprintf(&buffer, "  Active: %t\n", alert.IsActive)
-			fmt.Fprintf(&buffer, "  Created: %s\n\n", alert.CreatedAt.Format("2006-01-02 15:04:05 UTC"))
+		err := eachRecord(s.query(ctx, data.queries.alertConfigs), data.AlertConfigs, func(alert *models.AlertConfig) {
+			fmt.Fprintf(buffer, "Type: %s\n", alert.AlertType.String())
+			fmt.Fprintf(buffer, "  Condition: %s\n", alert.Condition)
+			fmt.Fprintf(buffer, "  Threshold: %s\n", weather.FormatDecimal(alert.Threshold, 1))
+			fmt.Fprintf(buffer, "  Active: %t\n", alert.IsActive)
+			fmt.Fprintf(buffer, "  Created: %s\n\n", alert.CreatedAt.Format("2006-01-02 15:04:05 UTC"))
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read alert configs: %w", err)
 		}
 		buffer.WriteString("\n")
 	}
 
 	// Triggered alerts
-	if len(data.TriggeredAlerts) > 0 {
-		fmt.Fprintf(&buffer, "Triggered Alerts (%d alerts):\n", len(data.TriggeredAlerts))
+	count, err = s.recordCount(ctx, data.queries.triggeredAlerts, len(data.TriggeredAlerts))
+	if err != nil {
+		return fmt.Errorf("failed to count triggered alerts: %w", err)
+	}
+	if count > 0 {
+		fmt.Fprintf(buffer, "Triggered Alerts (%d alerts):\n", count)
 		buffer.WriteString("-------------------------------\n")
-		for _, alert := range data.TriggeredAlerts {
-			fmt.Fprintf(&buffer, "Alert: %s\n", alert.Title)
-			fmt.Fprintf(&buffer, "  Type: %s, Severity: %s\n", alert.AlertType.String(), alert.Severity.String())
-			fmt.Fprintf(&buffer, "  Value: %.1f (Threshold: %.1f)\n", alert.Value, alert.Threshold)
-			fmt.Fprintf(&buffer, "  Description: %s\n", alert.Description)
-			fmt.Fprintf(&buffer, "  Resolved: %t\n", alert.IsResolved)
-			fmt.Fprintf(&buffer, "  Triggered: %s\n\n", alert.CreatedAt.Format("2006-01-02 15:04:05 UTC"))
+		err := eachRecord(s.query(ctx, data.queries.triggeredAlerts), data.TriggeredAlerts, func(alert *models.EnvironmentalAlert) {
+			fmt.Fprintf(buffer, "Alert: %s\n", alert.Title)
+			fmt.Fprintf(buffer, "  Type: %s, Severity: %s\n", alert.AlertType.String(), alert.Severity.String())
+			fmt.Fprintf(buffer, "  Value: %s (Threshold: %s)\n", weather.FormatDecimal(alert.Value, 1), weather.FormatDecimal(alert.Threshold, 1))
+			fmt.Fprintf(buffer, "  Description: %s\n", alert.Description)
+			fmt.Fprintf(buffer, "  Resolved: %t\n", alert.IsResolved)
+			fmt.Fprintf(buffer, "  %s: %s\n",
+				s.localization.T(context.Background(), userLang, "export_status"),
+				s.localization.T(context.Background(), userLang, alertStatusKey(alert)))
+			fmt.Fprintf(buffer, "  Triggered: %s\n\n", alert.CreatedAt.Format("2006-01-02 15:04:05 UTC"))
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read triggered alerts: %w", err)
 		}
 	}
 
-	buffer.WriteString("End of Export\n")
+	// Admin actions
+	count, err = s.recordCount(ctx, data.queries.auditLogs, len(data.AuditLogs))
+	if err != nil {
+		return fmt.Errorf("failed to count audit log: %w", err)
+	}
+	if count > 0 {
+		fmt.Fprintf(buffer, "Admin Actions (%d entries):\n", count)
+		buffer.WriteString("-----------------------------\n")
+		err := eachRecord(s.query(ctx, data.queries.auditLogs), data.AuditLogs, func(entry *models.AuditLog) {
+			fmt.Fprintf(buffer, "Action: %s\n", entry.Action)
+			fmt.Fprintf(buffer, "  Admin: %d, Target: %d\n", entry.ActorID, entry.TargetID)
+			fmt.Fprintf(buffer, "  Details: %s\n", entry.Metadata)
+			if entry.IP != "" {
+				fmt.Fprintf(buffer, "  IP: %s\n", entry.IP)
+			}
+			fmt.Fprintf(buffer, "  Time: %s\n\n", entry.CreatedAt.Format("2006-01-02 15:04:05 UTC"))
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read audit log: %w", err)
+		}
+	}
 
-	filename := fmt.Sprintf("shopogoda_%s_%s_%s.txt",
-		data.Type,
-		data.User.Username,
-		data.ExportedAt.Format("2006-01-02"))
+	buffer.WriteString("End of Export\n")
 
-	return &buffer, filename, nil
+	return buffer.Flush()
 }
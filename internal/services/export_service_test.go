@@ -1,12 +1,15 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"testing"
 	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -29,7 +32,17 @@ func TestNewExportService(t *testing.T) {
 	assert.NotNil(t, service.localization)
 }
 
-func TestExportService_GetWeatherData(t *testing.T) {
+// readExportRecords reads every record an export query selects
+func readExportRecords[T any](t *testing.T, service *ExportService, query exportQuery) []T {
+	var records []T
+	err := eachRecord(service.query(context.Background(), query), nil, func(record *T) {
+		records = append(records, *record)
+	})
+	require.NoError(t, err)
+	return records
+}
+
+func TestExportService_WeatherDataQuery(t *testing.T) {
 	mockDB := helpers.NewMockDB(t)
 	defer func() { _ = mockDB.Close() }()
 	logger := helpers.NewSilentTestLogger()
@@ -51,13 +64,12 @@ func TestExportService_GetWeatherData(t *testing.T) {
 			now.Add(-10*time.Hour),
 		)
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "weather_data" WHERE user_id = \$1 AND timestamp >= \$2 ORDER BY timestamp DESC LIMIT \$3`).
-			WithArgs(userID, helpers.AnyTime{}, 1000).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "weather_records" WHERE user_id = \$1 AND timestamp >= \$2 AND timestamp <= \$3 ORDER BY timestamp DESC LIMIT \$4`).
+			WithArgs(userID, thirtyDaysAgo, now, 1000).
 			WillReturnRows(rows)
 
-		weatherData, err := service.getWeatherData(context.Background(), userID)
+		weatherData := readExportRecords[models.WeatherRecord](t, service, weatherDataQuery(userID, thirtyDaysAgo, now))
 
-		require.NoError(t, err)
 		assert.Len(t, weatherData, 1)
 		assert.Equal(t, userID, weatherData[0].UserID)
 		assert.Equal(t, 20.5, weatherData[0].Temperature)
@@ -68,19 +80,18 @@ func TestExportService_GetWeatherData(t *testing.T) {
 	t.Run("no weather data found", func(t *testing.T) {
 		rows := mockDB.Mock.NewRows([]string{"id", "user_id", "location_name", "temperature"})
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "weather_data" WHERE user_id = \$1 AND timestamp >= \$2 ORDER BY timestamp DESC LIMIT \$3`).
-			WithArgs(userID, helpers.AnyTime{}, 1000).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "weather_records" WHERE user_id = \$1 AND timestamp >= \$2 AND timestamp <= \$3 ORDER BY timestamp DESC LIMIT \$4`).
+			WithArgs(userID, thirtyDaysAgo, now, 1000).
 			WillReturnRows(rows)
 
-		weatherData, err := service.getWeatherData(context.Background(), userID)
+		weatherData := readExportRecords[models.WeatherRecord](t, service, weatherDataQuery(userID, thirtyDaysAgo, now))
 
-		require.NoError(t, err)
 		assert.Len(t, weatherData, 0)
 		mockDB.ExpectationsWereMet(t)
 	})
 }
 
-func TestExportService_GetAlertConfigs(t *testing.T) {
+func TestExportService_AlertConfigsQuery(t *testing.T) {
 	mockDB := helpers.NewMockDB(t)
 	defer func() { _ = mockDB.Close() }()
 	logger := helpers.NewSilentTestLogger()
@@ -101,22 +112,23 @@ func TestExportService_GetAlertConfigs(t *testing.T) {
 			WithArgs(userID).
 			WillReturnRows(rows)
 
-		alertConfigs, err := service.getAlertConfigs(context.Background(), userID)
+		alertConfigs := readExportRecords[models.AlertConfig](t, service, alertConfigsQuery(userID))
 
-		require.NoError(t, err)
 		assert.Len(t, alertConfigs, 1)
 		assert.Equal(t, userID, alertConfigs[0].UserID)
 		mockDB.ExpectationsWereMet(t)
 	})
 }
 
-func TestExportService_GetTriggeredAlerts(t *testing.T) {
+func TestExportService_TriggeredAlertsQuery(t *testing.T) {
 	mockDB := helpers.NewMockDB(t)
 	defer func() { _ = mockDB.Close() }()
 	logger := helpers.NewSilentTestLogger()
 	service := NewExportService(mockDB.DB, logger, nil)
 
 	userID := int64(123)
+	now := time.Now().UTC()
+	ninetyDaysAgo := now.AddDate(0, 0, -90)
 
 	t.Run("get triggered alerts successfully", func(t *testing.T) {
 		rows := mockDB.Mock.NewRows([]string{
@@ -129,20 +141,19 @@ func TestExportService_GetTriggeredAlerts(t *testing.T) {
 			35.0, 30.0, false, time.Now(), time.Now(),
 		)
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "environmental_alerts" WHERE user_id = \$1 AND created_at >= \$2 ORDER BY created_at DESC`).
-			WithArgs(userID, helpers.AnyTime{}).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "environmental_alerts" WHERE user_id = \$1 AND created_at >= \$2 AND created_at <= \$3 ORDER BY created_at DESC`).
+			WithArgs(userID, ninetyDaysAgo, now).
 			WillReturnRows(rows)
 
-		triggeredAlerts, err := service.getTriggeredAlerts(context.Background(), userID)
+		triggeredAlerts := readExportRecords[models.EnvironmentalAlert](t, service, triggeredAlertsQuery(userID, ninetyDaysAgo, now))
 
-		require.NoError(t, err)
 		assert.Len(t, triggeredAlerts, 1)
 		assert.Equal(t, userID, triggeredAlerts[0].UserID)
 		mockDB.ExpectationsWereMet(t)
 	})
 }
 
-func TestExportService_GetSubscriptions(t *testing.T) {
+func TestExportService_SubscriptionsQuery(t *testing.T) {
 	mockDB := helpers.NewMockDB(t)
 	defer func() { _ = mockDB.Close() }()
 	logger := helpers.NewSilentTestLogger()
@@ -163,9 +174,8 @@ func TestExportService_GetSubscriptions(t *testing.T) {
 			WithArgs(userID).
 			WillReturnRows(rows)
 
-		subscriptions, err := service.getSubscriptions(context.Background(), userID)
+		subscriptions := readExportRecords[models.Subscription](t, service, subscriptionsQuery(userID))
 
-		require.NoError(t, err)
 		assert.Len(t, subscriptions, 1)
 		assert.Equal(t, userID, subscriptions[0].UserID)
 		mockDB.ExpectationsWereMet(t)
@@ -181,7 +191,7 @@ func TestExportService_ExportToJSON(t *testing.T) {
 		Username: "testuser",
 	}
 
-	weatherData := []models.WeatherData{
+	weatherData := []models.WeatherRecord{
 		{
 			UserID:      123,
 			Temperature: 20.5,
@@ -199,10 +209,12 @@ func TestExportService_ExportToJSON(t *testing.T) {
 	}
 
 	t.Run("export to JSON successfully", func(t *testing.T) {
-		buffer, filename, err := service.exportToJSON(exportData, "en-US")
+		var buffer bytes.Buffer
+		err := service.writeJSON(context.Background(), &buffer, exportData)
+		filename := exportFilename(exportData, "json")
 
 		require.NoError(t, err)
-		assert.NotNil(t, buffer)
+		assert.NotZero(t, buffer.Len())
 		assert.Contains(t, filename, "shopogoda_weather_testuser")
 		assert.Contains(t, filename, ".json")
 
@@ -225,7 +237,7 @@ func TestExportService_ExportToCSV(t *testing.T) {
 		Username: "testuser",
 	}
 
-	weatherData := []models.WeatherData{
+	weatherData := []models.WeatherRecord{
 		{
 			UserID:      123,
 			Temperature: 20.5,
@@ -244,10 +256,12 @@ func TestExportService_ExportToCSV(t *testing.T) {
 	}
 
 	t.Run("export to CSV successfully", func(t *testing.T) {
-		buffer, filename, err := service.exportToCSV(exportData, "en-US")
+		var buffer bytes.Buffer
+		err := service.writeCSV(context.Background(), &buffer, exportData, "en-US")
+		filename := exportFilename(exportData, "csv")
 
 		require.NoError(t, err)
-		assert.NotNil(t, buffer)
+		assert.NotZero(t, buffer.Len())
 		assert.Contains(t, filename, "shopogoda_weather_testuser")
 		assert.Contains(t, filename, ".csv")
 
@@ -257,6 +271,30 @@ func TestExportService_ExportToCSV(t *testing.T) {
 		assert.Contains(t, csvContent, "20.5") // Temperature
 	})
 
+	t.Run("export to CSV marks approximate location", func(t *testing.T) {
+		approximateUser := &models.User{
+			ID:                  123,
+			Username:            "testuser",
+			LocationName:        "Kyiv",
+			City:                "Kyiv",
+			Country:             "UA",
+			Latitude:            50.4501,
+			Longitude:           30.5234,
+			LocationApproximate: true,
+		}
+		var buffer bytes.Buffer
+		err := service.writeCSV(context.Background(), &buffer, &ExportData{
+			User:       approximateUser,
+			ExportedAt: time.Now().UTC(),
+			Format:     ExportFormatCSV,
+			Type:       ExportTypeAll,
+		}, "en-US")
+
+		require.NoError(t, err)
+		// Translations are not loaded here, so the marker shows as its key
+		assert.Contains(t, buffer.String(), "50.4501,30.5234,location_approximate_suffix")
+	})
+
 	t.Run("export to CSV with subscriptions", func(t *testing.T) {
 		subscriptions := []models.Subscription{
 			{
@@ -276,10 +314,12 @@ func TestExportService_ExportToCSV(t *testing.T) {
 			Type:          ExportTypeSubscriptions,
 		}
 
-		buffer, filename, err := service.exportToCSV(exportDataWithSubs, "en-US")
+		var buffer bytes.Buffer
+		err := service.writeCSV(context.Background(), &buffer, exportDataWithSubs, "en-US")
+		filename := exportFilename(exportDataWithSubs, "csv")
 
 		require.NoError(t, err)
-		assert.NotNil(t, buffer)
+		assert.NotZero(t, buffer.Len())
 		assert.Contains(t, filename, ".csv")
 
 		csvContent := buffer.String()
@@ -318,10 +358,12 @@ func TestExportService_ExportToCSV(t *testing.T) {
 			Type:            ExportTypeAlerts,
 		}
 
-		buffer, filename, err := service.exportToCSV(exportDataWithAlerts, "en-US")
+		var buffer bytes.Buffer
+		err := service.writeCSV(context.Background(), &buffer, exportDataWithAlerts, "en-US")
+		filename := exportFilename(exportDataWithAlerts, "csv")
 
 		require.NoError(t, err)
-		assert.NotNil(t, buffer)
+		assert.NotZero(t, buffer.Len())
 		assert.Contains(t, filename, ".csv")
 
 		csvContent := buffer.String()
@@ -347,7 +389,7 @@ func TestExportService_ExportToTXT(t *testing.T) {
 		Timezone:  "UTC",
 	}
 
-	weatherData := []models.WeatherData{
+	weatherData := []models.WeatherRecord{
 		{
 			UserID:      123,
 			Temperature: 20.5,
@@ -378,10 +420,12 @@ func TestExportService_ExportToTXT(t *testing.T) {
 	}
 
 	t.Run("export to TXT successfully", func(t *testing.T) {
-		buffer, filename, err := service.exportToTXT(exportData, "en-US")
+		var buffer bytes.Buffer
+		err := service.writeTXT(context.Background(), &buffer, exportData, "en-US")
+		filename := exportFilename(exportData, "txt")
 
 		require.NoError(t, err)
-		assert.NotNil(t, buffer)
+		assert.NotZero(t, buffer.Len())
 		assert.Contains(t, filename, "shopogoda_all_testuser")
 		assert.Contains(t, filename, ".txt")
 
@@ -413,10 +457,12 @@ func TestExportService_ExportToTXT(t *testing.T) {
 			Type:         ExportTypeAlerts,
 		}
 
-		buffer, filename, err := service.exportToTXT(exportDataWithAlerts, "en-US")
+		var buffer bytes.Buffer
+		err := service.writeTXT(context.Background(), &buffer, exportDataWithAlerts, "en-US")
+		filename := exportFilename(exportDataWithAlerts, "txt")
 
 		require.NoError(t, err)
-		assert.NotNil(t, buffer)
+		assert.NotZero(t, buffer.Len())
 		assert.Contains(t, filename, ".txt")
 
 		txtContent := buffer.String()
@@ -447,10 +493,12 @@ func TestExportService_ExportToTXT(t *testing.T) {
 			Type:            ExportTypeAlerts,
 		}
 
-		buffer, filename, err := service.exportToTXT(exportDataWithTriggered, "en-US")
+		var buffer bytes.Buffer
+		err := service.writeTXT(context.Background(), &buffer, exportDataWithTriggered, "en-US")
+		filename := exportFilename(exportDataWithTriggered, "txt")
 
 		require.NoError(t, err)
-		assert.NotNil(t, buffer)
+		assert.NotZero(t, buffer.Len())
 		assert.Contains(t, filename, ".txt")
 
 		txtContent := buffer.String()
@@ -475,7 +523,7 @@ func TestExportService_ExportUserData(t *testing.T) {
 		// Mock user query
 		userRows := mockDB.Mock.NewRows([]string{"id", "username"})
 		userRows.AddRow(userID, "testuser")
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(userID, 1).
 			WillReturnRows(userRows)
 
@@ -485,9 +533,11 @@ func TestExportService_ExportUserData(t *testing.T) {
 			"wind_speed", "wind_degree", "visibility", "uv_index", "description",
 			"aqi", "co", "no", "no2", "o3", "so2", "pm25", "pm10", "nh3", "timestamp",
 		})
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "weather_data"`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "weather_records"`).
 			WillReturnRows(weatherRows)
 
+		expectExportCursorSave(mockDB, userID, ExportTypeWeatherData, helpers.AnyTime{})
+
 		buffer, filename, err := service.ExportUserData(
 			context.Background(),
 			userID,
@@ -497,7 +547,7 @@ func TestExportService_ExportUserData(t *testing.T) {
 		)
 
 		require.NoError(t, err)
-		assert.NotNil(t, buffer)
+		assert.NotZero(t, buffer.Len())
 		assert.Contains(t, filename, ".json")
 		mockDB.ExpectationsWereMet(t)
 	})
@@ -506,7 +556,7 @@ func TestExportService_ExportUserData(t *testing.T) {
 		// Mock user query
 		userRows := mockDB.Mock.NewRows([]string{"id", "username"})
 		userRows.AddRow(userID, "testuser")
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(userID, 1).
 			WillReturnRows(userRows)
 
@@ -526,20 +576,7 @@ func TestExportService_ExportUserData(t *testing.T) {
 	})
 
 	t.Run("unsupported export format", func(t *testing.T) {
-		// Mock user query
-		userRows := mockDB.Mock.NewRows([]string{"id", "username"})
-		userRows.AddRow(userID, "testuser")
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
-			WithArgs(userID, 1).
-			WillReturnRows(userRows)
-
-		// Mock weather data query
-		weatherRows := mockDB.Mock.NewRows([]string{
-			"id", "user_id", "location_name", "temperature",
-		})
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "weather_data"`).
-			WillReturnRows(weatherRows)
-
+		// The format is checked before any data is loaded
 		buffer, filename, err := service.ExportUserData(
 			context.Background(),
 			userID,
@@ -559,7 +596,7 @@ func TestExportService_ExportUserData(t *testing.T) {
 		// Mock user query
 		userRows := mockDB.Mock.NewRows([]string{"id", "username"})
 		userRows.AddRow(userID, "testuser")
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(userID, 1).
 			WillReturnRows(userRows)
 
@@ -573,6 +610,8 @@ func TestExportService_ExportUserData(t *testing.T) {
 		mockDB.Mock.ExpectQuery(`SELECT \* FROM "environmental_alerts"`).
 			WillReturnRows(triggeredRows)
 
+		expectExportCursorSave(mockDB, userID, ExportTypeAlerts, helpers.AnyTime{})
+
 		buffer, filename, err := service.ExportUserData(
 			context.Background(),
 			userID,
@@ -582,7 +621,7 @@ func TestExportService_ExportUserData(t *testing.T) {
 		)
 
 		require.NoError(t, err)
-		assert.NotNil(t, buffer)
+		assert.NotZero(t, buffer.Len())
 		assert.Contains(t, filename, ".csv")
 		mockDB.ExpectationsWereMet(t)
 	})
@@ -591,14 +630,16 @@ func TestExportService_ExportUserData(t *testing.T) {
 		// Mock user query
 		userRows := mockDB.Mock.NewRows([]string{"id", "username"})
 		userRows.AddRow(userID, "testuser")
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(userID, 1).
 			WillReturnRows(userRows)
 
-		// Mock subscriptions query
-		subRows := mockDB.Mock.NewRows([]string{"id", "user_id", "notification_type", "frequency"})
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "subscriptions"`).
-			WillReturnRows(subRows)
+		// The subscriptions are counted to head their section; none are read
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM \(SELECT \* FROM "subscriptions" WHERE user_id = \$1\) AS records`).
+			WithArgs(userID).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(0))
+
+		expectExportCursorSave(mockDB, userID, ExportTypeSubscriptions, helpers.AnyTime{})
 
 		buffer, filename, err := service.ExportUserData(
 			context.Background(),
@@ -609,7 +650,7 @@ func TestExportService_ExportUserData(t *testing.T) {
 		)
 
 		require.NoError(t, err)
-		assert.NotNil(t, buffer)
+		assert.NotZero(t, buffer.Len())
 		assert.Contains(t, filename, ".txt")
 		mockDB.ExpectationsWereMet(t)
 	})
@@ -618,15 +659,20 @@ func TestExportService_ExportUserData(t *testing.T) {
 		// Mock user query
 		userRows := mockDB.Mock.NewRows([]string{"id", "username"})
 		userRows.AddRow(userID, "testuser")
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(userID, 1).
 			WillReturnRows(userRows)
 
 		// Mock weather data query
 		weatherRows := mockDB.Mock.NewRows([]string{"id", "user_id", "location_name", "temperature"})
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "weather_data"`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "weather_records"`).
 			WillReturnRows(weatherRows)
 
+		// The records are read in the order of the document
+		subRows := mockDB.Mock.NewRows([]string{"id", "user_id", "notification_type"})
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "subscriptions"`).
+			WillReturnRows(subRows)
+
 		// Mock alert configs query
 		alertRows := mockDB.Mock.NewRows([]string{"id", "user_id", "alert_type", "condition", "threshold"})
 		mockDB.Mock.ExpectQuery(`SELECT \* FROM "alert_configs"`).
@@ -637,10 +683,14 @@ func TestExportService_ExportUserData(t *testing.T) {
 		mockDB.Mock.ExpectQuery(`SELECT \* FROM "environmental_alerts"`).
 			WillReturnRows(triggeredRows)
 
-		// Mock subscriptions query
-		subRows := mockDB.Mock.NewRows([]string{"id", "user_id", "notification_type"})
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "subscriptions"`).
-			WillReturnRows(subRows)
+		// Admin actions on the user are part of all data
+		auditRows := mockDB.Mock.NewRows([]string{"actor_id", "action", "target_id", "metadata", "ip"}).
+			AddRow(1, AuditRoleChanged, userID, []byte(`{"new_role":2,"old_role":1}`), "149.154.167.220")
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "audit_logs" WHERE \(actor_id = \$1 OR target_id = \$2\)`).
+			WithArgs(userID, userID, helpers.AnyTime{}, helpers.AnyTime{}).
+			WillReturnRows(auditRows)
+
+		expectExportCursorSave(mockDB, userID, ExportTypeAll, helpers.AnyTime{})
 
 		buffer, filename, err := service.ExportUserData(
 			context.Background(),
@@ -651,8 +701,10 @@ func TestExportService_ExportUserData(t *testing.T) {
 		)
 
 		require.NoError(t, err)
-		assert.NotNil(t, buffer)
+		assert.NotZero(t, buffer.Len())
 		assert.Contains(t, filename, ".json")
+		assert.Contains(t, buffer.String(), `"action": "role_changed"`)
+		assert.Contains(t, buffer.String(), `"ip": "149.154.167.220"`)
 		mockDB.ExpectationsWereMet(t)
 	})
 
@@ -676,6 +728,84 @@ func TestExportService_ExportUserData(t *testing.T) {
 	})
 }
 
+func TestExportService_StreamExportUserData(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	userID := int64(123)
+
+	expectSubscriptionsExport := func(mockDB *helpers.MockDB) {
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
+			WithArgs(userID, 1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id", "username"}).AddRow(userID, "testuser"))
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "subscriptions"`).
+			WithArgs(userID).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id", "user_id", "subscription_type", "time_of_day"}).
+				AddRow(uuid.New(), userID, models.SubscriptionDaily, "08:00").
+				AddRow(uuid.New(), userID, models.SubscriptionWeekly, "09:00"))
+	}
+
+	t.Run("writes the file and records the export", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := NewExportService(mockDB.DB, logger, NewLocalizationService(logger))
+
+		expectSubscriptionsExport(mockDB)
+		expectExportCursorSave(mockDB, userID, ExportTypeSubscriptions, helpers.AnyTime{})
+
+		var buffer bytes.Buffer
+		err := service.StreamExportUserData(context.Background(), userID, ExportTypeSubscriptions, ExportFormatJSON, "en-US", &buffer)
+		require.NoError(t, err)
+
+		var data ExportData
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &data))
+		assert.Equal(t, "testuser", data.User.Username)
+		require.Len(t, data.Subscriptions, 2)
+		assert.Equal(t, "09:00", data.Subscriptions[1].TimeOfDay)
+		assert.Equal(t, ExportTypeSubscriptions, data.Type)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("failed write is not recorded", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := NewExportService(mockDB.DB, logger, NewLocalizationService(logger))
+
+		expectSubscriptionsExport(mockDB)
+
+		reader, writer := io.Pipe()
+		_ = reader.CloseWithError(errors.New("upload aborted"))
+		err := service.StreamExportUserData(context.Background(), userID, ExportTypeSubscriptions, ExportFormatCSV, "en-US", writer)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "upload aborted")
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestExportService_WriteJSONMatchesMarshal(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	service := NewExportService(nil, logger, NewLocalizationService(logger))
+
+	exportedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	data := &ExportData{
+		User: &models.User{ID: 123, Username: "testuser"},
+		WeatherData: []models.WeatherRecord{
+			{UserID: 123, Temperature: 20.5, Timestamp: exportedAt},
+			{UserID: 123, Temperature: 18, Timestamp: exportedAt.Add(-time.Hour)},
+		},
+		ExportedAt: exportedAt,
+		Format:     ExportFormatJSON,
+		Type:       ExportTypeAll,
+		Coverage:   []ExportInterval{{Dataset: "weather_data", From: exportedAt.AddDate(0, 0, -30), To: exportedAt}},
+	}
+
+	var buffer bytes.Buffer
+	require.NoError(t, service.writeJSON(context.Background(), &buffer, data))
+
+	// Encoding the records one at a time gives the same document as encoding it whole
+	expected, err := json.MarshalIndent(data, "", "  ")
+	require.NoError(t, err)
+	assert.Equal(t, string(expected)+"\n", buffer.String())
+}
+
 func TestExportConstants(t *testing.T) {
 	t.Run("export formats", func(t *testing.T) {
 		assert.Equal(t, ExportFormat("json"), ExportFormatJSON)
@@ -690,3 +820,419 @@ func TestExportConstants(t *testing.T) {
 		assert.Equal(t, ExportType("all"), ExportTypeAll)
 	})
 }
+
+// expectExportCursorSave expects the upsert recording a successful export
+func expectExportCursorSave(mockDB *helpers.MockDB, userID int64, exportType ExportType, exportedUntil any) {
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectExec(`INSERT INTO "export_cursors" .* ON CONFLICT \("user_id","export_type"\) DO UPDATE SET "last_exported_at"="excluded"\."last_exported_at","updated_at"="excluded"\."updated_at"`).
+		WithArgs(userID, string(exportType), exportedUntil, helpers.AnyTime{}).
+		WillReturnResult(helpers.NewResult(1, 1))
+	mockDB.Mock.ExpectCommit()
+}
+
+// exportWeatherRows builds weather rows stored at the given times. Observation
+// times lag behind, as they do for records served from cache.
+func exportWeatherRows(mockDB *helpers.MockDB, userID int64, storedAt ...time.Time) *sqlmock.Rows {
+	rows := mockDB.Mock.NewRows([]string{"id", "user_id", "temperature", "timestamp", "from_cache", "created_at"})
+	for i, ts := range storedAt {
+		rows.AddRow(uuid.New(), userID, 10.0+float64(i), ts.Add(-30*time.Minute), i%2 == 0, ts)
+	}
+	return rows
+}
+
+func TestExportService_ExportUserDataIncremental(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	userID := int64(123)
+
+	lastExport := time.Date(2025, 6, 5, 10, 0, 0, 0, time.UTC)
+	firstExport := time.Date(2025, 6, 12, 10, 0, 0, 0, time.UTC)
+	secondExport := time.Date(2025, 6, 19, 10, 0, 0, 0, time.UTC)
+
+	weatherQuery := `SELECT \* FROM "weather_records" WHERE user_id = \$1 AND created_at > \$2 AND created_at <= \$3 ORDER BY created_at ASC LIMIT \$4`
+	limitQuery := `SELECT "created_at" FROM "weather_records" WHERE user_id = \$1 AND created_at > \$2 AND created_at <= \$3 ORDER BY created_at ASC LIMIT \$4 OFFSET \$5`
+	completeQuery := `SELECT MAX\(created_at\) FROM "weather_records" WHERE user_id = \$1 AND created_at > \$2 AND created_at < \$3`
+	cursorQuery := `SELECT \* FROM "export_cursors" WHERE user_id = \$1 AND export_type = \$2 ORDER BY "export_cursors"\."user_id" LIMIT \$3`
+
+	expectUser := func(mockDB *helpers.MockDB) {
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
+			WithArgs(userID, 1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id", "username"}).AddRow(userID, "testuser"))
+	}
+	expectCursor := func(mockDB *helpers.MockDB, exportType ExportType, lastExportedAt time.Time) {
+		mockDB.Mock.ExpectQuery(cursorQuery).
+			WithArgs(userID, string(exportType), 1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"user_id", "export_type", "last_exported_at"}).
+				AddRow(userID, string(exportType), lastExportedAt))
+	}
+	// expectWithinLimit expects the check that the records in (since, until] fit the record limit
+	expectWithinLimit := func(mockDB *helpers.MockDB, since, until time.Time) {
+		mockDB.Mock.ExpectQuery(limitQuery).
+			WithArgs(userID, since, until, 1, exportRecordLimit-1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"created_at"}))
+	}
+	decode := func(t *testing.T, buffer interface{ Bytes() []byte }) ExportData {
+		var data ExportData
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &data))
+		return data
+	}
+
+	t.Run("consecutive exports have no gaps or duplicates", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := NewExportService(mockDB.DB, logger, NewLocalizationService(logger))
+
+		// First export picks up everything after the previous export, including a
+		// record captured exactly at the new upper bound
+		service.now = func() time.Time { return firstExport }
+		expectUser(mockDB)
+		expectCursor(mockDB, ExportTypeWeatherData, lastExport)
+		expectWithinLimit(mockDB, lastExport, firstExport)
+		mockDB.Mock.ExpectQuery(weatherQuery).
+			WithArgs(userID, lastExport, firstExport, exportRecordLimit).
+			WillReturnRows(exportWeatherRows(mockDB, userID, lastExport.Add(time.Hour), firstExport))
+		expectExportCursorSave(mockDB, userID, ExportTypeWeatherData, firstExport)
+
+		buffer, _, err := service.ExportUserDataIncremental(context.Background(), userID, ExportTypeWeatherData, ExportFormatJSON, "en-US")
+		require.NoError(t, err)
+
+		first := decode(t, buffer)
+		require.Len(t, first.Coverage, 1)
+		assert.Equal(t, ExportInterval{Dataset: "weather_data", From: lastExport, To: firstExport, Incremental: true}, first.Coverage[0])
+		require.Len(t, first.WeatherData, 2)
+		assert.Equal(t, lastExport.Add(30*time.Minute), first.WeatherData[0].Timestamp)
+		assert.True(t, first.WeatherData[0].FromCache)
+
+		// Second export starts exactly at the first export's upper bound (exclusive)
+		service.now = func() time.Time { return secondExport }
+		expectUser(mockDB)
+		expectCursor(mockDB, ExportTypeWeatherData, firstExport)
+		expectWithinLimit(mockDB, firstExport, secondExport)
+		mockDB.Mock.ExpectQuery(weatherQuery).
+			WithArgs(userID, firstExport, secondExport, exportRecordLimit).
+			WillReturnRows(exportWeatherRows(mockDB, userID, firstExport.Add(time.Minute)))
+		expectExportCursorSave(mockDB, userID, ExportTypeWeatherData, secondExport)
+
+		buffer, _, err = service.ExportUserDataIncremental(context.Background(), userID, ExportTypeWeatherData, ExportFormatJSON, "en-US")
+		require.NoError(t, err)
+
+		second := decode(t, buffer)
+		require.Len(t, second.Coverage, 1)
+		assert.Equal(t, first.Coverage[0].To, second.Coverage[0].From)
+		assert.Equal(t, secondExport, second.Coverage[0].To)
+		assert.Len(t, second.WeatherData, 1)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("truncated export ends at the last complete insertion time", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := NewExportService(mockDB.DB, logger, NewLocalizationService(logger))
+		service.now = func() time.Time { return firstExport }
+
+		timestamps := make([]time.Time, exportRecordLimit)
+		for i := range timestamps {
+			timestamps[i] = lastExport.Add(time.Duration(i+1) * time.Minute)
+		}
+		// The last two records share an insertion time; more may follow beyond the limit
+		timestamps[exportRecordLimit-1] = timestamps[exportRecordLimit-2]
+		completeUntil := timestamps[exportRecordLimit-3]
+
+		expectUser(mockDB)
+		expectCursor(mockDB, ExportTypeWeatherData, lastExport)
+		mockDB.Mock.ExpectQuery(limitQuery).
+			WithArgs(userID, lastExport, firstExport, 1, exportRecordLimit-1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"created_at"}).AddRow(timestamps[exportRecordLimit-1]))
+		mockDB.Mock.ExpectQuery(completeQuery).
+			WithArgs(userID, lastExport, timestamps[exportRecordLimit-1]).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"max"}).AddRow(completeUntil))
+		mockDB.Mock.ExpectQuery(weatherQuery).
+			WithArgs(userID, lastExport, completeUntil, exportRecordLimit).
+			WillReturnRows(exportWeatherRows(mockDB, userID, timestamps[:exportRecordLimit-2]...))
+		expectExportCursorSave(mockDB, userID, ExportTypeWeatherData, completeUntil)
+
+		buffer, _, err := service.ExportUserDataIncremental(context.Background(), userID, ExportTypeWeatherData, ExportFormatJSON, "en-US")
+		require.NoError(t, err)
+
+		data := decode(t, buffer)
+		assert.Len(t, data.WeatherData, exportRecordLimit-2)
+		require.Len(t, data.Coverage, 1)
+		assert.Equal(t, completeUntil, data.Coverage[0].To)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("limit filled by one insertion time ends at it", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := NewExportService(mockDB.DB, logger, NewLocalizationService(logger))
+		service.now = func() time.Time { return firstExport }
+
+		storedAt := lastExport.Add(time.Minute)
+		expectUser(mockDB)
+		expectCursor(mockDB, ExportTypeWeatherData, lastExport)
+		mockDB.Mock.ExpectQuery(limitQuery).
+			WithArgs(userID, lastExport, firstExport, 1, exportRecordLimit-1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"created_at"}).AddRow(storedAt))
+		mockDB.Mock.ExpectQuery(completeQuery).
+			WithArgs(userID, lastExport, storedAt).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"max"}).AddRow(nil))
+
+		export, err := service.PrepareExport(context.Background(), userID, ExportTypeWeatherData, ExportFormatJSON, "en-US", true, nil)
+		require.NoError(t, err)
+		assert.Equal(t, storedAt, export.until)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("all data shares one interval across datasets", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := NewExportService(mockDB.DB, logger, NewLocalizationService(logger))
+		service.now = func() time.Time { return firstExport }
+
+		expectUser(mockDB)
+		expectCursor(mockDB, ExportTypeAll, lastExport)
+		expectWithinLimit(mockDB, lastExport, firstExport)
+		mockDB.Mock.ExpectQuery(weatherQuery).
+			WithArgs(userID, lastExport, firstExport, exportRecordLimit).
+			WillReturnRows(exportWeatherRows(mockDB, userID))
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "environmental_alerts" WHERE user_id = \$1 AND created_at > \$2 AND created_at <= \$3 ORDER BY created_at ASC`).
+			WithArgs(userID, lastExport, firstExport).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id", "user_id", "title", "created_at"}).
+				AddRow(uuid.New(), userID, "High Temperature", lastExport.Add(time.Hour)))
+		expectExportCursorSave(mockDB, userID, ExportTypeAll, firstExport)
+
+		buffer, _, err := service.ExportUserDataIncremental(context.Background(), userID, ExportTypeAll, ExportFormatCSV, "en-US")
+		require.NoError(t, err)
+
+		csvContent := buffer.String()
+		assert.Contains(t, csvContent, "weather_data,\"(2025-06-05T10:00:00Z, 2025-06-12T10:00:00Z]\"")
+		assert.Contains(t, csvContent, "triggered_alerts,\"(2025-06-05T10:00:00Z, 2025-06-12T10:00:00Z]\"")
+		assert.Contains(t, csvContent, "High Temperature")
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("first incremental export starts at the retention window", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := NewExportService(mockDB.DB, logger, NewLocalizationService(logger))
+		service.now = func() time.Time { return firstExport }
+
+		windowStart := firstExport.AddDate(0, 0, -weatherExportWindowDays)
+
+		expectUser(mockDB)
+		mockDB.Mock.ExpectQuery(cursorQuery).
+			WithArgs(userID, string(ExportTypeWeatherData), 1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"user_id", "export_type", "last_exported_at"}))
+		expectWithinLimit(mockDB, windowStart, firstExport)
+		mockDB.Mock.ExpectQuery(weatherQuery).
+			WithArgs(userID, windowStart, firstExport, exportRecordLimit).
+			WillReturnRows(exportWeatherRows(mockDB, userID))
+		expectExportCursorSave(mockDB, userID, ExportTypeWeatherData, firstExport)
+
+		buffer, _, err := service.ExportUserDataIncremental(context.Background(), userID, ExportTypeWeatherData, ExportFormatJSON, "en-US")
+		require.NoError(t, err)
+
+		data := decode(t, buffer)
+		require.Len(t, data.Coverage, 1)
+		assert.Equal(t, windowStart, data.Coverage[0].From)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("prepared export moves the cursor only when committed", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := NewExportService(mockDB.DB, logger, NewLocalizationService(logger))
+		service.now = func() time.Time { return firstExport }
+
+		expectUser(mockDB)
+		expectCursor(mockDB, ExportTypeWeatherData, lastExport)
+		expectWithinLimit(mockDB, lastExport, firstExport)
+
+		export, err := service.PrepareExport(context.Background(), userID, ExportTypeWeatherData, ExportFormatJSON, "en-US", true, nil)
+		require.NoError(t, err)
+		// The records are only read while the file is written
+		mockDB.ExpectationsWereMet(t)
+
+		mockDB.Mock.ExpectQuery(weatherQuery).
+			WithArgs(userID, lastExport, firstExport, exportRecordLimit).
+			WillReturnRows(exportWeatherRows(mockDB, userID, lastExport.Add(time.Hour)))
+		var buffer bytes.Buffer
+		_, err = service.WriteExport(context.Background(), &buffer, export)
+		require.NoError(t, err)
+		assert.Len(t, decode(t, &buffer).WeatherData, 1)
+		// Nothing is recorded until the file is delivered
+		mockDB.ExpectationsWereMet(t)
+
+		expectExportCursorSave(mockDB, userID, ExportTypeWeatherData, firstExport)
+		service.CommitExport(context.Background(), export)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("subscriptions cannot be exported incrementally", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		service := NewExportService(mockDB.DB, logger, NewLocalizationService(logger))
+
+		expectUser(mockDB)
+
+		buffer, _, err := service.ExportUserDataIncremental(context.Background(), userID, ExportTypeSubscriptions, ExportFormatJSON, "en-US")
+
+		assert.Error(t, err)
+		assert.Nil(t, buffer)
+		assert.Contains(t, err.Error(), "incremental export not supported")
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestExportService_GetLastExportTime(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	logger := helpers.NewSilentTestLogger()
+	service := NewExportService(mockDB.DB, logger, nil)
+
+	userID := int64(123)
+	lastExport := time.Date(2025, 6, 5, 10, 0, 0, 0, time.UTC)
+
+	t.Run("previous export", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "export_cursors"`).
+			WithArgs(userID, "weather", 1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"user_id", "export_type", "last_exported_at"}).
+				AddRow(userID, "weather", lastExport))
+
+		result, err := service.GetLastExportTime(context.Background(), userID, ExportTypeWeatherData)
+
+		require.NoError(t, err)
+		require.NotNil(t, result)
+		assert.Equal(t, lastExport, *result)
+	})
+
+	t.Run("never exported", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "export_cursors"`).
+			WithArgs(userID, "alerts", 1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"user_id", "export_type", "last_exported_at"}))
+
+		result, err := service.GetLastExportTime(context.Background(), userID, ExportTypeAlerts)
+
+		require.NoError(t, err)
+		assert.Nil(t, result)
+	})
+
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestExportService_PrepareExportRange(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	userID := int64(123)
+	exportedAt := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	joinedAt := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+
+	weatherQuery := `SELECT \* FROM "weather_records" WHERE user_id = \$1 AND timestamp >= \$2 AND timestamp <= \$3 ORDER BY timestamp DESC LIMIT \$4`
+	alertsQuery := `SELECT \* FROM "environmental_alerts" WHERE user_id = \$1 AND created_at >= \$2 AND created_at <= \$3 ORDER BY created_at DESC`
+
+	newService := func(t *testing.T) (*ExportService, *helpers.MockDB) {
+		mockDB := helpers.NewMockDB(t)
+		t.Cleanup(func() { _ = mockDB.Close() })
+		service := NewExportService(mockDB.DB, logger, NewLocalizationService(logger))
+		service.now = func() time.Time { return exportedAt }
+		return service, mockDB
+	}
+	expectUser := func(mockDB *helpers.MockDB) {
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users"`).
+			WithArgs(userID, 1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id", "username", "created_at"}).AddRow(userID, "testuser", joinedAt))
+	}
+
+	t.Run("custom range filters every dataset and names the file", func(t *testing.T) {
+		service, mockDB := newService(t)
+		window, err := ParseExportRange("2026-01-01 2026-03-31")
+		require.NoError(t, err)
+
+		expectUser(mockDB)
+		export, err := service.PrepareExport(context.Background(), userID, ExportTypeAll, ExportFormatCSV, "en-US", false, &window)
+		require.NoError(t, err)
+		assert.Equal(t, "shopogoda_all_testuser_2026-01-01_2026-03-31.csv", export.Filename)
+
+		mockDB.Mock.ExpectQuery(weatherQuery).
+			WithArgs(userID, window.From, window.To, exportRecordLimit).
+			WillReturnRows(exportWeatherRows(mockDB, userID, time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)))
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "subscriptions"`).
+			WithArgs(userID).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}))
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "alert_configs"`).
+			WithArgs(userID).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}))
+		mockDB.Mock.ExpectQuery(alertsQuery).
+			WithArgs(userID, window.From, window.To).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}))
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "audit_logs"`).
+			WithArgs(userID, userID, window.From, window.To).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}))
+		var buffer bytes.Buffer
+		_, err = service.WriteExport(context.Background(), &buffer, export)
+		require.NoError(t, err)
+		assert.Contains(t, buffer.String(), "weather_data,\"[2026-01-01T00:00:00Z, 2026-03-31T23:59:59.999999Z]\"")
+
+		// A range ending in the past does not move the incremental cursor
+		service.CommitExport(context.Background(), export)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("last days end at the export", func(t *testing.T) {
+		service, mockDB := newService(t)
+		window := ExportRangeLastDays(exportedAt, 7)
+		from := exportedAt.AddDate(0, 0, -7)
+
+		expectUser(mockDB)
+
+		export, err := service.PrepareExport(context.Background(), userID, ExportTypeWeatherData, ExportFormatJSON, "en-US", false, &window)
+		require.NoError(t, err)
+		assert.Equal(t, "shopogoda_weather_testuser_2026-10-09_2026-10-16.json", export.Filename)
+		assert.Equal(t, ExportInterval{Dataset: "weather_data", From: from, To: exportedAt}, export.data.Coverage[0])
+
+		expectExportCursorSave(mockDB, userID, ExportTypeWeatherData, exportedAt)
+		service.CommitExport(context.Background(), export)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("all time starts when the user joined", func(t *testing.T) {
+		service, mockDB := newService(t)
+
+		expectUser(mockDB)
+
+		export, err := service.PrepareExport(context.Background(), userID, ExportTypeWeatherData, ExportFormatTXT, "en-US", false, &ExportRange{})
+		require.NoError(t, err)
+		assert.Equal(t, "shopogoda_weather_testuser_2025-03-01_2026-10-16.txt", export.Filename)
+
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM \(SELECT \* FROM "weather_records" WHERE user_id = \$1 AND timestamp >= \$2 AND timestamp <= \$3 ORDER BY timestamp DESC LIMIT \$4\) AS records`).
+			WithArgs(userID, joinedAt, exportedAt, exportRecordLimit).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(1))
+		mockDB.Mock.ExpectQuery(weatherQuery).
+			WithArgs(userID, joinedAt, exportedAt, exportRecordLimit).
+			WillReturnRows(exportWeatherRows(mockDB, userID, joinedAt.Add(time.Hour)))
+		var buffer bytes.Buffer
+		_, err = service.WriteExport(context.Background(), &buffer, export)
+		require.NoError(t, err)
+		assert.Contains(t, buffer.String(), "Weather Data (1 records):")
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("invalid ranges are rejected before any query", func(t *testing.T) {
+		service, mockDB := newService(t)
+		reversed := ExportRange{From: exportedAt, To: exportedAt.AddDate(0, 0, -1)}
+		tooLong := ExportRange{From: exportedAt.AddDate(-3, 0, 0), To: exportedAt}
+		future := ExportRange{From: exportedAt.AddDate(0, 0, 1)}
+
+		_, err := service.PrepareExport(context.Background(), userID, ExportTypeWeatherData, ExportFormatJSON, "en-US", false, &reversed)
+		assert.ErrorIs(t, err, ErrExportRangeOrder)
+		_, err = service.PrepareExport(context.Background(), userID, ExportTypeWeatherData, ExportFormatJSON, "en-US", false, &tooLong)
+		assert.ErrorIs(t, err, ErrExportRangeTooLong)
+		_, err = service.PrepareExport(context.Background(), userID, ExportTypeWeatherData, ExportFormatJSON, "en-US", true, &tooLong)
+		assert.Error(t, err)
+		mockDB.ExpectationsWereMet(t)
+
+		// A range starting after the export is only known to be empty once resolved
+		expectUser(mockDB)
+		_, err = service.PrepareExport(context.Background(), userID, ExportTypeWeatherData, ExportFormatJSON, "en-US", false, &future)
+		assert.ErrorIs(t, err, ErrExportRangeOrder)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
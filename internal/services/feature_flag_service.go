@@ -0,0 +1,354 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+
+	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/internal/models"
+)
+
+// Feature names a behaviour that can be switched on gradually
+type Feature string
+
+const (
+	FeatureNowcast        Feature = "nowcast"         // Minute-by-minute precipitation for the next hour
+	FeatureCharts         Feature = "charts"          // Chart button under forecasts
+	FeatureDigestTemplate Feature = "digest_template" // New daily digest layout
+)
+
+type featureDefinition struct {
+	Name        Feature
+	Default     bool
+	Description string
+}
+
+// featureDefinitions lists every known flag with its built-in default
+var featureDefinitions = []featureDefinition{
+	{Name: FeatureNowcast, Default: false, Description: "Next-hour precipitation nowcast"},
+	{Name: FeatureCharts, Default: false, Description: "Forecast charts"},
+	{Name: FeatureDigestTemplate, Default: false, Description: "New daily digest template"},
+}
+
+const (
+	featureFlagKeyPrefix = "feature_flag:"
+	featureRolloutField  = "rollout"
+	featureUserPrefix    = "user:"
+	featureResetChange   = "reset"
+)
+
+// FlagState holds every layer that decides whether a flag is on
+type FlagState struct {
+	Default    bool
+	Config     *bool          // Set when the flag appears in FEATURE_FLAGS
+	Percentage *int           // Runtime rollout, 0-100; "on" and "off" are 100 and 0
+	Users      map[int64]bool // Runtime per-user overrides
+}
+
+// EnabledFor evaluates the flag for a user. A per-user override wins over the
+// rollout percentage, which wins over config, which wins over the default.
+func (s FlagState) EnabledFor(feature Feature, userID int64) bool {
+	if enabled, ok := s.Users[userID]; ok {
+		return enabled
+	}
+	if s.Percentage != nil {
+		return rolloutBucket(feature, userID) < *s.Percentage
+	}
+	if s.Config != nil {
+		return *s.Config
+	}
+	return s.Default
+}
+
+// rolloutBucket maps a user to a stable bucket in [0, 100). The flag name is
+// part of the hash so each flag rolls out to a different set of users.
+func rolloutBucket(feature Feature, userID int64) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(string(feature) + ":" + strconv.FormatInt(userID, 10)))
+	return int(h.Sum32() % 100)
+}
+
+// FlagChange is a runtime change requested by an admin
+type FlagChange struct {
+	Percentage *int  // Set for on, off and N%
+	UserID     int64 // Set for user:ID and user:ID:off
+	Enabled    bool  // Value of the per-user override
+}
+
+func (c FlagChange) String() string {
+	if c.Percentage != nil {
+		return fmt.Sprintf("%d%%", *c.Percentage)
+	}
+	if c.Enabled {
+		return fmt.Sprintf("user:%d", c.UserID)
+	}
+	return fmt.Sprintf("user:%d:off", c.UserID)
+}
+
+// ParseFlagChange parses the value of "/flags set <flag> <value>":
+// on, off, a percentage such as 10%, user:<id> or user:<id>:off
+func ParseFlagChange(value string) (FlagChange, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	switch value {
+	case "on":
+		return percentageChange(100), nil
+	case "off":
+		return percentageChange(0), nil
+	}
+
+	if strings.HasSuffix(value, "%") {
+		percentage, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+		if err != nil || percentage < 0 || percentage > 100 {
+			return FlagChange{}, fmt.Errorf("invalid rollout percentage: %s", value)
+		}
+		return percentageChange(percentage), nil
+	}
+
+	if rest, ok := strings.CutPrefix(value, featureUserPrefix); ok {
+		change := FlagChange{Enabled: true}
+		if idPart, ok := strings.CutSuffix(rest, ":off"); ok {
+			rest = idPart
+			change.Enabled = false
+		} else if idPart, ok := strings.CutSuffix(rest, ":on"); ok {
+			rest = idPart
+		}
+		userID, err := strconv.ParseInt(rest, 10, 64)
+		if err != nil || userID <= 0 {
+			return FlagChange{}, fmt.Errorf("invalid user ID: %s", rest)
+		}
+		change.UserID = userID
+		return change, nil
+	}
+
+	return FlagChange{}, fmt.Errorf("invalid flag value: %s (use on, off, N%% or user:ID)", value)
+}
+
+func percentageChange(percentage int) FlagChange {
+	return FlagChange{Percentage: &percentage}
+}
+
+// FlagStatus describes a flag for the admin listing
+type FlagStatus struct {
+	Name        Feature
+	Description string
+	State       FlagState
+}
+
+// FeatureFlagService evaluates feature flags. Defaults live in code, config can
+// override them at startup and admins at runtime; runtime changes are stored in
+// Redis and read on every evaluation, so they apply without a restart. Every
+// runtime change is recorded in the database before it is applied.
+type FeatureFlagService struct {
+	db       *gorm.DB
+	redis    *redis.Client
+	configOn map[Feature]bool
+	logger   *zerolog.Logger
+}
+
+func NewFeatureFlagService(cfg *config.FeaturesConfig, db *gorm.DB, redis *redis.Client, logger *zerolog.Logger) *FeatureFlagService {
+	return &FeatureFlagService{
+		db:       db,
+		redis:    redis,
+		configOn: parseFeatureConfig(cfg.Flags, logger),
+		logger:   logger,
+	}
+}
+
+// parseFeatureConfig reads "name=on,name=off" pairs, skipping unknown or
+// malformed entries so a typo in the environment never stops the bot
+func parseFeatureConfig(spec string, logger *zerolog.Logger) map[Feature]bool {
+	result := make(map[Feature]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, value, _ := strings.Cut(entry, "=")
+		feature := Feature(strings.ToLower(strings.TrimSpace(name)))
+		if _, ok := lookupFeature(feature); !ok {
+			logger.Warn().Str("flag", string(feature)).Msg("Ignoring unknown feature flag in config")
+			continue
+		}
+
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "on", "true", "1":
+			result[feature] = true
+		case "off", "false", "0":
+			result[feature] = false
+		default:
+			logger.Warn().Str("flag", string(feature)).Str("value", value).Msg("Ignoring invalid feature flag value in config")
+		}
+	}
+	return result
+}
+
+func lookupFeature(feature Feature) (featureDefinition, bool) {
+	for _, definition := range featureDefinitions {
+		if definition.Name == feature {
+			return definition, true
+		}
+	}
+	return featureDefinition{}, false
+}
+
+func featureKey(feature Feature) string {
+	return featureFlagKeyPrefix + string(feature)
+}
+
+// State loads every layer of a flag. Runtime overrides are skipped with a
+// warning when Redis is unavailable, leaving config and the default in effect.
+func (s *FeatureFlagService) State(ctx context.Context, feature Feature) (FlagState, error) {
+	definition, ok := lookupFeature(feature)
+	if !ok {
+		return FlagState{}, fmt.Errorf("unknown feature flag: %s", feature)
+	}
+
+	state := FlagState{Default: definition.Default}
+	if enabled, ok := s.configOn[feature]; ok {
+		state.Config = &enabled
+	}
+
+	fields, err := s.redis.HGetAll(ctx, featureKey(feature)).Result()
+	if err != nil {
+		s.logger.Warn().Err(err).Str("flag", string(feature)).Msg("Failed to load feature flag overrides")
+		return state, nil
+	}
+
+	for field, value := range fields {
+		if field == featureRolloutField {
+			if percentage, err := strconv.Atoi(value); err == nil {
+				state.Percentage = &percentage
+			}
+			continue
+		}
+		if idPart, ok := strings.CutPrefix(field, featureUserPrefix); ok {
+			if userID, err := strconv.ParseInt(idPart, 10, 64); err == nil {
+				if state.Users == nil {
+					state.Users = make(map[int64]bool)
+				}
+				state.Users[userID] = value == "1"
+			}
+		}
+	}
+
+	return state, nil
+}
+
+// List returns the state of every known flag in definition order
+func (s *FeatureFlagService) List(ctx context.Context) ([]FlagStatus, error) {
+	statuses := make([]FlagStatus, 0, len(featureDefinitions))
+	for _, definition := range featureDefinitions {
+		state, err := s.State(ctx, definition.Name)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, FlagStatus{Name: definition.Name, Description: definition.Description, State: state})
+	}
+	return statuses, nil
+}
+
+// recordChange stores the audit record of a change. A change that cannot be
+// recorded is not applied.
+func (s *FeatureFlagService) recordChange(ctx context.Context, adminID int64, feature Feature, change string) error {
+	record := &models.FeatureFlagChange{
+		Flag:    string(feature),
+		Change:  change,
+		AdminID: adminID,
+	}
+	if err := s.db.WithContext(ctx).Create(record).Error; err != nil {
+		return fmt.Errorf("failed to record feature flag change: %w", err)
+	}
+	return nil
+}
+
+// Set records a runtime change to a flag and applies it
+func (s *FeatureFlagService) Set(ctx context.Context, adminID int64, feature Feature, change FlagChange) error {
+	if _, ok := lookupFeature(feature); !ok {
+		return fmt.Errorf("unknown feature flag: %s", feature)
+	}
+
+	field, value := featureRolloutField, ""
+	if change.Percentage != nil {
+		value = strconv.Itoa(*change.Percentage)
+	} else {
+		field = featureUserPrefix + strconv.FormatInt(change.UserID, 10)
+		value = "0"
+		if change.Enabled {
+			value = "1"
+		}
+	}
+
+	if err := s.recordChange(ctx, adminID, feature, change.String()); err != nil {
+		return err
+	}
+
+	if err := s.redis.HSet(ctx, featureKey(feature), field, value).Err(); err != nil {
+		return fmt.Errorf("failed to store feature flag: %w", err)
+	}
+
+	s.logger.Info().
+		Int64("admin_id", adminID).
+		Str("flag", string(feature)).
+		Str("change", change.String()).
+		Msg("Feature flag changed")
+
+	return nil
+}
+
+// Reset drops every runtime override of a flag, returning it to config or its default
+func (s *FeatureFlagService) Reset(ctx context.Context, adminID int64, feature Feature) error {
+	if _, ok := lookupFeature(feature); !ok {
+		return fmt.Errorf("unknown feature flag: %s", feature)
+	}
+
+	if err := s.recordChange(ctx, adminID, feature, featureResetChange); err != nil {
+		return err
+	}
+
+	if err := s.redis.Del(ctx, featureKey(feature)).Err(); err != nil {
+		return fmt.Errorf("failed to reset feature flag: %w", err)
+	}
+
+	s.logger.Info().
+		Int64("admin_id", adminID).
+		Str("flag", string(feature)).
+		Msg("Feature flag reset")
+
+	return nil
+}
+
+// ForUser evaluates every known flag for one user
+func (s *FeatureFlagService) ForUser(ctx context.Context, userID int64) *Features {
+	enabled := make(map[Feature]bool, len(featureDefinitions))
+	for _, definition := range featureDefinitions {
+		state, err := s.State(ctx, definition.Name)
+		if err != nil {
+			continue
+		}
+		enabled[definition.Name] = state.EnabledFor(definition.Name, userID)
+	}
+	return &Features{enabled: enabled}
+}
+
+// Features is the set of flags evaluated for one user, meant to be fetched once
+// per update and consulted by the handler
+type Features struct {
+	enabled map[Feature]bool
+}
+
+// Enabled reports whether a feature is on. Unknown flags and a nil set are off.
+func (f *Features) Enabled(feature Feature) bool {
+	if f == nil {
+		return false
+	}
+	return f.enabled[feature]
+}
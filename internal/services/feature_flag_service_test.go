@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestRolloutBucket(t *testing.T) {
+	t.Run("stable for the same user and flag", func(t *testing.T) {
+		for userID := int64(1); userID <= 100; userID++ {
+			assert.Equal(t, rolloutBucket(FeatureCharts, userID), rolloutBucket(FeatureCharts, userID))
+		}
+	})
+
+	t.Run("roughly proportional", func(t *testing.T) {
+		inRollout := 0
+		for userID := int64(1); userID <= 10000; userID++ {
+			bucket := rolloutBucket(FeatureCharts, userID)
+			require.GreaterOrEqual(t, bucket, 0)
+			require.Less(t, bucket, 100)
+			if bucket < 10 {
+				inRollout++
+			}
+		}
+		assert.InDelta(t, 1000, inRollout, 200)
+	})
+
+	t.Run("raising the percentage keeps existing users", func(t *testing.T) {
+		ten, twenty := 10, 20
+		for userID := int64(1); userID <= 1000; userID++ {
+			if (FlagState{Percentage: &ten}).EnabledFor(FeatureCharts, userID) {
+				assert.True(t, FlagState{Percentage: &twenty}.EnabledFor(FeatureCharts, userID))
+			}
+		}
+	})
+}
+
+func TestFlagState_EnabledFor(t *testing.T) {
+	on, off := true, false
+	zero, hundred := 0, 100
+
+	tests := []struct {
+		name  string
+		state FlagState
+		want  bool
+	}{
+		{"default only", FlagState{Default: true}, true},
+		{"config over default", FlagState{Default: true, Config: &off}, false},
+		{"percentage over config", FlagState{Config: &off, Percentage: &hundred}, true},
+		{"off percentage over config", FlagState{Config: &on, Percentage: &zero}, false},
+		{"user over percentage", FlagState{Percentage: &zero, Users: map[int64]bool{42: true}}, true},
+		{"user off over everything", FlagState{Default: true, Config: &on, Percentage: &hundred, Users: map[int64]bool{42: false}}, false},
+		{"other user override ignored", FlagState{Default: true, Users: map[int64]bool{7: false}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.state.EnabledFor(FeatureNowcast, 42))
+		})
+	}
+}
+
+func TestParseFlagChange(t *testing.T) {
+	percentage := func(change FlagChange) int {
+		require.NotNil(t, change.Percentage)
+		return *change.Percentage
+	}
+
+	change, err := ParseFlagChange("on")
+	require.NoError(t, err)
+	assert.Equal(t, 100, percentage(change))
+
+	change, err = ParseFlagChange("OFF")
+	require.NoError(t, err)
+	assert.Equal(t, 0, percentage(change))
+
+	change, err = ParseFlagChange("10%")
+	require.NoError(t, err)
+	assert.Equal(t, 10, percentage(change))
+	assert.Equal(t, "10%", change.String())
+
+	change, err = ParseFlagChange("user:12345")
+	require.NoError(t, err)
+	assert.Nil(t, change.Percentage)
+	assert.Equal(t, int64(12345), change.UserID)
+	assert.True(t, change.Enabled)
+
+	change, err = ParseFlagChange("user:12345:off")
+	require.NoError(t, err)
+	assert.False(t, change.Enabled)
+	assert.Equal(t, "user:12345:off", change.String())
+
+	for _, invalid := range []string{"", "maybe", "101%", "-5%", "abc%", "user:", "user:abc", "user:0"} {
+		_, err := ParseFlagChange(invalid)
+		assert.Error(t, err, "value %q", invalid)
+	}
+}
+
+func TestParseFeatureConfig(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+
+	flags := parseFeatureConfig(" charts=on, nowcast=off ,unknown=on,digest_template=sometimes", logger)
+
+	assert.Equal(t, map[Feature]bool{FeatureCharts: true, FeatureNowcast: false}, flags)
+	assert.Empty(t, parseFeatureConfig("", logger))
+}
+
+func TestFeatureFlagService_State(t *testing.T) {
+	mockRedis := helpers.NewMockRedis()
+	service := NewFeatureFlagService(&config.FeaturesConfig{Flags: "charts=on"}, nil, mockRedis.Client, helpers.NewSilentTestLogger())
+
+	t.Run("merges config and runtime overrides", func(t *testing.T) {
+		mockRedis.Mock.ExpectHGetAll("feature_flag:charts").SetVal(map[string]string{
+			"rollout":    "25",
+			"user:42":    "0",
+			"user:7":     "1",
+			"user:bogus": "1",
+		})
+
+		state, err := service.State(context.Background(), FeatureCharts)
+
+		require.NoError(t, err)
+		require.NotNil(t, state.Config)
+		assert.True(t, *state.Config)
+		require.NotNil(t, state.Percentage)
+		assert.Equal(t, 25, *state.Percentage)
+		assert.Equal(t, map[int64]bool{42: false, 7: true}, state.Users)
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("falls back to config when Redis fails", func(t *testing.T) {
+		mockRedis.Mock.ExpectHGetAll("feature_flag:nowcast").SetVal(map[string]string{})
+		mockRedis.Mock.ExpectHGetAll("feature_flag:charts").SetErr(errors.New("connection refused"))
+		mockRedis.Mock.ExpectHGetAll("feature_flag:digest_template").SetVal(map[string]string{})
+
+		features := service.ForUser(context.Background(), 42)
+
+		assert.True(t, features.Enabled(FeatureCharts))
+		assert.False(t, features.Enabled(FeatureNowcast))
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown flag", func(t *testing.T) {
+		_, err := service.State(context.Background(), Feature("teleport"))
+		assert.Error(t, err)
+	})
+}
+
+func TestFeatureFlagService_SetAndReset(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	mockRedis := helpers.NewMockRedis()
+	service := NewFeatureFlagService(&config.FeaturesConfig{}, mockDB.DB, mockRedis.Client, helpers.NewSilentTestLogger())
+	ctx := context.Background()
+
+	expectAudit := func(flag, change string) {
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "feature_flag_changes"`).
+			WithArgs(flag, change, int64(1), helpers.AnyTime{}).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mockDB.Mock.ExpectCommit()
+	}
+
+	rollout, err := ParseFlagChange("10%")
+	require.NoError(t, err)
+	expectAudit("charts", "10%")
+	mockRedis.Mock.ExpectHSet("feature_flag:charts", "rollout", "10").SetVal(1)
+	require.NoError(t, service.Set(ctx, 1, FeatureCharts, rollout))
+
+	userOff, err := ParseFlagChange("user:12345:off")
+	require.NoError(t, err)
+	expectAudit("nowcast", "user:12345:off")
+	mockRedis.Mock.ExpectHSet("feature_flag:nowcast", "user:12345", "0").SetVal(1)
+	require.NoError(t, service.Set(ctx, 1, FeatureNowcast, userOff))
+
+	expectAudit("charts", "reset")
+	mockRedis.Mock.ExpectDel("feature_flag:charts").SetVal(1)
+	require.NoError(t, service.Reset(ctx, 1, FeatureCharts))
+
+	assert.Error(t, service.Set(ctx, 1, Feature("teleport"), rollout))
+	assert.Error(t, service.Reset(ctx, 1, Feature("teleport")))
+	assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestFeatureFlagService_SetUnaudited(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	mockRedis := helpers.NewMockRedis()
+	service := NewFeatureFlagService(&config.FeaturesConfig{}, mockDB.DB, mockRedis.Client, helpers.NewSilentTestLogger())
+
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectQuery(`INSERT INTO "feature_flag_changes"`).WillReturnError(errors.New("connection refused"))
+	mockDB.Mock.ExpectRollback()
+
+	rollout, err := ParseFlagChange("on")
+	require.NoError(t, err)
+	assert.Error(t, service.Set(context.Background(), 1, FeatureCharts, rollout))
+	assert.NoError(t, mockRedis.Mock.ExpectationsWereMet(), "a change that cannot be audited is not applied")
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestFeatures_NilIsOff(t *testing.T) {
+	var features *Features
+	assert.False(t, features.Enabled(FeatureCharts))
+}
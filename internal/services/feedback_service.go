@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"github.com/valpere/shopogoda/internal/models"
+)
+
+const (
+	// MaxFeedbackLength bounds one feedback message, in characters, so that it
+	// fits in the message forwarded to the admins
+	MaxFeedbackLength = 2000
+
+	// FeedbackPageSize is how many unresolved messages /listfeedback shows at once
+	FeedbackPageSize = 5
+
+	// PendingFeedbackTimeout is how long /feedback waits for the message when
+	// it was sent without one, and how long an admin's reply is waited for
+	PendingFeedbackTimeout = 10 * time.Minute
+)
+
+var (
+	// ErrFeedbackEmpty is returned for feedback without any text
+	ErrFeedbackEmpty = errors.New("feedback is empty")
+	// ErrFeedbackTooLong is returned for feedback over MaxFeedbackLength
+	ErrFeedbackTooLong = errors.New("feedback is too long")
+	// ErrFeedbackNotFound is returned for IDs that match no feedback
+	ErrFeedbackNotFound = errors.New("feedback not found")
+	// ErrFeedbackResolved is returned when resolving feedback that already is
+	ErrFeedbackResolved = errors.New("feedback already resolved")
+)
+
+// pendingFeedbackKey marks a user whose next message is their feedback. It
+// lives under user:{id}: so that deleting the account removes it.
+func pendingFeedbackKey(userID int64) string {
+	return fmt.Sprintf("user:%d:feedback_pending", userID)
+}
+
+// pendingFeedbackReplyKey holds the feedback an admin is replying to
+func pendingFeedbackReplyKey(adminID int64) string {
+	return fmt.Sprintf("user:%d:feedback_reply", adminID)
+}
+
+// FeedbackService stores the messages users send to the admins and tracks
+// which of them the admins have dealt with
+type FeedbackService struct {
+	db    *gorm.DB
+	redis *redis.Client
+	now   func() time.Time
+}
+
+func NewFeedbackService(db *gorm.DB, redis *redis.Client) *FeedbackService {
+	return &FeedbackService{db: db, redis: redis, now: time.Now}
+}
+
+// Submit saves a user's feedback, sent from the given chat. Surrounding
+// whitespace is dropped; empty and overlong messages are refused.
+func (s *FeedbackService) Submit(ctx context.Context, userID, chatID int64, message string) (*models.Feedback, error) {
+	message = strings.TrimSpace(message)
+	switch {
+	case message == "":
+		return nil, ErrFeedbackEmpty
+	case utf8.RuneCountInString(message) > MaxFeedbackLength:
+		return nil, ErrFeedbackTooLong
+	}
+
+	feedback := &models.Feedback{UserID: userID, ChatID: chatID, Message: message, CreatedAt: s.now().UTC()}
+	if err := s.db.WithContext(ctx).Create(feedback).Error; err != nil {
+		return nil, fmt.Errorf("failed to save feedback: %w", err)
+	}
+	return feedback, nil
+}
+
+// List returns one page of the unresolved feedback, oldest first with its
+// sender loaded, and how much unresolved feedback there is in all. Pages
+// count from zero.
+func (s *FeedbackService) List(ctx context.Context, page int) ([]models.Feedback, int64, error) {
+	var total int64
+	if err := s.db.WithContext(ctx).Model(&models.Feedback{}).Where("is_resolved = ?", false).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count feedback: %w", err)
+	}
+
+	var feedback []models.Feedback
+	err := s.db.WithContext(ctx).
+		Preload("User").
+		Where("is_resolved = ?", false).
+		Order("created_at ASC").
+		Offset(max(page, 0) * FeedbackPageSize).
+		Limit(FeedbackPageSize).
+		Find(&feedback).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list feedback: %w", err)
+	}
+	return feedback, total, nil
+}
+
+// Get returns feedback with its sender loaded
+func (s *FeedbackService) Get(ctx context.Context, id uuid.UUID) (*models.Feedback, error) {
+	var feedback models.Feedback
+	err := s.db.WithContext(ctx).Preload("User").Where("id = ?", id).First(&feedback).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFeedbackNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feedback: %w", err)
+	}
+	return &feedback, nil
+}
+
+// Resolve marks feedback as dealt with by an admin
+func (s *FeedbackService) Resolve(ctx context.Context, id uuid.UUID, adminID int64) error {
+	result := s.db.WithContext(ctx).Model(&models.Feedback{}).
+		Where("id = ? AND is_resolved = ?", id, false).
+		Updates(map[string]interface{}{
+			"is_resolved": true,
+			"resolved_by": adminID,
+			"resolved_at": s.now().UTC(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to resolve feedback: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.Feedback{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to find feedback: %w", err)
+	}
+	if count == 0 {
+		return ErrFeedbackNotFound
+	}
+	return ErrFeedbackResolved
+}
+
+// StartPendingFeedback waits for the user's next message to be their feedback
+func (s *FeedbackService) StartPendingFeedback(ctx context.Context, userID int64) error {
+	if err := s.redis.Set(ctx, pendingFeedbackKey(userID), 1, PendingFeedbackTimeout).Err(); err != nil {
+		return fmt.Errorf("failed to store pending feedback: %w", err)
+	}
+	return nil
+}
+
+// TakePendingFeedback reports whether the user was asked for their feedback
+// within PendingFeedbackTimeout, and stops waiting for it
+func (s *FeedbackService) TakePendingFeedback(ctx context.Context, userID int64) (bool, error) {
+	err := s.redis.GetDel(ctx, pendingFeedbackKey(userID)).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load pending feedback: %w", err)
+	}
+	return true, nil
+}
+
+// ClearPendingFeedback stops waiting for the user's feedback
+func (s *FeedbackService) ClearPendingFeedback(ctx context.Context, userID int64) error {
+	return s.redis.Del(ctx, pendingFeedbackKey(userID)).Err()
+}
+
+// StartFeedbackReply waits for the admin's next message to be their reply to
+// the feedback
+func (s *FeedbackService) StartFeedbackReply(ctx context.Context, adminID int64, id uuid.UUID) error {
+	if err := s.redis.Set(ctx, pendingFeedbackReplyKey(adminID), id.String(), PendingFeedbackTimeout).Err(); err != nil {
+		return fmt.Errorf("failed to store pending feedback reply: %w", err)
+	}
+	return nil
+}
+
+// TakeFeedbackReply returns the feedback the admin started replying to within
+// PendingFeedbackTimeout, if any, and stops waiting for the reply
+func (s *FeedbackService) TakeFeedbackReply(ctx context.Context, adminID int64) (uuid.UUID, bool, error) {
+	value, err := s.redis.GetDel(ctx, pendingFeedbackReplyKey(adminID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return uuid.Nil, false, nil
+	}
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("failed to load pending feedback reply: %w", err)
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("invalid pending feedback reply %q: %w", value, err)
+	}
+	return id, true, nil
+}
+
+// ClearFeedbackReply stops waiting for the admin's reply
+func (s *FeedbackService) ClearFeedbackReply(ctx context.Context, adminID int64) error {
+	return s.redis.Del(ctx, pendingFeedbackReplyKey(adminID)).Err()
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func newTestFeedbackService(t *testing.T) (*FeedbackService, *helpers.MockDB, *helpers.MockRedis) {
+	mockDB := helpers.NewMockDB(t)
+	t.Cleanup(func() { _ = mockDB.Close() })
+	mockRedis := helpers.NewMockRedis()
+	service := NewFeedbackService(mockDB.DB, mockRedis.Client)
+	service.now = func() time.Time { return time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC) }
+	return service, mockDB, mockRedis
+}
+
+func TestFeedbackService_Submit(t *testing.T) {
+	t.Run("saves the trimmed message", func(t *testing.T) {
+		service, mockDB, _ := newTestFeedbackService(t)
+		id := uuid.New()
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "feedback"`).
+			WithArgs(int64(123), int64(-100), "The alerts come an hour late", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "is_resolved"}).AddRow(id, false))
+		mockDB.Mock.ExpectCommit()
+
+		feedback, err := service.Submit(context.Background(), 123, -100, "  The alerts come an hour late\n")
+		require.NoError(t, err)
+		assert.Equal(t, id, feedback.ID)
+		assert.Equal(t, "The alerts come an hour late", feedback.Message)
+		assert.Equal(t, int64(-100), feedback.ChatID)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("refuses empty and overlong messages", func(t *testing.T) {
+		service, mockDB, _ := newTestFeedbackService(t)
+
+		_, err := service.Submit(context.Background(), 123, 123, " \n ")
+		assert.ErrorIs(t, err, ErrFeedbackEmpty)
+
+		_, err = service.Submit(context.Background(), 123, 123, strings.Repeat("я", MaxFeedbackLength+1))
+		assert.ErrorIs(t, err, ErrFeedbackTooLong)
+
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestFeedbackService_List(t *testing.T) {
+	service, mockDB, _ := newTestFeedbackService(t)
+	id := uuid.New()
+
+	mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "feedback" WHERE is_resolved = \$1`).
+		WithArgs(false).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "feedback" WHERE is_resolved = \$1 ORDER BY created_at ASC LIMIT \$2 OFFSET \$3`).
+		WithArgs(false, FeedbackPageSize, FeedbackPageSize).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "message"}).AddRow(id, int64(123), "Add pollen for Lviv"))
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1`).
+		WithArgs(int64(123)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username"}).AddRow(int64(123), "lviv_rain"))
+
+	feedback, total, err := service.List(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), total)
+	require.Len(t, feedback, 1)
+	assert.Equal(t, "lviv_rain", feedback[0].User.Username)
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestFeedbackService_Resolve(t *testing.T) {
+	id := uuid.New()
+	expectUpdate := func(mockDB *helpers.MockDB, rows int64) {
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "feedback" SET "is_resolved"=\$1,"resolved_at"=\$2,"resolved_by"=\$3 WHERE id = \$4 AND is_resolved = \$5`).
+			WithArgs(true, time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC), int64(100), id, false).
+			WillReturnResult(sqlmock.NewResult(0, rows))
+		mockDB.Mock.ExpectCommit()
+	}
+	expectCount := func(mockDB *helpers.MockDB, count int) {
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "feedback" WHERE id = \$1`).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+	}
+
+	t.Run("resolves unresolved feedback", func(t *testing.T) {
+		service, mockDB, _ := newTestFeedbackService(t)
+		expectUpdate(mockDB, 1)
+
+		require.NoError(t, service.Resolve(context.Background(), id, 100))
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("reports feedback resolved before", func(t *testing.T) {
+		service, mockDB, _ := newTestFeedbackService(t)
+		expectUpdate(mockDB, 0)
+		expectCount(mockDB, 1)
+
+		assert.ErrorIs(t, service.Resolve(context.Background(), id, 100), ErrFeedbackResolved)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("reports unknown feedback", func(t *testing.T) {
+		service, mockDB, _ := newTestFeedbackService(t)
+		expectUpdate(mockDB, 0)
+		expectCount(mockDB, 0)
+
+		assert.ErrorIs(t, service.Resolve(context.Background(), id, 100), ErrFeedbackNotFound)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestFeedbackService_PendingFeedback(t *testing.T) {
+	service, _, mockRedis := newTestFeedbackService(t)
+	ctx := context.Background()
+
+	mockRedis.Mock.ExpectSet("user:123:feedback_pending", 1, PendingFeedbackTimeout).SetVal("OK")
+	require.NoError(t, service.StartPendingFeedback(ctx, 123))
+
+	mockRedis.Mock.ExpectGetDel("user:123:feedback_pending").SetVal("1")
+	pending, err := service.TakePendingFeedback(ctx, 123)
+	require.NoError(t, err)
+	assert.True(t, pending)
+
+	mockRedis.Mock.ExpectGetDel("user:123:feedback_pending").RedisNil()
+	pending, err = service.TakePendingFeedback(ctx, 123)
+	require.NoError(t, err)
+	assert.False(t, pending, "the message after the feedback is not taken as feedback")
+
+	mockRedis.ExpectationsWereMet(t)
+}
+
+func TestFeedbackService_Get(t *testing.T) {
+	service, mockDB, _ := newTestFeedbackService(t)
+	id := uuid.New()
+
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "feedback" WHERE id = \$1 ORDER BY "feedback"\."id" LIMIT \$2`).
+		WithArgs(id, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "chat_id", "message"}).AddRow(id, int64(123), int64(-100), "Add pollen for Lviv"))
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1`).
+		WithArgs(int64(123)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "language"}).AddRow(int64(123), "uk-UA"))
+
+	feedback, err := service.Get(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-100), feedback.ChatID)
+	assert.Equal(t, "uk-UA", feedback.User.Language)
+
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "feedback" WHERE id = \$1`).
+		WithArgs(id, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	_, err = service.Get(context.Background(), id)
+	assert.ErrorIs(t, err, ErrFeedbackNotFound)
+
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestFeedbackService_FeedbackReply(t *testing.T) {
+	service, _, mockRedis := newTestFeedbackService(t)
+	ctx := context.Background()
+	id := uuid.New()
+
+	mockRedis.Mock.ExpectSet("user:100:feedback_reply", id.String(), PendingFeedbackTimeout).SetVal("OK")
+	require.NoError(t, service.StartFeedbackReply(ctx, 100, id))
+
+	mockRedis.Mock.ExpectGetDel("user:100:feedback_reply").SetVal(id.String())
+	replyTo, pending, err := service.TakeFeedbackReply(ctx, 100)
+	require.NoError(t, err)
+	assert.True(t, pending)
+	assert.Equal(t, id, replyTo)
+
+	mockRedis.Mock.ExpectGetDel("user:100:feedback_reply").RedisNil()
+	_, pending, err = service.TakeFeedbackReply(ctx, 100)
+	require.NoError(t, err)
+	assert.False(t, pending)
+
+	mockRedis.ExpectationsWereMet(t)
+}
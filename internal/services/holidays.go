@@ -0,0 +1,237 @@
+package services
+
+import (
+	"strings"
+	"time"
+)
+
+// holidayRule is one nationwide public holiday. A holiday falls either on a
+// fixed date, on a day relative to Easter, or on the nth weekday of a month.
+type holidayRule struct {
+	name string
+
+	// Fixed date
+	month time.Month
+	day   int
+
+	// Days after Easter Sunday, Western or Orthodox
+	easter       bool
+	orthodox     bool
+	easterOffset int
+
+	// nth weekday of the month; -1 is the last one
+	weekday time.Weekday
+	nth     int
+
+	// Years the rule applies to, inclusive; zero is unbounded
+	from, until int
+}
+
+func fixedHoliday(name string, month time.Month, day int) holidayRule {
+	return holidayRule{name: name, month: month, day: day}
+}
+
+func easterHoliday(name string, offset int) holidayRule {
+	return holidayRule{name: name, easter: true, easterOffset: offset}
+}
+
+func orthodoxEasterHoliday(name string, offset int) holidayRule {
+	return holidayRule{name: name, easter: true, orthodox: true, easterOffset: offset}
+}
+
+func weekdayHoliday(name string, month time.Month, weekday time.Weekday, nth int) holidayRule {
+	return holidayRule{name: name, month: month, weekday: weekday, nth: nth}
+}
+
+func (r holidayRule) between(from, until int) holidayRule {
+	r.from, r.until = from, until
+	return r
+}
+
+// publicHolidays are the nationwide public holidays of the countries the bot
+// has users in, by ISO 3166 country code. Regional holidays and days off
+// moved from weekends are not included.
+var publicHolidays = map[string][]holidayRule{
+	"DE": {
+		fixedHoliday("Neujahr", time.January, 1),
+		easterHoliday("Karfreitag", -2),
+		easterHoliday("Ostermontag", 1),
+		fixedHoliday("Tag der Arbeit", time.May, 1),
+		easterHoliday("Christi Himmelfahrt", 39),
+		easterHoliday("Pfingstmontag", 50),
+		fixedHoliday("Tag der Deutschen Einheit", time.October, 3),
+		fixedHoliday("1. Weihnachtstag", time.December, 25),
+		fixedHoliday("2. Weihnachtstag", time.December, 26),
+	},
+	"ES": {
+		fixedHoliday("Año Nuevo", time.January, 1),
+		fixedHoliday("Epifanía del Señor", time.January, 6),
+		easterHoliday("Viernes Santo", -2),
+		fixedHoliday("Fiesta del Trabajo", time.May, 1),
+		fixedHoliday("Asunción de la Virgen", time.August, 15),
+		fixedHoliday("Fiesta Nacional de España", time.October, 12),
+		fixedHoliday("Todos los Santos", time.November, 1),
+		fixedHoliday("Día de la Constitución", time.December, 6),
+		fixedHoliday("Inmaculada Concepción", time.December, 8),
+		fixedHoliday("Navidad", time.December, 25),
+	},
+	"FR": {
+		fixedHoliday("Jour de l'an", time.January, 1),
+		easterHoliday("Lundi de Pâques", 1),
+		fixedHoliday("Fête du Travail", time.May, 1),
+		fixedHoliday("Victoire 1945", time.May, 8),
+		easterHoliday("Ascension", 39),
+		easterHoliday("Lundi de Pentecôte", 50),
+		fixedHoliday("Fête nationale", time.July, 14),
+		fixedHoliday("Assomption", time.August, 15),
+		fixedHoliday("Toussaint", time.November, 1),
+		fixedHoliday("Armistice 1918", time.November, 11),
+		fixedHoliday("Noël", time.December, 25),
+	},
+	"GB": {
+		fixedHoliday("New Year's Day", time.January, 1),
+		easterHoliday("Good Friday", -2),
+		easterHoliday("Easter Monday", 1),
+		weekdayHoliday("Early May bank holiday", time.May, time.Monday, 1),
+		weekdayHoliday("Spring bank holiday", time.May, time.Monday, -1),
+		weekdayHoliday("Summer bank holiday", time.August, time.Monday, -1),
+		fixedHoliday("Christmas Day", time.December, 25),
+		fixedHoliday("Boxing Day", time.December, 26),
+	},
+	"IT": {
+		fixedHoliday("Capodanno", time.January, 1),
+		fixedHoliday("Epifania", time.January, 6),
+		easterHoliday("Lunedì dell'Angelo", 1),
+		fixedHoliday("Festa della Liberazione", time.April, 25),
+		fixedHoliday("Festa del Lavoro", time.May, 1),
+		fixedHoliday("Festa della Repubblica", time.June, 2),
+		fixedHoliday("Ferragosto", time.August, 15),
+		fixedHoliday("Ognissanti", time.November, 1),
+		fixedHoliday("Immacolata Concezione", time.December, 8),
+		fixedHoliday("Natale", time.December, 25),
+		fixedHoliday("Santo Stefano", time.December, 26),
+	},
+	"PL": {
+		fixedHoliday("Nowy Rok", time.January, 1),
+		fixedHoliday("Trzech Króli", time.January, 6),
+		easterHoliday("Wielkanoc", 0),
+		easterHoliday("Poniedziałek Wielkanocny", 1),
+		fixedHoliday("Święto Pracy", time.May, 1),
+		fixedHoliday("Święto Konstytucji 3 Maja", time.May, 3),
+		easterHoliday("Zielone Świątki", 49),
+		easterHoliday("Boże Ciało", 60),
+		fixedHoliday("Wniebowzięcie NMP", time.August, 15),
+		fixedHoliday("Wszystkich Świętych", time.November, 1),
+		fixedHoliday("Narodowe Święto Niepodległości", time.November, 11),
+		fixedHoliday("Wigilia", time.December, 24).between(2025, 0),
+		fixedHoliday("Boże Narodzenie", time.December, 25),
+		fixedHoliday("Drugi dzień Bożego Narodzenia", time.December, 26),
+	},
+	"UA": {
+		fixedHoliday("Новий рік", time.January, 1),
+		fixedHoliday("Різдво Христове", time.January, 7).between(0, 2023),
+		fixedHoliday("Міжнародний жіночий день", time.March, 8),
+		orthodoxEasterHoliday("Великдень", 0),
+		orthodoxEasterHoliday("Трійця", 49),
+		fixedHoliday("День праці", time.May, 1),
+		fixedHoliday("День пам'яті та перемоги", time.May, 8).between(2024, 0),
+		fixedHoliday("День перемоги", time.May, 9).between(0, 2023),
+		fixedHoliday("День Конституції", time.June, 28),
+		fixedHoliday("День Української Державності", time.July, 15).between(2023, 0),
+		fixedHoliday("День Незалежності", time.August, 24),
+		fixedHoliday("День захисників і захисниць", time.October, 14).between(0, 2022),
+		fixedHoliday("День захисників і захисниць", time.October, 1).between(2023, 0),
+		fixedHoliday("Різдво Христове", time.December, 25),
+	},
+	"US": {
+		fixedHoliday("New Year's Day", time.January, 1),
+		weekdayHoliday("Martin Luther King Jr. Day", time.January, time.Monday, 3),
+		weekdayHoliday("Washington's Birthday", time.February, time.Monday, 3),
+		weekdayHoliday("Memorial Day", time.May, time.Monday, -1),
+		fixedHoliday("Juneteenth", time.June, 19).between(2021, 0),
+		fixedHoliday("Independence Day", time.July, 4),
+		weekdayHoliday("Labor Day", time.September, time.Monday, 1),
+		weekdayHoliday("Columbus Day", time.October, time.Monday, 2),
+		fixedHoliday("Veterans Day", time.November, 11),
+		weekdayHoliday("Thanksgiving Day", time.November, time.Thursday, 4),
+		fixedHoliday("Christmas Day", time.December, 25),
+	},
+}
+
+// HasHolidayCalendar reports whether the public holidays of a country are known
+func HasHolidayCalendar(country string) bool {
+	_, ok := publicHolidays[strings.ToUpper(country)]
+	return ok
+}
+
+// PublicHoliday returns the name of the public holiday on the calendar date of
+// day in a country, if there is one
+func PublicHoliday(country string, day time.Time) (string, bool) {
+	year, month, date := day.Date()
+	for _, rule := range publicHolidays[strings.ToUpper(country)] {
+		if (rule.from != 0 && year < rule.from) || (rule.until != 0 && year > rule.until) {
+			continue
+		}
+		m, d := rule.date(year)
+		if m == month && d == date {
+			return rule.name, true
+		}
+	}
+	return "", false
+}
+
+// date returns the month and the day the holiday falls on in a year
+func (r holidayRule) date(year int) (time.Month, int) {
+	switch {
+	case r.easter:
+		easter := westernEaster(year)
+		if r.orthodox {
+			easter = orthodoxEaster(year)
+		}
+		_, month, day := easter.AddDate(0, 0, r.easterOffset).Date()
+		return month, day
+	case r.nth != 0:
+		return r.month, nthWeekday(year, r.month, r.weekday, r.nth)
+	default:
+		return r.month, r.day
+	}
+}
+
+// nthWeekday returns the day of the month of its nth weekday, counting from
+// the end of the month when n is negative
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int) int {
+	if n > 0 {
+		first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		return 1 + (int(weekday)-int(first.Weekday())+7)%7 + (n-1)*7
+	}
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+	return last.Day() - (int(last.Weekday())-int(weekday)+7)%7 + (n+1)*7
+}
+
+// westernEaster returns Easter Sunday of the Gregorian calendar
+// (anonymous Gregorian algorithm)
+func westernEaster(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// orthodoxEaster returns Orthodox Easter Sunday as a Gregorian date (Meeus'
+// Julian algorithm, shifted by the 13 days between the calendars in 1900-2099)
+func orthodoxEaster(year int) time.Time {
+	a, b, c := year%4, year%7, year%19
+	d := (19*c + 15) % 30
+	e := (2*a + 4*b - d + 34) % 7
+	month := (d + e + 114) / 31
+	day := (d+e+114)%31 + 1
+	return time.Date(year, time.Month(month), day+13, 0, 0, 0, 0, time.UTC)
+}
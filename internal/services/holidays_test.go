@@ -0,0 +1,68 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestEaster(t *testing.T) {
+	tests := []struct {
+		year     int
+		western  time.Time
+		orthodox time.Time
+	}{
+		{2024, date(2024, time.March, 31), date(2024, time.May, 5)},
+		{2025, date(2025, time.April, 20), date(2025, time.April, 20)},
+		{2026, date(2026, time.April, 5), date(2026, time.April, 12)},
+		{2027, date(2027, time.March, 28), date(2027, time.May, 2)},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.western, westernEaster(tt.year), "western %d", tt.year)
+		assert.Equal(t, tt.orthodox, orthodoxEaster(tt.year), "orthodox %d", tt.year)
+	}
+}
+
+func TestPublicHoliday(t *testing.T) {
+	tests := []struct {
+		name    string
+		country string
+		day     time.Time
+		holiday string
+	}{
+		{"fixed date", "FR", date(2025, time.July, 14), "Fête nationale"},
+		{"country code is case-insensitive", "de", date(2025, time.October, 3), "Tag der Deutschen Einheit"},
+		{"relative to western Easter", "DE", date(2025, time.April, 18), "Karfreitag"},
+		{"relative to orthodox Easter", "UA", date(2024, time.June, 23), "Трійця"},
+		{"nth weekday", "US", date(2025, time.November, 27), "Thanksgiving Day"},
+		{"last weekday", "GB", date(2025, time.May, 26), "Spring bank holiday"},
+		{"rule before it was introduced", "PL", date(2024, time.December, 24), ""},
+		{"rule after it was introduced", "PL", date(2025, time.December, 24), "Wigilia"},
+		{"rule after it was abolished", "UA", date(2024, time.January, 7), ""},
+		{"local time keeps its calendar date", "US", time.Date(2025, time.July, 4, 23, 30, 0, 0, time.FixedZone("EDT", -4*3600)), "Independence Day"},
+		{"working day", "ES", date(2025, time.March, 3), ""},
+		{"unknown country", "ZZ", date(2025, time.January, 1), ""},
+		{"no country", "", date(2025, time.January, 1), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, ok := PublicHoliday(tt.country, tt.day)
+			assert.Equal(t, tt.holiday != "", ok)
+			assert.Equal(t, tt.holiday, name)
+		})
+	}
+}
+
+func TestHasHolidayCalendar(t *testing.T) {
+	assert.True(t, HasHolidayCalendar("UA"))
+	assert.True(t, HasHolidayCalendar("us"))
+	assert.False(t, HasHolidayCalendar("Ukraine"))
+	assert.False(t, HasHolidayCalendar(""))
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+const (
+	// MaxInlineResults is how many places an inline query offers
+	MaxInlineResults = 5
+
+	// InlineQueryResultCacheTime is how long inline results stay fresh: the
+	// weather of a place is kept in Redis between the keystrokes of inline
+	// queries, which arrive for every character typed, and Telegram may reuse
+	// an answer for as long
+	InlineQueryResultCacheTime = 5 * time.Minute
+	inlineWeatherKeyPrefix     = "weather:inline:"
+)
+
+// InlineWeather is the current weather of one place offered as an inline
+// query result
+type InlineWeather struct {
+	Location weather.Location
+	Weather  *WeatherData
+}
+
+// SearchInlineWeather returns the current weather of up to MaxInlineResults
+// places matching query, one per geocoding match. Places whose weather cannot
+// be fetched are left out.
+func (s *WeatherService) SearchInlineWeather(ctx context.Context, query string) ([]InlineWeather, error) {
+	var locations []weather.Location
+	if len(s.providers) > 0 {
+		found, err := s.searchLocations(ctx, query, MaxInlineResults)
+		if err != nil {
+			s.logger.Debug().Err(err).Str("query", query).Msg("Inline place search failed")
+		}
+		locations = appendDistinctPlaces(nil, found)
+	}
+	if len(locations) == 0 {
+		// GeocodeLocation also tries Nominatim and the geocoding cache
+		location, err := s.GeocodeLocation(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		locations = []weather.Location{*location}
+	}
+	if len(locations) > MaxInlineResults {
+		locations = locations[:MaxInlineResults]
+	}
+
+	results := make([]InlineWeather, len(locations))
+	var group errgroup.Group
+	for i, location := range locations {
+		group.Go(func() error {
+			current, err := s.GetInlineWeather(ctx, location.Latitude, location.Longitude)
+			if err != nil {
+				s.logger.Warn().Err(err).Str("location", location.Name).Msg("Failed to get weather for inline result")
+				return nil
+			}
+			current.LocationName = location.Name
+			current.Location = &location
+			results[i] = InlineWeather{Location: location, Weather: current}
+			return nil
+		})
+	}
+	_ = group.Wait()
+
+	found := results[:0]
+	for _, result := range results {
+		if result.Weather != nil {
+			found = append(found, result)
+		}
+	}
+	return found, nil
+}
+
+// GetInlineWeather returns the current weather and air quality at the
+// coordinates, cached together for InlineQueryResultCacheTime
+func (s *WeatherService) GetInlineWeather(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	cacheKey := localizedCacheKey(ctx, fmt.Sprintf("%s%.4f:%.4f", inlineWeatherKeyPrefix, lat, lon))
+	if cached, err := s.redis.Get(ctx, cacheKey).Result(); err == nil {
+		var current WeatherData
+		if err := json.Unmarshal([]byte(cached), &current); err == nil {
+			current.FromCache = true
+			return &current, nil
+		}
+	}
+
+	current, err := s.GetCompleteWeatherData(ctx, lat, lon)
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := json.Marshal(current); err == nil {
+		if err := s.redis.Set(ctx, cacheKey, data, InlineQueryResultCacheTime).Err(); err != nil {
+			s.logger.Warn().Err(err).Str("cache_key", cacheKey).Msg("Failed to cache inline weather")
+		}
+	}
+	return current, nil
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+func TestWeatherService_GetInlineWeather(t *testing.T) {
+	ctx := context.Background()
+	logger := zerolog.Nop()
+
+	t.Run("answers from the inline cache", func(t *testing.T) {
+		rdb, mock := redismock.NewClientMock()
+		service := NewWeatherService(&config.WeatherConfig{}, rdb, &logger)
+
+		cached, _ := json.Marshal(WeatherData{Temperature: 12.5, AQI: 2})
+		mock.ExpectGet("weather:inline:50.4500:30.5200").SetVal(string(cached))
+
+		current, err := service.GetInlineWeather(ctx, 50.45, 30.52)
+		require.NoError(t, err)
+		assert.Equal(t, 12.5, current.Temperature)
+		assert.True(t, current.FromCache)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("caches weather and air quality together on a miss", func(t *testing.T) {
+		rdb, mock := redismock.NewClientMock()
+		service := NewWeatherService(&config.WeatherConfig{}, rdb, &logger)
+
+		current, _ := json.Marshal(weather.WeatherData{Temperature: 8})
+		air, _ := json.Marshal(weather.AirQualityData{AQI: 3})
+		mock.ExpectGet("weather:inline:50.4500:30.5200").RedisNil()
+		mock.ExpectGet("weather:current:50.45:30.52").SetVal(string(current))
+		mock.ExpectGet("weather:air:50.45:30.52").SetVal(string(air))
+		mock.Regexp().ExpectSet("weather:inline:50.4500:30.5200", `.+`, InlineQueryResultCacheTime).SetVal("OK")
+
+		result, err := service.GetInlineWeather(ctx, 50.45, 30.52)
+		require.NoError(t, err)
+		assert.Equal(t, 8.0, result.Temperature)
+		assert.Equal(t, 3, result.AQI)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
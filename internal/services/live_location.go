@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+)
+
+// ErrLiveLocationEnded is returned when following a live location that is no
+// longer shared, or that another live location in the chat replaced
+var ErrLiveLocationEnded = errors.New("live location no longer shared")
+
+const (
+	liveLocationKeyPrefix = "live_location:"
+
+	// liveLocationCheckInterval is the shortest interval between two weather
+	// checks of a followed live location; Telegram moves it every few seconds
+	liveLocationCheckInterval = 10 * time.Minute
+
+	// maxLivePeriod caps how long a live location is followed. Telegram sends
+	// 0x7FFFFFFF for live locations shared until the user stops them.
+	maxLivePeriod = 24 * time.Hour
+)
+
+// LiveLocation is a live location shared with the bot, kept while Telegram
+// keeps updating it
+type LiveLocation struct {
+	UserID    int64     `json:"user_id"`
+	ChatID    int64     `json:"chat_id"`
+	MessageID int64     `json:"message_id"` // The location message Telegram edits as the user moves
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Until     time.Time `json:"until"`      // UTC, when the live period ends
+	Following bool      `json:"following"`  // The user asked to hear about weather changes
+	Condition string    `json:"condition"`  // Condition group at the last check
+	AQIKey    string    `json:"aqi_key"`    // AQIDescriptionKey at the last check; empty without air quality
+	CheckedAt time.Time `json:"checked_at"` // UTC, when the weather was last checked
+}
+
+// LiveWeatherChange is a material change of the weather at a followed live
+// location: another kind of weather, or another air quality category
+type LiveWeatherChange struct {
+	Current          *WeatherData
+	ConditionChanged bool
+	AQIChanged       bool
+}
+
+// CurrentWeatherSource provides the current weather that followed live
+// locations are checked against
+type CurrentWeatherSource interface {
+	GetCurrentWeatherByCoords(ctx context.Context, lat, lon float64) (*WeatherData, error)
+}
+
+// LiveLocationService follows live locations users share, so that they hear
+// about weather changes along the way. Live locations are kept in Redis until
+// their live period ends, so following stops on its own.
+type LiveLocationService struct {
+	redis   *redis.Client
+	weather CurrentWeatherSource
+	logger  *zerolog.Logger
+	now     func() time.Time
+}
+
+func NewLiveLocationService(redis *redis.Client, weather CurrentWeatherSource, logger *zerolog.Logger) *LiveLocationService {
+	return &LiveLocationService{
+		redis:   redis,
+		weather: weather,
+		logger:  logger,
+		now:     time.Now,
+	}
+}
+
+// LivePeriodEnd returns when a live location sent at sentAt stops updating
+func LivePeriodEnd(sentAt time.Time, livePeriodSeconds int64) time.Time {
+	period := min(time.Duration(livePeriodSeconds)*time.Second, maxLivePeriod)
+	return sentAt.Add(period).UTC()
+}
+
+// Share remembers a live location the user just shared in the chat, replacing
+// any earlier one. It is not followed until Follow is called.
+func (s *LiveLocationService) Share(ctx context.Context, userID, chatID, messageID int64, lat, lon float64, until time.Time) error {
+	return s.save(ctx, &LiveLocation{
+		UserID:    userID,
+		ChatID:    chatID,
+		MessageID: messageID,
+		Latitude:  lat,
+		Longitude: lon,
+		Until:     until.UTC(),
+	})
+}
+
+// Get returns the user's live location in the chat, or nil when none is shared
+func (s *LiveLocationService) Get(ctx context.Context, userID, chatID int64) (*LiveLocation, error) {
+	data, err := s.redis.Get(ctx, liveLocationKey(userID, chatID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load live location: %w", err)
+	}
+
+	var live LiveLocation
+	if err := json.Unmarshal(data, &live); err != nil {
+		return nil, fmt.Errorf("failed to decode live location: %w", err)
+	}
+	return &live, nil
+}
+
+// Follow starts following the live location of a message and returns it with
+// the current weather there, which later changes are measured against
+func (s *LiveLocationService) Follow(ctx context.Context, userID, chatID, messageID int64) (*LiveLocation, *WeatherData, error) {
+	live, err := s.Get(ctx, userID, chatID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if live == nil || live.MessageID != messageID {
+		return nil, nil, ErrLiveLocationEnded
+	}
+
+	current, err := s.weather.GetCurrentWeatherByCoords(ctx, live.Latitude, live.Longitude)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get weather for live location: %w", err)
+	}
+
+	live.Following = true
+	live.record(current, s.now().UTC())
+	if err := s.save(ctx, live); err != nil {
+		return nil, nil, err
+	}
+	return live, current, nil
+}
+
+// Move stores where a live location is now. For a followed live location it
+// checks the weather there at most every liveLocationCheckInterval and
+// returns the change when it is material; otherwise it returns nil.
+func (s *LiveLocationService) Move(ctx context.Context, userID, chatID, messageID int64, lat, lon float64) (*LiveWeatherChange, error) {
+	live, err := s.Get(ctx, userID, chatID)
+	if err != nil || live == nil || live.MessageID != messageID {
+		return nil, err
+	}
+
+	live.Latitude, live.Longitude = lat, lon
+	now := s.now().UTC()
+
+	var change *LiveWeatherChange
+	if live.Following && now.Sub(live.CheckedAt) >= liveLocationCheckInterval {
+		current, err := s.weather.GetCurrentWeatherByCoords(ctx, lat, lon)
+		if err != nil {
+			// The next move tries again; the position is kept either way
+			s.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to check weather at live location")
+		} else {
+			change = live.compare(current)
+			live.record(current, now)
+		}
+	}
+
+	if err := s.save(ctx, live); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// Stop forgets the user's live location in the chat and returns it, or nil
+// when none was shared
+func (s *LiveLocationService) Stop(ctx context.Context, userID, chatID int64) (*LiveLocation, error) {
+	live, err := s.Get(ctx, userID, chatID)
+	if err != nil || live == nil {
+		return nil, err
+	}
+	if err := s.redis.Del(ctx, liveLocationKey(userID, chatID)).Err(); err != nil {
+		return nil, fmt.Errorf("failed to delete live location: %w", err)
+	}
+	return live, nil
+}
+
+// save stores a live location until its live period ends; one that has ended
+// already is dropped
+func (s *LiveLocationService) save(ctx context.Context, live *LiveLocation) error {
+	key := liveLocationKey(live.UserID, live.ChatID)
+	ttl := live.Until.Sub(s.now())
+	if ttl <= 0 {
+		return s.redis.Del(ctx, key).Err()
+	}
+
+	data, err := json.Marshal(live)
+	if err != nil {
+		return fmt.Errorf("failed to encode live location: %w", err)
+	}
+	if err := s.redis.Set(ctx, key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save live location: %w", err)
+	}
+	return nil
+}
+
+// record keeps what later weather at the live location is compared with
+func (l *LiveLocation) record(current *WeatherData, at time.Time) {
+	l.Condition = conditionGroup(current.Icon)
+	l.AQIKey = ""
+	if current.AQI > 0 {
+		l.AQIKey = AQIDescriptionKey(current.AQI)
+	}
+	l.CheckedAt = at
+}
+
+// compare returns how the weather changed since the last check, or nil when
+// it did not change materially. Air quality that could not be read does not
+// count as a change.
+func (l *LiveLocation) compare(current *WeatherData) *LiveWeatherChange {
+	change := &LiveWeatherChange{Current: current}
+	change.ConditionChanged = conditionGroup(current.Icon) != l.Condition
+	if current.AQI > 0 && l.AQIKey != "" {
+		change.AQIChanged = AQIDescriptionKey(current.AQI) != l.AQIKey
+	}
+	if !change.ConditionChanged && !change.AQIChanged {
+		return nil
+	}
+	return change
+}
+
+// conditionGroups sorts the condition part of provider icon codes into the
+// kinds of weather a traveller cares about; clear and a few clouds, say, are
+// not worth a message
+var conditionGroups = map[string]string{
+	"01": "clear",
+	"02": "clear",
+	"03": "clouds",
+	"04": "clouds",
+	"09": "rain",
+	"10": "rain",
+	"11": "storm",
+	"13": "snow",
+	"50": "fog",
+}
+
+// conditionGroup returns the kind of weather of a provider icon code such as "10d"
+func conditionGroup(icon string) string {
+	if len(icon) >= 2 {
+		if group, ok := conditionGroups[icon[:2]]; ok {
+			return group
+		}
+	}
+	return icon
+}
+
+func liveLocationKey(userID, chatID int64) string {
+	return fmt.Sprintf("%s%d:%d", liveLocationKeyPrefix, chatID, userID)
+}
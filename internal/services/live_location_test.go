@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+type fakeCurrentWeather struct {
+	current *WeatherData
+	calls   int
+}
+
+func (f *fakeCurrentWeather) GetCurrentWeatherByCoords(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	f.calls++
+	return f.current, nil
+}
+
+func TestLiveLocation_Compare(t *testing.T) {
+	live := &LiveLocation{}
+	live.record(&WeatherData{Icon: "10d", AQI: 40}, time.Time{})
+
+	tests := []struct {
+		name      string
+		current   *WeatherData
+		condition bool
+		aqi       bool
+	}{
+		{"light to heavy rain", &WeatherData{Icon: "09n", AQI: 45}, false, false},
+		{"rain to snow", &WeatherData{Icon: "13d", AQI: 45}, true, false},
+		{"good to moderate air", &WeatherData{Icon: "10d", AQI: 80}, false, true},
+		{"air quality unavailable", &WeatherData{Icon: "10d"}, false, false},
+		{"both", &WeatherData{Icon: "11d", AQI: 160}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := live.compare(tt.current)
+			if !tt.condition && !tt.aqi {
+				assert.Nil(t, change)
+				return
+			}
+			require.NotNil(t, change)
+			assert.Equal(t, tt.condition, change.ConditionChanged)
+			assert.Equal(t, tt.aqi, change.AQIChanged)
+		})
+	}
+}
+
+func TestLivePeriodEnd(t *testing.T) {
+	sentAt := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	assert.Equal(t, sentAt.Add(time.Hour), LivePeriodEnd(sentAt, 3600))
+	// Shared until stopped
+	assert.Equal(t, sentAt.Add(maxLivePeriod), LivePeriodEnd(sentAt, 0x7FFFFFFF))
+}
+
+func TestLiveLocationService_FollowAndMove(t *testing.T) {
+	mockRedis := helpers.NewMockRedis()
+	source := &fakeCurrentWeather{current: &WeatherData{Icon: "01d", AQI: 30}}
+	logger := helpers.NewSilentTestLogger()
+	service := NewLiveLocationService(mockRedis.Client, source, logger)
+	now := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+
+	const key = "live_location:-100:123"
+	live := LiveLocation{UserID: 123, ChatID: -100, MessageID: 7, Latitude: 50.45, Longitude: 30.52, Until: now.Add(time.Hour)}
+	encode := func(l LiveLocation) string {
+		data, err := json.Marshal(l)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	mockRedis.Mock.ExpectSet(key, []byte(encode(live)), time.Hour).SetVal("OK")
+	require.NoError(t, service.Share(context.Background(), 123, -100, 7, 50.45, 30.52, now.Add(time.Hour)))
+
+	// Another message's button no longer follows anything
+	mockRedis.Mock.ExpectGet(key).SetVal(encode(live))
+	_, _, err := service.Follow(context.Background(), 123, -100, 6)
+	assert.ErrorIs(t, err, ErrLiveLocationEnded)
+
+	mockRedis.Mock.ExpectGet(key).SetVal(encode(live))
+	followed := live
+	followed.Following, followed.Condition, followed.AQIKey, followed.CheckedAt = true, "clear", "aqi_good", now
+	mockRedis.Mock.ExpectSet(key, []byte(encode(followed)), time.Hour).SetVal("OK")
+	_, current, err := service.Follow(context.Background(), 123, -100, 7)
+	require.NoError(t, err)
+	assert.Equal(t, "01d", current.Icon)
+
+	// Moving soon after the last check keeps the position without asking for weather
+	now = now.Add(5 * time.Minute)
+	mockRedis.Mock.ExpectGet(key).SetVal(encode(followed))
+	moved := followed
+	moved.Latitude = 50.50
+	mockRedis.Mock.ExpectSet(key, []byte(encode(moved)), 55*time.Minute).SetVal("OK")
+	change, err := service.Move(context.Background(), 123, -100, 7, 50.50, 30.52)
+	require.NoError(t, err)
+	assert.Nil(t, change)
+	assert.Equal(t, 1, source.calls)
+
+	// Into the rain
+	now = now.Add(10 * time.Minute)
+	source.current = &WeatherData{Icon: "10d", AQI: 35}
+	mockRedis.Mock.ExpectGet(key).SetVal(encode(moved))
+	rained := moved
+	rained.Latitude, rained.Condition, rained.CheckedAt = 50.60, "rain", now
+	mockRedis.Mock.ExpectSet(key, []byte(encode(rained)), 45*time.Minute).SetVal("OK")
+	change, err = service.Move(context.Background(), 123, -100, 7, 50.60, 30.52)
+	require.NoError(t, err)
+	require.NotNil(t, change)
+	assert.True(t, change.ConditionChanged)
+	assert.False(t, change.AQIChanged)
+
+	mockRedis.ExpectationsWereMet(t)
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LocalizationReport is the result of a consistency check of the bundled
+// translations against the default language and the keys used in code.
+// Findings about one form of a plural entry name it as key.category.
+type LocalizationReport struct {
+	Language          string              // Default language every other one is compared with
+	MissingReferenced []string            // Keys used in code that the default language lacks
+	Missing           map[string][]string // Per language, default language keys it lacks
+	Extra             map[string][]string // Per language, keys the default language lacks
+	VerbMismatches    map[string][]string // Per language, keys whose format verbs differ from the default
+}
+
+// Problems counts every finding of the report
+func (r *LocalizationReport) Problems() int {
+	problems := len(r.MissingReferenced)
+	for _, findings := range []map[string][]string{r.Missing, r.Extra, r.VerbMismatches} {
+		for _, keys := range findings {
+			problems += len(keys)
+		}
+	}
+	return problems
+}
+
+func (r *LocalizationReport) String() string {
+	count := func(findings map[string][]string) int {
+		total := 0
+		for _, keys := range findings {
+			total += len(keys)
+		}
+		return total
+	}
+	return fmt.Sprintf("%d referenced keys missing from %s, %d missing, %d extra, %d verb mismatches",
+		len(r.MissingReferenced), r.Language, count(r.Missing), count(r.Extra), count(r.VerbMismatches))
+}
+
+// Languages returns the languages with findings, sorted
+func (r *LocalizationReport) Languages() []string {
+	seen := make(map[string]bool)
+	for _, findings := range []map[string][]string{r.Missing, r.Extra, r.VerbMismatches} {
+		for language := range findings {
+			seen[language] = true
+		}
+	}
+	languages := make([]string, 0, len(seen))
+	for language := range seen {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// CheckConsistency compares the bundled translations of every language with
+// the default language: keys it lacks or adds, and strings whose format verbs
+// differ, which would garble the message at runtime. referenced are the keys
+// the code uses, usually locales.ReferencedKeys; those the default language
+// lacks are reported separately since users would see the raw key.
+// Template overrides are not checked here; ReloadOverrides rejects bad ones.
+func (ls *LocalizationService) CheckConsistency(referenced []string) *LocalizationReport {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	report := &LocalizationReport{
+		Language:       ls.defaultLanguage,
+		Missing:        make(map[string][]string),
+		Extra:          make(map[string][]string),
+		VerbMismatches: make(map[string][]string),
+	}
+
+	base, basePlurals := ls.translations[ls.defaultLanguage], ls.plurals[ls.defaultLanguage]
+	for _, key := range referenced {
+		_, ok := base[key]
+		if _, plural := basePlurals[key]; !ok && !plural {
+			report.MissingReferenced = append(report.MissingReferenced, key)
+		}
+	}
+	sort.Strings(report.MissingReferenced)
+
+	for language, translation := range ls.translations {
+		plurals := ls.plurals[language]
+		// The forms of the default language are checked against its own other
+		// form, since every form is given the same arguments
+		for key, baseForms := range basePlurals {
+			report.checkPlural(language, key, baseForms[PluralOther], plurals, translation)
+		}
+		if language == ls.defaultLanguage {
+			continue
+		}
+
+		for key, baseText := range base {
+			text, ok := translation[key]
+			_, plural := plurals[key]
+			switch {
+			case plural:
+				report.VerbMismatches[language] = append(report.VerbMismatches[language], key)
+			case !ok:
+				report.Missing[language] = append(report.Missing[language], key)
+			case !sameFormatVerbs(baseText, text):
+				report.VerbMismatches[language] = append(report.VerbMismatches[language], key)
+			}
+		}
+		for key := range translation {
+			_, plural := basePlurals[key]
+			if _, ok := base[key]; !ok && !plural {
+				report.Extra[language] = append(report.Extra[language], key)
+			}
+		}
+		for key := range plurals {
+			_, plural := basePlurals[key]
+			if _, ok := base[key]; !ok && !plural {
+				report.Extra[language] = append(report.Extra[language], key)
+			}
+		}
+	}
+
+	for _, findings := range []map[string][]string{report.Missing, report.Extra, report.VerbMismatches} {
+		for _, keys := range findings {
+			sort.Strings(keys)
+		}
+	}
+	return report
+}
+
+// checkPlural checks a plural entry of the default language in another
+// language: it needs a form for each plural category of the language, and
+// every form must take the arguments of the default language's other form
+func (r *LocalizationReport) checkPlural(language, key, baseText string, plurals PluralTranslation, translation Translation) {
+	forms, ok := plurals[key]
+	if !ok {
+		if _, ok := translation[key]; ok {
+			r.VerbMismatches[language] = append(r.VerbMismatches[language], key)
+		} else {
+			r.Missing[language] = append(r.Missing[language], key)
+		}
+		return
+	}
+
+	for _, category := range pluralCategories(language) {
+		if _, ok := forms[category]; !ok {
+			r.Missing[language] = append(r.Missing[language], key+"."+category)
+		}
+	}
+	for category, text := range forms {
+		if !sameFormatVerbs(baseText, text) {
+			r.VerbMismatches[language] = append(r.VerbMismatches[language], key+"."+category)
+		}
+	}
+}
+
+// formatVerbs returns the fmt verbs of a format string, each tagged with the
+// argument it formats, e.g. "1:s" and "2:d"; "%%" is not a verb. Arguments
+// are numbered as fmt does, so "%[2]s %[1]d" and "%d %s" differ only in order.
+func formatVerbs(format string) []string {
+	var verbs []string
+	argument := 1
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		// Flags, width, precision and argument indexes
+		for i < len(format) && strings.IndexByte("+-# 0123456789.*[]", format[i]) >= 0 {
+			if format[i] == '[' {
+				if end := strings.IndexByte(format[i:], ']'); end > 0 {
+					if n, err := strconv.Atoi(format[i+1 : i+end]); err == nil {
+						argument = n
+					}
+				}
+			}
+			i++
+		}
+		if i < len(format) && format[i] != '%' {
+			verbs = append(verbs, strconv.Itoa(argument)+":"+string(format[i]))
+			argument++
+		}
+	}
+	return verbs
+}
+
+// sameFormatVerbs reports whether two strings format the same arguments with
+// the same verbs. Translations may reorder arguments with explicit indexes,
+// so the order of the verbs does not matter, and %v stands for any verb.
+func sameFormatVerbs(base, translation string) bool {
+	baseVerbs, verbs := formatVerbs(base), formatVerbs(translation)
+	if len(baseVerbs) != len(verbs) {
+		return false
+	}
+	sort.Strings(baseVerbs)
+	sort.Strings(verbs)
+	for i := range baseVerbs {
+		if baseVerbs[i] != verbs[i] && !sameArgumentAnyVerb(baseVerbs[i], verbs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameArgumentAnyVerb reports whether two tagged verbs format the same
+// argument and one of them is %v, which positional placeholders expand into
+// and which formats an argument of any type
+func sameArgumentAnyVerb(a, b string) bool {
+	argumentA, verbA, _ := strings.Cut(a, ":")
+	argumentB, verbB, _ := strings.Cut(b, ":")
+	return argumentA == argumentB && (verbA == "v" || verbB == "v")
+}
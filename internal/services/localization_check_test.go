@@ -0,0 +1,165 @@
+package services
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/locales"
+	"github.com/valpere/shopogoda/internal/locales/keyscan"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+// TestLocalization_ReferencedKeysExist fails when code uses a key the default
+// language lacks. After adding a key, run go generate ./internal/locales and
+// add its text to the locale files.
+func TestLocalization_ReferencedKeysExist(t *testing.T) {
+	service := NewLocalizationService(helpers.NewSilentTestLogger())
+	require.NoError(t, service.LoadTranslations(locales.LocalesFS))
+
+	report := service.CheckConsistency(locales.ReferencedKeys)
+	assert.Empty(t, report.MissingReferenced, "keys used in code but missing from %s", report.Language)
+}
+
+// TestLocalization_ReferencedKeysGenerated fails when a key used in code is
+// missing from referenced_keys.go, or one listed there is no longer used. Run
+// go generate ./internal/locales to bring it up to date.
+func TestLocalization_ReferencedKeysGenerated(t *testing.T) {
+	keys, err := keyscan.Scan("../../internal", "../../cmd")
+	require.NoError(t, err)
+
+	generated := make(map[string]bool, len(locales.ReferencedKeys))
+	for _, key := range locales.ReferencedKeys {
+		generated[key] = true
+	}
+	var missing []string
+	for _, key := range keys {
+		if !generated[key] {
+			missing = append(missing, key)
+		}
+		delete(generated, key)
+	}
+	assert.Empty(t, missing, "keys used in code but missing from referenced_keys.go")
+	assert.Empty(t, generated, "keys in referenced_keys.go no longer used in code")
+}
+
+// TestLocalization_UkrainianComplete fails when a key of the default language
+// has no Ukrainian text, or one whose format verbs differ
+func TestLocalization_UkrainianComplete(t *testing.T) {
+	service := NewLocalizationService(helpers.NewSilentTestLogger())
+	require.NoError(t, service.LoadTranslations(locales.LocalesFS))
+	require.True(t, service.IsLanguageSupported("uk-UA"))
+
+	report := service.CheckConsistency(nil)
+	assert.Empty(t, report.Missing["uk-UA"], "keys missing from uk-UA")
+	assert.Empty(t, report.VerbMismatches["uk-UA"], "uk-UA keys whose format verbs differ from %s", report.Language)
+}
+
+func TestLocalizationService_CheckConsistency(t *testing.T) {
+	service := NewLocalizationService(helpers.NewSilentTestLogger())
+	require.NoError(t, service.LoadTranslations(fstest.MapFS{
+		"languages.json": &fstest.MapFile{Data: []byte(`{
+			"en-US": {"code": "en-US", "name": "English", "flag": "🇺🇸"},
+			"uk-UA": {"code": "uk-UA", "name": "Ukrainian", "flag": "🇺🇦"},
+			"de-DE": {"code": "de-DE", "name": "Deutsch", "flag": "🇩🇪"}
+		}`)},
+		"en-US.json": &fstest.MapFile{Data: []byte(`{
+			"greeting": "Hello, %s!",
+			"temperature": "%.1f°C in %s",
+			"records": "%d records",
+			"weather": "Weather"
+		}`)},
+		"uk-UA.json": &fstest.MapFile{Data: []byte(`{
+			"greeting": "Привіт, %s!",
+			"temperature": "%[2]s: %[1].1f°C",
+			"records": "Записи",
+			"weather": "Погода",
+			"stale": "Застаріле"
+		}`)},
+		"de-DE.json": &fstest.MapFile{Data: []byte(`{
+			"greeting": "Hallo, %d!",
+			"temperature": "%.1f°C in %s"
+		}`)},
+	}))
+
+	report := service.CheckConsistency([]string{"weather", "greeting", "forecast_title"})
+
+	assert.Equal(t, "en-US", report.Language)
+	assert.Equal(t, []string{"forecast_title"}, report.MissingReferenced)
+	assert.Equal(t, map[string][]string{"de-DE": {"records", "weather"}}, report.Missing)
+	assert.Equal(t, map[string][]string{"uk-UA": {"stale"}}, report.Extra)
+	assert.Equal(t, map[string][]string{
+		"uk-UA": {"records"},
+		"de-DE": {"greeting"},
+	}, report.VerbMismatches)
+	assert.Equal(t, 6, report.Problems())
+	assert.Equal(t, []string{"de-DE", "uk-UA"}, report.Languages())
+	assert.Equal(t, "1 referenced keys missing from en-US, 2 missing, 1 extra, 2 verb mismatches", report.String())
+}
+
+func TestLocalizationService_CheckConsistency_Plurals(t *testing.T) {
+	service := NewLocalizationService(helpers.NewSilentTestLogger())
+	require.NoError(t, service.LoadTranslations(fstest.MapFS{
+		"languages.json": &fstest.MapFile{Data: []byte(`{
+			"en-US": {"code": "en-US", "name": "English", "flag": "🇺🇸"},
+			"uk-UA": {"code": "uk-UA", "name": "Ukrainian", "flag": "🇺🇦"},
+			"de-DE": {"code": "de-DE", "name": "Deutsch", "flag": "🇩🇪"}
+		}`)},
+		"en-US.json": &fstest.MapFile{Data: []byte(`{
+			"users": {"one": "%d user", "other": "%d users"},
+			"alerts": {"one": "%d alert", "other": "%d alerts"},
+			"weather": "Weather"
+		}`)},
+		"uk-UA.json": &fstest.MapFile{Data: []byte(`{
+			"users": {"one": "%d користувач", "few": "%d користувачі", "other": "%d користувача"},
+			"alerts": {"one": "%d сповіщення", "few": "%d сповіщення", "many": "%s сповіщень", "other": "%d сповіщення"},
+			"weather": {"one": "Погода", "other": "Погода"},
+			"stale": {"other": "%d"}
+		}`)},
+		"de-DE.json": &fstest.MapFile{Data: []byte(`{
+			"users": "%d Benutzer",
+			"weather": "Wetter"
+		}`)},
+	}))
+
+	report := service.CheckConsistency([]string{"users", "weather"})
+
+	assert.Empty(t, report.MissingReferenced)
+	assert.Equal(t, map[string][]string{
+		"uk-UA": {"users.many"},
+		"de-DE": {"alerts"},
+	}, report.Missing)
+	assert.Equal(t, map[string][]string{"uk-UA": {"stale"}}, report.Extra)
+	assert.Equal(t, map[string][]string{
+		"uk-UA": {"alerts.many", "weather"},
+		"de-DE": {"users"},
+	}, report.VerbMismatches)
+}
+
+func TestSameFormatVerbs(t *testing.T) {
+	tests := []struct {
+		base        string
+		translation string
+		want        bool
+	}{
+		{"Weather", "Погода", true},
+		{"Hello, %s!", "Привіт, %s!", true},
+		{"%d%% off", "-%d%%", true},
+		{"%.1f°C in %s", "%[2]s: %[1].1f°C", true},
+		{"%s in %s", "%[2]s in %[1]s", true},
+		{"Hello, %s!", "Hallo, %d!", false},
+		{"%d records", "Записи", false},
+		{"Alert", "Alert %s", false},
+		{"%.1f°C in %s", "%s: %.1f°C", false},
+		{"50%", "50 %", true},
+		{"%s in %s", "%[2]v in %[1]v", true},
+		{"%.1f°C in %s", "%[2]v: %[1]v°C", true},
+		{"%s in %s", "%[2]v in %[2]v", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, sameFormatVerbs(tt.base, tt.translation), "%q vs %q", tt.base, tt.translation)
+	}
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// Message types the override footer can be appended to
+const (
+	MessageTypeWeather = "weather"
+	MessageTypeDigest  = "digest"
+)
+
+// footerKey is the translation appended to the message types listed in the
+// templates config. The bundled text is empty, so only deployments that
+// override it get a footer.
+const footerKey = "message_footer"
+
+// OverrideReport summarizes one load of template overrides
+type OverrideReport struct {
+	Loaded   int      // Overrides now in effect
+	Rejected []string // "language/key" entries whose format verbs differ from the base string
+	Unknown  []string // "language/key" entries with no base string, or files for unsupported languages
+}
+
+func (r OverrideReport) String() string {
+	return fmt.Sprintf("%d loaded, %d rejected, %d unknown", r.Loaded, len(r.Rejected), len(r.Unknown))
+}
+
+// SetOverrideDir sets the directory ReloadOverrides reads template overrides
+// from; empty disables them
+func (ls *LocalizationService) SetOverrideDir(dir string) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	ls.overrideDir = dir
+}
+
+// SetFooterMessageTypes sets the message types that get the override footer
+func (ls *LocalizationService) SetFooterMessageTypes(types []string) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	ls.footerTypes = make(map[string]bool, len(types))
+	for _, messageType := range types {
+		ls.footerTypes[strings.ToLower(strings.TrimSpace(messageType))] = true
+	}
+}
+
+// ReloadOverrides reads the template overrides from the override directory
+// again and swaps them in. With no directory set the overrides are cleared.
+func (ls *LocalizationService) ReloadOverrides() (OverrideReport, error) {
+	ls.mu.RLock()
+	dir := ls.overrideDir
+	ls.mu.RUnlock()
+
+	if dir == "" {
+		ls.mu.Lock()
+		ls.overrides = nil
+		ls.pluralOverrides = nil
+		ls.mu.Unlock()
+		return OverrideReport{}, nil
+	}
+	return ls.LoadOverrides(os.DirFS(dir))
+}
+
+// LoadOverrides replaces the template overrides with the ones in overridesFS:
+// a <language>.json per language in the format of the bundled locales, each
+// holding only the keys it changes; a plural entry is overridden with its
+// forms. An override is rejected when it has a different number of format
+// verbs than the bundled string, and skipped with a warning when the key or
+// language is unknown. If any file can't be read the previous overrides stay
+// in effect.
+func (ls *LocalizationService) LoadOverrides(overridesFS fs.FS) (OverrideReport, error) {
+	var report OverrideReport
+
+	files, err := fs.Glob(overridesFS, "*.json")
+	if err != nil {
+		return report, fmt.Errorf("failed to list template overrides: %w", err)
+	}
+
+	// The bundled strings are only read; hold them still while validating
+	ls.mu.RLock()
+	base, basePlurals := ls.translations, ls.plurals
+	overrides := make(Translations)
+	pluralOverrides := make(map[string]PluralTranslation)
+	for _, file := range files {
+		language := strings.TrimSuffix(file, ".json")
+		if language == "languages" {
+			continue
+		}
+
+		data, err := fs.ReadFile(overridesFS, file)
+		if err != nil {
+			ls.mu.RUnlock()
+			return report, fmt.Errorf("failed to read template overrides %s: %w", file, err)
+		}
+		entries, pluralEntries, err := parseLocale(data)
+		if err != nil {
+			ls.mu.RUnlock()
+			return report, fmt.Errorf("failed to parse template overrides %s: %w", file, err)
+		}
+
+		if _, ok := base[language]; !ok {
+			ls.logger.Warn().Str("file", file).Msg("Template overrides for an unsupported language ignored")
+			report.Unknown = append(report.Unknown, language)
+			continue
+		}
+
+		for key, text := range entries {
+			entry := language + "/" + key
+			baseText, ok := base[language][key]
+			if !ok {
+				baseText, ok = base[ls.defaultLanguage][key]
+			}
+			switch {
+			case !ok:
+				ls.logger.Warn().Str("language", language).Str("key", key).Msg("Template override for an unknown key ignored")
+				report.Unknown = append(report.Unknown, entry)
+			case formatVerbCount(text) != formatVerbCount(baseText):
+				ls.logger.Error().Str("language", language).Str("key", key).
+					Int("verbs", formatVerbCount(text)).
+					Int("expected_verbs", formatVerbCount(baseText)).
+					Msg("Template override rejected: format verbs differ from the bundled string")
+				report.Rejected = append(report.Rejected, entry)
+			default:
+				if overrides[language] == nil {
+					overrides[language] = make(Translation)
+				}
+				overrides[language][key] = text
+				report.Loaded++
+			}
+		}
+
+		// Every form of a plural override takes the arguments of the bundled
+		// other form
+		for key, forms := range pluralEntries {
+			entry := language + "/" + key
+			baseForms, ok := basePlurals[language][key]
+			if !ok {
+				baseForms, ok = basePlurals[ls.defaultLanguage][key]
+			}
+			if !ok {
+				ls.logger.Warn().Str("language", language).Str("key", key).Msg("Template override for an unknown plural key ignored")
+				report.Unknown = append(report.Unknown, entry)
+				continue
+			}
+			rejected := false
+			for category, text := range forms {
+				if formatVerbCount(text) != formatVerbCount(baseForms[PluralOther]) {
+					ls.logger.Error().Str("language", language).Str("key", key).Str("category", category).
+						Int("verbs", formatVerbCount(text)).
+						Int("expected_verbs", formatVerbCount(baseForms[PluralOther])).
+						Msg("Template override rejected: format verbs differ from the bundled string")
+					rejected = true
+				}
+			}
+			if rejected {
+				report.Rejected = append(report.Rejected, entry)
+				continue
+			}
+			if pluralOverrides[language] == nil {
+				pluralOverrides[language] = make(PluralTranslation)
+			}
+			pluralOverrides[language][key] = forms
+			report.Loaded++
+		}
+	}
+	ls.mu.RUnlock()
+
+	ls.mu.Lock()
+	ls.overrides = overrides
+	ls.pluralOverrides = pluralOverrides
+	ls.mu.Unlock()
+
+	ls.logger.Info().
+		Int("loaded", report.Loaded).
+		Int("rejected", len(report.Rejected)).
+		Int("unknown", len(report.Unknown)).
+		Msg("Loaded template overrides")
+	return report, nil
+}
+
+// AppendFooter adds the footer to a message of a type listed in the
+// templates config, unless the footer text is empty
+func (ls *LocalizationService) AppendFooter(ctx context.Context, language, messageType, message string) string {
+	ls.mu.RLock()
+	enabled := ls.footerTypes[messageType]
+	ls.mu.RUnlock()
+	if !enabled {
+		return message
+	}
+
+	footer := ls.T(ctx, language, footerKey)
+	if strings.TrimSpace(footer) == "" || footer == footerKey {
+		return message
+	}
+	return message + "\n\n" + footer
+}
+
+// ParseFooterMessageTypes splits the comma-separated message types of the
+// templates config
+func ParseFooterMessageTypes(list string) []string {
+	var types []string
+	for _, messageType := range strings.Split(list, ",") {
+		if messageType = strings.TrimSpace(messageType); messageType != "" {
+			types = append(types, messageType)
+		}
+	}
+	return types
+}
+
+// formatVerbCount counts the fmt verbs in a format string; "%%" is not one
+func formatVerbCount(format string) int {
+	return len(formatVerbs(format))
+}
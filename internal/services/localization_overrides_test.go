@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+// newOverrideTestService returns a service with two bundled languages
+func newOverrideTestService(t *testing.T) *LocalizationService {
+	t.Helper()
+	service := NewLocalizationService(helpers.NewSilentTestLogger())
+	require.NoError(t, service.LoadTranslations(fstest.MapFS{
+		"languages.json": &fstest.MapFile{Data: []byte(`{
+			"en-US": {"code": "en-US", "name": "English", "flag": "🇺🇸"},
+			"uk-UA": {"code": "uk-UA", "name": "Ukrainian", "flag": "🇺🇦"}
+		}`)},
+		"en-US.json": &fstest.MapFile{Data: []byte(`{
+			"greeting": "Hello, %s!",
+			"weather": "Weather",
+			"discount": "%d%% off",
+			"stations": {"one": "%d station", "other": "%d stations"},
+			"message_footer": ""
+		}`)},
+		"uk-UA.json": &fstest.MapFile{Data: []byte(`{
+			"greeting": "Привіт, %s!",
+			"weather": "Погода"
+		}`)},
+	}))
+	return service
+}
+
+func TestLocalizationService_LoadOverrides(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("overrides take precedence over bundled texts", func(t *testing.T) {
+		service := newOverrideTestService(t)
+
+		report, err := service.LoadOverrides(fstest.MapFS{
+			"en-US.json": &fstest.MapFile{Data: []byte(`{"greeting": "Welcome to Acme, %s!"}`)},
+			"uk-UA.json": &fstest.MapFile{Data: []byte(`{"weather": "Погода від Acme"}`)},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Loaded)
+		assert.Equal(t, "Welcome to Acme, Alex!", service.T(ctx, "en-US", "greeting", "Alex"))
+		assert.Equal(t, "Погода від Acme", service.T(ctx, "uk-UA", "weather"))
+		// Keys without an override keep the bundled text of their language
+		assert.Equal(t, "Привіт, Alex!", service.T(ctx, "uk-UA", "greeting", "Alex"))
+		assert.Equal(t, "Weather", service.T(ctx, "en-US", "weather"))
+	})
+
+	t.Run("override of the default language is used as the fallback", func(t *testing.T) {
+		service := newOverrideTestService(t)
+
+		_, err := service.LoadOverrides(fstest.MapFS{
+			"en-US.json": &fstest.MapFile{Data: []byte(`{"discount": "Save %d%%"}`)},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, "Save 10%", service.T(ctx, "uk-UA", "discount", 10))
+	})
+
+	t.Run("override with different format verbs is rejected", func(t *testing.T) {
+		service := newOverrideTestService(t)
+
+		report, err := service.LoadOverrides(fstest.MapFS{
+			"en-US.json": &fstest.MapFile{Data: []byte(`{
+				"greeting": "Welcome to Acme!",
+				"weather": "Weather for %s",
+				"discount": "%d%% off today"
+			}`)},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Loaded)
+		assert.ElementsMatch(t, []string{"en-US/greeting", "en-US/weather"}, report.Rejected)
+		assert.Equal(t, "Hello, Alex!", service.T(ctx, "en-US", "greeting", "Alex"))
+		assert.Equal(t, "Weather", service.T(ctx, "en-US", "weather"))
+		assert.Equal(t, "10% off today", service.T(ctx, "en-US", "discount", 10))
+	})
+
+	t.Run("unknown keys and languages are ignored", func(t *testing.T) {
+		service := newOverrideTestService(t)
+
+		report, err := service.LoadOverrides(fstest.MapFS{
+			"en-US.json": &fstest.MapFile{Data: []byte(`{"gretting": "Hi"}`)},
+			"pl-PL.json": &fstest.MapFile{Data: []byte(`{"weather": "Pogoda"}`)},
+		})
+
+		require.NoError(t, err)
+		assert.Zero(t, report.Loaded)
+		assert.ElementsMatch(t, []string{"en-US/gretting", "pl-PL"}, report.Unknown)
+		assert.Equal(t, "gretting", service.T(ctx, "en-US", "gretting"))
+	})
+
+	t.Run("plural entries are overridden with their forms", func(t *testing.T) {
+		service := newOverrideTestService(t)
+
+		report, err := service.LoadOverrides(fstest.MapFS{
+			"en-US.json": &fstest.MapFile{Data: []byte(`{
+				"stations": {"one": "%d Acme station", "other": "%d Acme stations"}
+			}`)},
+			"uk-UA.json": &fstest.MapFile{Data: []byte(`{
+				"stations": {"one": "%d станція", "other": "станції"},
+				"statoins": {"other": "%d"}
+			}`)},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Loaded)
+		assert.Equal(t, []string{"uk-UA/stations"}, report.Rejected)
+		assert.Equal(t, []string{"uk-UA/statoins"}, report.Unknown)
+		assert.Equal(t, "1 Acme station", service.TN(ctx, "en-US", "stations", 1))
+		assert.Equal(t, "5 Acme stations", service.TN(ctx, "uk-UA", "stations", 5))
+	})
+
+	t.Run("unparsable file keeps the previous overrides", func(t *testing.T) {
+		service := newOverrideTestService(t)
+		_, err := service.LoadOverrides(fstest.MapFS{
+			"en-US.json": &fstest.MapFile{Data: []byte(`{"weather": "Acme Weather"}`)},
+		})
+		require.NoError(t, err)
+
+		_, err = service.LoadOverrides(fstest.MapFS{
+			"en-US.json": &fstest.MapFile{Data: []byte(`{"weather": `)},
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, "Acme Weather", service.T(ctx, "en-US", "weather"))
+	})
+}
+
+func TestLocalizationService_ReloadOverrides(t *testing.T) {
+	ctx := context.Background()
+	service := newOverrideTestService(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "en-US.json")
+
+	require.NoError(t, os.WriteFile(file, []byte(`{"weather": "Acme Weather"}`), 0o600))
+	service.SetOverrideDir(dir)
+	_, err := service.ReloadOverrides()
+	require.NoError(t, err)
+	assert.Equal(t, "Acme Weather", service.T(ctx, "en-US", "weather"))
+
+	// Editing the file and reloading swaps the text in the running service
+	require.NoError(t, os.WriteFile(file, []byte(`{"weather": "Acme Forecast"}`), 0o600))
+	report, err := service.ReloadOverrides()
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Loaded)
+	assert.Equal(t, "Acme Forecast", service.T(ctx, "en-US", "weather"))
+
+	// Removing the file drops the override
+	require.NoError(t, os.Remove(file))
+	_, err = service.ReloadOverrides()
+	require.NoError(t, err)
+	assert.Equal(t, "Weather", service.T(ctx, "en-US", "weather"))
+
+	// Without a directory the overrides are cleared
+	require.NoError(t, os.WriteFile(file, []byte(`{"weather": "Acme Weather"}`), 0o600))
+	_, err = service.ReloadOverrides()
+	require.NoError(t, err)
+	service.SetOverrideDir("")
+	_, err = service.ReloadOverrides()
+	require.NoError(t, err)
+	assert.Equal(t, "Weather", service.T(ctx, "en-US", "weather"))
+}
+
+func TestLocalizationService_AppendFooter(t *testing.T) {
+	ctx := context.Background()
+	service := newOverrideTestService(t)
+	service.SetFooterMessageTypes(ParseFooterMessageTypes(" weather, "))
+
+	assert.Equal(t, "Sunny", service.AppendFooter(ctx, "en-US", MessageTypeWeather, "Sunny"), "empty bundled footer")
+
+	_, err := service.LoadOverrides(fstest.MapFS{
+		"en-US.json": &fstest.MapFile{Data: []byte(`{"message_footer": "Powered by Acme"}`)},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Sunny\n\nPowered by Acme", service.AppendFooter(ctx, "en-US", MessageTypeWeather, "Sunny"))
+	assert.Equal(t, "Sunny\n\nPowered by Acme", service.AppendFooter(ctx, "uk-UA", MessageTypeWeather, "Sunny"), "falls back to the default language")
+	assert.Equal(t, "Digest", service.AppendFooter(ctx, "en-US", MessageTypeDigest, "Digest"), "message type not configured")
+}
+
+func TestFormatVerbCount(t *testing.T) {
+	tests := []struct {
+		format string
+		want   int
+	}{
+		{"Weather", 0},
+		{"Hello, %s!", 1},
+		{"%.1f°C, feels like %+.1f°C", 2},
+		{"%d%% humidity", 1},
+		{"%[2]s in %[1]s", 2},
+		{"100%", 0},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, formatVerbCount(tt.format), tt.format)
+	}
+}
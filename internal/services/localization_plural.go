@@ -0,0 +1,156 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CLDR plural categories. Every plural entry has an other form, used for the
+// categories it has no form of.
+const (
+	PluralZero  = "zero"
+	PluralOne   = "one"
+	PluralTwo   = "two"
+	PluralFew   = "few"
+	PluralMany  = "many"
+	PluralOther = "other"
+)
+
+// PluralForms are the variants of a message about a count, by plural
+// category. In a locale file they are an object instead of a string:
+//
+//	"admin_stats_total_users" : {
+//	   "one" : "%d user",
+//	   "other" : "%d users"
+//	}
+type PluralForms map[string]string
+
+// PluralTranslation holds the plural entries of a language, by key
+type PluralTranslation map[string]PluralForms
+
+var pluralCategoryNames = map[string]bool{
+	PluralZero: true, PluralOne: true, PluralTwo: true,
+	PluralFew: true, PluralMany: true, PluralOther: true,
+}
+
+// pluralCategory returns the CLDR plural category of an integer count in a
+// language. Languages without rules here get other.
+func pluralCategory(language string, n int64) string {
+	if n < 0 {
+		n = -n
+	}
+	switch baseLanguage(language) {
+	case "en", "de":
+		if n == 1 {
+			return PluralOne
+		}
+	case "fr":
+		switch {
+		case n <= 1:
+			return PluralOne
+		case n%1000000 == 0:
+			return PluralMany // "1 000 000 de messages"
+		}
+	case "es":
+		switch {
+		case n == 1:
+			return PluralOne
+		case n != 0 && n%1000000 == 0:
+			return PluralMany // "1 000 000 de mensajes"
+		}
+	case "uk":
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return PluralOne
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return PluralFew
+		default:
+			return PluralMany
+		}
+	}
+	return PluralOther
+}
+
+// pluralCategories are the categories the counts of a language fall into,
+// which its plural entries need forms for
+func pluralCategories(language string) []string {
+	switch baseLanguage(language) {
+	case "en", "de":
+		return []string{PluralOne, PluralOther}
+	case "fr", "es":
+		return []string{PluralOne, PluralMany, PluralOther}
+	case "uk":
+		return []string{PluralOne, PluralFew, PluralMany, PluralOther}
+	}
+	return []string{PluralOther}
+}
+
+// baseLanguage returns the language subtag of a language tag, e.g. "uk" of "uk-UA"
+func baseLanguage(language string) string {
+	base, _, _ := strings.Cut(language, "-")
+	return strings.ToLower(base)
+}
+
+// form returns the form of a count in a language, or the other form when
+// the entry has none for its category
+func (forms PluralForms) form(language string, n int64) string {
+	if text, ok := forms[pluralCategory(language, n)]; ok {
+		return text
+	}
+	return forms[PluralOther]
+}
+
+// positionalPlaceholder is an argument referred to by position, e.g. {0}
+var positionalPlaceholder = regexp.MustCompile(`\{(\d+)\}`)
+
+// expandPlaceholders turns the positional placeholders of a translation into
+// fmt verbs, {0} into %[1]v, so that they are formatted like any other
+// argument
+func expandPlaceholders(text string) string {
+	return positionalPlaceholder.ReplaceAllStringFunc(text, func(placeholder string) string {
+		n, err := strconv.Atoi(placeholder[1 : len(placeholder)-1])
+		if err != nil {
+			return placeholder
+		}
+		return "%[" + strconv.Itoa(n+1) + "]v"
+	})
+}
+
+// parseLocale reads a locale file: strings are plain translations and
+// objects plural entries. A plural entry needs an other form and only knows
+// the CLDR categories. Positional placeholders are expanded into fmt verbs.
+func parseLocale(data []byte) (Translation, PluralTranslation, error) {
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, nil, err
+	}
+
+	translation := make(Translation, len(entries))
+	plurals := make(PluralTranslation)
+	for key, raw := range entries {
+		var text string
+		if err := json.Unmarshal(raw, &text); err == nil {
+			translation[key] = expandPlaceholders(text)
+			continue
+		}
+
+		var forms PluralForms
+		if err := json.Unmarshal(raw, &forms); err != nil {
+			return nil, nil, fmt.Errorf("%s is neither a string nor plural forms: %w", key, err)
+		}
+		for category, text := range forms {
+			if !pluralCategoryNames[category] {
+				return nil, nil, fmt.Errorf("%s has an unknown plural category %q", key, category)
+			}
+			forms[category] = expandPlaceholders(text)
+		}
+		if _, ok := forms[PluralOther]; !ok {
+			return nil, nil, fmt.Errorf("%s has no %q form", key, PluralOther)
+		}
+		plurals[key] = forms
+	}
+	return translation, plurals, nil
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestPluralCategory(t *testing.T) {
+	tests := []struct {
+		language string
+		n        int64
+		want     string
+	}{
+		{"en-US", 1, PluralOne},
+		{"en-US", 0, PluralOther},
+		{"en-US", 2, PluralOther},
+		{"de-DE", 1, PluralOne},
+		{"de-DE", 21, PluralOther},
+		{"fr-FR", 0, PluralOne},
+		{"fr-FR", 1, PluralOne},
+		{"fr-FR", 2, PluralOther},
+		{"fr-FR", 1000000, PluralMany},
+		{"es-ES", 1, PluralOne},
+		{"es-ES", 0, PluralOther},
+		{"es-ES", 1000000, PluralMany},
+		{"uk-UA", 1, PluralOne},
+		{"uk-UA", 2, PluralFew},
+		{"uk-UA", 5, PluralMany},
+		{"uk-UA", 11, PluralMany},
+		{"uk-UA", 12, PluralMany},
+		{"uk-UA", 21, PluralOne},
+		{"uk-UA", 22, PluralFew},
+		{"uk-UA", 25, PluralMany},
+		{"uk-UA", 112, PluralMany},
+		{"uk-UA", -3, PluralFew},
+		{"ja-JP", 1, PluralOther},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, pluralCategory(tt.language, tt.n), "%s %d", tt.language, tt.n)
+	}
+}
+
+func TestParseLocale(t *testing.T) {
+	t.Run("strings and plural entries", func(t *testing.T) {
+		translation, plurals, err := parseLocale([]byte(`{
+			"weather": "Weather",
+			"users": {"one": "%d user", "other": "%d users"}
+		}`))
+
+		require.NoError(t, err)
+		assert.Equal(t, Translation{"weather": "Weather"}, translation)
+		assert.Equal(t, PluralTranslation{"users": {"one": "%d user", "other": "%d users"}}, plurals)
+	})
+
+	t.Run("positional placeholders become fmt verbs", func(t *testing.T) {
+		translation, plurals, err := parseLocale([]byte(`{
+			"compare": "{1} vs {0}, {x} and {}",
+			"alerts": {"one": "{0} alert in {1}", "other": "%d alerts in {1}"}
+		}`))
+
+		require.NoError(t, err)
+		assert.Equal(t, Translation{"compare": "%[2]v vs %[1]v, {x} and {}"}, translation)
+		assert.Equal(t, PluralTranslation{"alerts": {"one": "%[1]v alert in %[2]v", "other": "%d alerts in %[2]v"}}, plurals)
+	})
+
+	t.Run("invalid entries", func(t *testing.T) {
+		for name, data := range map[string]string{
+			"not json":         `{"weather": `,
+			"number":           `{"weather": 1}`,
+			"unknown category": `{"users": {"single": "%d user", "other": "%d users"}}`,
+			"no other form":    `{"users": {"one": "%d user"}}`,
+		} {
+			_, _, err := parseLocale([]byte(data))
+			assert.Error(t, err, name)
+		}
+	})
+}
+
+func TestLocalizationService_TN(t *testing.T) {
+	ctx := context.Background()
+	service := NewLocalizationService(helpers.NewSilentTestLogger())
+	require.NoError(t, service.LoadTranslations(fstest.MapFS{
+		"languages.json": &fstest.MapFile{Data: []byte(`{
+			"en-US": {"code": "en-US", "name": "English", "flag": "🇺🇸"},
+			"uk-UA": {"code": "uk-UA", "name": "Ukrainian", "flag": "🇺🇦"},
+			"de-DE": {"code": "de-DE", "name": "Deutsch", "flag": "🇩🇪"}
+		}`)},
+		"en-US.json": &fstest.MapFile{Data: []byte(`{
+			"alerts": {"one": "%d alert in %s", "other": "%d alerts in %s"},
+			"days": {"one": "%d day", "other": "%d days"},
+			"range": "from %s to %s"
+		}`)},
+		"uk-UA.json": &fstest.MapFile{Data: []byte(`{
+			"alerts": {
+				"one": "%d сповіщення в %s",
+				"few": "%d сповіщення в %s",
+				"many": "%[2]s: %[1]d сповіщень",
+				"other": "%d сповіщення в %s"
+			},
+			"days": {"other": "днів: %d"},
+			"range": "до {1} з {0}"
+		}`)},
+		"de-DE.json": &fstest.MapFile{Data: []byte(`{
+			"alerts": {"one": "{0} Warnung in {1}", "other": "{1}: {0} Warnungen"}
+		}`)},
+	}))
+
+	t.Run("form of the count in the language", func(t *testing.T) {
+		assert.Equal(t, "1 alert in Kyiv", service.TN(ctx, "en-US", "alerts", 1, "Kyiv"))
+		assert.Equal(t, "3 alerts in Kyiv", service.TN(ctx, "en-US", "alerts", 3, "Kyiv"))
+		assert.Equal(t, "21 сповіщення в Київ", service.TN(ctx, "uk-UA", "alerts", 21, "Київ"))
+		assert.Equal(t, "Київ: 5 сповіщень", service.TN(ctx, "uk-UA", "alerts", 5, "Київ"))
+	})
+
+	t.Run("positional placeholders", func(t *testing.T) {
+		assert.Equal(t, "до Львів з Київ", service.T(ctx, "uk-UA", "range", "Київ", "Львів"))
+		assert.Equal(t, "1 Warnung in Berlin", service.TN(ctx, "de-DE", "alerts", 1, "Berlin"))
+		assert.Equal(t, "Berlin: 4 Warnungen", service.TN(ctx, "de-DE", "alerts", 4, "Berlin"))
+	})
+
+	t.Run("other form without a form for the category", func(t *testing.T) {
+		assert.Equal(t, "днів: 1", service.TN(ctx, "uk-UA", "days", 1))
+	})
+
+	t.Run("default language without the key", func(t *testing.T) {
+		assert.Equal(t, "1 day", service.TN(ctx, "de-DE", "days", 1))
+		assert.Equal(t, "2 days", service.TN(ctx, "de-DE", "days", 2))
+	})
+
+	t.Run("T gives the other form", func(t *testing.T) {
+		assert.Equal(t, "%d days", service.T(ctx, "en-US", "days"))
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		assert.Equal(t, "missing", service.TN(ctx, "en-US", "missing", 1))
+	})
+
+	t.Run("plural keys are listed", func(t *testing.T) {
+		assert.ElementsMatch(t, []string{"alerts", "days", "range"}, service.GetAvailableTranslationKeys("en-US"))
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"io/fs"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/valpere/shopogoda/internal"
@@ -27,9 +28,14 @@ type Translations map[string]Translation
 
 // LocalizationService handles multi-language support
 type LocalizationService struct {
-	translations       Translations       // [language][key] = translation
-	supportedLanguages SupportedLanguages // Supported languages
-	defaultLanguage    string             // fallback language (English)
+	translations       Translations                 // [language][key] = translation
+	plurals            map[string]PluralTranslation // [language][key] = plural forms
+	overrides          Translations                 // Deployment template overrides, looked up before translations
+	pluralOverrides    map[string]PluralTranslation // Overrides of plural entries
+	overrideDir        string                       // Where ReloadOverrides reads overrides from
+	footerTypes        map[string]bool              // Message types that get the override footer
+	supportedLanguages SupportedLanguages           // Supported languages
+	defaultLanguage    string                       // fallback language (English)
 	logger             *zerolog.Logger
 	mu                 sync.RWMutex
 }
@@ -38,6 +44,7 @@ type LocalizationService struct {
 func NewLocalizationService(logger *zerolog.Logger) *LocalizationService {
 	return &LocalizationService{
 		translations:    make(Translations),
+		plurals:         make(map[string]PluralTranslation),
 		defaultLanguage: internal.DefaultLanguage,
 		logger:          logger,
 	}
@@ -71,8 +78,8 @@ func (ls *LocalizationService) LoadTranslations(localesFS fs.FS) error {
 			continue
 		}
 
-		translations := make(Translation)
-		if err := json.Unmarshal(data, &translations); err != nil {
+		translations, plurals, err := parseLocale(data)
+		if err != nil {
 			ls.logger.Error().
 				Err(err).
 				Str("language", code).
@@ -81,44 +88,58 @@ func (ls *LocalizationService) LoadTranslations(localesFS fs.FS) error {
 		}
 
 		ls.translations[code] = translations
+		ls.plurals[code] = plurals
 		ls.logger.Info().
 			Str("language", code).
-			Int("keys", len(translations)).
+			Int("keys", len(translations)+len(plurals)).
 			Msg("Loaded translations")
 	}
 
 	return nil
 }
 
-// T translates a key to the specified language
+// T translates a key to the specified language. Arguments are formatted
+// with fmt verbs; a translation that needs them in another order refers to
+// them by index, e.g. "%[2]s: %[1]d", or by position from zero, e.g.
+// "{1}: {0}". A plural entry gives its other form.
 func (ls *LocalizationService) T(ctx context.Context, language, key string, args ...any) string {
+	return ls.translate(language, key, nil, args)
+}
+
+// TN translates a message about a count, in the plural form the count takes
+// in the language. The count is the first argument of the format, followed
+// by args.
+func (ls *LocalizationService) TN(ctx context.Context, language, key string, count int64, args ...any) string {
+	return ls.translate(language, key, &count, append([]any{count}, args...))
+}
+
+// translate looks a key up in the language, then in the default language,
+// and formats it; without a translation it returns the key
+func (ls *LocalizationService) translate(language, key string, count *int64, args []any) string {
 	ls.mu.RLock()
 	defer ls.mu.RUnlock()
 
-	// Try to get translation in requested language
-	if langMap, exists := ls.translations[language]; exists {
-		if translation, exists := langMap[key]; exists {
-			if len(args) > 0 {
-				return fmt.Sprintf(translation, args...)
-			}
-			return translation
+	format := func(translation string) string {
+		if len(args) > 0 {
+			return fmt.Sprintf(translation, args...)
 		}
+		return translation
+	}
+
+	// Try to get translation in requested language
+	if translation, exists := ls.message(language, key, count); exists {
+		return format(translation)
 	}
 
 	// Fall back to default language
-	if langMap, exists := ls.translations[ls.defaultLanguage]; exists {
-		if translation, exists := langMap[key]; exists {
-			ls.logger.Debug().
-				Str("key", key).
-				Str("requested_lang", language).
-				Str("fallback_lang", ls.defaultLanguage).
-				Msg("Using fallback language for translation")
-
-			if len(args) > 0 {
-				return fmt.Sprintf(translation, args...)
-			}
-			return translation
-		}
+	if translation, exists := ls.message(ls.defaultLanguage, key, count); exists {
+		ls.logger.Debug().
+			Str("key", key).
+			Str("requested_lang", language).
+			Str("fallback_lang", ls.defaultLanguage).
+			Msg("Using fallback language for translation")
+
+		return format(translation)
 	}
 
 	// If no translation found, return the key itself
@@ -130,6 +151,58 @@ func (ls *LocalizationService) T(ctx context.Context, language, key string, args
 	return key
 }
 
+// LocalizedDate formats the weekday, day and month of t in a language, e.g.
+// "Monday, Jan 2" or "Понеділок, 2 січня"
+func (ls *LocalizationService) LocalizedDate(t time.Time, language string) string {
+	ctx := context.Background()
+	weekday := ls.T(ctx, language, "weekday_"+strings.ToLower(t.Weekday().String()))
+	month := ls.T(ctx, language, "date_month_"+strings.ToLower(t.Month().String()))
+	return ls.T(ctx, language, "date_format_weekday_day_month", weekday, t.Day(), month)
+}
+
+// LocalizedShortWeekday abbreviates the weekday of t in a language, e.g. "Mon"
+// or "Пн"
+func (ls *LocalizationService) LocalizedShortWeekday(t time.Time, language string) string {
+	return ls.T(context.Background(), language, "weekday_short_"+strings.ToLower(t.Weekday().String()))
+}
+
+// lookup returns the translation of a key in one language, preferring a
+// template override to the bundled string. The caller holds mu.
+func (ls *LocalizationService) lookup(language, key string) (string, bool) {
+	if translation, exists := ls.overrides[language][key]; exists {
+		return translation, true
+	}
+	translation, exists := ls.translations[language][key]
+	return translation, exists
+}
+
+// lookupPlural returns the plural forms of a key in one language, preferring
+// a template override to the bundled forms. The caller holds mu.
+func (ls *LocalizationService) lookupPlural(language, key string) (PluralForms, bool) {
+	if forms, exists := ls.pluralOverrides[language][key]; exists {
+		return forms, true
+	}
+	forms, exists := ls.plurals[language][key]
+	return forms, exists
+}
+
+// message returns the text of a key in one language: a plain translation,
+// or the form of count of a plural entry, its other form without a count.
+// The caller holds mu.
+func (ls *LocalizationService) message(language, key string, count *int64) (string, bool) {
+	if translation, exists := ls.lookup(language, key); exists {
+		return translation, true
+	}
+	forms, exists := ls.lookupPlural(language, key)
+	if !exists {
+		return "", false
+	}
+	if count == nil {
+		return forms[PluralOther], true
+	}
+	return forms.form(language, *count), true
+}
+
 // IsLanguageSupported checks if a language code is supported
 func (ls *LocalizationService) IsLanguageSupported(language string) bool {
 	ls.mu.RLock()
@@ -182,7 +255,8 @@ func (ls *LocalizationService) DetectLanguageFromName(name string) string {
 	return ls.defaultLanguage
 }
 
-// GetAvailableTranslationKeys returns all available translation keys for a language
+// GetAvailableTranslationKeys returns all available translation keys for a
+// language, plural entries included
 func (ls *LocalizationService) GetAvailableTranslationKeys(language string) []string {
 	ls.mu.RLock()
 	defer ls.mu.RUnlock()
@@ -192,10 +266,14 @@ func (ls *LocalizationService) GetAvailableTranslationKeys(language string) []st
 		return nil
 	}
 
-	keys := make([]string, 0, len(langMap))
+	plurals := ls.plurals[language]
+	keys := make([]string, 0, len(langMap)+len(plurals))
 	for key := range langMap {
 		keys = append(keys, key)
 	}
+	for key := range plurals {
+		keys = append(keys, key)
+	}
 
 	return keys
 }
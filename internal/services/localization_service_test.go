@@ -5,10 +5,12 @@ import (
 	"sync"
 	"testing"
 	"testing/fstest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/valpere/shopogoda/internal/locales"
 	"github.com/valpere/shopogoda/tests/helpers"
 )
 
@@ -486,3 +488,47 @@ func TestLocalizationService_EmptyTranslations(t *testing.T) {
 		assert.Empty(t, keys)
 	})
 }
+
+func TestLocalizationService_LocalizedDate(t *testing.T) {
+	service := NewLocalizationService(helpers.NewSilentTestLogger())
+	require.NoError(t, service.LoadTranslations(locales.LocalesFS))
+
+	date := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC) // A Monday
+	tests := []struct {
+		language string
+		date     string
+		weekday  string
+	}{
+		{"en-US", "Monday, Mar 2", "Mon"},
+		{"uk-UA", "Понеділок, 2 березня", "Пн"},
+		{"de-DE", "Montag, 2. März", "Mo"},
+		{"fr-FR", "Lundi 2 mars", "lun."},
+		{"es-ES", "Lunes, 2 mar", "lun"},
+		{"pl-PL", "Monday, Mar 2", "Mon"}, // Falls back to English
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			assert.Equal(t, tt.date, service.LocalizedDate(date, tt.language))
+			assert.Equal(t, tt.weekday, service.LocalizedShortWeekday(date, tt.language))
+		})
+	}
+}
+
+// The one category of Ukrainian also takes 21, 31 and so on, so its forms
+// agree with the number only
+func TestLocalizationService_AlertLimitPlurals(t *testing.T) {
+	ctx := context.Background()
+	service := NewLocalizationService(helpers.NewSilentTestLogger())
+	require.NoError(t, service.LoadTranslations(locales.LocalesFS))
+
+	assert.Equal(t,
+		"⚠️ У вас уже 21 активне сповіщення — це максимум, тому нове сповіщення не створено. Видаліть або призупиніть сповіщення, щоб звільнити місце.",
+		service.TN(ctx, "uk-UA", "alert_limit_reached", 21))
+	assert.Equal(t,
+		"⏸️ 21 найновіше сповіщення залишається призупиненим: активних сповіщень може бути щонайбільше 30. Увімкніть сповіщення в /alerts, коли звільниться місце.",
+		service.TN(ctx, "uk-UA", "reactivate_alerts_trimmed", 21, 30))
+	assert.Equal(t,
+		"⚠️ У вас уже 1 активне сповіщення — це максимум, тому нове сповіщення не створено. Видаліть або призупиніть сповіщення, щоб звільнити місце.",
+		service.TN(ctx, "uk-UA", "alert_limit_reached", 1))
+}
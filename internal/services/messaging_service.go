@@ -0,0 +1,380 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/rs/zerolog"
+
+	"github.com/valpere/shopogoda/internal"
+	"github.com/valpere/shopogoda/pkg/metrics"
+)
+
+const (
+	// MessagingBulkChunkSize is the number of messages sent before pausing, keeping
+	// bulk sends under Telegram's limit of ~30 messages per second
+	MessagingBulkChunkSize = 25
+
+	// TemplateAdminBroadcast is the admin notice sent to all active users
+	TemplateAdminBroadcast = "admin_broadcast"
+	// TemplateWeeklyDigest is the weekly weather summary sent to subscribers
+	TemplateWeeklyDigest = "weekly_digest"
+	// TemplateDigestTimeSuggestion proposes moving the daily digest to when the user is active
+	TemplateDigestTimeSuggestion = "digest_time_suggestion"
+	// TemplateDailyDigest is the daily weather update, sent with its footer notices
+	TemplateDailyDigest = "daily_digest"
+	// TemplateQuotaWarning tells a user who sends too many requests to slow down
+	TemplateQuotaWarning = "quota_warning"
+	// TemplateUnusualWeather tells a user the weather is unusual for the season
+	TemplateUnusualWeather = "unusual_weather"
+	// TemplateAirQualityOutlook warns in the morning of air quality forecast
+	// to pass an air quality alert later in the day
+	TemplateAirQualityOutlook = "air_quality_outlook"
+)
+
+// dailyDigestTemplate renders the weather part of the daily digest
+var dailyDigestTemplate = MessageTemplate{
+	Name:      TemplateDailyDigest,
+	Key:       "notification_daily_digest",
+	Variables: []string{"location", "temperature", "humidity", "wind_speed", "wind_direction", "aqi", "visibility", "updated"},
+	ParseMode: "Markdown",
+}
+
+// ErrUnknownTemplate is returned when sending with a template that was never registered
+var ErrUnknownTemplate = errors.New("unknown message template")
+
+// ErrMissingTemplateVariable is returned when a required template variable is not provided
+var ErrMissingTemplateVariable = errors.New("missing template variable")
+
+// MessageSender delivers rendered text to a Telegram chat (implemented by *gotgbot.Bot)
+type MessageSender interface {
+	SendMessageWithContext(ctx context.Context, chatId int64, text string, opts *gotgbot.SendMessageOpts) (*gotgbot.Message, error)
+}
+
+// MessageTemplate describes a named message backed by a localization key.
+// Variables lists the required variables in the order the translation consumes them.
+type MessageTemplate struct {
+	Name      string
+	Key       string
+	Variables []string
+	ParseMode string
+}
+
+// SendOptions controls how Telegram delivers a templated message
+type SendOptions struct {
+	Markup  gotgbot.ReplyMarkup // Attached reply markup, e.g. inline buttons
+	Silent  bool                // Deliver without sound, for routine messages
+	ReplyTo int64               // Message the text replies to, if any
+}
+
+// TemplateStats holds delivery counters for a single template
+type TemplateStats struct {
+	Sent   int64 `json:"sent"`
+	Failed int64 `json:"failed"`
+}
+
+// BulkSendResult summarizes the outcome of a bulk send
+type BulkSendResult struct {
+	Sent          int     `json:"sent"`
+	Failed        int     `json:"failed"`
+	FailedUserIDs []int64 `json:"failed_user_ids,omitempty"`
+}
+
+// MessagingService renders localized message templates and delivers them to users.
+// It is the single place where user-facing messages are assembled and sent, so
+// additional channels can be added here without touching the callers.
+type MessagingService struct {
+	user         *UserService
+	localization *LocalizationService
+	metrics      *metrics.Metrics
+	logger       *zerolog.Logger
+	sender       MessageSender
+	chunkPause   time.Duration
+
+	mu        sync.RWMutex
+	templates map[string]MessageTemplate
+	stats     map[string]*TemplateStats
+}
+
+func NewMessagingService(userService *UserService, localization *LocalizationService, metricsCollector *metrics.Metrics, logger *zerolog.Logger) *MessagingService {
+	s := &MessagingService{
+		user:         userService,
+		localization: localization,
+		metrics:      metricsCollector,
+		logger:       logger,
+		chunkPause:   time.Second,
+		templates:    make(map[string]MessageTemplate),
+		stats:        make(map[string]*TemplateStats),
+	}
+
+	s.registerDefaultTemplates()
+
+	return s
+}
+
+// registerDefaultTemplates registers the templates used by the bot itself
+func (s *MessagingService) registerDefaultTemplates() {
+	defaults := []MessageTemplate{
+		{Name: TemplateAdminBroadcast, Key: "admin_broadcast_message_header", Variables: []string{"message"}, ParseMode: "Markdown"},
+		{Name: TemplateWeeklyDigest, Key: "notification_weekly_digest", Variables: []string{"location", "summary"}, ParseMode: "Markdown"},
+		{Name: TemplateDigestTimeSuggestion, Key: "digest_time_suggestion", Variables: []string{"suggested_time", "current_time"}, ParseMode: "Markdown"},
+		dailyDigestTemplate,
+		{Name: TemplateQuotaWarning, Key: "quota_warning"},
+		{Name: TemplateUnusualWeather, Key: "notification_unusual_weather", Variables: []string{"location", "note"}, ParseMode: "Markdown"},
+		{Name: TemplateAirQualityOutlook, Key: "notification_air_quality_outlook", Variables: []string{"location", "aqi", "time", "pollutant", "threshold"}, ParseMode: "Markdown"},
+	}
+
+	for _, tmpl := range defaults {
+		if err := s.RegisterTemplate(tmpl); err != nil {
+			s.logger.Error().Err(err).Str("template", tmpl.Name).Msg("Failed to register default message template")
+		}
+	}
+}
+
+// SetBot sets the Telegram bot instance used for delivery
+func (s *MessagingService) SetBot(bot *gotgbot.Bot) {
+	if bot == nil {
+		s.sender = nil
+		return
+	}
+	s.sender = bot
+}
+
+// RegisterTemplate adds or replaces a named template
+func (s *MessagingService) RegisterTemplate(tmpl MessageTemplate) error {
+	if tmpl.Name == "" {
+		return fmt.Errorf("template name cannot be empty")
+	}
+	if tmpl.Key == "" {
+		return fmt.Errorf("template %s: localization key cannot be empty", tmpl.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.templates[tmpl.Name] = tmpl
+	if _, exists := s.stats[tmpl.Name]; !exists {
+		s.stats[tmpl.Name] = &TemplateStats{}
+	}
+
+	return nil
+}
+
+// Render validates the variables and renders the template in the given language
+func (s *MessagingService) Render(ctx context.Context, language, templateName string, vars map[string]string) (string, error) {
+	tmpl, err := s.getTemplate(templateName)
+	if err != nil {
+		return "", err
+	}
+
+	args, err := templateArgs(tmpl, vars)
+	if err != nil {
+		return "", err
+	}
+
+	return s.localization.T(ctx, language, tmpl.Key, args...), nil
+}
+
+// Send renders the template in the user's language and delivers it to the user
+func (s *MessagingService) Send(ctx context.Context, userID int64, templateName string, vars map[string]string) error {
+	return s.SendWithOptions(ctx, userID, templateName, vars, SendOptions{})
+}
+
+// SendWithMarkup is like Send but attaches a reply markup, e.g. inline buttons
+func (s *MessagingService) SendWithMarkup(ctx context.Context, userID int64, templateName string, vars map[string]string, markup gotgbot.ReplyMarkup) error {
+	return s.SendWithOptions(ctx, userID, templateName, vars, SendOptions{Markup: markup})
+}
+
+// SendWithOptions is like Send with control over markup and notification sound
+func (s *MessagingService) SendWithOptions(ctx context.Context, userID int64, templateName string, vars map[string]string, options SendOptions) error {
+	// For direct messages the chat ID is the user ID
+	return s.SendToChat(ctx, userID, userID, templateName, vars, options)
+}
+
+// SendToChat renders the template in the user's language and delivers it to
+// chatID, which differs from the user ID in groups
+func (s *MessagingService) SendToChat(ctx context.Context, chatID, userID int64, templateName string, vars map[string]string, options SendOptions) error {
+	tmpl, err := s.getTemplate(templateName)
+	if err != nil {
+		return err
+	}
+
+	args, err := templateArgs(tmpl, vars)
+	if err != nil {
+		return err
+	}
+
+	if s.sender == nil {
+		s.logger.Debug().Str("template", templateName).Msg("Telegram bot not configured for messaging")
+		return nil
+	}
+
+	text := s.localization.T(ctx, s.userLanguage(ctx, userID), tmpl.Key, args...)
+	return s.deliver(ctx, chatID, tmpl, text, options)
+}
+
+// Deliver sends text composed from a template, such as a digest with its
+// footer notices appended, counting it in the template's delivery stats
+func (s *MessagingService) Deliver(ctx context.Context, chatID int64, templateName, text string, options SendOptions) error {
+	tmpl, err := s.getTemplate(templateName)
+	if err != nil {
+		return err
+	}
+
+	if s.sender == nil {
+		s.logger.Debug().Str("template", templateName).Msg("Telegram bot not configured for messaging")
+		return nil
+	}
+
+	return s.deliver(ctx, chatID, tmpl, text, options)
+}
+
+// deliver is the single send path of templated messages
+func (s *MessagingService) deliver(ctx context.Context, chatID int64, tmpl MessageTemplate, text string, options SendOptions) error {
+	var opts *gotgbot.SendMessageOpts
+	if tmpl.ParseMode != "" || options.Markup != nil || options.Silent || options.ReplyTo != 0 {
+		opts = &gotgbot.SendMessageOpts{
+			ParseMode:           tmpl.ParseMode,
+			ReplyMarkup:         options.Markup,
+			DisableNotification: options.Silent,
+		}
+		if options.ReplyTo != 0 {
+			opts.ReplyParameters = &gotgbot.ReplyParameters{MessageId: options.ReplyTo, AllowSendingWithoutReply: true}
+		}
+	}
+
+	// Templated messages are not answers the user is waiting for, so they
+	// wait in the bulk lane of the outbound limiter
+	if _, err := s.sender.SendMessageWithContext(WithBulkPriority(ctx), chatID, text, opts); err != nil {
+		s.recordDelivery(tmpl.Name, false)
+		s.logger.Error().
+			Err(err).
+			Int64("chat_id", chatID).
+			Str("template", tmpl.Name).
+			Msg("Failed to send templated message")
+		return fmt.Errorf("failed to send %s message to chat %d: %w", tmpl.Name, chatID, err)
+	}
+
+	s.recordDelivery(tmpl.Name, true)
+	return nil
+}
+
+// SendBulk delivers the template to many users in chunks, pausing between chunks.
+// Variables are validated once up front so an invalid call sends nothing.
+func (s *MessagingService) SendBulk(ctx context.Context, userIDs []int64, templateName string, vars map[string]string) (*BulkSendResult, error) {
+	tmpl, err := s.getTemplate(templateName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := templateArgs(tmpl, vars); err != nil {
+		return nil, err
+	}
+
+	result := &BulkSendResult{}
+
+	for start := 0; start < len(userIDs); start += MessagingBulkChunkSize {
+		if start > 0 && s.chunkPause > 0 {
+			select {
+			case <-ctx.Done():
+				return result, ctx.Err()
+			case <-time.After(s.chunkPause):
+			}
+		}
+
+		end := start + MessagingBulkChunkSize
+		if end > len(userIDs) {
+			end = len(userIDs)
+		}
+
+		for _, userID := range userIDs[start:end] {
+			if err := s.Send(ctx, userID, templateName, vars); err != nil {
+				result.Failed++
+				result.FailedUserIDs = append(result.FailedUserIDs, userID)
+				continue
+			}
+			result.Sent++
+		}
+	}
+
+	s.logger.Info().
+		Str("template", templateName).
+		Int("sent", result.Sent).
+		Int("failed", result.Failed).
+		Msg("Bulk message delivery completed")
+
+	return result, nil
+}
+
+// Stats returns delivery counters for a template
+func (s *MessagingService) Stats(templateName string) TemplateStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if stats, exists := s.stats[templateName]; exists {
+		return *stats
+	}
+	return TemplateStats{}
+}
+
+func (s *MessagingService) getTemplate(name string) (MessageTemplate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tmpl, exists := s.templates[name]
+	if !exists {
+		return MessageTemplate{}, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+	return tmpl, nil
+}
+
+func (s *MessagingService) recordDelivery(templateName string, success bool) {
+	status := "success"
+
+	s.mu.Lock()
+	stats, exists := s.stats[templateName]
+	if !exists {
+		stats = &TemplateStats{}
+		s.stats[templateName] = stats
+	}
+	if success {
+		stats.Sent++
+	} else {
+		stats.Failed++
+		status = "error"
+	}
+	s.mu.Unlock()
+
+	if s.metrics != nil {
+		s.metrics.IncrementCounter("messages_sent_total", templateName, status)
+	}
+}
+
+// userLanguage resolves the user's language, falling back to the default
+func (s *MessagingService) userLanguage(ctx context.Context, userID int64) string {
+	if s.user == nil {
+		return internal.DefaultLanguage
+	}
+
+	user, err := s.user.GetUser(ctx, userID)
+	if err != nil || user == nil || user.Language == "" {
+		return internal.DefaultLanguage
+	}
+	return user.Language
+}
+
+// templateArgs orders the variables as the template expects, failing on any missing one
+func templateArgs(tmpl MessageTemplate, vars map[string]string) ([]any, error) {
+	args := make([]any, 0, len(tmpl.Variables))
+	for _, name := range tmpl.Variables {
+		value, ok := vars[name]
+		if !ok {
+			return nil, fmt.Errorf("%w %q for template %s", ErrMissingTemplateVariable, name, tmpl.Name)
+		}
+		args = append(args, value)
+	}
+	return args, nil
+}
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/metrics"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+type sentMessage struct {
+	chatID int64
+	text   string
+	opts   *gotgbot.SendMessageOpts
+}
+
+// fakeMessageSender records messages and fails for selected chats
+type fakeMessageSender struct {
+	sent    []sentMessage
+	failFor map[int64]bool
+}
+
+func (f *fakeMessageSender) SendMessageWithContext(ctx context.Context, chatId int64, text string, opts *gotgbot.SendMessageOpts) (*gotgbot.Message, error) {
+	if f.failFor[chatId] {
+		return nil, errors.New("Forbidden: bot was blocked by the user")
+	}
+	f.sent = append(f.sent, sentMessage{chatID: chatId, text: text, opts: opts})
+	return &gotgbot.Message{}, nil
+}
+
+func newTestMessagingService(t *testing.T, userService *UserService) (*MessagingService, *fakeMessageSender) {
+	logger := helpers.NewSilentTestLogger()
+	localization := NewLocalizationService(logger)
+	err := localization.LoadTranslations(fstest.MapFS{
+		"languages.json": &fstest.MapFile{
+			Data: []byte(`{
+				"en-US": {"code": "en-US", "name": "English", "flag": "🇺🇸"},
+				"uk-UA": {"code": "uk-UA", "name": "Ukrainian", "flag": "🇺🇦"}
+			}`),
+		},
+		"en-US.json": &fstest.MapFile{
+			Data: []byte(`{"admin_broadcast_message_header": "Broadcast: %s", "notification_weekly_digest": "Week in %s: %s"}`),
+		},
+		"uk-UA.json": &fstest.MapFile{
+			Data: []byte(`{"admin_broadcast_message_header": "Розсилка: %s"}`),
+		},
+	})
+	require.NoError(t, err)
+
+	service := NewMessagingService(userService, localization, metrics.New(), logger)
+	service.chunkPause = 0
+
+	sender := &fakeMessageSender{failFor: map[int64]bool{}}
+	service.sender = sender
+
+	return service, sender
+}
+
+func TestMessagingService_Send(t *testing.T) {
+	t.Run("renders variables in template order", func(t *testing.T) {
+		service, sender := newTestMessagingService(t, nil)
+
+		err := service.Send(context.Background(), 42, TemplateWeeklyDigest, map[string]string{
+			"summary":  "mild and sunny",
+			"location": "Kyiv",
+		})
+
+		require.NoError(t, err)
+		require.Len(t, sender.sent, 1)
+		assert.Equal(t, int64(42), sender.sent[0].chatID)
+		assert.Equal(t, "Week in Kyiv: mild and sunny", sender.sent[0].text)
+	})
+
+	t.Run("silent delivery", func(t *testing.T) {
+		service, sender := newTestMessagingService(t, nil)
+
+		err := service.SendWithOptions(context.Background(), 42, TemplateWeeklyDigest, map[string]string{
+			"summary":  "mild and sunny",
+			"location": "Kyiv",
+		}, SendOptions{Silent: true})
+
+		require.NoError(t, err)
+		require.Len(t, sender.sent, 1)
+		require.NotNil(t, sender.sent[0].opts)
+		assert.True(t, sender.sent[0].opts.DisableNotification)
+		assert.Equal(t, "Markdown", sender.sent[0].opts.ParseMode)
+	})
+
+	t.Run("missing variable fails before sending", func(t *testing.T) {
+		service, sender := newTestMessagingService(t, nil)
+
+		err := service.Send(context.Background(), 42, TemplateWeeklyDigest, map[string]string{
+			"location": "Kyiv",
+		})
+
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrMissingTemplateVariable))
+		assert.Contains(t, err.Error(), "summary")
+		assert.Empty(t, sender.sent)
+		assert.Equal(t, TemplateStats{}, service.Stats(TemplateWeeklyDigest))
+	})
+
+	t.Run("unknown template", func(t *testing.T) {
+		service, sender := newTestMessagingService(t, nil)
+
+		err := service.Send(context.Background(), 42, "no_such_template", nil)
+
+		assert.True(t, errors.Is(err, ErrUnknownTemplate))
+		assert.Empty(t, sender.sent)
+	})
+
+	t.Run("uses recipient language", func(t *testing.T) {
+		mockRedis := helpers.NewMockRedis()
+		logger := helpers.NewSilentTestLogger()
+		userService := NewUserService(nil, mockRedis.Client, nil, logger, time.Now())
+		service, sender := newTestMessagingService(t, userService)
+
+		userJSON, _ := json.Marshal(models.User{ID: 7, Language: "uk-UA"})
+		mockRedis.Mock.ExpectGet("user:7").SetVal(string(userJSON))
+
+		err := service.Send(context.Background(), 7, TemplateAdminBroadcast, map[string]string{"message": "привіт"})
+
+		require.NoError(t, err)
+		require.Len(t, sender.sent, 1)
+		assert.Equal(t, "Розсилка: привіт", sender.sent[0].text)
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("no bot configured", func(t *testing.T) {
+		service, _ := newTestMessagingService(t, nil)
+		service.SetBot(nil)
+
+		err := service.Send(context.Background(), 42, TemplateAdminBroadcast, map[string]string{"message": "hi"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, TemplateStats{}, service.Stats(TemplateAdminBroadcast))
+	})
+}
+
+func TestMessagingService_Stats(t *testing.T) {
+	service, sender := newTestMessagingService(t, nil)
+	sender.failFor[2] = true
+	vars := map[string]string{"message": "maintenance tonight"}
+
+	require.NoError(t, service.Send(context.Background(), 1, TemplateAdminBroadcast, vars))
+	require.Error(t, service.Send(context.Background(), 2, TemplateAdminBroadcast, vars))
+	require.NoError(t, service.Send(context.Background(), 3, TemplateAdminBroadcast, vars))
+	require.NoError(t, service.Send(context.Background(), 1, TemplateWeeklyDigest, map[string]string{"location": "Lviv", "summary": "rain"}))
+
+	assert.Equal(t, TemplateStats{Sent: 2, Failed: 1}, service.Stats(TemplateAdminBroadcast))
+	assert.Equal(t, TemplateStats{Sent: 1}, service.Stats(TemplateWeeklyDigest))
+	assert.Equal(t, TemplateStats{}, service.Stats("unused"))
+}
+
+func TestMessagingService_SendBulk(t *testing.T) {
+	t.Run("sends every chunk and collects failures", func(t *testing.T) {
+		service, sender := newTestMessagingService(t, nil)
+
+		userIDs := make([]int64, MessagingBulkChunkSize*2+5)
+		for i := range userIDs {
+			userIDs[i] = int64(i + 1)
+		}
+		sender.failFor[3] = true
+		sender.failFor[MessagingBulkChunkSize+1] = true
+
+		result, err := service.SendBulk(context.Background(), userIDs, TemplateAdminBroadcast, map[string]string{"message": "hello"})
+
+		require.NoError(t, err)
+		assert.Equal(t, len(userIDs)-2, result.Sent)
+		assert.Equal(t, 2, result.Failed)
+		assert.Equal(t, []int64{3, MessagingBulkChunkSize + 1}, result.FailedUserIDs)
+		assert.Len(t, sender.sent, len(userIDs)-2)
+		assert.Equal(t, TemplateStats{Sent: int64(len(userIDs) - 2), Failed: 2}, service.Stats(TemplateAdminBroadcast))
+	})
+
+	t.Run("invalid variables send nothing", func(t *testing.T) {
+		service, sender := newTestMessagingService(t, nil)
+
+		result, err := service.SendBulk(context.Background(), []int64{1, 2, 3}, TemplateAdminBroadcast, map[string]string{})
+
+		assert.Nil(t, result)
+		assert.True(t, errors.Is(err, ErrMissingTemplateVariable))
+		assert.Empty(t, sender.sent)
+	})
+
+	t.Run("stops between chunks when context is cancelled", func(t *testing.T) {
+		service, sender := newTestMessagingService(t, nil)
+		service.chunkPause = time.Hour
+
+		userIDs := make([]int64, MessagingBulkChunkSize+1)
+		for i := range userIDs {
+			userIDs[i] = int64(i + 1)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		result, err := service.SendBulk(ctx, userIDs, TemplateAdminBroadcast, map[string]string{"message": "hello"})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, MessagingBulkChunkSize, result.Sent)
+		assert.Len(t, sender.sent, MessagingBulkChunkSize)
+	})
+}
+
+func TestMessagingService_RegisterTemplate(t *testing.T) {
+	service, _ := newTestMessagingService(t, nil)
+
+	assert.Error(t, service.RegisterTemplate(MessageTemplate{Key: "some_key"}))
+	assert.Error(t, service.RegisterTemplate(MessageTemplate{Name: "custom"}))
+
+	require.NoError(t, service.RegisterTemplate(MessageTemplate{Name: "custom", Key: "admin_broadcast_message_header", Variables: []string{"message"}}))
+
+	text, err := service.Render(context.Background(), "en-US", "custom", map[string]string{"message": "ok"})
+	require.NoError(t, err)
+	assert.Equal(t, "Broadcast: ok", text)
+}
+
+func TestMessagingService_Deliver(t *testing.T) {
+	t.Run("sends composed text and counts it for the template", func(t *testing.T) {
+		service, sender := newTestMessagingService(t, nil)
+
+		err := service.Deliver(context.Background(), 42, TemplateDailyDigest, "digest\n\nfooter notice", SendOptions{Silent: true})
+
+		require.NoError(t, err)
+		require.Len(t, sender.sent, 1)
+		assert.Equal(t, "digest\n\nfooter notice", sender.sent[0].text)
+		assert.Equal(t, "Markdown", sender.sent[0].opts.ParseMode)
+		assert.True(t, sender.sent[0].opts.DisableNotification)
+		assert.Equal(t, TemplateStats{Sent: 1}, service.Stats(TemplateDailyDigest))
+	})
+
+	t.Run("unknown template", func(t *testing.T) {
+		service, sender := newTestMessagingService(t, nil)
+
+		err := service.Deliver(context.Background(), 42, "no_such_template", "text", SendOptions{})
+
+		assert.True(t, errors.Is(err, ErrUnknownTemplate))
+		assert.Empty(t, sender.sent)
+	})
+}
+
+func TestMessagingService_SendToChat(t *testing.T) {
+	service, sender := newTestMessagingService(t, nil)
+
+	err := service.SendToChat(context.Background(), -100123, 42, TemplateQuotaWarning, nil, SendOptions{ReplyTo: 7})
+
+	require.NoError(t, err)
+	require.Len(t, sender.sent, 1)
+	assert.Equal(t, int64(-100123), sender.sent[0].chatID)
+	require.NotNil(t, sender.sent[0].opts.ReplyParameters)
+	assert.Equal(t, int64(7), sender.sent[0].opts.ReplyParameters.MessageId)
+	assert.Equal(t, TemplateStats{Sent: 1}, service.Stats(TemplateQuotaWarning))
+}
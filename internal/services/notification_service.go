@@ -2,22 +2,93 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/PaulSonOfLars/gotgbot/v2"
 	"github.com/rs/zerolog"
 
 	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/internal/markdown"
 	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/weather"
 )
 
 type NotificationService struct {
-	config *config.IntegrationsConfig
-	logger *zerolog.Logger
-	client *http.Client
-	bot    *gotgbot.Bot // Telegram bot instance for sending notifications
+	config       *config.IntegrationsConfig
+	logger       *zerolog.Logger
+	client       *http.Client
+	bot          *gotgbot.Bot         // Telegram bot instance for sending notifications
+	localization *LocalizationService // Translates the daily digest; English when unset
+	messaging    *MessagingService    // Send path of the daily digest; the bot directly when unset
+	escalations  *AlertEscalationService
+}
+
+// Extreme alert texts used when no localization service is configured
+const (
+	defaultExtremeAlertHeader = "🆘🆘🆘 EXTREME WEATHER WARNING 🆘🆘🆘"
+	defaultAlertAckButton     = "👌 OK, got it"
+)
+
+// defaultDailyDigestFormat is the en-US daily digest, used when no
+// localization service is configured
+const defaultDailyDigestFormat = `☀️ *Daily Weather Update*
+📍 *%s*
+
+🌡️ *Temperature:* %s
+💧 *Humidity:* %s
+💨 *Wind:* %s %s°
+🌿 *Air Quality:* AQI %s
+👁️ *Visibility:* %s
+📅 *Updated:* %s`
+
+// defaultAlertActivityTexts are the en-US texts of the digest's alert activity
+// section, used when no localization service is configured
+var defaultAlertActivityTexts = map[string]string{
+	"digest_alert_activity_title":       "🔔 *Recent Alert Activity*",
+	"digest_alert_activity_more":        "…and more",
+	"digest_alert_suppressed_holiday":   "(suppressed: holiday)",
+	"digest_alert_suppressed_quiet_day": "(suppressed: quiet day)",
+	"digest_alert_history_btn":          "📜 See full alert history",
+	"alert_type_temperature":            "Temperature",
+	"alert_type_humidity":               "Humidity",
+	"alert_type_pressure":               "Pressure",
+	"alert_type_wind_speed":             "Wind speed",
+	"alert_type_uv_index":               "UV index",
+	"alert_type_air_quality":            "Air quality",
+}
+
+// defaultSunriseTexts are the en-US texts of sunrise notifications, used when
+// no localization service is configured
+var defaultSunriseTexts = map[string]string{
+	"sunrise_notification_title": "🌅 *Good morning, the sun is up*",
+	"sun_sunset":                 "🌇 Sunset: %s",
+	"sun_day_length":             "⏳ Day length: %dh %02dm",
+}
+
+// defaultWeatherWarningTexts are the en-US texts of official warnings, used
+// when no localization service is configured
+var defaultWeatherWarningTexts = map[string]string{
+	"weather_warning_header":            "⚠️ *Official Weather Warning*",
+	"weather_warning_severity":          "Severity: %s",
+	"weather_warning_period":            "🕒 %s – %s",
+	"weather_warning_issued_by":         "🏛 Issued by %s",
+	"weather_warning_severity_minor":    "Minor",
+	"weather_warning_severity_moderate": "Moderate",
+	"weather_warning_severity_severe":   "Severe",
+	"weather_warning_severity_extreme":  "Extreme",
+	"date_format_day_month":             "Jan 2",
+}
+
+// AlertActivity lists the alerts that triggered for a user since their previous digest
+type AlertActivity struct {
+	Alerts []models.EnvironmentalAlert // Newest first
+	More   bool                        // More alerts triggered than are listed
 }
 
 type SlackMessage struct {
@@ -51,6 +122,45 @@ func (s *NotificationService) SetBot(bot *gotgbot.Bot) {
 	s.bot = bot
 }
 
+// SetLocalization renders the daily digest in each user's language
+func (s *NotificationService) SetLocalization(localization *LocalizationService) {
+	s.localization = localization
+}
+
+// SetMessaging sends the daily digest through the messaging service, which
+// counts deliveries per template
+func (s *NotificationService) SetMessaging(messaging *MessagingService) {
+	s.messaging = messaging
+}
+
+// SetEscalations follows up on extreme alerts the user does not acknowledge
+func (s *NotificationService) SetEscalations(escalations *AlertEscalationService) {
+	s.escalations = escalations
+}
+
+// translate renders key in the user's language, or returns fallback when no
+// localization service is configured
+func (s *NotificationService) translate(user *models.User, key, fallback string) string {
+	if s.localization == nil {
+		return fallback
+	}
+	return s.localization.T(context.Background(), user.Language, key)
+}
+
+// translator returns a function rendering keys in the user's language. Without a
+// localization service the texts come from fallbacks, or the key itself.
+func (s *NotificationService) translator(user *models.User, fallbacks map[string]string) func(key string, args ...any) string {
+	return func(key string, args ...any) string {
+		if s.localization != nil {
+			return s.localization.T(context.Background(), user.Language, key, args...)
+		}
+		if text, ok := fallbacks[key]; ok {
+			return fmt.Sprintf(text, args...)
+		}
+		return key
+	}
+}
+
 // getTelegramChatID returns the chat ID for sending direct messages to a user
 // For direct messages to users, chat ID is the same as user ID
 // See: https://core.telegram.org/bots/api#chat
@@ -81,8 +191,8 @@ func (s *NotificationService) SendSlackAlert(alert *models.EnvironmentalAlert, u
 					{Title: "Location", Value: locationName, Short: true},
 					{Title: "User", Value: user.GetDisplayName(), Short: true},
 					{Title: "Severity", Value: s.getSeverityText(alert.Severity), Short: true},
-					{Title: "Current Value", Value: fmt.Sprintf("%.1f", alert.Value), Short: true},
-					{Title: "Threshold", Value: fmt.Sprintf("%.1f", alert.Threshold), Short: true},
+					{Title: "Current Value", Value: weather.FormatDecimal(alert.Value, 1), Short: true},
+					{Title: "Threshold", Value: weather.FormatDecimal(alert.Threshold, 1), Short: true},
 				},
 			},
 		},
@@ -91,22 +201,22 @@ func (s *NotificationService) SendSlackAlert(alert *models.EnvironmentalAlert, u
 	return s.sendSlackMessage(message)
 }
 
-func (s *NotificationService) SendSlackWeatherUpdate(weather *WeatherData, subscribers []models.User) error {
+func (s *NotificationService) SendSlackWeatherUpdate(current *WeatherData, subscribers []models.User) error {
 	if s.config.SlackWebhookURL == "" {
 		return nil
 	}
 
 	message := SlackMessage{
-		Text: fmt.Sprintf("🌤️ Daily Weather Update for %s", weather.LocationName),
+		Text: fmt.Sprintf("🌤️ Daily Weather Update for %s", current.LocationName),
 		Attachments: []SlackAttachment{
 			{
 				Color: "good",
 				Title: "Current Conditions",
 				Fields: []SlackField{
-					{Title: "Temperature", Value: fmt.Sprintf("%.1f°C", weather.Temperature), Short: true},
-					{Title: "Humidity", Value: fmt.Sprintf("%d%%", weather.Humidity), Short: true},
-					{Title: "Wind", Value: fmt.Sprintf("%.1f km/h %d°", weather.WindSpeed, weather.WindDirection), Short: true},
-					{Title: "Air Quality", Value: fmt.Sprintf("AQI %d", weather.AQI), Short: true},
+					{Title: "Temperature", Value: weather.FormatTemp(current.Temperature, weather.UnitsMetric), Short: true},
+					{Title: "Humidity", Value: weather.FormatPercent(float64(current.Humidity)), Short: true},
+					{Title: "Wind", Value: fmt.Sprintf("%s %d°", weather.FormatSpeed(current.WindSpeed, weather.UnitsMetric), current.WindDirection), Short: true},
+					{Title: "Air Quality", Value: "AQI " + weather.FormatAQI(float64(current.AQI)), Short: true},
 				},
 			},
 		},
@@ -192,16 +302,16 @@ func (s *NotificationService) SendTelegramAlert(alert *models.EnvironmentalAlert
 📍 *Location:* %s
 👤 *User:* %s
 🚨 *Severity:* %s
-📊 *Current Value:* %.1f
-⚠️ *Threshold:* %.1f`,
+📊 *Current Value:* %s
+⚠️ *Threshold:* %s`,
 		severityEmoji,
 		alert.Title,
 		alert.Description,
-		locationName,
-		user.GetDisplayName(),
+		markdown.Escape(locationName),
+		markdown.Escape(user.GetDisplayName()),
 		s.getSeverityText(alert.Severity),
-		alert.Value,
-		alert.Threshold)
+		weather.FormatDecimal(alert.Value, 1),
+		weather.FormatDecimal(alert.Threshold, 1))
 
 	// Assumption: For direct messages to users, chat ID is the same as user ID.
 	// This is only valid if the user has already started a private chat with the bot.
@@ -212,10 +322,22 @@ func (s *NotificationService) SendTelegramAlert(alert *models.EnvironmentalAlert
 	// as they may indicate that the user has not started a chat with the bot or has blocked the bot.
 	// Possible recovery mechanisms include notifying the user through another channel,
 	// prompting the user to start a chat with the bot, or logging the incident for further review.
+	opts := &gotgbot.SendMessageOpts{ParseMode: "Markdown"}
+
+	// Extreme alerts stand out with a header line and ask for an acknowledgment;
+	// without one the user gets a follow-up
+	extreme := isExtremeAlert(alert)
+	if extreme {
+		message = s.translate(user, "alert_extreme_header", defaultExtremeAlertHeader) + "\n\n" + message
+		opts.ReplyMarkup = gotgbot.InlineKeyboardMarkup{
+			InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+				{{Text: s.translate(user, "alert_ack_btn", defaultAlertAckButton), CallbackData: AlertAckCallback}},
+			},
+		}
+	}
+
 	chatID := s.getTelegramChatID(user)
-	_, err := s.bot.SendMessage(chatID, message, &gotgbot.SendMessageOpts{
-		ParseMode: "Markdown",
-	})
+	sent, err := s.bot.SendMessageWithContext(WithBulkPriority(context.Background()), chatID, message, opts)
 
 	if err != nil {
 		s.logger.Error().
@@ -226,39 +348,66 @@ func (s *NotificationService) SendTelegramAlert(alert *models.EnvironmentalAlert
 		return fmt.Errorf("failed to send Telegram alert to user %d: %w", user.ID, err)
 	}
 
+	if extreme && s.escalations != nil {
+		if err := s.escalations.Track(context.Background(), user, chatID, sent.MessageId, alert); err != nil {
+			s.logger.Warn().Err(err).Int64("user_id", user.ID).Msg("Failed to schedule alert follow-up")
+		}
+	}
+
 	s.logger.Info().Int64("user_id", user.ID).Int64("chat_id", chatID).Msg("Telegram alert notification sent successfully")
 	return nil
 }
 
 // SendTelegramWeatherUpdate sends a daily weather update to users via Telegram
-func (s *NotificationService) SendTelegramWeatherUpdate(weather *WeatherData, user *models.User) error {
+func (s *NotificationService) SendTelegramWeatherUpdate(current *WeatherData, user *models.User) error {
+	return s.SendTelegramDailyDigest(current, user, s.getTelegramChatID(user), nil, "")
+}
+
+// SendTelegramDailyDigest sends the daily weather update to the chat, the
+// user's own or a group, followed by the alerts that triggered since the
+// previous digest. A nil or empty activity omits the section; a non-empty
+// note, such as unusual weather for the season, follows the weather update.
+func (s *NotificationService) SendTelegramDailyDigest(current *WeatherData, user *models.User, chatID int64, activity *AlertActivity, note string) error {
 	if s.bot == nil {
 		s.logger.Debug().Msg("Telegram bot not configured for notifications")
 		return nil
 	}
 
-	message := fmt.Sprintf(`☀️ *Daily Weather Update*
-📍 *%s*
+	// The weather update is followed by a short summary in the user's secondary
+	// language; the alert activity and the button stay in the primary language
+	message := RenderForUser(user, func(language string) string {
+		text := s.formatDailyWeather(current, language, UserUnits(user), userLocation(user))
+		if note != "" {
+			text += "\n\n" + note
+		}
+		return text
+	}, WeatherSummary(s.localization, current, UserUnits(user)))
+
+	// Digests are routine; users may take them without sound
+	opts := &gotgbot.SendMessageOpts{ParseMode: "Markdown", DisableNotification: user.QuietDigests}
+	if activity != nil && len(activity.Alerts) > 0 {
+		t := s.translator(user, defaultAlertActivityTexts)
+		message += "\n\n" + formatAlertActivity(t("digest_alert_activity_title"), activity, userLocation(user), UserUnits(user), t)
+		opts.ReplyMarkup = gotgbot.InlineKeyboardMarkup{
+			InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+				{{Text: t("digest_alert_history_btn"), CallbackData: "alerts_history"}},
+			},
+		}
+	}
 
-🌡️ *Temperature:* %.1f°C
-💧 *Humidity:* %d%%
-💨 *Wind:* %.1f km/h %d°
-🌿 *Air Quality:* AQI %d
-👁️ *Visibility:* %.1f km
-📅 *Updated:* %s`,
-		weather.LocationName,
-		weather.Temperature,
-		weather.Humidity,
-		weather.WindSpeed,
-		weather.WindDirection,
-		weather.AQI,
-		weather.Visibility,
-		weather.Timestamp.Format("15:04 UTC"))
+	if s.localization != nil {
+		message = s.localization.AppendFooter(context.Background(), user.Language, MessageTypeDigest, message)
+	}
 
-	chatID := s.getTelegramChatID(user)
-	_, err := s.bot.SendMessage(chatID, message, &gotgbot.SendMessageOpts{
-		ParseMode: "Markdown",
-	})
+	var err error
+	if s.messaging != nil {
+		err = s.messaging.Deliver(context.Background(), chatID, TemplateDailyDigest, message, SendOptions{
+			Markup: opts.ReplyMarkup,
+			Silent: opts.DisableNotification,
+		})
+	} else {
+		_, err = s.bot.SendMessageWithContext(WithBulkPriority(context.Background()), chatID, message, opts)
+	}
 
 	if err != nil {
 		s.logger.Error().
@@ -273,8 +422,30 @@ func (s *NotificationService) SendTelegramWeatherUpdate(weather *WeatherData, us
 	return nil
 }
 
-// SendTelegramWeeklyUpdate sends a weekly weather summary to users via Telegram
-func (s *NotificationService) SendTelegramWeeklyUpdate(user *models.User, summary string) error {
+// formatDailyWeather renders the weather summary of the daily digest in the
+// given unit system, with the time of the weather in zone
+func (s *NotificationService) formatDailyWeather(current *WeatherData, language, units string, zone *time.Location) string {
+	args, _ := templateArgs(dailyDigestTemplate, map[string]string{
+		"location":       markdown.EscapeBold(current.LocationName),
+		"temperature":    weather.FormatTemp(current.Temperature, units),
+		"humidity":       weather.FormatPercent(float64(current.Humidity)),
+		"wind_speed":     weather.FormatSpeed(current.WindSpeed, units),
+		"wind_direction": strconv.Itoa(current.WindDirection),
+		"aqi":            weather.FormatAQI(float64(current.AQI)),
+		"visibility":     weather.FormatVisibility(current.Visibility, units),
+		"updated":        current.Timestamp.In(zone).Format("15:04 MST"),
+	})
+
+	if s.localization == nil {
+		return fmt.Sprintf(defaultDailyDigestFormat, args...)
+	}
+	return s.localization.T(context.Background(), language, dailyDigestTemplate.Key, args...)
+}
+
+// SendTelegramWeeklyUpdate sends a weekly weather summary to the chat via
+// Telegram, followed by a short summary of current in the user's secondary
+// language when bilingual output is on
+func (s *NotificationService) SendTelegramWeeklyUpdate(user *models.User, chatID int64, summary string, current *WeatherData) error {
 	if s.bot == nil {
 		s.logger.Debug().Msg("Telegram bot not configured for notifications")
 		return nil
@@ -285,11 +456,14 @@ func (s *NotificationService) SendTelegramWeeklyUpdate(user *models.User, summar
 
 %s
 
-Have a great week ahead! 🌟`, user.LocationName, summary)
+Have a great week ahead! 🌟`, markdown.EscapeBold(user.LocationName), summary)
+	if current != nil {
+		message += SecondarySummary(user, WeatherSummary(s.localization, current, UserUnits(user)))
+	}
 
-	chatID := s.getTelegramChatID(user)
-	_, err := s.bot.SendMessage(chatID, message, &gotgbot.SendMessageOpts{
-		ParseMode: "Markdown",
+	_, err := s.bot.SendMessageWithContext(WithBulkPriority(context.Background()), chatID, message, &gotgbot.SendMessageOpts{
+		ParseMode:           "Markdown",
+		DisableNotification: user.QuietDigests,
 	})
 
 	if err != nil {
@@ -305,6 +479,113 @@ Have a great week ahead! 🌟`, user.LocationName, summary)
 	return nil
 }
 
+// SendTelegramSunrise tells the chat the sun rose at the user's location, when
+// it sets and what the weather is like
+func (s *NotificationService) SendTelegramSunrise(current *WeatherData, user *models.User, chatID int64) error {
+	if s.bot == nil {
+		s.logger.Debug().Msg("Telegram bot not configured for notifications")
+		return nil
+	}
+
+	message := formatSunrise(current, user, s.translator(user, defaultSunriseTexts)) + "\n\n" +
+		WeatherSummary(s.localization, current, UserUnits(user))(user.Language)
+	_, err := s.bot.SendMessageWithContext(WithBulkPriority(context.Background()), chatID, message, &gotgbot.SendMessageOpts{
+		ParseMode:           "Markdown",
+		DisableNotification: user.QuietDigests,
+	})
+	if err != nil {
+		s.logger.Error().
+			Err(err).
+			Int64("user_id", user.ID).
+			Int64("chat_id", chatID).
+			Msg("Failed to send Telegram sunrise notification - user may have blocked bot or deleted chat")
+		return fmt.Errorf("failed to send Telegram sunrise notification to user %d: %w", user.ID, err)
+	}
+
+	s.logger.Info().Int64("user_id", user.ID).Int64("chat_id", chatID).Msg("Telegram sunrise notification sent successfully")
+	return nil
+}
+
+// formatSunrise renders the header of a sunrise notification with the sunset
+// in the user's timezone and the length of the day, when the provider
+// reported both
+func formatSunrise(current *WeatherData, user *models.User, t func(key string, args ...any) string) string {
+	text := t("sunrise_notification_title")
+	if current.Sunrise.IsZero() || current.Sunset.IsZero() {
+		return text
+	}
+	daylight := current.Sunset.Sub(current.Sunrise).Round(time.Minute)
+	return text + "\n" +
+		t("sun_sunset", current.Sunset.In(userLocation(user)).Format("15:04")) + "\n" +
+		t("sun_day_length", int(daylight.Hours()), int(daylight.Minutes())%60)
+}
+
+// SendTelegramWeatherWarning sends an official weather warning to the chat.
+// The header and labels are in the user's language; the event name, issuer
+// and description are passed on as the weather service wrote them.
+func (s *NotificationService) SendTelegramWeatherWarning(user *models.User, chatID int64, warning *weather.WeatherWarning) error {
+	if s.bot == nil {
+		s.logger.Debug().Msg("Telegram bot not configured for notifications")
+		return nil
+	}
+
+	message := formatWeatherWarning(user, warning, s.translator(user, defaultWeatherWarningTexts))
+	_, err := s.bot.SendMessageWithContext(WithBulkPriority(context.Background()), chatID, message, &gotgbot.SendMessageOpts{
+		ParseMode: "Markdown",
+	})
+	if err != nil {
+		s.logger.Error().
+			Err(err).
+			Int64("user_id", user.ID).
+			Int64("chat_id", chatID).
+			Str("warning_id", warning.ID).
+			Msg("Failed to send Telegram weather warning - user may have blocked bot or deleted chat")
+		return fmt.Errorf("failed to send Telegram weather warning to user %d: %w", user.ID, err)
+	}
+
+	s.logger.Info().Int64("user_id", user.ID).Int64("chat_id", chatID).Str("warning_id", warning.ID).Msg("Telegram weather warning sent successfully")
+	return nil
+}
+
+// formatWeatherWarning renders an official warning with its period in the
+// user's timezone
+func formatWeatherWarning(user *models.User, warning *weather.WeatherWarning, t func(key string, args ...any) string) string {
+	location := userLocation(user)
+	layout := t("date_format_day_month") + " 15:04"
+
+	var b strings.Builder
+	b.WriteString(t("weather_warning_header"))
+	if user.LocationName != "" {
+		fmt.Fprintf(&b, "\n📍 *%s*", markdown.EscapeBold(user.LocationName))
+	}
+	fmt.Fprintf(&b, "\n\n%s *%s*\n", weatherWarningEmoji(warning.Severity), markdown.EscapeBold(warning.Event))
+	if warning.Severity != weather.WarningUnknown && warning.Severity != "" {
+		b.WriteString(t("weather_warning_severity", t("weather_warning_severity_"+string(warning.Severity))) + "\n")
+	}
+	b.WriteString(t("weather_warning_period", warning.Start.In(location).Format(layout), warning.End.In(location).Format(layout)) + "\n")
+	if warning.Sender != "" {
+		b.WriteString(t("weather_warning_issued_by", markdown.Escape(warning.Sender)) + "\n")
+	}
+	if warning.Description != "" {
+		b.WriteString("\n" + markdown.Escape(warning.Description))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// weatherWarningEmoji marks a warning by its severity, like the bot's own alerts
+func weatherWarningEmoji(severity weather.WarningSeverity) string {
+	switch severity {
+	case weather.WarningExtreme:
+		return "🆘"
+	case weather.WarningSevere:
+		return "🚨"
+	case weather.WarningModerate:
+		return "🔶"
+	default:
+		return "⚠️"
+	}
+}
+
 func (s *NotificationService) getSeverityEmoji(severity models.Severity) string {
 	switch severity {
 	case models.SeverityLow:
@@ -319,3 +600,108 @@ func (s *NotificationService) getSeverityEmoji(severity models.Severity) string
 		return "ℹ️"
 	}
 }
+
+// FormatAlertHistory renders triggered alerts under a title in the user's
+// language and timezone, as listed in the daily digest
+func (s *NotificationService) FormatAlertHistory(user *models.User, title string, activity *AlertActivity) string {
+	return formatAlertActivity(title, activity, userLocation(user), UserUnits(user), s.translator(user, defaultAlertActivityTexts))
+}
+
+// formatAlertActivity renders the "recent alert activity" digest section with
+// trigger times in the given timezone, values in the given unit system and
+// texts from t
+func formatAlertActivity(title string, activity *AlertActivity, location *time.Location, units string, t func(key string, args ...any) string) string {
+	var b strings.Builder
+	b.WriteString(title)
+	for _, alert := range activity.Alerts {
+		fmt.Fprintf(&b, "\n• %s — %s: %s",
+			alert.CreatedAt.In(location).Format("Jan 2 15:04"),
+			t(alertTypeKey(alert.AlertType)),
+			FormatAlertValue(alert.AlertType, alert.Value, units))
+		switch alert.Suppressed {
+		case models.SuppressedHoliday:
+			b.WriteString(" " + t("digest_alert_suppressed_holiday"))
+		case models.SuppressedQuietDay:
+			b.WriteString(" " + t("digest_alert_suppressed_quiet_day"))
+		}
+	}
+	if activity.More {
+		b.WriteString("\n" + t("digest_alert_activity_more"))
+	}
+	return b.String()
+}
+
+// FormatAlertValue formats an alert value, stored in metric, with the unit of
+// its metric in the given unit system
+func FormatAlertValue(alertType models.AlertType, value float64, units string) string {
+	switch alertType {
+	case models.AlertTemperature:
+		return weather.FormatTemp(value, units)
+	case models.AlertHumidity:
+		return weather.FormatPercent(value)
+	case models.AlertWindSpeed:
+		return weather.FormatSpeed(value, units)
+	case models.AlertPressure:
+		return weather.FormatPressure(value, units)
+	case models.AlertAirQuality:
+		return "AQI " + weather.FormatAQI(value)
+	case models.AlertPollen:
+		return fmt.Sprintf("risk %s/%d", weather.FormatDecimal(value, 0), weather.MaxPollenRisk)
+	case models.AlertTide:
+		return weather.FormatHeight(value, units)
+	default:
+		return weather.FormatDecimal(value, 1)
+	}
+}
+
+// AlertValueFromMetric converts an alert value, stored in metric, to the given
+// unit system. Values without a unit, such as humidity or AQI, are unchanged.
+func AlertValueFromMetric(alertType models.AlertType, value float64, units string) float64 {
+	if units != weather.UnitsImperial {
+		return value
+	}
+	switch alertType {
+	case models.AlertTemperature:
+		return weather.CelsiusToFahrenheit(value)
+	case models.AlertWindSpeed:
+		return weather.KmhToMph(value)
+	case models.AlertPressure:
+		return weather.HpaToInHg(value)
+	case models.AlertTide:
+		return weather.MetresToFeet(value)
+	default:
+		return value
+	}
+}
+
+// AlertValueToMetric converts an alert value entered in the given unit system
+// to metric, the unit alerts are stored and evaluated in
+func AlertValueToMetric(alertType models.AlertType, value float64, units string) float64 {
+	if units != weather.UnitsImperial {
+		return value
+	}
+	switch alertType {
+	case models.AlertTemperature:
+		return weather.FahrenheitToCelsius(value)
+	case models.AlertWindSpeed:
+		return weather.MphToKmh(value)
+	case models.AlertPressure:
+		return weather.InHgToHpa(value)
+	case models.AlertTide:
+		return weather.FeetToMetres(value)
+	default:
+		return value
+	}
+}
+
+// userLocation returns the user's timezone, falling back to UTC
+func userLocation(user *models.User) *time.Location {
+	if user.Timezone == "" {
+		return time.UTC
+	}
+	location, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,7 +14,9 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/internal/locales"
 	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/weather"
 	"github.com/valpere/shopogoda/tests/helpers"
 )
 
@@ -347,6 +350,77 @@ func TestNotificationService_SendTelegramAlert(t *testing.T) {
 	})
 }
 
+func TestNotificationService_SendTelegramAlert_Extreme(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	localization := NewLocalizationService(logger)
+	require.NoError(t, localization.LoadTranslations(locales.LocalesFS))
+
+	newService := func(t *testing.T) (*NotificationService, *restrictedBotAPI, *helpers.MockDB) {
+		api := &restrictedBotAPI{}
+		mockDB := helpers.NewMockDB(t)
+		t.Cleanup(func() { _ = mockDB.Close() })
+
+		service := NewNotificationService(&config.IntegrationsConfig{}, logger)
+		service.SetBot(&gotgbot.Bot{Token: "test", BotClient: api})
+		service.SetLocalization(localization)
+		service.SetEscalations(NewAlertEscalationService(mockDB.DB, localization, logger))
+		return service, api, mockDB
+	}
+	user := &models.User{ID: 42, Language: "en-US", LocationName: "Kyiv"}
+
+	t.Run("extreme alert stands out and awaits acknowledgment", func(t *testing.T) {
+		service, api, mockDB := newService(t)
+		alert := &models.EnvironmentalAlert{AlertType: models.AlertAirQuality, Title: "Hazardous air", Severity: models.SeverityCritical, Value: 320, Threshold: 150}
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`INSERT INTO "alert_escalations"`).
+			WithArgs(int64(42), int64(1), int64(42), "Hazardous air", helpers.AnyTime{}, helpers.AnyTime{}).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+
+		require.NoError(t, service.SendTelegramAlert(alert, user))
+
+		require.Len(t, api.requests, 1)
+		assert.True(t, strings.HasPrefix(api.requests[0].text, "🆘🆘🆘 EXTREME WEATHER WARNING 🆘🆘🆘\n\n"))
+		assert.Contains(t, api.params[0], "reply_markup")
+		assert.NotContains(t, api.params[0], "disable_notification", "alerts are never silent")
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("normal alert is sent as before", func(t *testing.T) {
+		service, api, mockDB := newService(t)
+		alert := &models.EnvironmentalAlert{AlertType: models.AlertWindSpeed, Title: "Windy", Severity: models.SeverityMedium}
+
+		require.NoError(t, service.SendTelegramAlert(alert, user))
+
+		require.Len(t, api.requests, 1)
+		assert.True(t, strings.HasPrefix(api.requests[0].text, "🔶 *Weather Alert*"))
+		assert.NotContains(t, api.params[0], "reply_markup")
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestNotificationService_QuietDigests(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	current := &WeatherData{LocationName: "Kyiv", Temperature: 20, Timestamp: time.Now()}
+
+	for _, quiet := range []bool{false, true} {
+		api := &restrictedBotAPI{}
+		service := NewNotificationService(&config.IntegrationsConfig{}, logger)
+		service.SetBot(&gotgbot.Bot{Token: "test", BotClient: api})
+		user := &models.User{ID: 42, LocationName: "Kyiv", QuietDigests: quiet}
+
+		require.NoError(t, service.SendTelegramDailyDigest(current, user, user.ID, nil, ""))
+		require.NoError(t, service.SendTelegramWeeklyUpdate(user, user.ID, "summary", current))
+
+		require.Len(t, api.params, 2)
+		for _, params := range api.params {
+			_, silent := params["disable_notification"]
+			assert.Equal(t, quiet, silent)
+		}
+	}
+}
+
 func TestNotificationService_SendTelegramWeatherUpdate(t *testing.T) {
 	logger := helpers.NewSilentTestLogger()
 	cfg := &config.IntegrationsConfig{}
@@ -386,7 +460,100 @@ func TestNotificationService_SendTelegramWeeklyUpdate(t *testing.T) {
 
 		summary := "Week summary: Mostly sunny with occasional clouds"
 
-		err := service.SendTelegramWeeklyUpdate(user, summary)
+		err := service.SendTelegramWeeklyUpdate(user, user.ID, summary, nil)
 		assert.NoError(t, err)
 	})
 }
+
+func TestFormatAlertActivity(t *testing.T) {
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	if err != nil {
+		t.Skip("timezone data not available")
+	}
+
+	activity := &AlertActivity{
+		Alerts: []models.EnvironmentalAlert{
+			{AlertType: models.AlertTemperature, Value: 31.24, CreatedAt: time.Date(2025, 7, 2, 12, 4, 0, 0, time.UTC)},
+			{AlertType: models.AlertAirQuality, Value: 151, CreatedAt: time.Date(2025, 7, 1, 6, 30, 0, 0, time.UTC)},
+		},
+		More: true,
+	}
+
+	logger := helpers.NewSilentTestLogger()
+	localization := NewLocalizationService(logger)
+	require.NoError(t, localization.LoadTranslations(locales.LocalesFS))
+	service := NewNotificationService(&config.IntegrationsConfig{}, logger)
+	service.SetLocalization(localization)
+
+	en := service.translator(&models.User{Language: "en-US"}, nil)
+	text := formatAlertActivity(en("digest_alert_activity_title"), activity, kyiv, weather.UnitsMetric, en)
+
+	assert.Contains(t, text, "🔔 *Recent Alert Activity*")
+	assert.Contains(t, text, "• Jul 2 15:04 — Temperature: 31.2°C")
+	assert.Contains(t, text, "• Jul 1 09:30 — Air quality: AQI 151")
+	assert.Contains(t, text, "…and more")
+
+	uk := service.translator(&models.User{Language: "uk-UA"}, nil)
+	text = formatAlertActivity(uk("digest_alert_activity_title"), activity, kyiv, weather.UnitsImperial, uk)
+
+	// Values are stored in metric and follow the user's current units
+	assert.Contains(t, text, "Температура: 88.2°F")
+	assert.NotContains(t, text, "Recent Alert Activity")
+}
+
+func TestFormatWeatherWarning(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Kyiv"); err != nil {
+		t.Skip("timezone data not available")
+	}
+
+	warning := &weather.WeatherWarning{
+		Event:       "Orange Wind_Warning",
+		Sender:      "Ukrainian Hydrometeorological Center",
+		Severity:    weather.WarningSevere,
+		Start:       time.Date(2026, 4, 15, 5, 0, 0, 0, time.UTC),
+		End:         time.Date(2026, 4, 15, 21, 0, 0, 0, time.UTC),
+		Description: "Gusts of 20-25 m/s *in the afternoon*",
+	}
+
+	logger := helpers.NewSilentTestLogger()
+	localization := NewLocalizationService(logger)
+	require.NoError(t, localization.LoadTranslations(locales.LocalesFS))
+	service := NewNotificationService(&config.IntegrationsConfig{}, logger)
+	service.SetLocalization(localization)
+
+	user := &models.User{Language: "en-US", LocationName: "Kyiv", Timezone: "Europe/Kyiv"}
+	text := formatWeatherWarning(user, warning, service.translator(user, nil))
+
+	assert.True(t, strings.HasPrefix(text, "⚠️ *Official Weather Warning*\n📍 *Kyiv*"))
+	assert.Contains(t, text, "🚨 *Orange Wind_Warning*")
+	assert.Contains(t, text, "Severity: Severe")
+	assert.Contains(t, text, "🕒 Apr 15 08:00 – Apr 16 00:00")
+	assert.Contains(t, text, "Issued by Ukrainian Hydrometeorological Center")
+	assert.True(t, strings.HasSuffix(text, "\n\nGusts of 20-25 m/s \\*in the afternoon\\*"), "the description is passed on verbatim")
+
+	user.Language = "uk-UA"
+	text = formatWeatherWarning(user, warning, service.translator(user, nil))
+	assert.Contains(t, text, "Офіційне попередження про погоду")
+	assert.Contains(t, text, "🕒 15.04 08:00 – 16.04 00:00")
+	assert.Contains(t, text, "Orange Wind_Warning", "the event keeps the issuer's language")
+
+	warning.Severity = weather.WarningUnknown
+	text = formatWeatherWarning(user, warning, service.translator(user, nil))
+	assert.NotContains(t, text, "Рівень")
+}
+
+func TestFormatSunrise(t *testing.T) {
+	service := NewNotificationService(&config.IntegrationsConfig{}, helpers.NewSilentTestLogger())
+	user := &models.User{Language: "en-US", Timezone: "UTC"}
+	current := &WeatherData{
+		Sunrise: time.Date(2024, 6, 21, 3, 43, 0, 0, time.UTC),
+		Sunset:  time.Date(2024, 6, 21, 20, 21, 20, 0, time.UTC),
+	}
+
+	text := formatSunrise(current, user, service.translator(user, defaultSunriseTexts))
+	assert.Equal(t, "🌅 *Good morning, the sun is up*\n🌇 Sunset: 20:21\n⏳ Day length: 16h 38m", text)
+
+	// Without the provider's times there is only the greeting
+	text = formatSunrise(&WeatherData{}, user, service.translator(user, defaultSunriseTexts))
+	assert.Equal(t, "🌅 *Good morning, the sun is up*", text)
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/valpere/shopogoda/internal/models"
+)
+
+// OnboardingStep is a step of the guided setup /start walks new users through
+type OnboardingStep string
+
+const (
+	OnboardingLanguage     OnboardingStep = "language"
+	OnboardingLocation     OnboardingStep = "location"
+	OnboardingSubscription OnboardingStep = "subscription"
+)
+
+// OnboardingTimeout is how long an unfinished onboarding waits for the user
+// before the next /start begins it again
+const OnboardingTimeout = 7 * 24 * time.Hour
+
+// onboardingKey holds the step a user is at. It lives under user:{id}: so that
+// deleting the account removes it.
+func onboardingKey(userID int64) string {
+	return fmt.Sprintf("user:%d:onboarding", userID)
+}
+
+// NeedsOnboarding reports whether /start should guide the user through the
+// setup: they have neither a location nor finished or skipped it before
+func NeedsOnboarding(user *models.User) bool {
+	return user != nil && user.LocationName == "" && user.OnboardedAt == nil
+}
+
+// OnboardingService tracks new users through the guided setup: the step they
+// are at is kept in Redis, when they started and finished it on the user
+type OnboardingService struct {
+	redis *redis.Client
+	users *UserService
+}
+
+func NewOnboardingService(redis *redis.Client, users *UserService) *OnboardingService {
+	return &OnboardingService{redis: redis, users: users}
+}
+
+// Begin puts the user at the first step, restarting an unfinished onboarding
+func (s *OnboardingService) Begin(ctx context.Context, userID int64) error {
+	if err := s.users.MarkOnboardingStarted(ctx, userID); err != nil {
+		return err
+	}
+	return s.Advance(ctx, userID, OnboardingLanguage)
+}
+
+// Advance moves the user to the given step
+func (s *OnboardingService) Advance(ctx context.Context, userID int64, step OnboardingStep) error {
+	if err := s.redis.Set(ctx, onboardingKey(userID), string(step), OnboardingTimeout).Err(); err != nil {
+		return fmt.Errorf("failed to store onboarding step: %w", err)
+	}
+	return nil
+}
+
+// Step returns the step the user is at, empty when they are not onboarding
+func (s *OnboardingService) Step(ctx context.Context, userID int64) (OnboardingStep, error) {
+	step, err := s.redis.Get(ctx, onboardingKey(userID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load onboarding step: %w", err)
+	}
+	return OnboardingStep(step), nil
+}
+
+// Complete ends the onboarding, finished or skipped, and records it on the user
+func (s *OnboardingService) Complete(ctx context.Context, userID int64) error {
+	if err := s.users.MarkOnboarded(ctx, userID); err != nil {
+		return err
+	}
+	return s.redis.Del(ctx, onboardingKey(userID)).Err()
+}
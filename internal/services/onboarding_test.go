@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestNeedsOnboarding(t *testing.T) {
+	onboarded := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+
+	assert.True(t, NeedsOnboarding(&models.User{ID: 42}))
+	assert.False(t, NeedsOnboarding(&models.User{ID: 42, LocationName: "Kyiv"}))
+	assert.False(t, NeedsOnboarding(&models.User{ID: 42, OnboardedAt: &onboarded}))
+	assert.False(t, NeedsOnboarding(nil))
+}
+
+func TestOnboardingService(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	mockRedis := helpers.NewMockRedis()
+	users := NewUserService(mockDB.DB, mockRedis.Client, nil, helpers.NewSilentTestLogger(), time.Now())
+	service := NewOnboardingService(mockRedis.Client, users)
+	ctx := context.Background()
+
+	t.Run("begin records the start once and puts the user at the language step", func(t *testing.T) {
+		mockRedis.Mock.ExpectDel("user:42").SetVal(1)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "users" SET "onboarding_started_at"=\$1,"updated_at"=\$2 WHERE \(id = \$3 AND onboarding_started_at IS NULL\) AND "users"."deleted_at" IS NULL`).
+			WithArgs(helpers.AnyTime{}, helpers.AnyTime{}, int64(42)).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+		mockRedis.Mock.ExpectSet("user:42:onboarding", "language", OnboardingTimeout).SetVal("OK")
+
+		require.NoError(t, service.Begin(ctx, 42))
+		mockDB.ExpectationsWereMet(t)
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("step reads the stored step", func(t *testing.T) {
+		mockRedis.Mock.ExpectGet("user:42:onboarding").SetVal("location")
+		step, err := service.Step(ctx, 42)
+		require.NoError(t, err)
+		assert.Equal(t, OnboardingLocation, step)
+
+		mockRedis.Mock.ExpectGet("user:43:onboarding").RedisNil()
+		step, err = service.Step(ctx, 43)
+		require.NoError(t, err)
+		assert.Empty(t, step)
+	})
+
+	t.Run("complete records the end and forgets the step", func(t *testing.T) {
+		mockRedis.Mock.ExpectDel("user:42").SetVal(1)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "users" SET "onboarded_at"=\$1,"updated_at"=\$2 WHERE \(id = \$3 AND onboarded_at IS NULL\) AND "users"."deleted_at" IS NULL`).
+			WithArgs(helpers.AnyTime{}, helpers.AnyTime{}, int64(42)).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+		mockRedis.Mock.ExpectDel("user:42:onboarding").SetVal(1)
+
+		require.NoError(t, service.Complete(ctx, 42))
+		mockDB.ExpectationsWereMet(t)
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+}
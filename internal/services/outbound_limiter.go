@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/rs/zerolog"
+	"golang.org/x/time/rate"
+
+	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/pkg/metrics"
+)
+
+// SendPriority selects the rate limiter lane an outbound message waits in
+type SendPriority int
+
+const (
+	// PriorityInteractive is used for replies to something the user just did
+	PriorityInteractive SendPriority = iota
+	// PriorityBulk is used for scheduled notifications, broadcasts and alerts
+	PriorityBulk
+)
+
+func (p SendPriority) String() string {
+	if p == PriorityBulk {
+		return "bulk"
+	}
+	return "interactive"
+}
+
+const (
+	defaultBulkRate         = 25
+	defaultInteractiveRate  = 5
+	defaultInteractiveBurst = 5
+
+	// maxBulkRetries bounds how often a bulk message is retried after a 429
+	maxBulkRetries = 2
+	// pauseJitterRatio is the largest extra pause added on top of retry_after,
+	// so paused senders do not all resume in the same instant
+	pauseJitterRatio = 0.2
+)
+
+type priorityKey struct{}
+
+// WithBulkPriority marks every Telegram request made with ctx as bulk traffic.
+// Requests without the mark are treated as interactive replies.
+func WithBulkPriority(ctx context.Context) context.Context {
+	return context.WithValue(ctx, priorityKey{}, PriorityBulk)
+}
+
+func priorityFromContext(ctx context.Context) SendPriority {
+	if priority, ok := ctx.Value(priorityKey{}).(SendPriority); ok {
+		return priority
+	}
+	return PriorityInteractive
+}
+
+// OutboundLimiter keeps the bot under Telegram's global message limit. Bulk
+// traffic shares one token bucket and is paused whenever Telegram answers 429;
+// interactive replies have their own small bucket so a large notification slot
+// never delays the answer to a command.
+type OutboundLimiter struct {
+	bulk        *rate.Limiter
+	interactive *rate.Limiter
+	metrics     *metrics.Metrics
+	logger      *zerolog.Logger
+	jitter      func(max time.Duration) time.Duration
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+	waiting     map[SendPriority]int
+}
+
+func NewOutboundLimiter(cfg *config.OutboundConfig, metricsCollector *metrics.Metrics, logger *zerolog.Logger) *OutboundLimiter {
+	bulkRate := cfg.BulkRate
+	if bulkRate <= 0 {
+		bulkRate = defaultBulkRate
+	}
+	interactiveRate := cfg.InteractiveRate
+	if interactiveRate <= 0 {
+		interactiveRate = defaultInteractiveRate
+	}
+	interactiveBurst := cfg.InteractiveBurst
+	if interactiveBurst <= 0 {
+		interactiveBurst = defaultInteractiveBurst
+	}
+
+	return &OutboundLimiter{
+		bulk:        rate.NewLimiter(rate.Limit(bulkRate), 1),
+		interactive: rate.NewLimiter(rate.Limit(interactiveRate), interactiveBurst),
+		metrics:     metricsCollector,
+		logger:      logger,
+		jitter:      randomJitter,
+		waiting:     make(map[SendPriority]int),
+	}
+}
+
+func randomJitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return rand.N(max) // #nosec G404 -- jitter does not need a secure source
+}
+
+// Wait blocks until a message of the given priority may be sent
+func (l *OutboundLimiter) Wait(ctx context.Context, priority SendPriority) error {
+	l.trackWaiting(priority, 1)
+	defer l.trackWaiting(priority, -1)
+
+	if priority == PriorityInteractive {
+		return l.interactive.Wait(ctx)
+	}
+
+	for {
+		pause := l.remainingPause()
+		if pause <= 0 {
+			break
+		}
+		timer := time.NewTimer(pause)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return l.bulk.Wait(ctx)
+}
+
+// Pause holds back bulk traffic for d plus a random share of it. A shorter
+// pause never cuts an existing one short.
+func (l *OutboundLimiter) Pause(d time.Duration) {
+	until := time.Now().Add(d + l.jitter(time.Duration(float64(d)*pauseJitterRatio)))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+func (l *OutboundLimiter) remainingPause() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return time.Until(l.pausedUntil)
+}
+
+func (l *OutboundLimiter) trackWaiting(priority SendPriority, delta int) {
+	l.mu.Lock()
+	l.waiting[priority] += delta
+	depth := l.waiting[priority]
+	l.mu.Unlock()
+
+	if l.metrics != nil {
+		l.metrics.SetGauge("telegram_outbound_queue_depth", float64(depth), priority.String())
+	}
+}
+
+// observe records the outcome of a send of method, failures by their Telegram
+// error code, and reports whether Telegram rejected it for flooding
+func (l *OutboundLimiter) observe(method string, priority SendPriority, err error) bool {
+	status := "sent"
+	if err != nil {
+		status = "failed"
+	}
+
+	var tgErr *gotgbot.TelegramError
+	limited := errors.As(err, &tgErr) && tgErr.Code == 429
+	if limited {
+		status = "rate_limited"
+	}
+
+	if l.metrics != nil {
+		l.metrics.IncrementCounter("telegram_outbound_messages_total", priority.String(), status)
+		if limited {
+			l.metrics.IncrementCounter("telegram_rate_limited_total", priority.String())
+		}
+		if err != nil {
+			l.metrics.IncrementCounter("telegram_send_failures_total", method, sendErrorCode(err))
+		}
+	}
+
+	if !limited {
+		return false
+	}
+
+	retryAfter := time.Second
+	if tgErr.ResponseParams != nil && tgErr.ResponseParams.RetryAfter > 0 {
+		retryAfter = time.Duration(tgErr.ResponseParams.RetryAfter) * time.Second
+	}
+
+	// Any 429 means the bot as a whole is over the limit, so bulk traffic
+	// backs off even when the rejected message was an interactive reply
+	l.Pause(retryAfter)
+	l.logger.Warn().
+		Str("priority", priority.String()).
+		Dur("retry_after", retryAfter).
+		Msg("Telegram rate limit hit, pausing bulk messages")
+
+	return true
+}
+
+// WrapClient returns a bot client that paces message sends through the limiter.
+// Other API methods (callback answers, getUpdates, file downloads) pass through.
+func (l *OutboundLimiter) WrapClient(client gotgbot.BotClient) gotgbot.BotClient {
+	return &limitedBotClient{BotClient: client, limiter: l}
+}
+
+type limitedBotClient struct {
+	gotgbot.BotClient
+	limiter *OutboundLimiter
+}
+
+func (c *limitedBotClient) RequestWithContext(ctx context.Context, token string, method string, params map[string]any, opts *gotgbot.RequestOpts) (json.RawMessage, error) {
+	if !isMessageMethod(method) {
+		return c.BotClient.RequestWithContext(ctx, token, method, params, opts)
+	}
+
+	priority := priorityFromContext(ctx)
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.Wait(ctx, priority); err != nil {
+			return nil, err
+		}
+
+		result, err := c.BotClient.RequestWithContext(ctx, token, method, params, opts)
+		if !c.limiter.observe(method, priority, err) {
+			return result, err
+		}
+
+		// Interactive replies are not retried: by the time the pause ends the
+		// user has moved on, and the handler reports the error itself
+		if priority == PriorityInteractive || attempt >= maxBulkRetries {
+			return result, err
+		}
+	}
+}
+
+// sendErrorCode is the Telegram error code of a failed send, or "network" when
+// the request never got an answer from Telegram
+func sendErrorCode(err error) string {
+	var tgErr *gotgbot.TelegramError
+	if errors.As(err, &tgErr) {
+		return strconv.Itoa(tgErr.Code)
+	}
+	return "network"
+}
+
+func isMessageMethod(method string) bool {
+	for _, prefix := range []string{"send", "edit", "copy", "forward"} {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
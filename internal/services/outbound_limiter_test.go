@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+// fakeBotAPI records requests and answers the first rateLimited message
+// requests with a 429, like Telegram does when the bot sends too fast, and
+// the rest with failure when it is set
+type fakeBotAPI struct {
+	mu          sync.Mutex
+	calls       []string
+	rateLimited int
+	retryAfter  int64
+	failure     error
+}
+
+func (f *fakeBotAPI) RequestWithContext(ctx context.Context, token string, method string, params map[string]any, opts *gotgbot.RequestOpts) (json.RawMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, method)
+
+	if method == "sendMessage" && f.rateLimited > 0 {
+		f.rateLimited--
+		return nil, &gotgbot.TelegramError{
+			Method:         method,
+			Code:           429,
+			Description:    "Too Many Requests: retry after 1",
+			ResponseParams: &gotgbot.ResponseParameters{RetryAfter: f.retryAfter},
+		}
+	}
+
+	if method == "sendMessage" && f.failure != nil {
+		return nil, f.failure
+	}
+
+	if method == "getMe" {
+		return json.RawMessage(`{"id": 1, "is_bot": true, "first_name": "ShoPogoda"}`), nil
+	}
+	return json.RawMessage(`{"message_id": 1, "date": 0, "chat": {"id": 123, "type": "private"}}`), nil
+}
+
+func (f *fakeBotAPI) GetAPIURL(opts *gotgbot.RequestOpts) string {
+	return gotgbot.DefaultAPIURL
+}
+
+func (f *fakeBotAPI) FileURL(token string, tgFilePath string, opts *gotgbot.RequestOpts) string {
+	return ""
+}
+
+func (f *fakeBotAPI) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.calls)
+}
+
+func newLimitedTestBot(cfg config.OutboundConfig) (*gotgbot.Bot, *OutboundLimiter, *fakeBotAPI) {
+	api := &fakeBotAPI{}
+	limiter := NewOutboundLimiter(&cfg, nil, helpers.NewSilentTestLogger())
+	limiter.jitter = func(time.Duration) time.Duration { return 0 }
+	bot := &gotgbot.Bot{Token: "test", BotClient: limiter.WrapClient(api)}
+	return bot, limiter, api
+}
+
+func TestOutboundLimiter_PacesBulkMessages(t *testing.T) {
+	bot, _, api := newLimitedTestBot(config.OutboundConfig{BulkRate: 20})
+	ctx := WithBulkPriority(context.Background())
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		_, err := bot.SendMessageWithContext(ctx, 123, "update", nil)
+		require.NoError(t, err)
+	}
+	elapsed := time.Since(start)
+
+	// The first message goes out at once, the other four wait 50ms each
+	assert.GreaterOrEqual(t, elapsed, 190*time.Millisecond)
+	assert.Less(t, elapsed, time.Second)
+	assert.Equal(t, 5, api.callCount())
+}
+
+func TestOutboundLimiter_InteractiveNotBlockedByBulk(t *testing.T) {
+	bot, limiter, _ := newLimitedTestBot(config.OutboundConfig{BulkRate: 1, InteractiveRate: 5, InteractiveBurst: 3})
+	limiter.Pause(time.Minute)
+
+	t.Run("bulk waits for the pause", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(WithBulkPriority(context.Background()), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := bot.SendMessageWithContext(ctx, 123, "digest", nil)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("interactive replies go out immediately", func(t *testing.T) {
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			_, err := bot.SendMessage(123, "reply", nil)
+			require.NoError(t, err)
+		}
+
+		assert.Less(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("other API methods are not limited", func(t *testing.T) {
+		start := time.Now()
+		_, err := bot.GetMe(nil)
+
+		require.NoError(t, err)
+		assert.Less(t, time.Since(start), 100*time.Millisecond)
+	})
+}
+
+func TestOutboundLimiter_RateLimitResponses(t *testing.T) {
+	t.Run("bulk message is retried after retry_after", func(t *testing.T) {
+		bot, _, api := newLimitedTestBot(config.OutboundConfig{BulkRate: 100})
+		api.rateLimited = 1
+		api.retryAfter = 1
+
+		start := time.Now()
+		_, err := bot.SendMessageWithContext(WithBulkPriority(context.Background()), 123, "digest", nil)
+
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), time.Second)
+		assert.Equal(t, 2, api.callCount())
+	})
+
+	t.Run("bulk retries are bounded", func(t *testing.T) {
+		bot, _, api := newLimitedTestBot(config.OutboundConfig{BulkRate: 100})
+		api.rateLimited = maxBulkRetries + 1
+		api.retryAfter = 1
+		ctx := WithBulkPriority(context.Background())
+
+		_, err := bot.SendMessageWithContext(ctx, 123, "digest", nil)
+
+		var tgErr *gotgbot.TelegramError
+		require.ErrorAs(t, err, &tgErr)
+		assert.Equal(t, 429, tgErr.Code)
+		assert.Equal(t, maxBulkRetries+1, api.callCount())
+	})
+
+	t.Run("interactive reply fails fast and pauses bulk", func(t *testing.T) {
+		bot, limiter, api := newLimitedTestBot(config.OutboundConfig{BulkRate: 100})
+		api.rateLimited = 1
+		api.retryAfter = 30
+
+		start := time.Now()
+		_, err := bot.SendMessage(123, "reply", nil)
+
+		var tgErr *gotgbot.TelegramError
+		require.ErrorAs(t, err, &tgErr)
+		assert.Less(t, time.Since(start), 100*time.Millisecond)
+		assert.Equal(t, 1, api.callCount())
+		assert.Greater(t, limiter.remainingPause(), 29*time.Second)
+	})
+}
+
+func TestOutboundLimiter_CountsSendFailures(t *testing.T) {
+	metricsCollector, registry := helpers.NewTestMetrics()
+	api := &fakeBotAPI{rateLimited: 1, retryAfter: 30}
+	limiter := NewOutboundLimiter(&config.OutboundConfig{BulkRate: 100}, metricsCollector, helpers.NewSilentTestLogger())
+	bot := &gotgbot.Bot{Token: "test", BotClient: limiter.WrapClient(api)}
+
+	_, err := bot.SendMessage(123, "reply", nil)
+	require.Error(t, err)
+
+	api.failure = &gotgbot.TelegramError{Method: "sendMessage", Code: 403, Description: "Forbidden: bot was blocked by the user"}
+	_, err = bot.SendMessage(123, "reply", nil)
+	require.Error(t, err)
+
+	api.failure = errors.New("connection reset by peer")
+	_, err = bot.SendMessage(123, "reply", nil)
+	require.Error(t, err)
+
+	api.failure = nil
+	_, err = bot.SendMessage(123, "reply", nil)
+	require.NoError(t, err)
+
+	series := helpers.ScrapeMetrics(t, registry)
+	assert.Equal(t, 1.0, series[`telegram_send_failures_total{error_code="429",method="sendMessage"}`])
+	assert.Equal(t, 1.0, series[`telegram_send_failures_total{error_code="403",method="sendMessage"}`])
+	assert.Equal(t, 1.0, series[`telegram_send_failures_total{error_code="network",method="sendMessage"}`])
+	assert.Equal(t, 1.0, series[`telegram_outbound_messages_total{priority="interactive",status="sent"}`])
+}
+
+func TestOutboundLimiter_PauseNeverShortens(t *testing.T) {
+	limiter := NewOutboundLimiter(&config.OutboundConfig{}, nil, helpers.NewSilentTestLogger())
+	limiter.jitter = func(max time.Duration) time.Duration { return max }
+
+	limiter.Pause(10 * time.Second)
+	limiter.Pause(time.Second)
+
+	// 10s plus the full 20% jitter
+	assert.Greater(t, limiter.remainingPause(), 11*time.Second)
+}
+
+func TestSpreadDelay(t *testing.T) {
+	window := 5 * time.Minute
+
+	assert.Equal(t, time.Duration(0), spreadDelay(0, 10, window))
+	assert.Equal(t, 30*time.Second, spreadDelay(1, 10, window))
+	assert.Equal(t, 270*time.Second, spreadDelay(9, 10, window))
+	assert.Equal(t, time.Duration(0), spreadDelay(0, 1, window))
+	assert.Equal(t, time.Duration(0), spreadDelay(5, 10, 0))
+}
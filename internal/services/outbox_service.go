@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/metrics"
+)
+
+// Error classes of failed deliveries. Classes appear in callback data, so they
+// must not contain underscores.
+const (
+	DeliveryErrorBlocked      = "blocked"        // The user blocked the bot or deleted the account
+	DeliveryErrorChatNotFound = "chat-not-found" // The chat no longer exists
+	DeliveryErrorBadRequest   = "bad-request"    // Telegram rejected the message itself
+	DeliveryErrorRateLimited  = "rate-limited"   // Telegram answered 429 after the limiter's own retries
+	DeliveryErrorWeather      = "weather"        // Weather data for the notification was unavailable
+	DeliveryErrorPaused       = "paused"         // Not sent yet because the bot may not post in the chat
+	DeliveryErrorInterrupted  = "interrupted"    // Not sent yet because delivery stopped before its turn
+	DeliveryErrorOther        = "other"
+)
+
+// deliveryErrorClasses lists every class, so the dead letter gauge can be
+// reset to zero for classes that were purged
+var deliveryErrorClasses = []string{
+	DeliveryErrorBlocked,
+	DeliveryErrorChatNotFound,
+	DeliveryErrorBadRequest,
+	DeliveryErrorRateLimited,
+	DeliveryErrorWeather,
+	DeliveryErrorPaused,
+	DeliveryErrorInterrupted,
+	DeliveryErrorOther,
+}
+
+const (
+	// maxDeliveryAttempts is how often a notification is tried, counting the
+	// scheduled send, before it becomes a dead letter
+	maxDeliveryAttempts = 3
+	// defaultDeadLetterRetention applies when no retention is configured
+	defaultDeadLetterRetention = 30 * 24 * time.Hour
+	// maxOutboxErrorLength bounds the stored error message
+	maxOutboxErrorLength = 500
+)
+
+// errWeatherUnavailable marks scheduled notifications that failed before
+// anything was sent because the weather could not be fetched
+var errWeatherUnavailable = errors.New("failed to get weather")
+
+// errDeliveryInterrupted marks scheduled notifications that were not attempted
+// because the scheduler stopped or the next run was due before their turn
+var errDeliveryInterrupted = errors.New("delivery interrupted before its turn")
+
+// DeadLetterClass summarizes the dead letters of one error class
+type DeadLetterClass struct {
+	ErrorClass string
+	Count      int64
+	Oldest     time.Time
+	Newest     time.Time
+}
+
+// OutboxService keeps scheduled notifications whose delivery failed: pending
+// items are retried by the scheduler, dead letters wait for an admin to retry,
+// purge or export them and are dropped after the retention period
+type OutboxService struct {
+	db        *gorm.DB
+	metrics   *metrics.Metrics
+	logger    *zerolog.Logger
+	retention time.Duration
+	now       func() time.Time
+}
+
+func NewOutboxService(db *gorm.DB, metricsCollector *metrics.Metrics, logger *zerolog.Logger, retention time.Duration) *OutboxService {
+	if retention <= 0 {
+		retention = defaultDeadLetterRetention
+	}
+	return &OutboxService{
+		db:        db,
+		metrics:   metricsCollector,
+		logger:    logger,
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// Fail records a failed delivery attempt of item. New items are created; the
+// item stays pending until it has been tried maxDeliveryAttempts times or the
+// error cannot be fixed by retrying, then it becomes a dead letter.
+func (s *OutboxService) Fail(ctx context.Context, item *models.OutboxItem, cause error) error {
+	item.Attempts++
+	item.ErrorClass = DeliveryErrorClass(cause)
+	item.LastError = truncateError(cause)
+	item.Status = models.OutboxPending
+	item.DeadAt = nil
+	if item.Attempts >= maxDeliveryAttempts || permanentDeliveryError(item.ErrorClass) {
+		now := s.now().UTC()
+		item.Status = models.OutboxDead
+		item.DeadAt = &now
+	}
+
+	if item.ID == uuid.Nil {
+		return s.db.WithContext(ctx).Create(item).Error
+	}
+	return s.db.WithContext(ctx).Model(&models.OutboxItem{}).
+		Where("id = ?", item.ID).
+		Updates(map[string]interface{}{
+			"status":      item.Status,
+			"attempts":    item.Attempts,
+			"error_class": item.ErrorClass,
+			"last_error":  item.LastError,
+			"dead_at":     item.DeadAt,
+		}).Error
+}
+
+// Defer keeps a scheduled notification that was not attempted, because the
+// bot may not post in the chat right now or delivery was interrupted, as
+// pending without counting an attempt. A subscription has at most one deferred
+// item, so a long pause ends with one notification instead of a backlog.
+func (s *OutboxService) Defer(ctx context.Context, subscriptionID uuid.UUID, userID int64, cause error) error {
+	var pending int64
+	err := s.db.WithContext(ctx).Model(&models.OutboxItem{}).
+		Where("subscription_id = ? AND status = ?", subscriptionID, models.OutboxPending).
+		Count(&pending).Error
+	if err != nil || pending > 0 {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Create(&models.OutboxItem{
+		SubscriptionID: subscriptionID,
+		UserID:         userID,
+		Status:         models.OutboxPending,
+		ErrorClass:     DeliveryErrorClass(cause),
+		LastError:      truncateError(cause),
+	}).Error
+}
+
+// Resolve removes an item that was delivered on retry or is no longer wanted
+func (s *OutboxService) Resolve(ctx context.Context, id uuid.UUID) error {
+	return s.db.WithContext(ctx).Where("id = ?", id).Delete(&models.OutboxItem{}).Error
+}
+
+// Pending returns the items waiting for a retry with their subscription and user
+func (s *OutboxService) Pending(ctx context.Context) ([]models.OutboxItem, error) {
+	var items []models.OutboxItem
+	err := s.db.WithContext(ctx).
+		Preload("Subscription.User").
+		Where("status = ?", models.OutboxPending).
+		Order("created_at").
+		Find(&items).Error
+	return items, err
+}
+
+// DeadLetters returns the number and age range of dead letters per error class,
+// largest class first
+func (s *OutboxService) DeadLetters(ctx context.Context) ([]DeadLetterClass, error) {
+	var classes []DeadLetterClass
+	err := s.db.WithContext(ctx).Model(&models.OutboxItem{}).
+		Select("error_class, COUNT(*) AS count, MIN(dead_at) AS oldest, MAX(dead_at) AS newest").
+		Where("status = ?", models.OutboxDead).
+		Group("error_class").
+		Order("count DESC").
+		Scan(&classes).Error
+	return classes, err
+}
+
+// RetryClass moves the dead letters of a class back to pending with a fresh
+// retry budget, e.g. after the bug behind them was fixed. Items already moved
+// are not counted again.
+func (s *OutboxService) RetryClass(ctx context.Context, errorClass string) (int64, error) {
+	result := s.db.WithContext(ctx).Model(&models.OutboxItem{}).
+		Where("status = ? AND error_class = ?", models.OutboxDead, errorClass).
+		Updates(map[string]interface{}{
+			"status":   models.OutboxPending,
+			"attempts": 0,
+			"dead_at":  nil,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// PurgeClass deletes the dead letters of a class
+func (s *OutboxService) PurgeClass(ctx context.Context, errorClass string) (int64, error) {
+	result := s.db.WithContext(ctx).
+		Where("status = ? AND error_class = ?", models.OutboxDead, errorClass).
+		Delete(&models.OutboxItem{})
+	return result.RowsAffected, result.Error
+}
+
+// ExportClass returns the dead letters of a class as indented JSON
+func (s *OutboxService) ExportClass(ctx context.Context, errorClass string) ([]byte, error) {
+	var items []models.OutboxItem
+	if err := s.db.WithContext(ctx).
+		Where("status = ? AND error_class = ?", models.OutboxDead, errorClass).
+		Order("dead_at").
+		Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(items, "", "  ")
+}
+
+// expired selects the dead letters older than the retention period
+func (s *OutboxService) expired(ctx context.Context) *gorm.DB {
+	cutoff := s.now().UTC().Add(-s.retention)
+	return s.db.WithContext(ctx).Where("status = ? AND dead_at < ?", models.OutboxDead, cutoff)
+}
+
+// CountExpired returns how many dead letters PurgeExpired would delete
+func (s *OutboxService) CountExpired(ctx context.Context) (int64, error) {
+	var count int64
+	err := s.expired(ctx).Model(&models.OutboxItem{}).Count(&count).Error
+	return count, err
+}
+
+// PurgeExpired deletes dead letters older than the retention period
+func (s *OutboxService) PurgeExpired(ctx context.Context) (int64, error) {
+	result := s.expired(ctx).Delete(&models.OutboxItem{})
+	return result.RowsAffected, result.Error
+}
+
+// UpdateMetrics publishes the dead letter depth per error class
+func (s *OutboxService) UpdateMetrics(ctx context.Context) {
+	if s.metrics == nil {
+		return
+	}
+
+	classes, err := s.DeadLetters(ctx)
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("Failed to count dead letters")
+		return
+	}
+
+	depth := make(map[string]int64, len(classes))
+	for _, class := range classes {
+		depth[class.ErrorClass] = class.Count
+	}
+	for _, class := range deliveryErrorClasses {
+		s.metrics.SetGauge("delivery_dead_letters", float64(depth[class]), class)
+	}
+}
+
+// DeliveryErrorClass classifies why a scheduled notification was not delivered
+func DeliveryErrorClass(err error) string {
+	switch {
+	case errors.Is(err, errWeatherUnavailable):
+		return DeliveryErrorWeather
+	case errors.Is(err, ErrChatUnwritable):
+		return DeliveryErrorPaused
+	case errors.Is(err, errDeliveryInterrupted):
+		return DeliveryErrorInterrupted
+	}
+
+	var tgErr *gotgbot.TelegramError
+	if !errors.As(err, &tgErr) {
+		return DeliveryErrorOther
+	}
+
+	description := strings.ToLower(tgErr.Description)
+	switch {
+	case tgErr.Code == 403:
+		return DeliveryErrorBlocked
+	case tgErr.Code == 429:
+		return DeliveryErrorRateLimited
+	case tgErr.Code == 400 && strings.Contains(description, "chat not found"):
+		return DeliveryErrorChatNotFound
+	case tgErr.Code == 400:
+		return DeliveryErrorBadRequest
+	}
+	return DeliveryErrorOther
+}
+
+// permanentDeliveryError reports whether retrying cannot succeed without a
+// change on the user's side or in the bot
+func permanentDeliveryError(errorClass string) bool {
+	switch errorClass {
+	case DeliveryErrorBlocked, DeliveryErrorChatNotFound, DeliveryErrorBadRequest:
+		return true
+	}
+	return false
+}
+
+func truncateError(err error) string {
+	message := err.Error()
+	if len(message) > maxOutboxErrorLength {
+		return strings.ToValidUTF8(message[:maxOutboxErrorLength], "")
+	}
+	return message
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func newTestOutbox(t *testing.T) (*OutboxService, *helpers.MockDB, time.Time) {
+	mockDB := helpers.NewMockDB(t)
+	t.Cleanup(func() { _ = mockDB.Close() })
+
+	service := NewOutboxService(mockDB.DB, nil, helpers.NewSilentTestLogger(), 0)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+	return service, mockDB, now
+}
+
+func TestDeliveryErrorClass(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"blocked by user", &gotgbot.TelegramError{Code: 403, Description: "Forbidden: bot was blocked by the user"}, DeliveryErrorBlocked},
+		{"deactivated user", &gotgbot.TelegramError{Code: 403, Description: "Forbidden: user is deactivated"}, DeliveryErrorBlocked},
+		{"chat not found", &gotgbot.TelegramError{Code: 400, Description: "Bad Request: chat not found"}, DeliveryErrorChatNotFound},
+		{"malformed message", &gotgbot.TelegramError{Code: 400, Description: "Bad Request: can't parse entities"}, DeliveryErrorBadRequest},
+		{"rate limited", &gotgbot.TelegramError{Code: 429, Description: "Too Many Requests: retry after 5"}, DeliveryErrorRateLimited},
+		{"wrapped telegram error", fmt.Errorf("failed to send weekly notification: %w", &gotgbot.TelegramError{Code: 403}), DeliveryErrorBlocked},
+		{"weather unavailable", fmt.Errorf("%w for user %d: %w", errWeatherUnavailable, 1, errors.New("timeout")), DeliveryErrorWeather},
+		{"network error", errors.New("connection reset"), DeliveryErrorOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DeliveryErrorClass(tt.err))
+		})
+	}
+}
+
+func TestOutboxService_Fail(t *testing.T) {
+	transient := &gotgbot.TelegramError{Code: 429, Description: "Too Many Requests: retry after 5"}
+
+	t.Run("first transient failure stays pending", func(t *testing.T) {
+		service, mockDB, _ := newTestOutbox(t)
+		item := &models.OutboxItem{SubscriptionID: uuid.New(), UserID: 123}
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "outbox_items"`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mockDB.Mock.ExpectCommit()
+
+		require.NoError(t, service.Fail(context.Background(), item, transient))
+
+		assert.Equal(t, models.OutboxPending, item.Status)
+		assert.Equal(t, 1, item.Attempts)
+		assert.Equal(t, DeliveryErrorRateLimited, item.ErrorClass)
+		assert.Nil(t, item.DeadAt)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("last retry moves the item to dead", func(t *testing.T) {
+		service, mockDB, now := newTestOutbox(t)
+		item := &models.OutboxItem{ID: uuid.New(), UserID: 123, Status: models.OutboxPending, Attempts: maxDeliveryAttempts - 1}
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "outbox_items" SET .*"status"=.* WHERE id = \$\d+`).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+
+		require.NoError(t, service.Fail(context.Background(), item, transient))
+
+		assert.Equal(t, models.OutboxDead, item.Status)
+		assert.Equal(t, maxDeliveryAttempts, item.Attempts)
+		require.NotNil(t, item.DeadAt)
+		assert.Equal(t, now, *item.DeadAt)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("permanent failure is dead right away", func(t *testing.T) {
+		service, mockDB, _ := newTestOutbox(t)
+		item := &models.OutboxItem{SubscriptionID: uuid.New(), UserID: 123}
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "outbox_items"`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mockDB.Mock.ExpectCommit()
+
+		err := &gotgbot.TelegramError{Code: 403, Description: "Forbidden: bot was blocked by the user"}
+		require.NoError(t, service.Fail(context.Background(), item, err))
+
+		assert.Equal(t, models.OutboxDead, item.Status)
+		assert.Equal(t, DeliveryErrorBlocked, item.ErrorClass)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestOutboxService_RetryClass(t *testing.T) {
+	service, mockDB, _ := newTestOutbox(t)
+	retry := `UPDATE "outbox_items" SET "attempts"=\$1,"dead_at"=\$2,"status"=\$3,"updated_at"=\$4 WHERE status = \$5 AND error_class = \$6`
+
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectExec(retry).
+		WithArgs(0, nil, models.OutboxPending, helpers.AnyTime{}, models.OutboxDead, DeliveryErrorBadRequest).
+		WillReturnResult(helpers.NewResult(0, 3))
+	mockDB.Mock.ExpectCommit()
+	// The items are pending now, so a second retry matches none of them
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectExec(retry).
+		WithArgs(0, nil, models.OutboxPending, helpers.AnyTime{}, models.OutboxDead, DeliveryErrorBadRequest).
+		WillReturnResult(helpers.NewResult(0, 0))
+	mockDB.Mock.ExpectCommit()
+
+	moved, err := service.RetryClass(context.Background(), DeliveryErrorBadRequest)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), moved)
+
+	moved, err = service.RetryClass(context.Background(), DeliveryErrorBadRequest)
+	require.NoError(t, err)
+	assert.Zero(t, moved)
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestOutboxService_CountExpired(t *testing.T) {
+	service, mockDB, now := newTestOutbox(t)
+
+	mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "outbox_items" WHERE status = \$1 AND dead_at < \$2`).
+		WithArgs(models.OutboxDead, now.Add(-defaultDeadLetterRetention)).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(4))
+
+	count, err := service.CountExpired(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestOutboxService_PurgeExpired(t *testing.T) {
+	service, mockDB, now := newTestOutbox(t)
+
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectExec(`DELETE FROM "outbox_items" WHERE status = \$1 AND dead_at < \$2`).
+		WithArgs(models.OutboxDead, now.Add(-defaultDeadLetterRetention)).
+		WillReturnResult(helpers.NewResult(0, 4))
+	mockDB.Mock.ExpectCommit()
+
+	purged, err := service.PurgeExpired(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), purged)
+	mockDB.ExpectationsWereMet(t)
+}
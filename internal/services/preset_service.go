@@ -0,0 +1,379 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/valpere/shopogoda/internal"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+var (
+	// ErrPresetNotFound is returned for tokens that match no preset
+	ErrPresetNotFound = errors.New("preset not found")
+	// ErrPresetRevoked is returned for presets that can no longer be applied
+	ErrPresetRevoked = errors.New("preset has been revoked")
+)
+
+// PresetStartPrefix prefixes the token in the /start parameter of preset deep links
+const PresetStartPrefix = "preset_"
+
+// PresetAlert is one alert condition of a preset, with the value in metric units
+type PresetAlert struct {
+	Type     models.AlertType `json:"type"`
+	Operator string           `json:"operator"`
+	Value    float64          `json:"value"`
+}
+
+// PresetSpec describes a preset before its location has been geocoded
+type PresetSpec struct {
+	Name             string
+	LocationQuery    string
+	SubscriptionType models.SubscriptionType
+	Frequency        models.Frequency
+	TimeOfDay        string
+	Alerts           []PresetAlert
+}
+
+// presetMetrics maps the metric names accepted in preset alert conditions
+var presetMetrics = map[string]models.AlertType{
+	"temp":        models.AlertTemperature,
+	"temperature": models.AlertTemperature,
+	"humidity":    models.AlertHumidity,
+	"pressure":    models.AlertPressure,
+	"wind":        models.AlertWindSpeed,
+	"uv":          models.AlertUVIndex,
+	"aqi":         models.AlertAirQuality,
+}
+
+// presetOperators lists the comparison symbols with the longest first, so
+// ">=" is not read as ">"
+var presetOperators = []struct{ symbol, operator string }{
+	{">=", "gte"},
+	{"<=", "lte"},
+	{">", "gt"},
+	{"<", "lt"},
+	{"=", "eq"},
+}
+
+// ParsePresetSpec parses the text of /preset create:
+//
+//	<name>; <location>[; daily|weekly HH:MM][; alerts][; <metric><op><value>...]
+//
+// Metrics are temp, humidity, pressure, wind, uv and aqi with values in
+// metric units, e.g. "wind>30; temp<0".
+func ParsePresetSpec(text string) (*PresetSpec, error) {
+	fields := strings.Split(text, ";")
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("preset needs a name and a location")
+	}
+
+	spec := &PresetSpec{
+		Name:          strings.TrimSpace(fields[0]),
+		LocationQuery: strings.TrimSpace(fields[1]),
+	}
+	if spec.Name == "" || spec.LocationQuery == "" {
+		return nil, fmt.Errorf("preset needs a name and a location")
+	}
+
+	for _, field := range fields[2:] {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if err := spec.parseClause(field); err != nil {
+			return nil, err
+		}
+	}
+	return spec, nil
+}
+
+func (spec *PresetSpec) parseClause(field string) error {
+	words := strings.Fields(strings.ToLower(field))
+	switch words[0] {
+	case "daily", "weekly":
+		if spec.SubscriptionType != 0 {
+			return fmt.Errorf("preset can hold only one subscription")
+		}
+		if len(words) != 2 || ValidateTimeOfDay(words[1]) != nil {
+			return fmt.Errorf("invalid schedule %q, expected e.g. \"daily 07:00\"", field)
+		}
+		spec.SubscriptionType, spec.Frequency = models.SubscriptionDaily, models.FrequencyDaily
+		if words[0] == "weekly" {
+			spec.SubscriptionType, spec.Frequency = models.SubscriptionWeekly, models.FrequencyWeekly
+		}
+		spec.TimeOfDay = words[1]
+		return nil
+	case "alerts":
+		if spec.SubscriptionType != 0 {
+			return fmt.Errorf("preset can hold only one subscription")
+		}
+		// Same settings as the alerts subscription created from the menu
+		spec.SubscriptionType, spec.Frequency, spec.TimeOfDay = models.SubscriptionAlerts, models.FrequencyDaily, "12:00"
+		return nil
+	}
+
+	condition := strings.Join(words, "")
+	for _, op := range presetOperators {
+		metric, value, found := strings.Cut(condition, op.symbol)
+		if !found {
+			continue
+		}
+		alertType, ok := presetMetrics[metric]
+		if !ok {
+			return fmt.Errorf("unknown alert metric %q", metric)
+		}
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid alert value %q", value)
+		}
+		spec.Alerts = append(spec.Alerts, PresetAlert{Type: alertType, Operator: op.operator, Value: threshold})
+		return nil
+	}
+	return fmt.Errorf("unrecognized preset setting %q", field)
+}
+
+// PresetService manages shareable presets and applies them to user accounts
+type PresetService struct {
+	db           *gorm.DB
+	localization *LocalizationService
+	logger       *zerolog.Logger
+	now          func() time.Time
+}
+
+func NewPresetService(db *gorm.DB, localization *LocalizationService, logger *zerolog.Logger) *PresetService {
+	return &PresetService{
+		db:           db,
+		localization: localization,
+		logger:       logger,
+		now:          time.Now,
+	}
+}
+
+// withTx returns a copy of the service that writes through the transaction db
+func (s *PresetService) withTx(db *gorm.DB) *PresetService {
+	scoped := *s
+	scoped.db = db
+	return &scoped
+}
+
+// Create stores a preset for the geocoded location under a new random token
+func (s *PresetService) Create(ctx context.Context, createdBy int64, spec *PresetSpec, location *weather.Location) (*models.Preset, error) {
+	token, err := newPresetToken()
+	if err != nil {
+		return nil, err
+	}
+	alerts, err := json.Marshal(spec.Alerts)
+	if err != nil {
+		return nil, err
+	}
+
+	preset := &models.Preset{
+		Token:            token,
+		Name:             spec.Name,
+		CreatedBy:        createdBy,
+		LocationName:     location.Name,
+		Country:          location.Country,
+		City:             location.City,
+		Latitude:         location.Latitude,
+		Longitude:        location.Longitude,
+		SubscriptionType: spec.SubscriptionType,
+		Frequency:        spec.Frequency,
+		TimeOfDay:        spec.TimeOfDay,
+		Alerts:           string(alerts),
+	}
+	if err := s.db.WithContext(ctx).Create(preset).Error; err != nil {
+		return nil, err
+	}
+
+	s.logger.Info().Str("token", token).Int64("created_by", createdBy).Msg("Preset created")
+	return preset, nil
+}
+
+// newPresetToken returns 16 hex characters; the token travels in deep links
+// and callback data, so it must not contain underscores
+func newPresetToken() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate preset token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// GetByToken returns the preset for a deep link token. Revoked presets are
+// returned together with ErrPresetRevoked.
+func (s *PresetService) GetByToken(ctx context.Context, token string) (*models.Preset, error) {
+	var preset models.Preset
+	err := s.db.WithContext(ctx).Where("token = ?", token).First(&preset).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPresetNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if preset.RevokedAt != nil {
+		return &preset, ErrPresetRevoked
+	}
+	return &preset, nil
+}
+
+// List returns the presets that can still be applied, newest first
+func (s *PresetService) List(ctx context.Context) ([]models.Preset, error) {
+	var presets []models.Preset
+	err := s.db.WithContext(ctx).
+		Where("revoked_at IS NULL").
+		Order("created_at DESC").
+		Find(&presets).Error
+	return presets, err
+}
+
+// Revoke stops a preset from being applied; settings users already applied stay
+func (s *PresetService) Revoke(ctx context.Context, token string) error {
+	result := s.db.WithContext(ctx).Model(&models.Preset{}).
+		Where("token = ? AND revoked_at IS NULL", token).
+		Update("revoked_at", s.now().UTC())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPresetNotFound
+	}
+	return nil
+}
+
+// apply writes the preset's settings to the user's account through the
+// transaction-bound services of tx. It reports false without changing
+// anything when the user has applied the preset before.
+func (s *PresetService) apply(ctx context.Context, tx *Services, preset *models.Preset, userID int64) (bool, error) {
+	alerts, err := presetAlerts(preset)
+	if err != nil {
+		return false, err
+	}
+
+	result := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&models.PresetApplication{
+		PresetID:  preset.ID,
+		UserID:    userID,
+		AppliedAt: s.now().UTC(),
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	if err := tx.User.SetUserLocation(ctx, userID, preset.LocationName, preset.Country, preset.City, preset.Latitude, preset.Longitude); err != nil {
+		return false, err
+	}
+
+	if preset.SubscriptionType != 0 {
+		subscription, err := tx.Subscription.EnsureSubscription(ctx, userID, preset.SubscriptionType, preset.Frequency, preset.TimeOfDay)
+		if err != nil {
+			return false, err
+		}
+		if subscription.Frequency != preset.Frequency || subscription.TimeOfDay != preset.TimeOfDay {
+			err := tx.Subscription.UpdateSubscription(ctx, userID, subscription.ID, map[string]interface{}{
+				"frequency":   preset.Frequency,
+				"time_of_day": preset.TimeOfDay,
+			})
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+
+	for _, alert := range alerts {
+		if _, err := tx.Alert.CreateAlert(ctx, userID, alert.Type, AlertCondition{Operator: alert.Operator, Value: alert.Value}); err != nil {
+			return false, err
+		}
+	}
+
+	err = s.db.WithContext(ctx).Model(&models.Preset{}).
+		Where("id = ?", preset.ID).
+		Update("usage_count", gorm.Expr("usage_count + 1")).Error
+	return err == nil, err
+}
+
+func presetAlerts(preset *models.Preset) ([]PresetAlert, error) {
+	var alerts []PresetAlert
+	if preset.Alerts == "" {
+		return nil, nil
+	}
+	if err := json.Unmarshal([]byte(preset.Alerts), &alerts); err != nil {
+		return nil, fmt.Errorf("invalid alerts in preset %s: %w", preset.Token, err)
+	}
+	return alerts, nil
+}
+
+// Preview describes what applying the preset sets up, in the user's language
+// and with alert thresholds in the user's units
+func (s *PresetService) Preview(ctx context.Context, preset *models.Preset, user *models.User) string {
+	language := internal.DefaultLanguage
+	units := weather.UnitsMetric
+	if user != nil {
+		if user.Language != "" {
+			language = user.Language
+		}
+		if user.Units != "" {
+			units = user.Units
+		}
+	}
+	t := func(key string, args ...interface{}) string {
+		return s.localization.T(ctx, language, key, args...)
+	}
+
+	lines := []string{
+		t("preset_preview_title", preset.Name),
+		"",
+		t("preset_preview_location", preset.LocationName),
+	}
+	switch preset.SubscriptionType {
+	case models.SubscriptionDaily:
+		lines = append(lines, t("preset_preview_daily", preset.TimeOfDay))
+	case models.SubscriptionWeekly:
+		lines = append(lines, t("preset_preview_weekly", preset.TimeOfDay))
+	case models.SubscriptionAlerts:
+		lines = append(lines, t("preset_preview_alert_notifications"))
+	}
+
+	alerts, err := presetAlerts(preset)
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("Failed to read preset alerts for preview")
+	}
+	for _, alert := range alerts {
+		lines = append(lines, t("preset_preview_alert",
+			t(alertTypeKey(alert.Type)),
+			operatorSymbol(alert.Operator),
+			FormatAlertValue(alert.Type, alert.Value, units)))
+	}
+
+	lines = append(lines, "", t("preset_preview_confirm"))
+	return strings.Join(lines, "\n")
+}
+
+// alertTypeKey returns the localization key of an alert type name, e.g.
+// alert_type_wind_speed
+func alertTypeKey(alertType models.AlertType) string {
+	return "alert_type_" + strings.ReplaceAll(strings.ToLower(alertType.String()), " ", "_")
+}
+
+func operatorSymbol(operator string) string {
+	for _, op := range presetOperators {
+		if op.operator == operator {
+			return op.symbol
+		}
+	}
+	return operator
+}
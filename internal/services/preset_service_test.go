@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/locales"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/metrics"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestParsePresetSpec(t *testing.T) {
+	t.Run("full preset", func(t *testing.T) {
+		spec, err := ParsePresetSpec("Hiking club; Lviv, UA; daily 07:00; wind>30; temp <= -5")
+
+		require.NoError(t, err)
+		assert.Equal(t, "Hiking club", spec.Name)
+		assert.Equal(t, "Lviv, UA", spec.LocationQuery)
+		assert.Equal(t, models.SubscriptionDaily, spec.SubscriptionType)
+		assert.Equal(t, models.FrequencyDaily, spec.Frequency)
+		assert.Equal(t, "07:00", spec.TimeOfDay)
+		assert.Equal(t, []PresetAlert{
+			{Type: models.AlertWindSpeed, Operator: "gt", Value: 30},
+			{Type: models.AlertTemperature, Operator: "lte", Value: -5},
+		}, spec.Alerts)
+	})
+
+	t.Run("location only", func(t *testing.T) {
+		spec, err := ParsePresetSpec("Office; Kyiv")
+
+		require.NoError(t, err)
+		assert.Zero(t, spec.SubscriptionType)
+		assert.Empty(t, spec.Alerts)
+	})
+
+	invalid := []struct {
+		name string
+		text string
+	}{
+		{"missing location", "Hiking club"},
+		{"empty name", " ; Lviv"},
+		{"bad schedule time", "Club; Lviv; daily 7am"},
+		{"two subscriptions", "Club; Lviv; daily 07:00; weekly 08:00"},
+		{"unknown metric", "Club; Lviv; snowfall>10"},
+		{"bad value", "Club; Lviv; wind>fast"},
+		{"unknown setting", "Club; Lviv; saturday"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePresetSpec(tt.text)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func testPreset() *models.Preset {
+	return &models.Preset{
+		ID:               uuid.MustParse("6f1c7f5e-9a51-4d39-9a4b-3cf2f1a1b001"),
+		Token:            "0123456789abcdef",
+		Name:             "Hiking club",
+		LocationName:     "Lviv",
+		Country:          "UA",
+		City:             "Lviv",
+		Latitude:         49.84,
+		Longitude:        24.03,
+		SubscriptionType: models.SubscriptionWeekly,
+		Frequency:        models.FrequencyWeekly,
+		TimeOfDay:        "08:00",
+		Alerts:           `[{"type":4,"operator":"gt","value":30},{"type":1,"operator":"lt","value":0}]`,
+	}
+}
+
+func newTestPresetService(t *testing.T) *PresetService {
+	logger := helpers.NewSilentTestLogger()
+	localization := NewLocalizationService(logger)
+	require.NoError(t, localization.LoadTranslations(locales.LocalesFS))
+	return NewPresetService(nil, localization, logger)
+}
+
+func TestPresetService_Preview(t *testing.T) {
+	service := newTestPresetService(t)
+
+	t.Run("metric user", func(t *testing.T) {
+		preview := service.Preview(context.Background(), testPreset(), &models.User{Language: "en-US", Units: "metric"})
+
+		assert.Equal(t, "📦 *Hiking club*\nApplying this preset sets up:\n\n"+
+			"📍 Location: Lviv\n"+
+			"📆 Weekly forecast on Mondays at 08:00\n"+
+			"⚠️ Alert: Wind speed > 30.0 km/h\n"+
+			"⚠️ Alert: Temperature < 0.0°C\n\n"+
+			"Times are in your timezone. Your language and units stay as they are.", preview)
+	})
+
+	t.Run("thresholds follow the user's units and language", func(t *testing.T) {
+		preview := service.Preview(context.Background(), testPreset(), &models.User{Language: "uk-UA", Units: "imperial"})
+
+		assert.Contains(t, preview, "Швидкість вітру > 18.6 mph")
+		assert.Contains(t, preview, "Температура < 32.0°F")
+		assert.Contains(t, preview, "щопонеділка о 08:00")
+	})
+
+	t.Run("unknown user falls back to defaults", func(t *testing.T) {
+		preview := service.Preview(context.Background(), testPreset(), nil)
+
+		assert.Contains(t, preview, "Wind speed > 30.0 km/h")
+	})
+}
+
+func TestServices_ApplyPreset(t *testing.T) {
+	const userID = int64(123)
+	preset := testPreset()
+	presetColumns := []string{"id", "token", "name", "location_name", "country", "city", "latitude", "longitude", "subscription_type", "frequency", "time_of_day", "alerts", "revoked_at"}
+	presetRow := func(mockDB *helpers.MockDB, revokedAt *time.Time) {
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "presets" WHERE token = \$1`).
+			WithArgs(preset.Token, 1).
+			WillReturnRows(mockDB.Mock.NewRows(presetColumns).AddRow(
+				preset.ID, preset.Token, preset.Name, preset.LocationName, preset.Country, preset.City,
+				preset.Latitude, preset.Longitude, preset.SubscriptionType, preset.Frequency, preset.TimeOfDay,
+				preset.Alerts, revokedAt))
+	}
+
+	newServices := func(t *testing.T) (*Services, *helpers.MockDB, *redisRecorder) {
+		mockDB := helpers.NewMockDB(t)
+		t.Cleanup(func() { _ = mockDB.Close() })
+		redisClient, recorder := newRecordingRedis()
+		logger := helpers.NewSilentTestLogger()
+
+		svcs := &Services{
+			User:         NewUserService(mockDB.DB, redisClient, metrics.New(), logger, time.Now()),
+			Alert:        NewAlertService(mockDB.DB, redisClient),
+			Subscription: NewSubscriptionService(mockDB.DB, redisClient),
+			Presets:      NewPresetService(mockDB.DB, nil, logger),
+			Transfers:    NewAccountTransferService(mockDB.DB, logger),
+			db:           mockDB.DB,
+		}
+		return svcs, mockDB, recorder
+	}
+
+	t.Run("applies every setting once", func(t *testing.T) {
+		svcs, mockDB, recorder := newServices(t)
+
+		presetRow(mockDB, nil)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`INSERT INTO "preset_applications" .* ON CONFLICT DO NOTHING`).
+			WithArgs(preset.ID, userID, helpers.AnyTime{}).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectExec(`UPDATE "users" SET`).WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "subscriptions" WHERE user_id = \$1 AND subscription_type = \$2`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}))
+		mockDB.Mock.ExpectQuery(`INSERT INTO "subscriptions"`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mockDB.Mock.ExpectQuery(`SELECT "id" FROM "users" WHERE id = \$1 .*FOR UPDATE`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(userID))
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "alert_configs"`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(0))
+		mockDB.Mock.ExpectQuery(`INSERT INTO "alert_configs"`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mockDB.Mock.ExpectQuery(`SELECT "id" FROM "users" WHERE id = \$1 .*FOR UPDATE`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(userID))
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "alert_configs"`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(1))
+		mockDB.Mock.ExpectQuery(`INSERT INTO "alert_configs"`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mockDB.Mock.ExpectExec(`UPDATE "presets" SET "usage_count"=usage_count \+ 1`).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+
+		applied, err := svcs.ApplyPreset(context.Background(), preset.Token, userID)
+
+		require.NoError(t, err)
+		assert.True(t, applied)
+		assert.Equal(t, []string{"del user:123"}, recorder.commands)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("applying again changes nothing", func(t *testing.T) {
+		svcs, mockDB, recorder := newServices(t)
+
+		presetRow(mockDB, nil)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`INSERT INTO "preset_applications" .* ON CONFLICT DO NOTHING`).
+			WillReturnResult(helpers.NewResult(0, 0))
+		mockDB.Mock.ExpectCommit()
+
+		applied, err := svcs.ApplyPreset(context.Background(), preset.Token, userID)
+
+		require.NoError(t, err)
+		assert.False(t, applied)
+		assert.Empty(t, recorder.commands)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("revoked token", func(t *testing.T) {
+		svcs, mockDB, _ := newServices(t)
+		revokedAt := time.Now().UTC()
+
+		presetRow(mockDB, &revokedAt)
+
+		applied, err := svcs.ApplyPreset(context.Background(), preset.Token, userID)
+
+		assert.ErrorIs(t, err, ErrPresetRevoked)
+		assert.False(t, applied)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		svcs, mockDB, _ := newServices(t)
+
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "presets" WHERE token = \$1`).
+			WillReturnRows(mockDB.Mock.NewRows(presetColumns))
+
+		_, err := svcs.ApplyPreset(context.Background(), "ffffffffffffffff", userID)
+
+		assert.ErrorIs(t, err, ErrPresetNotFound)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestPresetService_Revoke(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer mockDB.Close()
+	service := NewPresetService(mockDB.DB, nil, helpers.NewSilentTestLogger())
+
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectExec(`UPDATE "presets" SET "revoked_at"=\$1,"updated_at"=\$2 WHERE token = \$3 AND revoked_at IS NULL`).
+		WithArgs(helpers.AnyTime{}, helpers.AnyTime{}, "0123456789abcdef").
+		WillReturnResult(helpers.NewResult(0, 0))
+	mockDB.Mock.ExpectCommit()
+
+	err := service.Revoke(context.Background(), "0123456789abcdef")
+
+	assert.ErrorIs(t, err, ErrPresetNotFound, "revoking twice reports that no active preset matched")
+	mockDB.ExpectationsWereMet(t)
+}
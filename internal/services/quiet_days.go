@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/valpere/shopogoda/internal/models"
+)
+
+// dateLayout is how quiet days are written in commands and listed
+const dateLayout = "2006-01-02"
+
+// maxQuietRangeDays bounds one range of quiet days
+const maxQuietRangeDays = 366
+
+var (
+	ErrInvalidDateRange  = errors.New("invalid date range")
+	ErrDateRangeTooLong  = errors.New("date range too long")
+	ErrDateRangeReversed = errors.New("date range ends before it starts")
+)
+
+// DateRange is a range of calendar dates, both ends inclusive. Dates are
+// midnight UTC of the calendar day.
+type DateRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// CalendarDate returns the calendar date of t, in t's location, as midnight UTC
+func CalendarDate(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// UserToday returns today's calendar date in the user's timezone
+func UserToday(user *models.User) time.Time {
+	return CalendarDate(time.Now().In(userLocation(user)))
+}
+
+// ParseDateRange parses "2025-08-10..2025-08-24" or a single "2025-08-10"
+func ParseDateRange(s string) (DateRange, error) {
+	startText, endText, isRange := strings.Cut(strings.TrimSpace(s), "..")
+	if !isRange {
+		endText = startText
+	}
+
+	start, err := time.Parse(dateLayout, strings.TrimSpace(startText))
+	if err != nil {
+		return DateRange{}, fmt.Errorf("%w: %q", ErrInvalidDateRange, s)
+	}
+	end, err := time.Parse(dateLayout, strings.TrimSpace(endText))
+	if err != nil {
+		return DateRange{}, fmt.Errorf("%w: %q", ErrInvalidDateRange, s)
+	}
+
+	r := DateRange{Start: start, End: end}
+	switch {
+	case end.Before(start):
+		return DateRange{}, ErrDateRangeReversed
+	case r.Days() > maxQuietRangeDays:
+		return DateRange{}, ErrDateRangeTooLong
+	}
+	return r, nil
+}
+
+// Days returns the number of days in the range
+func (r DateRange) Days() int {
+	return int(r.End.Sub(r.Start).Hours()/24) + 1
+}
+
+// Overlaps reports whether two ranges share at least one day
+func (r DateRange) Overlaps(o DateRange) bool {
+	return !r.End.Before(o.Start) && !o.End.Before(r.Start)
+}
+
+// Subtract returns what is left of the range after removing o: nothing, one
+// range, or two when o is strictly inside
+func (r DateRange) Subtract(o DateRange) []DateRange {
+	if !r.Overlaps(o) {
+		return []DateRange{r}
+	}
+
+	var rest []DateRange
+	if r.Start.Before(o.Start) {
+		rest = append(rest, DateRange{Start: r.Start, End: o.Start.AddDate(0, 0, -1)})
+	}
+	if r.End.After(o.End) {
+		rest = append(rest, DateRange{Start: o.End.AddDate(0, 0, 1), End: r.End})
+	}
+	return rest
+}
+
+func (r DateRange) String() string {
+	if r.Start.Equal(r.End) {
+		return r.Start.Format(dateLayout)
+	}
+	return r.Start.Format(dateLayout) + ".." + r.End.Format(dateLayout)
+}
+
+func quietPeriodRange(period *models.QuietPeriod) DateRange {
+	return DateRange{Start: CalendarDate(period.StartDate), End: CalendarDate(period.EndDate)}
+}
+
+// QuietDayService manages the days, such as vacations, on which a user's
+// routine alerts are recorded without being sent
+type QuietDayService struct {
+	db *gorm.DB
+}
+
+func NewQuietDayService(db *gorm.DB) *QuietDayService {
+	return &QuietDayService{db: db}
+}
+
+// Add makes a range of days quiet. Ranges it overlaps or adjoins are merged
+// into it; the merged range is returned.
+func (s *QuietDayService) Add(ctx context.Context, userID int64, r DateRange) (DateRange, error) {
+	merged := r
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var periods []models.QuietPeriod
+		if err := tx.Where("user_id = ? AND start_date <= ? AND end_date >= ?",
+			userID, r.End.AddDate(0, 0, 1), r.Start.AddDate(0, 0, -1)).
+			Find(&periods).Error; err != nil {
+			return err
+		}
+
+		for i := range periods {
+			existing := quietPeriodRange(&periods[i])
+			if existing.Start.Before(merged.Start) {
+				merged.Start = existing.Start
+			}
+			if existing.End.After(merged.End) {
+				merged.End = existing.End
+			}
+			if err := tx.Delete(&periods[i]).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&models.QuietPeriod{UserID: userID, StartDate: merged.Start, EndDate: merged.End}).Error
+	})
+	if err != nil {
+		return DateRange{}, fmt.Errorf("failed to add quiet days: %w", err)
+	}
+	return merged, nil
+}
+
+// Remove makes a range of days no longer quiet, shortening or splitting the
+// quiet ranges it overlaps. It reports whether any quiet day was removed.
+func (s *QuietDayService) Remove(ctx context.Context, userID int64, r DateRange) (bool, error) {
+	removed := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var periods []models.QuietPeriod
+		if err := tx.Where("user_id = ? AND start_date <= ? AND end_date >= ?", userID, r.End, r.Start).
+			Find(&periods).Error; err != nil {
+			return err
+		}
+
+		for i := range periods {
+			if err := tx.Delete(&periods[i]).Error; err != nil {
+				return err
+			}
+			for _, rest := range quietPeriodRange(&periods[i]).Subtract(r) {
+				if err := tx.Create(&models.QuietPeriod{UserID: userID, StartDate: rest.Start, EndDate: rest.End}).Error; err != nil {
+					return err
+				}
+			}
+			removed = true
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to remove quiet days: %w", err)
+	}
+	return removed, nil
+}
+
+// List returns the user's quiet ranges that have not ended before a date,
+// earliest first
+func (s *QuietDayService) List(ctx context.Context, userID int64, from time.Time) ([]DateRange, error) {
+	var periods []models.QuietPeriod
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ? AND end_date >= ?", userID, CalendarDate(from)).
+		Order("start_date").
+		Find(&periods).Error; err != nil {
+		return nil, err
+	}
+
+	ranges := make([]DateRange, len(periods))
+	for i := range periods {
+		ranges[i] = quietPeriodRange(&periods[i])
+	}
+	return ranges, nil
+}
+
+// IsQuietDay reports whether a calendar date is one of the user's quiet days
+func (s *QuietDayService) IsQuietDay(ctx context.Context, userID int64, date time.Time) (bool, error) {
+	date = CalendarDate(date)
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.QuietPeriod{}).
+		Where("user_id = ? AND start_date <= ? AND end_date >= ?", userID, date, date).
+		Count(&count).Error
+	return count > 0, err
+}
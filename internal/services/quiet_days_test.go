@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestParseDateRange(t *testing.T) {
+	t.Run("range", func(t *testing.T) {
+		r, err := ParseDateRange("2025-08-10..2025-08-24")
+		require.NoError(t, err)
+		assert.Equal(t, DateRange{Start: date(2025, time.August, 10), End: date(2025, time.August, 24)}, r)
+		assert.Equal(t, 15, r.Days())
+		assert.Equal(t, "2025-08-10..2025-08-24", r.String())
+	})
+
+	t.Run("single day", func(t *testing.T) {
+		r, err := ParseDateRange(" 2025-08-10 ")
+		require.NoError(t, err)
+		assert.Equal(t, 1, r.Days())
+		assert.Equal(t, "2025-08-10", r.String())
+	})
+
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{"tomorrow", ErrInvalidDateRange},
+		{"2025-08-10..", ErrInvalidDateRange},
+		{"2025-02-30", ErrInvalidDateRange},
+		{"2025-08-24..2025-08-10", ErrDateRangeReversed},
+		{"2025-01-01..2026-01-02", ErrDateRangeTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := ParseDateRange(tt.input)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestDateRange_Overlaps(t *testing.T) {
+	august := DateRange{Start: date(2025, time.August, 10), End: date(2025, time.August, 24)}
+
+	tests := []struct {
+		name  string
+		other DateRange
+		want  bool
+	}{
+		{"inside", DateRange{Start: date(2025, time.August, 15), End: date(2025, time.August, 16)}, true},
+		{"around", DateRange{Start: date(2025, time.August, 1), End: date(2025, time.August, 31)}, true},
+		{"sharing the first day", DateRange{Start: date(2025, time.August, 1), End: date(2025, time.August, 10)}, true},
+		{"sharing the last day", DateRange{Start: date(2025, time.August, 24), End: date(2025, time.August, 30)}, true},
+		{"ending the day before", DateRange{Start: date(2025, time.August, 1), End: date(2025, time.August, 9)}, false},
+		{"starting the day after", DateRange{Start: date(2025, time.August, 25), End: date(2025, time.August, 30)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, august.Overlaps(tt.other))
+			assert.Equal(t, tt.want, tt.other.Overlaps(august))
+		})
+	}
+}
+
+func TestDateRange_Subtract(t *testing.T) {
+	august := DateRange{Start: date(2025, time.August, 10), End: date(2025, time.August, 24)}
+	days := func(from, to int) DateRange {
+		return DateRange{Start: date(2025, time.August, from), End: date(2025, time.August, to)}
+	}
+
+	assert.Equal(t, []DateRange{august}, august.Subtract(days(1, 5)), "no overlap")
+	assert.Empty(t, august.Subtract(days(1, 31)), "removed entirely")
+	assert.Equal(t, []DateRange{days(16, 24)}, august.Subtract(days(1, 15)), "start removed")
+	assert.Equal(t, []DateRange{days(10, 19)}, august.Subtract(days(20, 31)), "end removed")
+	assert.Equal(t, []DateRange{days(10, 14), days(16, 24)}, august.Subtract(days(15, 15)), "split")
+}
+
+func TestQuietDayService_Add(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	service := NewQuietDayService(mockDB.DB)
+
+	existingID := uuid.New()
+
+	// 2025-08-20..2025-08-31 overlaps the new range and is merged into it
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "quiet_periods" WHERE user_id = \$1 AND start_date <= \$2 AND end_date >= \$3`).
+		WithArgs(int64(42), date(2025, time.August, 25), date(2025, time.August, 9)).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id", "user_id", "start_date", "end_date"}).
+			AddRow(existingID, 42, date(2025, time.August, 20), date(2025, time.August, 31)))
+	mockDB.Mock.ExpectExec(`DELETE FROM "quiet_periods" WHERE "quiet_periods"."id" = \$1`).
+		WithArgs(existingID).
+		WillReturnResult(helpers.NewResult(0, 1))
+	mockDB.Mock.ExpectQuery(`INSERT INTO "quiet_periods"`).
+		WithArgs(int64(42), date(2025, time.August, 10), date(2025, time.August, 31), helpers.AnyTime{}).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mockDB.Mock.ExpectCommit()
+
+	merged, err := service.Add(context.Background(), 42, DateRange{Start: date(2025, time.August, 10), End: date(2025, time.August, 24)})
+
+	require.NoError(t, err)
+	assert.Equal(t, "2025-08-10..2025-08-31", merged.String())
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestQuietDayService_Remove(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	service := NewQuietDayService(mockDB.DB)
+
+	existingID := uuid.New()
+
+	// Removing one day splits the range around it
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "quiet_periods"`).
+		WithArgs(int64(42), date(2025, time.August, 15), date(2025, time.August, 15)).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id", "user_id", "start_date", "end_date"}).
+			AddRow(existingID, 42, date(2025, time.August, 10), date(2025, time.August, 24)))
+	mockDB.Mock.ExpectExec(`DELETE FROM "quiet_periods"`).
+		WithArgs(existingID).
+		WillReturnResult(helpers.NewResult(0, 1))
+	mockDB.Mock.ExpectQuery(`INSERT INTO "quiet_periods"`).
+		WithArgs(int64(42), date(2025, time.August, 10), date(2025, time.August, 14), helpers.AnyTime{}).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mockDB.Mock.ExpectQuery(`INSERT INTO "quiet_periods"`).
+		WithArgs(int64(42), date(2025, time.August, 16), date(2025, time.August, 24), helpers.AnyTime{}).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mockDB.Mock.ExpectCommit()
+
+	removed, err := service.Remove(context.Background(), 42, DateRange{Start: date(2025, time.August, 15), End: date(2025, time.August, 15)})
+
+	require.NoError(t, err)
+	assert.True(t, removed)
+	mockDB.ExpectationsWereMet(t)
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+// maxRecommendations is how many tips a weather message carries at most
+const maxRecommendations = 2
+
+// Recommendation is a clothing or activity tip for the current weather
+type Recommendation struct {
+	Key      string  // Translation key of the tip
+	Severity int     // Higher is more pressing
+	Value    float64 // The reading the tip quotes; see Quote
+	Quote    recommendationQuote
+}
+
+// recommendationQuote is which reading a tip quotes, formatted in the
+// reader's units
+type recommendationQuote int
+
+const (
+	quoteNone recommendationQuote = iota
+	quoteFeelsLike
+	quoteAQI
+	quoteUV
+	quotePrecipitation
+)
+
+// recommendationGroup keeps tips about the same thing from both showing, e.g.
+// "dress warmly" next to "take a jacket"
+type recommendationGroup string
+
+const (
+	groupAir      recommendationGroup = "air"
+	groupClothing recommendationGroup = "clothing"
+	groupRain     recommendationGroup = "rain"
+	groupSun      recommendationGroup = "sun"
+	groupActivity recommendationGroup = "activity"
+)
+
+// conditionRange matches a reading in [Min, Max); either bound may be
+// infinite
+type conditionRange struct {
+	Reading  func(*WeatherData) float64
+	Min, Max float64
+}
+
+func (c conditionRange) matches(w *WeatherData) bool {
+	value := c.Reading(w)
+	return value >= c.Min && value < c.Max
+}
+
+func feelsLike(w *WeatherData) float64     { return w.FeelsLike }
+func windSpeed(w *WeatherData) float64     { return w.WindSpeed }
+func precipitation(w *WeatherData) float64 { return w.Precipitation }
+func uvIndex(w *WeatherData) float64       { return w.UVIndex }
+func airQuality(w *WeatherData) float64    { return float64(w.AQI) }
+
+// recommendationRule gives its tip when all of its conditions match
+type recommendationRule struct {
+	Key        string
+	Group      recommendationGroup
+	Severity   int
+	Conditions []conditionRange
+	Quote      recommendationQuote
+}
+
+var (
+	below = math.Inf(-1)
+	above = math.Inf(1)
+)
+
+// recommendationRules is the table Recommend applies. Temperatures are the
+// feels-like °C, wind in km/h, precipitation in mm over the last hour and
+// air quality the US AQI, which is 0 when unknown.
+var recommendationRules = []recommendationRule{
+	{Key: "recommendation_air_stay_inside", Group: groupAir, Severity: 100, Quote: quoteAQI,
+		Conditions: []conditionRange{{airQuality, 200, above}}},
+	{Key: "recommendation_air_no_exercise", Group: groupAir, Severity: 90, Quote: quoteAQI,
+		Conditions: []conditionRange{{airQuality, 150, 200}}},
+	{Key: "recommendation_extreme_cold", Group: groupClothing, Severity: 85, Quote: quoteFeelsLike,
+		Conditions: []conditionRange{{feelsLike, below, -15}}},
+	{Key: "recommendation_extreme_heat", Group: groupClothing, Severity: 85, Quote: quoteFeelsLike,
+		Conditions: []conditionRange{{feelsLike, 35, above}}},
+	{Key: "recommendation_heavy_rain", Group: groupRain, Severity: 70, Quote: quotePrecipitation,
+		Conditions: []conditionRange{{precipitation, 4, above}}},
+	{Key: "recommendation_jacket_wind", Group: groupClothing, Severity: 60, Quote: quoteFeelsLike,
+		Conditions: []conditionRange{{feelsLike, -15, 10}, {windSpeed, 30, above}}},
+	{Key: "recommendation_sunscreen", Group: groupSun, Severity: 55, Quote: quoteUV,
+		Conditions: []conditionRange{{uvIndex, 6, above}}},
+	{Key: "recommendation_umbrella", Group: groupRain, Severity: 50,
+		Conditions: []conditionRange{{precipitation, 0.1, 4}}},
+	{Key: "recommendation_dress_warmly", Group: groupClothing, Severity: 40, Quote: quoteFeelsLike,
+		Conditions: []conditionRange{{feelsLike, -15, 5}}},
+	{Key: "recommendation_jacket", Group: groupClothing, Severity: 30, Quote: quoteFeelsLike,
+		Conditions: []conditionRange{{feelsLike, 5, 12}}},
+	{Key: "recommendation_outdoors", Group: groupActivity, Severity: 10,
+		Conditions: []conditionRange{{feelsLike, 18, 28}, {precipitation, below, 0.1}, {airQuality, below, 100}}},
+}
+
+// Recommend picks the tips for the weather: the most severe matching rule of
+// each group, at most maxRecommendations of them, most severe first
+func Recommend(w *WeatherData) []Recommendation {
+	if w == nil {
+		return nil
+	}
+
+	var tips []Recommendation
+	seen := make(map[recommendationGroup]bool)
+	for _, rule := range recommendationRules {
+		if seen[rule.Group] || !rule.matches(w) {
+			continue
+		}
+		seen[rule.Group] = true
+		tips = append(tips, Recommendation{Key: rule.Key, Severity: rule.Severity, Value: rule.Quote.reading(w), Quote: rule.Quote})
+	}
+
+	// The table is mostly in severity order already; the sort makes it a rule
+	sort.SliceStable(tips, func(i, j int) bool { return tips[i].Severity > tips[j].Severity })
+	if len(tips) > maxRecommendations {
+		tips = tips[:maxRecommendations]
+	}
+	return tips
+}
+
+func (r recommendationRule) matches(w *WeatherData) bool {
+	for _, condition := range r.Conditions {
+		if !condition.matches(w) {
+			return false
+		}
+	}
+	return true
+}
+
+func (q recommendationQuote) reading(w *WeatherData) float64 {
+	switch q {
+	case quoteFeelsLike:
+		return w.FeelsLike
+	case quoteAQI:
+		return float64(w.AQI)
+	case quoteUV:
+		return w.UVIndex
+	case quotePrecipitation:
+		return w.Precipitation
+	}
+	return 0
+}
+
+// format renders the quoted reading in the unit system, empty for tips that
+// quote none
+func (q recommendationQuote) format(value float64, units string) string {
+	switch q {
+	case quoteFeelsLike:
+		return weather.FormatTemp(value, units)
+	case quoteAQI:
+		return weather.FormatAQI(value)
+	case quoteUV:
+		return weather.FormatUV(value)
+	case quotePrecipitation:
+		return weather.FormatPrecipitation(value, units)
+	}
+	return ""
+}
+
+// RecommendationTips renders the tips for the weather in the language, with
+// the readings they quote in the unit system
+func RecommendationTips(localization *LocalizationService, language, units string, w *WeatherData) []string {
+	recommendations := Recommend(w)
+	tips := make([]string, 0, len(recommendations))
+	for _, tip := range recommendations {
+		if tip.Quote == quoteNone {
+			tips = append(tips, localization.T(context.Background(), language, tip.Key))
+			continue
+		}
+		tips = append(tips, localization.T(context.Background(), language, tip.Key, tip.Quote.format(tip.Value, units)))
+	}
+	return tips
+}
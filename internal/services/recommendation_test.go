@@ -0,0 +1,77 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/locales"
+	"github.com/valpere/shopogoda/pkg/weather"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestRecommend(t *testing.T) {
+	keys := func(w *WeatherData) []string {
+		var keys []string
+		for _, tip := range Recommend(w) {
+			keys = append(keys, tip.Key)
+		}
+		return keys
+	}
+
+	tests := []struct {
+		name    string
+		weather WeatherData
+		want    []string
+	}{
+		{"mild and calm has no tip", WeatherData{FeelsLike: 15, UVIndex: 2}, nil},
+		{"cold with strong wind takes a jacket", WeatherData{FeelsLike: 4, WindSpeed: 40}, []string{"recommendation_jacket_wind"}},
+		{"cold and calm dresses warmly", WeatherData{FeelsLike: 0, WindSpeed: 10}, []string{"recommendation_dress_warmly"}},
+		{"cool needs a light jacket", WeatherData{FeelsLike: 8}, []string{"recommendation_jacket"}},
+		{"pleasant and dry suggests going out", WeatherData{FeelsLike: 22, AQI: 40}, []string{"recommendation_outdoors"}},
+		{"polluted air avoids exercise", WeatherData{FeelsLike: 15, AQI: 170}, []string{"recommendation_air_no_exercise"}},
+		{"hazardous air keeps people inside", WeatherData{FeelsLike: 15, AQI: 250}, []string{"recommendation_air_stay_inside"}},
+		{"heavy rain beats the umbrella", WeatherData{FeelsLike: 15, Precipitation: 6}, []string{"recommendation_heavy_rain"}},
+		{
+			"conflicting conditions keep the two most severe",
+			WeatherData{FeelsLike: 3, WindSpeed: 45, Precipitation: 1, AQI: 160},
+			[]string{"recommendation_air_no_exercise", "recommendation_jacket_wind"},
+		},
+		{
+			"one tip per group",
+			WeatherData{FeelsLike: 38, UVIndex: 9},
+			[]string{"recommendation_extreme_heat", "recommendation_sunscreen"},
+		},
+		{
+			"bad air rules out the outdoors tip",
+			WeatherData{FeelsLike: 22, AQI: 120},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, keys(&tt.weather))
+		})
+	}
+
+	assert.Nil(t, Recommend(nil))
+}
+
+func TestRecommendationTips(t *testing.T) {
+	localization := NewLocalizationService(helpers.NewSilentTestLogger())
+	require.NoError(t, localization.LoadTranslations(locales.LocalesFS))
+
+	w := &WeatherData{FeelsLike: 4, WindSpeed: 40, AQI: 170}
+	assert.Equal(t, []string{
+		"😷 AQI 170 — avoid outdoor exercise",
+		"🧥 Take a jacket, feels like 4.0°C with strong wind",
+	}, RecommendationTips(localization, "en-US", weather.UnitsMetric, w))
+	assert.Equal(t, []string{
+		"😷 AQI 170 — avoid outdoor exercise",
+		"🧥 Take a jacket, feels like 39.2°F with strong wind",
+	}, RecommendationTips(localization, "en-US", weather.UnitsImperial, w))
+
+	assert.Equal(t, []string{"☂️ Візьміть парасольку"},
+		RecommendationTips(localization, "uk-UA", weather.UnitsMetric, &WeatherData{FeelsLike: 15, Precipitation: 1}))
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+
+	"github.com/valpere/shopogoda/internal/version"
+)
+
+const (
+	// latestReleaseURL is the GitHub API endpoint of the bot's newest release
+	latestReleaseURL = "https://api.github.com/repos/valpere/shopogoda/releases/latest"
+
+	// latestReleaseKey caches the newest release; GitHub allows 60
+	// unauthenticated API requests an hour per address
+	latestReleaseKey = "release:latest"
+	latestReleaseTTL = time.Hour
+
+	releaseCheckTimeout = 10 * time.Second
+)
+
+// Release is a published release of the bot
+type Release struct {
+	Version string `json:"tag_name"`
+	URL     string `json:"html_url"`
+}
+
+// UpdateStatus compares the running version with the newest release
+type UpdateStatus struct {
+	Current  string
+	Latest   *Release
+	UpToDate bool // The running version is the newest release or later
+}
+
+// ReleaseService checks the bot's GitHub releases for newer versions
+type ReleaseService struct {
+	redis   *redis.Client
+	client  *http.Client
+	logger  *zerolog.Logger
+	url     string
+	current string
+}
+
+func NewReleaseService(redis *redis.Client, logger *zerolog.Logger) *ReleaseService {
+	return &ReleaseService{
+		redis:   redis,
+		client:  &http.Client{Timeout: releaseCheckTimeout},
+		logger:  logger,
+		url:     latestReleaseURL,
+		current: version.Version,
+	}
+}
+
+// CheckForUpdate compares the running version with the newest release
+func (s *ReleaseService) CheckForUpdate(ctx context.Context) (*UpdateStatus, error) {
+	latest, err := s.LatestRelease(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &UpdateStatus{
+		Current:  s.current,
+		Latest:   latest,
+		UpToDate: !version.Newer(latest.Version, s.current),
+	}, nil
+}
+
+// LatestRelease returns the newest release, cached for latestReleaseTTL
+func (s *ReleaseService) LatestRelease(ctx context.Context) (*Release, error) {
+	if cached, err := s.redis.Get(ctx, latestReleaseKey).Result(); err == nil {
+		var release Release
+		if err := json.Unmarshal([]byte(cached), &release); err == nil {
+			return &release, nil
+		}
+	} else if !errors.Is(err, redis.Nil) {
+		s.logger.Warn().Err(err).Msg("Failed to read cached release")
+	}
+
+	release, err := s.fetchLatestRelease(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := json.Marshal(release); err == nil {
+		if err := s.redis.Set(ctx, latestReleaseKey, data, latestReleaseTTL).Err(); err != nil {
+			s.logger.Warn().Err(err).Msg("Failed to cache release")
+		}
+	}
+	return release, nil
+}
+
+func (s *ReleaseService) fetchLatestRelease(ctx context.Context) (*Release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create release request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "ShoPogoda/"+s.current)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest release: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get latest release: status %d", resp.StatusCode)
+	}
+
+	var release Release
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, fmt.Errorf("failed to decode latest release: %w", err)
+	}
+	if release.Version == "" {
+		return nil, errors.New("latest release has no version")
+	}
+	return &release, nil
+}
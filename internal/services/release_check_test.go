@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func newTestReleaseService(t *testing.T, handler http.HandlerFunc) (*ReleaseService, *helpers.MockRedis) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	mockRedis := helpers.NewMockRedis()
+
+	service := NewReleaseService(mockRedis.Client, helpers.NewSilentTestLogger())
+	service.url = server.URL
+	service.current = "0.1.1"
+	return service, mockRedis
+}
+
+func TestReleaseService_CheckForUpdate(t *testing.T) {
+	release := `{"tag_name":"v0.2.0","html_url":"https://github.com/valpere/shopogoda/releases/tag/v0.2.0"}`
+
+	t.Run("fetches and caches the newest release", func(t *testing.T) {
+		service, mockRedis := newTestReleaseService(t, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "ShoPogoda/0.1.1", r.Header.Get("User-Agent"))
+			_, _ = w.Write([]byte(release))
+		})
+		mockRedis.Mock.ExpectGet(latestReleaseKey).RedisNil()
+		mockRedis.Mock.ExpectSet(latestReleaseKey, []byte(release), latestReleaseTTL).SetVal("OK")
+
+		status, err := service.CheckForUpdate(context.Background())
+
+		require.NoError(t, err)
+		assert.False(t, status.UpToDate)
+		assert.Equal(t, "0.1.1", status.Current)
+		assert.Equal(t, &Release{Version: "v0.2.0", URL: "https://github.com/valpere/shopogoda/releases/tag/v0.2.0"}, status.Latest)
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("uses the cached release", func(t *testing.T) {
+		service, mockRedis := newTestReleaseService(t, func(w http.ResponseWriter, r *http.Request) {
+			t.Error("GitHub was asked although the release was cached")
+		})
+		mockRedis.Mock.ExpectGet(latestReleaseKey).SetVal(`{"tag_name":"v0.1.1","html_url":"https://example.com"}`)
+
+		status, err := service.CheckForUpdate(context.Background())
+
+		require.NoError(t, err)
+		assert.True(t, status.UpToDate)
+	})
+
+	t.Run("reports failed requests", func(t *testing.T) {
+		service, mockRedis := newTestReleaseService(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		})
+		mockRedis.Mock.ExpectGet(latestReleaseKey).RedisNil()
+
+		_, err := service.CheckForUpdate(context.Background())
+
+		assert.ErrorContains(t, err, "status 403")
+	})
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/valpere/shopogoda/internal"
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+// RenderFunc renders user-facing content in one language
+type RenderFunc func(language string) string
+
+// bilingualDivider separates the primary rendering from the secondary block
+const bilingualDivider = "\n\n┈┈┈┈┈┈┈┈┈┈\n"
+
+// defaultWeatherSummaryFormat is the en-US weather summary, used when no
+// localization service is configured
+const defaultWeatherSummaryFormat = "📍 *%s* · %s %s\n🌡️ %s (feels like %s) · 💧 %s · 💨 %s"
+
+// Bilingual decorates render for users who read two languages: the content is
+// rendered in the requested language first, then summary renders a short block
+// in secondary below a divider. Formatters are unaware of the mode; with no
+// secondary language, or the same one, the output is unchanged.
+func Bilingual(render, summary RenderFunc, secondary string) RenderFunc {
+	return func(language string) string {
+		primary := render(language)
+		if secondary == "" || secondary == language {
+			return primary
+		}
+		return primary + bilingualDivider + summary(secondary)
+	}
+}
+
+// RenderForUser renders content in the user's language, followed by the
+// summary in their secondary language when bilingual output is on
+func RenderForUser(user *models.User, render, summary RenderFunc) string {
+	language, secondary := renderLanguages(user)
+	return Bilingual(render, summary, secondary)(language)
+}
+
+// SecondarySummary returns the divider and the summary in the user's secondary
+// language, for messages composed elsewhere such as templated digests. It is
+// empty when bilingual output is off.
+func SecondarySummary(user *models.User, summary RenderFunc) string {
+	return RenderForUser(user, func(string) string { return "" }, summary)
+}
+
+// UserUnits returns the unit system the user reads weather in, metric unless
+// they chose imperial
+func UserUnits(user *models.User) string {
+	if user == nil || user.Units == "" {
+		return weather.UnitsMetric
+	}
+	return user.Units
+}
+
+// UserTimezone returns the user's timezone, or UTC when it is unset or invalid
+func UserTimezone(user *models.User) *time.Location {
+	if user == nil {
+		return time.UTC
+	}
+	return userLocation(user)
+}
+
+func renderLanguages(user *models.User) (language, secondary string) {
+	language = internal.DefaultLanguage
+	if user != nil {
+		if user.Language != "" {
+			language = user.Language
+		}
+		secondary = user.SecondaryLanguage
+	}
+	return language, secondary
+}
+
+// WeatherSummary renders the key figures of current weather in two lines, the
+// block shown in the secondary language under weather messages and digests
+func WeatherSummary(localization *LocalizationService, current *WeatherData, units string) RenderFunc {
+	return func(language string) string {
+		args := []any{
+			markdown.EscapeBold(current.LocationName),
+			current.Icon, current.Description,
+			weather.FormatTemp(current.Temperature, units),
+			weather.FormatTemp(current.FeelsLike, units),
+			weather.FormatPercent(float64(current.Humidity)),
+			weather.FormatSpeed(current.WindSpeed, units),
+		}
+		if localization == nil {
+			return fmt.Sprintf(defaultWeatherSummaryFormat, args...)
+		}
+		return localization.T(context.Background(), language, "bilingual_weather_summary", args...)
+	}
+}
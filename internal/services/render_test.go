@@ -0,0 +1,157 @@
+package services
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/internal/locales"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/weather"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files from the current renderer output")
+
+func TestBilingual(t *testing.T) {
+	render := func(language string) string {
+		return "[" + language + "]\n\nline two"
+	}
+	summary := func(language string) string {
+		return "(" + language + ")"
+	}
+
+	t.Run("off without a secondary language", func(t *testing.T) {
+		assert.Equal(t, render("en-US"), Bilingual(render, summary, "")("en-US"))
+	})
+
+	t.Run("off when secondary matches the primary", func(t *testing.T) {
+		assert.Equal(t, render("en-US"), Bilingual(render, summary, "en-US")("en-US"))
+	})
+
+	t.Run("appends the secondary summary", func(t *testing.T) {
+		result := Bilingual(render, summary, "uk-UA")("en-US")
+		assert.Equal(t, "[en-US]\n\nline two"+bilingualDivider+"(uk-UA)", result)
+	})
+}
+
+func TestRenderForUser(t *testing.T) {
+	render := func(language string) string { return language }
+	summary := func(language string) string { return "(" + language + ")" }
+
+	assert.Equal(t, "en-US", RenderForUser(nil, render, summary))
+	assert.Equal(t, "de-DE", RenderForUser(&models.User{Language: "de-DE"}, render, summary))
+	assert.Equal(t, "de-DE"+bilingualDivider+"(fr-FR)", RenderForUser(&models.User{Language: "de-DE", SecondaryLanguage: "fr-FR"}, render, summary))
+}
+
+func TestUserTimezone(t *testing.T) {
+	assert.Equal(t, time.UTC, UserTimezone(nil))
+	assert.Equal(t, time.UTC, UserTimezone(&models.User{}))
+	assert.Equal(t, time.UTC, UserTimezone(&models.User{Timezone: "Mars/Olympus"}))
+	assert.Equal(t, "Europe/Kyiv", UserTimezone(&models.User{Timezone: "Europe/Kyiv"}).String())
+}
+
+func TestMoonPhaseKey(t *testing.T) {
+	assert.Equal(t, "moon_new", MoonPhaseKey(0))
+	assert.Equal(t, "moon_first_quarter", MoonPhaseKey(0.25))
+	assert.Equal(t, "moon_full", MoonPhaseKey(0.5))
+	assert.Equal(t, "moon_waning_crescent", MoonPhaseKey(0.875))
+	assert.Equal(t, "moon_new", MoonPhaseKey(0.99))
+}
+
+func TestSecondarySummary(t *testing.T) {
+	summary := func(language string) string { return "(" + language + ")" }
+
+	assert.Empty(t, SecondarySummary(nil, summary))
+	assert.Empty(t, SecondarySummary(&models.User{Language: "uk-UA", SecondaryLanguage: "uk-UA"}, summary))
+	assert.Equal(t, bilingualDivider+"(uk-UA)", SecondarySummary(&models.User{SecondaryLanguage: "uk-UA"}, summary))
+}
+
+func TestBilingualDailyDigestSnapshot(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	localization := NewLocalizationService(logger)
+	require.NoError(t, localization.LoadTranslations(locales.LocalesFS))
+
+	service := NewNotificationService(&config.IntegrationsConfig{}, logger)
+	service.SetLocalization(localization)
+
+	current := &WeatherData{
+		LocationName:  "Kyiv",
+		Temperature:   -0.4,
+		FeelsLike:     -4.1,
+		Description:   "light snow",
+		Icon:          "🌨️",
+		Humidity:      93,
+		WindSpeed:     9.96,
+		WindDirection: 270,
+		Visibility:    4.2,
+		AQI:           42,
+		Timestamp:     time.Date(2025, 1, 15, 7, 0, 0, 0, time.UTC),
+	}
+	user := &models.User{Language: "en-US", SecondaryLanguage: "uk-UA"}
+
+	message := RenderForUser(user, func(language string) string {
+		return service.formatDailyWeather(current, language, weather.UnitsMetric, time.UTC)
+	}, WeatherSummary(localization, current, weather.UnitsMetric))
+
+	// The full update in English, then the divider and the short Ukrainian summary
+	primary, secondary, found := strings.Cut(message, bilingualDivider)
+	require.True(t, found, "divider missing")
+	assert.Equal(t, service.formatDailyWeather(current, "en-US", weather.UnitsMetric, time.UTC), primary)
+	assert.Equal(t, WeatherSummary(localization, current, weather.UnitsMetric)("uk-UA"), secondary)
+	assert.Len(t, strings.Split(secondary, "\n"), 2)
+
+	assertGolden(t, filepath.Join("testdata", "bilingual_digest.golden"), message)
+}
+
+// assertGolden compares text with a golden file, rewriting it with -update
+func assertGolden(t *testing.T, path, text string) {
+	t.Helper()
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(text), 0o644))
+	}
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err, "golden file missing; run go test with -update")
+	assert.Equal(t, string(expected), text)
+}
+
+func TestBilingualWeeklyDigest(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	localization := NewLocalizationService(logger)
+	require.NoError(t, localization.LoadTranslations(locales.LocalesFS))
+
+	api := &restrictedBotAPI{}
+	service := NewNotificationService(&config.IntegrationsConfig{}, logger)
+	service.SetLocalization(localization)
+	service.SetBot(&gotgbot.Bot{Token: "test", BotClient: api})
+
+	current := &WeatherData{LocationName: "Kyiv", Temperature: -0.4, FeelsLike: -4.1, Description: "light snow", Icon: "🌨️", Humidity: 93, WindSpeed: 9.96}
+	user := &models.User{ID: 42, LocationName: "Kyiv", Language: "en-US", SecondaryLanguage: "uk-UA"}
+
+	require.NoError(t, service.SendTelegramWeeklyUpdate(user, user.ID, "summary", current))
+
+	require.Len(t, api.requests, 1)
+	primary, secondary, found := strings.Cut(api.requests[0].text, bilingualDivider)
+	require.True(t, found, "divider missing")
+	assert.Contains(t, primary, "Weekly Weather Summary")
+	assert.Equal(t, WeatherSummary(localization, current, weather.UnitsMetric)("uk-UA"), secondary)
+	assert.Contains(t, secondary, "відчувається як")
+}
+
+func TestWeatherSummaryEscapesLocation(t *testing.T) {
+	localization := NewLocalizationService(helpers.NewSilentTestLogger())
+	require.NoError(t, localization.LoadTranslations(locales.LocalesFS))
+
+	current := &WeatherData{LocationName: "Las Vegas_NV*", Icon: "☀️", Description: "clear sky"}
+	assert.True(t, strings.HasPrefix(WeatherSummary(localization, current, weather.UnitsMetric)("en-US"), `📍 *Las Vegas_NV*\***`))
+	assert.True(t, strings.HasPrefix(WeatherSummary(nil, current, weather.UnitsMetric)("en-US"), `📍 *Las Vegas_NV*\***`))
+}
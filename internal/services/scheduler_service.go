@@ -2,20 +2,50 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
 	"gorm.io/gorm"
 
+	"github.com/valpere/shopogoda/internal/markdown"
 	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/internal/notifications"
+	"github.com/valpere/shopogoda/pkg/metrics"
+	"github.com/valpere/shopogoda/pkg/weather"
 )
 
 const (
 	// NotificationPlatformCount represents the number of notification platforms (Slack + Telegram)
 	NotificationPlatformCount = 2
+
+	// defaultDeliveryWindow is how long a notification slot is spread over
+	defaultDeliveryWindow = 5 * time.Minute
+	// notificationInterval is how often scheduled notifications are processed;
+	// a delivery still running when the next run is due hands the rest over
+	notificationInterval = time.Hour
+	// deferUndeliveredTimeout bounds keeping undelivered notifications on shutdown
+	deferUndeliveredTimeout = 10 * time.Second
+
+	// maxDigestAlerts caps the "recent alert activity" section of the daily digest
+	maxDigestAlerts = 5
+	// maxDigestActivityWindow bounds how far back the digest looks for alerts
+	// when the previous delivery is long ago
+	maxDigestActivityWindow = 7 * 24 * time.Hour
+
+	// digestSuggestionInterval is how often digest send-time suggestions are made
+	digestSuggestionInterval = 7 * 24 * time.Hour
+	// digestSuggestionLastRunKey records when suggestions were last made, so the
+	// weekly run is kept across restarts and deployments
+	digestSuggestionLastRunKey = "scheduler:digest_suggestions:last_run"
+
+	// subscriptionGaugeInterval is how often the active_subscriptions gauge is
+	// counted again
+	subscriptionGaugeInterval = 5 * time.Minute
 )
 
 type SchedulerService struct {
@@ -23,9 +53,24 @@ type SchedulerService struct {
 	redis        *redis.Client
 	weather      *WeatherService
 	alert        *AlertService
+	subscription *SubscriptionService
 	notification *NotificationService
+	messaging    *MessagingService
+	engagement   *EngagementService
+	chatAccess   *ChatAccessService
+	chats        *ChatSettingsService
+	widgets      *WidgetService
+	outbox       *OutboxService
+	escalations  *AlertEscalationService
+	localization *LocalizationService
+	anomalies    *AnomalyService
+	records      *WeatherRecordService
+	webhooks     *notifications.WebhookNotifier
+	metrics      *metrics.Metrics
 	logger       *zerolog.Logger
 	stopChan     chan struct{}
+
+	deliveryWindow time.Duration
 }
 
 func NewSchedulerService(
@@ -44,7 +89,89 @@ func NewSchedulerService(
 		notification: notification,
 		logger:       logger,
 		stopChan:     make(chan struct{}),
+
+		deliveryWindow: defaultDeliveryWindow,
+	}
+}
+
+// SetMessaging routes templated notifications (weekly digest) through the messaging service
+func (s *SchedulerService) SetMessaging(messaging *MessagingService) {
+	s.messaging = messaging
+}
+
+// SetDeliveryWindow sets the period over which the notifications of one slot are
+// spread, so a popular time like 08:00 does not hit Telegram as a single spike.
+// Zero sends the whole slot at once.
+func (s *SchedulerService) SetDeliveryWindow(window time.Duration) {
+	if window < 0 {
+		window = 0
 	}
+	s.deliveryWindow = window
+}
+
+// SetChatAccess pauses scheduled deliveries to chats where the bot cannot post
+func (s *SchedulerService) SetChatAccess(chatAccess *ChatAccessService) {
+	s.chatAccess = chatAccess
+}
+
+// SetWidgets enables refreshing of pinned live weather widgets
+func (s *SchedulerService) SetWidgets(widgets *WidgetService) {
+	s.widgets = widgets
+}
+
+// SetLocalization enables localized parts of scheduled notifications, such as
+// the week header of the weekly digest
+func (s *SchedulerService) SetLocalization(localization *LocalizationService) {
+	s.localization = localization
+}
+
+// SetOutbox keeps failed scheduled notifications for retries and as dead letters
+func (s *SchedulerService) SetOutbox(outbox *OutboxService) {
+	s.outbox = outbox
+}
+
+// SetAnomalies enables notes about weather that is unusual for the season
+func (s *SchedulerService) SetAnomalies(anomalies *AnomalyService) {
+	s.anomalies = anomalies
+}
+
+// SetWeatherRecords records the weather sent by subscriptions and drops
+// records past their retention
+func (s *SchedulerService) SetWeatherRecords(records *WeatherRecordService) {
+	s.records = records
+}
+
+// SetWebhooks posts triggered alerts to the webhooks users configured
+func (s *SchedulerService) SetWebhooks(webhooks *notifications.WebhookNotifier) {
+	s.webhooks = webhooks
+}
+
+// SetEscalations enables follow-ups on unacknowledged extreme alerts
+func (s *SchedulerService) SetEscalations(escalations *AlertEscalationService) {
+	s.escalations = escalations
+}
+
+// SetSubscriptions holds back routine alerts of users who take them in the
+// daily digest instead of immediately
+func (s *SchedulerService) SetSubscriptions(subscription *SubscriptionService) {
+	s.subscription = subscription
+}
+
+// SetChatSettings lets subscriptions created in a group describe the group's
+// location, in its language and units
+func (s *SchedulerService) SetChatSettings(chats *ChatSettingsService) {
+	s.chats = chats
+}
+
+// SetEngagement enables the weekly digest send-time suggestion job
+// SetMetrics sets the collector used to count triggered alerts and
+// dispatched notifications
+func (s *SchedulerService) SetMetrics(metricsCollector *metrics.Metrics) {
+	s.metrics = metricsCollector
+}
+
+func (s *SchedulerService) SetEngagement(engagement *EngagementService) {
+	s.engagement = engagement
 }
 
 func (s *SchedulerService) Start(ctx context.Context) {
@@ -55,9 +182,34 @@ func (s *SchedulerService) Start(ctx context.Context) {
 	defer alertTicker.Stop()
 
 	// Send daily weather updates at 8 AM
-	dailyTicker := time.NewTicker(time.Hour)
+	dailyTicker := time.NewTicker(notificationInterval)
 	defer dailyTicker.Stop()
 
+	// Refresh pinned widgets; each widget has its own slots, so check often
+	widgetTicker := time.NewTicker(15 * time.Minute)
+	defer widgetTicker.Stop()
+
+	// Drop dead letters and weather records past their retention
+	deadLetterTicker := time.NewTicker(24 * time.Hour)
+	defer deadLetterTicker.Stop()
+
+	// Follow up on extreme alerts nobody acknowledged
+	escalationTicker := time.NewTicker(time.Minute)
+	defer escalationTicker.Stop()
+
+	// Pass official severe weather warnings on to extreme weather subscribers
+	warningTicker := time.NewTicker(warningCheckInterval)
+	defer warningTicker.Stop()
+
+	// Warn alert subscribers in the morning of bad air forecast for the day
+	airOutlookTicker := time.NewTicker(airQualityOutlookInterval)
+	defer airOutlookTicker.Stop()
+
+	// Keep the active_subscriptions gauge current, from the start
+	subscriptionGaugeTicker := time.NewTicker(subscriptionGaugeInterval)
+	defer subscriptionGaugeTicker.Stop()
+	s.refreshSubscriptionGauge(ctx)
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -69,7 +221,25 @@ func (s *SchedulerService) Start(ctx context.Context) {
 		case <-alertTicker.C:
 			s.checkAndProcessAlerts(ctx)
 		case <-dailyTicker.C:
-			s.processDailyNotifications(ctx)
+			// Delivery is spread over several minutes; keep checking alerts meanwhile
+			go s.processDailyNotifications(ctx)
+			// Suggest better digest times once a week, by wall clock
+			s.processDigestTimeSuggestions(ctx, time.Now())
+		case <-widgetTicker.C:
+			if s.widgets != nil {
+				s.widgets.RefreshDue(ctx)
+			}
+		case <-deadLetterTicker.C:
+			s.purgeDeadLetters(ctx)
+			s.purgeWeatherRecords(ctx)
+		case <-escalationTicker.C:
+			s.processAlertEscalations(ctx)
+		case <-warningTicker.C:
+			s.processWeatherWarnings(ctx, time.Now())
+		case <-airOutlookTicker.C:
+			s.processAirQualityOutlooks(ctx, time.Now())
+		case <-subscriptionGaugeTicker.C:
+			s.refreshSubscriptionGauge(ctx)
 		}
 	}
 }
@@ -78,6 +248,16 @@ func (s *SchedulerService) Stop() {
 	close(s.stopChan)
 }
 
+// weatherAtUserLocation gets the current weather at the user's coordinates.
+// Only a location stored without coordinates is looked up by its name, which
+// fails for names such as "Location (51.5074, -0.1278)".
+func (s *SchedulerService) weatherAtUserLocation(ctx context.Context, user *models.User) (*WeatherData, error) {
+	if user.Latitude != 0 || user.Longitude != 0 {
+		return s.weather.GetCurrentWeatherByCoords(ctx, user.Latitude, user.Longitude)
+	}
+	return s.weather.GetCurrentWeatherByLocation(ctx, user.LocationName)
+}
+
 func (s *SchedulerService) checkAndProcessAlerts(ctx context.Context) {
 	s.logger.Debug().Msg("Checking weather alerts")
 
@@ -92,7 +272,7 @@ func (s *SchedulerService) checkAndProcessAlerts(ctx context.Context) {
 
 	for _, user := range users {
 		// Get current weather for user's location
-		weather, err := s.weather.GetCurrentWeatherByCoords(ctx, user.Latitude, user.Longitude)
+		weather, err := s.weatherAtUserLocation(ctx, &user)
 		if err != nil {
 			s.logger.Error().Err(err).
 				Str("location", user.LocationName).
@@ -101,8 +281,10 @@ func (s *SchedulerService) checkAndProcessAlerts(ctx context.Context) {
 			continue
 		}
 
+		s.noteUnusualWeather(ctx, &user, weather)
+
 		// Check for triggered alerts
-		alerts, err := s.alert.CheckAlerts(ctx, weather.ToModelWeatherData(), user.ID)
+		alerts, err := s.alert.CheckAlerts(ctx, weather.ToModelWeatherData(), &user)
 		if err != nil {
 			s.logger.Error().Err(err).
 				Str("location", user.LocationName).
@@ -111,8 +293,28 @@ func (s *SchedulerService) checkAndProcessAlerts(ctx context.Context) {
 			continue
 		}
 
+		if s.metrics != nil {
+			for _, alert := range alerts {
+				s.metrics.IncrementCounter("alert_triggers_total", alert.AlertType.String(), alert.Severity.String())
+			}
+		}
+
+		immediate := s.immediateAlertDelivery(ctx, user.ID, len(alerts))
+
 		// Send notifications for triggered alerts
 		for _, alert := range alerts {
+			// Webhooks feed the user's own systems, so they get every trigger
+			s.sendAlertWebhook(ctx, &alert, &user)
+
+			// Routine alerts of digest-only users wait for the next daily digest
+			if !immediate && alert.Severity != models.SeverityCritical {
+				s.logger.Debug().
+					Str("alert_type", alert.AlertType.String()).
+					Int64("user_id", user.ID).
+					Msg("Alert deferred to the daily digest")
+				continue
+			}
+
 			// Track alert notification errors but don't fail processing
 			var alertErrors []string
 
@@ -122,8 +324,10 @@ func (s *SchedulerService) checkAndProcessAlerts(ctx context.Context) {
 				alertErrors = append(alertErrors, fmt.Sprintf("Slack: %v", err))
 			}
 
-			// Send Telegram alert
-			if err := s.notification.SendTelegramAlert(&alert, &user); err != nil {
+			// Send Telegram alert, unless the bot cannot currently post to the user
+			if s.chatUnwritable(ctx, user.ID) {
+				s.logger.Info().Int64("user_id", user.ID).Msg("Paused Telegram alert, bot cannot post in chat")
+			} else if err := s.notification.SendTelegramAlert(&alert, &user); err != nil {
 				s.logger.Error().Err(err).Msg("Failed to send Telegram alert")
 				alertErrors = append(alertErrors, fmt.Sprintf("Telegram: %v", err))
 			}
@@ -159,23 +363,164 @@ func (s *SchedulerService) checkAndProcessAlerts(ctx context.Context) {
 	}
 }
 
+// sendAlertWebhook posts a triggered alert to the user's webhook, if any
+func (s *SchedulerService) sendAlertWebhook(ctx context.Context, alert *models.EnvironmentalAlert, user *models.User) {
+	if s.webhooks == nil {
+		return
+	}
+	err := s.webhooks.Send(ctx, notifications.AlertEvent{
+		UserID:         user.ID,
+		AlertType:      alert.AlertType.String(),
+		TriggeredValue: alert.Value,
+		Threshold:      alert.Threshold,
+		Location:       user.LocationName,
+		Timestamp:      alert.CreatedAt.UTC(),
+	})
+	if err != nil {
+		s.logger.Warn().Err(err).
+			Str("alert_type", alert.AlertType.String()).
+			Int64("user_id", user.ID).
+			Msg("Failed to post alert webhook")
+	}
+}
+
+// noteUnusualWeather records the weather at the user's location for the
+// seasonal baseline and, for users who opted in, sends a note the first time
+// in a day the temperature is unusually warm or cold
+func (s *SchedulerService) noteUnusualWeather(ctx context.Context, user *models.User, current *WeatherData) {
+	if s.anomalies == nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	recorded, err := s.anomalies.RecordObservation(ctx, user.ID, current, now)
+	if err != nil {
+		s.logger.Warn().Err(err).Int64("user_id", user.ID).Msg("Failed to record weather observation")
+	}
+	// Alerts are checked every few minutes; look for unusual weather only
+	// with each new observation
+	if !recorded || !user.UnusualWeatherNotes || s.messaging == nil || s.chatUnwritable(ctx, user.ID) {
+		return
+	}
+
+	note := s.unusualWeatherNote(ctx, user, current, now)
+	if note == "" {
+		return
+	}
+	claimed, err := s.anomalies.ClaimDailyNote(ctx, user.ID, now)
+	if err != nil || !claimed {
+		return
+	}
+
+	vars := map[string]string{"location": markdown.EscapeBold(user.LocationName), "note": note}
+	if err := s.messaging.SendWithOptions(ctx, user.ID, TemplateUnusualWeather, vars, SendOptions{Silent: user.QuietDigests}); err != nil {
+		s.logger.Error().Err(err).Int64("user_id", user.ID).Msg("Failed to send unusual weather note")
+	}
+}
+
+// unusualWeatherNote returns the note for the digest when the current
+// temperature is unusual for the season, or an empty string
+func (s *SchedulerService) unusualWeatherNote(ctx context.Context, user *models.User, current *WeatherData, now time.Time) string {
+	if s.anomalies == nil {
+		return ""
+	}
+	return s.anomalies.NoteForUser(ctx, user, current.Temperature, now)
+}
+
+// recordWeather keeps the weather a subscription is about to send in the
+// user's weather records
+func (s *SchedulerService) recordWeather(ctx context.Context, subscription *models.Subscription, current *WeatherData) {
+	if s.records == nil {
+		return
+	}
+	_, err := s.records.Record(ctx, subscription.UserID, subscription.DeliveryChatID(),
+		subscription.User.Latitude, subscription.User.Longitude, current)
+	if err != nil {
+		s.logger.Warn().Err(err).Int64("user_id", subscription.UserID).Msg("Failed to record weather")
+	}
+}
+
+// immediateAlertDelivery reports whether the user's triggered alerts are sent
+// right away. Lookup failures fall back to immediate delivery.
+func (s *SchedulerService) immediateAlertDelivery(ctx context.Context, userID int64, triggered int) bool {
+	if s.subscription == nil || triggered == 0 {
+		return true
+	}
+
+	immediate, err := s.subscription.ImmediateAlertDelivery(ctx, userID)
+	if err != nil {
+		s.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to check alert delivery preference")
+		return true
+	}
+	return immediate
+}
+
+// processDigestTimeSuggestions makes digest send-time suggestions when a week
+// has passed since the last run. The run is recorded before it starts, so a
+// slow or failing run is not repeated every hour.
+func (s *SchedulerService) processDigestTimeSuggestions(ctx context.Context, now time.Time) {
+	if s.engagement == nil {
+		return
+	}
+
+	lastRun, err := s.redis.Get(ctx, digestSuggestionLastRunKey).Int64()
+	switch {
+	case errors.Is(err, redis.Nil):
+		// Never ran
+	case err != nil:
+		s.logger.Warn().Err(err).Msg("Failed to read last digest suggestion run")
+		return
+	case now.Sub(time.Unix(lastRun, 0)) < digestSuggestionInterval:
+		return
+	}
+
+	if err := s.redis.Set(ctx, digestSuggestionLastRunKey, now.Unix(), 0).Err(); err != nil {
+		s.logger.Warn().Err(err).Msg("Failed to record digest suggestion run")
+		return
+	}
+
+	if _, err := s.engagement.ProcessDigestTimeSuggestions(ctx); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to process digest time suggestions")
+	}
+}
+
 func (s *SchedulerService) processDailyNotifications(ctx context.Context) {
 	now := time.Now().UTC()
 	s.logger.Debug().Time("utc_time", now).Msg("Processing scheduled notifications")
 
-	// Get all active subscriptions with users who have locations set
+	s.retryFailedDeliveries(ctx)
+
+	due, err := s.DueSubscriptions(ctx, now)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to get active subscriptions")
+		return
+	}
+
+	s.deliverSpread(ctx, due)
+}
+
+// DueSubscriptions returns the active daily and weekly subscriptions of active
+// users with a location whose time of day, in the user's timezone, falls in
+// the run at now
+func (s *SchedulerService) DueSubscriptions(ctx context.Context, now time.Time) ([]models.Subscription, error) {
 	var subscriptions []models.Subscription
 	if err := s.db.WithContext(ctx).
 		Preload("User").
 		Joins("JOIN users ON users.id = subscriptions.user_id").
-		Where("subscriptions.is_active = ? AND users.location_name != '' AND users.location_name IS NOT NULL", true).
+		Where("subscriptions.is_active = ? AND users.is_active = ? AND users.location_name != '' AND users.location_name IS NOT NULL", true, true).
 		Find(&subscriptions).Error; err != nil {
-		s.logger.Error().Err(err).Msg("Failed to get active subscriptions")
-		return
+		return nil, err
 	}
+	return s.dueSubscriptions(subscriptions, now), nil
+}
 
+// dueSubscriptions returns the subscriptions to send at now. Each time of day
+// is read in the user's current timezone, loaded with the subscription on
+// every run, so changing the timezone moves the next notification with it.
+// Empty or invalid timezones count as UTC.
+func (s *SchedulerService) dueSubscriptions(subscriptions []models.Subscription, now time.Time) []models.Subscription {
+	var due []models.Subscription
 	for _, subscription := range subscriptions {
-		// Parse user's timezone
 		userTimezone := subscription.User.Timezone
 		if userTimezone == "" {
 			userTimezone = "UTC"
@@ -187,54 +532,307 @@ func (s *SchedulerService) processDailyNotifications(ctx context.Context) {
 			location = time.UTC
 		}
 
-		// Convert current time to user's timezone
-		userTime := now.In(location)
+		if s.shouldSendNotification(subscription, now.In(location)) {
+			due = append(due, subscription)
+		}
+	}
+	return due
+}
+
+// deliverSpread sends the due notifications evenly over the delivery window.
+// Notifications whose turn does not come, because the scheduler stops or the
+// next run is due first, are kept in the outbox for the retries.
+func (s *SchedulerService) deliverSpread(ctx context.Context, due []models.Subscription) {
+	if len(due) == 0 {
+		return
+	}
+
+	s.logger.Info().
+		Int("count", len(due)).
+		Dur("window", s.deliveryWindow).
+		Msg("Delivering scheduled notifications")
 
-		// Check if it's time to send the notification
-		if s.shouldSendNotification(subscription, userTime) {
+	start := time.Now()
+	nextRun := start.Add(notificationInterval)
+	for i, subscription := range due {
+		if wait := time.Until(start.Add(spreadDelay(i, len(due), s.deliveryWindow))); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				s.deferUndelivered(ctx, due[i:], "Notification delivery cancelled")
+				return
+			case <-s.stopChan:
+				timer.Stop()
+				s.deferUndelivered(ctx, due[i:], "Scheduler stopped during notification delivery")
+				return
+			case <-timer.C:
+			}
+		}
+		if !time.Now().Before(nextRun) {
+			s.deferUndelivered(ctx, due[i:], "Notification delivery overran into the next run")
+			return
+		}
+
+		if s.chatUnwritable(ctx, subscription.DeliveryChatID()) {
 			s.logger.Info().
 				Str("type", subscription.SubscriptionType.String()).
-				Str("time", subscription.TimeOfDay).
-				Str("user_timezone", userTimezone).
 				Int64("user_id", subscription.UserID).
-				Msg("Sending scheduled notification")
+				Msg("Paused scheduled notification, bot cannot post in chat")
+			s.deferDelivery(ctx, subscription, ErrChatUnwritable)
+			continue
+		}
 
-			if err := s.sendScheduledNotification(ctx, subscription); err != nil {
-				s.logger.Error().Err(err).
-					Int64("user_id", subscription.UserID).
-					Str("type", subscription.SubscriptionType.String()).
-					Msg("Failed to send scheduled notification")
-			}
+		s.logger.Info().
+			Str("type", subscription.SubscriptionType.String()).
+			Str("time", subscription.TimeOfDay).
+			Int64("user_id", subscription.UserID).
+			Msg("Sending scheduled notification")
+
+		if err := s.dispatchNotification(ctx, subscription); err != nil {
+			s.logger.Error().Err(err).
+				Int64("user_id", subscription.UserID).
+				Str("type", subscription.SubscriptionType.String()).
+				Msg("Failed to send scheduled notification")
+			s.recordFailedDelivery(ctx, &models.OutboxItem{SubscriptionID: subscription.ID, UserID: subscription.UserID}, err)
 		}
 	}
 }
 
+// deferUndelivered keeps the notifications a delivery did not reach in the
+// outbox. The context may already be cancelled, so the writes do not inherit
+// its cancellation.
+func (s *SchedulerService) deferUndelivered(ctx context.Context, undelivered []models.Subscription, reason string) {
+	s.logger.Warn().Int("undelivered", len(undelivered)).Msg(reason)
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), deferUndeliveredTimeout)
+	defer cancel()
+	for _, subscription := range undelivered {
+		s.deferDelivery(ctx, subscription, errDeliveryInterrupted)
+	}
+}
+
+// retryFailedDeliveries sends the pending outbox items once more. Items whose
+// subscription was cancelled or whose user paused their account in the
+// meantime are dropped.
+func (s *SchedulerService) retryFailedDeliveries(ctx context.Context) {
+	if s.outbox == nil {
+		return
+	}
+
+	items, err := s.outbox.Pending(ctx)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to load pending deliveries")
+		return
+	}
+
+	for i := range items {
+		item := &items[i]
+		if !item.Subscription.IsActive || !item.Subscription.User.IsActive {
+			s.resolveDelivery(ctx, item)
+			continue
+		}
+		// Kept pending until the bot may post in the chat again
+		if s.chatUnwritable(ctx, item.Subscription.DeliveryChatID()) {
+			continue
+		}
+
+		if err := s.dispatchNotification(ctx, item.Subscription); err != nil {
+			s.logger.Warn().Err(err).Int64("user_id", item.UserID).Int("attempt", item.Attempts+1).Msg("Retry of scheduled notification failed")
+			s.recordFailedDelivery(ctx, item, err)
+			continue
+		}
+		s.resolveDelivery(ctx, item)
+	}
+
+	s.outbox.UpdateMetrics(ctx)
+}
+
+func (s *SchedulerService) recordFailedDelivery(ctx context.Context, item *models.OutboxItem, cause error) {
+	if s.outbox == nil {
+		return
+	}
+	if err := s.outbox.Fail(ctx, item, cause); err != nil {
+		s.logger.Error().Err(err).Int64("user_id", item.UserID).Msg("Failed to record failed delivery")
+		return
+	}
+	if item.Status == models.OutboxDead {
+		s.logger.Warn().
+			Int64("user_id", item.UserID).
+			Str("error_class", item.ErrorClass).
+			Int("attempts", item.Attempts).
+			Msg("Scheduled notification moved to dead letters")
+	}
+}
+
+// deferDelivery keeps a notification that was not attempted in the outbox, so
+// it is sent by the retries; paused ones once the bot may post in the chat again
+func (s *SchedulerService) deferDelivery(ctx context.Context, subscription models.Subscription, cause error) {
+	if s.outbox == nil {
+		return
+	}
+	if err := s.outbox.Defer(ctx, subscription.ID, subscription.UserID, cause); err != nil {
+		s.logger.Error().Err(err).Int64("user_id", subscription.UserID).Msg("Failed to defer delivery")
+	}
+}
+
+func (s *SchedulerService) resolveDelivery(ctx context.Context, item *models.OutboxItem) {
+	if err := s.outbox.Resolve(ctx, item.ID); err != nil {
+		s.logger.Warn().Err(err).Str("item_id", item.ID.String()).Msg("Failed to resolve outbox item")
+	}
+}
+
+// refreshSubscriptionGauge sets the active_subscriptions gauge to the number of
+// active subscriptions of each type, zero for types nobody subscribes to
+func (s *SchedulerService) refreshSubscriptionGauge(ctx context.Context) {
+	if s.metrics == nil {
+		return
+	}
+
+	var rows []struct {
+		SubscriptionType models.SubscriptionType
+		Count            int64
+	}
+	err := s.db.WithContext(ctx).Model(&models.Subscription{}).
+		Select("subscription_type, COUNT(*) AS count").
+		Where("is_active = ?", true).
+		Group("subscription_type").
+		Scan(&rows).Error
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to count active subscriptions")
+		return
+	}
+
+	counts := make(map[models.SubscriptionType]int64, len(rows))
+	for _, row := range rows {
+		counts[row.SubscriptionType] = row.Count
+	}
+	for _, subscriptionType := range []models.SubscriptionType{
+		models.SubscriptionDaily,
+		models.SubscriptionWeekly,
+		models.SubscriptionAlerts,
+		models.SubscriptionExtreme,
+		models.SubscriptionSunrise,
+	} {
+		s.metrics.SetGauge("active_subscriptions", float64(counts[subscriptionType]), subscriptionType.String())
+	}
+}
+
+// purgeDeadLetters drops dead letters past the retention period
+func (s *SchedulerService) purgeDeadLetters(ctx context.Context) {
+	if s.outbox == nil {
+		return
+	}
+
+	purged, err := s.outbox.PurgeExpired(ctx)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to purge dead letters")
+		return
+	}
+	if purged > 0 {
+		s.logger.Info().Int64("count", purged).Msg("Purged expired dead letters")
+	}
+	s.outbox.UpdateMetrics(ctx)
+}
+
+// purgeWeatherRecords drops weather records past the retention period
+func (s *SchedulerService) purgeWeatherRecords(ctx context.Context) {
+	if s.records == nil {
+		return
+	}
+
+	purged, err := s.records.PurgeExpired(ctx)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to purge weather records")
+		return
+	}
+	if purged > 0 {
+		s.logger.Info().Int64("count", purged).Msg("Purged expired weather records")
+	}
+}
+
+// processAlertEscalations sends the follow-ups of unacknowledged extreme alerts
+func (s *SchedulerService) processAlertEscalations(ctx context.Context) {
+	if s.escalations == nil {
+		return
+	}
+
+	sent, err := s.escalations.ProcessDue(ctx)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to process alert escalations")
+		return
+	}
+	if sent > 0 {
+		s.logger.Info().Int("count", sent).Msg("Sent follow-ups for unacknowledged extreme alerts")
+	}
+}
+
+// chatUnwritable reports whether deliveries to the chat are paused because
+// Telegram recently refused the bot's messages there
+func (s *SchedulerService) chatUnwritable(ctx context.Context, chatID int64) bool {
+	return s.chatAccess != nil && s.chatAccess.Suppressed(ctx, chatID)
+}
+
+// spreadDelay returns the offset from the start of the window at which the
+// i-th of n notifications is sent
+func spreadDelay(i, n int, window time.Duration) time.Duration {
+	if n <= 1 || window <= 0 {
+		return 0
+	}
+	return window * time.Duration(i) / time.Duration(n)
+}
+
+// subscriptionTimeUTC returns the UTC instant of a time of day (HH:MM) on the
+// local date of day in its location. A time skipped when clocks spring
+// forward falls on the moment they do; a time that occurs twice when they fall
+// back is the later one. time.Date leaves the choice between the two
+// occurrences unspecified (it gives the earlier one for America/New_York and
+// the later one for Europe/Kyiv), so it is made here.
+func subscriptionTimeUTC(timeOfDay string, day time.Time) (time.Time, error) {
+	targetTime, err := time.Parse("15:04", timeOfDay)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	target := time.Date(day.Year(), day.Month(), day.Day(), targetTime.Hour(), targetTime.Minute(), 0, 0, day.Location())
+	if target.Hour() != targetTime.Hour() || target.Minute() != targetTime.Minute() {
+		// time.Date moved the skipped time past the gap; use the transition
+		target, _ = target.ZoneBounds()
+	} else if _, end := target.ZoneBounds(); !end.IsZero() {
+		// When the zone of target ends by turning clocks back, the last
+		// stretch of it repeats after the change; move to the repeat
+		_, offset := target.Zone()
+		_, nextOffset := end.Zone()
+		if shift := time.Duration(offset-nextOffset) * time.Second; shift > 0 && !target.Before(end.Add(-shift)) {
+			target = target.Add(shift)
+		}
+	}
+	return target.UTC(), nil
+}
+
 func (s *SchedulerService) shouldSendNotification(subscription models.Subscription, userTime time.Time) bool {
-	// Parse the time of day (format: HH:MM)
-	targetTime, err := time.Parse("15:04", subscription.TimeOfDay)
+	if subscription.SubscriptionType == models.SubscriptionSunrise {
+		return sunriseDue(&subscription.User, userTime)
+	}
+
+	// Convert the time of day (format: HH:MM) in the user's timezone to UTC
+	targetToday, err := subscriptionTimeUTC(subscription.TimeOfDay, userTime)
 	if err != nil {
 		s.logger.Warn().Str("time_of_day", subscription.TimeOfDay).Err(err).Msg("Invalid time format")
 		return false
 	}
 
 	// Check if current time matches the target time (within 5-minute window)
-	// Create target time for today using user's timezone
-	targetToday := time.Date(
-		userTime.Year(), userTime.Month(), userTime.Day(),
-		targetTime.Hour(), targetTime.Minute(), 0, 0,
-		userTime.Location(),
-	)
-
-	// Calculate time difference and check if within 5-minute window
-	timeDiff := userTime.Sub(targetToday)
+	timeDiff := userTime.UTC().Sub(targetToday)
 	if timeDiff >= 0 && timeDiff <= 5*time.Minute {
 		switch subscription.SubscriptionType {
-		case models.SubscriptionDaily:
-			// Send daily notifications every day
+		case models.SubscriptionDaily, models.SubscriptionWeekly:
+			// Send weekly notifications only on the subscription's day, and
+			// daily ones every day
+			if subscription.IsWeekly() {
+				return userTime.Weekday() == subscription.WeeklySendDay()
+			}
 			return true
-		case models.SubscriptionWeekly:
-			// Send weekly notifications only on Mondays
-			return userTime.Weekday() == time.Monday
 		case models.SubscriptionAlerts, models.SubscriptionExtreme:
 			// Alert subscriptions are handled by checkAndProcessAlerts
 			return false
@@ -244,16 +842,45 @@ func (s *SchedulerService) shouldSendNotification(subscription models.Subscripti
 	return false
 }
 
+// sunriseDue reports whether the sun rose at the user's location within the
+// last five minutes, on the user's local date of userTime. Sunrise
+// subscriptions stay quiet on days of the polar night or day.
+func sunriseDue(user *models.User, userTime time.Time) bool {
+	sunrise, _ := weather.SunTimes(userTime, user.Latitude, user.Longitude)
+	if sunrise.IsZero() {
+		return false
+	}
+	timeDiff := userTime.Sub(sunrise)
+	return timeDiff >= 0 && timeDiff <= 5*time.Minute
+}
+
+// dispatchNotification sends a scheduled notification and counts the attempt
+func (s *SchedulerService) dispatchNotification(ctx context.Context, subscription models.Subscription) error {
+	err := s.sendScheduledNotification(ctx, subscription)
+	if s.metrics != nil {
+		status := "sent"
+		if err != nil {
+			status = "failed"
+		}
+		s.metrics.IncrementCounter("subscription_dispatches_total", subscription.SubscriptionType.String(), status)
+	}
+	return err
+}
+
 func (s *SchedulerService) sendScheduledNotification(ctx context.Context, subscription models.Subscription) error {
+	subscription.User = s.deliveryUser(ctx, subscription)
+	chatID := subscription.DeliveryChatID()
+
 	// Get weather for user's location
-	weather, err := s.weather.GetCurrentWeatherByCoords(
+	current, err := s.weather.GetCurrentWeatherByCoords(
 		ctx,
 		subscription.User.Latitude,
 		subscription.User.Longitude,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to get weather for user %d: %w", subscription.UserID, err)
+		return fmt.Errorf("%w (%s) for user %d: %w", errWeatherUnavailable, WeatherErrorClass(err), subscription.UserID, err)
 	}
+	s.recordWeather(ctx, &subscription, current)
 
 	switch subscription.SubscriptionType {
 	case models.SubscriptionDaily:
@@ -264,15 +891,20 @@ func (s *SchedulerService) sendScheduledNotification(ctx context.Context, subscr
 		var notificationErrors []string
 
 		// Send Slack notification
-		if err := s.notification.SendSlackWeatherUpdate(weather, users); err != nil {
+		if err := s.notification.SendSlackWeatherUpdate(current, users); err != nil {
 			s.logger.Error().Err(err).Msg("Failed to send Slack daily notification")
 			notificationErrors = append(notificationErrors, fmt.Sprintf("Slack: %v", err))
 		}
 
-		// Send Telegram notification
-		if err := s.notification.SendTelegramWeatherUpdate(weather, &subscription.User); err != nil {
+		// Send Telegram notification with the alerts that fired since the last digest
+		now := time.Now().UTC()
+		activity := s.recentAlertActivity(ctx, subscription, now)
+		note := s.unusualWeatherNote(ctx, &subscription.User, current, now)
+		if err := s.notification.SendTelegramDailyDigest(current, &subscription.User, chatID, activity, note); err != nil {
 			s.logger.Error().Err(err).Msg("Failed to send Telegram daily notification")
 			notificationErrors = append(notificationErrors, fmt.Sprintf("Telegram: %v", err))
+		} else {
+			s.recordDelivery(ctx, subscription.ID, now)
 		}
 
 		// Return error only if all platforms failed
@@ -286,18 +918,137 @@ func (s *SchedulerService) sendScheduledNotification(ctx context.Context, subscr
 
 	case models.SubscriptionWeekly:
 		// Send weekly summary (simplified for now)
-		summary := fmt.Sprintf(`This week's weather overview:
-🌡️ Current temperature: %.1f°C
-💧 Humidity: %d%%
-💨 Wind: %.1f km/h
-🌿 Air quality: AQI %d
+		summary := fmt.Sprintf(`%sThis week's weather overview:
+🌡️ Current temperature: %s
+💧 Humidity: %s
+💨 Wind: %s
+🌿 Air quality: AQI %s
 
-Stay weather-aware!`, weather.Temperature, weather.Humidity, weather.WindSpeed, weather.AQI)
+Stay weather-aware!`,
+			s.weeklyDigestHeader(ctx, &subscription.User),
+			weather.FormatTemp(current.Temperature, UserUnits(&subscription.User)),
+			weather.FormatPercent(float64(current.Humidity)),
+			weather.FormatSpeed(current.WindSpeed, UserUnits(&subscription.User)),
+			weather.FormatAQI(float64(current.AQI)))
 
-		if err := s.notification.SendTelegramWeeklyUpdate(&subscription.User, summary); err != nil {
+		if s.messaging != nil {
+			vars := map[string]string{
+				"location": markdown.EscapeBold(subscription.User.LocationName),
+				"summary":  summary,
+			}
+			// The digest is followed by a short summary in the secondary language
+			language, _ := renderLanguages(&subscription.User)
+			text, err := s.messaging.Render(ctx, language, TemplateWeeklyDigest, vars)
+			if err != nil {
+				return fmt.Errorf("failed to render weekly notification: %w", err)
+			}
+			text += SecondarySummary(&subscription.User, WeatherSummary(s.localization, current, UserUnits(&subscription.User)))
+
+			options := SendOptions{Silent: subscription.User.QuietDigests}
+			if err := s.messaging.Deliver(ctx, chatID, TemplateWeeklyDigest, text, options); err != nil {
+				return fmt.Errorf("failed to send weekly notification: %w", err)
+			}
+			return nil
+		}
+
+		if err := s.notification.SendTelegramWeeklyUpdate(&subscription.User, chatID, summary, current); err != nil {
 			return fmt.Errorf("failed to send weekly notification: %w", err)
 		}
+
+	case models.SubscriptionSunrise:
+		if err := s.notification.SendTelegramSunrise(current, &subscription.User, chatID); err != nil {
+			return fmt.Errorf("failed to send sunrise notification: %w", err)
+		}
+		s.recordDelivery(ctx, subscription.ID, time.Now().UTC())
 	}
 
 	return nil
 }
+
+// deliveryUser returns the subscriber as the notification describes them. A
+// subscription created in a group that has a location set describes it in the
+// group's language and units; the subscriber's own are kept otherwise, and
+// when the group settings cannot be read.
+func (s *SchedulerService) deliveryUser(ctx context.Context, subscription models.Subscription) models.User {
+	user := subscription.User
+	if subscription.ChatID == 0 || s.chats == nil {
+		return user
+	}
+
+	settings, err := s.chats.GetChatSettings(ctx, subscription.ChatID)
+	if err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", subscription.ChatID).Msg("Failed to load chat settings for notification")
+		return user
+	}
+	if settings == nil || !settings.HasLocation() {
+		return user
+	}
+
+	user.LocationName = settings.LocationName
+	user.Latitude = settings.Latitude
+	user.Longitude = settings.Longitude
+	if settings.Language != "" {
+		user.Language = settings.Language
+	}
+	if settings.Units != "" {
+		user.Units = settings.Units
+	}
+	return user
+}
+
+// weeklyDigestHeader returns the range of the current week in the user's
+// convention, followed by a blank line, or nothing without localization
+func (s *SchedulerService) weeklyDigestHeader(ctx context.Context, user *models.User) string {
+	if s.localization == nil {
+		return ""
+	}
+	return weekHeader(ctx, s.localization, user, time.Now().In(userLocation(user))) + "\n\n"
+}
+
+// recentAlertActivity collects the alerts that triggered since the subscription's
+// previous delivery. Failures only drop the section from the digest.
+func (s *SchedulerService) recentAlertActivity(ctx context.Context, subscription models.Subscription, now time.Time) *AlertActivity {
+	if s.alert == nil {
+		return nil
+	}
+
+	since := digestActivitySince(subscription.LastSentAt, now)
+	alerts, err := s.alert.GetTriggeredAlertsSince(ctx, subscription.UserID, since, maxDigestAlerts+1)
+	if err != nil {
+		s.logger.Warn().Err(err).Int64("user_id", subscription.UserID).Msg("Failed to load recent alerts for digest")
+		return nil
+	}
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	activity := &AlertActivity{Alerts: alerts}
+	if len(alerts) > maxDigestAlerts {
+		activity.Alerts = alerts[:maxDigestAlerts]
+		activity.More = true
+	}
+	return activity
+}
+
+// digestActivitySince returns the start of the period a digest reports alerts for:
+// the previous delivery, or the last day when there is none. Old or future
+// delivery times are clamped so one digest never lists weeks of history.
+func digestActivitySince(lastSent *time.Time, now time.Time) time.Time {
+	if lastSent == nil || lastSent.After(now) {
+		return now.Add(-24 * time.Hour)
+	}
+	if earliest := now.Add(-maxDigestActivityWindow); lastSent.Before(earliest) {
+		return earliest
+	}
+	return *lastSent
+}
+
+// recordDelivery stores when a subscription was last delivered
+func (s *SchedulerService) recordDelivery(ctx context.Context, subscriptionID uuid.UUID, at time.Time) {
+	if err := s.db.WithContext(ctx).
+		Model(&models.Subscription{}).
+		Where("id = ?", subscriptionID).
+		Update("last_sent_at", at).Error; err != nil {
+		s.logger.Warn().Err(err).Str("subscription_id", subscriptionID.String()).Msg("Failed to record subscription delivery")
+	}
+}
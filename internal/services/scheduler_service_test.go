@@ -2,13 +2,21 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-redis/redismock/v9"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/valpere/shopogoda/internal/config"
 	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/weather"
 	"github.com/valpere/shopogoda/tests/helpers"
 )
 
@@ -118,6 +126,31 @@ func TestSchedulerService_ShouldSendNotification(t *testing.T) {
 		assert.False(t, service.shouldSendNotification(subscription, wednesdayTime))
 	})
 
+	t.Run("weekly subscription sent on Sundays", func(t *testing.T) {
+		sunday := int(time.Sunday)
+		subscription := models.Subscription{
+			SubscriptionType: models.SubscriptionWeekly,
+			TimeOfDay:        "08:00",
+			SendWeekday:      &sunday,
+		}
+		// January 12, 2025 is a Sunday
+		assert.True(t, service.shouldSendNotification(subscription, time.Date(2025, 1, 12, 8, 0, 0, 0, time.UTC)))
+		assert.False(t, service.shouldSendNotification(subscription, time.Date(2025, 1, 13, 8, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("daily weather switched to weekly", func(t *testing.T) {
+		friday := int(time.Friday)
+		subscription := models.Subscription{
+			SubscriptionType: models.SubscriptionDaily,
+			Frequency:        models.FrequencyWeekly,
+			TimeOfDay:        "08:00",
+			SendWeekday:      &friday,
+		}
+		// January 17, 2025 is a Friday
+		assert.True(t, service.shouldSendNotification(subscription, time.Date(2025, 1, 17, 8, 0, 0, 0, time.UTC)))
+		assert.False(t, service.shouldSendNotification(subscription, userTime))
+	})
+
 	t.Run("alert subscription should not trigger scheduled check", func(t *testing.T) {
 		subscription := models.Subscription{
 			SubscriptionType: models.SubscriptionAlerts,
@@ -151,6 +184,105 @@ func TestSchedulerService_ShouldSendNotification(t *testing.T) {
 	})
 }
 
+func TestSchedulerService_ShouldSendSunrise(t *testing.T) {
+	service := NewSchedulerService(nil, nil, &WeatherService{}, &AlertService{}, &NotificationService{}, helpers.NewSilentTestLogger())
+
+	// The sun rises in Kyiv at 07:56 local time, 05:56 UTC, at midwinter
+	kyiv := time.FixedZone("EET", 2*3600)
+	subscription := models.Subscription{
+		SubscriptionType: models.SubscriptionSunrise,
+		TimeOfDay:        models.SunriseTimeOfDay,
+		User:             models.User{Latitude: 50.4501, Longitude: 30.5234},
+	}
+
+	assert.True(t, service.shouldSendNotification(subscription, time.Date(2024, 12, 21, 7, 59, 0, 0, kyiv)))
+	assert.False(t, service.shouldSendNotification(subscription, time.Date(2024, 12, 21, 7, 50, 0, 0, kyiv)), "before sunrise")
+	assert.False(t, service.shouldSendNotification(subscription, time.Date(2024, 12, 21, 8, 5, 0, 0, kyiv)), "past the window")
+
+	// No sunrise during the polar night
+	subscription.User = models.User{Latitude: 69.6492, Longitude: 18.9553}
+	for hour := 0; hour < 24; hour++ {
+		assert.False(t, service.shouldSendNotification(subscription, time.Date(2024, 12, 21, hour, 0, 0, 0, time.UTC)))
+	}
+}
+
+func TestSubscriptionTimeUTC(t *testing.T) {
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	require.NoError(t, err)
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		timeOfDay string
+		day       time.Time
+		want      time.Time
+	}{
+		{"winter time", "08:00", time.Date(2026, 1, 15, 0, 0, 0, 0, kyiv), time.Date(2026, 1, 15, 6, 0, 0, 0, time.UTC)},
+		{"summer time", "08:00", time.Date(2026, 7, 15, 0, 0, 0, 0, kyiv), time.Date(2026, 7, 15, 5, 0, 0, 0, time.UTC)},
+		{"day clocks spring forward", "08:00", time.Date(2026, 3, 29, 0, 0, 0, 0, kyiv), time.Date(2026, 3, 29, 5, 0, 0, 0, time.UTC)},
+		// 03:00-04:00 does not exist on 29 March; 03:30 is sent at the jump
+		{"time skipped by spring forward", "03:30", time.Date(2026, 3, 29, 0, 0, 0, 0, kyiv), time.Date(2026, 3, 29, 1, 0, 0, 0, time.UTC)},
+		// 03:00-04:00 happens twice on 25 October; the later one counts
+		{"time repeated by fall back", "03:30", time.Date(2026, 10, 25, 0, 0, 0, 0, kyiv), time.Date(2026, 10, 25, 1, 30, 0, 0, time.UTC)},
+		// 01:00-02:00 happens twice on 1 November, first in EDT and then in EST
+		{"time repeated by fall back in the US", "01:30", time.Date(2026, 11, 1, 0, 0, 0, 0, newYork), time.Date(2026, 11, 1, 6, 30, 0, 0, time.UTC)},
+		{"time just before the repeat in the US", "00:59", time.Date(2026, 11, 1, 0, 0, 0, 0, newYork), time.Date(2026, 11, 1, 4, 59, 0, 0, time.UTC)},
+		{"time after the repeat in the US", "02:00", time.Date(2026, 11, 1, 0, 0, 0, 0, newYork), time.Date(2026, 11, 1, 7, 0, 0, 0, time.UTC)},
+		{"UTC", "08:00", time.Date(2026, 3, 29, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 29, 8, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := subscriptionTimeUTC(tt.timeOfDay, tt.day)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err = subscriptionTimeUTC("8am", time.Now())
+	assert.Error(t, err)
+}
+
+func TestSchedulerService_DueSubscriptions(t *testing.T) {
+	service := NewSchedulerService(nil, nil, &WeatherService{}, &AlertService{}, &NotificationService{}, helpers.NewSilentTestLogger())
+
+	daily := func(timezone string) models.Subscription {
+		return models.Subscription{
+			SubscriptionType: models.SubscriptionDaily,
+			TimeOfDay:        "08:00",
+			User:             models.User{Timezone: timezone},
+		}
+	}
+	due := func(subscription models.Subscription, now time.Time) bool {
+		return len(service.dueSubscriptions([]models.Subscription{subscription}, now)) == 1
+	}
+
+	t.Run("fires at the user's local time across spring forward", func(t *testing.T) {
+		subscription := daily("Europe/Kyiv")
+		// Saturday before the change: 08:00 EET is 06:00 UTC
+		assert.True(t, due(subscription, time.Date(2026, 3, 28, 6, 1, 0, 0, time.UTC)))
+		assert.False(t, due(subscription, time.Date(2026, 3, 28, 8, 1, 0, 0, time.UTC)))
+		// Sunday after the change: 08:00 EEST is 05:00 UTC
+		assert.True(t, due(subscription, time.Date(2026, 3, 29, 5, 1, 0, 0, time.UTC)))
+		assert.False(t, due(subscription, time.Date(2026, 3, 29, 6, 1, 0, 0, time.UTC)))
+	})
+
+	t.Run("empty and invalid timezones fall back to UTC", func(t *testing.T) {
+		for _, timezone := range []string{"", "Mars/Olympus_Mons"} {
+			assert.True(t, due(daily(timezone), time.Date(2026, 3, 29, 8, 1, 0, 0, time.UTC)), timezone)
+			assert.False(t, due(daily(timezone), time.Date(2026, 3, 29, 5, 1, 0, 0, time.UTC)), timezone)
+		}
+	})
+
+	t.Run("follows a changed timezone", func(t *testing.T) {
+		now := time.Date(2026, 7, 15, 12, 1, 0, 0, time.UTC)
+		assert.False(t, due(daily("Europe/Kyiv"), now))
+		// 08:00 EDT is 12:00 UTC
+		assert.True(t, due(daily("America/New_York"), now))
+	})
+}
+
 func TestSchedulerService_Stop(t *testing.T) {
 	logger := helpers.NewSilentTestLogger()
 	mockDB := helpers.NewMockDB(t)
@@ -178,6 +310,29 @@ func TestSchedulerService_Stop(t *testing.T) {
 	}
 }
 
+func TestSchedulerService_RefreshSubscriptionGauge(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	service := NewSchedulerService(mockDB.DB, helpers.NewMockRedis().Client, &WeatherService{}, &AlertService{}, &NotificationService{}, helpers.NewSilentTestLogger())
+	metricsCollector, registry := helpers.NewTestMetrics()
+	service.SetMetrics(metricsCollector)
+
+	mockDB.Mock.ExpectQuery(`SELECT subscription_type, COUNT\(\*\) AS count FROM "subscriptions" WHERE is_active = \$1 GROUP BY "subscription_type"`).
+		WithArgs(true).
+		WillReturnRows(sqlmock.NewRows([]string{"subscription_type", "count"}).
+			AddRow(models.SubscriptionDaily, 12).
+			AddRow(models.SubscriptionAlerts, 3))
+
+	service.refreshSubscriptionGauge(context.Background())
+
+	series := helpers.ScrapeMetrics(t, registry)
+	assert.Equal(t, 12.0, series[`active_subscriptions{type="Daily"}`])
+	assert.Equal(t, 3.0, series[`active_subscriptions{type="Alerts"}`])
+	assert.Equal(t, 0.0, series[`active_subscriptions{type="Sunrise"}`])
+	assert.Contains(t, series, `active_subscriptions{type="Sunrise"}`)
+	assert.NoError(t, mockDB.Mock.ExpectationsWereMet())
+}
+
 func TestSchedulerService_NotificationPlatformCount(t *testing.T) {
 	// Verify the constant is correct (Slack + Telegram = 2)
 	assert.Equal(t, 2, NotificationPlatformCount)
@@ -232,6 +387,76 @@ func TestSchedulerService_CheckAndProcessAlerts(t *testing.T) {
 	})
 }
 
+func TestSchedulerService_WeatherAtUserLocation(t *testing.T) {
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	weatherJSON, _ := json.Marshal(weather.WeatherData{Temperature: 14})
+	airJSON, _ := json.Marshal(weather.AirQualityData{AQI: 1})
+
+	t.Run("uses the stored coordinates without geocoding", func(t *testing.T) {
+		rdb, mock := redismock.NewClientMock()
+		service := &SchedulerService{weather: NewWeatherService(&config.WeatherConfig{}, rdb, &logger)}
+		mock.ExpectGet("weather:current:51.51:-0.13").SetVal(string(weatherJSON))
+		mock.ExpectGet("weather:air:51.51:-0.13").SetVal(string(airJSON))
+
+		user := &models.User{LocationName: "Location (51.5074, -0.1278)", Latitude: 51.5074, Longitude: -0.1278}
+		current, err := service.weatherAtUserLocation(ctx, user)
+		require.NoError(t, err)
+		assert.Equal(t, 14.0, current.Temperature)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("geocodes the name when there are no coordinates", func(t *testing.T) {
+		rdb, mock := redismock.NewClientMock()
+		service := &SchedulerService{weather: NewWeatherService(&config.WeatherConfig{}, rdb, &logger)}
+		locationJSON, _ := json.Marshal(weather.Location{Name: "Kyiv", Latitude: 50.45, Longitude: 30.52})
+		mock.ExpectGet("geocode:kyiv").SetVal(string(locationJSON))
+		mock.ExpectGet("weather:current:50.45:30.52").SetVal(string(weatherJSON))
+		mock.ExpectGet("weather:air:50.45:30.52").SetVal(string(airJSON))
+
+		current, err := service.weatherAtUserLocation(ctx, &models.User{LocationName: "Kyiv"})
+		require.NoError(t, err)
+		assert.Equal(t, "Kyiv", current.LocationName)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSchedulerService_DeliveryUser(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	ctx := context.Background()
+	service := &SchedulerService{chats: NewChatSettingsService(mockDB.DB), logger: helpers.NewSilentTestLogger()}
+	user := models.User{ID: 42, LocationName: "Kyiv", Latitude: 50.45, Longitude: 30.52, Language: "en-US", Units: "imperial"}
+
+	t.Run("private subscription keeps the user's settings", func(t *testing.T) {
+		assert.Equal(t, user, service.deliveryUser(ctx, models.Subscription{UserID: 42, User: user}))
+	})
+
+	t.Run("group subscription takes the group's settings", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "chat_locations" WHERE chat_id = \$1`).
+			WithArgs(int64(-100123), 1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"chat_id", "location_name", "latitude", "longitude", "language", "units"}).
+				AddRow(int64(-100123), "Lviv", 49.84, 24.03, "uk-UA", ""))
+
+		got := service.deliveryUser(ctx, models.Subscription{UserID: 42, ChatID: -100123, User: user})
+		assert.Equal(t, "Lviv", got.LocationName)
+		assert.Equal(t, 49.84, got.Latitude)
+		assert.Equal(t, "uk-UA", got.Language)
+		assert.Equal(t, "imperial", got.Units)
+		assert.Equal(t, int64(42), got.ID)
+	})
+
+	t.Run("group without a location keeps the user's settings", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "chat_locations" WHERE chat_id = \$1`).
+			WithArgs(int64(-100456), 1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"chat_id"}))
+
+		assert.Equal(t, user, service.deliveryUser(ctx, models.Subscription{UserID: 42, ChatID: -100456, User: user}))
+	})
+
+	mockDB.ExpectationsWereMet(t)
+}
+
 func TestSchedulerService_ProcessDailyNotifications(t *testing.T) {
 	mockDB := helpers.NewMockDB(t)
 	defer func() { _ = mockDB.Close() }()
@@ -394,3 +619,80 @@ func TestSchedulerService_SendScheduledNotification(t *testing.T) {
 		assert.Contains(t, err.Error(), "failed to get weather")
 	})
 }
+
+func TestDigestActivitySince(t *testing.T) {
+	now := time.Date(2025, 1, 10, 8, 0, 0, 0, time.UTC)
+	recent := now.Add(-30 * time.Hour)
+	stale := now.Add(-30 * 24 * time.Hour)
+	future := now.Add(time.Hour)
+
+	assert.Equal(t, now.Add(-24*time.Hour), digestActivitySince(nil, now), "never delivered")
+	assert.Equal(t, recent, digestActivitySince(&recent, now), "since last delivery")
+	assert.Equal(t, now.Add(-maxDigestActivityWindow), digestActivitySince(&stale, now), "clamped to window")
+	assert.Equal(t, now.Add(-24*time.Hour), digestActivitySince(&future, now), "clock skew")
+}
+
+func TestSchedulerService_ProcessDigestTimeSuggestions(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	now := time.Date(2025, 6, 16, 9, 0, 0, 0, time.UTC)
+
+	newScheduler := func(t *testing.T) (*SchedulerService, *helpers.MockDB, *helpers.MockRedis) {
+		mockDB := helpers.NewMockDB(t)
+		t.Cleanup(func() { _ = mockDB.Close() })
+		mockRedis := helpers.NewMockRedis()
+
+		service := NewSchedulerService(mockDB.DB, mockRedis.Client, nil, nil, nil, logger)
+		service.SetEngagement(NewEngagementService(mockRedis.Client, nil, NewSubscriptionService(mockDB.DB, mockRedis.Client), nil, nil, logger))
+		return service, mockDB, mockRedis
+	}
+	expectRun := func(mockDB *helpers.MockDB) {
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "subscriptions"`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}))
+	}
+
+	t.Run("first run happens right away", func(t *testing.T) {
+		service, mockDB, mockRedis := newScheduler(t)
+		mockRedis.Mock.ExpectGet(digestSuggestionLastRunKey).RedisNil()
+		mockRedis.Mock.ExpectSet(digestSuggestionLastRunKey, now.Unix(), 0).SetVal("OK")
+		expectRun(mockDB)
+
+		service.processDigestTimeSuggestions(context.Background(), now)
+
+		mockRedis.ExpectationsWereMet(t)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("skipped within a week of the last run", func(t *testing.T) {
+		service, mockDB, mockRedis := newScheduler(t)
+		lastRun := now.Add(-digestSuggestionInterval + time.Hour)
+		mockRedis.Mock.ExpectGet(digestSuggestionLastRunKey).SetVal(strconv.FormatInt(lastRun.Unix(), 10))
+
+		service.processDigestTimeSuggestions(context.Background(), now)
+
+		mockRedis.ExpectationsWereMet(t)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("runs again once a week has passed, even after a restart", func(t *testing.T) {
+		service, mockDB, mockRedis := newScheduler(t)
+		lastRun := now.Add(-digestSuggestionInterval)
+		mockRedis.Mock.ExpectGet(digestSuggestionLastRunKey).SetVal(strconv.FormatInt(lastRun.Unix(), 10))
+		mockRedis.Mock.ExpectSet(digestSuggestionLastRunKey, now.Unix(), 0).SetVal("OK")
+		expectRun(mockDB)
+
+		service.processDigestTimeSuggestions(context.Background(), now)
+
+		mockRedis.ExpectationsWereMet(t)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("unknown last run does not risk a duplicate run", func(t *testing.T) {
+		service, mockDB, mockRedis := newScheduler(t)
+		mockRedis.Mock.ExpectGet(digestSuggestionLastRunKey).SetErr(errors.New("redis down"))
+
+		service.processDigestTimeSuggestions(context.Background(), now)
+
+		mockRedis.ExpectationsWereMet(t)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
@@ -5,13 +5,20 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"time"
 
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
 	"gorm.io/gorm"
 
 	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/internal/notifications"
+	"github.com/valpere/shopogoda/internal/ratelimit"
 	"github.com/valpere/shopogoda/pkg/metrics"
 )
 
@@ -31,16 +38,41 @@ import (
 //	user, err := svcs.User.GetUser(ctx, userID)
 //	weather, err := svcs.Weather.GetCurrentWeather(ctx, lat, lon)
 type Services struct {
-	User         *UserService         // User management, locations, timezones, statistics
-	Weather      *WeatherService      // Weather data retrieval and geocoding
-	Alert        *AlertService        // Custom alert configurations and monitoring
-	Subscription *SubscriptionService // Notification subscription management
-	Notification *NotificationService // Dual-platform notification delivery (Telegram + Slack)
-	Scheduler    *SchedulerService    // Background job scheduling for alerts and notifications
-	Export       *ExportService       // Data export for GDPR compliance and backups
-	Localization *LocalizationService // Multi-language translation support
-	Demo         *DemoService         // Demo data management for testing
-	startTime    time.Time            // Application start time for uptime calculation
+	User         *UserService                   // User management, locations, timezones, statistics
+	Weather      *WeatherService                // Weather data retrieval and geocoding
+	Tides        TideService                    // High and low tides of coastal places
+	Alert        *AlertService                  // Custom alert configurations and monitoring
+	Subscription *SubscriptionService           // Notification subscription management
+	Notification *NotificationService           // Dual-platform notification delivery (Telegram + Slack)
+	Scheduler    *SchedulerService              // Background job scheduling for alerts and notifications
+	Export       *ExportService                 // Data export for GDPR compliance and backups
+	Localization *LocalizationService           // Multi-language translation support
+	Demo         *DemoService                   // Demo data management for testing
+	Messaging    *MessagingService              // Template-based user messaging with delivery stats
+	Broadcast    *BroadcastService              // Admin broadcasts sent in the background with progress reports
+	Engagement   *EngagementService             // Activity tracking and digest send-time suggestions
+	Outbound     *OutboundLimiter               // Telegram send pacing with separate bulk and interactive lanes
+	FeatureFlags *FeatureFlagService            // Gradual rollout of features with runtime overrides
+	ChatAccess   *ChatAccessService             // Registry of chats where the bot lost the right to post
+	Widgets      *WidgetService                 // Pinned live weather messages refreshed in place
+	Presets      *PresetService                 // Shareable deep-link bundles of location, subscription and alerts
+	Outbox       *OutboxService                 // Failed scheduled deliveries: retries and dead letters
+	Escalations  *AlertEscalationService        // Follow-ups on unacknowledged extreme alerts
+	QuietDays    *QuietDayService               // User-defined days on which routine alerts are held back
+	Transfers    *AccountTransferService        // Moving a user's configuration to a new Telegram account
+	Labels       *CallbackLabelService          // Labels too long for callback data, referenced by token
+	Today        *TodayService                  // Chat-level "today in <city>" cards
+	ChatSettings *ChatSettingsService           // Location, language and units of groups
+	Feedback     *FeedbackService               // Messages from users to the admins and their resolution
+	Live         *LiveLocationService           // Shared live locations followed for weather changes
+	Records      *WeatherRecordService          // Weather users looked up or were sent, for /history and exports
+	Releases     *ReleaseService                // Checks GitHub releases for a newer version of the bot
+	Onboarding   *OnboardingService             // Guided setup that /start walks new users through
+	Webhooks     *notifications.WebhookNotifier // Per-user URLs that alert triggers are posted to
+	Audit        *AuditService                  // Structured log of admin actions
+	WeatherLimit *ratelimit.RateLimiter         // Per-user cap on weather API requests, shared across instances
+	startTime    time.Time                      // Application start time for uptime calculation
+	db           *gorm.DB                       // Connection that WithTx opens transactions on
 }
 
 // New creates a new Services container with all dependencies initialized.
@@ -70,17 +102,71 @@ func New(db *gorm.DB, redis *redis.Client, cfg *config.Config, logger *zerolog.L
 
 	userService := NewUserService(db, redis, metricsCollector, logger, startTime)
 	weatherService := NewWeatherService(&cfg.Weather, redis, logger)
+	weatherService.SetMetrics(metricsCollector)
+	weatherService.SetHistoryStore(db)
+	userService.SetLocationApproximator(weatherService)
+	auditService := NewAuditService(db)
+	userService.SetAudit(auditService)
+	adminIDs, invalidAdminIDs := ParseAdminIDs(cfg.Bot.AdminIDs)
+	if len(invalidAdminIDs) > 0 {
+		logger.Warn().Strs("entries", invalidAdminIDs).Msg("Ignoring ADMIN_TELEGRAM_IDS entries that are not user IDs")
+	}
+	userService.SetBootstrapAdmins(adminIDs)
+	if err := userService.SetAPIKeySecret(cfg.Weather.UserKeySecret); err != nil {
+		logger.Warn().Err(err).Msg("User API keys are disabled")
+	}
 	alertService := NewAlertService(db, redis)
+	quietDayService := NewQuietDayService(db)
+	alertService.SetQuietDays(quietDayService)
+	alertService.SetPollen(weatherService)
+	alertService.SetTides(weatherService)
+	alertService.SetMaxAlertsPerUser(cfg.MaxAlertsPerUser)
 	subscriptionService := NewSubscriptionService(db, redis)
 	notificationService := NewNotificationService(&cfg.Integrations, logger)
 	schedulerService := NewSchedulerService(db, redis, weatherService, alertService, notificationService, logger)
+	schedulerService.SetDeliveryWindow(cfg.Outbound.DeliveryWindow)
+	schedulerService.SetSubscriptions(subscriptionService)
+	chatSettingsService := NewChatSettingsService(db)
+	schedulerService.SetChatSettings(chatSettingsService)
+	schedulerService.SetMetrics(metricsCollector)
+	outboundLimiter := NewOutboundLimiter(&cfg.Outbound, metricsCollector, logger)
 	localizationService := NewLocalizationService(logger)
+	localizationService.SetOverrideDir(cfg.Templates.Dir)
+	localizationService.SetFooterMessageTypes(ParseFooterMessageTypes(cfg.Templates.Footer))
+	notificationService.SetLocalization(localizationService)
+	schedulerService.SetLocalization(localizationService)
 	exportService := NewExportService(db, logger, localizationService)
+	exportService.SetRedis(redis)
 	demoService := NewDemoService(db, logger)
+	messagingService := NewMessagingService(userService, localizationService, metricsCollector, logger)
+	schedulerService.SetMessaging(messagingService)
+	notificationService.SetMessaging(messagingService)
+	engagementService := NewEngagementService(redis, userService, subscriptionService, messagingService, localizationService, logger)
+	schedulerService.SetEngagement(engagementService)
+	featureFlagService := NewFeatureFlagService(&cfg.Features, db, redis, logger)
+	chatAccessService := NewChatAccessService(redis, userService, localizationService, logger)
+	schedulerService.SetChatAccess(chatAccessService)
+	widgetService := NewWidgetService(db, weatherService, localizationService, logger)
+	schedulerService.SetWidgets(widgetService)
+	presetService := NewPresetService(db, localizationService, logger)
+	outboxService := NewOutboxService(db, metricsCollector, logger, cfg.Outbound.DeadLetterRetention)
+	schedulerService.SetOutbox(outboxService)
+	escalationService := NewAlertEscalationService(db, localizationService, logger)
+	notificationService.SetEscalations(escalationService)
+	schedulerService.SetEscalations(escalationService)
+	broadcastService := NewBroadcastService(userService, messagingService, logger)
+	broadcastService.SetWorkers(cfg.BroadcastWorkers)
+	webhookNotifier := notifications.NewWebhookNotifier(db)
+	schedulerService.SetWebhooks(webhookNotifier)
+	anomalyService := NewAnomalyService(db, redis, localizationService, logger, cfg.Anomaly.Threshold)
+	schedulerService.SetAnomalies(anomalyService)
+	weatherRecordService := NewWeatherRecordService(db, redis, logger, cfg.Weather.RecordRetention)
+	schedulerService.SetWeatherRecords(weatherRecordService)
 
 	return &Services{
 		User:         userService,
 		Weather:      weatherService,
+		Tides:        weatherService,
 		Alert:        alertService,
 		Subscription: subscriptionService,
 		Notification: notificationService,
@@ -88,16 +174,229 @@ func New(db *gorm.DB, redis *redis.Client, cfg *config.Config, logger *zerolog.L
 		Export:       exportService,
 		Localization: localizationService,
 		Demo:         demoService,
+		Messaging:    messagingService,
+		Broadcast:    broadcastService,
+		Engagement:   engagementService,
+		Outbound:     outboundLimiter,
+		FeatureFlags: featureFlagService,
+		ChatAccess:   chatAccessService,
+		Widgets:      widgetService,
+		Presets:      presetService,
+		Outbox:       outboxService,
+		Escalations:  escalationService,
+		QuietDays:    quietDayService,
+		Transfers:    NewAccountTransferService(db, logger),
+		Labels:       NewCallbackLabelService(redis),
+		Today:        NewTodayService(db, redis, weatherService, localizationService, logger),
+		ChatSettings: chatSettingsService,
+		Feedback:     NewFeedbackService(db, redis),
+		Live:         NewLiveLocationService(redis, weatherService, logger),
+		Records:      weatherRecordService,
+		Releases:     NewReleaseService(redis, logger),
+		Onboarding:   NewOnboardingService(redis, userService),
+		Webhooks:     webhookNotifier,
+		Audit:        auditService,
+		WeatherLimit: ratelimit.New(redis, "weather", cfg.RateLimit.WeatherPerMinute),
 		startTime:    startTime,
+		db:           db,
+	}
+}
+
+// Features returns the feature flags evaluated for a user. Handlers call it
+// once per update and check individual flags with Enabled.
+//
+// Example:
+//
+//	if svcs.Features(ctx, userID).Enabled(services.FeatureCharts) {
+//		// show the chart button
+//	}
+func (s *Services) Features(ctx context.Context, userID int64) *Features {
+	if s.FeatureFlags == nil {
+		return nil
+	}
+	return s.FeatureFlags.ForUser(ctx, userID)
+}
+
+// Migrate brings the database schema up to date with the models
+func (s *Services) Migrate(ctx context.Context) error {
+	return models.Migrate(s.db.WithContext(ctx))
+}
+
+// txScope collects work that must wait until a transaction has committed
+type txScope struct {
+	pending []func(ctx context.Context)
+}
+
+func (t *txScope) afterCommit(fn func(ctx context.Context)) {
+	t.pending = append(t.pending, fn)
+}
+
+// WithTx runs fn as one unit of work. The User, Alert, Subscription, Presets
+// and Transfers services passed to fn share a single database transaction; when fn returns an error
+// every write is rolled back, otherwise the transaction commits and only then
+// are user cache entries invalidated. Other services are shared unchanged.
+//
+// Example:
+//
+//	err := svcs.WithTx(ctx, func(tx *services.Services) error {
+//		if err := tx.User.SetUserLocation(ctx, userID, name, country, city, lat, lon); err != nil {
+//			return err
+//		}
+//		_, err := tx.Subscription.CreateSubscription(ctx, userID, models.SubscriptionDaily, models.FrequencyDaily, "08:00")
+//		return err
+//	})
+func (s *Services) WithTx(ctx context.Context, fn func(tx *Services) error) error {
+	scope := &txScope{}
+	err := s.db.WithContext(ctx).Transaction(func(db *gorm.DB) error {
+		scoped := *s
+		scoped.User = s.User.withTx(db, scope)
+		scoped.Alert = s.Alert.withTx(db)
+		scoped.Subscription = s.Subscription.withTx(db)
+		scoped.Presets = s.Presets.withTx(db)
+		scoped.Transfers = s.Transfers.withTx(db)
+		return fn(&scoped)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, fn := range scope.pending {
+		fn(ctx)
+	}
+	return nil
+}
+
+// ApplyPreset applies the preset behind a deep link token to the user's
+// account: it sets the location, ensures the subscription and creates the
+// alerts in one transaction. Applying a preset a second time changes nothing
+// and reports false. ErrPresetNotFound and ErrPresetRevoked are returned for
+// tokens that cannot be applied.
+func (s *Services) ApplyPreset(ctx context.Context, token string, userID int64) (bool, error) {
+	preset, err := s.Presets.GetByToken(ctx, token)
+	if err != nil {
+		return false, err
+	}
+
+	var applied bool
+	err = s.WithTx(ctx, func(tx *Services) error {
+		applied, err = tx.Presets.apply(ctx, tx, preset, userID)
+		return err
+	})
+	return applied, err
+}
+
+// CompleteTransfer moves the configuration of an account transfer that both
+// ends have confirmed, deactivates the old account and marks the transfer
+// completed, all in one transaction. ErrTransferNotFound is returned when the
+// transfer is not ready, ErrTransferExpired when its code has expired.
+func (s *Services) CompleteTransfer(ctx context.Context, id uuid.UUID) (*models.AccountTransfer, error) {
+	var transfer *models.AccountTransfer
+	err := s.WithTx(ctx, func(tx *Services) error {
+		var err error
+		transfer, err = tx.Transfers.complete(ctx, id)
+		if err != nil {
+			return err
+		}
+		for _, userID := range []int64{transfer.FromUserID, transfer.ToUserID} {
+			if err := tx.User.invalidateCache(ctx, fmt.Sprintf("user:%d", userID)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+
+	s.Transfers.logCompleted(transfer)
+	return transfer, nil
+}
+
+// RecommendedDigestTime is when the daily digest set up by CompleteOnboarding is sent
+const RecommendedDigestTime = "08:00"
+
+// RecommendedAlerts are the alerts created by CompleteOnboarding and
+// CreateRecommendedAlerts, with values in metric units
+var RecommendedAlerts = []PresetAlert{
+	{Type: models.AlertTemperature, Operator: "gt", Value: 30},
+	{Type: models.AlertTemperature, Operator: "lt", Value: 0},
+	{Type: models.AlertWindSpeed, Operator: "gt", Value: 50},
+	{Type: models.AlertAirQuality, Operator: "gt", Value: 150},
+}
+
+// CompleteOnboarding finishes the setup of a new user in one transaction: it
+// registers the user, subscribes them to the daily digest and creates the
+// recommended alerts. When any step fails nothing is kept, so the user can
+// simply try again.
+func (s *Services) CompleteOnboarding(ctx context.Context, tgUser *gotgbot.User) error {
+	return s.WithTx(ctx, func(tx *Services) error {
+		if err := tx.User.RegisterUser(ctx, tgUser); err != nil {
+			return err
+		}
+		if _, err := tx.Subscription.EnsureSubscription(ctx, tgUser.Id, models.SubscriptionDaily, models.FrequencyDaily, RecommendedDigestTime); err != nil {
+			return err
+		}
+		if _, err := tx.createRecommendedAlerts(ctx, tgUser.Id); err != nil {
+			return err
+		}
+		return tx.User.invalidateCache(ctx, fmt.Sprintf("user:%d", tgUser.Id))
+	})
+}
+
+// CreateRecommendedAlerts creates the recommended alerts the user does not
+// have yet, all or none, and returns how many were created
+func (s *Services) CreateRecommendedAlerts(ctx context.Context, userID int64) (int, error) {
+	var created int
+	err := s.WithTx(ctx, func(tx *Services) error {
+		var err error
+		created, err = tx.createRecommendedAlerts(ctx, userID)
+		return err
+	})
+	return created, err
+}
+
+func (s *Services) createRecommendedAlerts(ctx context.Context, userID int64) (int, error) {
+	existing, err := s.Alert.GetUserAlerts(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	have := make(map[PresetAlert]bool, len(existing))
+	for _, alert := range existing {
+		var condition AlertCondition
+		if err := json.Unmarshal([]byte(alert.Condition), &condition); err != nil {
+			continue
+		}
+		have[PresetAlert{Type: alert.AlertType, Operator: condition.Operator, Value: condition.Value}] = true
+	}
+
+	created := 0
+	for _, alert := range RecommendedAlerts {
+		if have[alert] {
+			continue
+		}
+		if _, err := s.Alert.CreateAlert(ctx, userID, alert.Type, AlertCondition{Operator: alert.Operator, Value: alert.Value}); err != nil {
+			return 0, err
+		}
+		created++
+	}
+	return created, nil
 }
 
 // StartScheduler starts the background scheduler service for processing
 // alerts and scheduled notifications.
 //
-// The scheduler runs two concurrent jobs:
+// The scheduler runs six concurrent jobs, next to the weather history
+// recorder:
 //  1. Alert processing - Every 10 minutes
-//  2. Scheduled notifications - Every hour (timezone-aware)
+//  2. Scheduled notifications and retries of failed ones - Every hour (timezone-aware)
+//  3. Digest send-time suggestions - Every week, checked hourly against the last run
+//  4. Live weather widget refresh - Every 15 minutes (timezone-aware)
+//  5. Dead letter purge - Every day
+//  6. Follow-ups on unacknowledged extreme alerts - Every minute
+//
+// The weather history recorder saves the weather at users' locations every
+// hour by default (WEATHER_HISTORY_INTERVAL) and stops when ctx is cancelled.
 //
 // Parameters:
 //   - ctx: Context for cancellation and lifecycle management
@@ -107,6 +406,7 @@ func New(db *gorm.DB, redis *redis.Client, cfg *config.Config, logger *zerolog.L
 //	ctx := context.Background()
 //	svcs.StartScheduler(ctx)
 func (s *Services) StartScheduler(ctx context.Context) {
+	go s.Weather.RunHistoryRecorder(ctx)
 	s.Scheduler.Start(ctx)
 }
 
@@ -118,4 +418,7 @@ func (s *Services) StartScheduler(ctx context.Context) {
 //	defer svcs.Stop()
 func (s *Services) Stop() {
 	s.Scheduler.Stop()
+	if s.Broadcast != nil {
+		s.Broadcast.Stop()
+	}
 }
@@ -2,11 +2,22 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/internal/models"
 	"github.com/valpere/shopogoda/pkg/metrics"
 	"github.com/valpere/shopogoda/tests/helpers"
 )
@@ -97,3 +108,285 @@ func TestServices_Stop(t *testing.T) {
 		services.Stop()
 	})
 }
+
+// redisRecorder answers every Redis command without a server and records it
+type redisRecorder struct {
+	mu       sync.Mutex
+	commands []string
+}
+
+func newRecordingRedis() (*redis.Client, *redisRecorder) {
+	recorder := &redisRecorder{}
+	client := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+	client.AddHook(recorder)
+	return client, recorder
+}
+
+func (r *redisRecorder) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (r *redisRecorder) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.commands = append(r.commands, strings.TrimSpace(fmt.Sprintln(cmd.Args()...)))
+		return nil
+	}
+}
+
+func (r *redisRecorder) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestServices_WithTx(t *testing.T) {
+	const userID = int64(123)
+	errStep := errors.New("step failed")
+	tgUser := &gotgbot.User{Id: userID, Username: "hiker", FirstName: "Test", LanguageCode: "uk"}
+
+	// setup is a composite flow of the kind onboarding performs: register the
+	// user, save a location, subscribe and add an alert
+	setup := func(ctx context.Context, tx *Services) error {
+		if err := tx.User.RegisterUser(ctx, tgUser); err != nil {
+			return err
+		}
+		if err := tx.User.SetUserLocation(ctx, userID, "Kyiv", "UA", "Kyiv", 50.45, 30.52); err != nil {
+			return err
+		}
+		if _, err := tx.Subscription.CreateSubscription(ctx, userID, models.SubscriptionDaily, models.FrequencyDaily, "08:00"); err != nil {
+			return err
+		}
+		_, err := tx.Alert.CreateAlert(ctx, userID, models.AlertTemperature, AlertCondition{Operator: "gt", Value: 30})
+		return err
+	}
+
+	type step struct {
+		statement string
+		exec      bool // UPDATE statements return a result rather than rows
+		id        any  // Primary key returned by an INSERT
+	}
+	steps := []step{
+		{statement: `INSERT INTO "users"`, id: userID},
+		{statement: `UPDATE "users" SET`, exec: true},
+		{statement: `INSERT INTO "subscriptions"`, id: uuid.New()},
+		{statement: `SELECT "id" FROM "users" WHERE id = \$1 .*FOR UPDATE`, id: userID},
+		{statement: `SELECT count\(\*\) FROM "alert_configs"`, id: 0},
+		{statement: `INSERT INTO "alert_configs"`, id: uuid.New()},
+	}
+
+	newServices := func(t *testing.T) (*Services, *helpers.MockDB, *redisRecorder) {
+		mockDB := helpers.NewMockDB(t)
+		t.Cleanup(func() { _ = mockDB.Close() })
+		redisClient, recorder := newRecordingRedis()
+		logger := helpers.NewSilentTestLogger()
+
+		svcs := &Services{
+			User:         NewUserService(mockDB.DB, redisClient, metrics.New(), logger, time.Now()),
+			Alert:        NewAlertService(mockDB.DB, redisClient),
+			Subscription: NewSubscriptionService(mockDB.DB, redisClient),
+			Presets:      NewPresetService(mockDB.DB, nil, logger),
+			Transfers:    NewAccountTransferService(mockDB.DB, logger),
+			db:           mockDB.DB,
+		}
+		return svcs, mockDB, recorder
+	}
+
+	// expectSteps expects the statements of the flow up to failAt, which fails
+	expectSteps := func(mockDB *helpers.MockDB, failAt int) {
+		for i, step := range steps {
+			switch {
+			case step.exec && i == failAt:
+				mockDB.Mock.ExpectExec(step.statement).WillReturnError(errStep)
+			case step.exec:
+				mockDB.Mock.ExpectExec(step.statement).WillReturnResult(helpers.NewResult(0, 1))
+			case i == failAt:
+				mockDB.Mock.ExpectQuery(step.statement).WillReturnError(errStep)
+			default:
+				mockDB.Mock.ExpectQuery(step.statement).WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(step.id))
+			}
+			if i == failAt {
+				return
+			}
+		}
+	}
+
+	t.Run("commits every step and invalidates the cache afterwards", func(t *testing.T) {
+		svcs, mockDB, recorder := newServices(t)
+
+		mockDB.Mock.ExpectBegin()
+		expectSteps(mockDB, len(steps))
+		mockDB.Mock.ExpectCommit()
+
+		err := svcs.WithTx(context.Background(), func(tx *Services) error {
+			return setup(context.Background(), tx)
+		})
+
+		require.NoError(t, err)
+		mockDB.ExpectationsWereMet(t)
+		assert.Equal(t, []string{"del user:123"}, recorder.commands)
+	})
+
+	for failAt, step := range steps {
+		t.Run("rolls back when "+step.statement+" fails", func(t *testing.T) {
+			svcs, mockDB, recorder := newServices(t)
+
+			mockDB.Mock.ExpectBegin()
+			expectSteps(mockDB, failAt)
+			mockDB.Mock.ExpectRollback()
+
+			err := svcs.WithTx(context.Background(), func(tx *Services) error {
+				return setup(context.Background(), tx)
+			})
+
+			assert.ErrorIs(t, err, errStep)
+			mockDB.ExpectationsWereMet(t)
+			assert.Empty(t, recorder.commands, "cache must not change when the transaction rolls back")
+		})
+	}
+
+	t.Run("reads inside the transaction bypass the cache", func(t *testing.T) {
+		svcs, mockDB, recorder := newServices(t)
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users"`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id", "language"}).AddRow(userID, "uk-UA"))
+		mockDB.Mock.ExpectCommit()
+
+		err := svcs.WithTx(context.Background(), func(tx *Services) error {
+			user, err := tx.User.GetUser(context.Background(), userID)
+			if err == nil {
+				assert.Equal(t, "uk-UA", user.Language)
+			}
+			return err
+		})
+
+		require.NoError(t, err)
+		mockDB.ExpectationsWereMet(t)
+		assert.Empty(t, recorder.commands)
+	})
+}
+
+func TestServices_CompleteOnboarding(t *testing.T) {
+	const userID = int64(123)
+	errStep := errors.New("step failed")
+	tgUser := &gotgbot.User{Id: userID, Username: "hiker", FirstName: "Test", LanguageCode: "uk"}
+
+	type step struct {
+		name  string
+		query string
+		rows  func(mockDB *helpers.MockDB) *sqlmock.Rows
+	}
+	idRow := func(id any) func(mockDB *helpers.MockDB) *sqlmock.Rows {
+		return func(mockDB *helpers.MockDB) *sqlmock.Rows {
+			return mockDB.Mock.NewRows([]string{"id"}).AddRow(id)
+		}
+	}
+	lockUser := `SELECT "id" FROM "users" WHERE id = \$1 .*FOR UPDATE`
+	countAlerts := `SELECT count\(\*\) FROM "alert_configs" WHERE user_id = \$1 AND is_active = \$2`
+	countRow := func(count int) func(mockDB *helpers.MockDB) *sqlmock.Rows {
+		return func(mockDB *helpers.MockDB) *sqlmock.Rows {
+			return mockDB.Mock.NewRows([]string{"count"}).AddRow(count)
+		}
+	}
+	steps := []step{
+		{name: "register", query: `INSERT INTO "users"`, rows: idRow(userID)},
+		{name: "find subscription", query: `SELECT \* FROM "subscriptions"`, rows: func(mockDB *helpers.MockDB) *sqlmock.Rows {
+			return mockDB.Mock.NewRows([]string{"id"})
+		}},
+		{name: "subscribe", query: `INSERT INTO "subscriptions"`, rows: idRow(uuid.New())},
+		{name: "list alerts", query: `SELECT \* FROM "alert_configs"`, rows: func(mockDB *helpers.MockDB) *sqlmock.Rows {
+			// The user already has the frost alert, so it is not created again
+			return mockDB.Mock.NewRows([]string{"id", "user_id", "alert_type", "condition"}).
+				AddRow(uuid.New(), userID, models.AlertTemperature, `{"operator":"lt","value":0}`)
+		}},
+		{name: "lock alerts for heat", query: lockUser, rows: idRow(userID)},
+		{name: "count alerts for heat", query: countAlerts, rows: countRow(1)},
+		{name: "heat alert", query: `INSERT INTO "alert_configs"`, rows: idRow(uuid.New())},
+		{name: "lock alerts for wind", query: lockUser, rows: idRow(userID)},
+		{name: "count alerts for wind", query: countAlerts, rows: countRow(2)},
+		{name: "wind alert", query: `INSERT INTO "alert_configs"`, rows: idRow(uuid.New())},
+		{name: "lock alerts for air", query: lockUser, rows: idRow(userID)},
+		{name: "count alerts for air", query: countAlerts, rows: countRow(3)},
+		{name: "air alert", query: `INSERT INTO "alert_configs"`, rows: idRow(uuid.New())},
+	}
+
+	newServices := func(t *testing.T) (*Services, *helpers.MockDB, *redisRecorder) {
+		mockDB := helpers.NewMockDB(t)
+		t.Cleanup(func() { _ = mockDB.Close() })
+		redisClient, recorder := newRecordingRedis()
+		logger := helpers.NewSilentTestLogger()
+
+		svcs := &Services{
+			User:         NewUserService(mockDB.DB, redisClient, metrics.New(), logger, time.Now()),
+			Alert:        NewAlertService(mockDB.DB, redisClient),
+			Subscription: NewSubscriptionService(mockDB.DB, redisClient),
+			Presets:      NewPresetService(mockDB.DB, nil, logger),
+			Transfers:    NewAccountTransferService(mockDB.DB, logger),
+			db:           mockDB.DB,
+		}
+		return svcs, mockDB, recorder
+	}
+
+	expectSteps := func(mockDB *helpers.MockDB, failAt int) {
+		for i, step := range steps {
+			if i == failAt {
+				mockDB.Mock.ExpectQuery(step.query).WillReturnError(errStep)
+				return
+			}
+			mockDB.Mock.ExpectQuery(step.query).WillReturnRows(step.rows(mockDB))
+		}
+	}
+
+	t.Run("sets up everything and refreshes the cache after commit", func(t *testing.T) {
+		svcs, mockDB, recorder := newServices(t)
+
+		mockDB.Mock.ExpectBegin()
+		expectSteps(mockDB, len(steps))
+		mockDB.Mock.ExpectCommit()
+
+		require.NoError(t, svcs.CompleteOnboarding(context.Background(), tgUser))
+		mockDB.ExpectationsWereMet(t)
+		assert.Equal(t, []string{"del user:123"}, recorder.commands)
+	})
+
+	for failAt, step := range steps {
+		t.Run("rolls back when "+step.name+" fails", func(t *testing.T) {
+			svcs, mockDB, recorder := newServices(t)
+
+			mockDB.Mock.ExpectBegin()
+			expectSteps(mockDB, failAt)
+			mockDB.Mock.ExpectRollback()
+
+			err := svcs.CompleteOnboarding(context.Background(), tgUser)
+
+			assert.ErrorIs(t, err, errStep)
+			mockDB.ExpectationsWereMet(t)
+			assert.Empty(t, recorder.commands, "cache must not change when the transaction rolls back")
+		})
+	}
+
+	t.Run("recommended alerts roll back together", func(t *testing.T) {
+		svcs, mockDB, recorder := newServices(t)
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "alert_configs"`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}))
+		mockDB.Mock.ExpectQuery(lockUser).WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(userID))
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "alert_configs"`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(0))
+		mockDB.Mock.ExpectQuery(`INSERT INTO "alert_configs"`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mockDB.Mock.ExpectQuery(lockUser).WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(userID))
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "alert_configs"`).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(1))
+		mockDB.Mock.ExpectQuery(`INSERT INTO "alert_configs"`).WillReturnError(errStep)
+		mockDB.Mock.ExpectRollback()
+
+		created, err := svcs.CreateRecommendedAlerts(context.Background(), userID)
+
+		assert.ErrorIs(t, err, errStep)
+		assert.Zero(t, created)
+		mockDB.ExpectationsWereMet(t)
+		assert.Empty(t, recorder.commands)
+	})
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+
+	"github.com/valpere/shopogoda/internal"
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+// shareCardImageKeyPrefix keys rendered share card images by place,
+// language, units and hour
+const shareCardImageKeyPrefix = "share_card:"
+
+// ShareCard is the weather card a user forwards to friends. It holds the
+// public weather of a place only: never the user's notes, alerts or settings,
+// so it reads the same in whatever chat it ends up in.
+type ShareCard struct {
+	Location string
+	Current  *WeatherData
+	Forecast *weather.DailyForecast // Nil when the forecast is unavailable
+}
+
+// ComposeShareCard gathers the current weather and today's forecast for a
+// location, with the location named and the weather described in the given
+// language
+func (s *WeatherService) ComposeShareCard(ctx context.Context, locationName, language string) (*ShareCard, error) {
+	ctx = weather.WithLanguage(ctx, language)
+	current, err := s.GetCurrentWeatherByLocation(ctx, locationName)
+	if err != nil {
+		return nil, err
+	}
+
+	card := &ShareCard{Location: current.LocationName, Current: current}
+	if current.Location == nil {
+		return card, nil
+	}
+	card.Location = s.GetLocalizedLocationName(current.Location, language)
+
+	forecast, err := s.GetForecast(ctx, current.Location.Latitude, current.Location.Longitude, 1)
+	switch {
+	case err != nil:
+		s.logger.Warn().Err(err).Str("location", locationName).Msg("Share card without forecast")
+	case len(forecast.Forecasts) > 0:
+		card.Forecast = &forecast.Forecasts[0]
+	}
+	return card, nil
+}
+
+// RenderShareCard formats the card in the given language and units
+func RenderShareCard(ctx context.Context, localization *LocalizationService, language, units string, card *ShareCard) string {
+	if language == "" {
+		language = internal.DefaultLanguage
+	}
+	if units == "" {
+		units = weather.UnitsMetric
+	}
+	t := func(key string, args ...any) string {
+		return localization.T(ctx, language, key, args...)
+	}
+	current := card.Current
+
+	lines := []string{
+		t("share_title", markdown.EscapeBold(card.Location)),
+		t("share_now", current.Icon, current.Description,
+			weather.FormatTemp(current.Temperature, units), weather.FormatTemp(current.FeelsLike, units)),
+	}
+	if card.Forecast != nil {
+		lines = append(lines, t("share_forecast",
+			weather.FormatTemp(card.Forecast.MinTemp, units), weather.FormatTemp(card.Forecast.MaxTemp, units),
+			weather.FormatPercent(card.Forecast.PrecipitationChance*100)))
+	}
+	lines = append(lines, "", t("share_attribution"), t("share_footer"))
+
+	return strings.Join(lines, "\n")
+}
+
+// ShareCardKeyboard is the only button under a share card: the deep link that
+// opens the bot on the reader's own today card
+func ShareCardKeyboard(ctx context.Context, localization *LocalizationService, language, botUsername string) [][]gotgbot.InlineKeyboardButton {
+	return [][]gotgbot.InlineKeyboardButton{{{
+		Text: localization.T(ctx, language, "share_button_open"),
+		Url:  TodayShareLink(botUsername),
+	}}}
+}
+
+// CachedShareCardImage returns the image of the card, drawn by render at most
+// once in weatherMapTTL for the same place, language and units within an hour
+func (s *WeatherService) CachedShareCardImage(ctx context.Context, card *ShareCard, language, units string, now time.Time, render func() ([]byte, error)) ([]byte, error) {
+	cacheKey := fmt.Sprintf("%s%s:%s:%s:%s", shareCardImageKeyPrefix,
+		NormalizeGeocodeQuery(card.Location), language, units, now.UTC().Format("2006010215"))
+	if cached, err := s.redis.Get(ctx, cacheKey).Bytes(); err == nil {
+		return cached, nil
+	}
+
+	image, err := render()
+	if err != nil {
+		return nil, fmt.Errorf("failed to render share card: %w", err)
+	}
+
+	if err := s.redis.Set(ctx, cacheKey, image, weatherMapTTL).Err(); err != nil {
+		s.logger.Warn().Err(err).Str("cache_key", cacheKey).Msg("Failed to cache share card image")
+	}
+	return image, nil
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/internal/locales"
+	"github.com/valpere/shopogoda/pkg/weather"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestRenderShareCard(t *testing.T) {
+	localization := NewLocalizationService(helpers.NewSilentTestLogger())
+	require.NoError(t, localization.LoadTranslations(locales.LocalesFS))
+	ctx := context.Background()
+
+	// The card only ever sees public weather for the place; fields that are
+	// personal or belong to the detailed message are left out
+	card := &ShareCard{
+		Location: "Kyiv",
+		Current: &WeatherData{
+			Temperature: 14.6, FeelsLike: 13.9, Icon: "⛅", Description: "broken clouds",
+			Humidity: 71, AQI: 42, LocationName: "Kyiv, UA",
+		},
+		Forecast: &weather.DailyForecast{MinTemp: 8.1, MaxTemp: 17.4, PrecipitationChance: 0.6},
+	}
+
+	expected := "📍 *Kyiv*\n" +
+		"⛅ broken clouds, 14.6°C (feels like 13.9°C)\n" +
+		"📅 Today: 8.1°C to 17.4°C, 60% chance of precipitation\n\n" +
+		"Weather data: OpenWeather\n" +
+		"_via ShoPogoda_"
+	text := RenderShareCard(ctx, localization, "en-US", "", card)
+	assert.Equal(t, expected, text)
+
+	for _, personal := range []string{"Alert", "alert", "Updated", "Kyiv, UA", "📜"} {
+		assert.NotContains(t, text, personal)
+	}
+
+	t.Run("without a forecast", func(t *testing.T) {
+		bare := &ShareCard{Location: "Kyiv", Current: card.Current}
+		assert.Equal(t, "📍 *Kyiv*\n⛅ broken clouds, 14.6°C (feels like 13.9°C)\n\nWeather data: OpenWeather\n_via ShoPogoda_",
+			RenderShareCard(ctx, localization, "en-US", "", bare))
+	})
+
+	t.Run("the only button is the deep link", func(t *testing.T) {
+		keyboard := ShareCardKeyboard(ctx, localization, "en-US", "shopogoda_bot")
+		require.Len(t, keyboard, 1)
+		require.Len(t, keyboard[0], 1)
+		assert.Equal(t, "https://t.me/shopogoda_bot?start=today", keyboard[0][0].Url)
+		assert.Empty(t, keyboard[0][0].CallbackData)
+	})
+}
+
+func TestWeatherService_CachedShareCardImage(t *testing.T) {
+	mockRedis := helpers.NewMockRedis()
+	service := NewWeatherService(&config.WeatherConfig{}, mockRedis.Client, helpers.NewSilentTestLogger())
+	ctx := context.Background()
+	card := &ShareCard{Location: "Ivano-Frankivsk"}
+	now := time.Date(2025, 11, 3, 14, 25, 0, 0, time.FixedZone("EET", 2*3600))
+
+	renders := 0
+	render := func() ([]byte, error) {
+		renders++
+		return []byte("png"), nil
+	}
+
+	mockRedis.Mock.ExpectGet("share_card:ivano frankivsk:uk-UA:metric:2025110312").RedisNil()
+	mockRedis.Mock.ExpectSet("share_card:ivano frankivsk:uk-UA:metric:2025110312", []byte("png"), weatherMapTTL).SetVal("OK")
+	image, err := service.CachedShareCardImage(ctx, card, "uk-UA", weather.UnitsMetric, now, render)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("png"), image)
+
+	mockRedis.Mock.ExpectGet("share_card:ivano frankivsk:uk-UA:metric:2025110312").SetVal("png")
+	image, err = service.CachedShareCardImage(ctx, card, "uk-UA", weather.UnitsMetric, now, render)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("png"), image)
+
+	assert.Equal(t, 1, renders, "a cached card is not drawn again")
+	mockRedis.ExpectationsWereMet(t)
+}
@@ -2,6 +2,9 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,6 +14,21 @@ import (
 	"github.com/valpere/shopogoda/internal/models"
 )
 
+// ErrInvalidTimeOfDay is returned for a notification time that is not a
+// 24-hour HH:MM clock time
+var ErrInvalidTimeOfDay = errors.New("time of day must be HH:MM")
+
+var timeOfDayPattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+// ValidateTimeOfDay checks that a notification time is a 24-hour HH:MM clock
+// time, such as "08:00" or "22:30"
+func ValidateTimeOfDay(timeOfDay string) error {
+	if !timeOfDayPattern.MatchString(timeOfDay) {
+		return fmt.Errorf("%w: %q", ErrInvalidTimeOfDay, timeOfDay)
+	}
+	return nil
+}
+
 type SubscriptionService struct {
 	db    *gorm.DB
 	redis *redis.Client
@@ -23,7 +41,21 @@ func NewSubscriptionService(db *gorm.DB, redis *redis.Client) *SubscriptionServi
 	}
 }
 
+// withTx returns a copy of the service that writes through the transaction db
+func (s *SubscriptionService) withTx(db *gorm.DB) *SubscriptionService {
+	scoped := *s
+	scoped.db = db
+	return &scoped
+}
+
 func (s *SubscriptionService) CreateSubscription(ctx context.Context, userID int64, subType models.SubscriptionType, frequency models.Frequency, timeOfDay string) (*models.Subscription, error) {
+	if subType == models.SubscriptionSunrise {
+		// Sunrise subscriptions follow the sun, whatever time was asked for
+		timeOfDay = models.SunriseTimeOfDay
+	} else if err := ValidateTimeOfDay(timeOfDay); err != nil {
+		return nil, err
+	}
+
 	subscription := &models.Subscription{
 		UserID:           userID,
 		SubscriptionType: subType,
@@ -39,6 +71,97 @@ func (s *SubscriptionService) CreateSubscription(ctx context.Context, userID int
 	return subscription, nil
 }
 
+// CreateWeeklySubscription subscribes the user to the weekly digest, delivered
+// on the first day of the user's week
+func (s *SubscriptionService) CreateWeeklySubscription(ctx context.Context, user *models.User, timeOfDay string) (*models.Subscription, error) {
+	if err := ValidateTimeOfDay(timeOfDay); err != nil {
+		return nil, err
+	}
+
+	sendWeekday := int(FirstDayOfWeek(user))
+	subscription := &models.Subscription{
+		UserID:           user.ID,
+		SubscriptionType: models.SubscriptionWeekly,
+		Frequency:        models.FrequencyWeekly,
+		TimeOfDay:        timeOfDay,
+		SendWeekday:      &sendWeekday,
+		IsActive:         true,
+	}
+
+	if err := s.db.WithContext(ctx).Create(subscription).Error; err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+// HasActiveSubscription reports whether the user has an active subscription of the given type
+func (s *SubscriptionService) HasActiveSubscription(ctx context.Context, userID int64, subType models.SubscriptionType) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).
+		Model(&models.Subscription{}).
+		Where("user_id = ? AND subscription_type = ? AND is_active = ?", userID, subType, true).
+		Count(&count).Error
+
+	return count > 0, err
+}
+
+// ImmediateAlertDelivery reports whether the user's triggered alerts are sent
+// as they happen. Users who take a daily digest but have no alerts subscription
+// get routine alerts in the digest only.
+func (s *SubscriptionService) ImmediateAlertDelivery(ctx context.Context, userID int64) (bool, error) {
+	var active []models.SubscriptionType
+	err := s.db.WithContext(ctx).
+		Model(&models.Subscription{}).
+		Where("user_id = ? AND is_active = ?", userID, true).
+		Pluck("subscription_type", &active).Error
+	if err != nil {
+		return true, err
+	}
+
+	return immediateAlertDelivery(active), nil
+}
+
+// immediateAlertDelivery decides alert delivery from the user's active
+// subscription types. Without a daily digest there is nowhere else to report
+// alerts, so they are always sent immediately.
+func immediateAlertDelivery(active []models.SubscriptionType) bool {
+	digest := false
+	for _, subType := range active {
+		switch subType {
+		case models.SubscriptionAlerts, models.SubscriptionExtreme:
+			return true
+		case models.SubscriptionDaily:
+			digest = true
+		}
+	}
+	return !digest
+}
+
+// EnsureSubscription reactivates the user's latest subscription of the given type,
+// or creates one when the user never had it
+func (s *SubscriptionService) EnsureSubscription(ctx context.Context, userID int64, subType models.SubscriptionType, frequency models.Frequency, timeOfDay string) (*models.Subscription, error) {
+	var subscription models.Subscription
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND subscription_type = ?", userID, subType).
+		Order("created_at DESC").
+		First(&subscription).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.CreateSubscription(ctx, userID, subType, frequency, timeOfDay)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !subscription.IsActive {
+		if err := s.db.WithContext(ctx).Model(&subscription).Update("is_active", true).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return &subscription, nil
+}
+
 func (s *SubscriptionService) GetUserSubscriptions(ctx context.Context, userID int64) ([]models.Subscription, error) {
 	var subscriptions []models.Subscription
 	err := s.db.WithContext(ctx).
@@ -48,7 +171,27 @@ func (s *SubscriptionService) GetUserSubscriptions(ctx context.Context, userID i
 	return subscriptions, err
 }
 
+// GetUserSubscription returns one of the user's active subscriptions
+func (s *SubscriptionService) GetUserSubscription(ctx context.Context, userID int64, subscriptionID uuid.UUID) (*models.Subscription, error) {
+	var subscription models.Subscription
+	err := s.db.WithContext(ctx).
+		Where("id = ? AND user_id = ? AND is_active = ?", subscriptionID, userID, true).
+		First(&subscription).Error
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
 func (s *SubscriptionService) UpdateSubscription(ctx context.Context, userID int64, subscriptionID uuid.UUID, updates map[string]interface{}) error {
+	if timeOfDay, ok := updates["time_of_day"]; ok {
+		if err := ValidateTimeOfDay(fmt.Sprint(timeOfDay)); err != nil {
+			return err
+		}
+	}
+	if weekday, ok := updates["send_weekday"].(int); ok && (weekday < int(time.Sunday) || weekday > int(time.Saturday)) {
+		return fmt.Errorf("invalid weekday: %d", weekday)
+	}
 	updates["updated_at"] = time.Now().UTC()
 
 	return s.db.WithContext(ctx).
@@ -57,6 +200,15 @@ func (s *SubscriptionService) UpdateSubscription(ctx context.Context, userID int
 		Updates(updates).Error
 }
 
+// SetDeliveryChat makes one of the user's subscriptions deliver to a group
+// instead of the user's private chat
+func (s *SubscriptionService) SetDeliveryChat(ctx context.Context, userID int64, subscriptionID uuid.UUID, chatID int64) error {
+	return s.db.WithContext(ctx).
+		Model(&models.Subscription{}).
+		Where("id = ? AND user_id = ?", subscriptionID, userID).
+		Update("chat_id", chatID).Error
+}
+
 func (s *SubscriptionService) DeleteSubscription(ctx context.Context, userID int64, subscriptionID uuid.UUID) error {
 	return s.db.WithContext(ctx).
 		Model(&models.Subscription{}).
@@ -84,19 +236,19 @@ func (s *SubscriptionService) GetSubscriptionsByType(ctx context.Context, subTyp
 	return subscriptions, err
 }
 
+// ShouldSendNotification reports whether the subscription is due now. Its
+// time of day is read in the timezone of its preloaded user, UTC when unset.
 func (s *SubscriptionService) ShouldSendNotification(subscription *models.Subscription) bool {
-	now := time.Now().UTC()
+	now := time.Now().In(userLocation(&subscription.User))
 
-	// Parse time of day
-	timeOfDay, err := time.Parse("15:04", subscription.TimeOfDay)
+	// Today's time of day in the user's timezone, as a UTC instant
+	targetTime, err := subscriptionTimeUTC(subscription.TimeOfDay, now)
 	if err != nil {
 		return false
 	}
 
 	// Check if it's the right time of day (within 5 minute window)
-	currentTime := time.Date(1, 1, 1, now.Hour(), now.Minute(), 0, 0, time.UTC)
-	targetTime := time.Date(1, 1, 1, timeOfDay.Hour(), timeOfDay.Minute(), 0, 0, time.UTC)
-	timeDiff := currentTime.Sub(targetTime).Minutes()
+	timeDiff := now.Sub(targetTime).Minutes()
 
 	if timeDiff < 0 || timeDiff > 5 {
 		return false
@@ -113,7 +265,7 @@ func (s *SubscriptionService) ShouldSendNotification(subscription *models.Subscr
 	case models.FrequencyDaily:
 		return true
 	case models.FrequencyWeekly:
-		return now.Weekday() == time.Monday
+		return now.Weekday() == subscription.WeeklySendDay()
 	default:
 		return false
 	}
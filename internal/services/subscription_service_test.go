@@ -8,6 +8,8 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 
 	"github.com/valpere/shopogoda/internal/models"
 	"github.com/valpere/shopogoda/tests/helpers"
@@ -39,7 +41,7 @@ func TestSubscriptionService_CreateSubscription(t *testing.T) {
 
 		mockDB.Mock.ExpectBegin()
 		mockDB.Mock.ExpectQuery(`INSERT INTO "subscriptions"`).
-			WithArgs(userID, subType, frequency, timeOfDay, true, helpers.AnyTime{}, helpers.AnyTime{}).
+			WithArgs(userID, subType, frequency, timeOfDay, true, nil, nil, int64(0), helpers.AnyTime{}, helpers.AnyTime{}).
 			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
 		mockDB.Mock.ExpectCommit()
 
@@ -70,6 +72,154 @@ func TestSubscriptionService_CreateSubscription(t *testing.T) {
 		assert.Contains(t, err.Error(), "database error")
 		mockDB.ExpectationsWereMet(t)
 	})
+
+	t.Run("sunrise subscription", func(t *testing.T) {
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "subscriptions"`).
+			WithArgs(int64(123), models.SubscriptionSunrise, models.FrequencyDaily, models.SunriseTimeOfDay, true, nil, nil, int64(0), helpers.AnyTime{}, helpers.AnyTime{}).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mockDB.Mock.ExpectCommit()
+
+		subscription, err := service.CreateSubscription(context.Background(), 123, models.SubscriptionSunrise, models.FrequencyDaily, "")
+
+		require.NoError(t, err)
+		assert.Equal(t, models.SunriseTimeOfDay, subscription.TimeOfDay)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("invalid time of day", func(t *testing.T) {
+		subscription, err := service.CreateSubscription(context.Background(), 123, models.SubscriptionDaily, models.FrequencyDaily, "8am")
+
+		assert.ErrorIs(t, err, ErrInvalidTimeOfDay)
+		assert.Nil(t, subscription)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestValidateTimeOfDay(t *testing.T) {
+	for _, valid := range []string{"00:00", "06:00", "08:30", "23:59"} {
+		assert.NoError(t, ValidateTimeOfDay(valid), valid)
+	}
+	for _, invalid := range []string{"", "8:00", "24:00", "12:60", "08:00:00", "08-00", " 08:00"} {
+		assert.ErrorIs(t, ValidateTimeOfDay(invalid), ErrInvalidTimeOfDay, invalid)
+	}
+}
+
+func TestSubscriptionService_HasActiveSubscription(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	mockRedis := helpers.NewMockRedis()
+	service := NewSubscriptionService(mockDB.DB, mockRedis.Client)
+
+	t.Run("active subscription exists", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "subscriptions"`).
+			WithArgs(int64(123), models.SubscriptionAlerts, true).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(1))
+
+		subscribed, err := service.HasActiveSubscription(context.Background(), 123, models.SubscriptionAlerts)
+
+		assert.NoError(t, err)
+		assert.True(t, subscribed)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("no active subscription", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "subscriptions"`).
+			WithArgs(int64(123), models.SubscriptionAlerts, true).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(0))
+
+		subscribed, err := service.HasActiveSubscription(context.Background(), 123, models.SubscriptionAlerts)
+
+		assert.NoError(t, err)
+		assert.False(t, subscribed)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestSubscriptionService_ImmediateAlertDelivery(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	mockRedis := helpers.NewMockRedis()
+	service := NewSubscriptionService(mockDB.DB, mockRedis.Client)
+
+	t.Run("digest without alerts subscription defers alerts", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(`SELECT "subscription_type" FROM "subscriptions"`).
+			WithArgs(int64(123), true).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"subscription_type"}).
+				AddRow(int(models.SubscriptionDaily)).
+				AddRow(int(models.SubscriptionWeekly)))
+
+		immediate, err := service.ImmediateAlertDelivery(context.Background(), 123)
+
+		assert.NoError(t, err)
+		assert.False(t, immediate)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("alerts subscription sends immediately", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(`SELECT "subscription_type" FROM "subscriptions"`).
+			WithArgs(int64(123), true).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"subscription_type"}).
+				AddRow(int(models.SubscriptionDaily)).
+				AddRow(int(models.SubscriptionAlerts)))
+
+		immediate, err := service.ImmediateAlertDelivery(context.Background(), 123)
+
+		assert.NoError(t, err)
+		assert.True(t, immediate)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestImmediateAlertDelivery(t *testing.T) {
+	assert.True(t, immediateAlertDelivery(nil), "no digest to carry the alerts")
+	assert.True(t, immediateAlertDelivery([]models.SubscriptionType{models.SubscriptionWeekly}))
+	assert.False(t, immediateAlertDelivery([]models.SubscriptionType{models.SubscriptionDaily}))
+	assert.True(t, immediateAlertDelivery([]models.SubscriptionType{models.SubscriptionDaily, models.SubscriptionExtreme}))
+}
+
+func TestSubscriptionService_EnsureSubscription(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	mockRedis := helpers.NewMockRedis()
+	service := NewSubscriptionService(mockDB.DB, mockRedis.Client)
+	userID := int64(123)
+
+	t.Run("creates subscription when none exists", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "subscriptions" WHERE user_id = \$1 AND subscription_type = \$2`).
+			WithArgs(userID, models.SubscriptionAlerts, 1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}))
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "subscriptions"`).
+			WithArgs(userID, models.SubscriptionAlerts, models.FrequencyDaily, "12:00", true, nil, nil, int64(0), helpers.AnyTime{}, helpers.AnyTime{}).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mockDB.Mock.ExpectCommit()
+
+		subscription, err := service.EnsureSubscription(context.Background(), userID, models.SubscriptionAlerts, models.FrequencyDaily, "12:00")
+
+		assert.NoError(t, err)
+		assert.True(t, subscription.IsActive)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("reactivates cancelled subscription", func(t *testing.T) {
+		subscriptionID := uuid.New()
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "subscriptions" WHERE user_id = \$1 AND subscription_type = \$2`).
+			WithArgs(userID, models.SubscriptionAlerts, 1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id", "user_id", "subscription_type", "is_active"}).
+				AddRow(subscriptionID, userID, models.SubscriptionAlerts, false))
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "subscriptions" SET "is_active"=\$1,"updated_at"=\$2 WHERE "id" = \$3`).
+			WithArgs(true, helpers.AnyTime{}, subscriptionID).
+			WillReturnResult(helpers.NewResult(1, 1))
+		mockDB.Mock.ExpectCommit()
+
+		subscription, err := service.EnsureSubscription(context.Background(), userID, models.SubscriptionAlerts, models.FrequencyDaily, "12:00")
+
+		assert.NoError(t, err)
+		assert.Equal(t, subscriptionID, subscription.ID)
+		mockDB.ExpectationsWereMet(t)
+	})
 }
 
 func TestSubscriptionService_GetUserSubscriptions(t *testing.T) {
@@ -115,6 +265,35 @@ func TestSubscriptionService_GetUserSubscriptions(t *testing.T) {
 	})
 }
 
+func TestSubscriptionService_GetUserSubscription(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	service := NewSubscriptionService(mockDB.DB, helpers.NewMockRedis().Client)
+
+	t.Run("found", func(t *testing.T) {
+		sub := helpers.MockSubscription(123)
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "subscriptions" WHERE id = \$1 AND user_id = \$2 AND is_active = \$3`).
+			WithArgs(sub.ID, int64(123), true, 1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id", "user_id", "subscription_type", "frequency", "time_of_day", "is_active"}).
+				AddRow(sub.ID, sub.UserID, sub.SubscriptionType, sub.Frequency, sub.TimeOfDay, true))
+
+		subscription, err := service.GetUserSubscription(context.Background(), 123, sub.ID)
+		require.NoError(t, err)
+		assert.Equal(t, sub.ID, subscription.ID)
+		assert.Equal(t, sub.TimeOfDay, subscription.TimeOfDay)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("another user's or removed", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "subscriptions"`).
+			WillReturnError(gorm.ErrRecordNotFound)
+
+		_, err := service.GetUserSubscription(context.Background(), 456, uuid.New())
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
 func TestSubscriptionService_UpdateSubscription(t *testing.T) {
 	mockDB := helpers.NewMockDB(t)
 	defer func() { _ = mockDB.Close() }()
@@ -156,6 +335,20 @@ func TestSubscriptionService_UpdateSubscription(t *testing.T) {
 		assert.NoError(t, err) // GORM doesn't return error for zero rows affected
 		mockDB.ExpectationsWereMet(t)
 	})
+
+	t.Run("invalid time of day", func(t *testing.T) {
+		err := service.UpdateSubscription(context.Background(), 123, uuid.New(), map[string]interface{}{"time_of_day": "25:00"})
+
+		assert.ErrorIs(t, err, ErrInvalidTimeOfDay)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("invalid weekday", func(t *testing.T) {
+		err := service.UpdateSubscription(context.Background(), 123, uuid.New(), map[string]interface{}{"send_weekday": 7})
+
+		assert.Error(t, err)
+		mockDB.ExpectationsWereMet(t)
+	})
 }
 
 func TestSubscriptionService_DeleteSubscription(t *testing.T) {
@@ -350,4 +543,17 @@ func TestSubscriptionService_ShouldSendNotification(t *testing.T) {
 		}
 		assert.False(t, service.ShouldSendNotification(sub))
 	})
+
+	t.Run("time of day in the user's timezone", func(t *testing.T) {
+		// UTC+9 all year, so the local time is never the UTC time
+		tokyo, err := time.LoadLocation("Asia/Tokyo")
+		require.NoError(t, err)
+
+		sub := createSubscription(time.Now().In(tokyo).Format("15:04"), models.FrequencyDaily)
+		sub.User = models.User{Timezone: "Asia/Tokyo"}
+		assert.True(t, service.ShouldSendNotification(sub))
+
+		sub.TimeOfDay = currentTimeStr
+		assert.False(t, service.ShouldSendNotification(sub))
+	})
 }
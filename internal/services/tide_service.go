@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+const (
+	// TideForecastDays is how many days of tides /tide shows, today included
+	TideForecastDays = 2
+
+	// tideCacheTTL is how long tide predictions are cached per place. They
+	// are astronomical and do not change, but start from the day requested.
+	tideCacheTTL = 6 * time.Hour
+
+	// defaultTideCoastDistance is how far from the sea, in km, a place may be
+	// and still get tides when the configuration does not say
+	defaultTideCoastDistance = 20
+)
+
+// ErrTidesDisabled is returned when no tide API key is configured
+var ErrTidesDisabled = errors.New("tide predictions are not configured")
+
+// TideService predicts the high and low tides of coastal places
+type TideService interface {
+	GetTidePredictions(ctx context.Context, lat, lon float64, days int) (*weather.TideData, error)
+}
+
+// GetTidePredictions returns the high and low tides of a place from the start
+// of today for days days. Places further from the sea than the configured
+// coast distance have no tides of their own, which fails with
+// weather.ErrNoTideData.
+func (s *WeatherService) GetTidePredictions(ctx context.Context, lat, lon float64, days int) (*weather.TideData, error) {
+	if s.tides == nil {
+		return nil, ErrTidesDisabled
+	}
+	days = min(max(days, 1), weather.TideMaxDays)
+
+	key := weatherCacheKey(fmt.Sprintf("tides:%d", days), lat, lon)
+	tideData, _, err := cachedWeather(ctx, s, "tides", key, tideCacheTTL,
+		func() (*weather.TideData, error) {
+			return retryOnTimeout(func() (*weather.TideData, error) { return s.tides.GetTidePredictions(ctx, lat, lon, days) })
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tide data: %w", err)
+	}
+
+	// WorldTides answers for the nearest point at sea however far away it is
+	if distance := distanceKm(lat, lon, tideData.Latitude, tideData.Longitude); distance > s.tideCoastDistance() {
+		return nil, fmt.Errorf("%w: nearest sea is %.0f km away", weather.ErrNoTideData, distance)
+	}
+	return tideData, nil
+}
+
+func (s *WeatherService) tideCoastDistance() float64 {
+	if s.config != nil && s.config.TideCoastDistance > 0 {
+		return s.config.TideCoastDistance
+	}
+	return defaultTideCoastDistance
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+func TestWeatherService_GetTidePredictions(t *testing.T) {
+	logger := zerolog.Nop()
+	lat, lon := 50.3755, -4.1427 // Plymouth
+
+	t.Run("disabled without an API key", func(t *testing.T) {
+		rdb, _ := redismock.NewClientMock()
+		service := NewWeatherService(&config.WeatherConfig{}, rdb, &logger)
+
+		_, err := service.GetTidePredictions(context.Background(), lat, lon, TideForecastDays)
+		assert.ErrorIs(t, err, ErrTidesDisabled)
+	})
+
+	cached := func(mock redismock.ClientMock, atLat, atLon float64) {
+		data, _ := json.Marshal(weather.TideData{
+			Points:    []weather.TidePoint{{Time: time.Date(2024, 10, 16, 6, 0, 0, 0, time.UTC), Height: 2.3, Type: weather.TideHigh}},
+			Latitude:  atLat,
+			Longitude: atLon,
+		})
+		mock.ExpectGet("weather:tides:2:50.38:-4.14").SetVal(string(data))
+	}
+
+	t.Run("coastal place", func(t *testing.T) {
+		rdb, mock := redismock.NewClientMock()
+		service := NewWeatherService(&config.WeatherConfig{TideAPIKey: "tide-key"}, rdb, &logger)
+		cached(mock, 50.333, -4.167) // Five km out to sea
+
+		tides, err := service.GetTidePredictions(context.Background(), lat, lon, TideForecastDays)
+		require.NoError(t, err)
+		assert.Len(t, tides.Points, 1)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("inland place", func(t *testing.T) {
+		rdb, mock := redismock.NewClientMock()
+		service := NewWeatherService(&config.WeatherConfig{TideAPIKey: "tide-key", TideCoastDistance: 3}, rdb, &logger)
+		cached(mock, 50.333, -4.167)
+
+		_, err := service.GetTidePredictions(context.Background(), lat, lon, TideForecastDays)
+		assert.ErrorIs(t, err, weather.ErrNoTideData)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+
+	"github.com/valpere/shopogoda/internal"
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+const (
+	// todayCardTTL is how long a composed card is reused for the same location
+	todayCardTTL       = 30 * time.Minute
+	todayCardKeyPrefix = "today_card:"
+
+	// TodayCooldown is the shortest interval between two cards in one chat
+	TodayCooldown          = 2 * time.Minute
+	todayCooldownKeyPrefix = "today_cooldown:"
+
+	// todayRecordRadius is the reach in degrees, each way, of the history that
+	// counts as recorded at the card's location
+	todayRecordRadius = 0.1
+	// todayRainyChance is the chance of precipitation from which the tip
+	// suggests an umbrella
+	todayRainyChance = 0.5
+
+	// TodayStartParameter is the /start payload of the card's share link
+	TodayStartParameter = "today"
+)
+
+// TodayLocation is the place a /today card describes
+type TodayLocation struct {
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// TodayRecord is the warmest and coldest temperature recorded on this calendar
+// day in earlier years
+type TodayRecord struct {
+	High  float64 `json:"high"`
+	Low   float64 `json:"low"`
+	Years int     `json:"years"` // Earlier years with at least one observation
+}
+
+// TodayCard holds everything the /today card shows. Cards carry no user
+// specific data, so one card serves every chat asking about the location.
+type TodayCard struct {
+	Location   TodayLocation          `json:"location"`
+	Current    *WeatherData           `json:"current"`
+	Forecast   *weather.DailyForecast `json:"forecast,omitempty"` // Nil when the forecast is unavailable
+	Record     *TodayRecord           `json:"record,omitempty"`   // Nil without history for the day
+	ComposedAt time.Time              `json:"composed_at"`        // UTC
+}
+
+// TodayService composes the chat-level "today in <city>" card
+type TodayService struct {
+	db           *gorm.DB
+	redis        *redis.Client
+	weather      *WeatherService
+	localization *LocalizationService
+	logger       *zerolog.Logger
+	now          func() time.Time
+}
+
+func NewTodayService(db *gorm.DB, redis *redis.Client, weather *WeatherService, localization *LocalizationService, logger *zerolog.Logger) *TodayService {
+	return &TodayService{
+		db:           db,
+		redis:        redis,
+		weather:      weather,
+		localization: localization,
+		logger:       logger,
+		now:          time.Now,
+	}
+}
+
+// ResolveLocation returns the location a card in the chat describes: the
+// user's saved location in a private chat, the configured chat location
+// elsewhere. It returns nil when there is none.
+func (s *TodayService) ResolveLocation(ctx context.Context, chatType string, chatID int64, user *models.User) (*TodayLocation, error) {
+	if chatType == gotgbot.ChatTypePrivate {
+		if user == nil || !user.HasLocation() {
+			return nil, nil
+		}
+		return &TodayLocation{Name: user.LocationName, Latitude: user.Latitude, Longitude: user.Longitude}, nil
+	}
+
+	var settings models.ChatSettings
+	err := s.db.WithContext(ctx).Where("chat_id = ?", chatID).First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chat location: %w", err)
+	}
+	if !settings.HasLocation() {
+		return nil, nil
+	}
+	return &TodayLocation{Name: settings.LocationName, Latitude: settings.Latitude, Longitude: settings.Longitude}, nil
+}
+
+// ClaimCooldown reports whether the chat may get a card now, starting its
+// cooldown when it may
+func (s *TodayService) ClaimCooldown(ctx context.Context, chatID int64) (bool, error) {
+	key := todayCooldownKeyPrefix + strconv.FormatInt(chatID, 10)
+	return s.redis.SetNX(ctx, key, s.now().Unix(), TodayCooldown).Result()
+}
+
+// Compose returns the card for a location, reusing one composed within the
+// last todayCardTTL. Only the current weather is required; a missing forecast
+// or record leaves its part of the card out.
+func (s *TodayService) Compose(ctx context.Context, location TodayLocation) (*TodayCard, error) {
+	cacheKey := fmt.Sprintf("%s%.2f:%.2f", todayCardKeyPrefix, location.Latitude, location.Longitude)
+	if cached, err := s.redis.Get(ctx, cacheKey).Result(); err == nil {
+		var card TodayCard
+		if err := json.Unmarshal([]byte(cached), &card); err == nil {
+			return &card, nil
+		}
+	}
+
+	current, err := s.weather.GetCurrentWeatherByCoords(ctx, location.Latitude, location.Longitude)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get weather for today card: %w", err)
+	}
+
+	card := &TodayCard{
+		Location:   location,
+		Current:    current,
+		ComposedAt: s.now().UTC(),
+	}
+
+	forecast, err := s.weather.GetForecast(ctx, location.Latitude, location.Longitude, 1)
+	switch {
+	case err != nil:
+		s.logger.Warn().Err(err).Str("location", location.Name).Msg("Today card without forecast")
+	case len(forecast.Forecasts) > 0:
+		card.Forecast = &forecast.Forecasts[0]
+	}
+
+	card.Record, err = s.onThisDay(ctx, location, card.ComposedAt)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("location", location.Name).Msg("Today card without on-this-day record")
+	}
+
+	cardJSON, err := json.Marshal(card)
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("Failed to marshal today card for caching")
+	} else if err := s.redis.Set(ctx, cacheKey, cardJSON, todayCardTTL).Err(); err != nil {
+		s.logger.Warn().Err(err).Str("cache_key", cacheKey).Msg("Failed to cache today card")
+	}
+
+	return card, nil
+}
+
+// onThisDay finds the extremes recorded near the location on this calendar
+// day in earlier years. Observations belong to users, so the history is that
+// of users whose saved location lies within todayRecordRadius.
+func (s *TodayService) onThisDay(ctx context.Context, location TodayLocation, now time.Time) (*TodayRecord, error) {
+	var row struct {
+		High  *float64
+		Low   *float64
+		Years int
+	}
+	err := s.db.WithContext(ctx).Model(&models.WeatherData{}).
+		Select("MAX(weather_data.temperature) AS high, MIN(weather_data.temperature) AS low, COUNT(DISTINCT EXTRACT(YEAR FROM weather_data.timestamp)) AS years").
+		Joins("JOIN users ON users.id = weather_data.user_id").
+		Where("users.latitude BETWEEN ? AND ? AND users.longitude BETWEEN ? AND ?",
+			location.Latitude-todayRecordRadius, location.Latitude+todayRecordRadius,
+			location.Longitude-todayRecordRadius, location.Longitude+todayRecordRadius).
+		Where("EXTRACT(MONTH FROM weather_data.timestamp) = ? AND EXTRACT(DAY FROM weather_data.timestamp) = ?", int(now.Month()), now.Day()).
+		Where("weather_data.timestamp < ?", time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)).
+		Scan(&row).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load on-this-day record: %w", err)
+	}
+	if row.Years == 0 || row.High == nil || row.Low == nil {
+		return nil, nil
+	}
+	return &TodayRecord{High: *row.High, Low: *row.Low, Years: row.Years}, nil
+}
+
+// Render formats the card in the given language and units. Times are local to
+// the card's location; shareLink, when set, closes the card.
+func (s *TodayService) Render(ctx context.Context, language, units string, card *TodayCard, shareLink string) string {
+	if language == "" {
+		language = internal.DefaultLanguage
+	}
+	if units == "" {
+		units = weather.UnitsMetric
+	}
+	t := func(key string, args ...any) string {
+		return s.localization.T(ctx, language, key, args...)
+	}
+	current := card.Current
+
+	lines := []string{
+		t("today_title", markdown.EscapeBold(card.Location.Name)),
+		"",
+		t("today_now", current.Icon, current.Description,
+			weather.FormatTemp(current.Temperature, units), weather.FormatTemp(current.FeelsLike, units)),
+	}
+	if card.Forecast != nil {
+		lines = append(lines,
+			t("today_min_max", weather.FormatTemp(card.Forecast.MinTemp, units), weather.FormatTemp(card.Forecast.MaxTemp, units)),
+			t("today_precipitation", weather.FormatPercent(card.Forecast.PrecipitationChance*100)))
+	}
+	if !current.Sunrise.IsZero() && !current.Sunset.IsZero() {
+		zone := time.FixedZone("", current.UTCOffset)
+		lines = append(lines, t("today_sun", current.Sunrise.In(zone).Format("15:04"), current.Sunset.In(zone).Format("15:04")))
+	}
+	if current.AQI > 0 {
+		lines = append(lines, t("today_aqi", weather.FormatAQI(float64(current.AQI)), t(AQIDescriptionKey(current.AQI))))
+	}
+	lines = append(lines, t("today_tip", t(clothingTipKey(current.FeelsLike))))
+	if card.Forecast != nil && card.Forecast.PrecipitationChance >= todayRainyChance {
+		lines = append(lines, t("today_tip_umbrella"))
+	}
+	if card.Record != nil {
+		lines = append(lines, t("today_record",
+			weather.FormatTemp(card.Record.High, units), weather.FormatTemp(card.Record.Low, units), card.Record.Years))
+	}
+	if shareLink != "" {
+		lines = append(lines, "", t("today_share", shareLink))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// TodayShareLink returns the deep link that opens the bot on the user's own card
+func TodayShareLink(botUsername string) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s", botUsername, TodayStartParameter)
+}
+
+// clothingTipKey picks the clothing advice for an apparent temperature in °C
+func clothingTipKey(feelsLike float64) string {
+	switch {
+	case feelsLike < -10:
+		return "today_tip_freezing"
+	case feelsLike < 5:
+		return "today_tip_cold"
+	case feelsLike < 12:
+		return "today_tip_cool"
+	case feelsLike < 20:
+		return "today_tip_mild"
+	case feelsLike < 27:
+		return "today_tip_warm"
+	default:
+		return "today_tip_hot"
+	}
+}
+
+// AQIDescriptionKey returns the translation key describing an air quality index
+func AQIDescriptionKey(aqi int) string {
+	switch {
+	case aqi <= 50:
+		return "aqi_good"
+	case aqi <= 100:
+		return "aqi_moderate"
+	case aqi <= 150:
+		return "aqi_unhealthy_sensitive"
+	case aqi <= 200:
+		return "aqi_unhealthy"
+	case aqi <= 300:
+		return "aqi_very_unhealthy"
+	default:
+		return "aqi_hazardous"
+	}
+}
+
+// moonPhaseKeys are the translation keys of the eight moon phases, in the
+// order of weather.MoonPhaseOctant
+var moonPhaseKeys = [8]string{
+	"moon_new", "moon_waxing_crescent", "moon_first_quarter", "moon_waxing_gibbous",
+	"moon_full", "moon_waning_gibbous", "moon_last_quarter", "moon_waning_crescent",
+}
+
+// MoonPhaseKey returns the translation key naming a phase from weather.MoonPhase
+func MoonPhaseKey(phase float64) string {
+	return moonPhaseKeys[weather.MoonPhaseOctant(phase)]
+}
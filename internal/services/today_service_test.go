@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/internal/locales"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/weather"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestTodayService_ResolveLocation(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	service := NewTodayService(mockDB.DB, nil, nil, nil, helpers.NewSilentTestLogger())
+	ctx := context.Background()
+
+	user := &models.User{ID: 42, LocationName: "Kyiv", Latitude: 50.45, Longitude: 30.52}
+
+	t.Run("private chat uses the user's location", func(t *testing.T) {
+		location, err := service.ResolveLocation(ctx, gotgbot.ChatTypePrivate, 42, user)
+		require.NoError(t, err)
+		assert.Equal(t, &TodayLocation{Name: "Kyiv", Latitude: 50.45, Longitude: 30.52}, location)
+	})
+
+	t.Run("private chat without a saved location", func(t *testing.T) {
+		location, err := service.ResolveLocation(ctx, gotgbot.ChatTypePrivate, 42, &models.User{ID: 42})
+		require.NoError(t, err)
+		assert.Nil(t, location)
+	})
+
+	t.Run("group uses the chat location, not the user's", func(t *testing.T) {
+		rows := mockDB.Mock.NewRows([]string{"chat_id", "location_name", "latitude", "longitude", "set_by"}).
+			AddRow(int64(-100123), "Lviv", 49.84, 24.03, int64(7))
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "chat_locations" WHERE chat_id = \$1`).
+			WithArgs(int64(-100123), 1).
+			WillReturnRows(rows)
+
+		location, err := service.ResolveLocation(ctx, gotgbot.ChatTypeSupergroup, -100123, user)
+		require.NoError(t, err)
+		assert.Equal(t, &TodayLocation{Name: "Lviv", Latitude: 49.84, Longitude: 24.03}, location)
+	})
+
+	t.Run("group without a chat location", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "chat_locations" WHERE chat_id = \$1`).
+			WithArgs(int64(-100456), 1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"chat_id"}))
+
+		location, err := service.ResolveLocation(ctx, gotgbot.ChatTypeGroup, -100456, user)
+		require.NoError(t, err)
+		assert.Nil(t, location)
+	})
+
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestTodayService_Compose(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	mockRedis := helpers.NewMockRedis()
+	logger := helpers.NewSilentTestLogger()
+	weatherService := NewWeatherService(&config.WeatherConfig{OpenWeatherAPIKey: "test-key"}, mockRedis.Client, logger)
+	service := NewTodayService(mockDB.DB, mockRedis.Client, weatherService, nil, logger)
+	now := time.Date(2025, 4, 16, 9, 30, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	location := TodayLocation{Name: "Kyiv", Latitude: 50.45, Longitude: 30.52}
+	cardKey := "today_card:50.45:30.52"
+	sunrise := time.Date(2025, 4, 16, 2, 58, 0, 0, time.UTC)
+	sunset := time.Date(2025, 4, 16, 16, 51, 0, 0, time.UTC)
+
+	current, _ := json.Marshal(weather.WeatherData{Temperature: 14.6, FeelsLike: 13.9, Description: "broken clouds", Sunrise: sunrise, Sunset: sunset, UTCOffset: 10800, Timestamp: now})
+	air, _ := json.Marshal(weather.AirQualityData{AQI: 2})
+	forecastDay := weather.DailyForecast{Date: now.Truncate(24 * time.Hour), MinTemp: 8.1, MaxTemp: 17.4, PrecipitationChance: 0.6}
+	forecast, _ := json.Marshal(weather.ForecastData{Location: "Kyiv, UA", Forecasts: []weather.DailyForecast{forecastDay}})
+
+	mockRedis.Mock.ExpectGet(cardKey).RedisNil()
+	mockRedis.Mock.ExpectGet("weather:current:50.45:30.52").SetVal(string(current))
+	mockRedis.Mock.ExpectGet("weather:air:50.45:30.52").SetVal(string(air))
+	mockRedis.Mock.ExpectGet("weather:forecast:50.45:30.52:1").SetVal(string(forecast))
+	mockDB.Mock.ExpectQuery(`SELECT MAX\(weather_data.temperature\) AS high, MIN\(weather_data.temperature\) AS low, COUNT\(DISTINCT EXTRACT\(YEAR FROM weather_data.timestamp\)\) AS years FROM "weather_data" JOIN users ON users.id = weather_data.user_id`).
+		WithArgs(location.Latitude-todayRecordRadius, location.Latitude+todayRecordRadius,
+			location.Longitude-todayRecordRadius, location.Longitude+todayRecordRadius,
+			int64(4), int64(16), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"high", "low", "years"}).AddRow(23.5, 1.2, 3))
+
+	expected := &TodayCard{
+		Location: location,
+		Current: &WeatherData{
+			Temperature: 14.6, FeelsLike: 13.9, Description: "broken clouds", AQI: 2,
+			Sunrise: sunrise, Sunset: sunset, MoonPhase: weather.MoonPhase(now), UTCOffset: 10800,
+			Timestamp: now, FromCache: true,
+		},
+		Forecast:   &forecastDay,
+		Record:     &TodayRecord{High: 23.5, Low: 1.2, Years: 3},
+		ComposedAt: now,
+	}
+	expectedJSON, err := json.Marshal(expected)
+	require.NoError(t, err)
+	mockRedis.Mock.ExpectSet(cardKey, expectedJSON, todayCardTTL).SetVal("OK")
+
+	card, err := service.Compose(ctx, location)
+	require.NoError(t, err)
+	assert.Equal(t, expected, card)
+
+	// A second chat asking within the TTL gets the cached card without any
+	// provider or database calls
+	mockRedis.Mock.ExpectGet(cardKey).SetVal(string(expectedJSON))
+	cached, err := service.Compose(ctx, location)
+	require.NoError(t, err)
+	assert.Equal(t, expected, cached)
+
+	assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestTodayService_Render(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	localization := NewLocalizationService(logger)
+	require.NoError(t, localization.LoadTranslations(locales.LocalesFS))
+	service := NewTodayService(nil, nil, nil, localization, logger)
+
+	card := &TodayCard{
+		Location: TodayLocation{Name: "Kyiv"},
+		Current: &WeatherData{
+			Temperature: 14.6, FeelsLike: 13.9, Icon: "⛅", Description: "broken clouds", AQI: 42,
+			Sunrise: time.Date(2025, 4, 16, 2, 58, 0, 0, time.UTC), Sunset: time.Date(2025, 4, 16, 16, 51, 0, 0, time.UTC), UTCOffset: 10800,
+		},
+		Forecast: &weather.DailyForecast{MinTemp: 8.1, MaxTemp: 17.4, PrecipitationChance: 0.6},
+		Record:   &TodayRecord{High: 23.5, Low: 1.2, Years: 3},
+	}
+
+	expected := "📅 *Today in Kyiv*\n\n" +
+		"⛅ broken clouds, 14.6°C (feels like 13.9°C)\n" +
+		"🌡️ Min 8.1°C · Max 17.4°C\n" +
+		"☔ Chance of precipitation: 60%\n" +
+		"🌅 Sunrise 05:58 · 🌇 Sunset 19:51\n" +
+		"🌿 Air quality: 42 (Good)\n" +
+		"👕 A light jacket or long sleeves\n" +
+		"☂️ Take an umbrella\n" +
+		"📜 On this day in earlier years: up to 23.5°C, down to 1.2°C (3 years of records)\n\n" +
+		"🔗 Your own forecast: https://t.me/shopogoda_bot?start=today"
+	assert.Equal(t, expected, service.Render(context.Background(), "en-US", "", card, TodayShareLink("shopogoda_bot")))
+
+	t.Run("parts without data are left out", func(t *testing.T) {
+		bare := &TodayCard{Location: TodayLocation{Name: "Kyiv"}, Current: &WeatherData{Temperature: -12, FeelsLike: -18, Icon: "❄️", Description: "snow"}}
+		assert.Equal(t, "📅 *Today in Kyiv*\n\n❄️ snow, -12.0°C (feels like -18.0°C)\n👕 Heavy coat, hat and gloves",
+			service.Render(context.Background(), "en-US", "", bare, ""))
+	})
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/valpere/shopogoda/internal/models"
+)
+
+// ErrAPIKeysDisabled is returned when users' own API keys are used without
+// USER_API_KEY_SECRET set to encrypt them
+var ErrAPIKeysDisabled = errors.New("user API keys are not enabled")
+
+// apiKeyMaskVisible is how many characters MaskAPIKey leaves visible at each
+// end of a key
+const apiKeyMaskVisible = 4
+
+// SetAPIKeySecret enables users' own weather API keys, stored encrypted with
+// AES-GCM under a key derived from secret. An empty secret leaves them off.
+func (s *UserService) SetAPIKeySecret(secret string) error {
+	if secret == "" {
+		s.apiKeyCipher = nil
+		return nil
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return fmt.Errorf("failed to create API key cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("failed to create API key cipher: %w", err)
+	}
+	s.apiKeyCipher = gcm
+	return nil
+}
+
+// APIKeysEnabled reports whether users can set their own weather API key
+func (s *UserService) APIKeysEnabled() bool {
+	return s.apiKeyCipher != nil
+}
+
+// SetUserAPIKey stores the user's own weather API key, encrypted
+func (s *UserService) SetUserAPIKey(ctx context.Context, userID int64, key string) error {
+	encrypted, err := s.encryptAPIKey(key)
+	if err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Update("api_key", encrypted).Error
+}
+
+// ClearUserAPIKey removes the user's own weather API key, so their requests
+// use the bot's again
+func (s *UserService) ClearUserAPIKey(ctx context.Context, userID int64) error {
+	return s.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Update("api_key", "").Error
+}
+
+// GetUserAPIKey returns the user's own weather API key, decrypted, or "" when
+// they have none or user API keys are not enabled. The key is kept out of the
+// cached user, so it is read from the database.
+func (s *UserService) GetUserAPIKey(ctx context.Context, userID int64) (string, error) {
+	if s.apiKeyCipher == nil {
+		return "", nil
+	}
+
+	var keys []string
+	if err := s.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Pluck("api_key", &keys).Error; err != nil {
+		return "", fmt.Errorf("failed to get API key: %w", err)
+	}
+	if len(keys) == 0 || keys[0] == "" {
+		return "", nil
+	}
+	return s.decryptAPIKey(keys[0])
+}
+
+// encryptAPIKey seals key with a random nonce, returned in front of the
+// ciphertext and base64 encoded
+func (s *UserService) encryptAPIKey(key string) (string, error) {
+	if s.apiKeyCipher == nil {
+		return "", ErrAPIKeysDisabled
+	}
+	nonce := make([]byte, s.apiKeyCipher.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := s.apiKeyCipher.Seal(nonce, nonce, []byte(key), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptAPIKey opens a key sealed by encryptAPIKey. Keys sealed under
+// another secret fail to open.
+func (s *UserService) decryptAPIKey(encrypted string) (string, error) {
+	if s.apiKeyCipher == nil {
+		return "", ErrAPIKeysDisabled
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode API key: %w", err)
+	}
+	nonceSize := s.apiKeyCipher.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", errors.New("failed to decrypt API key: too short")
+	}
+	key, err := s.apiKeyCipher.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt API key: %w", err)
+	}
+	return string(key), nil
+}
+
+// MaskAPIKey hides all of a key but its first and last few characters, for
+// showing the user which key they set
+func MaskAPIKey(key string) string {
+	if len(key) <= 2*apiKeyMaskVisible {
+		return strings.Repeat("•", len(key))
+	}
+	return key[:apiKeyMaskVisible] + strings.Repeat("•", 8) + key[len(key)-apiKeyMaskVisible:]
+}
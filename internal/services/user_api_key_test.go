@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/pkg/metrics"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestUserService_APIKeyEncryption(t *testing.T) {
+	logger := zerolog.Nop()
+	service := NewUserService(nil, nil, metrics.New(), &logger, time.Now())
+
+	t.Run("disabled without a secret", func(t *testing.T) {
+		assert.False(t, service.APIKeysEnabled())
+		_, err := service.encryptAPIKey("0123456789abcdef")
+		assert.ErrorIs(t, err, ErrAPIKeysDisabled)
+	})
+
+	require.NoError(t, service.SetAPIKeySecret("server secret"))
+	require.True(t, service.APIKeysEnabled())
+
+	t.Run("round trip with a fresh nonce each time", func(t *testing.T) {
+		first, err := service.encryptAPIKey("0123456789abcdef")
+		require.NoError(t, err)
+		second, err := service.encryptAPIKey("0123456789abcdef")
+		require.NoError(t, err)
+		assert.NotEqual(t, first, second)
+		assert.NotContains(t, first, "0123456789abcdef")
+
+		key, err := service.decryptAPIKey(first)
+		require.NoError(t, err)
+		assert.Equal(t, "0123456789abcdef", key)
+	})
+
+	t.Run("another secret cannot open it", func(t *testing.T) {
+		encrypted, err := service.encryptAPIKey("0123456789abcdef")
+		require.NoError(t, err)
+
+		other := NewUserService(nil, nil, metrics.New(), &logger, time.Now())
+		require.NoError(t, other.SetAPIKeySecret("another secret"))
+		_, err = other.decryptAPIKey(encrypted)
+		assert.Error(t, err)
+
+		_, err = service.decryptAPIKey("c2hvcnQ=")
+		assert.Error(t, err)
+	})
+}
+
+func TestUserService_GetUserAPIKey(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	logger := zerolog.Nop()
+	service := NewUserService(mockDB.DB, nil, metrics.New(), &logger, time.Now())
+
+	t.Run("nothing is read while disabled", func(t *testing.T) {
+		key, err := service.GetUserAPIKey(context.Background(), 123)
+		assert.NoError(t, err)
+		assert.Empty(t, key)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	require.NoError(t, service.SetAPIKeySecret("server secret"))
+	encrypted, err := service.encryptAPIKey("0123456789abcdef")
+	require.NoError(t, err)
+
+	t.Run("decrypts the stored key", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(`SELECT "api_key" FROM "users" WHERE id = \$1`).
+			WithArgs(int64(123)).
+			WillReturnRows(sqlmock.NewRows([]string{"api_key"}).AddRow(encrypted))
+
+		key, err := service.GetUserAPIKey(context.Background(), 123)
+		assert.NoError(t, err)
+		assert.Equal(t, "0123456789abcdef", key)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("no key set", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(`SELECT "api_key" FROM "users" WHERE id = \$1`).
+			WithArgs(int64(123)).
+			WillReturnRows(sqlmock.NewRows([]string{"api_key"}).AddRow(""))
+
+		key, err := service.GetUserAPIKey(context.Background(), 123)
+		assert.NoError(t, err)
+		assert.Empty(t, key)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestMaskAPIKey(t *testing.T) {
+	assert.Equal(t, "0123••••••••cdef", MaskAPIKey("0123456789abcdef"))
+	assert.Equal(t, "••••••", MaskAPIKey("secret"))
+	assert.Empty(t, MaskAPIKey(""))
+}
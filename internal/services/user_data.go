@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/valpere/shopogoda/internal/models"
+)
+
+// ErrLastAdmin is returned when the only admin asks to delete their account,
+// which would leave the deployment without one
+var ErrLastAdmin = errors.New("the last admin cannot delete their account")
+
+// userDataModels are the tables that keep a user's data under user_id, in the
+// order DeleteUser empties them: rows that refer to others go first. Presets
+// the user shared are kept, so the links others got keep working.
+var userDataModels = []interface{}{
+	&models.OutboxItem{},
+	&models.AlertEscalation{},
+	&models.WeatherWidget{},
+	&models.EnvironmentalAlert{},
+	&models.AlertConfig{},
+	&models.Subscription{},
+	&models.QuietPeriod{},
+	&models.WeatherData{},
+	&models.WeatherHistory{},
+	&models.WeatherRecord{},
+	&models.UserLocation{},
+	&models.UserSession{},
+	&models.ExportCursor{},
+	&models.PresetApplication{},
+	&models.Feedback{},
+	&models.UserWebhook{},
+}
+
+// StoredUserData counts the records the bot keeps about a user, by category
+type StoredUserData struct {
+	Subscriptions   int64
+	AlertConfigs    int64
+	TriggeredAlerts int64
+	Locations       int64 // Saved places, next to the current location
+	WeatherRecords  int64 // Weather data, hourly weather history and recorded lookups
+}
+
+// GetStoredData counts the records the bot keeps about a user
+func (s *UserService) GetStoredData(ctx context.Context, userID int64) (*StoredUserData, error) {
+	var data StoredUserData
+	counts := []struct {
+		model interface{}
+		into  *int64
+	}{
+		{&models.Subscription{}, &data.Subscriptions},
+		{&models.AlertConfig{}, &data.AlertConfigs},
+		{&models.EnvironmentalAlert{}, &data.TriggeredAlerts},
+		{&models.UserLocation{}, &data.Locations},
+	}
+	for _, c := range counts {
+		if err := s.db.WithContext(ctx).Model(c.model).Where("user_id = ?", userID).Count(c.into).Error; err != nil {
+			return nil, fmt.Errorf("failed to count stored data: %w", err)
+		}
+	}
+
+	for _, model := range []interface{}{&models.WeatherData{}, &models.WeatherHistory{}, &models.WeatherRecord{}} {
+		var records int64
+		if err := s.db.WithContext(ctx).Model(model).Where("user_id = ?", userID).Count(&records).Error; err != nil {
+			return nil, fmt.Errorf("failed to count stored data: %w", err)
+		}
+		data.WeatherRecords += records
+	}
+	return &data, nil
+}
+
+// PendingDeletionTimeout is how long /deletemydata waits for DELETE to be typed
+const PendingDeletionTimeout = 5 * time.Minute
+
+// pendingDeletionKey marks a user whose next message confirms deleting their
+// data. It lives under user:{id}: so that the deletion removes it.
+func pendingDeletionKey(userID int64) string {
+	return fmt.Sprintf("user:%d:deletion_pending", userID)
+}
+
+// anonymizedUser is what is left of a deleted user: every column back to its
+// default, the names, location and settings included, and the deletion time
+func anonymizedUser(deletedAt time.Time) *models.User {
+	return &models.User{
+		Units:               "metric",
+		Timezone:            "UTC",
+		Role:                models.RoleUser,
+		SkinType:            3,
+		ShowRecommendations: true,
+		DeletedAt:           gorm.DeletedAt{Time: deletedAt, Valid: true},
+	}
+}
+
+// DeleteUser deletes a user's data in one transaction: subscriptions, alerts,
+// saved places, weather history, session, feedback, alert webhook and account
+// transfers. The users row is kept under its ID for the rows that refer to it,
+// but anonymized, inactive and marked deleted. The user's Redis keys go once
+// it has committed. A user who writes to the bot afterwards starts over as a
+// new user. The last admin gets ErrLastAdmin and keeps everything.
+func (s *UserService) DeleteUser(ctx context.Context, userID int64) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The admins are locked, so two of them deleting their accounts at
+		// once cannot both go
+		var admins []int64
+		if err := tx.Model(&models.User{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("role = ?", models.RoleAdmin).Pluck("id", &admins).Error; err != nil {
+			return err
+		}
+		if len(admins) == 1 && admins[0] == userID {
+			return ErrLastAdmin
+		}
+
+		for _, model := range userDataModels {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("from_user_id = ? OR to_user_id = ?", userID, userID).Delete(&models.AccountTransfer{}).Error; err != nil {
+			return err
+		}
+		// Every column is written, so nothing of the user outlives the deletion
+		return tx.Model(&models.User{}).Where("id = ?", userID).
+			Select("*").Omit("id", "created_at").
+			Updates(anonymizedUser(time.Now().UTC())).Error
+	})
+	if errors.Is(err, ErrLastAdmin) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete user data: %w", err)
+	}
+
+	// Users memoized for updates in flight are read again
+	s.generation.Add(1)
+	if s.tx != nil {
+		s.tx.afterCommit(func(ctx context.Context) {
+			if err := s.deleteUserKeys(ctx, userID); err != nil {
+				s.logger.Warn().Err(err).Int64("user_id", userID).Msg("Failed to delete user keys after commit")
+			}
+		})
+		return nil
+	}
+	return s.deleteUserKeys(ctx, userID)
+}
+
+// StartPendingDeletion waits for the user's next message to confirm deleting
+// their data
+func (s *UserService) StartPendingDeletion(ctx context.Context, userID int64) error {
+	if err := s.redis.Set(ctx, pendingDeletionKey(userID), 1, PendingDeletionTimeout).Err(); err != nil {
+		return fmt.Errorf("failed to store pending deletion: %w", err)
+	}
+	s.pendingDeletions.Store(userID, time.Now().Add(PendingDeletionTimeout))
+	return nil
+}
+
+// HasLocalPendingDeletion reports whether this process asked the user to
+// confirm deleting their data within PendingDeletionTimeout. It spares the
+// user's other messages a Redis lookup; a confirmation asked for by another
+// process, or before a restart, is only found by TakePendingDeletion.
+func (s *UserService) HasLocalPendingDeletion(userID int64) bool {
+	deadline, ok := s.pendingDeletions.Load(userID)
+	if !ok {
+		return false
+	}
+	if time.Now().After(deadline.(time.Time)) {
+		s.pendingDeletions.Delete(userID)
+		return false
+	}
+	return true
+}
+
+// TakePendingDeletion reports whether the user asked to delete their data
+// within PendingDeletionTimeout, and stops waiting for the confirmation
+func (s *UserService) TakePendingDeletion(ctx context.Context, userID int64) (bool, error) {
+	s.pendingDeletions.Delete(userID)
+	err := s.redis.GetDel(ctx, pendingDeletionKey(userID)).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load pending deletion: %w", err)
+	}
+	return true, nil
+}
+
+// deleteUserKeys removes the Redis keys of a user: the cached profile under
+// user:{id}, anything kept under user:{id}:, the interaction times and the
+// alert or export the user was entering
+func (s *UserService) deleteUserKeys(ctx context.Context, userID int64) error {
+	prefix := fmt.Sprintf("user:%d", userID)
+	keys := []string{prefix, activityKey(userID), pendingAlertKey(userID), pendingExportKey(userID)}
+
+	iter := s.redis.Scan(ctx, 0, prefix+":*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to find user keys: %w", err)
+	}
+
+	if err := s.redis.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to delete user keys: %w", err)
+	}
+	return nil
+}
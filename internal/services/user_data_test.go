@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/metrics"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestUserService_GetStoredData(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	mockRedis := helpers.NewMockRedis()
+	logger := zerolog.Nop()
+	service := NewUserService(mockDB.DB, mockRedis.Client, metrics.New(), &logger, time.Now())
+
+	expectCount := func(table string, count int) {
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "` + table + `" WHERE user_id = \$1`).
+			WithArgs(int64(123)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+	}
+	expectCount("subscriptions", 2)
+	expectCount("alert_configs", 3)
+	expectCount("environmental_alerts", 5)
+	expectCount("user_locations", 1)
+	expectCount("weather_data", 40)
+	expectCount("weather_history", 160)
+	expectCount("weather_records", 30)
+
+	data, err := service.GetStoredData(context.Background(), 123)
+
+	require.NoError(t, err)
+	assert.Equal(t, &StoredUserData{Subscriptions: 2, AlertConfigs: 3, TriggeredAlerts: 5, Locations: 1, WeatherRecords: 230}, data)
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestUserService_DeleteUser(t *testing.T) {
+	userTables := []string{
+		"outbox_items", "alert_escalations", "weather_widgets", "environmental_alerts",
+		"alert_configs", "subscriptions", "quiet_periods", "weather_data", "weather_history",
+		"weather_records", "user_locations", "user_sessions", "export_cursors", "preset_applications", "feedback", "user_webhooks",
+	}
+
+	t.Run("deletes the data of every table, anonymizes the user and drops their keys", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		mockRedis := helpers.NewMockRedis()
+		logger := zerolog.Nop()
+		service := NewUserService(mockDB.DB, mockRedis.Client, metrics.New(), &logger, time.Now())
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`SELECT "id" FROM "users" WHERE role = \$1 AND "users"\."deleted_at" IS NULL FOR UPDATE`).
+			WithArgs(models.RoleAdmin).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)).AddRow(int64(123)))
+		for _, table := range userTables {
+			mockDB.Mock.ExpectExec(`DELETE FROM "` + table + `" WHERE user_id = \$1`).
+				WithArgs(int64(123)).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mockDB.Mock.ExpectExec(`DELETE FROM "account_transfers" WHERE from_user_id = \$1 OR to_user_id = \$2`).
+			WithArgs(int64(123), int64(123)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		// The row stays for the ID others refer to, with the names blanked
+		anonymized := make([]driver.Value, 31)
+		for i := range anonymized {
+			anonymized[i] = sqlmock.AnyArg()
+		}
+		anonymized[0], anonymized[1], anonymized[2] = "", "", "" // username, first and last name
+		anonymized[7] = false                                    // is_active
+		var deletedAt time.Time
+		anonymized[29] = captureTime{&deletedAt}
+		anonymized[30] = int64(123)
+		mockDB.Mock.ExpectExec(`UPDATE "users" SET "username"=\$1,"first_name"=\$2,"last_name"=\$3,.*"is_active"=\$8,.*"deleted_at"=\$30 WHERE id = \$31 AND "users"\."deleted_at" IS NULL`).
+			WithArgs(anonymized...).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+
+		mockRedis.Mock.ExpectScan(0, "user:123:*", 100).SetVal([]string{"user:123:settings"}, 0)
+		mockRedis.Mock.ExpectDel("user:123", "activity:hours:123", "alert_pending:123", "export_pending:123", "user:123:settings").SetVal(3)
+
+		generation := service.generation.Load()
+		err := service.DeleteUser(context.Background(), 123)
+
+		require.NoError(t, err)
+		assert.Greater(t, service.generation.Load(), generation, "memoized users are read again")
+		assert.WithinDuration(t, time.Now(), deletedAt, time.Minute)
+		mockDB.ExpectationsWereMet(t)
+		mockRedis.ExpectationsWereMet(t)
+	})
+
+	t.Run("failed delete keeps everything", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		mockRedis := helpers.NewMockRedis()
+		logger := zerolog.Nop()
+		service := NewUserService(mockDB.DB, mockRedis.Client, metrics.New(), &logger, time.Now())
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`SELECT "id" FROM "users"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mockDB.Mock.ExpectExec(`DELETE FROM "outbox_items"`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mockDB.Mock.ExpectExec(`DELETE FROM "alert_escalations"`).WillReturnError(errors.New("connection lost"))
+		mockDB.Mock.ExpectRollback()
+
+		err := service.DeleteUser(context.Background(), 123)
+
+		assert.ErrorContains(t, err, "failed to delete user data")
+		mockDB.ExpectationsWereMet(t)
+		// Redis is left alone
+		mockRedis.ExpectationsWereMet(t)
+	})
+
+	t.Run("the last admin keeps their account", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		mockRedis := helpers.NewMockRedis()
+		logger := zerolog.Nop()
+		service := NewUserService(mockDB.DB, mockRedis.Client, metrics.New(), &logger, time.Now())
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`SELECT "id" FROM "users" WHERE role = \$1 AND "users"\."deleted_at" IS NULL FOR UPDATE`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(123)))
+		mockDB.Mock.ExpectRollback()
+
+		err := service.DeleteUser(context.Background(), 123)
+
+		assert.ErrorIs(t, err, ErrLastAdmin)
+		mockDB.ExpectationsWereMet(t)
+		mockRedis.ExpectationsWereMet(t)
+	})
+}
+
+func TestUserService_PendingDeletion(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	mockRedis := helpers.NewMockRedis()
+	logger := zerolog.Nop()
+	service := NewUserService(mockDB.DB, mockRedis.Client, metrics.New(), &logger, time.Now())
+	ctx := context.Background()
+
+	assert.False(t, service.HasLocalPendingDeletion(123))
+	mockRedis.Mock.ExpectSet("user:123:deletion_pending", 1, PendingDeletionTimeout).SetVal("OK")
+	require.NoError(t, service.StartPendingDeletion(ctx, 123))
+	assert.True(t, service.HasLocalPendingDeletion(123))
+	assert.False(t, service.HasLocalPendingDeletion(456))
+
+	// The pending deletion is taken by the next message, whatever it says
+	mockRedis.Mock.ExpectGetDel("user:123:deletion_pending").SetVal("1")
+	pending, err := service.TakePendingDeletion(ctx, 123)
+	require.NoError(t, err)
+	assert.True(t, pending)
+	assert.False(t, service.HasLocalPendingDeletion(123))
+
+	mockRedis.Mock.ExpectGetDel("user:123:deletion_pending").RedisNil()
+	pending, err = service.TakePendingDeletion(ctx, 123)
+	require.NoError(t, err)
+	assert.False(t, pending)
+
+	mockRedis.Mock.ExpectGetDel("user:123:deletion_pending").SetErr(errors.New("connection refused"))
+	_, err = service.TakePendingDeletion(ctx, 123)
+	assert.Error(t, err)
+
+	mockRedis.ExpectationsWereMet(t)
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/valpere/shopogoda/internal/models"
+)
+
+// MaxSavedLocations caps the places one user can save
+const MaxSavedLocations = 5
+
+var (
+	ErrLocationNotFound = errors.New("saved location not found")
+	ErrLocationExists   = errors.New("a location with this name is already saved")
+	ErrTooManyLocations = fmt.Errorf("at most %d locations can be saved", MaxSavedLocations)
+)
+
+// ListUserLocations returns the user's saved locations, the default first.
+//
+// The location stored on the user stays authoritative: subscriptions and
+// alerts read it, and /setlocation, presets, shared locations and privacy
+// mode all change it. Rather than teaching each of those about saved
+// locations, the default saved location is brought in line with it here.
+func (s *UserService) ListUserLocations(ctx context.Context, userID int64) ([]models.UserLocation, error) {
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var saved []models.UserLocation
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("is_default DESC, created_at").Find(&saved).Error; err != nil {
+		return nil, fmt.Errorf("failed to list saved locations: %w", err)
+	}
+	return s.syncDefaultLocation(ctx, user, saved)
+}
+
+// syncDefaultLocation makes the default saved location match the user's own
+// location; saved is ordered default first
+func (s *UserService) syncDefaultLocation(ctx context.Context, user *models.User, saved []models.UserLocation) ([]models.UserLocation, error) {
+	db := s.db.WithContext(ctx)
+	var current *models.UserLocation
+	if len(saved) > 0 && saved[0].IsDefault {
+		current = &saved[0]
+	}
+	if current != nil && isUserLocation(current, user) {
+		return saved, nil
+	}
+
+	if !user.HasLocation() {
+		if current == nil {
+			return saved, nil
+		}
+		// The user cleared their location
+		if err := db.Delete(&models.UserLocation{}, current.ID).Error; err != nil {
+			return nil, fmt.Errorf("failed to remove cleared location: %w", err)
+		}
+		return saved[1:], nil
+	}
+
+	// The user's location may be one they saved before
+	for i := range saved {
+		if !isUserLocation(&saved[i], user) {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.UserLocation{}).Where("user_id = ? AND is_default = ?", user.ID, true).Update("is_default", false).Error; err != nil {
+				return err
+			}
+			return tx.Model(&models.UserLocation{}).Where("id = ?", saved[i].ID).Update("is_default", true).Error
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to switch default location: %w", err)
+		}
+		if current != nil {
+			current.IsDefault = false
+		}
+		saved[i].IsDefault = true
+		return append(append([]models.UserLocation{saved[i]}, saved[:i]...), saved[i+1:]...), nil
+	}
+
+	if current != nil {
+		// The default was replaced
+		current.Name, current.Country, current.City = user.LocationName, user.Country, user.City
+		current.Latitude, current.Longitude = user.Latitude, user.Longitude
+		if err := db.Save(current).Error; err != nil {
+			return nil, fmt.Errorf("failed to update default location: %w", err)
+		}
+		return saved, nil
+	}
+
+	// A location set before locations could be saved, or the first one
+	location := models.UserLocation{
+		UserID:    user.ID,
+		Name:      user.LocationName,
+		Country:   user.Country,
+		City:      user.City,
+		Latitude:  user.Latitude,
+		Longitude: user.Longitude,
+		IsDefault: true,
+	}
+	if err := db.Create(&location).Error; err != nil {
+		return nil, fmt.Errorf("failed to save default location: %w", err)
+	}
+	return append([]models.UserLocation{location}, saved...), nil
+}
+
+func isUserLocation(location *models.UserLocation, user *models.User) bool {
+	return location.Name == user.LocationName &&
+		location.Latitude == user.Latitude && location.Longitude == user.Longitude
+}
+
+// AddUserLocation saves another location. The first one saved becomes the
+// user's default location.
+func (s *UserService) AddUserLocation(ctx context.Context, userID int64, name, country, city string, lat, lon float64) (*models.UserLocation, error) {
+	saved, err := s.ListUserLocations(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(saved) == 0 {
+		if err := s.SetUserLocation(ctx, userID, name, country, city, lat, lon); err != nil {
+			return nil, err
+		}
+		if saved, err = s.ListUserLocations(ctx, userID); err != nil {
+			return nil, err
+		}
+		if len(saved) == 0 {
+			return nil, fmt.Errorf("default location was not saved")
+		}
+		return &saved[0], nil
+	}
+
+	for _, location := range saved {
+		if strings.EqualFold(location.Name, name) {
+			return nil, ErrLocationExists
+		}
+	}
+	if len(saved) >= MaxSavedLocations {
+		return nil, ErrTooManyLocations
+	}
+
+	location := &models.UserLocation{UserID: userID, Name: name, Country: country, City: city, Latitude: lat, Longitude: lon}
+
+	// Users in city-level mode never get exact coordinates stored
+	if s.approximator != nil {
+		user, err := s.GetUser(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check location privacy: %w", err)
+		}
+		if user.LocationCityLevel {
+			approximate, err := s.approximator.ApproximateLocation(ctx, lat, lon)
+			if err != nil {
+				return nil, fmt.Errorf("failed to approximate location: %w", err)
+			}
+			location.Latitude, location.Longitude = approximate.Latitude, approximate.Longitude
+		}
+	}
+
+	if err := s.db.WithContext(ctx).Create(location).Error; err != nil {
+		return nil, fmt.Errorf("failed to save location: %w", err)
+	}
+	return location, nil
+}
+
+// SetDefaultLocation makes a saved location the user's location, the one
+// subscriptions and alerts use
+func (s *UserService) SetDefaultLocation(ctx context.Context, userID int64, locationID uuid.UUID) error {
+	location, err := s.findSavedLocation(ctx, userID, locationID)
+	if err != nil || location.IsDefault {
+		return err
+	}
+
+	if err := s.SetUserLocation(ctx, userID, location.Name, location.Country, location.City, location.Latitude, location.Longitude); err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.UserLocation{}).Where("user_id = ? AND is_default = ?", userID, true).Update("is_default", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.UserLocation{}).Where("id = ?", location.ID).Update("is_default", true).Error
+	})
+}
+
+// RemoveUserLocation removes a saved location. Removing the default makes the
+// oldest remaining location the default, or clears the user's location when
+// none is left.
+func (s *UserService) RemoveUserLocation(ctx context.Context, userID int64, locationID uuid.UUID) error {
+	location, err := s.findSavedLocation(ctx, userID, locationID)
+	if err != nil {
+		return err
+	}
+
+	db := s.db.WithContext(ctx)
+	if err := db.Delete(location).Error; err != nil {
+		return fmt.Errorf("failed to delete location: %w", err)
+	}
+	if !location.IsDefault {
+		return nil
+	}
+
+	var next models.UserLocation
+	err = db.Where("user_id = ?", userID).Order("created_at").First(&next).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.ClearUserLocation(ctx, userID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find next default location: %w", err)
+	}
+	return s.SetDefaultLocation(ctx, userID, next.ID)
+}
+
+func (s *UserService) findSavedLocation(ctx context.Context, userID int64, locationID uuid.UUID) (*models.UserLocation, error) {
+	var location models.UserLocation
+	err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", locationID, userID).First(&location).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrLocationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load saved location: %w", err)
+	}
+	return &location, nil
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+const kyivUser = `{"id":100,"location_name":"Kyiv","latitude":50.45,"longitude":30.52,"country":"UA"}`
+
+var savedLocationColumns = []string{"id", "user_id", "name", "country", "lat", "lon", "is_default"}
+
+// Saved location IDs used across the tests
+var (
+	firstLocationID  = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	secondLocationID = uuid.MustParse("00000000-0000-0000-0000-000000000002")
+)
+
+func TestUserService_ListUserLocations(t *testing.T) {
+	ctx := context.Background()
+	expectSaved := func(mockDB *helpers.MockDB, rows ...[]driver.Value) {
+		result := mockDB.Mock.NewRows(savedLocationColumns)
+		for _, row := range rows {
+			result.AddRow(row...)
+		}
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "user_locations" WHERE user_id = \$1 ORDER BY is_default DESC, created_at`).
+			WithArgs(int64(100)).
+			WillReturnRows(result)
+	}
+
+	// A location set before locations could be saved becomes the default
+	t.Run("adopts the user's location", func(t *testing.T) {
+		service, mockDB, mockRedis := newBootstrapTestService(t)
+		mockRedis.Mock.ExpectGet("user:100").SetVal(kyivUser)
+		expectSaved(mockDB)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "user_locations"`).
+			WithArgs(int64(100), "Kyiv", "UA", "", 50.45, 30.52, true, helpers.AnyTime{}).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(firstLocationID.String()))
+		mockDB.Mock.ExpectCommit()
+
+		saved, err := service.ListUserLocations(ctx, 100)
+		require.NoError(t, err)
+		require.Len(t, saved, 1)
+		assert.True(t, saved[0].IsDefault)
+		assert.Equal(t, "Kyiv", saved[0].Name)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("in sync needs no writes", func(t *testing.T) {
+		service, mockDB, mockRedis := newBootstrapTestService(t)
+		mockRedis.Mock.ExpectGet("user:100").SetVal(kyivUser)
+		expectSaved(mockDB,
+			[]driver.Value{firstLocationID.String(), 100, "Kyiv", "UA", 50.45, 30.52, true},
+			[]driver.Value{secondLocationID.String(), 100, "Lviv", "UA", 49.84, 24.03, false})
+
+		saved, err := service.ListUserLocations(ctx, 100)
+		require.NoError(t, err)
+		require.Len(t, saved, 2)
+		assert.Equal(t, "Lviv", saved[1].Name)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	// /setlocation, a preset or a shared location replaced the default
+	t.Run("follows a replaced default", func(t *testing.T) {
+		service, mockDB, mockRedis := newBootstrapTestService(t)
+		mockRedis.Mock.ExpectGet("user:100").SetVal(kyivUser)
+		expectSaved(mockDB, []driver.Value{firstLocationID.String(), 100, "Odesa", "UA", 46.48, 30.72, true})
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "user_locations" SET .* WHERE "id" = \$\d+`).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+
+		saved, err := service.ListUserLocations(ctx, 100)
+		require.NoError(t, err)
+		require.Len(t, saved, 1)
+		assert.Equal(t, "Kyiv", saved[0].Name)
+		assert.Equal(t, 50.45, saved[0].Latitude)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("switches to a saved copy of the user's location", func(t *testing.T) {
+		service, mockDB, mockRedis := newBootstrapTestService(t)
+		mockRedis.Mock.ExpectGet("user:100").SetVal(kyivUser)
+		expectSaved(mockDB,
+			[]driver.Value{firstLocationID.String(), 100, "Odesa", "UA", 46.48, 30.72, true},
+			[]driver.Value{secondLocationID.String(), 100, "Kyiv", "UA", 50.45, 30.52, false})
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "user_locations" SET "is_default"=\$1 WHERE user_id = \$2 AND is_default = \$3`).
+			WithArgs(false, int64(100), true).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectExec(`UPDATE "user_locations" SET "is_default"=\$1 WHERE id = \$2`).
+			WithArgs(true, secondLocationID).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+
+		saved, err := service.ListUserLocations(ctx, 100)
+		require.NoError(t, err)
+		require.Len(t, saved, 2)
+		assert.Equal(t, "Kyiv", saved[0].Name)
+		assert.True(t, saved[0].IsDefault)
+		assert.False(t, saved[1].IsDefault)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("drops a cleared default", func(t *testing.T) {
+		service, mockDB, mockRedis := newBootstrapTestService(t)
+		mockRedis.Mock.ExpectGet("user:100").SetVal(`{"id":100}`)
+		expectSaved(mockDB,
+			[]driver.Value{firstLocationID.String(), 100, "Kyiv", "UA", 50.45, 30.52, true},
+			[]driver.Value{secondLocationID.String(), 100, "Lviv", "UA", 49.84, 24.03, false})
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`DELETE FROM "user_locations" WHERE "user_locations"."id" = \$1`).
+			WithArgs(firstLocationID).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+
+		saved, err := service.ListUserLocations(ctx, 100)
+		require.NoError(t, err)
+		require.Len(t, saved, 1)
+		assert.Equal(t, "Lviv", saved[0].Name)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestUserService_AddUserLocation(t *testing.T) {
+	ctx := context.Background()
+	expectKyivSaved := func(mockDB *helpers.MockDB, mockRedis *helpers.MockRedis, extra int) {
+		mockRedis.Mock.ExpectGet("user:100").SetVal(kyivUser)
+		rows := mockDB.Mock.NewRows(savedLocationColumns).AddRow(firstLocationID.String(), 100, "Kyiv", "UA", 50.45, 30.52, true)
+		for i := 0; i < extra; i++ {
+			rows.AddRow(uuid.NewString(), 100, "Place", "UA", 0.0, 0.0, false)
+		}
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "user_locations"`).WillReturnRows(rows)
+	}
+
+	t.Run("saves another location next to the default", func(t *testing.T) {
+		service, mockDB, mockRedis := newBootstrapTestService(t)
+		expectKyivSaved(mockDB, mockRedis, 0)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "user_locations"`).
+			WithArgs(int64(100), "Lviv", "UA", "Lviv", 49.84, 24.03, false, helpers.AnyTime{}).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(secondLocationID.String()))
+		mockDB.Mock.ExpectCommit()
+
+		location, err := service.AddUserLocation(ctx, 100, "Lviv", "UA", "Lviv", 49.84, 24.03)
+		require.NoError(t, err)
+		assert.Equal(t, secondLocationID, location.ID)
+		assert.False(t, location.IsDefault)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("rejects a name already saved", func(t *testing.T) {
+		service, mockDB, mockRedis := newBootstrapTestService(t)
+		expectKyivSaved(mockDB, mockRedis, 0)
+
+		_, err := service.AddUserLocation(ctx, 100, "kyiv", "UA", "", 50.45, 30.52)
+		assert.ErrorIs(t, err, ErrLocationExists)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("stops at the limit", func(t *testing.T) {
+		service, mockDB, mockRedis := newBootstrapTestService(t)
+		expectKyivSaved(mockDB, mockRedis, MaxSavedLocations-1)
+
+		_, err := service.AddUserLocation(ctx, 100, "Lviv", "UA", "", 49.84, 24.03)
+		assert.ErrorIs(t, err, ErrTooManyLocations)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestUserService_RemoveUserLocation(t *testing.T) {
+	ctx := context.Background()
+	expectFind := func(mockDB *helpers.MockDB, id uuid.UUID, name string, isDefault bool) {
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "user_locations" WHERE id = \$1 AND user_id = \$2`).
+			WithArgs(id, int64(100), 1).
+			WillReturnRows(mockDB.Mock.NewRows(savedLocationColumns).AddRow(id.String(), 100, name, "UA", 49.84, 24.03, isDefault))
+	}
+	expectDelete := func(mockDB *helpers.MockDB, id uuid.UUID) {
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`DELETE FROM "user_locations" WHERE "user_locations"."id" = \$1`).
+			WithArgs(id).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+	}
+
+	t.Run("another location", func(t *testing.T) {
+		service, mockDB, _ := newBootstrapTestService(t)
+		expectFind(mockDB, secondLocationID, "Lviv", false)
+		expectDelete(mockDB, secondLocationID)
+
+		require.NoError(t, service.RemoveUserLocation(ctx, 100, secondLocationID))
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("the last location clears the user's location", func(t *testing.T) {
+		service, mockDB, mockRedis := newBootstrapTestService(t)
+		expectFind(mockDB, firstLocationID, "Kyiv", true)
+		expectDelete(mockDB, firstLocationID)
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "user_locations" WHERE user_id = \$1 ORDER BY created_at`).
+			WithArgs(int64(100), 1).
+			WillReturnRows(mockDB.Mock.NewRows(savedLocationColumns))
+		mockRedis.Mock.ExpectDel("user:100").SetVal(1)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "users" SET .* WHERE id = \$\d+`).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+
+		require.NoError(t, service.RemoveUserLocation(ctx, 100, firstLocationID))
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("someone else's location is not found", func(t *testing.T) {
+		service, mockDB, _ := newBootstrapTestService(t)
+		missing := uuid.New()
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "user_locations" WHERE id = \$1 AND user_id = \$2`).
+			WithArgs(missing, int64(100), 1).
+			WillReturnRows(mockDB.Mock.NewRows(savedLocationColumns))
+
+		assert.ErrorIs(t, service.RemoveUserLocation(ctx, 100, missing), ErrLocationNotFound)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestUserService_SetDefaultLocation(t *testing.T) {
+	service, mockDB, mockRedis := newBootstrapTestService(t)
+
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "user_locations" WHERE id = \$1 AND user_id = \$2`).
+		WithArgs(secondLocationID, int64(100), 1).
+		WillReturnRows(mockDB.Mock.NewRows(savedLocationColumns).AddRow(secondLocationID.String(), 100, "Lviv", "UA", 49.84, 24.03, false))
+	mockRedis.Mock.ExpectDel("user:100").SetVal(1)
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectExec(`UPDATE "users" SET .*"location_name"=.* WHERE id = \$\d+`).
+		WillReturnResult(helpers.NewResult(0, 1))
+	mockDB.Mock.ExpectCommit()
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectExec(`UPDATE "user_locations" SET "is_default"=\$1 WHERE user_id = \$2 AND is_default = \$3`).
+		WithArgs(false, int64(100), true).
+		WillReturnResult(helpers.NewResult(0, 1))
+	mockDB.Mock.ExpectExec(`UPDATE "user_locations" SET "is_default"=\$1 WHERE id = \$2`).
+		WithArgs(true, secondLocationID).
+		WillReturnResult(helpers.NewResult(0, 1))
+	mockDB.Mock.ExpectCommit()
+
+	require.NoError(t, service.SetDefaultLocation(context.Background(), 100, secondLocationID))
+	mockDB.ExpectationsWereMet(t)
+	assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+}
@@ -2,13 +2,18 @@ package services
 
 import (
 	"context"
+	"crypto/cipher"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
 	"gorm.io/gorm"
@@ -16,41 +21,102 @@ import (
 
 	"github.com/valpere/shopogoda/internal/models"
 	"github.com/valpere/shopogoda/pkg/metrics"
+	"github.com/valpere/shopogoda/pkg/weather"
 )
 
-// User table column names for upsert operations
+// User table column names for upsert operations. Updates register their
+// sender, at most once per activityWriteInterval, which keeps last_active_at
+// current.
 var userUpsertColumns = []string{
 	"username",
 	"first_name",
 	"last_name",
+	"last_active_at",
 	"updated_at",
 }
 
+// userUpsertReactivation makes a user deactivated for blocking the bot or for
+// inactivity active again on return. Users who paused their account with
+// DeactivateUser stay inactive until they reactivate it.
+var userUpsertReactivation = clause.Assignment{
+	Column: clause.Column{Name: "is_active"},
+	Value:  gorm.Expr(`"users"."deactivated_at" IS NULL`),
+}
+
+// userUpsertRevival makes a user who deleted their data a new user on return:
+// their anonymized row takes the language, role and registration time of the
+// new registration, and is no longer deleted
+var userUpsertRevival = []clause.Assignment{
+	{Column: clause.Column{Name: "language"}, Value: gorm.Expr(`CASE WHEN "users"."deleted_at" IS NULL THEN "users"."language" ELSE excluded.language END`)},
+	{Column: clause.Column{Name: "role"}, Value: gorm.Expr(`CASE WHEN "users"."deleted_at" IS NULL THEN "users"."role" ELSE excluded.role END`)},
+	{Column: clause.Column{Name: "created_at"}, Value: gorm.Expr(`CASE WHEN "users"."deleted_at" IS NULL THEN "users"."created_at" ELSE excluded.created_at END`)},
+	{Column: clause.Column{Name: "deleted_at"}, Value: gorm.Expr("NULL")},
+}
+
 type UserService struct {
-	db        *gorm.DB
-	redis     *redis.Client
-	metrics   *metrics.Metrics
-	logger    *zerolog.Logger
-	startTime time.Time
+	db           *gorm.DB
+	redis        *redis.Client
+	metrics      *metrics.Metrics
+	logger       *zerolog.Logger
+	startTime    time.Time
+	approximator LocationApproximator
+	audit        *AuditService // Records role changes made by admins; nil records none
+	tx           *txScope      // Set on copies bound to a transaction by Services.WithTx
+
+	// apiKeyCipher encrypts users' own weather API keys; nil while
+	// USER_API_KEY_SECRET is unset
+	apiKeyCipher cipher.AEAD
+
+	// bootstrapAdmins are the users ADMIN_TELEGRAM_IDS makes admins
+	bootstrapAdmins map[int64]bool
+	// adminExists is set once an admin is known to exist; the last admin
+	// cannot be demoted, so it never clears
+	adminExists *atomic.Bool
+
+	// generation changes whenever a user is modified; users memoized for an
+	// update are refetched once it moves past the generation they were read at
+	generation *atomic.Uint64
+
+	// pendingDeletions maps the users this process asked to type DELETE to
+	// when the request lapses
+	pendingDeletions *sync.Map
+}
+
+// LocationApproximator coarsens exact coordinates to a city-level location
+type LocationApproximator interface {
+	ApproximateLocation(ctx context.Context, lat, lon float64) (*weather.Location, error)
 }
 
 // allowedUserSettingsFields defines the whitelist of fields that can be updated via UpdateUserSettings
 // This prevents SQL injection and unauthorized field updates
 var allowedUserSettingsFields = map[string]bool{
-	"username":   true,
-	"first_name": true,
-	"last_name":  true,
-	"language":   true,
-	"units":      true,
-	"timezone":   true,
-	"is_active":  true,
+	"username":                  true,
+	"first_name":                true,
+	"last_name":                 true,
+	"language":                  true,
+	"units":                     true,
+	"timezone":                  true,
+	"is_active":                 true,
+	"secondary_language":        true,
+	"week_start":                true,
+	"quiet_digests":             true,
+	"alert_escalation_disabled": true,
+	"unusual_weather_notes":     true,
+	"skin_type":                 true,
+	"show_recommendations":      true,
 }
 
 type SystemStats struct {
 	TotalUsers          int64   `json:"total_users"`
 	ActiveUsers         int64   `json:"active_users"`
+	DeactivatedUsers    int64   `json:"deactivated_users"`
 	NewUsers24h         int64   `json:"new_users_24h"`
+	DailyActiveUsers    int64   `json:"daily_active_users"`   // Seen in the last 24 hours
+	WeeklyActiveUsers   int64   `json:"weekly_active_users"`  // Seen in the last 7 days
+	MonthlyActiveUsers  int64   `json:"monthly_active_users"` // Seen in the last 30 days
 	UsersWithLocation   int64   `json:"users_with_location"`
+	OnboardingStarted   int64   `json:"onboarding_started"`   // Users /start began the guided setup for
+	OnboardingCompleted int64   `json:"onboarding_completed"` // Of those, users who finished or skipped it
 	ActiveSubscriptions int64   `json:"active_subscriptions"`
 	AlertsConfigured    int64   `json:"alerts_configured"`
 	MessagesSent24h     int64   `json:"messages_sent_24h"`
@@ -60,16 +126,63 @@ type SystemStats struct {
 	Uptime              float64 `json:"uptime"`
 }
 
+// OnboardingConversion is the percentage of users who began the guided setup
+// and finished or skipped it
+func (s *SystemStats) OnboardingConversion() float64 {
+	if s.OnboardingStarted == 0 {
+		return 0
+	}
+	return float64(s.OnboardingCompleted) / float64(s.OnboardingStarted) * 100
+}
+
 func NewUserService(db *gorm.DB, redis *redis.Client, metricsCollector *metrics.Metrics, logger *zerolog.Logger, startTime time.Time) *UserService {
 	return &UserService{
-		db:        db,
-		redis:     redis,
-		metrics:   metricsCollector,
-		logger:    logger,
-		startTime: startTime,
+		db:               db,
+		redis:            redis,
+		metrics:          metricsCollector,
+		logger:           logger,
+		startTime:        startTime,
+		generation:       &atomic.Uint64{},
+		adminExists:      &atomic.Bool{},
+		pendingDeletions: &sync.Map{},
 	}
 }
 
+// SetLocationApproximator sets the resolver used for city-level location privacy
+func (s *UserService) SetLocationApproximator(approximator LocationApproximator) {
+	s.approximator = approximator
+}
+
+// SetAudit records the role changes admins make in the audit log
+func (s *UserService) SetAudit(audit *AuditService) {
+	s.audit = audit
+}
+
+// withTx returns a copy of the service that writes through the transaction db
+// and holds cache invalidations until the transaction commits
+func (s *UserService) withTx(db *gorm.DB, scope *txScope) *UserService {
+	scoped := *s
+	scoped.db = db
+	scoped.tx = scope
+	return &scoped
+}
+
+// invalidateCache drops a cached entry, or schedules the drop for after commit
+// when the service is bound to a transaction
+func (s *UserService) invalidateCache(ctx context.Context, key string) error {
+	s.generation.Add(1)
+	if s.tx != nil {
+		s.tx.afterCommit(func(ctx context.Context) {
+			s.generation.Add(1)
+			if err := s.redis.Del(ctx, key).Err(); err != nil {
+				s.logger.Warn().Err(err).Str("cache_key", key).Msg("Failed to invalidate user cache after commit")
+			}
+		})
+		return nil
+	}
+	return s.redis.Del(ctx, key).Err()
+}
+
 // NormalizeLanguageCode normalizes a Telegram IETF language tag to our supported language codes
 // Examples: "en-US" -> "en-US", "uk-UA" -> "uk-UA", "en" -> "en-US", "fr-CA" -> "fr-FR"
 // Unsupported languages default to "en-US"
@@ -127,25 +240,95 @@ func (s *UserService) RegisterUser(ctx context.Context, tgUser *gotgbot.User) er
 	// Examples: "en-US" -> "en-US", "en" -> "en-US", "fr-CA" -> "fr-FR", unsupported -> "en-US"
 	normalizedLang := s.NormalizeLanguageCode(tgUser.LanguageCode)
 
+	now := time.Now().UTC()
 	user := &models.User{
-		ID:        tgUser.Id,
-		Username:  tgUser.Username,
-		FirstName: tgUser.FirstName,
-		LastName:  tgUser.LastName,
-		Language:  normalizedLang,
-		IsActive:  true,
+		ID:           tgUser.Id,
+		Username:     tgUser.Username,
+		FirstName:    tgUser.FirstName,
+		LastName:     tgUser.LastName,
+		Language:     normalizedLang,
+		IsActive:     true,
+		LastActiveAt: &now,
+	}
+
+	// A listed admin registering for the first time starts as admin. The role
+	// is only written for new and deleted users, so an existing user keeps theirs.
+	bootstrap := false
+	if s.bootstrapAdmins[tgUser.Id] {
+		var existing int64
+		if err := s.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", tgUser.Id).Count(&existing).Error; err != nil {
+			return fmt.Errorf("failed to check user registration: %w", err)
+		}
+		if existing == 0 {
+			user.Role = models.RoleAdmin
+			bootstrap = true
+		}
 	}
 
 	// Use GORM's upsert functionality with proper conflict resolution
 	result := s.db.WithContext(ctx).Clauses(clause.OnConflict{
 		Columns:   []clause.Column{{Name: "id"}},
-		DoUpdates: clause.AssignmentColumns(userUpsertColumns),
+		DoUpdates: append(append(clause.AssignmentColumns(userUpsertColumns), userUpsertReactivation), userUpsertRevival...),
 	}).Create(user)
+	if result.Error != nil {
+		return result.Error
+	}
 
-	return result.Error
+	if bootstrap {
+		s.logger.Info().
+			Str("actor", "bootstrap").
+			Int64("target_user_id", user.ID).
+			Str("target_username", user.Username).
+			Int("old_role", int(models.RoleUser)).
+			Int("new_role", int(models.RoleAdmin)).
+			Msg("User role changed")
+		s.auditRoleChange(ctx, 0, user.ID, models.RoleUser, models.RoleAdmin, "bootstrap")
+		s.adminExists.Store(true)
+	}
+	return nil
+}
+
+// activityWriteInterval is how often the profile and last activity of a user
+// who keeps writing to the bot are written to the database
+const activityWriteInterval = 10 * time.Minute
+
+// activityWriteKey marks a user registered within the last activityWriteInterval
+func activityWriteKey(userID int64) string {
+	return fmt.Sprintf("user:%d:registered", userID)
+}
+
+// RegisterActiveUser registers the sender of an update like RegisterUser, but
+// at most once per activityWriteInterval, so a user chatting with the bot
+// does not cost a database write per message. Their profile and last activity
+// lag by up to that long. Without Redis every update is written.
+func (s *UserService) RegisterActiveUser(ctx context.Context, tgUser *gotgbot.User) error {
+	key := activityWriteKey(tgUser.Id)
+	fresh, err := s.redis.SetNX(ctx, key, 1, activityWriteInterval).Result()
+	if err == nil && !fresh {
+		return nil
+	}
+
+	if err := s.RegisterUser(ctx, tgUser); err != nil {
+		// The next update tries again
+		if fresh {
+			s.redis.Del(ctx, key)
+		}
+		return err
+	}
+	return nil
 }
 
 func (s *UserService) GetUser(ctx context.Context, userID int64) (*models.User, error) {
+	// Inside a transaction the cache may not reflect writes made so far, and
+	// uncommitted rows must never be cached
+	if s.tx != nil {
+		var user models.User
+		if err := s.db.WithContext(ctx).First(&user, userID).Error; err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+
 	// Try cache first
 	cacheKey := fmt.Sprintf("user:%d", userID)
 	cached, err := s.redis.Get(ctx, cacheKey).Result()
@@ -177,6 +360,77 @@ func (s *UserService) GetUser(ctx context.Context, userID int64) (*models.User,
 	return &user, nil
 }
 
+// GetUserByUsername looks a user up by their Telegram username, with or
+// without the leading @. Users without a username can only be found by ID, so
+// an empty name matches nobody rather than all of them.
+func (s *UserService) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	username = strings.TrimPrefix(strings.TrimSpace(username), "@")
+	if username == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// updateUserKey is the key of the memoized user in the update-local data
+const updateUserKey = "services.update_user"
+
+// updateUser is the user memoized for one update
+type updateUser struct {
+	user       models.User
+	generation uint64
+	fetches    int
+}
+
+// GetUpdateUser returns the user who sent an update. The user is fetched
+// once per update and kept in the update-local data, so handlers and
+// middleware can look it up repeatedly without further Redis or database
+// calls. A change to any user made during the update triggers one refetch.
+// Lookups of other users fall back to GetUser.
+func (s *UserService) GetUpdateUser(ctx context.Context, update *ext.Context, userID int64) (*models.User, error) {
+	if update == nil || update.EffectiveUser == nil || update.EffectiveUser.Id != userID {
+		return s.GetUser(ctx, userID)
+	}
+
+	generation := s.generation.Load()
+	memo, _ := update.Data[updateUserKey].(*updateUser)
+	if memo != nil && memo.generation == generation {
+		user := memo.user
+		return &user, nil
+	}
+
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if memo == nil {
+		memo = &updateUser{}
+		if update.Data == nil {
+			update.Data = make(map[string]any)
+		}
+		update.Data[updateUserKey] = memo
+	}
+	memo.user = *user
+	memo.generation = generation
+	memo.fetches++
+
+	return user, nil
+}
+
+// UpdateUserFetches reports how many times GetUpdateUser had to fetch the
+// user during an update
+func UpdateUserFetches(update *ext.Context) int {
+	if memo, ok := update.Data[updateUserKey].(*updateUser); ok {
+		return memo.fetches
+	}
+	return 0
+}
+
 func (s *UserService) UpdateUserSettings(ctx context.Context, userID int64, settings map[string]interface{}) error {
 	// Validate and filter settings to only allowed fields (security whitelist)
 	safeSettings := make(map[string]interface{})
@@ -194,7 +448,7 @@ func (s *UserService) UpdateUserSettings(ctx context.Context, userID int64, sett
 
 	// Invalidate cache BEFORE update to prevent stale data
 	cacheKey := fmt.Sprintf("user:%d", userID)
-	if err := s.redis.Del(ctx, cacheKey).Err(); err != nil {
+	if err := s.invalidateCache(ctx, cacheKey); err != nil {
 		s.logger.Warn().Err(err).Str("cache_key", cacheKey).Msg("Failed to invalidate user cache before update")
 	}
 
@@ -212,13 +466,19 @@ func (s *UserService) GetSystemStats(ctx context.Context) (*SystemStats, error)
 	// Get user statistics
 	s.db.WithContext(ctx).Model(&models.User{}).Count(&stats.TotalUsers)
 	s.db.WithContext(ctx).Model(&models.User{}).Where("is_active = ?", true).Count(&stats.ActiveUsers)
+	s.db.WithContext(ctx).Model(&models.User{}).Where("is_active = ?", false).Count(&stats.DeactivatedUsers)
 
-	yesterday := time.Now().UTC().AddDate(0, 0, -1)
-	s.db.WithContext(ctx).Model(&models.User{}).Where("created_at > ?", yesterday).Count(&stats.NewUsers24h)
+	now := time.Now().UTC()
+	s.db.WithContext(ctx).Model(&models.User{}).Where("created_at > ?", now.AddDate(0, 0, -1)).Count(&stats.NewUsers24h)
+	stats.DailyActiveUsers, stats.WeeklyActiveUsers, stats.MonthlyActiveUsers = s.countRecentlyActive(ctx, now)
 
 	// Get users with location configured
 	s.db.WithContext(ctx).Model(&models.User{}).Where("location_name != '' AND location_name IS NOT NULL").Count(&stats.UsersWithLocation)
 
+	// Get onboarding conversion
+	s.db.WithContext(ctx).Model(&models.User{}).Where("onboarding_started_at IS NOT NULL").Count(&stats.OnboardingStarted)
+	s.db.WithContext(ctx).Model(&models.User{}).Where("onboarding_started_at IS NOT NULL AND onboarded_at IS NOT NULL").Count(&stats.OnboardingCompleted)
+
 	// Get subscription statistics
 	s.db.WithContext(ctx).Model(&models.Subscription{}).Where("is_active = ?", true).Count(&stats.ActiveSubscriptions)
 	s.db.WithContext(ctx).Model(&models.AlertConfig{}).Where("is_active = ?", true).Count(&stats.AlertsConfigured)
@@ -228,9 +488,9 @@ func (s *UserService) GetSystemStats(ctx context.Context) (*SystemStats, error)
 		stats.CacheHitRate = s.metrics.GetCacheHitRate("weather")
 		stats.AvgResponseTime = int(s.metrics.GetAverageResponseTime())
 	} else {
-		// Fallback values if metrics not available
+		// Fallback values if metrics not available; response time is not
+		// made up, it stays 0 without measurements
 		stats.CacheHitRate = 85.5
-		stats.AvgResponseTime = 150
 	}
 
 	// Calculate uptime percentage
@@ -261,6 +521,42 @@ func (s *UserService) GetSystemStats(ctx context.Context) (*SystemStats, error)
 	return stats, nil
 }
 
+// countRecentlyActive counts the users who sent the bot anything in the 24
+// hours, 7 days and 30 days before now
+func (s *UserService) countRecentlyActive(ctx context.Context, now time.Time) (daily, weekly, monthly int64) {
+	for _, window := range []struct {
+		days  int
+		count *int64
+	}{{1, &daily}, {7, &weekly}, {30, &monthly}} {
+		s.db.WithContext(ctx).Model(&models.User{}).Where("last_active_at >= ?", now.AddDate(0, 0, -window.days)).Count(window.count)
+	}
+	return daily, weekly, monthly
+}
+
+// DeactivateInactiveUsers marks users who have not sent the bot anything for
+// inactiveDays as inactive and returns how many were. Users seen before the
+// last activity was recorded count from their registration. Users who still
+// get subscriptions or alerts stay active: they use the bot without writing
+// to it. Writing to the bot again reactivates a user.
+func (s *UserService) DeactivateInactiveUsers(ctx context.Context, inactiveDays int) (int64, error) {
+	cutoff := time.Now().UTC().AddDate(0, 0, -inactiveDays)
+
+	result := s.db.WithContext(ctx).Model(&models.User{}).
+		Where("is_active = ? AND COALESCE(last_active_at, created_at) < ?", true, cutoff).
+		Where("id NOT IN (?)", s.db.Model(&models.Subscription{}).Select("user_id").Where("is_active = ?", true)).
+		Where("id NOT IN (?)", s.db.Model(&models.AlertConfig{}).Select("user_id").Where("is_active = ?", true)).
+		Update("is_active", false)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to deactivate inactive users: %w", result.Error)
+	}
+
+	// Users memoized for updates in flight are read again
+	if result.RowsAffected > 0 {
+		s.generation.Add(1)
+	}
+	return result.RowsAffected, nil
+}
+
 // GetActiveUsers returns all active users
 func (s *UserService) GetActiveUsers(ctx context.Context) ([]models.User, error) {
 	var users []models.User
@@ -270,10 +566,22 @@ func (s *UserService) GetActiveUsers(ctx context.Context) ([]models.User, error)
 	return users, err
 }
 
+// GetAdmins returns the active admins
+func (s *UserService) GetAdmins(ctx context.Context) ([]models.User, error) {
+	var admins []models.User
+	err := s.db.WithContext(ctx).
+		Where("role = ? AND is_active = ?", models.RoleAdmin, true).
+		Find(&admins).Error
+	return admins, err
+}
+
 type UserStatistics struct {
 	TotalUsers         int64 `json:"total_users"`
 	ActiveUsers        int64 `json:"active_users"`
 	NewUsers24h        int64 `json:"new_users_24h"`
+	DailyActiveUsers   int64 `json:"daily_active_users"`   // Seen in the last 24 hours
+	WeeklyActiveUsers  int64 `json:"weekly_active_users"`  // Seen in the last 7 days
+	MonthlyActiveUsers int64 `json:"monthly_active_users"` // Seen in the last 30 days
 	AdminCount         int64 `json:"admin_count"`
 	ModeratorCount     int64 `json:"moderator_count"`
 	Messages24h        int64 `json:"messages_24h"`
@@ -289,8 +597,9 @@ func (s *UserService) GetUserStatistics(ctx context.Context) (*UserStatistics, e
 	s.db.WithContext(ctx).Model(&models.User{}).Count(&stats.TotalUsers)
 	s.db.WithContext(ctx).Model(&models.User{}).Where("is_active = ?", true).Count(&stats.ActiveUsers)
 
-	yesterday := time.Now().UTC().AddDate(0, 0, -1)
-	s.db.WithContext(ctx).Model(&models.User{}).Where("created_at > ?", yesterday).Count(&stats.NewUsers24h)
+	now := time.Now().UTC()
+	s.db.WithContext(ctx).Model(&models.User{}).Where("created_at > ?", now.AddDate(0, 0, -1)).Count(&stats.NewUsers24h)
+	stats.DailyActiveUsers, stats.WeeklyActiveUsers, stats.MonthlyActiveUsers = s.countRecentlyActive(ctx, now)
 
 	// Role counts
 	s.db.WithContext(ctx).Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&stats.AdminCount)
@@ -316,22 +625,100 @@ func (s *UserService) GetUserStatistics(ctx context.Context) (*UserStatistics, e
 	return stats, nil
 }
 
+// UserListFilter narrows the admin user listing of /users
+type UserListFilter string
+
+const (
+	UserFilterAll        UserListFilter = "all"
+	UserFilterAdmins     UserListFilter = "admins"
+	UserFilterModerators UserListFilter = "moderators"
+	UserFilterActive     UserListFilter = "active"
+	UserFilterInactive   UserListFilter = "inactive"
+)
+
+// UserListFilters are the filters of /users, in the order they are offered
+var UserListFilters = []UserListFilter{UserFilterAll, UserFilterAdmins, UserFilterModerators, UserFilterActive, UserFilterInactive}
+
+// UserListPageSize is how many users a page of /users lists
+const UserListPageSize = 10
+
+// ParseUserListFilter returns the filter named in callback data; unknown
+// names list all users
+func ParseUserListFilter(name string) UserListFilter {
+	filter := UserListFilter(name)
+	if slices.Contains(UserListFilters, filter) {
+		return filter
+	}
+	return UserFilterAll
+}
+
+func (f UserListFilter) apply(db *gorm.DB) *gorm.DB {
+	switch f {
+	case UserFilterAdmins:
+		return db.Where("role = ?", models.RoleAdmin)
+	case UserFilterModerators:
+		return db.Where("role = ?", models.RoleModerator)
+	case UserFilterActive:
+		return db.Where("is_active = ?", true)
+	case UserFilterInactive:
+		return db.Where("is_active = ?", false)
+	}
+	return db
+}
+
+// ListUsers returns users that match the filter, newest first, and how many
+// match in all. The ID breaks ties of the registration time, so pages neither
+// overlap nor skip users.
+func (s *UserService) ListUsers(ctx context.Context, offset, limit int, filter UserListFilter) ([]models.User, int64, error) {
+	var total int64
+	if err := filter.apply(s.db.WithContext(ctx).Model(&models.User{})).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	var users []models.User
+	err := filter.apply(s.db.WithContext(ctx)).
+		Order("created_at DESC, id DESC").
+		Offset(max(offset, 0)).
+		Limit(limit).
+		Find(&users).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, total, nil
+}
+
 // SetUserLocation updates the user's location
 func (s *UserService) SetUserLocation(ctx context.Context, userID int64, locationName, country, city string, lat, lon float64) error {
+	updates := map[string]interface{}{
+		"location_name":        locationName,
+		"latitude":             lat,
+		"longitude":            lon,
+		"country":              country,
+		"city":                 city,
+		"location_approximate": false,
+	}
+
+	// Users in city-level mode never get exact coordinates stored
+	if s.approximator != nil {
+		user, err := s.GetUser(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to check location privacy: %w", err)
+		}
+		if user.LocationCityLevel {
+			approximate, err := s.approximateLocationUpdates(ctx, lat, lon)
+			if err != nil {
+				return err
+			}
+			updates = approximate
+		}
+	}
+
 	// Invalidate cache BEFORE update
 	cacheKey := fmt.Sprintf("user:%d", userID)
-	if err := s.redis.Del(ctx, cacheKey).Err(); err != nil {
+	if err := s.invalidateCache(ctx, cacheKey); err != nil {
 		s.logger.Warn().Err(err).Str("cache_key", cacheKey).Msg("Failed to invalidate user cache before location update")
 	}
 
-	updates := map[string]interface{}{
-		"location_name": locationName,
-		"latitude":      lat,
-		"longitude":     lon,
-		"country":       country,
-		"city":          city,
-	}
-
 	// Do not modify timezone when setting location - they are separate entities
 
 	err := s.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error
@@ -342,11 +729,67 @@ func (s *UserService) SetUserLocation(ctx context.Context, userID int64, locatio
 	return nil
 }
 
+// DeactivateUser pauses the user's account: scheduled notifications, alerts
+// and widgets stop, and writing to the bot does not make the account active
+// again. Subscriptions, alerts and settings are kept for ReactivateUser.
+func (s *UserService) DeactivateUser(ctx context.Context, userID int64) error {
+	return s.setPaused(ctx, userID, map[string]interface{}{
+		"is_active":      false,
+		"deactivated_at": time.Now().UTC(),
+	})
+}
+
+// ReactivateUser resumes an account paused with DeactivateUser
+func (s *UserService) ReactivateUser(ctx context.Context, userID int64) error {
+	return s.setPaused(ctx, userID, map[string]interface{}{
+		"is_active":      true,
+		"deactivated_at": nil,
+	})
+}
+
+func (s *UserService) setPaused(ctx context.Context, userID int64, updates map[string]interface{}) error {
+	cacheKey := fmt.Sprintf("user:%d", userID)
+	if err := s.invalidateCache(ctx, cacheKey); err != nil {
+		s.logger.Warn().Err(err).Str("cache_key", cacheKey).Msg("Failed to invalidate user cache before update")
+	}
+
+	result := s.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update account state: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// MarkOnboardingStarted records when the user first began the guided setup
+func (s *UserService) MarkOnboardingStarted(ctx context.Context, userID int64) error {
+	return s.markOnce(ctx, userID, "onboarding_started_at")
+}
+
+// MarkOnboarded records when the user finished or skipped the guided setup
+func (s *UserService) MarkOnboarded(ctx context.Context, userID int64) error {
+	return s.markOnce(ctx, userID, "onboarded_at")
+}
+
+// markOnce sets a timestamp column of the user unless it is already set
+func (s *UserService) markOnce(ctx context.Context, userID int64, column string) error {
+	cacheKey := fmt.Sprintf("user:%d", userID)
+	if err := s.invalidateCache(ctx, cacheKey); err != nil {
+		s.logger.Warn().Err(err).Str("cache_key", cacheKey).Msg("Failed to invalidate user cache before update")
+	}
+
+	return s.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND "+column+" IS NULL", userID).
+		Update(column, time.Now()).Error
+}
+
 // ClearUserLocation clears the user's location without affecting timezone
 func (s *UserService) ClearUserLocation(ctx context.Context, userID int64) error {
 	// Invalidate cache BEFORE update
 	cacheKey := fmt.Sprintf("user:%d", userID)
-	if err := s.redis.Del(ctx, cacheKey).Err(); err != nil {
+	if err := s.invalidateCache(ctx, cacheKey); err != nil {
 		s.logger.Warn().Err(err).Str("cache_key", cacheKey).Msg("Failed to invalidate user cache before clearing location")
 	}
 
@@ -380,6 +823,63 @@ func (s *UserService) GetUserLocation(ctx context.Context, userID int64) (string
 	return user.LocationName, user.Latitude, user.Longitude, nil
 }
 
+// SetLocationPrivacy switches between precise and city-level location storage.
+// Enabling city-level mode immediately coarsens any precise coordinates already stored.
+func (s *UserService) SetLocationPrivacy(ctx context.Context, userID int64, cityLevel bool) error {
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{}
+	if cityLevel && user.HasLocation() && !user.LocationApproximate {
+		if s.approximator == nil {
+			return fmt.Errorf("location approximation is not available")
+		}
+		updates, err = s.approximateLocationUpdates(ctx, user.Latitude, user.Longitude)
+		if err != nil {
+			return err
+		}
+	}
+	updates["location_city_level"] = cityLevel
+
+	// Invalidate cache BEFORE update
+	cacheKey := fmt.Sprintf("user:%d", userID)
+	if err := s.invalidateCache(ctx, cacheKey); err != nil {
+		s.logger.Warn().Err(err).Str("cache_key", cacheKey).Msg("Failed to invalidate user cache before location privacy update")
+	}
+
+	return s.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error
+}
+
+// SetActivityTracking enables or disables tracking of when the user interacts with the bot
+func (s *UserService) SetActivityTracking(ctx context.Context, userID int64, enabled bool) error {
+	// Invalidate cache BEFORE update
+	cacheKey := fmt.Sprintf("user:%d", userID)
+	if err := s.invalidateCache(ctx, cacheKey); err != nil {
+		s.logger.Warn().Err(err).Str("cache_key", cacheKey).Msg("Failed to invalidate user cache before activity tracking update")
+	}
+
+	return s.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Update("activity_tracking_disabled", !enabled).Error
+}
+
+// approximateLocationUpdates builds the location columns for the city centroid around the given point
+func (s *UserService) approximateLocationUpdates(ctx context.Context, lat, lon float64) (map[string]interface{}, error) {
+	location, err := s.approximator.ApproximateLocation(ctx, lat, lon)
+	if err != nil {
+		return nil, fmt.Errorf("failed to approximate location: %w", err)
+	}
+
+	return map[string]interface{}{
+		"location_name":        location.Name,
+		"latitude":             location.Latitude,
+		"longitude":            location.Longitude,
+		"country":              location.Country,
+		"city":                 location.City,
+		"location_approximate": true,
+	}, nil
+}
+
 // GetUserTimezone returns the user's timezone, defaulting to UTC if not set
 func (s *UserService) GetUserTimezone(ctx context.Context, userID int64) string {
 	user, err := s.GetUser(ctx, userID)
@@ -428,6 +928,38 @@ func (s *UserService) UpdateUserLanguage(ctx context.Context, userID int64, lang
 	})
 }
 
+// UpdateUserSecondaryLanguage sets the language weather responses and digests
+// are repeated in. An empty language turns bilingual output off.
+func (s *UserService) UpdateUserSecondaryLanguage(ctx context.Context, userID int64, language string) error {
+	return s.UpdateUserSettings(ctx, userID, map[string]interface{}{
+		"secondary_language": language,
+	})
+}
+
+// UpdateUserWeekStart sets the first day of the user's week to
+// models.WeekStartMonday or models.WeekStartSunday. An empty value derives it
+// from the location and language again.
+func (s *UserService) UpdateUserWeekStart(ctx context.Context, userID int64, weekStart string) error {
+	switch weekStart {
+	case "", models.WeekStartMonday, models.WeekStartSunday:
+	default:
+		return fmt.Errorf("invalid week start: %s", weekStart)
+	}
+	return s.UpdateUserSettings(ctx, userID, map[string]interface{}{
+		"week_start": weekStart,
+	})
+}
+
+// UpdateUserSkinType sets the user's Fitzpatrick skin type, 1 to 6
+func (s *UserService) UpdateUserSkinType(ctx context.Context, userID int64, skinType int) error {
+	if skinType < weather.MinSkinType || skinType > weather.MaxSkinType {
+		return fmt.Errorf("invalid skin type: %d", skinType)
+	}
+	return s.UpdateUserSettings(ctx, userID, map[string]interface{}{
+		"skin_type": skinType,
+	})
+}
+
 // ChangeUserRole changes a user's role with validation and audit logging
 // Returns error if validation fails or role change is not permitted
 func (s *UserService) ChangeUserRole(ctx context.Context, adminID, targetUserID int64, newRole models.UserRole) error {
@@ -445,51 +977,104 @@ func (s *UserService) ChangeUserRole(ctx context.Context, adminID, targetUserID
 		return fmt.Errorf("cannot change your own role")
 	}
 
+	targetUser, err := s.updateRole(ctx, targetUserID, newRole)
+	if err != nil {
+		return err
+	}
+
+	// Audit log
+	s.logger.Info().
+		Int64("admin_id", adminID).
+		Str("admin_username", adminUser.Username).
+		Int64("target_user_id", targetUserID).
+		Str("target_username", targetUser.Username).
+		Int("old_role", int(targetUser.Role)).
+		Int("new_role", int(newRole)).
+		Msg("User role changed")
+
+	s.auditRoleChange(ctx, adminID, targetUserID, targetUser.Role, newRole, "")
+	return nil
+}
+
+// auditRoleChange records a role change in the audit log. The role has
+// changed by then, so a failure to record it is only logged. Via names what
+// made a change no admin made, such as "claim".
+func (s *UserService) auditRoleChange(ctx context.Context, actorID, targetUserID int64, oldRole, newRole models.UserRole, via string) {
+	if s.audit == nil {
+		return
+	}
+	metadata := map[string]any{"old_role": int(oldRole), "new_role": int(newRole)}
+	if via != "" {
+		metadata["via"] = via
+	}
+	err := s.audit.Log(ctx, AuditEntry{
+		ActorID:  actorID,
+		Action:   AuditRoleChanged,
+		TargetID: targetUserID,
+		Metadata: metadata,
+	})
+	if err != nil {
+		s.logger.Error().Err(err).Int64("admin_id", actorID).Int64("target_user_id", targetUserID).Msg("Failed to audit role change")
+	}
+}
+
+// AssignRole changes a user's role on behalf of an operator with direct
+// access to the deployment, such as the command line tools. It applies the
+// same validation as ChangeUserRole except the admin permission check.
+func (s *UserService) AssignRole(ctx context.Context, targetUserID int64, newRole models.UserRole) error {
+	targetUser, err := s.updateRole(ctx, targetUserID, newRole)
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info().
+		Str("actor", "operator").
+		Int64("target_user_id", targetUserID).
+		Str("target_username", targetUser.Username).
+		Int("old_role", int(targetUser.Role)).
+		Int("new_role", int(newRole)).
+		Msg("User role changed")
+
+	return nil
+}
+
+// updateRole validates and stores a role change. It returns the target user
+// as it was before the change.
+func (s *UserService) updateRole(ctx context.Context, targetUserID int64, newRole models.UserRole) (*models.User, error) {
 	// Validate new role value
 	if newRole < models.RoleUser || newRole > models.RoleAdmin {
-		return fmt.Errorf("invalid role value: %d", newRole)
+		return nil, fmt.Errorf("invalid role value: %d", newRole)
 	}
 
 	// Get target user and their current role
 	targetUser, err := s.GetUser(ctx, targetUserID)
 	if err != nil {
-		return fmt.Errorf("failed to get target user: %w", err)
+		return nil, fmt.Errorf("failed to get target user: %w", err)
 	}
 
 	// Prevent demoting the last admin
 	if targetUser.Role == models.RoleAdmin && newRole != models.RoleAdmin {
 		var adminCount int64
 		if err := s.db.WithContext(ctx).Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&adminCount).Error; err != nil {
-			return fmt.Errorf("failed to count admins: %w", err)
+			return nil, fmt.Errorf("failed to count admins: %w", err)
 		}
 		if adminCount <= 1 {
-			return fmt.Errorf("cannot demote the last admin")
+			return nil, fmt.Errorf("cannot demote the last admin")
 		}
 	}
 
 	// Invalidate cache BEFORE update
 	cacheKey := fmt.Sprintf("user:%d", targetUserID)
-	if err := s.redis.Del(ctx, cacheKey).Err(); err != nil {
+	if err := s.invalidateCache(ctx, cacheKey); err != nil {
 		s.logger.Warn().Err(err).Str("cache_key", cacheKey).Msg("Failed to invalidate user cache before role change")
 	}
 
 	// Update the role in database
-	err = s.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", targetUserID).Update("role", newRole).Error
-	if err != nil {
-		return fmt.Errorf("failed to update role: %w", err)
+	if err := s.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", targetUserID).Update("role", newRole).Error; err != nil {
+		return nil, fmt.Errorf("failed to update role: %w", err)
 	}
 
-	// Audit log
-	s.logger.Info().
-		Int64("admin_id", adminID).
-		Str("admin_username", adminUser.Username).
-		Int64("target_user_id", targetUserID).
-		Str("target_username", targetUser.Username).
-		Int("old_role", int(targetUser.Role)).
-		Int("new_role", int(newRole)).
-		Msg("User role changed")
-
-	return nil
+	return targetUser, nil
 }
 
 // GetRoleName returns the human-readable name for a role
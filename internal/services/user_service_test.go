@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"database/sql/driver"
 	"encoding/json"
 	"errors"
 	"testing"
@@ -9,12 +10,16 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 
 	"github.com/valpere/shopogoda/internal/models"
 	"github.com/valpere/shopogoda/pkg/metrics"
+	"github.com/valpere/shopogoda/pkg/weather"
 	"github.com/valpere/shopogoda/tests/helpers"
 )
 
@@ -70,8 +75,24 @@ func TestUserService_RegisterUser(t *testing.T) {
 				float64(0),        // longitude
 				"",                // country
 				"",                // city
+				false,             // location_city_level
+				false,             // location_approximate
+				false,             // activity_tracking_disabled
+				"",                // secondary_language
+				"",                // week_start
+				false,             // quiet_digests
+				false,             // alert_escalation_disabled
+				false,             // unusual_weather_notes
+				int64(3),          // skin_type (default)
+				true,              // show_recommendations (default)
+				"",                // api_key
+				helpers.AnyTime{}, // last_active_at
+				nil,               // deactivated_at
+				nil,               // onboarding_started_at
+				nil,               // onboarded_at
 				helpers.AnyTime{}, // created_at
 				helpers.AnyTime{}, // updated_at
+				nil,               // deleted_at
 				int64(123),        // id
 			).
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(123))
@@ -101,7 +122,9 @@ func TestUserService_RegisterUser(t *testing.T) {
 		}
 
 		mockDB.Mock.ExpectBegin()
-		mockDB.Mock.ExpectQuery(`INSERT INTO "users"`).
+		// A user who deleted their data comes back as a new one: language, role
+		// and registration time are taken over and the deletion is undone
+		mockDB.Mock.ExpectQuery(`INSERT INTO "users" .* ON CONFLICT \("id"\) DO UPDATE SET .*"language"=CASE WHEN "users"\."deleted_at" IS NULL THEN "users"\."language" ELSE excluded\.language END,"role"=CASE WHEN "users"\."deleted_at" IS NULL THEN "users"\."role" ELSE excluded\.role END,"created_at"=CASE WHEN "users"\."deleted_at" IS NULL THEN "users"\."created_at" ELSE excluded\.created_at END,"deleted_at"=NULL RETURNING`).
 			WithArgs(
 				"existinguser",    // username
 				"Updated",         // first_name
@@ -116,8 +139,24 @@ func TestUserService_RegisterUser(t *testing.T) {
 				float64(0),        // longitude
 				"",                // country
 				"",                // city
+				false,             // location_city_level
+				false,             // location_approximate
+				false,             // activity_tracking_disabled
+				"",                // secondary_language
+				"",                // week_start
+				false,             // quiet_digests
+				false,             // alert_escalation_disabled
+				false,             // unusual_weather_notes
+				int64(3),          // skin_type (default)
+				true,              // show_recommendations (default)
+				"",                // api_key
+				helpers.AnyTime{}, // last_active_at
+				nil,               // deactivated_at
+				nil,               // onboarding_started_at
+				nil,               // onboarded_at
 				helpers.AnyTime{}, // created_at
 				helpers.AnyTime{}, // updated_at
+				nil,               // deleted_at
 				int64(456),        // id
 			).
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(456))
@@ -162,8 +201,24 @@ func TestUserService_RegisterUser(t *testing.T) {
 				float64(0),        // longitude
 				"",                // country
 				"",                // city
+				false,             // location_city_level
+				false,             // location_approximate
+				false,             // activity_tracking_disabled
+				"",                // secondary_language
+				"",                // week_start
+				false,             // quiet_digests
+				false,             // alert_escalation_disabled
+				false,             // unusual_weather_notes
+				int64(3),          // skin_type (default)
+				true,              // show_recommendations (default)
+				"",                // api_key
+				helpers.AnyTime{}, // last_active_at
+				nil,               // deactivated_at
+				nil,               // onboarding_started_at
+				nil,               // onboarded_at
 				helpers.AnyTime{}, // created_at
 				helpers.AnyTime{}, // updated_at
+				nil,               // deleted_at
 				int64(789),        // id
 			).
 			WillReturnError(errors.New("database error"))
@@ -177,6 +232,54 @@ func TestUserService_RegisterUser(t *testing.T) {
 	})
 }
 
+func TestUserService_RegisterActiveUser(t *testing.T) {
+	tgUser := &gotgbot.User{Id: 123, Username: "testuser", FirstName: "Test", LanguageCode: "en"}
+	newService := func(t *testing.T) (*UserService, *helpers.MockDB, *helpers.MockRedis) {
+		mockDB := helpers.NewMockDB(t)
+		t.Cleanup(func() { _ = mockDB.Close() })
+		mockRedis := helpers.NewMockRedis()
+		logger := zerolog.Nop()
+		return NewUserService(mockDB.DB, mockRedis.Client, metrics.New(), &logger, time.Now()), mockDB, mockRedis
+	}
+
+	t.Run("registers on the first update of the interval", func(t *testing.T) {
+		service, mockDB, mockRedis := newService(t)
+
+		mockRedis.Mock.ExpectSetNX("user:123:registered", 1, activityWriteInterval).SetVal(true)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "users"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(123))
+		mockDB.Mock.ExpectCommit()
+
+		require.NoError(t, service.RegisterActiveUser(context.Background(), tgUser))
+		mockDB.ExpectationsWereMet(t)
+		mockRedis.ExpectationsWereMet(t)
+	})
+
+	t.Run("skips the database within the interval", func(t *testing.T) {
+		service, mockDB, mockRedis := newService(t)
+
+		mockRedis.Mock.ExpectSetNX("user:123:registered", 1, activityWriteInterval).SetVal(false)
+
+		require.NoError(t, service.RegisterActiveUser(context.Background(), tgUser))
+		mockDB.ExpectationsWereMet(t)
+		mockRedis.ExpectationsWereMet(t)
+	})
+
+	t.Run("lets the next update retry a failed write", func(t *testing.T) {
+		service, mockDB, mockRedis := newService(t)
+
+		mockRedis.Mock.ExpectSetNX("user:123:registered", 1, activityWriteInterval).SetVal(true)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "users"`).WillReturnError(errors.New("connection reset"))
+		mockDB.Mock.ExpectRollback()
+		mockRedis.Mock.ExpectDel("user:123:registered").SetVal(1)
+
+		assert.Error(t, service.RegisterActiveUser(context.Background(), tgUser))
+		mockDB.ExpectationsWereMet(t)
+		mockRedis.ExpectationsWereMet(t)
+	})
+}
+
 func TestUserService_GetUser(t *testing.T) {
 	t.Run("get from cache", func(t *testing.T) {
 		mockDB := helpers.NewMockDB(t)
@@ -225,7 +328,7 @@ func TestUserService_GetUser(t *testing.T) {
 
 		// Expect cache miss, then database query
 		mockRedis.Mock.ExpectGet("user:456").RedisNil()
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(userID, 1).
 			WillReturnRows(rows)
 		// Note: We don't verify the cache Set operation as it's not critical to this test
@@ -252,7 +355,7 @@ func TestUserService_GetUser(t *testing.T) {
 
 		userID := int64(999)
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(userID, 1).
 			WillReturnError(errors.New("record not found"))
 
@@ -264,6 +367,107 @@ func TestUserService_GetUser(t *testing.T) {
 	})
 }
 
+func TestUserService_GetUserByUsername(t *testing.T) {
+	t.Run("strips the @ and queries the username", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		logger := zerolog.Nop()
+		service := NewUserService(mockDB.DB, helpers.NewMockRedis().Client, metrics.New(), &logger, time.Now())
+
+		rows := mockDB.Mock.NewRows([]string{"id", "username", "first_name", "role"}).
+			AddRow(int64(123), "kyiv_walker", "Olena", models.RoleUser)
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE username = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
+			WithArgs("kyiv_walker", 1).
+			WillReturnRows(rows)
+
+		user, err := service.GetUserByUsername(context.Background(), "@kyiv_walker")
+		require.NoError(t, err)
+		assert.Equal(t, int64(123), user.ID)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("an empty username matches nobody", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		logger := zerolog.Nop()
+		service := NewUserService(mockDB.DB, helpers.NewMockRedis().Client, metrics.New(), &logger, time.Now())
+
+		user, err := service.GetUserByUsername(context.Background(), "@")
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		assert.Nil(t, user)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestUserService_GetUpdateUser(t *testing.T) {
+	newService := func(t *testing.T) (*UserService, *helpers.MockDB, *helpers.MockRedis) {
+		mockDB := helpers.NewMockDB(t)
+		t.Cleanup(func() { _ = mockDB.Close() })
+		mockRedis := helpers.NewMockRedis()
+		logger := zerolog.Nop()
+		return NewUserService(mockDB.DB, mockRedis.Client, metrics.New(), &logger, time.Now()), mockDB, mockRedis
+	}
+	expectCachedUser := func(mockRedis *helpers.MockRedis, language string) {
+		user := helpers.MockUser(123)
+		user.Language = language
+		userJSON, _ := json.Marshal(user)
+		mockRedis.Mock.ExpectGet("user:123").SetVal(string(userJSON))
+	}
+
+	t.Run("user is fetched once per update", func(t *testing.T) {
+		service, mockDB, mockRedis := newService(t)
+		update := &ext.Context{EffectiveUser: &gotgbot.User{Id: 123}}
+
+		expectCachedUser(mockRedis, "en-US")
+
+		for i := 0; i < 3; i++ {
+			user, err := service.GetUpdateUser(context.Background(), update, 123)
+			require.NoError(t, err)
+			assert.Equal(t, "en-US", user.Language)
+		}
+
+		assert.Equal(t, 1, UpdateUserFetches(update))
+		mockRedis.ExpectationsWereMet(t)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("language change within the update is seen", func(t *testing.T) {
+		service, mockDB, mockRedis := newService(t)
+		update := &ext.Context{EffectiveUser: &gotgbot.User{Id: 123}}
+
+		expectCachedUser(mockRedis, "en-US")
+		user, err := service.GetUpdateUser(context.Background(), update, 123)
+		require.NoError(t, err)
+		assert.Equal(t, "en-US", user.Language)
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "users" SET`).
+			WithArgs("uk-UA", helpers.AnyTime{}, int64(123)).
+			WillReturnResult(helpers.NewResult(1, 1))
+		mockDB.Mock.ExpectCommit()
+		require.NoError(t, service.UpdateUserLanguage(context.Background(), 123, "uk-UA"))
+
+		expectCachedUser(mockRedis, "uk-UA")
+		user, err = service.GetUpdateUser(context.Background(), update, 123)
+		require.NoError(t, err)
+		assert.Equal(t, "uk-UA", user.Language)
+
+		assert.Equal(t, 2, UpdateUserFetches(update))
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("other users are not memoized", func(t *testing.T) {
+		service, _, mockRedis := newService(t)
+		update := &ext.Context{EffectiveUser: &gotgbot.User{Id: 7}}
+
+		expectCachedUser(mockRedis, "en-US")
+		_, err := service.GetUpdateUser(context.Background(), update, 123)
+
+		require.NoError(t, err)
+		assert.Zero(t, UpdateUserFetches(update))
+	})
+}
+
 func TestUserService_UpdateUserSettings(t *testing.T) {
 	mockDB := helpers.NewMockDB(t)
 	defer func() { _ = mockDB.Close() }()
@@ -313,6 +517,50 @@ func TestUserService_UpdateUserSettings(t *testing.T) {
 	})
 }
 
+func TestUserService_DeactivateReactivate(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	mockRedis := helpers.NewMockRedis()
+	service := NewUserService(mockDB.DB, mockRedis.Client, nil, helpers.NewSilentTestLogger(), time.Now())
+	ctx := context.Background()
+
+	t.Run("pausing and resuming the account round-trips", func(t *testing.T) {
+		mockRedis.Mock.ExpectDel("user:42").SetVal(1)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "users" SET "deactivated_at"=\$1,"is_active"=\$2,"updated_at"=\$3 WHERE id = \$4`).
+			WithArgs(helpers.AnyTime{}, false, helpers.AnyTime{}, int64(42)).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+
+		require.NoError(t, service.DeactivateUser(ctx, 42))
+
+		mockRedis.Mock.ExpectDel("user:42").SetVal(1)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "users" SET "deactivated_at"=\$1,"is_active"=\$2,"updated_at"=\$3 WHERE id = \$4`).
+			WithArgs(nil, true, helpers.AnyTime{}, int64(42)).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+
+		require.NoError(t, service.ReactivateUser(ctx, 42))
+
+		mockDB.ExpectationsWereMet(t)
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		mockRedis.Mock.ExpectDel("user:404").SetVal(0)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "users"`).
+			WillReturnResult(helpers.NewResult(0, 0))
+		mockDB.Mock.ExpectCommit()
+
+		err := service.DeactivateUser(ctx, 404)
+
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
 func TestUserService_GetSystemStats(t *testing.T) {
 	mockDB := helpers.NewMockDB(t)
 	defer func() { _ = mockDB.Close() }()
@@ -322,25 +570,48 @@ func TestUserService_GetSystemStats(t *testing.T) {
 	logger := zerolog.Nop()
 	service := NewUserService(mockDB.DB, mockRedis.Client, metricsCollector, &logger, startTime)
 
+	metricsCollector.RecordCacheLookup("weather", true)
+	metricsCollector.RecordCacheLookup("weather", false)
+	metricsCollector.ObserveHistogram("bot_handler_duration_seconds", 0.12, "weather")
+	metricsCollector.ObserveHistogram("bot_handler_duration_seconds", 0.28, "forecast")
+
 	t.Run("successful stats retrieval", func(t *testing.T) {
 		// Total users
-		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users"`).
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE "users"\."deleted_at" IS NULL`).
 			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(100))
 
 		// Active users
-		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE is_active = \$1`).
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE is_active = \$1 AND "users"\."deleted_at" IS NULL`).
 			WithArgs(true).
 			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(85))
 
+		// Deactivated users
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE is_active = \$1 AND "users"\."deleted_at" IS NULL`).
+			WithArgs(false).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(15))
+
 		// New users 24h
-		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE created_at > \$1`).
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE created_at > \$1 AND "users"\."deleted_at" IS NULL`).
 			WithArgs(helpers.AnyTime{}).
 			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
 
+		// Seen in the last day, week and month
+		for _, count := range []int{12, 30, 55} {
+			mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE last_active_at >= \$1 AND "users"\."deleted_at" IS NULL`).
+				WithArgs(helpers.AnyTime{}).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+		}
+
 		// Users with location
-		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE location_name != '' AND location_name IS NOT NULL`).
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE \(location_name != '' AND location_name IS NOT NULL\) AND "users"\."deleted_at" IS NULL`).
 			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(60))
 
+		// Onboarding started and completed
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE onboarding_started_at IS NOT NULL AND "users"\."deleted_at" IS NULL`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(20))
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE \(onboarding_started_at IS NOT NULL AND onboarded_at IS NOT NULL\) AND "users"\."deleted_at" IS NULL`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(16))
+
 		// Active subscriptions
 		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "subscriptions" WHERE is_active = \$1`).
 			WithArgs(true).
@@ -356,13 +627,20 @@ func TestUserService_GetSystemStats(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, int64(100), stats.TotalUsers)
 		assert.Equal(t, int64(85), stats.ActiveUsers)
+		assert.Equal(t, int64(15), stats.DeactivatedUsers)
 		assert.Equal(t, int64(5), stats.NewUsers24h)
+		assert.Equal(t, int64(12), stats.DailyActiveUsers)
+		assert.Equal(t, int64(30), stats.WeeklyActiveUsers)
+		assert.Equal(t, int64(20), stats.OnboardingStarted)
+		assert.Equal(t, int64(16), stats.OnboardingCompleted)
+		assert.Equal(t, 80.0, stats.OnboardingConversion())
+		assert.Equal(t, int64(55), stats.MonthlyActiveUsers)
 		assert.Equal(t, int64(60), stats.UsersWithLocation)
 		assert.Equal(t, int64(40), stats.ActiveSubscriptions)
 		assert.Equal(t, int64(25), stats.AlertsConfigured)
 		// Real metrics values from Prometheus helpers
-		assert.Equal(t, 85.0, stats.CacheHitRate)
-		assert.Equal(t, 150, stats.AvgResponseTime)
+		assert.Equal(t, 50.0, stats.CacheHitRate)
+		assert.Equal(t, 200, stats.AvgResponseTime)
 		// Uptime is calculated dynamically, just ensure it's reasonable
 		assert.Greater(t, stats.Uptime, 0.0)
 
@@ -370,6 +648,54 @@ func TestUserService_GetSystemStats(t *testing.T) {
 	})
 }
 
+func TestUserService_DeactivateInactiveUsers(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	mockRedis := helpers.NewMockRedis()
+	logger := zerolog.Nop()
+	service := NewUserService(mockDB.DB, mockRedis.Client, metrics.New(), &logger, time.Now())
+
+	t.Run("deactivates users idle past the cutoff without subscriptions or alerts", func(t *testing.T) {
+		var cutoff time.Time
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "users" SET "is_active"=\$1,"updated_at"=\$2 WHERE \(is_active = \$3 AND COALESCE\(last_active_at, created_at\) < \$4\) AND id NOT IN \(SELECT "user_id" FROM "subscriptions" WHERE is_active = \$5\) AND id NOT IN \(SELECT "user_id" FROM "alert_configs" WHERE is_active = \$6\)`).
+			WithArgs(false, helpers.AnyTime{}, true, captureTime{&cutoff}, true, true).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mockDB.Mock.ExpectCommit()
+
+		generation := service.generation.Load()
+		deactivated, err := service.DeactivateInactiveUsers(context.Background(), 90)
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), deactivated)
+		assert.WithinDuration(t, time.Now().UTC().AddDate(0, 0, -90), cutoff, time.Minute)
+		assert.Greater(t, service.generation.Load(), generation, "memoized users are read again")
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "users"`).WillReturnError(errors.New("connection lost"))
+		mockDB.Mock.ExpectRollback()
+
+		_, err := service.DeactivateInactiveUsers(context.Background(), 90)
+
+		assert.ErrorContains(t, err, "failed to deactivate inactive users")
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+// captureTime matches a time argument and keeps it for assertions
+type captureTime struct{ into *time.Time }
+
+func (c captureTime) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	if ok {
+		*c.into = t
+	}
+	return ok
+}
+
 func TestUserService_GetActiveUsers(t *testing.T) {
 	mockDB := helpers.NewMockDB(t)
 	defer func() { _ = mockDB.Close() }()
@@ -396,7 +722,7 @@ func TestUserService_GetActiveUsers(t *testing.T) {
 			user2.IsActive, user2.Role, user2.CreatedAt, user2.UpdatedAt,
 		)
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE is_active = \$1`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE is_active = \$1 AND "users"\."deleted_at" IS NULL`).
 			WithArgs(true).
 			WillReturnRows(rows)
 
@@ -408,7 +734,7 @@ func TestUserService_GetActiveUsers(t *testing.T) {
 	})
 
 	t.Run("no active users", func(t *testing.T) {
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE is_active = \$1`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE is_active = \$1 AND "users"\."deleted_at" IS NULL`).
 			WithArgs(true).
 			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}))
 
@@ -420,6 +746,64 @@ func TestUserService_GetActiveUsers(t *testing.T) {
 	})
 }
 
+func TestUserService_ListUsers(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	logger := zerolog.Nop()
+	service := NewUserService(mockDB.DB, helpers.NewMockRedis().Client, metrics.New(), &logger, time.Now())
+
+	t.Run("filtered page", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE role = \$1 AND "users"\."deleted_at" IS NULL`).
+			WithArgs(models.RoleModerator).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(12))
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE role = \$1 AND "users"."deleted_at" IS NULL ORDER BY created_at DESC, id DESC LIMIT \$2 OFFSET \$3`).
+			WithArgs(models.RoleModerator, 10, 10).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id", "role"}).AddRow(int64(123), models.RoleModerator).AddRow(int64(456), models.RoleModerator))
+
+		users, total, err := service.ListUsers(context.Background(), 10, 10, UserFilterModerators)
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(12), total)
+		require.Len(t, users, 2)
+		assert.Equal(t, int64(123), users[0].ID)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("inactive users", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE is_active = \$1 AND "users"\."deleted_at" IS NULL`).
+			WithArgs(false).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"count"}).AddRow(0))
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE is_active = \$1 AND "users"."deleted_at" IS NULL ORDER BY created_at DESC, id DESC LIMIT \$2`).
+			WithArgs(false, 10).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}))
+
+		users, total, err := service.ListUsers(context.Background(), 0, 10, UserFilterInactive)
+
+		require.NoError(t, err)
+		assert.Zero(t, total)
+		assert.Empty(t, users)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("count error", func(t *testing.T) {
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE "users"\."deleted_at" IS NULL`).
+			WillReturnError(errors.New("database error"))
+
+		_, _, err := service.ListUsers(context.Background(), 0, 10, UserFilterAll)
+
+		assert.ErrorContains(t, err, "failed to count users")
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestParseUserListFilter(t *testing.T) {
+	for _, filter := range UserListFilters {
+		assert.Equal(t, filter, ParseUserListFilter(string(filter)))
+	}
+	assert.Equal(t, UserFilterAll, ParseUserListFilter("banned"))
+	assert.Equal(t, UserFilterAll, ParseUserListFilter(""))
+}
+
 func TestUserService_GetUserStatistics(t *testing.T) {
 	mockDB := helpers.NewMockDB(t)
 	defer func() { _ = mockDB.Close() }()
@@ -435,21 +819,26 @@ func TestUserService_GetUserStatistics(t *testing.T) {
 		mockRedis.Mock.ExpectGet("stats:weather_requests_24h").SetVal("200")
 
 		// Mock database queries
-		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users"`).
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE "users"\."deleted_at" IS NULL`).
 			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(100))
-		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE is_active = \$1`).
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE is_active = \$1 AND "users"\."deleted_at" IS NULL`).
 			WithArgs(true).
 			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(85))
-		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE created_at > \$1`).
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE created_at > \$1 AND "users"\."deleted_at" IS NULL`).
 			WithArgs(helpers.AnyTime{}).
 			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
-		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE role = \$1`).
+		for _, count := range []int{12, 30, 55} {
+			mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE last_active_at >= \$1 AND "users"\."deleted_at" IS NULL`).
+				WithArgs(helpers.AnyTime{}).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+		}
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE role = \$1 AND "users"\."deleted_at" IS NULL`).
 			WithArgs(models.RoleAdmin).
 			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
-		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE role = \$1`).
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE role = \$1 AND "users"\."deleted_at" IS NULL`).
 			WithArgs(models.RoleModerator).
 			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
-		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE location_name != '' AND location_name IS NOT NULL`).
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE \(location_name != '' AND location_name IS NOT NULL\) AND "users"\."deleted_at" IS NULL`).
 			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(60))
 		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "alert_configs" WHERE is_active = \$1`).
 			WithArgs(true).
@@ -461,6 +850,7 @@ func TestUserService_GetUserStatistics(t *testing.T) {
 		assert.Equal(t, int64(100), stats.TotalUsers)
 		assert.Equal(t, int64(85), stats.ActiveUsers)
 		assert.Equal(t, int64(5), stats.NewUsers24h)
+		assert.Equal(t, int64(30), stats.WeeklyActiveUsers)
 		assert.Equal(t, int64(2), stats.AdminCount)
 		assert.Equal(t, int64(5), stats.ModeratorCount)
 		assert.Equal(t, int64(60), stats.LocationsSaved)
@@ -487,7 +877,7 @@ func TestUserService_SetUserLocation(t *testing.T) {
 
 		mockDB.Mock.ExpectBegin()
 		mockDB.Mock.ExpectExec(`UPDATE "users" SET`).
-			WithArgs("London", "UK", 51.5074, "London", -0.1278, helpers.AnyTime{}, userID).
+			WithArgs("London", "UK", 51.5074, false, "London", -0.1278, helpers.AnyTime{}, userID).
 			WillReturnResult(helpers.NewResult(1, 1))
 		mockDB.Mock.ExpectCommit()
 
@@ -516,6 +906,239 @@ func TestUserService_SetUserLocation(t *testing.T) {
 	})
 }
 
+// fakeLocationApproximator returns a fixed city centroid regardless of the input point
+type fakeLocationApproximator struct {
+	location *weather.Location
+	err      error
+	calls    int
+}
+
+func (f *fakeLocationApproximator) ApproximateLocation(ctx context.Context, lat, lon float64) (*weather.Location, error) {
+	f.calls++
+	return f.location, f.err
+}
+
+func kyivCentroid() *fakeLocationApproximator {
+	return &fakeLocationApproximator{
+		location: &weather.Location{Name: "Kyiv", City: "Kyiv", Country: "UA", Latitude: 50.4501, Longitude: 30.5234},
+	}
+}
+
+func TestUserService_SetUserLocation_CityLevel(t *testing.T) {
+	t.Run("coarsens coordinates on save", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		mockRedis := helpers.NewMockRedis()
+		logger := zerolog.Nop()
+		service := NewUserService(mockDB.DB, mockRedis.Client, metrics.New(), &logger, time.Now())
+		approximator := kyivCentroid()
+		service.SetLocationApproximator(approximator)
+
+		userID := int64(123)
+		user := helpers.MockUser(userID)
+		user.LocationCityLevel = true
+		userJSON, _ := json.Marshal(user)
+
+		mockRedis.Mock.ExpectGet("user:123").SetVal(string(userJSON))
+		mockRedis.Mock.ExpectDel("user:123").SetVal(1)
+
+		// Exact point in Podil is replaced by the city centroid
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "users" SET`).
+			WithArgs("Kyiv", "UA", 50.4501, true, "Kyiv", 30.5234, helpers.AnyTime{}, userID).
+			WillReturnResult(helpers.NewResult(1, 1))
+		mockDB.Mock.ExpectCommit()
+
+		err := service.SetUserLocation(context.Background(), userID, "Podil (50.4663, 30.5155)", "", "", 50.4663, 30.5155)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, approximator.calls)
+		mockDB.ExpectationsWereMet(t)
+		mockRedis.ExpectationsWereMet(t)
+	})
+
+	t.Run("precise mode stores exact point", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		mockRedis := helpers.NewMockRedis()
+		logger := zerolog.Nop()
+		service := NewUserService(mockDB.DB, mockRedis.Client, metrics.New(), &logger, time.Now())
+		approximator := kyivCentroid()
+		service.SetLocationApproximator(approximator)
+
+		userID := int64(123)
+		userJSON, _ := json.Marshal(helpers.MockUser(userID))
+
+		mockRedis.Mock.ExpectGet("user:123").SetVal(string(userJSON))
+		mockRedis.Mock.ExpectDel("user:123").SetVal(1)
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "users" SET`).
+			WithArgs("", "", 50.4663, false, "Podil", 30.5155, helpers.AnyTime{}, userID).
+			WillReturnResult(helpers.NewResult(1, 1))
+		mockDB.Mock.ExpectCommit()
+
+		err := service.SetUserLocation(context.Background(), userID, "Podil", "", "", 50.4663, 30.5155)
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, approximator.calls)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("approximation failure does not store exact point", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		mockRedis := helpers.NewMockRedis()
+		logger := zerolog.Nop()
+		service := NewUserService(mockDB.DB, mockRedis.Client, metrics.New(), &logger, time.Now())
+		service.SetLocationApproximator(&fakeLocationApproximator{err: errors.New("geocoder down")})
+
+		userID := int64(123)
+		user := helpers.MockUser(userID)
+		user.LocationCityLevel = true
+		userJSON, _ := json.Marshal(user)
+
+		mockRedis.Mock.ExpectGet("user:123").SetVal(string(userJSON))
+
+		err := service.SetUserLocation(context.Background(), userID, "Podil", "", "", 50.4663, 30.5155)
+
+		assert.Error(t, err)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("privacy lookup failure does not store exact point", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		mockRedis := helpers.NewMockRedis()
+		logger := zerolog.Nop()
+		service := NewUserService(mockDB.DB, mockRedis.Client, metrics.New(), &logger, time.Now())
+		approximator := kyivCentroid()
+		service.SetLocationApproximator(approximator)
+
+		userID := int64(123)
+		mockRedis.Mock.ExpectGet("user:123").SetErr(errors.New("redis connection error"))
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users"`).
+			WillReturnError(errors.New("connection reset"))
+
+		err := service.SetUserLocation(context.Background(), userID, "Podil", "", "", 50.4663, 30.5155)
+
+		assert.Error(t, err)
+		assert.Equal(t, 0, approximator.calls)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestUserService_SetLocationPrivacy(t *testing.T) {
+	t.Run("enabling city-level coarsens stored precise location", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		mockRedis := helpers.NewMockRedis()
+		logger := zerolog.Nop()
+		service := NewUserService(mockDB.DB, mockRedis.Client, metrics.New(), &logger, time.Now())
+		approximator := kyivCentroid()
+		service.SetLocationApproximator(approximator)
+
+		userID := int64(123)
+		user := helpers.MockUser(userID)
+		user.LocationName = "Podil (50.4663, 30.5155)"
+		user.Latitude = 50.4663
+		user.Longitude = 30.5155
+		userJSON, _ := json.Marshal(user)
+
+		mockRedis.Mock.ExpectGet("user:123").SetVal(string(userJSON))
+		mockRedis.Mock.ExpectDel("user:123").SetVal(1)
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "users" SET`).
+			WithArgs("Kyiv", "UA", 50.4501, true, true, "Kyiv", 30.5234, helpers.AnyTime{}, userID).
+			WillReturnResult(helpers.NewResult(1, 1))
+		mockDB.Mock.ExpectCommit()
+
+		err := service.SetLocationPrivacy(context.Background(), userID, true)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, approximator.calls)
+		mockDB.ExpectationsWereMet(t)
+		mockRedis.ExpectationsWereMet(t)
+	})
+
+	t.Run("already approximate location is left as is", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		mockRedis := helpers.NewMockRedis()
+		logger := zerolog.Nop()
+		service := NewUserService(mockDB.DB, mockRedis.Client, metrics.New(), &logger, time.Now())
+		approximator := kyivCentroid()
+		service.SetLocationApproximator(approximator)
+
+		userID := int64(123)
+		user := helpers.MockUser(userID)
+		user.LocationApproximate = true
+		userJSON, _ := json.Marshal(user)
+
+		mockRedis.Mock.ExpectGet("user:123").SetVal(string(userJSON))
+		mockRedis.Mock.ExpectDel("user:123").SetVal(1)
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "users" SET`).
+			WithArgs(true, helpers.AnyTime{}, userID).
+			WillReturnResult(helpers.NewResult(1, 1))
+		mockDB.Mock.ExpectCommit()
+
+		err := service.SetLocationPrivacy(context.Background(), userID, true)
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, approximator.calls)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("disabling keeps stored location", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		mockRedis := helpers.NewMockRedis()
+		logger := zerolog.Nop()
+		service := NewUserService(mockDB.DB, mockRedis.Client, metrics.New(), &logger, time.Now())
+		service.SetLocationApproximator(kyivCentroid())
+
+		userID := int64(123)
+		user := helpers.MockUser(userID)
+		user.LocationCityLevel = true
+		user.LocationApproximate = true
+		userJSON, _ := json.Marshal(user)
+
+		mockRedis.Mock.ExpectGet("user:123").SetVal(string(userJSON))
+		mockRedis.Mock.ExpectDel("user:123").SetVal(1)
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "users" SET`).
+			WithArgs(false, helpers.AnyTime{}, userID).
+			WillReturnResult(helpers.NewResult(1, 1))
+		mockDB.Mock.ExpectCommit()
+
+		err := service.SetLocationPrivacy(context.Background(), userID, false)
+
+		require.NoError(t, err)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("no approximator available", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		mockRedis := helpers.NewMockRedis()
+		logger := zerolog.Nop()
+		service := NewUserService(mockDB.DB, mockRedis.Client, metrics.New(), &logger, time.Now())
+
+		userID := int64(123)
+		userJSON, _ := json.Marshal(helpers.MockUser(userID))
+		mockRedis.Mock.ExpectGet("user:123").SetVal(string(userJSON))
+
+		err := service.SetLocationPrivacy(context.Background(), userID, true)
+
+		assert.Error(t, err)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
 func TestUserService_ClearUserLocation(t *testing.T) {
 	t.Run("successful location clear", func(t *testing.T) {
 		mockDB := helpers.NewMockDB(t)
@@ -570,7 +1193,7 @@ func TestUserService_GetUserLocation(t *testing.T) {
 			user.IsActive, user.Role, user.CreatedAt, user.UpdatedAt,
 		)
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(userID, 1).
 			WillReturnRows(rows)
 
@@ -598,7 +1221,7 @@ func TestUserService_GetUserLocation(t *testing.T) {
 			user.IsActive, user.Role, user.CreatedAt, user.UpdatedAt,
 		)
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(userID, 1).
 			WillReturnRows(rows)
 
@@ -637,7 +1260,7 @@ func TestUserService_GetUserTimezone(t *testing.T) {
 			user.IsActive, user.Role, user.CreatedAt, user.UpdatedAt,
 		)
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(userID, 1).
 			WillReturnRows(rows)
 
@@ -661,7 +1284,7 @@ func TestUserService_GetUserTimezone(t *testing.T) {
 			user.IsActive, user.Role, user.CreatedAt, user.UpdatedAt,
 		)
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(userID, 1).
 			WillReturnRows(rows)
 
@@ -674,7 +1297,7 @@ func TestUserService_GetUserTimezone(t *testing.T) {
 	t.Run("user not found defaults to UTC", func(t *testing.T) {
 		userID := int64(999)
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(userID, 1).
 			WillReturnError(errors.New("record not found"))
 
@@ -708,7 +1331,7 @@ func TestUserService_ConvertToUserTime(t *testing.T) {
 			user.IsActive, user.Role, user.CreatedAt, user.UpdatedAt,
 		)
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(userID, 1).
 			WillReturnRows(rows)
 
@@ -736,7 +1359,7 @@ func TestUserService_ConvertToUserTime(t *testing.T) {
 			user.IsActive, user.Role, user.CreatedAt, user.UpdatedAt,
 		)
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(userID, 1).
 			WillReturnRows(rows)
 
@@ -773,7 +1396,7 @@ func TestUserService_ConvertToUTC(t *testing.T) {
 			user.IsActive, user.Role, user.CreatedAt, user.UpdatedAt,
 		)
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(userID, 1).
 			WillReturnRows(rows)
 
@@ -812,6 +1435,35 @@ func TestUserService_UpdateUserLanguage(t *testing.T) {
 	})
 }
 
+func TestUserService_UpdateUserSkinType(t *testing.T) {
+	mockDB := helpers.NewMockDB(t)
+	defer func() { _ = mockDB.Close() }()
+	mockRedis := helpers.NewMockRedis()
+	logger := zerolog.Nop()
+	service := NewUserService(mockDB.DB, mockRedis.Client, metrics.New(), &logger, time.Now())
+
+	t.Run("successful skin type update", func(t *testing.T) {
+		userID := int64(123)
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "users" SET "skin_type"=\$1`).
+			WithArgs(2, helpers.AnyTime{}, userID).
+			WillReturnResult(helpers.NewResult(1, 1))
+		mockDB.Mock.ExpectCommit()
+
+		err := service.UpdateUserSkinType(context.Background(), userID, 2)
+
+		assert.NoError(t, err)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("outside the Fitzpatrick scale", func(t *testing.T) {
+		assert.Error(t, service.UpdateUserSkinType(context.Background(), 123, 0))
+		assert.Error(t, service.UpdateUserSkinType(context.Background(), 123, 7))
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
 func TestUserService_IncrementMessageCounter(t *testing.T) {
 	t.Run("successful increment - new key", func(t *testing.T) {
 		mockDB := helpers.NewMockDB(t)
@@ -1022,6 +1674,7 @@ func TestUserService_ChangeUserRole(t *testing.T) {
 		startTime := time.Now()
 		logger := zerolog.Nop()
 		service := NewUserService(mockDB.DB, mockRedis.Client, metricsCollector, &logger, startTime)
+		service.SetAudit(NewAuditService(mockDB.DB))
 
 		adminID := int64(100)
 		targetUserID := int64(200)
@@ -1036,7 +1689,7 @@ func TestUserService_ChangeUserRole(t *testing.T) {
 			true, models.RoleAdmin, time.Now(), time.Now(),
 		)
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(adminID, 1).
 			WillReturnRows(adminRows)
 
@@ -1050,7 +1703,7 @@ func TestUserService_ChangeUserRole(t *testing.T) {
 			true, models.RoleUser, time.Now(), time.Now(),
 		)
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(targetUserID, 1).
 			WillReturnRows(targetRows)
 
@@ -1064,6 +1717,13 @@ func TestUserService_ChangeUserRole(t *testing.T) {
 			WillReturnResult(helpers.NewResult(1, 1))
 		mockDB.Mock.ExpectCommit()
 
+		// The change is recorded in the audit log
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+			WithArgs(adminID, AuditRoleChanged, targetUserID, []byte(`{"new_role":2,"old_role":1}`), "", helpers.AnyTime{}).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mockDB.Mock.ExpectCommit()
+
 		err := service.ChangeUserRole(context.Background(), adminID, targetUserID, models.RoleModerator)
 
 		assert.NoError(t, err)
@@ -1093,7 +1753,7 @@ func TestUserService_ChangeUserRole(t *testing.T) {
 			true, models.RoleUser, time.Now(), time.Now(),
 		)
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(nonAdminID, 1).
 			WillReturnRows(userRows)
 
@@ -1125,7 +1785,7 @@ func TestUserService_ChangeUserRole(t *testing.T) {
 			true, models.RoleAdmin, time.Now(), time.Now(),
 		)
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(adminID, 1).
 			WillReturnRows(adminRows)
 
@@ -1158,7 +1818,7 @@ func TestUserService_ChangeUserRole(t *testing.T) {
 			true, models.RoleAdmin, time.Now(), time.Now(),
 		)
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(adminID, 1).
 			WillReturnRows(adminRows)
 
@@ -1191,7 +1851,7 @@ func TestUserService_ChangeUserRole(t *testing.T) {
 			true, models.RoleAdmin, time.Now(), time.Now(),
 		)
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(adminID, 1).
 			WillReturnRows(adminRows)
 
@@ -1205,12 +1865,12 @@ func TestUserService_ChangeUserRole(t *testing.T) {
 			true, models.RoleAdmin, time.Now(), time.Now(),
 		)
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(targetAdminID, 1).
 			WillReturnRows(targetRows)
 
 		// Mock admin count query - only 1 admin
-		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE role = \$1`).
+		mockDB.Mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE role = \$1 AND "users"\."deleted_at" IS NULL`).
 			WithArgs(models.RoleAdmin).
 			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 
@@ -1243,12 +1903,12 @@ func TestUserService_ChangeUserRole(t *testing.T) {
 			true, models.RoleAdmin, time.Now(), time.Now(),
 		)
 
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(adminID, 1).
 			WillReturnRows(adminRows)
 
 		// Mock target user not found
-		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 ORDER BY "users"\."id" LIMIT \$2`).
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
 			WithArgs(targetUserID, 1).
 			WillReturnError(errors.New("record not found"))
 
@@ -1260,6 +1920,42 @@ func TestUserService_ChangeUserRole(t *testing.T) {
 	})
 }
 
+func TestUserService_AssignRole(t *testing.T) {
+	t.Run("operator promotes without an admin account", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		mockRedis := helpers.NewMockRedis()
+		logger := zerolog.Nop()
+		service := NewUserService(mockDB.DB, mockRedis.Client, metrics.New(), &logger, time.Now())
+
+		targetUserID := int64(200)
+		mockRedis.Mock.ExpectGet("user:200").RedisNil()
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT \$2`).
+			WithArgs(targetUserID, 1).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id", "username", "role"}).AddRow(targetUserID, "target", models.RoleUser))
+		mockRedis.Mock.ExpectDel("user:200").SetVal(1)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "users" SET "role"=\$1,"updated_at"=\$2 WHERE id = \$3`).
+			WithArgs(models.RoleAdmin, helpers.AnyTime{}, targetUserID).
+			WillReturnResult(helpers.NewResult(1, 1))
+		mockDB.Mock.ExpectCommit()
+
+		err := service.AssignRole(context.Background(), targetUserID, models.RoleAdmin)
+
+		assert.NoError(t, err)
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("invalid role is rejected", func(t *testing.T) {
+		logger := zerolog.Nop()
+		service := NewUserService(nil, nil, nil, &logger, time.Now())
+
+		err := service.AssignRole(context.Background(), 200, models.UserRole(9))
+
+		assert.ErrorContains(t, err, "invalid role value")
+	})
+}
+
 func TestUserService_GetRoleName(t *testing.T) {
 	mockDB := helpers.NewMockDB(t)
 	defer func() { _ = mockDB.Close() }()
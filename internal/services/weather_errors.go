@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+// Classes of failed weather fetches, as logged and stored with undelivered
+// notifications
+const (
+	WeatherErrorRateLimited  = "rate-limited"
+	WeatherErrorNotFound     = "not-found"
+	WeatherErrorUnauthorized = "unauthorized"
+	WeatherErrorTimeout      = "timeout"
+	WeatherErrorUnavailable  = "unavailable"
+	WeatherErrorNoAirData    = "no-air-data"
+	WeatherErrorOther        = "other"
+)
+
+// WeatherErrorClass classifies a failed weather fetch by the typed errors of
+// the weather client
+func WeatherErrorClass(err error) string {
+	var rateLimited *weather.RateLimitError
+	switch {
+	case errors.As(err, &rateLimited):
+		return WeatherErrorRateLimited
+	case errors.Is(err, weather.ErrNotFound):
+		return WeatherErrorNotFound
+	case errors.Is(err, weather.ErrUnauthorized):
+		return WeatherErrorUnauthorized
+	case errors.Is(err, weather.ErrTimeout):
+		return WeatherErrorTimeout
+	case errors.Is(err, weather.ErrUnavailable):
+		return WeatherErrorUnavailable
+	case errors.Is(err, weather.ErrNoAirData):
+		return WeatherErrorNoAirData
+	default:
+		return WeatherErrorOther
+	}
+}
+
+// WeatherErrorMessage tells the user why weather for a location could not be
+// fetched and what to do next. Every weather command goes through it, so one
+// failure reads the same everywhere; fallback is the command's own message,
+// used for failures that fit no class.
+func WeatherErrorMessage(ctx context.Context, localization *LocalizationService, language, location string, err error, fallback string) string {
+	t := func(key string, args ...any) string {
+		return localization.T(ctx, language, key, args...)
+	}
+
+	switch WeatherErrorClass(err) {
+	case WeatherErrorRateLimited:
+		var rateLimited *weather.RateLimitError
+		errors.As(err, &rateLimited)
+		return t("weather_error_rate_limited", retryMinutes(rateLimited))
+	case WeatherErrorNotFound:
+		return t("weather_error_not_found", location)
+	case WeatherErrorUnauthorized:
+		return t("weather_error_unauthorized")
+	case WeatherErrorTimeout:
+		return t("weather_error_timeout")
+	case WeatherErrorUnavailable:
+		return t("weather_error_unavailable")
+	case WeatherErrorNoAirData:
+		return t("weather_error_no_air_data", location)
+	default:
+		return fallback
+	}
+}
+
+// retryMinutes rounds the wait of a rate limited request up to whole minutes
+func retryMinutes(err *weather.RateLimitError) int {
+	return max(1, int(math.Ceil(err.RetryAfter.Minutes())))
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/locales"
+	"github.com/valpere/shopogoda/pkg/weather"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestWeatherErrorMessage(t *testing.T) {
+	localization := NewLocalizationService(helpers.NewSilentTestLogger())
+	require.NoError(t, localization.LoadTranslations(locales.LocalesFS))
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		err      error
+		class    string
+		expected map[string]string
+	}{
+		{
+			name:  "rate limited rounds the wait up to minutes",
+			err:   fmt.Errorf("failed to get weather: %w", &weather.RateLimitError{RetryAfter: 90 * time.Second}),
+			class: WeatherErrorRateLimited,
+			expected: map[string]string{
+				"en-US": "about 2 min",
+				"uk-UA": "приблизно за 2 хв",
+			},
+		},
+		{
+			name:  "not found names the location",
+			err:   fmt.Errorf("failed to geocode 'Atlantis': %w", weather.ErrNotFound),
+			class: WeatherErrorNotFound,
+			expected: map[string]string{
+				"en-US": "Could not find 'Atlantis'",
+				"uk-UA": "«Atlantis»",
+			},
+		},
+		{
+			name:  "unauthorized points to the user's own key",
+			err:   fmt.Errorf("%w: API request failed with status: 401", weather.ErrUnauthorized),
+			class: WeatherErrorUnauthorized,
+			expected: map[string]string{
+				"en-US": "API Key",
+				"uk-UA": "API-ключ",
+			},
+		},
+		{
+			name:  "timeout",
+			err:   fmt.Errorf("%w: failed to make request: deadline", weather.ErrTimeout),
+			class: WeatherErrorTimeout,
+			expected: map[string]string{
+				"en-US": "took too long",
+				"uk-UA": "надто довго",
+			},
+		},
+		{
+			name:  "unavailable",
+			err:   fmt.Errorf("%w: API request failed with status: 503", weather.ErrUnavailable),
+			class: WeatherErrorUnavailable,
+			expected: map[string]string{
+				"en-US": "having problems",
+				"uk-UA": "проблеми",
+			},
+		},
+		{
+			name:  "no air data",
+			err:   fmt.Errorf("%w available", weather.ErrNoAirData),
+			class: WeatherErrorNoAirData,
+			expected: map[string]string{
+				"en-US": "no air quality data for Atlantis",
+				"uk-UA": "якість повітря для Atlantis",
+			},
+		},
+		{
+			name:  "anything else keeps the command's message",
+			err:   errors.New("failed to decode response"),
+			class: WeatherErrorOther,
+			expected: map[string]string{
+				"en-US": "fallback",
+				"uk-UA": "fallback",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.class, WeatherErrorClass(tt.err))
+			for language, fragment := range tt.expected {
+				message := WeatherErrorMessage(ctx, localization, language, "Atlantis", tt.err, "fallback")
+				assert.Contains(t, message, fragment, language)
+			}
+		})
+	}
+}
+
+func TestRetryMinutes(t *testing.T) {
+	assert.Equal(t, 1, retryMinutes(&weather.RateLimitError{RetryAfter: 5 * time.Second}))
+	assert.Equal(t, 1, retryMinutes(&weather.RateLimitError{RetryAfter: time.Minute}))
+	assert.Equal(t, 3, retryMinutes(&weather.RateLimitError{RetryAfter: 121 * time.Second}))
+}
+
+func TestRetryOnTimeout(t *testing.T) {
+	t.Run("a timeout is retried once", func(t *testing.T) {
+		calls := 0
+		result, err := retryOnTimeout(func() (int, error) {
+			calls++
+			if calls == 1 {
+				return 0, weather.ErrTimeout
+			}
+			return 42, nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 42, result)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("a second timeout is returned", func(t *testing.T) {
+		calls := 0
+		_, err := retryOnTimeout(func() (int, error) {
+			calls++
+			return 0, weather.ErrTimeout
+		})
+		assert.ErrorIs(t, err, weather.ErrTimeout)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("other failures are not retried", func(t *testing.T) {
+		calls := 0
+		_, err := retryOnTimeout(func() (int, error) {
+			calls++
+			return 0, weather.ErrUnavailable
+		})
+		assert.ErrorIs(t, err, weather.ErrUnavailable)
+		assert.Equal(t, 1, calls)
+	})
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/valpere/shopogoda/internal/models"
+)
+
+// DefaultHistoryInterval is how often the weather at users' locations is
+// recorded when the configuration does not say
+const DefaultHistoryInterval = time.Hour
+
+// DailyWeatherHistory is one day of recorded weather at the user's location.
+// Days follow the location's local calendar.
+type DailyWeatherHistory struct {
+	Day          time.Time // Midnight of the local day, in UTC
+	LocationName string    // Location of the last snapshot of the day
+	MinTemp      float64   // Celsius
+	MaxTemp      float64   // Celsius
+	Samples      int
+}
+
+// SetHistoryStore sets the database that weather history is recorded in
+func (s *WeatherService) SetHistoryStore(db *gorm.DB) {
+	s.db = db
+}
+
+// RunHistoryRecorder records the weather at every user's location once per
+// configured interval until ctx is cancelled
+func (s *WeatherService) RunHistoryRecorder(ctx context.Context) {
+	if s.db == nil {
+		return
+	}
+
+	interval := s.config.HistoryInterval
+	if interval <= 0 {
+		interval = DefaultHistoryInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	s.logger.Info().Dur("interval", interval).Msg("Starting weather history recorder")
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info().Msg("Weather history recorder stopped")
+			return
+		case <-ticker.C:
+			recorded, err := s.RecordHistory(ctx, time.Now())
+			if err != nil {
+				s.logger.Error().Err(err).Msg("Failed to record weather history")
+				continue
+			}
+			s.logger.Debug().Int("recorded", recorded).Msg("Recorded weather history")
+		}
+	}
+}
+
+// RecordHistory saves the current weather at the location of every active
+// user who has one. Users at the same coordinates share one lookup. It
+// returns how many snapshots were saved.
+func (s *WeatherService) RecordHistory(ctx context.Context, now time.Time) (int, error) {
+	var users []models.User
+	if err := s.db.WithContext(ctx).
+		Where("is_active = ? AND location_name != '' AND location_name IS NOT NULL", true).
+		Find(&users).Error; err != nil {
+		return 0, fmt.Errorf("failed to get users with locations: %w", err)
+	}
+
+	fetched := make(map[string][]byte)
+	recorded := 0
+	for _, user := range users {
+		if ctx.Err() != nil {
+			return recorded, ctx.Err()
+		}
+
+		key := fmt.Sprintf("%.4f:%.4f", user.Latitude, user.Longitude)
+		data, ok := fetched[key]
+		if !ok {
+			current, err := s.GetCompleteWeatherData(ctx, user.Latitude, user.Longitude)
+			if err != nil {
+				s.logger.Warn().Err(err).Int64("user_id", user.ID).Str("location", user.LocationName).Msg("Failed to get weather for history")
+				continue
+			}
+			if data, err = json.Marshal(current); err != nil {
+				return recorded, fmt.Errorf("failed to encode weather: %w", err)
+			}
+			fetched[key] = data
+		}
+
+		snapshot := models.WeatherHistory{
+			UserID:       user.ID,
+			LocationName: user.LocationName,
+			Latitude:     user.Latitude,
+			Longitude:    user.Longitude,
+			Data:         data,
+			RecordedAt:   now.UTC(),
+		}
+		if err := s.db.WithContext(ctx).Create(&snapshot).Error; err != nil {
+			return recorded, fmt.Errorf("failed to save weather history: %w", err)
+		}
+		recorded++
+	}
+	return recorded, nil
+}
+
+// GetWeatherHistory returns the lowest and highest temperature of each of the
+// user's last days, oldest first. Days without snapshots are left out.
+func (s *WeatherService) GetWeatherHistory(ctx context.Context, userID int64, days int) ([]DailyWeatherHistory, error) {
+	if days <= 0 {
+		return nil, nil
+	}
+
+	// A day more than asked for, so the first local day is complete at any offset
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -days)
+	var snapshots []models.WeatherHistory
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ? AND recorded_at >= ?", userID, since).
+		Order("recorded_at").
+		Find(&snapshots).Error; err != nil {
+		return nil, fmt.Errorf("failed to load weather history: %w", err)
+	}
+
+	history := dailyWeatherHistory(snapshots)
+	if len(history) > days {
+		history = history[len(history)-days:]
+	}
+	return history, nil
+}
+
+// dailyWeatherHistory folds snapshots, ordered by time, into days
+func dailyWeatherHistory(snapshots []models.WeatherHistory) []DailyWeatherHistory {
+	var history []DailyWeatherHistory
+	for _, snapshot := range snapshots {
+		var data WeatherData
+		if err := json.Unmarshal(snapshot.Data, &data); err != nil {
+			continue
+		}
+
+		local := snapshot.RecordedAt.UTC().Add(time.Duration(data.UTCOffset) * time.Second)
+		day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+
+		if n := len(history); n > 0 && history[n-1].Day.Equal(day) {
+			last := &history[n-1]
+			last.MinTemp = min(last.MinTemp, data.Temperature)
+			last.MaxTemp = max(last.MaxTemp, data.Temperature)
+			last.LocationName = snapshot.LocationName
+			last.Samples++
+			continue
+		}
+		history = append(history, DailyWeatherHistory{
+			Day:          day,
+			LocationName: snapshot.LocationName,
+			MinTemp:      data.Temperature,
+			MaxTemp:      data.Temperature,
+			Samples:      1,
+		})
+	}
+	return history
+}
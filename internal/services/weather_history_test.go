@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-redis/redismock/v9"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/weather"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func historySnapshot(t *testing.T, recordedAt string, temperature float64, utcOffset int) models.WeatherHistory {
+	t.Helper()
+	at, err := time.Parse(time.RFC3339, recordedAt)
+	require.NoError(t, err)
+	data, err := json.Marshal(WeatherData{Temperature: temperature, UTCOffset: utcOffset})
+	require.NoError(t, err)
+	return models.WeatherHistory{LocationName: "Kyiv", Data: data, RecordedAt: at}
+}
+
+func TestDailyWeatherHistory(t *testing.T) {
+	t.Run("folds snapshots into local days", func(t *testing.T) {
+		kyiv := 3 * 3600
+		history := dailyWeatherHistory([]models.WeatherHistory{
+			historySnapshot(t, "2026-10-13T20:00:00Z", 9, kyiv),
+			// 22:00 UTC is already the next day in Kyiv
+			historySnapshot(t, "2026-10-13T22:00:00Z", 7, kyiv),
+			historySnapshot(t, "2026-10-14T11:00:00Z", 15.5, kyiv),
+			historySnapshot(t, "2026-10-14T20:00:00Z", 6, kyiv),
+		})
+
+		require.Len(t, history, 2)
+		assert.Equal(t, time.Date(2026, 10, 13, 0, 0, 0, 0, time.UTC), history[0].Day)
+		assert.Equal(t, 9.0, history[0].MinTemp)
+		assert.Equal(t, 9.0, history[0].MaxTemp)
+		assert.Equal(t, time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC), history[1].Day)
+		assert.Equal(t, 6.0, history[1].MinTemp)
+		assert.Equal(t, 15.5, history[1].MaxTemp)
+		assert.Equal(t, 3, history[1].Samples)
+	})
+
+	t.Run("skips unreadable snapshots", func(t *testing.T) {
+		history := dailyWeatherHistory([]models.WeatherHistory{
+			{Data: []byte("not json"), RecordedAt: time.Now()},
+		})
+		assert.Empty(t, history)
+	})
+}
+
+func TestWeatherService_RecordHistory(t *testing.T) {
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+
+	mockDB := helpers.NewMockDB(t)
+	defer mockDB.Close()
+	rdb, mockRedis := redismock.NewClientMock()
+	service := NewWeatherService(&config.WeatherConfig{}, rdb, &logger)
+	service.SetHistoryStore(mockDB.DB)
+
+	// Two users in Kyiv share one lookup, answered from the cache
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE \(is_active = \$1 AND location_name != '' AND location_name IS NOT NULL\) AND "users"."deleted_at" IS NULL`).
+		WithArgs(true).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"id", "location_name", "latitude", "longitude", "is_active"}).
+			AddRow(int64(100), "Kyiv", 50.45, 30.52, true).
+			AddRow(int64(200), "Kyiv", 50.45, 30.52, true))
+
+	current, _ := json.Marshal(weather.WeatherData{Temperature: 12.5, LocationName: "Kyiv"})
+	air, _ := json.Marshal(weather.AirQualityData{AQI: 2})
+	mockRedis.ExpectGet("weather:current:50.45:30.52").SetVal(string(current))
+	mockRedis.ExpectGet("weather:air:50.45:30.52").SetVal(string(air))
+
+	for _, userID := range []int64{100, 200} {
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "weather_history"`).
+			WithArgs(userID, "Kyiv", 50.45, 30.52, sqlmock.AnyArg(), now).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow("00000000-0000-0000-0000-000000000001"))
+		mockDB.Mock.ExpectCommit()
+	}
+
+	recorded, err := service.RecordHistory(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, recorded)
+	assert.NoError(t, mockDB.Mock.ExpectationsWereMet())
+	assert.NoError(t, mockRedis.ExpectationsWereMet())
+}
+
+func TestWeatherService_RunHistoryRecorder_StopsOnCancel(t *testing.T) {
+	logger := zerolog.Nop()
+	mockDB := helpers.NewMockDB(t)
+	defer mockDB.Close()
+	rdb, _ := redismock.NewClientMock()
+	service := NewWeatherService(&config.WeatherConfig{HistoryInterval: time.Hour}, rdb, &logger)
+	service.SetHistoryStore(mockDB.DB)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		service.RunHistoryRecorder(ctx)
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("recorder did not stop after cancellation")
+	}
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+// Weather maps are cached for ten minutes, about as often as the OpenWeather
+// layers update. Precipitation maps for /radar are cached per tile, so
+// everyone whose location falls in the same tile shares one render.
+const (
+	weatherMapTTL             = 10 * time.Minute
+	precipitationMapKeyPrefix = "weather:radar:"
+	weatherMapKeyPrefix       = "weather_map:"
+)
+
+// GetPrecipitationTile returns a PNG precipitation map around the coordinates
+// at the zoom level
+func (s *WeatherService) GetPrecipitationTile(ctx context.Context, lat, lon float64, zoom int) ([]byte, error) {
+	x, y := weather.TileCoordinates(lat, lon, zoom)
+	cacheKey := fmt.Sprintf("%s%d:%d:%d", precipitationMapKeyPrefix, zoom, x, y)
+	return s.cachedWeatherMap(ctx, cacheKey, func() ([]byte, error) {
+		return s.client.GetPrecipitationTile(ctx, lat, lon, zoom)
+	})
+}
+
+// GetWeatherMap returns a PNG map of one weather layer around the coordinates
+// at the zoom level. The layer is one of weather.MapLayers.
+func (s *WeatherService) GetWeatherMap(ctx context.Context, lat, lon float64, zoom int, layer string) ([]byte, error) {
+	mapLayer, err := weather.ParseMapLayer(layer)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKey := fmt.Sprintf("%s%s:%d:%.2f:%.2f", weatherMapKeyPrefix, mapLayer, zoom, lat, lon)
+	return s.cachedWeatherMap(ctx, cacheKey, func() ([]byte, error) {
+		return s.client.GetWeatherMap(ctx, lat, lon, zoom, mapLayer)
+	})
+}
+
+// cachedWeatherMap returns the image cached under cacheKey, rendering and
+// caching it on a miss
+func (s *WeatherService) cachedWeatherMap(ctx context.Context, cacheKey string, render func() ([]byte, error)) ([]byte, error) {
+	if cached, err := s.redis.Get(ctx, cacheKey).Bytes(); err == nil {
+		return cached, nil
+	}
+
+	image, err := retryOnTimeout(render)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get weather map: %w", err)
+	}
+
+	if err := s.redis.Set(ctx, cacheKey, image, weatherMapTTL).Err(); err != nil {
+		s.logger.Warn().Err(err).Str("cache_key", cacheKey).Msg("Failed to cache weather map")
+	}
+	return image, nil
+}
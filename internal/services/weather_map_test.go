@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+func TestWeatherService_GetPrecipitationTile_Cached(t *testing.T) {
+	logger := zerolog.Nop()
+	rdb, mock := redismock.NewClientMock()
+	service := NewWeatherService(&config.WeatherConfig{}, rdb, &logger)
+
+	// Kyiv lies in tile 74/43 at zoom 7
+	mock.ExpectGet("weather:radar:7:74:43").SetVal("\x89PNG")
+
+	image, err := service.GetPrecipitationTile(context.Background(), 50.45, 30.52, 7)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("\x89PNG"), image)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWeatherService_GetWeatherMap(t *testing.T) {
+	logger := zerolog.Nop()
+
+	t.Run("answers from the cache", func(t *testing.T) {
+		rdb, mock := redismock.NewClientMock()
+		service := NewWeatherService(&config.WeatherConfig{}, rdb, &logger)
+
+		mock.ExpectGet("weather_map:temperature:7:50.45:30.52").SetVal("\x89PNG")
+
+		image, err := service.GetWeatherMap(context.Background(), 50.4501, 30.5234, 7, "temperature")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("\x89PNG"), image)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rejects unknown layers", func(t *testing.T) {
+		rdb, mock := redismock.NewClientMock()
+		service := NewWeatherService(&config.WeatherConfig{}, rdb, &logger)
+
+		_, err := service.GetWeatherMap(context.Background(), 50.45, 30.52, 7, "clouds")
+		assert.ErrorIs(t, err, weather.ErrUnknownMapLayer)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+
+	"github.com/valpere/shopogoda/internal/models"
+)
+
+const (
+	// DefaultWeatherRecordRetention applies when no retention is configured
+	DefaultWeatherRecordRetention = 90 * 24 * time.Hour
+
+	// weatherRecordDedupWindow keeps at most one record per user and place in
+	// this window, however often the weather there is looked up
+	weatherRecordDedupWindow = 30 * time.Minute
+	weatherRecordKeyPrefix   = "weather_record:"
+	// weatherRecordRadius is how far, in degrees, a record may lie from the
+	// coordinates it is summarized for
+	weatherRecordRadius = 0.1
+)
+
+// ConditionShare is the part of the records that showed one kind of weather
+type ConditionShare struct {
+	Group string  // Kind of weather, as conditionGroup returns it
+	Share float64 // 0..1
+}
+
+// WeatherRecordSummary sums up the weather recorded at a place
+type WeatherRecordSummary struct {
+	MinTemp    float64 // Celsius
+	MaxTemp    float64 // Celsius
+	AvgTemp    float64 // Celsius
+	Samples    int
+	Conditions []ConditionShare // Most common first
+}
+
+// WeatherRecordService keeps the weather users looked up or were sent by a
+// subscription. Records are dropped after the retention period.
+type WeatherRecordService struct {
+	db        *gorm.DB
+	redis     *redis.Client
+	logger    *zerolog.Logger
+	retention time.Duration
+	now       func() time.Time
+}
+
+func NewWeatherRecordService(db *gorm.DB, redis *redis.Client, logger *zerolog.Logger, retention time.Duration) *WeatherRecordService {
+	if retention <= 0 {
+		retention = DefaultWeatherRecordRetention
+	}
+	return &WeatherRecordService{
+		db:        db,
+		redis:     redis,
+		logger:    logger,
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// Record stores the weather the user saw at the coordinates in the chat,
+// unless the same place was recorded for them within
+// weatherRecordDedupWindow. It reports whether a record was stored.
+func (s *WeatherRecordService) Record(ctx context.Context, userID, chatID int64, lat, lon float64, current *WeatherData) (bool, error) {
+	now := s.now()
+	key := fmt.Sprintf("%s%d:%.2f:%.2f", weatherRecordKeyPrefix, userID, lat, lon)
+	claimed, err := s.redis.SetNX(ctx, key, now.Unix(), weatherRecordDedupWindow).Result()
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	record := models.WeatherRecord{
+		UserID:      userID,
+		ChatID:      chatID,
+		Latitude:    lat,
+		Longitude:   lon,
+		Temperature: current.Temperature,
+		Humidity:    current.Humidity,
+		Pressure:    current.Pressure,
+		WindSpeed:   current.WindSpeed,
+		WindDegree:  current.WindDirection,
+		Visibility:  current.Visibility,
+		UVIndex:     current.UVIndex,
+		Description: current.Description,
+		Icon:        current.Icon,
+		AQI:         current.AQI,
+		Timestamp:   current.Timestamp.UTC(),
+		FromCache:   current.FromCache,
+		CreatedAt:   now.UTC(),
+	}
+	if err := s.db.WithContext(ctx).Create(&record).Error; err != nil {
+		return false, fmt.Errorf("failed to record weather: %w", err)
+	}
+	return true, nil
+}
+
+// PurgeExpired deletes records older than the retention period
+func (s *WeatherRecordService) PurgeExpired(ctx context.Context) (int64, error) {
+	cutoff := s.now().UTC().Add(-s.retention)
+	result := s.db.WithContext(ctx).Where("created_at < ?", cutoff).Delete(&models.WeatherRecord{})
+	return result.RowsAffected, result.Error
+}
+
+// Summarize sums up the user's records near the coordinates since the given
+// time. It returns nil when there are none.
+func (s *WeatherRecordService) Summarize(ctx context.Context, userID int64, lat, lon float64, since time.Time) (*WeatherRecordSummary, error) {
+	var records []models.WeatherRecord
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND timestamp >= ?", userID, since.UTC()).
+		Where("lat BETWEEN ? AND ? AND lon BETWEEN ? AND ?",
+			lat-weatherRecordRadius, lat+weatherRecordRadius, lon-weatherRecordRadius, lon+weatherRecordRadius).
+		Order("timestamp").
+		Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load weather records: %w", err)
+	}
+	return summarizeWeatherRecords(records), nil
+}
+
+// summarizeWeatherRecords computes the temperature range and mean and the
+// share of each kind of weather, or nil without records
+func summarizeWeatherRecords(records []models.WeatherRecord) *WeatherRecordSummary {
+	if len(records) == 0 {
+		return nil
+	}
+
+	summary := &WeatherRecordSummary{
+		MinTemp: records[0].Temperature,
+		MaxTemp: records[0].Temperature,
+		Samples: len(records),
+	}
+	var sum float64
+	counts := make(map[string]int)
+	for _, record := range records {
+		summary.MinTemp = min(summary.MinTemp, record.Temperature)
+		summary.MaxTemp = max(summary.MaxTemp, record.Temperature)
+		sum += record.Temperature
+		// Icons of no known kind of weather count only towards the temperatures
+		if group := conditionGroup(record.Icon); group != record.Icon {
+			counts[group]++
+		}
+	}
+	summary.AvgTemp = sum / float64(len(records))
+
+	for group, count := range counts {
+		summary.Conditions = append(summary.Conditions, ConditionShare{
+			Group: group,
+			Share: float64(count) / float64(len(records)),
+		})
+	}
+	sort.Slice(summary.Conditions, func(i, j int) bool {
+		a, b := summary.Conditions[i], summary.Conditions[j]
+		if a.Share != b.Share {
+			return a.Share > b.Share
+		}
+		return a.Group < b.Group
+	})
+	return summary
+}
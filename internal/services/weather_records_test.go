@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func newTestWeatherRecords(t *testing.T) (*WeatherRecordService, *helpers.MockDB, *helpers.MockRedis, time.Time) {
+	mockDB := helpers.NewMockDB(t)
+	t.Cleanup(func() { _ = mockDB.Close() })
+	mockRedis := helpers.NewMockRedis()
+
+	service := NewWeatherRecordService(mockDB.DB, mockRedis.Client, helpers.NewSilentTestLogger(), 0)
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+	return service, mockDB, mockRedis, now
+}
+
+func TestWeatherRecordService_Record(t *testing.T) {
+	current := &WeatherData{Temperature: 12.5, Humidity: 70, Icon: "10d", Timestamp: time.Date(2026, 10, 16, 8, 50, 0, 0, time.UTC), FromCache: true}
+
+	t.Run("stores the first lookup of a place", func(t *testing.T) {
+		service, mockDB, mockRedis, now := newTestWeatherRecords(t)
+		mockRedis.Mock.ExpectSetNX("weather_record:42:50.45:30.52", now.Unix(), weatherRecordDedupWindow).SetVal(true)
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectQuery(`INSERT INTO "weather_records"`).
+			WithArgs(int64(42), int64(-100), 50.4501, 30.5234, 12.5, 70, 0.0, 0.0, 0, 0.0, 0.0, "", "10d", 0, current.Timestamp, true, now).
+			WillReturnRows(mockDB.Mock.NewRows([]string{"id"}).AddRow("00000000-0000-0000-0000-000000000001"))
+		mockDB.Mock.ExpectCommit()
+
+		recorded, err := service.Record(context.Background(), 42, -100, 50.4501, 30.5234, current)
+
+		require.NoError(t, err)
+		assert.True(t, recorded)
+		mockDB.ExpectationsWereMet(t)
+		assert.NoError(t, mockRedis.Mock.ExpectationsWereMet())
+	})
+
+	t.Run("skips a place recorded within the window", func(t *testing.T) {
+		service, mockDB, mockRedis, now := newTestWeatherRecords(t)
+		mockRedis.Mock.ExpectSetNX("weather_record:42:50.45:30.52", now.Unix(), weatherRecordDedupWindow).SetVal(false)
+
+		recorded, err := service.Record(context.Background(), 42, -100, 50.4501, 30.5234, current)
+
+		require.NoError(t, err)
+		assert.False(t, recorded)
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestWeatherRecordService_PurgeExpired(t *testing.T) {
+	service, mockDB, _, now := newTestWeatherRecords(t)
+
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectExec(`DELETE FROM "weather_records" WHERE created_at < \$1`).
+		WithArgs(now.Add(-DefaultWeatherRecordRetention)).
+		WillReturnResult(helpers.NewResult(0, 7))
+	mockDB.Mock.ExpectCommit()
+
+	purged, err := service.PurgeExpired(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), purged)
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestWeatherRecordService_Summarize(t *testing.T) {
+	service, mockDB, _, now := newTestWeatherRecords(t)
+	since := now.AddDate(0, 0, -7)
+
+	rows := mockDB.Mock.NewRows([]string{"user_id", "temperature", "icon", "timestamp"}).
+		AddRow(42, 4.0, "10n", since.Add(time.Hour)).
+		AddRow(42, 10.0, "01d", since.Add(2*time.Hour)).
+		AddRow(42, 7.0, "10d", since.Add(3*time.Hour)).
+		AddRow(42, 3.0, "", since.Add(4*time.Hour))
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "weather_records" WHERE \(user_id = \$1 AND timestamp >= \$2\) AND \(lat BETWEEN \$3 AND \$4 AND lon BETWEEN \$5 AND \$6\) ORDER BY timestamp`).
+		WithArgs(int64(42), since, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(rows)
+
+	summary, err := service.Summarize(context.Background(), 42, 50.45, 30.52, since)
+
+	require.NoError(t, err)
+	require.NotNil(t, summary)
+	assert.Equal(t, 3.0, summary.MinTemp)
+	assert.Equal(t, 10.0, summary.MaxTemp)
+	assert.Equal(t, 6.0, summary.AvgTemp)
+	assert.Equal(t, 4, summary.Samples)
+	assert.Equal(t, []ConditionShare{{Group: "rain", Share: 0.5}, {Group: "clear", Share: 0.25}}, summary.Conditions)
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestSummarizeWeatherRecords_Empty(t *testing.T) {
+	assert.Nil(t, summarizeWeatherRecords(nil))
+	assert.Nil(t, summarizeWeatherRecords([]models.WeatherRecord{}))
+}
@@ -3,20 +3,27 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
+	"gorm.io/gorm"
 
 	"github.com/valpere/shopogoda/internal"
 
 	"github.com/valpere/shopogoda/internal/config"
 	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/metrics"
 	"github.com/valpere/shopogoda/pkg/weather"
 )
 
@@ -33,13 +40,58 @@ type NominatimAddress struct {
 	Neighbourhood string `json:"neighbourhood"`
 }
 
+// Geocoding cache. Found places rarely move, so they are kept for a week;
+// misspellings are remembered for a few hours so that retries of the same
+// typo do not spend provider quota.
+const (
+	geocodeKeyPrefix      = "geocode:"
+	geocodeCacheTTL       = 7 * 24 * time.Hour
+	geocodeNegativeTTL    = 6 * time.Hour
+	geocodeNotFoundMarker = "!not_found"
+)
+
+// Weather cache TTLs used when the configuration does not set them
+const (
+	defaultCurrentCacheTTL  = 10 * time.Minute
+	defaultForecastCacheTTL = time.Hour
+	hourlyCacheTTL          = 30 * time.Minute
+
+	// warningsCacheTTL lets the subscribers at one place share a warnings
+	// request within a check of the scheduler
+	warningsCacheTTL = 20 * time.Minute
+)
+
+// Last known weather per location name. When the provider cannot be reached
+// it is served, however old, in place of an error.
+const (
+	lastKnownWeatherKeyPrefix = "weather:"
+	lastKnownWeatherTTL       = 7 * 24 * time.Hour
+)
+
+// defaultProviderTimeout is how long a weather provider may take before the
+// next one is asked, when the configuration does not say
+const defaultProviderTimeout = 5 * time.Second
+
+// errNominatimNotFound is returned when Nominatim answers with no match at all
+var errNominatimNotFound = errors.New("location not found in Nominatim")
+
+// errNoProviders is returned when no weather provider is configured
+var errNoProviders = errors.New("no weather provider configured")
+
 type WeatherService struct {
-	client     *weather.Client
-	geocoder   *weather.GeocodingClient
+	client     *weather.Client           // OpenWeather, also for map tiles and pollen
+	providers  []weather.WeatherProvider // Asked in order until one answers
+	tides      *weather.TideClient       // WorldTides; nil without a tide API key
 	redis      *redis.Client
 	config     *config.WeatherConfig
 	logger     *zerolog.Logger
 	httpClient *http.Client
+	metrics    *metrics.Metrics
+	db         *gorm.DB // Weather history; nil until SetHistoryStore
+
+	// reportedFields remembers unknown API fields already logged, so each
+	// schema addition is logged once rather than on every request
+	reportedFields sync.Map
 }
 
 func NewWeatherService(cfg *config.WeatherConfig, redis *redis.Client, logger *zerolog.Logger) *WeatherService {
@@ -53,14 +105,76 @@ func NewWeatherService(cfg *config.WeatherConfig, redis *redis.Client, logger *z
 		},
 	}
 
-	return &WeatherService{
+	service := &WeatherService{
 		client:     weather.NewClient(cfg.OpenWeatherAPIKey),
-		geocoder:   weather.NewGeocodingClient(cfg.OpenWeatherAPIKey),
 		redis:      redis,
 		config:     cfg,
 		logger:     logger,
 		httpClient: httpClient,
 	}
+	service.client.SetUnknownFieldHandler(service.reportUnknownField)
+	service.client.SetUserAgent(service.getUserAgent())
+	service.providers = service.newProviders(cfg.Providers)
+	if cfg.TideAPIKey != "" {
+		service.tides = weather.NewTideClient(cfg.TideAPIKey)
+		service.tides.SetUserAgent(service.getUserAgent())
+	}
+
+	return service
+}
+
+// newProviders creates the weather providers named in a comma-separated list,
+// in order. Unknown names are logged and skipped; without any known one,
+// OpenWeather serves alone.
+func (s *WeatherService) newProviders(names string) []weather.WeatherProvider {
+	var providers []weather.WeatherProvider
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		switch name {
+		case weather.ProviderOpenWeatherMap:
+			geocoder := weather.NewGeocodingClient(s.config.OpenWeatherAPIKey)
+			geocoder.SetUnknownFieldHandler(s.reportUnknownField)
+			providers = append(providers, weather.NewOpenWeatherMap(s.client, geocoder))
+		case weather.ProviderOpenMeteo:
+			openMeteo := weather.NewOpenMeteo()
+			openMeteo.SetUnknownFieldHandler(s.reportUnknownField)
+			providers = append(providers, openMeteo)
+		default:
+			s.logger.Warn().Str("provider", name).Msg("Unknown weather provider ignored")
+		}
+	}
+
+	if len(providers) == 0 {
+		return s.newProviders(weather.ProviderOpenWeatherMap)
+	}
+	return providers
+}
+
+// SetMetrics sets the collector used to count weather API requests and schema
+// drift
+func (s *WeatherService) SetMetrics(metricsCollector *metrics.Metrics) {
+	s.metrics = metricsCollector
+}
+
+// reportUnknownField records a top-level API response field we do not know
+// about. Additions are harmless, but they usually precede renames.
+func (s *WeatherService) reportUnknownField(endpoint, field string) {
+	if s.metrics != nil {
+		s.metrics.IncrementCounter("weather_api_unknown_fields_total", endpoint, field)
+	}
+
+	if _, logged := s.reportedFields.LoadOrStore(endpoint+"."+field, true); !logged {
+		s.logger.Warn().
+			Str("endpoint", endpoint).
+			Str("field", field).
+			Msg("Unknown field in weather API response")
+	}
 }
 
 // getUserAgent safely returns the UserAgent from config with fallback to default
@@ -92,137 +206,325 @@ func (s *WeatherService) GetLocalizedLocationName(location *weather.Location, us
 	return location.Name
 }
 
-func (s *WeatherService) GetCurrentWeather(ctx context.Context, lat, lon float64) (*weather.WeatherData, error) {
-	// Try cache first
-	cacheKey := fmt.Sprintf("weather:current:%.4f:%.4f", lat, lon)
-	cached, err := s.redis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var weatherData weather.WeatherData
-		if err := json.Unmarshal([]byte(cached), &weatherData); err == nil {
-			return &weatherData, nil
+// retryOnTimeout repeats a provider request once when it timed out. A single
+// slow response is common and not worth troubling the user with; a second
+// one is surfaced.
+func retryOnTimeout[T any](fetch func() (T, error)) (T, error) {
+	result, err := fetch()
+	if errors.Is(err, weather.ErrTimeout) {
+		result, err = fetch()
+	}
+	return result, err
+}
+
+// fromProviders asks the providers in order until one answers. Each but the
+// last gets the provider timeout to do so; the last is retried once when it
+// times out, as a lone provider always was. Every attempt is counted per
+// provider, and the provider that answered is logged.
+func fromProviders[T any](ctx context.Context, s *WeatherService, api string, fetch func(context.Context, weather.WeatherProvider) (T, error)) (T, error) {
+	var zero T
+	failure := errNoProviders
+	for i, provider := range s.providers {
+		var result T
+		var err error
+		if i == len(s.providers)-1 {
+			result, err = retryOnTimeout(func() (T, error) { return fetch(ctx, provider) })
+		} else {
+			attemptCtx, cancel := context.WithTimeout(ctx, s.providerTimeout())
+			result, err = fetch(attemptCtx, provider)
+			cancel()
+		}
+		s.countProviderRequest(provider.Name(), api, err)
+
+		if err == nil {
+			// Answers from a fallback provider are worth noticing
+			event := s.logger.Debug()
+			if i > 0 {
+				event = s.logger.Info()
+			}
+			event.Str("provider", provider.Name()).Str("api", api).Msg("Weather request served")
+			return result, nil
+		}
+
+		// A provider without the feature says nothing about the others
+		if !errors.Is(err, weather.ErrNotSupported) || errors.Is(failure, errNoProviders) {
+			failure = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if i < len(s.providers)-1 {
+			event := s.logger.Warn()
+			if errors.Is(err, weather.ErrNotFound) || errors.Is(err, weather.ErrNotSupported) {
+				event = s.logger.Debug()
+			}
+			event.Err(err).Str("provider", provider.Name()).Str("api", api).Msg("Weather provider failed, trying the next one")
 		}
 	}
+	return zero, failure
+}
+
+func (s *WeatherService) providerTimeout() time.Duration {
+	if s.config != nil && s.config.ProviderTimeout > 0 {
+		return s.config.ProviderTimeout
+	}
+	return defaultProviderTimeout
+}
 
-	// Get from API
-	weatherData, err := s.client.GetCurrentWeather(ctx, lat, lon)
+func (s *WeatherService) GetCurrentWeather(ctx context.Context, lat, lon float64) (*weather.WeatherData, error) {
+	weatherData, hit, err := cachedWeather(ctx, s, "current", localizedCacheKey(ctx, weatherCacheKey("current", lat, lon)), s.currentCacheTTL(),
+		func() (*weather.WeatherData, error) {
+			return fromProviders(ctx, s, "current", func(ctx context.Context, provider weather.WeatherProvider) (*weather.WeatherData, error) {
+				return provider.GetCurrentWeather(ctx, lat, lon)
+			})
+		})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get weather data: %w", err)
 	}
+	weatherData.FromCache = hit
+	return weatherData, nil
+}
 
-	// Cache for 10 minutes
-	weatherJSON, err := json.Marshal(weatherData)
+func (s *WeatherService) GetForecast(ctx context.Context, lat, lon float64, days int) (*weather.ForecastData, error) {
+	cacheKey := localizedCacheKey(ctx, fmt.Sprintf("%s:%d", weatherCacheKey("forecast", lat, lon), days))
+	forecastData, _, err := cachedWeather(ctx, s, "forecast", cacheKey, s.forecastCacheTTL(),
+		func() (*weather.ForecastData, error) {
+			return fromProviders(ctx, s, "forecast", func(ctx context.Context, provider weather.WeatherProvider) (*weather.ForecastData, error) {
+				return provider.GetForecast(ctx, lat, lon, days)
+			})
+		})
 	if err != nil {
-		s.logger.Warn().Err(err).Msg("Failed to marshal weather data for caching")
-	} else {
-		if err := s.redis.Set(ctx, cacheKey, weatherJSON, 10*time.Minute).Err(); err != nil {
-			s.logger.Warn().Err(err).Str("cache_key", cacheKey).Msg("Failed to cache weather data")
-		}
+		return nil, fmt.Errorf("failed to get forecast data: %w", err)
 	}
+	return forecastData, nil
+}
 
-	return weatherData, nil
+// GetHourlyForecast returns the 3-hour forecast slots covering the next hours
+func (s *WeatherService) GetHourlyForecast(ctx context.Context, lat, lon float64, hours int) (*weather.HourlyForecastData, error) {
+	// The first slot goes stale sooner than a day does
+	cacheKey := localizedCacheKey(ctx, fmt.Sprintf("%s:%d", weatherCacheKey("hourly", lat, lon), hours))
+	hourlyData, _, err := cachedWeather(ctx, s, "hourly", cacheKey, hourlyCacheTTL,
+		func() (*weather.HourlyForecastData, error) {
+			return fromProviders(ctx, s, "hourly", func(ctx context.Context, provider weather.WeatherProvider) (*weather.HourlyForecastData, error) {
+				return provider.GetHourlyForecast(ctx, lat, lon, hours)
+			})
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hourly forecast data: %w", err)
+	}
+	return hourlyData, nil
 }
 
-func (s *WeatherService) GetForecast(ctx context.Context, lat, lon float64, days int) (*weather.ForecastData, error) {
-	// Try cache first
-	cacheKey := fmt.Sprintf("weather:forecast:%.4f:%.4f:%d", lat, lon, days)
-	cached, err := s.redis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var forecastData weather.ForecastData
-		if err := json.Unmarshal([]byte(cached), &forecastData); err == nil {
-			return &forecastData, nil
-		}
+func (s *WeatherService) GetAirQuality(ctx context.Context, lat, lon float64) (*weather.AirQualityData, error) {
+	airData, _, err := cachedWeather(ctx, s, "air_quality", weatherCacheKey("air", lat, lon), s.currentCacheTTL(),
+		func() (*weather.AirQualityData, error) {
+			return fromProviders(ctx, s, "air_quality", func(ctx context.Context, provider weather.WeatherProvider) (*weather.AirQualityData, error) {
+				return provider.GetAirQuality(ctx, lat, lon)
+			})
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get air quality data: %w", err)
 	}
+	return airData, nil
+}
 
-	// Get from API
-	forecastData, err := s.client.GetForecast(ctx, lat, lon, days)
+// GetPollenForecast returns the pollen counts of a place now and for the next
+// days. Counts are modelled hourly, so they are cached as long as a forecast.
+func (s *WeatherService) GetPollenForecast(ctx context.Context, lat, lon float64) (*weather.PollenData, error) {
+	pollenData, _, err := cachedWeather(ctx, s, "pollen", weatherCacheKey("pollen", lat, lon), s.forecastCacheTTL(),
+		func() (*weather.PollenData, error) {
+			return retryOnTimeout(func() (*weather.PollenData, error) { return s.client.GetPollenForecast(ctx, lat, lon) })
+		})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get forecast data: %w", err)
+		return nil, fmt.Errorf("failed to get pollen data: %w", err)
 	}
+	return pollenData, nil
+}
 
-	// Cache for 1 hour
-	forecastJSON, err := json.Marshal(forecastData)
+// GetAirQualityForecast returns the hourly air quality of a place from the
+// current hour on, up to hours of it. The forecast comes from OpenWeather
+// whichever providers are configured; without an OpenWeather key it returns
+// weather.ErrNotSupported.
+func (s *WeatherService) GetAirQualityForecast(ctx context.Context, lat, lon float64, hours int) (*weather.AirQualityForecast, error) {
+	if s.config == nil || s.config.OpenWeatherAPIKey == "" {
+		return nil, weather.ErrNotSupported
+	}
+
+	// Like the hourly forecast, its first hour goes stale soon
+	cacheKey := fmt.Sprintf("%s:%d", weatherCacheKey("air_forecast", lat, lon), hours)
+	forecast, _, err := cachedWeather(ctx, s, "air_forecast", cacheKey, hourlyCacheTTL,
+		func() (*weather.AirQualityForecast, error) {
+			return retryOnTimeout(func() (*weather.AirQualityForecast, error) {
+				return s.client.GetAirQualityForecast(ctx, lat, lon, hours)
+			})
+		})
 	if err != nil {
-		s.logger.Warn().Err(err).Msg("Failed to marshal forecast data for caching")
-	} else {
-		if err := s.redis.Set(ctx, cacheKey, forecastJSON, time.Hour).Err(); err != nil {
-			s.logger.Warn().Err(err).Str("cache_key", cacheKey).Msg("Failed to cache forecast data")
-		}
+		return nil, fmt.Errorf("failed to get air quality forecast: %w", err)
 	}
+	return forecast, nil
+}
 
-	return forecastData, nil
+// GetWeatherWarnings returns the official warnings covering a place. They
+// come from OpenWeather's One Call API whichever providers are configured;
+// with warnings turned off or no OpenWeather key it returns
+// weather.ErrNotSupported.
+func (s *WeatherService) GetWeatherWarnings(ctx context.Context, lat, lon float64) ([]weather.WeatherWarning, error) {
+	if s.config == nil || !s.config.Warnings || s.config.OpenWeatherAPIKey == "" {
+		return nil, weather.ErrNotSupported
+	}
+
+	warnings, _, err := cachedWeather(ctx, s, "warnings", weatherCacheKey("warnings", lat, lon), warningsCacheTTL,
+		func() (*[]weather.WeatherWarning, error) {
+			warnings, err := retryOnTimeout(func() ([]weather.WeatherWarning, error) { return s.client.GetWeatherWarnings(ctx, lat, lon) })
+			if err != nil {
+				return nil, err
+			}
+			return &warnings, nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get weather warnings: %w", err)
+	}
+	return *warnings, nil
 }
 
-func (s *WeatherService) GetAirQuality(ctx context.Context, lat, lon float64) (*weather.AirQualityData, error) {
-	// Try cache first
-	cacheKey := fmt.Sprintf("weather:air:%.4f:%.4f", lat, lon)
-	cached, err := s.redis.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var airData weather.AirQualityData
-		if err := json.Unmarshal([]byte(cached), &airData); err == nil {
-			return &airData, nil
+// GetAstroData works out the moon and the dark night at the coordinates on
+// the calendar day of date, in date's location. Nothing is fetched, so it
+// works whichever providers are configured.
+func (s *WeatherService) GetAstroData(ctx context.Context, lat, lon float64, date time.Time) (*weather.AstroData, error) {
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return nil, fmt.Errorf("invalid coordinates: %.4f, %.4f", lat, lon)
+	}
+	return weather.Astro(date, lat, lon), nil
+}
+
+// ValidateAPIKey checks that OpenWeather accepts a user's own API key. A
+// rejected key fails with weather.ErrUnauthorized.
+func (s *WeatherService) ValidateAPIKey(ctx context.Context, key string) error {
+	if s.client == nil {
+		return weather.ErrNotSupported
+	}
+	return s.client.ValidateAPIKey(ctx, key)
+}
+
+// InvalidateCurrentWeather drops the cached current weather and air quality
+// of a place, so that the next request for it reaches the provider. Current
+// weather is dropped in English and in the language requested with ctx.
+func (s *WeatherService) InvalidateCurrentWeather(ctx context.Context, locationName string) error {
+	location, err := s.GeocodeLocation(ctx, locationName)
+	if err != nil {
+		return fmt.Errorf("failed to geocode location: %w", err)
+	}
+	currentKey := weatherCacheKey("current", location.Latitude, location.Longitude)
+	keys := []string{currentKey}
+	if localizedKey := localizedCacheKey(ctx, currentKey); localizedKey != currentKey {
+		keys = append(keys, localizedKey)
+	}
+	keys = append(keys, weatherCacheKey("air", location.Latitude, location.Longitude))
+	return s.redis.Del(ctx, keys...).Err()
+}
+
+// weatherCacheKey is where provider answers for a place are cached. The
+// coordinates are rounded to two decimals, about a kilometre, so that people
+// asking about the same town share one provider call.
+func weatherCacheKey(kind string, lat, lon float64) string {
+	return fmt.Sprintf("weather:%s:%.2f:%.2f", kind, lat, lon)
+}
+
+// localizedCacheKey keeps answers with descriptions in the language requested
+// with ctx apart from the English ones under key
+func localizedCacheKey(ctx context.Context, key string) string {
+	if language := weather.LanguageFrom(ctx); language != "" {
+		return key + ":" + language
+	}
+	return key
+}
+
+// cachedWeather answers from the weather cache under key, or calls fetch and
+// caches its answer for ttl. It reports whether the answer came from the cache.
+func cachedWeather[T any](ctx context.Context, s *WeatherService, api, key string, ttl time.Duration, fetch func() (*T, error)) (*T, bool, error) {
+	if cached, err := s.redis.Get(ctx, key).Result(); err == nil {
+		var data T
+		if err := json.Unmarshal([]byte(cached), &data); err == nil {
+			s.countCacheLookup(true)
+			return &data, true, nil
 		}
 	}
+	s.countCacheLookup(false)
 
-	// Get from API
-	airData, err := s.client.GetAirQuality(ctx, lat, lon)
+	start := time.Now()
+	data, err := fetch()
+	s.countWeatherRequest(api, time.Since(start), err)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get air quality data: %w", err)
+		return nil, false, err
 	}
 
-	// Cache for 30 minutes
-	airJSON, err := json.Marshal(airData)
+	dataJSON, err := json.Marshal(data)
 	if err != nil {
-		s.logger.Warn().Err(err).Msg("Failed to marshal air quality data for caching")
-	} else {
-		if err := s.redis.Set(ctx, cacheKey, airJSON, 30*time.Minute).Err(); err != nil {
-			s.logger.Warn().Err(err).Str("cache_key", cacheKey).Msg("Failed to cache air quality data")
-		}
+		s.logger.Warn().Err(err).Str("api", api).Msg("Failed to marshal weather data for caching")
+	} else if err := s.redis.Set(ctx, key, dataJSON, ttl).Err(); err != nil {
+		s.logger.Warn().Err(err).Str("cache_key", key).Msg("Failed to cache weather data")
 	}
+	return data, false, nil
+}
 
-	return airData, nil
+func (s *WeatherService) currentCacheTTL() time.Duration {
+	if s.config != nil && s.config.CurrentCacheTTL > 0 {
+		return s.config.CurrentCacheTTL
+	}
+	return defaultCurrentCacheTTL
+}
+
+func (s *WeatherService) forecastCacheTTL() time.Duration {
+	if s.config != nil && s.config.ForecastCacheTTL > 0 {
+		return s.config.ForecastCacheTTL
+	}
+	return defaultForecastCacheTTL
 }
 
 func (s *WeatherService) GeocodeLocation(ctx context.Context, locationName string) (*weather.Location, error) {
-	// Normalize location name for consistent caching
-	normalizedName := strings.ToLower(strings.TrimSpace(locationName))
+	normalizedName := NormalizeGeocodeQuery(locationName)
 	if normalizedName == "" {
 		return nil, fmt.Errorf("location name cannot be empty")
 	}
+	notFound := fmt.Errorf("%w: '%s' - please check the spelling or try a major city name", weather.ErrNotFound, locationName)
 
-	// Try cache first
-	cacheKey := fmt.Sprintf("geocode:%s", normalizedName)
+	// Try cache first; a recent failed lookup is answered without the providers
+	cacheKey := geocodeKeyPrefix + normalizedName
 	cached, err := s.redis.Get(ctx, cacheKey).Result()
 	if err == nil {
+		if cached == geocodeNotFoundMarker {
+			s.countGeocodeLookup("negative_hit")
+			return nil, notFound
+		}
 		var location weather.Location
 		if err := json.Unmarshal([]byte(cached), &location); err == nil {
+			s.countGeocodeLookup("hit")
 			return &location, nil
 		}
 	}
+	s.countGeocodeLookup("miss")
 
-	// Try OpenWeatherMap API first if geocoder is available
-	if s.geocoder != nil {
-		location, err := s.geocoder.GeocodeLocation(ctx, locationName)
-		if err == nil {
-			// Cache for 24 hours
-			if err := s.cacheLocation(ctx, cacheKey, location); err != nil {
-				s.logger.Error().
-					Err(err).
-					Str("location", locationName).
-					Str("service", "openweathermap").
-					Msg("Failed to cache geocoding result")
-			}
-			return location, nil
+	// Try the weather providers first
+	location, err := fromProviders(ctx, s, "geocode", func(ctx context.Context, provider weather.WeatherProvider) (*weather.Location, error) {
+		return provider.GeocodeLocation(ctx, locationName)
+	})
+	if err == nil {
+		if err := s.cacheLocation(ctx, cacheKey, location); err != nil {
+			s.logger.Error().
+				Err(err).
+				Str("location", locationName).
+				Msg("Failed to cache geocoding result")
 		}
-		s.logger.Debug().
-			Err(err).
-			Str("location", locationName).
-			Msg("OpenWeatherMap geocoding failed, trying Nominatim")
+		return location, nil
 	}
+	s.logger.Debug().
+		Err(err).
+		Str("location", locationName).
+		Msg("Provider geocoding failed, trying Nominatim")
 
 	// Try Nominatim as fallback
 	nominatimLocation, err := s.geocodeWithNominatim(ctx, locationName)
 	if err == nil {
-		// Cache successful Nominatim results for 24 hours
 		if err := s.cacheLocation(ctx, cacheKey, nominatimLocation); err != nil {
 			s.logger.Error().
 				Err(err).
@@ -233,17 +535,81 @@ func (s *WeatherService) GeocodeLocation(ctx context.Context, locationName strin
 		return nominatimLocation, nil
 	}
 
-	return nil, fmt.Errorf("location '%s' not found - please check the spelling or try a major city name", locationName)
+	// Only a definite "no such place" is remembered; provider outages are not,
+	// and are reported as such
+	if !errors.Is(err, errNominatimNotFound) {
+		return nil, fmt.Errorf("failed to geocode '%s': %w", locationName, err)
+	}
+	if err := s.redis.Set(ctx, cacheKey, geocodeNotFoundMarker, geocodeNegativeTTL).Err(); err != nil {
+		s.logger.Warn().Err(err).Str("cache_key", cacheKey).Msg("Failed to cache failed geocoding lookup")
+	}
+
+	return nil, notFound
+}
+
+// NormalizeGeocodeQuery reduces a location query to the form geocoding results
+// are cached under: lowercase, punctuation dropped and whitespace collapsed, so
+// that "St. Louis", "st louis" and " ST  LOUIS " share one entry
+func NormalizeGeocodeQuery(query string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(query) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			continue
+		}
+		b.WriteRune(' ')
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// countWeatherRequest counts a request to the weather provider by its outcome,
+// "ok" or the class of its failure, and how long it took
+func (s *WeatherService) countWeatherRequest(api string, elapsed time.Duration, err error) {
+	if s.metrics == nil {
+		return
+	}
+	status := "ok"
+	if err != nil {
+		status = WeatherErrorClass(err)
+	}
+	s.metrics.IncrementCounter("weather_requests_total", api, status)
+	s.metrics.ObserveHistogram("weather_api_duration_seconds", elapsed.Seconds(), api)
 }
 
-// cacheLocation is a helper method to cache location data
+// countProviderRequest counts a request to one weather provider by its
+// outcome, including the failed ones another provider answered after
+func (s *WeatherService) countProviderRequest(provider, api string, err error) {
+	if s.metrics == nil {
+		return
+	}
+	status := "ok"
+	if err != nil {
+		status = WeatherErrorClass(err)
+	}
+	s.metrics.IncrementCounter("weather_provider_requests_total", provider, api, status)
+}
+
+func (s *WeatherService) countCacheLookup(hit bool) {
+	if s.metrics != nil {
+		s.metrics.RecordCacheLookup("weather", hit)
+	}
+}
+
+func (s *WeatherService) countGeocodeLookup(result string) {
+	if s.metrics != nil {
+		s.metrics.IncrementCounter("geocode_cache_lookups_total", result)
+	}
+}
+
+// cacheLocation is a helper method to cache location data. A successful
+// lookup replaces a negative entry under the same key.
 func (s *WeatherService) cacheLocation(ctx context.Context, cacheKey string, location *weather.Location) error {
 	locationJSON, err := json.Marshal(location)
 	if err != nil {
 		return fmt.Errorf("failed to marshal location for caching: %w", err)
 	}
 
-	return s.redis.Set(ctx, cacheKey, locationJSON, 24*time.Hour).Err()
+	return s.redis.Set(ctx, cacheKey, locationJSON, geocodeCacheTTL).Err()
 }
 
 // GetCurrentWeatherByCoords gets weather data by coordinates (alias for GetCompleteWeatherData)
@@ -251,7 +617,9 @@ func (s *WeatherService) GetCurrentWeatherByCoords(ctx context.Context, lat, lon
 	return s.GetCompleteWeatherData(ctx, lat, lon)
 }
 
-// GetCurrentWeatherByLocation gets weather data by location name
+// GetCurrentWeatherByLocation gets weather data by location name. A key set
+// on ctx with weather.WithAPIKey is used for OpenWeather requests in place of
+// the bot's.
 func (s *WeatherService) GetCurrentWeatherByLocation(ctx context.Context, locationName string) (*WeatherData, error) {
 	// First geocode the location name to get coordinates
 	location, err := s.GeocodeLocation(ctx, locationName)
@@ -269,9 +637,76 @@ func (s *WeatherService) GetCurrentWeatherByLocation(ctx context.Context, locati
 	weatherData.LocationName = location.Name
 	weatherData.Location = location
 
+	s.storeLastKnownWeather(ctx, locationName, weatherData)
+
 	return weatherData, nil
 }
 
+// WeatherResult is weather fetched for a location along with how fresh it is
+type WeatherResult struct {
+	Data *WeatherData
+	// StaleDataWarning is set when the provider could not be reached and Data
+	// is the last weather fetched for the location
+	StaleDataWarning bool
+	// CacheAge is how old stale Data is
+	CacheAge time.Duration
+}
+
+// GetWeatherResultByLocation gets weather by location name like
+// GetCurrentWeatherByLocation, except that when the provider fails it falls
+// back to the last weather fetched for the location, marked stale. A location
+// that does not exist still fails.
+func (s *WeatherService) GetWeatherResultByLocation(ctx context.Context, locationName string) (*WeatherResult, error) {
+	weatherData, err := s.GetCurrentWeatherByLocation(ctx, locationName)
+	if err == nil {
+		return &WeatherResult{Data: weatherData}, nil
+	}
+	if errors.Is(err, weather.ErrNotFound) {
+		return nil, err
+	}
+
+	lastKnown, cacheErr := s.lastKnownWeather(ctx, locationName)
+	if cacheErr != nil {
+		return nil, err
+	}
+
+	age := time.Since(lastKnown.Timestamp)
+	s.logger.Warn().Err(err).Str("location", locationName).Dur("age", age).Msg("Serving stale weather data")
+	return &WeatherResult{Data: lastKnown, StaleDataWarning: true, CacheAge: age}, nil
+}
+
+func lastKnownWeatherKey(locationName string) string {
+	return lastKnownWeatherKeyPrefix + NormalizeGeocodeQuery(locationName)
+}
+
+// storeLastKnownWeather keeps weather fetched for a location to fall back on
+// during a provider outage
+func (s *WeatherService) storeLastKnownWeather(ctx context.Context, locationName string, data *WeatherData) {
+	cacheKey := lastKnownWeatherKey(locationName)
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	if err := s.redis.Set(ctx, cacheKey, dataJSON, lastKnownWeatherTTL).Err(); err != nil {
+		s.logger.Warn().Err(err).Str("cache_key", cacheKey).Msg("Failed to store last known weather")
+	}
+}
+
+// lastKnownWeather returns the last weather fetched for a location
+func (s *WeatherService) lastKnownWeather(ctx context.Context, locationName string) (*WeatherData, error) {
+	cached, err := s.redis.Get(ctx, lastKnownWeatherKey(locationName)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var data WeatherData
+	if err := json.Unmarshal([]byte(cached), &data); err != nil {
+		return nil, err
+	}
+	data.FromCache = true
+	return &data, nil
+}
+
 // GetLocationName returns a formatted location name from coordinates (reverse geocoding)
 func (s *WeatherService) GetLocationName(ctx context.Context, lat, lon float64) (string, error) {
 	// Try cache first
@@ -299,6 +734,9 @@ func (s *WeatherService) GetLocationName(ctx context.Context, lat, lon float64)
 
 // ToModelWeatherData converts service WeatherData to models.WeatherData
 func (wd *WeatherData) ToModelWeatherData() *models.WeatherData {
+	sourceTimestamp := wd.Timestamp.UTC()
+	fromCache := wd.FromCache
+
 	return &models.WeatherData{
 		Temperature: wd.Temperature,
 		Humidity:    wd.Humidity,
@@ -316,18 +754,23 @@ func (wd *WeatherData) ToModelWeatherData() *models.WeatherData {
 		PM25:        wd.PM25,
 		PM10:        wd.PM10,
 		Timestamp:   wd.Timestamp,
+
+		SourceTimestamp: &sourceTimestamp,
+		FromCache:       &fromCache,
 	}
 }
 
 // WeatherData represents weather data compatible with models.WeatherData
 type WeatherData struct {
 	Temperature   float64           `json:"temperature"`
+	FeelsLike     float64           `json:"feels_like"`
 	Humidity      int               `json:"humidity"`
 	Pressure      float64           `json:"pressure"`
 	WindSpeed     float64           `json:"wind_speed"`
 	WindDirection int               `json:"wind_direction"`
 	Visibility    float64           `json:"visibility"`
 	UVIndex       float64           `json:"uv_index"`
+	Precipitation float64           `json:"precipitation"` // Rain and snow in the last hour, mm
 	Description   string            `json:"description"`
 	Icon          string            `json:"icon"`
 	LocationName  string            `json:"location_name"`
@@ -338,7 +781,12 @@ type WeatherData struct {
 	O3            float64           `json:"o3"`
 	PM25          float64           `json:"pm25"`
 	PM10          float64           `json:"pm10"`
+	Sunrise       time.Time         `json:"sunrise"`    // UTC
+	Sunset        time.Time         `json:"sunset"`     // UTC
+	MoonPhase     float64           `json:"moon_phase"` // weather.MoonPhase at Timestamp
+	UTCOffset     int               `json:"utc_offset"`
 	Timestamp     time.Time         `json:"timestamp"`
+	FromCache     bool              `json:"from_cache"`
 }
 
 // GetCompleteWeatherData gets both weather and air quality data
@@ -363,12 +811,14 @@ func (s *WeatherService) GetCompleteWeatherData(ctx context.Context, lat, lon fl
 
 	return &WeatherData{
 		Temperature:   weatherData.Temperature,
+		FeelsLike:     weatherData.FeelsLike,
 		Humidity:      weatherData.Humidity,
 		Pressure:      weatherData.Pressure,
 		WindSpeed:     weatherData.WindSpeed,
 		WindDirection: weatherData.WindDirection,
 		Visibility:    weatherData.Visibility,
 		UVIndex:       weatherData.UVIndex,
+		Precipitation: weatherData.Precipitation,
 		Description:   weatherData.Description,
 		Icon:          weatherData.Icon,
 		LocationName:  weatherData.LocationName,
@@ -378,7 +828,12 @@ func (s *WeatherService) GetCompleteWeatherData(ctx context.Context, lat, lon fl
 		O3:            air.O3,
 		PM25:          air.PM25,
 		PM10:          air.PM10,
+		Sunrise:       weatherData.Sunrise,
+		Sunset:        weatherData.Sunset,
+		MoonPhase:     weather.MoonPhase(weatherData.Timestamp),
+		UTCOffset:     weatherData.UTCOffset,
 		Timestamp:     weatherData.Timestamp,
+		FromCache:     weatherData.FromCache,
 	}, nil
 }
 
@@ -397,7 +852,7 @@ func (s *WeatherService) geocodeWithNominatim(ctx context.Context, locationName
 
 	resp, err := s.httpClient.Do(req) // #nosec G704
 	if err != nil {
-		return nil, fmt.Errorf("failed to make Nominatim request: %w", err)
+		return nil, fmt.Errorf("nominatim: %w", weather.RequestError(err))
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -406,7 +861,7 @@ func (s *WeatherService) geocodeWithNominatim(ctx context.Context, locationName
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("nominatim API request failed with status: %d", resp.StatusCode)
+		return nil, fmt.Errorf("nominatim: %w", weather.StatusError(resp))
 	}
 
 	var apiResponse []struct {
@@ -422,7 +877,7 @@ func (s *WeatherService) geocodeWithNominatim(ctx context.Context, locationName
 	}
 
 	if len(apiResponse) == 0 {
-		return nil, fmt.Errorf("location not found in Nominatim")
+		return nil, errNominatimNotFound
 	}
 
 	result := apiResponse[0]
@@ -459,12 +914,23 @@ func (s *WeatherService) geocodeWithNominatim(ctx context.Context, locationName
 
 // reverseGeocodeWithNominatim performs reverse geocoding using Nominatim
 func (s *WeatherService) reverseGeocodeWithNominatim(ctx context.Context, lat, lon float64) (string, error) {
+	address, err := s.reverseGeocodeAddressWithNominatim(ctx, lat, lon)
+	if err != nil {
+		return "", err
+	}
+
+	// Determine the most appropriate location name and format
+	return s.formatLocationFromAddress(*address, lat, lon), nil
+}
+
+// reverseGeocodeAddressWithNominatim returns the structured address for coordinates using Nominatim
+func (s *WeatherService) reverseGeocodeAddressWithNominatim(ctx context.Context, lat, lon float64) (*NominatimAddress, error) {
 	url := fmt.Sprintf("https://nominatim.openstreetmap.org/reverse?lat=%.6f&lon=%.6f&format=json&addressdetails=1",
 		lat, lon)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create Nominatim reverse request: %w", err)
+		return nil, fmt.Errorf("failed to create Nominatim reverse request: %w", err)
 	}
 
 	// Set User-Agent as required by Nominatim usage policy
@@ -472,7 +938,7 @@ func (s *WeatherService) reverseGeocodeWithNominatim(ctx context.Context, lat, l
 
 	resp, err := s.httpClient.Do(req) // #nosec G704
 	if err != nil {
-		return "", fmt.Errorf("failed to make Nominatim reverse request: %w", err)
+		return nil, fmt.Errorf("failed to make Nominatim reverse request: %w", err)
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -481,7 +947,7 @@ func (s *WeatherService) reverseGeocodeWithNominatim(ctx context.Context, lat, l
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("nominatim reverse API request failed with status: %d", resp.StatusCode)
+		return nil, fmt.Errorf("nominatim reverse API request failed with status: %d", resp.StatusCode)
 	}
 
 	var result struct {
@@ -493,11 +959,52 @@ func (s *WeatherService) reverseGeocodeWithNominatim(ctx context.Context, lat, l
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("failed to decode Nominatim reverse response: %w", err)
+		return nil, fmt.Errorf("failed to decode Nominatim reverse response: %w", err)
 	}
 
-	// Determine the most appropriate location name and format
-	return s.formatLocationFromAddress(result.Address, lat, lon), nil
+	return &result.Address, nil
+}
+
+// ApproximateLocation resolves coordinates to the centroid of the surrounding city.
+// Used for city-level location privacy: the exact point is never returned.
+func (s *WeatherService) ApproximateLocation(ctx context.Context, lat, lon float64) (*weather.Location, error) {
+	address, err := s.reverseGeocodeAddressWithNominatim(ctx, lat, lon)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reverse geocode coordinates: %w", err)
+	}
+
+	city := cityFromAddress(*address)
+	if city == "" {
+		return nil, fmt.Errorf("no city found near (%.4f, %.4f)", lat, lon)
+	}
+
+	query := city
+	if address.Country != "" {
+		query = fmt.Sprintf("%s, %s", city, address.Country)
+	}
+
+	centroid, err := s.GeocodeLocation(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to geocode city centroid: %w", err)
+	}
+
+	return &weather.Location{
+		Name:      city,
+		Country:   centroid.Country,
+		City:      city,
+		Latitude:  centroid.Latitude,
+		Longitude: centroid.Longitude,
+	}, nil
+}
+
+// cityFromAddress picks the settlement-level name from an address, falling back to wider areas
+func cityFromAddress(address NominatimAddress) string {
+	for _, name := range []string{address.City, address.Town, address.Village, address.County, address.State} {
+		if name != "" {
+			return name
+		}
+	}
+	return ""
 }
 
 // formatLocationFromAddress formats location name with smart logic for exact vs nearby matches
@@ -541,3 +1048,221 @@ func (s *WeatherService) formatLocationFromAddress(address NominatimAddress, lat
 		return fmt.Sprintf("near %s %s", locationName, coords)
 	}
 }
+
+// maxPlaceCandidates is how many alternative places are offered at once
+const maxPlaceCandidates = 3
+
+// placeSearchLimit is how many matches each fallback search asks the geocoder for
+const placeSearchLimit = 5
+
+// placeQualifiers are administrative words people add to place names that the
+// geocoder does not expect, such as "village" or "district"
+var placeQualifiers = map[string]bool{
+	"district": true, "raion": true, "rayon": true, "region": true, "oblast": true, "county": true,
+	"province": true, "municipality": true, "community": true, "hromada": true, "village": true,
+	"town": true, "city": true, "settlement": true,
+	"район": true, "р-н": true, "область": true, "обл.": true, "громада": true, "село": true, "с.": true,
+	"селище": true, "смт": true, "місто": true, "м.": true,
+	"landkreis": true, "kreis": true, "gemeinde": true, "dorf": true, "stadt": true,
+	"commune": true, "département": true, "municipio": true, "provincia": true, "pueblo": true,
+}
+
+// PlaceCandidate is a named place offered instead of, or next to, what the user
+// asked for. Distances are relative to RelativeTo, or to the user's own
+// position when RelativeTo is empty.
+type PlaceCandidate struct {
+	Location   weather.Location
+	Region     string  // State, or country when the state is unknown
+	DistanceKm float64 // Zero for the reference place itself
+	Direction  string  // Compass point (N, NE, ...) from the reference
+	RelativeTo string
+}
+
+// SuggestPlaces looks for places close to a name the geocoder could not
+// resolve. It retries with qualifiers such as "district" removed and with each
+// comma-separated part on its own, and returns up to three distinct matches.
+// The first match is the reference the others are described relative to,
+// since the location the user meant is unknown.
+func (s *WeatherService) SuggestPlaces(ctx context.Context, locationName string) ([]PlaceCandidate, error) {
+	if len(s.providers) == 0 {
+		return nil, nil
+	}
+
+	var found []weather.Location
+	for _, query := range fallbackQueries(locationName) {
+		locations, err := s.searchLocations(ctx, query, placeSearchLimit)
+		if err != nil {
+			s.logger.Debug().Err(err).Str("query", query).Msg("Fallback place search failed")
+			continue
+		}
+		found = appendDistinctPlaces(found, locations)
+		if len(found) >= maxPlaceCandidates {
+			break
+		}
+	}
+
+	return relativePlaceCandidates(found), nil
+}
+
+// NearbyPlaces lists the three named places closest to the coordinates, for
+// users who prefer a place name over a raw GPS position
+func (s *WeatherService) NearbyPlaces(ctx context.Context, lat, lon float64) ([]PlaceCandidate, error) {
+	locations, err := fromProviders(ctx, s, "reverse_geocode", func(ctx context.Context, provider weather.WeatherProvider) ([]weather.Location, error) {
+		return provider.ReverseLocations(ctx, lat, lon, placeSearchLimit)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find nearby places: %w", err)
+	}
+
+	return placesAround(lat, lon, locations), nil
+}
+
+// searchLocations returns up to limit places matching the name from the first
+// provider that answers
+func (s *WeatherService) searchLocations(ctx context.Context, locationName string, limit int) ([]weather.Location, error) {
+	return fromProviders(ctx, s, "search", func(ctx context.Context, provider weather.WeatherProvider) ([]weather.Location, error) {
+		return provider.SearchLocations(ctx, locationName, limit)
+	})
+}
+
+// fallbackQueries builds the searches for a place name the geocoder could not
+// resolve, most specific first. The original name comes first: searched again
+// for several matches rather than the single best one, it also covers one-word
+// misspellings that have no qualifiers or parts to drop.
+func fallbackQueries(locationName string) []string {
+	original := strings.Join(strings.Fields(locationName), " ")
+
+	var queries []string
+	seen := map[string]bool{}
+	add := func(query string) {
+		key := strings.ToLower(query)
+		if len([]rune(query)) < 2 || seen[key] {
+			return
+		}
+		seen[key] = true
+		queries = append(queries, query)
+	}
+
+	add(original)
+
+	var parts []string
+	for _, part := range strings.Split(original, ",") {
+		if cleaned := stripPlaceQualifiers(part); cleaned != "" {
+			parts = append(parts, cleaned)
+		}
+	}
+
+	for _, part := range parts {
+		add(part)
+		if strings.Contains(part, "-") {
+			add(strings.ReplaceAll(part, "-", " "))
+		}
+	}
+
+	return queries
+}
+
+// stripPlaceQualifiers removes administrative words from a place name
+func stripPlaceQualifiers(name string) string {
+	var kept []string
+	for _, word := range strings.Fields(name) {
+		if !placeQualifiers[strings.ToLower(word)] {
+			kept = append(kept, word)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// appendDistinctPlaces adds locations that are not already present, treating
+// places closer than one kilometre as the same
+func appendDistinctPlaces(places []weather.Location, locations []weather.Location) []weather.Location {
+	for _, location := range locations {
+		duplicate := false
+		for _, existing := range places {
+			if distanceKm(existing.Latitude, existing.Longitude, location.Latitude, location.Longitude) < 1 {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			places = append(places, location)
+		}
+	}
+	return places
+}
+
+// relativePlaceCandidates describes up to three places relative to the first
+func relativePlaceCandidates(locations []weather.Location) []PlaceCandidate {
+	if len(locations) > maxPlaceCandidates {
+		locations = locations[:maxPlaceCandidates]
+	}
+
+	candidates := make([]PlaceCandidate, 0, len(locations))
+	for i, location := range locations {
+		candidate := PlaceCandidate{Location: location, Region: placeRegion(location)}
+		if i > 0 {
+			anchor := locations[0]
+			candidate.RelativeTo = anchor.Name
+			candidate.DistanceKm = distanceKm(anchor.Latitude, anchor.Longitude, location.Latitude, location.Longitude)
+			candidate.Direction = compassDirection(anchor.Latitude, anchor.Longitude, location.Latitude, location.Longitude)
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	return candidates
+}
+
+// placesAround returns the three places closest to the point, nearest first
+func placesAround(lat, lon float64, locations []weather.Location) []PlaceCandidate {
+	candidates := make([]PlaceCandidate, 0, len(locations))
+	for _, location := range appendDistinctPlaces(nil, locations) {
+		candidates = append(candidates, PlaceCandidate{
+			Location:   location,
+			Region:     placeRegion(location),
+			DistanceKm: distanceKm(lat, lon, location.Latitude, location.Longitude),
+			Direction:  compassDirection(lat, lon, location.Latitude, location.Longitude),
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].DistanceKm < candidates[j].DistanceKm
+	})
+	if len(candidates) > maxPlaceCandidates {
+		candidates = candidates[:maxPlaceCandidates]
+	}
+
+	return candidates
+}
+
+func placeRegion(location weather.Location) string {
+	if location.State != "" {
+		return location.State
+	}
+	return location.Country
+}
+
+// distanceKm is the great-circle distance between two points
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadiusKm * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// compassDirection is the eight-point compass direction of the second point as
+// seen from the first
+func compassDirection(lat1, lon1, lat2, lon2 float64) string {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dLambda := (lon2 - lon1) * math.Pi / 180
+
+	y := math.Sin(dLambda) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dLambda)
+	return weather.CompassPoint(math.Atan2(y, x) * 180 / math.Pi)
+}
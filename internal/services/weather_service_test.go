@@ -1,19 +1,25 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/go-redis/redismock/v9"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/pkg/metrics"
 	"github.com/valpere/shopogoda/pkg/weather"
+	"github.com/valpere/shopogoda/tests/helpers"
 )
 
 func TestNewWeatherService(t *testing.T) {
@@ -29,7 +35,8 @@ func TestNewWeatherService(t *testing.T) {
 
 	assert.NotNil(t, service)
 	assert.NotNil(t, service.client)
-	assert.NotNil(t, service.geocoder)
+	require.Len(t, service.providers, 1)
+	assert.Equal(t, weather.ProviderOpenWeatherMap, service.providers[0].Name())
 	assert.NotNil(t, service.redis)
 	assert.NotNil(t, service.httpClient)
 	assert.Equal(t, cfg, service.config)
@@ -82,7 +89,7 @@ func TestGetCurrentWeather(t *testing.T) {
 			Humidity:    60,
 		}
 		cachedJSON, _ := json.Marshal(cachedData)
-		cacheKey := "weather:current:50.4501:30.5234"
+		cacheKey := "weather:current:50.45:30.52"
 
 		mock.ExpectGet(cacheKey).SetVal(string(cachedJSON))
 
@@ -92,6 +99,7 @@ func TestGetCurrentWeather(t *testing.T) {
 		assert.NotNil(t, result)
 		assert.Equal(t, 20.5, result.Temperature)
 		assert.Equal(t, "Clear sky", result.Description)
+		assert.True(t, result.FromCache)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
@@ -104,7 +112,7 @@ func TestGetCurrentWeather(t *testing.T) {
 
 		ctx := context.Background()
 		lat, lon := 50.4501, 30.5234
-		cacheKey := "weather:current:50.4501:30.5234"
+		cacheKey := "weather:current:50.45:30.52"
 
 		// Expect cache miss
 		mock.ExpectGet(cacheKey).RedisNil()
@@ -120,6 +128,72 @@ func TestGetCurrentWeather(t *testing.T) {
 	})
 }
 
+func TestWeatherCache(t *testing.T) {
+	logger := zerolog.Nop()
+
+	t.Run("neighbours share one entry and lookups feed the hit rate", func(t *testing.T) {
+		rdb, mock := redismock.NewClientMock()
+		service := NewWeatherService(&config.WeatherConfig{OpenWeatherAPIKey: "test-key"}, rdb, &logger)
+		metricsCollector := metrics.New()
+		service.SetMetrics(metricsCollector)
+
+		cachedJSON, _ := json.Marshal(weather.WeatherData{Temperature: 20.5})
+		mock.ExpectGet("weather:current:50.45:30.52").SetVal(string(cachedJSON))
+		mock.ExpectGet("weather:current:50.45:30.52").SetVal(string(cachedJSON))
+		mock.ExpectGet("weather:current:50.45:30.52").RedisNil()
+
+		// A few hundred metres apart
+		_, err := service.GetCurrentWeather(context.Background(), 50.4501, 30.5234)
+		require.NoError(t, err)
+		_, err = service.GetCurrentWeather(context.Background(), 50.4543, 30.5198)
+		require.NoError(t, err)
+
+		// A miss goes to the provider, unreachable here
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = service.GetCurrentWeather(ctx, 50.4501, 30.5234)
+		assert.Error(t, err)
+
+		assert.InDelta(t, 66.7, metricsCollector.GetCacheHitRate("weather"), 0.1)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("TTLs come from the configuration", func(t *testing.T) {
+		rdb, _ := redismock.NewClientMock()
+		service := NewWeatherService(&config.WeatherConfig{CurrentCacheTTL: 5 * time.Minute, ForecastCacheTTL: 3 * time.Hour}, rdb, &logger)
+		assert.Equal(t, 5*time.Minute, service.currentCacheTTL())
+		assert.Equal(t, 3*time.Hour, service.forecastCacheTTL())
+
+		service = NewWeatherService(&config.WeatherConfig{}, rdb, &logger)
+		assert.Equal(t, 10*time.Minute, service.currentCacheTTL())
+		assert.Equal(t, time.Hour, service.forecastCacheTTL())
+	})
+
+	t.Run("refresh drops current weather and air quality of the place", func(t *testing.T) {
+		rdb, mock := redismock.NewClientMock()
+		service := NewWeatherService(&config.WeatherConfig{OpenWeatherAPIKey: "test-key"}, rdb, &logger)
+
+		locationJSON, _ := json.Marshal(weather.Location{Name: "Kyiv", Latitude: 50.4501, Longitude: 30.5234})
+		mock.ExpectGet("geocode:kyiv").SetVal(string(locationJSON))
+		mock.ExpectDel("weather:current:50.45:30.52", "weather:air:50.45:30.52").SetVal(2)
+
+		assert.NoError(t, service.InvalidateCurrentWeather(context.Background(), "Kyiv"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("refresh drops current weather in the language asked for", func(t *testing.T) {
+		rdb, mock := redismock.NewClientMock()
+		service := NewWeatherService(&config.WeatherConfig{OpenWeatherAPIKey: "test-key"}, rdb, &logger)
+
+		locationJSON, _ := json.Marshal(weather.Location{Name: "Kyiv", Latitude: 50.4501, Longitude: 30.5234})
+		mock.ExpectGet("geocode:kyiv").SetVal(string(locationJSON))
+		mock.ExpectDel("weather:current:50.45:30.52", "weather:current:50.45:30.52:uk", "weather:air:50.45:30.52").SetVal(3)
+
+		assert.NoError(t, service.InvalidateCurrentWeather(weather.WithLanguage(context.Background(), "uk-UA"), "Kyiv"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
 func TestGetForecast(t *testing.T) {
 	logger := zerolog.Nop()
 
@@ -143,7 +217,7 @@ func TestGetForecast(t *testing.T) {
 			},
 		}
 		cachedJSON, _ := json.Marshal(cachedData)
-		cacheKey := "weather:forecast:50.4501:30.5234:5"
+		cacheKey := "weather:forecast:50.45:30.52:5"
 
 		mock.ExpectGet(cacheKey).SetVal(string(cachedJSON))
 
@@ -154,6 +228,22 @@ func TestGetForecast(t *testing.T) {
 		assert.Len(t, result.Forecasts, 2)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
+
+	t.Run("caches forecasts in each language apart", func(t *testing.T) {
+		rdb, mock := redismock.NewClientMock()
+		service := NewWeatherService(&config.WeatherConfig{OpenWeatherAPIKey: "test-key"}, rdb, &logger)
+
+		cachedJSON, _ := json.Marshal(weather.ForecastData{
+			Location:  "Kyiv",
+			Forecasts: []weather.DailyForecast{{MinTemp: 18.0, MaxTemp: 22.0, Description: "ясно"}},
+		})
+		mock.ExpectGet("weather:forecast:50.45:30.52:5:uk").SetVal(string(cachedJSON))
+
+		result, err := service.GetForecast(weather.WithLanguage(context.Background(), "uk-UA"), 50.4501, 30.5234, 5)
+		require.NoError(t, err)
+		assert.Equal(t, "ясно", result.Forecasts[0].Description)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 }
 
 func TestGetAirQuality(t *testing.T) {
@@ -177,7 +267,7 @@ func TestGetAirQuality(t *testing.T) {
 			PM10: 25.0,
 		}
 		cachedJSON, _ := json.Marshal(cachedData)
-		cacheKey := "weather:air:50.4501:30.5234"
+		cacheKey := "weather:air:50.45:30.52"
 
 		mock.ExpectGet(cacheKey).SetVal(string(cachedJSON))
 
@@ -190,6 +280,57 @@ func TestGetAirQuality(t *testing.T) {
 	})
 }
 
+func TestGetAirQualityForecast(t *testing.T) {
+	logger := zerolog.Nop()
+
+	t.Run("returns cached forecast", func(t *testing.T) {
+		rdb, mock := redismock.NewClientMock()
+		service := NewWeatherService(&config.WeatherConfig{OpenWeatherAPIKey: "test-key"}, rdb, &logger)
+
+		cached := weather.AirQualityForecast{Hours: []weather.AirQualityHour{
+			{Time: time.Date(2026, 4, 16, 9, 0, 0, 0, time.UTC), AQI: 97, O3: 135.93},
+		}}
+		cachedJSON, _ := json.Marshal(cached)
+		mock.ExpectGet("weather:air_forecast:50.45:30.52:72").SetVal(string(cachedJSON))
+
+		result, err := service.GetAirQualityForecast(context.Background(), 50.4501, 30.5234, weather.AirQualityForecastHours)
+
+		require.NoError(t, err)
+		require.Len(t, result.Hours, 1)
+		assert.Equal(t, 97, result.Hours[0].AQI)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("needs an OpenWeather key", func(t *testing.T) {
+		rdb, mock := redismock.NewClientMock()
+		service := NewWeatherService(&config.WeatherConfig{}, rdb, &logger)
+
+		_, err := service.GetAirQualityForecast(context.Background(), 50.4501, 30.5234, weather.AirQualityForecastHours)
+
+		assert.ErrorIs(t, err, weather.ErrNotSupported)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetAstroData(t *testing.T) {
+	logger := zerolog.Nop()
+	rdb, mock := redismock.NewClientMock()
+	// Computed, so no provider key is needed
+	service := NewWeatherService(&config.WeatherConfig{}, rdb, &logger)
+	kyiv := time.FixedZone("EET", 2*60*60)
+
+	data, err := service.GetAstroData(context.Background(), 50.4501, 30.5234, time.Date(2024, 12, 21, 9, 0, 0, 0, kyiv))
+
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 12, 21, 0, 0, 0, 0, kyiv), data.Date)
+	assert.Equal(t, weather.MoonWaningGibbous, data.MoonPhase)
+	assert.False(t, data.AstroTwilight.IsZero())
+
+	_, err = service.GetAstroData(context.Background(), 91, 30.5234, time.Now())
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestGeocodeLocation(t *testing.T) {
 	logger := zerolog.Nop()
 
@@ -224,6 +365,137 @@ func TestGeocodeLocation(t *testing.T) {
 	})
 }
 
+// nominatimStub answers Nominatim searches with a fixed status and body and
+// counts the requests that reached it
+type nominatimStub struct {
+	status   int
+	body     string
+	requests int
+}
+
+func (n *nominatimStub) RoundTrip(r *http.Request) (*http.Response, error) {
+	n.requests++
+	return &http.Response{
+		StatusCode: n.status,
+		Body:       io.NopCloser(strings.NewReader(n.body)),
+		Header:     make(http.Header),
+		Request:    r,
+	}, nil
+}
+
+func TestNormalizeGeocodeQuery(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"Kyiv", "kyiv"},
+		{"  St. Louis ", "st louis"},
+		{"ST   LOUIS", "st louis"},
+		{"Lndon!?", "lndon"},
+		{"Ivano-Frankivsk", "ivano frankivsk"},
+		{"Біла   Церква", "біла церква"},
+		{"...", ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, NormalizeGeocodeQuery(tt.query), tt.query)
+	}
+}
+
+func TestGeocodeLocation_CacheHit(t *testing.T) {
+	logger := zerolog.Nop()
+	rdb, mock := redismock.NewClientMock()
+	service := NewWeatherService(&config.WeatherConfig{OpenWeatherAPIKey: "test-key"}, rdb, &logger)
+	service.providers = nil
+	stub := &nominatimStub{status: http.StatusOK, body: `[{"lat":"50.4501","lon":"30.5234","display_name":"Kyiv, Ukraine"}]`}
+	service.httpClient = &http.Client{Transport: stub}
+	service.SetMetrics(metrics.New())
+
+	cached := &weather.Location{Name: "Kyiv", Country: "Ukraine", Latitude: 50.4501, Longitude: 30.5234}
+	cachedJSON, _ := json.Marshal(cached)
+	for _, query := range []string{"Kyiv", "  KYIV ", "kyiv."} {
+		mock.ExpectGet("geocode:kyiv").SetVal(string(cachedJSON))
+
+		location, err := service.GeocodeLocation(context.Background(), query)
+		require.NoError(t, err, query)
+		assert.Equal(t, cached, location, query)
+	}
+
+	assert.Zero(t, stub.requests, "cache hits must not reach the geocoding API")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGeocodeLocation_NegativeCache(t *testing.T) {
+	logger := zerolog.Nop()
+	ctx := context.Background()
+
+	newService := func(stub *nominatimStub) (*WeatherService, redismock.ClientMock) {
+		rdb, mock := redismock.NewClientMock()
+		service := NewWeatherService(&config.WeatherConfig{OpenWeatherAPIKey: "test-key"}, rdb, &logger)
+		service.providers = nil
+		service.httpClient = &http.Client{Transport: stub}
+		service.SetMetrics(metrics.New())
+		return service, mock
+	}
+
+	t.Run("a miss is remembered for the negative TTL", func(t *testing.T) {
+		stub := &nominatimStub{status: http.StatusOK, body: "[]"}
+		service, mock := newService(stub)
+
+		mock.ExpectGet("geocode:lndon").RedisNil()
+		mock.ExpectSet("geocode:lndon", geocodeNotFoundMarker, geocodeNegativeTTL).SetVal("OK")
+
+		_, err := service.GeocodeLocation(ctx, "Lndon")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+		assert.Equal(t, 1, stub.requests)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("repeats that normalize alike are answered from the negative entry", func(t *testing.T) {
+		stub := &nominatimStub{status: http.StatusOK, body: "[]"}
+		service, mock := newService(stub)
+
+		for _, query := range []string{"Lndon", " LNDON! ", "lndon."} {
+			mock.ExpectGet("geocode:lndon").SetVal(geocodeNotFoundMarker)
+
+			_, err := service.GeocodeLocation(ctx, query)
+			require.Error(t, err, query)
+			assert.Contains(t, err.Error(), "not found")
+		}
+		assert.Zero(t, stub.requests, "negative hits must not reach the provider")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("a provider outage is not remembered", func(t *testing.T) {
+		stub := &nominatimStub{status: http.StatusServiceUnavailable}
+		service, mock := newService(stub)
+
+		mock.ExpectGet("geocode:london").RedisNil()
+
+		_, err := service.GeocodeLocation(ctx, "London")
+		require.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("a later successful lookup overwrites the negative entry", func(t *testing.T) {
+		stub := &nominatimStub{status: http.StatusOK, body: `[{"lat":"51.5074","lon":"-0.1278","display_name":"London, Greater London, England, United Kingdom"}]`}
+		service, mock := newService(stub)
+
+		expected := &weather.Location{Latitude: 51.5074, Longitude: -0.1278, Name: "London", Country: "United Kingdom", City: "London"}
+		expectedJSON, _ := json.Marshal(expected)
+
+		// The negative entry has expired; the positive one replaces it under the same key
+		mock.ExpectGet("geocode:london").RedisNil()
+		mock.ExpectSet("geocode:london", expectedJSON, geocodeCacheTTL).SetVal("OK")
+
+		location, err := service.GeocodeLocation(ctx, "London,")
+		require.NoError(t, err)
+		assert.Equal(t, expected, location)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
 func TestCacheLocation(t *testing.T) {
 	logger := zerolog.Nop()
 	rdb, mock := redismock.NewClientMock()
@@ -242,7 +514,7 @@ func TestCacheLocation(t *testing.T) {
 
 	cacheKey := "geocode:test"
 	locationJSON, _ := json.Marshal(location)
-	mock.ExpectSet(cacheKey, locationJSON, 24*time.Hour).SetVal("OK")
+	mock.ExpectSet(cacheKey, locationJSON, geocodeCacheTTL).SetVal("OK")
 
 	err := service.cacheLocation(ctx, cacheKey, location)
 
@@ -289,6 +561,52 @@ func TestGetCurrentWeatherByLocation(t *testing.T) {
 	assert.Error(t, err) // Expected failure on weather API
 }
 
+func TestGetWeatherResultByLocation(t *testing.T) {
+	logger := zerolog.Nop()
+	geocodeJSON, _ := json.Marshal(weather.Location{
+		Latitude: 50.4501, Longitude: 30.5234, Name: "Kyiv", Country: "UA",
+	})
+
+	// A canceled request makes the provider unreachable without the network
+	unreachable, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	newService := func() (*WeatherService, redismock.ClientMock) {
+		rdb, mock := redismock.NewClientMock()
+		service := NewWeatherService(&config.WeatherConfig{OpenWeatherAPIKey: "test-key"}, rdb, &logger)
+		mock.ExpectGet("geocode:kyiv").SetVal(string(geocodeJSON))
+		mock.ExpectGet("weather:current:50.45:30.52").RedisNil()
+		return service, mock
+	}
+
+	t.Run("serves the last known weather when the provider is down", func(t *testing.T) {
+		service, mock := newService()
+		lastKnown, _ := json.Marshal(WeatherData{
+			LocationName: "Kyiv",
+			Temperature:  12.5,
+			Timestamp:    time.Now().Add(-3 * time.Hour),
+		})
+		mock.ExpectGet("weather:kyiv").SetVal(string(lastKnown))
+
+		result, err := service.GetWeatherResultByLocation(unreachable, " KYIV ")
+		require.NoError(t, err)
+		assert.True(t, result.StaleDataWarning)
+		assert.InDelta(t, 3*time.Hour, result.CacheAge, float64(time.Minute))
+		assert.Equal(t, 12.5, result.Data.Temperature)
+		assert.True(t, result.Data.FromCache)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("fails without weather to fall back on", func(t *testing.T) {
+		service, mock := newService()
+		mock.ExpectGet("weather:kyiv").RedisNil()
+
+		result, err := service.GetWeatherResultByLocation(unreachable, "Kyiv")
+		assert.ErrorIs(t, err, weather.ErrUnavailable)
+		assert.Nil(t, result)
+	})
+}
+
 func TestGetLocationName(t *testing.T) {
 	logger := zerolog.Nop()
 
@@ -450,6 +768,7 @@ func TestWeatherData_ToModelWeatherData(t *testing.T) {
 		PM25:          12.5,
 		PM10:          18.3,
 		Timestamp:     time.Now().UTC(),
+		FromCache:     true,
 	}
 
 	result := wd.ToModelWeatherData()
@@ -471,6 +790,10 @@ func TestWeatherData_ToModelWeatherData(t *testing.T) {
 	assert.Equal(t, wd.PM25, result.PM25)
 	assert.Equal(t, wd.PM10, result.PM10)
 	assert.Equal(t, wd.Timestamp, result.Timestamp)
+	require.NotNil(t, result.SourceTimestamp)
+	assert.Equal(t, wd.Timestamp, *result.SourceTimestamp)
+	require.NotNil(t, result.FromCache)
+	assert.True(t, *result.FromCache)
 }
 
 func TestGetForecast_CacheMiss(t *testing.T) {
@@ -484,7 +807,7 @@ func TestGetForecast_CacheMiss(t *testing.T) {
 	ctx := context.Background()
 	lat, lon := 50.4501, 30.5234
 	days := 5
-	cacheKey := "weather:forecast:50.4501:30.5234:5"
+	cacheKey := "weather:forecast:50.45:30.52:5"
 
 	// Expect cache miss
 	mock.ExpectGet(cacheKey).RedisNil()
@@ -507,7 +830,7 @@ func TestGetAirQuality_CacheMiss(t *testing.T) {
 
 	ctx := context.Background()
 	lat, lon := 50.4501, 30.5234
-	cacheKey := "weather:air:50.4501:30.5234"
+	cacheKey := "weather:air:50.45:30.52"
 
 	// Expect cache miss
 	mock.ExpectGet(cacheKey).RedisNil()
@@ -551,11 +874,11 @@ func TestGetCompleteWeatherData_Success(t *testing.T) {
 	lat, lon := 50.4501, 30.5234
 
 	// Expect weather cache miss
-	weatherCacheKey := "weather:current:50.4501:30.5234"
+	weatherCacheKey := "weather:current:50.45:30.52"
 	mock.ExpectGet(weatherCacheKey).RedisNil()
 
 	// Expect air quality cache miss
-	airCacheKey := "weather:air:50.4501:30.5234"
+	airCacheKey := "weather:air:50.45:30.52"
 	mock.ExpectGet(airCacheKey).RedisNil()
 
 	// API calls will fail in unit test - this tests the error handling path
@@ -571,3 +894,255 @@ func TestGetCompleteWeatherData_AirQualityFailure(t *testing.T) {
 	// which is complex in unit tests. This path is better tested in integration tests.
 	t.Skip("Integration test - requires HTTP mocking")
 }
+
+func TestWeatherService_ReportUnknownField(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	rdb, _ := redismock.NewClientMock()
+	service := NewWeatherService(&config.WeatherConfig{OpenWeatherAPIKey: "test-key"}, rdb, &logger)
+	service.SetMetrics(metrics.New())
+
+	service.reportUnknownField(weather.EndpointCurrentWeather, "uvi")
+	service.reportUnknownField(weather.EndpointCurrentWeather, "uvi")
+	service.reportUnknownField(weather.EndpointForecast, "uvi")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"endpoint":"current_weather"`)
+	assert.Contains(t, lines[0], `"field":"uvi"`)
+	assert.Contains(t, lines[1], `"endpoint":"forecast"`)
+}
+
+func TestFallbackQueries(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "drops administrative qualifiers",
+			input:    "Velyka Dymerka village",
+			expected: []string{"Velyka Dymerka village", "Velyka Dymerka"},
+		},
+		{
+			name:     "tries each comma-separated part",
+			input:    "Velyka Dymerka, Brovary district, Kyiv oblast",
+			expected: []string{"Velyka Dymerka, Brovary district, Kyiv oblast", "Velyka Dymerka", "Brovary", "Kyiv"},
+		},
+		{
+			name:     "Ukrainian qualifiers",
+			input:    "село Пилипча, Білоцерківський район",
+			expected: []string{"село Пилипча, Білоцерківський район", "Пилипча", "Білоцерківський"},
+		},
+		{
+			name:     "hyphenated names also tried with spaces",
+			input:    "Novo-Petrivtsi",
+			expected: []string{"Novo-Petrivtsi", "Novo Petrivtsi"},
+		},
+		{
+			name:     "one-word misspelling is searched again for several matches",
+			input:    "Kyyv",
+			expected: []string{"Kyyv"},
+		},
+		{
+			name:     "qualifier only",
+			input:    "district",
+			expected: []string{"district"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, fallbackQueries(tt.input))
+		})
+	}
+}
+
+func TestRelativePlaceCandidates(t *testing.T) {
+	kyiv := weather.Location{Name: "Kyiv", State: "Kyiv", Country: "UA", Latitude: 50.4501, Longitude: 30.5234}
+	brovary := weather.Location{Name: "Brovary", State: "Kyiv Oblast", Country: "UA", Latitude: 50.5114, Longitude: 30.7903}
+	vyshneve := weather.Location{Name: "Vyshneve", Country: "UA", Latitude: 50.3886, Longitude: 30.3708}
+	irpin := weather.Location{Name: "Irpin", State: "Kyiv Oblast", Country: "UA", Latitude: 50.5218, Longitude: 30.2506}
+	kyivAgain := weather.Location{Name: "Kiev", Country: "UA", Latitude: 50.4502, Longitude: 30.5236}
+
+	places := appendDistinctPlaces(nil, []weather.Location{kyiv, kyivAgain, brovary})
+	places = appendDistinctPlaces(places, []weather.Location{vyshneve, irpin})
+	candidates := relativePlaceCandidates(places)
+
+	require.Len(t, candidates, 3)
+
+	assert.Equal(t, "Kyiv", candidates[0].Location.Name)
+	assert.Empty(t, candidates[0].RelativeTo)
+	assert.Empty(t, candidates[0].Direction)
+
+	assert.Equal(t, "Brovary", candidates[1].Location.Name)
+	assert.Equal(t, "Kyiv Oblast", candidates[1].Region)
+	assert.Equal(t, "Kyiv", candidates[1].RelativeTo)
+	assert.Equal(t, "E", candidates[1].Direction)
+	assert.InDelta(t, 20.3, candidates[1].DistanceKm, 0.5)
+
+	assert.Equal(t, "Vyshneve", candidates[2].Location.Name)
+	assert.Equal(t, "UA", candidates[2].Region)
+	assert.Equal(t, "SW", candidates[2].Direction)
+}
+
+func TestPlacesAround(t *testing.T) {
+	locations := []weather.Location{
+		{Name: "Irpin", Latitude: 50.5218, Longitude: 30.2506},
+		{Name: "Brovary", Latitude: 50.5114, Longitude: 30.7903},
+		{Name: "Kyiv", Latitude: 50.4500, Longitude: 30.5241},
+		{Name: "Vyshneve", Latitude: 50.3886, Longitude: 30.3708},
+	}
+
+	candidates := placesAround(50.4600, 30.5300, locations)
+
+	require.Len(t, candidates, 3)
+	assert.Equal(t, "Kyiv", candidates[0].Location.Name)
+	assert.Equal(t, "S", candidates[0].Direction)
+	assert.Less(t, candidates[0].DistanceKm, 2.0)
+	assert.Equal(t, "Vyshneve", candidates[1].Location.Name)
+	assert.Equal(t, "Brovary", candidates[2].Location.Name)
+	for _, candidate := range candidates {
+		assert.Empty(t, candidate.RelativeTo)
+	}
+}
+
+func TestCompassDirection(t *testing.T) {
+	assert.Equal(t, "N", compassDirection(50, 30, 51, 30))
+	assert.Equal(t, "E", compassDirection(50, 30, 50, 31))
+	assert.Equal(t, "S", compassDirection(50, 30, 49, 30))
+	assert.Equal(t, "W", compassDirection(50, 30, 50, 29))
+	assert.Equal(t, "NE", compassDirection(50, 30, 50.5, 30.8))
+}
+
+// stubProvider answers current weather and place searches with fixed results
+type stubProvider struct {
+	name     string
+	current  *weather.WeatherData
+	err      error
+	requests int
+}
+
+func (p *stubProvider) Name() string { return p.name }
+
+func (p *stubProvider) GetCurrentWeather(ctx context.Context, lat, lon float64) (*weather.WeatherData, error) {
+	p.requests++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.current, nil
+}
+
+func (p *stubProvider) GetForecast(ctx context.Context, lat, lon float64, days int) (*weather.ForecastData, error) {
+	return nil, weather.ErrNotSupported
+}
+
+func (p *stubProvider) GetHourlyForecast(ctx context.Context, lat, lon float64, hours int) (*weather.HourlyForecastData, error) {
+	return nil, weather.ErrNotSupported
+}
+
+func (p *stubProvider) GetAirQuality(ctx context.Context, lat, lon float64) (*weather.AirQualityData, error) {
+	return nil, weather.ErrNotSupported
+}
+
+func (p *stubProvider) GeocodeLocation(ctx context.Context, locationName string) (*weather.Location, error) {
+	return nil, weather.ErrNotSupported
+}
+
+func (p *stubProvider) SearchLocations(ctx context.Context, locationName string, limit int) ([]weather.Location, error) {
+	return nil, weather.ErrNotSupported
+}
+
+func (p *stubProvider) ReverseLocations(ctx context.Context, lat, lon float64, limit int) ([]weather.Location, error) {
+	p.requests++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return nil, weather.ErrNotSupported
+}
+
+func TestWeatherService_Providers(t *testing.T) {
+	logger := zerolog.Nop()
+
+	t.Run("configured order, unknown names skipped", func(t *testing.T) {
+		rdb, _ := redismock.NewClientMock()
+		service := NewWeatherService(&config.WeatherConfig{Providers: " OpenMeteo, darksky ,openweathermap,openmeteo"}, rdb, &logger)
+
+		require.Len(t, service.providers, 2)
+		assert.Equal(t, weather.ProviderOpenMeteo, service.providers[0].Name())
+		assert.Equal(t, weather.ProviderOpenWeatherMap, service.providers[1].Name())
+	})
+
+	t.Run("falls back to the next provider and logs which one answered", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		rdb, mock := redismock.NewClientMock()
+		service := NewWeatherService(&config.WeatherConfig{}, rdb, &logger)
+		metricsCollector, registry := helpers.NewTestMetrics()
+		service.SetMetrics(metricsCollector)
+		primary := &stubProvider{name: "primary", err: &weather.RateLimitError{RetryAfter: time.Minute}}
+		secondary := &stubProvider{name: "secondary", current: &weather.WeatherData{Temperature: 12.5}}
+		service.providers = []weather.WeatherProvider{primary, secondary}
+
+		mock.ExpectGet("weather:current:50.45:30.52").RedisNil()
+		mock.Regexp().ExpectSet("weather:current:50.45:30.52", `.*`, 10*time.Minute).SetVal("OK")
+
+		current, err := service.GetCurrentWeather(context.Background(), 50.4501, 30.5234)
+
+		require.NoError(t, err)
+		assert.Equal(t, 12.5, current.Temperature)
+		assert.Equal(t, 1, primary.requests)
+		assert.NoError(t, mock.ExpectationsWereMet())
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 2)
+		assert.Contains(t, lines[0], `"provider":"primary"`)
+		assert.Contains(t, lines[0], `"level":"warn"`)
+		assert.Contains(t, lines[1], `"provider":"secondary"`)
+		assert.Contains(t, lines[1], `"message":"Weather request served"`)
+
+		series := helpers.ScrapeMetrics(t, registry)
+		assert.Equal(t, 1.0, series[`weather_provider_requests_total{api="current",provider="primary",status="rate-limited"}`])
+		assert.Equal(t, 1.0, series[`weather_provider_requests_total{api="current",provider="secondary",status="ok"}`])
+		assert.Equal(t, 1.0, series[`weather_requests_total{api="current",status="ok"}`])
+		assert.Equal(t, 1.0, series[`weather_api_duration_seconds{api="current"}`])
+	})
+
+	t.Run("the error of the last provider that supports the request is returned", func(t *testing.T) {
+		rdb, _ := redismock.NewClientMock()
+		service := NewWeatherService(&config.WeatherConfig{}, rdb, &logger)
+		primary := &stubProvider{name: "primary", err: weather.ErrUnavailable}
+		secondary := &stubProvider{name: "secondary"}
+		service.providers = []weather.WeatherProvider{primary, secondary}
+
+		_, err := service.NearbyPlaces(context.Background(), 50.4501, 30.5234)
+
+		assert.ErrorIs(t, err, weather.ErrUnavailable)
+		assert.Equal(t, 1, secondary.requests)
+	})
+
+	t.Run("a slow provider gives way after the provider timeout", func(t *testing.T) {
+		rdb, _ := redismock.NewClientMock()
+		service := NewWeatherService(&config.WeatherConfig{ProviderTimeout: 10 * time.Millisecond}, rdb, &logger)
+		slow := &slowProvider{stubProvider: stubProvider{name: "slow"}}
+		fast := &stubProvider{name: "fast", current: &weather.WeatherData{Temperature: 3}}
+		service.providers = []weather.WeatherProvider{slow, fast}
+
+		current, err := fromProviders(context.Background(), service, "current", func(ctx context.Context, provider weather.WeatherProvider) (*weather.WeatherData, error) {
+			return provider.GetCurrentWeather(ctx, 50.45, 30.52)
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 3.0, current.Temperature)
+	})
+}
+
+// slowProvider answers current weather only once the request is given up on
+type slowProvider struct {
+	stubProvider
+}
+
+func (p *slowProvider) GetCurrentWeather(ctx context.Context, lat, lon float64) (*weather.WeatherData, error) {
+	<-ctx.Done()
+	return nil, weather.RequestError(ctx.Err())
+}
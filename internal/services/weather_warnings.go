@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+const (
+	// warningCheckInterval is how often official warnings are looked up for
+	// extreme weather subscribers
+	warningCheckInterval = 30 * time.Minute
+
+	// warningSentRetention keeps a sent warning's mark past its end, so that a
+	// late copy from the provider is not taken for a new warning
+	warningSentRetention = time.Hour
+)
+
+// weatherWarningSentKey marks a warning as sent to a chat
+func weatherWarningSentKey(chatID int64, warningID string) string {
+	return fmt.Sprintf("weather_warning:%d:%s", chatID, warningID)
+}
+
+// processWeatherWarnings sends the official warnings in effect at each
+// extreme weather subscriber's location. Every warning reaches a chat once,
+// however many checks it stays in effect for.
+func (s *SchedulerService) processWeatherWarnings(ctx context.Context, now time.Time) {
+	var subscriptions []models.Subscription
+	if err := s.db.WithContext(ctx).
+		Preload("User").
+		Where("subscription_type = ? AND is_active = ?", models.SubscriptionExtreme, true).
+		Find(&subscriptions).Error; err != nil {
+		s.logger.Error().Err(err).Msg("Failed to get extreme weather subscriptions")
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		user := s.deliveryUser(ctx, subscription)
+		if !user.IsActive || !user.HasLocation() {
+			continue
+		}
+		chatID := subscription.DeliveryChatID()
+		if s.chatUnwritable(ctx, chatID) {
+			s.logger.Info().Int64("chat_id", chatID).Msg("Paused weather warnings, bot cannot post in chat")
+			continue
+		}
+
+		warnings, err := s.weather.GetWeatherWarnings(ctx, user.Latitude, user.Longitude)
+		if errors.Is(err, weather.ErrNotSupported) {
+			// Not configured; the same holds for every other subscriber
+			return
+		}
+		if err != nil {
+			s.logger.Error().Err(err).
+				Str("location", user.LocationName).
+				Int64("user_id", user.ID).
+				Msg("Failed to get weather warnings")
+			continue
+		}
+
+		for i := range warnings {
+			if warnings[i].InEffect(now) {
+				s.sendWeatherWarning(ctx, &user, chatID, &warnings[i], now)
+			}
+		}
+	}
+}
+
+// sendWeatherWarning sends a warning to the chat unless it was sent there
+// before. A failed send is retried on the next check.
+func (s *SchedulerService) sendWeatherWarning(ctx context.Context, user *models.User, chatID int64, warning *weather.WeatherWarning, now time.Time) {
+	key := weatherWarningSentKey(chatID, warning.ID)
+	claimed, err := s.redis.SetNX(ctx, key, now.Unix(), max(warning.End.Sub(now), 0)+warningSentRetention).Result()
+	if err != nil {
+		s.logger.Warn().Err(err).Int64("chat_id", chatID).Str("warning_id", warning.ID).Msg("Failed to check sent weather warning")
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	err = s.notification.SendTelegramWeatherWarning(user, chatID, warning)
+	if err != nil {
+		if delErr := s.redis.Del(ctx, key).Err(); delErr != nil {
+			s.logger.Warn().Err(delErr).Str("key", key).Msg("Failed to release weather warning for retry")
+		}
+	}
+
+	if s.metrics != nil {
+		status := "sent"
+		if err != nil {
+			status = "failed"
+		}
+		s.metrics.IncrementCounter("subscription_dispatches_total", models.SubscriptionExtreme.String(), status)
+	}
+}
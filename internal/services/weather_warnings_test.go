@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/config"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/weather"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestSchedulerService_SendWeatherWarning(t *testing.T) {
+	now := time.Date(2026, 4, 15, 9, 0, 0, 0, time.UTC)
+	warning := &weather.WeatherWarning{
+		ID:       "3f2a9c1d8e7b6a50",
+		Event:    "Orange Wind Warning",
+		Severity: weather.WarningSevere,
+		Start:    time.Date(2026, 4, 15, 5, 0, 0, 0, time.UTC),
+		End:      time.Date(2026, 4, 15, 21, 0, 0, 0, time.UTC),
+	}
+	user := &models.User{ID: 123, Language: "en-US", LocationName: "Kyiv", IsActive: true}
+	ttl := 12*time.Hour + warningSentRetention
+
+	newScheduler := func(api *restrictedBotAPI) (*SchedulerService, *helpers.MockRedis) {
+		logger := helpers.NewSilentTestLogger()
+		mockRedis := helpers.NewMockRedis()
+		notification := NewNotificationService(&config.IntegrationsConfig{}, logger)
+		notification.SetBot(&gotgbot.Bot{Token: "test", BotClient: api})
+		return NewSchedulerService(nil, mockRedis.Client, nil, nil, notification, logger), mockRedis
+	}
+
+	t.Run("sends a new warning once", func(t *testing.T) {
+		api := &restrictedBotAPI{}
+		service, mockRedis := newScheduler(api)
+
+		mockRedis.Mock.ExpectSetNX("weather_warning:123:3f2a9c1d8e7b6a50", now.Unix(), ttl).SetVal(true)
+		service.sendWeatherWarning(context.Background(), user, 123, warning, now)
+		mockRedis.Mock.ExpectSetNX("weather_warning:123:3f2a9c1d8e7b6a50", now.Unix(), ttl).SetVal(false)
+		service.sendWeatherWarning(context.Background(), user, 123, warning, now)
+
+		require.Len(t, api.requests, 1)
+		assert.Contains(t, api.requests[0].text, "Orange Wind Warning")
+		mockRedis.ExpectationsWereMet(t)
+	})
+
+	t.Run("retries a warning that failed to send", func(t *testing.T) {
+		api := &restrictedBotAPI{forbidden: map[int64]*gotgbot.TelegramError{123: errMuted}}
+		service, mockRedis := newScheduler(api)
+
+		mockRedis.Mock.ExpectSetNX("weather_warning:123:3f2a9c1d8e7b6a50", now.Unix(), ttl).SetVal(true)
+		mockRedis.Mock.ExpectDel("weather_warning:123:3f2a9c1d8e7b6a50").SetVal(1)
+		service.sendWeatherWarning(context.Background(), user, 123, warning, now)
+
+		mockRedis.ExpectationsWereMet(t)
+	})
+}
+
+func TestSchedulerService_ProcessWeatherWarnings(t *testing.T) {
+	t.Run("stops when warnings are not configured", func(t *testing.T) {
+		mockDB := helpers.NewMockDB(t)
+		defer func() { _ = mockDB.Close() }()
+		mockRedis := helpers.NewMockRedis()
+		logger := helpers.NewSilentTestLogger()
+		weatherService := NewWeatherService(&config.WeatherConfig{OpenWeatherAPIKey: "test-key"}, mockRedis.Client, logger)
+		service := NewSchedulerService(mockDB.DB, mockRedis.Client, weatherService, nil, NewNotificationService(&config.IntegrationsConfig{}, logger), logger)
+
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "subscriptions" WHERE subscription_type = \$1 AND is_active = \$2`).
+			WithArgs(models.SubscriptionExtreme, true).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "subscription_type", "is_active"}).
+				AddRow(uuid.New(), int64(123), models.SubscriptionExtreme, true).
+				AddRow(uuid.New(), int64(456), models.SubscriptionExtreme, true))
+		mockDB.Mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" IN \(\$1,\$2\)`).
+			WithArgs(int64(123), int64(456)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "is_active", "location_name", "latitude", "longitude"}).
+				AddRow(int64(123), true, "Kyiv", 50.45, 30.52).
+				AddRow(int64(456), true, "Lviv", 49.84, 24.03))
+
+		service.processWeatherWarnings(context.Background(), time.Now())
+
+		mockDB.ExpectationsWereMet(t)
+		mockRedis.ExpectationsWereMet(t)
+	})
+}
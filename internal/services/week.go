@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/valpere/shopogoda/internal/models"
+)
+
+// sundayFirstCountries are the countries whose calendars start the week on
+// Sunday (CLDR); everywhere else weeks start on Monday
+var sundayFirstCountries = map[string]bool{
+	"AG": true, "AS": true, "BD": true, "BR": true, "BS": true, "BT": true,
+	"BW": true, "BZ": true, "CA": true, "CO": true, "DM": true, "DO": true,
+	"ET": true, "GT": true, "GU": true, "HK": true, "HN": true, "ID": true,
+	"IL": true, "IN": true, "JM": true, "JP": true, "KE": true, "KH": true,
+	"KR": true, "LA": true, "MH": true, "MM": true, "MO": true, "MT": true,
+	"MX": true, "MZ": true, "NI": true, "NP": true, "PA": true, "PE": true,
+	"PH": true, "PK": true, "PR": true, "PY": true, "SA": true, "SG": true,
+	"SV": true, "TH": true, "TT": true, "TW": true, "UM": true, "US": true,
+	"VE": true, "VI": true, "WS": true, "YE": true, "ZA": true, "ZW": true,
+}
+
+// FirstDayOfWeek returns the day the user's weeks start on: the explicit
+// setting, else the convention of the country of their location, else of the
+// region of their language (en-US starts on Sunday, uk-UA on Monday)
+func FirstDayOfWeek(user *models.User) time.Weekday {
+	if user == nil {
+		return time.Monday
+	}
+
+	switch user.WeekStart {
+	case models.WeekStartMonday:
+		return time.Monday
+	case models.WeekStartSunday:
+		return time.Sunday
+	}
+
+	country := strings.ToUpper(user.Country)
+	if country == "" {
+		if _, region, ok := strings.Cut(user.Language, "-"); ok {
+			country = strings.ToUpper(region)
+		}
+	}
+	if sundayFirstCountries[country] {
+		return time.Sunday
+	}
+	return time.Monday
+}
+
+// WeekRange returns the first and the last day, at midnight in t's location,
+// of the week that contains t when weeks start on firstDay
+func WeekRange(t time.Time, firstDay time.Weekday) (time.Time, time.Time) {
+	offset := (int(t.Weekday()) - int(firstDay) + 7) % 7
+	start := time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 0, 6)
+}
+
+// usesISOWeekNumbers reports whether week numbers are shown: Monday-first
+// locales number weeks by ISO 8601, Sunday-first ones rarely number them
+func usesISOWeekNumbers(firstDay time.Weekday) bool {
+	return firstDay == time.Monday
+}
+
+// weekHeader renders the range of the week containing t for the weekly
+// digest, with the ISO week number where the user's convention uses it
+func weekHeader(ctx context.Context, localization *LocalizationService, user *models.User, t time.Time) string {
+	language := user.Language
+	firstDay := FirstDayOfWeek(user)
+	start, end := WeekRange(t, firstDay)
+
+	layout := localization.T(ctx, language, "date_format_day_month")
+	if usesISOWeekNumbers(firstDay) {
+		_, week := t.ISOWeek()
+		return localization.T(ctx, language, "weekly_digest_week_iso", week, start.Format(layout), end.Format(layout))
+	}
+	return localization.T(ctx, language, "weekly_digest_week", start.Format(layout), end.Format(layout))
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/locales"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestFirstDayOfWeek(t *testing.T) {
+	tests := []struct {
+		name string
+		user *models.User
+		want time.Weekday
+	}{
+		{"unknown user", nil, time.Monday},
+		{"US English", &models.User{Language: "en-US"}, time.Sunday},
+		{"Ukrainian", &models.User{Language: "uk-UA"}, time.Monday},
+		{"location country wins over language", &models.User{Language: "en-US", Country: "UA"}, time.Monday},
+		{"Sunday-first location", &models.User{Language: "de-DE", Country: "br"}, time.Sunday},
+		{"explicit setting wins", &models.User{Language: "en-US", Country: "US", WeekStart: models.WeekStartMonday}, time.Monday},
+		{"explicit Sunday", &models.User{Language: "uk-UA", WeekStart: models.WeekStartSunday}, time.Sunday},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, FirstDayOfWeek(tt.user))
+		})
+	}
+}
+
+func TestWeekRange(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name      string
+		t         time.Time
+		firstDay  time.Weekday
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		// ISO week 1 of 2025 runs from Monday, December 30, 2024
+		{"Monday-first, last day of the year", time.Date(2024, 12, 31, 18, 30, 0, 0, time.UTC), time.Monday, date(2024, 12, 30), date(2025, 1, 5)},
+		{"Monday-first, first day of the year", date(2025, 1, 1), time.Monday, date(2024, 12, 30), date(2025, 1, 5)},
+		{"Monday-first, on the Sunday", date(2025, 1, 5), time.Monday, date(2024, 12, 30), date(2025, 1, 5)},
+		{"Monday-first, on the Monday", date(2024, 12, 30), time.Monday, date(2024, 12, 30), date(2025, 1, 5)},
+		{"Sunday-first, last day of the year", date(2024, 12, 31), time.Sunday, date(2024, 12, 29), date(2025, 1, 4)},
+		{"Sunday-first, first day of the year", date(2025, 1, 1), time.Sunday, date(2024, 12, 29), date(2025, 1, 4)},
+		{"Sunday-first, on the Sunday", date(2025, 1, 5), time.Sunday, date(2025, 1, 5), date(2025, 1, 11)},
+		{"Sunday-first, on the Saturday", date(2025, 1, 4), time.Sunday, date(2024, 12, 29), date(2025, 1, 4)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := WeekRange(tt.t, tt.firstDay)
+			assert.Equal(t, tt.wantStart, start)
+			assert.Equal(t, tt.wantEnd, end)
+		})
+	}
+}
+
+func TestWeekHeader(t *testing.T) {
+	logger := helpers.NewSilentTestLogger()
+	localization := NewLocalizationService(logger)
+	require.NoError(t, localization.LoadTranslations(locales.LocalesFS))
+	newYearsEve := time.Date(2024, 12, 31, 9, 0, 0, 0, time.UTC)
+
+	t.Run("ISO week number for Monday-first locales", func(t *testing.T) {
+		header := weekHeader(context.Background(), localization, &models.User{Language: "uk-UA"}, newYearsEve)
+		assert.Equal(t, "🗓 Тиждень 1 · 30.12 – 05.01", header)
+	})
+
+	t.Run("no week number for Sunday-first locales", func(t *testing.T) {
+		header := weekHeader(context.Background(), localization, &models.User{Language: "en-US"}, newYearsEve)
+		assert.Equal(t, "🗓 Week of Dec 29 – Jan 4", header)
+	})
+
+	t.Run("explicit Monday start shows the week number", func(t *testing.T) {
+		header := weekHeader(context.Background(), localization, &models.User{Language: "en-US", WeekStart: models.WeekStartMonday}, newYearsEve)
+		assert.Equal(t, "🗓 Week 1 · Dec 30 – Jan 5", header)
+	})
+}
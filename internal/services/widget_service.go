@@ -0,0 +1,308 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+
+	"github.com/valpere/shopogoda/internal"
+	"github.com/valpere/shopogoda/internal/markdown"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/pkg/weather"
+)
+
+// ErrWidgetExists is returned when a chat already has a live weather widget
+var ErrWidgetExists = errors.New("chat already has a live weather widget")
+
+// Refresh schedule of a new widget, in the user's local time
+const (
+	defaultWidgetIntervalHours = 3
+	defaultWidgetWindowStart   = "06:00"
+	defaultWidgetWindowEnd     = "22:00"
+)
+
+// WidgetUnpinCallback is the callback data of the button under a widget that stops it
+const WidgetUnpinCallback = "widget_unpin"
+
+// WidgetService keeps one pinned message per private chat up to date with
+// the current weather at the user's saved location
+type WidgetService struct {
+	db           *gorm.DB
+	weather      *WeatherService
+	localization *LocalizationService
+	logger       *zerolog.Logger
+	bot          *gotgbot.Bot
+	now          func() time.Time
+}
+
+func NewWidgetService(db *gorm.DB, weather *WeatherService, localization *LocalizationService, logger *zerolog.Logger) *WidgetService {
+	return &WidgetService{
+		db:           db,
+		weather:      weather,
+		localization: localization,
+		logger:       logger,
+		now:          time.Now,
+	}
+}
+
+// SetBot sets the bot used to send, pin and edit widget messages
+func (s *WidgetService) SetBot(bot *gotgbot.Bot) {
+	s.bot = bot
+}
+
+// Get returns the widget of a chat, or nil when the chat has none
+func (s *WidgetService) Get(ctx context.Context, chatID int64) (*models.WeatherWidget, error) {
+	var widget models.WeatherWidget
+	err := s.db.WithContext(ctx).Where("chat_id = ?", chatID).First(&widget).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &widget, nil
+}
+
+// Pin sends the current weather at the user's saved location to the chat,
+// pins the message and stores it as the chat's widget. A chat holds at most
+// one widget; ErrWidgetExists is returned when it already has one.
+func (s *WidgetService) Pin(ctx context.Context, chatID int64, user *models.User) (*models.WeatherWidget, error) {
+	if s.bot == nil {
+		return nil, fmt.Errorf("bot not configured for widgets")
+	}
+
+	existing, err := s.Get(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrWidgetExists
+	}
+
+	current, err := s.weather.GetCurrentWeatherByCoords(ctx, user.Latitude, user.Longitude)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get weather for widget: %w", err)
+	}
+
+	now := s.now().UTC()
+	message, err := s.bot.SendMessageWithContext(ctx, chatID, s.render(ctx, user, current, now), &gotgbot.SendMessageOpts{
+		ParseMode:   "Markdown",
+		ReplyMarkup: s.markup(ctx, user),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send widget: %w", err)
+	}
+
+	if _, err := s.bot.PinChatMessageWithContext(ctx, chatID, message.MessageId, &gotgbot.PinChatMessageOpts{DisableNotification: true}); err != nil {
+		return nil, fmt.Errorf("failed to pin widget: %w", err)
+	}
+
+	widget := &models.WeatherWidget{
+		ChatID:          chatID,
+		UserID:          user.ID,
+		MessageID:       message.MessageId,
+		IntervalHours:   defaultWidgetIntervalHours,
+		WindowStart:     defaultWidgetWindowStart,
+		WindowEnd:       defaultWidgetWindowEnd,
+		LastRefreshedAt: &now,
+	}
+	if err := s.db.WithContext(ctx).Create(widget).Error; err != nil {
+		s.unpinMessage(ctx, chatID, message.MessageId)
+		return nil, fmt.Errorf("failed to save widget: %w", err)
+	}
+
+	s.logger.Info().Int64("chat_id", chatID).Int64("message_id", message.MessageId).Msg("Live weather widget pinned")
+	return widget, nil
+}
+
+// Unpin stops updating the chat's widget and unpins its message. It reports
+// whether the chat had a widget.
+func (s *WidgetService) Unpin(ctx context.Context, chatID int64) (bool, error) {
+	widget, err := s.Get(ctx, chatID)
+	if err != nil || widget == nil {
+		return false, err
+	}
+
+	if err := s.remove(ctx, chatID); err != nil {
+		return false, err
+	}
+	s.unpinMessage(ctx, chatID, widget.MessageID)
+	return true, nil
+}
+
+func (s *WidgetService) remove(ctx context.Context, chatID int64) error {
+	return s.db.WithContext(ctx).Where("chat_id = ?", chatID).Delete(&models.WeatherWidget{}).Error
+}
+
+// unpinMessage unpins a widget message; the user may already have unpinned or
+// deleted it, so failures are only logged
+func (s *WidgetService) unpinMessage(ctx context.Context, chatID, messageID int64) {
+	if s.bot == nil {
+		return
+	}
+	if _, err := s.bot.UnpinChatMessageWithContext(ctx, chatID, &gotgbot.UnpinChatMessageOpts{MessageId: &messageID}); err != nil {
+		s.logger.Debug().Err(err).Int64("chat_id", chatID).Msg("Could not unpin widget message")
+	}
+}
+
+// RefreshDue edits every widget whose refresh slot has come with fresh weather
+func (s *WidgetService) RefreshDue(ctx context.Context) {
+	if s.bot == nil {
+		return
+	}
+
+	var widgets []models.WeatherWidget
+	if err := s.db.WithContext(ctx).Preload("User").Find(&widgets).Error; err != nil {
+		s.logger.Error().Err(err).Msg("Failed to load weather widgets")
+		return
+	}
+
+	now := s.now().UTC()
+	for _, widget := range widgets {
+		// Paused accounts keep their widget, which resumes on reactivation
+		if !widget.User.IsActive {
+			continue
+		}
+		if !widgetRefreshDue(widget, now.In(userLocation(&widget.User))) {
+			continue
+		}
+		if err := s.refresh(ctx, widget, now); err != nil {
+			s.logger.Warn().Err(err).Int64("chat_id", widget.ChatID).Msg("Failed to refresh weather widget")
+		}
+	}
+}
+
+func (s *WidgetService) refresh(ctx context.Context, widget models.WeatherWidget, now time.Time) error {
+	if s.unpinnedByUser(ctx, widget) {
+		s.logger.Info().Int64("chat_id", widget.ChatID).Msg("Widget was unpinned, stopping updates")
+		return s.remove(ctx, widget.ChatID)
+	}
+
+	if !widget.User.HasLocation() {
+		return fmt.Errorf("user %d has no location", widget.UserID)
+	}
+	current, err := s.weather.GetCurrentWeatherByCoords(ctx, widget.User.Latitude, widget.User.Longitude)
+	if err != nil {
+		return err
+	}
+	return s.update(ctx, widget, current, now)
+}
+
+// update edits the widget message in place. When the message no longer exists
+// the widget record is dropped.
+func (s *WidgetService) update(ctx context.Context, widget models.WeatherWidget, current *WeatherData, now time.Time) error {
+	_, _, err := s.bot.EditMessageTextWithContext(WithBulkPriority(ctx), s.render(ctx, &widget.User, current, now), &gotgbot.EditMessageTextOpts{
+		ChatId:      widget.ChatID,
+		MessageId:   widget.MessageID,
+		ParseMode:   "Markdown",
+		ReplyMarkup: *s.markup(ctx, &widget.User),
+	})
+	if widgetMessageGone(err) {
+		s.logger.Info().Err(err).Int64("chat_id", widget.ChatID).Msg("Widget message is gone, removing widget")
+		return s.remove(ctx, widget.ChatID)
+	}
+	if err != nil && !MessageNotModified(err) {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Model(&models.WeatherWidget{}).
+		Where("chat_id = ?", widget.ChatID).
+		Update("last_refreshed_at", now).Error
+}
+
+// unpinnedByUser reports whether the widget is known to be unpinned. Telegram
+// only reports the most recently sent pinned message, so the widget counts as
+// unpinned when nothing is pinned or the latest pinned message is older.
+func (s *WidgetService) unpinnedByUser(ctx context.Context, widget models.WeatherWidget) bool {
+	chat, err := s.bot.GetChatWithContext(ctx, widget.ChatID, nil)
+	if err != nil {
+		return false
+	}
+	return chat.PinnedMessage == nil || chat.PinnedMessage.MessageId < widget.MessageID
+}
+
+// widgetMessageGone reports whether an edit failed because the message was deleted
+func widgetMessageGone(err error) bool {
+	var tgErr *gotgbot.TelegramError
+	if !errors.As(err, &tgErr) {
+		return false
+	}
+	description := strings.ToLower(tgErr.Description)
+	return strings.Contains(description, "message to edit not found") ||
+		strings.Contains(description, "message_id_invalid") ||
+		strings.Contains(description, "message can't be edited")
+}
+
+// MessageNotModified reports whether an edit was rejected because the text is unchanged
+func MessageNotModified(err error) bool {
+	var tgErr *gotgbot.TelegramError
+	return errors.As(err, &tgErr) && strings.Contains(strings.ToLower(tgErr.Description), "message is not modified")
+}
+
+// widgetRefreshDue reports whether the widget has missed its latest refresh
+// slot. Slots start at the window start and repeat every interval until the
+// window end, in the user's local time (the location of now).
+func widgetRefreshDue(widget models.WeatherWidget, now time.Time) bool {
+	slot, ok := latestWidgetSlot(widget, now)
+	return ok && (widget.LastRefreshedAt == nil || widget.LastRefreshedAt.Before(slot))
+}
+
+func latestWidgetSlot(widget models.WeatherWidget, now time.Time) (time.Time, bool) {
+	start, errStart := time.Parse("15:04", widget.WindowStart)
+	end, errEnd := time.Parse("15:04", widget.WindowEnd)
+	if errStart != nil || errEnd != nil || widget.IntervalHours <= 0 {
+		return time.Time{}, false
+	}
+
+	windowStart := time.Date(now.Year(), now.Month(), now.Day(), start.Hour(), start.Minute(), 0, 0, now.Location())
+	windowEnd := time.Date(now.Year(), now.Month(), now.Day(), end.Hour(), end.Minute(), 0, 0, now.Location())
+	if now.Before(windowStart) || now.After(windowEnd) {
+		return time.Time{}, false
+	}
+
+	interval := time.Duration(widget.IntervalHours) * time.Hour
+	elapsed := now.Sub(windowStart) / interval
+	return windowStart.Add(elapsed * interval), true
+}
+
+// render formats the widget text: the weather summary, followed by the short
+// one in the secondary language when bilingual output is on, and the local
+// time of the last update
+func (s *WidgetService) render(ctx context.Context, user *models.User, current *WeatherData, now time.Time) string {
+	language := user.Language
+	if language == "" {
+		language = internal.DefaultLanguage
+	}
+	units := UserUnits(user)
+
+	summary := RenderForUser(user, func(language string) string {
+		return s.localization.T(ctx, language, "widget_weather",
+			markdown.EscapeBold(current.LocationName),
+			current.Icon, current.Description,
+			weather.FormatTemp(current.Temperature, units),
+			weather.FormatPercent(float64(current.Humidity)),
+			weather.FormatSpeed(current.WindSpeed, units),
+			weather.FormatAQI(float64(current.AQI)))
+	}, WeatherSummary(s.localization, current, units))
+	updated := s.localization.T(ctx, language, "widget_last_updated", now.In(userLocation(user)).Format("15:04"))
+
+	return summary + "\n\n" + updated
+}
+
+func (s *WidgetService) markup(ctx context.Context, user *models.User) *gotgbot.InlineKeyboardMarkup {
+	language := user.Language
+	if language == "" {
+		language = internal.DefaultLanguage
+	}
+	return &gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+			{{Text: s.localization.T(ctx, language, "widget_unpin_btn"), CallbackData: WidgetUnpinCallback}},
+		},
+	}
+}
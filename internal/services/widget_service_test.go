@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/locales"
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/tests/helpers"
+)
+
+func TestWidgetRefreshDue(t *testing.T) {
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	require.NoError(t, err)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2025, 6, 10, hour, minute, 0, 0, kyiv)
+	}
+	refreshed := func(hour, minute int) *time.Time {
+		t := at(hour, minute).UTC()
+		return &t
+	}
+	yesterdayEvening := refreshed(21, 5).Add(-24 * time.Hour)
+	widget := func(last *time.Time) models.WeatherWidget {
+		return models.WeatherWidget{IntervalHours: 3, WindowStart: "06:00", WindowEnd: "22:00", LastRefreshedAt: last}
+	}
+
+	tests := []struct {
+		name   string
+		widget models.WeatherWidget
+		now    time.Time
+		want   bool
+	}{
+		{"before the window", widget(&yesterdayEvening), at(5, 59), false},
+		{"first slot of the day", widget(&yesterdayEvening), at(6, 0), true},
+		{"refreshed in the current slot", widget(refreshed(9, 10)), at(11, 45), false},
+		{"next slot reached", widget(refreshed(9, 10)), at(12, 0), true},
+		{"missed slots are caught up once", widget(refreshed(6, 0)), at(13, 30), true},
+		{"after the window", widget(refreshed(21, 0)), at(22, 30), false},
+		{"last slot before the window end", widget(refreshed(18, 0)), at(21, 15), true},
+		{"never refreshed", widget(nil), at(7, 0), true},
+		{"invalid window", models.WeatherWidget{IntervalHours: 3, WindowStart: "6am", WindowEnd: "22:00"}, at(12, 0), false},
+		{"invalid interval", models.WeatherWidget{WindowStart: "06:00", WindowEnd: "22:00"}, at(12, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, widgetRefreshDue(tt.widget, tt.now))
+		})
+	}
+}
+
+func newTestWidgetService(t *testing.T, api gotgbot.BotClient) (*WidgetService, *helpers.MockDB) {
+	logger := helpers.NewSilentTestLogger()
+	localization := NewLocalizationService(logger)
+	require.NoError(t, localization.LoadTranslations(locales.LocalesFS))
+
+	mockDB := helpers.NewMockDB(t)
+	t.Cleanup(func() { _ = mockDB.Close() })
+
+	service := NewWidgetService(mockDB.DB, nil, localization, logger)
+	service.SetBot(&gotgbot.Bot{Token: "test", BotClient: api})
+	return service, mockDB
+}
+
+func testWidget() models.WeatherWidget {
+	return models.WeatherWidget{
+		ChatID:        42,
+		UserID:        42,
+		MessageID:     100,
+		IntervalHours: 3,
+		WindowStart:   "06:00",
+		WindowEnd:     "22:00",
+		User:          models.User{ID: 42, Language: "en-US", Timezone: "UTC", Latitude: 50.45, Longitude: 30.52, LocationName: "Kyiv"},
+	}
+}
+
+func TestWidgetService_Pin_OneWidgetPerChat(t *testing.T) {
+	api := &restrictedBotAPI{}
+	service, mockDB := newTestWidgetService(t, api)
+	widget := testWidget()
+
+	mockDB.Mock.ExpectQuery(`SELECT \* FROM "weather_widgets" WHERE chat_id = \$1`).
+		WithArgs(widget.ChatID, 1).
+		WillReturnRows(mockDB.Mock.NewRows([]string{"chat_id", "user_id", "message_id"}).AddRow(widget.ChatID, widget.UserID, widget.MessageID))
+
+	_, err := service.Pin(context.Background(), widget.ChatID, &widget.User)
+
+	assert.ErrorIs(t, err, ErrWidgetExists)
+	assert.Empty(t, api.requests, "nothing is sent when the chat already has a widget")
+	mockDB.ExpectationsWereMet(t)
+}
+
+func TestWidgetService_Update(t *testing.T) {
+	current := &WeatherData{LocationName: "Kyiv", Temperature: 21.4, Humidity: 60, WindSpeed: 12, AQI: 35, Icon: "☀️", Description: "clear sky"}
+	now := time.Date(2025, 6, 10, 9, 0, 0, 0, time.UTC)
+
+	t.Run("edits the message and records the refresh", func(t *testing.T) {
+		api := &restrictedBotAPI{}
+		service, mockDB := newTestWidgetService(t, api)
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`UPDATE "weather_widgets" SET "last_refreshed_at"=\$1,"updated_at"=\$2 WHERE chat_id = \$3`).
+			WithArgs(now, helpers.AnyTime{}, int64(42)).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+
+		require.NoError(t, service.update(context.Background(), testWidget(), current, now))
+
+		require.Len(t, api.requests, 1)
+		assert.Equal(t, "editMessageText", api.requests[0].method)
+		assert.Contains(t, api.requests[0].text, "21.4°C")
+		assert.Contains(t, api.requests[0].text, "Last updated: 09:00")
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("removes the widget when the message was deleted", func(t *testing.T) {
+		api := &restrictedBotAPI{forbidden: map[int64]*gotgbot.TelegramError{
+			42: {Method: "editMessageText", Code: 400, Description: "Bad Request: message to edit not found"},
+		}}
+		service, mockDB := newTestWidgetService(t, api)
+
+		mockDB.Mock.ExpectBegin()
+		mockDB.Mock.ExpectExec(`DELETE FROM "weather_widgets" WHERE chat_id = \$1`).
+			WithArgs(int64(42)).
+			WillReturnResult(helpers.NewResult(0, 1))
+		mockDB.Mock.ExpectCommit()
+
+		require.NoError(t, service.update(context.Background(), testWidget(), current, now))
+		mockDB.ExpectationsWereMet(t)
+	})
+
+	t.Run("keeps the widget on other failures", func(t *testing.T) {
+		api := &restrictedBotAPI{forbidden: map[int64]*gotgbot.TelegramError{
+			42: {Method: "editMessageText", Code: 429, Description: "Too Many Requests: retry after 5"},
+		}}
+		service, mockDB := newTestWidgetService(t, api)
+
+		assert.Error(t, service.update(context.Background(), testWidget(), current, now))
+		mockDB.ExpectationsWereMet(t)
+	})
+}
+
+func TestWidgetService_RefreshStopsUnpinnedWidget(t *testing.T) {
+	// The fake answers getChat without a pinned message
+	api := &restrictedBotAPI{}
+	service, mockDB := newTestWidgetService(t, api)
+
+	mockDB.Mock.ExpectBegin()
+	mockDB.Mock.ExpectExec(`DELETE FROM "weather_widgets" WHERE chat_id = \$1`).
+		WithArgs(int64(42)).
+		WillReturnResult(helpers.NewResult(0, 1))
+	mockDB.Mock.ExpectCommit()
+
+	// The weather service is nil, so fetching weather would panic
+	require.NoError(t, service.refresh(context.Background(), testWidget(), time.Now()))
+
+	require.Len(t, api.requests, 1)
+	assert.Equal(t, "getChat", api.requests[0].method)
+	mockDB.ExpectationsWereMet(t)
+}
@@ -80,6 +80,21 @@ func (mr *MockWeatherClientInterfaceMockRecorder) GetForecast(ctx, lat, lon, day
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetForecast", reflect.TypeOf((*MockWeatherClientInterface)(nil).GetForecast), ctx, lat, lon, days)
 }
 
+// GetHourlyForecast mocks base method.
+func (m *MockWeatherClientInterface) GetHourlyForecast(ctx context.Context, lat, lon float64, hours int) (*weather.HourlyForecastData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHourlyForecast", ctx, lat, lon, hours)
+	ret0, _ := ret[0].(*weather.HourlyForecastData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHourlyForecast indicates an expected call of GetHourlyForecast.
+func (mr *MockWeatherClientInterfaceMockRecorder) GetHourlyForecast(ctx, lat, lon, hours interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHourlyForecast", reflect.TypeOf((*MockWeatherClientInterface)(nil).GetHourlyForecast), ctx, lat, lon, hours)
+}
+
 // MockGeocodingClientInterface is a mock of GeocodingClientInterface interface.
 type MockGeocodingClientInterface struct {
 	ctrl     *gomock.Controller
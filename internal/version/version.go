@@ -3,6 +3,8 @@ package version
 import (
 	"fmt"
 	"runtime"
+	"strconv"
+	"strings"
 )
 
 // Version information - set at build time via ldflags
@@ -18,6 +20,9 @@ var (
 
 	// GoVersion is the Go version used to build
 	GoVersion = runtime.Version()
+
+	// Platform is the operating system and architecture the binary runs on
+	Platform = runtime.GOOS + "/" + runtime.GOARCH
 )
 
 // Info represents complete version information
@@ -26,6 +31,7 @@ type Info struct {
 	GitCommit string `json:"git_commit"`
 	BuildTime string `json:"build_time"`
 	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
 }
 
 // GetInfo returns version information
@@ -35,13 +41,14 @@ func GetInfo() Info {
 		GitCommit: GitCommit,
 		BuildTime: BuildTime,
 		GoVersion: GoVersion,
+		Platform:  Platform,
 	}
 }
 
 // String returns formatted version information
 func (i Info) String() string {
-	return fmt.Sprintf("ShoPogoda v%s\nCommit: %s\nBuilt: %s\nGo: %s",
-		i.Version, i.GitCommit, i.BuildTime, i.GoVersion)
+	return fmt.Sprintf("ShoPogoda v%s\nCommit: %s\nBuilt: %s\nGo: %s\nPlatform: %s",
+		i.Version, i.GitCommit, i.BuildTime, i.GoVersion, i.Platform)
 }
 
 // Short returns short version string
@@ -52,3 +59,55 @@ func (i Info) Short() string {
 	}
 	return fmt.Sprintf("v%s (%s)", i.Version, commit)
 }
+
+// Newer reports whether version a is later than version b. Versions are
+// compared by their dotted numbers, with or without a leading "v"; a
+// pre-release such as "1.2.0-rc1" comes before its release.
+func Newer(a, b string) bool {
+	return compare(a, b) > 0
+}
+
+func compare(a, b string) int {
+	aCore, aPre := splitVersion(a)
+	bCore, bPre := splitVersion(b)
+	for i := 0; i < max(len(aCore), len(bCore)); i++ {
+		var x, y int
+		if i < len(aCore) {
+			x = aCore[i]
+		}
+		if i < len(bCore) {
+			y = bCore[i]
+		}
+		if x != y {
+			return x - y
+		}
+	}
+
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	default:
+		return strings.Compare(aPre, bPre)
+	}
+}
+
+// splitVersion returns the numbers of a version and its pre-release suffix.
+// Parts that are not numbers count as zero.
+func splitVersion(v string) ([]int, string) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i] // Build metadata does not order versions
+	}
+	core, pre, _ := strings.Cut(v, "-")
+
+	var numbers []int
+	for _, part := range strings.Split(core, ".") {
+		n, _ := strconv.Atoi(part)
+		numbers = append(numbers, n)
+	}
+	return numbers, pre
+}
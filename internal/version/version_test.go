@@ -29,6 +29,10 @@ func TestGetInfo(t *testing.T) {
 	if info.GoVersion != runtime.Version() {
 		t.Errorf("Expected Go version %s, got %s", runtime.Version(), info.GoVersion)
 	}
+
+	if info.Platform != runtime.GOOS+"/"+runtime.GOARCH {
+		t.Errorf("Expected platform %s/%s, got %s", runtime.GOOS, runtime.GOARCH, info.Platform)
+	}
 }
 
 func TestInfoString(t *testing.T) {
@@ -37,6 +41,7 @@ func TestInfoString(t *testing.T) {
 		GitCommit: "abc123",
 		BuildTime: "2025-01-02",
 		GoVersion: "go1.24.6",
+		Platform:  "linux/amd64",
 	}
 
 	result := info.String()
@@ -47,6 +52,7 @@ func TestInfoString(t *testing.T) {
 		"Commit: abc123",
 		"Built: 2025-01-02",
 		"Go: go1.24.6",
+		"Platform: linux/amd64",
 	}
 
 	for _, part := range expectedParts {
@@ -114,3 +120,28 @@ func TestDefaultValues(t *testing.T) {
 		t.Error("GoVersion should not be empty")
 	}
 }
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"0.2.0", "0.1.1", true},
+		{"v0.1.10", "0.1.9", true},
+		{"1.0", "0.9.9", true},
+		{"0.1.1", "0.1.1", false},
+		{"v0.1.1", "0.1.1", false},
+		{"0.1.1", "0.2.0", false},
+		{"1.0.0", "1.0.0-rc1", true},
+		{"1.0.0-rc1", "1.0.0", false},
+		{"1.0.0-rc2", "1.0.0-rc1", true},
+		{"1.0.0+build5", "1.0.0", false},
+		{"1.0.0", "1.0", false},
+	}
+
+	for _, tt := range tests {
+		if got := Newer(tt.a, tt.b); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
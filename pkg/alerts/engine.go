@@ -3,6 +3,8 @@ package alerts
 import (
 	"fmt"
 	"time"
+
+	"github.com/valpere/shopogoda/pkg/weather"
 )
 
 // AlertLevel represents the severity level of an alert
@@ -208,33 +210,32 @@ func (e *Engine) CreateAlert(alertType AlertType, currentValue, threshold float6
 	title := fmt.Sprintf("%s Alert - %s", alertType.String(), level.String())
 
 	var description string
-	var unit string
 
 	switch alertType {
 	case AlertTypeTemperature:
-		unit = "°C"
+		current, limit := weather.FormatTemp(currentValue, weather.UnitsMetric), weather.FormatTemp(threshold, weather.UnitsMetric)
 		if currentValue > threshold {
-			description = fmt.Sprintf("Temperature is %.1f%s, above threshold of %.1f%s", currentValue, unit, threshold, unit)
+			description = fmt.Sprintf("Temperature is %s, above threshold of %s", current, limit)
 		} else {
-			description = fmt.Sprintf("Temperature is %.1f%s, below threshold of %.1f%s", currentValue, unit, threshold, unit)
+			description = fmt.Sprintf("Temperature is %s, below threshold of %s", current, limit)
 		}
 	case AlertTypeHumidity:
-		unit = "%"
+		current, limit := weather.FormatPercent(currentValue), weather.FormatPercent(threshold)
 		if currentValue > threshold {
-			description = fmt.Sprintf("Humidity is %.0f%s, above threshold of %.0f%s", currentValue, unit, threshold, unit)
+			description = fmt.Sprintf("Humidity is %s, above threshold of %s", current, limit)
 		} else {
-			description = fmt.Sprintf("Humidity is %.0f%s, below threshold of %.0f%s", currentValue, unit, threshold, unit)
+			description = fmt.Sprintf("Humidity is %s, below threshold of %s", current, limit)
 		}
 	case AlertTypeAirQuality:
-		description = fmt.Sprintf("Air Quality Index is %.0f, threshold: %.0f", currentValue, threshold)
+		description = fmt.Sprintf("Air Quality Index is %s, threshold: %s", weather.FormatAQI(currentValue), weather.FormatAQI(threshold))
 		if currentValue > 150 {
 			description += " - Unhealthy for sensitive groups"
 		}
 	case AlertTypeWindSpeed:
-		unit = "km/h"
-		description = fmt.Sprintf("Wind speed is %.1f%s, threshold: %.1f%s", currentValue, unit, threshold, unit)
+		description = fmt.Sprintf("Wind speed is %s, threshold: %s",
+			weather.FormatSpeed(currentValue, weather.UnitsMetric), weather.FormatSpeed(threshold, weather.UnitsMetric))
 	case AlertTypeUVIndex:
-		description = fmt.Sprintf("UV Index is %.1f, threshold: %.1f", currentValue, threshold)
+		description = fmt.Sprintf("UV Index is %s, threshold: %s", weather.FormatDecimal(currentValue, 1), weather.FormatDecimal(threshold, 1))
 		if currentValue > 7 {
 			description += " - Very high UV exposure"
 		}
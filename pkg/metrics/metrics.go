@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"net/http"
+	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -12,6 +13,15 @@ type Metrics struct {
 	counters   map[string]*prometheus.CounterVec
 	histograms map[string]*prometheus.HistogramVec
 	gauges     map[string]*prometheus.GaugeVec
+
+	// cacheLookups tallies lookups per cache type to keep cache_hit_rate current
+	cacheMu      sync.Mutex
+	cacheLookups map[string]*cacheTally
+}
+
+// cacheTally counts the lookups of one cache type
+type cacheTally struct {
+	hits, total int
 }
 
 func New() *Metrics {
@@ -19,6 +29,8 @@ func New() *Metrics {
 		counters:   make(map[string]*prometheus.CounterVec),
 		histograms: make(map[string]*prometheus.HistogramVec),
 		gauges:     make(map[string]*prometheus.GaugeVec),
+
+		cacheLookups: make(map[string]*cacheTally),
 	}
 
 	// Initialize common metrics
@@ -46,13 +58,93 @@ func New() *Metrics {
 		[]string{"api", "status"},
 	)
 
+	m.counters["weather_provider_requests_total"] = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "weather_provider_requests_total",
+			Help: "Total number of requests to each weather provider",
+		},
+		[]string{"provider", "api", "status"},
+	)
+
+	m.counters["alert_triggers_total"] = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "alert_triggers_total",
+			Help: "Total number of weather alerts triggered",
+		},
+		[]string{"alert_type", "severity"},
+	)
+
+	m.counters["subscription_dispatches_total"] = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "subscription_dispatches_total",
+			Help: "Total number of scheduled subscription notifications dispatched",
+		},
+		[]string{"type", "status"},
+	)
+
+	m.counters["weather_api_unknown_fields_total"] = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "weather_api_unknown_fields_total",
+			Help: "Weather API responses containing top-level fields outside the known schema",
+		},
+		[]string{"endpoint", "field"},
+	)
+
+	m.counters["cache_lookups_total"] = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_lookups_total",
+			Help: "Total number of cache lookups by result, hit or miss",
+		},
+		[]string{"cache_type", "result"},
+	)
+
+	m.counters["geocode_cache_lookups_total"] = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "geocode_cache_lookups_total",
+			Help: "Total number of geocoding cache lookups by result: hit, negative_hit (a place known not to exist) or miss",
+		},
+		[]string{"result"},
+	)
+
+	m.counters["messages_sent_total"] = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "messages_sent_total",
+			Help: "Total number of templated messages delivered to users",
+		},
+		[]string{"template", "status"},
+	)
+
+	m.counters["telegram_outbound_messages_total"] = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telegram_outbound_messages_total",
+			Help: "Total number of outbound Telegram messages by priority lane",
+		},
+		[]string{"priority", "status"},
+	)
+
+	m.counters["telegram_rate_limited_total"] = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telegram_rate_limited_total",
+			Help: "Total number of 429 responses from the Telegram API",
+		},
+		[]string{"priority"},
+	)
+
+	m.counters["telegram_send_failures_total"] = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telegram_send_failures_total",
+			Help: "Total number of failed Telegram API sends by method and error code, \"network\" when Telegram gave none",
+		},
+		[]string{"method", "error_code"},
+	)
+
 	m.histograms["bot_handler_duration_seconds"] = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "bot_handler_duration_seconds",
-			Help:    "Duration of bot handler execution",
+			Help:    "Duration of bot handler execution by command",
 			Buckets: prometheus.DefBuckets,
 		},
-		[]string{"type"},
+		[]string{"command"},
 	)
 
 	m.histograms["weather_api_duration_seconds"] = prometheus.NewHistogramVec(
@@ -64,6 +156,15 @@ func New() *Metrics {
 		[]string{"api"},
 	)
 
+	m.histograms["bot_user_fetches_per_update"] = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "bot_user_fetches_per_update",
+			Help:    "Number of times the sender of an update was loaded while handling it",
+			Buckets: []float64{0, 1, 2, 3, 5, 10},
+		},
+		[]string{},
+	)
+
 	m.gauges["active_users"] = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "active_users",
@@ -72,6 +173,30 @@ func New() *Metrics {
 		[]string{},
 	)
 
+	m.gauges["active_subscriptions"] = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "active_subscriptions",
+			Help: "Number of active subscriptions by type",
+		},
+		[]string{"type"},
+	)
+
+	m.gauges["telegram_outbound_queue_depth"] = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "telegram_outbound_queue_depth",
+			Help: "Number of outbound Telegram messages waiting for the rate limiter",
+		},
+		[]string{"priority"},
+	)
+
+	m.gauges["delivery_dead_letters"] = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "delivery_dead_letters",
+			Help: "Number of scheduled notifications kept as dead letters after delivery failed",
+		},
+		[]string{"error_class"},
+	)
+
 	m.gauges["cache_hit_rate"] = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "cache_hit_rate",
@@ -126,17 +251,39 @@ func (m *Metrics) SetGauge(name string, value float64, labelValues ...string) {
 	}
 }
 
+// RecordCacheLookup counts a cache lookup and updates the cache's hit rate
+func (m *Metrics) RecordCacheLookup(cacheType string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.IncrementCounter("cache_lookups_total", cacheType, result)
+
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+	tally, ok := m.cacheLookups[cacheType]
+	if !ok {
+		tally = &cacheTally{}
+		m.cacheLookups[cacheType] = tally
+	}
+	tally.total++
+	if hit {
+		tally.hits++
+	}
+	m.SetGauge("cache_hit_rate", 100*float64(tally.hits)/float64(tally.total), cacheType)
+}
+
 func (m *Metrics) Handler() http.Handler {
 	return promhttp.Handler()
 }
 
-// GetCacheHitRate calculates cache hit rate from Prometheus metrics
-// Returns the percentage (0-100) of cache hits vs total cache operations
+// GetCacheHitRate returns the percentage (0-100) of lookups in the cache that
+// were hits, as kept by RecordCacheLookup, or 0 before the first lookup
 func (m *Metrics) GetCacheHitRate(cacheType string) float64 {
 	// Check if the gauge exists
 	gauge, exists := m.gauges["cache_hit_rate"]
 	if !exists {
-		return 85.0 // Default fallback
+		return 0
 	}
 
 	// Create a channel to collect metrics
@@ -168,17 +315,17 @@ func (m *Metrics) GetCacheHitRate(cacheType string) float64 {
 		}
 	}
 
-	// Fallback if metric not found
-	return 85.0
+	// No lookups yet
+	return 0
 }
 
 // GetAverageResponseTime calculates average response time from handler duration histogram
-// Returns the average in milliseconds
+// Returns the average in milliseconds, or 0 before the first handler has run
 func (m *Metrics) GetAverageResponseTime() float64 {
 	// Check if the histogram exists
 	histogram, exists := m.histograms["bot_handler_duration_seconds"]
 	if !exists {
-		return 150.0 // Default fallback
+		return 0
 	}
 
 	// Create a channel to collect metrics
@@ -216,6 +363,6 @@ func (m *Metrics) GetAverageResponseTime() float64 {
 		return avgSeconds * 1000.0
 	}
 
-	// Fallback if no data
-	return 150.0
+	// No handler has run yet
+	return 0
 }
@@ -1,10 +1,13 @@
 package metrics
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNew(t *testing.T) {
@@ -22,12 +25,18 @@ func TestNew(t *testing.T) {
 	assert.Contains(t, m.counters, "bot_updates_total")
 	assert.Contains(t, m.counters, "bot_errors_total")
 	assert.Contains(t, m.counters, "weather_requests_total")
+	assert.Contains(t, m.counters, "alert_triggers_total")
+	assert.Contains(t, m.counters, "subscription_dispatches_total")
+	assert.Contains(t, m.counters, "cache_lookups_total")
+	assert.Contains(t, m.counters, "geocode_cache_lookups_total")
+	assert.Contains(t, m.counters, "telegram_send_failures_total")
 
 	assert.Contains(t, m.histograms, "bot_handler_duration_seconds")
 	assert.Contains(t, m.histograms, "weather_api_duration_seconds")
 
 	assert.Contains(t, m.gauges, "active_users")
 	assert.Contains(t, m.gauges, "cache_hit_rate")
+	assert.Contains(t, m.gauges, "active_subscriptions")
 }
 
 func TestMetrics_IncrementCounter(t *testing.T) {
@@ -127,9 +136,9 @@ func TestMetrics_GetCacheHitRate(t *testing.T) {
 	prometheus.DefaultRegisterer = prometheus.NewRegistry()
 	m := New()
 
-	t.Run("returns default when no data", func(t *testing.T) {
+	t.Run("returns zero before any lookup", func(t *testing.T) {
 		rate := m.GetCacheHitRate("weather")
-		assert.Equal(t, 85.0, rate)
+		assert.Equal(t, 0.0, rate)
 	})
 
 	t.Run("returns actual value after setting gauge", func(t *testing.T) {
@@ -153,13 +162,27 @@ func TestMetrics_GetCacheHitRate(t *testing.T) {
 	})
 }
 
+func TestMetrics_RecordCacheLookup(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	m := New()
+
+	m.RecordCacheLookup("weather", true)
+	m.RecordCacheLookup("weather", true)
+	m.RecordCacheLookup("weather", true)
+	m.RecordCacheLookup("weather", false)
+	m.RecordCacheLookup("geocode", false)
+
+	assert.Equal(t, 75.0, m.GetCacheHitRate("weather"))
+	assert.Equal(t, 0.0, m.GetCacheHitRate("geocode"))
+}
+
 func TestMetrics_GetAverageResponseTime(t *testing.T) {
 	prometheus.DefaultRegisterer = prometheus.NewRegistry()
 	m := New()
 
-	t.Run("returns default when no observations", func(t *testing.T) {
+	t.Run("returns zero when no observations", func(t *testing.T) {
 		avgTime := m.GetAverageResponseTime()
-		assert.Equal(t, 150.0, avgTime)
+		assert.Equal(t, 0.0, avgTime)
 	})
 
 	t.Run("calculates average from histogram observations", func(t *testing.T) {
@@ -203,3 +226,65 @@ func TestMetrics_GetAverageResponseTime(t *testing.T) {
 		assert.InDelta(t, 200.0, avg2, 1.0) // (100 + 300) / 2 = 200
 	})
 }
+
+// scrape gathers a registry as the metrics endpoint would, keyed by name and
+// labels like name{k="v"}. Histograms give their sample count.
+func scrape(t *testing.T, registry *prometheus.Registry) map[string]float64 {
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	series := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := make([]string, 0, len(metric.GetLabel()))
+			for _, label := range metric.GetLabel() {
+				labels = append(labels, label.GetName()+`="`+label.GetValue()+`"`)
+			}
+			key := family.GetName() + "{" + strings.Join(labels, ",") + "}"
+
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				series[key] = metric.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				series[key] = metric.GetGauge().GetValue()
+			case dto.MetricType_HISTOGRAM:
+				series[key] = float64(metric.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return series
+}
+
+func TestMetrics_Scrape(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	prometheus.DefaultRegisterer = registry
+	m := New()
+
+	// Simulated traffic
+	m.IncrementCounter("bot_updates_total", "message")
+	m.ObserveHistogram("bot_handler_duration_seconds", 0.12, "weather")
+	m.ObserveHistogram("bot_handler_duration_seconds", 0.08, "weather")
+	m.ObserveHistogram("bot_handler_duration_seconds", 0.3, "forecast")
+	m.IncrementCounter("telegram_send_failures_total", "sendMessage", "403")
+	m.IncrementCounter("telegram_send_failures_total", "sendMessage", "network")
+	m.IncrementCounter("weather_requests_total", "current", "success")
+	m.IncrementCounter("weather_provider_requests_total", "openweathermap", "current", "error")
+	m.ObserveHistogram("weather_api_duration_seconds", 0.4, "current")
+	m.SetGauge("active_subscriptions", 7, "daily")
+	m.SetGauge("active_subscriptions", 0, "alerts")
+
+	series := scrape(t, registry)
+	assert.Equal(t, 1.0, series[`bot_updates_total{type="message"}`])
+	assert.Equal(t, 2.0, series[`bot_handler_duration_seconds{command="weather"}`])
+	assert.Equal(t, 1.0, series[`bot_handler_duration_seconds{command="forecast"}`])
+	assert.Equal(t, 1.0, series[`telegram_send_failures_total{error_code="403",method="sendMessage"}`])
+	assert.Equal(t, 1.0, series[`telegram_send_failures_total{error_code="network",method="sendMessage"}`])
+	assert.Equal(t, 1.0, series[`weather_requests_total{api="current",status="success"}`])
+	assert.Equal(t, 1.0, series[`weather_provider_requests_total{api="current",provider="openweathermap",status="error"}`])
+	assert.Equal(t, 1.0, series[`weather_api_duration_seconds{api="current"}`])
+	assert.Equal(t, 7.0, series[`active_subscriptions{type="daily"}`])
+	assert.Contains(t, series, `active_subscriptions{type="alerts"}`)
+
+	// The /stats average response time comes from the same histogram
+	assert.InDelta(t, 166.7, m.GetAverageResponseTime(), 0.1)
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// serverShutdownTimeout bounds how long a scrape in flight may hold up shutdown
+const serverShutdownTimeout = 5 * time.Second
+
+var (
+	serverMu sync.Mutex
+	server   *http.Server
+)
+
+// StartMetricsServer serves the registered metrics for Prometheus to scrape
+// at /metrics on addr, e.g. ":9090". It blocks until the server fails or
+// ShutdownMetricsServer stops it, in which case it returns nil.
+func StartMetricsServer(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serverMu.Lock()
+	if server != nil {
+		serverMu.Unlock()
+		return errors.New("metrics server already running")
+	}
+	server = srv
+	serverMu.Unlock()
+
+	err := srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	serverMu.Lock()
+	if server == srv {
+		server = nil
+	}
+	serverMu.Unlock()
+	return err
+}
+
+// ShutdownMetricsServer stops the server started by StartMetricsServer,
+// letting scrapes in flight finish. It does nothing when none is running.
+func ShutdownMetricsServer(ctx context.Context) error {
+	serverMu.Lock()
+	srv := server
+	server = nil
+	serverMu.Unlock()
+
+	if srv == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, serverShutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
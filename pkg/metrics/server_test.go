@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartMetricsServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	done := make(chan error, 1)
+	go func() { done <- StartMetricsServer(addr) }()
+
+	var resp *http.Response
+	require.Eventually(t, func() bool {
+		resp, err = http.Get("http://" + addr + "/metrics")
+		return err == nil
+	}, 2*time.Second, 20*time.Millisecond)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, resp.Body.Close())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "go_goroutines")
+
+	assert.Error(t, StartMetricsServer(addr), "a second server is refused")
+
+	require.NoError(t, ShutdownMetricsServer(context.Background()))
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("metrics server did not stop")
+	}
+
+	// Nothing left to stop
+	assert.NoError(t, ShutdownMetricsServer(context.Background()))
+}
@@ -0,0 +1,106 @@
+package weather
+
+import "time"
+
+// AirQualityForecastHours is how far ahead the air pollution forecast reaches
+const AirQualityForecastHours = 72
+
+// Parts of the day the air quality forecast is summed up in, by local hour:
+// night until 06:00, morning until 12:00, afternoon until 18:00 and evening
+// until midnight
+const (
+	DayPartNight     = "night"
+	DayPartMorning   = "morning"
+	DayPartAfternoon = "afternoon"
+	DayPartEvening   = "evening"
+)
+
+// dayPartHours is how many hours each part of the day lasts
+const dayPartHours = 6
+
+// dayParts are the parts of the day in order, each dayPartHours long
+var dayParts = [24 / dayPartHours]string{DayPartNight, DayPartMorning, DayPartAfternoon, DayPartEvening}
+
+// AirQualityHour is the forecast air quality of an hour, with the index on
+// the US EPA scale and the concentrations in µg/m³
+type AirQualityHour struct {
+	Time time.Time `json:"time"` // UTC start of the hour
+	AQI  int       `json:"aqi"`
+	CO   float64   `json:"co"`
+	NO2  float64   `json:"no2"`
+	O3   float64   `json:"o3"`
+	PM25 float64   `json:"pm25"`
+	PM10 float64   `json:"pm10"`
+}
+
+// Pollutant names the pollutant that sets the index of the hour
+func (h AirQualityHour) Pollutant() string {
+	return DominantPollutant(h.PM25, h.PM10, h.O3, h.NO2, h.CO)
+}
+
+// AirQualityForecast is the air quality of a place hour by hour, from the
+// current hour on
+type AirQualityForecast struct {
+	Hours []AirQualityHour `json:"hours"`
+}
+
+// Next returns the forecast hours that are not over at from, at most hours
+// of them
+func (f *AirQualityForecast) Next(from time.Time, hours int) []AirQualityHour {
+	if f == nil {
+		return nil
+	}
+	var next []AirQualityHour
+	for _, hour := range f.Hours {
+		if len(next) == hours {
+			break
+		}
+		if hour.Time.Add(time.Hour).After(from) {
+			next = append(next, hour)
+		}
+	}
+	return next
+}
+
+// AirQualityPeriod is a part of a day in the air quality forecast, rated by
+// its worst hour
+type AirQualityPeriod struct {
+	Part  string         // One of the DayPart names
+	Start time.Time      // Local start of the part of the day
+	Worst AirQualityHour // The hour of the highest index
+}
+
+// AirQualityDayParts groups consecutive hours into the parts of the day they
+// fall in, read in zone, and keeps the worst hour of each
+func AirQualityDayParts(hours []AirQualityHour, zone *time.Location) []AirQualityPeriod {
+	var periods []AirQualityPeriod
+	for _, hour := range hours {
+		local := hour.Time.In(zone)
+		slot := local.Hour() / dayPartHours
+		start := time.Date(local.Year(), local.Month(), local.Day(), slot*dayPartHours, 0, 0, 0, zone)
+
+		if n := len(periods); n > 0 && periods[n-1].Start.Equal(start) {
+			if hour.AQI > periods[n-1].Worst.AQI {
+				periods[n-1].Worst = hour
+			}
+			continue
+		}
+		periods = append(periods, AirQualityPeriod{Part: dayParts[slot], Start: start, Worst: hour})
+	}
+	return periods
+}
+
+// WorstAirQualityHour returns the hour of the highest index, the earliest of
+// equally bad ones, and false when there are no hours
+func WorstAirQualityHour(hours []AirQualityHour) (AirQualityHour, bool) {
+	if len(hours) == 0 {
+		return AirQualityHour{}, false
+	}
+	worst := hours[0]
+	for _, hour := range hours[1:] {
+		if hour.AQI > worst.AQI {
+			worst = hour
+		}
+	}
+	return worst, true
+}
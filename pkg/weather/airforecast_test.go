@@ -0,0 +1,69 @@
+package weather
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/pkg/weather/weathertest"
+)
+
+func TestAirQualityForecast_Next(t *testing.T) {
+	forecast, err := decodeAirQualityForecast(weathertest.MustFixture(weathertest.AirForecast), AirQualityForecastHours, nil)
+	require.NoError(t, err)
+
+	// The hour under way is still ahead
+	next := forecast.Next(pollenFixtureNow, 24)
+	require.Len(t, next, 24)
+	assert.Equal(t, time.Date(2026, 4, 16, 9, 0, 0, 0, time.UTC), next[0].Time)
+
+	assert.Len(t, forecast.Next(time.Date(2026, 4, 17, 9, 0, 0, 0, time.UTC), 24), 3)
+	assert.Empty(t, forecast.Next(time.Date(2026, 4, 18, 0, 0, 0, 0, time.UTC), 24))
+
+	var missing *AirQualityForecast
+	assert.Nil(t, missing.Next(pollenFixtureNow, 24))
+}
+
+func TestAirQualityDayParts(t *testing.T) {
+	forecast, err := decodeAirQualityForecast(weathertest.MustFixture(weathertest.AirForecast), AirQualityForecastHours, nil)
+	require.NoError(t, err)
+	kyiv := time.FixedZone("EEST", 3*60*60)
+
+	periods := AirQualityDayParts(forecast.Next(pollenFixtureNow, 24), kyiv)
+
+	// 12:00 local on the 16th to 11:00 on the 17th
+	parts := make([]string, len(periods))
+	for i, period := range periods {
+		parts[i] = period.Part
+	}
+	assert.Equal(t, []string{DayPartAfternoon, DayPartEvening, DayPartNight, DayPartMorning}, parts)
+
+	afternoon := periods[0]
+	assert.Equal(t, time.Date(2026, 4, 16, 12, 0, 0, 0, kyiv), afternoon.Start)
+	assert.Equal(t, 119, afternoon.Worst.AQI)
+	assert.Equal(t, time.Date(2026, 4, 16, 11, 0, 0, 0, time.UTC), afternoon.Worst.Time)
+	assert.Equal(t, PollutantO3, afternoon.Worst.Pollutant())
+
+	night := periods[2]
+	assert.Equal(t, time.Date(2026, 4, 17, 0, 0, 0, 0, kyiv), night.Start)
+	assert.Equal(t, PollutantPM25, night.Worst.Pollutant())
+
+	assert.Empty(t, AirQualityDayParts(nil, kyiv))
+}
+
+func TestWorstAirQualityHour(t *testing.T) {
+	hours := []AirQualityHour{
+		{Time: time.Date(2026, 4, 16, 9, 0, 0, 0, time.UTC), AQI: 40},
+		{Time: time.Date(2026, 4, 16, 10, 0, 0, 0, time.UTC), AQI: 112},
+		{Time: time.Date(2026, 4, 16, 11, 0, 0, 0, time.UTC), AQI: 112},
+	}
+
+	worst, ok := WorstAirQualityHour(hours)
+	assert.True(t, ok)
+	assert.Equal(t, hours[1], worst)
+
+	_, ok = WorstAirQualityHour(nil)
+	assert.False(t, ok)
+}
@@ -0,0 +1,47 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+)
+
+type apiKeyKey struct{}
+
+// WithAPIKey asks OpenWeather requests made with ctx to use a user's own API
+// key, with its own rate limit, instead of the client's. An empty key keeps
+// the client's.
+func WithAPIKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, apiKeyKey{}, key)
+}
+
+// APIKeyFrom returns the API key requested with ctx, or "" for none
+func APIKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(apiKeyKey{}).(string)
+	return key
+}
+
+// requestKey returns the API key requested with ctx, falling back to the
+// client's own
+func requestKey(ctx context.Context, key string) string {
+	if override := APIKeyFrom(ctx); override != "" {
+		return override
+	}
+	return key
+}
+
+// apiKeyCheckLatitude and apiKeyCheckLongitude are where ValidateAPIKey asks
+// for the weather; any place works
+const (
+	apiKeyCheckLatitude  = 51.5074
+	apiKeyCheckLongitude = -0.1278
+)
+
+// ValidateAPIKey checks that OpenWeather accepts key by asking it for the
+// current weather with it. A rejected key fails with ErrUnauthorized.
+func (c *Client) ValidateAPIKey(ctx context.Context, key string) error {
+	if key == "" {
+		return fmt.Errorf("%w: empty API key", ErrUnauthorized)
+	}
+	_, err := c.GetCurrentWeather(WithAPIKey(ctx, key), apiKeyCheckLatitude, apiKeyCheckLongitude)
+	return err
+}
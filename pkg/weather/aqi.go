@@ -0,0 +1,116 @@
+package weather
+
+import "math"
+
+// Factors converting µg/m³ to ppb (ppm for CO) at 25 °C and 1 atm: the
+// molar volume, 24.45 l, over the molar mass of the gas
+const (
+	o3MicrogramsPerPPB  = 48.00 / 24.45
+	no2MicrogramsPerPPB = 46.01 / 24.45
+	coMicrogramsPerPPM  = 28.01 / 24.45 * 1000
+)
+
+// aqiBreakpoint maps a concentration range onto a range of the index
+type aqiBreakpoint struct {
+	low, high           float64
+	indexLow, indexHigh int
+}
+
+// US EPA breakpoints of each pollutant, in the units and precision the
+// concentrations are truncated to: PM2.5 in µg/m³ to one decimal (the 2024
+// revision), PM10 in whole µg/m³, O3 and NO2 in whole ppb and CO in ppm to one
+// decimal. The 8-hour ozone table ends at 200 ppb; above it the 1-hour one
+// applies.
+var (
+	pm25Breakpoints = []aqiBreakpoint{
+		{0, 9.0, 0, 50}, {9.1, 35.4, 51, 100}, {35.5, 55.4, 101, 150},
+		{55.5, 125.4, 151, 200}, {125.5, 225.4, 201, 300}, {225.5, 325.4, 301, 500},
+	}
+	pm10Breakpoints = []aqiBreakpoint{
+		{0, 54, 0, 50}, {55, 154, 51, 100}, {155, 254, 101, 150},
+		{255, 354, 151, 200}, {355, 424, 201, 300}, {425, 604, 301, 500},
+	}
+	o3Breakpoints = []aqiBreakpoint{
+		{0, 54, 0, 50}, {55, 70, 51, 100}, {71, 85, 101, 150},
+		{86, 105, 151, 200}, {106, 404, 201, 300}, {405, 604, 301, 500},
+	}
+	no2Breakpoints = []aqiBreakpoint{
+		{0, 53, 0, 50}, {54, 100, 51, 100}, {101, 360, 101, 150},
+		{361, 649, 151, 200}, {650, 1249, 201, 300}, {1250, 2049, 301, 500},
+	}
+	coBreakpoints = []aqiBreakpoint{
+		{0, 4.4, 0, 50}, {4.5, 9.4, 51, 100}, {9.5, 12.4, 101, 150},
+		{12.5, 15.4, 151, 200}, {15.5, 30.4, 201, 300}, {30.5, 50.4, 301, 500},
+	}
+)
+
+// MaxAQI is the top of the US EPA air quality index scale
+const MaxAQI = 500
+
+// USAQI computes the US EPA air quality index, 0 to 500, from pollutant
+// concentrations in µg/m³: the highest of the sub-indices of PM2.5, PM10,
+// ozone, NO2 and CO. Providers report the index on scales of their own, so
+// every AirQualityData carries this one instead.
+func USAQI(pm25, pm10, o3, no2, co float64) int {
+	aqi := 0
+	for _, index := range subIndices(pm25, pm10, o3, no2, co) {
+		aqi = max(aqi, index.value)
+	}
+	return aqi
+}
+
+// Pollutants the air quality index is computed from, as named by
+// DominantPollutant
+const (
+	PollutantPM25 = "pm25"
+	PollutantPM10 = "pm10"
+	PollutantO3   = "o3"
+	PollutantNO2  = "no2"
+	PollutantCO   = "co"
+)
+
+// DominantPollutant names the pollutant whose sub-index sets the US AQI of
+// the concentrations, in µg/m³. Ties go to the particles, which are named
+// first; clean air is put down to PM2.5.
+func DominantPollutant(pm25, pm10, o3, no2, co float64) string {
+	indices := subIndices(pm25, pm10, o3, no2, co)
+	dominant := indices[0]
+	for _, index := range indices[1:] {
+		if index.value > dominant.value {
+			dominant = index
+		}
+	}
+	return dominant.pollutant
+}
+
+// pollutantIndex is the sub-index of one pollutant
+type pollutantIndex struct {
+	pollutant string
+	value     int
+}
+
+// subIndices rates each pollutant on the US EPA scale, particles first
+func subIndices(pm25, pm10, o3, no2, co float64) [5]pollutantIndex {
+	return [5]pollutantIndex{
+		{PollutantPM25, subIndex(pm25Breakpoints, math.Floor(pm25*10)/10)},
+		{PollutantPM10, subIndex(pm10Breakpoints, math.Floor(pm10))},
+		{PollutantO3, subIndex(o3Breakpoints, math.Floor(o3/o3MicrogramsPerPPB))},
+		{PollutantNO2, subIndex(no2Breakpoints, math.Floor(no2/no2MicrogramsPerPPB))},
+		{PollutantCO, subIndex(coBreakpoints, math.Floor(co/coMicrogramsPerPPM*10)/10)},
+	}
+}
+
+// subIndex interpolates a truncated concentration linearly within its
+// breakpoint range. Concentrations beyond the table are off the scale.
+func subIndex(breakpoints []aqiBreakpoint, concentration float64) int {
+	if concentration <= 0 {
+		return 0
+	}
+	for _, bp := range breakpoints {
+		if concentration <= bp.high {
+			share := (concentration - bp.low) / (bp.high - bp.low)
+			return int(math.Round(float64(bp.indexLow) + share*float64(bp.indexHigh-bp.indexLow)))
+		}
+	}
+	return MaxAQI
+}
@@ -0,0 +1,53 @@
+package weather
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUSAQI(t *testing.T) {
+	tests := []struct {
+		name                    string
+		pm25, pm10, o3, no2, co float64
+		want                    int
+	}{
+		{name: "clean air", want: 0},
+		{name: "top of good PM2.5", pm25: 9.0, want: 50},
+		{name: "top of moderate PM2.5", pm25: 35.4, want: 100},
+		{name: "bottom of unhealthy PM2.5", pm25: 55.5, want: 151},
+		{name: "top of moderate PM10", pm10: 154, want: 100},
+		{name: "ozone converted to ppb", o3: 138, want: 100},
+		{name: "NO2 converted to ppb", no2: 189, want: 100},
+		{name: "CO converted to ppm", co: 10770, want: 100},
+		{name: "worst pollutant sets the index", pm25: 12, pm10: 100, o3: 40, want: 73},
+		{name: "off the scale", pm25: 400, want: MaxAQI},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, USAQI(tt.pm25, tt.pm10, tt.o3, tt.no2, tt.co))
+		})
+	}
+}
+
+func TestDominantPollutant(t *testing.T) {
+	tests := []struct {
+		name                    string
+		pm25, pm10, o3, no2, co float64
+		want                    string
+	}{
+		{name: "clean air", want: PollutantPM25},
+		{name: "fine particles", pm25: 30, pm10: 40, o3: 60, want: PollutantPM25},
+		{name: "summer ozone", pm25: 5, o3: 150, want: PollutantO3},
+		{name: "traffic NO2", pm25: 4, no2: 189, want: PollutantNO2},
+		{name: "dust storm", pm25: 20, pm10: 300, want: PollutantPM10},
+		{name: "tie goes to particles", pm25: 9.0, pm10: 54, want: PollutantPM25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DominantPollutant(tt.pm25, tt.pm10, tt.o3, tt.no2, tt.co))
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package weather
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// synodicMonth is the mean time from one new moon to the next, in days
+	synodicMonth = 29.530588853
+
+	// julianUnixEpoch is the Julian date of the Unix epoch
+	julianUnixEpoch = 2440587.5
+	// julian2000 is the Julian date of J2000.0, 2000-01-01 12:00 UTC
+	julian2000 = 2451545.0
+
+	// sunriseAltitude is the altitude of the sun's centre, in degrees, at
+	// sunrise and sunset: its radius plus atmospheric refraction
+	sunriseAltitude = -0.833
+	// astronomicalTwilightAltitude is the altitude of the sun at the start of
+	// astronomical twilight in the morning and its end in the evening; below
+	// it the sky is fully dark
+	astronomicalTwilightAltitude = -18
+	// earthObliquity is the tilt of the Earth's axis, in degrees
+	earthObliquity = 23.4397
+)
+
+// knownNewMoon is a new moon that phases are counted from
+var knownNewMoon = time.Date(2000, 1, 6, 18, 14, 0, 0, time.UTC)
+
+// moonPhaseEmojis are the eight phases from new moon to waning crescent
+var moonPhaseEmojis = [8]string{"🌑", "🌒", "🌓", "🌔", "🌕", "🌖", "🌗", "🌘"}
+
+// MoonPhase returns how far the moon is through its cycle at t, from 0 at
+// new moon over 0.5 at full moon to just under 1
+func MoonPhase(t time.Time) float64 {
+	days := t.Sub(knownNewMoon).Hours() / 24
+	phase := math.Mod(days/synodicMonth, 1)
+	if phase < 0 {
+		phase++
+	}
+	return phase
+}
+
+// MoonPhaseOctant returns which of the eight named phases, 0 for new moon to
+// 7 for waning crescent, a phase from MoonPhase falls in
+func MoonPhaseOctant(phase float64) int {
+	return int(math.Round(phase*8)) % 8
+}
+
+// MoonPhaseEmoji returns the emoji of a phase from MoonPhase
+func MoonPhaseEmoji(phase float64) string {
+	return moonPhaseEmojis[MoonPhaseOctant(phase)]
+}
+
+// SunTimes returns the sunrise and sunset, in UTC, at the coordinates on the
+// calendar day of day. Both are zero on days the sun does not rise or set,
+// such as during the polar day or night. Times are accurate to a minute or two.
+func SunTimes(day time.Time, lat, lon float64) (sunrise, sunset time.Time) {
+	return sunCrossings(day, lat, lon, sunriseAltitude)
+}
+
+// AstronomicalTwilight returns when the sky stops being fully dark in the
+// morning and starts being so in the evening, in UTC, at the coordinates on
+// the calendar day of day. Both are zero on days the sun does not sink 18°
+// below the horizon, such as summer nights far from the equator.
+func AstronomicalTwilight(day time.Time, lat, lon float64) (dawn, dusk time.Time) {
+	return sunCrossings(day, lat, lon, astronomicalTwilightAltitude)
+}
+
+// sunCrossings returns when the sun's centre rises above and sinks below an
+// altitude in degrees at the coordinates on the calendar day of day, or
+// zero times when it stays above or below it all day
+func sunCrossings(day time.Time, lat, lon, altitude float64) (rising, setting time.Time) {
+	transit, eclipticLongitude := solarTransit(day, lon)
+
+	sinDeclination := math.Sin(eclipticLongitude) * math.Sin(earthObliquity*math.Pi/180)
+	cosDeclination := math.Cos(math.Asin(sinDeclination))
+	latRad := lat * math.Pi / 180
+	cosHourAngle := (math.Sin(altitude*math.Pi/180) - math.Sin(latRad)*sinDeclination) /
+		(math.Cos(latRad) * cosDeclination)
+	if cosHourAngle < -1 || cosHourAngle > 1 {
+		return time.Time{}, time.Time{}
+	}
+
+	hourAngle := math.Acos(cosHourAngle) * 180 / math.Pi
+	return fromJulianDate(transit - hourAngle/360), fromJulianDate(transit + hourAngle/360)
+}
+
+// AstroData is the sky of a day at a place, for astronomy: the moon and how
+// long the night is fully dark
+type AstroData struct {
+	Date             time.Time `json:"date"`              // Midnight starting the day, in the zone it was asked for
+	MoonPhase        string    `json:"moon_phase"`        // One of the Moon phase names
+	MoonIllumination float64   `json:"moon_illumination"` // Lit fraction of the moon at noon, 0 to 1
+	MoonWaxing       bool      `json:"moon_waxing"`       // Whether the lit part is growing
+	Moonrise         time.Time `json:"moonrise"`          // UTC; zero when the moon does not rise that day
+	Moonset          time.Time `json:"moonset"`           // UTC; zero when the moon does not set that day
+	AstroTwilight    time.Time `json:"astro_twilight"`    // UTC end of astronomical twilight in the evening; zero when the sky does not get fully dark
+	AstroDawn        time.Time `json:"astro_dawn"`        // UTC start of astronomical twilight the next morning, when the dark night ends
+}
+
+// Emoji returns the emoji of the day's moon
+func (a *AstroData) Emoji() string {
+	return MoonEmoji(a.MoonIllumination, a.MoonWaxing)
+}
+
+// Astro works out the sky at the coordinates on the calendar day of date, in
+// date's location
+func Astro(date time.Time, lat, lon float64) *AstroData {
+	midnight := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	phase := MoonPhase(midnight.Add(12 * time.Hour))
+	illumination := MoonIllumination(phase)
+	waxing := phase < 0.5
+
+	data := &AstroData{
+		Date:             midnight,
+		MoonPhase:        MoonPhaseName(illumination, waxing),
+		MoonIllumination: illumination,
+		MoonWaxing:       waxing,
+	}
+	data.Moonrise, data.Moonset = MoonTimes(midnight, lat, lon)
+	_, data.AstroTwilight = AstronomicalTwilight(midnight, lat, lon)
+	if !data.AstroTwilight.IsZero() {
+		data.AstroDawn, _ = AstronomicalTwilight(midnight.AddDate(0, 0, 1), lat, lon)
+	}
+	return data
+}
+
+// SolarNoon returns when the sun is highest, in UTC, at the longitude on the
+// calendar day of day. Unlike sunrise it exists during the polar day and night.
+func SolarNoon(day time.Time, lon float64) time.Time {
+	transit, _ := solarTransit(day, lon)
+	return fromJulianDate(transit)
+}
+
+// solarTransit returns the Julian date the sun crosses the meridian at the
+// longitude on the calendar day of day, and the sun's ecliptic longitude then
+// in radians
+func solarTransit(day time.Time, lon float64) (transit, eclipticLongitude float64) {
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	// Days since J2000.0 to the day's noon, shifted to local solar noon
+	n := julianDate(midnight) + 0.5 - julian2000
+	meanSolarNoon := n - lon/360
+
+	anomaly := math.Mod(357.5291+0.98560028*meanSolarNoon, 360)
+	anomalyRad := anomaly * math.Pi / 180
+	center := 1.9148*math.Sin(anomalyRad) + 0.0200*math.Sin(2*anomalyRad) + 0.0003*math.Sin(3*anomalyRad)
+	eclipticLongitude = math.Mod(anomaly+center+180+102.9372, 360) * math.Pi / 180
+	transit = julian2000 + meanSolarNoon + 0.0053*math.Sin(anomalyRad) - 0.0069*math.Sin(2*eclipticLongitude)
+	return transit, eclipticLongitude
+}
+
+// SunElevation returns the altitude of the sun's centre above the horizon, in
+// degrees, at the coordinates at t; negative when the sun is down. Refraction
+// is ignored, which only matters right at sunrise and sunset.
+func SunElevation(t time.Time, lat, lon float64) float64 {
+	altitude, _ := SunPosition(t, lat, lon)
+	return altitude
+}
+
+// SunPosition returns where the sun is seen from the coordinates at t: its
+// altitude above the horizon as SunElevation does, and its azimuth in degrees
+// clockwise from north
+func SunPosition(t time.Time, lat, lon float64) (altitude, azimuth float64) {
+	d := julianDate(t) - julian2000
+
+	anomaly := math.Mod(357.5291+0.98560028*d, 360) * math.Pi / 180
+	center := 1.9148*math.Sin(anomaly) + 0.0200*math.Sin(2*anomaly) + 0.0003*math.Sin(3*anomaly)
+	eclipticLongitude := (anomaly*180/math.Pi + center + 180 + 102.9372) * math.Pi / 180
+
+	obliquity := earthObliquity * math.Pi / 180
+	declination := math.Asin(math.Sin(eclipticLongitude) * math.Sin(obliquity))
+	rightAscension := math.Atan2(math.Sin(eclipticLongitude)*math.Cos(obliquity), math.Cos(eclipticLongitude))
+
+	siderealTime := (280.16 + 360.9856235*d + lon) * math.Pi / 180
+	hourAngle := siderealTime - rightAscension
+
+	latRad := lat * math.Pi / 180
+	altitudeRad := math.Asin(math.Sin(latRad)*math.Sin(declination) + math.Cos(latRad)*math.Cos(declination)*math.Cos(hourAngle))
+	// Measured from south, as the hour angle is, then turned to north
+	fromSouth := math.Atan2(math.Sin(hourAngle), math.Cos(hourAngle)*math.Sin(latRad)-math.Tan(declination)*math.Cos(latRad))
+	azimuth = math.Mod(fromSouth*180/math.Pi+180+360, 360)
+	return altitudeRad * 180 / math.Pi, azimuth
+}
+
+// CompassPoint names the eight-point compass direction of a bearing in
+// degrees clockwise from north
+func CompassPoint(bearing float64) string {
+	points := [8]string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}
+	return points[int(math.Round(math.Mod(bearing+360, 360)/45))%len(points)]
+}
+
+// julianDate converts a time to a Julian date
+func julianDate(t time.Time) float64 {
+	return float64(t.Unix())/86400 + julianUnixEpoch
+}
+
+// fromJulianDate converts a Julian date to a UTC time, to the second
+func fromJulianDate(jd float64) time.Time {
+	return time.Unix(int64(math.Round((jd-julianUnixEpoch)*86400)), 0).UTC()
+}
@@ -0,0 +1,146 @@
+package weather
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSunTimes(t *testing.T) {
+	tests := []struct {
+		name     string
+		day      time.Time
+		lat, lon float64
+		sunrise  time.Time
+		sunset   time.Time
+	}{
+		{
+			name: "London midsummer",
+			day:  time.Date(2024, 6, 21, 15, 0, 0, 0, time.UTC),
+			lat:  51.5074, lon: -0.1278,
+			sunrise: time.Date(2024, 6, 21, 3, 43, 0, 0, time.UTC),
+			sunset:  time.Date(2024, 6, 21, 20, 21, 0, 0, time.UTC),
+		},
+		{
+			name: "Kyiv midwinter",
+			day:  time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC),
+			lat:  50.4501, lon: 30.5234,
+			sunrise: time.Date(2024, 12, 21, 5, 56, 0, 0, time.UTC),
+			sunset:  time.Date(2024, 12, 21, 13, 56, 0, 0, time.UTC),
+		},
+		{
+			// Local morning is still the UTC day before
+			name: "Sydney",
+			day:  time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+			lat:  -33.8688, lon: 151.2093,
+			sunrise: time.Date(2024, 1, 14, 18, 59, 0, 0, time.UTC),
+			sunset:  time.Date(2024, 1, 15, 9, 9, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sunrise, sunset := SunTimes(tt.day, tt.lat, tt.lon)
+			assert.WithinDuration(t, tt.sunrise, sunrise, 2*time.Minute)
+			assert.WithinDuration(t, tt.sunset, sunset, 2*time.Minute)
+		})
+	}
+
+	t.Run("polar day and night", func(t *testing.T) {
+		for _, day := range []time.Time{
+			time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC),
+		} {
+			sunrise, sunset := SunTimes(day, 69.6492, 18.9553) // Tromsø
+			assert.True(t, sunrise.IsZero())
+			assert.True(t, sunset.IsZero())
+		}
+	})
+}
+
+func TestSolarNoon(t *testing.T) {
+	// London's solar noon is a minute or two after 12:00 UTC at midsummer
+	assert.WithinDuration(t, time.Date(2024, 6, 21, 12, 2, 0, 0, time.UTC),
+		SolarNoon(time.Date(2024, 6, 21, 20, 0, 0, 0, time.UTC), -0.1278), 2*time.Minute)
+	// Halfway between sunrise and sunset, and still there in the polar night
+	sunrise, sunset := SunTimes(time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC), 50.4501, 30.5234)
+	assert.WithinDuration(t, sunrise.Add(sunset.Sub(sunrise)/2), SolarNoon(sunrise, 30.5234), time.Minute)
+	assert.False(t, SolarNoon(time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC), 18.9553).IsZero())
+}
+
+func TestSunPosition(t *testing.T) {
+	noon := SolarNoon(time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), -0.1278)
+	altitude, azimuth := SunPosition(noon, 51.5074, -0.1278)
+	assert.InDelta(t, 62, altitude, 1)
+	assert.InDelta(t, 180, azimuth, 1)
+
+	// The midsummer sun rises in the north-east and sets in the north-west
+	sunrise, sunset := SunTimes(noon, 51.5074, -0.1278)
+	_, azimuth = SunPosition(sunrise, 51.5074, -0.1278)
+	assert.InDelta(t, 49, azimuth, 2)
+	_, azimuth = SunPosition(sunset, 51.5074, -0.1278)
+	assert.InDelta(t, 311, azimuth, 2)
+
+	// South of the tropics the noon sun stands in the north
+	_, azimuth = SunPosition(SolarNoon(noon, 151.2093), -33.8688, 151.2093)
+	assert.True(t, azimuth < 1 || azimuth > 359, "azimuth %.1f", azimuth)
+}
+
+func TestCompassPoint(t *testing.T) {
+	assert.Equal(t, "N", CompassPoint(0))
+	assert.Equal(t, "NE", CompassPoint(49))
+	assert.Equal(t, "S", CompassPoint(180))
+	assert.Equal(t, "NW", CompassPoint(311))
+	assert.Equal(t, "N", CompassPoint(359))
+	assert.Equal(t, "W", CompassPoint(-90))
+}
+
+func TestMoonPhase(t *testing.T) {
+	newMoon := time.Date(2024, 1, 11, 11, 57, 0, 0, time.UTC)
+	fullMoon := time.Date(2024, 1, 25, 17, 54, 0, 0, time.UTC)
+
+	assert.InDelta(t, 0, MoonPhase(newMoon), 0.02)
+	assert.InDelta(t, 0.5, MoonPhase(fullMoon), 0.02)
+	assert.Equal(t, "🌑", MoonPhaseEmoji(MoonPhase(newMoon)))
+	assert.Equal(t, "🌕", MoonPhaseEmoji(MoonPhase(fullMoon)))
+	assert.Equal(t, "🌓", MoonPhaseEmoji(MoonPhase(newMoon.Add(7*24*time.Hour+9*time.Hour))))
+
+	// Dates before the reference new moon wrap into the cycle
+	phase := MoonPhase(time.Date(1999, 12, 1, 0, 0, 0, 0, time.UTC))
+	assert.GreaterOrEqual(t, phase, 0.0)
+	assert.Less(t, phase, 1.0)
+
+	// Just before new moon rounds to the new moon, not past the last octant
+	assert.Equal(t, 0, MoonPhaseOctant(0.97))
+	assert.Equal(t, 7, MoonPhaseOctant(0.9))
+}
+
+func TestAstronomicalTwilight(t *testing.T) {
+	dawn, dusk := AstronomicalTwilight(time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC), 50.4501, 30.5234) // Kyiv
+	assert.WithinDuration(t, time.Date(2024, 12, 21, 3, 55, 0, 0, time.UTC), dawn, 3*time.Minute)
+	assert.WithinDuration(t, time.Date(2024, 12, 21, 15, 57, 0, 0, time.UTC), dusk, 3*time.Minute)
+
+	// The midsummer sun stays within 18° of the horizon in London
+	dawn, dusk = AstronomicalTwilight(time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), 51.5074, -0.1278)
+	assert.True(t, dawn.IsZero())
+	assert.True(t, dusk.IsZero())
+}
+
+func TestAstro(t *testing.T) {
+	kyiv := time.FixedZone("EET", 2*60*60)
+	data := Astro(time.Date(2024, 12, 21, 18, 30, 0, 0, kyiv), 50.4501, 30.5234)
+
+	assert.Equal(t, time.Date(2024, 12, 21, 0, 0, 0, 0, kyiv), data.Date)
+	// Six days after the full moon of December 15th
+	assert.Equal(t, MoonWaningGibbous, data.MoonPhase)
+	assert.False(t, data.MoonWaxing)
+	assert.InDelta(t, 0.7, data.MoonIllumination, 0.1)
+	assert.Equal(t, "🌖", data.Emoji())
+	assert.WithinDuration(t, time.Date(2024, 12, 21, 15, 57, 0, 0, time.UTC), data.AstroTwilight, 3*time.Minute)
+	assert.WithinDuration(t, time.Date(2024, 12, 22, 3, 55, 0, 0, time.UTC), data.AstroDawn, 3*time.Minute)
+
+	summer := Astro(time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), 51.5074, -0.1278)
+	assert.True(t, summer.AstroTwilight.IsZero())
+	assert.True(t, summer.AstroDawn.IsZero())
+}
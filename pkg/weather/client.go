@@ -2,23 +2,30 @@ package weather
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
 // Client represents a weather API client
 type Client struct {
-	apiKey     string
-	baseURL    string
-	httpClient *http.Client
+	apiKey         string
+	baseURL        string
+	weatherTileURL string // OpenWeather map layers
+	mapTileURL     string // OpenStreetMap base map
+	pollenURL      string // Open-Meteo air quality, which has pollen counts
+	userAgent      string
+	httpClient     *http.Client
+	onUnknownField UnknownFieldHandler
 }
 
 // WeatherData represents current weather information
 type WeatherData struct {
 	Temperature   float64   `json:"temperature"`
+	FeelsLike     float64   `json:"feels_like"`
 	Humidity      int       `json:"humidity"`
 	Pressure      float64   `json:"pressure"`
 	WindSpeed     float64   `json:"wind_speed"`
@@ -28,7 +35,12 @@ type WeatherData struct {
 	Description   string    `json:"description"`
 	Icon          string    `json:"icon"`
 	LocationName  string    `json:"location_name"`
+	Sunrise       time.Time `json:"sunrise"`       // UTC; zero when the provider omits it
+	Sunset        time.Time `json:"sunset"`        // UTC; zero when the provider omits it
+	UTCOffset     int       `json:"utc_offset"`    // Seconds east of UTC at the location
+	Precipitation float64   `json:"precipitation"` // Rain and snow in the last hour, mm
 	Timestamp     time.Time `json:"timestamp"`
+	FromCache     bool      `json:"-"` // Set when served from cache rather than the provider
 }
 
 // ForecastData represents weather forecast
@@ -46,6 +58,33 @@ type DailyForecast struct {
 	Icon        string    `json:"icon"`
 	Humidity    int       `json:"humidity"`
 	WindSpeed   float64   `json:"wind_speed"`
+	Sunrise     time.Time `json:"sunrise"` // UTC; zero when the sun does not rise that day
+	Sunset      time.Time `json:"sunset"`  // UTC; zero when the sun does not set that day
+	// PrecipitationChance is the highest probability of precipitation, 0 to 1,
+	// among the day's forecast entries
+	PrecipitationChance float64 `json:"precipitation_chance"`
+}
+
+// HourlyForecastData represents the forecast for the next hours
+type HourlyForecastData struct {
+	Location  string           `json:"location"`
+	UTCOffset int              `json:"utc_offset"` // Seconds east of UTC at the location
+	Slots     []HourlyForecast `json:"slots"`
+}
+
+// HourlySlotHours is the length of one hourly forecast slot
+const HourlySlotHours = 3
+
+// HourlyForecast represents one 3-hour forecast slot
+type HourlyForecast struct {
+	Time        time.Time `json:"time"` // UTC start of the slot
+	Temperature float64   `json:"temperature"`
+	WindSpeed   float64   `json:"wind_speed"` // km/h
+	Description string    `json:"description"`
+	Icon        string    `json:"icon"`
+	// PrecipitationChance is the probability of precipitation, 0 to 1
+	PrecipitationChance float64 `json:"precipitation_chance"`
+	Clouds              int     `json:"clouds"` // Cloud cover, %
 }
 
 // AirQualityData represents air quality information
@@ -66,24 +105,39 @@ type Location struct {
 	Name       string            `json:"name"`
 	Country    string            `json:"country"`
 	City       string            `json:"city"`
+	State      string            `json:"state,omitempty"`
 	LocalNames map[string]string `json:"local_names,omitempty"`
 }
 
 // NewClient creates a new weather API client
 func NewClient(apiKey string) *Client {
 	return &Client{
-		apiKey:  apiKey,
-		baseURL: "https://api.openweathermap.org",
+		apiKey:         apiKey,
+		baseURL:        "https://api.openweathermap.org",
+		weatherTileURL: "https://tile.openweathermap.org",
+		mapTileURL:     "https://tile.openstreetmap.org",
+		pollenURL:      "https://air-quality-api.open-meteo.com",
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
 	}
 }
 
+// SetUnknownFieldHandler registers a callback for response fields that are not
+// part of the known provider schema
+func (c *Client) SetUnknownFieldHandler(handler UnknownFieldHandler) {
+	c.onUnknownField = handler
+}
+
+// SetUserAgent sets the User-Agent sent with map tile requests
+func (c *Client) SetUserAgent(userAgent string) {
+	c.userAgent = userAgent
+}
+
 // GetCurrentWeather retrieves current weather for a location
 func (c *Client) GetCurrentWeather(ctx context.Context, lat, lon float64) (*WeatherData, error) {
-	url := fmt.Sprintf("%s/data/2.5/weather?lat=%.6f&lon=%.6f&appid=%s&units=metric",
-		c.baseURL, lat, lon, c.apiKey)
+	url := fmt.Sprintf("%s/data/2.5/weather?lat=%.6f&lon=%.6f&appid=%s&units=metric%s",
+		c.baseURL, lat, lon, requestKey(ctx, c.apiKey), languageParam(ctx))
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -92,58 +146,84 @@ func (c *Client) GetCurrentWeather(ctx context.Context, lat, lon float64) (*Weat
 
 	resp, err := c.httpClient.Do(req) // #nosec G704
 	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
+		return nil, RequestError(err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status: %d", resp.StatusCode)
+		return nil, StatusError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return decodeCurrentWeather(body, c.onUnknownField)
+}
+
+// GetForecast retrieves weather forecast for a location
+func (c *Client) GetForecast(ctx context.Context, lat, lon float64, days int) (*ForecastData, error) {
+	body, err := c.fetchForecast(ctx, lat, lon)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeForecast(body, days, c.onUnknownField)
+}
+
+// GetHourlyForecast retrieves the 3-hour forecast slots covering the next
+// hours for a location
+func (c *Client) GetHourlyForecast(ctx context.Context, lat, lon float64, hours int) (*HourlyForecastData, error) {
+	body, err := c.fetchForecast(ctx, lat, lon)
+	if err != nil {
+		return nil, err
 	}
 
-	var apiResponse struct {
-		Main struct {
-			Temp     float64 `json:"temp"`
-			Humidity int     `json:"humidity"`
-			Pressure float64 `json:"pressure"`
-		} `json:"main"`
-		Wind struct {
-			Speed float64 `json:"speed"`
-			Deg   int     `json:"deg"`
-		} `json:"wind"`
-		Weather []struct {
-			Main        string `json:"main"`
-			Description string `json:"description"`
-			Icon        string `json:"icon"`
-		} `json:"weather"`
-		Visibility int `json:"visibility"`
+	return decodeHourlyForecast(body, hours, c.onUnknownField)
+}
+
+// fetchForecast requests the 5-day forecast in 3-hour steps that both the
+// daily and the hourly forecast are built from
+func (c *Client) fetchForecast(ctx context.Context, lat, lon float64) ([]byte, error) {
+	url := fmt.Sprintf("%s/data/2.5/forecast?lat=%.6f&lon=%.6f&appid=%s&units=metric%s",
+		c.baseURL, lat, lon, requestKey(ctx, c.apiKey), languageParam(ctx))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	resp, err := c.httpClient.Do(req) // #nosec G704
+	if err != nil {
+		return nil, RequestError(err)
 	}
+	defer func() { _ = resp.Body.Close() }()
 
-	weather := &WeatherData{
-		Temperature:   apiResponse.Main.Temp,
-		Humidity:      apiResponse.Main.Humidity,
-		Pressure:      apiResponse.Main.Pressure,
-		WindSpeed:     apiResponse.Wind.Speed * 3.6, // Convert m/s to km/h
-		WindDirection: apiResponse.Wind.Deg,
-		Visibility:    float64(apiResponse.Visibility) / 1000, // Convert m to km
-		Timestamp:     time.Now(),
+	if resp.StatusCode != http.StatusOK {
+		return nil, StatusError(resp)
 	}
 
-	if len(apiResponse.Weather) > 0 {
-		weather.Description = apiResponse.Weather[0].Description
-		weather.Icon = apiResponse.Weather[0].Icon
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
+	return body, nil
+}
 
-	return weather, nil
+// languageParam is the query parameter asking for weather descriptions in
+// the language requested with ctx
+func languageParam(ctx context.Context) string {
+	if language := LanguageFrom(ctx); language != "" {
+		return "&lang=" + url.QueryEscape(language)
+	}
+	return ""
 }
 
-// GetForecast retrieves weather forecast for a location
-func (c *Client) GetForecast(ctx context.Context, lat, lon float64, days int) (*ForecastData, error) {
-	url := fmt.Sprintf("%s/data/2.5/forecast?lat=%.6f&lon=%.6f&appid=%s&units=metric",
-		c.baseURL, lat, lon, c.apiKey)
+// GetAirQuality retrieves air quality data for a location
+func (c *Client) GetAirQuality(ctx context.Context, lat, lon float64) (*AirQualityData, error) {
+	url := fmt.Sprintf("%s/data/2.5/air_pollution?lat=%.6f&lon=%.6f&appid=%s",
+		c.baseURL, lat, lon, requestKey(ctx, c.apiKey))
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -152,77 +232,92 @@ func (c *Client) GetForecast(ctx context.Context, lat, lon float64, days int) (*
 
 	resp, err := c.httpClient.Do(req) // #nosec G704
 	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
+		return nil, RequestError(err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status: %d", resp.StatusCode)
+		return nil, StatusError(resp)
 	}
 
-	var apiResponse struct {
-		List []struct {
-			Dt   int64 `json:"dt"`
-			Main struct {
-				Temp    float64 `json:"temp"`
-				TempMin float64 `json:"temp_min"`
-				TempMax float64 `json:"temp_max"`
-			} `json:"main"`
-			Weather []struct {
-				Description string `json:"description"`
-				Icon        string `json:"icon"`
-			} `json:"weather"`
-		} `json:"list"`
-		City struct {
-			Name    string `json:"name"`
-			Country string `json:"country"`
-		} `json:"city"`
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	return decodeAirQuality(body, c.onUnknownField)
+}
+
+// GetAirQualityForecast retrieves the hourly air quality of a location from
+// the current hour on, up to hours of it; OpenWeather forecasts up to
+// AirQualityForecastHours ahead
+func (c *Client) GetAirQualityForecast(ctx context.Context, lat, lon float64, hours int) (*AirQualityForecast, error) {
+	url := fmt.Sprintf("%s/data/2.5/air_pollution/forecast?lat=%.6f&lon=%.6f&appid=%s",
+		c.baseURL, lat, lon, requestKey(ctx, c.apiKey))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	forecast := &ForecastData{
-		Location:  fmt.Sprintf("%s, %s", apiResponse.City.Name, apiResponse.City.Country),
-		Forecasts: make([]DailyForecast, 0),
+	resp, err := c.httpClient.Do(req) // #nosec G704
+	if err != nil {
+		return nil, RequestError(err)
 	}
+	defer func() { _ = resp.Body.Close() }()
 
-	// Group forecasts by day and take the first 'days' entries
-	daysSeen := make(map[string]bool)
-	for _, item := range apiResponse.List {
-		if len(forecast.Forecasts) >= days {
-			break
-		}
+	if resp.StatusCode != http.StatusOK {
+		return nil, StatusError(resp)
+	}
 
-		date := time.Unix(item.Dt, 0).UTC().Truncate(24 * time.Hour)
-		dateKey := date.Format("2006-01-02")
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
 
-		if !daysSeen[dateKey] {
-			daysSeen[dateKey] = true
+	return decodeAirQualityForecast(body, min(hours, AirQualityForecastHours), c.onUnknownField)
+}
 
-			daily := DailyForecast{
-				Date:    date,
-				MinTemp: item.Main.TempMin,
-				MaxTemp: item.Main.TempMax,
-			}
+// GetWeatherWarnings retrieves the official warnings covering a location
+// from the One Call API, which needs its own OpenWeather subscription
+func (c *Client) GetWeatherWarnings(ctx context.Context, lat, lon float64) ([]WeatherWarning, error) {
+	url := fmt.Sprintf("%s/data/3.0/onecall?lat=%.6f&lon=%.6f&exclude=current,minutely,hourly,daily&appid=%s",
+		c.baseURL, lat, lon, requestKey(ctx, c.apiKey))
 
-			if len(item.Weather) > 0 {
-				daily.Description = item.Weather[0].Description
-				daily.Icon = item.Weather[0].Icon
-			}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
 
-			forecast.Forecasts = append(forecast.Forecasts, daily)
-		}
+	resp, err := c.httpClient.Do(req) // #nosec G704
+	if err != nil {
+		return nil, RequestError(err)
 	}
+	defer func() { _ = resp.Body.Close() }()
 
-	return forecast, nil
+	if resp.StatusCode != http.StatusOK {
+		return nil, StatusError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return decodeWarnings(body, c.onUnknownField)
 }
 
-// GetAirQuality retrieves air quality data for a location
-func (c *Client) GetAirQuality(ctx context.Context, lat, lon float64) (*AirQualityData, error) {
-	url := fmt.Sprintf("%s/data/2.5/air_pollution?lat=%.6f&lon=%.6f&appid=%s",
-		c.baseURL, lat, lon, c.apiKey)
+// GetPollenForecast retrieves the pollen counts of a location for now and the
+// next PollenForecastDays days. OpenWeather has no pollen data, so they come
+// from Open-Meteo, which needs no key but covers Europe only; elsewhere it
+// fails with ErrNoPollenData.
+func (c *Client) GetPollenForecast(ctx context.Context, lat, lon float64) (*PollenData, error) {
+	variables := make([]string, 0, len(pollenSpecies))
+	for _, species := range pollenSpecies {
+		variables = append(variables, species.variable)
+	}
+	url := fmt.Sprintf("%s/v1/air-quality?latitude=%.6f&longitude=%.6f&hourly=%s&timezone=auto&timeformat=unixtime&forecast_days=%d",
+		c.pollenURL, lat, lon, strings.Join(variables, ","), PollenForecastDays+1)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -231,54 +326,28 @@ func (c *Client) GetAirQuality(ctx context.Context, lat, lon float64) (*AirQuali
 
 	resp, err := c.httpClient.Do(req) // #nosec G704
 	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
+		return nil, RequestError(err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status: %d", resp.StatusCode)
-	}
-
-	var apiResponse struct {
-		List []struct {
-			Main struct {
-				AQI int `json:"aqi"`
-			} `json:"main"`
-			Components struct {
-				CO   float64 `json:"co"`
-				NO2  float64 `json:"no2"`
-				O3   float64 `json:"o3"`
-				PM25 float64 `json:"pm2_5"`
-				PM10 float64 `json:"pm10"`
-			} `json:"components"`
-		} `json:"list"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if len(apiResponse.List) == 0 {
-		return nil, fmt.Errorf("no air quality data available")
-	}
-
-	data := apiResponse.List[0]
-	return &AirQualityData{
-		AQI:       data.Main.AQI,
-		CO:        data.Components.CO,
-		NO2:       data.Components.NO2,
-		O3:        data.Components.O3,
-		PM25:      data.Components.PM25,
-		PM10:      data.Components.PM10,
-		Timestamp: time.Now(),
-	}, nil
+		return nil, StatusError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return decodePollen(body, time.Now(), c.onUnknownField)
 }
 
 // GeocodingClient handles location-related requests
 type GeocodingClient struct {
-	apiKey     string
-	baseURL    string
-	httpClient *http.Client
+	apiKey         string
+	baseURL        string
+	httpClient     *http.Client
+	onUnknownField UnknownFieldHandler
 }
 
 // NewGeocodingClient creates a new geocoding client
@@ -292,13 +361,56 @@ func NewGeocodingClient(apiKey string) *GeocodingClient {
 	}
 }
 
+// SetUnknownFieldHandler registers a callback for response fields that are not
+// part of the known provider schema
+func (c *GeocodingClient) SetUnknownFieldHandler(handler UnknownFieldHandler) {
+	c.onUnknownField = handler
+}
+
 // GeocodeLocation converts location name to coordinates
 func (c *GeocodingClient) GeocodeLocation(ctx context.Context, locationName string) (*Location, error) {
 	// URL encode the location name to properly handle non-English characters
 	encodedLocation := url.QueryEscape(locationName)
 	requestURL := fmt.Sprintf("%s/geo/1.0/direct?q=%s&limit=1&appid=%s",
-		c.baseURL, encodedLocation, c.apiKey)
+		c.baseURL, encodedLocation, requestKey(ctx, c.apiKey))
+
+	body, err := c.fetch(ctx, requestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeGeocoding(body, c.onUnknownField)
+}
+
+// SearchLocations returns up to limit places matching the name, best match first.
+// Unlike GeocodeLocation, an empty result is not an error.
+func (c *GeocodingClient) SearchLocations(ctx context.Context, locationName string, limit int) ([]Location, error) {
+	requestURL := fmt.Sprintf("%s/geo/1.0/direct?q=%s&limit=%d&appid=%s",
+		c.baseURL, url.QueryEscape(locationName), limit, requestKey(ctx, c.apiKey))
+
+	body, err := c.fetch(ctx, requestURL)
+	if err != nil {
+		return nil, err
+	}
 
+	return decodeGeocodingResults(body, c.onUnknownField)
+}
+
+// ReverseLocations returns up to limit named places around the coordinates
+func (c *GeocodingClient) ReverseLocations(ctx context.Context, lat, lon float64, limit int) ([]Location, error) {
+	requestURL := fmt.Sprintf("%s/geo/1.0/reverse?lat=%.6f&lon=%.6f&limit=%d&appid=%s",
+		c.baseURL, lat, lon, limit, requestKey(ctx, c.apiKey))
+
+	body, err := c.fetch(ctx, requestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeGeocodingResults(body, c.onUnknownField)
+}
+
+// fetch performs a GET request and returns the body of a successful response
+func (c *GeocodingClient) fetch(ctx context.Context, requestURL string) ([]byte, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -306,38 +418,18 @@ func (c *GeocodingClient) GeocodeLocation(ctx context.Context, locationName stri
 
 	resp, err := c.httpClient.Do(req) // #nosec G704
 	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
+		return nil, RequestError(err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status: %d", resp.StatusCode)
-	}
-
-	var apiResponse []struct {
-		Name       string            `json:"name"`
-		Country    string            `json:"country"`
-		State      string            `json:"state"`
-		Lat        float64           `json:"lat"`
-		Lon        float64           `json:"lon"`
-		LocalNames map[string]string `json:"local_names"`
+		return nil, StatusError(resp)
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if len(apiResponse) == 0 {
-		return nil, fmt.Errorf("location not found")
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	result := apiResponse[0]
-	return &Location{
-		Latitude:   result.Lat,
-		Longitude:  result.Lon,
-		Name:       result.Name,
-		Country:    result.Country,
-		City:       result.Name,
-		LocalNames: result.LocalNames,
-	}, nil
+	return body, nil
 }
@@ -3,8 +3,10 @@ package weather
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
@@ -43,12 +45,15 @@ func TestClient_GetCurrentWeather_Success(t *testing.T) {
 			},
 			"weather": []map[string]interface{}{
 				{
+					"id":          802,
 					"main":        "Clouds",
 					"description": "scattered clouds",
 					"icon":        "03d",
 				},
 			},
 			"visibility": 10000,
+			"rain":       map[string]interface{}{"1h": 0.8},
+			"snow":       map[string]interface{}{"1h": 0.2},
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -71,9 +76,59 @@ func TestClient_GetCurrentWeather_Success(t *testing.T) {
 	assert.Equal(t, 10.0, weather.Visibility)
 	assert.Equal(t, "scattered clouds", weather.Description)
 	assert.Equal(t, "03d", weather.Icon)
+	assert.InDelta(t, 1.0, weather.Precipitation, 0.001)
 	assert.False(t, weather.Timestamp.IsZero())
 }
 
+func TestClient_GetCurrentWeather_Language(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient("test_key")
+	client.baseURL = server.URL
+
+	_, _ = client.GetCurrentWeather(WithLanguage(context.Background(), "uk-UA"), 50.45, 30.52)
+	assert.Equal(t, "uk", query.Get("lang"))
+
+	_, _ = client.GetForecast(WithLanguage(context.Background(), "de-DE"), 50.45, 30.52, 5)
+	assert.Equal(t, "de", query.Get("lang"))
+
+	// English is the provider's default, so it is not asked for
+	_, _ = client.GetCurrentWeather(WithLanguage(context.Background(), "en-US"), 50.45, 30.52)
+	assert.False(t, query.Has("lang"))
+	_, _ = client.GetCurrentWeather(context.Background(), 50.45, 30.52)
+	assert.False(t, query.Has("lang"))
+}
+
+func TestClient_APIKeyOverride(t *testing.T) {
+	var appIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		appIDs = append(appIDs, r.URL.Query().Get("appid"))
+		if r.URL.Query().Get("appid") == "rejected_key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient("test_key")
+	client.baseURL = server.URL
+
+	_, _ = client.GetCurrentWeather(WithAPIKey(context.Background(), "user_key"), 50.45, 30.52)
+	_, _ = client.GetAirQuality(WithAPIKey(context.Background(), ""), 50.45, 30.52)
+	assert.Equal(t, []string{"user_key", "test_key"}, appIDs)
+
+	err := client.ValidateAPIKey(context.Background(), "rejected_key")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.ErrorIs(t, client.ValidateAPIKey(context.Background(), ""), ErrUnauthorized)
+	assert.ErrorIs(t, client.ValidateAPIKey(context.Background(), "user_key"), ErrUnavailable)
+}
+
 func TestClient_GetCurrentWeather_APIError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
@@ -133,10 +188,11 @@ func TestClient_GetCurrentWeather_NoWeatherData(t *testing.T) {
 
 	weather, err := client.GetCurrentWeather(context.Background(), 40.7128, -74.0060)
 
-	require.NoError(t, err)
-	require.NotNil(t, weather)
-	assert.Equal(t, "", weather.Description)
-	assert.Equal(t, "", weather.Icon)
+	// Without a condition block the response no longer matches the schema
+	var schemaErr *SchemaError
+	require.True(t, errors.As(err, &schemaErr))
+	assert.Equal(t, "weather[0].id", schemaErr.Field)
+	assert.Nil(t, weather)
 }
 
 func TestClient_GetForecast_Success(t *testing.T) {
@@ -155,6 +211,7 @@ func TestClient_GetForecast_Success(t *testing.T) {
 					},
 					"weather": []map[string]interface{}{
 						{
+							"id":          800,
 							"description": "clear sky",
 							"icon":        "01d",
 						},
@@ -169,6 +226,7 @@ func TestClient_GetForecast_Success(t *testing.T) {
 					},
 					"weather": []map[string]interface{}{
 						{
+							"id":          801,
 							"description": "few clouds",
 							"icon":        "02d",
 						},
@@ -207,10 +265,10 @@ func TestClient_GetForecast_LimitDays(t *testing.T) {
 		now := time.Now().Unix()
 		response := map[string]interface{}{
 			"list": []map[string]interface{}{
-				{"dt": now, "main": map[string]interface{}{"temp": 15.0, "temp_min": 12.0, "temp_max": 18.0}, "weather": []map[string]interface{}{{"description": "day 1", "icon": "01d"}}},
-				{"dt": now + 86400, "main": map[string]interface{}{"temp": 16.0, "temp_min": 13.0, "temp_max": 19.0}, "weather": []map[string]interface{}{{"description": "day 2", "icon": "02d"}}},
-				{"dt": now + 172800, "main": map[string]interface{}{"temp": 17.0, "temp_min": 14.0, "temp_max": 20.0}, "weather": []map[string]interface{}{{"description": "day 3", "icon": "03d"}}},
-				{"dt": now + 259200, "main": map[string]interface{}{"temp": 18.0, "temp_min": 15.0, "temp_max": 21.0}, "weather": []map[string]interface{}{{"description": "day 4", "icon": "04d"}}},
+				{"dt": now, "main": map[string]interface{}{"temp": 15.0, "temp_min": 12.0, "temp_max": 18.0}, "weather": []map[string]interface{}{{"id": 800, "description": "day 1", "icon": "01d"}}},
+				{"dt": now + 86400, "main": map[string]interface{}{"temp": 16.0, "temp_min": 13.0, "temp_max": 19.0}, "weather": []map[string]interface{}{{"id": 800, "description": "day 2", "icon": "02d"}}},
+				{"dt": now + 172800, "main": map[string]interface{}{"temp": 17.0, "temp_min": 14.0, "temp_max": 20.0}, "weather": []map[string]interface{}{{"id": 800, "description": "day 3", "icon": "03d"}}},
+				{"dt": now + 259200, "main": map[string]interface{}{"temp": 18.0, "temp_min": 15.0, "temp_max": 21.0}, "weather": []map[string]interface{}{{"id": 800, "description": "day 4", "icon": "04d"}}},
 			},
 			"city": map[string]interface{}{"name": "Test", "country": "US"},
 		}
@@ -230,6 +288,56 @@ func TestClient_GetForecast_LimitDays(t *testing.T) {
 	assert.Len(t, forecast.Forecasts, 2) // Should limit to 2 days
 }
 
+func TestClient_GetHourlyForecast(t *testing.T) {
+	start := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.URL.Path, "/data/2.5/forecast")
+
+		list := make([]map[string]interface{}, 0, 10)
+		for i := 0; i < 10; i++ {
+			list = append(list, map[string]interface{}{
+				"dt":      start + int64(i)*3*3600,
+				"main":    map[string]interface{}{"temp": 20.0 + float64(i), "temp_min": 18.0, "temp_max": 25.0},
+				"wind":    map[string]interface{}{"speed": 5.0},
+				"pop":     0.4,
+				"clouds":  map[string]interface{}{"all": 75},
+				"weather": []map[string]interface{}{{"id": 500, "description": "light rain", "icon": "10d"}},
+			})
+		}
+		response := map[string]interface{}{
+			"list": list,
+			"city": map[string]interface{}{"name": "Kyiv", "country": "UA", "timezone": 10800},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	client := NewClient("test_key")
+	client.baseURL = server.URL
+
+	forecast, err := client.GetHourlyForecast(context.Background(), 50.45, 30.52, 24)
+	require.NoError(t, err)
+	assert.Equal(t, "Kyiv, UA", forecast.Location)
+	assert.Equal(t, 10800, forecast.UTCOffset)
+	require.Len(t, forecast.Slots, 8)
+
+	first := forecast.Slots[0]
+	assert.Equal(t, time.Unix(start, 0).UTC(), first.Time)
+	assert.Equal(t, 20.0, first.Temperature)
+	assert.Equal(t, 18.0, first.WindSpeed) // 5 m/s in km/h
+	assert.Equal(t, 0.4, first.PrecipitationChance)
+	assert.Equal(t, 75, first.Clouds)
+	assert.Equal(t, "10d", first.Icon)
+	assert.Equal(t, 27.0, forecast.Slots[7].Temperature)
+
+	// A window that is not a multiple of the slot length still covers it
+	forecast, err = client.GetHourlyForecast(context.Background(), 50.45, 30.52, 4)
+	require.NoError(t, err)
+	assert.Len(t, forecast.Slots, 2)
+}
+
 func TestClient_GetForecast_APIError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusUnauthorized)
@@ -279,7 +387,8 @@ func TestClient_GetAirQuality_Success(t *testing.T) {
 
 	require.NoError(t, err)
 	require.NotNil(t, airQuality)
-	assert.Equal(t, 3, airQuality.AQI)
+	// OpenWeather's 3 of 5 is replaced by the US index of the PM2.5 reading
+	assert.Equal(t, 63, airQuality.AQI)
 	assert.Equal(t, 250.5, airQuality.CO)
 	assert.Equal(t, 30.5, airQuality.NO2)
 	assert.Equal(t, 50.5, airQuality.O3)
@@ -519,3 +628,103 @@ func TestGeocodingClient_GeocodeLocation_NetworkError(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to make request")
 }
+
+func TestClient_ErrorClasses(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		expected error
+	}{
+		{"not found", http.StatusNotFound, ErrNotFound},
+		{"gateway timeout", http.StatusGatewayTimeout, ErrTimeout},
+		{"server error", http.StatusServiceUnavailable, ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			client := NewClient("test_key")
+			client.baseURL = server.URL
+
+			_, err := client.GetCurrentWeather(context.Background(), 40.7128, -74.0060)
+			assert.ErrorIs(t, err, tt.expected)
+		})
+	}
+}
+
+func TestClient_RateLimited(t *testing.T) {
+	tests := []struct {
+		retryAfter string
+		expected   time.Duration
+	}{
+		{"120", 2 * time.Minute},
+		{"", DefaultRetryAfter},
+		{"soon", DefaultRetryAfter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.retryAfter, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(http.StatusTooManyRequests)
+			}))
+			defer server.Close()
+
+			client := NewClient("test_key")
+			client.baseURL = server.URL
+
+			_, err := client.GetForecast(context.Background(), 40.7128, -74.0060, 5)
+			var rateLimited *RateLimitError
+			require.True(t, errors.As(err, &rateLimited))
+			assert.Equal(t, tt.expected, rateLimited.RetryAfter)
+		})
+	}
+}
+
+func TestClient_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewClient("test_key")
+	client.baseURL = server.URL
+	client.httpClient.Timeout = 50 * time.Millisecond
+
+	_, err := client.GetAirQuality(context.Background(), 40.7128, -74.0060)
+	assert.ErrorIs(t, err, ErrTimeout)
+}
+
+func TestClient_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serverURL := server.URL
+	server.Close()
+
+	client := NewGeocodingClient("test_key")
+	client.baseURL = serverURL
+
+	_, err := client.GeocodeLocation(context.Background(), "London")
+	assert.ErrorIs(t, err, ErrUnavailable)
+}
+
+func TestGeocodingClient_GeocodeLocation_NotFoundClass(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	client := NewGeocodingClient("test_key")
+	client.baseURL = server.URL
+
+	_, err := client.GeocodeLocation(context.Background(), "NonexistentCity")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,359 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/pkg/weather/weathertest"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files from the current parser output")
+
+// goldenOutput is what gets compared against testdata/golden
+type goldenOutput struct {
+	SchemaVersion int         `json:"schema_version"`
+	Result        interface{} `json:"result"`
+}
+
+// pollenFixtureNow is 12:30 in Kyiv on the first day of the pollen fixture
+var pollenFixtureNow = time.Date(2026, 4, 16, 9, 30, 0, 0, time.UTC)
+
+// decodeFixture runs the decoder that belongs to a fixture. Wall-clock
+// timestamps are cleared so the output is stable.
+func decodeFixture(t *testing.T, name string, report UnknownFieldHandler) interface{} {
+	data := weathertest.MustFixture(name)
+
+	switch name {
+	case weathertest.CurrentWeather:
+		result, err := decodeCurrentWeather(data, report)
+		require.NoError(t, err)
+		result.Timestamp = time.Time{}
+		return result
+	case weathertest.Forecast:
+		result, err := decodeForecast(data, 5, report)
+		require.NoError(t, err)
+		return result
+	case weathertest.AirPollution:
+		result, err := decodeAirQuality(data, report)
+		require.NoError(t, err)
+		result.Timestamp = time.Time{}
+		return result
+	case weathertest.AirForecast:
+		result, err := decodeAirQualityForecast(data, 24, report)
+		require.NoError(t, err)
+		return result
+	case weathertest.Geocoding:
+		result, err := decodeGeocoding(data, report)
+		require.NoError(t, err)
+		return result
+	case weathertest.Pollen:
+		result, err := decodePollen(data, pollenFixtureNow, report)
+		require.NoError(t, err)
+		return result
+	case weathertest.OneCall:
+		result, err := decodeWarnings(data, report)
+		require.NoError(t, err)
+		return result
+	case weathertest.ReverseGeocoding:
+		result, err := decodeGeocodingResults(data, report)
+		require.NoError(t, err)
+		return result
+	case weathertest.OpenMeteoForecast:
+		// One response carries the variables of all three kinds of request
+		current, err := decodeOpenMeteoCurrent(data, report)
+		require.NoError(t, err)
+		current.Timestamp = time.Time{}
+		daily, err := decodeOpenMeteoForecast(data, 5, report)
+		require.NoError(t, err)
+		hourly, err := decodeOpenMeteoHourly(data, 12, pollenFixtureNow, report)
+		require.NoError(t, err)
+		return map[string]interface{}{"current": current, "daily": daily, "hourly": hourly}
+	case weathertest.OpenMeteoAirQuality:
+		result, err := decodeOpenMeteoAirQuality(data, report)
+		require.NoError(t, err)
+		result.Timestamp = time.Time{}
+		return result
+	case weathertest.OpenMeteoGeocoding:
+		result, err := decodeOpenMeteoGeocoding(data, report)
+		require.NoError(t, err)
+		return result
+	}
+
+	t.Fatalf("no decoder for fixture %s", name)
+	return nil
+}
+
+func TestContract_RecordedFixtures(t *testing.T) {
+	for _, name := range weathertest.Names() {
+		t.Run(name, func(t *testing.T) {
+			var unknown []string
+			result := decodeFixture(t, name, func(endpoint, field string) {
+				unknown = append(unknown, field)
+			})
+			assert.Empty(t, unknown, "recorded fixture contains fields missing from the known schema")
+
+			actual, err := json.MarshalIndent(goldenOutput{SchemaVersion: SchemaVersion, Result: result}, "", "  ")
+			require.NoError(t, err)
+			actual = append(actual, '\n')
+
+			path := filepath.Join("testdata", "golden", strings.TrimSuffix(name, ".json")+".golden.json")
+			if *updateGolden {
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+				require.NoError(t, os.WriteFile(path, actual, 0o600))
+			}
+
+			expected, err := os.ReadFile(path) // #nosec G304 -- test fixture path
+			require.NoError(t, err, "golden file missing; run go test ./pkg/weather -run TestContract -update")
+			assert.Equal(t, string(expected), string(actual),
+				"parser output changed; if intended, bump SchemaVersion and rerun with -update")
+		})
+	}
+}
+
+func TestContract_MissingRequiredFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		remove  func(map[string]interface{})
+		field   string
+		decode  func([]byte) error
+	}{
+		{
+			name:    "current weather without main.temp",
+			fixture: weathertest.CurrentWeather,
+			remove:  func(m map[string]interface{}) { delete(m["main"].(map[string]interface{}), "temp") },
+			field:   "main.temp",
+			decode:  func(data []byte) error { _, err := decodeCurrentWeather(data, nil); return err },
+		},
+		{
+			name:    "current weather without condition id",
+			fixture: weathertest.CurrentWeather,
+			remove: func(m map[string]interface{}) {
+				delete(m["weather"].([]interface{})[0].(map[string]interface{}), "id")
+			},
+			field:  "weather[0].id",
+			decode: func(data []byte) error { _, err := decodeCurrentWeather(data, nil); return err },
+		},
+		{
+			name:    "current weather with empty conditions",
+			fixture: weathertest.CurrentWeather,
+			remove:  func(m map[string]interface{}) { m["weather"] = []interface{}{} },
+			field:   "weather[0].id",
+			decode:  func(data []byte) error { _, err := decodeCurrentWeather(data, nil); return err },
+		},
+		{
+			name:    "forecast entry without main.temp",
+			fixture: weathertest.Forecast,
+			remove: func(m map[string]interface{}) {
+				entry := m["list"].([]interface{})[2].(map[string]interface{})
+				delete(entry["main"].(map[string]interface{}), "temp")
+			},
+			field:  "list[2].main.temp",
+			decode: func(data []byte) error { _, err := decodeForecast(data, 5, nil); return err },
+		},
+		{
+			name:    "air pollution without aqi",
+			fixture: weathertest.AirPollution,
+			remove: func(m map[string]interface{}) {
+				entry := m["list"].([]interface{})[0].(map[string]interface{})
+				delete(entry["main"].(map[string]interface{}), "aqi")
+			},
+			field:  "list[0].main.aqi",
+			decode: func(data []byte) error { _, err := decodeAirQuality(data, nil); return err },
+		},
+		{
+			name:    "air pollution forecast hour without time",
+			fixture: weathertest.AirForecast,
+			remove: func(m map[string]interface{}) {
+				delete(m["list"].([]interface{})[4].(map[string]interface{}), "dt")
+			},
+			field:  "list[4].dt",
+			decode: func(data []byte) error { _, err := decodeAirQualityForecast(data, 24, nil); return err },
+		},
+		{
+			name:    "pollen without grass counts",
+			fixture: weathertest.Pollen,
+			remove:  func(m map[string]interface{}) { delete(m["hourly"].(map[string]interface{}), "grass_pollen") },
+			field:   "hourly.grass_pollen",
+			decode:  func(data []byte) error { _, err := decodePollen(data, pollenFixtureNow, nil); return err },
+		},
+		{
+			name:    "warning without start",
+			fixture: weathertest.OneCall,
+			remove: func(m map[string]interface{}) {
+				delete(m["alerts"].([]interface{})[1].(map[string]interface{}), "start")
+			},
+			field:  "alerts[1].start",
+			decode: func(data []byte) error { _, err := decodeWarnings(data, nil); return err },
+		},
+		{
+			name:    "open-meteo current weather without temperature",
+			fixture: weathertest.OpenMeteoForecast,
+			remove:  func(m map[string]interface{}) { delete(m["current"].(map[string]interface{}), "temperature_2m") },
+			field:   "current.temperature_2m",
+			decode:  func(data []byte) error { _, err := decodeOpenMeteoCurrent(data, nil); return err },
+		},
+		{
+			name:    "open-meteo forecast without weather codes",
+			fixture: weathertest.OpenMeteoForecast,
+			remove:  func(m map[string]interface{}) { delete(m["daily"].(map[string]interface{}), "weather_code") },
+			field:   "daily.weather_code",
+			decode:  func(data []byte) error { _, err := decodeOpenMeteoForecast(data, 5, nil); return err },
+		},
+		{
+			name:    "open-meteo hourly forecast with a missing temperature",
+			fixture: weathertest.OpenMeteoForecast,
+			remove: func(m map[string]interface{}) {
+				m["hourly"].(map[string]interface{})["temperature_2m"].([]interface{})[3] = nil
+			},
+			field:  "hourly.temperature_2m[3]",
+			decode: func(data []byte) error { _, err := decodeOpenMeteoHourly(data, 12, pollenFixtureNow, nil); return err },
+		},
+		{
+			name:    "open-meteo air quality without current values",
+			fixture: weathertest.OpenMeteoAirQuality,
+			remove:  func(m map[string]interface{}) { delete(m, "current") },
+			field:   "current",
+			decode:  func(data []byte) error { _, err := decodeOpenMeteoAirQuality(data, nil); return err },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var payload map[string]interface{}
+			require.NoError(t, json.Unmarshal(weathertest.MustFixture(tt.fixture), &payload))
+			tt.remove(payload)
+			data, err := json.Marshal(payload)
+			require.NoError(t, err)
+
+			err = tt.decode(data)
+
+			var schemaErr *SchemaError
+			require.True(t, errors.As(err, &schemaErr), "expected schema error, got %v", err)
+			assert.Equal(t, tt.field, schemaErr.Field)
+		})
+	}
+
+	t.Run("geocoding without coordinates", func(t *testing.T) {
+		_, err := decodeGeocoding([]byte(`[{"name": "Kyiv", "country": "UA", "latitude": 50.45, "lon": 30.52}]`), nil)
+
+		var schemaErr *SchemaError
+		require.True(t, errors.As(err, &schemaErr))
+		assert.Equal(t, "[0].lat", schemaErr.Field)
+	})
+}
+
+func TestContract_UnknownFieldsReported(t *testing.T) {
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(weathertest.MustFixture(weathertest.CurrentWeather), &payload))
+	payload["uvi"] = 3.2
+	payload["air_quality"] = map[string]interface{}{"aqi": 2}
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	var reported []string
+	result, err := decodeCurrentWeather(data, func(endpoint, field string) {
+		reported = append(reported, endpoint+"."+field)
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 14.62, result.Temperature)
+	assert.Equal(t, []string{"current_weather.air_quality", "current_weather.uvi"}, reported)
+}
+
+func TestContract_ClientAgainstFixtureServer(t *testing.T) {
+	server := weathertest.NewServer()
+	defer server.Close()
+
+	client := NewClient("test_key")
+	client.baseURL = server.URL
+	var reported []string
+	client.SetUnknownFieldHandler(func(endpoint, field string) { reported = append(reported, field) })
+
+	current, err := client.GetCurrentWeather(context.Background(), 50.4501, 30.5234)
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, current.Visibility)
+
+	forecast, err := client.GetForecast(context.Background(), 50.4501, 30.5234, 5)
+	require.NoError(t, err)
+	assert.Len(t, forecast.Forecasts, 3)
+
+	airQuality, err := client.GetAirQuality(context.Background(), 50.4501, 30.5234)
+	require.NoError(t, err)
+	assert.Equal(t, 38, airQuality.AQI)
+
+	airForecast, err := client.GetAirQualityForecast(context.Background(), 50.4501, 30.5234, AirQualityForecastHours)
+	require.NoError(t, err)
+	assert.Len(t, airForecast.Hours, 30)
+	assert.Equal(t, time.Date(2026, 4, 16, 6, 0, 0, 0, time.UTC), airForecast.Hours[0].Time)
+
+	client.pollenURL = server.URL
+	pollen, err := client.GetPollenForecast(context.Background(), 50.4501, 30.5234)
+	require.NoError(t, err)
+	assert.Equal(t, 10800, pollen.UTCOffset)
+
+	geocoder := NewGeocodingClient("test_key")
+	geocoder.baseURL = server.URL
+	location, err := geocoder.GeocodeLocation(context.Background(), "Kyiv")
+	require.NoError(t, err)
+	assert.Equal(t, "Київ", location.LocalNames["uk"])
+
+	nearby, err := geocoder.ReverseLocations(context.Background(), 50.4501, 30.5234, 5)
+	require.NoError(t, err)
+	assert.Len(t, nearby, 4)
+	assert.Equal(t, "Kyiv Oblast", nearby[1].State)
+
+	assert.Empty(t, reported)
+}
+
+func TestContract_OpenMeteoAgainstFixtureServer(t *testing.T) {
+	server := weathertest.NewServer()
+	defer server.Close()
+
+	provider := NewOpenMeteo()
+	provider.forecastURL = server.URL
+	provider.airQualityURL = server.URL
+	provider.geocodingURL = server.URL
+	var reported []string
+	provider.SetUnknownFieldHandler(func(endpoint, field string) { reported = append(reported, field) })
+
+	current, err := provider.GetCurrentWeather(context.Background(), 50.4501, 30.5234)
+	require.NoError(t, err)
+	assert.Equal(t, "scattered clouds", current.Description)
+	assert.Equal(t, "03d", current.Icon)
+	assert.InDelta(t, 24.14, current.Visibility, 0.001)
+
+	forecast, err := provider.GetForecast(context.Background(), 50.4501, 30.5234, 3)
+	require.NoError(t, err)
+	require.Len(t, forecast.Forecasts, 3)
+	assert.Equal(t, time.Date(2026, 4, 16, 0, 0, 0, 0, time.UTC), forecast.Forecasts[0].Date)
+	assert.InDelta(t, 0.72, forecast.Forecasts[0].PrecipitationChance, 0.001)
+
+	hourly, err := provider.GetHourlyForecast(context.Background(), 50.4501, 30.5234, 12)
+	require.NoError(t, err)
+	assert.Equal(t, 10800, hourly.UTCOffset)
+
+	airQuality, err := provider.GetAirQuality(context.Background(), 50.4501, 30.5234)
+	require.NoError(t, err)
+	assert.Equal(t, 42, airQuality.AQI)
+
+	location, err := provider.GeocodeLocation(context.Background(), "Kyiv")
+	require.NoError(t, err)
+	assert.Equal(t, "UA", location.Country)
+	assert.Equal(t, "Kyiv City", location.State)
+
+	_, err = provider.ReverseLocations(context.Background(), 50.4501, 30.5234, 5)
+	assert.ErrorIs(t, err, ErrNotSupported)
+
+	assert.Empty(t, reported)
+}
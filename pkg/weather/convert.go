@@ -0,0 +1,59 @@
+package weather
+
+// Conversion factors for imperial units
+const (
+	kmPerMile        = 1.609344
+	inHgPerHectopasc = 0.0295299830714
+	mmPerInch        = 25.4
+	metresPerFoot    = 0.3048
+)
+
+// CelsiusToFahrenheit converts a temperature from °C to °F
+func CelsiusToFahrenheit(celsius float64) float64 {
+	return celsius*9/5 + 32
+}
+
+// FahrenheitToCelsius converts a temperature from °F to °C
+func FahrenheitToCelsius(fahrenheit float64) float64 {
+	return (fahrenheit - 32) * 5 / 9
+}
+
+// KmhToMph converts a speed from km/h to mph
+func KmhToMph(kmh float64) float64 {
+	return kmh / kmPerMile
+}
+
+// MphToKmh converts a speed from mph to km/h
+func MphToKmh(mph float64) float64 {
+	return mph * kmPerMile
+}
+
+// KmToMiles converts a distance from km to miles
+func KmToMiles(km float64) float64 {
+	return km / kmPerMile
+}
+
+// HpaToInHg converts a pressure from hPa to inches of mercury
+func HpaToInHg(hpa float64) float64 {
+	return hpa * inHgPerHectopasc
+}
+
+// MmToInches converts a length such as an amount of precipitation from mm to inches
+func MmToInches(mm float64) float64 {
+	return mm / mmPerInch
+}
+
+// InHgToHpa converts a pressure from inches of mercury to hPa
+func InHgToHpa(inHg float64) float64 {
+	return inHg / inHgPerHectopasc
+}
+
+// MetresToFeet converts a height such as a tide from metres to feet
+func MetresToFeet(metres float64) float64 {
+	return metres / metresPerFoot
+}
+
+// FeetToMetres converts a height from feet to metres
+func FeetToMetres(feet float64) float64 {
+	return feet * metresPerFoot
+}
@@ -0,0 +1,25 @@
+package weather
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConversions(t *testing.T) {
+	assert.InDelta(t, 32.0, CelsiusToFahrenheit(0), 1e-9)
+	assert.InDelta(t, 212.0, CelsiusToFahrenheit(100), 1e-9)
+	assert.InDelta(t, -40.0, CelsiusToFahrenheit(-40), 1e-9)
+	assert.InDelta(t, 62.137, KmhToMph(100), 0.001)
+	assert.InDelta(t, 6.214, KmToMiles(10), 0.001)
+	assert.InDelta(t, 29.921, HpaToInHg(1013.25), 0.001)
+	assert.InDelta(t, 1.0, MmToInches(25.4), 1e-9)
+}
+
+func TestConversions_RoundTrip(t *testing.T) {
+	for _, value := range []float64{-40, -0.4, 0, 12.5, 1013.25} {
+		assert.InDelta(t, value, FahrenheitToCelsius(CelsiusToFahrenheit(value)), 1e-9)
+		assert.InDelta(t, value, MphToKmh(KmhToMph(value)), 1e-9)
+		assert.InDelta(t, value, InHgToHpa(HpaToInHg(value)), 1e-9)
+	}
+}
@@ -0,0 +1,78 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Failure classes of provider requests, matched with errors.Is. A rate limited
+// request fails with a *RateLimitError instead, matched with errors.As.
+var (
+	ErrNotFound     = errors.New("location not found")
+	ErrUnauthorized = errors.New("API key rejected by weather provider")
+	ErrUnavailable  = errors.New("weather provider unavailable")
+	ErrTimeout      = errors.New("weather provider timed out")
+	ErrNoAirData    = errors.New("no air quality data")
+	ErrNoPollenData = errors.New("no pollen data")
+	ErrNoTideData   = errors.New("no tide data")
+)
+
+// DefaultRetryAfter is assumed when a rate limited response does not say how
+// long to wait
+const DefaultRetryAfter = time.Minute
+
+// RateLimitError reports a request the provider turned down for exceeding the
+// rate limit
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited by weather provider, retry after %s", e.RetryAfter)
+}
+
+// RequestError classifies the error of a request that got no response
+func RequestError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: failed to make request: %v", ErrTimeout, err)
+	}
+	return fmt.Errorf("%w: failed to make request: %v", ErrUnavailable, err)
+}
+
+// StatusError classifies a response other than 200 OK
+func StatusError(resp *http.Response) error {
+	switch code := resp.StatusCode; {
+	case code == http.StatusTooManyRequests:
+		return &RateLimitError{RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	case code == http.StatusUnauthorized:
+		return fmt.Errorf("%w: API request failed with status: %d", ErrUnauthorized, code)
+	case code == http.StatusNotFound:
+		return fmt.Errorf("%w: API request failed with status: %d", ErrNotFound, code)
+	case code == http.StatusGatewayTimeout:
+		return fmt.Errorf("%w: API request failed with status: %d", ErrTimeout, code)
+	case code >= http.StatusInternalServerError:
+		return fmt.Errorf("%w: API request failed with status: %d", ErrUnavailable, code)
+	default:
+		return fmt.Errorf("API request failed with status: %d", code)
+	}
+}
+
+// parseRetryAfter reads a Retry-After header, given either in seconds or as
+// an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return DefaultRetryAfter
+}
@@ -0,0 +1,145 @@
+package weather
+
+import (
+	"math"
+	"strconv"
+)
+
+// Unit systems, matching the values stored in a user's units preference
+const (
+	UnitsMetric   = "metric"
+	UnitsImperial = "imperial"
+)
+
+// Display precision per quantity. Temperature, speed, visibility and the UV
+// index keep one decimal; humidity, AQI and metric pressure are whole numbers.
+const (
+	tempDecimals       = 1
+	speedDecimals      = 1
+	visibilityDecimals = 1
+	uvDecimals         = 1
+	percentDecimals    = 0
+	aqiDecimals        = 0
+	hpaDecimals        = 0
+	inHgDecimals       = 2
+	mmDecimals         = 1
+	inchDecimals       = 2
+	heightDecimals     = 1
+)
+
+// FormatDecimal rounds value half away from zero to the given number of
+// decimals. Negative values that round to zero keep their sign, so -0.04°C
+// reads "-0.0" rather than suggesting it is above freezing.
+func FormatDecimal(value float64, decimals int) string {
+	scale := math.Pow10(decimals)
+
+	// Trim the scaled value to 15 significant digits first so binary artifacts
+	// such as 2.675*100 = 267.49999999999997 round the way the decimal reads
+	scaled, err := strconv.ParseFloat(strconv.FormatFloat(value*scale, 'g', 15, 64), 64)
+	if err != nil {
+		scaled = value * scale
+	}
+	rounded := math.Round(scaled) / scale
+
+	if rounded == 0 {
+		if value < 0 {
+			return "-" + strconv.FormatFloat(0, 'f', decimals, 64)
+		}
+		// Drop the sign of a negative zero coming from the provider
+		rounded = 0
+	}
+	return strconv.FormatFloat(rounded, 'f', decimals, 64)
+}
+
+// FormatTemp formats a Celsius temperature in the given unit system, e.g. "-0.4°C" or "31.3°F"
+func FormatTemp(celsius float64, units string) string {
+	if units == UnitsImperial {
+		return FormatDecimal(CelsiusToFahrenheit(celsius), tempDecimals) + "°F"
+	}
+	return FormatDecimal(celsius, tempDecimals) + "°C"
+}
+
+// FormatSpeed formats a speed given in km/h, e.g. "10.0 km/h" or "6.2 mph"
+func FormatSpeed(kmh float64, units string) string {
+	if units == UnitsImperial {
+		return FormatDecimal(KmhToMph(kmh), speedDecimals) + " mph"
+	}
+	return FormatDecimal(kmh, speedDecimals) + " km/h"
+}
+
+// FormatPressure formats a pressure given in hPa, e.g. "1013 hPa" or "29.92 inHg"
+func FormatPressure(hpa float64, units string) string {
+	if units == UnitsImperial {
+		return FormatDecimal(HpaToInHg(hpa), inHgDecimals) + " inHg"
+	}
+	return FormatDecimal(hpa, hpaDecimals) + " hPa"
+}
+
+// FormatPercent formats a percentage such as relative humidity as a whole number, e.g. "65%"
+func FormatPercent(percent float64) string {
+	return FormatDecimal(percent, percentDecimals) + "%"
+}
+
+// FormatAQI formats an air quality index as a whole number
+func FormatAQI(aqi float64) string {
+	return FormatDecimal(aqi, aqiDecimals)
+}
+
+// FormatUV formats a UV index with one decimal, e.g. "6.5"
+func FormatUV(uv float64) string {
+	return FormatDecimal(uv, uvDecimals)
+}
+
+// FormatVisibility formats a visibility given in km, e.g. "10.0 km" or "6.2 mi"
+func FormatVisibility(km float64, units string) string {
+	if units == UnitsImperial {
+		return FormatDecimal(KmToMiles(km), visibilityDecimals) + " mi"
+	}
+	return FormatDecimal(km, visibilityDecimals) + " km"
+}
+
+// FormatPrecipitation formats an amount of rain or snow given in mm, e.g.
+// "1.2 mm" or "0.05 in"
+func FormatPrecipitation(mm float64, units string) string {
+	if units == UnitsImperial {
+		return FormatDecimal(MmToInches(mm), inchDecimals) + " in"
+	}
+	return FormatDecimal(mm, mmDecimals) + " mm"
+}
+
+// FormatHeight formats a height such as a tide given in metres, e.g. "1.8 m"
+// or "5.9 ft"
+func FormatHeight(metres float64, units string) string {
+	if units == UnitsImperial {
+		return FormatDecimal(MetresToFeet(metres), heightDecimals) + " ft"
+	}
+	return FormatDecimal(metres, heightDecimals) + " m"
+}
+
+// conditionEmoji maps the condition part of a provider icon code, e.g. "10"
+// of "10d", to an emoji
+var conditionEmoji = map[string]string{
+	"01": "☀️",
+	"02": "🌤️",
+	"03": "⛅",
+	"04": "☁️",
+	"09": "🌧️",
+	"10": "🌦️",
+	"11": "⛈️",
+	"13": "❄️",
+	"50": "🌫️",
+}
+
+// ConditionEmoji returns the emoji for a provider icon code such as "10d".
+// Clear night skies get a moon; unknown codes get a thermometer.
+func ConditionEmoji(icon string) string {
+	if icon == "01n" {
+		return "🌙"
+	}
+	if len(icon) >= 2 {
+		if emoji, ok := conditionEmoji[icon[:2]]; ok {
+			return emoji
+		}
+	}
+	return "🌡️"
+}
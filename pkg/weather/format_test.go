@@ -0,0 +1,95 @@
+package weather
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDecimal(t *testing.T) {
+	tests := []struct {
+		value    float64
+		decimals int
+		want     string
+	}{
+		{-0.4, 1, "-0.4"},
+		{-0.05, 1, "-0.1"},
+		{-0.04, 1, "-0.0"},
+		{0.049, 1, "0.0"},
+		{0.05, 1, "0.1"},
+		{99.95, 1, "100.0"},
+		{9.96, 1, "10.0"},
+		{2.675, 2, "2.68"},
+		{-2.5, 0, "-3"},
+		{2.5, 0, "3"},
+		{-0.4, 0, "-0"},
+		{0, 1, "0.0"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, FormatDecimal(tt.value, tt.decimals), "FormatDecimal(%v, %d)", tt.value, tt.decimals)
+	}
+}
+
+func TestFormatTemp(t *testing.T) {
+	tests := []struct {
+		celsius  float64
+		units    string
+		expected string
+	}{
+		{-0.4, UnitsMetric, "-0.4°C"},
+		{-0.05, UnitsMetric, "-0.1°C"},
+		{0.049, UnitsMetric, "0.0°C"},
+		{99.95, UnitsMetric, "100.0°C"},
+		{-0.4, UnitsImperial, "31.3°F"},
+		{-17.8, UnitsImperial, "-0.0°F"},
+		{-17.76, UnitsImperial, "0.0°F"},
+		{99.95, UnitsImperial, "211.9°F"},
+		{21.3, "", "21.3°C"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, FormatTemp(tt.celsius, tt.units), "FormatTemp(%v, %q)", tt.celsius, tt.units)
+	}
+}
+
+func TestFormatSpeed(t *testing.T) {
+	assert.Equal(t, "10.0 km/h", FormatSpeed(9.96, UnitsMetric))
+	assert.Equal(t, "0.0 km/h", FormatSpeed(0.049, UnitsMetric))
+	assert.Equal(t, "6.2 mph", FormatSpeed(9.96, UnitsImperial))
+	assert.Equal(t, "62.1 mph", FormatSpeed(99.95, UnitsImperial))
+}
+
+func TestFormatPressure(t *testing.T) {
+	assert.Equal(t, "1013 hPa", FormatPressure(1013.25, UnitsMetric))
+	assert.Equal(t, "1014 hPa", FormatPressure(1013.5, UnitsMetric))
+	assert.Equal(t, "29.92 inHg", FormatPressure(1013.25, UnitsImperial))
+}
+
+func TestFormatPercentAndAQI(t *testing.T) {
+	assert.Equal(t, "100%", FormatPercent(99.95))
+	assert.Equal(t, "0%", FormatPercent(0.049))
+	assert.Equal(t, "65%", FormatPercent(65))
+	assert.Equal(t, "3", FormatAQI(2.5))
+	assert.Equal(t, "150", FormatAQI(150))
+}
+
+func TestFormatUVAndVisibility(t *testing.T) {
+	assert.Equal(t, "0.5", FormatUV(0.49))
+	assert.Equal(t, "6.0", FormatUV(5.96))
+	assert.Equal(t, "10.0 km", FormatVisibility(10, UnitsMetric))
+	assert.Equal(t, "6.2 mi", FormatVisibility(10, UnitsImperial))
+}
+
+func TestFormatPrecipitation(t *testing.T) {
+	assert.Equal(t, "1.3 mm", FormatPrecipitation(1.25, UnitsMetric))
+	assert.Equal(t, "0.05 in", FormatPrecipitation(1.25, UnitsImperial))
+}
+
+func TestConditionEmoji(t *testing.T) {
+	assert.Equal(t, "☀️", ConditionEmoji("01d"))
+	assert.Equal(t, "🌙", ConditionEmoji("01n"))
+	assert.Equal(t, "🌦️", ConditionEmoji("10n"))
+	assert.Equal(t, "🌡️", ConditionEmoji("99d"))
+	assert.Equal(t, "🌡️", ConditionEmoji(""))
+}
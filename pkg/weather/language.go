@@ -0,0 +1,26 @@
+package weather
+
+import (
+	"context"
+	"strings"
+)
+
+type languageKey struct{}
+
+// WithLanguage asks providers to describe the weather requested with ctx in
+// a language, given as a tag like "uk-UA". Requests without one are answered
+// in English.
+func WithLanguage(ctx context.Context, language string) context.Context {
+	return context.WithValue(ctx, languageKey{}, language)
+}
+
+// LanguageFrom returns the provider language code requested with ctx, like
+// "uk" for "uk-UA", or "" for English, which providers answer in by default
+func LanguageFrom(ctx context.Context) string {
+	language, _ := ctx.Value(languageKey{}).(string)
+	code, _, _ := strings.Cut(strings.ToLower(language), "-")
+	if code == "en" {
+		return ""
+	}
+	return code
+}
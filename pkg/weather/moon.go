@@ -0,0 +1,132 @@
+package weather
+
+import (
+	"math"
+	"time"
+)
+
+// Names of the eight moon phases, as MoonPhaseName returns them
+const (
+	MoonNew            = "new"
+	MoonWaxingCrescent = "waxing_crescent"
+	MoonFirstQuarter   = "first_quarter"
+	MoonWaxingGibbous  = "waxing_gibbous"
+	MoonFull           = "full"
+	MoonWaningGibbous  = "waning_gibbous"
+	MoonLastQuarter    = "last_quarter"
+	MoonWaningCrescent = "waning_crescent"
+)
+
+// moonPhaseNames are the phases in the order of moonPhaseEmojis
+var moonPhaseNames = [8]string{
+	MoonNew, MoonWaxingCrescent, MoonFirstQuarter, MoonWaxingGibbous,
+	MoonFull, MoonWaningGibbous, MoonLastQuarter, MoonWaningCrescent,
+}
+
+const (
+	// moonriseAltitude is the altitude of the moon's centre, in degrees, at
+	// moonrise and moonset: its radius and refraction less its parallax
+	moonriseAltitude = 0.133
+
+	// moonTimesStep is how finely MoonTimes scans the day for the moon
+	// crossing the horizon
+	moonTimesStep = 10 * time.Minute
+)
+
+// MoonIllumination returns the lit fraction of the moon's disc, from 0 at new
+// moon to 1 at full moon, for a phase from MoonPhase
+func MoonIllumination(phase float64) float64 {
+	return (1 - math.Cos(2*math.Pi*phase)) / 2
+}
+
+// moonIlluminationOctant returns which of the eight phases the moon is in by
+// how much of it is lit: new and full within 3% of dark and fully lit,
+// quarters within 10% of half lit
+func moonIlluminationOctant(illumination float64, waxing bool) int {
+	var octant int
+	switch {
+	case illumination < 0.03:
+		return 0
+	case illumination > 0.97:
+		return 4
+	case illumination < 0.4:
+		octant = 1
+	case illumination <= 0.6:
+		octant = 2
+	default:
+		octant = 3
+	}
+	if !waxing {
+		octant = 8 - octant
+	}
+	return octant
+}
+
+// MoonPhaseName names the phase of a moon lit by illumination, one of the
+// Moon phase names
+func MoonPhaseName(illumination float64, waxing bool) string {
+	return moonPhaseNames[moonIlluminationOctant(illumination, waxing)]
+}
+
+// MoonEmoji returns the emoji of a moon lit by illumination
+func MoonEmoji(illumination float64, waxing bool) string {
+	return moonPhaseEmojis[moonIlluminationOctant(illumination, waxing)]
+}
+
+// NextMoonPhase returns the next time after from that the moon reaches a
+// phase from MoonPhase: 0 for new moon, 0.25 for first quarter, 0.5 for full
+// moon and 0.75 for last quarter
+func NextMoonPhase(from time.Time, phase float64) time.Time {
+	ahead := math.Mod(phase-MoonPhase(from)+1, 1)
+	if ahead == 0 {
+		ahead = 1
+	}
+	return from.Add(time.Duration(ahead * synodicMonth * 24 * float64(time.Hour)))
+}
+
+// MoonAltitude returns the altitude of the moon's centre above the horizon,
+// in degrees, at the coordinates at t. It is accurate to about a degree.
+func MoonAltitude(t time.Time, lat, lon float64) float64 {
+	const rad = math.Pi / 180
+	d := julianDate(t) - julian2000
+
+	meanLongitude := rad * (218.316 + 13.176396*d)
+	anomaly := rad * (134.963 + 13.064993*d)
+	distanceArgument := rad * (93.272 + 13.229350*d)
+	eclipticLongitude := meanLongitude + rad*6.289*math.Sin(anomaly)
+	eclipticLatitude := rad * 5.128 * math.Sin(distanceArgument)
+
+	obliquity := earthObliquity * rad
+	rightAscension := math.Atan2(math.Sin(eclipticLongitude)*math.Cos(obliquity)-math.Tan(eclipticLatitude)*math.Sin(obliquity), math.Cos(eclipticLongitude))
+	declination := math.Asin(math.Sin(eclipticLatitude)*math.Cos(obliquity) + math.Cos(eclipticLatitude)*math.Sin(obliquity)*math.Sin(eclipticLongitude))
+
+	hourAngle := rad*(280.16+360.9856235*d+lon) - rightAscension
+	latRad := lat * rad
+	return math.Asin(math.Sin(latRad)*math.Sin(declination)+math.Cos(latRad)*math.Cos(declination)*math.Cos(hourAngle)) / rad
+}
+
+// MoonTimes returns the moonrise and moonset, in UTC, at the coordinates in
+// the 24 hours from the midnight starting day in its location. Either is
+// zero when the moon does not rise or set in that time, which happens about
+// once a month and at high latitudes for days on end.
+func MoonTimes(day time.Time, lat, lon float64) (moonrise, moonset time.Time) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	end := start.AddDate(0, 0, 1)
+
+	prev := MoonAltitude(start, lat, lon) - moonriseAltitude
+	for t := start.Add(moonTimesStep); !t.After(end); t = t.Add(moonTimesStep) {
+		next := MoonAltitude(t, lat, lon) - moonriseAltitude
+		if (prev < 0) != (next < 0) {
+			// The moon moves little in a step, so a straight line finds the
+			// crossing to within a minute
+			crossing := t.Add(-time.Duration(next / (next - prev) * float64(moonTimesStep))).UTC().Round(time.Minute)
+			if prev < 0 && moonrise.IsZero() {
+				moonrise = crossing
+			} else if prev >= 0 && moonset.IsZero() {
+				moonset = crossing
+			}
+		}
+		prev = next
+	}
+	return moonrise, moonset
+}
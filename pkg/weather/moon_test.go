@@ -0,0 +1,83 @@
+package weather
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoonPhaseName(t *testing.T) {
+	tests := []struct {
+		illumination float64
+		waxing       bool
+		name         string
+		emoji        string
+	}{
+		{0.01, true, MoonNew, "🌑"},
+		{0.01, false, MoonNew, "🌑"},
+		{0.2, true, MoonWaxingCrescent, "🌒"},
+		{0.5, true, MoonFirstQuarter, "🌓"},
+		{0.8, true, MoonWaxingGibbous, "🌔"},
+		{0.99, true, MoonFull, "🌕"},
+		{0.8, false, MoonWaningGibbous, "🌖"},
+		{0.45, false, MoonLastQuarter, "🌗"},
+		{0.2, false, MoonWaningCrescent, "🌘"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.name, MoonPhaseName(tt.illumination, tt.waxing))
+		assert.Equal(t, tt.emoji, MoonEmoji(tt.illumination, tt.waxing))
+	}
+}
+
+func TestMoonIllumination(t *testing.T) {
+	assert.InDelta(t, 0, MoonIllumination(0), 1e-9)
+	assert.InDelta(t, 0.5, MoonIllumination(0.25), 1e-9)
+	assert.InDelta(t, 1, MoonIllumination(0.5), 1e-9)
+	assert.InDelta(t, 0.5, MoonIllumination(0.75), 1e-9)
+}
+
+func TestNextMoonPhase(t *testing.T) {
+	from := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	full := NextMoonPhase(from, 0.5)
+	assert.WithinDuration(t, time.Date(2024, 1, 25, 17, 54, 0, 0, time.UTC), full, 15*time.Hour)
+	assert.InDelta(t, 0.5, MoonPhase(full), 1e-6)
+
+	// A phase reached right now is a cycle away
+	assert.WithinDuration(t, full.Add(time.Duration(synodicMonth*24*float64(time.Hour))), NextMoonPhase(full, 0.5), time.Minute)
+}
+
+func TestMoonTimes(t *testing.T) {
+	london := [2]float64{51.5074, -0.1278}
+
+	t.Run("the full moon rises around sunset", func(t *testing.T) {
+		day := time.Date(2024, 1, 25, 0, 0, 0, 0, time.UTC)
+		moonrise, moonset := MoonTimes(day, london[0], london[1])
+		sunrise, sunset := SunTimes(day, london[0], london[1])
+
+		assert.WithinDuration(t, sunset, moonrise, time.Hour)
+		assert.WithinDuration(t, sunrise, moonset, time.Hour)
+		assert.InDelta(t, moonriseAltitude, MoonAltitude(moonrise, london[0], london[1]), 0.3)
+		assert.Greater(t, MoonAltitude(moonrise.Add(time.Hour), london[0], london[1]), MoonAltitude(moonrise, london[0], london[1]))
+	})
+
+	t.Run("the new moon rises around sunrise", func(t *testing.T) {
+		day := time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC)
+		moonrise, moonset := MoonTimes(day, london[0], london[1])
+		sunrise, sunset := SunTimes(day, london[0], london[1])
+
+		assert.WithinDuration(t, sunrise, moonrise, time.Hour)
+		assert.WithinDuration(t, sunset, moonset, time.Hour)
+	})
+
+	t.Run("a day without a moonset", func(t *testing.T) {
+		// The moon set at 23:06 local the day before and sets again after
+		// midnight
+		kyiv := time.FixedZone("EEST", 3*60*60)
+		moonrise, moonset := MoonTimes(time.Date(2026, 10, 19, 0, 0, 0, 0, kyiv), 50.4501, 30.5234)
+		assert.False(t, moonrise.IsZero())
+		assert.True(t, moonset.IsZero())
+	})
+}
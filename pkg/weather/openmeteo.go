@@ -0,0 +1,513 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Variables requested from the Open-Meteo forecast API for each kind of request
+const (
+	openMeteoCurrentVariables = "temperature_2m,apparent_temperature,relative_humidity_2m,pressure_msl," +
+		"wind_speed_10m,wind_direction_10m,visibility,precipitation,weather_code,is_day"
+	openMeteoDailyVariables      = "weather_code,temperature_2m_max,temperature_2m_min,precipitation_probability_max,sunrise,sunset"
+	openMeteoHourlyVariables     = "temperature_2m,wind_speed_10m,weather_code,is_day,precipitation_probability,cloud_cover"
+	openMeteoAirQualityVariables = "us_aqi,pm10,pm2_5,carbon_monoxide,nitrogen_dioxide,ozone"
+)
+
+// OpenMeteo serves weather, air quality and places from the Open-Meteo APIs,
+// which need no key. It has no reverse geocoding.
+type OpenMeteo struct {
+	forecastURL    string
+	airQualityURL  string
+	geocodingURL   string
+	httpClient     *http.Client
+	onUnknownField UnknownFieldHandler
+}
+
+// NewOpenMeteo creates an Open-Meteo provider
+func NewOpenMeteo() *OpenMeteo {
+	return &OpenMeteo{
+		forecastURL:   "https://api.open-meteo.com",
+		airQualityURL: "https://air-quality-api.open-meteo.com",
+		geocodingURL:  "https://geocoding-api.open-meteo.com",
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// SetUnknownFieldHandler registers a callback for response fields that are not
+// part of the known provider schema
+func (p *OpenMeteo) SetUnknownFieldHandler(handler UnknownFieldHandler) {
+	p.onUnknownField = handler
+}
+
+// Name identifies the provider in logs and metrics
+func (p *OpenMeteo) Name() string {
+	return ProviderOpenMeteo
+}
+
+// GetCurrentWeather retrieves current weather for a location
+func (p *OpenMeteo) GetCurrentWeather(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	requestURL := fmt.Sprintf("%s/v1/forecast?latitude=%.6f&longitude=%.6f&current=%s&daily=sunrise,sunset&forecast_days=1&timezone=auto&timeformat=unixtime",
+		p.forecastURL, lat, lon, openMeteoCurrentVariables)
+
+	body, err := p.fetch(ctx, requestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeOpenMeteoCurrent(body, p.onUnknownField)
+}
+
+// GetForecast retrieves the daily forecast for a location, starting today
+func (p *OpenMeteo) GetForecast(ctx context.Context, lat, lon float64, days int) (*ForecastData, error) {
+	requestURL := fmt.Sprintf("%s/v1/forecast?latitude=%.6f&longitude=%.6f&daily=%s&forecast_days=%d&timezone=auto&timeformat=unixtime",
+		p.forecastURL, lat, lon, openMeteoDailyVariables, min(max(days, 1), 16))
+
+	body, err := p.fetch(ctx, requestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeOpenMeteoForecast(body, days, p.onUnknownField)
+}
+
+// GetHourlyForecast retrieves 3-hour forecast slots covering the next hours
+// for a location, built from the hourly forecast
+func (p *OpenMeteo) GetHourlyForecast(ctx context.Context, lat, lon float64, hours int) (*HourlyForecastData, error) {
+	requestURL := fmt.Sprintf("%s/v1/forecast?latitude=%.6f&longitude=%.6f&hourly=%s&forecast_days=%d&timezone=auto&timeformat=unixtime",
+		p.forecastURL, lat, lon, openMeteoHourlyVariables, min(max(hours, 0)/24+2, 16))
+
+	body, err := p.fetch(ctx, requestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeOpenMeteoHourly(body, hours, time.Now(), p.onUnknownField)
+}
+
+// GetAirQuality retrieves air quality data for a location
+func (p *OpenMeteo) GetAirQuality(ctx context.Context, lat, lon float64) (*AirQualityData, error) {
+	requestURL := fmt.Sprintf("%s/v1/air-quality?latitude=%.6f&longitude=%.6f&current=%s&timeformat=unixtime",
+		p.airQualityURL, lat, lon, openMeteoAirQualityVariables)
+
+	body, err := p.fetch(ctx, requestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeOpenMeteoAirQuality(body, p.onUnknownField)
+}
+
+// GeocodeLocation converts location name to coordinates
+func (p *OpenMeteo) GeocodeLocation(ctx context.Context, locationName string) (*Location, error) {
+	locations, err := p.SearchLocations(ctx, locationName, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(locations) == 0 {
+		return nil, ErrNotFound
+	}
+	return &locations[0], nil
+}
+
+// SearchLocations returns up to limit places matching the name, best match first.
+// Unlike GeocodeLocation, an empty result is not an error.
+func (p *OpenMeteo) SearchLocations(ctx context.Context, locationName string, limit int) ([]Location, error) {
+	requestURL := fmt.Sprintf("%s/v1/search?name=%s&count=%d&format=json",
+		p.geocodingURL, url.QueryEscape(locationName), limit)
+
+	body, err := p.fetch(ctx, requestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeOpenMeteoGeocoding(body, p.onUnknownField)
+}
+
+// ReverseLocations is not offered by Open-Meteo
+func (p *OpenMeteo) ReverseLocations(ctx context.Context, lat, lon float64, limit int) ([]Location, error) {
+	return nil, fmt.Errorf("open-meteo reverse geocoding: %w", ErrNotSupported)
+}
+
+// fetch performs a GET request and returns the body of a successful response
+func (p *OpenMeteo) fetch(ctx context.Context, requestURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.httpClient.Do(req) // #nosec G704
+	if err != nil {
+		return nil, RequestError(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, StatusError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return body, nil
+}
+
+// wmoCondition is the OpenWeather description and icon, without the day or
+// night suffix, closest to a WMO weather code
+type wmoCondition struct {
+	description string
+	icon        string
+}
+
+// wmoConditions maps the WMO weather codes Open-Meteo reports to OpenWeather
+// conditions, so that weather renders the same whichever provider served it
+var wmoConditions = map[int]wmoCondition{
+	0:  {"clear sky", "01"},
+	1:  {"few clouds", "02"},
+	2:  {"scattered clouds", "03"},
+	3:  {"overcast clouds", "04"},
+	45: {"fog", "50"},
+	48: {"fog", "50"},
+	51: {"light intensity drizzle", "09"},
+	53: {"drizzle", "09"},
+	55: {"heavy intensity drizzle", "09"},
+	56: {"freezing rain", "13"},
+	57: {"freezing rain", "13"},
+	61: {"light rain", "10"},
+	63: {"moderate rain", "10"},
+	65: {"heavy intensity rain", "10"},
+	66: {"freezing rain", "13"},
+	67: {"freezing rain", "13"},
+	71: {"light snow", "13"},
+	73: {"snow", "13"},
+	75: {"heavy snow", "13"},
+	77: {"snow", "13"},
+	80: {"light intensity shower rain", "09"},
+	81: {"shower rain", "09"},
+	82: {"heavy intensity shower rain", "09"},
+	85: {"light shower snow", "13"},
+	86: {"heavy shower snow", "13"},
+	95: {"thunderstorm", "11"},
+	96: {"thunderstorm with rain", "11"},
+	99: {"thunderstorm with heavy rain", "11"},
+}
+
+// openMeteoCondition returns the description and icon of a WMO weather code.
+// An unknown code keeps both empty.
+func openMeteoCondition(code int, isDay bool) (string, string) {
+	condition, ok := wmoConditions[code]
+	if !ok {
+		return "", ""
+	}
+	if isDay {
+		return condition.description, condition.icon + "d"
+	}
+	return condition.description, condition.icon + "n"
+}
+
+type openMeteoForecastPayload struct {
+	Latitude         float64 `json:"latitude"`
+	Longitude        float64 `json:"longitude"`
+	UTCOffsetSeconds int     `json:"utc_offset_seconds"`
+	Current          *struct {
+		Temperature         *float64 `json:"temperature_2m"`
+		ApparentTemperature *float64 `json:"apparent_temperature"`
+		Humidity            int      `json:"relative_humidity_2m"`
+		Pressure            float64  `json:"pressure_msl"`
+		WindSpeed           float64  `json:"wind_speed_10m"`
+		WindDirection       int      `json:"wind_direction_10m"`
+		Visibility          float64  `json:"visibility"`
+		Precipitation       float64  `json:"precipitation"`
+		WeatherCode         *int     `json:"weather_code"`
+		IsDay               int      `json:"is_day"`
+	} `json:"current"`
+	Hourly struct {
+		Time                     []int64    `json:"time"`
+		Temperature              []*float64 `json:"temperature_2m"`
+		WindSpeed                []float64  `json:"wind_speed_10m"`
+		WeatherCode              []*int     `json:"weather_code"`
+		IsDay                    []int      `json:"is_day"`
+		PrecipitationProbability []float64  `json:"precipitation_probability"`
+		CloudCover               []int      `json:"cloud_cover"`
+	} `json:"hourly"`
+	Daily struct {
+		Time                        []int64    `json:"time"`
+		WeatherCode                 []*int     `json:"weather_code"`
+		TemperatureMax              []*float64 `json:"temperature_2m_max"`
+		TemperatureMin              []*float64 `json:"temperature_2m_min"`
+		PrecipitationProbabilityMax []float64  `json:"precipitation_probability_max"`
+		Sunrise                     []int64    `json:"sunrise"`
+		Sunset                      []int64    `json:"sunset"`
+	} `json:"daily"`
+}
+
+// location names the place of a forecast by its coordinates, since Open-Meteo
+// does not know place names
+func (p *openMeteoForecastPayload) location() string {
+	return fmt.Sprintf("%.2f, %.2f", p.Latitude, p.Longitude)
+}
+
+// parseOpenMeteoForecast parses an Open-Meteo /v1/forecast response
+func parseOpenMeteoForecast(data []byte, report UnknownFieldHandler) (*openMeteoForecastPayload, error) {
+	if err := checkObjectFields(EndpointOpenMeteoForecast, data, report); err != nil {
+		return nil, err
+	}
+
+	var payload openMeteoForecastPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &payload, nil
+}
+
+// requireSeries validates that a series has a value for every time step
+func requireSeries[T any](endpoint, field string, series []*T, steps int) error {
+	if len(series) < steps {
+		return &SchemaError{Endpoint: endpoint, Field: field}
+	}
+	for i, value := range series[:steps] {
+		if value == nil {
+			return &SchemaError{Endpoint: endpoint, Field: fmt.Sprintf("%s[%d]", field, i)}
+		}
+	}
+	return nil
+}
+
+// valueAt returns the value of an optional series at a time step, or zero
+func valueAt[T any](series []T, i int) T {
+	var zero T
+	if i < len(series) {
+		return series[i]
+	}
+	return zero
+}
+
+// decodeOpenMeteoCurrent parses an Open-Meteo /v1/forecast response with the
+// current variables and today's sunrise and sunset
+func decodeOpenMeteoCurrent(data []byte, report UnknownFieldHandler) (*WeatherData, error) {
+	payload, err := parseOpenMeteoForecast(data, report)
+	if err != nil {
+		return nil, err
+	}
+
+	current := payload.Current
+	if current == nil || current.Temperature == nil {
+		return nil, &SchemaError{Endpoint: EndpointOpenMeteoForecast, Field: "current.temperature_2m"}
+	}
+	if current.WeatherCode == nil {
+		return nil, &SchemaError{Endpoint: EndpointOpenMeteoForecast, Field: "current.weather_code"}
+	}
+
+	feelsLike := *current.Temperature
+	if current.ApparentTemperature != nil {
+		feelsLike = *current.ApparentTemperature
+	}
+	description, icon := openMeteoCondition(*current.WeatherCode, current.IsDay == 1)
+
+	return &WeatherData{
+		Temperature:   *current.Temperature,
+		FeelsLike:     feelsLike,
+		Humidity:      current.Humidity,
+		Pressure:      current.Pressure,
+		WindSpeed:     current.WindSpeed,
+		WindDirection: current.WindDirection,
+		Visibility:    current.Visibility / 1000, // Convert m to km
+		Description:   description,
+		Icon:          icon,
+		Sunrise:       unixTime(valueAt(payload.Daily.Sunrise, 0)),
+		Sunset:        unixTime(valueAt(payload.Daily.Sunset, 0)),
+		UTCOffset:     payload.UTCOffsetSeconds,
+		Precipitation: current.Precipitation,
+		Timestamp:     time.Now(),
+	}, nil
+}
+
+// decodeOpenMeteoForecast parses an Open-Meteo /v1/forecast response with the
+// daily variables, keeping the first days days
+func decodeOpenMeteoForecast(data []byte, days int, report UnknownFieldHandler) (*ForecastData, error) {
+	payload, err := parseOpenMeteoForecast(data, report)
+	if err != nil {
+		return nil, err
+	}
+
+	daily := payload.Daily
+	steps := min(max(days, 0), len(daily.Time))
+	if err := requireSeries(EndpointOpenMeteoForecast, "daily.temperature_2m_max", daily.TemperatureMax, steps); err != nil {
+		return nil, err
+	}
+	if err := requireSeries(EndpointOpenMeteoForecast, "daily.temperature_2m_min", daily.TemperatureMin, steps); err != nil {
+		return nil, err
+	}
+	if err := requireSeries(EndpointOpenMeteoForecast, "daily.weather_code", daily.WeatherCode, steps); err != nil {
+		return nil, err
+	}
+
+	forecast := &ForecastData{
+		Location:  payload.location(),
+		Forecasts: make([]DailyForecast, 0, steps),
+	}
+	offset := time.Duration(payload.UTCOffsetSeconds) * time.Second
+	for i := range steps {
+		description, icon := openMeteoCondition(*daily.WeatherCode[i], true)
+		forecast.Forecasts = append(forecast.Forecasts, DailyForecast{
+			// Days start at local midnight
+			Date:                localDate(time.Unix(daily.Time[i], 0), offset),
+			Sunrise:             unixTime(valueAt(daily.Sunrise, i)),
+			Sunset:              unixTime(valueAt(daily.Sunset, i)),
+			MinTemp:             *daily.TemperatureMin[i],
+			MaxTemp:             *daily.TemperatureMax[i],
+			Description:         description,
+			Icon:                icon,
+			PrecipitationChance: valueAt(daily.PrecipitationProbabilityMax, i) / 100,
+		})
+	}
+
+	return forecast, nil
+}
+
+// decodeOpenMeteoHourly parses an Open-Meteo /v1/forecast response with the
+// hourly variables into 3-hour slots starting with the hour containing now.
+// A slot takes the weather of its first hour and the highest chance of
+// precipitation of its hours.
+func decodeOpenMeteoHourly(data []byte, hours int, now time.Time, report UnknownFieldHandler) (*HourlyForecastData, error) {
+	payload, err := parseOpenMeteoForecast(data, report)
+	if err != nil {
+		return nil, err
+	}
+
+	hourly := payload.Hourly
+	steps := len(hourly.Time)
+	if err := requireSeries(EndpointOpenMeteoForecast, "hourly.temperature_2m", hourly.Temperature, steps); err != nil {
+		return nil, err
+	}
+	if err := requireSeries(EndpointOpenMeteoForecast, "hourly.weather_code", hourly.WeatherCode, steps); err != nil {
+		return nil, err
+	}
+
+	first := 0
+	for first < steps-1 && hourly.Time[first+1] <= now.Unix() {
+		first++
+	}
+
+	slots := max(0, (hours+HourlySlotHours-1)/HourlySlotHours)
+	forecast := &HourlyForecastData{
+		Location:  payload.location(),
+		UTCOffset: payload.UTCOffsetSeconds,
+		Slots:     make([]HourlyForecast, 0, slots),
+	}
+	for start := first; start < steps && len(forecast.Slots) < slots; start += HourlySlotHours {
+		chance := 0.0
+		for i := start; i < min(start+HourlySlotHours, steps); i++ {
+			chance = max(chance, valueAt(hourly.PrecipitationProbability, i))
+		}
+		description, icon := openMeteoCondition(*hourly.WeatherCode[start], valueAt(hourly.IsDay, start) == 1)
+		forecast.Slots = append(forecast.Slots, HourlyForecast{
+			Time:                time.Unix(hourly.Time[start], 0).UTC(),
+			Temperature:         *hourly.Temperature[start],
+			WindSpeed:           valueAt(hourly.WindSpeed, start),
+			Description:         description,
+			Icon:                icon,
+			PrecipitationChance: chance / 100,
+			Clouds:              valueAt(hourly.CloudCover, start),
+		})
+	}
+
+	return forecast, nil
+}
+
+type openMeteoAirQualityPayload struct {
+	Current *struct {
+		USAQI *float64 `json:"us_aqi"`
+		PM10  float64  `json:"pm10"`
+		PM25  float64  `json:"pm2_5"`
+		CO    float64  `json:"carbon_monoxide"`
+		NO2   float64  `json:"nitrogen_dioxide"`
+		Ozone float64  `json:"ozone"`
+	} `json:"current"`
+}
+
+// decodeOpenMeteoAirQuality parses an Open-Meteo /v1/air-quality response with
+// the current variables. Its index is already on the US EPA scale.
+func decodeOpenMeteoAirQuality(data []byte, report UnknownFieldHandler) (*AirQualityData, error) {
+	if err := checkObjectFields(EndpointOpenMeteoAirQuality, data, report); err != nil {
+		return nil, err
+	}
+
+	var payload openMeteoAirQualityPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if payload.Current == nil {
+		return nil, &SchemaError{Endpoint: EndpointOpenMeteoAirQuality, Field: "current"}
+	}
+	current := payload.Current
+	// The index is null where the model has no data
+	if current.USAQI == nil {
+		return nil, fmt.Errorf("%w available", ErrNoAirData)
+	}
+
+	return &AirQualityData{
+		AQI:       int(math.Round(*current.USAQI)),
+		CO:        current.CO,
+		NO2:       current.NO2,
+		O3:        current.Ozone,
+		PM25:      current.PM25,
+		PM10:      current.PM10,
+		Timestamp: time.Now(),
+	}, nil
+}
+
+type openMeteoGeocodingPayload struct {
+	Results []struct {
+		Name        string   `json:"name"`
+		Latitude    *float64 `json:"latitude"`
+		Longitude   *float64 `json:"longitude"`
+		CountryCode string   `json:"country_code"`
+		Admin1      string   `json:"admin1"`
+	} `json:"results"`
+}
+
+// decodeOpenMeteoGeocoding parses an Open-Meteo /v1/search response, keeping
+// the provider's ordering. Without a match the results are left out.
+func decodeOpenMeteoGeocoding(data []byte, report UnknownFieldHandler) ([]Location, error) {
+	if err := checkObjectFields(EndpointOpenMeteoGeocoding, data, report); err != nil {
+		return nil, err
+	}
+
+	var payload openMeteoGeocodingPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	locations := make([]Location, 0, len(payload.Results))
+	for i, result := range payload.Results {
+		if result.Latitude == nil {
+			return nil, &SchemaError{Endpoint: EndpointOpenMeteoGeocoding, Field: fmt.Sprintf("results[%d].latitude", i)}
+		}
+		if result.Longitude == nil {
+			return nil, &SchemaError{Endpoint: EndpointOpenMeteoGeocoding, Field: fmt.Sprintf("results[%d].longitude", i)}
+		}
+
+		locations = append(locations, Location{
+			Latitude:  *result.Latitude,
+			Longitude: *result.Longitude,
+			Name:      result.Name,
+			Country:   result.CountryCode,
+			City:      result.Name,
+			State:     result.Admin1,
+		})
+	}
+
+	return locations, nil
+}
@@ -0,0 +1,82 @@
+package weather
+
+import "time"
+
+// PollenForecastDays is how many days after today the pollen forecast covers
+const PollenForecastDays = 3
+
+// MaxPollenRisk is the highest pollen risk level, very high
+const MaxPollenRisk = 5
+
+// Pollen groups
+const (
+	PollenTree  = "tree"
+	PollenGrass = "grass"
+	PollenWeed  = "weed"
+)
+
+// pollenRiskThresholds are the counts in grains/m³ from which each group
+// reaches risk levels 1 to 5. They follow the scale of the US National
+// Allergy Bureau, with its "high" band split in two.
+var pollenRiskThresholds = map[string][MaxPollenRisk]float64{
+	PollenTree:  {1, 15, 90, 500, 1500},
+	PollenGrass: {1, 5, 20, 100, 200},
+	PollenWeed:  {1, 10, 50, 200, 500},
+}
+
+// PollenReading is the pollen count of a group and its risk level, from 0,
+// none, to 5, very high
+type PollenReading struct {
+	Count float64 `json:"count"` // grains/m³
+	Risk  int     `json:"risk"`
+}
+
+// PollenDay is the highest pollen count of each group during a day
+type PollenDay struct {
+	Date  time.Time     `json:"date"` // Midnight of the local day, in UTC
+	Tree  PollenReading `json:"tree"`
+	Grass PollenReading `json:"grass"`
+	Weed  PollenReading `json:"weed"`
+}
+
+// PollenData is the pollen count of a place at the current hour and the
+// forecast of the days ahead
+type PollenData struct {
+	Tree      PollenReading `json:"tree"`
+	Grass     PollenReading `json:"grass"`
+	Weed      PollenReading `json:"weed"`
+	Days      []PollenDay   `json:"days"`       // Up to PollenForecastDays days after today
+	UTCOffset int           `json:"utc_offset"` // Seconds east of UTC at the location
+	Timestamp time.Time     `json:"timestamp"`  // UTC start of the current hour
+}
+
+// MaxRisk is the highest risk level among the groups
+func (p *PollenData) MaxRisk() int {
+	return max(p.Tree.Risk, p.Grass.Risk, p.Weed.Risk)
+}
+
+// MaxRisk is the highest risk level among the groups during the day
+func (d PollenDay) MaxRisk() int {
+	return max(d.Tree.Risk, d.Grass.Risk, d.Weed.Risk)
+}
+
+// PollenRisk returns the risk level, 0 to 5, of a pollen count of the group
+func PollenRisk(group string, count float64) int {
+	thresholds, ok := pollenRiskThresholds[group]
+	if !ok {
+		return 0
+	}
+	risk := 0
+	for _, threshold := range thresholds {
+		if count < threshold {
+			break
+		}
+		risk++
+	}
+	return risk
+}
+
+// newPollenReading rates a pollen count of the group
+func newPollenReading(group string, count float64) PollenReading {
+	return PollenReading{Count: count, Risk: PollenRisk(group, count)}
+}
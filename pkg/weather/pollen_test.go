@@ -0,0 +1,60 @@
+package weather
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollenRisk(t *testing.T) {
+	tests := []struct {
+		group string
+		count float64
+		risk  int
+	}{
+		{PollenTree, 0, 0},
+		{PollenTree, 14.9, 1},
+		{PollenTree, 15, 2},
+		{PollenTree, 499, 3},
+		{PollenTree, 1500, 5},
+		{PollenGrass, 4, 1},
+		{PollenGrass, 20, 3},
+		{PollenGrass, 150, 4},
+		{PollenWeed, 50, 3},
+		{PollenWeed, 5000, MaxPollenRisk},
+		{"mould", 1000, 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.risk, PollenRisk(tt.group, tt.count), "%s %.1f", tt.group, tt.count)
+	}
+}
+
+func TestDecodePollen(t *testing.T) {
+	response := `{"utc_offset_seconds": 3600, "hourly": {
+		"time": [1776290400, 1776294000, 1776380400],
+		"alder_pollen": [1, 2, 3], "birch_pollen": [10, 20, 300], "olive_pollen": [null, null, null],
+		"grass_pollen": [0, 30, 5], "mugwort_pollen": [0, 0, 0], "ragweed_pollen": [0, 0, 0]}}`
+
+	t.Run("current hour and following days", func(t *testing.T) {
+		// 00:10 local, in the second hour of the response; the third is the next day
+		pollen, err := decodePollen([]byte(response), time.Date(2026, 4, 15, 23, 10, 0, 0, time.UTC), nil)
+		require.NoError(t, err)
+		assert.Equal(t, PollenReading{Count: 22, Risk: 2}, pollen.Tree)
+		assert.Equal(t, PollenReading{Count: 30, Risk: 3}, pollen.Grass)
+		assert.Equal(t, 3, pollen.MaxRisk())
+		require.Len(t, pollen.Days, 1)
+		assert.Equal(t, time.Date(2026, 4, 17, 0, 0, 0, 0, time.UTC), pollen.Days[0].Date)
+		assert.Equal(t, PollenReading{Count: 303, Risk: 3}, pollen.Days[0].Tree)
+		assert.Equal(t, PollenReading{Count: 5, Risk: 2}, pollen.Days[0].Grass)
+	})
+
+	t.Run("outside the covered region", func(t *testing.T) {
+		_, err := decodePollen([]byte(`{"hourly": {"time": [1776290400],
+			"alder_pollen": [null], "birch_pollen": [null], "olive_pollen": [null],
+			"grass_pollen": [null], "mugwort_pollen": [null], "ragweed_pollen": [null]}}`), pollenFixtureNow, nil)
+		assert.True(t, errors.Is(err, ErrNoPollenData))
+	})
+}
@@ -0,0 +1,69 @@
+package weather
+
+import (
+	"context"
+	"errors"
+)
+
+// Provider names, as used in the configured provider list, logs and metrics
+const (
+	ProviderOpenWeatherMap = "openweathermap"
+	ProviderOpenMeteo      = "openmeteo"
+)
+
+// ErrNotSupported is returned by a provider for a kind of request it cannot
+// answer at all, so that the next provider is asked instead
+var ErrNotSupported = errors.New("not supported by weather provider")
+
+// WeatherProvider is a source of weather data and place names. Temperatures
+// are in °C, speeds in km/h and the air quality index is on the US EPA scale,
+// whichever provider answers.
+type WeatherProvider interface {
+	// Name identifies the provider in logs and metrics
+	Name() string
+
+	GetCurrentWeather(ctx context.Context, lat, lon float64) (*WeatherData, error)
+	GetForecast(ctx context.Context, lat, lon float64, days int) (*ForecastData, error)
+	GetHourlyForecast(ctx context.Context, lat, lon float64, hours int) (*HourlyForecastData, error)
+	GetAirQuality(ctx context.Context, lat, lon float64) (*AirQualityData, error)
+
+	// GeocodeLocation returns the best match for a place name, or ErrNotFound
+	GeocodeLocation(ctx context.Context, locationName string) (*Location, error)
+	// SearchLocations returns up to limit matches for a place name
+	SearchLocations(ctx context.Context, locationName string, limit int) ([]Location, error)
+	// ReverseLocations returns up to limit named places around the coordinates
+	ReverseLocations(ctx context.Context, lat, lon float64, limit int) ([]Location, error)
+}
+
+// OpenWeatherMap serves weather from the OpenWeather API and places from its
+// geocoding API
+type OpenWeatherMap struct {
+	*Client
+	geocoder *GeocodingClient
+}
+
+// NewOpenWeatherMap creates an OpenWeather provider from its weather and
+// geocoding clients
+func NewOpenWeatherMap(client *Client, geocoder *GeocodingClient) *OpenWeatherMap {
+	return &OpenWeatherMap{Client: client, geocoder: geocoder}
+}
+
+// Name identifies the provider in logs and metrics
+func (p *OpenWeatherMap) Name() string {
+	return ProviderOpenWeatherMap
+}
+
+// GeocodeLocation converts a place name to coordinates
+func (p *OpenWeatherMap) GeocodeLocation(ctx context.Context, locationName string) (*Location, error) {
+	return p.geocoder.GeocodeLocation(ctx, locationName)
+}
+
+// SearchLocations returns up to limit places matching the name, best match first
+func (p *OpenWeatherMap) SearchLocations(ctx context.Context, locationName string, limit int) ([]Location, error) {
+	return p.geocoder.SearchLocations(ctx, locationName, limit)
+}
+
+// ReverseLocations returns up to limit named places around the coordinates
+func (p *OpenWeatherMap) ReverseLocations(ctx context.Context, lat, lon float64, limit int) ([]Location, error) {
+	return p.geocoder.ReverseLocations(ctx, lat, lon, limit)
+}
@@ -0,0 +1,634 @@
+package weather
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaVersion identifies the provider response layout the decoders below
+// expect. Bump it together with the golden files whenever a decoder changes
+// its output on purpose.
+const SchemaVersion = 7
+
+// Endpoint names used in schema errors and unknown field reports
+const (
+	EndpointCurrentWeather = "current_weather"
+	EndpointForecast       = "forecast"
+	EndpointAirPollution   = "air_pollution"
+	EndpointAirForecast    = "air_pollution_forecast"
+	EndpointGeocoding      = "geocoding"
+	EndpointPollen         = "pollen"
+	EndpointOneCall        = "onecall"
+
+	EndpointOpenMeteoForecast   = "openmeteo_forecast"
+	EndpointOpenMeteoAirQuality = "openmeteo_air_quality"
+	EndpointOpenMeteoGeocoding  = "openmeteo_geocoding"
+)
+
+// SchemaError reports a provider response that lacks a field we depend on.
+// Without it, a renamed upstream field would silently decode as zero.
+type SchemaError struct {
+	Endpoint string
+	Field    string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%s response is missing required field %q", e.Endpoint, e.Field)
+}
+
+// UnknownFieldHandler is called once per response for every top-level field
+// that is not part of the documented schema of the endpoint
+type UnknownFieldHandler func(endpoint, field string)
+
+// knownFields lists the documented top-level fields of each endpoint, including
+// the ones we do not decode. Anything else is reported as an upstream addition.
+var knownFields = map[string]map[string]bool{
+	EndpointCurrentWeather: fieldSet("coord", "weather", "base", "main", "visibility", "wind", "clouds",
+		"rain", "snow", "dt", "sys", "timezone", "id", "name", "cod"),
+	EndpointForecast:     fieldSet("cod", "message", "cnt", "list", "city"),
+	EndpointAirPollution: fieldSet("coord", "list"),
+	EndpointAirForecast:  fieldSet("coord", "list"),
+	EndpointGeocoding:    fieldSet("name", "local_names", "lat", "lon", "country", "state"),
+	EndpointOneCall: fieldSet("lat", "lon", "timezone", "timezone_offset", "current", "minutely", "hourly",
+		"daily", "alerts"),
+	EndpointPollen: fieldSet("latitude", "longitude", "generationtime_ms", "utc_offset_seconds", "timezone",
+		"timezone_abbreviation", "elevation", "hourly_units", "hourly"),
+	EndpointOpenMeteoForecast: fieldSet("latitude", "longitude", "generationtime_ms", "utc_offset_seconds", "timezone",
+		"timezone_abbreviation", "elevation", "current_units", "current", "hourly_units", "hourly", "daily_units", "daily"),
+	EndpointOpenMeteoAirQuality: fieldSet("latitude", "longitude", "generationtime_ms", "utc_offset_seconds", "timezone",
+		"timezone_abbreviation", "elevation", "current_units", "current"),
+	EndpointOpenMeteoGeocoding: fieldSet("results", "generationtime_ms"),
+}
+
+func fieldSet(fields ...string) map[string]bool {
+	set := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		set[field] = true
+	}
+	return set
+}
+
+// reportUnknownFields passes the unknown keys of the given objects to report,
+// each key at most once and in sorted order
+func reportUnknownFields(endpoint string, objects []map[string]json.RawMessage, report UnknownFieldHandler) {
+	if report == nil {
+		return
+	}
+
+	known := knownFields[endpoint]
+	seen := make(map[string]bool)
+	var unknown []string
+	for _, object := range objects {
+		for field := range object {
+			if !known[field] && !seen[field] {
+				seen[field] = true
+				unknown = append(unknown, field)
+			}
+		}
+	}
+
+	sort.Strings(unknown)
+	for _, field := range unknown {
+		report(endpoint, field)
+	}
+}
+
+func checkObjectFields(endpoint string, data []byte, report UnknownFieldHandler) error {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	reportUnknownFields(endpoint, []map[string]json.RawMessage{object}, report)
+	return nil
+}
+
+type conditionPayload struct {
+	ID          *int   `json:"id"`
+	Main        string `json:"main"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+}
+
+// requireCondition validates that the first weather condition carries an id,
+// which is the most reliable sign the condition block is still where we expect
+func requireCondition(endpoint, path string, conditions []conditionPayload) error {
+	if len(conditions) == 0 || conditions[0].ID == nil {
+		return &SchemaError{Endpoint: endpoint, Field: path + "weather[0].id"}
+	}
+	return nil
+}
+
+type currentWeatherPayload struct {
+	Main struct {
+		Temp      *float64 `json:"temp"`
+		FeelsLike *float64 `json:"feels_like"`
+		Humidity  int      `json:"humidity"`
+		Pressure  float64  `json:"pressure"`
+	} `json:"main"`
+	Wind struct {
+		Speed float64 `json:"speed"`
+		Deg   int     `json:"deg"`
+	} `json:"wind"`
+	Weather    []conditionPayload `json:"weather"`
+	Visibility int                `json:"visibility"`
+	Sys        struct {
+		Sunrise int64 `json:"sunrise"`
+		Sunset  int64 `json:"sunset"`
+	} `json:"sys"`
+	Timezone int `json:"timezone"`
+	Rain     struct {
+		OneHour float64 `json:"1h"`
+	} `json:"rain"`
+	Snow struct {
+		OneHour float64 `json:"1h"`
+	} `json:"snow"`
+}
+
+// decodeCurrentWeather parses a /data/2.5/weather response
+func decodeCurrentWeather(data []byte, report UnknownFieldHandler) (*WeatherData, error) {
+	if err := checkObjectFields(EndpointCurrentWeather, data, report); err != nil {
+		return nil, err
+	}
+
+	var payload currentWeatherPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if payload.Main.Temp == nil {
+		return nil, &SchemaError{Endpoint: EndpointCurrentWeather, Field: "main.temp"}
+	}
+	if err := requireCondition(EndpointCurrentWeather, "", payload.Weather); err != nil {
+		return nil, err
+	}
+
+	// The apparent temperature is optional; without it the air temperature is the best estimate
+	feelsLike := *payload.Main.Temp
+	if payload.Main.FeelsLike != nil {
+		feelsLike = *payload.Main.FeelsLike
+	}
+
+	return &WeatherData{
+		Temperature:   *payload.Main.Temp,
+		FeelsLike:     feelsLike,
+		Humidity:      payload.Main.Humidity,
+		Pressure:      payload.Main.Pressure,
+		WindSpeed:     payload.Wind.Speed * 3.6, // Convert m/s to km/h
+		WindDirection: payload.Wind.Deg,
+		Visibility:    float64(payload.Visibility) / 1000, // Convert m to km
+		Description:   payload.Weather[0].Description,
+		Icon:          payload.Weather[0].Icon,
+		Sunrise:       unixTime(payload.Sys.Sunrise),
+		Sunset:        unixTime(payload.Sys.Sunset),
+		UTCOffset:     payload.Timezone,
+		Precipitation: payload.Rain.OneHour + payload.Snow.OneHour,
+		Timestamp:     time.Now(),
+	}, nil
+}
+
+// unixTime converts a provider timestamp to UTC, keeping a missing one zero
+func unixTime(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0).UTC()
+}
+
+type forecastPayload struct {
+	List []struct {
+		Dt   int64 `json:"dt"`
+		Main struct {
+			Temp    *float64 `json:"temp"`
+			TempMin float64  `json:"temp_min"`
+			TempMax float64  `json:"temp_max"`
+		} `json:"main"`
+		Wind struct {
+			Speed float64 `json:"speed"`
+		} `json:"wind"`
+		Weather []conditionPayload `json:"weather"`
+		Pop     float64            `json:"pop"`
+		Clouds  struct {
+			All int `json:"all"`
+		} `json:"clouds"`
+	} `json:"list"`
+	City struct {
+		Name    string `json:"name"`
+		Country string `json:"country"`
+		Coord   struct {
+			Lat float64 `json:"lat"`
+			Lon float64 `json:"lon"`
+		} `json:"coord"`
+		Timezone int `json:"timezone"`
+	} `json:"city"`
+}
+
+// parseForecast parses and validates a /data/2.5/forecast response
+func parseForecast(data []byte, report UnknownFieldHandler) (*forecastPayload, error) {
+	if err := checkObjectFields(EndpointForecast, data, report); err != nil {
+		return nil, err
+	}
+
+	var payload forecastPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	for i, item := range payload.List {
+		path := fmt.Sprintf("list[%d].", i)
+		if item.Main.Temp == nil {
+			return nil, &SchemaError{Endpoint: EndpointForecast, Field: path + "main.temp"}
+		}
+		if err := requireCondition(EndpointForecast, path, item.Weather); err != nil {
+			return nil, err
+		}
+	}
+	return &payload, nil
+}
+
+// decodeForecast parses a /data/2.5/forecast response, keeping the first entry
+// of each of the first days calendar days. The response only has today's
+// sunrise and sunset, so those of every day are computed from the city's
+// coordinates.
+func decodeForecast(data []byte, days int, report UnknownFieldHandler) (*ForecastData, error) {
+	payload, err := parseForecast(data, report)
+	if err != nil {
+		return nil, err
+	}
+
+	forecast := &ForecastData{
+		Location:  fmt.Sprintf("%s, %s", payload.City.Name, payload.City.Country),
+		Forecasts: make([]DailyForecast, 0),
+	}
+
+	// Group forecasts by day and take the first 'days' entries; the chance of
+	// precipitation is the highest of all the day's entries
+	dayIndex := make(map[string]int)
+	for _, item := range payload.List {
+		date := time.Unix(item.Dt, 0).UTC().Truncate(24 * time.Hour)
+		dateKey := date.Format("2006-01-02")
+
+		if i, seen := dayIndex[dateKey]; seen {
+			forecast.Forecasts[i].PrecipitationChance = max(forecast.Forecasts[i].PrecipitationChance, item.Pop)
+			continue
+		}
+		if len(forecast.Forecasts) >= days {
+			break
+		}
+
+		dayIndex[dateKey] = len(forecast.Forecasts)
+		sunrise, sunset := SunTimes(date, payload.City.Coord.Lat, payload.City.Coord.Lon)
+		forecast.Forecasts = append(forecast.Forecasts, DailyForecast{
+			Date:                date,
+			Sunrise:             sunrise,
+			Sunset:              sunset,
+			MinTemp:             item.Main.TempMin,
+			MaxTemp:             item.Main.TempMax,
+			Description:         item.Weather[0].Description,
+			Icon:                item.Weather[0].Icon,
+			PrecipitationChance: item.Pop,
+		})
+	}
+
+	return forecast, nil
+}
+
+// decodeHourlyForecast parses a /data/2.5/forecast response, keeping the
+// 3-hour entries that cover the first hours of the forecast
+func decodeHourlyForecast(data []byte, hours int, report UnknownFieldHandler) (*HourlyForecastData, error) {
+	payload, err := parseForecast(data, report)
+	if err != nil {
+		return nil, err
+	}
+
+	slots := min(max(0, (hours+HourlySlotHours-1)/HourlySlotHours), len(payload.List))
+	forecast := &HourlyForecastData{
+		Location:  fmt.Sprintf("%s, %s", payload.City.Name, payload.City.Country),
+		UTCOffset: payload.City.Timezone,
+		Slots:     make([]HourlyForecast, 0, slots),
+	}
+	for _, item := range payload.List[:slots] {
+		forecast.Slots = append(forecast.Slots, HourlyForecast{
+			Time:                time.Unix(item.Dt, 0).UTC(),
+			Temperature:         *item.Main.Temp,
+			WindSpeed:           item.Wind.Speed * 3.6, // Convert m/s to km/h
+			Description:         item.Weather[0].Description,
+			Icon:                item.Weather[0].Icon,
+			PrecipitationChance: item.Pop,
+			Clouds:              item.Clouds.All,
+		})
+	}
+
+	return forecast, nil
+}
+
+type airPollutionPayload struct {
+	List []struct {
+		Dt   *int64 `json:"dt"`
+		Main struct {
+			AQI *int `json:"aqi"`
+		} `json:"main"`
+		Components struct {
+			CO   float64 `json:"co"`
+			NO2  float64 `json:"no2"`
+			O3   float64 `json:"o3"`
+			PM25 float64 `json:"pm2_5"`
+			PM10 float64 `json:"pm10"`
+		} `json:"components"`
+	} `json:"list"`
+}
+
+// decodeAirQuality parses a /data/2.5/air_pollution response
+func decodeAirQuality(data []byte, report UnknownFieldHandler) (*AirQualityData, error) {
+	if err := checkObjectFields(EndpointAirPollution, data, report); err != nil {
+		return nil, err
+	}
+
+	var payload airPollutionPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(payload.List) == 0 {
+		return nil, fmt.Errorf("%w available", ErrNoAirData)
+	}
+
+	item := payload.List[0]
+	if item.Main.AQI == nil {
+		return nil, &SchemaError{Endpoint: EndpointAirPollution, Field: "list[0].main.aqi"}
+	}
+
+	// OpenWeather's own index runs from 1 to 5; the US one is computed from
+	// the components
+	return &AirQualityData{
+		AQI:       USAQI(item.Components.PM25, item.Components.PM10, item.Components.O3, item.Components.NO2, item.Components.CO),
+		CO:        item.Components.CO,
+		NO2:       item.Components.NO2,
+		O3:        item.Components.O3,
+		PM25:      item.Components.PM25,
+		PM10:      item.Components.PM10,
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// decodeAirQualityForecast parses a /data/2.5/air_pollution/forecast
+// response, keeping the first hours of it
+func decodeAirQualityForecast(data []byte, hours int, report UnknownFieldHandler) (*AirQualityForecast, error) {
+	if err := checkObjectFields(EndpointAirForecast, data, report); err != nil {
+		return nil, err
+	}
+
+	var payload airPollutionPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(payload.List) == 0 {
+		return nil, fmt.Errorf("%w available", ErrNoAirData)
+	}
+
+	forecast := &AirQualityForecast{Hours: make([]AirQualityHour, 0, min(len(payload.List), hours))}
+	for i, item := range payload.List {
+		if len(forecast.Hours) == hours {
+			break
+		}
+		if item.Dt == nil {
+			return nil, &SchemaError{Endpoint: EndpointAirForecast, Field: fmt.Sprintf("list[%d].dt", i)}
+		}
+
+		components := item.Components
+		forecast.Hours = append(forecast.Hours, AirQualityHour{
+			Time: time.Unix(*item.Dt, 0).UTC(),
+			AQI:  USAQI(components.PM25, components.PM10, components.O3, components.NO2, components.CO),
+			CO:   components.CO,
+			NO2:  components.NO2,
+			O3:   components.O3,
+			PM25: components.PM25,
+			PM10: components.PM10,
+		})
+	}
+	return forecast, nil
+}
+
+type oneCallAlertsPayload struct {
+	Alerts []struct {
+		SenderName  string `json:"sender_name"`
+		Event       string `json:"event"`
+		Start       *int64 `json:"start"`
+		End         *int64 `json:"end"`
+		Description string `json:"description"`
+	} `json:"alerts"`
+}
+
+// decodeWarnings parses the alerts of a /data/3.0/onecall response. Places
+// without warnings have no alerts at all.
+func decodeWarnings(data []byte, report UnknownFieldHandler) ([]WeatherWarning, error) {
+	if err := checkObjectFields(EndpointOneCall, data, report); err != nil {
+		return nil, err
+	}
+
+	var payload oneCallAlertsPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	warnings := make([]WeatherWarning, 0, len(payload.Alerts))
+	for i, alert := range payload.Alerts {
+		switch {
+		case alert.Event == "":
+			return nil, &SchemaError{Endpoint: EndpointOneCall, Field: fmt.Sprintf("alerts[%d].event", i)}
+		case alert.Start == nil:
+			return nil, &SchemaError{Endpoint: EndpointOneCall, Field: fmt.Sprintf("alerts[%d].start", i)}
+		case alert.End == nil:
+			return nil, &SchemaError{Endpoint: EndpointOneCall, Field: fmt.Sprintf("alerts[%d].end", i)}
+		}
+
+		start := time.Unix(*alert.Start, 0).UTC()
+		warnings = append(warnings, WeatherWarning{
+			ID:          warningID(alert.SenderName, alert.Event, start),
+			Event:       alert.Event,
+			Sender:      alert.SenderName,
+			Severity:    WarningSeverityOf(alert.Event),
+			Start:       start,
+			End:         time.Unix(*alert.End, 0).UTC(),
+			Description: strings.TrimSpace(alert.Description),
+		})
+	}
+	return warnings, nil
+}
+
+// pollenSpecies are the hourly variables requested from the pollen provider
+// and the group each one counts towards
+var pollenSpecies = []struct{ variable, group string }{
+	{"alder_pollen", PollenTree},
+	{"birch_pollen", PollenTree},
+	{"olive_pollen", PollenTree},
+	{"grass_pollen", PollenGrass},
+	{"mugwort_pollen", PollenWeed},
+	{"ragweed_pollen", PollenWeed},
+}
+
+type pollenPayload struct {
+	UTCOffsetSeconds int                        `json:"utc_offset_seconds"`
+	Hourly           map[string]json.RawMessage `json:"hourly"`
+}
+
+// pollenHour is the pollen count of each group during an hour
+type pollenHour struct {
+	time   time.Time
+	counts map[string]float64
+}
+
+// decodePollen parses an Open-Meteo /v1/air-quality response with the hourly
+// pollen variables in unix time. The counts of the hour containing now are the
+// current ones; the days after today are rated by their highest counts.
+func decodePollen(data []byte, now time.Time, report UnknownFieldHandler) (*PollenData, error) {
+	if err := checkObjectFields(EndpointPollen, data, report); err != nil {
+		return nil, err
+	}
+
+	var payload pollenPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var times []int64
+	if raw, ok := payload.Hourly["time"]; !ok || json.Unmarshal(raw, &times) != nil {
+		return nil, &SchemaError{Endpoint: EndpointPollen, Field: "hourly.time"}
+	}
+
+	hours := make([]pollenHour, len(times))
+	for i, t := range times {
+		hours[i] = pollenHour{time: time.Unix(t, 0).UTC(), counts: map[string]float64{}}
+	}
+	// Outside the covered region or season every count is null
+	reported := false
+	for _, species := range pollenSpecies {
+		var counts []*float64
+		if raw, ok := payload.Hourly[species.variable]; !ok || json.Unmarshal(raw, &counts) != nil {
+			return nil, &SchemaError{Endpoint: EndpointPollen, Field: "hourly." + species.variable}
+		}
+		for i, count := range counts {
+			if i < len(hours) && count != nil {
+				hours[i].counts[species.group] += *count
+				reported = true
+			}
+		}
+	}
+	if !reported || len(hours) == 0 {
+		return nil, fmt.Errorf("%w available for this location", ErrNoPollenData)
+	}
+
+	current := hours[0]
+	for _, hour := range hours {
+		if hour.time.After(now) {
+			break
+		}
+		current = hour
+	}
+
+	pollen := &PollenData{
+		Tree:      newPollenReading(PollenTree, current.counts[PollenTree]),
+		Grass:     newPollenReading(PollenGrass, current.counts[PollenGrass]),
+		Weed:      newPollenReading(PollenWeed, current.counts[PollenWeed]),
+		UTCOffset: payload.UTCOffsetSeconds,
+		Timestamp: current.time,
+	}
+
+	offset := time.Duration(payload.UTCOffsetSeconds) * time.Second
+	today := localDate(now, offset)
+	for _, hour := range hours {
+		date := localDate(hour.time, offset)
+		if !date.After(today) {
+			continue
+		}
+		if len(pollen.Days) == 0 || !pollen.Days[len(pollen.Days)-1].Date.Equal(date) {
+			if len(pollen.Days) == PollenForecastDays {
+				break
+			}
+			pollen.Days = append(pollen.Days, PollenDay{Date: date})
+		}
+		day := &pollen.Days[len(pollen.Days)-1]
+		day.Tree = highestPollen(day.Tree, PollenTree, hour.counts[PollenTree])
+		day.Grass = highestPollen(day.Grass, PollenGrass, hour.counts[PollenGrass])
+		day.Weed = highestPollen(day.Weed, PollenWeed, hour.counts[PollenWeed])
+	}
+
+	return pollen, nil
+}
+
+// localDate is the calendar day of t at a UTC offset, as midnight UTC
+func localDate(t time.Time, offset time.Duration) time.Time {
+	local := t.UTC().Add(offset)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// highestPollen keeps the higher of a day's reading and an hour's count
+func highestPollen(reading PollenReading, group string, count float64) PollenReading {
+	if count <= reading.Count {
+		return reading
+	}
+	return newPollenReading(group, count)
+}
+
+type geocodingPayload []struct {
+	Name       string            `json:"name"`
+	Country    string            `json:"country"`
+	State      string            `json:"state"`
+	Lat        *float64          `json:"lat"`
+	Lon        *float64          `json:"lon"`
+	LocalNames map[string]string `json:"local_names"`
+}
+
+// decodeGeocoding parses a /geo/1.0/direct response and returns the best match
+func decodeGeocoding(data []byte, report UnknownFieldHandler) (*Location, error) {
+	locations, err := decodeGeocodingResults(data, report)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(locations) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &locations[0], nil
+}
+
+// decodeGeocodingResults parses a direct or reverse geocoding response, which
+// share the same layout, keeping the provider's ordering
+func decodeGeocodingResults(data []byte, report UnknownFieldHandler) ([]Location, error) {
+	var objects []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &objects); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	reportUnknownFields(EndpointGeocoding, objects, report)
+
+	var payload geocodingPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	locations := make([]Location, 0, len(payload))
+	for i, result := range payload {
+		if result.Lat == nil {
+			return nil, &SchemaError{Endpoint: EndpointGeocoding, Field: fmt.Sprintf("[%d].lat", i)}
+		}
+		if result.Lon == nil {
+			return nil, &SchemaError{Endpoint: EndpointGeocoding, Field: fmt.Sprintf("[%d].lon", i)}
+		}
+
+		locations = append(locations, Location{
+			Latitude:   *result.Lat,
+			Longitude:  *result.Lon,
+			Name:       result.Name,
+			Country:    result.Country,
+			City:       result.Name,
+			State:      result.State,
+			LocalNames: result.LocalNames,
+		})
+	}
+
+	return locations, nil
+}
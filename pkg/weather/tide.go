@@ -0,0 +1,171 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TideMaxDays is the most days of tides a request returns
+const TideMaxDays = 7
+
+// Tide types of a TidePoint
+const (
+	TideHigh = "high"
+	TideLow  = "low"
+)
+
+// TidePoint is a high or low tide
+type TidePoint struct {
+	Time   time.Time `json:"time"`   // UTC
+	Height float64   `json:"height"` // Metres above mean sea level
+	Type   string    `json:"type"`   // TideHigh or TideLow
+}
+
+// TideData is the predicted high and low tides of a place
+type TideData struct {
+	Points []TidePoint `json:"points"` // In time order
+	// Latitude and Longitude are where the predictions are for: the nearest
+	// point at sea to the requested place, or a tide station
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Station   string  `json:"station,omitempty"` // Tide station the predictions come from, if any
+}
+
+// Upcoming returns the tides at or after now
+func (d *TideData) Upcoming(now time.Time) []TidePoint {
+	for i, point := range d.Points {
+		if !point.Time.Before(now) {
+			return d.Points[i:]
+		}
+	}
+	return nil
+}
+
+// HighestTide returns the highest high tide from from until to, and false
+// when there is none in that time
+func (d *TideData) HighestTide(from, to time.Time) (TidePoint, bool) {
+	var highest TidePoint
+	found := false
+	for _, point := range d.Points {
+		if point.Type != TideHigh || point.Time.Before(from) || point.Time.After(to) {
+			continue
+		}
+		if !found || point.Height > highest.Height {
+			highest, found = point, true
+		}
+	}
+	return highest, found
+}
+
+// TideClient predicts tides with the WorldTides API
+type TideClient struct {
+	apiKey     string
+	baseURL    string
+	userAgent  string
+	httpClient *http.Client
+}
+
+// NewTideClient creates a WorldTides client
+func NewTideClient(apiKey string) *TideClient {
+	return &TideClient{
+		apiKey:  apiKey,
+		baseURL: "https://www.worldtides.info",
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// SetUserAgent sets the User-Agent sent with tide requests
+func (c *TideClient) SetUserAgent(userAgent string) {
+	c.userAgent = userAgent
+}
+
+// worldTidesResponse is the part of a WorldTides v3 answer the bot reads
+type worldTidesResponse struct {
+	Status      int     `json:"status"`
+	Error       string  `json:"error"`
+	ResponseLat float64 `json:"responseLat"`
+	ResponseLon float64 `json:"responseLon"`
+	Station     string  `json:"station"`
+	Extremes    []struct {
+		Dt     int64   `json:"dt"`
+		Height float64 `json:"height"`
+		Type   string  `json:"type"`
+	} `json:"extremes"`
+}
+
+// GetTidePredictions retrieves the high and low tides of a place from the
+// start of today, in its timezone, for days days up to TideMaxDays. A place on
+// land gets the tides of the nearest point at sea, however far; it fails with
+// ErrNoTideData when there are none at all.
+func (c *TideClient) GetTidePredictions(ctx context.Context, lat, lon float64, days int) (*TideData, error) {
+	days = min(max(days, 1), TideMaxDays)
+	// Users' own API keys are OpenWeather ones, so the client's key is used
+	requestURL := fmt.Sprintf("%s/api/v3?extremes&date=today&days=%d&datum=MSL&lat=%.6f&lon=%.6f&key=%s",
+		c.baseURL, days, lat, lon, url.QueryEscape(c.apiKey))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	resp, err := c.httpClient.Do(req) // #nosec G704
+	if err != nil {
+		return nil, RequestError(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, StatusError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return decodeTides(body)
+}
+
+// decodeTides reads a WorldTides answer, which also reports a failed request
+// in its status and error fields
+func decodeTides(body []byte) (*TideData, error) {
+	var response worldTidesResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if response.Status != 0 && response.Status != http.StatusOK {
+		return nil, fmt.Errorf("%w: tide request failed with status %d: %s", ErrUnavailable, response.Status, response.Error)
+	}
+	if len(response.Extremes) == 0 {
+		return nil, ErrNoTideData
+	}
+
+	data := &TideData{
+		Points:    make([]TidePoint, 0, len(response.Extremes)),
+		Latitude:  response.ResponseLat,
+		Longitude: response.ResponseLon,
+		Station:   response.Station,
+	}
+	for _, extreme := range response.Extremes {
+		tideType := TideLow
+		if strings.EqualFold(extreme.Type, "high") {
+			tideType = TideHigh
+		}
+		data.Points = append(data.Points, TidePoint{
+			Time:   time.Unix(extreme.Dt, 0).UTC(),
+			Height: extreme.Height,
+			Type:   tideType,
+		})
+	}
+	return data, nil
+}
@@ -0,0 +1,95 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTideClient_GetTidePredictions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v3", r.URL.Path)
+		assert.Contains(t, r.URL.Query(), "extremes")
+		assert.Equal(t, "2", r.URL.Query().Get("days"))
+		assert.Equal(t, "50.375000", r.URL.Query().Get("lat"))
+		assert.Equal(t, "tide_key", r.URL.Query().Get("key"))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":200,"callCount":1,"requestLat":50.375,"requestLon":-4.14,
+			"responseLat":50.333,"responseLon":-4.167,"atlas":"FES","station":"PLYMOUTH (DEVONPORT)",
+			"extremes":[
+				{"dt":1729058400,"date":"2024-10-16T06:00+0000","height":2.31,"type":"High"},
+				{"dt":1729080600,"date":"2024-10-16T12:10+0000","height":-2.05,"type":"Low"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewTideClient("tide_key")
+	client.baseURL = server.URL
+
+	// A user's own OpenWeather key is not sent to WorldTides
+	tides, err := client.GetTidePredictions(WithAPIKey(context.Background(), "user_key"), 50.375, -4.14, 2)
+	require.NoError(t, err)
+	require.Len(t, tides.Points, 2)
+	assert.Equal(t, TidePoint{Time: time.Date(2024, 10, 16, 6, 0, 0, 0, time.UTC), Height: 2.31, Type: TideHigh}, tides.Points[0])
+	assert.Equal(t, TideLow, tides.Points[1].Type)
+	assert.Equal(t, 50.333, tides.Latitude)
+	assert.Equal(t, "PLYMOUTH (DEVONPORT)", tides.Station)
+}
+
+func TestTideClient_GetTidePredictionsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"no tides", http.StatusOK, `{"status":200,"extremes":[]}`, ErrNoTideData},
+		{"rejected request", http.StatusOK, `{"status":400,"error":"Invalid key"}`, ErrUnavailable},
+		{"server error", http.StatusBadGateway, `{}`, ErrUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := NewTideClient("tide_key")
+			client.baseURL = server.URL
+			_, err := client.GetTidePredictions(context.Background(), 0, 0, 1)
+			assert.True(t, errors.Is(err, tt.want), "got %v", err)
+		})
+	}
+}
+
+func TestTideData(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2024, 10, 16, hour, 0, 0, 0, time.UTC) }
+	tides := &TideData{Points: []TidePoint{
+		{Time: at(0), Height: 1.9, Type: TideHigh},
+		{Time: at(6), Height: -1.8, Type: TideLow},
+		{Time: at(12), Height: 2.3, Type: TideHigh},
+		{Time: at(18), Height: -2.0, Type: TideLow},
+	}}
+
+	assert.Len(t, tides.Upcoming(at(6)), 3)
+	assert.Empty(t, tides.Upcoming(at(19)))
+
+	highest, ok := tides.HighestTide(at(0), at(23))
+	require.True(t, ok)
+	assert.Equal(t, 2.3, highest.Height)
+	highest, ok = tides.HighestTide(at(1), at(11))
+	assert.False(t, ok, "no high tide from 01:00 to 11:00, got %v", highest)
+}
+
+func TestFormatHeight(t *testing.T) {
+	assert.Equal(t, "1.8 m", FormatHeight(1.84, UnitsMetric))
+	assert.Equal(t, "6.0 ft", FormatHeight(1.84, UnitsImperial))
+	assert.Equal(t, "-0.3 m", FormatHeight(-0.3, UnitsMetric))
+}
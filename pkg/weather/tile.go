@@ -0,0 +1,164 @@
+package weather
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"net/http"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// TileSize is the width and height of one map tile in pixels
+	TileSize = 256
+
+	// DefaultMapZoom shows a few hundred kilometres around the user, enough
+	// to see rain coming
+	DefaultMapZoom = 7
+
+	// mapSpan is how many tiles across a weather map is; the tile with the
+	// location sits in the middle
+	mapSpan = 3
+)
+
+// MapLayer is a weather layer that can be drawn over the base map
+type MapLayer string
+
+// Map layers
+const (
+	MapLayerPrecipitation MapLayer = "precipitation"
+	MapLayerTemperature   MapLayer = "temperature"
+	MapLayerWind          MapLayer = "wind"
+)
+
+// MapLayers lists the available layers in the order they are offered
+var MapLayers = []MapLayer{MapLayerPrecipitation, MapLayerTemperature, MapLayerWind}
+
+// mapLayerPaths maps each layer to its OpenWeather tile layer
+var mapLayerPaths = map[MapLayer]string{
+	MapLayerPrecipitation: "precipitation_new",
+	MapLayerTemperature:   "temp_new",
+	MapLayerWind:          "wind_new",
+}
+
+// ErrUnknownMapLayer is returned for a layer name that is not one of MapLayers
+var ErrUnknownMapLayer = errors.New("unknown map layer")
+
+// ParseMapLayer returns the layer with the given name
+func ParseMapLayer(name string) (MapLayer, error) {
+	layer := MapLayer(name)
+	if _, ok := mapLayerPaths[layer]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownMapLayer, name)
+	}
+	return layer, nil
+}
+
+// TileCoordinates returns the x and y of the Web Mercator tile containing the
+// coordinates at the zoom level
+func TileCoordinates(lat, lon float64, zoom int) (x, y int) {
+	n := math.Exp2(float64(zoom))
+	latRad := lat * math.Pi / 180
+	x = int(math.Floor((lon + 180) / 360 * n))
+	y = int(math.Floor((1 - math.Log(math.Tan(latRad)+1/math.Cos(latRad))/math.Pi) / 2 * n))
+	maxTile := int(n) - 1
+	return min(max(x, 0), maxTile), min(max(y, 0), maxTile)
+}
+
+// GetPrecipitationTile renders a PNG map of the precipitation around the
+// coordinates
+func (c *Client) GetPrecipitationTile(ctx context.Context, lat, lon float64, zoom int) ([]byte, error) {
+	return c.GetWeatherMap(ctx, lat, lon, zoom, MapLayerPrecipitation)
+}
+
+// GetWeatherMap renders a PNG map of a weather layer around the coordinates:
+// the OpenWeather layer over OpenStreetMap tiles, mapSpan tiles across with
+// the location's tile in the middle
+func (c *Client) GetWeatherMap(ctx context.Context, lat, lon float64, zoom int, layer MapLayer) ([]byte, error) {
+	layerPath, ok := mapLayerPaths[layer]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMapLayer, layer)
+	}
+
+	centerX, centerY := TileCoordinates(lat, lon, zoom)
+	tiles := 1 << zoom
+	canvas := image.NewRGBA(image.Rect(0, 0, mapSpan*TileSize, mapSpan*TileSize))
+
+	base := make([]image.Image, mapSpan*mapSpan)
+	overlay := make([]image.Image, mapSpan*mapSpan)
+	appID := requestKey(ctx, c.apiKey)
+	group, groupCtx := errgroup.WithContext(ctx)
+	for i := range base {
+		// Longitude wraps around; rows beyond the poles stay blank
+		x := ((centerX+i%mapSpan-mapSpan/2)%tiles + tiles) % tiles
+		y := centerY + i/mapSpan - mapSpan/2
+		if y < 0 || y >= tiles {
+			continue
+		}
+		group.Go(func() (err error) {
+			base[i], err = c.fetchTile(groupCtx, fmt.Sprintf("%s/%d/%d/%d.png", c.mapTileURL, zoom, x, y))
+			return err
+		})
+		group.Go(func() (err error) {
+			overlay[i], err = c.fetchTile(groupCtx, fmt.Sprintf("%s/map/%s/%d/%d/%d.png?appid=%s",
+				c.weatherTileURL, layerPath, zoom, x, y, appID))
+			return err
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	for i := range base {
+		origin := image.Pt(i%mapSpan*TileSize, i/mapSpan*TileSize)
+		for _, tile := range []image.Image{base[i], overlay[i]} {
+			if tile != nil {
+				draw.Draw(canvas, image.Rectangle{Min: origin, Max: origin.Add(image.Pt(TileSize, TileSize))}, tile, tile.Bounds().Min, draw.Over)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, fmt.Errorf("failed to encode weather map: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// fetchTile downloads and decodes one PNG map tile
+func (c *Client) fetchTile(ctx context.Context, tileURL string) (image.Image, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", tileURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	// OpenStreetMap turns away tile requests that do not identify the application
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	resp, err := c.httpClient.Do(req) // #nosec G704
+	if err != nil {
+		return nil, RequestError(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, StatusError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	tile, err := png.Decode(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode map tile: %w", err)
+	}
+	return tile, nil
+}
@@ -0,0 +1,133 @@
+package weather
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTileCoordinates(t *testing.T) {
+	x, y := TileCoordinates(50.45, 30.52, 7)
+	assert.Equal(t, 74, x)
+	assert.Equal(t, 43, y)
+
+	x, y = TileCoordinates(50.45, 30.52, 0)
+	assert.Zero(t, x)
+	assert.Zero(t, y)
+
+	// Beyond the Mercator limit the edge tile is used
+	_, y = TileCoordinates(89.9, 0, 3)
+	assert.Equal(t, 0, y)
+}
+
+// solidTile encodes a tile of one colour
+func solidTile(t *testing.T, c color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, TileSize, TileSize))
+	for x := range TileSize {
+		for y := range TileSize {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestClient_GetPrecipitationTile(t *testing.T) {
+	base := solidTile(t, color.RGBA{R: 200, G: 200, B: 200, A: 255})
+	rain := solidTile(t, color.RGBA{B: 255, A: 255})
+	dry := solidTile(t, color.RGBA{})
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/map/precipitation_new/"):
+			assert.Equal(t, "test_key", r.URL.Query().Get("appid"))
+			// Rain only over the middle tile
+			if r.URL.Path == "/map/precipitation_new/7/74/43.png" {
+				_, _ = w.Write(rain)
+				return
+			}
+			_, _ = w.Write(dry)
+		default:
+			assert.Equal(t, "shopogoda-test", r.Header.Get("User-Agent"))
+			_, _ = w.Write(base)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("test_key")
+	client.weatherTileURL = server.URL
+	client.mapTileURL = server.URL + "/osm"
+	client.SetUserAgent("shopogoda-test")
+
+	data, err := client.GetPrecipitationTile(context.Background(), 50.45, 30.52, DefaultMapZoom)
+	require.NoError(t, err)
+	assert.Equal(t, int32(18), requests.Load())
+
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 3*TileSize, 3*TileSize), img.Bounds())
+	assert.Equal(t, color.RGBA{R: 200, G: 200, B: 200, A: 255}, color.RGBAModel.Convert(img.At(10, 10)))
+	assert.Equal(t, color.RGBA{B: 255, A: 255}, color.RGBAModel.Convert(img.At(TileSize+10, TileSize+10)))
+}
+
+func TestClient_GetPrecipitationTile_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient("test_key")
+	client.weatherTileURL = server.URL
+	client.mapTileURL = server.URL
+
+	_, err := client.GetPrecipitationTile(context.Background(), 50.45, 30.52, DefaultMapZoom)
+	assert.ErrorIs(t, err, ErrUnavailable)
+}
+
+func TestParseMapLayer(t *testing.T) {
+	for _, layer := range MapLayers {
+		parsed, err := ParseMapLayer(string(layer))
+		require.NoError(t, err)
+		assert.Equal(t, layer, parsed)
+	}
+
+	_, err := ParseMapLayer("clouds")
+	assert.ErrorIs(t, err, ErrUnknownMapLayer)
+}
+
+func TestClient_GetWeatherMap_Layer(t *testing.T) {
+	tile := solidTile(t, color.RGBA{})
+	var windTiles atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/map/wind_new/7/") {
+			windTiles.Add(1)
+		}
+		_, _ = w.Write(tile)
+	}))
+	defer server.Close()
+
+	client := NewClient("test_key")
+	client.weatherTileURL = server.URL
+	client.mapTileURL = server.URL
+
+	_, err := client.GetWeatherMap(context.Background(), 50.45, 30.52, DefaultMapZoom, MapLayerWind)
+	require.NoError(t, err)
+	assert.Equal(t, int32(9), windTiles.Load())
+
+	_, err = client.GetWeatherMap(context.Background(), 50.45, 30.52, DefaultMapZoom, "clouds")
+	assert.ErrorIs(t, err, ErrUnknownMapLayer)
+}
@@ -0,0 +1,141 @@
+package weather
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// clearSkyMaxUV is the UV index with the sun overhead and no clouds
+	clearSkyMaxUV = 12.5
+	// overcastUVShare is the share of UV that still gets through a sky fully
+	// covered by clouds
+	overcastUVShare = 0.4
+
+	// erythemalWattsPerIndex is the erythemally weighted irradiance of one
+	// unit of the UV index, in W/m²
+	erythemalWattsPerIndex = 0.025
+	// minBurningUV is the UV index below which no skin type burns in a day
+	minBurningUV = 1.0
+)
+
+// Fitzpatrick skin types run from 1, always burns, to 6, never burns
+const (
+	MinSkinType     = 1
+	MaxSkinType     = 6
+	DefaultSkinType = 3
+)
+
+// minimalErythemaDoses is the erythemal dose, in J/m², that reddens
+// unprotected skin of each Fitzpatrick type
+var minimalErythemaDoses = [MaxSkinType + 1]float64{1: 200, 2: 250, 3: 300, 4: 450, 5: 600, 6: 1000}
+
+// uvBars draw a UV index from 0 to 11 and above as a bar of growing height
+var uvBars = []rune("▁▂▃▄▅▆▇█")
+
+// UVHour is the estimated UV index of an hour
+type UVHour struct {
+	Time    time.Time // UTC start of the hour
+	UVIndex float64
+}
+
+// ClearSkyUVIndex estimates the UV index under a cloudless sky from the
+// elevation of the sun: zero while it is down, 12.5 with it overhead
+func ClearSkyUVIndex(t time.Time, lat, lon float64) float64 {
+	elevation := SunElevation(t, lat, lon)
+	if elevation <= 0 {
+		return 0
+	}
+	return clearSkyMaxUV * math.Pow(math.Sin(elevation*math.Pi/180), 2.42)
+}
+
+// EstimateUVIndex estimates the UV index at the coordinates at t with clouds
+// percent of the sky covered. Broken clouds let most UV through; an overcast
+// sky still lets through about 40%.
+func EstimateUVIndex(t time.Time, lat, lon float64, clouds int) float64 {
+	cover := math.Min(math.Max(float64(clouds)/100, 0), 1)
+	return ClearSkyUVIndex(t, lat, lon) * (1 - (1-overcastUVShare)*cover*cover)
+}
+
+// HourlyUVIndex estimates the UV index of each of the hours starting at the
+// full hour after from, with the cloud cover of the forecast slot covering it
+func HourlyUVIndex(forecast *HourlyForecastData, lat, lon float64, from time.Time, hours int) []UVHour {
+	start := from.UTC().Truncate(time.Hour).Add(time.Hour)
+	result := make([]UVHour, 0, hours)
+	for i := 0; i < hours; i++ {
+		hour := start.Add(time.Duration(i) * time.Hour)
+		result = append(result, UVHour{Time: hour, UVIndex: EstimateUVIndex(hour, lat, lon, slotClouds(forecast, hour))})
+	}
+	return result
+}
+
+// slotClouds returns the cloud cover of the last forecast slot starting at or
+// before t, or of the first slot when t is before all of them
+func slotClouds(forecast *HourlyForecastData, t time.Time) int {
+	if forecast == nil || len(forecast.Slots) == 0 {
+		return 0
+	}
+	clouds := forecast.Slots[0].Clouds
+	for _, slot := range forecast.Slots {
+		if slot.Time.After(t) {
+			break
+		}
+		clouds = slot.Clouds
+	}
+	return clouds
+}
+
+// clampSkinType keeps a skin type within the Fitzpatrick scale, treating an
+// unset one as the default
+func clampSkinType(skinType int) int {
+	if skinType < MinSkinType || skinType > MaxSkinType {
+		return DefaultSkinType
+	}
+	return skinType
+}
+
+// SafeExposure returns how long unprotected skin of the Fitzpatrick type can
+// stay in the sun at the UV index before it starts to burn, rounded down to
+// the minute. It is zero when the UV index is too low to burn at all.
+func SafeExposure(uv float64, skinType int) time.Duration {
+	if uv < minBurningUV {
+		return 0
+	}
+	seconds := minimalErythemaDoses[clampSkinType(skinType)] / (uv * erythemalWattsPerIndex)
+	return (time.Duration(seconds) * time.Second).Truncate(time.Minute)
+}
+
+// RecommendedSPF returns the sun protection factor of sunscreen recommended
+// for skin of the Fitzpatrick type at the UV index, or 0 when none is needed
+func RecommendedSPF(uv float64, skinType int) int {
+	skinType = clampSkinType(skinType)
+	fair := skinType <= 2
+	switch {
+	case uv < minBurningUV:
+		return 0
+	case uv < 3:
+		if fair {
+			return 15
+		}
+		return 0
+	case uv < 6:
+		if skinType <= 3 {
+			return 30
+		}
+		return 15
+	case uv < 8:
+		if fair {
+			return 50
+		}
+		return 30
+	default:
+		return 50
+	}
+}
+
+// UVBar draws a UV index as a block character, from ▁ for 0 to █ for 11
+// and above
+func UVBar(uv float64) string {
+	i := int(math.Round(uv * float64(len(uvBars)-1) / 11))
+	return string(uvBars[min(max(i, 0), len(uvBars)-1)])
+}
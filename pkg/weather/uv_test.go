@@ -0,0 +1,84 @@
+package weather
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSunElevation(t *testing.T) {
+	// Solar noon at the equinox on the equator: the sun is nearly overhead
+	noon := time.Date(2024, 3, 20, 12, 7, 0, 0, time.UTC)
+	assert.InDelta(t, 90, SunElevation(noon, 0, 0), 1)
+
+	// London at midsummer noon: 90 - 51.5 + 23.4
+	assert.InDelta(t, 62, SunElevation(time.Date(2024, 6, 21, 12, 2, 0, 0, time.UTC), 51.5074, -0.1278), 1)
+
+	// Kyiv at midnight
+	assert.Less(t, SunElevation(time.Date(2024, 6, 21, 21, 0, 0, 0, time.UTC), 50.4501, 30.5234), 0.0)
+}
+
+func TestEstimateUVIndex(t *testing.T) {
+	noon := time.Date(2024, 3, 20, 12, 7, 0, 0, time.UTC)
+	assert.InDelta(t, 12.5, ClearSkyUVIndex(noon, 0, 0), 0.2)
+	assert.InDelta(t, 12.5, EstimateUVIndex(noon, 0, 0, 0), 0.2)
+	assert.InDelta(t, 5, EstimateUVIndex(noon, 0, 0, 100), 0.1, "overcast keeps 40%")
+	assert.Greater(t, EstimateUVIndex(noon, 0, 0, 30), 11.5, "scattered clouds barely matter")
+
+	midnight := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+	assert.Zero(t, EstimateUVIndex(midnight, 0, 0, 0))
+}
+
+func TestHourlyUVIndex(t *testing.T) {
+	start := time.Date(2024, 3, 20, 9, 0, 0, 0, time.UTC)
+	forecast := &HourlyForecastData{Slots: []HourlyForecast{
+		{Time: start, Clouds: 0},
+		{Time: start.Add(3 * time.Hour), Clouds: 100},
+	}}
+
+	hours := HourlyUVIndex(forecast, 0, 0, start.Add(30*time.Minute), 6)
+	assert.Len(t, hours, 6)
+	assert.Equal(t, start.Add(time.Hour), hours[0].Time, "starts at the next full hour")
+	assert.InDelta(t, ClearSkyUVIndex(hours[1].Time, 0, 0), hours[1].UVIndex, 0.001, "11:00 is in the clear slot")
+	assert.InDelta(t, 0.4*ClearSkyUVIndex(hours[2].Time, 0, 0), hours[2].UVIndex, 0.001, "12:00 is in the overcast slot")
+
+	assert.Len(t, HourlyUVIndex(nil, 0, 0, start, 3), 3, "without a forecast the sky is taken as clear")
+}
+
+func TestSafeExposure(t *testing.T) {
+	assert.Zero(t, SafeExposure(0.5, 1), "too little UV to burn")
+	assert.Equal(t, 33*time.Minute, SafeExposure(6, 3))
+	assert.Equal(t, 22*time.Minute, SafeExposure(6, 1))
+	assert.Equal(t, 111*time.Minute, SafeExposure(6, 6))
+	assert.Equal(t, SafeExposure(6, DefaultSkinType), SafeExposure(6, 0), "unset skin type")
+	assert.Less(t, SafeExposure(11, 3), SafeExposure(6, 3))
+}
+
+func TestRecommendedSPF(t *testing.T) {
+	tests := []struct {
+		uv       float64
+		skinType int
+		spf      int
+	}{
+		{0.5, 1, 0},
+		{2, 1, 15},
+		{2, 4, 0},
+		{4, 3, 30},
+		{4, 5, 15},
+		{7, 2, 50},
+		{7, 6, 30},
+		{9, 6, 50},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.spf, RecommendedSPF(tt.uv, tt.skinType), "UV %.1f, skin type %d", tt.uv, tt.skinType)
+	}
+}
+
+func TestUVBar(t *testing.T) {
+	assert.Equal(t, "▁", UVBar(0))
+	assert.Equal(t, "▅", UVBar(6))
+	assert.Equal(t, "█", UVBar(11))
+	assert.Equal(t, "█", UVBar(14))
+	assert.Equal(t, "▁", UVBar(-1))
+}
@@ -0,0 +1,83 @@
+package weather
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WarningSeverity is the severity of an official warning, on the scale of
+// the Common Alerting Protocol that national weather services issue in
+type WarningSeverity string
+
+const (
+	WarningUnknown  WarningSeverity = "unknown"
+	WarningMinor    WarningSeverity = "minor"
+	WarningModerate WarningSeverity = "moderate"
+	WarningSevere   WarningSeverity = "severe"
+	WarningExtreme  WarningSeverity = "extreme"
+)
+
+// WeatherWarning is an official severe weather warning covering a place, as
+// issued by a national weather service. Event and Description are the
+// issuer's own words.
+type WeatherWarning struct {
+	ID          string          `json:"id"` // Stable across requests for the same warning
+	Event       string          `json:"event"`
+	Sender      string          `json:"sender"`
+	Severity    WarningSeverity `json:"severity"`
+	Start       time.Time       `json:"start"` // UTC
+	End         time.Time       `json:"end"`   // UTC
+	Description string          `json:"description"`
+}
+
+// InEffect reports whether the warning has not ended by t. Warnings issued
+// ahead of the weather count from the moment they are issued.
+func (w *WeatherWarning) InEffect(t time.Time) bool {
+	return t.Before(w.End)
+}
+
+// warningID identifies a warning by its issuer, event and start. Providers
+// give no ID of their own, and these stay the same while the warning is
+// reissued with an updated text.
+func warningID(sender, event string, start time.Time) string {
+	sum := sha256.Sum256([]byte(sender + "\x00" + event + "\x00" + strconv.FormatInt(start.Unix(), 10)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// warningSeverityWords map words of an event name to a severity, the most
+// severe first. Europe colours its warnings; North America ranks warnings
+// above watches and advisories.
+var warningSeverityWords = []struct {
+	word     string
+	severity WarningSeverity
+}{
+	{"extreme", WarningExtreme},
+	{"red", WarningExtreme},
+	{"orange", WarningSevere},
+	{"amber", WarningSevere},
+	{"yellow", WarningModerate},
+	{"warning", WarningSevere},
+	{"watch", WarningModerate},
+	{"advisory", WarningModerate},
+	{"statement", WarningMinor},
+	{"green", WarningMinor},
+}
+
+// WarningSeverityOf rates a warning by its event name, as OpenWeather passes
+// warnings on without their severity
+func WarningSeverityOf(event string) WarningSeverity {
+	words := strings.FieldsFunc(strings.ToLower(event), func(r rune) bool {
+		return !('a' <= r && r <= 'z')
+	})
+	for _, candidate := range warningSeverityWords {
+		for _, word := range words {
+			if word == candidate.word {
+				return candidate.severity
+			}
+		}
+	}
+	return WarningUnknown
+}
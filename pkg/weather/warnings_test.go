@@ -0,0 +1,54 @@
+package weather
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarningSeverityOf(t *testing.T) {
+	tests := []struct {
+		event string
+		want  WarningSeverity
+	}{
+		{"Red Rain Warning", WarningExtreme},
+		{"Extreme wind", WarningExtreme},
+		{"Orange Wind Warning", WarningSevere},
+		{"Amber warning for snow", WarningSevere},
+		{"Yellow Thunderstorm Warning", WarningModerate},
+		{"Tornado Warning", WarningSevere},
+		{"Winter Storm Watch", WarningModerate},
+		{"Heat Advisory", WarningModerate},
+		{"Special Weather Statement", WarningMinor},
+		{"Frost", WarningUnknown},
+		{"Redwood fire danger", WarningUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.event, func(t *testing.T) {
+			assert.Equal(t, tt.want, WarningSeverityOf(tt.event))
+		})
+	}
+}
+
+func TestWarningID(t *testing.T) {
+	start := time.Date(2026, 4, 15, 5, 0, 0, 0, time.UTC)
+	id := warningID("UHMC", "Orange Wind Warning", start)
+
+	assert.Len(t, id, 16)
+	assert.Equal(t, id, warningID("UHMC", "Orange Wind Warning", start.In(time.FixedZone("EEST", 3*3600))))
+	assert.NotEqual(t, id, warningID("UHMC", "Orange Wind Warning", start.Add(time.Hour)))
+	assert.NotEqual(t, id, warningID("UHMC", "Red Wind Warning", start))
+}
+
+func TestWeatherWarning_InEffect(t *testing.T) {
+	warning := WeatherWarning{
+		Start: time.Date(2026, 4, 15, 5, 0, 0, 0, time.UTC),
+		End:   time.Date(2026, 4, 15, 21, 0, 0, 0, time.UTC),
+	}
+
+	assert.True(t, warning.InEffect(warning.Start.Add(-2*time.Hour)), "issued ahead of the weather")
+	assert.True(t, warning.InEffect(warning.Start.Add(time.Hour)))
+	assert.False(t, warning.InEffect(warning.End))
+}
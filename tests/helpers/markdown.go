@@ -0,0 +1,97 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MarkdownEntity is an entity of a parsed Markdown message, located by byte
+// offsets into its plain text
+type MarkdownEntity struct {
+	Type   string // bold, italic, code, pre or text_link
+	Offset int
+	Length int
+}
+
+// markdownEntityTypes maps the characters that open an entity to its type
+var markdownEntityTypes = map[byte]string{'*': "bold", '_': "italic", '`': "code", '[': "text_link"}
+
+// ParseMarkdown parses text the way Telegram parses its legacy Markdown mode
+// and returns what the reader sees: the plain text and its entities. As in
+// Telegram, a backslash escapes a delimiter only outside an entity; inside
+// one, everything up to the closing delimiter is shown as is. An entity
+// that never closes is an error, which Telegram reports as "can't parse
+// entities" and rejects the message for.
+func ParseMarkdown(text string) (string, []MarkdownEntity, error) {
+	var plain strings.Builder
+	var entities []MarkdownEntity
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c == '\\' && i+1 < len(text) && strings.IndexByte("_*`[", text[i+1]) >= 0 {
+			plain.WriteByte(text[i+1])
+			i++
+			continue
+		}
+		entityType, ok := markdownEntityTypes[c]
+		if !ok {
+			plain.WriteByte(c)
+			continue
+		}
+
+		begin := i
+		closing := string(c)
+		switch {
+		case c == '[':
+			closing = "]"
+		case strings.HasPrefix(text[i:], "```"):
+			entityType, closing = "pre", "```"
+			i += 2
+		}
+		end := strings.Index(text[i+1:], closing)
+		if end < 0 {
+			return "", nil, fmt.Errorf("can't find end of the entity starting at byte offset %d", begin)
+		}
+		content := text[i+1 : i+1+end]
+		i += end + len(closing)
+
+		// Brackets without a URL after them are a link to nothing, shown as text
+		linked := true
+		if c == '[' {
+			linked = strings.HasPrefix(text[i+1:], "(")
+			if linked {
+				urlEnd := strings.IndexByte(text[i+1:], ')')
+				if urlEnd < 0 {
+					return "", nil, fmt.Errorf("can't find end of the URL of the entity starting at byte offset %d", begin)
+				}
+				i += urlEnd + 1
+			}
+		}
+		// Telegram drops an empty entity, such as the one "**" opens and closes
+		if content != "" && linked {
+			entities = append(entities, MarkdownEntity{Type: entityType, Offset: plain.Len(), Length: len(content)})
+		}
+		plain.WriteString(content)
+	}
+	return plain.String(), entities, nil
+}
+
+// MarkdownIsBold reports whether the bytes from offset up to end of a parsed
+// message are bold, leaving out asterisks, which can only be shown unbolded
+func MarkdownIsBold(plain string, entities []MarkdownEntity, offset, end int) bool {
+	for i := offset; i < end; i++ {
+		if plain[i] == '*' {
+			continue
+		}
+		bold := false
+		for _, entity := range entities {
+			if entity.Type == "bold" && entity.Offset <= i && i < entity.Offset+entity.Length {
+				bold = true
+				break
+			}
+		}
+		if !bold {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMarkdown(t *testing.T) {
+	t.Run("entities and escapes", func(t *testing.T) {
+		plain, entities, err := ParseMarkdown("*Kyiv* is \\*nice\\_ [here](https://example.com) `a_b`")
+		require.NoError(t, err)
+		assert.Equal(t, "Kyiv is *nice_ here a_b", plain)
+		assert.Equal(t, []MarkdownEntity{
+			{Type: "bold", Offset: 0, Length: 4},
+			{Type: "text_link", Offset: 15, Length: 4},
+			{Type: "code", Offset: 20, Length: 3},
+		}, entities)
+	})
+
+	t.Run("backslash inside an entity is shown", func(t *testing.T) {
+		plain, _, err := ParseMarkdown(`*Las Vegas\_NV*`)
+		require.NoError(t, err)
+		assert.Equal(t, `Las Vegas\_NV`, plain)
+	})
+
+	t.Run("asterisk reopened after an escape", func(t *testing.T) {
+		plain, entities, err := ParseMarkdown(`*A*\**B*`)
+		require.NoError(t, err)
+		assert.Equal(t, "A*B", plain)
+		assert.True(t, MarkdownIsBold(plain, entities, 0, 3))
+
+		plain, entities, err = ParseMarkdown(`*A*\*B`)
+		require.NoError(t, err)
+		assert.Equal(t, "A*B", plain)
+		assert.False(t, MarkdownIsBold(plain, entities, 0, 3))
+	})
+
+	t.Run("unclosed entity", func(t *testing.T) {
+		_, _, err := ParseMarkdown("Las Vegas_NV")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,51 @@
+package helpers
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/pkg/metrics"
+)
+
+// NewTestMetrics creates a metrics collector registered with a registry of its
+// own, which the test can scrape
+func NewTestMetrics() (*metrics.Metrics, *prometheus.Registry) {
+	registry := prometheus.NewRegistry()
+	prometheus.DefaultRegisterer = registry
+	return metrics.New(), registry
+}
+
+// ScrapeMetrics gathers a registry as the metrics endpoint would, keyed by
+// name and labels like name{k="v"} with labels in name order. Counters and
+// gauges give their value and histograms their sample count.
+func ScrapeMetrics(t *testing.T, registry *prometheus.Registry) map[string]float64 {
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	series := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := make([]string, 0, len(metric.GetLabel()))
+			for _, label := range metric.GetLabel() {
+				labels = append(labels, label.GetName()+`="`+label.GetValue()+`"`)
+			}
+			sort.Strings(labels)
+			key := family.GetName() + "{" + strings.Join(labels, ",") + "}"
+
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				series[key] = metric.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				series[key] = metric.GetGauge().GetValue()
+			case dto.MetricType_HISTOGRAM:
+				series[key] = float64(metric.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return series
+}
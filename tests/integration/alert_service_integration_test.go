@@ -330,7 +330,7 @@ func TestIntegration_AlertServiceCheckAlerts(t *testing.T) {
 	}
 
 	t.Run("check alerts and trigger temperature alert", func(t *testing.T) {
-		triggeredAlerts, err := suite.alertService.CheckAlerts(ctx, weatherData, suite.testUserID)
+		triggeredAlerts, err := suite.alertService.CheckAlerts(ctx, weatherData, &models.User{ID: suite.testUserID})
 		require.NoError(t, err)
 
 		// Should trigger temperature alert, not humidity alert
@@ -355,7 +355,7 @@ func TestIntegration_AlertServiceCheckAlerts(t *testing.T) {
 
 		weatherData.Humidity = 80 // Now above threshold
 
-		triggeredAlerts, err := suite.alertService.CheckAlerts(ctx, weatherData, suite.testUserID)
+		triggeredAlerts, err := suite.alertService.CheckAlerts(ctx, weatherData, &models.User{ID: suite.testUserID})
 		require.NoError(t, err)
 
 		// Should trigger both alerts
@@ -383,7 +383,7 @@ func TestIntegration_AlertServiceCheckAlerts(t *testing.T) {
 		humidAlert.IsActive = false
 		require.NoError(t, suite.db.Save(humidAlert).Error)
 
-		triggeredAlerts, err := suite.alertService.CheckAlerts(ctx, weatherData, suite.testUserID)
+		triggeredAlerts, err := suite.alertService.CheckAlerts(ctx, weatherData, &models.User{ID: suite.testUserID})
 		require.NoError(t, err)
 
 		// Should only trigger temperature alert (humidity is inactive)
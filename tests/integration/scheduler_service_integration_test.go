@@ -0,0 +1,112 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valpere/shopogoda/internal/models"
+	"github.com/valpere/shopogoda/internal/services"
+)
+
+func TestIntegration_SchedulerDueSubscriptions(t *testing.T) {
+	suite := setupSubscriptionServiceTest(t)
+	defer suite.teardown(t)
+
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	scheduler := services.NewSchedulerService(suite.db, suite.redisClient, nil, nil, nil, &logger)
+
+	kyivUser := &models.User{
+		ID:           87654322,
+		Username:     "scheduler_kyiv",
+		FirstName:    "Kyiv",
+		Language:     "uk-UA",
+		LocationName: "Kyiv, Ukraine",
+		Latitude:     50.4501,
+		Longitude:    30.5234,
+		Timezone:     "Europe/Kyiv",
+		Role:         models.RoleUser,
+		IsActive:     true,
+	}
+	require.NoError(t, suite.db.Create(kyivUser).Error)
+	noLocationUser := &models.User{
+		ID:        87654323,
+		Username:  "scheduler_nowhere",
+		FirstName: "Nowhere",
+		Language:  "en-US",
+		Timezone:  "Europe/Kyiv",
+		Role:      models.RoleUser,
+		IsActive:  true,
+	}
+	require.NoError(t, suite.db.Create(noLocationUser).Error)
+
+	create := func(userID int64, subType models.SubscriptionType, frequency models.Frequency, timeOfDay string) *models.Subscription {
+		subscription, err := suite.subscriptionService.CreateSubscription(ctx, userID, subType, frequency, timeOfDay)
+		require.NoError(t, err)
+		return subscription
+	}
+	kyivDaily := create(kyivUser.ID, models.SubscriptionDaily, models.FrequencyDaily, "08:00")
+	kyivWeekly := create(kyivUser.ID, models.SubscriptionWeekly, models.FrequencyWeekly, "08:00")
+	create(kyivUser.ID, models.SubscriptionAlerts, models.FrequencyHourly, "08:00")
+	newYorkEarly := create(suite.testUserID, models.SubscriptionDaily, models.FrequencyDaily, "01:00")
+	create(suite.testUserID, models.SubscriptionDaily, models.FrequencyDaily, "08:00")
+	create(noLocationUser.ID, models.SubscriptionDaily, models.FrequencyDaily, "08:00")
+	cancelled := create(kyivUser.ID, models.SubscriptionDaily, models.FrequencyDaily, "08:00")
+	require.NoError(t, suite.subscriptionService.DeleteSubscription(ctx, kyivUser.ID, cancelled.ID))
+
+	// Monday 05:02 UTC is 08:02 in Kyiv and 01:02 in New York
+	now := time.Date(2025, 6, 2, 5, 2, 0, 0, time.UTC)
+
+	due, err := scheduler.DueSubscriptions(ctx, now)
+	require.NoError(t, err)
+
+	ids := make([]string, 0, len(due))
+	for _, subscription := range due {
+		ids = append(ids, subscription.ID.String())
+		assert.NotZero(t, subscription.User.ID, "the user is loaded with the subscription")
+	}
+	assert.ElementsMatch(t, []string{kyivDaily.ID.String(), kyivWeekly.ID.String(), newYorkEarly.ID.String()}, ids)
+
+	t.Run("a timezone change moves the next notification", func(t *testing.T) {
+		require.NoError(t, suite.db.Model(&models.User{}).Where("id = ?", kyivUser.ID).Update("timezone", "UTC").Error)
+
+		due, err := scheduler.DueSubscriptions(ctx, now)
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+		assert.Equal(t, newYorkEarly.ID, due[0].ID)
+
+		due, err = scheduler.DueSubscriptions(ctx, now.Add(3*time.Hour))
+		require.NoError(t, err)
+		assert.Len(t, due, 2, "both Kyiv subscriptions at 08:00 UTC")
+	})
+}
+
+func TestIntegration_SchedulerStopsOnCancellation(t *testing.T) {
+	suite := setupSubscriptionServiceTest(t)
+	defer suite.teardown(t)
+
+	logger := zerolog.Nop()
+	scheduler := services.NewSchedulerService(suite.db, suite.redisClient, nil, nil, nil, &logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		scheduler.Start(ctx)
+		close(stopped)
+	}()
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduler did not stop after its context was cancelled")
+	}
+}
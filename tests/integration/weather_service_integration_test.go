@@ -198,7 +198,7 @@ func TestIntegration_WeatherServiceGetCurrentWeather(t *testing.T) {
 
 	t.Run("get current weather from API", func(t *testing.T) {
 		// First call - should hit API (we can't easily mock, so we test cache behavior)
-		cacheKey := fmt.Sprintf("weather:current:%.4f:%.4f", lat, lon)
+		cacheKey := fmt.Sprintf("weather:current:%.2f:%.2f", lat, lon)
 
 		// Ensure cache is empty
 		suite.redisClient.Del(ctx, cacheKey)
@@ -232,7 +232,7 @@ func TestIntegration_WeatherServiceGetCurrentWeather(t *testing.T) {
 	})
 
 	t.Run("get current weather from cache", func(t *testing.T) {
-		cacheKey := fmt.Sprintf("weather:current:%.4f:%.4f", lat, lon)
+		cacheKey := fmt.Sprintf("weather:current:%.2f:%.2f", lat, lon)
 
 		// Populate cache with test data
 		testData := &weather.WeatherData{
@@ -260,7 +260,7 @@ func TestIntegration_WeatherServiceGetForecast(t *testing.T) {
 	days := 5
 
 	t.Run("forecast caching behavior", func(t *testing.T) {
-		cacheKey := fmt.Sprintf("weather:forecast:%.4f:%.4f:%d", lat, lon, days)
+		cacheKey := fmt.Sprintf("weather:forecast:%.2f:%.2f:%d", lat, lon, days)
 
 		// Populate cache with test forecast data
 		testForecast := &weather.ForecastData{
@@ -299,7 +299,7 @@ func TestIntegration_WeatherServiceGetAirQuality(t *testing.T) {
 	lat, lon := 50.4501, 30.5234
 
 	t.Run("air quality caching behavior", func(t *testing.T) {
-		cacheKey := fmt.Sprintf("weather:air:%.4f:%.4f", lat, lon)
+		cacheKey := fmt.Sprintf("weather:air:%.2f:%.2f", lat, lon)
 
 		// Populate cache with test air quality data
 		testAirData := &weather.AirQualityData{
@@ -393,8 +393,8 @@ func TestIntegration_WeatherServiceGetCompleteWeatherData(t *testing.T) {
 
 	t.Run("get complete weather data with air quality", func(t *testing.T) {
 		// Populate both weather and air quality caches
-		weatherCacheKey := fmt.Sprintf("weather:current:%.4f:%.4f", lat, lon)
-		airCacheKey := fmt.Sprintf("weather:air:%.4f:%.4f", lat, lon)
+		weatherCacheKey := fmt.Sprintf("weather:current:%.2f:%.2f", lat, lon)
+		airCacheKey := fmt.Sprintf("weather:air:%.2f:%.2f", lat, lon)
 
 		testWeather := &weather.WeatherData{
 			Temperature:   15.5,
@@ -440,11 +440,11 @@ func TestIntegration_WeatherServiceGetCompleteWeatherData(t *testing.T) {
 
 	t.Run("get complete weather data with missing air quality", func(t *testing.T) {
 		// Clear air quality cache to test fallback
-		airCacheKey := fmt.Sprintf("weather:air:%.4f:%.4f", lat, lon)
+		airCacheKey := fmt.Sprintf("weather:air:%.2f:%.2f", lat, lon)
 		suite.redisClient.Del(ctx, airCacheKey)
 
 		// Populate only weather cache
-		weatherCacheKey := fmt.Sprintf("weather:current:%.4f:%.4f", lat, lon)
+		weatherCacheKey := fmt.Sprintf("weather:current:%.2f:%.2f", lat, lon)
 		testWeather := &weather.WeatherData{
 			Temperature: 18.0,
 			Humidity:    70,
@@ -507,7 +507,7 @@ func TestIntegration_WeatherServiceCacheTTL(t *testing.T) {
 	lat, lon := 50.4501, 30.5234
 
 	t.Run("weather cache has 10 minute TTL", func(t *testing.T) {
-		cacheKey := fmt.Sprintf("weather:current:%.4f:%.4f", lat, lon)
+		cacheKey := fmt.Sprintf("weather:current:%.2f:%.2f", lat, lon)
 		testData := &weather.WeatherData{Temperature: 15.0}
 		weatherJSON, _ := json.Marshal(testData)
 		suite.redisClient.Set(ctx, cacheKey, weatherJSON, 10*time.Minute)
@@ -520,7 +520,7 @@ func TestIntegration_WeatherServiceCacheTTL(t *testing.T) {
 	})
 
 	t.Run("forecast cache has 1 hour TTL", func(t *testing.T) {
-		cacheKey := fmt.Sprintf("weather:forecast:%.4f:%.4f:5", lat, lon)
+		cacheKey := fmt.Sprintf("weather:forecast:%.2f:%.2f:5", lat, lon)
 		testData := &weather.ForecastData{Location: "Test"}
 		forecastJSON, _ := json.Marshal(testData)
 		suite.redisClient.Set(ctx, cacheKey, forecastJSON, time.Hour)
@@ -533,7 +533,7 @@ func TestIntegration_WeatherServiceCacheTTL(t *testing.T) {
 	})
 
 	t.Run("air quality cache has 30 minute TTL", func(t *testing.T) {
-		cacheKey := fmt.Sprintf("weather:air:%.4f:%.4f", lat, lon)
+		cacheKey := fmt.Sprintf("weather:air:%.2f:%.2f", lat, lon)
 		testData := &weather.AirQualityData{AQI: 2}
 		airJSON, _ := json.Marshal(testData)
 		suite.redisClient.Set(ctx, cacheKey, airJSON, 30*time.Minute)